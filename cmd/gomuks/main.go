@@ -23,6 +23,7 @@ import (
 	"github.com/chzyer/readline"
 	"go.mau.fi/util/exhttp"
 	flag "maunium.net/go/mauflag"
+	"maunium.net/go/mautrix/id"
 
 	"go.mau.fi/gomuks/pkg/gomuks"
 	"go.mau.fi/gomuks/pkg/hicli"
@@ -32,6 +33,10 @@ import (
 
 var wantHelp, _ = flag.MakeHelpFlag()
 var wantVersion = flag.MakeFull("v", "version", "View gomuks version and quit.", "false").Bool()
+var importHistory = flag.Make().LongKey("import-history").Usage("Import an Element-style room export JSON file into the local database and quit.").String()
+var importRoom = flag.Make().LongKey("import-room").Usage("Room ID to import history into. Defaults to the room ID in the export file.").String()
+var headless = flag.Make().LongKey("headless").Usage("Run only the backend without serving the web frontend. Supports systemd socket activation.").Default("false").Bool()
+var healthcheck = flag.Make().LongKey("healthcheck").Usage("Check the health endpoint of a running gomuks instance and exit with 0 if it's healthy or 1 if not.").Default("false").Bool()
 
 func main() {
 	gomuks.PromptInput = readline.Line
@@ -40,7 +45,7 @@ func main() {
 	exhttp.AutoAllowCORS = false
 	flag.SetHelpTitles(
 		"gomuks - A Matrix client written in Go.",
-		"gomuks [-hv]",
+		"gomuks [-hv] [--headless] [--healthcheck] [--import-history <path> [--import-room <room ID>]]",
 	)
 	err := flag.Parse()
 
@@ -57,6 +62,16 @@ func main() {
 	}
 
 	gmx := gomuks.NewGomuks()
+	if *healthcheck {
+		gmx.RunHealthcheckAndExit()
+	}
 	gmx.FrontendFS = web.Frontend
-	gmx.Run()
+	if *importHistory != "" {
+		gmx.RunImportHistory(*importHistory, id.RoomID(*importRoom))
+	}
+	if *headless {
+		gmx.RunHeadless()
+	} else {
+		gmx.Run()
+	}
 }
@@ -80,6 +80,20 @@ func jsMessageListener(_ js.Value, args []js.Value) any {
 			}
 		}()
 		return nil
+	} else if isStorageCommand(wrappedCmd.Command) {
+		go func() {
+			ctx := gmx.Log.With().
+				Str("action", "wasmuks storage command").
+				Stringer("command", wrappedCmd.Command).
+				Logger().WithContext(context.Background())
+			resp, err := handleStorageCommand(ctx, wrappedCmd)
+			if err != nil {
+				postMessage(jsoncmd.RespError, wrappedCmd.RequestID, err.Error())
+			} else {
+				postMessage(jsoncmd.RespSuccess, wrappedCmd.RequestID, resp)
+			}
+		}()
+		return nil
 	}
 	go func() {
 		resp := gmx.Client.SubmitJSONCommand(context.Background(), wrappedCmd)
@@ -123,7 +137,7 @@ func main() {
 		Str("go_version", runtime.Version()).
 		Time("built_at", version.Gomuks.BuildTime).
 		Msg("Initializing gomuks in wasm")
-	gmx.StartClient()
+	startClient()
 	gmx.Log.Info().Msg("Initialization complete")
 	postMessage(jsoncmd.EventClientState, 0, gmx.Client.State())
 	postMessage(jsoncmd.EventSyncStatus, 0, gmx.Client.SyncStatus.Load())
@@ -0,0 +1,161 @@
+// gomuks - A Matrix client written in Go.
+// Copyright (C) 2025 Tulir Asokan
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+//go:build js
+
+package main
+
+import (
+	"context"
+	"fmt"
+	"syscall/js"
+
+	"github.com/rs/zerolog"
+
+	"go.mau.fi/gomuks/pkg/hicli"
+	"go.mau.fi/gomuks/pkg/hicli/jsoncmd"
+	sqlite_wasm_js "go.mau.fi/gomuks/pkg/sqlite-wasm-js"
+)
+
+const getDatabaseSizeQuery = `
+	SELECT page_count * page_size, freelist_count * page_size
+	FROM pragma_page_count(), pragma_page_size(), pragma_freelist_count()
+`
+
+func isStorageCommand(cmd jsoncmd.Name) bool {
+	switch cmd {
+	case jsoncmd.ReqGetStorageStats, jsoncmd.ReqCompactStorage, jsoncmd.ReqWipeStorage:
+		return true
+	default:
+		return false
+	}
+}
+
+func handleStorageCommand(ctx context.Context, req *hicli.JSONCommand) (any, error) {
+	switch req.Command {
+	case jsoncmd.ReqGetStorageStats:
+		return jsoncmd.GetStorageStats.RunCtx(ctx, req.Data, getStorageStats)
+	case jsoncmd.ReqCompactStorage:
+		return jsoncmd.CompactStorage.RunCtx(ctx, req.Data, compactStorage)
+	case jsoncmd.ReqWipeStorage:
+		return jsoncmd.WipeStorage.RunCtx(ctx, req.Data, wipeStorage)
+	default:
+		return nil, fmt.Errorf("unknown storage command %q", req.Command)
+	}
+}
+
+func getStorageStats(ctx context.Context) (*jsoncmd.StorageStats, error) {
+	stats := &jsoncmd.StorageStats{MediaCacheSize: -1}
+	err := gmx.Client.DB.QueryRow(ctx, getDatabaseSizeQuery).Scan(&stats.DatabaseSize, &stats.DatabaseFreeSize)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get database size: %w", err)
+	}
+	stats.DatabaseFiles, err = sqlite_wasm_js.FileNames()
+	if err != nil {
+		return nil, err
+	}
+	estimate, err := sqlite_wasm_js.AwaitPromise(js.Global().Call("meowStorageEstimate"))
+	if err != nil {
+		zerolog.Ctx(ctx).Warn().Err(err).Msg("Failed to get storage estimate")
+	} else {
+		stats.Usage = int64(estimate.Get("usage").Float())
+		stats.Quota = int64(estimate.Get("quota").Float())
+	}
+	mediaCache, err := sqlite_wasm_js.AwaitPromise(js.Global().Call("meowMediaCacheStats"))
+	if err != nil {
+		zerolog.Ctx(ctx).Warn().Err(err).Msg("Failed to get media cache size")
+	} else {
+		stats.MediaCacheSize = int64(mediaCache.Get("size").Float())
+		stats.MediaCacheEntries = mediaCache.Get("entries").Int()
+	}
+	return stats, nil
+}
+
+type compactionStep struct {
+	message string
+	fn      func(ctx context.Context) error
+}
+
+func execStep(query string) func(ctx context.Context) error {
+	return func(ctx context.Context) error {
+		_, err := gmx.Client.DB.Exec(ctx, query)
+		return err
+	}
+}
+
+func clearMediaCache(ctx context.Context) error {
+	deleted, err := sqlite_wasm_js.AwaitPromise(js.Global().Call("meowClearMediaCache"))
+	if err != nil {
+		return err
+	}
+	zerolog.Ctx(ctx).Debug().Int("deleted_entries", deleted.Int()).Msg("Cleared media cache")
+	return nil
+}
+
+func compactStorage(ctx context.Context, params *jsoncmd.CompactStorageParams) (*jsoncmd.StorageStats, error) {
+	steps := []compactionStep{
+		{"Checkpointing write-ahead log", execStep("PRAGMA wal_checkpoint(TRUNCATE)")},
+		{"Vacuuming database", execStep("VACUUM")},
+		{"Optimizing database", execStep("PRAGMA optimize")},
+	}
+	if params.ClearMediaCache {
+		steps = append(steps, compactionStep{"Clearing media cache", clearMediaCache})
+	}
+	log := zerolog.Ctx(ctx)
+	for i, step := range steps {
+		log.Info().Msg(step.message)
+		postMessage(jsoncmd.EventStorageCompactionProgress, 0, &jsoncmd.StorageCompactionProgress{
+			Step:       i + 1,
+			TotalSteps: len(steps),
+			Message:    step.message,
+		})
+		if err := step.fn(ctx); err != nil {
+			return nil, fmt.Errorf("compaction step %q failed: %w", step.message, err)
+		}
+	}
+	return getStorageStats(ctx)
+}
+
+// startClient starts the client and replaces the logout function, as the default one
+// deletes files from the real file system rather than the origin-private file system.
+func startClient() {
+	gmx.StartClient()
+	gmx.Client.LogoutFunc = wipeStorage
+}
+
+func wipeStorage(ctx context.Context) error {
+	log := zerolog.Ctx(ctx)
+	log.Info().Msg("Stopping client and wiping storage")
+	gmx.Client.Stop()
+	if gmx.Client.IsLoggedIn() {
+		_, err := gmx.Client.Client.Logout(ctx)
+		if err != nil {
+			log.Warn().Err(err).Msg("Failed to log out, wiping storage anyway")
+		}
+	}
+	wipeErr := sqlite_wasm_js.WipeFiles()
+	if wipeErr != nil {
+		wipeErr = fmt.Errorf("failed to delete database files: %w", wipeErr)
+		log.Err(wipeErr).Msg("Failed to wipe storage")
+	} else if err := clearMediaCache(ctx); err != nil {
+		log.Warn().Err(err).Msg("Failed to clear media cache")
+	}
+	log.Info().Msg("Restarting client")
+	startClient()
+	gmx.Client.EventHandler(gmx.Client.State())
+	gmx.Client.EventHandler(gmx.Client.SyncStatus.Load())
+	return wipeErr
+}
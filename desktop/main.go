@@ -25,6 +25,8 @@ import (
 	"runtime"
 
 	"github.com/wailsapp/wails/v3/pkg/application"
+	"github.com/wailsapp/wails/v3/pkg/events"
+	"github.com/wailsapp/wails/v3/pkg/services/notifications"
 	"go.mau.fi/util/exhttp"
 
 	"go.mau.fi/gomuks/pkg/gomuks"
@@ -48,6 +50,8 @@ type CommandHandler struct {
 	Gomuks *gomuks.Gomuks
 	Ctx    context.Context
 	App    *application.App
+	Tray   *Tray
+	URIs   *URIHandler
 }
 
 func (c *CommandHandler) HandleCommand(cmd *hicli.JSONCommand) *hicli.JSONCommand {
@@ -65,6 +69,7 @@ func (c *CommandHandler) Init() {
 			var roomCount int
 			for payload := range c.Gomuks.Client.GetInitialSync(ctx, 100) {
 				roomCount += len(payload.Rooms)
+				c.Tray.ApplySync(payload, false)
 				c.App.Event.Emit("hicli_event", jsoncmd.SpecSyncComplete.Format(payload))
 			}
 			if ctx.Err() != nil {
@@ -72,6 +77,7 @@ func (c *CommandHandler) Init() {
 			}
 			c.App.Event.Emit("hicli_event", jsoncmd.SpecInitComplete.Format(jsoncmd.Empty{}))
 			log.Info().Int("room_count", roomCount).Msg("Sent initial rooms to client")
+			c.URIs.Init()
 		}()
 	}
 }
@@ -94,26 +100,38 @@ func main() {
 		Str("go_version", runtime.Version()).
 		Time("built_at", version.Gomuks.BuildTime).
 		Msg("Initializing gomuks desktop")
-	gmx.StartClient()
-	gmx.Log.Info().Msg("Initialization complete, starting desktop app")
 
 	cmdCtx, cancelCmdCtx := context.WithCancel(context.Background())
 	ch := &CommandHandler{Gomuks: gmx, Ctx: cmdCtx}
+	ch.URIs = &URIHandler{handler: ch}
+	notifier := notifications.New()
+	apiHandler := &PointableHandler{}
+	// The app is created before starting the client, so that the single instance check
+	// exits before a second backend is started on the same database.
 	app := application.New(application.Options{
 		Name:        "gomuks-desktop",
 		Description: "A Matrix client written in Go and React",
+		SingleInstance: &application.SingleInstanceOptions{
+			UniqueID: "fi.mau.gomuks.desktop",
+			OnSecondInstanceLaunch: func(data application.SecondInstanceData) {
+				gmx.Log.Debug().Strs("args", data.Args).Msg("Second instance launched")
+				if uri := findURIArg(data.Args); uri != "" {
+					ch.URIs.Open(uri)
+				} else {
+					ch.Tray.ShowWindow()
+				}
+			},
+		},
 		Services: []application.Service{
-			application.NewServiceWithOptions(
-				&PointableHandler{gmx.CreateAPIRouter()},
-				application.ServiceOptions{Route: "/_gomuks"},
-			),
+			application.NewServiceWithOptions(apiHandler, application.ServiceOptions{Route: "/_gomuks"}),
 			application.NewService(ch),
+			application.NewService(notifier),
 		},
 		Assets: application.AssetOptions{
 			Handler: application.AssetFileServerFS(web.Frontend),
 		},
 		Mac: application.MacOptions{
-			ApplicationShouldTerminateAfterLastWindowClosed: true,
+			ApplicationShouldTerminateAfterLastWindowClosed: !gmx.Config.Desktop.CloseToTray,
 		},
 		OnShutdown: func() {
 			cancelCmdCtx()
@@ -123,8 +141,13 @@ func main() {
 		},
 	})
 	ch.App = app
+	ch.Tray = NewTray(gmx, app, notifier)
+
+	gmx.StartClient()
+	apiHandler.handler = gmx.CreateAPIRouter()
+	gmx.Log.Info().Msg("Initialization complete, starting desktop app")
 
-	app.Window.NewWithOptions(application.WebviewWindowOptions{
+	window := app.Window.NewWithOptions(application.WebviewWindowOptions{
 		Title: "gomuks desktop",
 		Mac: application.MacWindow{
 			InvisibleTitleBarHeight: 50,
@@ -135,8 +158,28 @@ func main() {
 		URL:              "/",
 	})
 
+	if !ch.Tray.Setup(window) && gmx.Config.Desktop.CloseToTray {
+		gmx.Log.Warn().Msg("System tray not available, closing the window will quit the app")
+	}
+	go func() {
+		authorized, err := notifier.RequestNotificationAuthorization()
+		if err != nil {
+			gmx.Log.Warn().Err(err).Msg("Failed to request notification authorization")
+		} else if !authorized {
+			gmx.Log.Warn().Msg("Notifications are not authorized")
+		}
+	}()
+
 	gmx.EventBuffer.Subscribe(0, nil, func(command *gomuks.BufferedEvent) {
 		app.Event.Emit("hicli_event", command)
+		ch.Tray.HandleEvent(command)
+	})
+	if uri := findURIArg(os.Args[1:]); uri != "" {
+		ch.URIs.Open(uri)
+	}
+	// On macOS, URIs are delivered as an application event rather than command-line arguments
+	app.Event.OnApplicationEvent(events.Common.ApplicationLaunchedWithUrl, func(evt *application.ApplicationEvent) {
+		ch.URIs.Open(evt.Context().URL())
 	})
 
 	err = app.Run()
@@ -0,0 +1,223 @@
+// gomuks - A Matrix client written in Go.
+// Copyright (C) 2024 Tulir Asokan
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+package main
+
+import (
+	"context"
+	_ "embed"
+	"fmt"
+	"os"
+	"runtime"
+	"sync"
+	"sync/atomic"
+
+	"github.com/rs/zerolog"
+	"github.com/wailsapp/wails/v3/pkg/application"
+	"github.com/wailsapp/wails/v3/pkg/events"
+	"github.com/wailsapp/wails/v3/pkg/services/notifications"
+	"maunium.net/go/mautrix/id"
+
+	"go.mau.fi/gomuks/pkg/gomuks"
+	"go.mau.fi/gomuks/pkg/hicli/jsoncmd"
+)
+
+//go:embed build/trayicon.png
+var trayIcon []byte
+
+// FocusRoomEvent is emitted to the frontend when the user clicks a notification.
+// The event data is the ID of the room that should be opened.
+const FocusRoomEvent = "focus_room"
+
+type Tray struct {
+	Gomuks   *gomuks.Gomuks
+	App      *application.App
+	Window   *application.WebviewWindow
+	Notifier *notifications.NotificationService
+	Log      zerolog.Logger
+
+	tray                 *application.SystemTray
+	notificationsEnabled atomic.Bool
+
+	lock       sync.Mutex
+	highlights map[id.RoomID]int
+	lastTotal  int
+}
+
+func NewTray(gmx *gomuks.Gomuks, app *application.App, notifier *notifications.NotificationService) *Tray {
+	t := &Tray{
+		Gomuks:     gmx,
+		App:        app,
+		Notifier:   notifier,
+		Log:        gmx.Log.With().Str("component", "tray").Logger(),
+		highlights: make(map[id.RoomID]int),
+	}
+	t.notificationsEnabled.Store(true)
+	return t
+}
+
+// trayAvailable checks whether the platform is likely to have a system tray.
+// On Linux, the tray is implemented with the StatusNotifierItem D-Bus protocol,
+// which doesn't work without a session bus.
+func trayAvailable() bool {
+	if runtime.GOOS == "linux" {
+		return os.Getenv("DBUS_SESSION_BUS_ADDRESS") != ""
+	}
+	return true
+}
+
+// Setup creates the tray icon and hooks up the window and notification handlers.
+// It returns false if the tray is disabled or unavailable, in which case closing the window quits the app.
+func (t *Tray) Setup(window *application.WebviewWindow) bool {
+	t.Window = window
+	t.Notifier.OnNotificationResponse(t.onNotificationResponse)
+	if t.Gomuks.Config.Desktop.DisableTray {
+		return false
+	} else if !trayAvailable() {
+		t.Log.Warn().Msg("System tray doesn't seem to be available, not creating tray icon")
+		return false
+	}
+
+	menu := application.NewMenu()
+	menu.Add("Open").OnClick(func(*application.Context) {
+		t.ShowWindow()
+	})
+	menu.AddCheckbox("Notifications", true).OnClick(func(ctx *application.Context) {
+		enabled := ctx.ClickedMenuItem().Checked()
+		t.notificationsEnabled.Store(enabled)
+		t.Log.Debug().Bool("enabled", enabled).Msg("Toggled notifications from tray menu")
+	})
+	menu.AddSeparator()
+	menu.Add("Quit").OnClick(func(*application.Context) {
+		t.App.Quit()
+	})
+
+	t.tray = t.App.SystemTray.New()
+	if runtime.GOOS == "darwin" {
+		t.tray.SetTemplateIcon(trayIcon)
+	} else {
+		t.tray.SetIcon(trayIcon)
+	}
+	t.tray.SetTooltip("gomuks desktop")
+	t.tray.SetMenu(menu)
+	t.tray.OnClick(t.ShowWindow)
+
+	if t.Gomuks.Config.Desktop.CloseToTray {
+		window.RegisterHook(events.Common.WindowClosing, func(evt *application.WindowEvent) {
+			evt.Cancel()
+			window.Hide()
+		})
+	}
+	return true
+}
+
+func (t *Tray) ShowWindow() {
+	t.Window.Show()
+	t.Window.UnMinimise()
+	t.Window.Focus()
+}
+
+func (t *Tray) onNotificationResponse(result notifications.NotificationResult) {
+	if result.Error != nil {
+		t.Log.Err(result.Error).Msg("Error in notification response")
+		return
+	}
+	roomID, _ := result.Response.UserInfo["room_id"].(string)
+	t.ShowWindow()
+	if roomID != "" {
+		t.App.Event.Emit(FocusRoomEvent, roomID)
+	}
+}
+
+// HandleEvent updates the unread counter and sends notifications based on events from the event buffer.
+func (t *Tray) HandleEvent(evt *gomuks.BufferedEvent) {
+	if resp, ok := evt.Data.(*jsoncmd.SyncComplete); ok {
+		t.ApplySync(resp, true)
+	}
+}
+
+func (t *Tray) ApplySync(resp *jsoncmd.SyncComplete, notify bool) {
+	t.lock.Lock()
+	if resp.ClearState {
+		clear(t.highlights)
+	}
+	for roomID, room := range resp.Rooms {
+		if room.Meta == nil {
+			continue
+		} else if room.Meta.UnreadHighlights > 0 {
+			t.highlights[roomID] = room.Meta.UnreadHighlights
+		} else {
+			delete(t.highlights, roomID)
+		}
+	}
+	for _, roomID := range resp.LeftRooms {
+		delete(t.highlights, roomID)
+	}
+	var total int
+	for _, count := range t.highlights {
+		total += count
+	}
+	changed := total != t.lastTotal
+	t.lastTotal = total
+	t.lock.Unlock()
+	if changed {
+		t.updateTray(total)
+	}
+	if notify {
+		t.sendNotifications(resp)
+	}
+}
+
+func (t *Tray) updateTray(highlights int) {
+	if t.tray == nil {
+		return
+	}
+	if highlights > 0 {
+		t.tray.SetTooltip(fmt.Sprintf("gomuks desktop (%d unread mentions)", highlights))
+		t.tray.SetLabel(fmt.Sprintf("%d", highlights))
+	} else {
+		t.tray.SetTooltip("gomuks desktop")
+		t.tray.SetLabel("")
+	}
+}
+
+func (t *Tray) sendNotifications(resp *jsoncmd.SyncComplete) {
+	if !t.notificationsEnabled.Load() || (t.Window != nil && t.Window.IsVisible() && t.Window.IsFocused()) {
+		return
+	}
+	ctx := t.Log.WithContext(context.Background())
+	for _, room := range resp.Rooms {
+		for _, notif := range room.Notifications {
+			msg := t.Gomuks.FormatPushNotificationMessage(ctx, notif)
+			if msg == nil {
+				continue
+			}
+			title := msg.Sender.Name
+			if title != msg.RoomName {
+				title = fmt.Sprintf("%s (%s)", msg.Sender.Name, msg.RoomName)
+			}
+			err := t.Notifier.SendNotification(notifications.NotificationOptions{
+				ID:    fmt.Sprintf("%d", msg.EventRowID),
+				Title: title,
+				Body:  msg.Text,
+				Data:  map[string]any{"room_id": msg.RoomID.String()},
+			})
+			if err != nil {
+				t.Log.Err(err).Stringer("event_id", msg.EventID).Msg("Failed to send notification")
+			}
+		}
+	}
+}
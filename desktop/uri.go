@@ -0,0 +1,90 @@
+// gomuks - A Matrix client written in Go.
+// Copyright (C) 2024 Tulir Asokan
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+package main
+
+import (
+	"strings"
+	"sync"
+
+	"maunium.net/go/mautrix/id"
+
+	"go.mau.fi/gomuks/pkg/hicli/jsoncmd"
+)
+
+// findURIArg returns the first command-line argument that looks like a matrix: URI or matrix.to link.
+func findURIArg(args []string) string {
+	for _, arg := range args {
+		if strings.HasPrefix(arg, "matrix:") || strings.HasPrefix(arg, "https://matrix.to/") {
+			return arg
+		}
+	}
+	return ""
+}
+
+func parseOpenURI(rawURI string) (*jsoncmd.OpenURI, error) {
+	uri, err := id.ParseMatrixURIOrMatrixToURL(rawURI)
+	if err != nil {
+		return nil, err
+	}
+	return &jsoncmd.OpenURI{
+		URI:       uri.String(),
+		UserID:    uri.UserID(),
+		RoomID:    uri.RoomID(),
+		RoomAlias: uri.RoomAlias(),
+		EventID:   uri.EventID(),
+		Via:       uri.Via,
+	}, nil
+}
+
+// URIHandler forwards URIs to the frontend. URIs received before the frontend has initialized
+// (e.g. the one the app was launched with) are queued until Init is called.
+type URIHandler struct {
+	handler *CommandHandler
+
+	lock        sync.Mutex
+	ready       bool
+	pendingURIs []*jsoncmd.OpenURI
+}
+
+func (uh *URIHandler) Open(rawURI string) {
+	log := uh.handler.Gomuks.Log.With().Str("uri", rawURI).Logger()
+	parsed, err := parseOpenURI(rawURI)
+	if err != nil {
+		log.Warn().Err(err).Msg("Failed to parse URI to open")
+		return
+	}
+	log.Debug().Msg("Opening URI")
+	uh.lock.Lock()
+	defer uh.lock.Unlock()
+	if !uh.ready {
+		uh.pendingURIs = append(uh.pendingURIs, parsed)
+		return
+	}
+	uh.handler.Tray.ShowWindow()
+	uh.handler.App.Event.Emit("hicli_event", jsoncmd.SpecOpenURI.Format(parsed))
+}
+
+// Init marks the frontend as ready and sends any queued URIs.
+func (uh *URIHandler) Init() {
+	uh.lock.Lock()
+	defer uh.lock.Unlock()
+	uh.ready = true
+	for _, uri := range uh.pendingURIs {
+		uh.handler.App.Event.Emit("hicli_event", jsoncmd.SpecOpenURI.Format(uri))
+	}
+	uh.pendingURIs = nil
+}
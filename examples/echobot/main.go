@@ -0,0 +1,139 @@
+// gomuks - A Matrix client written in Go.
+// Copyright (C) 2025 Tulir Asokan
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+// echobot is an example of using the gomuks client package. It connects to a running gomuks backend,
+// logs into Matrix if the backend isn't logged in yet, joins a room and echoes all text messages sent there.
+package main
+
+import (
+	"context"
+	"os"
+	"os/signal"
+	"sync/atomic"
+	"syscall"
+
+	"github.com/rs/zerolog"
+	"go.mau.fi/util/exerrors"
+	"go.mau.fi/util/exzerolog"
+	"go.mau.fi/util/ptr"
+	"go.mau.fi/zeroconfig"
+	flag "maunium.net/go/mauflag"
+	"maunium.net/go/mautrix/event"
+	"maunium.net/go/mautrix/id"
+
+	"go.mau.fi/gomuks/pkg/hicli/database"
+	"go.mau.fi/gomuks/pkg/hicli/jsoncmd"
+	"go.mau.fi/gomuks/pkg/rpc"
+	"go.mau.fi/gomuks/pkg/rpc/client"
+)
+
+var address = flag.MakeFull("a", "address", "Address to use to connect to the backend", "http://localhost:29325").String()
+var username = flag.MakeFull("u", "username", "Username for the backend", "").String()
+var password = flag.MakeFull("p", "password", "Password for the backend", "").String()
+var homeserver = flag.MakeFull("s", "homeserver", "Homeserver URL to log in with if the backend isn't logged in", "").String()
+var matrixUsername = flag.MakeFull("U", "matrix-username", "Matrix username to log in with", "").String()
+var matrixPassword = flag.MakeFull("P", "matrix-password", "Matrix password to log in with", "").String()
+var roomToJoin = flag.MakeFull("r", "room", "Room ID or alias to join and echo messages in", "").String()
+
+var cli *client.GomuksClient
+var echoRoomID atomic.Pointer[id.RoomID]
+
+func main() {
+	exerrors.PanicIfNotNil(flag.Parse())
+	log := exerrors.Must((&zeroconfig.Config{
+		Writers: []zeroconfig.WriterConfig{{
+			Type:   zeroconfig.WriterTypeStdout,
+			Format: zeroconfig.LogFormatPrettyColored,
+		}},
+		MinLevel: ptr.Ptr(zerolog.InfoLevel),
+	}).Compile())
+	exzerolog.SetupDefaults(log)
+	ctx := log.WithContext(context.Background())
+
+	cli = exerrors.Must(client.NewGomuksClient(*address, rpc.ConnectionOptions{}))
+	rpc.OnEvent(cli.GomuksRPC, handleClientState)
+	rpc.OnEvent(cli.GomuksRPC, handleSync)
+	cli.OnDisconnected(func(ctx context.Context, err error) {
+		zerolog.Ctx(ctx).Warn().Err(err).Msg("Lost connection to backend")
+	})
+	cli.OnResumed(func(ctx context.Context) {
+		zerolog.Ctx(ctx).Info().Msg("Reconnected to backend")
+	})
+	exerrors.PanicIfNotNil(cli.Authenticate(ctx, *username, *password))
+	exerrors.PanicIfNotNil(cli.Connect(ctx))
+
+	exerrors.PanicIfNotNil(cli.InitComplete.Wait(ctx))
+	resp := exerrors.Must(cli.JoinRoom(ctx, &jsoncmd.JoinRoomParams{RoomIDOrAlias: *roomToJoin}))
+	echoRoomID.Store(&resp.RoomID)
+	log.Info().Stringer("room_id", resp.RoomID).Msg("Joined room, echoing messages")
+
+	c := make(chan os.Signal, 1)
+	signal.Notify(c, os.Interrupt, syscall.SIGTERM)
+	<-c
+	cli.Disconnect()
+}
+
+func handleClientState(ctx context.Context, evt *jsoncmd.ClientState) {
+	if !evt.Initialized || evt.IsLoggedIn {
+		return
+	} else if *homeserver == "" {
+		zerolog.Ctx(ctx).Error().Msg("Backend isn't logged in and no homeserver was given")
+		return
+	}
+	// Handlers block the event loop, so make the request in the background
+	go func() {
+		err := cli.Login(ctx, &jsoncmd.LoginParams{
+			HomeserverURL: *homeserver,
+			Username:      *matrixUsername,
+			Password:      *matrixPassword,
+		})
+		if err != nil {
+			zerolog.Ctx(ctx).Err(err).Msg("Failed to log in")
+		}
+	}()
+}
+
+func handleSync(ctx context.Context, evt *jsoncmd.SyncComplete) {
+	// Don't echo old messages from the initial sync
+	roomID := echoRoomID.Load()
+	if !cli.InitComplete.IsSet() || roomID == nil {
+		return
+	}
+	room, ok := evt.Rooms[*roomID]
+	if !ok {
+		return
+	}
+	eventsByRowID := make(map[database.EventRowID]*database.Event, len(room.Events))
+	for _, dbEvt := range room.Events {
+		eventsByRowID[dbEvt.RowID] = dbEvt
+	}
+	for _, entry := range room.Timeline {
+		dbEvt := eventsByRowID[entry.Event]
+		if dbEvt == nil || dbEvt.Sender == cli.ClientState.UserID || dbEvt.GetType() != event.EventMessage {
+			continue
+		}
+		content := dbEvt.GetMautrixContent().AsMessage()
+		if content.MsgType != event.MsgText || dbEvt.RelationType == event.RelReplace {
+			continue
+		}
+		go func() {
+			_, err := cli.Reply(ctx, *roomID, dbEvt.ID, content.Body)
+			if err != nil {
+				zerolog.Ctx(ctx).Err(err).Stringer("event_id", dbEvt.ID).Msg("Failed to echo message")
+			}
+		}()
+	}
+}
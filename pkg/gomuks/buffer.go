@@ -17,6 +17,7 @@
 package gomuks
 
 import (
+	"context"
 	"maps"
 	"slices"
 	"sync"
@@ -28,6 +29,9 @@ import (
 
 type WebsocketCloseFunc func(websocket.StatusCode, string)
 
+// WebsocketFlushFunc blocks until all events passed to a listener have been sent to the client.
+type WebsocketFlushFunc func(context.Context)
+
 type BufferedEvent = jsoncmd.Container[any]
 
 type EventBuffer struct {
@@ -39,22 +43,24 @@ type EventBuffer struct {
 
 	DisableCache bool
 
-	websocketClosers map[uint64]WebsocketCloseFunc
-	lastAckedID      map[uint64]int64
-	eventListeners   map[uint64]func(*BufferedEvent)
-	nextListenerID   uint64
+	websocketClosers  map[uint64]WebsocketCloseFunc
+	websocketFlushers map[uint64]WebsocketFlushFunc
+	lastAckedID       map[uint64]int64
+	eventListeners    map[uint64]func(*BufferedEvent)
+	nextListenerID    uint64
 }
 
 func NewEventBuffer(maxSize int) *EventBuffer {
 	disableCache := maxSize <= 0
 	return &EventBuffer{
-		websocketClosers: make(map[uint64]WebsocketCloseFunc),
-		lastAckedID:      make(map[uint64]int64),
-		eventListeners:   make(map[uint64]func(*BufferedEvent)),
-		buf:              make([]*BufferedEvent, 0, 32),
-		MaxSize:          maxSize,
-		DisableCache:     disableCache,
-		minID:            -1,
+		websocketClosers:  make(map[uint64]WebsocketCloseFunc),
+		websocketFlushers: make(map[uint64]WebsocketFlushFunc),
+		lastAckedID:       make(map[uint64]int64),
+		eventListeners:    make(map[uint64]func(*BufferedEvent)),
+		buf:               make([]*BufferedEvent, 0, 32),
+		MaxSize:           maxSize,
+		DisableCache:      disableCache,
+		minID:             -1,
 	}
 }
 
@@ -87,11 +93,56 @@ func (eb *EventBuffer) GetClosers() []WebsocketCloseFunc {
 	return slices.Collect(maps.Values(eb.websocketClosers))
 }
 
+// SetFlusher sets the function used to wait for the events of the given listener to be sent out.
+func (eb *EventBuffer) SetFlusher(listenerID uint64, flush WebsocketFlushFunc) {
+	eb.lock.Lock()
+	defer eb.lock.Unlock()
+	if _, ok := eb.eventListeners[listenerID]; ok {
+		eb.websocketFlushers[listenerID] = flush
+	}
+}
+
+// Flush waits until all events pushed so far have been sent to every connected websocket,
+// or until the context is canceled.
+func (eb *EventBuffer) Flush(ctx context.Context) {
+	eb.lock.RLock()
+	flushers := slices.Collect(maps.Values(eb.websocketFlushers))
+	eb.lock.RUnlock()
+	var wg sync.WaitGroup
+	wg.Add(len(flushers))
+	for _, flush := range flushers {
+		go func() {
+			defer wg.Done()
+			flush(ctx)
+		}()
+	}
+	wg.Wait()
+}
+
+// HasWebsocketClients returns true if any websocket client is currently connected.
+func (eb *EventBuffer) HasWebsocketClients() bool {
+	eb.lock.Lock()
+	defer eb.lock.Unlock()
+	return len(eb.websocketClosers) > 0
+}
+
+// Stats returns the current size of the buffer and the number of listeners.
+func (eb *EventBuffer) Stats() *jsoncmd.EventBufferStats {
+	eb.lock.RLock()
+	defer eb.lock.RUnlock()
+	return &jsoncmd.EventBufferStats{
+		Length:    len(eb.buf),
+		MaxSize:   eb.MaxSize,
+		Listeners: len(eb.eventListeners),
+	}
+}
+
 func (eb *EventBuffer) Unsubscribe(listenerID uint64) {
 	eb.lock.Lock()
 	defer eb.lock.Unlock()
 	delete(eb.eventListeners, listenerID)
 	delete(eb.websocketClosers, listenerID)
+	delete(eb.websocketFlushers, listenerID)
 }
 
 func (eb *EventBuffer) addToBuffer(evt *BufferedEvent) {
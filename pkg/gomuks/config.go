@@ -31,6 +31,8 @@ import (
 	"go.mau.fi/zeroconfig"
 	"golang.org/x/crypto/bcrypt"
 	"gopkg.in/yaml.v3"
+
+	"go.mau.fi/gomuks/pkg/hicli/jsoncmd"
 )
 
 type Config struct {
@@ -38,17 +40,61 @@ type Config struct {
 	Matrix  MatrixConfig      `yaml:"matrix"`
 	Push    PushConfig        `yaml:"push"`
 	Media   MediaConfig       `yaml:"media"`
+	Desktop DesktopConfig     `yaml:"desktop"`
+	Debug   DebugConfig       `yaml:"debug"`
 	Logging zeroconfig.Config `yaml:"logging"`
 }
 
+// DebugConfig contains options for diagnosing performance problems.
+type DebugConfig struct {
+	// Serve pprof profiles under /_gomuks/debug/pprof/ and runtime stats under /_gomuks/debug/gomuks-stats.
+	// Unlike web.debug_endpoints, these require authentication. Collecting some of the stats has a small cost,
+	// so this should be disabled when not needed.
+	Enabled bool `yaml:"enabled"`
+}
+
+// DesktopConfig contains options that are only used by the desktop app.
+type DesktopConfig struct {
+	// Hide the window into the system tray instead of quitting when it's closed.
+	// Ignored if the system tray isn't available.
+	CloseToTray bool `yaml:"close_to_tray"`
+	// Don't create a system tray icon at all.
+	DisableTray bool `yaml:"disable_tray"`
+}
+
 type MatrixConfig struct {
-	DisableHTTP2 bool `yaml:"disable_http2"`
+	DisableHTTP2 bool                       `yaml:"disable_http2"`
+	SyncFilter   jsoncmd.SyncFilterSettings `yaml:"sync_filter"`
+	Presence     PresenceConfig             `yaml:"presence"`
+	Retention    RetentionConfig            `yaml:"retention"`
+}
+
+// RetentionConfig contains options for applying the m.room.retention policies of rooms to the local database.
+type RetentionConfig struct {
+	// Never delete messages locally, even if the room has a retention policy. If disabled, messages older than
+	// the max_lifetime of the room are deleted from the local database periodically. State events, pinned events
+	// and the latest message of each room are kept.
+	NeverDeleteLocally bool `yaml:"never_delete_locally"`
+}
+
+// PresenceConfig contains options for setting presence automatically based on activity in frontends.
+// Frontends report activity with the report_activity command. If the homeserver rejects a presence update,
+// automatic presence is disabled until gomuks is restarted.
+type PresenceConfig struct {
+	// Set presence to unavailable after this many minutes without activity in any frontend,
+	// and back to online when activity resumes. Zero disables automatic away.
+	AutoAwayMinutes int `yaml:"auto_away_minutes"`
+	// Set presence to offline when gomuks is shut down gracefully.
+	OfflineOnQuit bool `yaml:"offline_on_quit"`
 }
 
 type PushConfig struct {
 	FCMGateway      string `yaml:"fcm_gateway"`
 	VAPIDPrivateKey string `yaml:"vapid_private_key"`
 	VAPIDPublicKey  string `yaml:"vapid_public_key"`
+	// If enabled, push notifications only contain the room and event IDs.
+	// Message text, names and avatars are left out, so they never pass through the push service.
+	EncryptedPush bool `yaml:"encrypted_push"`
 }
 
 type MediaConfig struct {
@@ -57,6 +103,7 @@ type MediaConfig struct {
 
 type WebConfig struct {
 	ListenAddress   string   `yaml:"listen_address"`
+	UnixSocket      string   `yaml:"unix_socket,omitempty"`
 	Username        string   `yaml:"username"`
 	PasswordHash    string   `yaml:"password_hash"`
 	TokenKey        string   `yaml:"token_key"`
@@ -85,6 +132,9 @@ func makeDefaultConfig() Config {
 		},
 		Matrix: MatrixConfig{
 			DisableHTTP2: false,
+			Retention: RetentionConfig{
+				NeverDeleteLocally: true,
+			},
 		},
 		Media: MediaConfig{
 			ThumbnailSize: 120,
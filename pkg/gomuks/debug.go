@@ -0,0 +1,48 @@
+// gomuks - A Matrix client written in Go.
+// Copyright (C) 2025 Tulir Asokan
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+package gomuks
+
+import (
+	"net/http"
+	"net/http/pprof"
+
+	"go.mau.fi/util/exhttp"
+	"maunium.net/go/mautrix"
+)
+
+// addDebugRoutes adds the profiling and stats endpoints to the API router, which is behind the auth middleware.
+func (gmx *Gomuks) addDebugRoutes(api *http.ServeMux) {
+	api.HandleFunc("GET /debug/pprof/", pprof.Index)
+	api.HandleFunc("GET /debug/pprof/cmdline", pprof.Cmdline)
+	api.HandleFunc("GET /debug/pprof/profile", pprof.Profile)
+	api.HandleFunc("GET /debug/pprof/symbol", pprof.Symbol)
+	api.HandleFunc("GET /debug/pprof/trace", pprof.Trace)
+	api.HandleFunc("GET /debug/gomuks-stats", gmx.GetDebugStats)
+}
+
+func (gmx *Gomuks) GetDebugStats(w http.ResponseWriter, r *http.Request) {
+	if gmx.Client == nil {
+		mautrix.MUnknown.WithMessage("Client not started").WithStatus(http.StatusServiceUnavailable).Write(w)
+		return
+	}
+	stats, err := gmx.Client.GetDebugStats(r.Context())
+	if err != nil {
+		mautrix.MUnknown.WithMessage("Failed to collect stats: %v", err).Write(w)
+		return
+	}
+	exhttp.WriteJSONResponse(w, http.StatusOK, stats)
+}
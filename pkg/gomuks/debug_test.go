@@ -0,0 +1,113 @@
+// gomuks - A Matrix client written in Go.
+// Copyright (C) 2026 Tulir Asokan
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+package gomuks
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"go.mau.fi/util/exhttp"
+)
+
+// newTestAPIServer serves the API router with the same prefix and auth middleware as StartServer.
+func newTestAPIServer(t *testing.T, gmx *Gomuks) *httptest.Server {
+	t.Helper()
+	srv := httptest.NewServer(exhttp.ApplyMiddleware(
+		gmx.CreateAPIRouter(),
+		exhttp.StripPrefix("/_gomuks"),
+		gmx.AuthMiddleware,
+	))
+	t.Cleanup(srv.Close)
+	return srv
+}
+
+func doTestRequest(t *testing.T, srv *httptest.Server, path, authToken string) *http.Response {
+	t.Helper()
+	req, err := http.NewRequest(http.MethodGet, srv.URL+path, nil)
+	if err != nil {
+		t.Fatalf("Failed to create request: %v", err)
+	}
+	if authToken != "" {
+		req.AddCookie(&http.Cookie{Name: "gomuks_auth", Value: authToken})
+	}
+	resp, err := srv.Client().Do(req)
+	if err != nil {
+		t.Fatalf("Request to %s failed: %v", path, err)
+	}
+	t.Cleanup(func() {
+		_ = resp.Body.Close()
+	})
+	return resp
+}
+
+func TestDebugRoutes(t *testing.T) {
+	debugPaths := []string{"/_gomuks/debug/pprof/", "/_gomuks/debug/pprof/cmdline", "/_gomuks/debug/gomuks-stats"}
+
+	t.Run("enabled", func(t *testing.T) {
+		gmx := newTestGomuks(t)
+		gmx.Config.Debug.Enabled = true
+		gmx.Client.EnableDebugStats()
+		srv := newTestAPIServer(t, gmx)
+		token, _ := gmx.generateToken()
+		for _, path := range debugPaths {
+			if resp := doTestRequest(t, srv, path, token); resp.StatusCode != http.StatusOK {
+				t.Errorf("GET %s returned %d, want %d", path, resp.StatusCode, http.StatusOK)
+			}
+		}
+		resp := doTestRequest(t, srv, "/_gomuks/debug/pprof/", token)
+		body, err := io.ReadAll(resp.Body)
+		if err != nil {
+			t.Fatalf("Failed to read pprof index: %v", err)
+		} else if !strings.Contains(string(body), "goroutine") {
+			t.Error("pprof index doesn't list profiles")
+		}
+		resp = doTestRequest(t, srv, "/_gomuks/debug/gomuks-stats", token)
+		var stats map[string]any
+		if err = json.NewDecoder(resp.Body).Decode(&stats); err != nil {
+			t.Errorf("Failed to decode stats: %v", err)
+		}
+	})
+
+	t.Run("requires auth", func(t *testing.T) {
+		gmx := newTestGomuks(t)
+		gmx.Config.Debug.Enabled = true
+		srv := newTestAPIServer(t, gmx)
+		for _, path := range debugPaths {
+			if resp := doTestRequest(t, srv, path, ""); resp.StatusCode != http.StatusUnauthorized {
+				t.Errorf("Unauthenticated GET %s returned %d, want %d", path, resp.StatusCode, http.StatusUnauthorized)
+			}
+			if resp := doTestRequest(t, srv, path, "invalid"); resp.StatusCode != http.StatusUnauthorized {
+				t.Errorf("GET %s with invalid token returned %d, want %d", path, resp.StatusCode, http.StatusUnauthorized)
+			}
+		}
+	})
+
+	t.Run("disabled", func(t *testing.T) {
+		gmx := newTestGomuks(t)
+		srv := newTestAPIServer(t, gmx)
+		token, _ := gmx.generateToken()
+		for _, path := range debugPaths {
+			if resp := doTestRequest(t, srv, path, token); resp.StatusCode != http.StatusNotFound {
+				t.Errorf("GET %s returned %d with debug disabled, want %d", path, resp.StatusCode, http.StatusNotFound)
+			}
+		}
+	})
+}
@@ -26,6 +26,7 @@ import (
 	"path/filepath"
 	"runtime"
 	"sync"
+	"sync/atomic"
 	"syscall"
 	"time"
 
@@ -46,6 +47,7 @@ import (
 
 type Gomuks struct {
 	Log    *zerolog.Logger
+	LogCtl *LogControl
 	Server *http.Server
 	Client *hicli.HiClient
 
@@ -61,6 +63,10 @@ type Gomuks struct {
 
 	Config      Config
 	DisableAuth bool
+	// Headless disables serving the web frontend.
+	Headless bool
+
+	shuttingDown atomic.Bool
 
 	GetDBConfig func() dbutil.PoolConfig
 
@@ -69,6 +75,8 @@ type Gomuks struct {
 
 	EventBuffer *EventBuffer
 
+	widgets widgetSessionStore
+
 	// Maps from temporary MXC URIs from by the media repository for URL
 	// previews to permanent MXC URIs suitable for sending in an inline preview
 	temporaryMXCToPermanent         map[id.ContentURIString]id.ContentURIString
@@ -79,6 +87,10 @@ type Gomuks struct {
 func NewGomuks() *Gomuks {
 	gmx := &Gomuks{
 		stopChan: make(chan struct{}),
+		widgets: widgetSessionStore{
+			sessions:        make(map[string]*widgetSession),
+			openIDDecisions: make(map[widgetKey]bool),
+		},
 
 		temporaryMXCToPermanent:         map[id.ContentURIString]id.ContentURIString{},
 		temporaryMXCToEncryptedFileInfo: map[id.ContentURIString]*event.EncryptedFileInfo{},
@@ -168,7 +180,7 @@ func (gmx *Gomuks) InitDirectories() {
 }
 
 func (gmx *Gomuks) SetupLog() {
-	gmx.Log = exerrors.Must(gmx.Config.Logging.Compile())
+	gmx.Log, gmx.LogCtl = exerrors.Must2(CompileLogger(gmx.Config.Logging))
 	exzerolog.SetupDefaults(gmx.Log)
 }
 
@@ -190,6 +202,17 @@ func (gmx *Gomuks) StartClient() {
 		gmx.HandleEvent,
 	)
 	gmx.Client.LogoutFunc = gmx.Logout
+	gmx.Client.LogLevelFunc = gmx.SetLogLevel
+	gmx.Client.RecentLogsFunc = gmx.LogCtl.Recent.Get
+	gmx.Client.ImageAuthTokenFunc = func() jsoncmd.ImageAuthToken {
+		return gmx.generateImageToken(imageAuthTokenExpiry)
+	}
+	if gmx.Config.Debug.Enabled {
+		gmx.Client.EnableDebugStats()
+		gmx.Client.DebugStatsFunc = func(stats *jsoncmd.DebugStats) {
+			stats.EventBuffer = gmx.EventBuffer.Stats()
+		}
+	}
 	httpClient := gmx.Client.Client.Client
 	if runtime.GOOS == "js" {
 		gmx.Client.Client.UserAgent = ""
@@ -210,6 +233,22 @@ func (gmx *Gomuks) StartClient() {
 		gmx.Log.WithLevel(zerolog.FatalLevel).Err(err).Msg("Failed to get first user ID")
 		os.Exit(11)
 	}
+	err = gmx.Client.InitSyncFilter(&gmx.Config.Matrix.SyncFilter)
+	if err != nil {
+		gmx.Log.WithLevel(zerolog.FatalLevel).Err(err).Msg("Invalid sync filter settings in config")
+		os.Exit(14)
+	}
+	gmx.Client.InitAutoPresence(
+		time.Duration(gmx.Config.Matrix.Presence.AutoAwayMinutes)*time.Minute,
+		gmx.Config.Matrix.Presence.OfflineOnQuit,
+	)
+	gmx.Client.EnforceRetention = !gmx.Config.Matrix.Retention.NeverDeleteLocally
+	gmx.Client.SyncFilterChanged = func(settings *jsoncmd.SyncFilterSettings) {
+		gmx.Config.Matrix.SyncFilter = *settings
+		if err := gmx.SaveConfig(); err != nil {
+			gmx.Log.Err(err).Msg("Failed to save config after changing sync filter")
+		}
+	}
 	err = gmx.Client.Start(ctx, userID, nil)
 	if err != nil {
 		gmx.Log.WithLevel(zerolog.FatalLevel).Err(err).Msg("Failed to start client")
@@ -235,6 +274,7 @@ func (gmx *Gomuks) Stop() {
 func (gmx *Gomuks) WaitForInterrupt() {
 	c := make(chan os.Signal, 1)
 	signal.Notify(c, os.Interrupt, syscall.SIGTERM)
+	defer signal.Stop(c)
 	select {
 	case <-c:
 	case <-gmx.stopChan:
@@ -245,6 +285,9 @@ func (gmx *Gomuks) DirectStop() {
 	for _, closer := range gmx.EventBuffer.GetClosers() {
 		closer(websocket.StatusServiceRestart, "Server shutting down")
 	}
+	ctx, cancel := context.WithTimeout(gmx.Log.WithContext(context.Background()), 5*time.Second)
+	gmx.Client.StopAutoPresence(ctx)
+	cancel()
 	gmx.Client.Stop()
 	if gmx.Server != nil {
 		err := gmx.Server.Close()
@@ -272,7 +315,9 @@ func (gmx *Gomuks) Run() {
 	gmx.Log.Info().Msg("Initialization complete")
 	gmx.WaitForInterrupt()
 	gmx.Log.Info().Msg("Shutting down...")
-	gmx.DirectStop()
+	ctx, cancel := context.WithTimeout(context.Background(), ShutdownTimeout)
+	gmx.Shutdown(ctx)
+	cancel()
 	gmx.Log.Info().Msg("Shutdown complete")
 	os.Exit(0)
 }
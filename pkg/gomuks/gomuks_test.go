@@ -0,0 +1,51 @@
+// gomuks - A Matrix client written in Go.
+// Copyright (C) 2026 Tulir Asokan
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+package gomuks
+
+import (
+	"context"
+	"path/filepath"
+	"testing"
+
+	"github.com/rs/zerolog"
+	"go.mau.fi/util/dbutil"
+
+	"go.mau.fi/gomuks/pkg/hicli"
+	"go.mau.fi/gomuks/pkg/hicli/database"
+)
+
+// newTestGomuks creates a gomuks instance with a logged-in client and a migrated database in a temporary directory.
+// The client doesn't have a homeserver and the HTTP server isn't started.
+func newTestGomuks(t *testing.T) *Gomuks {
+	t.Helper()
+	rawDB, err := dbutil.NewWithDialect(filepath.Join(t.TempDir(), "gomuks.db"), "sqlite3-fk-wal")
+	if err != nil {
+		t.Fatalf("Failed to open database: %v", err)
+	}
+	t.Cleanup(func() {
+		_ = rawDB.Close()
+	})
+	cli := hicli.New(rawDB, nil, zerolog.Nop(), []byte("meow"), func(any) {})
+	if err = cli.DB.Upgrade(context.Background()); err != nil {
+		t.Fatalf("Failed to upgrade database: %v", err)
+	}
+	cli.Account = &database.Account{UserID: "@alice:example.com"}
+	log := zerolog.Nop()
+	gmx := &Gomuks{Log: &log, Client: cli, EventBuffer: NewEventBuffer(0), stopChan: make(chan struct{})}
+	gmx.Config.Web.TokenKey = "meow"
+	return gmx
+}
@@ -0,0 +1,327 @@
+// gomuks - A Matrix client written in Go.
+// Copyright (C) 2025 Tulir Asokan
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+package gomuks
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"image"
+	"image/jpeg"
+	"image/png"
+	"io"
+	"net/http"
+	"os"
+	"os/exec"
+	"path/filepath"
+
+	"github.com/gabriel-vasile/mimetype"
+	"github.com/rs/zerolog"
+	"go.mau.fi/util/random"
+	"maunium.net/go/mautrix"
+
+	"go.mau.fi/gomuks/pkg/orientation"
+)
+
+// Values for the strip_exif upload query parameter.
+const (
+	StripEXIFAlways = "always"
+	StripEXIFAsk    = "ask"
+	StripEXIFNever  = "never"
+)
+
+// ErrImageHasLocation is returned by the upload endpoint in the ask mode if the image contains location data.
+// The frontend should ask the user and upload the file again with strip_exif set to always or never.
+var ErrImageHasLocation = mautrix.RespError{
+	ErrCode:    "FI.MAU.GOMUKS.IMAGE_HAS_LOCATION",
+	Err:        "The image contains location metadata",
+	StatusCode: http.StatusConflict,
+}
+
+var errMalformedImage = errors.New("malformed image")
+
+const (
+	exifHeader         = "Exif\x00\x00"
+	pngSignature       = "\x89PNG\r\n\x1a\n"
+	tiffTagOrientation = 0x0112
+	tiffTagGPSInfo     = 0x8825
+	// xmpLocationTag is used to detect location data in XMP packets, which are plain XML.
+	xmpLocationTag = "GPSLatitude"
+)
+
+type imageMetadata struct {
+	hasMetadata bool
+	hasLocation bool
+	orientation orientation.Orientation
+}
+
+// parseEXIF reads the orientation and checks for the GPS info pointer in the first IFD of the given TIFF data.
+func (meta *imageMetadata) parseEXIF(data []byte) {
+	meta.hasMetadata = true
+	if len(data) < 8 {
+		return
+	}
+	var byteOrder binary.ByteOrder
+	switch string(data[:2]) {
+	case "MM":
+		byteOrder = binary.BigEndian
+	case "II":
+		byteOrder = binary.LittleEndian
+	default:
+		return
+	}
+	offset := int(byteOrder.Uint32(data[4:8]))
+	if offset < 8 || offset+2 > len(data) {
+		return
+	}
+	numTags := int(byteOrder.Uint16(data[offset:]))
+	tags := data[offset+2:]
+	for i := 0; i < numTags && (i+1)*12 <= len(tags); i++ {
+		tag := tags[i*12 : (i+1)*12]
+		switch byteOrder.Uint16(tag) {
+		case tiffTagOrientation:
+			if val := byteOrder.Uint16(tag[8:]); val >= 1 && val <= 8 {
+				meta.orientation = orientation.Orientation(val)
+			}
+		case tiffTagGPSInfo:
+			meta.hasLocation = true
+		}
+	}
+}
+
+// stripJPEGMetadata removes EXIF, XMP and IPTC segments from a JPEG file.
+// Other segments like ICC profiles are kept, and the image data isn't touched.
+func stripJPEGMetadata(data []byte) ([]byte, imageMetadata, error) {
+	var meta imageMetadata
+	if len(data) < 4 || data[0] != 0xff || data[1] != 0xd8 {
+		return nil, meta, fmt.Errorf("%w: missing JPEG SOI marker", errMalformedImage)
+	}
+	out := make([]byte, 2, len(data))
+	copy(out, data[:2])
+	pos := 2
+	for pos+2 <= len(data) {
+		if data[pos] != 0xff {
+			return nil, meta, fmt.Errorf("%w: invalid JPEG marker at %d", errMalformedImage, pos)
+		}
+		marker := data[pos+1]
+		switch {
+		case marker == 0xff:
+			// Fill byte
+			pos++
+			continue
+		case marker == 0xda, marker == 0xd9:
+			// Start of scan or end of image, the rest of the file is image data
+			return append(out, data[pos:]...), meta, nil
+		case marker == 0x01, marker >= 0xd0 && marker <= 0xd7:
+			// Markers without a payload
+			out = append(out, data[pos:pos+2]...)
+			pos += 2
+			continue
+		}
+		if pos+4 > len(data) {
+			break
+		}
+		size := int(binary.BigEndian.Uint16(data[pos+2:]))
+		end := pos + 2 + size
+		if size < 2 || end > len(data) {
+			return nil, meta, fmt.Errorf("%w: invalid JPEG segment size at %d", errMalformedImage, pos)
+		}
+		payload := data[pos+4 : end]
+		switch marker {
+		case 0xe1: // APP1: EXIF or XMP
+			if bytes.HasPrefix(payload, []byte(exifHeader)) {
+				meta.parseEXIF(payload[len(exifHeader):])
+			} else {
+				meta.hasMetadata = true
+				meta.hasLocation = meta.hasLocation || bytes.Contains(payload, []byte(xmpLocationTag))
+			}
+		case 0xed: // APP13: Photoshop IPTC
+			meta.hasMetadata = true
+		default:
+			out = append(out, data[pos:end]...)
+		}
+		pos = end
+	}
+	return nil, meta, fmt.Errorf("%w: truncated JPEG", errMalformedImage)
+}
+
+// stripPNGMetadata removes the EXIF and text chunks from a PNG file.
+func stripPNGMetadata(data []byte) ([]byte, imageMetadata, error) {
+	var meta imageMetadata
+	if !bytes.HasPrefix(data, []byte(pngSignature)) {
+		return nil, meta, fmt.Errorf("%w: missing PNG signature", errMalformedImage)
+	}
+	out := make([]byte, len(pngSignature), len(data))
+	copy(out, pngSignature)
+	pos := len(pngSignature)
+	for pos+12 <= len(data) {
+		length := int(binary.BigEndian.Uint32(data[pos:]))
+		chunkType := string(data[pos+4 : pos+8])
+		end := pos + 12 + length
+		if length < 0 || end > len(data) {
+			break
+		}
+		payload := data[pos+8 : pos+8+length]
+		switch chunkType {
+		case "eXIf":
+			meta.parseEXIF(payload)
+		case "tEXt", "zTXt", "iTXt", "tIME":
+			meta.hasMetadata = true
+			meta.hasLocation = meta.hasLocation || bytes.Contains(payload, []byte(xmpLocationTag))
+		default:
+			out = append(out, data[pos:end]...)
+		}
+		if chunkType == "IEND" {
+			return out, meta, nil
+		}
+		pos = end
+	}
+	return nil, meta, fmt.Errorf("%w: truncated PNG", errMalformedImage)
+}
+
+// readHEICMetadata finds the EXIF and XMP items in a HEIC file. HEIC files can't be stripped
+// without rewriting the container, so that's left to ImageMagick.
+func readHEICMetadata(data []byte) (meta imageMetadata) {
+	if idx := bytes.Index(data, []byte(exifHeader)); idx != -1 {
+		meta.parseEXIF(data[idx+len(exifHeader):])
+	}
+	if bytes.Contains(data, []byte(xmpLocationTag)) {
+		meta.hasMetadata = true
+		meta.hasLocation = true
+	}
+	return
+}
+
+// bakeOrientation applies the EXIF orientation to the pixels of a stripped JPEG or PNG image,
+// as the orientation flag is removed along with the rest of the metadata. The image is re-encoded
+// in its original format.
+func bakeOrientation(data []byte, mimeType string, o orientation.Orientation) ([]byte, error) {
+	decoded, _, err := image.Decode(bytes.NewReader(data))
+	if err != nil {
+		return nil, fmt.Errorf("failed to decode image: %w", err)
+	}
+	var buf bytes.Buffer
+	switch mimeType {
+	case "image/jpeg":
+		err = jpeg.Encode(&buf, o.Fix(decoded), &jpeg.Options{Quality: 95})
+	case "image/png":
+		err = png.Encode(&buf, o.Fix(decoded))
+	default:
+		return nil, fmt.Errorf("can't apply orientation to %s", mimeType)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode image: %w", err)
+	}
+	return buf.Bytes(), nil
+}
+
+func (gmx *Gomuks) stripHEICMetadata(ctx context.Context, inputPath string) ([]byte, error) {
+	if magickPath == "" {
+		return nil, fmt.Errorf("stripping HEIC metadata requires ImageMagick")
+	}
+	outputPath := filepath.Join(gmx.TempDir, "stripped-"+random.String(12)+".heic")
+	defer func() {
+		_ = os.Remove(outputPath)
+	}()
+	cmd := exec.CommandContext(ctx, magickPath, inputPath, "-auto-orient", "-strip", outputPath)
+	if output, err := cmd.CombinedOutput(); err != nil {
+		zerolog.Ctx(ctx).Err(err).Bytes("output", output).Msg("Failed to strip HEIC metadata with magick")
+		return nil, fmt.Errorf("failed to strip metadata with magick: %w", err)
+	}
+	return os.ReadFile(outputPath)
+}
+
+// stripImageMetadata removes metadata from the image in the given file according to the strip_exif mode.
+// If the file was changed, the hash of the new content is returned. Files other than JPEG, PNG and
+// HEIC images are left untouched.
+func (gmx *Gomuks) stripImageMetadata(ctx context.Context, mode string, file *os.File) ([]byte, error) {
+	if mode != StripEXIFAlways && mode != StripEXIFAsk {
+		return nil, nil
+	}
+	_, err := file.Seek(0, io.SeekStart)
+	if err != nil {
+		return nil, fmt.Errorf("failed to seek to start of temp file: %w", err)
+	}
+	mimeType, err := mimetype.DetectReader(file)
+	if err != nil {
+		return nil, fmt.Errorf("failed to detect mime type: %w", err)
+	}
+	mimeStr := mimeType.String()
+	if mimeStr != "image/jpeg" && mimeStr != "image/png" && mimeStr != "image/heic" {
+		return nil, nil
+	}
+	data, err := os.ReadFile(file.Name())
+	if err != nil {
+		return nil, fmt.Errorf("failed to read temp file: %w", err)
+	}
+	var stripped []byte
+	var meta imageMetadata
+	switch mimeStr {
+	case "image/jpeg":
+		stripped, meta, err = stripJPEGMetadata(data)
+	case "image/png":
+		stripped, meta, err = stripPNGMetadata(data)
+	case "image/heic":
+		meta = readHEICMetadata(data)
+	}
+	if err != nil {
+		return nil, err
+	} else if !meta.hasMetadata {
+		return nil, nil
+	} else if mode == StripEXIFAsk {
+		if meta.hasLocation {
+			return nil, ErrImageHasLocation
+		}
+		return nil, nil
+	}
+	if mimeStr == "image/heic" {
+		stripped, err = gmx.stripHEICMetadata(ctx, file.Name())
+	} else if meta.orientation != orientation.Unspecified && meta.orientation != orientation.Normal {
+		stripped, err = bakeOrientation(stripped, mimeStr, meta.orientation)
+	}
+	if err != nil {
+		return nil, err
+	}
+	zerolog.Ctx(ctx).Debug().
+		Str("mime_type", mimeStr).
+		Bool("had_location", meta.hasLocation).
+		Int("orientation", int(meta.orientation)).
+		Int("old_size", len(data)).
+		Int("new_size", len(stripped)).
+		Msg("Stripped image metadata")
+	_, err = file.Seek(0, io.SeekStart)
+	if err != nil {
+		return nil, fmt.Errorf("failed to seek to start of temp file: %w", err)
+	}
+	err = file.Truncate(0)
+	if err != nil {
+		return nil, fmt.Errorf("failed to truncate temp file: %w", err)
+	}
+	_, err = file.Write(stripped)
+	if err != nil {
+		return nil, fmt.Errorf("failed to write stripped image: %w", err)
+	}
+	_, err = file.Seek(0, io.SeekStart)
+	if err != nil {
+		return nil, fmt.Errorf("failed to seek to start of temp file: %w", err)
+	}
+	checksum := sha256.Sum256(stripped)
+	return checksum[:], nil
+}
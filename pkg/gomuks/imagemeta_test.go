@@ -0,0 +1,138 @@
+// gomuks - A Matrix client written in Go.
+// Copyright (C) 2026 Tulir Asokan
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+package gomuks
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"image"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"go.mau.fi/gomuks/pkg/orientation"
+)
+
+// The fixtures are 4x2 blue images with a red bottom-left pixel. Both have an EXIF block with orientation 6
+// (rotate 90° clockwise) and a GPS info pointer, and the PNG also has a tEXt chunk.
+var imageFixtures = []struct {
+	file      string
+	mimeType  string
+	signature string
+}{
+	{"gps-rotated.jpg", "image/jpeg", "\xff\xd8"},
+	{"gps-rotated.png", "image/png", pngSignature},
+}
+
+func openImageFixture(t *testing.T, name string) *os.File {
+	t.Helper()
+	data, err := os.ReadFile(filepath.Join("testdata", name))
+	if err != nil {
+		t.Fatalf("Failed to read fixture: %v", err)
+	}
+	path := filepath.Join(t.TempDir(), name)
+	if err = os.WriteFile(path, data, 0600); err != nil {
+		t.Fatalf("Failed to write fixture copy: %v", err)
+	}
+	file, err := os.OpenFile(path, os.O_RDWR, 0)
+	if err != nil {
+		t.Fatalf("Failed to open fixture copy: %v", err)
+	}
+	t.Cleanup(func() {
+		_ = file.Close()
+	})
+	return file
+}
+
+func TestStripImageMetadata_Fixtures(t *testing.T) {
+	gmx := &Gomuks{}
+	for _, fixture := range imageFixtures {
+		t.Run(fixture.file, func(t *testing.T) {
+			file := openImageFixture(t, fixture.file)
+			hash, err := gmx.stripImageMetadata(context.Background(), StripEXIFAlways, file)
+			if err != nil {
+				t.Fatalf("stripImageMetadata returned error: %v", err)
+			} else if hash == nil {
+				t.Fatal("stripImageMetadata didn't change the file")
+			}
+			out, err := os.ReadFile(file.Name())
+			if err != nil {
+				t.Fatalf("Failed to read stripped file: %v", err)
+			}
+			if !bytes.HasPrefix(out, []byte(fixture.signature)) {
+				t.Errorf("Stripped file isn't %s anymore", fixture.mimeType)
+			}
+			for _, marker := range []string{exifHeader, "eXIf", "tEXt"} {
+				if bytes.Contains(out, []byte(marker)) {
+					t.Errorf("Stripped file still contains %q", marker)
+				}
+			}
+			var meta imageMetadata
+			if fixture.mimeType == "image/jpeg" {
+				_, meta, err = stripJPEGMetadata(out)
+			} else {
+				_, meta, err = stripPNGMetadata(out)
+			}
+			if err != nil {
+				t.Errorf("Failed to re-parse stripped file: %v", err)
+			} else if meta.hasMetadata || meta.hasLocation || meta.orientation != orientation.Unspecified {
+				t.Errorf("Stripped file still has metadata: %+v", meta)
+			}
+			img, format, err := image.Decode(bytes.NewReader(out))
+			if err != nil {
+				t.Fatalf("Failed to decode stripped file: %v", err)
+			} else if "image/"+format != fixture.mimeType {
+				t.Errorf("Stripped file was decoded as %s", format)
+			}
+			if size := img.Bounds().Size(); size.X != 2 || size.Y != 4 {
+				t.Errorf("Rotated image is %dx%d, expected 2x4", size.X, size.Y)
+			}
+			if fixture.mimeType == "image/png" {
+				// PNG is lossless, so the red pixel must end up in the top-left corner after rotating clockwise.
+				if r, g, b, _ := img.At(0, 0).RGBA(); r>>8 != 0xff || g != 0 || b != 0 {
+					t.Errorf("Unexpected top-left pixel after rotation: %v", img.At(0, 0))
+				}
+			}
+		})
+	}
+}
+
+func TestStripImageMetadata_AskMode(t *testing.T) {
+	gmx := &Gomuks{}
+	for _, fixture := range imageFixtures {
+		t.Run(fixture.file, func(t *testing.T) {
+			file := openImageFixture(t, fixture.file)
+			original, _ := os.ReadFile(file.Name())
+			_, err := gmx.stripImageMetadata(context.Background(), StripEXIFAsk, file)
+			if !errors.Is(err, ErrImageHasLocation) {
+				t.Errorf("Expected ErrImageHasLocation, got %v", err)
+			}
+			if after, _ := os.ReadFile(file.Name()); !bytes.Equal(original, after) {
+				t.Error("File was modified in ask mode")
+			}
+		})
+	}
+}
+
+func TestStripImageMetadata_NeverMode(t *testing.T) {
+	file := openImageFixture(t, "gps-rotated.jpg")
+	hash, err := (&Gomuks{}).stripImageMetadata(context.Background(), StripEXIFNever, file)
+	if err != nil || hash != nil {
+		t.Errorf("Expected file to be left untouched, got hash %x and error %v", hash, err)
+	}
+}
@@ -0,0 +1,51 @@
+// gomuks - A Matrix client written in Go.
+// Copyright (C) 2025 Tulir Asokan
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+package gomuks
+
+import (
+	"context"
+	"fmt"
+	"os"
+
+	"maunium.net/go/mautrix/id"
+)
+
+// RunImportHistory imports an Element-style room export file into the local database and exits.
+func (gmx *Gomuks) RunImportHistory(path string, roomID id.RoomID) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		_, _ = fmt.Fprintln(os.Stderr, "Failed to read export file:", err)
+		os.Exit(1)
+	}
+	gmx.InitDirectories()
+	err = gmx.LoadConfig()
+	if err != nil {
+		_, _ = fmt.Fprintln(os.Stderr, "Failed to load config:", err)
+		os.Exit(9)
+	}
+	gmx.SetupLog()
+	gmx.StartClient()
+	ctx := gmx.Log.WithContext(context.Background())
+	resp, err := gmx.Client.ImportHistory(ctx, roomID, data)
+	gmx.DirectStop()
+	if err != nil {
+		_, _ = fmt.Fprintln(os.Stderr, "Failed to import history:", err)
+		os.Exit(1)
+	}
+	fmt.Printf("Imported %d events into %s (%d skipped)\n", resp.Imported, resp.RoomID, resp.Skipped)
+	os.Exit(0)
+}
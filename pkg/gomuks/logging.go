@@ -0,0 +1,227 @@
+// gomuks - A Matrix client written in Go.
+// Copyright (C) 2025 Tulir Asokan
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+package gomuks
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"maps"
+	"sync"
+	"sync/atomic"
+
+	"github.com/rs/zerolog"
+	"github.com/tidwall/gjson"
+	"go.mau.fi/zeroconfig"
+
+	"go.mau.fi/gomuks/pkg/hicli/jsoncmd"
+)
+
+// RecentLogCount is the number of log lines kept in memory for the get_recent_logs command.
+const RecentLogCount = 2000
+
+// RecentLogWriter is a bounded ring buffer of the most recent JSON log lines.
+type RecentLogWriter struct {
+	lock  sync.Mutex
+	lines []json.RawMessage
+	next  int
+	full  bool
+}
+
+func NewRecentLogWriter(size int) *RecentLogWriter {
+	return &RecentLogWriter{lines: make([]json.RawMessage, size)}
+}
+
+func (rlw *RecentLogWriter) Write(p []byte) (int, error) {
+	// zerolog reuses the buffer, so the line must be copied
+	line := make(json.RawMessage, len(p))
+	copy(line, p)
+	rlw.lock.Lock()
+	rlw.lines[rlw.next] = line
+	rlw.next = (rlw.next + 1) % len(rlw.lines)
+	if rlw.next == 0 {
+		rlw.full = true
+	}
+	rlw.lock.Unlock()
+	return len(p), nil
+}
+
+// Get returns up to limit of the most recent log lines in chronological order.
+func (rlw *RecentLogWriter) Get(limit int) []json.RawMessage {
+	rlw.lock.Lock()
+	defer rlw.lock.Unlock()
+	count := rlw.next
+	if rlw.full {
+		count = len(rlw.lines)
+	}
+	if limit > 0 && limit < count {
+		count = limit
+	}
+	output := make([]json.RawMessage, count)
+	for i := range output {
+		output[i] = rlw.lines[(rlw.next-count+i+len(rlw.lines))%len(rlw.lines)]
+	}
+	return output
+}
+
+// LogControl allows changing the log level globally or for specific components at runtime.
+type LogControl struct {
+	Recent *RecentLogWriter
+
+	output    zerolog.LevelWriter
+	lock      sync.Mutex
+	global    atomic.Int32
+	overrides atomic.Pointer[map[string]zerolog.Level]
+}
+
+func (lc *LogControl) levelFor(p []byte) zerolog.Level {
+	overrides := lc.overrides.Load()
+	if overrides == nil {
+		return zerolog.GlobalLevel()
+	}
+	// Components are matched against either the component or action fields of log lines
+	res := gjson.GetManyBytes(p, "component", "action")
+	if lvl, ok := (*overrides)[res[0].Str]; ok && res[0].Str != "" {
+		return lvl
+	} else if lvl, ok = (*overrides)[res[1].Str]; ok && res[1].Str != "" {
+		return lvl
+	}
+	return zerolog.Level(lc.global.Load())
+}
+
+func (lc *LogControl) Write(p []byte) (int, error) {
+	return lc.WriteLevel(zerolog.NoLevel, p)
+}
+
+func (lc *LogControl) WriteLevel(level zerolog.Level, p []byte) (int, error) {
+	if level >= zerolog.TraceLevel && level < zerolog.FatalLevel && level < lc.levelFor(p) {
+		return len(p), nil
+	}
+	return lc.output.WriteLevel(level, p)
+}
+
+// SetLevel changes the minimum log level of the given component, or the global level if the
+// component is empty. Setting the level of a component to NoLevel removes the override.
+func (lc *LogControl) SetLevel(component string, level zerolog.Level) *jsoncmd.LogLevels {
+	lc.lock.Lock()
+	defer lc.lock.Unlock()
+	var overrides map[string]zerolog.Level
+	if existing := lc.overrides.Load(); existing != nil {
+		overrides = maps.Clone(*existing)
+	} else {
+		overrides = make(map[string]zerolog.Level)
+	}
+	if component == "" {
+		lc.global.Store(int32(level))
+	} else if level == zerolog.NoLevel {
+		delete(overrides, component)
+	} else {
+		overrides[component] = level
+	}
+	// The zerolog global level is used to skip building log events that no writer wants
+	minLevel := zerolog.Level(lc.global.Load())
+	for _, lvl := range overrides {
+		minLevel = min(minLevel, lvl)
+	}
+	if len(overrides) == 0 {
+		lc.overrides.Store(nil)
+	} else {
+		lc.overrides.Store(&overrides)
+	}
+	zerolog.SetGlobalLevel(minLevel)
+	return lc.getLevels(overrides)
+}
+
+func (lc *LogControl) GetLevels() *jsoncmd.LogLevels {
+	lc.lock.Lock()
+	defer lc.lock.Unlock()
+	var overrides map[string]zerolog.Level
+	if existing := lc.overrides.Load(); existing != nil {
+		overrides = *existing
+	}
+	return lc.getLevels(overrides)
+}
+
+func (lc *LogControl) getLevels(overrides map[string]zerolog.Level) *jsoncmd.LogLevels {
+	levels := &jsoncmd.LogLevels{
+		Global:     zerolog.Level(lc.global.Load()).String(),
+		Components: make(map[string]string, len(overrides)),
+	}
+	for component, lvl := range overrides {
+		levels.Components[component] = lvl.String()
+	}
+	return levels
+}
+
+// CompileLogger creates a logger from the given config, with the writers wrapped in a LogControl
+// and an in-memory writer for recent log lines added.
+func CompileLogger(cfg zeroconfig.Config) (*zerolog.Logger, *LogControl, error) {
+	lc := &LogControl{
+		Recent: NewRecentLogWriter(RecentLogCount),
+	}
+	lc.global.Store(int32(zerolog.TraceLevel))
+	if len(cfg.Writers) == 0 || (cfg.MinLevel != nil && *cfg.MinLevel == zerolog.Disabled) {
+		log := zerolog.Nop()
+		return &log, lc, nil
+	}
+	writers := make([]io.Writer, len(cfg.Writers)+1)
+	for i, wc := range cfg.Writers {
+		writer, err := wc.Compile()
+		if err != nil {
+			return nil, nil, err
+		}
+		writers[i] = writer
+	}
+	writers[len(cfg.Writers)] = lc.Recent
+	lc.output = zerolog.MultiLevelWriter(writers...).(zerolog.LevelWriter)
+	if cfg.MinLevel != nil {
+		lc.global.Store(int32(*cfg.MinLevel))
+	}
+	// Compile the rest of the config (timestamps, metadata, etc.) with a dummy writer,
+	// then replace the output with the level-controlled writer.
+	cfg.Writers = []zeroconfig.WriterConfig{{Type: zeroconfig.WriterTypeStdout}}
+	cfg.MinLevel = nil
+	log, err := cfg.Compile()
+	if err != nil {
+		return nil, nil, err
+	}
+	filtered := log.Output(lc)
+	zerolog.SetGlobalLevel(zerolog.Level(lc.global.Load()))
+	return &filtered, lc, nil
+}
+
+// SetLogLevel is called by the set_log_level command. An empty level only returns the current levels,
+// while the level "reset" removes a component-specific override.
+func (gmx *Gomuks) SetLogLevel(component, level string) (*jsoncmd.LogLevels, error) {
+	if level == "" {
+		return gmx.LogCtl.GetLevels(), nil
+	} else if level == "reset" {
+		if component == "" {
+			return nil, fmt.Errorf("can't reset global log level")
+		}
+		return gmx.LogCtl.SetLevel(component, zerolog.NoLevel), nil
+	}
+	parsed, err := zerolog.ParseLevel(level)
+	if err != nil || parsed == zerolog.NoLevel {
+		return nil, fmt.Errorf("invalid log level %q", level)
+	}
+	gmx.Log.Info().
+		Str("target_component", component).
+		Stringer("level", parsed).
+		Msg("Changing log level")
+	return gmx.LogCtl.SetLevel(component, parsed), nil
+}
@@ -830,6 +830,11 @@ func (gmx *Gomuks) cacheAndUploadMedia(
 		return nil, fmt.Errorf("failed to copy upload media to temp file: %w", err)
 	}
 	checksum := hasher.Sum(nil)
+	if newHash, err := gmx.stripImageMetadata(ctx, query.Get("strip_exif"), tempFile); err != nil {
+		return nil, fmt.Errorf("failed to strip image metadata: %w", err)
+	} else if newHash != nil {
+		checksum = newHash
+	}
 	if newHash, err := gmx.reencodeMedia(ctx, query, tempFile); err != nil {
 		return nil, fmt.Errorf("failed to reencode media: %w", err)
 	} else if newHash != nil {
@@ -1055,6 +1060,13 @@ func (gmx *Gomuks) GenerateFileInfo(ctx context.Context, file io.ReadSeeker) (ev
 				zerolog.Ctx(ctx).Warn().Err(err).Msg("Failed to decode image config and magick not installed")
 			}
 		} else {
+			if mimeType.String() == "image/jpeg" {
+				_, err = file.Seek(0, io.SeekStart)
+				if err != nil {
+					return "", nil, "", fmt.Errorf("failed to seek to start of file: %w", err)
+				}
+				img = orientation.Read(file).Fix(img)
+			}
 			bounds := img.Bounds()
 			info.Width = bounds.Dx()
 			info.Height = bounds.Dy()
@@ -1071,13 +1083,6 @@ func (gmx *Gomuks) GenerateFileInfo(ctx context.Context, file io.ReadSeeker) (ev
 				zerolog.Ctx(ctx).Warn().Err(err).Msg("Failed to generate image blurhash")
 			}
 			info.AnoaBlurhash = hash
-			if mimeType.String() == "image/jpeg" {
-				_, err = file.Seek(0, io.SeekStart)
-				if err != nil {
-					return "", nil, "", fmt.Errorf("failed to seek to start of file: %w", err)
-				}
-				info.Width, info.Height = orientation.Read(file).ApplyToDimensions(info.Width, info.Height)
-			}
 		}
 	case "video":
 		msgType = event.MsgVideo
@@ -1188,7 +1193,10 @@ func (gmx *Gomuks) generateVideoThumbnail(ctx context.Context, filePath string,
 
 func ToRespError(err error) mautrix.RespError {
 	var httpErr mautrix.HTTPError
-	if errors.As(err, &httpErr) {
+	var respErr mautrix.RespError
+	if errors.As(err, &respErr) {
+		return respErr
+	} else if errors.As(err, &httpErr) {
 		if httpErr.WrappedError != nil {
 			return ErrBadGateway.WithMessage(httpErr.WrappedError.Error())
 		} else if httpErr.RespError != nil {
@@ -83,9 +83,11 @@ func (gmx *Gomuks) SendPushNotifications(sync *jsoncmd.SyncComplete) {
 					Str("action", "send push notification").
 					Logger().WithContext(context.Background())
 			}
-			msg := gmx.formatPushNotificationMessage(ctx, notif)
+			msg := gmx.FormatPushNotificationMessage(ctx, notif)
 			if msg == nil {
 				continue
+			} else if gmx.Config.Push.EncryptedPush {
+				msg = msg.WithoutContent()
 			}
 			msgJSON, err := json.Marshal(msg)
 			if err != nil {
@@ -95,7 +97,7 @@ func (gmx *Gomuks) SendPushNotifications(sync *jsoncmd.SyncComplete) {
 					Msg("Failed to marshal push notification")
 				continue
 			} else if len(msgJSON) > 1500 {
-				// This should not happen as long as formatPushNotificationMessage doesn't return too long messages
+				// This should not happen as long as FormatPushNotificationMessage doesn't return too long messages
 				zerolog.Ctx(ctx).Error().
 					Int64("event_rowid", int64(notif.RowID)).
 					Stringer("event_id", notif.Event.ID).
@@ -119,7 +121,7 @@ func (gmx *Gomuks) SendPushNotifications(sync *jsoncmd.SyncComplete) {
 		zerolog.Ctx(ctx).Err(err).Msg("Failed to get push registrations")
 		return
 	}
-	if len(push.RawMessages) > 0 {
+	if len(push.RawMessages) > 0 && !gmx.Config.Push.EncryptedPush {
 		exp := time.Now().Add(24 * time.Hour)
 		push.ImageAuth = gmx.generateImageToken(24 * time.Hour)
 		push.ImageAuthExpiry = ptr.Ptr(jsontime.UM(exp))
@@ -176,7 +178,13 @@ func (gmx *Gomuks) SendPushNotification(ctx context.Context, pushRegs []*databas
 		log.Error().Msg("Generated push payload too long")
 		return
 	}
+	// Web push is only needed when the web frontend isn't open, as it'll show notifications itself otherwise.
+	// Other push types are always sent, as mobile apps may keep the websocket open in the background.
+	hasWebsocketClients := gmx.EventBuffer.HasWebsocketClients()
 	for _, reg := range pushRegs {
+		if reg.Type == database.PushTypeWeb && hasWebsocketClients {
+			continue
+		}
 		devicePayload := rawPayload
 		encrypted := false
 		if reg.Encryption.Key != nil {
@@ -214,11 +222,10 @@ func (gmx *Gomuks) SendPushNotification(ctx context.Context, pushRegs []*databas
 			shouldDelete = gmx.SendWebPush(ctx, &sub, devicePayload, notif.HasImportant)
 		}
 		if shouldDelete {
-			log.Debug().Str("device_id", reg.DeviceID).Msg("Expiring push registration as gateway returned 404")
-			reg.Expiration = jsontime.UnixNow()
-			err = gmx.Client.DB.PushRegistration.Put(ctx, reg)
+			log.Debug().Str("device_id", reg.DeviceID).Msg("Deleting push registration as gateway reported it expired")
+			err = gmx.Client.DB.PushRegistration.Delete(ctx, reg.DeviceID)
 			if err != nil {
-				log.Err(err).Msg("Failed to mark push registration as expired")
+				log.Err(err).Msg("Failed to delete expired push registration")
 			}
 		}
 	}
@@ -284,6 +291,10 @@ func (gmx *Gomuks) SendFCMPush(ctx context.Context, token string, payload []byte
 	return
 }
 
+// webPushTTL is how long the push service should keep trying to deliver a web push notification.
+// It matches the lifetime of the image auth token included in the payload.
+const webPushTTL = 24 * time.Hour
+
 func (gmx *Gomuks) SendWebPush(ctx context.Context, sub *webpush.Subscription, payload []byte, important bool) (shouldDelete bool) {
 	if !important {
 		// Dismissing notifications isn't supported currently
@@ -293,12 +304,15 @@ func (gmx *Gomuks) SendWebPush(ctx context.Context, sub *webpush.Subscription, p
 		HTTPClient:      pushClient,
 		Subscriber:      "https://gomuks.app",
 		Topic:           "", // TODO use topics for collapsing pending notifications on read receipt?
+		TTL:             int(webPushTTL.Seconds()),
+		Urgency:         webpush.UrgencyHigh,
 		VAPIDPublicKey:  gmx.Config.Push.VAPIDPublicKey,
 		VAPIDPrivateKey: gmx.Config.Push.VAPIDPrivateKey,
 	})
 	if err != nil {
 		zerolog.Ctx(ctx).Err(err).Str("endpoint", sub.Endpoint).Msg("Failed to send push request")
-	} else if resp.StatusCode != http.StatusOK {
+	} else if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		// Push services respond with 201 Created on success
 		zerolog.Ctx(ctx).Error().
 			Int("status", resp.StatusCode).
 			Str("endpoint", sub.Endpoint).
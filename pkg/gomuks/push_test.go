@@ -0,0 +1,341 @@
+// gomuks - A Matrix client written in Go.
+// Copyright (C) 2026 Tulir Asokan
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+//go:build !js
+
+package gomuks
+
+import (
+	"bytes"
+	"context"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/ecdh"
+	"crypto/hkdf"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/SherClockHolmes/webpush-go"
+	"go.mau.fi/util/ptr"
+	"maunium.net/go/mautrix/id"
+
+	"go.mau.fi/gomuks/pkg/hicli/database"
+	"go.mau.fi/gomuks/pkg/hicli/jsoncmd"
+)
+
+// testPushSubscriber acts as a browser that has subscribed to web push.
+type testPushSubscriber struct {
+	key  *ecdh.PrivateKey
+	auth []byte
+}
+
+func newTestPushSubscriber(t *testing.T) *testPushSubscriber {
+	t.Helper()
+	key, err := ecdh.P256().GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatalf("Failed to generate subscriber key: %v", err)
+	}
+	auth := make([]byte, 16)
+	_, _ = rand.Read(auth)
+	return &testPushSubscriber{key: key, auth: auth}
+}
+
+func (tps *testPushSubscriber) subscription(endpoint string) *webpush.Subscription {
+	return &webpush.Subscription{
+		Endpoint: endpoint,
+		Keys: webpush.Keys{
+			P256dh: base64.RawURLEncoding.EncodeToString(tps.key.PublicKey().Bytes()),
+			Auth:   base64.RawURLEncoding.EncodeToString(tps.auth),
+		},
+	}
+}
+
+// decrypt decrypts a single-record aes128gcm web push message as specified in RFC 8188 and RFC 8291.
+func (tps *testPushSubscriber) decrypt(body []byte) ([]byte, error) {
+	if len(body) < 21 {
+		return nil, errors.New("body too short for header")
+	}
+	salt := body[:16]
+	recordSize := binary.BigEndian.Uint32(body[16:20])
+	keyIDLen := int(body[20])
+	if len(body) < 21+keyIDLen {
+		return nil, errors.New("body too short for key ID")
+	}
+	serverKey, err := ecdh.P256().NewPublicKey(body[21 : 21+keyIDLen])
+	if err != nil {
+		return nil, err
+	}
+	ciphertext := body[21+keyIDLen:]
+	if len(ciphertext) > int(recordSize) {
+		return nil, errors.New("message doesn't fit in one record")
+	}
+	sharedSecret, err := tps.key.ECDH(serverKey)
+	if err != nil {
+		return nil, err
+	}
+	keyInfo := "WebPush: info\x00" + string(tps.key.PublicKey().Bytes()) + string(serverKey.Bytes())
+	ikm, err := hkdf.Key(sha256.New, sharedSecret, tps.auth, keyInfo, 32)
+	if err != nil {
+		return nil, err
+	}
+	cek, err := hkdf.Key(sha256.New, ikm, salt, "Content-Encoding: aes128gcm\x00", 16)
+	if err != nil {
+		return nil, err
+	}
+	nonce, err := hkdf.Key(sha256.New, ikm, salt, "Content-Encoding: nonce\x00", 12)
+	if err != nil {
+		return nil, err
+	}
+	block, err := aes.NewCipher(cek)
+	if err != nil {
+		return nil, err
+	}
+	gcm, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, err
+	}
+	plaintext, err := gcm.Open(nil, nonce, ciphertext, nil)
+	if err != nil {
+		return nil, err
+	}
+	// The last record ends with a 0x02 delimiter followed by optional zero padding
+	plaintext = bytes.TrimRight(plaintext, "\x00")
+	if len(plaintext) == 0 || plaintext[len(plaintext)-1] != 0x02 {
+		return nil, errors.New("missing padding delimiter")
+	}
+	return plaintext[:len(plaintext)-1], nil
+}
+
+type receivedPush struct {
+	header http.Header
+	body   []byte
+}
+
+// fakePushService is a push endpoint that records requests and responds with a fixed status code.
+type fakePushService struct {
+	*httptest.Server
+	status   int
+	lock     sync.Mutex
+	requests []receivedPush
+}
+
+func newFakePushService(t *testing.T, status int) *fakePushService {
+	fps := &fakePushService{status: status}
+	fps.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		fps.lock.Lock()
+		fps.requests = append(fps.requests, receivedPush{header: r.Header, body: body})
+		fps.lock.Unlock()
+		w.WriteHeader(fps.status)
+	}))
+	t.Cleanup(fps.Close)
+	return fps
+}
+
+func (fps *fakePushService) received() []receivedPush {
+	fps.lock.Lock()
+	defer fps.lock.Unlock()
+	return append([]receivedPush(nil), fps.requests...)
+}
+
+func newTestPushGomuks(t *testing.T) *Gomuks {
+	t.Helper()
+	gmx := newTestGomuks(t)
+	var err error
+	gmx.Config.Push.VAPIDPrivateKey, gmx.Config.Push.VAPIDPublicKey, err = webpush.GenerateVAPIDKeys()
+	if err != nil {
+		t.Fatalf("Failed to generate VAPID keys: %v", err)
+	}
+	return gmx
+}
+
+func putTestWebPushRegistration(t *testing.T, gmx *Gomuks, deviceID string, sub *webpush.Subscription) {
+	t.Helper()
+	data, err := json.Marshal(sub)
+	if err != nil {
+		t.Fatalf("Failed to marshal subscription: %v", err)
+	}
+	reg := &database.PushRegistration{DeviceID: deviceID, Type: database.PushTypeWeb, Data: data}
+	if err = reg.Validate(); err != nil {
+		t.Fatalf("Test subscription is invalid: %v", err)
+	} else if err = gmx.Client.DB.PushRegistration.Put(context.Background(), reg); err != nil {
+		t.Fatalf("Failed to save push registration: %v", err)
+	}
+}
+
+func TestSendWebPush_Encrypted(t *testing.T) {
+	gmx := newTestPushGomuks(t)
+	service := newFakePushService(t, http.StatusCreated)
+	subscriber := newTestPushSubscriber(t)
+	payload := []byte(`{"messages":[{"text":"hello"}]}`)
+
+	if gmx.SendWebPush(context.Background(), subscriber.subscription(service.URL), payload, false) {
+		t.Error("Unimportant push requested deleting the registration")
+	} else if len(service.received()) != 0 {
+		t.Fatal("Unimportant push was sent")
+	}
+	if gmx.SendWebPush(context.Background(), subscriber.subscription(service.URL), payload, true) {
+		t.Error("Successful push requested deleting the registration")
+	}
+	requests := service.received()
+	if len(requests) != 1 {
+		t.Fatalf("Expected 1 push request, got %d", len(requests))
+	}
+	req := requests[0]
+	if encoding := req.header.Get("Content-Encoding"); encoding != "aes128gcm" {
+		t.Errorf("Content-Encoding = %q, want aes128gcm", encoding)
+	}
+	if ttl, wantTTL := req.header.Get("TTL"), strconv.Itoa(int(webPushTTL.Seconds())); ttl != wantTTL {
+		t.Errorf("TTL = %q, want %s", ttl, wantTTL)
+	}
+	if !strings.HasPrefix(req.header.Get("Authorization"), "vapid ") {
+		t.Errorf("Missing VAPID authorization: %q", req.header.Get("Authorization"))
+	}
+	if bytes.Contains(req.body, []byte("hello")) {
+		t.Error("Push body contains the plaintext")
+	}
+	decrypted, err := subscriber.decrypt(req.body)
+	if err != nil {
+		t.Fatalf("Failed to decrypt push body: %v", err)
+	} else if !bytes.Equal(decrypted, payload) {
+		t.Errorf("Decrypted payload = %s, want %s", decrypted, payload)
+	}
+}
+
+func TestSendPushNotification_PrunesExpiredRegistrations(t *testing.T) {
+	tests := []struct {
+		name       string
+		status     int
+		wantPruned bool
+	}{
+		{"created", http.StatusCreated, false},
+		{"server error", http.StatusInternalServerError, false},
+		{"not found", http.StatusNotFound, true},
+		{"gone", http.StatusGone, true},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			ctx := context.Background()
+			gmx := newTestPushGomuks(t)
+			service := newFakePushService(t, test.status)
+			putTestWebPushRegistration(t, gmx, "browser", newTestPushSubscriber(t).subscription(service.URL))
+			regs, err := gmx.Client.DB.PushRegistration.GetAll(ctx)
+			if err != nil || len(regs) != 1 {
+				t.Fatalf("Failed to get push registrations: %v (%d)", err, len(regs))
+			}
+			gmx.SendPushNotification(ctx, regs, &PushNotification{
+				RawMessages:  []json.RawMessage{json.RawMessage(`{"text":"hi"}`)},
+				HasImportant: true,
+			})
+			if len(service.received()) != 1 {
+				t.Fatalf("Expected 1 push request, got %d", len(service.received()))
+			}
+			regs, err = gmx.Client.DB.PushRegistration.GetAll(ctx)
+			if err != nil {
+				t.Fatalf("Failed to get push registrations: %v", err)
+			} else if pruned := len(regs) == 0; pruned != test.wantPruned {
+				t.Errorf("Registration pruned = %t, want %t", pruned, test.wantPruned)
+			}
+		})
+	}
+}
+
+func TestSendPushNotifications_EncryptedPush(t *testing.T) {
+	const roomID id.RoomID = "!room:example.com"
+	const secretText = "the launch code is 1234"
+	const roomName = "Top secret room"
+	tests := []struct {
+		name          string
+		encryptedPush bool
+	}{
+		{"full content", false},
+		{"encrypted push", true},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			gmx := newTestPushGomuks(t)
+			gmx.Config.Push.EncryptedPush = test.encryptedPush
+			service := newFakePushService(t, http.StatusCreated)
+			subscriber := newTestPushSubscriber(t)
+			putTestWebPushRegistration(t, gmx, "browser", subscriber.subscription(service.URL))
+			room := &database.Room{ID: roomID, Name: ptr.Ptr(roomName)}
+			gmx.SendPushNotifications(&jsoncmd.SyncComplete{
+				Rooms: map[id.RoomID]*jsoncmd.SyncRoom{
+					roomID: {
+						Meta: room,
+						Notifications: []jsoncmd.SyncNotification{{
+							RowID: 1,
+							Sound: true,
+							Event: &database.Event{
+								RowID:   1,
+								RoomID:  roomID,
+								ID:      "$event",
+								Sender:  "@bob:example.com",
+								Type:    "m.room.message",
+								Content: json.RawMessage(`{"msgtype":"m.text","body":"` + secretText + `"}`),
+							},
+							Room: room,
+						}},
+					},
+				},
+			})
+			requests := service.received()
+			if len(requests) != 1 {
+				t.Fatalf("Expected 1 push request, got %d", len(requests))
+			}
+			decrypted, err := subscriber.decrypt(requests[0].body)
+			if err != nil {
+				t.Fatalf("Failed to decrypt push body: %v", err)
+			}
+			var push struct {
+				Messages  []*PushNewMessage `json:"messages"`
+				ImageAuth string            `json:"image_auth"`
+			}
+			if err = json.Unmarshal(decrypted, &push); err != nil {
+				t.Fatalf("Failed to parse push payload: %v", err)
+			} else if len(push.Messages) != 1 {
+				t.Fatalf("Expected 1 message, got %d", len(push.Messages))
+			}
+			msg := push.Messages[0]
+			if msg.EventID != "$event" || msg.RoomID != roomID {
+				t.Errorf("Message doesn't reference the event: %+v", msg)
+			}
+			hasContent := strings.Contains(string(decrypted), secretText) || strings.Contains(string(decrypted), roomName)
+			if test.encryptedPush {
+				if hasContent {
+					t.Errorf("Payload contains message content: %s", decrypted)
+				} else if !msg.ContentOmitted {
+					t.Error("content_omitted isn't set")
+				} else if push.ImageAuth != "" {
+					t.Error("Payload contains an image auth token")
+				}
+			} else if !hasContent || msg.Text != secretText || msg.RoomName != roomName {
+				t.Errorf("Payload doesn't contain message content: %s", decrypted)
+			}
+		})
+	}
+}
@@ -51,6 +51,26 @@ type PushNewMessage struct {
 	Mention bool   `json:"mention,omitempty"`
 	Reply   bool   `json:"reply,omitempty"`
 	Sound   bool   `json:"sound,omitempty"`
+
+	// ContentOmitted is set if the text, names and avatars were left out because encrypted push is enabled.
+	ContentOmitted bool `json:"content_omitted,omitempty"`
+}
+
+// WithoutContent returns a copy of the message that only references the room and event.
+func (pnm *PushNewMessage) WithoutContent() *PushNewMessage {
+	return &PushNewMessage{
+		Timestamp:  pnm.Timestamp,
+		EventID:    pnm.EventID,
+		EventRowID: pnm.EventRowID,
+		RoomID:     pnm.RoomID,
+		Sender:     NotificationUser{ID: pnm.Sender.ID},
+		Self:       NotificationUser{ID: pnm.Self.ID},
+		Mention:    pnm.Mention,
+		Reply:      pnm.Reply,
+		Sound:      pnm.Sound,
+
+		ContentOmitted: true,
+	}
 }
 
 type NotificationUser struct {
@@ -96,7 +116,9 @@ func (gmx *Gomuks) getNotificationUser(ctx context.Context, roomID id.RoomID, us
 	return
 }
 
-func (gmx *Gomuks) formatPushNotificationMessage(ctx context.Context, notif jsoncmd.SyncNotification) *PushNewMessage {
+// FormatPushNotificationMessage formats a notification from a sync response into a push message.
+// It returns nil if the event is not a message that should be notified about.
+func (gmx *Gomuks) FormatPushNotificationMessage(ctx context.Context, notif jsoncmd.SyncNotification) *PushNewMessage {
 	evtType := notif.Event.Type
 	rawContent := notif.Event.Content
 	if evtType == event.EventEncrypted.Type {
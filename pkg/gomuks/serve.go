@@ -0,0 +1,250 @@
+// gomuks - A Matrix client written in Go.
+// Copyright (C) 2025 Tulir Asokan
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+package gomuks
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/coder/websocket"
+	"go.mau.fi/util/exhttp"
+	"maunium.net/go/mautrix"
+
+	"go.mau.fi/gomuks/pkg/hicli/jsoncmd"
+)
+
+// ShutdownTimeout is the maximum time to wait for in-flight HTTP requests when shutting down gracefully.
+const ShutdownTimeout = 10 * time.Second
+
+// systemdListenFDsStart is the first file descriptor passed by systemd socket activation.
+const systemdListenFDsStart = 3
+
+// SystemdListeners returns the listeners passed by systemd socket activation (LISTEN_FDS), if any.
+// The environment variables are unset afterward so that they aren't inherited by child processes.
+func SystemdListeners() ([]net.Listener, error) {
+	defer func() {
+		_ = os.Unsetenv("LISTEN_PID")
+		_ = os.Unsetenv("LISTEN_FDS")
+		_ = os.Unsetenv("LISTEN_FDNAMES")
+	}()
+	pid, err := strconv.Atoi(os.Getenv("LISTEN_PID"))
+	if err != nil || pid != os.Getpid() {
+		return nil, nil
+	}
+	count, err := strconv.Atoi(os.Getenv("LISTEN_FDS"))
+	if err != nil || count <= 0 {
+		return nil, nil
+	}
+	names := strings.Split(os.Getenv("LISTEN_FDNAMES"), ":")
+	listeners := make([]net.Listener, 0, count)
+	for i := range count {
+		name := fmt.Sprintf("LISTEN_FD_%d", systemdListenFDsStart+i)
+		if i < len(names) && names[i] != "" {
+			name = names[i]
+		}
+		file := os.NewFile(uintptr(systemdListenFDsStart+i), name)
+		listener, err := net.FileListener(file)
+		_ = file.Close()
+		if err != nil {
+			for _, l := range listeners {
+				_ = l.Close()
+			}
+			return nil, fmt.Errorf("failed to use socket activation fd %s: %w", name, err)
+		}
+		listeners = append(listeners, listener)
+	}
+	return listeners, nil
+}
+
+func listenUnix(path string) (net.Listener, error) {
+	// Remove stale sockets left over from unclean shutdowns
+	if info, err := os.Stat(path); err == nil && info.Mode()&os.ModeSocket != 0 {
+		_ = os.Remove(path)
+	}
+	listener, err := net.Listen("unix", path)
+	if err != nil {
+		return nil, err
+	}
+	err = os.Chmod(path, 0660)
+	if err != nil {
+		_ = listener.Close()
+		return nil, fmt.Errorf("failed to set socket permissions: %w", err)
+	}
+	return listener, nil
+}
+
+// CreateListeners creates the listeners for the HTTP server. If the process was started with systemd
+// socket activation, only the activated sockets are used. Otherwise, the configured TCP listen address
+// and unix socket are listened on.
+func (gmx *Gomuks) CreateListeners() ([]net.Listener, error) {
+	listeners, err := SystemdListeners()
+	if err != nil {
+		return nil, err
+	} else if len(listeners) > 0 {
+		gmx.Log.Info().Int("count", len(listeners)).Msg("Using listeners from systemd socket activation")
+		return listeners, nil
+	}
+	if gmx.Config.Web.ListenAddress != "" {
+		listener, err := net.Listen("tcp", gmx.Config.Web.ListenAddress)
+		if err != nil {
+			return nil, fmt.Errorf("failed to listen on %s: %w", gmx.Config.Web.ListenAddress, err)
+		}
+		listeners = append(listeners, listener)
+	}
+	if gmx.Config.Web.UnixSocket != "" {
+		listener, err := listenUnix(gmx.Config.Web.UnixSocket)
+		if err != nil {
+			for _, l := range listeners {
+				_ = l.Close()
+			}
+			return nil, fmt.Errorf("failed to listen on unix socket %s: %w", gmx.Config.Web.UnixSocket, err)
+		}
+		listeners = append(listeners, listener)
+	}
+	if len(listeners) == 0 {
+		return nil, fmt.Errorf("no listen address or unix socket configured")
+	}
+	return listeners, nil
+}
+
+var ErrShuttingDown = mautrix.RespError{ErrCode: "FI.MAU.GOMUKS.SHUTTING_DOWN", Err: "Server is shutting down", StatusCode: http.StatusServiceUnavailable}
+
+type HealthResponse struct {
+	OK         bool                `json:"ok"`
+	LoggedIn   bool                `json:"logged_in"`
+	SyncStatus *jsoncmd.SyncStatus `json:"sync_status,omitempty"`
+}
+
+// GetHealth reports whether the server is up and able to sync. It doesn't require authentication.
+func (gmx *Gomuks) GetHealth(w http.ResponseWriter, r *http.Request) {
+	var resp HealthResponse
+	status := http.StatusOK
+	if gmx.shuttingDown.Load() || gmx.Client == nil {
+		status = http.StatusServiceUnavailable
+	} else {
+		resp.LoggedIn = gmx.Client.IsLoggedIn()
+		resp.SyncStatus = gmx.Client.SyncStatus.Load()
+		if resp.SyncStatus != nil && resp.SyncStatus.Type == jsoncmd.SyncStatusFailed {
+			status = http.StatusServiceUnavailable
+		}
+	}
+	resp.OK = status == http.StatusOK
+	exhttp.WriteJSONResponse(w, status, &resp)
+}
+
+// Shutdown stops gomuks in a fixed order: first new websockets are rejected and the events buffered
+// for existing websockets are sent out, then the HTTP listeners are closed, then existing websockets
+// are closed so clients can resume after a restart, then syncing is stopped and finally the database is closed.
+func (gmx *Gomuks) Shutdown(ctx context.Context) {
+	gmx.shuttingDown.Store(true)
+	gmx.EventBuffer.Flush(ctx)
+	if gmx.Server != nil {
+		err := gmx.Server.Shutdown(ctx)
+		if err != nil {
+			gmx.Log.Err(err).Msg("Failed to shut down server gracefully")
+			_ = gmx.Server.Close()
+		}
+	}
+	for _, closer := range gmx.EventBuffer.GetClosers() {
+		closer(websocket.StatusServiceRestart, "Server shutting down")
+	}
+	if gmx.Client != nil {
+		gmx.Client.StopAutoPresence(ctx)
+		// Stops the sync loop and then closes the database
+		gmx.Client.Stop()
+	}
+	if gmx.Config.Web.UnixSocket != "" {
+		_ = os.Remove(gmx.Config.Web.UnixSocket)
+	}
+}
+
+// RunHealthcheck queries the health endpoint of a running gomuks instance using the address from the config
+// and returns an error if it's not healthy.
+func (gmx *Gomuks) RunHealthcheck(ctx context.Context) error {
+	cli := &http.Client{Timeout: 10 * time.Second}
+	addr := gmx.Config.Web.ListenAddress
+	if gmx.Config.Web.UnixSocket != "" {
+		socketPath := gmx.Config.Web.UnixSocket
+		cli.Transport = &http.Transport{
+			DialContext: func(ctx context.Context, _, _ string) (net.Conn, error) {
+				var d net.Dialer
+				return d.DialContext(ctx, "unix", socketPath)
+			},
+		}
+		addr = "unix"
+	} else if host, port, err := net.SplitHostPort(addr); err == nil && isWildcardHost(host) {
+		addr = net.JoinHostPort("localhost", port)
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, fmt.Sprintf("http://%s/_gomuks/health", addr), nil)
+	if err != nil {
+		return err
+	}
+	resp, err := cli.Do(req)
+	if err != nil {
+		return fmt.Errorf("failed to reach health endpoint: %w", err)
+	}
+	_ = resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("health endpoint returned HTTP %d", resp.StatusCode)
+	}
+	return nil
+}
+
+// isWildcardHost returns true if listening on the given host accepts connections on all interfaces.
+func isWildcardHost(host string) bool {
+	if host == "" {
+		return true
+	}
+	ip := net.ParseIP(host)
+	return ip != nil && ip.IsUnspecified()
+}
+
+var errHeadlessPrompt = errors.New("can't prompt for input in headless mode, set web.username and web.password_hash in the config")
+
+// RunHeadless runs gomuks as a pure backend without serving the web frontend. Unlike Run, it supports
+// systemd socket activation and shuts down gracefully on SIGTERM.
+func (gmx *Gomuks) RunHeadless() {
+	gmx.Headless = true
+	PromptInput = func(string) (string, error) {
+		return "", errHeadlessPrompt
+	}
+	gmx.Run()
+}
+
+// RunHealthcheckAndExit loads the config, checks the health endpoint and exits with 0 if it's healthy or 1 if not.
+func (gmx *Gomuks) RunHealthcheckAndExit() {
+	gmx.InitDirectories()
+	gmx.DisableAuth = true
+	err := gmx.LoadConfig()
+	if err != nil {
+		_, _ = fmt.Fprintln(os.Stderr, "Failed to load config:", err)
+		os.Exit(1)
+	}
+	err = gmx.RunHealthcheck(context.Background())
+	if err != nil {
+		_, _ = fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+	os.Exit(0)
+}
@@ -0,0 +1,427 @@
+// gomuks - A Matrix client written in Go.
+// Copyright (C) 2026 Tulir Asokan
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+//go:build unix
+
+package gomuks
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"strconv"
+	"sync"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/coder/websocket"
+	"maunium.net/go/mautrix/id"
+
+	"go.mau.fi/gomuks/pkg/hicli/jsoncmd"
+)
+
+func TestCreateListeners(t *testing.T) {
+	tests := []struct {
+		name          string
+		listenAddress string
+		unixSocket    bool
+		wantNetworks  []string
+	}{
+		{"tcp", "127.0.0.1:0", false, []string{"tcp"}},
+		{"unix socket", "", true, []string{"unix"}},
+		{"tcp and unix socket", "127.0.0.1:0", true, []string{"tcp", "unix"}},
+		{"nothing configured", "", false, nil},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			t.Setenv("LISTEN_PID", "")
+			gmx := newTestGomuks(t)
+			gmx.Config.Web.ListenAddress = test.listenAddress
+			if test.unixSocket {
+				gmx.Config.Web.UnixSocket = filepath.Join(t.TempDir(), "gomuks.sock")
+			}
+			listeners, err := gmx.CreateListeners()
+			for _, listener := range listeners {
+				t.Cleanup(func() {
+					_ = listener.Close()
+				})
+			}
+			if test.wantNetworks == nil {
+				if err == nil {
+					t.Error("Expected an error when no listeners are configured")
+				}
+				return
+			} else if err != nil {
+				t.Fatalf("CreateListeners failed: %v", err)
+			}
+			if len(listeners) != len(test.wantNetworks) {
+				t.Fatalf("Got %d listeners, want %d", len(listeners), len(test.wantNetworks))
+			}
+			for i, listener := range listeners {
+				if network := listener.Addr().Network(); network != test.wantNetworks[i] {
+					t.Errorf("Listener %d is %s, want %s", i, network, test.wantNetworks[i])
+				}
+			}
+			if test.unixSocket {
+				info, err := os.Stat(gmx.Config.Web.UnixSocket)
+				if err != nil {
+					t.Fatalf("Failed to stat unix socket: %v", err)
+				} else if perm := info.Mode().Perm(); perm != 0660 {
+					t.Errorf("Unix socket has permissions %o, want 660", perm)
+				}
+			}
+		})
+	}
+}
+
+func TestCreateListeners_ReplacesStaleSocket(t *testing.T) {
+	gmx := newTestGomuks(t)
+	gmx.Config.Web.UnixSocket = filepath.Join(t.TempDir(), "gomuks.sock")
+	// A socket file left behind by a process that didn't shut down cleanly
+	stale, err := net.Listen("unix", gmx.Config.Web.UnixSocket)
+	if err != nil {
+		t.Fatalf("Failed to create stale socket: %v", err)
+	}
+	stale.(*net.UnixListener).SetUnlinkOnClose(false)
+	_ = stale.Close()
+
+	listeners, err := gmx.CreateListeners()
+	if err != nil {
+		t.Fatalf("CreateListeners failed with stale socket: %v", err)
+	}
+	for _, listener := range listeners {
+		_ = listener.Close()
+	}
+}
+
+func TestSystemdListeners_NotActivated(t *testing.T) {
+	tests := []struct {
+		name string
+		pid  string
+		fds  string
+	}{
+		{"no environment", "", ""},
+		{"other process", strconv.Itoa(os.Getpid() + 1), "1"},
+		{"no fds", strconv.Itoa(os.Getpid()), "0"},
+		{"invalid fd count", strconv.Itoa(os.Getpid()), "meow"},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			t.Setenv("LISTEN_PID", test.pid)
+			t.Setenv("LISTEN_FDS", test.fds)
+			t.Setenv("LISTEN_FDNAMES", "gomuks")
+			listeners, err := SystemdListeners()
+			if err != nil || len(listeners) != 0 {
+				t.Errorf("SystemdListeners() = %v, %v, want no listeners", listeners, err)
+			}
+			// The variables must not be inherited by child processes
+			for _, key := range []string{"LISTEN_PID", "LISTEN_FDS", "LISTEN_FDNAMES"} {
+				if _, ok := os.LookupEnv(key); ok {
+					t.Errorf("%s wasn't unset", key)
+				}
+			}
+		})
+	}
+}
+
+func TestGetHealth(t *testing.T) {
+	tests := []struct {
+		name         string
+		shuttingDown bool
+		syncStatus   jsoncmd.SyncStatusType
+		wantStatus   int
+	}{
+		{"healthy", false, jsoncmd.SyncStatusOK, http.StatusOK},
+		{"not synced yet", false, "", http.StatusOK},
+		{"sync failing", false, jsoncmd.SyncStatusFailed, http.StatusServiceUnavailable},
+		{"shutting down", true, jsoncmd.SyncStatusOK, http.StatusServiceUnavailable},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			gmx := newTestGomuks(t)
+			gmx.shuttingDown.Store(test.shuttingDown)
+			if test.syncStatus != "" {
+				gmx.Client.SyncStatus.Store(&jsoncmd.SyncStatus{Type: test.syncStatus})
+			}
+			w := httptest.NewRecorder()
+			gmx.GetHealth(w, httptest.NewRequest(http.MethodGet, "/_gomuks/health", nil))
+			var resp HealthResponse
+			if w.Code != test.wantStatus {
+				t.Errorf("Status = %d, want %d", w.Code, test.wantStatus)
+			} else if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil {
+				t.Fatalf("Failed to parse response: %v", err)
+			} else if resp.OK != (test.wantStatus == http.StatusOK) {
+				t.Errorf("ok = %t with status %d", resp.OK, w.Code)
+			} else if !test.shuttingDown && !resp.LoggedIn {
+				t.Error("logged_in is false")
+			}
+		})
+	}
+}
+
+func TestRunHealthcheck(t *testing.T) {
+	tests := []struct {
+		name       string
+		unixSocket bool
+		healthy    bool
+		// The listen address to put in the config, with %s replaced by the port
+		configAddress string
+	}{
+		{"tcp healthy", false, true, ":%s"},
+		{"tcp unhealthy", false, false, ":%s"},
+		{"tcp ipv4 wildcard", false, true, "0.0.0.0:%s"},
+		{"tcp ipv6 wildcard", false, true, "[::]:%s"},
+		{"tcp loopback", false, true, "127.0.0.1:%s"},
+		{"unix socket healthy", true, true, ""},
+		{"unix socket unhealthy", true, false, ""},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			gmx := newTestGomuks(t)
+			gmx.Config.Web.ListenAddress = "127.0.0.1:0"
+			if test.unixSocket {
+				gmx.Config.Web.ListenAddress = ""
+				gmx.Config.Web.UnixSocket = filepath.Join(t.TempDir(), "gomuks.sock")
+			}
+			listeners, err := gmx.CreateListeners()
+			if err != nil {
+				t.Fatalf("CreateListeners failed: %v", err)
+			}
+			srv := &http.Server{Handler: http.HandlerFunc(gmx.GetHealth)}
+			go func() {
+				_ = srv.Serve(listeners[0])
+			}()
+			t.Cleanup(func() {
+				_ = srv.Close()
+			})
+			if !test.unixSocket {
+				// Health checks against wildcard listen addresses must go to localhost
+				_, port, _ := net.SplitHostPort(listeners[0].Addr().String())
+				gmx.Config.Web.ListenAddress = fmt.Sprintf(test.configAddress, port)
+			}
+			if !test.healthy {
+				gmx.Client.SyncStatus.Store(&jsoncmd.SyncStatus{Type: jsoncmd.SyncStatusFailed})
+			}
+			err = gmx.RunHealthcheck(context.Background())
+			if test.healthy && err != nil {
+				t.Errorf("Unexpected error: %v", err)
+			} else if !test.healthy && err == nil {
+				t.Error("Expected an error from unhealthy server")
+			}
+		})
+	}
+}
+
+func TestIsWildcardHost(t *testing.T) {
+	tests := map[string]bool{
+		"":            true,
+		"0.0.0.0":     true,
+		"::":          true,
+		"0:0::0":      true,
+		"127.0.0.1":   false,
+		"::1":         false,
+		"localhost":   false,
+		"example.com": false,
+	}
+	for host, want := range tests {
+		if got := isWildcardHost(host); got != want {
+			t.Errorf("isWildcardHost(%q) = %t, want %t", host, got, want)
+		}
+	}
+}
+
+func TestShutdown_Order(t *testing.T) {
+	gmx := newTestGomuks(t)
+	gmx.Config.Web.ListenAddress = "127.0.0.1:0"
+	gmx.Config.Web.UnixSocket = filepath.Join(t.TempDir(), "gomuks.sock")
+	listeners, err := gmx.CreateListeners()
+	if err != nil {
+		t.Fatalf("CreateListeners failed: %v", err)
+	}
+	gmx.Server = &http.Server{Handler: http.HandlerFunc(gmx.GetHealth)}
+	for _, listener := range listeners {
+		go func() {
+			_ = gmx.Server.Serve(listener)
+		}()
+	}
+	tcpAddr := listeners[0].Addr().String()
+
+	var lock sync.Mutex
+	var closedWith websocket.StatusCode
+	var steps []string
+	var listenerID uint64
+	listenerID, _ = gmx.EventBuffer.Subscribe(0, func(status websocket.StatusCode, _ string) {
+		lock.Lock()
+		defer lock.Unlock()
+		closedWith = status
+		// Listeners must already be closed, so that clients can't reconnect before the process exits
+		if conn, err := net.DialTimeout("tcp", tcpAddr, time.Second); err == nil {
+			_ = conn.Close()
+			steps = append(steps, "listener still open")
+		}
+		if !gmx.shuttingDown.Load() {
+			steps = append(steps, "not marked as shutting down")
+		}
+		// The database must still be open, so that nothing is lost while the websockets are closing
+		if err := gmx.Client.DB.RawDB.Ping(); err != nil {
+			steps = append(steps, "database already closed")
+		}
+		steps = append(steps, "websockets closed")
+	}, func(*BufferedEvent) {})
+	gmx.EventBuffer.SetFlusher(listenerID, func(context.Context) {
+		lock.Lock()
+		defer lock.Unlock()
+		// Buffered events must be sent out before the listeners are closed
+		if conn, err := net.DialTimeout("tcp", tcpAddr, time.Second); err != nil {
+			steps = append(steps, "listener closed before flush")
+		} else {
+			_ = conn.Close()
+		}
+		if !gmx.shuttingDown.Load() {
+			steps = append(steps, "not marked as shutting down before flush")
+		}
+		steps = append(steps, "events flushed")
+	})
+
+	ctx, cancel := context.WithTimeout(context.Background(), ShutdownTimeout)
+	defer cancel()
+	gmx.Shutdown(ctx)
+
+	lock.Lock()
+	defer lock.Unlock()
+	if len(steps) != 2 || steps[0] != "events flushed" || steps[1] != "websockets closed" {
+		t.Errorf("Unexpected shutdown order: %v", steps)
+	} else if closedWith != websocket.StatusServiceRestart {
+		t.Errorf("Websockets were closed with %d, want %d", closedWith, websocket.StatusServiceRestart)
+	}
+	if err = gmx.Client.DB.RawDB.Ping(); err == nil {
+		t.Error("Database is still open after shutdown")
+	}
+	if _, err = os.Stat(gmx.Config.Web.UnixSocket); !errors.Is(err, os.ErrNotExist) {
+		t.Errorf("Unix socket wasn't removed: %v", err)
+	}
+	w := httptest.NewRecorder()
+	gmx.GetHealth(w, httptest.NewRequest(http.MethodGet, "/_gomuks/health", nil))
+	if w.Code != http.StatusServiceUnavailable {
+		t.Errorf("Health endpoint returned %d after shutdown", w.Code)
+	}
+}
+
+func TestWaitForInterrupt(t *testing.T) {
+	tests := []struct {
+		name    string
+		trigger func(gmx *Gomuks)
+	}{
+		{"SIGTERM", func(*Gomuks) {
+			_ = syscall.Kill(os.Getpid(), syscall.SIGTERM)
+		}},
+		{"SIGINT", func(*Gomuks) {
+			_ = syscall.Kill(os.Getpid(), syscall.SIGINT)
+		}},
+		{"stop channel", func(gmx *Gomuks) {
+			gmx.Stop()
+		}},
+	}
+	// Catch the signals in the test too, so that a signal sent before WaitForInterrupt starts listening
+	// doesn't kill the test process.
+	guard := make(chan os.Signal, 16)
+	signal.Notify(guard, syscall.SIGTERM, syscall.SIGINT)
+	defer signal.Stop(guard)
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			gmx := newTestGomuks(t)
+			done := make(chan struct{})
+			go func() {
+				gmx.WaitForInterrupt()
+				close(done)
+			}()
+			timeout := time.After(5 * time.Second)
+			for {
+				test.trigger(gmx)
+				select {
+				case <-done:
+					return
+				case <-time.After(10 * time.Millisecond):
+				case <-timeout:
+					t.Fatal("WaitForInterrupt didn't return")
+				}
+			}
+		})
+	}
+}
+
+func TestShutdown_FlushesWebsocketEvents(t *testing.T) {
+	gmx := newTestGomuks(t)
+	srv := httptest.NewServer(http.HandlerFunc(gmx.HandleWebsocket))
+	t.Cleanup(srv.Close)
+	gmx.Server = srv.Config
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	conn, _, err := websocket.Dial(ctx, "ws"+srv.URL[len("http"):], nil)
+	if err != nil {
+		t.Fatalf("Failed to connect websocket: %v", err)
+	}
+	defer conn.CloseNow()
+	readCmd := func() (*jsoncmd.Container[json.RawMessage], error) {
+		_, data, err := conn.Read(ctx)
+		if err != nil {
+			return nil, err
+		}
+		var cmd jsoncmd.Container[json.RawMessage]
+		return &cmd, json.Unmarshal(data, &cmd)
+	}
+	// The listener is subscribed before the run ID is sent
+	if cmd, err := readCmd(); err != nil {
+		t.Fatalf("Failed to read first message: %v", err)
+	} else if cmd.Command != jsoncmd.EventRunID {
+		t.Fatalf("First message was %s, want %s", cmd.Command, jsoncmd.EventRunID)
+	}
+
+	const eventCount = 200
+	for i := range eventCount {
+		gmx.EventBuffer.Push(&jsoncmd.Typing{RoomID: id.RoomID(fmt.Sprintf("!room%d:example.com", i))})
+	}
+	go gmx.Shutdown(ctx)
+
+	var received int
+	for {
+		cmd, err := readCmd()
+		var closeErr websocket.CloseError
+		if errors.As(err, &closeErr) {
+			if closeErr.Code != websocket.StatusServiceRestart {
+				t.Errorf("Websocket closed with %d, want %d", closeErr.Code, websocket.StatusServiceRestart)
+			}
+			break
+		} else if err != nil {
+			t.Fatalf("Failed to read message: %v", err)
+		}
+		if cmd.Command == jsoncmd.EventTyping {
+			received++
+		}
+	}
+	if received != eventCount {
+		t.Errorf("Received %d of %d events pushed before shutdown", received, eventCount)
+	}
+}
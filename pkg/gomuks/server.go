@@ -29,11 +29,13 @@ import (
 	"io/fs"
 	"net/http"
 	_ "net/http/pprof"
+	"os"
 	"strconv"
 	"strings"
 	"time"
 
 	"github.com/alecthomas/chroma/v2/styles"
+	"github.com/rs/zerolog"
 	"github.com/rs/zerolog/hlog"
 	"go.mau.fi/util/exerrors"
 	"go.mau.fi/util/exhttp"
@@ -62,9 +64,18 @@ func (gmx *Gomuks) CreateAPIRouter() http.Handler {
 	api.HandleFunc("GET /keys/restorebackup/{room_id}", gmx.RestoreKeyBackup)
 	api.HandleFunc("GET /codeblock/{style}", gmx.GetCodeblockCSS)
 	api.HandleFunc("GET /url_preview", gmx.GetURLPreview)
+	api.HandleFunc("GET /widget/host", gmx.ServeWidgetHost)
+	api.HandleFunc("POST /widget/session", gmx.CreateWidgetSession)
+	api.HandleFunc("DELETE /widget/session/{session_id}", gmx.DeleteWidgetSession)
+	api.HandleFunc("GET /widget/session/{session_id}/events", gmx.StreamWidgetEvents)
+	api.HandleFunc("POST /widget/session/{session_id}/action", gmx.HandleWidgetAction)
+	api.HandleFunc("POST /widget/session/{session_id}/openid", gmx.SetWidgetOpenIDDecision)
+	if gmx.Config.Debug.Enabled {
+		gmx.addDebugRoutes(api)
+	}
 	return exhttp.ApplyMiddleware(
 		api,
-		hlog.NewHandler(*gmx.Log),
+		hlog.NewHandler(gmx.Log.With().Str("component", "rpc").Logger()),
 		hlog.RequestIDHandler("request_id", "Request-ID"),
 		requestlog.AccessLogger(requestlog.Options{}),
 	)
@@ -81,12 +92,15 @@ func (gmx *Gomuks) StartServer() {
 	if gmx.Config.Web.DebugEndpoints {
 		router.Handle("/debug/", http.DefaultServeMux)
 	}
+	router.HandleFunc("GET /_gomuks/health", gmx.GetHealth)
 	router.Handle("/_gomuks/", exhttp.ApplyMiddleware(
 		api,
 		exhttp.StripPrefix("/_gomuks"),
 		gmx.AuthMiddleware,
 	))
-	if frontend, err := fs.Sub(gmx.FrontendFS, "dist"); err != nil {
+	if gmx.Headless {
+		gmx.Log.Info().Msg("Running in headless mode, not serving frontend")
+	} else if frontend, err := fs.Sub(gmx.FrontendFS, "dist"); err != nil {
 		gmx.Log.Warn().Err(err).Msg("Frontend not found")
 	} else if indexFile, err := frontend.Open("index.html"); err != nil {
 		gmx.Log.Warn().Err(err).Msg("Failed to open frontend index.html")
@@ -111,17 +125,24 @@ func (gmx *Gomuks) StartServer() {
 			1,
 		)
 	}
+	listeners, err := gmx.CreateListeners()
+	if err != nil {
+		gmx.Log.WithLevel(zerolog.FatalLevel).Err(err).Msg("Failed to create listeners")
+		os.Exit(15)
+	}
 	gmx.Server = &http.Server{
 		Addr:    gmx.Config.Web.ListenAddress,
 		Handler: router,
 	}
-	go func() {
-		err := gmx.Server.ListenAndServe()
-		if err != nil && !errors.Is(err, http.ErrServerClosed) {
-			panic(err)
-		}
-	}()
-	gmx.Log.Info().Str("address", gmx.Config.Web.ListenAddress).Msg("Server started")
+	for _, listener := range listeners {
+		go func() {
+			err := gmx.Server.Serve(listener)
+			if err != nil && !errors.Is(err, http.ErrServerClosed) {
+				panic(err)
+			}
+		}()
+		gmx.Log.Info().Stringer("address", listener.Addr()).Msg("Server started")
+	}
 }
 
 func (gmx *Gomuks) FrontendCacheMiddleware(next http.Handler) http.Handler {
@@ -207,6 +228,9 @@ func (gmx *Gomuks) generateToken() (string, time.Time) {
 	}), expiry
 }
 
+// imageAuthTokenExpiry is how long tokens for the image_auth query parameter are valid.
+const imageAuthTokenExpiry = 1 * time.Hour
+
 func (gmx *Gomuks) generateImageToken(expiry time.Duration) jsoncmd.ImageAuthToken {
 	return jsoncmd.ImageAuthToken(gmx.signToken(tokenData{
 		Username:  gmx.Config.Web.Username,
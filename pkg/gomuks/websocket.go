@@ -66,6 +66,10 @@ func (gmx *Gomuks) HandleWebsocket(w http.ResponseWriter, r *http.Request) {
 	}
 	defer recoverPanic("read loop")
 
+	if gmx.shuttingDown.Load() {
+		ErrShuttingDown.Write(w)
+		return
+	}
 	conn, acceptErr := websocket.Accept(w, r, &websocket.AcceptOptions{
 		OriginPatterns: gmx.Config.Web.OriginPatterns,
 	})
@@ -104,6 +108,7 @@ func (gmx *Gomuks) HandleWebsocket(w http.ResponseWriter, r *http.Request) {
 	ctx = log.WithContext(ctx)
 	var listenerID uint64
 	evts := make(chan *BufferedEvent, 512)
+	flushRequests := make(chan chan struct{})
 	forceClose := func() {
 		cancel()
 		if listenerID != 0 {
@@ -139,6 +144,21 @@ func (gmx *Gomuks) HandleWebsocket(w http.ResponseWriter, r *http.Request) {
 			}()
 		}
 	})
+	gmx.EventBuffer.SetFlusher(listenerID, func(flushCtx context.Context) {
+		done := make(chan struct{})
+		select {
+		case flushRequests <- done:
+		case <-ctx.Done():
+			return
+		case <-flushCtx.Done():
+			return
+		}
+		select {
+		case <-done:
+		case <-ctx.Done():
+		case <-flushCtx.Done():
+		}
+	})
 	didResume := resumeData != nil
 
 	lastDataReceived := &atomic.Int64{}
@@ -146,7 +166,7 @@ func (gmx *Gomuks) HandleWebsocket(w http.ResponseWriter, r *http.Request) {
 	const RecvTimeout = 60 * time.Second
 	lastImageAuthTokenSent := time.Now()
 	sendImageAuthToken := func() {
-		err := writeCmd(ctx, conn, fp, jsoncmd.SpecImageAuthToken.Format(gmx.generateImageToken(1*time.Hour)))
+		err := writeCmd(ctx, conn, fp, jsoncmd.SpecImageAuthToken.Format(gmx.generateImageToken(imageAuthTokenExpiry)))
 		if err != nil {
 			log.Err(err).Msg("Failed to write image auth token message")
 			return
@@ -192,6 +212,17 @@ func (gmx *Gomuks) HandleWebsocket(w http.ResponseWriter, r *http.Request) {
 				} else {
 					log.Trace().Int64("req_id", cmd.RequestID).Msg("Sent outgoing event")
 				}
+			case done := <-flushRequests:
+				// Write everything that was queued before the flush was requested
+				for len(evts) > 0 {
+					cmd := <-evts
+					_, err := writeCmdWithExtra(ctx, conn, fp, cmd, chanToSeq(evts))
+					if err != nil {
+						log.Err(err).Int64("req_id", cmd.RequestID).Msg("Failed to write outgoing event while flushing")
+						return
+					}
+				}
+				close(done)
 			case <-ticker.C:
 				if time.Since(lastImageAuthTokenSent) > 30*time.Minute {
 					sendImageAuthToken()
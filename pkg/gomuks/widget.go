@@ -0,0 +1,592 @@
+// gomuks - A Matrix client written in Go.
+// Copyright (C) 2025 Tulir Asokan
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+package gomuks
+
+import (
+	"context"
+	_ "embed"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/rs/zerolog/hlog"
+	"go.mau.fi/util/exhttp"
+	"go.mau.fi/util/random"
+	"maunium.net/go/mautrix"
+	"maunium.net/go/mautrix/event"
+	"maunium.net/go/mautrix/id"
+
+	"go.mau.fi/gomuks/pkg/hicli/database"
+	"go.mau.fi/gomuks/pkg/hicli/jsoncmd"
+	"go.mau.fi/gomuks/pkg/widgetapi"
+)
+
+//go:embed widget-host.html
+var widgetHostPage []byte
+
+// widgetEventQueueSize is the number of events buffered per widget session before new ones are dropped.
+const widgetEventQueueSize = 128
+
+type widgetSession struct {
+	ID       string
+	WidgetID string
+	Context  widgetapi.Context
+	Caps     *widgetapi.Capabilities
+
+	events    chan *widgetMessage
+	listening bool
+}
+
+func (sess *widgetSession) key() widgetKey {
+	return widgetKey{RoomID: sess.Context.RoomID, WidgetID: sess.WidgetID}
+}
+
+type widgetMessage struct {
+	Action string `json:"action"`
+	Data   any    `json:"data"`
+}
+
+type widgetSessionStore struct {
+	lock            sync.Mutex
+	sessions        map[string]*widgetSession
+	toDeviceListens int
+	// openIDDecisions remembers whether the user allowed each widget to get OpenID tokens,
+	// so that the user is only asked once per widget.
+	openIDDecisions map[widgetKey]bool
+}
+
+type widgetKey struct {
+	RoomID   id.RoomID
+	WidgetID string
+}
+
+// ServeWidgetHost serves the page that hosts a widget in an iframe and translates its postMessage
+// widget API requests into calls to the widget session endpoints.
+func (gmx *Gomuks) ServeWidgetHost(w http.ResponseWriter, r *http.Request) {
+	if err := validateWidgetURL(r.URL.Query().Get("url"), r.Host); err != nil {
+		mautrix.MInvalidParam.WithMessage(err.Error()).Write(w)
+		return
+	}
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.WriteHeader(http.StatusOK)
+	_, _ = w.Write(widgetHostPage)
+}
+
+// validateWidgetURL checks that a widget URL is safe to load in the widget host iframe.
+// Only http(s) URLs are allowed, and the widget must not be on the same host as gomuks,
+// as it would otherwise be able to access the authenticated gomuks origin.
+func validateWidgetURL(rawURL, gomuksHost string) error {
+	parsed, err := url.Parse(rawURL)
+	if err != nil {
+		return fmt.Errorf("invalid widget URL: %w", err)
+	} else if parsed.Scheme != "http" && parsed.Scheme != "https" {
+		return fmt.Errorf("widget URL scheme must be http or https")
+	} else if parsed.Host == "" {
+		return fmt.Errorf("widget URL must have a host")
+	} else if strings.EqualFold(parsed.Host, gomuksHost) {
+		return fmt.Errorf("widget URL must not be on the same origin as gomuks")
+	}
+	return nil
+}
+
+type reqCreateWidgetSession struct {
+	RoomID       id.RoomID `json:"room_id"`
+	WidgetID     string    `json:"widget_id"`
+	Capabilities []string  `json:"capabilities"`
+	// The subset of the requested capabilities that the user approved.
+	ApprovedCapabilities []string `json:"approved_capabilities"`
+}
+
+type respCreateWidgetSession struct {
+	SessionID    string   `json:"session_id"`
+	Capabilities []string `json:"capabilities"`
+}
+
+// CreateWidgetSession negotiates the capabilities requested by a widget and starts a session for it.
+// Only capabilities that the frontend reports as approved by the user are granted.
+func (gmx *Gomuks) CreateWidgetSession(w http.ResponseWriter, r *http.Request) {
+	var req reqCreateWidgetSession
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		mautrix.MNotJSON.WithMessage("Failed to parse request body").Write(w)
+		return
+	} else if req.RoomID == "" || req.WidgetID == "" {
+		mautrix.MInvalidParam.WithMessage("Room ID and widget ID must be provided").Write(w)
+		return
+	}
+	room, err := gmx.Client.DB.Room.Get(r.Context(), req.RoomID)
+	if err != nil {
+		hlog.FromRequest(r).Err(err).Msg("Failed to get room for widget session")
+		mautrix.MUnknown.WithMessage("Failed to get room").Write(w)
+		return
+	} else if room == nil {
+		mautrix.MNotFound.WithMessage("Room not found").Write(w)
+		return
+	}
+	sess := &widgetSession{
+		ID:       random.String(32),
+		WidgetID: req.WidgetID,
+		Context: widgetapi.Context{
+			RoomID:   req.RoomID,
+			UserID:   gmx.Client.Account.UserID,
+			DeviceID: gmx.Client.Account.DeviceID,
+		},
+		events: make(chan *widgetMessage, widgetEventQueueSize),
+	}
+	sess.Caps = sess.Context.Negotiate(req.Capabilities, req.ApprovedCapabilities)
+	gmx.widgets.lock.Lock()
+	gmx.widgets.sessions[sess.ID] = sess
+	gmx.widgets.lock.Unlock()
+	hlog.FromRequest(r).Debug().
+		Str("widget_id", sess.WidgetID).
+		Stringer("room_id", req.RoomID).
+		Strs("requested_capabilities", req.Capabilities).
+		Strs("approved_capabilities", req.ApprovedCapabilities).
+		Strs("granted_capabilities", sess.Caps.Strings()).
+		Msg("Created widget session")
+	exhttp.WriteJSONResponse(w, http.StatusOK, &respCreateWidgetSession{
+		SessionID:    sess.ID,
+		Capabilities: sess.Caps.Strings(),
+	})
+}
+
+func (gmx *Gomuks) getWidgetSession(w http.ResponseWriter, r *http.Request) *widgetSession {
+	gmx.widgets.lock.Lock()
+	sess, ok := gmx.widgets.sessions[r.PathValue("session_id")]
+	gmx.widgets.lock.Unlock()
+	if !ok {
+		mautrix.MNotFound.WithMessage("Widget session not found").Write(w)
+		return nil
+	}
+	return sess
+}
+
+// DeleteWidgetSession ends a widget session.
+func (gmx *Gomuks) DeleteWidgetSession(w http.ResponseWriter, r *http.Request) {
+	gmx.widgets.lock.Lock()
+	delete(gmx.widgets.sessions, r.PathValue("session_id"))
+	gmx.widgets.lock.Unlock()
+	exhttp.WriteEmptyJSONResponse(w, http.StatusOK)
+}
+
+// StreamWidgetEvents sends the events the widget is allowed to see as server-sent events.
+// The session is deleted when the stream is closed.
+func (gmx *Gomuks) StreamWidgetEvents(w http.ResponseWriter, r *http.Request) {
+	sess := gmx.getWidgetSession(w, r)
+	if sess == nil {
+		return
+	}
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		mautrix.MUnrecognized.WithMessage("Streaming not supported").Write(w)
+		return
+	}
+	gmx.widgets.lock.Lock()
+	if sess.listening {
+		gmx.widgets.lock.Unlock()
+		mautrix.MForbidden.WithMessage("Widget session is already being listened to").Write(w)
+		return
+	}
+	sess.listening = true
+	if sess.Caps.WantsToDevice() {
+		gmx.widgets.toDeviceListens++
+		gmx.Client.ToDeviceInSync.Store(true)
+	}
+	gmx.widgets.lock.Unlock()
+	listenerID, _ := gmx.EventBuffer.Subscribe(0, nil, sess.handleEvent)
+	defer func() {
+		gmx.EventBuffer.Unsubscribe(listenerID)
+		gmx.EventBuffer.ClearListenerLastAckedID(listenerID)
+		gmx.widgets.lock.Lock()
+		delete(gmx.widgets.sessions, sess.ID)
+		if sess.Caps.WantsToDevice() {
+			gmx.widgets.toDeviceListens--
+			if gmx.widgets.toDeviceListens == 0 {
+				gmx.Client.ToDeviceInSync.Store(false)
+			}
+		}
+		gmx.widgets.lock.Unlock()
+	}()
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-store")
+	w.WriteHeader(http.StatusOK)
+	flusher.Flush()
+	keepalive := time.NewTicker(30 * time.Second)
+	defer keepalive.Stop()
+	for {
+		select {
+		case msg := <-sess.events:
+			data, err := json.Marshal(msg)
+			if err != nil {
+				hlog.FromRequest(r).Err(err).Msg("Failed to marshal widget event")
+				continue
+			}
+			_, err = fmt.Fprintf(w, "data: %s\n\n", data)
+			if err != nil {
+				return
+			}
+		case <-keepalive.C:
+			_, err := w.Write([]byte(": keepalive\n\n"))
+			if err != nil {
+				return
+			}
+		case <-r.Context().Done():
+			return
+		}
+		flusher.Flush()
+	}
+}
+
+// handleEvent is called by the event buffer for every event dispatched by hicli. It must not block.
+func (sess *widgetSession) handleEvent(evt *BufferedEvent) {
+	syncComplete, ok := evt.Data.(*jsoncmd.SyncComplete)
+	if !ok {
+		return
+	}
+	for roomID, room := range syncComplete.Rooms {
+		if !sess.Caps.CanAccessRoom(roomID) {
+			continue
+		}
+		relevant := make(map[database.EventRowID]struct{}, len(room.Timeline))
+		for _, entry := range room.Timeline {
+			relevant[entry.Event] = struct{}{}
+		}
+		for _, keys := range room.State {
+			for _, rowID := range keys {
+				relevant[rowID] = struct{}{}
+			}
+		}
+		for _, dbEvt := range room.Events {
+			if _, ok := relevant[dbEvt.RowID]; !ok {
+				continue
+			}
+			if mautrixEvt := sess.filterEvent(dbEvt); mautrixEvt != nil {
+				sess.push("send_event", mautrixEvt)
+			}
+		}
+	}
+	for _, toDevice := range syncComplete.ToDevice {
+		if sess.Caps.CanReceiveToDevice(toDevice.Type) {
+			sess.push("send_to_device", toDevice)
+		}
+	}
+}
+
+func (sess *widgetSession) push(action string, data any) {
+	select {
+	case sess.events <- &widgetMessage{Action: action, Data: data}:
+	default:
+		// The widget isn't keeping up, drop the event rather than blocking sync processing
+	}
+}
+
+// filterEvent converts the event into the form widgets expect and returns nil if the widget isn't allowed to see it.
+// Encrypted events are only passed through after they're decrypted, using the decrypted type and content.
+func (sess *widgetSession) filterEvent(dbEvt *database.Event) *event.Event {
+	evt := dbEvt.AsRawMautrix()
+	if dbEvt.Decrypted != nil {
+		evt.Type = event.Type{Type: dbEvt.DecryptedType, Class: event.MessageEventType}
+		evt.Content = event.Content{VeryRaw: dbEvt.Decrypted}
+	} else if dbEvt.Type == event.EventEncrypted.Type {
+		return nil
+	} else {
+		// AsRawMautrix applies edits to the content, but widgets expect the original event
+		evt.Content = event.Content{VeryRaw: dbEvt.Content}
+	}
+	if !sess.Caps.CanReceiveEvent(dbEvt.RoomID, evt.Type, evt.StateKey, evt.Content.VeryRaw) {
+		return nil
+	}
+	return evt
+}
+
+type reqWidgetAction struct {
+	Action string          `json:"action"`
+	Data   json.RawMessage `json:"data"`
+}
+
+var errWidgetForbidden = mautrix.MForbidden.WithMessage("Widget is missing the capability for this action")
+
+// HandleWidgetAction executes a fromWidget action after checking that the widget has the required capabilities.
+func (gmx *Gomuks) HandleWidgetAction(w http.ResponseWriter, r *http.Request) {
+	sess := gmx.getWidgetSession(w, r)
+	if sess == nil {
+		return
+	}
+	var req reqWidgetAction
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		mautrix.MNotJSON.WithMessage("Failed to parse request body").Write(w)
+		return
+	}
+	resp, err := gmx.executeWidgetAction(r.Context(), sess, req.Action, req.Data)
+	if err != nil {
+		hlog.FromRequest(r).Debug().Err(err).
+			Str("widget_id", sess.WidgetID).
+			Str("widget_action", req.Action).
+			Msg("Widget action failed")
+		var respErr mautrix.RespError
+		if errors.As(err, &respErr) {
+			respErr.Write(w)
+		} else {
+			writeMaybeRespError(err, w)
+		}
+		return
+	}
+	exhttp.WriteJSONResponse(w, http.StatusOK, resp)
+}
+
+type widgetSendEventRequest struct {
+	RoomID   id.RoomID       `json:"room_id,omitempty"`
+	Type     event.Type      `json:"type"`
+	StateKey *string         `json:"state_key,omitempty"`
+	Content  json.RawMessage `json:"content"`
+	Delay    int64           `json:"delay,omitempty"`
+}
+
+type widgetSendEventResponse struct {
+	RoomID  id.RoomID  `json:"room_id"`
+	EventID id.EventID `json:"event_id,omitempty"`
+	DelayID string     `json:"delay_id,omitempty"`
+}
+
+type widgetSendToDeviceRequest struct {
+	Type      event.Type                                   `json:"type"`
+	Encrypted bool                                         `json:"encrypted"`
+	Messages  map[id.UserID]map[id.DeviceID]*event.Content `json:"messages"`
+}
+
+type widgetReadEventsRequest struct {
+	RoomIDs  []id.RoomID     `json:"room_ids,omitempty"`
+	Type     event.Type      `json:"type"`
+	StateKey json.RawMessage `json:"state_key,omitempty"`
+	Limit    int             `json:"limit,omitempty"`
+}
+
+type widgetReadEventsResponse struct {
+	Events []*event.Event `json:"events"`
+}
+
+type widgetUpdateDelayedEventRequest struct {
+	DelayID id.DelayID        `json:"delay_id"`
+	Action  event.DelayAction `json:"action"`
+}
+
+type widgetOpenIDResponse struct {
+	State string `json:"state"`
+	*mautrix.RespOpenIDToken
+}
+
+func (gmx *Gomuks) widgetOpenIDResponse(ctx context.Context, allowed bool) (*widgetOpenIDResponse, error) {
+	if !allowed {
+		return &widgetOpenIDResponse{State: "blocked"}, nil
+	}
+	resp, err := gmx.Client.Client.RequestOpenIDToken(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return &widgetOpenIDResponse{State: "allowed", RespOpenIDToken: resp}, nil
+}
+
+type reqWidgetOpenIDDecision struct {
+	Allowed bool `json:"allowed"`
+}
+
+// SetWidgetOpenIDDecision stores the user's answer to a widget asking for an OpenID token and returns
+// the response that should be sent to the widget. The answer is remembered for later requests from the
+// same widget, so the user is only asked once.
+func (gmx *Gomuks) SetWidgetOpenIDDecision(w http.ResponseWriter, r *http.Request) {
+	sess := gmx.getWidgetSession(w, r)
+	if sess == nil {
+		return
+	}
+	var req reqWidgetOpenIDDecision
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		mautrix.MNotJSON.WithMessage("Failed to parse request body").Write(w)
+		return
+	}
+	gmx.widgets.lock.Lock()
+	allowed, decided := gmx.widgets.openIDDecisions[sess.key()]
+	if !decided {
+		allowed = req.Allowed
+		gmx.widgets.openIDDecisions[sess.key()] = allowed
+	}
+	gmx.widgets.lock.Unlock()
+	hlog.FromRequest(r).Debug().
+		Str("widget_id", sess.WidgetID).
+		Stringer("room_id", sess.Context.RoomID).
+		Bool("allowed", allowed).
+		Msg("Stored widget OpenID decision")
+	resp, err := gmx.widgetOpenIDResponse(r.Context(), allowed)
+	if err != nil {
+		hlog.FromRequest(r).Err(err).Msg("Failed to get OpenID token for widget")
+		mautrix.MUnknown.WithMessage("Failed to get OpenID token").Write(w)
+		return
+	}
+	exhttp.WriteJSONResponse(w, http.StatusOK, resp)
+}
+
+func (gmx *Gomuks) executeWidgetAction(ctx context.Context, sess *widgetSession, action string, data json.RawMessage) (any, error) {
+	switch action {
+	case "send_event":
+		var req widgetSendEventRequest
+		if err := json.Unmarshal(data, &req); err != nil {
+			return nil, mautrix.MBadJSON.WithMessage("Failed to parse send_event request")
+		}
+		return gmx.widgetSendEvent(ctx, sess, &req)
+	case "send_to_device":
+		var req widgetSendToDeviceRequest
+		if err := json.Unmarshal(data, &req); err != nil {
+			return nil, mautrix.MBadJSON.WithMessage("Failed to parse send_to_device request")
+		}
+		req.Type.Class = event.ToDeviceEventType
+		if !sess.Caps.CanSendToDevice(req.Type) {
+			return nil, errWidgetForbidden
+		}
+		_, err := gmx.Client.SendToDevice(ctx, req.Type, &mautrix.ReqSendToDevice{Messages: req.Messages}, req.Encrypted)
+		return struct{}{}, err
+	case "org.matrix.msc2876.read_events":
+		var req widgetReadEventsRequest
+		if err := json.Unmarshal(data, &req); err != nil {
+			return nil, mautrix.MBadJSON.WithMessage("Failed to parse read_events request")
+		}
+		return gmx.widgetReadEvents(ctx, sess, &req)
+	case "get_openid":
+		gmx.widgets.lock.Lock()
+		allowed, decided := gmx.widgets.openIDDecisions[sess.key()]
+		gmx.widgets.lock.Unlock()
+		if !decided {
+			// The host page will ask the user and send the answer to the openid endpoint.
+			return &widgetOpenIDResponse{State: "request"}, nil
+		}
+		return gmx.widgetOpenIDResponse(ctx, allowed)
+	case "watch_turn_servers", "org.matrix.msc3846.watch_turn_servers":
+		if !sess.Caps.Has(widgetapi.KindTurnServers) {
+			return nil, errWidgetForbidden
+		}
+		return gmx.Client.Client.TurnServer(ctx)
+	case "org.matrix.msc4157.update_delayed_event":
+		var req widgetUpdateDelayedEventRequest
+		if err := json.Unmarshal(data, &req); err != nil {
+			return nil, mautrix.MBadJSON.WithMessage("Failed to parse update_delayed_event request")
+		} else if !sess.Caps.Has(widgetapi.KindUpdateDelayedEvent) {
+			return nil, errWidgetForbidden
+		}
+		return gmx.Client.Client.UpdateDelayedEvent(ctx, &mautrix.ReqUpdateDelayedEvent{
+			DelayID: req.DelayID,
+			Action:  req.Action,
+		})
+	default:
+		return nil, mautrix.MUnrecognized.WithMessage("Unsupported widget action %q", action)
+	}
+}
+
+func (gmx *Gomuks) widgetSendEvent(ctx context.Context, sess *widgetSession, req *widgetSendEventRequest) (*widgetSendEventResponse, error) {
+	roomID := req.RoomID
+	if roomID == "" {
+		roomID = sess.Context.RoomID
+	}
+	if req.Delay > 0 && !sess.Caps.Has(widgetapi.KindSendDelayedEvent) {
+		return nil, errWidgetForbidden
+	}
+	if req.StateKey != nil {
+		req.Type.Class = event.StateEventType
+		if !sess.Caps.CanSendStateEvent(roomID, req.Type, *req.StateKey) {
+			return nil, errWidgetForbidden
+		}
+		eventID, err := gmx.Client.SetState(ctx, roomID, req.Type, *req.StateKey, req.Content, mautrix.ReqSendEvent{
+			UnstableDelay: time.Duration(req.Delay) * time.Millisecond,
+		})
+		if err != nil {
+			return nil, err
+		} else if req.Delay > 0 {
+			return &widgetSendEventResponse{RoomID: roomID, DelayID: string(eventID)}, nil
+		}
+		return &widgetSendEventResponse{RoomID: roomID, EventID: eventID}, nil
+	}
+	req.Type.Class = event.MessageEventType
+	if !sess.Caps.CanSendEvent(roomID, req.Type, req.Content) {
+		return nil, errWidgetForbidden
+	} else if req.Delay > 0 {
+		return nil, mautrix.MUnrecognized.WithMessage("Delayed message events are not supported")
+	}
+	dbEvt, err := gmx.Client.Send(ctx, roomID, req.Type, req.Content, false, true)
+	if err != nil {
+		return nil, err
+	} else if dbEvt.SendError != "" {
+		return nil, mautrix.MUnknown.WithMessage("%s", dbEvt.SendError)
+	}
+	return &widgetSendEventResponse{RoomID: roomID, EventID: dbEvt.ID}, nil
+}
+
+// widgetReadEventsDefaultLimit is the number of events returned by read_events if the widget doesn't specify a limit.
+const widgetReadEventsDefaultLimit = 50
+
+func (gmx *Gomuks) widgetReadEvents(ctx context.Context, sess *widgetSession, req *widgetReadEventsRequest) (*widgetReadEventsResponse, error) {
+	roomIDs := req.RoomIDs
+	if len(roomIDs) == 0 {
+		roomIDs = []id.RoomID{sess.Context.RoomID}
+	}
+	// state_key is either a specific state key or `true` to read all state events of the type
+	var stateKey *string
+	var isState bool
+	if len(req.StateKey) > 0 {
+		isState = true
+		if err := json.Unmarshal(req.StateKey, &stateKey); err != nil {
+			stateKey = nil
+		}
+	}
+	limit := req.Limit
+	if limit <= 0 || limit > widgetReadEventsDefaultLimit {
+		limit = widgetReadEventsDefaultLimit
+	}
+	resp := &widgetReadEventsResponse{Events: []*event.Event{}}
+	for _, roomID := range roomIDs {
+		if !sess.Caps.CanAccessRoom(roomID) {
+			return nil, errWidgetForbidden
+		}
+		var events []*database.Event
+		var err error
+		if isState {
+			req.Type.Class = event.StateEventType
+			if !sess.Caps.CanReadState(roomID, req.Type, stateKey) {
+				return nil, errWidgetForbidden
+			}
+			events, err = gmx.Client.DB.CurrentState.GetAll(ctx, roomID)
+		} else {
+			events, err = gmx.Client.DB.Timeline.Get(ctx, roomID, limit, 0)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to get events: %w", err)
+		}
+		for _, dbEvt := range events {
+			if isState && (dbEvt.Type != req.Type.Type || dbEvt.StateKey == nil || (stateKey != nil && *dbEvt.StateKey != *stateKey)) {
+				continue
+			} else if !isState && dbEvt.Type != req.Type.Type && dbEvt.DecryptedType != req.Type.Type {
+				continue
+			}
+			if evt := sess.filterEvent(dbEvt); evt != nil {
+				resp.Events = append(resp.Events, evt)
+			}
+		}
+	}
+	return resp, nil
+}
@@ -0,0 +1,91 @@
+// gomuks - A Matrix client written in Go.
+// Copyright (C) 2026 Tulir Asokan
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+package gomuks
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+)
+
+func TestValidateWidgetURL(t *testing.T) {
+	tests := []struct {
+		name    string
+		url     string
+		wantErr bool
+	}{
+		{"https", "https://widgets.example.com/etherpad?room=$matrix_room_id", false},
+		{"http", "http://widgets.example.com/", false},
+		{"different port on same hostname", "https://gomuks.example.com:8443/widget", false},
+		{"javascript", "javascript:alert(document.cookie)", true},
+		{"data", "data:text/html,<script>alert(1)</script>", true},
+		{"file", "file:///etc/passwd", true},
+		{"no scheme", "//widgets.example.com/", true},
+		{"relative", "_gomuks/widget/host", true},
+		{"empty", "", true},
+		{"same origin", "https://gomuks.example.com/_gomuks/media", true},
+		{"same origin uppercase", "https://GOMUKS.example.com/", true},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			err := validateWidgetURL(test.url, "gomuks.example.com")
+			if test.wantErr && err == nil {
+				t.Errorf("validateWidgetURL(%q) succeeded, want error", test.url)
+			} else if !test.wantErr && err != nil {
+				t.Errorf("validateWidgetURL(%q) failed: %v", test.url, err)
+			}
+		})
+	}
+}
+
+func TestServeWidgetHost(t *testing.T) {
+	gmx := &Gomuks{}
+	serve := func(widgetURL string) *httptest.ResponseRecorder {
+		params := url.Values{"room_id": {"!room:example.com"}, "widget_id": {"widget"}, "url": {widgetURL}}
+		req := httptest.NewRequest(http.MethodGet, "http://gomuks.example.com/_gomuks/widget/host?"+params.Encode(), nil)
+		w := httptest.NewRecorder()
+		gmx.ServeWidgetHost(w, req)
+		return w
+	}
+
+	t.Run("valid", func(t *testing.T) {
+		w := serve("https://widgets.example.com/")
+		if w.Code != http.StatusOK {
+			t.Fatalf("Got status %d, want %d", w.Code, http.StatusOK)
+		}
+		if !strings.Contains(w.Body.String(), `iframe.sandbox = "allow-scripts allow-forms allow-popups`) {
+			t.Error("Widget host page doesn't sandbox the widget iframe")
+		}
+	})
+	for _, widgetURL := range []string{
+		"javascript:alert(1)",
+		"data:text/html,<script>alert(1)</script>",
+		"http://gomuks.example.com/_gomuks/widget/host",
+	} {
+		t.Run(widgetURL, func(t *testing.T) {
+			w := serve(widgetURL)
+			if w.Code != http.StatusBadRequest {
+				t.Errorf("Got status %d, want %d", w.Code, http.StatusBadRequest)
+			}
+			if strings.Contains(w.Body.String(), "<iframe") || strings.Contains(w.Body.String(), "createElement") {
+				t.Error("Widget host page was served for a rejected URL")
+			}
+		})
+	}
+}
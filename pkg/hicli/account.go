@@ -0,0 +1,134 @@
+// Copyright (c) 2025 Tulir Asokan
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package hicli
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/rs/zerolog"
+	"maunium.net/go/mautrix"
+
+	"go.mau.fi/gomuks/pkg/hicli/jsoncmd"
+)
+
+type reqChangePassword struct {
+	NewPassword   string `json:"new_password"`
+	LogoutDevices bool   `json:"logout_devices"`
+	Auth          any    `json:"auth,omitempty"`
+}
+
+type reqDeactivateAccount struct {
+	Erase bool `json:"erase"`
+	Auth  any  `json:"auth,omitempty"`
+}
+
+func (h *HiClient) ChangePassword(ctx context.Context, params *jsoncmd.ChangePasswordParams) (*jsoncmd.UIAResponse, error) {
+	if params.NewPassword == "" {
+		return nil, fmt.Errorf("new password must not be empty")
+	}
+	req := &reqChangePassword{
+		NewPassword:   params.NewPassword,
+		LogoutDevices: params.LogoutDevices,
+	}
+	return h.doPasswordUIARequest(ctx, h.Client.BuildClientURL("v3", "account", "password"), params.OldPassword, req, &req.Auth)
+}
+
+func (h *HiClient) SetDeviceName(ctx context.Context, params *jsoncmd.SetDeviceNameParams) error {
+	name := strings.TrimSpace(params.Name)
+	if name == "" {
+		return fmt.Errorf("device name must not be empty")
+	}
+	deviceID := params.DeviceID
+	if deviceID == "" {
+		deviceID = h.Account.DeviceID
+	}
+	err := h.Client.SetDeviceInfo(ctx, deviceID, &mautrix.ReqDeviceInfo{DisplayName: name})
+	if err != nil {
+		return err
+	}
+	if deviceID == h.Account.DeviceID {
+		h.deviceName.Store(&name)
+		h.dispatchCurrentState()
+	}
+	return nil
+}
+
+// loadDeviceName fetches the display name of the current device, so that frontends can show it
+// in the client state. Failing to fetch it is not critical, the name just won't be shown.
+func (h *HiClient) loadDeviceName(ctx context.Context) {
+	// If SetDeviceName is called while the request is in flight, the response may have the old name,
+	// so the fetched name is only stored if the cached one hasn't been replaced in the meantime.
+	prevName := h.deviceName.Load()
+	resp, err := h.Client.GetDeviceInfo(ctx, h.Account.DeviceID)
+	if err != nil {
+		zerolog.Ctx(ctx).Warn().Err(err).Msg("Failed to get current device name")
+		return
+	} else if !h.deviceName.CompareAndSwap(prevName, &resp.DisplayName) {
+		zerolog.Ctx(ctx).Debug().Msg("Device name was changed while fetching it, ignoring fetched name")
+		return
+	}
+	h.dispatchCurrentState()
+}
+
+func (h *HiClient) DeactivateAccount(ctx context.Context, params *jsoncmd.DeactivateAccountParams) (*jsoncmd.UIAResponse, error) {
+	req := &reqDeactivateAccount{
+		Erase: params.Erase,
+	}
+	resp, err := h.doPasswordUIARequest(ctx, h.Client.BuildClientURL("v3", "account", "deactivate"), params.Password, req, &req.Auth)
+	if err != nil || !resp.Success {
+		return resp, err
+	}
+	zerolog.Ctx(ctx).Info().Bool("erase", params.Erase).Msg("Account deactivated")
+	return resp, nil
+}
+
+// doPasswordUIARequest makes a request to an endpoint that requires user-interactive authentication
+// and completes the authentication using the m.login.password stage. If the server rejects the
+// password or requires other stages, the UIA response is returned instead of an error.
+func (h *HiClient) doPasswordUIARequest(ctx context.Context, url, password string, req any, auth *any) (*jsoncmd.UIAResponse, error) {
+	uiaResp, err := h.makeUIARequest(ctx, url, req)
+	if err != nil || uiaResp == nil {
+		return &jsoncmd.UIAResponse{Success: err == nil}, err
+	} else if !uiaResp.HasSingleStageFlow(mautrix.AuthTypePassword) {
+		return &jsoncmd.UIAResponse{UIA: uiaResp}, nil
+	}
+	*auth = &mautrix.ReqUIAuthLogin{
+		BaseAuthData: mautrix.BaseAuthData{
+			Type:    mautrix.AuthTypePassword,
+			Session: uiaResp.Session,
+		},
+		User:     h.Account.UserID.String(),
+		Password: password,
+	}
+	uiaResp, err = h.makeUIARequest(ctx, url, req)
+	if err != nil {
+		return nil, err
+	}
+	return &jsoncmd.UIAResponse{Success: uiaResp == nil, UIA: uiaResp}, nil
+}
+
+func (h *HiClient) makeUIARequest(ctx context.Context, url string, req any) (*mautrix.RespUserInteractive, error) {
+	respData, err := h.Client.MakeFullRequest(ctx, mautrix.FullRequest{
+		Method:           http.MethodPost,
+		URL:              url,
+		RequestJSON:      req,
+		SensitiveContent: true,
+	})
+	var httpErr mautrix.HTTPError
+	if errors.As(err, &httpErr) && httpErr.IsStatus(http.StatusUnauthorized) {
+		var uiaResp mautrix.RespUserInteractive
+		if json.Unmarshal(respData, &uiaResp) == nil && (uiaResp.Session != "" || len(uiaResp.Flows) > 0) {
+			return &uiaResp, nil
+		}
+	}
+	return nil, err
+}
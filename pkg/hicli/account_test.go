@@ -0,0 +1,171 @@
+// Copyright (c) 2026 Tulir Asokan
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package hicli
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"sync"
+	"testing"
+
+	"maunium.net/go/mautrix"
+	"maunium.net/go/mautrix/id"
+
+	"go.mau.fi/gomuks/pkg/hicli/jsoncmd"
+)
+
+// fakeDeviceServer implements the device info endpoints of the client-server API.
+type fakeDeviceServer struct {
+	lock  sync.Mutex
+	names map[id.DeviceID]string
+	puts  []string
+	// getStarted and releaseGet can be set to pause GET requests after they've been received.
+	getStarted chan struct{}
+	releaseGet chan struct{}
+}
+
+func (fds *fakeDeviceServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	deviceID, ok := strings.CutPrefix(r.URL.Path, "/_matrix/client/v3/devices/")
+	if !ok {
+		http.NotFound(w, r)
+		return
+	}
+	switch r.Method {
+	case http.MethodGet:
+		fds.lock.Lock()
+		name, ok := fds.names[id.DeviceID(deviceID)]
+		fds.lock.Unlock()
+		if fds.getStarted != nil {
+			close(fds.getStarted)
+			<-fds.releaseGet
+		}
+		if !ok {
+			w.WriteHeader(http.StatusNotFound)
+			_, _ = w.Write([]byte(`{"errcode":"M_NOT_FOUND","error":"Unknown device"}`))
+			return
+		}
+		_ = json.NewEncoder(w).Encode(&mautrix.RespDeviceInfo{DeviceID: id.DeviceID(deviceID), DisplayName: name})
+	case http.MethodPut:
+		var req mautrix.ReqDeviceInfo
+		_ = json.NewDecoder(r.Body).Decode(&req)
+		fds.lock.Lock()
+		defer fds.lock.Unlock()
+		fds.puts = append(fds.puts, deviceID+"="+req.DisplayName)
+		if _, ok = fds.names[id.DeviceID(deviceID)]; !ok {
+			w.WriteHeader(http.StatusNotFound)
+			_, _ = w.Write([]byte(`{"errcode":"M_NOT_FOUND","error":"Unknown device"}`))
+			return
+		}
+		fds.names[id.DeviceID(deviceID)] = req.DisplayName
+		_, _ = w.Write([]byte("{}"))
+	default:
+		w.WriteHeader(http.StatusMethodNotAllowed)
+	}
+}
+
+func newTestDeviceClient(t *testing.T) (*HiClient, *testEvents, *fakeDeviceServer) {
+	t.Helper()
+	h, events := newTestClient(t)
+	fds := &fakeDeviceServer{names: map[id.DeviceID]string{
+		testDeviceID: InitialDeviceDisplayName,
+		"OTHER":      "Other device",
+	}}
+	srv := httptest.NewServer(fds)
+	t.Cleanup(srv.Close)
+	h.Client.HomeserverURL, _ = url.Parse(srv.URL)
+	h.Client.AccessToken = "fake"
+	return h, events, fds
+}
+
+func TestSetDeviceName(t *testing.T) {
+	tests := []struct {
+		name           string
+		params         jsoncmd.SetDeviceNameParams
+		wantErr        bool
+		wantPut        string
+		wantCachedName string
+	}{
+		{"current device", jsoncmd.SetDeviceNameParams{Name: "My laptop"}, false, "TESTDEVICE=My laptop", "My laptop"},
+		{"current device by ID", jsoncmd.SetDeviceNameParams{DeviceID: testDeviceID, Name: "My laptop"}, false, "TESTDEVICE=My laptop", "My laptop"},
+		{"trims whitespace", jsoncmd.SetDeviceNameParams{Name: "  My laptop\n"}, false, "TESTDEVICE=My laptop", "My laptop"},
+		{"other device", jsoncmd.SetDeviceNameParams{DeviceID: "OTHER", Name: "Phone"}, false, "OTHER=Phone", InitialDeviceDisplayName},
+		{"empty name", jsoncmd.SetDeviceNameParams{Name: "   "}, true, "", InitialDeviceDisplayName},
+		{"unknown device", jsoncmd.SetDeviceNameParams{DeviceID: "UNKNOWN", Name: "Phone"}, true, "UNKNOWN=Phone", InitialDeviceDisplayName},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			h, events, fds := newTestDeviceClient(t)
+			initialName := InitialDeviceDisplayName
+			h.deviceName.Store(&initialName)
+
+			err := h.SetDeviceName(context.Background(), &test.params)
+			if test.wantErr && err == nil {
+				t.Error("Expected an error")
+			} else if !test.wantErr && err != nil {
+				t.Errorf("Unexpected error: %v", err)
+			}
+			if test.wantPut == "" && len(fds.puts) != 0 {
+				t.Errorf("Unexpected requests to server: %v", fds.puts)
+			} else if test.wantPut != "" && (len(fds.puts) != 1 || fds.puts[0] != test.wantPut) {
+				t.Errorf("Requests to server = %v, want [%s]", fds.puts, test.wantPut)
+			}
+			if name := h.State().DeviceName; name != test.wantCachedName {
+				t.Errorf("Cached device name = %q, want %q", name, test.wantCachedName)
+			}
+			// Only renaming the current device changes the client state
+			var stateDispatched bool
+			for _, evt := range events.all() {
+				if state, ok := evt.(*jsoncmd.ClientState); ok && state.DeviceName == test.wantCachedName {
+					stateDispatched = true
+				}
+			}
+			if wantDispatch := test.wantCachedName != InitialDeviceDisplayName; stateDispatched != wantDispatch {
+				t.Errorf("Client state dispatched = %t, want %t", stateDispatched, wantDispatch)
+			}
+		})
+	}
+}
+
+func TestLoadDeviceName(t *testing.T) {
+	h, _, fds := newTestDeviceClient(t)
+	fds.names[testDeviceID] = "Renamed elsewhere"
+	h.loadDeviceName(context.Background())
+	if name := h.State().DeviceName; name != "Renamed elsewhere" {
+		t.Errorf("Cached device name = %q, want the name from the server", name)
+	}
+}
+
+func TestLoadDeviceName_RaceWithRename(t *testing.T) {
+	h, _, fds := newTestDeviceClient(t)
+	fds.getStarted = make(chan struct{})
+	fds.releaseGet = make(chan struct{})
+	// Login caches the initial name before the sync loop starts fetching the real name
+	initialName := InitialDeviceDisplayName
+	h.deviceName.Store(&initialName)
+
+	loadDone := make(chan struct{})
+	go func() {
+		defer close(loadDone)
+		h.loadDeviceName(context.Background())
+	}()
+	<-fds.getStarted
+	// The server has read the old name, but the response hasn't been delivered yet
+	err := h.SetDeviceName(context.Background(), &jsoncmd.SetDeviceNameParams{Name: "My laptop"})
+	if err != nil {
+		t.Fatalf("SetDeviceName failed: %v", err)
+	}
+	close(fds.releaseGet)
+	<-loadDone
+
+	if name := h.State().DeviceName; name != "My laptop" {
+		t.Errorf("Cached device name = %q, the rename was overwritten by the stale fetch", name)
+	}
+}
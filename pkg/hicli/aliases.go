@@ -0,0 +1,135 @@
+// Copyright (c) 2025 Tulir Asokan
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package hicli
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"slices"
+
+	"maunium.net/go/mautrix"
+	"maunium.net/go/mautrix/event"
+	"maunium.net/go/mautrix/id"
+
+	"go.mau.fi/gomuks/pkg/hicli/jsoncmd"
+)
+
+var (
+	ErrAliasInUse        = errors.New("alias is already in use")
+	ErrAliasNotFound     = errors.New("alias not found")
+	ErrAliasNotPermitted = errors.New("not allowed to manage this alias")
+	ErrAliasWrongRoom    = errors.New("alias doesn't point to this room")
+)
+
+func wrapAliasError(alias id.RoomAlias, err error) error {
+	var httpErr mautrix.HTTPError
+	if errors.Is(err, mautrix.MForbidden) {
+		return fmt.Errorf("%w %s: %w", ErrAliasNotPermitted, alias, err)
+	} else if errors.Is(err, mautrix.MNotFound) {
+		return fmt.Errorf("%w: %s", ErrAliasNotFound, alias)
+	} else if errors.As(err, &httpErr) && httpErr.IsStatus(http.StatusConflict) {
+		return fmt.Errorf("%w: %s", ErrAliasInUse, alias)
+	}
+	return err
+}
+
+func (h *HiClient) CreateAlias(ctx context.Context, params *jsoncmd.CreateAliasParams) error {
+	_, err := h.Client.CreateAlias(ctx, params.Alias, params.RoomID)
+	if err != nil {
+		return wrapAliasError(params.Alias, err)
+	}
+	if params.Canonical == jsoncmd.CanonicalAliasNone {
+		return nil
+	}
+	return h.updateCanonicalAlias(ctx, params.RoomID, func(content *event.CanonicalAliasEventContent) bool {
+		if params.Canonical == jsoncmd.CanonicalAliasMain {
+			if content.Alias == params.Alias {
+				return false
+			}
+			if content.Alias != "" && !slices.Contains(content.AltAliases, content.Alias) {
+				content.AltAliases = append(content.AltAliases, content.Alias)
+			}
+			content.Alias = params.Alias
+			content.AltAliases = slices.DeleteFunc(content.AltAliases, func(alias id.RoomAlias) bool {
+				return alias == params.Alias
+			})
+			return true
+		} else if !slices.Contains(content.AltAliases, params.Alias) && content.Alias != params.Alias {
+			content.AltAliases = append(content.AltAliases, params.Alias)
+			return true
+		}
+		return false
+	})
+}
+
+func (h *HiClient) DeleteAlias(ctx context.Context, params *jsoncmd.DeleteAliasParams) error {
+	if params.RoomID != "" {
+		resp, err := h.Client.ResolveAlias(ctx, params.Alias)
+		if err != nil {
+			return wrapAliasError(params.Alias, err)
+		} else if resp.RoomID != params.RoomID {
+			return fmt.Errorf("%w: %s", ErrAliasWrongRoom, params.Alias)
+		}
+	}
+	_, err := h.Client.DeleteAlias(ctx, params.Alias)
+	if err != nil {
+		return wrapAliasError(params.Alias, err)
+	}
+	if params.RoomID == "" || !params.UpdateCanonical {
+		return nil
+	}
+	return h.updateCanonicalAlias(ctx, params.RoomID, func(content *event.CanonicalAliasEventContent) bool {
+		changed := false
+		if content.Alias == params.Alias {
+			content.Alias = ""
+			changed = true
+		}
+		if slices.Contains(content.AltAliases, params.Alias) {
+			content.AltAliases = slices.DeleteFunc(content.AltAliases, func(alias id.RoomAlias) bool {
+				return alias == params.Alias
+			})
+			changed = true
+		}
+		return changed
+	})
+}
+
+func (h *HiClient) GetLocalAliases(ctx context.Context, roomID id.RoomID) ([]id.RoomAlias, error) {
+	resp, err := h.Client.GetAliases(ctx, roomID)
+	if err != nil {
+		return nil, err
+	}
+	return resp.Aliases, nil
+}
+
+func (h *HiClient) updateCanonicalAlias(
+	ctx context.Context,
+	roomID id.RoomID,
+	update func(content *event.CanonicalAliasEventContent) bool,
+) error {
+	var content event.CanonicalAliasEventContent
+	evt, err := h.DB.CurrentState.Get(ctx, roomID, event.StateCanonicalAlias, "")
+	if err != nil {
+		return fmt.Errorf("failed to get current canonical alias event: %w", err)
+	} else if evt != nil {
+		err = json.Unmarshal(evt.GetContent(), &content)
+		if err != nil {
+			return fmt.Errorf("failed to parse current canonical alias event: %w", err)
+		}
+	}
+	if !update(&content) {
+		return nil
+	}
+	_, err = h.SetState(ctx, roomID, event.StateCanonicalAlias, "", &content)
+	if err != nil {
+		return fmt.Errorf("failed to update canonical alias event: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,47 @@
+// Copyright (c) 2025 Tulir Asokan
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package hicli
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"maunium.net/go/mautrix"
+	"maunium.net/go/mautrix/id"
+
+	"go.mau.fi/gomuks/pkg/hicli/database"
+)
+
+var ErrRoomNotArchived = errors.New("room is not archived")
+
+// GetLeftRooms returns the metadata of all rooms that the user has left, but which haven't been forgotten.
+func (h *HiClient) GetLeftRooms(ctx context.Context) ([]*database.Room, error) {
+	return h.DB.Room.GetLeft(ctx)
+}
+
+// ForgetRoom forgets a room that the user has left, both on the server and in the local database.
+func (h *HiClient) ForgetRoom(ctx context.Context, roomID id.RoomID) error {
+	room, err := h.DB.Room.Get(ctx, roomID)
+	if err != nil {
+		return fmt.Errorf("failed to get room from database: %w", err)
+	} else if room != nil && room.LeftAt.IsZero() {
+		return ErrRoomNotArchived
+	}
+	_, err = h.Client.ForgetRoom(mautrix.WithMaxRetries(ctx, 2), roomID)
+	// If the server doesn't know about the room anymore, it's fine to delete the local data anyway.
+	if err != nil && !errors.Is(err, mautrix.MNotFound) {
+		return err
+	} else if room == nil {
+		return nil
+	}
+	err = h.DB.Room.Delete(ctx, roomID)
+	if err != nil {
+		return fmt.Errorf("failed to delete room from database: %w", err)
+	}
+	return nil
+}
@@ -32,9 +32,11 @@ const (
 	RawState       = "rawstate"
 	DiscardSession = "discardsession"
 	Devtools       = "devtools"
+	JumpToDate     = "jump-to-date"
 	Meow           = "meow"
 	AddAlias       = "alias add"
 	DelAlias       = "alias del"
+	ListAliases    = "alias list"
 )
 
 var CommandDefinitions = []*cmdschema.EventContent{{
@@ -63,6 +65,13 @@ var CommandDefinitions = []*cmdschema.EventContent{{
 	Command:     Leave,
 	Aliases:     []string{"part"},
 	Description: event.MakeExtensibleText("Leave the current room"),
+	Parameters: []*cmdschema.Parameter{{
+		Key:         "reason",
+		Schema:      cmdschema.PrimitiveTypeString.Schema(),
+		Description: event.MakeExtensibleText("Reason for leaving"),
+		Optional:    true,
+	}},
+	TailParam: "reason",
 }, {
 	Command:     Invite,
 	Description: event.MakeExtensibleText("Invite a user to the current room"),
@@ -203,22 +212,46 @@ var CommandDefinitions = []*cmdschema.EventContent{{
 }, {
 	Command:     Devtools,
 	Description: event.MakeExtensibleText("Open the room state explorer"),
+}, {
+	Command:     JumpToDate,
+	Description: event.MakeExtensibleText("View the messages sent around a date"),
+	Parameters: []*cmdschema.Parameter{{
+		Key:         "date",
+		Schema:      cmdschema.PrimitiveTypeString.Schema(),
+		Description: event.MakeExtensibleText("The date, e.g. 2025-03-03, yesterday or last monday. A date picker is opened if omitted"),
+		Optional:    true,
+	}},
+	TailParam: "date",
 }, {
 	Command:     AddAlias,
-	Description: event.MakeExtensibleText("Add a room alias to the current room. Does not update the canonical alias event."),
+	Description: event.MakeExtensibleText("Publish a room alias for the current room in the room directory"),
 	Parameters: []*cmdschema.Parameter{{
 		Key:         "name",
 		Schema:      cmdschema.PrimitiveTypeString.Schema(),
-		Description: event.MakeExtensibleText("Room alias name to add (without the # and domain)"),
+		Description: event.MakeExtensibleText("Room alias to add (either a full alias or just the name without the # and domain)"),
+	}, {
+		Key:         "canonical",
+		Schema:      cmdschema.PrimitiveTypeBoolean.Schema(),
+		Description: event.MakeExtensibleText("Set the alias as the main address of the room"),
+		Optional:    true,
+	}, {
+		Key:         "alt",
+		Schema:      cmdschema.PrimitiveTypeBoolean.Schema(),
+		Description: event.MakeExtensibleText("Add the alias to the alternative addresses of the room"),
+		Optional:    true,
 	}},
 	Aliases: []string{"alias create"},
 }, {
 	Command:     DelAlias,
-	Description: event.MakeExtensibleText("Remove a room alias from the current room. Does not update the canonical alias event."),
+	Description: event.MakeExtensibleText("Remove a room alias of the current room from the room directory and the canonical alias event"),
 	Parameters: []*cmdschema.Parameter{{
 		Key:         "name",
 		Schema:      cmdschema.PrimitiveTypeString.Schema(),
-		Description: event.MakeExtensibleText("Room alias name to remove (without the # and domain)"),
+		Description: event.MakeExtensibleText("Room alias to remove (either a full alias or just the name without the # and domain)"),
 	}},
 	Aliases: []string{"alias remove", "alias rm", "alias delete"},
+}, {
+	Command:     ListAliases,
+	Description: event.MakeExtensibleText("List the aliases of the current room published on your homeserver"),
+	Aliases:     []string{"alias ls"},
 }}
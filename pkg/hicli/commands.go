@@ -22,6 +22,7 @@ import (
 
 	"go.mau.fi/gomuks/pkg/hicli/cmdspec"
 	"go.mau.fi/gomuks/pkg/hicli/database"
+	"go.mau.fi/gomuks/pkg/hicli/jsoncmd"
 )
 
 func (h *HiClient) ProcessCommand(
@@ -48,7 +49,7 @@ func (h *HiClient) ProcessCommand(
 	case cmdspec.Join:
 		responseText, retErr = callWithParsedArgs(ctx, roomID, cmd.Arguments, relatesTo, h.handleCmdJoin)
 	case cmdspec.Leave:
-		responseText = h.handleCmdLeave(ctx, roomID)
+		responseText, retErr = callWithParsedArgs(ctx, roomID, cmd.Arguments, relatesTo, h.handleCmdLeave)
 	case cmdspec.MyRoomNick:
 		responseText, retErr = callWithParsedArgs(ctx, roomID, cmd.Arguments, relatesTo, h.handleCmdMyRoomNick)
 	case cmdspec.MyRoomAvatar:
@@ -73,6 +74,8 @@ func (h *HiClient) ProcessCommand(
 		responseText, retErr = callWithParsedArgs(ctx, roomID, cmd.Arguments, relatesTo, h.handleCmdAddAlias)
 	case cmdspec.DelAlias:
 		responseText, retErr = callWithParsedArgs(ctx, roomID, cmd.Arguments, relatesTo, h.handleCmdDelAlias)
+	case cmdspec.ListAliases:
+		responseText = h.handleCmdListAliases(ctx, roomID)
 	default:
 		responseHTML = fmt.Sprintf("Unknown command <code>%s</code>", html.EscapeString(cmd.Command))
 	}
@@ -173,8 +176,12 @@ func (h *HiClient) handleCmdJoin(ctx context.Context, _ id.RoomID, args joinArgs
 	return ""
 }
 
-func (h *HiClient) handleCmdLeave(ctx context.Context, roomID id.RoomID) string {
-	_, err := h.Client.LeaveRoom(ctx, roomID)
+type leaveArgs struct {
+	Reason string `json:"reason"`
+}
+
+func (h *HiClient) handleCmdLeave(ctx context.Context, roomID id.RoomID, args leaveArgs, _ *event.RelatesTo) string {
+	_, err := h.Client.LeaveRoom(ctx, roomID, &mautrix.ReqLeave{Reason: args.Reason})
 	if err != nil {
 		return fmt.Sprintf("Failed to leave room: %v", err)
 	}
@@ -322,20 +329,59 @@ func (h *HiClient) handleCmdRawInternal(ctx context.Context, roomID id.RoomID, a
 	}
 }
 
-func (h *HiClient) handleCmdAddAlias(ctx context.Context, roomID id.RoomID, args myRoomNickParams, _ *event.RelatesTo) string {
-	fullAlias := id.NewRoomAlias(args.Name, h.Account.UserID.Homeserver())
-	_, err := h.Client.CreateAlias(ctx, fullAlias, roomID)
+type addAliasParams struct {
+	Name      string `json:"name"`
+	Canonical bool   `json:"canonical"`
+	Alt       bool   `json:"alt"`
+}
+
+func (h *HiClient) parseAliasName(name string) id.RoomAlias {
+	if strings.HasPrefix(name, "#") && strings.ContainsRune(name, ':') {
+		return id.RoomAlias(name)
+	}
+	return id.NewRoomAlias(strings.TrimPrefix(name, "#"), h.Account.UserID.Homeserver())
+}
+
+func (h *HiClient) handleCmdAddAlias(ctx context.Context, roomID id.RoomID, args addAliasParams, _ *event.RelatesTo) string {
+	params := &jsoncmd.CreateAliasParams{
+		RoomID: roomID,
+		Alias:  h.parseAliasName(args.Name),
+	}
+	if args.Canonical {
+		params.Canonical = jsoncmd.CanonicalAliasMain
+	} else if args.Alt {
+		params.Canonical = jsoncmd.CanonicalAliasAlt
+	}
+	err := h.CreateAlias(ctx, params)
 	if err != nil {
 		return fmt.Sprintf("Failed to create alias: %v", err)
 	}
-	return fmt.Sprintf("Created alias %s", fullAlias)
+	return fmt.Sprintf("Created alias %s", params.Alias)
 }
 
-func (h *HiClient) handleCmdDelAlias(ctx context.Context, _ id.RoomID, args myRoomNickParams, _ *event.RelatesTo) string {
-	fullAlias := id.NewRoomAlias(args.Name, h.Account.UserID.Homeserver())
-	_, err := h.Client.DeleteAlias(ctx, fullAlias)
+func (h *HiClient) handleCmdDelAlias(ctx context.Context, roomID id.RoomID, args myRoomNickParams, _ *event.RelatesTo) string {
+	fullAlias := h.parseAliasName(args.Name)
+	err := h.DeleteAlias(ctx, &jsoncmd.DeleteAliasParams{
+		RoomID:          roomID,
+		Alias:           fullAlias,
+		UpdateCanonical: true,
+	})
 	if err != nil {
 		return fmt.Sprintf("Failed to delete alias: %v", err)
 	}
 	return fmt.Sprintf("Deleted alias %s", fullAlias)
 }
+
+func (h *HiClient) handleCmdListAliases(ctx context.Context, roomID id.RoomID) string {
+	aliases, err := h.GetLocalAliases(ctx, roomID)
+	if err != nil {
+		return fmt.Sprintf("Failed to get aliases: %v", err)
+	} else if len(aliases) == 0 {
+		return "This room has no aliases on your homeserver"
+	}
+	aliasStrings := make([]string, len(aliases))
+	for i, alias := range aliases {
+		aliasStrings[i] = alias.String()
+	}
+	return fmt.Sprintf("Aliases on your homeserver: %s", strings.Join(aliasStrings, ", "))
+}
@@ -27,6 +27,11 @@ type Database struct {
 	Media            *MediaQuery
 	SpaceEdge        *SpaceEdgeQuery
 	PushRegistration *PushRegistrationQuery
+	FrontendStore    *FrontendStoreQuery
+
+	PolicySubscription *PolicySubscriptionQuery
+	PolicyEnforcement  *PolicyEnforcementQuery
+	MegolmSessionShare *MegolmSessionShareQuery
 }
 
 func New(rawDB *dbutil.Database) *Database {
@@ -47,6 +52,11 @@ func New(rawDB *dbutil.Database) *Database {
 		Media:            &MediaQuery{QueryHelper: dbutil.MakeQueryHelper(rawDB, newMedia)},
 		SpaceEdge:        &SpaceEdgeQuery{QueryHelper: dbutil.MakeQueryHelper(rawDB, newSpaceEdge)},
 		PushRegistration: &PushRegistrationQuery{QueryHelper: dbutil.MakeQueryHelper(rawDB, newPushRegistration)},
+		FrontendStore:    &FrontendStoreQuery{QueryHelper: dbutil.MakeQueryHelper(rawDB, newFrontendStoreEntry)},
+
+		PolicySubscription: &PolicySubscriptionQuery{QueryHelper: dbutil.MakeQueryHelper(rawDB, newPolicySubscription)},
+		PolicyEnforcement:  &PolicyEnforcementQuery{QueryHelper: dbutil.MakeQueryHelper(rawDB, newPolicyEnforcement)},
+		MegolmSessionShare: &MegolmSessionShareQuery{QueryHelper: dbutil.MakeQueryHelper(rawDB, newMegolmSessionShare)},
 	}
 }
 
@@ -89,3 +99,19 @@ func newSpaceEdge(_ *dbutil.QueryHelper[*SpaceEdge]) *SpaceEdge {
 func newPushRegistration(_ *dbutil.QueryHelper[*PushRegistration]) *PushRegistration {
 	return &PushRegistration{}
 }
+
+func newFrontendStoreEntry(_ *dbutil.QueryHelper[*FrontendStoreEntry]) *FrontendStoreEntry {
+	return &FrontendStoreEntry{}
+}
+
+func newPolicySubscription(_ *dbutil.QueryHelper[*PolicySubscription]) *PolicySubscription {
+	return &PolicySubscription{}
+}
+
+func newPolicyEnforcement(_ *dbutil.QueryHelper[*PolicyEnforcement]) *PolicyEnforcement {
+	return &PolicyEnforcement{}
+}
+
+func newMegolmSessionShare(_ *dbutil.QueryHelper[*MegolmSessionShare]) *MegolmSessionShare {
+	return &MegolmSessionShare{}
+}
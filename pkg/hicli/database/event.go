@@ -31,7 +31,7 @@ const (
 		SELECT rowid, -1,
 		       room_id, event_id, sender, type, state_key, timestamp, content, decrypted, decrypted_type,
 		       unsigned, local_content, transaction_id, redacted_by, relates_to, relation_type,
-		       megolm_session_id, decryption_error, send_error, reactions, last_edit_rowid, unread_type
+		       megolm_session_id, decryption_error, send_error, reactions, reaction_senders, last_edit_rowid, unread_type
 		FROM event
 	`
 	getEventByRowID                  = getEventBaseQuery + `WHERE rowid = $1`
@@ -53,13 +53,22 @@ const (
 		ORDER BY timestamp DESC
 		LIMIT $3
 	`
+	getReactionsToMeQuery = getEventBaseQuery + `
+		WHERE timestamp <= $1 AND unread_type > 0 AND (unread_type & 16) != 0 AND redacted_by IS NULL
+		ORDER BY timestamp DESC
+		LIMIT $2
+	`
+	markReactionsToMeQuery = `
+		UPDATE event SET unread_type = unread_type | 16
+		WHERE room_id = $1 AND relates_to = $2 AND relation_type = 'm.annotation' AND sender <> $3
+	`
 	insertEventBaseQuery = `
 		INSERT INTO event (
 			room_id, event_id, sender, type, state_key, timestamp, content, decrypted, decrypted_type,
 			unsigned, local_content, transaction_id, redacted_by, relates_to, relation_type,
-			megolm_session_id, decryption_error, send_error, reactions, last_edit_rowid, unread_type
+			megolm_session_id, decryption_error, send_error, reactions, reaction_senders, last_edit_rowid, unread_type
 		)
-		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $14, $15, $16, $17, $18, $19, $20, $21)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $14, $15, $16, $17, $18, $19, $20, $21, $22)
 	`
 	insertEventQuery = insertEventBaseQuery + `RETURNING rowid`
 	upsertEventQuery = insertEventBaseQuery + `
@@ -79,6 +88,7 @@ const (
 		RETURNING rowid
 	`
 	updateEventSendErrorQuery        = `UPDATE event SET send_error = $2 WHERE rowid = $1`
+	markEventDiscardedQuery          = `UPDATE event SET send_error = $2 WHERE rowid = $1 AND event_id LIKE '~%'`
 	updateEventIDQuery               = `UPDATE event SET event_id = $2, send_error = NULL WHERE rowid=$1`
 	updateEventDecryptedQuery        = `UPDATE event SET decrypted = $2, decrypted_type = $3, decryption_error = NULL, unread_type = $4, local_content = $5 WHERE rowid = $1`
 	updateEventLocalContentQuery     = `UPDATE event SET local_content = $2 WHERE rowid = $1`
@@ -106,7 +116,25 @@ const (
 	setLastEditRowIDQuery = `
 		UPDATE event SET last_edit_rowid = $2 WHERE event_id = $1
 	`
-	updateReactionCountsQuery = `UPDATE event SET reactions = $2 WHERE event_id = $1`
+	getReactionCountsQuery = `
+		SELECT relates_to, content ->> '$."m.relates_to".key' AS reaction_key, COUNT(*), json_group_object(sender, event_id)
+		FROM event
+		WHERE room_id = ?
+		  AND type = 'm.reaction'
+		  AND relation_type = 'm.annotation'
+		  AND redacted_by IS NULL
+		  AND typeof(content ->> '$."m.relates_to".key') = 'text'
+		  AND relates_to IN (%s)
+		GROUP BY relates_to, reaction_key
+	`
+	// The second parameter is a JSON object from event ID to ReactionSummary
+	updateReactionCountsQuery = `
+		UPDATE event
+		SET reactions = summary.value -> '$.counts',
+		    reaction_senders = summary.value -> '$.senders'
+		FROM json_each($2) AS summary
+		WHERE event.room_id = $1 AND event.event_id = summary.key
+	`
 )
 
 type EventQuery struct {
@@ -140,6 +168,16 @@ func (eq *EventQuery) GetMentions(ctx context.Context, ts time.Time, unreadType
 	return eq.QueryMany(ctx, getMentionEventsQuery, ts.UnixMilli(), unreadType, limit)
 }
 
+func (eq *EventQuery) GetReactionsToMe(ctx context.Context, ts time.Time, limit int) ([]*Event, error) {
+	return eq.QueryMany(ctx, getReactionsToMeQuery, ts.UnixMilli(), limit)
+}
+
+// MarkReactionsToMe flags existing reactions to the given event as reactions to the current user.
+// This is used when the reaction target is stored after the reactions themselves.
+func (eq *EventQuery) MarkReactionsToMe(ctx context.Context, roomID id.RoomID, eventID id.EventID, ownUserID id.UserID) error {
+	return eq.Exec(ctx, markReactionsToMeQuery, roomID, eventID, ownUserID)
+}
+
 func (eq *EventQuery) GetByRowIDs(ctx context.Context, rowIDs ...EventRowID) ([]*Event, error) {
 	query, params := buildMultiEventGetFunction(nil, rowIDs, getManyEventsByRowID)
 	return eq.QueryMany(ctx, query, params...)
@@ -162,8 +200,8 @@ func (eq *EventQuery) Insert(ctx context.Context, evt *Event) (rowID EventRowID,
 }
 
 var stateEventMassInserter = dbutil.NewMassInsertBuilder[*Event, [1]any](
-	strings.ReplaceAll(upsertEventQuery, "($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $14, $15, $16, $17, $18, $19, $20, $21)", "($1, $2, $3, $4, $5, $6, $7, $8, $9, $10)"),
-	"($1, $%d, $%d, $%d, $%d, $%d, $%d, NULL, NULL, $%d, NULL, $%d, $%d, NULL, NULL, NULL, NULL, NULL, '{}', 0, 0)",
+	strings.ReplaceAll(upsertEventQuery, "($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $14, $15, $16, $17, $18, $19, $20, $21, $22)", "($1, $2, $3, $4, $5, $6, $7, $8, $9, $10)"),
+	"($1, $%d, $%d, $%d, $%d, $%d, $%d, NULL, NULL, $%d, NULL, $%d, $%d, NULL, NULL, NULL, NULL, NULL, '{}', '{}', 0, 0)",
 )
 
 var massInsertConverter = dbutil.ConvertRowFn[EventRowID](dbutil.ScanSingleColumn[EventRowID])
@@ -202,6 +240,11 @@ func (eq *EventQuery) UpdateSendError(ctx context.Context, rowID EventRowID, sen
 	return eq.Exec(ctx, updateEventSendErrorQuery, rowID, sendError)
 }
 
+// MarkDiscarded sets the send error of an unsent outgoing event to SendErrorDiscarded.
+func (eq *EventQuery) MarkDiscarded(ctx context.Context, rowID EventRowID) error {
+	return eq.Exec(ctx, markEventDiscardedQuery, rowID, SendErrorDiscarded)
+}
+
 func (eq *EventQuery) UpdateDecrypted(ctx context.Context, evt *Event) error {
 	return eq.Exec(
 		ctx,
@@ -226,7 +269,8 @@ func (eq *EventQuery) FillReactionCounts(ctx context.Context, roomID id.RoomID,
 	eventIDs := make([]id.EventID, 0, len(events))
 	eventMap := make(map[id.EventID]*Event)
 	for _, evt := range events {
-		if evt.Reactions == nil {
+		// Rows from before reaction senders were stored have counts, but no senders
+		if evt.Reactions == nil || evt.ReactionSenders == nil {
 			eventIDs = append(eventIDs, evt.ID)
 			eventMap[evt.ID] = evt
 		}
@@ -234,14 +278,17 @@ func (eq *EventQuery) FillReactionCounts(ctx context.Context, roomID id.RoomID,
 	if len(eventIDs) == 0 {
 		return nil
 	}
-	result, err := eq.GetReactions(ctx, roomID, eventIDs...)
-	if err != nil {
-		return err
-	}
-	for evtID, res := range result {
-		eventMap[evtID].Reactions = res.Counts
-	}
-	return nil
+	return eq.GetDB().DoTxn(ctx, nil, func(ctx context.Context) error {
+		summaries, err := eq.GetReactionCounts(ctx, roomID, eventIDs...)
+		if err != nil {
+			return err
+		}
+		for evtID, summary := range summaries {
+			eventMap[evtID].Reactions = summary.Counts
+			eventMap[evtID].ReactionSenders = summary.Senders
+		}
+		return eq.updateReactionCounts(ctx, roomID, summaries)
+	})
 }
 
 func (eq *EventQuery) FillLastEditRowIDs(ctx context.Context, roomID id.RoomID, events []*Event) error {
@@ -282,8 +329,22 @@ func (eq *EventQuery) FillLastEditRowIDs(ctx context.Context, roomID id.RoomID,
 var reactionKeyPath = exgjson.Path("m.relates_to", "key")
 
 type GetReactionsResult struct {
-	Events []*Event
-	Counts map[string]int
+	Events  []*Event
+	Counts  map[string]int
+	Senders map[string]map[id.UserID]id.EventID
+}
+
+// ReactionSummary contains the aggregated reactions to a single event.
+type ReactionSummary struct {
+	Counts  map[string]int                      `json:"counts"`
+	Senders map[string]map[id.UserID]id.EventID `json:"senders"`
+}
+
+func newReactionSummary() *ReactionSummary {
+	return &ReactionSummary{
+		Counts:  make(map[string]int),
+		Senders: make(map[string]map[id.UserID]id.EventID),
+	}
 }
 
 func buildMultiEventGetFunction[T any](preParams []any, eventIDs []T, query string) (string, []any) {
@@ -318,7 +379,10 @@ func (eq *EventQuery) GetEditRowIDs(ctx context.Context, roomID id.RoomID, event
 func (eq *EventQuery) GetReactions(ctx context.Context, roomID id.RoomID, eventIDs ...id.EventID) (map[id.EventID]*GetReactionsResult, error) {
 	result := make(map[id.EventID]*GetReactionsResult, len(eventIDs))
 	for _, evtID := range eventIDs {
-		result[evtID] = &GetReactionsResult{Counts: make(map[string]int)}
+		result[evtID] = &GetReactionsResult{
+			Counts:  make(map[string]int),
+			Senders: make(map[string]map[id.UserID]id.EventID),
+		}
 	}
 	return result, eq.GetDB().DoTxn(ctx, nil, func(ctx context.Context) error {
 		query, params := buildMultiEventGetFunction([]any{roomID}, eventIDs, getEventReactionsQuery)
@@ -334,20 +398,58 @@ func (eq *EventQuery) GetReactions(ctx context.Context, roomID id.RoomID, eventI
 			keyRes := gjson.GetBytes(evt.Content, reactionKeyPath)
 			if keyRes.Type == gjson.String {
 				dest.Counts[keyRes.Str]++
+				if dest.Senders[keyRes.Str] == nil {
+					dest.Senders[keyRes.Str] = make(map[id.UserID]id.EventID)
+				}
+				dest.Senders[keyRes.Str][evt.Sender] = evt.ID
 			}
 		}
+		summaries := make(map[id.EventID]*ReactionSummary, len(result))
 		for evtID, res := range result {
-			if len(res.Counts) > 0 {
-				err = eq.Exec(ctx, updateReactionCountsQuery, evtID, dbutil.JSON{Data: &res.Counts})
-				if err != nil {
-					return err
-				}
-			}
+			summaries[evtID] = &ReactionSummary{Counts: res.Counts, Senders: res.Senders}
 		}
-		return nil
+		return eq.updateReactionCounts(ctx, roomID, summaries)
 	})
 }
 
+type reactionCountTuple struct {
+	eventID id.EventID
+	key     string
+	count   int
+	senders map[id.UserID]id.EventID
+}
+
+// GetReactionCounts returns the number of reactions and their senders per key for each of the given events.
+// The counting is done in the database, so unlike GetReactions, the reaction events aren't loaded.
+// Every requested event ID is present in the output, even if it has no reactions.
+func (eq *EventQuery) GetReactionCounts(ctx context.Context, roomID id.RoomID, eventIDs ...id.EventID) (map[id.EventID]*ReactionSummary, error) {
+	output := make(map[id.EventID]*ReactionSummary, len(eventIDs))
+	for _, evtID := range eventIDs {
+		output[evtID] = newReactionSummary()
+	}
+	query, params := buildMultiEventGetFunction([]any{roomID}, eventIDs, getReactionCountsQuery)
+	rows, err := eq.GetDB().Query(ctx, query, params...)
+	return output, dbutil.NewRowIterWithError(rows, func(row dbutil.Scannable) (tuple reactionCountTuple, err error) {
+		err = row.Scan(&tuple.eventID, &tuple.key, &tuple.count, dbutil.JSON{Data: &tuple.senders})
+		return
+	}, err).Iter(func(tuple reactionCountTuple) (bool, error) {
+		if dest, ok := output[tuple.eventID]; ok {
+			dest.Counts[tuple.key] = tuple.count
+			dest.Senders[tuple.key] = tuple.senders
+		}
+		return true, nil
+	})
+}
+
+// updateReactionCounts stores the given reaction counts and senders for all events in a single statement.
+// Events with no reactions are stored with empty objects, so they don't have to be counted again.
+func (eq *EventQuery) updateReactionCounts(ctx context.Context, roomID id.RoomID, counts map[id.EventID]*ReactionSummary) error {
+	if len(counts) == 0 {
+		return nil
+	}
+	return eq.Exec(ctx, updateReactionCountsQuery, roomID, dbutil.JSON{Data: counts})
+}
+
 // An EventRowID uniquely identifies a single Matrix room event in the gomuks database.
 //
 // Event row IDs are always positive integers. They are not ordered in any useful way, the counter
@@ -383,12 +485,19 @@ type LocalContent struct {
 	ReplyFallbackRemoved bool `json:"reply_fallback_removed,omitempty"`
 	// The push rule ID that caused this event to notify or highlight.
 	PushRuleID string `json:"push_rule_id,omitempty"`
+	// Whether the event was imported from a room export rather than received from the homeserver.
+	// Imported events must not be used for read receipts or resending.
+	Imported bool `json:"imported,omitempty"`
 }
 
 func (c *LocalContent) GetReplyFallbackRemoved() bool {
 	return c != nil && c.ReplyFallbackRemoved
 }
 
+func (c *LocalContent) GetImported() bool {
+	return c != nil && c.Imported
+}
+
 func (c *LocalContent) GetPushRuleID() string {
 	if c == nil {
 		return ""
@@ -426,9 +535,15 @@ type Event struct {
 	DecryptionError string       `json:"decryption_error,omitempty"`
 	SendError       string       `json:"send_error,omitempty"`
 
-	Reactions     map[string]int `json:"reactions,omitempty"`
-	LastEditRowID *EventRowID    `json:"last_edit_rowid,omitempty"`
-	UnreadType    UnreadType     `json:"unread_type,omitempty"`
+	Reactions map[string]int `json:"reactions,omitempty"`
+	// ReactionSenders contains the user IDs who reacted with each key, mapped to the reaction event IDs.
+	ReactionSenders map[string]map[id.UserID]id.EventID `json:"reaction_senders,omitempty"`
+	LastEditRowID   *EventRowID                         `json:"last_edit_rowid,omitempty"`
+	UnreadType      UnreadType                          `json:"unread_type,omitempty"`
+
+	// QueuePosition is the number of outgoing events ahead of this one in the room's send queue.
+	// It's not stored in the database and is only set on pending events.
+	QueuePosition int `json:"queue_position,omitempty"`
 
 	parsedContent *event.Content
 	LastEditRef   *Event `json:"-"`
@@ -448,6 +563,7 @@ func MautrixToEvent(evt *event.Event) *Event {
 		MegolmSessionID: getMegolmSessionID(evt),
 		TransactionID:   evt.Unsigned.TransactionID,
 		Reactions:       make(map[string]int),
+		ReactionSenders: make(map[string]map[id.UserID]id.EventID),
 	}
 	if !strings.HasPrefix(dbEvt.TransactionID, "hicli-mautrix-go_") {
 		dbEvt.TransactionID = ""
@@ -461,6 +577,18 @@ func MautrixToEvent(evt *event.Event) *Event {
 	return dbEvt
 }
 
+const (
+	// SendErrorNotSent is the send error of outgoing events that are still being sent.
+	SendErrorNotSent = "not sent"
+	// SendErrorDiscarded is the send error of failed outgoing events that the user chose not to resend.
+	SendErrorDiscarded = "discarded"
+)
+
+// SendFailed returns true if the event is an outgoing event that failed to send and hasn't been discarded.
+func (e *Event) SendFailed() bool {
+	return e.SendError != "" && e.SendError != SendErrorNotSent && e.SendError != SendErrorDiscarded
+}
+
 func (e *Event) GetType() (evtType event.Type) {
 	evtType.Class = event.MessageEventType
 	if e.StateKey != nil {
@@ -566,6 +694,7 @@ func (e *Event) Scan(row dbutil.Scannable) (*Event, error) {
 		&decryptionError,
 		&sendError,
 		dbutil.JSON{Data: &e.Reactions},
+		dbutil.JSON{Data: &e.ReactionSenders},
 		&e.LastEditRowID,
 		&e.UnreadType,
 	)
@@ -627,10 +756,13 @@ func (e *Event) GetReplyTo() id.EventID {
 }
 
 func (e *Event) sqlVariables() []any {
-	var reactions any
+	var reactions, reactionSenders any
 	if e.Reactions != nil {
 		reactions = e.Reactions
 	}
+	if e.ReactionSenders != nil {
+		reactionSenders = e.ReactionSenders
+	}
 	return []any{
 		e.RoomID,
 		e.ID,
@@ -651,6 +783,7 @@ func (e *Event) sqlVariables() []any {
 		dbutil.StrPtr(e.DecryptionError),
 		dbutil.StrPtr(e.SendError),
 		dbutil.JSON{Data: reactions},
+		dbutil.JSON{Data: reactionSenders},
 		e.LastEditRowID,
 		e.UnreadType,
 	}
@@ -691,6 +824,13 @@ func (e *Event) MarkReplyFallbackRemoved() {
 	e.LocalContent.ReplyFallbackRemoved = true
 }
 
+func (e *Event) MarkImported() {
+	if e.LocalContent == nil {
+		e.LocalContent = &LocalContent{}
+	}
+	e.LocalContent.Imported = true
+}
+
 func MakeFakeEvent(roomID id.RoomID, html string) *Event {
 	return &Event{
 		RowID:         -EventRowID(time.Now().UnixMilli()),
@@ -0,0 +1,222 @@
+// Copyright (c) 2026 Tulir Asokan
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package database
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"maps"
+	"testing"
+	"time"
+
+	"go.mau.fi/util/jsontime"
+	"maunium.net/go/mautrix/event"
+	"maunium.net/go/mautrix/id"
+)
+
+const testRoomID id.RoomID = "!room:example.com"
+
+func putTestEvent(t testing.TB, db *Database, evt *Event) *Event {
+	t.Helper()
+	ctx := context.Background()
+	if err := db.Room.CreateRow(ctx, evt.RoomID); err != nil {
+		t.Fatalf("Failed to create room row: %v", err)
+	}
+	if evt.Timestamp.IsZero() {
+		evt.Timestamp = jsontime.UM(time.Now())
+	}
+	if evt.Unsigned == nil {
+		evt.Unsigned = json.RawMessage("{}")
+	}
+	if _, err := db.Event.Insert(ctx, evt); err != nil {
+		t.Fatalf("Failed to insert event %s: %v", evt.ID, err)
+	}
+	return evt
+}
+
+func putTestMessage(t testing.TB, db *Database, evtID id.EventID) *Event {
+	t.Helper()
+	return putTestEvent(t, db, &Event{
+		RoomID:  testRoomID,
+		ID:      evtID,
+		Sender:  "@alice:example.com",
+		Type:    event.EventMessage.Type,
+		Content: json.RawMessage(`{"msgtype":"m.text","body":"hello"}`),
+	})
+}
+
+func putTestReaction(t testing.TB, db *Database, evtID, target id.EventID, sender id.UserID, key any, redactedBy id.EventID) *Event {
+	t.Helper()
+	content, _ := json.Marshal(map[string]any{
+		"m.relates_to": map[string]any{"rel_type": "m.annotation", "event_id": target, "key": key},
+	})
+	return putTestEvent(t, db, &Event{
+		RoomID:       testRoomID,
+		ID:           evtID,
+		Sender:       sender,
+		Type:         event.EventReaction.Type,
+		Content:      content,
+		RelatesTo:    target,
+		RelationType: event.RelAnnotation,
+		RedactedBy:   redactedBy,
+	})
+}
+
+func TestGetReactionCounts(t *testing.T) {
+	ctx := context.Background()
+	db := newTestDB(t)
+	putTestMessage(t, db, "$reacted")
+	putTestMessage(t, db, "$unreacted")
+	putTestMessage(t, db, "$onlyredacted")
+	putTestReaction(t, db, "$r1", "$reacted", "@alice:example.com", "👍", "")
+	putTestReaction(t, db, "$r2", "$reacted", "@bob:example.com", "👍", "")
+	putTestReaction(t, db, "$r3", "$reacted", "@alice:example.com", "❤️", "")
+	putTestReaction(t, db, "$r4", "$reacted", "@carol:example.com", "👍", "$redaction1")
+	putTestReaction(t, db, "$r5", "$reacted", "@carol:example.com", 123, "")
+	putTestReaction(t, db, "$r6", "$onlyredacted", "@bob:example.com", "🎉", "$redaction2")
+	// Reactions in other rooms must not be counted
+	putTestEvent(t, db, &Event{
+		RoomID:       "!other:example.com",
+		ID:           "$r7",
+		Sender:       "@bob:example.com",
+		Type:         event.EventReaction.Type,
+		Content:      json.RawMessage(`{"m.relates_to":{"rel_type":"m.annotation","event_id":"$reacted","key":"👍"}}`),
+		RelatesTo:    "$reacted",
+		RelationType: event.RelAnnotation,
+	})
+
+	summaries, err := db.Event.GetReactionCounts(ctx, testRoomID, "$reacted", "$unreacted", "$onlyredacted")
+	if err != nil {
+		t.Fatalf("GetReactionCounts failed: %v", err)
+	}
+	wantCounts := map[id.EventID]map[string]int{
+		"$reacted":      {"👍": 2, "❤️": 1},
+		"$unreacted":    {},
+		"$onlyredacted": {},
+	}
+	wantSenders := map[id.EventID]map[string]map[id.UserID]id.EventID{
+		"$reacted": {
+			"👍":  {"@alice:example.com": "$r1", "@bob:example.com": "$r2"},
+			"❤️": {"@alice:example.com": "$r3"},
+		},
+		"$unreacted":    {},
+		"$onlyredacted": {},
+	}
+	if len(summaries) != len(wantCounts) {
+		t.Fatalf("Expected %d summaries, got %d", len(wantCounts), len(summaries))
+	}
+	for evtID, want := range wantCounts {
+		summary := summaries[evtID]
+		if summary == nil {
+			t.Errorf("No summary for %s", evtID)
+			continue
+		} else if !maps.Equal(summary.Counts, want) {
+			t.Errorf("Counts for %s = %v, want %v", evtID, summary.Counts, want)
+		}
+		if !maps.EqualFunc(summary.Senders, wantSenders[evtID], maps.Equal) {
+			t.Errorf("Senders for %s = %v, want %v", evtID, summary.Senders, wantSenders[evtID])
+		}
+	}
+
+	// The counting query must match the results of loading the reaction events
+	loaded, err := db.Event.GetReactions(ctx, testRoomID, "$reacted", "$unreacted", "$onlyredacted")
+	if err != nil {
+		t.Fatalf("GetReactions failed: %v", err)
+	}
+	for evtID, res := range loaded {
+		if !maps.Equal(res.Counts, summaries[evtID].Counts) {
+			t.Errorf("GetReactions counts for %s = %v, GetReactionCounts = %v", evtID, res.Counts, summaries[evtID].Counts)
+		}
+	}
+}
+
+func TestFillReactionCounts_StoresEmptyObject(t *testing.T) {
+	ctx := context.Background()
+	db := newTestDB(t)
+	putTestMessage(t, db, "$reacted")
+	putTestMessage(t, db, "$unreacted")
+	putTestReaction(t, db, "$r1", "$reacted", "@alice:example.com", "👍", "")
+	putTestReaction(t, db, "$r2", "$unreacted", "@bob:example.com", "👎", "$redaction")
+
+	var events []*Event
+	for _, evtID := range []id.EventID{"$reacted", "$unreacted"} {
+		evt, err := db.Event.GetByID(ctx, evtID)
+		if err != nil {
+			t.Fatalf("Failed to get %s: %v", evtID, err)
+		} else if evt.Reactions != nil {
+			t.Fatalf("Expected %s to not have cached reactions yet, got %v", evtID, evt.Reactions)
+		}
+		events = append(events, evt)
+	}
+	if err := db.Event.FillReactionCounts(ctx, testRoomID, events); err != nil {
+		t.Fatalf("FillReactionCounts failed: %v", err)
+	}
+	if events[0].Reactions["👍"] != 1 {
+		t.Errorf("Unexpected reactions for $reacted: %v", events[0].Reactions)
+	}
+	if events[1].Reactions == nil || len(events[1].Reactions) != 0 {
+		t.Errorf("Expected empty reactions for $unreacted, got %v", events[1].Reactions)
+	}
+
+	tests := []struct {
+		eventID         id.EventID
+		reactions       string
+		reactionSenders string
+	}{
+		{"$reacted", `{"👍":1}`, `{"👍":{"@alice:example.com":"$r1"}}`},
+		{"$unreacted", `{}`, `{}`},
+	}
+	for _, test := range tests {
+		var reactions, reactionSenders string
+		err := db.QueryRow(ctx, "SELECT reactions, reaction_senders FROM event WHERE event_id=$1", test.eventID).
+			Scan(&reactions, &reactionSenders)
+		if err != nil {
+			t.Fatalf("Failed to get stored reactions of %s: %v", test.eventID, err)
+		}
+		if reactions != test.reactions || reactionSenders != test.reactionSenders {
+			t.Errorf("Stored reactions of %s = %s / %s, want %s / %s",
+				test.eventID, reactions, reactionSenders, test.reactions, test.reactionSenders)
+		}
+	}
+	// Events with a stored empty object must not be counted again
+	reloaded, err := db.Event.GetByID(ctx, "$unreacted")
+	if err != nil {
+		t.Fatalf("Failed to reload event: %v", err)
+	} else if reloaded.Reactions == nil || reloaded.ReactionSenders == nil {
+		t.Errorf("Stored empty reactions weren't loaded as empty maps: %v / %v", reloaded.Reactions, reloaded.ReactionSenders)
+	}
+}
+
+func BenchmarkGetReactionCounts(b *testing.B) {
+	ctx := context.Background()
+	db := newTestDB(b)
+	const targets = 1000
+	const reactionsPerTarget = 10
+	keys := []string{"👍", "❤️", "😂", "🎉"}
+	eventIDs := make([]id.EventID, 0, 50)
+	for i := range targets {
+		target := id.EventID(fmt.Sprintf("$target%d", i))
+		putTestMessage(b, db, target)
+		if i%(targets/cap(eventIDs)) == 0 {
+			eventIDs = append(eventIDs, target)
+		}
+		for j := range reactionsPerTarget {
+			putTestReaction(
+				b, db, id.EventID(fmt.Sprintf("$reaction%d_%d", i, j)), target,
+				id.UserID(fmt.Sprintf("@user%d:example.com", j)), keys[j%len(keys)], "",
+			)
+		}
+	}
+	b.ResetTimer()
+	for range b.N {
+		_, err := db.Event.GetReactionCounts(ctx, testRoomID, eventIDs...)
+		if err != nil {
+			b.Fatalf("GetReactionCounts failed: %v", err)
+		}
+	}
+}
@@ -0,0 +1,94 @@
+// Copyright (c) 2025 Tulir Asokan
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package database
+
+import (
+	"context"
+	"encoding/json"
+	"time"
+
+	"go.mau.fi/util/dbutil"
+	"go.mau.fi/util/jsontime"
+)
+
+const (
+	getFrontendStoreEntryQuery = `
+		SELECT namespace, key, value, updated_at FROM frontend_store WHERE namespace = $1 AND key = $2
+	`
+	getFrontendStoreNamespaceQuery = `
+		SELECT namespace, key, value, updated_at FROM frontend_store WHERE namespace = $1 ORDER BY key
+	`
+	putFrontendStoreEntryQuery = `
+		INSERT INTO frontend_store (namespace, key, value, updated_at)
+		VALUES ($1, $2, $3, $4)
+		ON CONFLICT (namespace, key) DO UPDATE SET
+			value = excluded.value,
+			updated_at = excluded.updated_at
+	`
+	deleteFrontendStoreEntryQuery = `
+		DELETE FROM frontend_store WHERE namespace = $1 AND key = $2
+	`
+	getFrontendStoreNamespaceSizeQuery = `
+		SELECT COALESCE(SUM(length(CAST(key AS BLOB)) + length(CAST(value AS BLOB))), 0)
+		FROM frontend_store
+		WHERE namespace = $1 AND key <> $2
+	`
+)
+
+type FrontendStoreQuery struct {
+	*dbutil.QueryHelper[*FrontendStoreEntry]
+}
+
+func (fsq *FrontendStoreQuery) Get(ctx context.Context, namespace, key string) (*FrontendStoreEntry, error) {
+	return fsq.QueryOne(ctx, getFrontendStoreEntryQuery, namespace, key)
+}
+
+func (fsq *FrontendStoreQuery) GetNamespace(ctx context.Context, namespace string) ([]*FrontendStoreEntry, error) {
+	return fsq.QueryMany(ctx, getFrontendStoreNamespaceQuery, namespace)
+}
+
+func (fsq *FrontendStoreQuery) Put(ctx context.Context, entry *FrontendStoreEntry) error {
+	return fsq.Exec(ctx, putFrontendStoreEntryQuery, entry.sqlVariables()...)
+}
+
+func (fsq *FrontendStoreQuery) Delete(ctx context.Context, namespace, key string) error {
+	return fsq.Exec(ctx, deleteFrontendStoreEntryQuery, namespace, key)
+}
+
+// GetNamespaceSize returns the total size in bytes of the keys and values in the given namespace,
+// not counting the entry with the given key (so that the entry's new size can be added instead).
+func (fsq *FrontendStoreQuery) GetNamespaceSize(ctx context.Context, namespace, exceptKey string) (size int, err error) {
+	err = fsq.GetDB().QueryRow(ctx, getFrontendStoreNamespaceSizeQuery, namespace, exceptKey).Scan(&size)
+	return
+}
+
+// FrontendStoreEntry is a single value in the key-value store that frontends can use for their own state.
+type FrontendStoreEntry struct {
+	Namespace string             `json:"namespace"`
+	Key       string             `json:"key"`
+	Value     json.RawMessage    `json:"value"`
+	UpdatedAt jsontime.UnixMilli `json:"updated_at"`
+}
+
+// Size returns the number of bytes the entry counts towards the namespace quota.
+func (fse *FrontendStoreEntry) Size() int {
+	return len(fse.Key) + len(fse.Value)
+}
+
+func (fse *FrontendStoreEntry) Scan(row dbutil.Scannable) (*FrontendStoreEntry, error) {
+	var updatedAt int64
+	err := row.Scan(&fse.Namespace, &fse.Key, (*[]byte)(&fse.Value), &updatedAt)
+	if err != nil {
+		return nil, err
+	}
+	fse.UpdatedAt = jsontime.UM(time.UnixMilli(updatedAt))
+	return fse, nil
+}
+
+func (fse *FrontendStoreEntry) sqlVariables() []any {
+	return []any{fse.Namespace, fse.Key, unsafeJSONString(fse.Value), fse.UpdatedAt.UnixMilli()}
+}
@@ -0,0 +1,67 @@
+// Copyright (c) 2026 Tulir Asokan
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package database
+
+import (
+	"context"
+	"time"
+
+	"go.mau.fi/util/dbutil"
+	"go.mau.fi/util/jsontime"
+	"maunium.net/go/mautrix/id"
+)
+
+const (
+	getMegolmSessionShareQuery = `
+		SELECT room_id, session_id, user_count, device_count, shared_at FROM megolm_session_share
+		WHERE room_id = $1 AND session_id = $2
+	`
+	putMegolmSessionShareQuery = `
+		INSERT INTO megolm_session_share (room_id, session_id, user_count, device_count, shared_at)
+		VALUES ($1, $2, $3, $4, $5)
+		ON CONFLICT (room_id, session_id) DO UPDATE SET
+			user_count = excluded.user_count,
+			device_count = excluded.device_count,
+			shared_at = excluded.shared_at
+	`
+)
+
+type MegolmSessionShareQuery struct {
+	*dbutil.QueryHelper[*MegolmSessionShare]
+}
+
+func (msq *MegolmSessionShareQuery) Get(ctx context.Context, roomID id.RoomID, sessionID id.SessionID) (*MegolmSessionShare, error) {
+	return msq.QueryOne(ctx, getMegolmSessionShareQuery, roomID, sessionID)
+}
+
+func (msq *MegolmSessionShareQuery) Put(ctx context.Context, share *MegolmSessionShare) error {
+	return msq.Exec(ctx, putMegolmSessionShareQuery, share.sqlVariables()...)
+}
+
+// MegolmSessionShare records how many devices an outbound megolm session was shared with.
+// If the session is shared again (e.g. with new devices), the counts are updated.
+type MegolmSessionShare struct {
+	RoomID      id.RoomID          `json:"room_id"`
+	SessionID   id.SessionID       `json:"session_id"`
+	UserCount   int                `json:"user_count"`
+	DeviceCount int                `json:"device_count"`
+	SharedAt    jsontime.UnixMilli `json:"shared_at"`
+}
+
+func (mss *MegolmSessionShare) Scan(row dbutil.Scannable) (*MegolmSessionShare, error) {
+	var sharedAt int64
+	err := row.Scan(&mss.RoomID, &mss.SessionID, &mss.UserCount, &mss.DeviceCount, &sharedAt)
+	if err != nil {
+		return nil, err
+	}
+	mss.SharedAt = jsontime.UM(time.UnixMilli(sharedAt))
+	return mss, nil
+}
+
+func (mss *MegolmSessionShare) sqlVariables() []any {
+	return []any{mss.RoomID, mss.SessionID, mss.UserCount, mss.DeviceCount, mss.SharedAt.UnixMilli()}
+}
@@ -0,0 +1,159 @@
+// Copyright (c) 2025 Tulir Asokan
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package database
+
+import (
+	"context"
+	"time"
+
+	"go.mau.fi/util/dbutil"
+	"go.mau.fi/util/jsontime"
+	"maunium.net/go/mautrix/id"
+)
+
+const (
+	getPolicySubscriptionsQuery = `
+		SELECT policy_room_id, target_room_id, action FROM policy_subscription ORDER BY policy_room_id, target_room_id
+	`
+	putPolicySubscriptionQuery = `
+		INSERT INTO policy_subscription (policy_room_id, target_room_id, action)
+		VALUES ($1, $2, $3)
+		ON CONFLICT (policy_room_id, target_room_id) DO UPDATE SET action = excluded.action
+	`
+	deletePolicySubscriptionQuery = `
+		DELETE FROM policy_subscription WHERE policy_room_id = $1 AND target_room_id = $2
+	`
+	getPolicyEnforcementBaseQuery = `
+		SELECT room_id, user_id, action, policy_room_id, entity, reason, timestamp FROM policy_enforcement
+	`
+	getAllPolicyEnforcementsQuery    = getPolicyEnforcementBaseQuery + `ORDER BY room_id, user_id`
+	getPolicyEnforcementQuery        = getPolicyEnforcementBaseQuery + `WHERE room_id = $1 AND user_id = $2`
+	getPolicyEnforcementsByRoomQuery = getPolicyEnforcementBaseQuery + `WHERE policy_room_id = $1`
+	putPolicyEnforcementQuery        = `
+		INSERT INTO policy_enforcement (room_id, user_id, action, policy_room_id, entity, reason, timestamp)
+		VALUES ($1, $2, $3, $4, $5, $6, $7)
+		ON CONFLICT (room_id, user_id) DO UPDATE SET
+			action = excluded.action,
+			policy_room_id = excluded.policy_room_id,
+			entity = excluded.entity,
+			reason = excluded.reason,
+			timestamp = excluded.timestamp
+	`
+	deletePolicyEnforcementQuery = `
+		DELETE FROM policy_enforcement WHERE room_id = $1 AND user_id = $2
+	`
+)
+
+type PolicyAction string
+
+const (
+	// PolicyActionBan bans matching users in rooms where the user has permission to do so.
+	// In other rooms, the matching users are only flagged.
+	PolicyActionBan PolicyAction = "ban"
+	// PolicyActionFlag only flags matching users, so that frontends can mark their messages.
+	PolicyActionFlag PolicyAction = "flag"
+
+	// PolicyActionUnban and PolicyActionUnflag are only used in enforcement events
+	// when a previous enforcement is undone after the rule was removed.
+	PolicyActionUnban  PolicyAction = "unban"
+	PolicyActionUnflag PolicyAction = "unflag"
+)
+
+func (pa PolicyAction) IsValid() bool {
+	return pa == PolicyActionBan || pa == PolicyActionFlag
+}
+
+type PolicySubscriptionQuery struct {
+	*dbutil.QueryHelper[*PolicySubscription]
+}
+
+func (psq *PolicySubscriptionQuery) GetAll(ctx context.Context) ([]*PolicySubscription, error) {
+	return psq.QueryMany(ctx, getPolicySubscriptionsQuery)
+}
+
+func (psq *PolicySubscriptionQuery) Put(ctx context.Context, sub *PolicySubscription) error {
+	return psq.Exec(ctx, putPolicySubscriptionQuery, sub.sqlVariables()...)
+}
+
+func (psq *PolicySubscriptionQuery) Delete(ctx context.Context, policyRoomID, targetRoomID id.RoomID) error {
+	return psq.Exec(ctx, deletePolicySubscriptionQuery, policyRoomID, targetRoomID)
+}
+
+// PolicySubscription means that the rules in a policy list room are enforced in a room or in all joined rooms.
+type PolicySubscription struct {
+	PolicyRoomID id.RoomID `json:"policy_room_id"`
+	// The room where the rules are enforced. If empty, the rules are enforced in all joined rooms.
+	TargetRoomID id.RoomID    `json:"target_room_id,omitempty"`
+	Action       PolicyAction `json:"action"`
+}
+
+// Applies returns true if the subscription should be enforced in the given room.
+func (ps *PolicySubscription) Applies(roomID id.RoomID) bool {
+	return ps.TargetRoomID == "" || ps.TargetRoomID == roomID
+}
+
+func (ps *PolicySubscription) Scan(row dbutil.Scannable) (*PolicySubscription, error) {
+	err := row.Scan(&ps.PolicyRoomID, &ps.TargetRoomID, &ps.Action)
+	if err != nil {
+		return nil, err
+	}
+	return ps, nil
+}
+
+func (ps *PolicySubscription) sqlVariables() []any {
+	return []any{ps.PolicyRoomID, ps.TargetRoomID, ps.Action}
+}
+
+type PolicyEnforcementQuery struct {
+	*dbutil.QueryHelper[*PolicyEnforcement]
+}
+
+func (peq *PolicyEnforcementQuery) GetAll(ctx context.Context) ([]*PolicyEnforcement, error) {
+	return peq.QueryMany(ctx, getAllPolicyEnforcementsQuery)
+}
+
+func (peq *PolicyEnforcementQuery) Get(ctx context.Context, roomID id.RoomID, userID id.UserID) (*PolicyEnforcement, error) {
+	return peq.QueryOne(ctx, getPolicyEnforcementQuery, roomID, userID)
+}
+
+func (peq *PolicyEnforcementQuery) GetByPolicyRoom(ctx context.Context, policyRoomID id.RoomID) ([]*PolicyEnforcement, error) {
+	return peq.QueryMany(ctx, getPolicyEnforcementsByRoomQuery, policyRoomID)
+}
+
+func (peq *PolicyEnforcementQuery) Put(ctx context.Context, enf *PolicyEnforcement) error {
+	return peq.Exec(ctx, putPolicyEnforcementQuery, enf.sqlVariables()...)
+}
+
+func (peq *PolicyEnforcementQuery) Delete(ctx context.Context, roomID id.RoomID, userID id.UserID) error {
+	return peq.Exec(ctx, deletePolicyEnforcementQuery, roomID, userID)
+}
+
+// PolicyEnforcement records that a policy rule was enforced against a user in a room,
+// so that it can be undone if the rule is removed.
+type PolicyEnforcement struct {
+	RoomID       id.RoomID          `json:"room_id"`
+	UserID       id.UserID          `json:"user_id"`
+	Action       PolicyAction       `json:"action"`
+	PolicyRoomID id.RoomID          `json:"policy_room_id"`
+	Entity       string             `json:"entity"`
+	Reason       string             `json:"reason"`
+	Timestamp    jsontime.UnixMilli `json:"timestamp"`
+}
+
+func (pe *PolicyEnforcement) Scan(row dbutil.Scannable) (*PolicyEnforcement, error) {
+	var timestamp int64
+	err := row.Scan(&pe.RoomID, &pe.UserID, &pe.Action, &pe.PolicyRoomID, &pe.Entity, &pe.Reason, &timestamp)
+	if err != nil {
+		return nil, err
+	}
+	pe.Timestamp = jsontime.UM(time.UnixMilli(timestamp))
+	return pe, nil
+}
+
+func (pe *PolicyEnforcement) sqlVariables() []any {
+	return []any{pe.RoomID, pe.UserID, pe.Action, pe.PolicyRoomID, pe.Entity, pe.Reason, pe.Timestamp.UnixMilli()}
+}
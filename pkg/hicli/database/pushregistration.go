@@ -9,8 +9,11 @@ package database
 import (
 	"context"
 	"encoding/json"
+	"errors"
+	"fmt"
 	"time"
 
+	"github.com/SherClockHolmes/webpush-go"
 	"go.mau.fi/util/dbutil"
 	"go.mau.fi/util/jsontime"
 )
@@ -30,6 +33,9 @@ const (
 			encryption = EXCLUDED.encryption,
 			expiration = EXCLUDED.expiration
 	`
+	deletePushRegistration = `
+		DELETE FROM push_registration WHERE device_id = $1
+	`
 )
 
 type PushRegistrationQuery struct {
@@ -40,6 +46,10 @@ func (prq *PushRegistrationQuery) Put(ctx context.Context, reg *PushRegistration
 	return prq.Exec(ctx, putPushRegistration, reg.sqlVariables()...)
 }
 
+func (prq *PushRegistrationQuery) Delete(ctx context.Context, deviceID string) error {
+	return prq.Exec(ctx, deletePushRegistration, deviceID)
+}
+
 func (seq *PushRegistrationQuery) GetAll(ctx context.Context) ([]*PushRegistration, error) {
 	return seq.QueryMany(ctx, getNonExpiredPushTargets, time.Now().Unix())
 }
@@ -75,6 +85,27 @@ type PushRegistration struct {
 	Expiration jsontime.Unix `json:"expiration"`
 }
 
+// Validate checks that the type-specific data of the registration is well-formed.
+func (pe *PushRegistration) Validate() error {
+	switch pe.Type {
+	case PushTypeFCM:
+		var token string
+		if err := json.Unmarshal(pe.Data, &token); err != nil || token == "" {
+			return errors.New("FCM push registration data must be a non-empty token string")
+		}
+	case PushTypeWeb:
+		var sub webpush.Subscription
+		if err := json.Unmarshal(pe.Data, &sub); err != nil {
+			return fmt.Errorf("invalid web push subscription: %w", err)
+		} else if sub.Endpoint == "" || sub.Keys.P256dh == "" || sub.Keys.Auth == "" {
+			return errors.New("web push subscription must have an endpoint and p256dh and auth keys")
+		}
+	default:
+		return fmt.Errorf("unknown push type %q", pe.Type)
+	}
+	return nil
+}
+
 func (pe *PushRegistration) Scan(row dbutil.Scannable) (*PushRegistration, error) {
 	err := row.Scan(&pe.DeviceID, &pe.Type, (*[]byte)(&pe.Data), dbutil.JSON{Data: &pe.Encryption}, &pe.Expiration)
 	if err != nil {
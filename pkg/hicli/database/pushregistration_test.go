@@ -0,0 +1,43 @@
+// Copyright (c) 2026 Tulir Asokan
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package database
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+func TestPushRegistration_Validate(t *testing.T) {
+	tests := []struct {
+		name    string
+		typ     PushType
+		data    string
+		wantErr bool
+	}{
+		{"fcm token", PushTypeFCM, `"token"`, false},
+		{"fcm empty token", PushTypeFCM, `""`, true},
+		{"fcm object", PushTypeFCM, `{"token":"meow"}`, true},
+		{"web subscription", PushTypeWeb, `{"endpoint":"https://push.example.com/1","keys":{"p256dh":"BExample","auth":"c2VjcmV0"}}`, false},
+		{"web subscription with extra fields", PushTypeWeb, `{"endpoint":"https://push.example.com/1","expirationTime":null,"keys":{"p256dh":"BExample","auth":"c2VjcmV0"}}`, false},
+		{"web missing endpoint", PushTypeWeb, `{"keys":{"p256dh":"BExample","auth":"c2VjcmV0"}}`, true},
+		{"web missing p256dh", PushTypeWeb, `{"endpoint":"https://push.example.com/1","keys":{"auth":"c2VjcmV0"}}`, true},
+		{"web missing auth", PushTypeWeb, `{"endpoint":"https://push.example.com/1","keys":{"p256dh":"BExample"}}`, true},
+		{"web string", PushTypeWeb, `"https://push.example.com/1"`, true},
+		{"unknown type", "apns", `"token"`, true},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			reg := &PushRegistration{DeviceID: "device", Type: test.typ, Data: json.RawMessage(test.data)}
+			err := reg.Validate()
+			if test.wantErr && err == nil {
+				t.Error("Expected an error")
+			} else if !test.wantErr && err != nil {
+				t.Errorf("Unexpected error: %v", err)
+			}
+		})
+	}
+}
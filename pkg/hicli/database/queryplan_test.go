@@ -0,0 +1,216 @@
+// Copyright (c) 2026 Tulir Asokan
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package database
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"go.mau.fi/util/dbutil"
+	_ "go.mau.fi/util/dbutil/litestream"
+	"maunium.net/go/mautrix/event"
+	"maunium.net/go/mautrix/id"
+)
+
+func newTestDB(t testing.TB) *Database {
+	t.Helper()
+	rawDB, err := dbutil.NewWithDialect("file::memory:", "sqlite3-fk-wal")
+	if err != nil {
+		t.Fatalf("Failed to open database: %v", err)
+	}
+	// Every connection to :memory: gets its own database, so there must only be one connection.
+	rawDB.RawDB.SetMaxOpenConns(1)
+	t.Cleanup(func() {
+		_ = rawDB.Close()
+	})
+	db := New(rawDB)
+	if err = db.Upgrade(context.Background()); err != nil {
+		t.Fatalf("Failed to upgrade database: %v", err)
+	}
+	return db
+}
+
+var createIndexRegex = regexp.MustCompile(`CREATE INDEX (\w+)`)
+
+// queryPlan returns the details of each step in the query plan, e.g. "SEARCH event USING INDEX ...".
+func queryPlan(t *testing.T, db *Database, query string, args ...any) []string {
+	t.Helper()
+	rows, err := db.Query(context.Background(), "EXPLAIN QUERY PLAN "+query, args...)
+	if err != nil {
+		t.Fatalf("Failed to explain query: %v", err)
+	}
+	defer rows.Close()
+	var steps []string
+	for rows.Next() {
+		var id, parent, unused int
+		var detail string
+		if err = rows.Scan(&id, &parent, &unused, &detail); err != nil {
+			t.Fatalf("Failed to scan query plan: %v", err)
+		}
+		steps = append(steps, detail)
+	}
+	if err = rows.Err(); err != nil {
+		t.Fatalf("Failed to read query plan: %v", err)
+	}
+	return steps
+}
+
+func TestQueryPlan_EventIndexes(t *testing.T) {
+	db := newTestDB(t)
+	// The indexes created by the migration must all be covered by the cases below
+	migration, err := os.ReadFile(filepath.Join("upgrades", "20-event-query-indexes.sql"))
+	if err != nil {
+		t.Fatalf("Failed to read migration: %v", err)
+	}
+	var migrationIndexes []string
+	for _, match := range createIndexRegex.FindAllStringSubmatch(string(migration), -1) {
+		migrationIndexes = append(migrationIndexes, match[1])
+	}
+	if len(migrationIndexes) == 0 {
+		t.Fatal("Migration doesn't create any indexes")
+	}
+
+	tests := []struct {
+		name  string
+		index string
+		query string
+		args  []any
+	}{
+		{"related events", "event_relates_to_idx", getRelatedEventsQuery,
+			[]any{"!room:example.com", "$event", "m.annotation"}},
+		{"reaction counts", "event_relates_to_idx", fmt.Sprintf(getReactionCountsQuery, "?, ?"),
+			[]any{"!room:example.com", "$event1", "$event2"}},
+		{"event reactions", "event_relates_to_idx", fmt.Sprintf(getEventReactionsQuery, "?"),
+			[]any{"!room:example.com", "$event"}},
+		{"mark reactions to me", "event_relates_to_idx", markReactionsToMeQuery,
+			[]any{"!room:example.com", "$event", "@alice:example.com"}},
+		{"mentions in room", "event_room_mention_idx", getMentionEventsInRoomQuery,
+			[]any{1700000000000, UnreadTypeHighlight, 50, "!room:example.com"}},
+		{"all mentions", "event_mention_idx", getMentionEventsQuery,
+			[]any{1700000000000, UnreadTypeHighlight, 50}},
+		{"failed events by session", "event_megolm_session_id_idx", getFailedEventsByMegolmSessionID,
+			[]any{"!room:example.com", "session"}},
+	}
+	var usedIndexes []string
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			plan := queryPlan(t, db, test.query, test.args...)
+			found := slices.ContainsFunc(plan, func(step string) bool {
+				return strings.HasPrefix(step, "SEARCH event USING INDEX "+test.index+" ") ||
+					strings.HasPrefix(step, "SCAN event USING INDEX "+test.index)
+			})
+			if !found {
+				t.Errorf("Query doesn't use %s, plan: %q", test.index, plan)
+			}
+			usedIndexes = append(usedIndexes, test.index)
+		})
+	}
+	for _, index := range migrationIndexes {
+		if !slices.Contains(usedIndexes, index) {
+			t.Errorf("Index %s from the migration isn't checked by any query plan test", index)
+		}
+	}
+}
+
+const (
+	benchmarkRooms  = 20
+	benchmarkEvents = 200_000
+	// fillBenchmarkEventsQuery generates messages in benchmarkRooms rooms. Every fourth event is a reaction
+	// to the previous event, every 50th event is a highlight and every 100th event failed to decrypt.
+	fillBenchmarkEventsQuery = `
+		WITH RECURSIVE n(i) AS (SELECT 0 UNION ALL SELECT i + 1 FROM n WHERE i < $1 - 1)
+		INSERT INTO event (
+			room_id, event_id, sender, type, timestamp, content, unsigned,
+			relates_to, relation_type, megolm_session_id, decryption_error, unread_type
+		)
+		SELECT
+			'!room' || (i / 4 % $2) || ':example.com', '$evt' || i, '@user' || (i % 7) || ':example.com',
+			CASE WHEN i % 4 = 3 THEN 'm.reaction' ELSE 'm.room.message' END,
+			1700000000000 + i * 1000, '{}', '{}',
+			CASE WHEN i % 4 = 3 THEN '$evt' || (i - 1) END,
+			CASE WHEN i % 4 = 3 THEN 'm.annotation' END,
+			CASE WHEN i % 100 = 1 THEN 'session' || (i % 1000) END,
+			CASE WHEN i % 100 = 1 THEN 'no session' END,
+			CASE WHEN i % 50 = 2 THEN 4 ELSE 0 END
+		FROM n
+	`
+	// v19EventIndexesQuery restores the indexes from before the event query index migration.
+	v19EventIndexesQuery = `
+		DROP INDEX event_room_mention_idx;
+		DROP INDEX event_relates_to_idx;
+		CREATE INDEX event_relates_to_idx ON event (room_id, relates_to);
+	`
+)
+
+// BenchmarkEventIndexes runs the queries covered by TestQueryPlan_EventIndexes on a large database,
+// first with the current indexes and then with the ones from before the event query index migration.
+func BenchmarkEventIndexes(b *testing.B) {
+	ctx := context.Background()
+	db := newTestDB(b)
+	for i := range benchmarkRooms {
+		if err := db.Room.CreateRow(ctx, id.RoomID(fmt.Sprintf("!room%d:example.com", i))); err != nil {
+			b.Fatalf("Failed to create room: %v", err)
+		}
+	}
+	if _, err := db.Exec(ctx, fillBenchmarkEventsQuery, benchmarkEvents, benchmarkRooms); err != nil {
+		b.Fatalf("Failed to fill database: %v", err)
+	}
+	if _, err := db.Exec(ctx, "ANALYZE"); err != nil {
+		b.Fatalf("Failed to analyze database: %v", err)
+	}
+	roomID := id.RoomID(fmt.Sprintf("!room%d:example.com", benchmarkRooms/2))
+	// Event 100202 is a highlight in the middle of the database in that room, and 100203 is a reaction to it
+	ts := time.UnixMilli(1700000000000 + 100_202*1000)
+	queries := []struct {
+		name string
+		fn   func() error
+	}{
+		{"related events", func() error {
+			_, err := db.Event.GetRelatedEvents(ctx, roomID, "$evt100202", event.RelAnnotation)
+			return err
+		}},
+		{"reactions", func() error {
+			_, err := db.Event.GetReactions(ctx, roomID, "$evt100202")
+			return err
+		}},
+		{"mentions in room", func() error {
+			_, err := db.Event.GetMentions(ctx, ts, UnreadTypeHighlight, 50, roomID)
+			return err
+		}},
+		{"all mentions", func() error {
+			_, err := db.Event.GetMentions(ctx, ts, UnreadTypeHighlight, 50, "")
+			return err
+		}},
+		{"failed events by session", func() error {
+			_, err := db.Event.GetFailedByMegolmSessionID(ctx, roomID, "session1")
+			return err
+		}},
+	}
+	runQueries := func(b *testing.B) {
+		for _, query := range queries {
+			b.Run(query.name, func(b *testing.B) {
+				for range b.N {
+					if err := query.fn(); err != nil {
+						b.Fatalf("Query failed: %v", err)
+					}
+				}
+			})
+		}
+	}
+	b.Run("v20", runQueries)
+	if _, err := db.Exec(ctx, v19EventIndexesQuery); err != nil {
+		b.Fatalf("Failed to restore old indexes: %v", err)
+	}
+	b.Run("v19", runQueries)
+}
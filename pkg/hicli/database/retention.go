@@ -0,0 +1,85 @@
+// Copyright (c) 2026 Tulir Asokan
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package database
+
+import (
+	"context"
+	"math"
+	"reflect"
+	"time"
+
+	"maunium.net/go/mautrix/event"
+	"maunium.net/go/mautrix/id"
+)
+
+// StateRetention is the MSC1763 message retention policy of a room.
+var StateRetention = event.Type{Type: "m.room.retention", Class: event.StateEventType}
+
+func init() {
+	event.TypeMap[StateRetention] = reflect.TypeOf(RetentionEventContent{})
+}
+
+// RetentionEventContent is the content of an m.room.retention state event. Lifetimes are in milliseconds.
+type RetentionEventContent struct {
+	MaxLifetime int64 `json:"max_lifetime,omitempty"`
+	MinLifetime int64 `json:"min_lifetime,omitempty"`
+}
+
+// MaxAge returns how long messages should be kept in the room, or zero if they should be kept forever.
+func (rec *RetentionEventContent) MaxAge() time.Duration {
+	if rec == nil || rec.MaxLifetime <= 0 || rec.MaxLifetime > math.MaxInt64/int64(time.Millisecond) {
+		return 0
+	}
+	return time.Duration(rec.MaxLifetime) * time.Millisecond
+}
+
+// ExpiryCutoff returns the timestamp before which messages have expired, or a zero time if messages never expire.
+func (rec *RetentionEventContent) ExpiryCutoff(now time.Time) time.Time {
+	maxAge := rec.MaxAge()
+	if maxAge == 0 {
+		return time.Time{}
+	}
+	return now.Add(-maxAge)
+}
+
+const (
+	getRoomsWithRetentionQuery = getRoomBaseQuery + `WHERE retention_content->>'$.max_lifetime' > 0`
+	// State events are kept, because they're referenced by current_state and needed for the room metadata.
+	// The room preview event and pinned events are kept too, as well as local echoes of unsent messages.
+	deleteExpiredEventsQuery = `
+		DELETE FROM event
+		WHERE room_id = $1
+		  AND timestamp < $2
+		  AND state_key IS NULL
+		  AND event_id NOT LIKE '~%'
+		  AND rowid <> COALESCE((SELECT preview_event_rowid FROM room WHERE room_id = $1), 0)
+		  AND event_id NOT IN (
+		      SELECT value FROM json_each((
+		          SELECT content->'$.pinned' FROM event
+		          WHERE rowid = (
+		              SELECT event_rowid FROM current_state
+		              WHERE room_id = $1 AND event_type = 'm.room.pinned_events' AND state_key = ''
+		          )
+		      ))
+		  )
+	`
+)
+
+// GetWithRetention returns all rooms that have a maximum message lifetime set.
+func (rq *RoomQuery) GetWithRetention(ctx context.Context) ([]*Room, error) {
+	return rq.QueryMany(ctx, getRoomsWithRetentionQuery)
+}
+
+// DeleteExpired deletes non-state events in the room that were sent before the given time.
+// The room preview and pinned events are not deleted.
+func (eq *EventQuery) DeleteExpired(ctx context.Context, roomID id.RoomID, before time.Time) (int64, error) {
+	res, err := eq.GetDB().Exec(ctx, deleteExpiredEventsQuery, roomID, before.UnixMilli())
+	if err != nil {
+		return 0, err
+	}
+	return res.RowsAffected()
+}
@@ -0,0 +1,171 @@
+// Copyright (c) 2026 Tulir Asokan
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package database
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+	"time"
+
+	"go.mau.fi/util/jsontime"
+	"go.mau.fi/util/ptr"
+	"maunium.net/go/mautrix/event"
+	"maunium.net/go/mautrix/id"
+)
+
+func TestRetentionEventContent_MaxAge(t *testing.T) {
+	tests := []struct {
+		name    string
+		content *RetentionEventContent
+		want    time.Duration
+	}{
+		{"nil", nil, 0},
+		{"no max lifetime", &RetentionEventContent{MinLifetime: 1000}, 0},
+		{"negative", &RetentionEventContent{MaxLifetime: -1}, 0},
+		{"one day", &RetentionEventContent{MaxLifetime: 86400000}, 24 * time.Hour},
+		{"overflowing", &RetentionEventContent{MaxLifetime: 1 << 62}, 0},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if got := test.content.MaxAge(); got != test.want {
+				t.Errorf("MaxAge() = %s, want %s", got, test.want)
+			}
+		})
+	}
+}
+
+func putTestOldMessage(t *testing.T, db *Database, roomID id.RoomID, evtID id.EventID, ts time.Time) *Event {
+	t.Helper()
+	return putTestEvent(t, db, &Event{
+		RoomID:    roomID,
+		ID:        evtID,
+		Sender:    "@alice:example.com",
+		Type:      event.EventMessage.Type,
+		Timestamp: jsontime.UM(ts),
+		Content:   json.RawMessage(`{"msgtype":"m.text","body":"hello"}`),
+	})
+}
+
+func TestDeleteExpired(t *testing.T) {
+	ctx := context.Background()
+	db := newTestDB(t)
+	now := time.Now()
+	old := now.Add(-48 * time.Hour)
+	cutoff := now.Add(-24 * time.Hour)
+	const otherRoomID id.RoomID = "!other:example.com"
+
+	putTestOldMessage(t, db, testRoomID, "$expired1", old)
+	putTestOldMessage(t, db, testRoomID, "$expired2", cutoff.Add(-time.Millisecond))
+	putTestOldMessage(t, db, testRoomID, "$recent", now)
+	putTestOldMessage(t, db, testRoomID, "$pinned", old)
+	preview := putTestOldMessage(t, db, testRoomID, "$preview", old)
+	putTestOldMessage(t, db, testRoomID, "~unsent", old)
+	putTestOldMessage(t, db, otherRoomID, "$otherroom", old)
+	nameEvt := putTestEvent(t, db, &Event{
+		RoomID:    testRoomID,
+		ID:        "$name",
+		Sender:    "@alice:example.com",
+		Type:      event.StateRoomName.Type,
+		StateKey:  ptr.Ptr(""),
+		Timestamp: jsontime.UM(old),
+		Content:   json.RawMessage(`{"name":"Room"}`),
+	})
+	pinnedEvt := putTestEvent(t, db, &Event{
+		RoomID:    testRoomID,
+		ID:        "$pinnedstate",
+		Sender:    "@alice:example.com",
+		Type:      event.StatePinnedEvents.Type,
+		StateKey:  ptr.Ptr(""),
+		Timestamp: jsontime.UM(old),
+		Content:   json.RawMessage(`{"pinned":["$pinned"]}`),
+	})
+	for _, evt := range []*Event{nameEvt, pinnedEvt} {
+		err := db.CurrentState.Set(ctx, testRoomID, event.Type{Type: evt.Type, Class: event.StateEventType}, "", evt.RowID, "")
+		if err != nil {
+			t.Fatalf("Failed to set current state: %v", err)
+		}
+	}
+	err := db.Room.Upsert(ctx, &Room{
+		ID:                testRoomID,
+		PreviewEventRowID: preview.RowID,
+		Retention:         &RetentionEventContent{MaxLifetime: (24 * time.Hour).Milliseconds()},
+	})
+	if err != nil {
+		t.Fatalf("Failed to update room: %v", err)
+	}
+
+	deleted, err := db.Event.DeleteExpired(ctx, testRoomID, cutoff)
+	if err != nil {
+		t.Fatalf("DeleteExpired failed: %v", err)
+	} else if deleted != 2 {
+		t.Errorf("DeleteExpired deleted %d events, want 2", deleted)
+	}
+	tests := []struct {
+		eventID  id.EventID
+		wantKept bool
+	}{
+		{"$expired1", false},
+		{"$expired2", false},
+		{"$recent", true},
+		{"$name", true},
+		{"$pinnedstate", true},
+		{"$pinned", true},
+		{"$preview", true},
+		{"~unsent", true},
+		{"$otherroom", true},
+	}
+	for _, test := range tests {
+		evt, err := db.Event.GetByID(ctx, test.eventID)
+		if err != nil {
+			t.Fatalf("Failed to get %s: %v", test.eventID, err)
+		} else if kept := evt != nil; kept != test.wantKept {
+			t.Errorf("%s kept = %t, want %t", test.eventID, kept, test.wantKept)
+		}
+	}
+}
+
+func TestDeleteExpired_NoPinnedEventsOrPreview(t *testing.T) {
+	ctx := context.Background()
+	db := newTestDB(t)
+	now := time.Now()
+	putTestOldMessage(t, db, testRoomID, "$expired", now.Add(-48*time.Hour))
+	putTestOldMessage(t, db, testRoomID, "$recent", now)
+
+	deleted, err := db.Event.DeleteExpired(ctx, testRoomID, now.Add(-24*time.Hour))
+	if err != nil {
+		t.Fatalf("DeleteExpired failed: %v", err)
+	} else if deleted != 1 {
+		t.Errorf("DeleteExpired deleted %d events, want 1", deleted)
+	}
+	if evt, err := db.Event.GetByID(ctx, "$expired"); err != nil || evt != nil {
+		t.Errorf("Expired event wasn't deleted (err: %v)", err)
+	}
+}
+
+func TestGetWithRetention(t *testing.T) {
+	ctx := context.Background()
+	db := newTestDB(t)
+	rooms := []*Room{
+		{ID: "!retention:example.com", Retention: &RetentionEventContent{MaxLifetime: 1000}},
+		{ID: "!minonly:example.com", Retention: &RetentionEventContent{MinLifetime: 1000}},
+		{ID: "!none:example.com"},
+	}
+	for _, room := range rooms {
+		if err := db.Room.CreateRow(ctx, room.ID); err != nil {
+			t.Fatalf("Failed to create room: %v", err)
+		} else if err = db.Room.Upsert(ctx, room); err != nil {
+			t.Fatalf("Failed to update room: %v", err)
+		}
+	}
+	got, err := db.Room.GetWithRetention(ctx)
+	if err != nil {
+		t.Fatalf("GetWithRetention failed: %v", err)
+	} else if len(got) != 1 || got[0].ID != "!retention:example.com" {
+		t.Errorf("GetWithRetention returned %d rooms, want only !retention:example.com", len(got))
+	}
+}
@@ -25,16 +25,22 @@ const (
 		SELECT room_id, creation_content, tombstone_content, name, name_quality,
 		       avatar, explicit_avatar, dm_user_id, topic, canonical_alias,
 		       lazy_load_summary, encryption_event, has_member_list, preview_event_rowid, sorting_timestamp,
-		       unread_highlights, unread_notifications, unread_messages, marked_unread, prev_batch
+		       unread_highlights, unread_notifications, unread_messages, marked_unread, prev_batch, left_at,
+		       server_unread_highlights, server_unread_notifications, retention_content
 		FROM room
 	`
-	getRoomsBySortingTimestampQuery = getRoomBaseQuery + `WHERE sorting_timestamp < $1 AND sorting_timestamp > 0 AND room_type<>'m.space' ORDER BY sorting_timestamp DESC LIMIT $2`
-	getRoomsByTypeQuery             = getRoomBaseQuery + `WHERE room_type = $1`
+	getRoomsBySortingTimestampQuery = getRoomBaseQuery + `WHERE sorting_timestamp < $1 AND sorting_timestamp > 0 AND room_type<>'m.space' AND left_at IS NULL ORDER BY sorting_timestamp DESC LIMIT $2`
+	getRoomsByTypeQuery             = getRoomBaseQuery + `WHERE room_type = $1 AND left_at IS NULL`
 	getRoomByIDQuery                = getRoomBaseQuery + `WHERE room_id = $1`
+	getLeftRoomsQuery               = getRoomBaseQuery + `WHERE left_at IS NOT NULL ORDER BY left_at DESC`
+	getJoinedRoomIDsQuery           = `SELECT room_id FROM room WHERE COALESCE(room_type, '')<>'m.space' AND left_at IS NULL`
 	ensureRoomExistsQuery           = `
 		INSERT INTO room (room_id) VALUES ($1)
 		ON CONFLICT (room_id) DO NOTHING
 	`
+	setRoomLeftAtQuery = `
+		UPDATE room SET left_at = $2 WHERE room_id = $1
+	`
 	upsertRoomFromSyncQuery = `
 		UPDATE room
 		SET room_type = COALESCE(room.room_type, json($2)->>'$.type', ''),
@@ -56,7 +62,10 @@ const (
 			unread_notifications = COALESCE($17, room.unread_notifications),
 			unread_messages = COALESCE($18, room.unread_messages),
 			marked_unread = COALESCE($19, room.marked_unread),
-			prev_batch = COALESCE($20, room.prev_batch)
+			prev_batch = COALESCE($20, room.prev_batch),
+			server_unread_highlights = $21,
+			server_unread_notifications = $22,
+			retention_content = COALESCE($23, room.retention_content)
 		WHERE room_id = $1
 	`
 	setRoomPrevBatchQuery = `
@@ -105,6 +114,16 @@ func (rq *RoomQuery) GetAllSpaces(ctx context.Context) ([]*Room, error) {
 	return rq.QueryMany(ctx, getRoomsByTypeQuery, event.RoomTypeSpace)
 }
 
+func (rq *RoomQuery) GetLeft(ctx context.Context) ([]*Room, error) {
+	return rq.QueryMany(ctx, getLeftRoomsQuery)
+}
+
+// GetJoinedIDs returns the IDs of all joined rooms except spaces.
+func (rq *RoomQuery) GetJoinedIDs(ctx context.Context) ([]id.RoomID, error) {
+	rows, err := rq.GetDB().Query(ctx, getJoinedRoomIDsQuery)
+	return dbutil.NewRowIterWithError(rows, dbutil.ScanSingleColumn[id.RoomID], err).AsList()
+}
+
 func (rq *RoomQuery) Upsert(ctx context.Context, room *Room) error {
 	return rq.Exec(ctx, upsertRoomFromSyncQuery, room.sqlVariables()...)
 }
@@ -117,6 +136,11 @@ func (rq *RoomQuery) CreateRow(ctx context.Context, roomID id.RoomID) error {
 	return rq.Exec(ctx, ensureRoomExistsQuery, roomID)
 }
 
+// SetLeft marks the room as archived. A zero time marks the room as joined again.
+func (rq *RoomQuery) SetLeft(ctx context.Context, roomID id.RoomID, leftAt time.Time) error {
+	return rq.Exec(ctx, setRoomLeftAtQuery, roomID, dbutil.UnixMilliPtr(leftAt))
+}
+
 func (rq *RoomQuery) SetPrevBatch(ctx context.Context, roomID id.RoomID, prevBatch string) error {
 	return rq.Exec(ctx, setRoomPrevBatchQuery, roomID, prevBatch)
 }
@@ -155,6 +179,7 @@ type Room struct {
 	ID              id.RoomID                    `json:"room_id"`
 	CreationContent *event.CreateEventContent    `json:"creation_content,omitempty"`
 	Tombstone       *event.TombstoneEventContent `json:"tombstone,omitempty"`
+	Retention       *RetentionEventContent       `json:"retention,omitempty"`
 
 	Name           *string        `json:"name,omitempty"`
 	NameQuality    NameQuality    `json:"name_quality"`
@@ -172,9 +197,15 @@ type Room struct {
 	PreviewEventRowID EventRowID         `json:"preview_event_rowid"`
 	SortingTimestamp  jsontime.UnixMilli `json:"sorting_timestamp"`
 	UnreadCounts
-	MarkedUnread *bool `json:"marked_unread,omitempty"`
+	// The unread counts reported by the homeserver. The normal counts are calculated locally from the
+	// events in the database, but the server's counts are kept for comparison and for clients that prefer them.
+	ServerUnreadCounts ServerUnreadCounts `json:"server_unread_counts"`
+	MarkedUnread       *bool              `json:"marked_unread,omitempty"`
 
 	PrevBatch string `json:"prev_batch"`
+	// The time when the user left the room. Rooms with a left timestamp are archived
+	// and only kept for browsing the local history.
+	LeftAt jsontime.UnixMilli `json:"left_at,omitzero"`
 }
 
 func (r *Room) EnsureNotNil() {
@@ -203,7 +234,8 @@ func (r *Room) VisibleMetaIsEqual(other *Room) bool {
 		ptr.Val(r.DMUserID) == ptr.Val(other.DMUserID) &&
 		r.LazyLoadSummary.Equal(other.LazyLoadSummary) &&
 		ptr.Val(r.EncryptionEvent).Algorithm == ptr.Val(other.EncryptionEvent).Algorithm &&
-		r.HasMemberList == other.HasMemberList
+		r.HasMemberList == other.HasMemberList &&
+		r.Retention.MaxAge() == other.Retention.MaxAge()
 }
 
 func (r *Room) CheckChangesAndCopyInto(other *Room) (hasChanges bool) {
@@ -215,6 +247,10 @@ func (r *Room) CheckChangesAndCopyInto(other *Room) (hasChanges bool) {
 		other.Tombstone = r.Tombstone
 		hasChanges = true
 	}
+	if r.Retention != nil {
+		other.Retention = r.Retention
+		hasChanges = true
+	}
 	if r.Name != nil && r.NameQuality >= other.NameQuality {
 		other.Name = r.Name
 		other.NameQuality = r.NameQuality
@@ -269,6 +305,10 @@ func (r *Room) CheckChangesAndCopyInto(other *Room) (hasChanges bool) {
 		other.UnreadMessages = r.UnreadMessages
 		hasChanges = true
 	}
+	if r.ServerUnreadCounts != other.ServerUnreadCounts {
+		other.ServerUnreadCounts = r.ServerUnreadCounts
+		hasChanges = true
+	}
 	if r.MarkedUnread != other.MarkedUnread {
 		other.MarkedUnread = r.MarkedUnread
 		hasChanges = true
@@ -282,7 +322,7 @@ func (r *Room) CheckChangesAndCopyInto(other *Room) (hasChanges bool) {
 
 func (r *Room) Scan(row dbutil.Scannable) (*Room, error) {
 	var prevBatch sql.NullString
-	var previewEventRowID, sortingTimestamp sql.NullInt64
+	var previewEventRowID, sortingTimestamp, leftAt sql.NullInt64
 	err := row.Scan(
 		&r.ID,
 		dbutil.JSON{Data: &r.CreationContent},
@@ -304,6 +344,10 @@ func (r *Room) Scan(row dbutil.Scannable) (*Room, error) {
 		&r.UnreadMessages,
 		&r.MarkedUnread,
 		&prevBatch,
+		&leftAt,
+		&r.ServerUnreadCounts.Highlights,
+		&r.ServerUnreadCounts.Notifications,
+		dbutil.JSON{Data: &r.Retention},
 	)
 	if err != nil {
 		return nil, err
@@ -311,6 +355,7 @@ func (r *Room) Scan(row dbutil.Scannable) (*Room, error) {
 	r.PrevBatch = prevBatch.String
 	r.PreviewEventRowID = EventRowID(previewEventRowID.Int64)
 	r.SortingTimestamp = jsontime.UMInt(sortingTimestamp.Int64)
+	r.LeftAt = jsontime.UMInt(leftAt.Int64)
 	return r, nil
 }
 
@@ -336,6 +381,9 @@ func (r *Room) sqlVariables() []any {
 		r.UnreadMessages,
 		r.MarkedUnread,
 		dbutil.StrPtr(r.PrevBatch),
+		r.ServerUnreadCounts.Highlights,
+		r.ServerUnreadCounts.Notifications,
+		dbutil.JSONPtr(r.Retention),
 	}
 }
 
@@ -25,6 +25,9 @@ const (
 	removeSessionRequestQuery = `
 		DELETE FROM session_request WHERE session_id = $1 AND min_index >= $2
 	`
+	resetSessionRequestBackupCheckedQuery = `
+		UPDATE session_request SET backup_checked = false
+	`
 	getNextSessionsToRequestQuery = `
 		SELECT room_id, session_id, sender, min_index, backup_checked, request_sent
 		FROM session_request
@@ -32,6 +35,9 @@ const (
 		ORDER BY backup_checked, rowid
 		LIMIT $1
 	`
+	countPendingSessionRequestsQuery = `
+		SELECT COUNT(*) FROM session_request WHERE request_sent = false OR backup_checked = false
+	`
 )
 
 type SessionRequestQuery struct {
@@ -42,10 +48,22 @@ func (srq *SessionRequestQuery) Next(ctx context.Context, count int) ([]*Session
 	return srq.QueryMany(ctx, getNextSessionsToRequestQuery, count)
 }
 
+// CountPending returns the number of queued sessions that haven't been requested or checked from the key backup yet.
+func (srq *SessionRequestQuery) CountPending(ctx context.Context) (count int, err error) {
+	err = srq.GetDB().QueryRow(ctx, countPendingSessionRequestsQuery).Scan(&count)
+	return
+}
+
 func (srq *SessionRequestQuery) Remove(ctx context.Context, sessionID id.SessionID, minIndex uint32) error {
 	return srq.Exec(ctx, removeSessionRequestQuery, sessionID, minIndex)
 }
 
+// ResetBackupChecked marks all queued sessions as not checked from the key backup,
+// which is used to check them again after the key backup key is obtained.
+func (srq *SessionRequestQuery) ResetBackupChecked(ctx context.Context) error {
+	return srq.Exec(ctx, resetSessionRequestBackupCheckedQuery)
+}
+
 func (srq *SessionRequestQuery) Put(ctx context.Context, sr *SessionRequest) error {
 	return srq.Exec(ctx, putSessionRequestQueueEntry, sr.sqlVariables()...)
 }
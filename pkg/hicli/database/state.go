@@ -8,11 +8,14 @@ package database
 
 import (
 	"context"
+	"database/sql"
 	"fmt"
 	"slices"
 	"strings"
+	"time"
 
 	"go.mau.fi/util/dbutil"
+	"go.mau.fi/util/jsontime"
 	"maunium.net/go/mautrix/event"
 	"maunium.net/go/mautrix/id"
 )
@@ -33,7 +36,7 @@ const (
 		SELECT event.rowid, -1,
 		       event.room_id, event.event_id, sender, event.type, event.state_key, timestamp, content, decrypted, decrypted_type,
 		       unsigned, local_content, transaction_id, redacted_by, relates_to, relation_type,
-		       megolm_session_id, decryption_error, send_error, reactions, last_edit_rowid, unread_type
+		       megolm_session_id, decryption_error, send_error, reactions, reaction_senders, last_edit_rowid, unread_type
 		FROM current_state cs
 		JOIN event ON cs.event_rowid = event.rowid
 	`
@@ -42,6 +45,23 @@ const (
 	getCurrentRoomStateMembersQuery        = getCurrentRoomStateBaseQuery + `WHERE cs.room_id = $1 AND type='m.room.member'`
 	getManyCurrentRoomStateQuery           = getCurrentRoomStateBaseQuery + `WHERE (cs.room_id, cs.event_type, cs.state_key) IN (%s)`
 	getCurrentStateEventQuery              = getCurrentRoomStateBaseQuery + `WHERE cs.room_id = $1 AND cs.event_type = $2 AND cs.state_key = $3`
+	getCurrentRoomStateOfTypesQuery        = getCurrentRoomStateBaseQuery + `WHERE cs.room_id = $1 AND cs.event_type IN (%s)`
+	// The join time is the timestamp of the current member event if it changed the membership to join.
+	// If it's a profile change, the previous event is checked too, in case that one is the join.
+	getMemberListQuery = `
+		SELECT cs.state_key, cs.membership, event.content->>'$.displayname', event.content->>'$.reason',
+		       CASE
+		           WHEN cs.membership <> 'join' THEN NULL
+		           WHEN COALESCE(event.unsigned->>'$.prev_content.membership', '') <> 'join' THEN event.timestamp
+		           WHEN prev.content->>'$.membership' = 'join'
+		                AND COALESCE(prev.unsigned->>'$.prev_content.membership', '') <> 'join' THEN prev.timestamp
+		       END
+		FROM current_state cs
+		JOIN event ON cs.event_rowid = event.rowid
+		LEFT JOIN event prev ON prev.event_id = event.unsigned->>'$.replaces_state'
+		WHERE cs.room_id = $1 AND cs.event_type = 'm.room.member'
+		ORDER BY cs.state_key
+	`
 )
 
 var massInsertCurrentStateBuilder = dbutil.NewMassInsertBuilder[*CurrentStateEntry, [1]any](addCurrentStateQuery, "($1, $%d, $%d, $%d, $%d)")
@@ -120,6 +140,52 @@ func (csq *CurrentStateQuery) GetAllExceptMembers(ctx context.Context, roomID id
 	return csq.QueryMany(ctx, getCurrentRoomStateWithoutMembersQuery, roomID)
 }
 
+// GetAllOfTypes returns all current state events of the given types in a room.
+func (csq *CurrentStateQuery) GetAllOfTypes(ctx context.Context, roomID id.RoomID, types ...event.Type) ([]*Event, error) {
+	args := make([]any, len(types)+1)
+	placeholders := make([]string, len(types))
+	args[0] = roomID
+	for i, evtType := range types {
+		args[i+1] = evtType.Type
+		placeholders[i] = fmt.Sprintf("$%d", i+2)
+	}
+	query := fmt.Sprintf(getCurrentRoomStateOfTypesQuery, strings.Join(placeholders, ", "))
+	return csq.QueryMany(ctx, query, args...)
+}
+
 func (csq *CurrentStateQuery) GetMembers(ctx context.Context, roomID id.RoomID) ([]*Event, error) {
 	return csq.QueryMany(ctx, getCurrentRoomStateMembersQuery, roomID)
 }
+
+// MemberListEntry is a summary of a single member event used for exporting the member list.
+type MemberListEntry struct {
+	UserID      id.UserID          `json:"user_id"`
+	Membership  event.Membership   `json:"membership"`
+	Displayname string             `json:"displayname,omitempty"`
+	Reason      string             `json:"reason,omitempty"`
+	PowerLevel  int                `json:"power_level"`
+	JoinedAt    jsontime.UnixMilli `json:"joined_at,omitzero"`
+}
+
+func scanMemberListEntry(row dbutil.Scannable) (*MemberListEntry, error) {
+	var entry MemberListEntry
+	var displayname, reason sql.NullString
+	var joinedAt sql.NullInt64
+	err := row.Scan(&entry.UserID, &entry.Membership, &displayname, &reason, &joinedAt)
+	if err != nil {
+		return nil, err
+	}
+	entry.Displayname = displayname.String
+	entry.Reason = reason.String
+	if joinedAt.Valid {
+		entry.JoinedAt = jsontime.UM(time.UnixMilli(joinedAt.Int64))
+	}
+	return &entry, nil
+}
+
+// IterMembers returns an iterator over the members of a room sorted by user ID. Unlike GetMembers, this doesn't
+// load the whole member list into memory, so it's suitable for very large rooms. The power level isn't filled.
+func (csq *CurrentStateQuery) IterMembers(ctx context.Context, roomID id.RoomID) dbutil.RowIter[*MemberListEntry] {
+	rows, err := csq.GetDB().Query(ctx, getMemberListQuery, roomID)
+	return dbutil.NewRowIterWithError(rows, scanMemberListEntry, err)
+}
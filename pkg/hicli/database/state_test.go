@@ -0,0 +1,107 @@
+// Copyright (c) 2026 Tulir Asokan
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package database
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+
+	"go.mau.fi/util/jsontime"
+	"go.mau.fi/util/ptr"
+	"maunium.net/go/mautrix/event"
+	"maunium.net/go/mautrix/id"
+)
+
+// putTestMemberEvent stores a member event with the given content and unsigned data and sets it as the current state.
+func putTestMemberEvent(t *testing.T, db *Database, evtID id.EventID, userID id.UserID, ts int64, content, unsigned string) *Event {
+	t.Helper()
+	var memberContent event.MemberEventContent
+	if err := json.Unmarshal([]byte(content), &memberContent); err != nil {
+		t.Fatalf("Failed to parse member content: %v", err)
+	}
+	evt := putTestEvent(t, db, &Event{
+		RoomID:    testRoomID,
+		ID:        evtID,
+		Sender:    userID,
+		Type:      event.StateMember.Type,
+		StateKey:  ptr.Ptr(userID.String()),
+		Timestamp: jsontime.UMInt(ts),
+		Content:   json.RawMessage(content),
+		Unsigned:  json.RawMessage(unsigned),
+	})
+	err := db.CurrentState.Set(context.Background(), testRoomID, event.StateMember, userID.String(), evt.RowID, memberContent.Membership)
+	if err != nil {
+		t.Fatalf("Failed to set current state: %v", err)
+	}
+	return evt
+}
+
+func TestIterMembers(t *testing.T) {
+	db := newTestDB(t)
+	// Plain join without a previous membership
+	putTestMemberEvent(t, db, "$alice", "@alice:example.com", 1000, `{"membership":"join","displayname":"Alice"}`, `{}`)
+	// Join followed by a displayname change, which must still use the time of the join
+	putTestMemberEvent(t, db, "$bobjoin", "@bob:example.com", 2000, `{"membership":"join"}`, `{}`)
+	putTestMemberEvent(t, db, "$bobname", "@bob:example.com", 3000, `{"membership":"join","displayname":"Bob"}`,
+		`{"prev_content":{"membership":"join"},"replaces_state":"$bobjoin"}`)
+	// Two profile changes in a row, so the join isn't reachable anymore
+	putTestMemberEvent(t, db, "$carolname1", "@carol:example.com", 4000, `{"membership":"join","displayname":"C"}`,
+		`{"prev_content":{"membership":"join"},"replaces_state":"$caroljoin"}`)
+	putTestMemberEvent(t, db, "$carolname2", "@carol:example.com", 5000, `{"membership":"join","displayname":"Carol"}`,
+		`{"prev_content":{"membership":"join","displayname":"C"},"replaces_state":"$carolname1"}`)
+	// Join after accepting an invite
+	putTestMemberEvent(t, db, "$dave", "@dave:example.com", 6000, `{"membership":"join"}`,
+		`{"prev_content":{"membership":"invite"},"replaces_state":"$daveinvite"}`)
+	putTestMemberEvent(t, db, "$eve", "@eve:example.com", 7000, `{"membership":"ban","reason":"spam"}`,
+		`{"prev_content":{"membership":"join"},"replaces_state":"$evejoin"}`)
+	putTestMemberEvent(t, db, "$frank", "@frank:example.com", 8000, `{"membership":"invite"}`, `{}`)
+	// Non-member state in the same room must be ignored
+	putTestEvent(t, db, &Event{
+		RoomID:   testRoomID,
+		ID:       "$name",
+		Sender:   "@alice:example.com",
+		Type:     event.StateRoomName.Type,
+		StateKey: ptr.Ptr(""),
+		Content:  json.RawMessage(`{"name":"Room"}`),
+	})
+
+	entries, err := db.CurrentState.IterMembers(context.Background(), testRoomID).AsList()
+	if err != nil {
+		t.Fatalf("IterMembers failed: %v", err)
+	}
+	want := []MemberListEntry{
+		{UserID: "@alice:example.com", Membership: event.MembershipJoin, Displayname: "Alice", JoinedAt: jsontime.UMInt(1000)},
+		{UserID: "@bob:example.com", Membership: event.MembershipJoin, Displayname: "Bob", JoinedAt: jsontime.UMInt(2000)},
+		{UserID: "@carol:example.com", Membership: event.MembershipJoin, Displayname: "Carol"},
+		{UserID: "@dave:example.com", Membership: event.MembershipJoin, JoinedAt: jsontime.UMInt(6000)},
+		{UserID: "@eve:example.com", Membership: event.MembershipBan, Reason: "spam"},
+		{UserID: "@frank:example.com", Membership: event.MembershipInvite},
+	}
+	if len(entries) != len(want) {
+		t.Fatalf("IterMembers() returned %d entries, want %d", len(entries), len(want))
+	}
+	for i, entry := range entries {
+		if !entry.JoinedAt.Time.Equal(want[i].JoinedAt.Time) {
+			t.Errorf("Entry #%d joined at %v, want %v", i, entry.JoinedAt.Time, want[i].JoinedAt.Time)
+		}
+		entry.JoinedAt = want[i].JoinedAt
+		if *entry != want[i] {
+			t.Errorf("Entry #%d = %+v, want %+v", i, *entry, want[i])
+		}
+	}
+}
+
+func TestIterMembers_Empty(t *testing.T) {
+	db := newTestDB(t)
+	entries, err := db.CurrentState.IterMembers(context.Background(), testRoomID).AsList()
+	if err != nil {
+		t.Fatalf("IterMembers failed: %v", err)
+	} else if len(entries) != 0 {
+		t.Errorf("IterMembers() returned %d entries for an unknown room, want 0", len(entries))
+	}
+}
@@ -10,6 +10,7 @@ import (
 	"context"
 	"database/sql"
 	"errors"
+	"fmt"
 	"sync"
 
 	"go.mau.fi/util/dbutil"
@@ -27,22 +28,53 @@ const (
 	`
 	prependTimelineQuery = `
 		INSERT INTO timeline (room_id, rowid, event_rowid) VALUES ($1, $2, $3)
+		ON CONFLICT DO NOTHING
+		RETURNING rowid, event_rowid
 	`
 	checkTimelineContainsQuery = `
 		SELECT EXISTS(SELECT 1 FROM timeline WHERE room_id = $1 AND event_rowid = $2)
 	`
-	findMinRowIDQuery = `SELECT MIN(rowid) FROM timeline`
-	getTimelineQuery  = `
+	findMinRowIDQuery  = `SELECT COALESCE(MIN(rowid), 0) FROM timeline`
+	findMaxRowIDQuery  = `SELECT COALESCE(MAX(rowid), 0) FROM timeline`
+	findPrevRowIDQuery = `SELECT COALESCE(MAX(rowid), 0) FROM timeline WHERE rowid < $1`
+	getTimelineQuery   = `
 		SELECT event.rowid, timeline.rowid,
 		       event.room_id, event_id, sender, type, state_key, timestamp, content, decrypted, decrypted_type,
 		       unsigned, local_content, transaction_id, redacted_by, relates_to, relation_type,
-		       megolm_session_id, decryption_error, send_error, reactions, last_edit_rowid, unread_type
+		       megolm_session_id, decryption_error, send_error, reactions, reaction_senders, last_edit_rowid, unread_type
 		FROM timeline
 		JOIN event ON event.rowid = timeline.event_rowid
 		WHERE timeline.room_id = $1 AND ($2 = 0 OR timeline.rowid < $2)
 		ORDER BY timeline.rowid DESC
 		LIMIT $3
 	`
+	checkTimelineNotEmptyQuery = `
+		SELECT EXISTS(SELECT 1 FROM timeline WHERE room_id = $1)
+	`
+	checkTimelineRowExistsQuery = `
+		SELECT EXISTS(SELECT 1 FROM timeline WHERE room_id = $1 AND rowid = $2)
+	`
+	getTimelineTailQuery = `
+		SELECT rowid, event_rowid FROM timeline WHERE room_id = $1 AND rowid >= $2 ORDER BY rowid ASC
+	`
+	deleteTimelineTailQuery = `
+		DELETE FROM timeline WHERE room_id = $1 AND rowid >= $2
+	`
+	putTimelineGapQuery = `
+		INSERT INTO timeline_gap (room_id, timeline_rowid, prev_batch, missed_count) VALUES ($1, $2, $3, $4)
+		ON CONFLICT (room_id, timeline_rowid) DO UPDATE SET prev_batch = excluded.prev_batch, missed_count = excluded.missed_count
+	`
+	deleteTimelineGapQuery = `
+		DELETE FROM timeline_gap WHERE room_id = $1 AND timeline_rowid = $2
+	`
+	getTimelineGapQuery = `
+		SELECT room_id, timeline_rowid, prev_batch, missed_count FROM timeline_gap WHERE room_id = $1 AND timeline_rowid = $2
+	`
+	getTimelineGapsInRangeQuery = `
+		SELECT room_id, timeline_rowid, prev_batch, missed_count FROM timeline_gap
+		WHERE room_id = $1 AND timeline_rowid >= $2 AND timeline_rowid <= $3
+		ORDER BY timeline_rowid ASC
+	`
 )
 
 // A TimelineRowID is a sorting identifier for events in a room. All events shown in the timeline
@@ -54,9 +86,15 @@ const (
 // If the timeline cache for a room is cleared for any reason (either manually or due to a limited
 // sync), the identifiers will be reset for that room and start from higher values than before.
 //
+// When a gap is created, the entries after it are appended with GapRowIDSpace unused row IDs
+// before them, so that events filled into the gap later can be given row IDs in the middle.
+//
 // Zero is not a valid value, as it's used to indicate an absent value in some contexts.
 type TimelineRowID int64
 
+// GapRowIDSpace is the number of timeline row IDs left unused before entries appended after a gap.
+const GapRowIDSpace = 1 << 20
+
 // A TimelineRowTuple combines a timeline row ID with an event row ID.
 // It is used in the `timeline` field of sync payloads.
 type TimelineRowTuple struct {
@@ -69,6 +107,23 @@ var timelineRowTupleScanner = dbutil.ConvertRowFn[TimelineRowTuple](func(row dbu
 	return
 })
 
+// A TimelineGap marks a point in the timeline where events are known to be missing, e.g. because
+// a sync was limited while there was already a cached timeline. The gap is located right before
+// the entry with the given timeline row ID, and can be filled by paginating backwards from PrevBatch.
+type TimelineGap struct {
+	RoomID        id.RoomID     `json:"room_id"`
+	TimelineRowID TimelineRowID `json:"timeline_rowid"`
+	PrevBatch     string        `json:"-"`
+	// MissedCount is an estimate of how many messages are missing. It's based on the notification
+	// count from the server, so it's zero if the count is unknown (e.g. in muted rooms).
+	MissedCount int `json:"missed_count,omitempty"`
+}
+
+var timelineGapScanner = dbutil.ConvertRowFn[*TimelineGap](func(row dbutil.Scannable) (*TimelineGap, error) {
+	var gap TimelineGap
+	return dbutil.ValueOrErr(&gap, row.Scan(&gap.RoomID, &gap.TimelineRowID, &gap.PrevBatch, &gap.MissedCount))
+})
+
 func (trt TimelineRowTuple) GetMassInsertValues() [2]any {
 	return [2]any{trt.Timeline, trt.Event}
 }
@@ -113,13 +168,14 @@ func (tq *TimelineQuery) reserveRowIDs(ctx context.Context, count int) (startFro
 
 // Prepend adds the given event row IDs to the beginning of the timeline.
 // The events must be sorted in reverse chronological order (newest event first).
-func (tq *TimelineQuery) Prepend(ctx context.Context, roomID id.RoomID, rowIDs []EventRowID) (prependEntries []TimelineRowTuple, err error) {
-	var startFrom TimelineRowID
-	startFrom, err = tq.reserveRowIDs(ctx, len(rowIDs))
+//
+// Events that are already in the timeline keep their existing position and are not included in the returned tuples.
+func (tq *TimelineQuery) Prepend(ctx context.Context, roomID id.RoomID, rowIDs []EventRowID) ([]TimelineRowTuple, error) {
+	startFrom, err := tq.reserveRowIDs(ctx, len(rowIDs))
 	if err != nil {
-		return
+		return nil, err
 	}
-	prependEntries = make([]TimelineRowTuple, len(rowIDs))
+	prependEntries := make([]TimelineRowTuple, len(rowIDs))
 	for i, rowID := range rowIDs {
 		prependEntries[i] = TimelineRowTuple{
 			Timeline: startFrom - TimelineRowID(i),
@@ -127,11 +183,11 @@ func (tq *TimelineQuery) Prepend(ctx context.Context, roomID id.RoomID, rowIDs [
 		}
 	}
 	query, params := prependTimelineQueryBuilder.Build([1]any{roomID}, prependEntries)
-	err = tq.Exec(ctx, query, params...)
-	return
+	return timelineRowTupleScanner.NewRowIter(tq.GetDB().Query(ctx, query, params...)).AsList()
 }
 
 // Append adds the given event row IDs to the end of the timeline.
+// Events that are already in the timeline are skipped and not included in the returned tuples.
 func (tq *TimelineQuery) Append(ctx context.Context, roomID id.RoomID, rowIDs []EventRowID) ([]TimelineRowTuple, error) {
 	query, params := appendTimelineQueryBuilder.Build([1]any{roomID}, rowIDs)
 	return timelineRowTupleScanner.NewRowIter(tq.GetDB().Query(ctx, query, params...)).AsList()
@@ -145,3 +201,144 @@ func (tq *TimelineQuery) Has(ctx context.Context, roomID id.RoomID, eventRowID E
 	err = tq.GetDB().QueryRow(ctx, checkTimelineContainsQuery, roomID, eventRowID).Scan(&exists)
 	return
 }
+
+// HasAny checks if the given room has any events in the timeline.
+func (tq *TimelineQuery) HasAny(ctx context.Context, roomID id.RoomID) (exists bool, err error) {
+	err = tq.GetDB().QueryRow(ctx, checkTimelineNotEmptyQuery, roomID).Scan(&exists)
+	return
+}
+
+// AppendAfterGap adds the given event row IDs to the end of the timeline like Append, but leaves
+// GapRowIDSpace unused timeline row IDs before the new entries, so that a gap right before them
+// can be filled with Splice without moving any existing entries.
+func (tq *TimelineQuery) AppendAfterGap(ctx context.Context, roomID id.RoomID, rowIDs []EventRowID) ([]TimelineRowTuple, error) {
+	var maxRowID TimelineRowID
+	err := tq.GetDB().QueryRow(ctx, findMaxRowIDQuery).Scan(&maxRowID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to find last timeline row ID: %w", err)
+	}
+	startFrom := max(maxRowID, 0) + GapRowIDSpace + 1
+	entries := make([]TimelineRowTuple, len(rowIDs))
+	for i, rowID := range rowIDs {
+		entries[i] = TimelineRowTuple{
+			Timeline: startFrom + TimelineRowID(i),
+			Event:    rowID,
+		}
+	}
+	query, params := prependTimelineQueryBuilder.Build([1]any{roomID}, entries)
+	return timelineRowTupleScanner.NewRowIter(tq.GetDB().Query(ctx, query, params...)).AsList()
+}
+
+// Splice inserts the given event row IDs into the timeline right before the given timeline row ID.
+// The events must be sorted in chronological order (oldest event first). Events that are already
+// in the timeline keep their existing position and are not included in the returned tuples.
+// The gap located exactly at the splice point (if any) is deleted.
+//
+// The new entries are given the unused row IDs right below the splice point (see AppendAfterGap).
+// If there aren't enough unused row IDs, e.g. because the gap was created before they were reserved,
+// all entries starting from the splice point are reinserted after the new events with new row IDs,
+// and gaps located after the splice point are moved along with the entries. In that case the
+// returned tuples also include all the reinserted entries.
+func (tq *TimelineQuery) Splice(ctx context.Context, roomID id.RoomID, before TimelineRowID, rowIDs []EventRowID) ([]TimelineRowTuple, error) {
+	var exists bool
+	var prevRowID TimelineRowID
+	err := tq.GetDB().QueryRow(ctx, checkTimelineRowExistsQuery, roomID, before).Scan(&exists)
+	if err != nil {
+		return nil, fmt.Errorf("failed to check if splice point exists: %w", err)
+	} else if !exists {
+		return nil, fmt.Errorf("splice point not found in timeline")
+	}
+	err = tq.GetDB().QueryRow(ctx, findPrevRowIDQuery, before).Scan(&prevRowID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to find previous timeline row ID: %w", err)
+	}
+	// Zero isn't a valid row ID, so the free range can't extend below it
+	prevRowID = max(prevRowID, 0)
+	if int64(before-prevRowID-1) < int64(len(rowIDs)) {
+		return tq.spliceByReinserting(ctx, roomID, before, rowIDs)
+	}
+	err = tq.DeleteGap(ctx, roomID, before)
+	if err != nil {
+		return nil, fmt.Errorf("failed to delete gap at splice point: %w", err)
+	}
+	if len(rowIDs) == 0 {
+		return []TimelineRowTuple{}, nil
+	}
+	startFrom := before - TimelineRowID(len(rowIDs))
+	entries := make([]TimelineRowTuple, len(rowIDs))
+	for i, rowID := range rowIDs {
+		entries[i] = TimelineRowTuple{
+			Timeline: startFrom + TimelineRowID(i),
+			Event:    rowID,
+		}
+	}
+	query, params := prependTimelineQueryBuilder.Build([1]any{roomID}, entries)
+	return timelineRowTupleScanner.NewRowIter(tq.GetDB().Query(ctx, query, params...)).AsList()
+}
+
+func (tq *TimelineQuery) spliceByReinserting(ctx context.Context, roomID id.RoomID, before TimelineRowID, rowIDs []EventRowID) ([]TimelineRowTuple, error) {
+	tail, err := timelineRowTupleScanner.NewRowIter(tq.GetDB().Query(ctx, getTimelineTailQuery, roomID, before)).AsList()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get timeline entries after splice point: %w", err)
+	}
+	gaps, err := tq.GetGaps(ctx, roomID, before+1, tail[len(tail)-1].Timeline)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get gaps after splice point: %w", err)
+	}
+	gapEvents := make(map[EventRowID]*TimelineGap, len(gaps))
+	for _, gap := range gaps {
+		for _, tuple := range tail {
+			if tuple.Timeline == gap.TimelineRowID {
+				gapEvents[tuple.Event] = gap
+				break
+			}
+		}
+	}
+	// This also deletes the gap at the splice point, as gaps cascade with their timeline entry
+	err = tq.Exec(ctx, deleteTimelineTailQuery, roomID, before)
+	if err != nil {
+		return nil, fmt.Errorf("failed to delete timeline entries after splice point: %w", err)
+	}
+	newRowIDs := make([]EventRowID, 0, len(rowIDs)+len(tail))
+	newRowIDs = append(newRowIDs, rowIDs...)
+	for _, tuple := range tail {
+		newRowIDs = append(newRowIDs, tuple.Event)
+	}
+	// Reserve space before the reinserted entries so that the rest of the gap can be filled in place
+	tuples, err := tq.AppendAfterGap(ctx, roomID, newRowIDs)
+	if err != nil {
+		return nil, fmt.Errorf("failed to reinsert timeline entries: %w", err)
+	}
+	for _, tuple := range tuples {
+		if gap, ok := gapEvents[tuple.Event]; ok {
+			gap.TimelineRowID = tuple.Timeline
+			err = tq.PutGap(ctx, gap)
+			if err != nil {
+				return nil, fmt.Errorf("failed to move gap: %w", err)
+			}
+		}
+	}
+	return tuples, nil
+}
+
+// PutGap marks a gap in the timeline right before the gap's timeline row ID.
+func (tq *TimelineQuery) PutGap(ctx context.Context, gap *TimelineGap) error {
+	return tq.Exec(ctx, putTimelineGapQuery, gap.RoomID, gap.TimelineRowID, gap.PrevBatch, gap.MissedCount)
+}
+
+func (tq *TimelineQuery) DeleteGap(ctx context.Context, roomID id.RoomID, rowID TimelineRowID) error {
+	return tq.Exec(ctx, deleteTimelineGapQuery, roomID, rowID)
+}
+
+func (tq *TimelineQuery) GetGap(ctx context.Context, roomID id.RoomID, rowID TimelineRowID) (*TimelineGap, error) {
+	gap, err := timelineGapScanner(tq.GetDB().QueryRow(ctx, getTimelineGapQuery, roomID, rowID))
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, nil
+	}
+	return gap, err
+}
+
+// GetGaps returns all gaps in the given room between the given timeline row IDs (inclusive).
+func (tq *TimelineQuery) GetGaps(ctx context.Context, roomID id.RoomID, minRowID, maxRowID TimelineRowID) ([]*TimelineGap, error) {
+	return timelineGapScanner.NewRowIter(tq.GetDB().Query(ctx, getTimelineGapsInRangeQuery, roomID, minRowID, maxRowID)).AsList()
+}
@@ -0,0 +1,326 @@
+// Copyright (c) 2026 Tulir Asokan
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package database
+
+import (
+	"context"
+	"math"
+	"slices"
+	"testing"
+
+	"maunium.net/go/mautrix/id"
+)
+
+func putTestMessages(t *testing.T, db *Database, eventIDs ...id.EventID) []EventRowID {
+	t.Helper()
+	rowIDs := make([]EventRowID, len(eventIDs))
+	for i, evtID := range eventIDs {
+		rowIDs[i] = putTestMessage(t, db, evtID).RowID
+	}
+	return rowIDs
+}
+
+// getTestTimeline returns the event IDs in the timeline of the test room in chronological order.
+func getTestTimeline(t *testing.T, db *Database) []id.EventID {
+	t.Helper()
+	evts, err := db.Timeline.Get(context.Background(), testRoomID, 1000, 0)
+	if err != nil {
+		t.Fatalf("Failed to get timeline: %v", err)
+	}
+	eventIDs := make([]id.EventID, 0, len(evts))
+	for _, evt := range slices.Backward(evts) {
+		eventIDs = append(eventIDs, evt.ID)
+	}
+	for i := 1; i < len(evts); i++ {
+		if evts[i].TimelineRowID >= evts[i-1].TimelineRowID {
+			t.Fatalf("Timeline isn't strictly sorted: %d came after %d", evts[i].TimelineRowID, evts[i-1].TimelineRowID)
+		}
+	}
+	return eventIDs
+}
+
+func tupleEvents(tuples []TimelineRowTuple) []EventRowID {
+	rowIDs := make([]EventRowID, len(tuples))
+	for i, tuple := range tuples {
+		rowIDs[i] = tuple.Event
+	}
+	return rowIDs
+}
+
+func TestTimelineQuery_AppendSkipsExisting(t *testing.T) {
+	ctx := context.Background()
+	db := newTestDB(t)
+	rowIDs := putTestMessages(t, db, "$a", "$b", "$c")
+
+	tuples, err := db.Timeline.Append(ctx, testRoomID, rowIDs[:2])
+	if err != nil {
+		t.Fatalf("Failed to append: %v", err)
+	} else if len(tuples) != 2 {
+		t.Fatalf("First append returned %d tuples, want 2", len(tuples))
+	}
+	// Sync returning an event that's already in the timeline must not move or duplicate it
+	tuples, err = db.Timeline.Append(ctx, testRoomID, []EventRowID{rowIDs[1], rowIDs[2]})
+	if err != nil {
+		t.Fatalf("Failed to append overlapping events: %v", err)
+	} else if !slices.Equal(tupleEvents(tuples), rowIDs[2:]) {
+		t.Errorf("Overlapping append returned %v, want only %v", tupleEvents(tuples), rowIDs[2:])
+	}
+	if got := getTestTimeline(t, db); !slices.Equal(got, []id.EventID{"$a", "$b", "$c"}) {
+		t.Errorf("Timeline is %v, want [$a $b $c]", got)
+	}
+}
+
+func TestTimelineQuery_PrependSkipsExisting(t *testing.T) {
+	ctx := context.Background()
+	db := newTestDB(t)
+	rowIDs := putTestMessages(t, db, "$old1", "$old2", "$synced1", "$synced2")
+
+	synced, err := db.Timeline.Append(ctx, testRoomID, rowIDs[2:])
+	if err != nil {
+		t.Fatalf("Failed to append: %v", err)
+	}
+	// Paginating backwards from before the sync returns $synced1 again along with older events.
+	// Prepend takes events in reverse chronological order.
+	tuples, err := db.Timeline.Prepend(ctx, testRoomID, []EventRowID{rowIDs[2], rowIDs[1], rowIDs[1], rowIDs[0]})
+	if err != nil {
+		t.Fatalf("Failed to prepend overlapping events: %v", err)
+	} else if !slices.Equal(tupleEvents(tuples), []EventRowID{rowIDs[1], rowIDs[0]}) {
+		t.Errorf("Overlapping prepend returned %v, want %v", tupleEvents(tuples), []EventRowID{rowIDs[1], rowIDs[0]})
+	}
+	for _, tuple := range tuples {
+		if tuple.Timeline >= 0 {
+			t.Errorf("Prepended event %d got non-negative timeline row ID %d", tuple.Event, tuple.Timeline)
+		}
+	}
+	if got := getTestTimeline(t, db); !slices.Equal(got, []id.EventID{"$old1", "$old2", "$synced1", "$synced2"}) {
+		t.Errorf("Timeline is %v, want [$old1 $old2 $synced1 $synced2]", got)
+	}
+	evts, err := db.Timeline.Get(ctx, testRoomID, 1000, 0)
+	if err != nil {
+		t.Fatalf("Failed to get timeline: %v", err)
+	}
+	for _, evt := range evts {
+		if evt.RowID == synced[0].Event && evt.TimelineRowID != synced[0].Timeline {
+			t.Errorf("Synced event moved from %d to %d", synced[0].Timeline, evt.TimelineRowID)
+		}
+	}
+
+	// Prepending only events that are already there is a no-op rather than an error
+	tuples, err = db.Timeline.Prepend(ctx, testRoomID, []EventRowID{rowIDs[3], rowIDs[2]})
+	if err != nil {
+		t.Fatalf("Failed to prepend existing events: %v", err)
+	} else if len(tuples) != 0 {
+		t.Errorf("Prepending existing events returned %v, want nothing", tuples)
+	}
+}
+
+func TestTimelineQuery_UniquePerEvent(t *testing.T) {
+	ctx := context.Background()
+	db := newTestDB(t)
+	rowIDs := putTestMessages(t, db, "$a")
+	if _, err := db.Timeline.Append(ctx, testRoomID, rowIDs); err != nil {
+		t.Fatalf("Failed to append: %v", err)
+	}
+	_, err := db.Exec(ctx, "INSERT INTO timeline (room_id, event_rowid) VALUES ($1, $2)", testRoomID, rowIDs[0])
+	if err == nil {
+		t.Error("Inserting a duplicate timeline entry without ON CONFLICT succeeded")
+	}
+	var count int
+	err = db.QueryRow(ctx, "SELECT COUNT(*) FROM timeline WHERE event_rowid = $1", rowIDs[0]).Scan(&count)
+	if err != nil {
+		t.Fatalf("Failed to count timeline entries: %v", err)
+	} else if count != 1 {
+		t.Errorf("Event has %d timeline entries, want 1", count)
+	}
+}
+
+// getTestTimelineRowIDs returns the timeline row ID of each event in the test room.
+func getTestTimelineRowIDs(t *testing.T, db *Database) map[id.EventID]TimelineRowID {
+	t.Helper()
+	evts, err := db.Timeline.Get(context.Background(), testRoomID, 1000, 0)
+	if err != nil {
+		t.Fatalf("Failed to get timeline: %v", err)
+	}
+	rowIDs := make(map[id.EventID]TimelineRowID, len(evts))
+	for _, evt := range evts {
+		rowIDs[evt.ID] = evt.TimelineRowID
+	}
+	return rowIDs
+}
+
+// appendTestTimelineWithGap appends the given events to the test timeline with a gap before the
+// event at gapIdx, the same way sync does after a limited sync.
+func appendTestTimelineWithGap(t *testing.T, db *Database, rowIDs []EventRowID, gapIdx int) []TimelineRowTuple {
+	t.Helper()
+	ctx := context.Background()
+	before, err := db.Timeline.Append(ctx, testRoomID, rowIDs[:gapIdx])
+	if err != nil {
+		t.Fatalf("Failed to append: %v", err)
+	}
+	after, err := db.Timeline.AppendAfterGap(ctx, testRoomID, rowIDs[gapIdx:])
+	if err != nil {
+		t.Fatalf("Failed to append after gap: %v", err)
+	}
+	err = db.Timeline.PutGap(ctx, &TimelineGap{RoomID: testRoomID, TimelineRowID: after[0].Timeline, PrevBatch: "gap1", MissedCount: 2})
+	if err != nil {
+		t.Fatalf("Failed to put gap: %v", err)
+	}
+	return append(before, after...)
+}
+
+func TestTimelineQuery_Splice(t *testing.T) {
+	ctx := context.Background()
+	db := newTestDB(t)
+	rowIDs := putTestMessages(t, db, "$a", "$b", "$c", "$d", "$e", "$f")
+	existing := appendTestTimelineWithGap(t, db, []EventRowID{rowIDs[0], rowIDs[1], rowIDs[4], rowIDs[5]}, 2)
+	spliceAt := existing[2].Timeline
+	err := db.Timeline.PutGap(ctx, &TimelineGap{RoomID: testRoomID, TimelineRowID: existing[3].Timeline, PrevBatch: "gap2", MissedCount: 7})
+	if err != nil {
+		t.Fatalf("Failed to put gap: %v", err)
+	}
+
+	tuples, err := db.Timeline.Splice(ctx, testRoomID, spliceAt, []EventRowID{rowIDs[2], rowIDs[3]})
+	if err != nil {
+		t.Fatalf("Splice failed: %v", err)
+	} else if want := rowIDs[2:4]; !slices.Equal(tupleEvents(tuples), want) {
+		t.Errorf("Splice returned %v, want only the spliced events %v", tupleEvents(tuples), want)
+	}
+	if got := getTestTimeline(t, db); !slices.Equal(got, []id.EventID{"$a", "$b", "$c", "$d", "$e", "$f"}) {
+		t.Errorf("Timeline after splice is %v, want [$a $b $c $d $e $f]", got)
+	}
+	after := getTestTimelineRowIDs(t, db)
+	for i, evtID := range []id.EventID{"$a", "$b", "$e", "$f"} {
+		if after[evtID] != existing[i].Timeline {
+			t.Errorf("Existing entry %s was renumbered from %d to %d", evtID, existing[i].Timeline, after[evtID])
+		}
+	}
+	for i, tuple := range tuples {
+		if evtID := []id.EventID{"$c", "$d"}[i]; after[evtID] != tuple.Timeline {
+			t.Errorf("Returned tuple for %s has row ID %d, but the database has %d", evtID, tuple.Timeline, after[evtID])
+		}
+	}
+
+	// The gap that was filled is gone, the gap further down the timeline is untouched
+	gaps, err := db.Timeline.GetGaps(ctx, testRoomID, math.MinInt64, math.MaxInt64)
+	if err != nil {
+		t.Fatalf("Failed to get gaps: %v", err)
+	} else if len(gaps) != 1 {
+		t.Fatalf("Got %d gaps after splice, want 1", len(gaps))
+	} else if gaps[0].PrevBatch != "gap2" || gaps[0].MissedCount != 7 || gaps[0].TimelineRowID != existing[3].Timeline {
+		t.Errorf("Gap before $f was changed: %+v", gaps[0])
+	}
+
+	// Filling the rest of the gap puts the events before the previously spliced ones
+	rowIDs = append(rowIDs, putTestMessages(t, db, "$b2")...)
+	_, err = db.Timeline.Splice(ctx, testRoomID, tuples[0].Timeline, rowIDs[6:])
+	if err != nil {
+		t.Fatalf("Second splice failed: %v", err)
+	}
+	if got := getTestTimeline(t, db); !slices.Equal(got, []id.EventID{"$a", "$b", "$b2", "$c", "$d", "$e", "$f"}) {
+		t.Errorf("Timeline after second splice is %v, want [$a $b $b2 $c $d $e $f]", got)
+	}
+}
+
+func TestTimelineQuery_SpliceWithoutSpace(t *testing.T) {
+	ctx := context.Background()
+	db := newTestDB(t)
+	rowIDs := putTestMessages(t, db, "$a", "$b", "$c", "$d", "$e", "$f")
+	// Gaps created before row IDs were reserved have no space for new entries
+	existing, err := db.Timeline.Append(ctx, testRoomID, []EventRowID{rowIDs[0], rowIDs[1], rowIDs[4], rowIDs[5]})
+	if err != nil {
+		t.Fatalf("Failed to append: %v", err)
+	}
+	spliceAt := existing[2].Timeline
+	err = db.Timeline.PutGap(ctx, &TimelineGap{RoomID: testRoomID, TimelineRowID: spliceAt, PrevBatch: "gap1", MissedCount: 2})
+	if err != nil {
+		t.Fatalf("Failed to put gap: %v", err)
+	}
+	err = db.Timeline.PutGap(ctx, &TimelineGap{RoomID: testRoomID, TimelineRowID: existing[3].Timeline, PrevBatch: "gap2", MissedCount: 7})
+	if err != nil {
+		t.Fatalf("Failed to put gap: %v", err)
+	}
+
+	tuples, err := db.Timeline.Splice(ctx, testRoomID, spliceAt, []EventRowID{rowIDs[2], rowIDs[3]})
+	if err != nil {
+		t.Fatalf("Splice failed: %v", err)
+	} else if want := rowIDs[2:]; !slices.Equal(tupleEvents(tuples), want) {
+		t.Errorf("Splice returned %v, want the spliced events and the tail %v", tupleEvents(tuples), want)
+	}
+	if got := getTestTimeline(t, db); !slices.Equal(got, []id.EventID{"$a", "$b", "$c", "$d", "$e", "$f"}) {
+		t.Errorf("Timeline after splice is %v, want [$a $b $c $d $e $f]", got)
+	}
+	after := getTestTimelineRowIDs(t, db)
+	if after["$a"] != existing[0].Timeline || after["$b"] != existing[1].Timeline {
+		t.Error("Entries before the splice point were renumbered")
+	}
+	for i, tuple := range tuples {
+		if evtID := []id.EventID{"$c", "$d", "$e", "$f"}[i]; after[evtID] != tuple.Timeline {
+			t.Errorf("Returned tuple for %s has row ID %d, but the database has %d", evtID, tuple.Timeline, after[evtID])
+		}
+	}
+	if tuples[0].Timeline-existing[1].Timeline <= GapRowIDSpace {
+		t.Error("Reinserted entries don't have space reserved for the rest of the gap")
+	}
+
+	// The gap that was filled is gone, the gap further down the timeline moved along with its event
+	if gap, err := db.Timeline.GetGap(ctx, testRoomID, spliceAt); err != nil {
+		t.Fatalf("Failed to get gap: %v", err)
+	} else if gap != nil && gap.PrevBatch == "gap1" {
+		t.Error("Gap at the splice point wasn't removed")
+	}
+	gaps, err := db.Timeline.GetGaps(ctx, testRoomID, math.MinInt64, math.MaxInt64)
+	if err != nil {
+		t.Fatalf("Failed to get gaps: %v", err)
+	} else if len(gaps) != 1 {
+		t.Fatalf("Got %d gaps after splice, want 1", len(gaps))
+	} else if gaps[0].PrevBatch != "gap2" || gaps[0].MissedCount != 7 || gaps[0].TimelineRowID != after["$f"] {
+		t.Errorf("Gap before $f wasn't moved correctly: %+v (row ID of $f is %d)", gaps[0], after["$f"])
+	}
+}
+
+func TestTimelineQuery_SpliceOverlap(t *testing.T) {
+	ctx := context.Background()
+	db := newTestDB(t)
+	rowIDs := putTestMessages(t, db, "$a", "$b", "$c", "$d", "$e")
+	existing := appendTestTimelineWithGap(t, db, []EventRowID{rowIDs[0], rowIDs[1], rowIDs[3], rowIDs[4]}, 2)
+	// The filled events overlap both sides of the gap: $b is before the splice point and $d is after it
+	tuples, err := db.Timeline.Splice(ctx, testRoomID, existing[2].Timeline, []EventRowID{rowIDs[1], rowIDs[2], rowIDs[3]})
+	if err != nil {
+		t.Fatalf("Splice failed: %v", err)
+	} else if want := rowIDs[2:3]; !slices.Equal(tupleEvents(tuples), want) {
+		t.Errorf("Splice returned %v, want %v", tupleEvents(tuples), want)
+	}
+	if got := getTestTimeline(t, db); !slices.Equal(got, []id.EventID{"$a", "$b", "$c", "$d", "$e"}) {
+		t.Errorf("Timeline after overlapping splice is %v, want [$a $b $c $d $e]", got)
+	}
+	after := getTestTimelineRowIDs(t, db)
+	if after["$b"] != existing[1].Timeline {
+		t.Errorf("$b moved from %d to %d", existing[1].Timeline, after["$b"])
+	} else if after["$d"] != existing[2].Timeline {
+		t.Errorf("$d moved from %d to %d", existing[2].Timeline, after["$d"])
+	}
+	if after["$c"] != tuples[0].Timeline {
+		t.Errorf("Returned tuple for $c has row ID %d, but the database has %d", tuples[0].Timeline, after["$c"])
+	}
+}
+
+func TestTimelineQuery_SpliceNotFound(t *testing.T) {
+	ctx := context.Background()
+	db := newTestDB(t)
+	rowIDs := putTestMessages(t, db, "$a", "$b")
+	existing, err := db.Timeline.Append(ctx, testRoomID, rowIDs[:1])
+	if err != nil {
+		t.Fatalf("Failed to append: %v", err)
+	}
+	_, err = db.Timeline.Splice(ctx, testRoomID, existing[0].Timeline+1000, rowIDs[1:])
+	if err == nil {
+		t.Error("Splicing after the end of the timeline succeeded")
+	}
+	if got := getTestTimeline(t, db); !slices.Equal(got, []id.EventID{"$a"}) {
+		t.Errorf("Timeline after failed splice is %v, want [$a]", got)
+	}
+}
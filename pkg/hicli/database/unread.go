@@ -53,6 +53,9 @@ const (
 	UnreadTypeNotify    UnreadType = 0b0010
 	UnreadTypeHighlight UnreadType = 0b0100
 	UnreadTypeSound     UnreadType = 0b1000
+	// UnreadTypeReactionToMe marks reactions from other users to the current user's events.
+	// It doesn't affect unread counts, it's only used for the reactions feed.
+	UnreadTypeReactionToMe UnreadType = 0b10000
 )
 
 type UnreadCounts struct {
@@ -61,6 +64,23 @@ type UnreadCounts struct {
 	UnreadMessages      int `json:"unread_messages"`
 }
 
+// ServerUnreadCounts are the notification counts reported by the homeserver in sync.
+// Some servers report stale counts, so the locally calculated UnreadCounts are used by default.
+type ServerUnreadCounts struct {
+	Highlights    int `json:"highlight_count"`
+	Notifications int `json:"notification_count"`
+}
+
+// AsUnreadCounts converts the server counts into the local format. The server doesn't count
+// messages that don't notify, so the notification count is used for messages too.
+func (suc ServerUnreadCounts) AsUnreadCounts() UnreadCounts {
+	return UnreadCounts{
+		UnreadHighlights:    suc.Highlights,
+		UnreadNotifications: suc.Notifications,
+		UnreadMessages:      suc.Notifications,
+	}
+}
+
 func (uc *UnreadCounts) IsZero() bool {
 	return uc.UnreadHighlights == 0 && uc.UnreadNotifications == 0 && uc.UnreadMessages == 0
 }
@@ -1,4 +1,4 @@
--- v0 -> v15 (compatible with v10+): Latest revision
+-- v0 -> v24 (compatible with v17+): Latest revision
 CREATE TABLE account (
 	user_id        TEXT NOT NULL PRIMARY KEY,
 	device_id      TEXT NOT NULL,
@@ -13,6 +13,7 @@ CREATE TABLE room (
 	room_type            TEXT,
 	creation_content     TEXT,
 	tombstone_content    TEXT,
+	retention_content    TEXT,
 
 	name                 TEXT,
 	name_quality         INTEGER NOT NULL DEFAULT 0,
@@ -31,15 +32,19 @@ CREATE TABLE room (
 	unread_highlights    INTEGER NOT NULL DEFAULT 0,
 	unread_notifications INTEGER NOT NULL DEFAULT 0,
 	unread_messages      INTEGER NOT NULL DEFAULT 0,
+	server_unread_highlights    INTEGER NOT NULL DEFAULT 0,
+	server_unread_notifications INTEGER NOT NULL DEFAULT 0,
 	marked_unread        INTEGER NOT NULL DEFAULT false,
 
 	prev_batch           TEXT,
+	left_at              INTEGER,
 
 	CONSTRAINT room_preview_event_fkey FOREIGN KEY (preview_event_rowid) REFERENCES event (rowid) ON DELETE SET NULL
 ) STRICT;
 CREATE INDEX room_type_idx ON room (room_type);
 CREATE INDEX room_sorting_timestamp_idx ON room (sorting_timestamp DESC);
 CREATE INDEX room_preview_idx ON room (preview_event_rowid);
+CREATE INDEX room_left_at_idx ON room (left_at DESC) WHERE left_at IS NOT NULL;
 -- CREATE INDEX room_sorting_timestamp_idx ON room (unread_notifications > 0);
 -- CREATE INDEX room_sorting_timestamp_idx ON room (unread_messages > 0);
 
@@ -102,6 +107,7 @@ CREATE TABLE event (
 	send_error        TEXT,
 
 	reactions         TEXT,
+	reaction_senders  TEXT,
 	last_edit_rowid   INTEGER,
 	unread_type       INTEGER NOT NULL DEFAULT 0,
 
@@ -111,9 +117,10 @@ CREATE TABLE event (
 ) STRICT;
 CREATE INDEX event_room_id_idx ON event (room_id);
 CREATE INDEX event_redacted_by_idx ON event (room_id, redacted_by);
-CREATE INDEX event_relates_to_idx ON event (room_id, relates_to);
+CREATE INDEX event_relates_to_idx ON event (room_id, relates_to, relation_type);
 CREATE INDEX event_megolm_session_id_idx ON event (room_id, megolm_session_id);
 CREATE INDEX event_mention_idx ON event (timestamp DESC) WHERE unread_type > 0;
+CREATE INDEX event_room_mention_idx ON event (room_id, timestamp DESC) WHERE unread_type > 0;
 
 CREATE TRIGGER event_update_redacted_by
 	AFTER INSERT
@@ -213,6 +220,47 @@ BEGIN
 	  AND reactions IS NOT NULL;
 END;
 
+CREATE TRIGGER event_insert_fill_reaction_senders
+	AFTER INSERT
+	ON event
+	WHEN NEW.type = 'm.reaction'
+		AND NEW.relation_type = 'm.annotation'
+		AND NEW.redacted_by IS NULL
+		AND typeof(NEW.content ->> '$."m.relates_to".key') = 'text'
+		AND NEW.content ->> '$."m.relates_to".key' NOT LIKE '%"%'
+BEGIN
+	UPDATE event
+	SET reaction_senders=json_set(
+		reaction_senders,
+		'$.' || json_quote(NEW.content ->> '$."m.relates_to".key'),
+		json_set(
+			coalesce(reaction_senders -> ('$.' || json_quote(NEW.content ->> '$."m.relates_to".key')), '{}'),
+			'$.' || json_quote(NEW.sender),
+			NEW.event_id
+		))
+	WHERE event_id = NEW.relates_to
+	  AND reaction_senders IS NOT NULL;
+END;
+
+CREATE TRIGGER event_redact_fill_reaction_senders
+	AFTER UPDATE
+	ON event
+	WHEN NEW.type = 'm.reaction'
+		AND NEW.relation_type = 'm.annotation'
+		AND NEW.redacted_by IS NOT NULL
+		AND OLD.redacted_by IS NULL
+		AND typeof(NEW.content ->> '$."m.relates_to".key') = 'text'
+		AND NEW.content ->> '$."m.relates_to".key' NOT LIKE '%"%'
+BEGIN
+	UPDATE event
+	SET reaction_senders=json_remove(
+		reaction_senders,
+		'$.' || json_quote(NEW.content ->> '$."m.relates_to".key') || '.' || json_quote(NEW.sender)
+	)
+	WHERE event_id = NEW.relates_to
+	  AND reaction_senders ->> ('$.' || json_quote(NEW.content ->> '$."m.relates_to".key') || '.' || json_quote(NEW.sender)) = NEW.event_id;
+END;
+
 CREATE TABLE media (
 	mxc             TEXT NOT NULL PRIMARY KEY,
 	enc_file        TEXT,
@@ -260,6 +308,18 @@ CREATE TABLE timeline (
 ) STRICT;
 CREATE INDEX timeline_room_id_idx ON timeline (room_id);
 
+CREATE TABLE timeline_gap (
+	room_id        TEXT    NOT NULL,
+	timeline_rowid INTEGER NOT NULL,
+	prev_batch     TEXT    NOT NULL,
+	missed_count   INTEGER NOT NULL DEFAULT 0,
+
+	PRIMARY KEY (room_id, timeline_rowid),
+	CONSTRAINT timeline_gap_room_fkey FOREIGN KEY (room_id) REFERENCES room (room_id) ON DELETE CASCADE,
+	CONSTRAINT timeline_gap_timeline_fkey FOREIGN KEY (timeline_rowid) REFERENCES timeline (rowid) ON DELETE CASCADE
+) STRICT;
+CREATE INDEX timeline_gap_timeline_idx ON timeline_gap (timeline_rowid);
+
 CREATE TABLE current_state (
 	room_id     TEXT    NOT NULL,
 	event_type  TEXT    NOT NULL,
@@ -317,3 +377,44 @@ CREATE TABLE push_registration (
 
 	PRIMARY KEY (device_id)
 ) STRICT;
+
+CREATE TABLE frontend_store (
+	namespace  TEXT    NOT NULL,
+	key        TEXT    NOT NULL,
+	value      TEXT    NOT NULL,
+	updated_at INTEGER NOT NULL,
+
+	PRIMARY KEY (namespace, key)
+) STRICT;
+
+CREATE TABLE policy_subscription (
+	policy_room_id TEXT NOT NULL,
+	-- The room where the rules are enforced, or an empty string to enforce them in all joined rooms
+	target_room_id TEXT NOT NULL,
+	action         TEXT NOT NULL,
+
+	PRIMARY KEY (policy_room_id, target_room_id)
+) STRICT;
+
+CREATE TABLE policy_enforcement (
+	room_id        TEXT    NOT NULL,
+	user_id        TEXT    NOT NULL,
+	action         TEXT    NOT NULL,
+	policy_room_id TEXT    NOT NULL,
+	entity         TEXT    NOT NULL,
+	reason         TEXT    NOT NULL,
+	timestamp      INTEGER NOT NULL,
+
+	PRIMARY KEY (room_id, user_id)
+) STRICT;
+CREATE INDEX policy_enforcement_policy_room_idx ON policy_enforcement (policy_room_id);
+
+CREATE TABLE megolm_session_share (
+	room_id      TEXT    NOT NULL,
+	session_id   TEXT    NOT NULL,
+	user_count   INTEGER NOT NULL,
+	device_count INTEGER NOT NULL,
+	shared_at    INTEGER NOT NULL,
+
+	PRIMARY KEY (room_id, session_id)
+) STRICT;
@@ -0,0 +1,12 @@
+-- v16 (compatible with v10+): Add table for timeline gaps
+CREATE TABLE timeline_gap (
+	room_id        TEXT    NOT NULL,
+	timeline_rowid INTEGER NOT NULL,
+	prev_batch     TEXT    NOT NULL,
+	missed_count   INTEGER NOT NULL DEFAULT 0,
+
+	PRIMARY KEY (room_id, timeline_rowid),
+	CONSTRAINT timeline_gap_room_fkey FOREIGN KEY (room_id) REFERENCES room (room_id) ON DELETE CASCADE,
+	CONSTRAINT timeline_gap_timeline_fkey FOREIGN KEY (timeline_rowid) REFERENCES timeline (rowid) ON DELETE CASCADE
+) STRICT;
+CREATE INDEX timeline_gap_timeline_idx ON timeline_gap (timeline_rowid);
@@ -0,0 +1,3 @@
+-- v17 (compatible with v17+): Keep left rooms as archived instead of deleting them
+ALTER TABLE room ADD COLUMN left_at INTEGER;
+CREATE INDEX room_left_at_idx ON room (left_at DESC) WHERE left_at IS NOT NULL;
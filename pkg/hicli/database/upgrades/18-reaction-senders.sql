@@ -0,0 +1,44 @@
+-- v18 (compatible with v17+): Store who sent each reaction
+-- Existing rows are left as NULL and filled lazily when the events are next loaded.
+ALTER TABLE event ADD COLUMN reaction_senders TEXT;
+
+CREATE TRIGGER event_insert_fill_reaction_senders
+	AFTER INSERT
+	ON event
+	WHEN NEW.type = 'm.reaction'
+		AND NEW.relation_type = 'm.annotation'
+		AND NEW.redacted_by IS NULL
+		AND typeof(NEW.content ->> '$."m.relates_to".key') = 'text'
+		AND NEW.content ->> '$."m.relates_to".key' NOT LIKE '%"%'
+BEGIN
+	UPDATE event
+	SET reaction_senders=json_set(
+		reaction_senders,
+		'$.' || json_quote(NEW.content ->> '$."m.relates_to".key'),
+		json_set(
+			coalesce(reaction_senders -> ('$.' || json_quote(NEW.content ->> '$."m.relates_to".key')), '{}'),
+			'$.' || json_quote(NEW.sender),
+			NEW.event_id
+		))
+	WHERE event_id = NEW.relates_to
+	  AND reaction_senders IS NOT NULL;
+END;
+
+CREATE TRIGGER event_redact_fill_reaction_senders
+	AFTER UPDATE
+	ON event
+	WHEN NEW.type = 'm.reaction'
+		AND NEW.relation_type = 'm.annotation'
+		AND NEW.redacted_by IS NOT NULL
+		AND OLD.redacted_by IS NULL
+		AND typeof(NEW.content ->> '$."m.relates_to".key') = 'text'
+		AND NEW.content ->> '$."m.relates_to".key' NOT LIKE '%"%'
+BEGIN
+	UPDATE event
+	SET reaction_senders=json_remove(
+		reaction_senders,
+		'$.' || json_quote(NEW.content ->> '$."m.relates_to".key') || '.' || json_quote(NEW.sender)
+	)
+	WHERE event_id = NEW.relates_to
+	  AND reaction_senders ->> ('$.' || json_quote(NEW.content ->> '$."m.relates_to".key') || '.' || json_quote(NEW.sender)) = NEW.event_id;
+END;
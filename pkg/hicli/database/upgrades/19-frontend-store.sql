@@ -0,0 +1,9 @@
+-- v19 (compatible with v17+): Add table for frontend key-value storage
+CREATE TABLE frontend_store (
+	namespace  TEXT    NOT NULL,
+	key        TEXT    NOT NULL,
+	value      TEXT    NOT NULL,
+	updated_at INTEGER NOT NULL,
+
+	PRIMARY KEY (namespace, key)
+) STRICT;
@@ -0,0 +1,4 @@
+-- v20 (compatible with v17+): Add indexes for per-room mention and relation queries
+DROP INDEX event_relates_to_idx;
+CREATE INDEX event_relates_to_idx ON event (room_id, relates_to, relation_type);
+CREATE INDEX event_room_mention_idx ON event (room_id, timestamp DESC) WHERE unread_type > 0;
@@ -0,0 +1,3 @@
+-- v21 (compatible with v17+): Store unread counts reported by the server
+ALTER TABLE room ADD COLUMN server_unread_highlights INTEGER NOT NULL DEFAULT 0;
+ALTER TABLE room ADD COLUMN server_unread_notifications INTEGER NOT NULL DEFAULT 0;
@@ -0,0 +1,22 @@
+-- v22 (compatible with v17+): Add tables for policy list subscriptions
+CREATE TABLE policy_subscription (
+	policy_room_id TEXT NOT NULL,
+	-- The room where the rules are enforced, or an empty string to enforce them in all joined rooms
+	target_room_id TEXT NOT NULL,
+	action         TEXT NOT NULL,
+
+	PRIMARY KEY (policy_room_id, target_room_id)
+) STRICT;
+
+CREATE TABLE policy_enforcement (
+	room_id        TEXT    NOT NULL,
+	user_id        TEXT    NOT NULL,
+	action         TEXT    NOT NULL,
+	policy_room_id TEXT    NOT NULL,
+	entity         TEXT    NOT NULL,
+	reason         TEXT    NOT NULL,
+	timestamp      INTEGER NOT NULL,
+
+	PRIMARY KEY (room_id, user_id)
+) STRICT;
+CREATE INDEX policy_enforcement_policy_room_idx ON policy_enforcement (policy_room_id);
@@ -0,0 +1,10 @@
+-- v23 (compatible with v17+): Record how many devices outbound megolm sessions were shared with
+CREATE TABLE megolm_session_share (
+	room_id      TEXT    NOT NULL,
+	session_id   TEXT    NOT NULL,
+	user_count   INTEGER NOT NULL,
+	device_count INTEGER NOT NULL,
+	shared_at    INTEGER NOT NULL,
+
+	PRIMARY KEY (room_id, session_id)
+) STRICT;
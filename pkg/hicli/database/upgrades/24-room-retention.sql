@@ -0,0 +1,9 @@
+-- v24 (compatible with v17+): Store message retention policy in room table
+ALTER TABLE room ADD COLUMN retention_content TEXT;
+UPDATE room SET retention_content=(
+	SELECT content FROM event
+	WHERE rowid=(
+		SELECT event_rowid FROM current_state
+		WHERE current_state.room_id=room.room_id AND event_type='m.room.retention' AND state_key=''
+	)
+);
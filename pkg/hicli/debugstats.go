@@ -0,0 +1,112 @@
+// Copyright (c) 2025 Tulir Asokan
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package hicli
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"runtime"
+	"slices"
+	"sync"
+	"time"
+
+	"go.mau.fi/gomuks/pkg/hicli/jsoncmd"
+)
+
+// syncTimingSamples is the number of most recent sync responses that timing percentiles are calculated from.
+const syncTimingSamples = 512
+
+var ErrDebugStatsDisabled = errors.New("debug stats are not enabled")
+
+// syncTimings is a ring buffer of the time spent processing recent sync responses.
+// All methods are no-ops on a nil pointer, which is used when debug stats are disabled.
+type syncTimings struct {
+	lock    sync.Mutex
+	samples [syncTimingSamples]time.Duration
+	next    int
+	count   int
+}
+
+func (st *syncTimings) record(dur time.Duration) {
+	if st == nil {
+		return
+	}
+	st.lock.Lock()
+	st.samples[st.next] = dur
+	st.next = (st.next + 1) % len(st.samples)
+	st.count = min(st.count+1, len(st.samples))
+	st.lock.Unlock()
+}
+
+func (st *syncTimings) stats() (stats jsoncmd.SyncTimingStats) {
+	if st == nil {
+		return
+	}
+	st.lock.Lock()
+	sorted := slices.Clone(st.samples[:st.count])
+	st.lock.Unlock()
+	if len(sorted) == 0 {
+		return
+	}
+	slices.Sort(sorted)
+	percentile := func(p int) int64 {
+		return sorted[(len(sorted)-1)*p/100].Milliseconds()
+	}
+	return jsoncmd.SyncTimingStats{
+		Count: len(sorted),
+		P50MS: percentile(50),
+		P90MS: percentile(90),
+		P99MS: percentile(99),
+		MaxMS: sorted[len(sorted)-1].Milliseconds(),
+	}
+}
+
+// EnableDebugStats starts collecting stats that are too expensive to always keep track of.
+// It must be called before Start.
+func (h *HiClient) EnableDebugStats() {
+	h.syncTimings = &syncTimings{}
+}
+
+func (h *HiClient) GetDebugStats(ctx context.Context) (*jsoncmd.DebugStats, error) {
+	if h.syncTimings == nil {
+		return nil, ErrDebugStatsDisabled
+	}
+	var mem runtime.MemStats
+	runtime.ReadMemStats(&mem)
+	dbStats := h.DB.RawDB.Stats()
+	stats := &jsoncmd.DebugStats{
+		Goroutines:  runtime.NumGoroutine(),
+		HeapAlloc:   mem.HeapAlloc,
+		HeapObjects: mem.HeapObjects,
+		NumGC:       mem.NumGC,
+		Database: jsoncmd.DatabaseStats{
+			OpenConnections: dbStats.OpenConnections,
+			InUse:           dbStats.InUse,
+			Idle:            dbStats.Idle,
+			WaitCount:       dbStats.WaitCount,
+			WaitDurationMS:  dbStats.WaitDuration.Milliseconds(),
+		},
+		Sync: h.syncTimings.stats(),
+	}
+	var err error
+	stats.DecryptionQueue, err = h.DB.SessionRequest.CountPending(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to count queued sessions: %w", err)
+	}
+	h.sendQueuesLock.Lock()
+	for _, queue := range h.sendQueues {
+		queue.lock.Lock()
+		stats.SendQueue += len(queue.items)
+		queue.lock.Unlock()
+	}
+	h.sendQueuesLock.Unlock()
+	if h.DebugStatsFunc != nil {
+		h.DebugStatsFunc(stats)
+	}
+	return stats, nil
+}
@@ -10,6 +10,7 @@ import (
 	"context"
 	"fmt"
 	"sync"
+	"time"
 
 	"github.com/rs/zerolog"
 	"github.com/tidwall/gjson"
@@ -22,24 +23,6 @@ import (
 	"go.mau.fi/gomuks/pkg/hicli/jsoncmd"
 )
 
-func (h *HiClient) fetchFromKeyBackup(ctx context.Context, roomID id.RoomID, sessionID id.SessionID) (*crypto.InboundGroupSession, error) {
-	data, err := h.Client.GetKeyBackupForRoomAndSession(ctx, h.KeyBackupVersion, roomID, sessionID)
-	if err != nil {
-		return nil, fmt.Errorf("failed to fetch key from server: %w", err)
-	} else if data == nil {
-		return nil, nil
-	}
-	decrypted, err := data.SessionData.Decrypt(h.KeyBackupKey)
-	if err != nil {
-		return nil, fmt.Errorf("failed to decrypt key: %w", err)
-	}
-	sess, err := h.Crypto.ImportRoomKeyFromBackup(ctx, h.KeyBackupVersion, roomID, sessionID, decrypted)
-	if err != nil {
-		return nil, fmt.Errorf("failed to import decrypted key: %w", err)
-	}
-	return sess, nil
-}
-
 func (h *HiClient) handleReceivedMegolmSession(ctx context.Context, roomID id.RoomID, sessionID id.SessionID, firstKnownIndex uint32) {
 	log := zerolog.Ctx(ctx)
 	err := h.DB.SessionRequest.Remove(ctx, sessionID, firstKnownIndex)
@@ -60,6 +43,7 @@ func (h *HiClient) handleReceivedMegolmSession(ctx context.Context, roomID id.Ro
 		return
 	}
 	decrypted := events[:0]
+	var unreadTypeChanged bool
 	for _, evt := range events {
 		if evt.Decrypted != nil {
 			continue
@@ -79,7 +63,9 @@ func (h *HiClient) handleReceivedMegolmSession(ctx context.Context, roomID id.Ro
 			log.Warn().Err(err).Stringer("event_id", evt.ID).Msg("Failed to decrypt event even after receiving megolm session")
 		} else {
 			decrypted = append(decrypted, evt)
+			oldUnreadType := evt.UnreadType
 			h.postDecryptProcess(ctx, nil, evt, mautrixEvt)
+			unreadTypeChanged = unreadTypeChanged || evt.UnreadType != oldUnreadType
 		}
 	}
 	if len(decrypted) > 0 {
@@ -106,10 +92,47 @@ func (h *HiClient) handleReceivedMegolmSession(ctx context.Context, roomID id.Ro
 			log.Err(err).Msg("Failed to save decrypted events")
 		} else {
 			h.EventHandler(&jsoncmd.EventsDecrypted{Events: decrypted, PreviewEventRowID: newPreview, RoomID: roomID})
+			if unreadTypeChanged {
+				h.recalculateUnreadsAfterDecryption(ctx, roomID)
+			}
 		}
 	}
 }
 
+// recalculateUnreadsAfterDecryption updates the unread counts of a room after delayed decryption.
+// Encrypted events can't be evaluated against push rules until they're decrypted,
+// so the counts calculated during sync may be wrong for events that were decrypted later.
+func (h *HiClient) recalculateUnreadsAfterDecryption(ctx context.Context, roomID id.RoomID) {
+	log := zerolog.Ctx(ctx)
+	room, err := h.DB.Room.Get(ctx, roomID)
+	if err != nil {
+		log.Err(err).Msg("Failed to get room to recalculate unread counts")
+		return
+	} else if room == nil {
+		return
+	}
+	counts, err := h.DB.Room.CalculateUnreads(ctx, roomID, h.Account.UserID)
+	if err != nil {
+		log.Err(err).Msg("Failed to recalculate unread counts after decryption")
+		return
+	} else if counts == room.UnreadCounts {
+		return
+	}
+	room.UnreadCounts = counts
+	err = h.DB.Room.Upsert(ctx, room)
+	if err != nil {
+		log.Err(err).Msg("Failed to save recalculated unread counts")
+		return
+	}
+	h.EventHandler(&jsoncmd.SyncComplete{
+		Rooms: map[id.RoomID]*jsoncmd.SyncRoom{
+			roomID: {
+				Meta: room,
+			},
+		},
+	})
+}
+
 func (h *HiClient) WakeupRequestQueue() {
 	select {
 	case h.requestQueueWakeup <- struct{}{}:
@@ -147,50 +170,20 @@ func (h *HiClient) RunRequestQueue(ctx context.Context) {
 func (h *HiClient) requestQueuedSession(ctx context.Context, req *database.SessionRequest, doneFunc func()) {
 	defer doneFunc()
 	log := zerolog.Ctx(ctx)
-	if !req.BackupChecked {
-		sess, err := h.fetchFromKeyBackup(ctx, req.RoomID, req.SessionID)
-		if err != nil {
-			log.Err(err).
-				Stringer("session_id", req.SessionID).
-				Msg("Failed to fetch session from key backup")
-
-			// TODO should this have retries instead of just storing it's checked?
-			req.BackupChecked = true
-			err = h.DB.SessionRequest.Put(ctx, req)
-			if err != nil {
-				log.Err(err).Stringer("session_id", req.SessionID).Msg("Failed to update session request after trying to check backup")
-			}
-		} else if sess == nil || sess.Internal.FirstKnownIndex() > req.MinIndex {
-			req.BackupChecked = true
-			err = h.DB.SessionRequest.Put(ctx, req)
-			if err != nil {
-				log.Err(err).Stringer("session_id", req.SessionID).Msg("Failed to update session request after checking backup")
-			}
-		} else {
-			log.Debug().Stringer("session_id", req.SessionID).
-				Msg("Found session with sufficiently low first known index, removing from queue")
-			err = h.DB.SessionRequest.Remove(ctx, req.SessionID, sess.Internal.FirstKnownIndex())
-			if err != nil {
-				log.Err(err).Stringer("session_id", req.SessionID).Msg("Failed to remove session from request queue")
-			}
-		}
+	err := h.Crypto.SendRoomKeyRequest(ctx, req.RoomID, "", req.SessionID, "", map[id.UserID][]id.DeviceID{
+		h.Account.UserID: {"*"},
+		req.Sender:       {"*"},
+	})
+	if err != nil {
+		log.Err(err).
+			Stringer("session_id", req.SessionID).
+			Msg("Failed to send key request")
 	} else {
-		err := h.Crypto.SendRoomKeyRequest(ctx, req.RoomID, "", req.SessionID, "", map[id.UserID][]id.DeviceID{
-			h.Account.UserID: {"*"},
-			req.Sender:       {"*"},
-		})
-		//var err error
+		log.Debug().Stringer("session_id", req.SessionID).Msg("Sent key request")
+		req.RequestSent = true
+		err = h.DB.SessionRequest.Put(ctx, req)
 		if err != nil {
-			log.Err(err).
-				Stringer("session_id", req.SessionID).
-				Msg("Failed to send key request")
-		} else {
-			log.Debug().Stringer("session_id", req.SessionID).Msg("Sent key request")
-			req.RequestSent = true
-			err = h.DB.SessionRequest.Put(ctx, req)
-			if err != nil {
-				log.Err(err).Stringer("session_id", req.SessionID).Msg("Failed to update session request after sending request")
-			}
+			log.Err(err).Stringer("session_id", req.SessionID).Msg("Failed to update session request after sending request")
 		}
 	}
 }
@@ -204,11 +197,32 @@ func (h *HiClient) RequestQueuedSessions(ctx context.Context) (bool, error) {
 	} else if len(sessions) == 0 {
 		return false, nil
 	}
-	var wg sync.WaitGroup
-	wg.Add(len(sessions))
+	backupChecks := make(map[id.RoomID][]*database.SessionRequest)
+	var keyRequests []*database.SessionRequest
+	var nextBackupCheck time.Duration
 	for _, req := range sessions {
+		if req.BackupChecked {
+			keyRequests = append(keyRequests, req)
+		} else if wait := h.keyBackupWaitTime(req.SessionID); wait > 0 {
+			if nextBackupCheck == 0 || wait < nextBackupCheck {
+				nextBackupCheck = wait
+			}
+		} else {
+			backupChecks[req.RoomID] = append(backupChecks[req.RoomID], req)
+		}
+	}
+	if len(keyRequests) == 0 && len(backupChecks) == 0 {
+		time.AfterFunc(nextBackupCheck, h.WakeupRequestQueue)
+		return false, nil
+	}
+	var wg sync.WaitGroup
+	wg.Add(len(keyRequests))
+	for _, req := range keyRequests {
 		go h.requestQueuedSession(ctx, req, wg.Done)
 	}
+	for roomID, reqs := range backupChecks {
+		h.checkKeyBackup(ctx, roomID, reqs)
+	}
 	wg.Wait()
 
 	return true, err
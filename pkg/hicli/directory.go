@@ -0,0 +1,54 @@
+// Copyright (c) 2025 Tulir Asokan
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package hicli
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+
+	"maunium.net/go/mautrix"
+
+	"go.mau.fi/gomuks/pkg/hicli/jsoncmd"
+)
+
+type reqPublicRoomsFilter struct {
+	GenericSearchTerm string `json:"generic_search_term,omitempty"`
+}
+
+type reqSearchPublicRooms struct {
+	Limit  int                  `json:"limit,omitempty"`
+	Since  string               `json:"since,omitempty"`
+	Filter reqPublicRoomsFilter `json:"filter"`
+}
+
+func (h *HiClient) SearchPublicRooms(ctx context.Context, params *jsoncmd.SearchPublicRoomsParams) (*mautrix.RespPublicRooms, error) {
+	query := map[string]string{}
+	if params.Server != "" {
+		query["server"] = params.Server
+	}
+	var resp mautrix.RespPublicRooms
+	_, err := h.Client.MakeFullRequest(mautrix.WithMaxRetries(ctx, 0), mautrix.FullRequest{
+		Method: http.MethodPost,
+		URL:    h.Client.BuildURLWithQuery(mautrix.ClientURLPath{"v3", "publicRooms"}, query),
+		RequestJSON: &reqSearchPublicRooms{
+			Limit:  params.Limit,
+			Since:  params.Since,
+			Filter: reqPublicRoomsFilter{GenericSearchTerm: params.Filter},
+		},
+		ResponseJSON: &resp,
+	})
+	if err != nil {
+		if params.Server != "" && !errors.Is(err, context.Canceled) {
+			// Errors from remote directories are usually federation failures, so make it clear where the error came from
+			return nil, fmt.Errorf("failed to query room directory of %s: %w", params.Server, err)
+		}
+		return nil, err
+	}
+	return &resp, nil
+}
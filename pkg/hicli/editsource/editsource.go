@@ -0,0 +1,64 @@
+// Copyright (c) 2025 Tulir Asokan
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+// Package editsource converts formatted Matrix messages back into the markdown that gomuks
+// would have sent them from, so they can be edited or copied.
+package editsource
+
+import (
+	"fmt"
+
+	"go.mau.fi/util/ptr"
+	"maunium.net/go/mautrix/event"
+	"maunium.net/go/mautrix/format"
+	"maunium.net/go/mautrix/id"
+)
+
+// HTMLParser is the HTML to markdown converter used for edit sources.
+var HTMLParser = ptr.Clone(format.MarkdownHTMLParser)
+
+func init() {
+	HTMLParser.PillConverter = func(displayname, mxid, eventID string, ctx format.Context) string {
+		switch {
+		case len(mxid) == 0, mxid[0] == '@':
+			return fmt.Sprintf("[%s](%s)", displayname, id.UserID(mxid).URI().MatrixToURL())
+		case len(eventID) > 0:
+			return fmt.Sprintf("[%s](%s)", displayname, id.RoomID(mxid).EventURI(id.EventID(eventID)).MatrixToURL())
+		case mxid[0] == '!' && displayname == mxid:
+			return fmt.Sprintf("[%s](%s)", displayname, id.RoomID(mxid).URI().MatrixToURL())
+		case mxid[0] == '#':
+			return fmt.Sprintf("[%s](%s)", displayname, id.RoomAlias(mxid).URI().MatrixToURL())
+		default:
+			return HTMLParser.LinkConverter(displayname, "https://matrix.to/#/"+mxid, ctx)
+		}
+	}
+	HTMLParser.ImageConverter = func(src, alt, title, width, height string, isEmoji bool) string {
+		if isEmoji {
+			return fmt.Sprintf(`![%s](%s %q)`, alt, src, "Emoji: "+title)
+		} else if title != "" {
+			return fmt.Sprintf(`![%s](%s %q)`, alt, src, title)
+		} else {
+			return fmt.Sprintf(`![%s](%s)`, alt, src)
+		}
+	}
+}
+
+// FromContent returns the markdown source of the given message content, including a /me or
+// /notice prefix for emotes and notices. Plaintext messages return the body as-is.
+func FromContent(content *event.MessageEventContent) string {
+	source := content.Body
+	if content.Format == event.FormatHTML && content.FormattedBody != "" {
+		source, _ = format.HTMLToMarkdownFull(HTMLParser, content.FormattedBody)
+	}
+	switch content.MsgType {
+	case event.MsgEmote:
+		return "/me " + source
+	case event.MsgNotice:
+		return "/notice " + source
+	default:
+		return source
+	}
+}
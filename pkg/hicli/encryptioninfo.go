@@ -0,0 +1,183 @@
+// Copyright (c) 2026 Tulir Asokan
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package hicli
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/rs/zerolog"
+	"github.com/tidwall/gjson"
+	"go.mau.fi/util/jsontime"
+	"go.mau.fi/util/ptr"
+	"maunium.net/go/mautrix/crypto"
+	"maunium.net/go/mautrix/event"
+	"maunium.net/go/mautrix/id"
+
+	"go.mau.fi/gomuks/pkg/hicli/database"
+	"go.mau.fi/gomuks/pkg/hicli/jsoncmd"
+)
+
+// recordGroupSessionShare stores how many devices the current outbound session of the room was shared with,
+// so that it can be shown when debugging why someone couldn't decrypt a message.
+func (h *HiClient) recordGroupSessionShare(ctx context.Context, roomID id.RoomID, users []id.UserID) {
+	log := zerolog.Ctx(ctx)
+	session, err := h.CryptoStore.GetOutboundGroupSession(ctx, roomID)
+	if err != nil {
+		log.Err(err).Msg("Failed to get outbound group session to record share count")
+		return
+	} else if session == nil {
+		return
+	}
+	share := &database.MegolmSessionShare{
+		RoomID:    roomID,
+		SessionID: session.ID(),
+		SharedAt:  jsontime.UM(time.Now()),
+	}
+	for _, userID := range users {
+		devices, err := h.CryptoStore.GetDevices(ctx, userID)
+		if err != nil {
+			log.Err(err).Stringer("user_id", userID).Msg("Failed to get devices to record share count")
+			return
+		}
+		sharedWithUser := false
+		for _, device := range devices {
+			shared, err := h.CryptoStore.IsOutboundGroupSessionShared(ctx, userID, device.IdentityKey, share.SessionID)
+			if err != nil {
+				log.Err(err).Stringer("user_id", userID).Msg("Failed to check if session was shared with device")
+				return
+			} else if shared {
+				share.DeviceCount++
+				sharedWithUser = true
+			}
+		}
+		if sharedWithUser {
+			share.UserCount++
+		}
+	}
+	err = h.DB.MegolmSessionShare.Put(ctx, share)
+	if err != nil {
+		log.Err(err).Msg("Failed to save group session share count")
+	}
+}
+
+func makeProfileDevice(device *id.Device) *jsoncmd.ProfileDevice {
+	return &jsoncmd.ProfileDevice{
+		DeviceID:    device.DeviceID,
+		Name:        device.Name,
+		IdentityKey: device.IdentityKey,
+		SigningKey:  device.SigningKey,
+		Fingerprint: device.Fingerprint(),
+		Trust:       device.Trust,
+	}
+}
+
+// isOwnSession returns true if the megolm session was created by this device.
+func (h *HiClient) isOwnSession(sess *crypto.InboundGroupSession) bool {
+	own := h.Crypto.OwnIdentity()
+	return sess.SenderKey == own.IdentityKey && sess.SigningKey == own.SigningKey && len(sess.ForwardingChains) == 0
+}
+
+func (h *HiClient) GetEventEncryptionInfo(ctx context.Context, params *jsoncmd.GetEventEncryptionInfoParams) (*jsoncmd.EventEncryptionInfo, error) {
+	evt, err := h.DB.Event.GetByID(ctx, params.EventID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get event: %w", err)
+	} else if evt == nil || evt.RoomID != params.RoomID {
+		return nil, fmt.Errorf("event not found")
+	}
+	resp := &jsoncmd.EventEncryptionInfo{DecryptionError: evt.DecryptionError}
+	if evt.Type != event.EventEncrypted.Type {
+		return resp, nil
+	}
+	resp.Encrypted = true
+	resp.Algorithm = id.Algorithm(gjson.GetBytes(evt.Content, "algorithm").Str)
+	resp.SessionID = evt.MegolmSessionID
+	if resp.SessionID == "" {
+		return resp, nil
+	}
+	sess, err := h.CryptoStore.GetGroupSession(ctx, evt.RoomID, resp.SessionID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get megolm session: %w", err)
+	} else if sess == nil {
+		return resp, nil
+	}
+	resp.HasSession = true
+	resp.ReceivedAt = jsontime.UM(sess.ReceivedAt)
+	resp.KeyBackupVersion = sess.KeyBackupVersion
+	resp.Forwarded = len(sess.ForwardingChains) > 1 || (len(sess.ForwardingChains) == 1 && sess.ForwardingChains[0] != sess.SenderKey.String())
+	if h.isOwnSession(sess) {
+		resp.Outbound = true
+		resp.SenderDevice = makeProfileDevice(h.Crypto.OwnIdentity())
+		resp.SenderTrust = ptr.Ptr(id.TrustStateVerified)
+		resp.Share, err = h.DB.MegolmSessionShare.Get(ctx, evt.RoomID, resp.SessionID)
+		if err != nil {
+			return nil, fmt.Errorf("failed to get session share count: %w", err)
+		}
+		return resp, nil
+	}
+	// This mirrors the trust resolution that is done when decrypting events
+	senderKey := sess.SenderKey
+	if resp.Forwarded {
+		senderKey = id.Curve25519(sess.ForwardingChains[len(sess.ForwardingChains)-1])
+	}
+	device, err := h.CryptoStore.FindDeviceByKey(ctx, evt.Sender, senderKey)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get sender device: %w", err)
+	} else if device == nil && resp.Forwarded {
+		resp.SenderTrust = ptr.Ptr(id.TrustStateForwarded)
+	} else if device == nil {
+		resp.SenderTrust = ptr.Ptr(id.TrustStateUnknownDevice)
+	} else {
+		resp.SenderDevice = makeProfileDevice(device)
+		if !resp.Forwarded && device.SigningKey != sess.SigningKey {
+			resp.SenderTrust = ptr.Ptr(id.TrustStateInvalid)
+		} else if trust, err := h.Crypto.ResolveTrustContext(ctx, device); err != nil {
+			return nil, fmt.Errorf("failed to resolve sender device trust: %w", err)
+		} else {
+			resp.SenderTrust = &trust
+		}
+	}
+	return resp, nil
+}
+
+func (h *HiClient) GetSessionDevices(ctx context.Context, params *jsoncmd.GetSessionDevicesParams) (*jsoncmd.SessionDevices, error) {
+	sess, err := h.CryptoStore.GetGroupSession(ctx, params.RoomID, params.SessionID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get megolm session: %w", err)
+	}
+	ownDevice := h.Crypto.OwnIdentity()
+	resp := &jsoncmd.SessionDevices{
+		Outbound: sess != nil && h.isOwnSession(sess),
+		Devices: []*jsoncmd.SessionDevice{{
+			ProfileDevice: makeProfileDevice(ownDevice),
+			HasSession:    sess != nil,
+			Current:       true,
+		}},
+	}
+	if !resp.Outbound {
+		return resp, nil
+	}
+	devices, err := h.CryptoStore.GetDevices(ctx, h.Account.UserID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get own devices: %w", err)
+	}
+	for _, device := range devices {
+		if device.DeviceID == ownDevice.DeviceID {
+			continue
+		}
+		shared, err := h.CryptoStore.IsOutboundGroupSessionShared(ctx, device.UserID, device.IdentityKey, params.SessionID)
+		if err != nil {
+			return nil, fmt.Errorf("failed to check if session was shared with %s: %w", device.DeviceID, err)
+		}
+		resp.Devices = append(resp.Devices, &jsoncmd.SessionDevice{
+			ProfileDevice: makeProfileDevice(device),
+			HasSession:    shared,
+		})
+	}
+	return resp, nil
+}
@@ -0,0 +1,125 @@
+// Copyright (c) 2025 Tulir Asokan
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package hicli
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"go.mau.fi/util/jsontime"
+
+	"go.mau.fi/gomuks/pkg/hicli/database"
+	"go.mau.fi/gomuks/pkg/hicli/jsoncmd"
+)
+
+// MaxFrontendStoreNamespaceSize is the maximum total size of the keys and values in a single namespace
+// of the frontend key-value store.
+const MaxFrontendStoreNamespaceSize = 1024 * 1024
+
+const maxFrontendStoreNameLength = 255
+
+var (
+	ErrStorageQuotaExceeded = errors.New("storage quota exceeded")
+	ErrInvalidStorageKey    = errors.New("invalid storage key")
+)
+
+func validateStorageNamespace(namespace string) error {
+	if namespace == "" || len(namespace) > maxFrontendStoreNameLength {
+		return fmt.Errorf("%w: namespace must be between 1 and %d bytes", ErrInvalidStorageKey, maxFrontendStoreNameLength)
+	}
+	return nil
+}
+
+func validateStorageKey(namespace, key string) error {
+	if err := validateStorageNamespace(namespace); err != nil {
+		return err
+	} else if key == "" || len(key) > maxFrontendStoreNameLength {
+		return fmt.Errorf("%w: key must be between 1 and %d bytes", ErrInvalidStorageKey, maxFrontendStoreNameLength)
+	}
+	return nil
+}
+
+// StorageGet returns a value from the frontend key-value store, or nil if it's not set.
+func (h *HiClient) StorageGet(ctx context.Context, params *jsoncmd.StorageKeyParams) (*database.FrontendStoreEntry, error) {
+	if err := validateStorageKey(params.Namespace, params.Key); err != nil {
+		return nil, err
+	}
+	return h.DB.FrontendStore.Get(ctx, params.Namespace, params.Key)
+}
+
+// StorageList returns all values in a namespace of the frontend key-value store.
+func (h *HiClient) StorageList(ctx context.Context, params *jsoncmd.StorageListParams) ([]*database.FrontendStoreEntry, error) {
+	if err := validateStorageNamespace(params.Namespace); err != nil {
+		return nil, err
+	}
+	return h.DB.FrontendStore.GetNamespace(ctx, params.Namespace)
+}
+
+// StorageSet stores a value in the frontend key-value store. Writes that would make the namespace
+// larger than MaxFrontendStoreNamespaceSize are rejected with ErrStorageQuotaExceeded.
+func (h *HiClient) StorageSet(ctx context.Context, params *jsoncmd.StorageSetParams) error {
+	if err := validateStorageKey(params.Namespace, params.Key); err != nil {
+		return err
+	} else if len(params.Value) == 0 || !json.Valid(params.Value) {
+		return fmt.Errorf("value must be valid JSON")
+	}
+	entry := &database.FrontendStoreEntry{
+		Namespace: params.Namespace,
+		Key:       params.Key,
+		Value:     params.Value,
+		UpdatedAt: jsontime.UM(time.Now()),
+	}
+	// The lock makes the quota check and write atomic for concurrent writes from multiple frontends.
+	// It's also held while broadcasting, so that frontends receive changes in the order they were written.
+	h.frontendStoreLock.Lock()
+	defer h.frontendStoreLock.Unlock()
+	err := h.DB.DoTxn(ctx, nil, func(ctx context.Context) error {
+		size, err := h.DB.FrontendStore.GetNamespaceSize(ctx, params.Namespace, params.Key)
+		if err != nil {
+			return fmt.Errorf("failed to get namespace size: %w", err)
+		} else if size+entry.Size() > MaxFrontendStoreNamespaceSize {
+			return fmt.Errorf("%w: namespace %q would use %d bytes out of %d", ErrStorageQuotaExceeded,
+				params.Namespace, size+entry.Size(), MaxFrontendStoreNamespaceSize)
+		}
+		return h.DB.FrontendStore.Put(ctx, entry)
+	})
+	if err != nil {
+		return err
+	}
+	if params.Broadcast {
+		h.EventHandler(&jsoncmd.StorageChanged{
+			Namespace: params.Namespace,
+			Key:       params.Key,
+			Value:     params.Value,
+		})
+	}
+	return nil
+}
+
+// StorageDelete removes a value from the frontend key-value store.
+func (h *HiClient) StorageDelete(ctx context.Context, params *jsoncmd.StorageKeyParams) error {
+	if err := validateStorageKey(params.Namespace, params.Key); err != nil {
+		return err
+	}
+	h.frontendStoreLock.Lock()
+	defer h.frontendStoreLock.Unlock()
+	err := h.DB.FrontendStore.Delete(ctx, params.Namespace, params.Key)
+	if err != nil {
+		return err
+	}
+	if params.Broadcast {
+		h.EventHandler(&jsoncmd.StorageChanged{
+			Namespace: params.Namespace,
+			Key:       params.Key,
+			Deleted:   true,
+		})
+	}
+	return nil
+}
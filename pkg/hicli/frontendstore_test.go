@@ -0,0 +1,171 @@
+// Copyright (c) 2026 Tulir Asokan
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package hicli
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"testing"
+
+	"go.mau.fi/gomuks/pkg/hicli/jsoncmd"
+)
+
+func storageChangedEvents(events *testEvents) []*jsoncmd.StorageChanged {
+	var out []*jsoncmd.StorageChanged
+	for _, evt := range events.all() {
+		if changed, ok := evt.(*jsoncmd.StorageChanged); ok {
+			out = append(out, changed)
+		}
+	}
+	return out
+}
+
+func TestStorageSet_ConcurrentSameKey(t *testing.T) {
+	ctx := context.Background()
+	h, events := newTestClient(t)
+	const writers = 32
+	var wg sync.WaitGroup
+	errs := make(chan error, writers)
+	for i := range writers {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			errs <- h.StorageSet(ctx, &jsoncmd.StorageSetParams{
+				Namespace: "settings",
+				Key:       "theme",
+				Value:     json.RawMessage(fmt.Sprintf(`{"writer":%d}`, i)),
+				Broadcast: true,
+			})
+		}()
+	}
+	wg.Wait()
+	close(errs)
+	for err := range errs {
+		if err != nil {
+			t.Fatalf("StorageSet failed: %v", err)
+		}
+	}
+	changes := storageChangedEvents(events)
+	if len(changes) != writers {
+		t.Fatalf("Expected %d storage_changed events, got %d", writers, len(changes))
+	}
+	entry, err := h.StorageGet(ctx, &jsoncmd.StorageKeyParams{Namespace: "settings", Key: "theme"})
+	if err != nil {
+		t.Fatalf("StorageGet failed: %v", err)
+	} else if entry == nil {
+		t.Fatal("Value wasn't stored")
+	}
+	// Broadcasts happen while holding the lock, so the last broadcast must match what's in the database.
+	if last := changes[len(changes)-1]; string(last.Value) != string(entry.Value) {
+		t.Errorf("Last broadcast value %s doesn't match stored value %s", last.Value, entry.Value)
+	}
+}
+
+func TestStorageSetDelete_ConcurrentBroadcastOrder(t *testing.T) {
+	ctx := context.Background()
+	h, events := newTestClient(t)
+	var wg sync.WaitGroup
+	for i := range 20 {
+		wg.Add(2)
+		go func() {
+			defer wg.Done()
+			_ = h.StorageSet(ctx, &jsoncmd.StorageSetParams{
+				Namespace: "drafts", Key: "room", Value: json.RawMessage(fmt.Sprintf(`%d`, i)), Broadcast: true,
+			})
+		}()
+		go func() {
+			defer wg.Done()
+			_ = h.StorageDelete(ctx, &jsoncmd.StorageKeyParams{Namespace: "drafts", Key: "room", Broadcast: true})
+		}()
+	}
+	wg.Wait()
+	changes := storageChangedEvents(events)
+	if len(changes) != 40 {
+		t.Fatalf("Expected 40 storage_changed events, got %d", len(changes))
+	}
+	entry, err := h.StorageGet(ctx, &jsoncmd.StorageKeyParams{Namespace: "drafts", Key: "room"})
+	if err != nil {
+		t.Fatalf("StorageGet failed: %v", err)
+	}
+	last := changes[len(changes)-1]
+	if last.Deleted != (entry == nil) {
+		t.Errorf("Last broadcast (deleted: %t) doesn't match database (entry: %v)", last.Deleted, entry)
+	} else if entry != nil && string(last.Value) != string(entry.Value) {
+		t.Errorf("Last broadcast value %s doesn't match stored value %s", last.Value, entry.Value)
+	}
+}
+
+func TestStorageSet_Quota(t *testing.T) {
+	ctx := context.Background()
+	h, events := newTestClient(t)
+	// A JSON string that fills the namespace exactly together with its key
+	bigValue := func(key string, size int) json.RawMessage {
+		return json.RawMessage(`"` + strings.Repeat("a", size-len(key)-2) + `"`)
+	}
+	set := func(namespace, key string, value json.RawMessage) error {
+		return h.StorageSet(ctx, &jsoncmd.StorageSetParams{Namespace: namespace, Key: key, Value: value, Broadcast: true})
+	}
+	if err := set("ns", "big", bigValue("big", MaxFrontendStoreNamespaceSize)); err != nil {
+		t.Fatalf("Write up to the quota failed: %v", err)
+	}
+	if err := set("ns", "small", json.RawMessage(`1`)); !errors.Is(err, ErrStorageQuotaExceeded) {
+		t.Errorf("Expected quota error for a new key, got %v", err)
+	}
+	if err := set("ns", "big", bigValue("big", MaxFrontendStoreNamespaceSize+1)); !errors.Is(err, ErrStorageQuotaExceeded) {
+		t.Errorf("Expected quota error for growing the existing key, got %v", err)
+	}
+	// Rewriting the same key only counts the new size
+	if err := set("ns", "big", bigValue("big", MaxFrontendStoreNamespaceSize-10)); err != nil {
+		t.Errorf("Shrinking the existing key failed: %v", err)
+	}
+	if err := set("ns", "small", json.RawMessage(`1`)); err != nil {
+		t.Errorf("Write after shrinking failed: %v", err)
+	}
+	// Other namespaces have their own quota
+	if err := set("other", "key", json.RawMessage(`1`)); err != nil {
+		t.Errorf("Write to another namespace failed: %v", err)
+	}
+	if changes := storageChangedEvents(events); len(changes) != 4 {
+		t.Errorf("Expected 4 storage_changed events (failed writes shouldn't broadcast), got %d", len(changes))
+	}
+	entry, err := h.StorageGet(ctx, &jsoncmd.StorageKeyParams{Namespace: "ns", Key: "big"})
+	if err != nil || entry == nil {
+		t.Fatalf("StorageGet failed: %v", err)
+	} else if len(entry.Value) != MaxFrontendStoreNamespaceSize-10-len("big") {
+		t.Errorf("Rejected write changed the stored value (size %d)", len(entry.Value))
+	}
+}
+
+func TestStorageSet_Validation(t *testing.T) {
+	ctx := context.Background()
+	h, _ := newTestClient(t)
+	tests := []struct {
+		name    string
+		params  jsoncmd.StorageSetParams
+		wantErr error
+	}{
+		{"empty namespace", jsoncmd.StorageSetParams{Key: "key", Value: json.RawMessage(`1`)}, ErrInvalidStorageKey},
+		{"empty key", jsoncmd.StorageSetParams{Namespace: "ns", Value: json.RawMessage(`1`)}, ErrInvalidStorageKey},
+		{"long key", jsoncmd.StorageSetParams{Namespace: "ns", Key: strings.Repeat("k", 256), Value: json.RawMessage(`1`)}, ErrInvalidStorageKey},
+		{"invalid JSON", jsoncmd.StorageSetParams{Namespace: "ns", Key: "key", Value: json.RawMessage(`{`)}, nil},
+		{"empty value", jsoncmd.StorageSetParams{Namespace: "ns", Key: "key"}, nil},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			err := h.StorageSet(ctx, &test.params)
+			if err == nil {
+				t.Fatal("Expected an error")
+			} else if test.wantErr != nil && !errors.Is(err, test.wantErr) {
+				t.Errorf("Expected %v, got %v", test.wantErr, err)
+			}
+		})
+	}
+}
@@ -9,6 +9,7 @@ package hicli
 
 import (
 	"context"
+	"encoding/json"
 	"errors"
 	"fmt"
 	"net"
@@ -45,6 +46,10 @@ type HiClient struct {
 
 	Initialized bool
 	Verified    bool
+	// The display name of the current device, see loadDeviceName.
+	deviceName atomic.Pointer[string]
+	// SessionProblems contains the results of the startup session checks (see checkSession).
+	SessionProblems []*jsoncmd.SessionProblem
 
 	KeyBackupVersion id.KeyBackupVersion
 	KeyBackupKey     *backup.MegolmBackupKey
@@ -55,18 +60,51 @@ type HiClient struct {
 	lastSync   time.Time
 
 	ToDeviceInSync atomic.Bool
+	// EnforceRetention enables deleting local events that are older than the max_lifetime
+	// in the m.room.retention state of the room. It must be set before the client is started.
+	EnforceRetention bool
 
 	EventHandler func(evt any)
 	LogoutFunc   func(context.Context) error
+	// LogLevelFunc changes the log level of a component (or globally if the component is empty).
+	// If level is empty, the current levels are returned without changing anything.
+	LogLevelFunc   func(component, level string) (*jsoncmd.LogLevels, error)
+	RecentLogsFunc func(limit int) []json.RawMessage
+	// ImageAuthTokenFunc generates a new token for authenticating media requests.
+	ImageAuthTokenFunc func() jsoncmd.ImageAuthToken
+	// SyncFilterChanged is called after the sync filter settings are changed with SetSyncFilter,
+	// so that the new settings can be persisted.
+	SyncFilterChanged func(settings *jsoncmd.SyncFilterSettings)
+	// DebugStatsFunc can add stats from outside hicli to the response of GetDebugStats.
+	DebugStatsFunc func(stats *jsoncmd.DebugStats)
 
 	firstSyncReceived bool
 	syncingID         int
 	syncLock          sync.Mutex
 	stopSync          atomic.Pointer[context.CancelFunc]
+	syncFilter        atomic.Pointer[jsoncmd.SyncFilterSettings]
+	syncTimings       *syncTimings
 	encryptLock       sync.Mutex
 	loginLock         sync.Mutex
 
 	requestQueueWakeup chan struct{}
+	keyBackupLock      sync.Mutex
+	keyBackupState     keyBackupFetchState
+	keyBackupRestored  atomic.Int64
+
+	policyLock        sync.Mutex
+	policyEnforceLock sync.Mutex
+	policySubs        []*database.PolicySubscription
+	policyRules       map[id.RoomID][]*compiledPolicyRule
+	policiesLoaded    bool
+
+	threePIDLock     sync.Mutex
+	threePIDSessions map[string]*threePIDSession
+
+	leaveDryRunsLock sync.Mutex
+	leaveDryRuns     map[string]*leaveDryRun
+
+	autoPresence *AutoPresence
 
 	jsonRequestsLock sync.Mutex
 	jsonRequests     map[int64]context.CancelCauseFunc
@@ -74,8 +112,11 @@ type HiClient struct {
 	paginationInterrupterLock sync.Mutex
 	paginationInterrupter     map[id.RoomID]context.CancelCauseFunc
 
-	sendLock     map[id.RoomID]*sync.Mutex
-	sendLockLock sync.Mutex
+	sendQueues     map[id.RoomID]*sendQueue
+	sendQueuesLock sync.Mutex
+
+	settingsLock      sync.Mutex
+	frontendStoreLock sync.Mutex
 }
 
 var (
@@ -105,7 +146,7 @@ func New(rawDB, cryptoDB *dbutil.Database, log zerolog.Logger, pickleKey []byte,
 		requestQueueWakeup:    make(chan struct{}, 1),
 		jsonRequests:          make(map[int64]context.CancelCauseFunc),
 		paginationInterrupter: make(map[id.RoomID]context.CancelCauseFunc),
-		sendLock:              make(map[id.RoomID]*sync.Mutex),
+		sendQueues:            make(map[id.RoomID]*sendQueue),
 
 		EventHandler: evtHandler,
 	}
@@ -177,12 +218,22 @@ func (h *HiClient) Start(ctx context.Context, userID id.UserID, expectedAccount
 		panic(fmt.Errorf("invalid parameters: different user ID in expected account and user ID"))
 	}
 	err := h.DB.Upgrade(ctx)
-	if err != nil {
-		return fmt.Errorf("failed to upgrade hicli db: %w", err)
+	if err == nil {
+		err = h.CryptoStore.DB.Upgrade(ctx)
+		if err != nil {
+			err = fmt.Errorf("failed to upgrade crypto db: %w", err)
+		}
+	} else {
+		err = fmt.Errorf("failed to upgrade hicli db: %w", err)
 	}
-	err = h.CryptoStore.DB.Upgrade(ctx)
-	if err != nil {
-		return fmt.Errorf("failed to upgrade crypto db: %w", err)
+	if errors.Is(err, dbutil.ErrUnsupportedDatabaseVersion) {
+		zerolog.Ctx(ctx).Err(err).Msg("Database was created by a newer version")
+		h.SessionProblems = []*jsoncmd.SessionProblem{unsupportedDatabaseProblem(err)}
+		h.Initialized = true
+		h.dispatchCurrentState()
+		return nil
+	} else if err != nil {
+		return err
 	}
 	account, err := h.DB.Account.Get(ctx, userID)
 	if err != nil {
@@ -216,6 +267,13 @@ func (h *HiClient) Start(ctx context.Context, userID id.UserID, expectedAccount
 		if err != nil {
 			return fmt.Errorf("failed to load olm machine: %w", err)
 		}
+		h.SessionProblems = h.checkSession(ctx)
+		if hasFatalProblem(h.SessionProblems) {
+			zerolog.Ctx(ctx).Error().Any("problems", h.SessionProblems).Msg("Session checks failed, not starting sync")
+			h.Initialized = true
+			h.dispatchCurrentState()
+			return nil
+		}
 
 		h.Verified, err = h.checkIsCurrentDeviceVerified(ctx)
 		if err != nil {
@@ -275,6 +333,8 @@ func (h *HiClient) Sync() {
 	h.stopSync.Store(&cancel)
 	go h.RunRequestQueue(h.Log.WithContext(ctx))
 	go h.LoadPushRules(h.Log.WithContext(ctx))
+	go h.loadDeviceName(h.Log.WithContext(ctx))
+	go h.RunMaintenance(h.Log.WithContext(ctx))
 	ctx = log.WithContext(ctx)
 	log.Info().Msg("Starting syncing")
 	err := h.Client.SyncWithContext(ctx)
@@ -0,0 +1,123 @@
+// Copyright (c) 2026 Tulir Asokan
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package hicli
+
+import (
+	"context"
+	"encoding/json"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/rs/zerolog"
+	"go.mau.fi/util/dbutil"
+	"go.mau.fi/util/jsontime"
+	"go.mau.fi/util/random"
+	"maunium.net/go/mautrix/event"
+	"maunium.net/go/mautrix/id"
+
+	"go.mau.fi/gomuks/pkg/hicli/database"
+)
+
+const (
+	testUserID   id.UserID   = "@alice:example.com"
+	testDeviceID id.DeviceID = "TESTDEVICE"
+)
+
+// testEvents collects the events a test client dispatches to the frontend.
+type testEvents struct {
+	lock   sync.Mutex
+	events []any
+}
+
+func (te *testEvents) handle(evt any) {
+	te.lock.Lock()
+	te.events = append(te.events, evt)
+	te.lock.Unlock()
+}
+
+func (te *testEvents) all() []any {
+	te.lock.Lock()
+	defer te.lock.Unlock()
+	return append([]any(nil), te.events...)
+}
+
+// newTestClient creates a client with a migrated database in a temporary directory.
+// The client is logged in as testUserID, but it doesn't have a homeserver.
+func newTestClient(t testing.TB) (*HiClient, *testEvents) {
+	t.Helper()
+	rawDB, err := dbutil.NewWithDialect(filepath.Join(t.TempDir(), "hicli.db"), "sqlite3-fk-wal")
+	if err != nil {
+		t.Fatalf("Failed to open database: %v", err)
+	}
+	t.Cleanup(func() {
+		_ = rawDB.Close()
+	})
+	events := &testEvents{}
+	h := New(rawDB, nil, zerolog.Nop(), []byte("meow"), events.handle)
+	if err = h.DB.Upgrade(context.Background()); err != nil {
+		t.Fatalf("Failed to upgrade database: %v", err)
+	}
+	h.Account = &database.Account{UserID: testUserID, DeviceID: testDeviceID}
+	h.Client.UserID = testUserID
+	h.Client.DeviceID = testDeviceID
+	return h, events
+}
+
+// putTestEvent stores an event in the database. If stateKey is non-nil, the event is also
+// set as the current state of the room.
+func putTestEvent(
+	t testing.TB, h *HiClient, roomID id.RoomID, sender id.UserID, evtType event.Type, stateKey *string, content any,
+) *database.Event {
+	t.Helper()
+	ctx := context.Background()
+	if err := h.DB.Room.CreateRow(ctx, roomID); err != nil {
+		t.Fatalf("Failed to create room row: %v", err)
+	}
+	rawContent, err := json.Marshal(content)
+	if err != nil {
+		t.Fatalf("Failed to marshal content: %v", err)
+	}
+	evt := &database.Event{
+		RoomID:    roomID,
+		ID:        id.EventID("$" + random.String(43)),
+		Sender:    sender,
+		Type:      evtType.Type,
+		StateKey:  stateKey,
+		Timestamp: jsontime.UM(time.Now()),
+		Content:   rawContent,
+		Unsigned:  json.RawMessage("{}"),
+	}
+	evt.RowID, err = h.DB.Event.Upsert(ctx, evt)
+	if err != nil {
+		t.Fatalf("Failed to insert event: %v", err)
+	}
+	if stateKey != nil {
+		var membership event.Membership
+		if evtType == event.StateMember {
+			var memberContent event.MemberEventContent
+			_ = json.Unmarshal(rawContent, &memberContent)
+			membership = memberContent.Membership
+		}
+		err = h.DB.CurrentState.Set(ctx, roomID, evtType, *stateKey, evt.RowID, membership)
+		if err != nil {
+			t.Fatalf("Failed to set current state: %v", err)
+		}
+	}
+	return evt
+}
+
+func putTestState(t testing.TB, h *HiClient, roomID id.RoomID, evtType event.Type, stateKey string, content any) *database.Event {
+	t.Helper()
+	return putTestEvent(t, h, roomID, testUserID, evtType, &stateKey, content)
+}
+
+func putTestMember(t testing.TB, h *HiClient, roomID id.RoomID, userID id.UserID, membership event.Membership) {
+	t.Helper()
+	putTestEvent(t, h, roomID, userID, event.StateMember, (*string)(&userID), &event.MemberEventContent{Membership: membership})
+}
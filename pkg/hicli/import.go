@@ -0,0 +1,146 @@
+// Copyright (c) 2025 Tulir Asokan
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package hicli
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"slices"
+
+	"github.com/rs/zerolog"
+	"maunium.net/go/mautrix/event"
+	"maunium.net/go/mautrix/id"
+
+	"go.mau.fi/gomuks/pkg/hicli/database"
+	"go.mau.fi/gomuks/pkg/hicli/jsoncmd"
+)
+
+// RoomExport is the subset of the Element room export JSON format that is used for importing history.
+type RoomExport struct {
+	Messages []*event.Event `json:"messages"`
+}
+
+func (h *HiClient) ImportHistory(ctx context.Context, roomID id.RoomID, data json.RawMessage) (*jsoncmd.ImportHistoryResponse, error) {
+	var export RoomExport
+	err := json.Unmarshal(data, &export)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse export: %w", err)
+	}
+	if roomID == "" {
+		for _, evt := range export.Messages {
+			if evt != nil && evt.RoomID != "" {
+				roomID = evt.RoomID
+				break
+			}
+		}
+		if roomID == "" {
+			return nil, fmt.Errorf("room ID not specified and not found in export")
+		}
+	}
+	log := zerolog.Ctx(ctx).With().
+		Str("action", "import history").
+		Stringer("room_id", roomID).
+		Logger()
+	ctx = log.WithContext(ctx)
+
+	ctx, done, err := h.startPagination(ctx, roomID)
+	if err != nil {
+		return nil, err
+	}
+	defer done()
+
+	room, err := h.DB.Room.Get(ctx, roomID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get room from database: %w", err)
+	} else if room == nil {
+		return nil, fmt.Errorf("not in room %s", roomID)
+	}
+
+	resp := &jsoncmd.ImportHistoryResponse{RoomID: roomID}
+	seen := make(map[id.EventID]struct{}, len(export.Messages))
+	events := make([]*event.Event, 0, len(export.Messages))
+	for _, evt := range export.Messages {
+		if evt == nil || evt.ID == "" || evt.Type.Type == "" {
+			resp.Skipped++
+			continue
+		} else if evt.RoomID == "" {
+			evt.RoomID = roomID
+		} else if evt.RoomID != roomID {
+			resp.Skipped++
+			continue
+		}
+		if _, alreadySeen := seen[evt.ID]; alreadySeen {
+			resp.Skipped++
+			continue
+		}
+		seen[evt.ID] = struct{}{}
+		if existing, err := h.DB.Event.GetByID(ctx, evt.ID); err != nil {
+			return nil, fmt.Errorf("failed to check if event %s exists: %w", evt.ID, err)
+		} else if existing != nil {
+			resp.Skipped++
+			continue
+		}
+		// Imported events must never be matched with local echoes
+		evt.Unsigned.TransactionID = ""
+		events = append(events, evt)
+	}
+	if len(events) == 0 {
+		return resp, nil
+	}
+
+	wakeupSessionRequests := false
+	err = h.DB.DoTxn(ctx, nil, func(ctx context.Context) error {
+		if err = ctx.Err(); err != nil {
+			return err
+		}
+		eventRowIDs := make([]database.EventRowID, len(events))
+		decryptionQueue := make(map[id.SessionID]*database.SessionRequest)
+		for i, evt := range events {
+			dbEvt, err := h.processEvent(ctx, evt, room.LazyLoadSummary, decryptionQueue, false)
+			if err != nil {
+				return err
+			}
+			dbEvt.MarkImported()
+			err = h.DB.Event.UpdateLocalContent(ctx, dbEvt)
+			if err != nil {
+				return fmt.Errorf("failed to mark event %s as imported: %w", evt.ID, err)
+			}
+			eventRowIDs[i] = dbEvt.RowID
+		}
+		wakeupSessionRequests = len(decryptionQueue) > 0
+		for _, entry := range decryptionQueue {
+			err = h.DB.SessionRequest.Put(ctx, entry)
+			if err != nil {
+				return fmt.Errorf("failed to save session request for %s: %w", entry.SessionID, err)
+			}
+		}
+		// Exports are in chronological order, but prepending expects the newest event first
+		slices.Reverse(eventRowIDs)
+		_, err = h.DB.Timeline.Prepend(ctx, room.ID, eventRowIDs)
+		if err != nil {
+			return fmt.Errorf("failed to prepend events to timeline: %w", err)
+		}
+		err = h.DB.Room.SetPrevBatch(ctx, room.ID, database.PrevBatchPaginationComplete)
+		if err != nil {
+			return fmt.Errorf("failed to set prev_batch: %w", err)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	if wakeupSessionRequests {
+		h.WakeupRequestQueue()
+	}
+	resp.Imported = len(events)
+	log.Info().
+		Int("imported", resp.Imported).
+		Int("skipped", resp.Skipped).
+		Msg("Imported room history")
+	return resp, nil
+}
@@ -8,6 +8,7 @@ package hicli
 
 import (
 	"context"
+	"encoding/json"
 	"errors"
 	"fmt"
 	"net/url"
@@ -47,7 +48,7 @@ func (h *HiClient) handleJSONCommand(ctx context.Context, req *JSONCommand) (any
 		})
 	case jsoncmd.ReqSendMessage:
 		return jsoncmd.SendMessage.Run(req.Data, func(params *jsoncmd.SendMessageParams) (*database.Event, error) {
-			return h.SendMessage(ctx, params.RoomID, params.BaseContent, params.Extra, params.Text, params.RelatesTo, params.Mentions, params.URLPreviews)
+			return h.SendMessage(ctx, params.RoomID, params.BaseContent, params.Extra, params.Text, params.RelatesTo, params.Mentions, params.URLPreviews, params.SplitCodeBlocks)
 		})
 	case jsoncmd.ReqSendEvent:
 		return jsoncmd.SendEvent.Run(req.Data, func(params *jsoncmd.SendEventParams) (*database.Event, error) {
@@ -57,6 +58,14 @@ func (h *HiClient) handleJSONCommand(ctx context.Context, req *JSONCommand) (any
 		return jsoncmd.ResendEvent.Run(req.Data, func(params *jsoncmd.ResendEventParams) (*database.Event, error) {
 			return h.Resend(ctx, params.TransactionID)
 		})
+	case jsoncmd.ReqDiscardEvent:
+		return jsoncmd.DiscardEvent.Run(req.Data, func(params *jsoncmd.DiscardEventParams) error {
+			return h.DiscardEvent(ctx, params.TransactionID)
+		})
+	case jsoncmd.ReqFlushSendQueue:
+		return jsoncmd.FlushSendQueue.Run(req.Data, func(params *jsoncmd.FlushSendQueueParams) (int, error) {
+			return h.FlushSendQueue(params.RoomID), nil
+		})
 	case jsoncmd.ReqReportEvent:
 		return jsoncmd.ReportEvent.Run(req.Data, func(params *jsoncmd.ReportEventParams) error {
 			return h.Client.ReportEvent(ctx, params.RoomID, params.EventID, params.Reason)
@@ -135,6 +144,10 @@ func (h *HiClient) handleJSONCommand(ctx context.Context, req *JSONCommand) (any
 		return jsoncmd.GetProfileEncryptionInfo.Run(req.Data, func(params *jsoncmd.GetProfileParams) (*jsoncmd.ProfileEncryptionInfo, error) {
 			return h.GetProfileEncryptionInfo(ctx, params.UserID)
 		})
+	case jsoncmd.ReqGetEventEncryptionInfo:
+		return jsoncmd.GetEventEncryptionInfo.RunCtx(ctx, req.Data, h.GetEventEncryptionInfo)
+	case jsoncmd.ReqGetSessionDevices:
+		return jsoncmd.GetSessionDevices.RunCtx(ctx, req.Data, h.GetSessionDevices)
 	case jsoncmd.ReqGetEvent:
 		return jsoncmd.GetEvent.Run(req.Data, func(params *jsoncmd.GetEventParams) (*database.Event, error) {
 			if params.Unredact {
@@ -150,6 +163,10 @@ func (h *HiClient) handleJSONCommand(ctx context.Context, req *JSONCommand) (any
 		return jsoncmd.GetEventContext.Run(req.Data, func(params *jsoncmd.GetEventContextParams) (*jsoncmd.EventContextResponse, error) {
 			return h.GetEventContext(mautrix.WithMaxRetries(ctx, 0), params.RoomID, params.EventID, params.Limit)
 		})
+	case jsoncmd.ReqGetEventNearTimestamp:
+		return jsoncmd.GetEventNearTimestamp.Run(req.Data, func(params *jsoncmd.GetEventNearTimestampParams) (*mautrix.RespTimestampToEvent, error) {
+			return h.GetEventNearTimestamp(mautrix.WithMaxRetries(ctx, 0), params.RoomID, params.Timestamp.Time, params.Direction)
+		})
 	case jsoncmd.ReqPaginateManual:
 		return jsoncmd.PaginateManual.Run(req.Data, func(params *jsoncmd.PaginateManualParams) (*jsoncmd.ManualPaginationResponse, error) {
 			return h.PaginateManual(mautrix.WithMaxRetries(ctx, 0), params.RoomID, params.ThreadRoot, params.Since, params.Direction, params.Limit)
@@ -158,6 +175,10 @@ func (h *HiClient) handleJSONCommand(ctx context.Context, req *JSONCommand) (any
 		return jsoncmd.GetMentions.Run(req.Data, func(params *jsoncmd.GetMentionsParams) ([]*database.Event, error) {
 			return nonNilArray(h.GetMentions(ctx, params.MaxTimestamp.Time, params.Type, params.Limit, params.RoomID))
 		})
+	case jsoncmd.ReqGetReactionsToMe:
+		return jsoncmd.GetReactionsToMe.Run(req.Data, func(params *jsoncmd.GetReactionsToMeParams) ([]*jsoncmd.ReactionToMe, error) {
+			return nonNilArray(h.GetReactionsToMe(ctx, params.MaxTimestamp.Time, params.Limit))
+		})
 	case jsoncmd.ReqGetRoomState:
 		return jsoncmd.GetRoomState.Run(req.Data, func(params *jsoncmd.GetRoomStateParams) ([]*database.Event, error) {
 			return h.GetRoomState(ctx, params.RoomID, params.IncludeMembers, params.FetchMembers, params.Refetch)
@@ -166,6 +187,10 @@ func (h *HiClient) handleJSONCommand(ctx context.Context, req *JSONCommand) (any
 		return jsoncmd.GetSpecificRoomState.Run(req.Data, func(params *jsoncmd.GetSpecificRoomStateParams) ([]*database.Event, error) {
 			return nonNilArray(h.DB.CurrentState.GetMany(ctx, params.Keys))
 		})
+	case jsoncmd.ReqGetEventsByRowIDs:
+		return jsoncmd.GetEventsByRowIDs.Run(req.Data, func(params *jsoncmd.GetEventsByRowIDsParams) ([]*database.Event, error) {
+			return nonNilArray(h.DB.Event.GetByRowIDs(ctx, params.RowIDs...))
+		})
 	case jsoncmd.ReqGetReceipts:
 		return jsoncmd.GetReceipts.Run(req.Data, func(params *jsoncmd.GetReceiptsParams) (map[id.EventID][]*database.Receipt, error) {
 			return h.GetReceipts(ctx, params.RoomID, params.EventIDs)
@@ -174,6 +199,26 @@ func (h *HiClient) handleJSONCommand(ctx context.Context, req *JSONCommand) (any
 		return jsoncmd.Paginate.Run(req.Data, func(params *jsoncmd.PaginateParams) (*jsoncmd.PaginationResponse, error) {
 			return h.Paginate(ctx, params.RoomID, params.MaxTimelineID, params.Limit, params.Reset)
 		})
+	case jsoncmd.ReqPaginateLineage:
+		return jsoncmd.PaginateLineage.RunCtx(ctx, req.Data, h.PaginateLineage)
+	case jsoncmd.ReqFillGap:
+		return jsoncmd.FillGap.Run(req.Data, func(params *jsoncmd.FillGapParams) (*jsoncmd.FillGapResponse, error) {
+			return h.FillGap(ctx, params.RoomID, params.TimelineRowID, params.Limit)
+		})
+	case jsoncmd.ReqResetRoom:
+		return jsoncmd.ResetRoom.Run(req.Data, func(params *jsoncmd.ResetRoomParams) error {
+			if !params.Confirm {
+				return ErrResetNotConfirmed
+			}
+			return h.ResetRoom(ctx, params.RoomID, params.Limit)
+		})
+	case jsoncmd.ReqResetSync:
+		return jsoncmd.ResetSync.Run(req.Data, func(params *jsoncmd.ResetSyncParams) error {
+			if !params.Confirm {
+				return ErrResetNotConfirmed
+			}
+			return h.ResetSync(ctx)
+		})
 	case jsoncmd.ReqGetRoomSummary:
 		return jsoncmd.GetRoomSummary.Run(req.Data, func(params *jsoncmd.GetRoomSummaryParams) (*mautrix.RespRoomSummary, error) {
 			return h.Client.GetRoomSummary(mautrix.WithMaxRetries(ctx, 2), params.RoomIDOrAlias, params.Via...)
@@ -201,6 +246,8 @@ func (h *HiClient) handleJSONCommand(ctx context.Context, req *JSONCommand) (any
 				Reason: params.Reason,
 			})
 		})
+	case jsoncmd.ReqLeaveRooms:
+		return jsoncmd.LeaveRooms.RunCtx(ctx, req.Data, h.LeaveRooms)
 	case jsoncmd.ReqLeaveRoom:
 		return jsoncmd.LeaveRoom.Run(req.Data, func(params *jsoncmd.LeaveRoomParams) (*mautrix.RespLeaveRoom, error) {
 			resp, err := h.Client.LeaveRoom(mautrix.WithMaxRetries(ctx, 2), params.RoomID, &mautrix.ReqLeave{Reason: params.Reason})
@@ -303,6 +350,9 @@ func (h *HiClient) handleJSONCommand(ctx context.Context, req *JSONCommand) (any
 		})
 	case jsoncmd.ReqRegisterPush:
 		return jsoncmd.RegisterPush.Run(req.Data, func(params *database.PushRegistration) error {
+			if err := params.Validate(); err != nil {
+				return err
+			}
 			return h.DB.PushRegistration.Put(ctx, params)
 		})
 	case jsoncmd.ReqListenToDevice:
@@ -317,6 +367,115 @@ func (h *HiClient) handleJSONCommand(ctx context.Context, req *JSONCommand) (any
 		return jsoncmd.CalculateRoomID.Run(req.Data, func(params *jsoncmd.CalculateRoomIDParams) (id.RoomID, error) {
 			return h.CalculateRoomID(params.Timestamp, params.CreationContent)
 		})
+	case jsoncmd.ReqImportHistory:
+		return jsoncmd.ImportHistory.Run(req.Data, func(params *jsoncmd.ImportHistoryParams) (*jsoncmd.ImportHistoryResponse, error) {
+			return h.ImportHistory(ctx, params.RoomID, params.Export)
+		})
+	case jsoncmd.ReqChangePassword:
+		return jsoncmd.ChangePassword.Run(req.Data, func(params *jsoncmd.ChangePasswordParams) (*jsoncmd.UIAResponse, error) {
+			return h.ChangePassword(ctx, params)
+		})
+	case jsoncmd.ReqCreateAlias:
+		return jsoncmd.CreateAlias.Run(req.Data, func(params *jsoncmd.CreateAliasParams) error {
+			return h.CreateAlias(ctx, params)
+		})
+	case jsoncmd.ReqDeleteAlias:
+		return jsoncmd.DeleteAlias.Run(req.Data, func(params *jsoncmd.DeleteAliasParams) error {
+			return h.DeleteAlias(ctx, params)
+		})
+	case jsoncmd.ReqGetLocalAliases:
+		return jsoncmd.GetLocalAliases.Run(req.Data, func(params *jsoncmd.GetLocalAliasesParams) ([]id.RoomAlias, error) {
+			return h.GetLocalAliases(ctx, params.RoomID)
+		})
+	case jsoncmd.ReqSearchPublicRooms:
+		return jsoncmd.SearchPublicRooms.Run(req.Data, func(params *jsoncmd.SearchPublicRoomsParams) (*mautrix.RespPublicRooms, error) {
+			return h.SearchPublicRooms(ctx, params)
+		})
+	case jsoncmd.ReqSetLogLevel:
+		return jsoncmd.SetLogLevel.Run(req.Data, func(params *jsoncmd.SetLogLevelParams) (*jsoncmd.LogLevels, error) {
+			if h.LogLevelFunc == nil {
+				return nil, errors.New("changing log levels is not supported")
+			}
+			return h.LogLevelFunc(params.Component, params.Level)
+		})
+	case jsoncmd.ReqGetRecentLogs:
+		return jsoncmd.GetRecentLogs.Run(req.Data, func(params *jsoncmd.GetRecentLogsParams) ([]json.RawMessage, error) {
+			if h.RecentLogsFunc == nil {
+				return nil, errors.New("fetching recent logs is not supported")
+			}
+			return h.RecentLogsFunc(params.Limit), nil
+		})
+	case jsoncmd.ReqGetImageAuthToken:
+		return jsoncmd.GetImageAuthToken.Run(req.Data, func() (jsoncmd.ImageAuthToken, error) {
+			if h.ImageAuthTokenFunc == nil {
+				return "", errors.New("image auth tokens are not supported")
+			}
+			return h.ImageAuthTokenFunc(), nil
+		})
+	case jsoncmd.ReqGetLeftRooms:
+		return jsoncmd.GetLeftRooms.RunCtx(ctx, req.Data, h.GetLeftRooms)
+	case jsoncmd.ReqForgetRoom:
+		return jsoncmd.ForgetRoom.Run(req.Data, func(params *jsoncmd.ForgetRoomParams) error {
+			return h.ForgetRoom(ctx, params.RoomID)
+		})
+	case jsoncmd.ReqReportActivity:
+		return jsoncmd.ReportActivity.Run(req.Data, func() error {
+			h.ReportActivity()
+			return nil
+		})
+	case jsoncmd.ReqGetSyncFilter:
+		return jsoncmd.GetSyncFilter.RunCtx(ctx, req.Data, func(ctx context.Context) (*jsoncmd.SyncFilterSettings, error) {
+			return h.GetSyncFilter(), nil
+		})
+	case jsoncmd.ReqSetSyncFilter:
+		return jsoncmd.SetSyncFilter.Run(req.Data, func(params *jsoncmd.SyncFilterSettings) error {
+			return h.SetSyncFilter(params)
+		})
+	case jsoncmd.ReqExportSettings:
+		return jsoncmd.ExportSettings.RunCtx(ctx, req.Data, h.ExportSettings)
+	case jsoncmd.ReqImportSettings:
+		return jsoncmd.ImportSettings.RunCtx(ctx, req.Data, h.ImportSettings)
+	case jsoncmd.ReqStorageGet:
+		return jsoncmd.StorageGet.RunCtx(ctx, req.Data, h.StorageGet)
+	case jsoncmd.ReqStorageSet:
+		return jsoncmd.StorageSet.RunCtx(ctx, req.Data, h.StorageSet)
+	case jsoncmd.ReqStorageDelete:
+		return jsoncmd.StorageDelete.RunCtx(ctx, req.Data, h.StorageDelete)
+	case jsoncmd.ReqStorageList:
+		return jsoncmd.StorageList.RunCtx(ctx, req.Data, h.StorageList)
+	case jsoncmd.ReqExportMembers:
+		return jsoncmd.ExportMembers.RunCtx(ctx, req.Data, h.ExportMembers)
+	case jsoncmd.ReqDeactivateAccount:
+		return jsoncmd.DeactivateAccount.Run(req.Data, func(params *jsoncmd.DeactivateAccountParams) (*jsoncmd.UIAResponse, error) {
+			resp, err := h.DeactivateAccount(ctx, params)
+			if err == nil && resp.Success && h.LogoutFunc != nil {
+				go func() {
+					err := h.LogoutFunc(context.WithoutCancel(ctx))
+					if err != nil {
+						zerolog.Ctx(ctx).Err(err).Msg("Failed to clear data after deactivating account")
+					}
+				}()
+			}
+			return resp, err
+		})
+	case jsoncmd.ReqSetDeviceName:
+		return jsoncmd.SetDeviceName.RunCtx(ctx, req.Data, h.SetDeviceName)
+	case jsoncmd.ReqSubscribePolicyRoom:
+		return jsoncmd.SubscribePolicyRoom.RunCtx(ctx, req.Data, h.SubscribePolicyRoom)
+	case jsoncmd.ReqUnsubscribePolicyRoom:
+		return jsoncmd.UnsubscribePolicyRoom.RunCtx(ctx, req.Data, h.UnsubscribePolicyRoom)
+	case jsoncmd.ReqListPolicyRules:
+		return jsoncmd.ListPolicyRules.RunCtx(ctx, req.Data, h.ListPolicyRules)
+	case jsoncmd.ReqPolicyDryRun:
+		return jsoncmd.PolicyDryRun.RunCtx(ctx, req.Data, h.PolicyDryRun)
+	case jsoncmd.ReqGet3PIDs:
+		return jsoncmd.Get3PIDs.RunCtx(ctx, req.Data, h.Get3PIDs)
+	case jsoncmd.ReqAdd3PIDEmail:
+		return jsoncmd.Add3PIDEmail.RunCtx(ctx, req.Data, h.Add3PIDEmail)
+	case jsoncmd.ReqDelete3PID:
+		return jsoncmd.Delete3PID.RunCtx(ctx, req.Data, h.Delete3PID)
+	case jsoncmd.ReqGetDebugStats:
+		return jsoncmd.GetDebugStats.RunCtx(ctx, req.Data, h.GetDebugStats)
 	default:
 		return nil, fmt.Errorf("unknown command %q", req.Command)
 	}
@@ -22,11 +22,15 @@ type JSONCommand = jsoncmd.Container[json.RawMessage]
 func (h *HiClient) State() *jsoncmd.ClientState {
 	state := &jsoncmd.ClientState{
 		Initialized: h.Initialized,
+		Problems:    h.SessionProblems,
 	}
 	if acc := h.Account; acc != nil {
 		state.IsLoggedIn = true
 		state.UserID = acc.UserID
 		state.DeviceID = acc.DeviceID
+		if name := h.deviceName.Load(); name != nil {
+			state.DeviceName = *name
+		}
 		state.HomeserverURL = acc.HomeserverURL
 		state.IsVerified = h.Verified
 	}
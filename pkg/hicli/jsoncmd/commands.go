@@ -7,6 +7,8 @@
 package jsoncmd
 
 import (
+	"encoding/json"
+
 	"maunium.net/go/mautrix"
 	"maunium.net/go/mautrix/id"
 
@@ -32,6 +34,8 @@ const (
 	ReqSendMessage              Name = "send_message"
 	ReqSendEvent                Name = "send_event"
 	ReqResendEvent              Name = "resend_event"
+	ReqDiscardEvent             Name = "discard_event"
+	ReqFlushSendQueue           Name = "flush_send_queue"
 	ReqReportEvent              Name = "report_event"
 	ReqRedactEvent              Name = "redact_event"
 	ReqSetState                 Name = "set_state"
@@ -45,20 +49,30 @@ const (
 	ReqGetMutualRooms           Name = "get_mutual_rooms"
 	ReqTrackUserDevices         Name = "track_user_devices"
 	ReqGetProfileEncryptionInfo Name = "get_profile_encryption_info"
+	ReqGetEventEncryptionInfo   Name = "get_event_encryption_info"
+	ReqGetSessionDevices        Name = "get_session_devices"
 	ReqGetEvent                 Name = "get_event"
 	ReqGetEventContext          Name = "get_event_context"
+	ReqGetEventNearTimestamp    Name = "get_event_near_timestamp"
 	ReqPaginateManual           Name = "paginate_manual"
 	ReqGetMentions              Name = "get_mentions"
+	ReqGetReactionsToMe         Name = "get_reactions_to_me"
 	ReqGetRelatedEvents         Name = "get_related_events"
 	ReqGetRoomState             Name = "get_room_state"
 	ReqGetSpecificRoomState     Name = "get_specific_room_state"
+	ReqGetEventsByRowIDs        Name = "get_events_by_row_ids"
 	ReqGetReceipts              Name = "get_receipts"
 	ReqPaginate                 Name = "paginate"
+	ReqPaginateLineage          Name = "paginate_lineage"
+	ReqFillGap                  Name = "fill_gap"
+	ReqResetRoom                Name = "reset_room"
+	ReqResetSync                Name = "reset_sync"
 	ReqGetRoomSummary           Name = "get_room_summary"
 	ReqGetSpaceHierarchy        Name = "get_space_hierarchy"
 	ReqJoinRoom                 Name = "join_room"
 	ReqKnockRoom                Name = "knock_room"
 	ReqLeaveRoom                Name = "leave_room"
+	ReqLeaveRooms               Name = "leave_rooms"
 	ReqCreateRoom               Name = "create_room"
 	ReqMuteRoom                 Name = "mute_room"
 	ReqEnsureGroupSessionShared Name = "ensure_group_session_shared"
@@ -76,6 +90,40 @@ const (
 	ReqGetTurnServers           Name = "get_turn_servers"
 	ReqGetMediaConfig           Name = "get_media_config"
 	ReqCalculateRoomID          Name = "calculate_room_id"
+	ReqImportHistory            Name = "import_history"
+	ReqChangePassword           Name = "change_password"
+	ReqDeactivateAccount        Name = "deactivate_account"
+	ReqCreateAlias              Name = "create_alias"
+	ReqDeleteAlias              Name = "delete_alias"
+	ReqGetLocalAliases          Name = "get_local_aliases"
+	ReqSearchPublicRooms        Name = "search_public_rooms"
+	ReqSetLogLevel              Name = "set_log_level"
+	ReqGetRecentLogs            Name = "get_recent_logs"
+	ReqGetLeftRooms             Name = "get_left_rooms"
+	ReqForgetRoom               Name = "forget_room"
+	ReqGetSyncFilter            Name = "get_sync_filter"
+	ReqSetSyncFilter            Name = "set_sync_filter"
+	ReqExportSettings           Name = "export_settings"
+	ReqImportSettings           Name = "import_settings"
+	ReqStorageGet               Name = "storage_get"
+	ReqStorageSet               Name = "storage_set"
+	ReqStorageDelete            Name = "storage_delete"
+	ReqStorageList              Name = "storage_list"
+	ReqExportMembers            Name = "export_members"
+	ReqGetImageAuthToken        Name = "get_image_auth_token"
+	ReqSubscribePolicyRoom      Name = "subscribe_policy_room"
+	ReqUnsubscribePolicyRoom    Name = "unsubscribe_policy_room"
+	ReqListPolicyRules          Name = "list_policy_rules"
+	ReqPolicyDryRun             Name = "policy_dry_run"
+	ReqGet3PIDs                 Name = "get_3pids"
+	ReqAdd3PIDEmail             Name = "add_3pid_email"
+	ReqDelete3PID               Name = "delete_3pid"
+	ReqGetDebugStats            Name = "get_debug_stats"
+	ReqGetStorageStats          Name = "get_storage_stats"
+	ReqCompactStorage           Name = "compact_storage"
+	ReqWipeStorage              Name = "wipe_storage"
+	ReqReportActivity           Name = "report_activity"
+	ReqSetDeviceName            Name = "set_device_name"
 
 	RespError   Name = "error"
 	RespSuccess Name = "response"
@@ -92,6 +140,15 @@ const (
 	EventImageAuthToken  Name = "image_auth_token"
 	EventInitComplete    Name = "init_complete"
 	EventRunID           Name = "run_id"
+	EventOpenURI         Name = "open_uri"
+	EventStorageChanged  Name = "storage_changed"
+	EventPolicyEnforced  Name = "policy_enforced"
+	EventSendCooldown    Name = "send_cooldown"
+	EventSendQueueUpdate Name = "send_queue_update"
+
+	EventLeaveRoomsProgress Name = "leave_rooms_progress"
+
+	EventStorageCompactionProgress Name = "storage_compaction_progress"
 )
 
 // Frontend -> backend request specs
@@ -111,6 +168,12 @@ var (
 	SendEvent = &CommandSpec[*SendEventParams, *database.Event]{Name: ReqSendEvent}
 	// ResendEvent retries sending a previously failed outgoing event.
 	ResendEvent = &CommandSpec[*ResendEventParams, *database.Event]{Name: ReqResendEvent}
+	// DiscardEvent marks a previously failed outgoing event as discarded, so that it's not shown anymore.
+	// The updated event is dispatched as a `send_complete` event with `send_error` set to `discarded`.
+	DiscardEvent = &CommandSpecWithoutResponse[*DiscardEventParams]{Name: ReqDiscardEvent}
+	// FlushSendQueue drops all queued outgoing events in a room except the one currently being sent.
+	// The response is the number of events that were dropped.
+	FlushSendQueue = &CommandSpec[*FlushSendQueueParams, int]{Name: ReqFlushSendQueue}
 	// ReportEvent reports an event to the homeserver.
 	ReportEvent = &CommandSpecWithoutResponse[*ReportEventParams]{Name: ReqReportEvent}
 	// RedactEvent redacts an event in a room.
@@ -140,6 +203,13 @@ var (
 	TrackUserDevices = &CommandSpec[*GetProfileParams, *ProfileEncryptionInfo]{Name: ReqTrackUserDevices}
 	// GetProfileEncryptionInfo returns the device list and trust state information for a user.
 	GetProfileEncryptionInfo = &CommandSpec[*GetProfileParams, *ProfileEncryptionInfo]{Name: ReqGetProfileEncryptionInfo}
+	// GetEventEncryptionInfo returns information about how an event was encrypted, such as the megolm session,
+	// the number of devices the session was shared with (for events sent from this device) and the trust state
+	// of the sender device. This will not call the homeserver.
+	GetEventEncryptionInfo = &CommandSpec[*GetEventEncryptionInfoParams, *EventEncryptionInfo]{Name: ReqGetEventEncryptionInfo}
+	// GetSessionDevices returns which of the current user's devices have a megolm session. The full list is only
+	// known for sessions created by this device, for other sessions only the current device is checked.
+	GetSessionDevices = &CommandSpec[*GetSessionDevicesParams, *SessionDevices]{Name: ReqGetSessionDevices}
 	// GetEvent returns a single event in a room. This uses the database if possible,
 	// but will fetch from the homeserver if the event isn't found locally.
 	GetEvent = &CommandSpec[*GetEventParams, *database.Event]{Name: ReqGetEvent}
@@ -148,6 +218,9 @@ var (
 	// currently no safe way to merge back into the main timeline, so jumping has to be implemented
 	// as a separate view.
 	GetEventContext = &CommandSpec[*GetEventContextParams, *EventContextResponse]{Name: ReqGetEventContext}
+	// GetEventNearTimestamp finds the event closest to a timestamp using the homeserver's timestamp_to_event
+	// endpoint (MSC3030). The returned event ID can be passed to `get_event_context` to jump to a date.
+	GetEventNearTimestamp = &CommandSpec[*GetEventNearTimestampParams, *mautrix.RespTimestampToEvent]{Name: ReqGetEventNearTimestamp}
 	// PaginateManual returns a page of messages from the homeserver using a pagination token.
 	// This is used to paginate after jumping to a specific event using `get_event_context` and
 	// for normal pagination in the thread view.
@@ -156,6 +229,10 @@ var (
 	// The result is sorted by timestamp in descending order. Sorting by timestamp means the sender could
 	// have faked it, but there's no other cross-room event ordering in Matrix.
 	GetMentions = &CommandSpec[*GetMentionsParams, []*database.Event]{Name: ReqGetMentions}
+	// GetReactionsToMe returns recent reactions from other users to the current user's events, along with
+	// the events they're reacting to. This will not call the homeserver. The result is sorted by timestamp
+	// in descending order like in `get_mentions`.
+	GetReactionsToMe = &CommandSpec[*GetReactionsToMeParams, []*ReactionToMe]{Name: ReqGetReactionsToMe}
 	// GetRelatedEvents returns events related to a given event from the database (e.g. reactions,
 	// edits, replies depending on relation type). This will not call the homeserver.
 	GetRelatedEvents = &CommandSpec[*GetRelatedEventsParams, []*database.Event]{Name: ReqGetRelatedEvents}
@@ -164,11 +241,32 @@ var (
 	// GetSpecificRoomState returns the requested individual state events.
 	// The events are only fetched from the database, this will not call the homeserver.
 	GetSpecificRoomState = &CommandSpec[*GetSpecificRoomStateParams, []*database.Event]{Name: ReqGetSpecificRoomState}
+	// GetEventsByRowIDs returns events by their database row IDs, e.g. for loading room list previews.
+	// The events are only fetched from the database, this will not call the homeserver.
+	GetEventsByRowIDs = &CommandSpec[*GetEventsByRowIDsParams, []*database.Event]{Name: ReqGetEventsByRowIDs}
 	// GetReceipts returns read receipts for a set of event IDs. This will not call the homeserver.
 	GetReceipts = &CommandSpec[*GetReceiptsParams, map[id.EventID][]*database.Receipt]{Name: ReqGetReceipts}
 	// Paginate returns older messages in the timeline. This will return locally cached timelines
 	// if available and fetch more from the homeserver if needed.
 	Paginate = &CommandSpec[*PaginateParams, *PaginationResponse]{Name: ReqPaginate}
+	// PaginateLineage returns messages from the rooms that the given room was upgraded from, so that frontends can
+	// continue paginating past the beginning of the room. Each call returns events from one predecessor, and the
+	// next older predecessor is included once there's nothing more to load from the current one. Predecessors that
+	// the user was never in are only loaded if their history is world-readable. The events are not added to the
+	// timeline of either room.
+	PaginateLineage = &CommandSpec[*PaginateLineageParams, *PaginateLineageResponse]{Name: ReqPaginateLineage}
+	// FillGap loads missing events in a timeline gap (created by limited syncs) from the homeserver.
+	// The gap is filled by paginating backwards until an event that is already in the timeline is
+	// encountered. The returned timeline entries replace all entries starting from the gap, as the
+	// existing entries will have new row IDs after the new events are spliced in.
+	FillGap = &CommandSpec[*FillGapParams, *FillGapResponse]{Name: ReqFillGap}
+	// ResetRoom throws away the cached timeline and state of a room and fetches them from the homeserver again.
+	// This is meant for recovering from a corrupted local timeline. After the reset, a `sync_complete` event
+	// with `reset` set is dispatched for the room, so the frontend should rebuild its room cache from that.
+	ResetRoom = &CommandSpecWithoutResponse[*ResetRoomParams]{Name: ReqResetRoom}
+	// ResetSync forgets the sync token and restarts syncing, so the next sync is a fresh initial sync.
+	// Stored events are kept, rooms that already have a timeline will get a gap before the new events.
+	ResetSync = &CommandSpecWithoutResponse[*ResetSyncParams]{Name: ReqResetSync}
 	// GetRoomSummary returns the basic metadata of a room from the homeserver, such as name,
 	// topic, avatar and member count. This should be used for previewing rooms before joining.
 	// For joined rooms, metadata is automatically pushed in the sync payloads.
@@ -183,6 +281,10 @@ var (
 	KnockRoom = &CommandSpec[*JoinRoomParams, *mautrix.RespKnockRoom]{Name: ReqKnockRoom}
 	// LeaveRoom leaves or rejects the invite to the given room.
 	LeaveRoom = &CommandSpec[*LeaveRoomParams, *mautrix.RespLeaveRoom]{Name: ReqLeaveRoom}
+	// LeaveRooms leaves many rooms at once, e.g. all rooms in a space. It must first be called with `dry_run` to get
+	// the list of rooms, then again with the returned `dry_run_id` to leave them. Rooms are left one at a time and
+	// a `leave_rooms_progress` event is emitted after each one.
+	LeaveRooms = &CommandSpec[*LeaveRoomsParams, *LeaveRoomsResponse]{Name: ReqLeaveRooms}
 	// CreateRoom creates a new room.
 	CreateRoom = &CommandSpec[*mautrix.ReqCreateRoom, *mautrix.RespCreateRoom]{Name: ReqCreateRoom}
 	// MuteRoom mutes or unmutes a room by manipulating push rules. It returns the previous mute state.
@@ -233,6 +335,112 @@ var (
 	// only relevant when creating v12+ rooms with the `fi.mau.origin_server_ts` extension that
 	// allows the client to pre-calculate the room ID.
 	CalculateRoomID = &CommandSpec[*CalculateRoomIDParams, id.RoomID]{Name: ReqCalculateRoomID}
+	// ImportHistory imports events from an Element-style room export (`{"messages": [...]}`) into the
+	// local database. Imported events are placed before all existing history in the room, and the
+	// room is marked as fully paginated. Events that already exist locally are skipped.
+	ImportHistory = &CommandSpec[*ImportHistoryParams, *ImportHistoryResponse]{Name: ReqImportHistory}
+	// ChangePassword changes the account password. The old password is used to complete
+	// user-interactive authentication. If the password is wrong or the server requires other
+	// authentication stages, the response will contain the UIA details instead of an error.
+	ChangePassword = &CommandSpec[*ChangePasswordParams, *UIAResponse]{Name: ReqChangePassword}
+	// DeactivateAccount permanently deactivates the account. Authentication works the same way as
+	// in `change_password`. After a successful deactivation, the client is logged out.
+	DeactivateAccount = &CommandSpec[*DeactivateAccountParams, *UIAResponse]{Name: ReqDeactivateAccount}
+	// CreateAlias publishes a new alias for a room in the room directory. Optionally, the alias can
+	// also be added to the room's `m.room.canonical_alias` state event as the main or alternative alias.
+	CreateAlias = &CommandSpecWithoutResponse[*CreateAliasParams]{Name: ReqCreateAlias}
+	// DeleteAlias removes an alias from the room directory. If a room ID is provided, the alias must
+	// point to that room, and it can optionally be removed from the canonical alias event too.
+	DeleteAlias = &CommandSpecWithoutResponse[*DeleteAliasParams]{Name: ReqDeleteAlias}
+	// GetLocalAliases returns the aliases of a room that were published on the user's own homeserver.
+	GetLocalAliases = &CommandSpec[*GetLocalAliasesParams, []id.RoomAlias]{Name: ReqGetLocalAliases}
+	// SearchPublicRooms searches the public room directory of the user's homeserver or a remote server.
+	SearchPublicRooms = &CommandSpec[*SearchPublicRoomsParams, *mautrix.RespPublicRooms]{Name: ReqSearchPublicRooms}
+	// SetLogLevel changes the minimum log level at runtime, either globally or for a single component
+	// (e.g. `sync`, `crypto` or `rpc`). The changes are not persisted. If the level is empty,
+	// the current levels are returned without changes, and the level `reset` removes a component override.
+	SetLogLevel = &CommandSpec[*SetLogLevelParams, *LogLevels]{Name: ReqSetLogLevel}
+	// GetRecentLogs returns the most recent log lines from the in-memory log buffer as raw JSON objects.
+	GetRecentLogs = &CommandSpec[*GetRecentLogsParams, []json.RawMessage]{Name: ReqGetRecentLogs}
+	// GetLeftRooms returns the metadata of archived rooms, i.e. rooms that the user has left,
+	// but which are still stored locally. The rooms are sorted by the time they were left, newest first.
+	GetLeftRooms = &CommandSpecWithoutRequest[[]*database.Room]{Name: ReqGetLeftRooms}
+	// ForgetRoom forgets an archived room on the server and deletes all local data of the room.
+	ForgetRoom = &CommandSpecWithoutResponse[*ForgetRoomParams]{Name: ReqForgetRoom}
+	// GetSyncFilter returns the current sync filter settings.
+	GetSyncFilter = &CommandSpecWithoutRequest[*SyncFilterSettings]{Name: ReqGetSyncFilter}
+	// SetSyncFilter changes the sync filter settings. If syncing is running, it's restarted with the new filter.
+	// Settings that would exclude event types required for core functionality are rejected.
+	SetSyncFilter = &CommandSpecWithoutResponse[*SyncFilterSettings]{Name: ReqSetSyncFilter}
+	// ExportSettings returns the settings backup stored in the `fi.mau.gomuks.settings` account data event.
+	// If there's no backup yet, an empty one is returned.
+	ExportSettings = &CommandSpecWithoutRequest[*SettingsBackup]{Name: ReqExportSettings}
+	// ImportSettings merges the given sections into the settings backup. Sections that were modified
+	// more recently on the server are skipped and listed as conflicts, unless override is set.
+	// Frontends should use this for every write to their exportable settings to keep the backup in sync.
+	ImportSettings = &CommandSpec[*ImportSettingsParams, *ImportSettingsResponse]{Name: ReqImportSettings}
+	// StorageGet returns a single value from the local frontend key-value store, or null if it's not set.
+	StorageGet = &CommandSpec[*StorageKeyParams, *database.FrontendStoreEntry]{Name: ReqStorageGet}
+	// StorageSet stores a JSON value in the local frontend key-value store. Each namespace has a size limit,
+	// and writes that would exceed it are rejected. If broadcast is set, a `storage_changed` event is sent
+	// to all connected frontends.
+	StorageSet = &CommandSpecWithoutResponse[*StorageSetParams]{Name: ReqStorageSet}
+	// StorageDelete removes a value from the local frontend key-value store.
+	StorageDelete = &CommandSpecWithoutResponse[*StorageKeyParams]{Name: ReqStorageDelete}
+	// StorageList returns all values in a namespace of the local frontend key-value store, sorted by key.
+	StorageList = &CommandSpec[*StorageListParams, []*database.FrontendStoreEntry]{Name: ReqStorageList}
+	// ExportMembers exports the member list of a room with power levels and join times as CSV or JSON.
+	// The full member list is fetched first if it hasn't been fetched yet. If a path is given, the output
+	// is written into that file on the backend, which should be used for very large rooms.
+	ExportMembers = &CommandSpec[*ExportMembersParams, *ExportMembersResponse]{Name: ReqExportMembers}
+	// GetImageAuthToken returns a new token for authenticating media requests with the `image_auth` query parameter.
+	// Tokens are also pushed periodically with the `image_auth_token` event, but frontends can use this to refresh
+	// an expired token right away.
+	GetImageAuthToken = &CommandSpecWithoutRequest[ImageAuthToken]{Name: ReqGetImageAuthToken}
+	// SubscribePolicyRoom starts enforcing the ban rules of a policy list room (MSC2313) in a room or
+	// in all joined rooms. Existing members are checked right away, new members are checked as they join.
+	SubscribePolicyRoom = &CommandSpecWithoutResponse[*SubscribePolicyRoomParams]{Name: ReqSubscribePolicyRoom}
+	// UnsubscribePolicyRoom stops enforcing the rules of a policy list room.
+	// Bans and flags that are no longer justified by any other subscription are undone.
+	UnsubscribePolicyRoom = &CommandSpecWithoutResponse[*UnsubscribePolicyRoomParams]{Name: ReqUnsubscribePolicyRoom}
+	// ListPolicyRules returns the policy list subscriptions, the active rules and the enforcements based on them.
+	ListPolicyRules = &CommandSpec[*ListPolicyRulesParams, *ListPolicyRulesResponse]{Name: ReqListPolicyRules}
+	// PolicyDryRun returns the bans, flags and unbans that enforcing the current rules would do, without doing them.
+	PolicyDryRun = &CommandSpec[*PolicyDryRunParams, []*database.PolicyEnforcement]{Name: ReqPolicyDryRun}
+	// Get3PIDs returns the email addresses and phone numbers linked to the account.
+	Get3PIDs = &CommandSpecWithoutRequest[[]*ThreePID]{Name: ReqGet3PIDs}
+	// Add3PIDEmail adds an email address to the account. The first call sends a validation email and
+	// returns `validation_sent`. After the user has clicked the link in the email (or entered the code,
+	// if the server supports it), calling it again with the same address completes the validation and adds
+	// the address using the password for user-interactive authentication. Expected failures like
+	// the address being in use are returned as a status instead of an error.
+	Add3PIDEmail = &CommandSpec[*Add3PIDEmailParams, *Add3PIDResponse]{Name: ReqAdd3PIDEmail}
+	// Delete3PID removes an email address or phone number from the account.
+	Delete3PID = &CommandSpecWithoutResponse[*Delete3PIDParams]{Name: ReqDelete3PID}
+	// GetDebugStats returns runtime and cache stats for diagnosing performance problems.
+	// It's only available if debug stats are enabled in the backend config.
+	GetDebugStats = &CommandSpecWithoutRequest[*DebugStats]{Name: ReqGetDebugStats}
+)
+
+// Wasm-specific frontend -> backend request specs
+var (
+	// GetStorageStats returns the size of the browser storage used by gomuks.
+	GetStorageStats = &CommandSpecWithoutRequest[*StorageStats]{Name: ReqGetStorageStats}
+	// CompactStorage checkpoints the write-ahead log and vacuums the database to return unused space to the
+	// browser, optionally also clearing the media cache. `storage_compaction_progress` events are emitted
+	// while it's running. Returns the storage stats after compaction.
+	CompactStorage = &CommandSpec[*CompactStorageParams, *StorageStats]{Name: ReqCompactStorage}
+	// WipeStorage logs out, closes the database and deletes all stored data, then restarts the client in a
+	// logged out state. The access token is invalidated on a best-effort basis, the local data is deleted
+	// even if the homeserver can't be reached.
+	WipeStorage = &CommandSpecWithoutData{Name: ReqWipeStorage}
+	// ReportActivity tells the backend that the user is active. If automatic presence is enabled in the config,
+	// the presence is set to online and the away timer is reset. Frontends should call this on user input,
+	// debounced to at most about once per minute.
+	ReportActivity = &CommandSpecWithoutData{Name: ReqReportActivity}
+	// SetDeviceName changes the display name of a device of the current user, which is shown in the device lists
+	// of other clients. If the device ID is omitted, the current device is renamed.
+	SetDeviceName = &CommandSpecWithoutResponse[*SetDeviceNameParams]{Name: ReqSetDeviceName}
 )
 
 // Backend -> frontend event specs
@@ -243,6 +451,12 @@ var (
 	SpecTyping          = &EventSpec[*Typing]{Name: EventTyping}
 	SpecSendComplete    = &EventSpec[*SendComplete]{Name: EventSendComplete}
 	SpecClientState     = &EventSpec[*ClientState]{Name: EventClientState}
+	SpecStorageChanged  = &EventSpec[*StorageChanged]{Name: EventStorageChanged}
+	SpecPolicyEnforced  = &EventSpec[*PolicyEnforced]{Name: EventPolicyEnforced}
+	SpecSendCooldown    = &EventSpec[*SendCooldown]{Name: EventSendCooldown}
+	SpecSendQueueUpdate = &EventSpec[*SendQueueUpdate]{Name: EventSendQueueUpdate}
+
+	SpecLeaveRoomsProgress = &EventSpec[*LeaveRoomsProgress]{Name: EventLeaveRoomsProgress}
 )
 
 // Websocket-specific backend -> frontend event specs
@@ -251,3 +465,13 @@ var (
 	SpecInitComplete   = &EventSpec[Empty]{Name: EventInitComplete}
 	SpecRunID          = &EventSpec[*RunData]{Name: EventRunID}
 )
+
+// Desktop-specific backend -> frontend event specs
+var (
+	SpecOpenURI = &EventSpec[*OpenURI]{Name: EventOpenURI}
+)
+
+// Wasm-specific backend -> frontend event specs
+var (
+	SpecStorageCompactionProgress = &EventSpec[*StorageCompactionProgress]{Name: EventStorageCompactionProgress}
+)
@@ -31,6 +31,18 @@ func EventTypeName(evt any) Name {
 		return EventSendComplete
 	case *ClientState:
 		return EventClientState
+	case *OpenURI:
+		return EventOpenURI
+	case *StorageChanged:
+		return EventStorageChanged
+	case *PolicyEnforced:
+		return EventPolicyEnforced
+	case *SendCooldown:
+		return EventSendCooldown
+	case *SendQueueUpdate:
+		return EventSendQueueUpdate
+	case *LeaveRoomsProgress:
+		return EventLeaveRoomsProgress
 	default:
 		panic(fmt.Errorf("unknown event type %T", evt))
 	}
@@ -44,6 +56,9 @@ type SyncRoom struct {
 	Timeline []database.TimelineRowTuple `json:"timeline"`
 	// If true, the frontend should discard the existing timeline cache for this room.
 	Reset bool `json:"reset"`
+	// New gaps in the timeline. A gap means there are missing events right before the timeline
+	// entry with the given row ID, which can be loaded using the `fill_gap` command.
+	Gaps []*database.TimelineGap `json:"gaps,omitempty"`
 	// New state events. This nested map should be deeply merged into the existing state map.
 	State map[event.Type]map[string]database.EventRowID `json:"state"`
 	// New room account data events. Like global account data, only changes are listed,
@@ -64,8 +79,48 @@ type SyncNotification struct {
 	RowID     database.EventRowID `json:"event_rowid"`
 	Sound     bool                `json:"sound"`
 	Highlight bool                `json:"highlight"`
-	Event     *database.Event     `json:"-"`
-	Room      *database.Room      `json:"-"`
+	// Reaction is set for reactions to the current user's events. Clients should only show them if the user has
+	// enabled reaction notifications.
+	Reaction bool            `json:"reaction,omitempty"`
+	Event    *database.Event `json:"-"`
+	Room     *database.Room  `json:"-"`
+}
+
+// OpenURI is emitted by the desktop app when the OS asks it to open a matrix: URI or matrix.to link.
+// Exactly one of the user ID, room ID and room alias fields is set.
+type OpenURI struct {
+	URI       string       `json:"uri"`
+	UserID    id.UserID    `json:"user_id,omitempty"`
+	RoomID    id.RoomID    `json:"room_id,omitempty"`
+	RoomAlias id.RoomAlias `json:"room_alias,omitempty"`
+	EventID   id.EventID   `json:"event_id,omitempty"`
+	Via       []string     `json:"via,omitempty"`
+}
+
+// StorageChanged is emitted when a value in the frontend key-value store is set or deleted with broadcast enabled.
+type StorageChanged struct {
+	Namespace string `json:"namespace"`
+	Key       string `json:"key"`
+	// The new value. This is not set if the value was deleted.
+	Value json.RawMessage `json:"value,omitempty"`
+	// True if the value was deleted rather than set.
+	Deleted bool `json:"deleted,omitempty"`
+}
+
+// StorageCompactionProgress is emitted by wasmuks before each step of the `compact_storage` command.
+type StorageCompactionProgress struct {
+	// The index of the current step, starting from 1.
+	Step       int    `json:"step"`
+	TotalSteps int    `json:"total_steps"`
+	Message    string `json:"message"`
+}
+
+// PolicyEnforced is emitted when a policy list rule is enforced against a user in a room,
+// or when an earlier enforcement is undone because the rule was removed.
+type PolicyEnforced struct {
+	*database.PolicyEnforcement
+	// Set if banning or unbanning the user failed.
+	Error string `json:"error,omitempty"`
 }
 
 type SyncToDevice struct {
@@ -144,18 +199,82 @@ type Typing struct {
 	event.TypingEventContent
 }
 
+// SendComplete is emitted when an outgoing event has been sent, failed to send or was discarded.
+// Changes to the queue positions of events that haven't been sent yet are emitted as SendQueueUpdate instead.
 type SendComplete struct {
 	Event *database.Event `json:"event"`
 	Error error           `json:"error"`
 }
 
+// SendQueueUpdate is emitted when the positions of events waiting in a room's send queue change.
+// The events haven't been sent yet, so frontends should only update the queue position of their local echoes.
+type SendQueueUpdate struct {
+	RoomID id.RoomID `json:"room_id"`
+	// Positions maps transaction IDs to the number of events ahead of them in the queue.
+	Positions map[string]int `json:"positions"`
+}
+
+// SendCooldown is emitted when sending to a room is rate limited, and again with a zero Until
+// once a send succeeds.
+type SendCooldown struct {
+	RoomID id.RoomID `json:"room_id"`
+	// Until is when sending is expected to be allowed again.
+	Until jsontime.UnixMilli `json:"until,omitempty"`
+	// Count is the number of consecutive rate limit errors.
+	Count int `json:"count,omitempty"`
+}
+
+// LeaveRoomsProgress is emitted after each room is left (or fails to be left) by the `leave_rooms` command,
+// and while waiting for a rate limit to pass.
+type LeaveRoomsProgress struct {
+	DryRunID string    `json:"dry_run_id"`
+	RoomID   id.RoomID `json:"room_id"`
+	// The number of rooms that have been processed so far, including failed ones.
+	Done  int    `json:"done"`
+	Total int    `json:"total"`
+	Error string `json:"error,omitempty"`
+	// Set if the homeserver rate limited the request and leaving will be retried at this time.
+	RetryAt jsontime.UnixMilli `json:"retry_at,omitzero"`
+}
+
 type ClientState struct {
-	Initialized   bool        `json:"is_initialized"`
-	IsLoggedIn    bool        `json:"is_logged_in"`
-	IsVerified    bool        `json:"is_verified"`
-	UserID        id.UserID   `json:"user_id,omitempty"`
-	DeviceID      id.DeviceID `json:"device_id,omitempty"`
-	HomeserverURL string      `json:"homeserver_url,omitempty"`
+	Initialized bool        `json:"is_initialized"`
+	IsLoggedIn  bool        `json:"is_logged_in"`
+	IsVerified  bool        `json:"is_verified"`
+	UserID      id.UserID   `json:"user_id,omitempty"`
+	DeviceID    id.DeviceID `json:"device_id,omitempty"`
+	// The display name of the current device. It's empty until it has been fetched from the homeserver.
+	DeviceName    string `json:"device_name,omitempty"`
+	HomeserverURL string `json:"homeserver_url,omitempty"`
+	// Problems found by the startup session checks. If any of them are fatal, the client won't sync.
+	Problems []*SessionProblem `json:"problems,omitempty"`
+}
+
+type SessionProblemCode string
+
+const (
+	// SessionProblemLoggedOut means the access token is no longer valid, e.g. because the session was logged out
+	// from another client.
+	SessionProblemLoggedOut SessionProblemCode = "logged_out"
+	// SessionProblemDeviceMismatch means the access token belongs to a different user or device than the local data.
+	SessionProblemDeviceMismatch SessionProblemCode = "device_mismatch"
+	// SessionProblemCryptoMismatch means the identity keys in the local crypto store don't match the ones
+	// that the server has for the device.
+	SessionProblemCryptoMismatch SessionProblemCode = "crypto_store_mismatch"
+	// SessionProblemClockSkew means the local clock differs significantly from the homeserver's clock.
+	// This is only a warning and doesn't prevent syncing.
+	SessionProblemClockSkew SessionProblemCode = "clock_skew"
+	// SessionProblemUnsupportedDatabase means the local database was created by a newer incompatible version.
+	SessionProblemUnsupportedDatabase SessionProblemCode = "unsupported_database"
+)
+
+type SessionProblem struct {
+	Code SessionProblemCode `json:"code"`
+	// A human-readable description of the problem and how to fix it.
+	Message string `json:"message"`
+	Fatal   bool   `json:"fatal"`
+	// Technical details of the problem, such as the underlying error.
+	Details string `json:"details,omitempty"`
 }
 
 type ImageAuthToken string
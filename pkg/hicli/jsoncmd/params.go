@@ -39,8 +39,13 @@ type SendMessageParams struct {
 	RelatesTo *event.RelatesTo `json:"relates_to,omitempty"`
 	// Standard Matrix `m.mentions` data.
 	Mentions *event.Mentions `json:"mentions,omitempty"`
-	// Beeper URL previews to attach to the message.
-	URLPreviews []*event.BeeperLinkPreview `json:"url_previews,omitempty"`
+	// Beeper URL previews to attach to the message. An empty array (as opposed to null) tells other clients
+	// not to generate previews for the links in the message.
+	URLPreviews []*event.BeeperLinkPreview `json:"url_previews"`
+	// Messages that are too large for a single event are split into multiple events at paragraph boundaries.
+	// If a single code block is too large, the send fails with FI.MAU.GOMUKS.MESSAGE_TOO_LARGE unless this
+	// is set, in which case the code block is split too.
+	SplitCodeBlocks bool `json:"split_code_blocks,omitempty"`
 }
 
 type SendEventParams struct {
@@ -55,6 +60,14 @@ type ResendEventParams struct {
 	TransactionID string `json:"transaction_id"`
 }
 
+type DiscardEventParams struct {
+	TransactionID string `json:"transaction_id"`
+}
+
+type FlushSendQueueParams struct {
+	RoomID id.RoomID `json:"room_id"`
+}
+
 type ReportEventParams struct {
 	RoomID  id.RoomID  `json:"room_id"`
 	EventID id.EventID `json:"event_id"`
@@ -122,12 +135,30 @@ type GetEventParams struct {
 	Unredact bool       `json:"unredact"`
 }
 
+type GetEventEncryptionInfoParams struct {
+	RoomID  id.RoomID  `json:"room_id"`
+	EventID id.EventID `json:"event_id"`
+}
+
+type GetSessionDevicesParams struct {
+	RoomID    id.RoomID    `json:"room_id"`
+	SessionID id.SessionID `json:"session_id"`
+}
+
 type GetEventContextParams struct {
 	RoomID  id.RoomID  `json:"room_id"`
 	EventID id.EventID `json:"event_id"`
 	Limit   int        `json:"limit"`
 }
 
+type GetEventNearTimestampParams struct {
+	RoomID    id.RoomID          `json:"room_id"`
+	Timestamp jsontime.UnixMilli `json:"ts"`
+	// The direction to search in. Forward finds the first event at or after the timestamp,
+	// backward finds the last event at or before it.
+	Direction mautrix.Direction `json:"direction"`
+}
+
 type GetMentionsParams struct {
 	// The maximum event timestamp to return. For the first query, this should be set to the current timestamp.
 	MaxTimestamp jsontime.UnixMilli `json:"max_timestamp"`
@@ -139,6 +170,13 @@ type GetMentionsParams struct {
 	RoomID id.RoomID `json:"room_id,omitempty"`
 }
 
+type GetReactionsToMeParams struct {
+	// The maximum event timestamp to return. For the first query, this should be set to the current timestamp.
+	MaxTimestamp jsontime.UnixMilli `json:"max_timestamp"`
+	// Maximum number of reactions to return.
+	Limit int `json:"limit"`
+}
+
 type GetRelatedEventsParams struct {
 	RoomID  id.RoomID  `json:"room_id"`
 	EventID id.EventID `json:"event_id"`
@@ -162,6 +200,10 @@ type GetSpecificRoomStateParams struct {
 	Keys []database.RoomStateGUID `json:"keys"`
 }
 
+type GetEventsByRowIDsParams struct {
+	RowIDs []database.EventRowID `json:"row_ids"`
+}
+
 type EnsureGroupSessionSharedParams struct {
 	RoomID id.RoomID `json:"room_id"`
 }
@@ -210,6 +252,40 @@ type PaginateParams struct {
 	Reset bool `json:"reset,omitempty"`
 }
 
+type PaginateLineageParams struct {
+	// The room whose predecessors are being loaded.
+	RoomID id.RoomID `json:"room_id"`
+	// The predecessor to load messages from. If empty, the direct predecessor of the room is used.
+	PredecessorID id.RoomID `json:"predecessor_id,omitempty"`
+	// The oldest timeline row ID already loaded from the predecessor if it's stored locally.
+	MaxTimelineID database.TimelineRowID `json:"max_timeline_id,omitempty"`
+	// The `next_batch` token from the previous response if the predecessor isn't stored locally.
+	Since string `json:"since,omitempty"`
+	// Maximum number of messages to return.
+	Limit int `json:"limit"`
+}
+
+type FillGapParams struct {
+	RoomID id.RoomID `json:"room_id"`
+	// The timeline row ID that the gap is located before.
+	TimelineRowID database.TimelineRowID `json:"timeline_rowid"`
+	// Maximum number of events to fetch from the server.
+	Limit int `json:"limit"`
+}
+
+type ResetRoomParams struct {
+	RoomID id.RoomID `json:"room_id"`
+	// Maximum number of recent messages to fetch from the server after the reset.
+	Limit int `json:"limit,omitempty"`
+	// Must be set to true, as the reset deletes the local timeline of the room.
+	Confirm bool `json:"confirm"`
+}
+
+type ResetSyncParams struct {
+	// Must be set to true, as the reset causes a full initial sync.
+	Confirm bool `json:"confirm"`
+}
+
 type PaginateManualParams struct {
 	RoomID id.RoomID `json:"room_id"`
 	// Root event ID for thread pagination. Omit for non-thread pagination.
@@ -249,6 +325,25 @@ type LeaveRoomParams struct {
 	Reason string    `json:"reason"`
 }
 
+// LeaveRoomsParams are the parameters for leaving rooms in bulk. Exactly one of SpaceID, ServerName and RoomIDs
+// must be set in dry runs. Filters can't be used for the actual run, which must refer to an earlier dry run instead.
+type LeaveRoomsParams struct {
+	// Leave all joined rooms under this space, including subspaces and the space itself.
+	SpaceID id.RoomID `json:"space_id,omitempty"`
+	// Leave all joined rooms where every other joined member is on this server.
+	ServerName string `json:"server_name,omitempty"`
+	// Leave the given rooms. Rooms that the user isn't joined to are ignored.
+	RoomIDs []id.RoomID `json:"room_ids,omitempty"`
+
+	// If true, the rooms aren't left, only listed along with a dry run ID.
+	DryRun bool `json:"dry_run,omitempty"`
+	// The ID returned by the dry run. Required when not doing a dry run.
+	DryRunID string `json:"dry_run_id,omitempty"`
+	// Rooms from the dry run that should be kept after all.
+	ExcludeRoomIDs []id.RoomID `json:"exclude_room_ids,omitempty"`
+	Reason         string      `json:"reason,omitempty"`
+}
+
 type GetReceiptsParams struct {
 	RoomID   id.RoomID    `json:"room_id"`
 	EventIDs []id.EventID `json:"event_ids"`
@@ -267,3 +362,231 @@ type CalculateRoomIDParams struct {
 	Timestamp       int64           `json:"timestamp"`
 	CreationContent json.RawMessage `json:"content"`
 }
+
+type ImportHistoryParams struct {
+	// The room to import events into. If omitted, the room ID of the events in the export is used.
+	RoomID id.RoomID `json:"room_id,omitempty"`
+	// The export file contents. Only the `messages` field is used.
+	Export json.RawMessage `json:"export"`
+}
+
+type ChangePasswordParams struct {
+	OldPassword string `json:"old_password"`
+	NewPassword string `json:"new_password"`
+	// If true, all other sessions will be logged out.
+	LogoutDevices bool `json:"logout_devices"`
+}
+
+type SetDeviceNameParams struct {
+	// The device to rename. Defaults to the current device.
+	DeviceID id.DeviceID `json:"device_id,omitempty"`
+	Name     string      `json:"name"`
+}
+
+type DeactivateAccountParams struct {
+	Password string `json:"password"`
+	// If true, the server is asked to forget all messages sent by the user.
+	Erase bool `json:"erase"`
+}
+
+type CanonicalAliasMode string
+
+const (
+	CanonicalAliasNone CanonicalAliasMode = ""
+	CanonicalAliasMain CanonicalAliasMode = "main"
+	CanonicalAliasAlt  CanonicalAliasMode = "alt"
+)
+
+type CreateAliasParams struct {
+	RoomID id.RoomID    `json:"room_id"`
+	Alias  id.RoomAlias `json:"alias"`
+	// How the alias should be added to the canonical alias event. Empty means the event isn't changed.
+	Canonical CanonicalAliasMode `json:"canonical,omitempty"`
+}
+
+type DeleteAliasParams struct {
+	RoomID id.RoomID    `json:"room_id,omitempty"`
+	Alias  id.RoomAlias `json:"alias"`
+	// If true, the alias is also removed from the canonical alias event of the room.
+	UpdateCanonical bool `json:"update_canonical,omitempty"`
+}
+
+type GetLocalAliasesParams struct {
+	RoomID id.RoomID `json:"room_id"`
+}
+
+type SearchPublicRoomsParams struct {
+	// The server whose room directory to search. Defaults to the user's own homeserver.
+	Server string `json:"server,omitempty"`
+	// A search term to filter rooms by.
+	Filter string `json:"filter,omitempty"`
+	// A pagination token from a previous request.
+	Since string `json:"since,omitempty"`
+	Limit int    `json:"limit,omitempty"`
+}
+
+type SetLogLevelParams struct {
+	// The component to change the level of. If empty, the global level is changed.
+	Component string `json:"component,omitempty"`
+	Level     string `json:"level"`
+}
+
+type GetRecentLogsParams struct {
+	// The maximum number of log lines to return. If zero, all buffered lines are returned.
+	Limit int `json:"limit,omitempty"`
+}
+
+type ForgetRoomParams struct {
+	RoomID id.RoomID `json:"room_id"`
+}
+
+// SyncFilterSettings contains the user-configurable parts of the filter used for /sync.
+type SyncFilterSettings struct {
+	// The maximum number of timeline events to return per room in each sync. If zero, the default of 100 is used.
+	TimelineLimit int `json:"timeline_limit,omitempty" yaml:"timeline_limit"`
+	// If true, the server sends the full member list of every room instead of only the members relevant
+	// to the events in the sync. This makes syncs much heavier in large rooms. Note that this doesn't affect
+	// the has_member_list flag of rooms: the member list is only considered complete after it has been fetched
+	// explicitly, because limited timelines and incremental syncs don't guarantee that all members are included.
+	DisableLazyLoadMembers bool `json:"disable_lazy_load_members,omitempty" yaml:"disable_lazy_load_members"`
+	// Event types that the server should leave out of the sync timeline, e.g. m.reaction for very large accounts.
+	// Events that are excluded this way will only be seen when they're fetched on demand.
+	ExcludeEventTypes []string `json:"exclude_event_types,omitempty" yaml:"exclude_event_types"`
+}
+
+// SettingsBackup is the content of the `fi.mau.gomuks.settings` account data event,
+// which stores the exportable settings of each frontend so they can be restored on other devices.
+type SettingsBackup struct {
+	// The version of the backup format. Backups written by newer versions keep their version number.
+	Version int `json:"version"`
+	// Settings sections keyed by frontend, e.g. `web` or `tui`.
+	Sections map[string]*SettingsSection `json:"sections"`
+}
+
+type SettingsSection struct {
+	// The time when the section was last changed on the device that wrote it.
+	ModifiedAt jsontime.UnixMilli `json:"modified_at"`
+	// The setting values. Keys that a frontend doesn't recognize must be left as-is.
+	Values map[string]json.RawMessage `json:"values"`
+}
+
+type ImportSettingsParams struct {
+	// The sections to import. Values set to null are removed from the stored section.
+	Settings *SettingsBackup `json:"settings"`
+	// If true, the given sections are imported even if the stored ones were modified more recently.
+	Override bool `json:"override,omitempty"`
+}
+
+type StorageKeyParams struct {
+	Namespace string `json:"namespace"`
+	Key       string `json:"key"`
+	// If true, a `storage_changed` event is sent to all connected frontends after deleting.
+	// This is ignored for storage_get.
+	Broadcast bool `json:"broadcast,omitempty"`
+}
+
+type StorageSetParams struct {
+	Namespace string          `json:"namespace"`
+	Key       string          `json:"key"`
+	Value     json.RawMessage `json:"value"`
+	// If true, a `storage_changed` event is sent to all connected frontends after the value is stored.
+	Broadcast bool `json:"broadcast,omitempty"`
+}
+
+type StorageListParams struct {
+	Namespace string `json:"namespace"`
+}
+
+type MemberExportFormat string
+
+const (
+	MemberExportCSV  MemberExportFormat = "csv"
+	MemberExportJSON MemberExportFormat = "json"
+)
+
+type ExportMembersParams struct {
+	RoomID id.RoomID `json:"room_id"`
+	// The output format, either `csv` (default) or `json`.
+	Format MemberExportFormat `json:"format,omitempty"`
+	// If set, only members with these membership states are included.
+	Memberships []event.Membership `json:"memberships,omitempty"`
+	// If set, the output is written into this file on the backend instead of being returned.
+	Path string `json:"path,omitempty"`
+}
+
+type ExportMembersResponse struct {
+	// The number of members that were exported.
+	Count int `json:"count"`
+	// The exported data, if no path was given.
+	Data string `json:"data,omitempty"`
+}
+
+type SubscribePolicyRoomParams struct {
+	PolicyRoomID id.RoomID `json:"policy_room_id"`
+	// The room to enforce the rules in. If empty, the rules are enforced in all joined rooms.
+	TargetRoomID id.RoomID `json:"target_room_id,omitempty"`
+	// What to do with matching users, either `ban` (default) or `flag`.
+	Action database.PolicyAction `json:"action,omitempty"`
+}
+
+type UnsubscribePolicyRoomParams struct {
+	PolicyRoomID id.RoomID `json:"policy_room_id"`
+	TargetRoomID id.RoomID `json:"target_room_id,omitempty"`
+}
+
+type ListPolicyRulesParams struct {
+	// If set, only rules from this policy room are returned.
+	PolicyRoomID id.RoomID `json:"policy_room_id,omitempty"`
+}
+
+type PolicyDryRunParams struct {
+	// If set, only enforcements in this room are listed.
+	TargetRoomID id.RoomID `json:"target_room_id,omitempty"`
+}
+
+type Add3PIDEmailParams struct {
+	Email string `json:"email"`
+	// The account password, used for user-interactive authentication when adding the address.
+	Password string `json:"password,omitempty"`
+	// The code from the validation email. Only used if the server supports submitting codes.
+	Code string `json:"code,omitempty"`
+	// If true, a new validation email is sent even if there's already a pending validation.
+	Resend bool `json:"resend,omitempty"`
+	// If set, the address is also bound on this identity server after adding it, which allows
+	// other users to find the account by the address. Binding is not done by default.
+	IdentityServer string `json:"identity_server,omitempty"`
+}
+
+type Delete3PIDParams struct {
+	Medium  string `json:"medium"`
+	Address string `json:"address"`
+}
+
+type CompactStorageParams struct {
+	// If true, cached media is deleted too. It will be downloaded again when needed.
+	ClearMediaCache bool `json:"clear_media_cache,omitempty"`
+}
+
+type PolicyEntityType string
+
+const (
+	PolicyEntityUser   PolicyEntityType = "user"
+	PolicyEntityServer PolicyEntityType = "server"
+)
+
+type PolicyRule struct {
+	PolicyRoomID   id.RoomID                  `json:"policy_room_id"`
+	EventID        id.EventID                 `json:"event_id"`
+	EntityType     PolicyEntityType           `json:"entity_type"`
+	Entity         string                     `json:"entity"`
+	Reason         string                     `json:"reason,omitempty"`
+	Recommendation event.PolicyRecommendation `json:"recommendation"`
+}
+
+type ListPolicyRulesResponse struct {
+	Subscriptions []*database.PolicySubscription `json:"subscriptions"`
+	// Active ban rules in subscribed policy rooms. Rules with other recommendations are ignored.
+	Rules []*PolicyRule `json:"rules"`
+	// Bans and flags that have been applied based on the rules.
+	Enforcements []*database.PolicyEnforcement `json:"enforcements"`
+}
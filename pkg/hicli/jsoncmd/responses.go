@@ -7,6 +7,8 @@
 package jsoncmd
 
 import (
+	"go.mau.fi/util/jsontime"
+	"maunium.net/go/mautrix"
 	"maunium.net/go/mautrix/id"
 
 	"go.mau.fi/gomuks/pkg/hicli/database"
@@ -30,12 +32,93 @@ type ProfileEncryptionInfo struct {
 	Errors         []string         `json:"errors"`
 }
 
+// EventEncryptionInfo contains information for debugging the encryption of a single event.
+type EventEncryptionInfo struct {
+	Encrypted bool         `json:"encrypted"`
+	Algorithm id.Algorithm `json:"algorithm,omitempty"`
+	SessionID id.SessionID `json:"session_id,omitempty"`
+	// The error from the last decryption attempt, if the event couldn't be decrypted.
+	DecryptionError string `json:"decryption_error,omitempty"`
+
+	// Whether this device has the megolm session. The fields below are only set if it does.
+	HasSession bool `json:"has_session"`
+	// Whether the session was created by this device.
+	Outbound bool `json:"outbound,omitempty"`
+	// Whether the session was forwarded by another device rather than received directly from the sender.
+	Forwarded  bool               `json:"forwarded,omitempty"`
+	ReceivedAt jsontime.UnixMilli `json:"received_at,omitempty"`
+	// The key backup version that the session was stored in or restored from.
+	KeyBackupVersion id.KeyBackupVersion `json:"key_backup_version,omitempty"`
+	// The device that created the session and the trust state of the session, like for decrypted events.
+	SenderDevice *ProfileDevice `json:"sender_device,omitempty"`
+	SenderTrust  *id.TrustState `json:"sender_trust,omitempty"`
+	// How many users and devices the session was shared with. Only set for sessions created by this device.
+	Share *database.MegolmSessionShare `json:"share,omitempty"`
+}
+
+type SessionDevice struct {
+	*ProfileDevice
+	HasSession bool `json:"has_session"`
+	Current    bool `json:"current,omitempty"`
+}
+
+// SessionDevices lists which of the current user's devices have a megolm session.
+type SessionDevices struct {
+	// Whether the session was created by this device. If false, other devices aren't included in the list,
+	// as there's no way to know which devices received the session from someone else.
+	Outbound bool             `json:"outbound"`
+	Devices  []*SessionDevice `json:"devices"`
+}
+
 type PaginationResponse struct {
 	Events        []*database.Event                  `json:"events"`
 	Receipts      map[id.EventID][]*database.Receipt `json:"receipts"`
 	RelatedEvents []*database.Event                  `json:"related_events"`
 	HasMore       bool                               `json:"has_more"`
 	FromServer    bool                               `json:"from_server"`
+	Gaps          []*database.TimelineGap            `json:"gaps,omitempty"`
+}
+
+// RoomLineageEntry is a room that another room was upgraded from, either directly or through several upgrades.
+type RoomLineageEntry struct {
+	RoomID id.RoomID `json:"room_id"`
+	Name   string    `json:"name,omitempty"`
+	// The room that replaced this room.
+	SuccessorID id.RoomID `json:"successor_id"`
+	// When the room was upgraded. This is the timestamp of the tombstone event if it's known,
+	// otherwise the creation time of the successor.
+	UpgradedAt jsontime.UnixMilli `json:"upgraded_at"`
+	// True if the room is stored locally, i.e. the user is or was a member of it.
+	Local bool `json:"local"`
+	// If set, the history of this room can't be loaded and the lineage ends here.
+	Error string `json:"error,omitempty"`
+}
+
+type PaginateLineageResponse struct {
+	// The predecessor that the events are from. This is null if the room wasn't upgraded from another room.
+	Room *RoomLineageEntry `json:"room"`
+	// The events in reverse chronological order (newest first).
+	Events   []*database.Event                  `json:"events"`
+	Receipts map[id.EventID][]*database.Receipt `json:"receipts,omitempty"`
+	HasMore  bool                               `json:"has_more"`
+	// The token to pass as `since` in the next request if the predecessor isn't stored locally.
+	NextBatch string `json:"next_batch,omitempty"`
+	// The next older room in the lineage. This is only set when there are no more events in the current room.
+	Next *RoomLineageEntry `json:"next,omitempty"`
+}
+
+type FillGapResponse struct {
+	// The newly loaded events in reverse chronological order (newest first).
+	Events []*database.Event `json:"events"`
+	// New timeline entries to merge into the existing timeline by row ID. The new events are
+	// usually placed right before the filled gap, but if there was no room for them, existing
+	// entries after the gap are moved too, so existing entries for the same events must be dropped.
+	// This is empty if no new events were found.
+	Timeline []database.TimelineRowTuple `json:"timeline"`
+	// All gaps that remain in the timeline starting from the oldest newly loaded event. This replaces
+	// all existing gaps starting from the filled gap. If the gap wasn't fully filled, this will
+	// include a new gap right before the oldest newly loaded event.
+	Gaps []*database.TimelineGap `json:"gaps"`
 }
 
 type EventContextResponse struct {
@@ -50,3 +133,147 @@ type ManualPaginationResponse struct {
 	Events    []*database.Event `json:"events"`
 	NextBatch string            `json:"next_batch"`
 }
+
+type ReactionToMe struct {
+	Reaction *database.Event `json:"reaction"`
+	// The event that was reacted to. This is null if the event has been deleted from the local database.
+	Target *database.Event `json:"target"`
+}
+
+type ImportHistoryResponse struct {
+	RoomID   id.RoomID `json:"room_id"`
+	Imported int       `json:"imported"`
+	Skipped  int       `json:"skipped"`
+}
+
+type UIAResponse struct {
+	Success bool `json:"success"`
+	// The user-interactive authentication response from the server, present if the request wasn't successful.
+	// If errcode is M_FORBIDDEN, the password was wrong. Otherwise, the server requires stages that aren't supported.
+	UIA *mautrix.RespUserInteractive `json:"uia,omitempty"`
+}
+
+type ThreePID struct {
+	Medium      string             `json:"medium"`
+	Address     string             `json:"address"`
+	ValidatedAt jsontime.UnixMilli `json:"validated_at"`
+	AddedAt     jsontime.UnixMilli `json:"added_at"`
+}
+
+type Add3PIDStatus string
+
+const (
+	// Add3PIDValidationSent means a validation email was sent and the request should be repeated
+	// after the user has validated the address.
+	Add3PIDValidationSent Add3PIDStatus = "validation_sent"
+	// Add3PIDNotValidated means the address hasn't been validated yet.
+	Add3PIDNotValidated Add3PIDStatus = "not_validated"
+	// Add3PIDInvalidCode means the server rejected the validation code.
+	Add3PIDInvalidCode Add3PIDStatus = "invalid_code"
+	// Add3PIDSessionExpired means the validation took too long and a new email must be requested.
+	Add3PIDSessionExpired Add3PIDStatus = "session_expired"
+	// Add3PIDInUse means the address is already linked to another account.
+	Add3PIDInUse Add3PIDStatus = "in_use"
+	// Add3PIDAuthFailed means user-interactive authentication failed, see the uia field for details.
+	Add3PIDAuthFailed Add3PIDStatus = "auth_failed"
+	// Add3PIDAdded means the address was added to the account.
+	Add3PIDAdded Add3PIDStatus = "added"
+	// Add3PIDBound means the address was bound on the identity server.
+	Add3PIDBound Add3PIDStatus = "bound"
+)
+
+type Add3PIDResponse struct {
+	Status Add3PIDStatus `json:"status"`
+	// True if the validation code from the email can be submitted through gomuks.
+	// Otherwise, the link in the email must be opened.
+	CodeSupported bool `json:"code_supported,omitempty"`
+	// True if the status refers to binding the address on the identity server.
+	// The address has already been added to the account at that point.
+	Binding bool `json:"binding,omitempty"`
+	// The user-interactive authentication response, if the status is auth_failed.
+	UIA *mautrix.RespUserInteractive `json:"uia,omitempty"`
+	// If binding on an identity server was requested, the error that occurred while binding.
+	BindError string `json:"bind_error,omitempty"`
+}
+
+type LogLevels struct {
+	Global     string            `json:"global"`
+	Components map[string]string `json:"components"`
+}
+
+type DebugStats struct {
+	Goroutines  int    `json:"goroutines"`
+	HeapAlloc   uint64 `json:"heap_alloc"`
+	HeapObjects uint64 `json:"heap_objects"`
+	NumGC       uint32 `json:"num_gc"`
+
+	Database DatabaseStats `json:"database"`
+	// Timings of processing sync responses, not including the time spent waiting for the server.
+	Sync SyncTimingStats `json:"sync"`
+	// The number of megolm sessions waiting to be requested from other devices or the key backup.
+	DecryptionQueue int `json:"decryption_queue"`
+	// The number of events waiting to be sent, summed over all rooms.
+	SendQueue int `json:"send_queue"`
+	// Stats of the event buffer that websocket clients resume from. Only set when running the full backend.
+	EventBuffer *EventBufferStats `json:"event_buffer,omitempty"`
+}
+
+type DatabaseStats struct {
+	OpenConnections int   `json:"open_connections"`
+	InUse           int   `json:"in_use"`
+	Idle            int   `json:"idle"`
+	WaitCount       int64 `json:"wait_count"`
+	WaitDurationMS  int64 `json:"wait_duration_ms"`
+}
+
+type SyncTimingStats struct {
+	// The number of sync responses the percentiles are calculated from.
+	Count int   `json:"count"`
+	P50MS int64 `json:"p50_ms"`
+	P90MS int64 `json:"p90_ms"`
+	P99MS int64 `json:"p99_ms"`
+	MaxMS int64 `json:"max_ms"`
+}
+
+type EventBufferStats struct {
+	Length    int `json:"length"`
+	MaxSize   int `json:"max_size"`
+	Listeners int `json:"listeners"`
+}
+
+type StorageStats struct {
+	// The size of the main database file, calculated from the page count.
+	DatabaseSize int64 `json:"database_size"`
+	// The amount of space in the database that is unused and would be freed by compaction.
+	DatabaseFreeSize int64 `json:"database_free_size"`
+	// The names of the files in the origin-private file system used by the database.
+	DatabaseFiles []string `json:"database_files"`
+	// The total size of the media cache, or -1 if it couldn't be calculated.
+	MediaCacheSize    int64 `json:"media_cache_size"`
+	MediaCacheEntries int   `json:"media_cache_entries"`
+	// The browser's storage usage estimate for the whole origin. These may be imprecise or
+	// zero depending on the browser.
+	Usage int64 `json:"usage"`
+	Quota int64 `json:"quota"`
+}
+
+type ImportSettingsResponse struct {
+	// The settings backup after the import.
+	Settings *SettingsBackup `json:"settings"`
+	// Sections that weren't imported, because the stored copy was modified more recently.
+	Conflicts []string `json:"conflicts,omitempty"`
+}
+
+type LeaveRoomsEntry struct {
+	RoomID id.RoomID `json:"room_id"`
+	Name   string    `json:"name,omitempty"`
+	// Only set after the rooms have actually been left.
+	Left  bool   `json:"left,omitempty"`
+	Error string `json:"error,omitempty"`
+}
+
+type LeaveRoomsResponse struct {
+	// The ID to pass back to leave_rooms to actually leave the rooms. Only set for dry runs.
+	DryRunID string             `json:"dry_run_id,omitempty"`
+	Rooms    []*LeaveRoomsEntry `json:"rooms"`
+}
@@ -0,0 +1,180 @@
+// Copyright (c) 2025 Tulir Asokan
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package hicli
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/rs/zerolog"
+	"maunium.net/go/mautrix"
+	"maunium.net/go/mautrix/crypto"
+	"maunium.net/go/mautrix/crypto/backup"
+	"maunium.net/go/mautrix/id"
+
+	"go.mau.fi/gomuks/pkg/hicli/database"
+)
+
+const (
+	// keyBackupGracePeriod is how long to wait for a missing session to arrive via to-device
+	// before looking for it in the key backup.
+	keyBackupGracePeriod = 3 * time.Second
+	// keyBackupFetchInterval is the minimum time between requests to the key backup.
+	keyBackupFetchInterval = 500 * time.Millisecond
+	// keyBackupRetryDelay is multiplied by the number of failed attempts to get the delay before the next attempt.
+	keyBackupRetryDelay = 10 * time.Second
+	// maxKeyBackupAttempts is how many times a session is fetched from the backup before giving up.
+	maxKeyBackupAttempts = 3
+)
+
+type keyBackupFetchState struct {
+	nextCheck map[id.SessionID]time.Time
+	attempts  map[id.SessionID]int
+	lastFetch time.Time
+}
+
+type encryptedKeyBackupData = mautrix.RespKeyBackupData[backup.EncryptedSessionData[backup.MegolmSessionData]]
+
+func (h *HiClient) fetchFromKeyBackup(ctx context.Context, roomID id.RoomID, sessionID id.SessionID) (*crypto.InboundGroupSession, error) {
+	data, err := h.Client.GetKeyBackupForRoomAndSession(ctx, h.KeyBackupVersion, roomID, sessionID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch key from server: %w", err)
+	} else if data == nil {
+		return nil, nil
+	}
+	return h.importFromKeyBackup(ctx, roomID, sessionID, data)
+}
+
+func (h *HiClient) importFromKeyBackup(ctx context.Context, roomID id.RoomID, sessionID id.SessionID, data *encryptedKeyBackupData) (*crypto.InboundGroupSession, error) {
+	decrypted, err := data.SessionData.Decrypt(h.KeyBackupKey)
+	if err != nil {
+		return nil, fmt.Errorf("failed to decrypt key: %w", err)
+	}
+	// Importing marks the session as received, which retries decrypting the events via handleReceivedMegolmSession
+	sess, err := h.Crypto.ImportRoomKeyFromBackup(ctx, h.KeyBackupVersion, roomID, sessionID, decrypted)
+	if err != nil {
+		return nil, fmt.Errorf("failed to import decrypted key: %w", err)
+	}
+	return sess, nil
+}
+
+// keyBackupWaitTime returns how long to wait before checking the key backup for the given session.
+// Sessions seen for the first time get a grace period to arrive via to-device first.
+func (h *HiClient) keyBackupWaitTime(sessionID id.SessionID) time.Duration {
+	h.keyBackupLock.Lock()
+	defer h.keyBackupLock.Unlock()
+	if h.keyBackupState.nextCheck == nil {
+		h.keyBackupState.nextCheck = make(map[id.SessionID]time.Time)
+		h.keyBackupState.attempts = make(map[id.SessionID]int)
+	}
+	nextCheck, ok := h.keyBackupState.nextCheck[sessionID]
+	if !ok {
+		nextCheck = time.Now().Add(keyBackupGracePeriod)
+		h.keyBackupState.nextCheck[sessionID] = nextCheck
+	}
+	return time.Until(nextCheck)
+}
+
+// retryKeyBackupLater schedules another key backup check after a failure.
+// It returns false if the session has already been retried too many times.
+func (h *HiClient) retryKeyBackupLater(sessionID id.SessionID) bool {
+	h.keyBackupLock.Lock()
+	defer h.keyBackupLock.Unlock()
+	h.keyBackupState.attempts[sessionID]++
+	attempts := h.keyBackupState.attempts[sessionID]
+	if attempts >= maxKeyBackupAttempts {
+		return false
+	}
+	h.keyBackupState.nextCheck[sessionID] = time.Now().Add(time.Duration(attempts) * keyBackupRetryDelay)
+	return true
+}
+
+func (h *HiClient) forgetKeyBackupState(sessionID id.SessionID) {
+	h.keyBackupLock.Lock()
+	delete(h.keyBackupState.nextCheck, sessionID)
+	delete(h.keyBackupState.attempts, sessionID)
+	h.keyBackupLock.Unlock()
+}
+
+func (h *HiClient) waitForKeyBackupRateLimit(ctx context.Context) error {
+	h.keyBackupLock.Lock()
+	wait := time.Until(h.keyBackupState.lastFetch.Add(keyBackupFetchInterval))
+	h.keyBackupState.lastFetch = time.Now().Add(max(wait, 0))
+	h.keyBackupLock.Unlock()
+	if wait <= 0 {
+		return nil
+	}
+	select {
+	case <-time.After(wait):
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// checkKeyBackup looks for the given sessions of a room in the key backup. If there are multiple sessions,
+// all keys of the room are fetched in one request instead of fetching each session separately.
+func (h *HiClient) checkKeyBackup(ctx context.Context, roomID id.RoomID, reqs []*database.SessionRequest) {
+	if h.KeyBackupKey == nil || h.KeyBackupVersion == "" {
+		// The backup will be checked again after the key is obtained by verifying.
+		for _, req := range reqs {
+			h.handleKeyBackupResult(ctx, req, nil, nil)
+		}
+		return
+	} else if err := h.waitForKeyBackupRateLimit(ctx); err != nil {
+		return
+	}
+	if len(reqs) == 1 {
+		sess, err := h.fetchFromKeyBackup(ctx, roomID, reqs[0].SessionID)
+		h.handleKeyBackupResult(ctx, reqs[0], sess, err)
+		return
+	}
+	resp, err := h.Client.GetKeyBackupForRoom(ctx, h.KeyBackupVersion, roomID)
+	if err != nil {
+		err = fmt.Errorf("failed to fetch keys from server: %w", err)
+	}
+	for _, req := range reqs {
+		var sess *crypto.InboundGroupSession
+		sessErr := err
+		if resp != nil {
+			if data, ok := resp.Sessions[req.SessionID]; ok {
+				sess, sessErr = h.importFromKeyBackup(ctx, roomID, req.SessionID, &data)
+			}
+		}
+		h.handleKeyBackupResult(ctx, req, sess, sessErr)
+	}
+}
+
+func (h *HiClient) handleKeyBackupResult(ctx context.Context, req *database.SessionRequest, sess *crypto.InboundGroupSession, err error) {
+	log := zerolog.Ctx(ctx).With().Stringer("session_id", req.SessionID).Logger()
+	if err != nil && errors.Is(err, mautrix.MNotFound) {
+		err = nil
+	} else if err != nil && h.retryKeyBackupLater(req.SessionID) {
+		log.Warn().Err(err).Msg("Failed to fetch session from key backup, will retry later")
+		return
+	} else if err != nil {
+		log.Err(err).Msg("Failed to fetch session from key backup, giving up")
+	}
+	h.forgetKeyBackupState(req.SessionID)
+	if sess == nil || sess.Internal.FirstKnownIndex() > req.MinIndex {
+		req.BackupChecked = true
+		err = h.DB.SessionRequest.Put(ctx, req)
+		if err != nil {
+			log.Err(err).Msg("Failed to update session request after checking backup")
+		}
+	} else {
+		log.Debug().
+			Int64("total_restored_from_backup", h.keyBackupRestored.Add(1)).
+			Msg("Found session with sufficiently low first known index, removing from queue")
+		err = h.DB.SessionRequest.Remove(ctx, req.SessionID, sess.Internal.FirstKnownIndex())
+		if err != nil {
+			log.Err(err).Msg("Failed to remove session from request queue")
+		}
+	}
+}
@@ -0,0 +1,257 @@
+// Copyright (c) 2026 Tulir Asokan
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package hicli
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"maunium.net/go/mautrix"
+	"maunium.net/go/mautrix/crypto/backup"
+	"maunium.net/go/mautrix/crypto/olm"
+	"maunium.net/go/mautrix/id"
+
+	"go.mau.fi/gomuks/pkg/hicli/database"
+)
+
+const testBackupRoomID id.RoomID = "!backup:example.com"
+
+// fakeKeyBackup is a homeserver that only implements fetching keys from the key backup.
+// The first failures requests fail with a server error.
+type fakeKeyBackup struct {
+	lock     sync.Mutex
+	failures int
+	requests int
+	sessions map[id.SessionID]encryptedKeyBackupData
+}
+
+func (fkb *fakeKeyBackup) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	fkb.lock.Lock()
+	defer fkb.lock.Unlock()
+	path, ok := strings.CutPrefix(r.URL.Path, "/_matrix/client/v3/room_keys/keys/")
+	if !ok || r.Method != http.MethodGet {
+		mautrix.MUnrecognized.WithStatus(http.StatusNotFound).Write(w)
+		return
+	}
+	fkb.requests++
+	if fkb.failures > 0 {
+		fkb.failures--
+		mautrix.MUnknown.WithStatus(http.StatusInternalServerError).Write(w)
+		return
+	}
+	roomID, sessionID, isSession := strings.Cut(path, "/")
+	if id.RoomID(roomID) != testBackupRoomID {
+		mautrix.MNotFound.WithMessage("No room keys found").Write(w)
+	} else if !isSession {
+		// The ephemeral key only marshals correctly through a pointer, so map values can't be encoded directly
+		sessions := make(map[id.SessionID]*encryptedKeyBackupData, len(fkb.sessions))
+		for sessionID, data := range fkb.sessions {
+			sessions[sessionID] = &data
+		}
+		_ = json.NewEncoder(w).Encode(map[string]any{"sessions": sessions})
+	} else if data, ok := fkb.sessions[id.SessionID(sessionID)]; ok {
+		_ = json.NewEncoder(w).Encode(&data)
+	} else {
+		mautrix.MNotFound.WithMessage("No room key found").Write(w)
+	}
+}
+
+func (fkb *fakeKeyBackup) requestCount() int {
+	fkb.lock.Lock()
+	defer fkb.lock.Unlock()
+	return fkb.requests
+}
+
+// newTestKeyBackup creates a client with a key backup key, and a fake key backup containing the given number
+// of sessions. The returned session requests are queued in the database, but haven't been checked yet.
+func newTestKeyBackup(t *testing.T, sessionCount, failures int) (*HiClient, *fakeKeyBackup, []*database.SessionRequest) {
+	t.Helper()
+	ctx := context.Background()
+	h, _ := newTestClient(t)
+	if err := h.CryptoStore.DB.Upgrade(ctx); err != nil {
+		t.Fatalf("Failed to upgrade crypto database: %v", err)
+	}
+	key, err := backup.NewMegolmBackupKey()
+	if err != nil {
+		t.Fatalf("Failed to generate backup key: %v", err)
+	}
+	h.KeyBackupKey = key
+	h.KeyBackupVersion = "1"
+	fkb := &fakeKeyBackup{failures: failures, sessions: make(map[id.SessionID]encryptedKeyBackupData)}
+	srv := httptest.NewServer(fkb)
+	t.Cleanup(srv.Close)
+	h.Client.HomeserverURL, _ = url.Parse(srv.URL)
+	h.Client.AccessToken = "fake"
+	// Retries are handled by the key backup queue, not the HTTP client
+	h.Client.DefaultHTTPRetries = 0
+	if err = h.DB.Room.CreateRow(ctx, testBackupRoomID); err != nil {
+		t.Fatalf("Failed to create room row: %v", err)
+	}
+
+	reqs := make([]*database.SessionRequest, sessionCount)
+	for i := range reqs {
+		outbound, err := olm.NewOutboundGroupSession()
+		if err != nil {
+			t.Fatalf("Failed to create outbound session: %v", err)
+		}
+		inbound, err := olm.NewInboundGroupSession([]byte(outbound.Key()))
+		if err != nil {
+			t.Fatalf("Failed to create inbound session: %v", err)
+		}
+		exported, err := inbound.Export(0)
+		if err != nil {
+			t.Fatalf("Failed to export session: %v", err)
+		}
+		encrypted, err := backup.EncryptSessionData(key, backup.MegolmSessionData{
+			Algorithm:  id.AlgorithmMegolmV1,
+			SenderKey:  "sender",
+			SessionKey: string(exported),
+		})
+		if err != nil {
+			t.Fatalf("Failed to encrypt session: %v", err)
+		}
+		fkb.sessions[inbound.ID()] = encryptedKeyBackupData{SessionData: *encrypted}
+		reqs[i] = &database.SessionRequest{RoomID: testBackupRoomID, SessionID: inbound.ID(), Sender: "@bob:example.com"}
+		if err = h.DB.SessionRequest.Put(ctx, reqs[i]); err != nil {
+			t.Fatalf("Failed to queue session request: %v", err)
+		}
+	}
+	return h, fkb, reqs
+}
+
+// skipKeyBackupRateLimit allows the next key backup check to run immediately.
+func skipKeyBackupRateLimit(h *HiClient) {
+	h.keyBackupLock.Lock()
+	h.keyBackupState.lastFetch = time.Time{}
+	h.keyBackupLock.Unlock()
+}
+
+// getQueuedSessions returns the session requests that are still in the queue.
+func getQueuedSessions(t *testing.T, h *HiClient) map[id.SessionID]*database.SessionRequest {
+	t.Helper()
+	reqs, err := h.DB.SessionRequest.Next(context.Background(), 100)
+	if err != nil {
+		t.Fatalf("Failed to get queued sessions: %v", err)
+	}
+	queued := make(map[id.SessionID]*database.SessionRequest, len(reqs))
+	for _, req := range reqs {
+		queued[req.SessionID] = req
+	}
+	return queued
+}
+
+func TestCheckKeyBackup_RetryAfterFailure(t *testing.T) {
+	tests := []struct {
+		name         string
+		sessionCount int
+	}{
+		{"single session", 1},
+		{"whole room", 3},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			ctx := context.Background()
+			h, fkb, reqs := newTestKeyBackup(t, test.sessionCount, 1)
+			for _, req := range reqs {
+				// Start the grace period like the request queue does
+				h.keyBackupWaitTime(req.SessionID)
+			}
+
+			h.checkKeyBackup(ctx, testBackupRoomID, reqs)
+			queued := getQueuedSessions(t, h)
+			for _, req := range reqs {
+				if queuedReq, ok := queued[req.SessionID]; !ok {
+					t.Fatalf("Session %s was removed from the queue after a failed backup check", req.SessionID)
+				} else if queuedReq.BackupChecked {
+					t.Errorf("Session %s was marked as checked after a failed backup check", req.SessionID)
+				} else if wait := h.keyBackupWaitTime(req.SessionID); wait <= keyBackupRetryDelay/2 || wait > keyBackupRetryDelay {
+					t.Errorf("Retry for session %s is scheduled in %s, want about %s", req.SessionID, wait, keyBackupRetryDelay)
+				}
+			}
+
+			skipKeyBackupRateLimit(h)
+			h.checkKeyBackup(ctx, testBackupRoomID, reqs)
+			if queued = getQueuedSessions(t, h); len(queued) != 0 {
+				t.Errorf("%d sessions are still queued after they were found in the backup", len(queued))
+			}
+			for _, req := range reqs {
+				sess, err := h.CryptoStore.GetGroupSession(ctx, testBackupRoomID, req.SessionID)
+				if err != nil || sess == nil {
+					t.Errorf("Session %s wasn't imported from the backup: %v", req.SessionID, err)
+				}
+				// The backoff state must be cleared, so that a future request for the session gets a fresh grace period
+				if wait := h.keyBackupWaitTime(req.SessionID); wait <= keyBackupGracePeriod/2 {
+					t.Errorf("Session %s kept its retry state after success (wait %s)", req.SessionID, wait)
+				}
+			}
+			if restored := h.keyBackupRestored.Load(); restored != int64(test.sessionCount) {
+				t.Errorf("Restored counter is %d, want %d", restored, test.sessionCount)
+			}
+			if requests := fkb.requestCount(); requests != 2 {
+				t.Errorf("Key backup was requested %d times, want 2", requests)
+			}
+		})
+	}
+}
+
+func TestCheckKeyBackup_GivesUp(t *testing.T) {
+	ctx := context.Background()
+	h, fkb, reqs := newTestKeyBackup(t, 1, maxKeyBackupAttempts+1)
+	h.keyBackupWaitTime(reqs[0].SessionID)
+	for attempt := 1; attempt <= maxKeyBackupAttempts; attempt++ {
+		skipKeyBackupRateLimit(h)
+		h.checkKeyBackup(ctx, testBackupRoomID, reqs)
+		queuedReq, ok := getQueuedSessions(t, h)[reqs[0].SessionID]
+		if !ok {
+			t.Fatalf("Session was removed from the queue after failed attempt %d", attempt)
+		} else if queuedReq.BackupChecked != (attempt == maxKeyBackupAttempts) {
+			t.Errorf("Session backup_checked is %t after failed attempt %d", queuedReq.BackupChecked, attempt)
+		}
+	}
+	if requests := fkb.requestCount(); requests != maxKeyBackupAttempts {
+		t.Errorf("Key backup was requested %d times, want %d", requests, maxKeyBackupAttempts)
+	}
+}
+
+func TestCheckKeyBackup_NotFound(t *testing.T) {
+	tests := []struct {
+		name       string
+		hasKey     bool
+		wantChecks int
+	}{
+		{"missing from backup", true, 1},
+		{"no backup key", false, 0},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			ctx := context.Background()
+			h, fkb, reqs := newTestKeyBackup(t, 1, 0)
+			delete(fkb.sessions, reqs[0].SessionID)
+			if !test.hasKey {
+				h.KeyBackupKey = nil
+			}
+			h.keyBackupWaitTime(reqs[0].SessionID)
+			h.checkKeyBackup(ctx, testBackupRoomID, reqs)
+			// Sessions that aren't in the backup must go straight to key requests without retrying
+			if queuedReq, ok := getQueuedSessions(t, h)[reqs[0].SessionID]; !ok {
+				t.Error("Session was removed from the queue")
+			} else if !queuedReq.BackupChecked {
+				t.Error("Session wasn't marked as checked")
+			}
+			if requests := fkb.requestCount(); requests != test.wantChecks {
+				t.Errorf("Key backup was requested %d times, want %d", requests, test.wantChecks)
+			}
+		})
+	}
+}
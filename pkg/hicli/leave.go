@@ -0,0 +1,248 @@
+// Copyright (c) 2025 Tulir Asokan
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package hicli
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"time"
+
+	"github.com/rs/zerolog"
+	"go.mau.fi/util/jsontime"
+	"go.mau.fi/util/ptr"
+	"go.mau.fi/util/random"
+	"maunium.net/go/mautrix"
+	"maunium.net/go/mautrix/event"
+	"maunium.net/go/mautrix/id"
+
+	"go.mau.fi/gomuks/pkg/hicli/database"
+	"go.mau.fi/gomuks/pkg/hicli/jsoncmd"
+)
+
+var (
+	ErrInvalidLeaveFilter = errors.New("exactly one of space_id, server_name and room_ids must be set for a dry run")
+	ErrLeaveDryRunMissing = errors.New("leaving rooms in bulk requires the ID of a dry run")
+	ErrLeaveDryRunExpired = errors.New("dry run not found or expired, do a new dry run")
+)
+
+const (
+	// leaveDryRunTimeout is how long the result of a leave_rooms dry run can be used to actually leave the rooms.
+	leaveDryRunTimeout = 1 * time.Hour
+	// maxLeaveAttempts is how many times leaving a single room is retried after rate limit errors.
+	maxLeaveAttempts = 5
+)
+
+type leaveDryRun struct {
+	rooms     []*jsoncmd.LeaveRoomsEntry
+	reason    string
+	createdAt time.Time
+}
+
+// LeaveRooms leaves rooms in bulk. A dry run lists the rooms matching the filter and stores the list, and the actual
+// run leaves the rooms from a stored dry run, so rooms are never left without the user having seen the list first.
+func (h *HiClient) LeaveRooms(ctx context.Context, params *jsoncmd.LeaveRoomsParams) (*jsoncmd.LeaveRoomsResponse, error) {
+	if params.DryRun {
+		return h.leaveRoomsDryRun(ctx, params)
+	} else if params.DryRunID == "" {
+		return nil, ErrLeaveDryRunMissing
+	}
+	h.leaveDryRunsLock.Lock()
+	dryRun, ok := h.leaveDryRuns[params.DryRunID]
+	// Each dry run can only be executed once
+	delete(h.leaveDryRuns, params.DryRunID)
+	h.leaveDryRunsLock.Unlock()
+	if !ok || time.Since(dryRun.createdAt) > leaveDryRunTimeout {
+		return nil, ErrLeaveDryRunExpired
+	}
+	reason := params.Reason
+	if reason == "" {
+		reason = dryRun.reason
+	}
+	rooms := slices.DeleteFunc(slices.Clone(dryRun.rooms), func(entry *jsoncmd.LeaveRoomsEntry) bool {
+		return slices.Contains(params.ExcludeRoomIDs, entry.RoomID)
+	})
+	log := zerolog.Ctx(ctx).With().Str("dry_run_id", params.DryRunID).Logger()
+	log.Info().Int("room_count", len(rooms)).Msg("Leaving rooms in bulk")
+	for i, entry := range rooms {
+		err := h.leaveRoomWithRetry(ctx, entry.RoomID, reason, func(retryAt time.Time) {
+			h.EventHandler(&jsoncmd.LeaveRoomsProgress{
+				DryRunID: params.DryRunID,
+				RoomID:   entry.RoomID,
+				Done:     i,
+				Total:    len(rooms),
+				RetryAt:  jsontime.UM(retryAt),
+			})
+		})
+		progress := &jsoncmd.LeaveRoomsProgress{
+			DryRunID: params.DryRunID,
+			RoomID:   entry.RoomID,
+			Done:     i + 1,
+			Total:    len(rooms),
+		}
+		if err != nil {
+			log.Err(err).Stringer("room_id", entry.RoomID).Msg("Failed to leave room")
+			entry.Error = err.Error()
+			progress.Error = entry.Error
+		} else {
+			entry.Left = true
+		}
+		h.EventHandler(progress)
+		if ctx.Err() != nil {
+			return nil, ctx.Err()
+		}
+	}
+	return &jsoncmd.LeaveRoomsResponse{Rooms: rooms}, nil
+}
+
+// leaveRoomWithRetry leaves a single room, waiting and retrying if the homeserver rate limits the request.
+func (h *HiClient) leaveRoomWithRetry(ctx context.Context, roomID id.RoomID, reason string, onRateLimit func(retryAt time.Time)) error {
+	for attempt := 0; ; attempt++ {
+		_, err := h.Client.LeaveRoom(mautrix.WithMaxRetries(ctx, 0), roomID, &mautrix.ReqLeave{Reason: reason})
+		retryAfter, rateLimited := getRetryAfter(err)
+		if !rateLimited || attempt+1 >= maxLeaveAttempts {
+			return err
+		}
+		if retryAfter <= 0 {
+			retryAfter = min(DefaultSendCooldown<<attempt, MaxDefaultSendCooldown)
+		}
+		onRateLimit(time.Now().Add(retryAfter))
+		select {
+		case <-time.After(retryAfter):
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+}
+
+func (h *HiClient) leaveRoomsDryRun(ctx context.Context, params *jsoncmd.LeaveRoomsParams) (*jsoncmd.LeaveRoomsResponse, error) {
+	var filterCount int
+	for _, set := range []bool{params.SpaceID != "", params.ServerName != "", len(params.RoomIDs) > 0} {
+		if set {
+			filterCount++
+		}
+	}
+	if filterCount != 1 {
+		return nil, ErrInvalidLeaveFilter
+	}
+	var roomIDs []id.RoomID
+	var err error
+	switch {
+	case params.SpaceID != "":
+		roomIDs, err = h.getSpaceDescendants(ctx, params.SpaceID)
+	case params.ServerName != "":
+		roomIDs, err = h.getRoomsOnlyWithServer(ctx, params.ServerName)
+	default:
+		roomIDs = params.RoomIDs
+	}
+	if err != nil {
+		return nil, err
+	}
+	rooms := make([]*jsoncmd.LeaveRoomsEntry, 0, len(roomIDs))
+	for _, roomID := range roomIDs {
+		room, err := h.DB.Room.Get(ctx, roomID)
+		if err != nil {
+			return nil, fmt.Errorf("failed to get room %s: %w", roomID, err)
+		} else if room == nil || !room.LeftAt.IsZero() || slices.ContainsFunc(rooms, func(entry *jsoncmd.LeaveRoomsEntry) bool {
+			return entry.RoomID == roomID
+		}) {
+			continue
+		}
+		rooms = append(rooms, &jsoncmd.LeaveRoomsEntry{RoomID: roomID, Name: ptr.Val(room.Name)})
+	}
+	dryRunID := random.String(16)
+	h.leaveDryRunsLock.Lock()
+	if h.leaveDryRuns == nil {
+		h.leaveDryRuns = make(map[string]*leaveDryRun)
+	}
+	for key, dryRun := range h.leaveDryRuns {
+		if time.Since(dryRun.createdAt) > leaveDryRunTimeout {
+			delete(h.leaveDryRuns, key)
+		}
+	}
+	h.leaveDryRuns[dryRunID] = &leaveDryRun{rooms: rooms, reason: params.Reason, createdAt: time.Now()}
+	h.leaveDryRunsLock.Unlock()
+	return &jsoncmd.LeaveRoomsResponse{DryRunID: dryRunID, Rooms: rooms}, nil
+}
+
+// getSpaceDescendants returns all rooms under the given space recursively, followed by the space itself.
+func (h *HiClient) getSpaceDescendants(ctx context.Context, spaceID id.RoomID) ([]id.RoomID, error) {
+	edges, err := h.DB.SpaceEdge.GetAll(ctx, "")
+	if err != nil {
+		return nil, fmt.Errorf("failed to get space edges: %w", err)
+	}
+	visited := map[id.RoomID]bool{spaceID: true}
+	var roomIDs []id.RoomID
+	queue := []id.RoomID{spaceID}
+	for len(queue) > 0 {
+		parent := queue[0]
+		queue = queue[1:]
+		for _, edge := range edges[parent] {
+			if visited[edge.ChildID] {
+				continue
+			}
+			visited[edge.ChildID] = true
+			roomIDs = append(roomIDs, edge.ChildID)
+			queue = append(queue, edge.ChildID)
+		}
+	}
+	return append(roomIDs, spaceID), nil
+}
+
+// getRoomsOnlyWithServer returns the joined rooms where all other joined members are on the given server.
+// Rooms whose member list hasn't been fully loaded are skipped unless the known members match the joined member
+// count from the sync summary, as there could be members from other servers that aren't known locally.
+func (h *HiClient) getRoomsOnlyWithServer(ctx context.Context, serverName string) ([]id.RoomID, error) {
+	roomIDs, err := h.DB.Room.GetJoinedIDs(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get joined rooms: %w", err)
+	}
+	spaces, err := h.DB.Room.GetAllSpaces(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get joined spaces: %w", err)
+	}
+	for _, space := range spaces {
+		roomIDs = append(roomIDs, space.ID)
+	}
+	var matching []id.RoomID
+	for _, roomID := range roomIDs {
+		room, err := h.DB.Room.Get(ctx, roomID)
+		if err != nil {
+			return nil, fmt.Errorf("failed to get room %s: %w", roomID, err)
+		} else if room == nil {
+			continue
+		}
+		var joinedCount, matchingCount int
+		var otherServer bool
+		err = h.DB.CurrentState.IterMembers(ctx, roomID).Iter(func(member *database.MemberListEntry) (bool, error) {
+			if member.Membership != event.MembershipJoin {
+				return true, nil
+			}
+			joinedCount++
+			if member.UserID == h.Account.UserID {
+				return true, nil
+			} else if member.UserID.Homeserver() != serverName {
+				otherServer = true
+				// No need to check the rest of the members
+				return false, nil
+			}
+			matchingCount++
+			return true, nil
+		})
+		if err != nil {
+			return nil, fmt.Errorf("failed to get members of %s: %w", roomID, err)
+		} else if otherServer || matchingCount == 0 {
+			continue
+		}
+		summary := room.LazyLoadSummary
+		if room.HasMemberList || (summary != nil && summary.JoinedMemberCount != nil && *summary.JoinedMemberCount == joinedCount) {
+			matching = append(matching, roomID)
+		}
+	}
+	return matching, nil
+}
@@ -0,0 +1,199 @@
+// Copyright (c) 2025 Tulir Asokan
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package hicli
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"time"
+
+	"go.mau.fi/util/jsontime"
+	"go.mau.fi/util/ptr"
+	"maunium.net/go/mautrix"
+	"maunium.net/go/mautrix/event"
+	"maunium.net/go/mautrix/id"
+
+	"go.mau.fi/gomuks/pkg/hicli/database"
+	"go.mau.fi/gomuks/pkg/hicli/jsoncmd"
+)
+
+// maxLineageDepth is the maximum number of predecessors that are followed when resolving the lineage of a room.
+const maxLineageDepth = 32
+
+// GetRoomLineage returns the rooms that the given room was upgraded from, newest first. Predecessors are followed
+// through rooms that are stored locally and rooms whose history is world-readable. If a predecessor can't be read,
+// it's included with an error and the lineage ends there. Cycles end the lineage without an error.
+func (h *HiClient) GetRoomLineage(ctx context.Context, roomID id.RoomID) ([]*jsoncmd.RoomLineageEntry, error) {
+	return h.resolveLineage(ctx, roomID, "")
+}
+
+// resolveLineage resolves the lineage of a room. If until is set, resolving stops one room after it,
+// so that the predecessor of until is known without resolving the whole lineage.
+func (h *HiClient) resolveLineage(ctx context.Context, roomID, until id.RoomID) ([]*jsoncmd.RoomLineageEntry, error) {
+	room, err := h.DB.Room.Get(ctx, roomID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get room: %w", err)
+	} else if room == nil {
+		return nil, fmt.Errorf("unknown room %s", roomID)
+	}
+	createContent := room.CreationContent
+	createdAt, err := h.getLocalStateTimestamp(ctx, roomID, event.StateCreate)
+	if err != nil {
+		return nil, err
+	}
+	visited := map[id.RoomID]bool{roomID: true}
+	successorID := roomID
+	var lineage []*jsoncmd.RoomLineageEntry
+	for len(lineage) < maxLineageDepth {
+		predecessorID := createContent.GetPredecessor().RoomID
+		if predecessorID == "" || visited[predecessorID] {
+			break
+		}
+		visited[predecessorID] = true
+		entry := &jsoncmd.RoomLineageEntry{
+			RoomID:      predecessorID,
+			SuccessorID: successorID,
+			UpgradedAt:  jsontime.UM(createdAt),
+		}
+		lineage = append(lineage, entry)
+		createContent, createdAt, err = h.resolveLineageEntry(ctx, entry)
+		if err != nil {
+			return nil, err
+		} else if entry.Error != "" || entry.SuccessorID == until {
+			break
+		}
+		successorID = predecessorID
+	}
+	return lineage, nil
+}
+
+func (h *HiClient) getLocalStateTimestamp(ctx context.Context, roomID id.RoomID, evtType event.Type) (time.Time, error) {
+	evt, err := h.DB.CurrentState.Get(ctx, roomID, evtType, "")
+	if err != nil {
+		return time.Time{}, fmt.Errorf("failed to get %s event of %s: %w", evtType.Type, roomID, err)
+	} else if evt == nil {
+		return time.Time{}, nil
+	}
+	return evt.Timestamp.Time, nil
+}
+
+// getRemoteState fetches a state event from the homeserver without updating the local state store,
+// as the user isn't in the room.
+func (h *HiClient) getRemoteState(ctx context.Context, roomID id.RoomID, evtType event.Type) (*event.Event, error) {
+	var evt event.Event
+	url := h.Client.BuildURLWithQuery(mautrix.ClientURLPath{"v3", "rooms", roomID, "state", evtType.Type, ""}, map[string]string{
+		"format": "event",
+	})
+	_, err := h.Client.MakeRequest(ctx, http.MethodGet, url, nil, &evt)
+	if err != nil {
+		return nil, err
+	}
+	evt.Type.Class = event.StateEventType
+	_ = evt.Content.ParseRaw(evtType)
+	return &evt, nil
+}
+
+// resolveLineageEntry fills the details of a predecessor room and returns its create event content and timestamp,
+// so that the next predecessor can be found. If the history of the room can't be read, the error field of the
+// entry is set instead.
+func (h *HiClient) resolveLineageEntry(ctx context.Context, entry *jsoncmd.RoomLineageEntry) (*event.CreateEventContent, time.Time, error) {
+	room, err := h.DB.Room.Get(ctx, entry.RoomID)
+	if err != nil {
+		return nil, time.Time{}, fmt.Errorf("failed to get room %s: %w", entry.RoomID, err)
+	} else if room != nil {
+		entry.Local = true
+		entry.Name = ptr.Val(room.Name)
+		if tombstoneTS, err := h.getLocalStateTimestamp(ctx, entry.RoomID, event.StateTombstone); err != nil {
+			return nil, time.Time{}, err
+		} else if !tombstoneTS.IsZero() {
+			entry.UpgradedAt = jsontime.UM(tombstoneTS)
+		}
+		createdAt, err := h.getLocalStateTimestamp(ctx, entry.RoomID, event.StateCreate)
+		return room.CreationContent, createdAt, err
+	}
+	visibility, err := h.getRemoteState(ctx, entry.RoomID, event.StateHistoryVisibility)
+	if err != nil {
+		entry.Error = "Not a member of the room and its history isn't public"
+		return nil, time.Time{}, nil
+	} else if visibility.Content.AsHistoryVisibility().HistoryVisibility != event.HistoryVisibilityWorldReadable {
+		entry.Error = "Not a member of the room and its history isn't world-readable"
+		return nil, time.Time{}, nil
+	}
+	if nameEvt, err := h.getRemoteState(ctx, entry.RoomID, event.StateRoomName); err == nil {
+		entry.Name = nameEvt.Content.AsRoomName().Name
+	}
+	if tombstoneEvt, err := h.getRemoteState(ctx, entry.RoomID, event.StateTombstone); err == nil {
+		entry.UpgradedAt = jsontime.UM(time.UnixMilli(tombstoneEvt.Timestamp))
+	}
+	createEvt, err := h.getRemoteState(ctx, entry.RoomID, event.StateCreate)
+	if err != nil {
+		// The history is readable, but the lineage can't be followed further
+		return nil, time.Time{}, nil
+	}
+	return createEvt.Content.AsCreate(), time.UnixMilli(createEvt.Timestamp), nil
+}
+
+// PaginateLineage loads messages from a predecessor of the given room. The events are returned to the frontend
+// without adding them to the timeline of either room, as the rooms' own timelines must stay separate.
+func (h *HiClient) PaginateLineage(ctx context.Context, params *jsoncmd.PaginateLineageParams) (*jsoncmd.PaginateLineageResponse, error) {
+	predecessorID := params.PredecessorID
+	if predecessorID == "" {
+		room, err := h.DB.Room.Get(ctx, params.RoomID)
+		if err != nil {
+			return nil, fmt.Errorf("failed to get room: %w", err)
+		} else if room == nil {
+			return nil, fmt.Errorf("unknown room %s", params.RoomID)
+		}
+		predecessorID = room.CreationContent.GetPredecessor().RoomID
+		if predecessorID == "" {
+			return &jsoncmd.PaginateLineageResponse{Events: []*database.Event{}}, nil
+		}
+	}
+	lineage, err := h.resolveLineage(ctx, params.RoomID, predecessorID)
+	if err != nil {
+		return nil, err
+	}
+	var entry, next *jsoncmd.RoomLineageEntry
+	for i, item := range lineage {
+		if item.RoomID == predecessorID {
+			entry = item
+			if i+1 < len(lineage) {
+				next = lineage[i+1]
+			}
+			break
+		}
+	}
+	if entry == nil && params.PredecessorID == "" {
+		// The room claims to be its own predecessor
+		return &jsoncmd.PaginateLineageResponse{Events: []*database.Event{}}, nil
+	} else if entry == nil {
+		return nil, fmt.Errorf("%s is not a predecessor of %s", predecessorID, params.RoomID)
+	}
+	resp := &jsoncmd.PaginateLineageResponse{Room: entry}
+	if entry.Error != "" {
+		resp.Events = []*database.Event{}
+		return resp, nil
+	} else if entry.Local {
+		page, err := h.Paginate(ctx, entry.RoomID, params.MaxTimelineID, params.Limit, false)
+		if err != nil {
+			return nil, fmt.Errorf("failed to paginate %s: %w", entry.RoomID, err)
+		}
+		resp.Events, resp.Receipts, resp.HasMore = page.Events, page.Receipts, page.HasMore
+	} else {
+		page, err := h.PaginateManual(ctx, entry.RoomID, "", params.Since, mautrix.DirectionBackward, params.Limit)
+		if err != nil {
+			return nil, fmt.Errorf("failed to paginate %s: %w", entry.RoomID, err)
+		}
+		resp.Events, resp.NextBatch = page.Events, page.NextBatch
+		resp.HasMore = page.NextBatch != "" && len(page.Events) > 0
+	}
+	if !resp.HasMore {
+		resp.Next = next
+	}
+	return resp, nil
+}
@@ -63,6 +63,8 @@ func (h *HiClient) Login(ctx context.Context, req *mautrix.ReqLogin) error {
 	}
 	h.CryptoStore.AccountID = resp.UserID.String()
 	h.CryptoStore.DeviceID = resp.DeviceID
+	deviceName := InitialDeviceDisplayName
+	h.deviceName.Store(&deviceName)
 	log := zerolog.Ctx(ctx)
 	log.Debug().Msg("Saving account to database after login")
 	err = h.DB.Account.Put(ctx, h.Account)
@@ -0,0 +1,86 @@
+// Copyright (c) 2026 Tulir Asokan
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package hicli
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/rs/zerolog"
+
+	"go.mau.fi/gomuks/pkg/hicli/database"
+)
+
+const (
+	// The first maintenance pass is delayed so that it doesn't slow down the initial sync after startup.
+	maintenanceStartDelay = 5 * time.Minute
+	maintenanceInterval   = 6 * time.Hour
+)
+
+// RunMaintenance periodically does cleanup tasks on the local database until the context is canceled.
+func (h *HiClient) RunMaintenance(ctx context.Context) {
+	log := zerolog.Ctx(ctx).With().Str("action", "maintenance").Logger()
+	ctx = log.WithContext(ctx)
+	timer := time.NewTimer(maintenanceStartDelay)
+	defer timer.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-timer.C:
+		}
+		h.runMaintenanceTasks(ctx)
+		timer.Reset(maintenanceInterval)
+	}
+}
+
+// runMaintenanceTasks does one pass of all enabled maintenance tasks.
+func (h *HiClient) runMaintenanceTasks(ctx context.Context) {
+	if h.EnforceRetention {
+		err := h.enforceRetention(ctx)
+		if err != nil && ctx.Err() == nil {
+			zerolog.Ctx(ctx).Err(err).Msg("Failed to enforce room retention policies")
+		}
+	}
+}
+
+// enforceRetention deletes local events that are older than the max_lifetime in the m.room.retention state
+// of each room. The pagination token of the room is cleared, so that the deleted range isn't fetched again.
+func (h *HiClient) enforceRetention(ctx context.Context) error {
+	rooms, err := h.DB.Room.GetWithRetention(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to get rooms with retention policies: %w", err)
+	}
+	now := time.Now()
+	for _, room := range rooms {
+		cutoff := room.Retention.ExpiryCutoff(now)
+		if cutoff.IsZero() {
+			continue
+		}
+		var deleted int64
+		err = h.DB.DoTxn(ctx, nil, func(ctx context.Context) error {
+			deleted, err = h.DB.Event.DeleteExpired(ctx, room.ID, cutoff)
+			if err != nil {
+				return fmt.Errorf("failed to delete expired events: %w", err)
+			} else if deleted > 0 {
+				return h.DB.Room.SetPrevBatch(ctx, room.ID, database.PrevBatchPaginationComplete)
+			}
+			return nil
+		})
+		if err != nil {
+			return fmt.Errorf("failed to enforce retention in %s: %w", room.ID, err)
+		} else if deleted > 0 {
+			zerolog.Ctx(ctx).Debug().
+				Stringer("room_id", room.ID).
+				Int64("deleted_events", deleted).
+				Time("cutoff", cutoff).
+				Msg("Deleted expired events")
+		}
+	}
+	return nil
+}
@@ -0,0 +1,81 @@
+// Copyright (c) 2026 Tulir Asokan
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package hicli
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+	"time"
+
+	"go.mau.fi/util/jsontime"
+	"maunium.net/go/mautrix/event"
+	"maunium.net/go/mautrix/id"
+
+	"go.mau.fi/gomuks/pkg/hicli/database"
+)
+
+func TestRunMaintenanceTasks_Retention(t *testing.T) {
+	tests := []struct {
+		name        string
+		enforce     bool
+		wantDeleted bool
+	}{
+		// never_delete_locally: true in the gomuks config disables EnforceRetention
+		{"never delete locally", false, false},
+		{"enforce retention", true, true},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			ctx := context.Background()
+			h, _ := newTestClient(t)
+			h.EnforceRetention = test.enforce
+			const roomID id.RoomID = "!retention:example.com"
+			putTestMember(t, h, roomID, testUserID, event.MembershipJoin)
+			err := h.DB.Room.Upsert(ctx, &database.Room{
+				ID:        roomID,
+				PrevBatch: "t1",
+				Retention: &database.RetentionEventContent{MaxLifetime: time.Hour.Milliseconds()},
+			})
+			if err != nil {
+				t.Fatalf("Failed to update room: %v", err)
+			}
+			_, err = h.DB.Event.Upsert(ctx, &database.Event{
+				RoomID:    roomID,
+				ID:        "$expired",
+				Sender:    testUserID,
+				Type:      event.EventMessage.Type,
+				Timestamp: jsontime.UM(time.Now().Add(-2 * time.Hour)),
+				Content:   json.RawMessage(`{"msgtype":"m.text","body":"old"}`),
+				Unsigned:  json.RawMessage("{}"),
+			})
+			if err != nil {
+				t.Fatalf("Failed to insert event: %v", err)
+			}
+
+			h.runMaintenanceTasks(ctx)
+
+			evt, err := h.DB.Event.GetByID(ctx, "$expired")
+			if err != nil {
+				t.Fatalf("Failed to get event: %v", err)
+			} else if deleted := evt == nil; deleted != test.wantDeleted {
+				t.Errorf("Expired event deleted = %t, want %t", deleted, test.wantDeleted)
+			}
+			room, err := h.DB.Room.Get(ctx, roomID)
+			if err != nil {
+				t.Fatalf("Failed to get room: %v", err)
+			}
+			wantPrevBatch := "t1"
+			if test.wantDeleted {
+				wantPrevBatch = database.PrevBatchPaginationComplete
+			}
+			if room.PrevBatch != wantPrevBatch {
+				t.Errorf("Room prev_batch = %q, want %q", room.PrevBatch, wantPrevBatch)
+			}
+		})
+	}
+}
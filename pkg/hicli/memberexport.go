@@ -0,0 +1,176 @@
+// Copyright (c) 2025 Tulir Asokan
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package hicli
+
+import (
+	"bufio"
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"maunium.net/go/mautrix/event"
+
+	"go.mau.fi/gomuks/pkg/hicli/database"
+	"go.mau.fi/gomuks/pkg/hicli/jsoncmd"
+)
+
+var (
+	ErrUnknownExportFormat = errors.New("unknown export format")
+	ErrRoomNotFound        = errors.New("room not found")
+)
+
+type memberExportWriter interface {
+	WriteMember(entry *database.MemberListEntry) error
+	Finish() error
+}
+
+type csvMemberWriter struct {
+	w *csv.Writer
+}
+
+var memberExportCSVHeader = []string{"user_id", "membership", "displayname", "power_level", "joined_at", "reason"}
+
+func newCSVMemberWriter(w io.Writer) (*csvMemberWriter, error) {
+	cw := &csvMemberWriter{w: csv.NewWriter(w)}
+	return cw, cw.w.Write(memberExportCSVHeader)
+}
+
+func (cw *csvMemberWriter) WriteMember(entry *database.MemberListEntry) error {
+	var joinedAt string
+	if !entry.JoinedAt.IsZero() {
+		joinedAt = entry.JoinedAt.UTC().Format(time.RFC3339)
+	}
+	return cw.w.Write([]string{
+		entry.UserID.String(),
+		string(entry.Membership),
+		entry.Displayname,
+		strconv.Itoa(entry.PowerLevel),
+		joinedAt,
+		entry.Reason,
+	})
+}
+
+func (cw *csvMemberWriter) Finish() error {
+	cw.w.Flush()
+	return cw.w.Error()
+}
+
+// jsonMemberWriter writes a JSON array one member at a time, so the whole list never needs to be in memory.
+type jsonMemberWriter struct {
+	w     io.Writer
+	first bool
+}
+
+func newJSONMemberWriter(w io.Writer) (*jsonMemberWriter, error) {
+	_, err := io.WriteString(w, "[")
+	return &jsonMemberWriter{w: w, first: true}, err
+}
+
+func (jw *jsonMemberWriter) WriteMember(entry *database.MemberListEntry) error {
+	data, err := json.Marshal(entry)
+	if err != nil {
+		return err
+	}
+	prefix := ",\n"
+	if jw.first {
+		prefix = "\n"
+		jw.first = false
+	}
+	_, err = io.WriteString(jw.w, prefix)
+	if err != nil {
+		return err
+	}
+	_, err = jw.w.Write(data)
+	return err
+}
+
+func (jw *jsonMemberWriter) Finish() error {
+	_, err := io.WriteString(jw.w, "\n]\n")
+	return err
+}
+
+func newMemberExportWriter(format jsoncmd.MemberExportFormat, w io.Writer) (memberExportWriter, error) {
+	switch format {
+	case jsoncmd.MemberExportCSV, "":
+		return newCSVMemberWriter(w)
+	case jsoncmd.MemberExportJSON:
+		return newJSONMemberWriter(w)
+	default:
+		return nil, fmt.Errorf("%w %q", ErrUnknownExportFormat, format)
+	}
+}
+
+// ExportMembers writes the member list of a room with power levels and join times as CSV or JSON.
+// If a path is given, the output is streamed into that file, otherwise it's returned in the response.
+func (h *HiClient) ExportMembers(ctx context.Context, params *jsoncmd.ExportMembersParams) (*jsoncmd.ExportMembersResponse, error) {
+	room, err := h.DB.Room.Get(ctx, params.RoomID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get room from database: %w", err)
+	} else if room == nil {
+		return nil, ErrRoomNotFound
+	} else if !room.HasMemberList {
+		err = h.processGetRoomState(ctx, params.RoomID, true, false, true)
+		if err != nil {
+			return nil, fmt.Errorf("failed to fetch member list: %w", err)
+		}
+	}
+	pl := (&pushRoom{ctx: ctx, roomID: params.RoomID, h: h}).GetPowerLevels()
+	if pl == nil {
+		pl = &event.PowerLevelsEventContent{}
+	}
+
+	var output io.Writer
+	var buf strings.Builder
+	var fileWriter *bufio.Writer
+	if params.Path != "" {
+		var file *os.File
+		file, err = os.OpenFile(params.Path, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0600)
+		if err != nil {
+			return nil, fmt.Errorf("failed to open output file: %w", err)
+		}
+		defer file.Close()
+		fileWriter = bufio.NewWriter(file)
+		output = fileWriter
+	} else {
+		output = &buf
+	}
+	writer, err := newMemberExportWriter(params.Format, output)
+	if err != nil {
+		return nil, err
+	}
+	var count int
+	err = h.DB.CurrentState.IterMembers(ctx, params.RoomID).Iter(func(entry *database.MemberListEntry) (bool, error) {
+		if len(params.Memberships) > 0 && !slices.Contains(params.Memberships, entry.Membership) {
+			return true, nil
+		}
+		entry.PowerLevel = pl.GetUserLevel(entry.UserID)
+		count++
+		return true, writer.WriteMember(entry)
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to export members: %w", err)
+	}
+	err = writer.Finish()
+	if err == nil && fileWriter != nil {
+		err = fileWriter.Flush()
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to finish export: %w", err)
+	}
+	return &jsoncmd.ExportMembersResponse{
+		Count: count,
+		Data:  buf.String(),
+	}, nil
+}
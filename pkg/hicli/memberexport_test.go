@@ -0,0 +1,169 @@
+// Copyright (c) 2026 Tulir Asokan
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package hicli
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"go.mau.fi/util/jsontime"
+	"maunium.net/go/mautrix/event"
+	"maunium.net/go/mautrix/id"
+
+	"go.mau.fi/gomuks/pkg/hicli/database"
+	"go.mau.fi/gomuks/pkg/hicli/jsoncmd"
+)
+
+const testExportRoomID id.RoomID = "!export:example.com"
+
+// putExportTestMember stores a member event with a fixed timestamp and sets it as the current state.
+func putExportTestMember(t *testing.T, h *HiClient, evtID id.EventID, userID id.UserID, ts int64, content, unsigned string) {
+	t.Helper()
+	ctx := context.Background()
+	var memberContent event.MemberEventContent
+	if err := json.Unmarshal([]byte(content), &memberContent); err != nil {
+		t.Fatalf("Failed to parse member content: %v", err)
+	}
+	stateKey := userID.String()
+	rowID, err := h.DB.Event.Upsert(ctx, &database.Event{
+		RoomID:    testExportRoomID,
+		ID:        evtID,
+		Sender:    userID,
+		Type:      event.StateMember.Type,
+		StateKey:  &stateKey,
+		Timestamp: jsontime.UMInt(ts),
+		Content:   json.RawMessage(content),
+		Unsigned:  json.RawMessage(unsigned),
+	})
+	if err != nil {
+		t.Fatalf("Failed to insert event: %v", err)
+	}
+	err = h.DB.CurrentState.Set(ctx, testExportRoomID, event.StateMember, stateKey, rowID, memberContent.Membership)
+	if err != nil {
+		t.Fatalf("Failed to set current state: %v", err)
+	}
+}
+
+func newTestExportClient(t *testing.T) *HiClient {
+	t.Helper()
+	h, _ := newTestClient(t)
+	putTestState(t, h, testExportRoomID, event.StateCreate, "", &event.CreateEventContent{Creator: testUserID, RoomVersion: "11"})
+	putTestState(t, h, testExportRoomID, event.StatePowerLevels, "", &event.PowerLevelsEventContent{
+		Users: map[id.UserID]int{testUserID: 100, "@bob:example.com": 50},
+	})
+	putExportTestMember(t, h, "$alice", testUserID, 1767225600000, `{"membership":"join","displayname":"Alice"}`, `{}`)
+	putExportTestMember(t, h, "$bobjoin", "@bob:example.com", 1767225660000, `{"membership":"join"}`, `{}`)
+	putExportTestMember(t, h, "$bobname", "@bob:example.com", 1767229200000, `{"membership":"join","displayname":"Bob"}`,
+		`{"prev_content":{"membership":"join"},"replaces_state":"$bobjoin"}`)
+	putExportTestMember(t, h, "$carol", "@carol:example.com", 1767232800000, `{"membership":"ban","reason":"spam, mostly"}`,
+		`{"prev_content":{"membership":"join"}}`)
+	putExportTestMember(t, h, "$dave", "@dave:example.com", 1767236400000, `{"membership":"invite","displayname":"Dave"}`, `{}`)
+	err := h.DB.Room.Upsert(context.Background(), &database.Room{ID: testExportRoomID, HasMemberList: true})
+	if err != nil {
+		t.Fatalf("Failed to update room: %v", err)
+	}
+	return h
+}
+
+func TestExportMembers(t *testing.T) {
+	h := newTestExportClient(t)
+	tests := []struct {
+		name        string
+		format      jsoncmd.MemberExportFormat
+		memberships []event.Membership
+		wantCount   int
+		want        string
+	}{
+		{"default format", "", nil, 4, "" +
+			"user_id,membership,displayname,power_level,joined_at,reason\n" +
+			"@alice:example.com,join,Alice,100,2026-01-01T00:00:00Z,\n" +
+			"@bob:example.com,join,Bob,50,2026-01-01T00:01:00Z,\n" +
+			"@carol:example.com,ban,,0,,\"spam, mostly\"\n" +
+			"@dave:example.com,invite,Dave,0,,\n"},
+		{"csv bans", jsoncmd.MemberExportCSV, []event.Membership{event.MembershipBan}, 1, "" +
+			"user_id,membership,displayname,power_level,joined_at,reason\n" +
+			"@carol:example.com,ban,,0,,\"spam, mostly\"\n"},
+		{"csv empty", jsoncmd.MemberExportCSV, []event.Membership{event.MembershipKnock}, 0, "" +
+			"user_id,membership,displayname,power_level,joined_at,reason\n"},
+		{"json", jsoncmd.MemberExportJSON, nil, 4, "[\n" +
+			`{"user_id":"@alice:example.com","membership":"join","displayname":"Alice","power_level":100,"joined_at":1767225600000},` + "\n" +
+			`{"user_id":"@bob:example.com","membership":"join","displayname":"Bob","power_level":50,"joined_at":1767225660000},` + "\n" +
+			`{"user_id":"@carol:example.com","membership":"ban","reason":"spam, mostly","power_level":0},` + "\n" +
+			`{"user_id":"@dave:example.com","membership":"invite","displayname":"Dave","power_level":0}` + "\n" +
+			"]\n"},
+		{"json joins and bans", jsoncmd.MemberExportJSON, []event.Membership{event.MembershipJoin, event.MembershipBan}, 3, "[\n" +
+			`{"user_id":"@alice:example.com","membership":"join","displayname":"Alice","power_level":100,"joined_at":1767225600000},` + "\n" +
+			`{"user_id":"@bob:example.com","membership":"join","displayname":"Bob","power_level":50,"joined_at":1767225660000},` + "\n" +
+			`{"user_id":"@carol:example.com","membership":"ban","reason":"spam, mostly","power_level":0}` + "\n" +
+			"]\n"},
+		{"json empty", jsoncmd.MemberExportJSON, []event.Membership{event.MembershipKnock}, 0, "[\n]\n"},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			resp, err := h.ExportMembers(context.Background(), &jsoncmd.ExportMembersParams{
+				RoomID:      testExportRoomID,
+				Format:      test.format,
+				Memberships: test.memberships,
+			})
+			if err != nil {
+				t.Fatalf("ExportMembers failed: %v", err)
+			}
+			if resp.Count != test.wantCount {
+				t.Errorf("ExportMembers() count = %d, want %d", resp.Count, test.wantCount)
+			}
+			if resp.Data != test.want {
+				t.Errorf("ExportMembers() data = %q, want %q", resp.Data, test.want)
+			}
+			if test.format == jsoncmd.MemberExportJSON {
+				var entries []database.MemberListEntry
+				if err = json.Unmarshal([]byte(resp.Data), &entries); err != nil {
+					t.Errorf("ExportMembers() returned invalid JSON: %v", err)
+				}
+			}
+		})
+	}
+}
+
+func TestExportMembers_File(t *testing.T) {
+	h := newTestExportClient(t)
+	path := filepath.Join(t.TempDir(), "members.json")
+	resp, err := h.ExportMembers(context.Background(), &jsoncmd.ExportMembersParams{
+		RoomID:      testExportRoomID,
+		Format:      jsoncmd.MemberExportJSON,
+		Memberships: []event.Membership{event.MembershipInvite},
+		Path:        path,
+	})
+	if err != nil {
+		t.Fatalf("ExportMembers failed: %v", err)
+	} else if resp.Count != 1 || resp.Data != "" {
+		t.Errorf("ExportMembers() = %+v, want count 1 and no data", resp)
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("Failed to read export file: %v", err)
+	}
+	want := "[\n" + `{"user_id":"@dave:example.com","membership":"invite","displayname":"Dave","power_level":0}` + "\n]\n"
+	if string(data) != want {
+		t.Errorf("Export file = %q, want %q", data, want)
+	}
+}
+
+func TestExportMembers_Errors(t *testing.T) {
+	h := newTestExportClient(t)
+	_, err := h.ExportMembers(context.Background(), &jsoncmd.ExportMembersParams{RoomID: "!unknown:example.com"})
+	if !errors.Is(err, ErrRoomNotFound) {
+		t.Errorf("ExportMembers() for unknown room error = %v, want %v", err, ErrRoomNotFound)
+	}
+	_, err = h.ExportMembers(context.Background(), &jsoncmd.ExportMembersParams{RoomID: testExportRoomID, Format: "xml"})
+	if !errors.Is(err, ErrUnknownExportFormat) {
+		t.Errorf("ExportMembers() with unknown format error = %v, want %v", err, ErrUnknownExportFormat)
+	}
+}
@@ -10,6 +10,7 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"math"
 	"slices"
 	"time"
 
@@ -24,6 +25,7 @@ import (
 )
 
 var ErrPaginationAlreadyInProgress = errors.New("pagination is already in progress")
+var ErrTimestampToEventUnsupported = errors.New("jumping to a date is not supported by your homeserver")
 
 func (h *HiClient) GetEvent(ctx context.Context, roomID id.RoomID, eventID id.EventID) (*database.Event, error) {
 	if evt, err := h.DB.Event.GetByID(ctx, eventID); err != nil {
@@ -63,6 +65,14 @@ func (h *HiClient) processStateReset(ctx context.Context, roomID id.RoomID, err
 		return
 	}
 	log := zerolog.Ctx(ctx)
+	room, err := h.DB.Room.Get(ctx, roomID)
+	if err != nil {
+		log.Err(err).Msg("Failed to get room from database to check if join event was reset")
+		return
+	} else if room == nil || !room.LeftAt.IsZero() {
+		// The room is already archived, so the error is expected
+		return
+	}
 	joinedRooms, err := h.Client.JoinedRooms(ctx)
 	if err != nil {
 		log.Err(err).Msg("Failed to fetch joined rooms to check if join event was reset")
@@ -72,10 +82,10 @@ func (h *HiClient) processStateReset(ctx context.Context, roomID id.RoomID, err
 		log.Debug().Msg("Fetching state failed, but room is still in joined rooms")
 		return
 	}
-	log.Info().Msg("Fetching room state failed and room is not in joined rooms, deleting from database")
-	err = h.DB.Room.Delete(ctx, roomID)
+	log.Info().Msg("Fetching room state failed and room is not in joined rooms, archiving room")
+	err = h.DB.Room.SetLeft(ctx, roomID, time.Now())
 	if err != nil {
-		log.Err(err).Msg("Failed to delete room from database after state reset")
+		log.Err(err).Msg("Failed to archive room after state reset")
 	}
 	h.EventHandler(&jsoncmd.SyncComplete{
 		LeftRooms: []id.RoomID{roomID},
@@ -298,6 +308,10 @@ func (h *HiClient) Paginate(ctx context.Context, roomID id.RoomID, maxTimelineID
 			h.ReprocessExistingEvent(ctx, evt)
 		}
 		resp = &jsoncmd.PaginationResponse{Events: evts, HasMore: true}
+		resp.Gaps, err = h.DB.Timeline.GetGaps(ctx, roomID, evts[len(evts)-1].TimelineRowID, evts[0].TimelineRowID)
+		if err != nil {
+			return nil, fmt.Errorf("failed to get timeline gaps: %w", err)
+		}
 	} else {
 		resp, err = h.PaginateServer(ctx, roomID, limit, reset)
 		if err != nil {
@@ -364,22 +378,36 @@ func (h *HiClient) GetReceipts(ctx context.Context, roomID id.RoomID, eventIDs [
 	return receipts, nil
 }
 
-func (h *HiClient) PaginateServer(ctx context.Context, roomID id.RoomID, limit int, reset bool) (*jsoncmd.PaginationResponse, error) {
+// startPagination marks the room as being paginated and returns a context that will be canceled
+// if the timeline is reset by a sync. The returned function must be called when pagination is done.
+func (h *HiClient) startPagination(ctx context.Context, roomID id.RoomID) (context.Context, func(), error) {
 	ctx, cancel := context.WithCancelCause(ctx)
-	defer cancel(context.Canceled)
 	h.paginationInterrupterLock.Lock()
 	if _, alreadyPaginating := h.paginationInterrupter[roomID]; alreadyPaginating {
 		h.paginationInterrupterLock.Unlock()
-		return nil, ErrPaginationAlreadyInProgress
+		cancel(context.Canceled)
+		return nil, nil, ErrPaginationAlreadyInProgress
 	}
 	h.paginationInterrupter[roomID] = cancel
 	h.paginationInterrupterLock.Unlock()
-	defer func() {
+	return ctx, func() {
 		h.paginationInterrupterLock.Lock()
 		delete(h.paginationInterrupter, roomID)
 		h.paginationInterrupterLock.Unlock()
-	}()
+		cancel(context.Canceled)
+	}, nil
+}
 
+func (h *HiClient) PaginateServer(ctx context.Context, roomID id.RoomID, limit int, reset bool) (*jsoncmd.PaginationResponse, error) {
+	ctx, done, err := h.startPagination(ctx, roomID)
+	if err != nil {
+		return nil, err
+	}
+	defer done()
+	return h.paginateServer(ctx, roomID, limit, reset)
+}
+
+func (h *HiClient) paginateServer(ctx context.Context, roomID id.RoomID, limit int, reset bool) (*jsoncmd.PaginationResponse, error) {
 	room, err := h.DB.Room.Get(ctx, roomID)
 	if err != nil {
 		return nil, fmt.Errorf("failed to get room from database: %w", err)
@@ -466,9 +494,19 @@ func (h *HiClient) PaginateServer(ctx context.Context, roomID id.RoomID, limit i
 		if err != nil {
 			return fmt.Errorf("failed to prepend events to timeline: %w", err)
 		}
-		for i, evt := range events {
-			evt.TimelineRowID = tuples[i].Timeline
+		timelineRowIDs := make(map[database.EventRowID]database.TimelineRowID, len(tuples))
+		for _, tuple := range tuples {
+			timelineRowIDs[tuple.Event] = tuple.Timeline
 		}
+		// Drop events that were already in the timeline (e.g. duplicates within the same chunk)
+		events = slices.DeleteFunc(events, func(evt *database.Event) bool {
+			rowID, ok := timelineRowIDs[evt.RowID]
+			if ok {
+				evt.TimelineRowID = rowID
+				delete(timelineRowIDs, evt.RowID)
+			}
+			return !ok
+		})
 		return nil
 	})
 	if err == nil && wakeupSessionRequests {
@@ -481,6 +519,138 @@ func (h *HiClient) PaginateServer(ctx context.Context, roomID id.RoomID, limit i
 	}, err
 }
 
+var ErrGapNotFound = errors.New("timeline gap not found")
+
+func (h *HiClient) FillGap(ctx context.Context, roomID id.RoomID, gapRowID database.TimelineRowID, limit int) (*jsoncmd.FillGapResponse, error) {
+	ctx, done, err := h.startPagination(ctx, roomID)
+	if err != nil {
+		return nil, err
+	}
+	defer done()
+
+	room, err := h.DB.Room.Get(ctx, roomID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get room from database: %w", err)
+	} else if room == nil {
+		return nil, fmt.Errorf("not in room %s", roomID)
+	}
+	gap, err := h.DB.Timeline.GetGap(ctx, roomID, gapRowID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get gap from database: %w", err)
+	} else if gap == nil {
+		return nil, ErrGapNotFound
+	}
+	resp, err := h.Client.Messages(ctx, roomID, gap.PrevBatch, "", mautrix.DirectionBackward, nil, limit)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get messages from server: %w", err)
+	}
+	// If the server doesn't have anything older, there's nothing more to fill
+	reconnected := resp.End == "" || len(resp.Chunk) == 0
+	events := make([]*database.Event, 0, len(resp.Chunk))
+	wrappedResp := &jsoncmd.FillGapResponse{
+		Events:   events,
+		Timeline: []database.TimelineRowTuple{},
+	}
+	wakeupSessionRequests := false
+	// Spliced events may get row IDs below the filled gap, so remaining gaps are fetched starting from the lowest one
+	gapsFrom := gap.TimelineRowID
+	err = h.DB.DoTxn(ctx, nil, func(ctx context.Context) error {
+		if err = ctx.Err(); err != nil {
+			return err
+		}
+		decryptionQueue := make(map[id.SessionID]*database.SessionRequest)
+		for _, evt := range resp.Chunk {
+			dbEvt, err := h.processEvent(ctx, evt, room.LazyLoadSummary, decryptionQueue, true)
+			if err != nil {
+				return err
+			} else if exists, err := h.DB.Timeline.Has(ctx, roomID, dbEvt.RowID); err != nil {
+				return fmt.Errorf("failed to check if event exists in timeline: %w", err)
+			} else if exists {
+				// Found an event that's already in the timeline, which means the gap is closed
+				reconnected = true
+				break
+			}
+			events = append(events, dbEvt)
+		}
+		wakeupSessionRequests = len(decryptionQueue) > 0
+		for _, entry := range decryptionQueue {
+			err = h.DB.SessionRequest.Put(ctx, entry)
+			if err != nil {
+				return fmt.Errorf("failed to save session request for %s: %w", entry.SessionID, err)
+			}
+		}
+		if len(events) == 0 {
+			if reconnected {
+				err = h.DB.Timeline.DeleteGap(ctx, roomID, gap.TimelineRowID)
+			} else {
+				gap.PrevBatch = resp.End
+				err = h.DB.Timeline.PutGap(ctx, gap)
+			}
+			if err != nil {
+				return fmt.Errorf("failed to update gap: %w", err)
+			}
+			return nil
+		}
+		err = h.DB.Event.FillReactionCounts(ctx, roomID, events)
+		if err != nil {
+			return fmt.Errorf("failed to fill reaction counts: %w", err)
+		}
+		err = h.DB.Event.FillLastEditRowIDs(ctx, roomID, events)
+		if err != nil {
+			return fmt.Errorf("failed to fill last edit row IDs: %w", err)
+		}
+		eventRowIDs := make([]database.EventRowID, len(events))
+		for i, evt := range events {
+			// The events are in reverse chronological order, but splicing wants them in chronological order
+			eventRowIDs[len(events)-i-1] = evt.RowID
+		}
+		wrappedResp.Timeline, err = h.DB.Timeline.Splice(ctx, roomID, gap.TimelineRowID, eventRowIDs)
+		if err != nil {
+			return fmt.Errorf("failed to splice events into timeline: %w", err)
+		}
+		timelineRowIDs := make(map[database.EventRowID]database.TimelineRowID, len(wrappedResp.Timeline))
+		for _, tuple := range wrappedResp.Timeline {
+			timelineRowIDs[tuple.Event] = tuple.Timeline
+		}
+		// Drop events that weren't spliced in (e.g. duplicates within the same chunk)
+		events = slices.DeleteFunc(events, func(evt *database.Event) bool {
+			rowID, ok := timelineRowIDs[evt.RowID]
+			if ok {
+				evt.TimelineRowID = rowID
+				delete(timelineRowIDs, evt.RowID)
+			}
+			return !ok
+		})
+		for _, evt := range events {
+			gapsFrom = min(gapsFrom, evt.TimelineRowID)
+		}
+		if !reconnected && len(events) > 0 {
+			err = h.DB.Timeline.PutGap(ctx, &database.TimelineGap{
+				RoomID:        roomID,
+				TimelineRowID: events[len(events)-1].TimelineRowID,
+				PrevBatch:     resp.End,
+				MissedCount:   max(gap.MissedCount-len(events), 0),
+			})
+			if err != nil {
+				return fmt.Errorf("failed to save new gap: %w", err)
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	if wakeupSessionRequests {
+		h.WakeupRequestQueue()
+	}
+	wrappedResp.Events = events
+	wrappedResp.Gaps, err = h.DB.Timeline.GetGaps(ctx, roomID, gapsFrom, math.MaxInt64)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get remaining gaps: %w", err)
+	}
+	return wrappedResp, nil
+}
+
 func (h *HiClient) GetEventContext(ctx context.Context, roomID id.RoomID, eventID id.EventID, limit int) (*jsoncmd.EventContextResponse, error) {
 	filter := &mautrix.FilterPart{LazyLoadMembers: true}
 	resp, err := h.Client.Context(ctx, roomID, eventID, filter, limit)
@@ -520,6 +690,24 @@ func (h *HiClient) GetEventContext(ctx context.Context, roomID id.RoomID, eventI
 	return wrappedResp, nil
 }
 
+var featureTimestampToEvent = mautrix.UnstableFeature{UnstableFlag: "org.matrix.msc3030", SpecVersion: mautrix.SpecV16}
+
+func (h *HiClient) GetEventNearTimestamp(ctx context.Context, roomID id.RoomID, ts time.Time, dir mautrix.Direction) (*mautrix.RespTimestampToEvent, error) {
+	if dir != mautrix.DirectionForward && dir != mautrix.DirectionBackward {
+		return nil, fmt.Errorf("invalid direction %q", dir)
+	}
+	if h.Client.SpecVersions != nil && !h.Client.SpecVersions.Supports(featureTimestampToEvent) {
+		return nil, ErrTimestampToEventUnsupported
+	}
+	resp, err := h.Client.TimestampToEvent(ctx, roomID, ts, dir)
+	if errors.Is(err, mautrix.MUnrecognized) {
+		return nil, ErrTimestampToEventUnsupported
+	} else if err != nil {
+		return nil, fmt.Errorf("failed to find event near timestamp: %w", err)
+	}
+	return resp, nil
+}
+
 func (h *HiClient) PaginateManual(
 	ctx context.Context,
 	roomID id.RoomID,
@@ -571,6 +759,29 @@ func (h *HiClient) PaginateManual(
 	return &wrappedResp, nil
 }
 
+func (h *HiClient) GetReactionsToMe(ctx context.Context, maxTS time.Time, limit int) ([]*jsoncmd.ReactionToMe, error) {
+	evts, err := h.DB.Event.GetReactionsToMe(ctx, maxTS, limit)
+	if err != nil {
+		return nil, err
+	}
+	output := make([]*jsoncmd.ReactionToMe, len(evts))
+	targets := make(map[id.EventID]*database.Event)
+	for i, evt := range evts {
+		target, ok := targets[evt.RelatesTo]
+		if !ok {
+			target, err = h.DB.Event.GetByID(ctx, evt.RelatesTo)
+			if err != nil {
+				return nil, fmt.Errorf("failed to get target of reaction %s: %w", evt.ID, err)
+			} else if target != nil {
+				h.ReprocessExistingEvent(ctx, target)
+			}
+			targets[evt.RelatesTo] = target
+		}
+		output[i] = &jsoncmd.ReactionToMe{Reaction: evt, Target: target}
+	}
+	return output, nil
+}
+
 func (h *HiClient) GetMentions(ctx context.Context, maxTS time.Time, unreadType database.UnreadType, limit int, roomID id.RoomID) ([]*database.Event, error) {
 	evts, err := h.DB.Event.GetMentions(ctx, maxTS, unreadType, limit, roomID)
 	for _, evt := range evts {
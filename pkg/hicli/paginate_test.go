@@ -0,0 +1,283 @@
+// Copyright (c) 2026 Tulir Asokan
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package hicli
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"slices"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"maunium.net/go/mautrix"
+	"maunium.net/go/mautrix/event"
+	"maunium.net/go/mautrix/id"
+
+	"go.mau.fi/gomuks/pkg/hicli/database"
+)
+
+const gapTestRoomID id.RoomID = "!gap:example.com"
+
+// fakeGapServer serves /messages pages keyed by the from token.
+type fakeGapServer map[string]map[string]any
+
+func (fgs fakeGapServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	resp, ok := fgs[r.URL.Query().Get("from")]
+	if !strings.HasSuffix(r.URL.Path, "/messages") || !ok {
+		http.Error(w, `{"errcode":"M_UNKNOWN","error":"unknown request"}`, http.StatusBadRequest)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(resp)
+}
+
+func gapTestMessage(evtID string, ts int64) map[string]any {
+	return map[string]any{
+		"event_id":         evtID,
+		"room_id":          gapTestRoomID,
+		"sender":           testUserID,
+		"type":             event.EventMessage.Type,
+		"origin_server_ts": ts,
+		"content":          map[string]any{"msgtype": "m.text", "body": evtID},
+	}
+}
+
+// putGapTestTimeline stores messages with the given IDs and appends them to the timeline.
+func putGapTestTimeline(t *testing.T, h *HiClient, eventIDs ...id.EventID) []database.TimelineRowTuple {
+	t.Helper()
+	ctx := context.Background()
+	if err := h.DB.Room.CreateRow(ctx, gapTestRoomID); err != nil {
+		t.Fatalf("Failed to create room row: %v", err)
+	}
+	rowIDs := make([]database.EventRowID, len(eventIDs))
+	for i, evtID := range eventIDs {
+		rawEvt, _ := json.Marshal(gapTestMessage(string(evtID), int64(i+1)))
+		var evt event.Event
+		_ = json.Unmarshal(rawEvt, &evt)
+		dbEvt := database.MautrixToEvent(&evt)
+		rowID, err := h.DB.Event.Upsert(ctx, dbEvt)
+		if err != nil {
+			t.Fatalf("Failed to insert event: %v", err)
+		}
+		rowIDs[i] = rowID
+	}
+	tuples, err := h.DB.Timeline.Append(ctx, gapTestRoomID, rowIDs)
+	if err != nil {
+		t.Fatalf("Failed to append timeline: %v", err)
+	}
+	return tuples
+}
+
+func gapTestTimeline(t *testing.T, h *HiClient) []id.EventID {
+	t.Helper()
+	evts, err := h.DB.Timeline.Get(context.Background(), gapTestRoomID, 100, 0)
+	if err != nil {
+		t.Fatalf("Failed to get timeline: %v", err)
+	}
+	eventIDs := make([]id.EventID, 0, len(evts))
+	for _, evt := range slices.Backward(evts) {
+		eventIDs = append(eventIDs, evt.ID)
+	}
+	return eventIDs
+}
+
+func gapTestGaps(t *testing.T, h *HiClient) []*database.TimelineGap {
+	t.Helper()
+	gaps, err := h.DB.Timeline.GetGaps(context.Background(), gapTestRoomID, math.MinInt64, math.MaxInt64)
+	if err != nil {
+		t.Fatalf("Failed to get gaps: %v", err)
+	}
+	return gaps
+}
+
+func TestFillGap(t *testing.T) {
+	ctx := context.Background()
+	h, _ := newTestClient(t)
+	srv := httptest.NewServer(fakeGapServer{
+		"gap1": {"start": "gap1", "end": "gap2", "chunk": []map[string]any{
+			// The server repeating an event within the chunk must not misalign the timeline row IDs
+			gapTestMessage("$e", 50), gapTestMessage("$e", 50), gapTestMessage("$d", 40),
+		}},
+		"gap2": {"start": "gap2", "end": "gap3", "chunk": []map[string]any{
+			gapTestMessage("$c", 30), gapTestMessage("$b", 20), gapTestMessage("$a", 10),
+		}},
+	})
+	t.Cleanup(srv.Close)
+	h.Client.HomeserverURL, _ = url.Parse(srv.URL)
+	h.Client.AccessToken = "fake"
+
+	existing := putGapTestTimeline(t, h, "$a", "$b", "$f", "$g")
+	err := h.DB.Timeline.PutGap(ctx, &database.TimelineGap{
+		RoomID: gapTestRoomID, TimelineRowID: existing[2].Timeline, PrevBatch: "gap1", MissedCount: 5,
+	})
+	if err != nil {
+		t.Fatalf("Failed to put gap: %v", err)
+	}
+
+	resp, err := h.FillGap(ctx, gapTestRoomID, existing[2].Timeline, 10)
+	if err != nil {
+		t.Fatalf("FillGap failed: %v", err)
+	}
+	if got := gapTestTimeline(t, h); !slices.Equal(got, []id.EventID{"$a", "$b", "$d", "$e", "$f", "$g"}) {
+		t.Fatalf("Timeline after first fill is %v", got)
+	}
+	if len(resp.Events) != 2 {
+		t.Fatalf("First fill returned %d events, want 2", len(resp.Events))
+	}
+	for _, evt := range resp.Events {
+		found := slices.ContainsFunc(resp.Timeline, func(tuple database.TimelineRowTuple) bool {
+			return tuple.Event == evt.RowID && tuple.Timeline == evt.TimelineRowID
+		})
+		if !found {
+			t.Errorf("Event %s has timeline row ID %d, which doesn't match the returned timeline %v", evt.ID, evt.TimelineRowID, resp.Timeline)
+		}
+	}
+	gaps := gapTestGaps(t, h)
+	if len(gaps) != 1 {
+		t.Fatalf("Got %d gaps after partial fill, want 1", len(gaps))
+	} else if gaps[0].PrevBatch != "gap2" || gaps[0].MissedCount != 3 {
+		t.Errorf("Unexpected gap after partial fill: %+v", gaps[0])
+	} else if oldest := resp.Events[len(resp.Events)-1]; oldest.ID != "$d" || gaps[0].TimelineRowID != oldest.TimelineRowID {
+		t.Errorf("New gap is at %d, want before $d", gaps[0].TimelineRowID)
+	} else if !slices.ContainsFunc(resp.Gaps, func(gap *database.TimelineGap) bool {
+		return gap.TimelineRowID == gaps[0].TimelineRowID
+	}) {
+		t.Errorf("New gap wasn't included in the response: %+v", resp.Gaps)
+	}
+
+	// The second page reaches $b which is already in the timeline, so the gap is closed
+	resp, err = h.FillGap(ctx, gapTestRoomID, gaps[0].TimelineRowID, 10)
+	if err != nil {
+		t.Fatalf("Second FillGap failed: %v", err)
+	}
+	if got := gapTestTimeline(t, h); !slices.Equal(got, []id.EventID{"$a", "$b", "$c", "$d", "$e", "$f", "$g"}) {
+		t.Errorf("Timeline after second fill is %v", got)
+	}
+	if len(resp.Events) != 1 || resp.Events[0].ID != "$c" {
+		t.Errorf("Second fill returned unexpected events: %+v", resp.Events)
+	}
+	// The first fill had to move the tail, but it reserved space so the second one doesn't
+	if len(resp.Timeline) != 1 || resp.Timeline[0].Timeline >= gaps[0].TimelineRowID {
+		t.Errorf("Second fill didn't insert in place before the gap at %d: %v", gaps[0].TimelineRowID, resp.Timeline)
+	}
+	if gaps = gapTestGaps(t, h); len(gaps) != 0 {
+		t.Errorf("Gap wasn't removed after the fill met older events: %+v", gaps)
+	}
+	if _, err = h.FillGap(ctx, gapTestRoomID, existing[2].Timeline, 10); err == nil {
+		t.Error("Filling a removed gap succeeded")
+	}
+}
+
+func TestFillGap_NothingNew(t *testing.T) {
+	ctx := context.Background()
+	h, _ := newTestClient(t)
+	srv := httptest.NewServer(fakeGapServer{
+		"gap1": {"start": "gap1", "end": "gap2", "chunk": []map[string]any{gapTestMessage("$a", 10)}},
+	})
+	t.Cleanup(srv.Close)
+	h.Client.HomeserverURL, _ = url.Parse(srv.URL)
+	h.Client.AccessToken = "fake"
+
+	existing := putGapTestTimeline(t, h, "$a", "$b")
+	err := h.DB.Timeline.PutGap(ctx, &database.TimelineGap{
+		RoomID: gapTestRoomID, TimelineRowID: existing[1].Timeline, PrevBatch: "gap1", MissedCount: 5,
+	})
+	if err != nil {
+		t.Fatalf("Failed to put gap: %v", err)
+	}
+	resp, err := h.FillGap(ctx, gapTestRoomID, existing[1].Timeline, 10)
+	if err != nil {
+		t.Fatalf("FillGap failed: %v", err)
+	} else if len(resp.Events) != 0 || len(resp.Timeline) != 0 {
+		t.Errorf("FillGap returned events even though there was nothing missing: %+v", resp)
+	}
+	if gaps := gapTestGaps(t, h); len(gaps) != 0 {
+		t.Errorf("Gap wasn't removed: %+v", gaps)
+	}
+	if got := gapTestTimeline(t, h); !slices.Equal(got, []id.EventID{"$a", "$b"}) {
+		t.Errorf("Timeline changed to %v", got)
+	}
+}
+
+func TestGetEventNearTimestamp(t *testing.T) {
+	ts := time.UnixMilli(1700000000000)
+	okResp := func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write([]byte(`{"event_id":"$near","origin_server_ts":1700000000123}`))
+	}
+	errResp := func(status int, errcode string) http.HandlerFunc {
+		return func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set("Content-Type", "application/json")
+			w.WriteHeader(status)
+			_, _ = fmt.Fprintf(w, `{"errcode":%q,"error":"meow"}`, errcode)
+		}
+	}
+	tests := []struct {
+		name         string
+		versions     *mautrix.RespVersions
+		handler      http.HandlerFunc
+		unsupported  bool
+		wantErr      bool
+		wantRequests int
+	}{
+		{"unknown versions", nil, okResp, false, false, 1},
+		{"stable spec version", &mautrix.RespVersions{Versions: []mautrix.SpecVersion{mautrix.SpecV16}}, okResp, false, false, 1},
+		{"unstable feature", &mautrix.RespVersions{
+			Versions:         []mautrix.SpecVersion{mautrix.SpecV15},
+			UnstableFeatures: map[string]bool{"org.matrix.msc3030": true},
+		}, okResp, false, false, 1},
+		{"old spec version", &mautrix.RespVersions{Versions: []mautrix.SpecVersion{mautrix.SpecV15}}, okResp, true, true, 0},
+		{"unrecognized endpoint", nil, errResp(http.StatusNotFound, "M_UNRECOGNIZED"), true, true, 1},
+		{"no event found", nil, errResp(http.StatusNotFound, "M_NOT_FOUND"), false, true, 1},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			h, _ := newTestClient(t)
+			var requests atomic.Int32
+			srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				requests.Add(1)
+				if !strings.HasSuffix(r.URL.Path, "/timestamp_to_event") {
+					http.Error(w, `{"errcode":"M_UNKNOWN","error":"unknown request"}`, http.StatusBadRequest)
+					return
+				}
+				test.handler(w, r)
+			}))
+			t.Cleanup(srv.Close)
+			h.Client.HomeserverURL, _ = url.Parse(srv.URL)
+			h.Client.AccessToken = "fake"
+			h.Client.SpecVersions = test.versions
+
+			resp, err := h.GetEventNearTimestamp(context.Background(), gapTestRoomID, ts, mautrix.DirectionForward)
+			if errors.Is(err, ErrTimestampToEventUnsupported) != test.unsupported {
+				t.Errorf("GetEventNearTimestamp() error = %v, want unsupported: %t", err, test.unsupported)
+			} else if (err != nil) != test.wantErr {
+				t.Errorf("GetEventNearTimestamp() error = %v, want error: %t", err, test.wantErr)
+			} else if err == nil && resp.EventID != "$near" {
+				t.Errorf("GetEventNearTimestamp() event ID = %q, want %q", resp.EventID, "$near")
+			}
+			if got := int(requests.Load()); got != test.wantRequests {
+				t.Errorf("Server got %d requests, want %d", got, test.wantRequests)
+			}
+		})
+	}
+}
+
+func TestGetEventNearTimestamp_InvalidDirection(t *testing.T) {
+	h, _ := newTestClient(t)
+	_, err := h.GetEventNearTimestamp(context.Background(), gapTestRoomID, time.Now(), mautrix.Direction('x'))
+	if err == nil || errors.Is(err, ErrTimestampToEventUnsupported) {
+		t.Errorf("GetEventNearTimestamp() error = %v, want invalid direction error", err)
+	}
+}
@@ -0,0 +1,577 @@
+// Copyright (c) 2025 Tulir Asokan
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package hicli
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"slices"
+
+	"github.com/rs/zerolog"
+	"github.com/tidwall/gjson"
+	"go.mau.fi/util/glob"
+	"go.mau.fi/util/jsontime"
+	"maunium.net/go/mautrix"
+	"maunium.net/go/mautrix/event"
+	"maunium.net/go/mautrix/id"
+
+	"go.mau.fi/gomuks/pkg/hicli/database"
+	"go.mau.fi/gomuks/pkg/hicli/jsoncmd"
+	"go.mau.fi/gomuks/pkg/serveracl"
+)
+
+var (
+	policyUserTypes   = []event.Type{event.StatePolicyUser, event.StateLegacyPolicyUser, event.StateUnstablePolicyUser}
+	policyServerTypes = []event.Type{event.StatePolicyServer, event.StateLegacyPolicyServer, event.StateUnstablePolicyServer}
+	allPolicyTypes    = slices.Concat(policyUserTypes, policyServerTypes)
+)
+
+func isPolicyRuleType(evtType event.Type) bool {
+	return slices.ContainsFunc(allPolicyTypes, func(policyType event.Type) bool {
+		return policyType.Type == evtType.Type
+	})
+}
+
+func isBanRecommendation(rec event.PolicyRecommendation) bool {
+	switch rec {
+	case event.PolicyRecommendationBan, event.PolicyRecommendationUnstableBan, event.PolicyRecommendationUnstableTakedown:
+		return true
+	default:
+		return false
+	}
+}
+
+type compiledPolicyRule struct {
+	*jsoncmd.PolicyRule
+	glob glob.Glob
+	hash *[32]byte
+}
+
+func compilePolicyRule(evt *database.Event) *compiledPolicyRule {
+	var content event.ModPolicyContent
+	if err := json.Unmarshal(evt.Content, &content); err != nil || !isBanRecommendation(content.Recommendation) {
+		return nil
+	}
+	rule := &compiledPolicyRule{
+		PolicyRule: &jsoncmd.PolicyRule{
+			PolicyRoomID:   evt.RoomID,
+			EventID:        evt.ID,
+			EntityType:     jsoncmd.PolicyEntityUser,
+			Entity:         content.Entity,
+			Reason:         content.Reason,
+			Recommendation: content.Recommendation,
+		},
+		hash: content.UnstableHashes.DecodeSHA256(),
+	}
+	if slices.Contains(policyServerTypes, evt.GetType()) {
+		rule.EntityType = jsoncmd.PolicyEntityServer
+	}
+	if content.Entity != "" && rule.EntityType == jsoncmd.PolicyEntityServer {
+		rule.glob = serveracl.CompilePattern(content.Entity)
+	} else if content.Entity != "" {
+		rule.glob = glob.Compile(content.Entity)
+	} else if rule.hash == nil {
+		return nil
+	}
+	return rule
+}
+
+func (rule *compiledPolicyRule) Match(userID id.UserID) bool {
+	entity := string(userID)
+	if rule.EntityType == jsoncmd.PolicyEntityServer {
+		entity = serveracl.UserServer(userID)
+	}
+	if rule.glob != nil {
+		return rule.glob.Match(entity)
+	}
+	return sha256.Sum256([]byte(entity)) == *rule.hash
+}
+
+func (h *HiClient) loadPolicyRules(ctx context.Context, policyRoomID id.RoomID) ([]*compiledPolicyRule, error) {
+	evts, err := h.DB.CurrentState.GetAllOfTypes(ctx, policyRoomID, allPolicyTypes...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get policy rules in %s: %w", policyRoomID, err)
+	}
+	rules := make([]*compiledPolicyRule, 0, len(evts))
+	for _, evt := range evts {
+		if rule := compilePolicyRule(evt); rule != nil {
+			rules = append(rules, rule)
+		}
+	}
+	return rules, nil
+}
+
+// ensurePoliciesLoaded loads the policy subscriptions and the rules of subscribed policy rooms.
+// The caller must hold policyLock.
+func (h *HiClient) ensurePoliciesLoaded(ctx context.Context) error {
+	if h.policiesLoaded {
+		return nil
+	}
+	subs, err := h.DB.PolicySubscription.GetAll(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to get policy subscriptions: %w", err)
+	}
+	rules := make(map[id.RoomID][]*compiledPolicyRule)
+	for _, sub := range subs {
+		if _, alreadyLoaded := rules[sub.PolicyRoomID]; alreadyLoaded {
+			continue
+		}
+		rules[sub.PolicyRoomID], err = h.loadPolicyRules(ctx, sub.PolicyRoomID)
+		if err != nil {
+			return err
+		}
+	}
+	h.policySubs = subs
+	h.policyRules = rules
+	h.policiesLoaded = true
+	return nil
+}
+
+// reloadPolicyRules reloads the rules of the given policy rooms after they changed in sync.
+// It returns false if none of the rooms are subscribed to.
+func (h *HiClient) reloadPolicyRules(ctx context.Context, policyRoomIDs []id.RoomID) (bool, error) {
+	h.policyLock.Lock()
+	defer h.policyLock.Unlock()
+	if err := h.ensurePoliciesLoaded(ctx); err != nil {
+		return false, err
+	}
+	anyChanged := false
+	for _, roomID := range policyRoomIDs {
+		if _, subscribed := h.policyRules[roomID]; !subscribed {
+			continue
+		}
+		rules, err := h.loadPolicyRules(ctx, roomID)
+		if err != nil {
+			return false, err
+		}
+		h.policyRules[roomID] = rules
+		anyChanged = true
+	}
+	return anyChanged, nil
+}
+
+type policySnapshot struct {
+	subs  []*database.PolicySubscription
+	rules map[id.RoomID][]*compiledPolicyRule
+}
+
+func (h *HiClient) getPolicySnapshot(ctx context.Context) (*policySnapshot, error) {
+	h.policyLock.Lock()
+	defer h.policyLock.Unlock()
+	if err := h.ensurePoliciesLoaded(ctx); err != nil {
+		return nil, err
+	}
+	return &policySnapshot{subs: h.policySubs, rules: h.policyRules}, nil
+}
+
+// match finds the first rule that matches the user in the given room. Ban subscriptions take priority over flags.
+func (ps *policySnapshot) match(roomID id.RoomID, userID id.UserID) (*database.PolicySubscription, *compiledPolicyRule) {
+	var flagSub *database.PolicySubscription
+	var flagRule *compiledPolicyRule
+	for _, sub := range ps.subs {
+		if !sub.Applies(roomID) || (flagSub != nil && sub.Action != database.PolicyActionBan) {
+			continue
+		}
+		for _, rule := range ps.rules[sub.PolicyRoomID] {
+			if !rule.Match(userID) {
+				continue
+			} else if sub.Action == database.PolicyActionBan {
+				return sub, rule
+			}
+			flagSub, flagRule = sub, rule
+			break
+		}
+	}
+	return flagSub, flagRule
+}
+
+func (ps *policySnapshot) targetRooms(ctx context.Context, h *HiClient) ([]id.RoomID, error) {
+	var rooms []id.RoomID
+	for _, sub := range ps.subs {
+		if sub.TargetRoomID == "" {
+			return h.DB.Room.GetJoinedIDs(ctx)
+		} else if !slices.Contains(rooms, sub.TargetRoomID) {
+			rooms = append(rooms, sub.TargetRoomID)
+		}
+	}
+	return rooms, nil
+}
+
+// canBan checks if we have the power to ban the given user in the room.
+func (h *HiClient) canBan(ctx context.Context, roomID id.RoomID, userID id.UserID) bool {
+	pl := (&pushRoom{ctx: ctx, roomID: roomID, h: h}).GetPowerLevels()
+	if pl == nil {
+		return false
+	}
+	ownLevel := pl.GetUserLevel(h.Account.UserID)
+	return ownLevel >= pl.Ban() && ownLevel > pl.GetUserLevel(userID)
+}
+
+// planRoomEnforcements compares the members of a room against the policy rules and returns the
+// bans and flags that should be applied, as well as previous enforcements that should be undone.
+// If userIDs is non-nil, only those users are checked and previous enforcements of other users are left alone.
+func (h *HiClient) planRoomEnforcements(
+	ctx context.Context, ps *policySnapshot, roomID id.RoomID, userIDs []id.UserID,
+) ([]*database.PolicyEnforcement, error) {
+	existing, err := h.DB.PolicyEnforcement.GetAll(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get existing policy enforcements: %w", err)
+	}
+	existingByUser := make(map[id.UserID]*database.PolicyEnforcement)
+	for _, enf := range existing {
+		if enf.RoomID == roomID {
+			existingByUser[enf.UserID] = enf
+		}
+	}
+	memberships := make(map[id.UserID]event.Membership)
+	if userIDs == nil {
+		err = h.DB.CurrentState.IterMembers(ctx, roomID).Iter(func(member *database.MemberListEntry) (bool, error) {
+			memberships[member.UserID] = member.Membership
+			return true, nil
+		})
+		if err != nil {
+			return nil, fmt.Errorf("failed to get members of %s: %w", roomID, err)
+		}
+	} else {
+		for _, userID := range userIDs {
+			evt, err := h.DB.CurrentState.Get(ctx, roomID, event.StateMember, userID.String())
+			if err != nil {
+				return nil, fmt.Errorf("failed to get member %s of %s: %w", userID, roomID, err)
+			} else if evt != nil {
+				memberships[userID] = event.Membership(gjson.GetBytes(evt.Content, "membership").Str)
+			}
+		}
+	}
+	now := jsontime.UnixMilliNow()
+	var plan []*database.PolicyEnforcement
+	for userID, membership := range memberships {
+		if userID == h.Account.UserID {
+			continue
+		}
+		prev := existingByUser[userID]
+		delete(existingByUser, userID)
+		sub, rule := ps.match(roomID, userID)
+		if rule == nil {
+			if prev != nil {
+				plan = append(plan, undoEnforcement(prev, membership, now))
+			}
+			continue
+		}
+		action := sub.Action
+		switch membership {
+		case event.MembershipJoin, event.MembershipInvite, event.MembershipKnock:
+			if action == database.PolicyActionBan && !h.canBan(ctx, roomID, userID) {
+				action = database.PolicyActionFlag
+			}
+		case event.MembershipBan:
+			// Already banned, either by us or by someone else
+			if prev == nil || prev.Action == database.PolicyActionBan {
+				continue
+			}
+			// The user was only flagged, so someone else banned them. Keep the flag rather than recording
+			// the ban as ours, as undoing the enforcement would otherwise unban them.
+			action = database.PolicyActionFlag
+		default:
+			// Users who have left are only flagged, so that they get banned if they come back
+			action = database.PolicyActionFlag
+		}
+		if prev != nil && prev.Action == action && prev.PolicyRoomID == sub.PolicyRoomID && prev.Entity == rule.Entity {
+			continue
+		}
+		plan = append(plan, &database.PolicyEnforcement{
+			RoomID:       roomID,
+			UserID:       userID,
+			Action:       action,
+			PolicyRoomID: sub.PolicyRoomID,
+			Entity:       rule.Entity,
+			Reason:       rule.Reason,
+			Timestamp:    now,
+		})
+	}
+	if userIDs == nil {
+		// Enforcements of users who aren't in the member list anymore
+		for _, prev := range existingByUser {
+			plan = append(plan, undoEnforcement(prev, "", now))
+		}
+	}
+	return plan, nil
+}
+
+func undoEnforcement(prev *database.PolicyEnforcement, membership event.Membership, now jsontime.UnixMilli) *database.PolicyEnforcement {
+	action := database.PolicyActionUnflag
+	if prev.Action == database.PolicyActionBan && membership == event.MembershipBan {
+		action = database.PolicyActionUnban
+	}
+	return &database.PolicyEnforcement{
+		RoomID:       prev.RoomID,
+		UserID:       prev.UserID,
+		Action:       action,
+		PolicyRoomID: prev.PolicyRoomID,
+		Entity:       prev.Entity,
+		Reason:       prev.Reason,
+		Timestamp:    now,
+	}
+}
+
+// planEnforcements plans enforcements in all target rooms, or only in the given room if it's set.
+func (h *HiClient) planEnforcements(ctx context.Context, ps *policySnapshot, onlyRoomID id.RoomID) ([]*database.PolicyEnforcement, error) {
+	var rooms []id.RoomID
+	if onlyRoomID != "" {
+		rooms = []id.RoomID{onlyRoomID}
+	} else {
+		var err error
+		rooms, err = ps.targetRooms(ctx, h)
+		if err != nil {
+			return nil, fmt.Errorf("failed to get policy target rooms: %w", err)
+		}
+		// Rooms with previous enforcements need to be checked even if they're no longer targeted
+		existing, err := h.DB.PolicyEnforcement.GetAll(ctx)
+		if err != nil {
+			return nil, fmt.Errorf("failed to get existing policy enforcements: %w", err)
+		}
+		for _, enf := range existing {
+			if !slices.Contains(rooms, enf.RoomID) {
+				rooms = append(rooms, enf.RoomID)
+			}
+		}
+	}
+	var plan []*database.PolicyEnforcement
+	for _, roomID := range rooms {
+		roomPlan, err := h.planRoomEnforcements(ctx, ps, roomID, nil)
+		if err != nil {
+			return nil, err
+		}
+		plan = append(plan, roomPlan...)
+	}
+	return plan, nil
+}
+
+func (h *HiClient) applyEnforcement(ctx context.Context, enf *database.PolicyEnforcement) {
+	log := zerolog.Ctx(ctx).With().
+		Stringer("room_id", enf.RoomID).
+		Stringer("user_id", enf.UserID).
+		Str("policy_action", string(enf.Action)).
+		Str("entity", enf.Entity).
+		Logger()
+	var err error
+	switch enf.Action {
+	case database.PolicyActionBan:
+		_, err = h.Client.BanUser(ctx, enf.RoomID, &mautrix.ReqBanUser{Reason: enf.Reason, UserID: enf.UserID})
+	case database.PolicyActionUnban:
+		_, err = h.Client.UnbanUser(ctx, enf.RoomID, &mautrix.ReqUnbanUser{UserID: enf.UserID})
+	}
+	if err != nil {
+		log.Err(err).Msg("Failed to enforce policy rule")
+		h.EventHandler(&jsoncmd.PolicyEnforced{PolicyEnforcement: enf, Error: err.Error()})
+		return
+	}
+	switch enf.Action {
+	case database.PolicyActionBan, database.PolicyActionFlag:
+		err = h.DB.PolicyEnforcement.Put(ctx, enf)
+	case database.PolicyActionUnban, database.PolicyActionUnflag:
+		err = h.DB.PolicyEnforcement.Delete(ctx, enf.RoomID, enf.UserID)
+	}
+	if err != nil {
+		log.Err(err).Msg("Failed to save policy enforcement")
+	} else {
+		log.Info().Msg("Enforced policy rule")
+	}
+	h.EventHandler(&jsoncmd.PolicyEnforced{PolicyEnforcement: enf})
+}
+
+func (h *HiClient) applyEnforcements(ctx context.Context, plan []*database.PolicyEnforcement) {
+	for _, enf := range plan {
+		if ctx.Err() != nil {
+			return
+		}
+		h.applyEnforcement(ctx, enf)
+	}
+}
+
+// enforcePolicies re-evaluates all policy subscriptions and applies the result.
+func (h *HiClient) enforcePolicies(ctx context.Context) error {
+	h.policyEnforceLock.Lock()
+	defer h.policyEnforceLock.Unlock()
+	ps, err := h.getPolicySnapshot(ctx)
+	if err != nil {
+		return err
+	}
+	plan, err := h.planEnforcements(ctx, ps, "")
+	if err != nil {
+		return err
+	}
+	h.applyEnforcements(ctx, plan)
+	return nil
+}
+
+// trackPolicyChanges collects changed policy rules and new room members during sync,
+// so that they can be checked against the policies after the sync is processed.
+func trackPolicyChanges(ctx context.Context, roomID id.RoomID, evt *event.Event, membership event.Membership) {
+	syncCtx, _ := ctx.Value(syncContextKey).(*syncContext)
+	if syncCtx == nil {
+		return
+	}
+	if isPolicyRuleType(evt.Type) {
+		if !slices.Contains(syncCtx.changedPolicyRooms, roomID) {
+			syncCtx.changedPolicyRooms = append(syncCtx.changedPolicyRooms, roomID)
+		}
+		return
+	}
+	switch membership {
+	case event.MembershipJoin, event.MembershipInvite, event.MembershipKnock:
+	default:
+		return
+	}
+	if evt.Unsigned.PrevContent != nil && gjson.GetBytes(evt.Unsigned.PrevContent.VeryRaw, "membership").Str == string(membership) {
+		// Profile changes don't need to be checked again
+		return
+	}
+	if syncCtx.newMembers == nil {
+		syncCtx.newMembers = make(map[id.RoomID][]id.UserID)
+	}
+	syncCtx.newMembers[roomID] = append(syncCtx.newMembers[roomID], id.UserID(*evt.StateKey))
+}
+
+// handlePolicyChanges is called after sync with the policy rooms whose rules changed
+// and the users who joined rooms, so that new rules and new members are checked.
+func (h *HiClient) handlePolicyChanges(ctx context.Context, changedPolicyRooms []id.RoomID, newMembers map[id.RoomID][]id.UserID) {
+	log := zerolog.Ctx(ctx)
+	if len(changedPolicyRooms) > 0 {
+		if changed, err := h.reloadPolicyRules(ctx, changedPolicyRooms); err != nil {
+			log.Err(err).Msg("Failed to reload policy rules")
+			return
+		} else if changed {
+			if err = h.enforcePolicies(ctx); err != nil {
+				log.Err(err).Msg("Failed to enforce policy rules")
+			}
+			// All members were already checked
+			return
+		}
+	}
+	if len(newMembers) == 0 {
+		return
+	}
+	h.policyEnforceLock.Lock()
+	defer h.policyEnforceLock.Unlock()
+	ps, err := h.getPolicySnapshot(ctx)
+	if err != nil {
+		log.Err(err).Msg("Failed to load policy rules")
+		return
+	} else if len(ps.subs) == 0 {
+		return
+	}
+	for roomID, userIDs := range newMembers {
+		if !slices.ContainsFunc(ps.subs, func(sub *database.PolicySubscription) bool { return sub.Applies(roomID) }) {
+			continue
+		}
+		plan, err := h.planRoomEnforcements(ctx, ps, roomID, userIDs)
+		if err != nil {
+			log.Err(err).Stringer("room_id", roomID).Msg("Failed to check new members against policy rules")
+			continue
+		}
+		h.applyEnforcements(ctx, plan)
+	}
+}
+
+func (h *HiClient) updatePolicySubscriptions(ctx context.Context, fn func() error) error {
+	h.policyLock.Lock()
+	err := fn()
+	h.policiesLoaded = false
+	h.policyLock.Unlock()
+	if err != nil {
+		return err
+	}
+	go func() {
+		ctx := h.Log.WithContext(context.Background())
+		if err := h.enforcePolicies(ctx); err != nil {
+			zerolog.Ctx(ctx).Err(err).Msg("Failed to enforce policy rules after subscription change")
+		}
+	}()
+	return nil
+}
+
+// SubscribePolicyRoom starts enforcing the rules of a policy room in the target room,
+// or in all joined rooms if the target is empty.
+func (h *HiClient) SubscribePolicyRoom(ctx context.Context, params *jsoncmd.SubscribePolicyRoomParams) error {
+	if params.Action == "" {
+		params.Action = database.PolicyActionBan
+	} else if !params.Action.IsValid() {
+		return fmt.Errorf("invalid policy action %q", params.Action)
+	}
+	if room, err := h.DB.Room.Get(ctx, params.PolicyRoomID); err != nil {
+		return fmt.Errorf("failed to get policy room: %w", err)
+	} else if room == nil {
+		return errors.New("policy room not found, make sure you've joined it")
+	}
+	return h.updatePolicySubscriptions(ctx, func() error {
+		return h.DB.PolicySubscription.Put(ctx, &database.PolicySubscription{
+			PolicyRoomID: params.PolicyRoomID,
+			TargetRoomID: params.TargetRoomID,
+			Action:       params.Action,
+		})
+	})
+}
+
+// UnsubscribePolicyRoom stops enforcing the rules of a policy room. Bans made based on the
+// subscription are undone, unless another subscription still matches the user.
+func (h *HiClient) UnsubscribePolicyRoom(ctx context.Context, params *jsoncmd.UnsubscribePolicyRoomParams) error {
+	return h.updatePolicySubscriptions(ctx, func() error {
+		return h.DB.PolicySubscription.Delete(ctx, params.PolicyRoomID, params.TargetRoomID)
+	})
+}
+
+func (h *HiClient) ListPolicyRules(ctx context.Context, params *jsoncmd.ListPolicyRulesParams) (*jsoncmd.ListPolicyRulesResponse, error) {
+	ps, err := h.getPolicySnapshot(ctx)
+	if err != nil {
+		return nil, err
+	}
+	resp := &jsoncmd.ListPolicyRulesResponse{
+		Subscriptions: make([]*database.PolicySubscription, 0, len(ps.subs)),
+		Rules:         make([]*jsoncmd.PolicyRule, 0),
+	}
+	for _, sub := range ps.subs {
+		if params.PolicyRoomID == "" || sub.PolicyRoomID == params.PolicyRoomID {
+			resp.Subscriptions = append(resp.Subscriptions, sub)
+		}
+	}
+	for roomID, rules := range ps.rules {
+		if params.PolicyRoomID != "" && roomID != params.PolicyRoomID {
+			continue
+		}
+		for _, rule := range rules {
+			resp.Rules = append(resp.Rules, rule.PolicyRule)
+		}
+	}
+	if params.PolicyRoomID != "" {
+		resp.Enforcements, err = h.DB.PolicyEnforcement.GetByPolicyRoom(ctx, params.PolicyRoomID)
+	} else {
+		resp.Enforcements, err = h.DB.PolicyEnforcement.GetAll(ctx)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get policy enforcements: %w", err)
+	}
+	return resp, nil
+}
+
+// PolicyDryRun returns the bans, flags and undos that would be applied if the policies were enforced now.
+func (h *HiClient) PolicyDryRun(ctx context.Context, params *jsoncmd.PolicyDryRunParams) ([]*database.PolicyEnforcement, error) {
+	ps, err := h.getPolicySnapshot(ctx)
+	if err != nil {
+		return nil, err
+	}
+	plan, err := h.planEnforcements(ctx, ps, params.TargetRoomID)
+	if err != nil {
+		return nil, err
+	}
+	if plan == nil {
+		plan = []*database.PolicyEnforcement{}
+	}
+	return plan, nil
+}
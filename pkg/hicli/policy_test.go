@@ -0,0 +1,358 @@
+// Copyright (c) 2026 Tulir Asokan
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package hicli
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"testing"
+
+	"go.mau.fi/util/jsontime"
+	"maunium.net/go/mautrix/event"
+	"maunium.net/go/mautrix/id"
+
+	"go.mau.fi/gomuks/pkg/hicli/database"
+	"go.mau.fi/gomuks/pkg/hicli/jsoncmd"
+)
+
+const (
+	testPolicyRoomID id.RoomID = "!policies:example.com"
+	testTargetRoomID id.RoomID = "!target:example.com"
+)
+
+func hashEntity(entity string) string {
+	hash := sha256.Sum256([]byte(entity))
+	return base64.StdEncoding.EncodeToString(hash[:])
+}
+
+func testPolicyEvent(evtType event.Type, content *event.ModPolicyContent) *database.Event {
+	stateKey := "rule"
+	rawContent, _ := json.Marshal(content)
+	return &database.Event{
+		RoomID:   testPolicyRoomID,
+		ID:       "$rule",
+		Type:     evtType.Type,
+		StateKey: &stateKey,
+		Content:  rawContent,
+	}
+}
+
+func TestCompilePolicyRule(t *testing.T) {
+	tests := []struct {
+		name       string
+		evtType    event.Type
+		content    event.ModPolicyContent
+		entityType jsoncmd.PolicyEntityType
+		matches    []id.UserID
+		nonMatches []id.UserID
+	}{{
+		name:       "user glob",
+		evtType:    event.StatePolicyUser,
+		content:    event.ModPolicyContent{Entity: "@spam*:evil.com", Recommendation: event.PolicyRecommendationBan},
+		entityType: jsoncmd.PolicyEntityUser,
+		matches:    []id.UserID{"@spam:evil.com", "@spammer:evil.com"},
+		nonMatches: []id.UserID{"@ham:evil.com", "@spam:good.com"},
+	}, {
+		name:       "exact user",
+		evtType:    event.StatePolicyUser,
+		content:    event.ModPolicyContent{Entity: "@spam:evil.com", Recommendation: event.PolicyRecommendationBan},
+		entityType: jsoncmd.PolicyEntityUser,
+		matches:    []id.UserID{"@spam:evil.com"},
+		nonMatches: []id.UserID{"@spammer:evil.com"},
+	}, {
+		name:       "server",
+		evtType:    event.StatePolicyServer,
+		content:    event.ModPolicyContent{Entity: "evil.com", Recommendation: event.PolicyRecommendationBan},
+		entityType: jsoncmd.PolicyEntityServer,
+		matches:    []id.UserID{"@anyone:evil.com"},
+		nonMatches: []id.UserID{"@anyone:sub.evil.com", "@evil.com:good.com"},
+	}, {
+		name:       "server glob",
+		evtType:    event.StatePolicyServer,
+		content:    event.ModPolicyContent{Entity: "*.evil.com", Recommendation: event.PolicyRecommendationBan},
+		entityType: jsoncmd.PolicyEntityServer,
+		matches:    []id.UserID{"@anyone:sub.evil.com"},
+		nonMatches: []id.UserID{"@anyone:good.com"},
+	}, {
+		name:    "hashed user",
+		evtType: event.StatePolicyUser,
+		content: event.ModPolicyContent{
+			Recommendation: event.PolicyRecommendationBan,
+			UnstableHashes: &event.PolicyHashes{SHA256: hashEntity("@spam:evil.com")},
+		},
+		entityType: jsoncmd.PolicyEntityUser,
+		matches:    []id.UserID{"@spam:evil.com"},
+		nonMatches: []id.UserID{"@ham:evil.com"},
+	}, {
+		name:    "hashed server",
+		evtType: event.StatePolicyServer,
+		content: event.ModPolicyContent{
+			Recommendation: event.PolicyRecommendationBan,
+			UnstableHashes: &event.PolicyHashes{SHA256: hashEntity("evil.com")},
+		},
+		entityType: jsoncmd.PolicyEntityServer,
+		matches:    []id.UserID{"@anyone:evil.com"},
+		nonMatches: []id.UserID{"@anyone:good.com"},
+	}, {
+		name:       "legacy user type",
+		evtType:    event.StateLegacyPolicyUser,
+		content:    event.ModPolicyContent{Entity: "@spam:evil.com", Recommendation: event.PolicyRecommendationUnstableBan},
+		entityType: jsoncmd.PolicyEntityUser,
+		matches:    []id.UserID{"@spam:evil.com"},
+	}, {
+		name:       "unstable user type",
+		evtType:    event.StateUnstablePolicyUser,
+		content:    event.ModPolicyContent{Entity: "@spam:evil.com", Recommendation: event.PolicyRecommendationUnstableTakedown},
+		entityType: jsoncmd.PolicyEntityUser,
+		matches:    []id.UserID{"@spam:evil.com"},
+	}, {
+		name:       "legacy server type",
+		evtType:    event.StateLegacyPolicyServer,
+		content:    event.ModPolicyContent{Entity: "evil.com", Recommendation: event.PolicyRecommendationBan},
+		entityType: jsoncmd.PolicyEntityServer,
+		matches:    []id.UserID{"@anyone:evil.com"},
+	}, {
+		name:       "unstable server type",
+		evtType:    event.StateUnstablePolicyServer,
+		content:    event.ModPolicyContent{Entity: "evil.com", Recommendation: event.PolicyRecommendationBan},
+		entityType: jsoncmd.PolicyEntityServer,
+		matches:    []id.UserID{"@anyone:evil.com"},
+	}}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			rule := compilePolicyRule(testPolicyEvent(test.evtType, &test.content))
+			if rule == nil {
+				t.Fatal("Rule wasn't compiled")
+			} else if rule.EntityType != test.entityType {
+				t.Errorf("Entity type = %s, want %s", rule.EntityType, test.entityType)
+			}
+			for _, userID := range test.matches {
+				if !rule.Match(userID) {
+					t.Errorf("Rule didn't match %s", userID)
+				}
+			}
+			for _, userID := range test.nonMatches {
+				if rule.Match(userID) {
+					t.Errorf("Rule matched %s", userID)
+				}
+			}
+		})
+	}
+}
+
+func TestCompilePolicyRule_Rejected(t *testing.T) {
+	tests := []struct {
+		name    string
+		content event.ModPolicyContent
+	}{
+		{"unban recommendation", event.ModPolicyContent{Entity: "@spam:evil.com", Recommendation: event.PolicyRecommendationUnban}},
+		{"unknown recommendation", event.ModPolicyContent{Entity: "@spam:evil.com", Recommendation: "com.example.mute"}},
+		{"no recommendation", event.ModPolicyContent{Entity: "@spam:evil.com"}},
+		{"no entity or hash", event.ModPolicyContent{Recommendation: event.PolicyRecommendationBan}},
+		{"invalid hash", event.ModPolicyContent{
+			Recommendation: event.PolicyRecommendationBan,
+			UnstableHashes: &event.PolicyHashes{SHA256: "meow"},
+		}},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if rule := compilePolicyRule(testPolicyEvent(event.StatePolicyUser, &test.content)); rule != nil {
+				t.Errorf("Rule was compiled: %+v", rule.PolicyRule)
+			}
+		})
+	}
+}
+
+func TestIsPolicyRuleType(t *testing.T) {
+	for _, evtType := range allPolicyTypes {
+		if !isPolicyRuleType(evtType) {
+			t.Errorf("%s wasn't detected as a policy rule type", evtType.Type)
+		}
+	}
+	if isPolicyRuleType(event.StatePolicyRoom) || isPolicyRuleType(event.StateMember) {
+		t.Error("Unexpected policy rule type")
+	}
+}
+
+func setupPolicyRoom(t *testing.T, h *HiClient, ownLevel int) {
+	t.Helper()
+	putTestState(t, h, testTargetRoomID, event.StateCreate, "", &event.CreateEventContent{Creator: testUserID, RoomVersion: "11"})
+	putTestState(t, h, testTargetRoomID, event.StatePowerLevels, "", &event.PowerLevelsEventContent{
+		Users: map[id.UserID]int{testUserID: ownLevel},
+	})
+	putTestMember(t, h, testTargetRoomID, testUserID, event.MembershipJoin)
+}
+
+func testPolicySnapshot(action database.PolicyAction, entities ...string) *policySnapshot {
+	rules := make([]*compiledPolicyRule, len(entities))
+	for i, entity := range entities {
+		rules[i] = compilePolicyRule(testPolicyEvent(event.StatePolicyUser, &event.ModPolicyContent{
+			Entity:         entity,
+			Reason:         "spam",
+			Recommendation: event.PolicyRecommendationBan,
+		}))
+	}
+	return &policySnapshot{
+		subs: []*database.PolicySubscription{{
+			PolicyRoomID: testPolicyRoomID,
+			TargetRoomID: testTargetRoomID,
+			Action:       action,
+		}},
+		rules: map[id.RoomID][]*compiledPolicyRule{testPolicyRoomID: rules},
+	}
+}
+
+func putTestEnforcement(t *testing.T, h *HiClient, userID id.UserID, action database.PolicyAction, entity string) {
+	t.Helper()
+	err := h.DB.PolicyEnforcement.Put(context.Background(), &database.PolicyEnforcement{
+		RoomID:       testTargetRoomID,
+		UserID:       userID,
+		Action:       action,
+		PolicyRoomID: testPolicyRoomID,
+		Entity:       entity,
+		Timestamp:    jsontime.UnixMilliNow(),
+	})
+	if err != nil {
+		t.Fatalf("Failed to store enforcement: %v", err)
+	}
+}
+
+func planByUser(t *testing.T, plan []*database.PolicyEnforcement) map[id.UserID]database.PolicyAction {
+	t.Helper()
+	out := make(map[id.UserID]database.PolicyAction, len(plan))
+	for _, enf := range plan {
+		if _, duplicate := out[enf.UserID]; duplicate {
+			t.Errorf("Multiple enforcements planned for %s", enf.UserID)
+		}
+		out[enf.UserID] = enf.Action
+	}
+	return out
+}
+
+func checkPlan(t *testing.T, plan []*database.PolicyEnforcement, expected map[id.UserID]database.PolicyAction) {
+	t.Helper()
+	actual := planByUser(t, plan)
+	for userID, action := range expected {
+		if actual[userID] != action {
+			t.Errorf("Planned action for %s = %q, want %q", userID, actual[userID], action)
+		}
+	}
+	for userID, action := range actual {
+		if _, ok := expected[userID]; !ok {
+			t.Errorf("Unexpected %q planned for %s", action, userID)
+		}
+	}
+}
+
+func TestPlanRoomEnforcements(t *testing.T) {
+	ctx := context.Background()
+	h, _ := newTestClient(t)
+	setupPolicyRoom(t, h, 100)
+	putTestMember(t, h, testTargetRoomID, "@spam:evil.com", event.MembershipJoin)
+	putTestMember(t, h, testTargetRoomID, "@invited:evil.com", event.MembershipInvite)
+	putTestMember(t, h, testTargetRoomID, "@left:evil.com", event.MembershipLeave)
+	putTestMember(t, h, testTargetRoomID, "@banned:evil.com", event.MembershipBan)
+	putTestMember(t, h, testTargetRoomID, "@innocent:good.com", event.MembershipJoin)
+
+	plan, err := h.planRoomEnforcements(ctx, testPolicySnapshot(database.PolicyActionBan, "@*:evil.com"), testTargetRoomID, nil)
+	if err != nil {
+		t.Fatalf("Failed to plan enforcements: %v", err)
+	}
+	checkPlan(t, plan, map[id.UserID]database.PolicyAction{
+		"@spam:evil.com":    database.PolicyActionBan,
+		"@invited:evil.com": database.PolicyActionBan,
+		// Users who left are only flagged, and users who are already banned are left alone
+		"@left:evil.com": database.PolicyActionFlag,
+	})
+
+	plan, err = h.planRoomEnforcements(ctx, testPolicySnapshot(database.PolicyActionFlag, "@*:evil.com"), testTargetRoomID, nil)
+	if err != nil {
+		t.Fatalf("Failed to plan enforcements: %v", err)
+	}
+	checkPlan(t, plan, map[id.UserID]database.PolicyAction{
+		"@spam:evil.com":    database.PolicyActionFlag,
+		"@invited:evil.com": database.PolicyActionFlag,
+		"@left:evil.com":    database.PolicyActionFlag,
+	})
+
+	// Only the given users are checked
+	plan, err = h.planRoomEnforcements(ctx, testPolicySnapshot(database.PolicyActionBan, "@*:evil.com"), testTargetRoomID, []id.UserID{"@spam:evil.com"})
+	if err != nil {
+		t.Fatalf("Failed to plan enforcements: %v", err)
+	}
+	checkPlan(t, plan, map[id.UserID]database.PolicyAction{"@spam:evil.com": database.PolicyActionBan})
+}
+
+func TestPlanRoomEnforcements_NoPower(t *testing.T) {
+	h, _ := newTestClient(t)
+	setupPolicyRoom(t, h, 0)
+	putTestMember(t, h, testTargetRoomID, "@spam:evil.com", event.MembershipJoin)
+	plan, err := h.planRoomEnforcements(context.Background(), testPolicySnapshot(database.PolicyActionBan, "@*:evil.com"), testTargetRoomID, nil)
+	if err != nil {
+		t.Fatalf("Failed to plan enforcements: %v", err)
+	}
+	checkPlan(t, plan, map[id.UserID]database.PolicyAction{"@spam:evil.com": database.PolicyActionFlag})
+}
+
+func TestPlanRoomEnforcements_Transitions(t *testing.T) {
+	ctx := context.Background()
+	h, _ := newTestClient(t)
+	setupPolicyRoom(t, h, 100)
+	// Banned by us, rule still exists: nothing to do
+	putTestMember(t, h, testTargetRoomID, "@banned-by-us:evil.com", event.MembershipBan)
+	putTestEnforcement(t, h, "@banned-by-us:evil.com", database.PolicyActionBan, "@*:evil.com")
+	// Banned by us, rule was removed: unban
+	putTestMember(t, h, testTargetRoomID, "@unbanned:other.com", event.MembershipBan)
+	putTestEnforcement(t, h, "@unbanned:other.com", database.PolicyActionBan, "@*:other.com")
+	// Flagged after leaving, rule was removed: unflag
+	putTestMember(t, h, testTargetRoomID, "@unflagged:other.com", event.MembershipLeave)
+	putTestEnforcement(t, h, "@unflagged:other.com", database.PolicyActionFlag, "@*:other.com")
+	// Flagged after leaving, came back: ban
+	putTestMember(t, h, testTargetRoomID, "@returned:evil.com", event.MembershipJoin)
+	putTestEnforcement(t, h, "@returned:evil.com", database.PolicyActionFlag, "@*:evil.com")
+	// Flagged, then banned manually by a moderator: the flag is kept
+	putTestMember(t, h, testTargetRoomID, "@manual:evil.com", event.MembershipBan)
+	putTestEnforcement(t, h, "@manual:evil.com", database.PolicyActionFlag, "@*:evil.com")
+	// Enforcement of a user who isn't a member anymore: unflag
+	putTestEnforcement(t, h, "@gone:evil.com", database.PolicyActionFlag, "@*:evil.com")
+
+	plan, err := h.planRoomEnforcements(ctx, testPolicySnapshot(database.PolicyActionBan, "@*:evil.com"), testTargetRoomID, nil)
+	if err != nil {
+		t.Fatalf("Failed to plan enforcements: %v", err)
+	}
+	checkPlan(t, plan, map[id.UserID]database.PolicyAction{
+		"@unbanned:other.com":  database.PolicyActionUnban,
+		"@unflagged:other.com": database.PolicyActionUnflag,
+		"@returned:evil.com":   database.PolicyActionBan,
+		"@gone:evil.com":       database.PolicyActionUnflag,
+	})
+}
+
+func TestPlanRoomEnforcements_ManualBanNotTakenOver(t *testing.T) {
+	ctx := context.Background()
+	h, _ := newTestClient(t)
+	setupPolicyRoom(t, h, 100)
+	putTestMember(t, h, testTargetRoomID, "@manual:evil.com", event.MembershipBan)
+	putTestEnforcement(t, h, "@manual:evil.com", database.PolicyActionFlag, "@manual:evil.com")
+
+	// The rule changed, so the flag is updated, but the ban must not be recorded as ours
+	plan, err := h.planRoomEnforcements(ctx, testPolicySnapshot(database.PolicyActionBan, "@*:evil.com"), testTargetRoomID, nil)
+	if err != nil {
+		t.Fatalf("Failed to plan enforcements: %v", err)
+	}
+	checkPlan(t, plan, map[id.UserID]database.PolicyAction{"@manual:evil.com": database.PolicyActionFlag})
+	h.applyEnforcements(ctx, plan)
+
+	// When the rule is removed, the user is only unflagged, not unbanned
+	plan, err = h.planRoomEnforcements(ctx, testPolicySnapshot(database.PolicyActionBan), testTargetRoomID, nil)
+	if err != nil {
+		t.Fatalf("Failed to plan enforcements: %v", err)
+	}
+	checkPlan(t, plan, map[id.UserID]database.PolicyAction{"@manual:evil.com": database.PolicyActionUnflag})
+}
@@ -0,0 +1,192 @@
+// Copyright (c) 2025 Tulir Asokan
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package hicli
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/rs/zerolog"
+	"maunium.net/go/mautrix"
+	"maunium.net/go/mautrix/event"
+)
+
+// AutoPresence sets the user's presence based on activity reported by frontends. The presence is set to online
+// when activity is reported, to unavailable after AwayAfter passes without activity and optionally to offline
+// when the client is shut down.
+//
+// If the homeserver rejects a presence update (e.g. because presence is disabled on the server),
+// all further transitions are skipped.
+type AutoPresence struct {
+	// AwayAfter is how long to wait after the last activity before setting presence to unavailable.
+	// If zero, the presence is never set to unavailable automatically.
+	AwayAfter time.Duration
+	// OfflineOnStop sets the presence to offline when the client is shut down gracefully.
+	OfflineOnStop bool
+	// SetPresence is called to actually change the presence.
+	SetPresence func(ctx context.Context, presence event.Presence) error
+	// Clock is used to get the current time and to schedule the away check.
+	// If nil, the real clock is used. It can be replaced with a fake clock in tests.
+	Clock PresenceClock
+
+	log          zerolog.Logger
+	lock         sync.Mutex
+	current      event.Presence
+	lastActivity time.Time
+	stopTimer    func() bool
+	disabled     bool
+	stopped      bool
+}
+
+// PresenceClock is the time source of AutoPresence.
+type PresenceClock interface {
+	Now() time.Time
+	// AfterFunc calls f in its own goroutine after the duration has elapsed.
+	// The returned function cancels the call like [time.Timer.Stop].
+	AfterFunc(d time.Duration, f func()) (stop func() bool)
+}
+
+type realPresenceClock struct{}
+
+func (realPresenceClock) Now() time.Time {
+	return time.Now()
+}
+
+func (realPresenceClock) AfterFunc(d time.Duration, f func()) func() bool {
+	return time.AfterFunc(d, f).Stop
+}
+
+func (ap *AutoPresence) clock() PresenceClock {
+	if ap.Clock == nil {
+		return realPresenceClock{}
+	}
+	return ap.Clock
+}
+
+func (ap *AutoPresence) cancelTimerLocked() {
+	if ap.stopTimer != nil {
+		ap.stopTimer()
+		ap.stopTimer = nil
+	}
+}
+
+// Activity marks the user as active, setting the presence to online if it wasn't already.
+func (ap *AutoPresence) Activity() {
+	ap.lock.Lock()
+	defer ap.lock.Unlock()
+	if ap.disabled || ap.stopped {
+		return
+	}
+	ap.lastActivity = ap.clock().Now()
+	if ap.current != event.PresenceOnline {
+		ap.setLocked(event.PresenceOnline)
+	}
+	if ap.AwayAfter > 0 && ap.stopTimer == nil && !ap.disabled {
+		ap.stopTimer = ap.clock().AfterFunc(ap.AwayAfter, ap.Check)
+	}
+}
+
+// Check sets the presence to unavailable if there hasn't been any activity for AwayAfter.
+// It's called automatically by a timer, but can also be called manually when using a fake clock.
+func (ap *AutoPresence) Check() {
+	ap.lock.Lock()
+	defer ap.lock.Unlock()
+	ap.stopTimer = nil
+	if ap.disabled || ap.stopped || ap.AwayAfter <= 0 || ap.current != event.PresenceOnline {
+		return
+	}
+	idleFor := ap.clock().Now().Sub(ap.lastActivity)
+	if idleFor < ap.AwayAfter {
+		ap.stopTimer = ap.clock().AfterFunc(ap.AwayAfter-idleFor, ap.Check)
+		return
+	}
+	ap.setLocked(event.PresenceUnavailable)
+}
+
+// Stop stops the away timer and sets the presence to offline if OfflineOnStop is enabled.
+// Activity is ignored after stopping.
+func (ap *AutoPresence) Stop(ctx context.Context) {
+	ap.lock.Lock()
+	defer ap.lock.Unlock()
+	if ap.stopped {
+		return
+	}
+	ap.stopped = true
+	ap.cancelTimerLocked()
+	if ap.OfflineOnStop && !ap.disabled {
+		ap.setLockedCtx(ctx, event.PresenceOffline)
+	}
+}
+
+// Current returns the presence that was last set successfully, or an empty string if it hasn't been set yet.
+func (ap *AutoPresence) Current() event.Presence {
+	ap.lock.Lock()
+	defer ap.lock.Unlock()
+	return ap.current
+}
+
+func (ap *AutoPresence) setLocked(presence event.Presence) {
+	ctx, cancel := context.WithTimeout(ap.log.WithContext(context.Background()), 30*time.Second)
+	defer cancel()
+	ap.setLockedCtx(ctx, presence)
+}
+
+func (ap *AutoPresence) setLockedCtx(ctx context.Context, presence event.Presence) {
+	err := ap.SetPresence(ctx, presence)
+	if err != nil {
+		var httpErr mautrix.HTTPError
+		if errors.As(err, &httpErr) && httpErr.RespError != nil {
+			// The server responded with an error, so presence is most likely disabled or not supported
+			ap.disabled = true
+			ap.cancelTimerLocked()
+			ap.log.Warn().Err(err).
+				Str("presence", string(presence)).
+				Msg("Homeserver rejected presence update, disabling automatic presence")
+		} else {
+			ap.log.Err(err).Str("presence", string(presence)).Msg("Failed to set presence")
+		}
+		return
+	}
+	ap.log.Debug().Str("presence", string(presence)).Msg("Changed presence")
+	ap.current = presence
+}
+
+// InitAutoPresence enables automatic presence changes. If awayAfter is zero, the presence is only set to online on
+// activity (and to offline on shutdown if offlineOnStop is true). It must be called before the client is started.
+func (h *HiClient) InitAutoPresence(awayAfter time.Duration, offlineOnStop bool) {
+	if awayAfter <= 0 && !offlineOnStop {
+		h.autoPresence = nil
+		return
+	}
+	h.autoPresence = &AutoPresence{
+		AwayAfter:     awayAfter,
+		OfflineOnStop: offlineOnStop,
+		SetPresence: func(ctx context.Context, presence event.Presence) error {
+			return h.Client.SetPresence(mautrix.WithMaxRetries(ctx, 0), mautrix.ReqPresence{Presence: presence})
+		},
+		log: h.Log.With().Str("component", "auto presence").Logger(),
+	}
+}
+
+// ReportActivity tells the client that the user did something in a frontend. Frontends should call it on user input,
+// but debounce the calls so it isn't sent on every keystroke.
+func (h *HiClient) ReportActivity() {
+	if h.autoPresence != nil && h.IsLoggedIn() {
+		// Presence is set synchronously, so don't block the caller
+		go h.autoPresence.Activity()
+	}
+}
+
+// StopAutoPresence stops automatic presence changes and sets the presence to offline if configured to do so.
+// It should be called before Stop, as the HTTP client is needed to change the presence.
+func (h *HiClient) StopAutoPresence(ctx context.Context) {
+	if h.autoPresence != nil && h.IsLoggedIn() {
+		h.autoPresence.Stop(ctx)
+	}
+}
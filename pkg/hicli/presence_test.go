@@ -0,0 +1,241 @@
+// Copyright (c) 2026 Tulir Asokan
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package hicli
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"slices"
+	"sync"
+	"testing"
+	"time"
+
+	"maunium.net/go/mautrix"
+	"maunium.net/go/mautrix/event"
+)
+
+type fakeTimer struct {
+	at      time.Time
+	fn      func()
+	stopped bool
+}
+
+// fakePresenceClock is a PresenceClock where time only moves when Advance is called.
+type fakePresenceClock struct {
+	lock   sync.Mutex
+	now    time.Time
+	timers []*fakeTimer
+}
+
+func (fc *fakePresenceClock) Now() time.Time {
+	fc.lock.Lock()
+	defer fc.lock.Unlock()
+	return fc.now
+}
+
+func (fc *fakePresenceClock) AfterFunc(d time.Duration, fn func()) func() bool {
+	fc.lock.Lock()
+	defer fc.lock.Unlock()
+	timer := &fakeTimer{at: fc.now.Add(d), fn: fn}
+	fc.timers = append(fc.timers, timer)
+	return func() bool {
+		fc.lock.Lock()
+		defer fc.lock.Unlock()
+		wasActive := !timer.stopped
+		timer.stopped = true
+		return wasActive
+	}
+}
+
+// Advance moves the clock forward and synchronously runs the timers that became due.
+func (fc *fakePresenceClock) Advance(d time.Duration) {
+	fc.lock.Lock()
+	fc.now = fc.now.Add(d)
+	var due []*fakeTimer
+	fc.timers = slices.DeleteFunc(fc.timers, func(timer *fakeTimer) bool {
+		if timer.stopped {
+			return true
+		} else if !timer.at.After(fc.now) {
+			timer.stopped = true
+			due = append(due, timer)
+			return true
+		}
+		return false
+	})
+	fc.lock.Unlock()
+	for _, timer := range due {
+		timer.fn()
+	}
+}
+
+func (fc *fakePresenceClock) pending() int {
+	fc.lock.Lock()
+	defer fc.lock.Unlock()
+	var count int
+	for _, timer := range fc.timers {
+		if !timer.stopped {
+			count++
+		}
+	}
+	return count
+}
+
+type presenceRecorder struct {
+	lock    sync.Mutex
+	changes []event.Presence
+	err     error
+}
+
+func (pr *presenceRecorder) set(_ context.Context, presence event.Presence) error {
+	pr.lock.Lock()
+	defer pr.lock.Unlock()
+	pr.changes = append(pr.changes, presence)
+	return pr.err
+}
+
+func (pr *presenceRecorder) all() []event.Presence {
+	pr.lock.Lock()
+	defer pr.lock.Unlock()
+	return slices.Clone(pr.changes)
+}
+
+func newTestAutoPresence(awayAfter time.Duration, offlineOnStop bool) (*AutoPresence, *fakePresenceClock, *presenceRecorder) {
+	clock := &fakePresenceClock{now: time.Date(2026, 1, 1, 12, 0, 0, 0, time.UTC)}
+	rec := &presenceRecorder{}
+	return &AutoPresence{
+		AwayAfter:     awayAfter,
+		OfflineOnStop: offlineOnStop,
+		SetPresence:   rec.set,
+		Clock:         clock,
+	}, clock, rec
+}
+
+func assertPresenceChanges(t *testing.T, rec *presenceRecorder, want ...event.Presence) {
+	t.Helper()
+	if got := rec.all(); !slices.Equal(got, want) {
+		t.Errorf("Presence changes = %v, want %v", got, want)
+	}
+}
+
+func TestAutoPresence_OnlineToUnavailable(t *testing.T) {
+	ap, clock, rec := newTestAutoPresence(10*time.Minute, false)
+
+	ap.Activity()
+	assertPresenceChanges(t, rec, event.PresenceOnline)
+	clock.Advance(9 * time.Minute)
+	// Activity while already online doesn't send a new presence update, but it delays going away
+	ap.Activity()
+	clock.Advance(time.Minute)
+	assertPresenceChanges(t, rec, event.PresenceOnline)
+	if ap.Current() != event.PresenceOnline {
+		t.Errorf("Presence went away too early: %s", ap.Current())
+	}
+	clock.Advance(9*time.Minute - time.Second)
+	assertPresenceChanges(t, rec, event.PresenceOnline)
+	clock.Advance(time.Second)
+	assertPresenceChanges(t, rec, event.PresenceOnline, event.PresenceUnavailable)
+	if ap.Current() != event.PresenceUnavailable {
+		t.Errorf("Current() = %s, want unavailable", ap.Current())
+	} else if clock.pending() != 0 {
+		t.Errorf("Away timer still pending after going unavailable")
+	}
+
+	// Activity after going away sets the presence back to online and restarts the timer
+	ap.Activity()
+	assertPresenceChanges(t, rec, event.PresenceOnline, event.PresenceUnavailable, event.PresenceOnline)
+	clock.Advance(10 * time.Minute)
+	assertPresenceChanges(t, rec, event.PresenceOnline, event.PresenceUnavailable, event.PresenceOnline, event.PresenceUnavailable)
+}
+
+func TestAutoPresence_NoAwayTimeout(t *testing.T) {
+	ap, clock, rec := newTestAutoPresence(0, true)
+	ap.Activity()
+	clock.Advance(24 * time.Hour)
+	assertPresenceChanges(t, rec, event.PresenceOnline)
+	if clock.pending() != 0 {
+		t.Error("Away timer was scheduled even though AwayAfter is zero")
+	}
+}
+
+func TestAutoPresence_Stop(t *testing.T) {
+	tests := []struct {
+		name          string
+		offlineOnStop bool
+		want          []event.Presence
+	}{
+		{"offline on quit", true, []event.Presence{event.PresenceOnline, event.PresenceOffline}},
+		{"keep presence on quit", false, []event.Presence{event.PresenceOnline}},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			ap, clock, rec := newTestAutoPresence(10*time.Minute, test.offlineOnStop)
+			ap.Activity()
+			ap.Stop(context.Background())
+			// Stopping twice must not send another update
+			ap.Stop(context.Background())
+			assertPresenceChanges(t, rec, test.want...)
+			if clock.pending() != 0 {
+				t.Error("Away timer wasn't canceled on stop")
+			}
+			// Neither the away timer nor new activity may change the presence after stopping
+			ap.Activity()
+			clock.Advance(time.Hour)
+			assertPresenceChanges(t, rec, test.want...)
+		})
+	}
+}
+
+func TestAutoPresence_DisabledByServer(t *testing.T) {
+	ap, clock, rec := newTestAutoPresence(10*time.Minute, true)
+	rec.err = mautrix.HTTPError{
+		Response:  &http.Response{StatusCode: http.StatusForbidden},
+		RespError: &mautrix.RespError{ErrCode: "M_FORBIDDEN", Err: "Presence is disabled"},
+	}
+	ap.Activity()
+	ap.Activity()
+	clock.Advance(time.Hour)
+	ap.Stop(context.Background())
+	assertPresenceChanges(t, rec, event.PresenceOnline)
+	if ap.Current() != "" {
+		t.Errorf("Current() = %s after rejected update, want empty", ap.Current())
+	}
+}
+
+func TestStopAutoPresence_SendsOffline(t *testing.T) {
+	h, _ := newTestClient(t)
+	var lock sync.Mutex
+	var sent []event.Presence
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPut || r.URL.Path != "/_matrix/client/v3/presence/"+testUserID.String()+"/status" {
+			http.NotFound(w, r)
+			return
+		}
+		var req mautrix.ReqPresence
+		_ = json.NewDecoder(r.Body).Decode(&req)
+		lock.Lock()
+		sent = append(sent, req.Presence)
+		lock.Unlock()
+		_, _ = w.Write([]byte("{}"))
+	}))
+	t.Cleanup(srv.Close)
+	h.Client.HomeserverURL, _ = url.Parse(srv.URL)
+	h.Client.AccessToken = "fake"
+
+	h.InitAutoPresence(0, true)
+	h.autoPresence.Clock = &fakePresenceClock{}
+	h.autoPresence.Activity()
+	h.StopAutoPresence(context.Background())
+
+	lock.Lock()
+	defer lock.Unlock()
+	if want := []event.Presence{event.PresenceOnline, event.PresenceOffline}; !slices.Equal(sent, want) {
+		t.Errorf("Sent presence updates %v, want %v", sent, want)
+	}
+}
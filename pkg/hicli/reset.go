@@ -0,0 +1,154 @@
+// Copyright (c) 2025 Tulir Asokan
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package hicli
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/rs/zerolog"
+	"maunium.net/go/mautrix/event"
+	"maunium.net/go/mautrix/id"
+
+	"go.mau.fi/gomuks/pkg/hicli/database"
+	"go.mau.fi/gomuks/pkg/hicli/jsoncmd"
+)
+
+var ErrResetNotConfirmed = errors.New("reset must be confirmed by setting confirm to true")
+
+const defaultResetRoomLimit = 50
+
+// ResetRoom deletes the cached timeline of a room, refetches the room state and recent messages
+// from the homeserver, and then dispatches a sync payload with Reset set for the room.
+//
+// The state is refetched first, so if the homeserver doesn't return it (e.g. because the user
+// isn't in the room anymore), nothing is deleted. The old current state entries are replaced in
+// the same transaction as the new ones are inserted.
+func (h *HiClient) ResetRoom(ctx context.Context, roomID id.RoomID, limit int) error {
+	if limit <= 0 {
+		limit = defaultResetRoomLimit
+	}
+	room, err := h.DB.Room.Get(ctx, roomID)
+	if err != nil {
+		return fmt.Errorf("failed to get room from database: %w", err)
+	} else if room == nil || !room.LeftAt.IsZero() {
+		return fmt.Errorf("not in room %s", roomID)
+	}
+	ctx, done, err := h.startPagination(ctx, roomID)
+	if err != nil {
+		return err
+	}
+	defer done()
+	log := zerolog.Ctx(ctx).With().Stringer("room_id", roomID).Logger()
+	log.Info().Msg("Resetting room timeline and state")
+	err = h.processGetRoomState(ctx, roomID, false, true, false)
+	if err != nil {
+		return err
+	}
+	err = h.DB.DoTxn(ctx, nil, func(ctx context.Context) error {
+		// Timeline gaps are deleted along with the timeline rows by the foreign key
+		err := h.DB.Timeline.Clear(ctx, roomID)
+		if err != nil {
+			return fmt.Errorf("failed to clear timeline: %w", err)
+		}
+		err = h.DB.Room.SetPrevBatch(ctx, roomID, "")
+		if err != nil {
+			return fmt.Errorf("failed to clear prev_batch: %w", err)
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	_, err = h.paginateServer(ctx, roomID, limit, true)
+	if err != nil {
+		return err
+	}
+	evt, err := h.getRoomResetPayload(ctx, roomID, limit)
+	if err != nil {
+		return err
+	}
+	h.EventHandler(&jsoncmd.SyncComplete{
+		Rooms: map[id.RoomID]*jsoncmd.SyncRoom{roomID: evt},
+	})
+	log.Info().Int("timeline_events", len(evt.Timeline)).Msg("Room reset complete")
+	return nil
+}
+
+func (h *HiClient) getRoomResetPayload(ctx context.Context, roomID id.RoomID, limit int) (*jsoncmd.SyncRoom, error) {
+	room, err := h.DB.Room.Get(ctx, roomID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get room from database: %w", err)
+	} else if room == nil {
+		return nil, fmt.Errorf("room disappeared during reset")
+	}
+	timelineEvts, err := h.DB.Timeline.Get(ctx, roomID, limit, 0)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get timeline: %w", err)
+	}
+	stateEvts, err := h.DB.CurrentState.GetAll(ctx, roomID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get current state: %w", err)
+	}
+	// The timeline query returns the newest events first, but sync payloads are in chronological order
+	timeline := make([]database.TimelineRowTuple, len(timelineEvts))
+	eventIDs := make([]id.EventID, len(timelineEvts))
+	for i, evt := range timelineEvts {
+		h.ReprocessExistingEvent(ctx, evt)
+		timeline[len(timelineEvts)-i-1] = database.TimelineRowTuple{Timeline: evt.TimelineRowID, Event: evt.RowID}
+		eventIDs[i] = evt.ID
+	}
+	state := make(map[event.Type]map[string]database.EventRowID)
+	for _, evt := range stateEvts {
+		evtType := event.Type{Type: evt.Type, Class: event.StateEventType}
+		stateMap, ok := state[evtType]
+		if !ok {
+			stateMap = make(map[string]database.EventRowID)
+			state[evtType] = stateMap
+		}
+		stateMap[*evt.StateKey] = evt.RowID
+	}
+	receipts, err := h.GetReceipts(ctx, roomID, eventIDs)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get receipts: %w", err)
+	}
+	return &jsoncmd.SyncRoom{
+		Meta:     room,
+		Timeline: timeline,
+		Reset:    true,
+		State:    state,
+		Events:   append(timelineEvts, stateEvts...),
+		Receipts: receipts,
+	}, nil
+}
+
+// ResetSync clears the stored sync token and restarts syncing if it was running, which makes the
+// homeserver send a fresh initial sync. Stored events are not touched: rooms that already have a
+// timeline will get a gap before the new events, like with any other limited sync.
+func (h *HiClient) ResetSync(ctx context.Context) error {
+	wasSyncing := h.IsSyncing()
+	h.Client.StopSync()
+	if fn := h.stopSync.Load(); fn != nil {
+		(*fn)()
+	}
+	// Wait for the previous sync loop to exit, so that it can't save a new token after this
+	h.syncLock.Lock()
+	err := h.DB.Account.PutNextBatch(ctx, h.Account.UserID, "")
+	if err == nil {
+		h.Account.NextBatch = ""
+	}
+	h.syncLock.Unlock()
+	if wasSyncing {
+		go h.Sync()
+	}
+	if err != nil {
+		return fmt.Errorf("failed to clear next_batch: %w", err)
+	}
+	zerolog.Ctx(ctx).Info().Msg("Sync token cleared")
+	return nil
+}
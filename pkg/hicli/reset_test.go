@@ -0,0 +1,202 @@
+// Copyright (c) 2026 Tulir Asokan
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package hicli
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"sync"
+	"testing"
+
+	"maunium.net/go/mautrix/event"
+	"maunium.net/go/mautrix/id"
+
+	"go.mau.fi/gomuks/pkg/hicli/database"
+	"go.mau.fi/gomuks/pkg/hicli/jsoncmd"
+)
+
+const resetTestRoomID id.RoomID = "!reset:example.com"
+
+// fakeRoomServer serves the room state and message endpoints used by ResetRoom and pagination.
+// The messages are paginated in two pages: the "from" token selects the page.
+type fakeRoomServer struct {
+	lock      sync.Mutex
+	fromCalls []string
+}
+
+func (frs *fakeRoomServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	var resp any
+	switch {
+	case strings.HasSuffix(r.URL.Path, "/state"):
+		resp = []map[string]any{
+			resetTestEvent("$create", event.StateCreate.Type, "", map[string]any{"room_version": "11"}, 1),
+			resetTestEvent("$member", event.StateMember.Type, testUserID.String(), map[string]any{"membership": "join"}, 2),
+			resetTestEvent("$name", event.StateRoomName.Type, "", map[string]any{"name": "Reset room"}, 3),
+		}
+	case strings.HasSuffix(r.URL.Path, "/messages"):
+		from := r.URL.Query().Get("from")
+		frs.lock.Lock()
+		frs.fromCalls = append(frs.fromCalls, from)
+		frs.lock.Unlock()
+		switch from {
+		case "":
+			resp = map[string]any{"start": "t3", "end": "t2", "chunk": []map[string]any{
+				resetTestMessage("$new3", 30), resetTestMessage("$new2", 20),
+			}}
+		case "t2":
+			resp = map[string]any{"start": "t2", "chunk": []map[string]any{
+				resetTestMessage("$new1", 10),
+			}}
+		default:
+			http.Error(w, `{"errcode":"M_UNKNOWN","error":"unknown token"}`, http.StatusBadRequest)
+			return
+		}
+	default:
+		http.Error(w, `{"errcode":"M_UNRECOGNIZED","error":"unrecognized request"}`, http.StatusNotFound)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(resp)
+}
+
+func (frs *fakeRoomServer) froms() []string {
+	frs.lock.Lock()
+	defer frs.lock.Unlock()
+	return append([]string(nil), frs.fromCalls...)
+}
+
+func resetTestEvent(evtID, evtType, stateKey string, content map[string]any, ts int64) map[string]any {
+	evt := map[string]any{
+		"event_id":         evtID,
+		"room_id":          resetTestRoomID,
+		"sender":           testUserID,
+		"type":             evtType,
+		"origin_server_ts": ts,
+		"content":          content,
+	}
+	if evtType != event.EventMessage.Type {
+		evt["state_key"] = stateKey
+	}
+	return evt
+}
+
+func resetTestMessage(evtID string, ts int64) map[string]any {
+	return resetTestEvent(evtID, event.EventMessage.Type, "", map[string]any{"msgtype": "m.text", "body": evtID}, ts)
+}
+
+func countRows(t *testing.T, h *HiClient, query string, args ...any) int {
+	t.Helper()
+	var count int
+	err := h.DB.QueryRow(context.Background(), query, args...).Scan(&count)
+	if err != nil {
+		t.Fatalf("Failed to count rows: %v", err)
+	}
+	return count
+}
+
+func TestResetRoom(t *testing.T) {
+	ctx := context.Background()
+	h, events := newTestClient(t)
+	fakeServer := &fakeRoomServer{}
+	srv := httptest.NewServer(fakeServer)
+	t.Cleanup(srv.Close)
+	h.Client.HomeserverURL, _ = url.Parse(srv.URL)
+	h.Client.AccessToken = "fake"
+
+	// Populate the room with a timeline that has a gap, stale state and a pagination token
+	putTestMember(t, h, resetTestRoomID, testUserID, event.MembershipJoin)
+	staleTopic := putTestState(t, h, resetTestRoomID, event.StateTopic, "", &event.TopicEventContent{Topic: "old"})
+	var oldRowIDs []database.EventRowID
+	for i := range 3 {
+		evt := putTestEvent(t, h, resetTestRoomID, testUserID, event.EventMessage, nil, &event.MessageEventContent{
+			MsgType: event.MsgText, Body: fmt.Sprintf("old %d", i),
+		})
+		oldRowIDs = append(oldRowIDs, evt.RowID)
+	}
+	tuples, err := h.DB.Timeline.Append(ctx, resetTestRoomID, oldRowIDs)
+	if err != nil {
+		t.Fatalf("Failed to append timeline: %v", err)
+	}
+	err = h.DB.Timeline.PutGap(ctx, &database.TimelineGap{
+		RoomID: resetTestRoomID, TimelineRowID: tuples[1].Timeline, PrevBatch: "gap_token", MissedCount: 5,
+	})
+	if err != nil {
+		t.Fatalf("Failed to put gap: %v", err)
+	}
+	if err = h.DB.Room.SetPrevBatch(ctx, resetTestRoomID, "old_token"); err != nil {
+		t.Fatalf("Failed to set prev_batch: %v", err)
+	}
+	if n := countRows(t, h, "SELECT COUNT(*) FROM timeline_gap WHERE room_id=$1", resetTestRoomID); n != 1 {
+		t.Fatalf("Expected 1 gap before reset, got %d", n)
+	}
+
+	if err = h.ResetRoom(ctx, resetTestRoomID, 2); err != nil {
+		t.Fatalf("ResetRoom failed: %v", err)
+	}
+
+	if n := countRows(t, h, "SELECT COUNT(*) FROM timeline_gap WHERE room_id=$1", resetTestRoomID); n != 0 {
+		t.Errorf("Expected gaps to be deleted, got %d", n)
+	}
+	for _, rowID := range oldRowIDs {
+		if n := countRows(t, h, "SELECT COUNT(*) FROM timeline WHERE event_rowid=$1", rowID); n != 0 {
+			t.Errorf("Old event %d is still in the timeline", rowID)
+		}
+	}
+	if n := countRows(t, h, "SELECT COUNT(*) FROM current_state WHERE event_rowid=$1", staleTopic.RowID); n != 0 {
+		t.Error("Stale topic is still in the current state")
+	}
+	if n := countRows(t, h, "SELECT COUNT(*) FROM current_state WHERE room_id=$1", resetTestRoomID); n != 3 {
+		t.Errorf("Expected 3 current state entries from the server, got %d", n)
+	}
+	// The old prev_batch must not be used: the refetch starts from the latest messages
+	if froms := fakeServer.froms(); len(froms) != 1 || froms[0] != "" {
+		t.Errorf("Expected one /messages request without a from token, got %q", froms)
+	}
+	room, err := h.DB.Room.Get(ctx, resetTestRoomID)
+	if err != nil {
+		t.Fatalf("Failed to get room: %v", err)
+	} else if room.PrevBatch != "t2" {
+		t.Errorf("Expected prev_batch to be the token from the refetch, got %q", room.PrevBatch)
+	}
+	timeline, err := h.DB.Timeline.Get(ctx, resetTestRoomID, 10, 0)
+	if err != nil {
+		t.Fatalf("Failed to get timeline: %v", err)
+	} else if len(timeline) != 2 || timeline[0].ID != "$new3" || timeline[1].ID != "$new2" {
+		t.Fatalf("Unexpected timeline after reset: %+v", timeline)
+	}
+	var resetPayload *jsoncmd.SyncRoom
+	for _, evt := range events.all() {
+		if sc, ok := evt.(*jsoncmd.SyncComplete); ok && sc.Rooms[resetTestRoomID] != nil {
+			resetPayload = sc.Rooms[resetTestRoomID]
+		}
+	}
+	if resetPayload == nil {
+		t.Fatal("No sync payload was dispatched")
+	} else if !resetPayload.Reset || len(resetPayload.Timeline) != 2 {
+		t.Errorf("Unexpected reset payload: reset=%t, timeline=%+v", resetPayload.Reset, resetPayload.Timeline)
+	}
+
+	// Paginating from the oldest event must continue from the new token and prepend the older events
+	resp, err := h.Paginate(ctx, resetTestRoomID, timeline[1].TimelineRowID, 10, false)
+	if err != nil {
+		t.Fatalf("Paginate failed: %v", err)
+	} else if len(resp.Events) != 1 || resp.Events[0].ID != "$new1" {
+		t.Fatalf("Unexpected pagination result: %+v", resp.Events)
+	} else if resp.HasMore {
+		t.Error("Expected pagination to be complete")
+	} else if resp.Events[0].TimelineRowID >= timeline[1].TimelineRowID {
+		t.Errorf("Paginated event wasn't prepended (row %d, oldest %d)", resp.Events[0].TimelineRowID, timeline[1].TimelineRowID)
+	}
+	if froms := fakeServer.froms(); len(froms) != 2 || froms[1] != "t2" {
+		t.Errorf("Expected second /messages request to use the new token, got %q", froms)
+	}
+}
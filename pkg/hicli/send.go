@@ -13,7 +13,6 @@ import (
 	"fmt"
 	"strconv"
 	"strings"
-	"sync"
 	"time"
 
 	"github.com/rs/zerolog"
@@ -48,34 +47,6 @@ var (
 	defaultNoHTML   = goldmark.New(baseExtensions, format.HTMLOptions, goldmark.WithExtensions(mdext.EscapeHTML))
 )
 
-var htmlToMarkdownForInput = ptr.Clone(format.MarkdownHTMLParser)
-
-func init() {
-	htmlToMarkdownForInput.PillConverter = func(displayname, mxid, eventID string, ctx format.Context) string {
-		switch {
-		case len(mxid) == 0, mxid[0] == '@':
-			return fmt.Sprintf("[%s](%s)", displayname, id.UserID(mxid).URI().MatrixToURL())
-		case len(eventID) > 0:
-			return fmt.Sprintf("[%s](%s)", displayname, id.RoomID(mxid).EventURI(id.EventID(eventID)).MatrixToURL())
-		case mxid[0] == '!' && displayname == mxid:
-			return fmt.Sprintf("[%s](%s)", displayname, id.RoomID(mxid).URI().MatrixToURL())
-		case mxid[0] == '#':
-			return fmt.Sprintf("[%s](%s)", displayname, id.RoomAlias(mxid).URI().MatrixToURL())
-		default:
-			return htmlToMarkdownForInput.LinkConverter(displayname, "https://matrix.to/#/"+mxid, ctx)
-		}
-	}
-	htmlToMarkdownForInput.ImageConverter = func(src, alt, title, width, height string, isEmoji bool) string {
-		if isEmoji {
-			return fmt.Sprintf(`![%s](%s %q)`, alt, src, "Emoji: "+title)
-		} else if title != "" {
-			return fmt.Sprintf(`![%s](%s %q)`, alt, src, title)
-		} else {
-			return fmt.Sprintf(`![%s](%s)`, alt, src)
-		}
-	}
-}
-
 func (h *HiClient) SendMessage(
 	ctx context.Context,
 	roomID id.RoomID,
@@ -85,6 +56,7 @@ func (h *HiClient) SendMessage(
 	relatesTo *event.RelatesTo,
 	mentions *event.Mentions,
 	urlPreviews []*event.BeeperLinkPreview,
+	splitCodeBlocks bool,
 ) (*database.Event, error) {
 	hasCommand := base != nil && base.MSC4391BotCommand != nil
 	if hasCommand && mentions.Has(cmdspec.FakeGomuksSender) && len(mentions.UserIDs) == 1 {
@@ -111,6 +83,8 @@ func (h *HiClient) SendMessage(
 		rawInputBody = true
 	}
 	var content event.MessageEventContent
+	// splittable is true for plain markdown messages, which can be sent as multiple events if they're too long
+	var splittable bool
 	msgType := event.MsgText
 	origText := text
 	if strings.HasPrefix(text, "/me ") {
@@ -140,6 +114,7 @@ func (h *HiClient) SendMessage(
 			}
 		}
 		content = format.RenderMarkdownCustom(text, defaultNoHTML)
+		splittable = !rawInputBody && base == nil
 	}
 	if rawInputBody {
 		content.Body = text
@@ -203,6 +178,15 @@ func (h *HiClient) SendMessage(
 		content.MsgType = ""
 		evtType = event.EventSticker
 	}
+	tooLarge, room, err := h.isTooLarge(ctx, roomID, evtType, &content, extra, unencrypted)
+	if err != nil {
+		return nil, err
+	} else if tooLarge {
+		if !splittable || content.RelatesTo.GetReplaceID() != "" {
+			return nil, ErrMessageTooLarge
+		}
+		return h.sendSplitMessage(ctx, room, &content, extra, text, unencrypted, ts, splitCodeBlocks)
+	}
 	return h.send(ctx, roomID, evtType, &event.Content{Parsed: content, Raw: extra}, origText, unencrypted, false, ts)
 }
 
@@ -213,6 +197,11 @@ func (h *HiClient) MarkRead(ctx context.Context, roomID id.RoomID, eventID id.Ev
 	} else if room == nil {
 		return fmt.Errorf("unknown room")
 	}
+	if evt, err := h.DB.Event.GetByID(ctx, eventID); err != nil {
+		return fmt.Errorf("failed to get event: %w", err)
+	} else if evt != nil && evt.LocalContent.GetImported() {
+		return fmt.Errorf("can't send read receipts for imported events")
+	}
 	content := &mautrix.ReqSetReadMarkers{
 		FullyRead: eventID,
 	}
@@ -287,6 +276,8 @@ func (h *HiClient) Resend(ctx context.Context, txnID string) (*database.Event, e
 		return nil, fmt.Errorf("failed to get event by transaction ID: %w", err)
 	} else if dbEvt == nil {
 		return nil, fmt.Errorf("unknown transaction ID")
+	} else if dbEvt.LocalContent.GetImported() {
+		return nil, fmt.Errorf("can't resend imported events")
 	} else if dbEvt.ID != "" && !strings.HasPrefix(dbEvt.ID.String(), "~") {
 		return nil, fmt.Errorf("event was already sent successfully")
 	}
@@ -297,10 +288,38 @@ func (h *HiClient) Resend(ctx context.Context, txnID string) (*database.Event, e
 		return nil, fmt.Errorf("unknown room")
 	}
 	dbEvt.SendError = ""
+	// If this event is blocking the send queue, it'll be put back at the front and the rest of the queue is released.
+	var changedPositions map[string]int
+	dbEvt.QueuePosition, changedPositions = h.getSendQueue(room.ID).enqueue(dbEvt)
+	h.emitQueuePositions(room.ID, changedPositions)
 	go h.actuallySend(context.WithoutCancel(ctx), room, dbEvt, event.Type{Type: dbEvt.Type, Class: event.MessageEventType}, false, false)
 	return dbEvt, nil
 }
 
+// DiscardEvent marks a failed outgoing event as discarded, so that frontends stop showing it.
+func (h *HiClient) DiscardEvent(ctx context.Context, txnID string) error {
+	dbEvt, err := h.DB.Event.GetByTransactionID(ctx, txnID)
+	if err != nil {
+		return fmt.Errorf("failed to get event by transaction ID: %w", err)
+	} else if dbEvt == nil {
+		return fmt.Errorf("unknown transaction ID")
+	} else if dbEvt.ID != "" && !strings.HasPrefix(dbEvt.ID.String(), "~") {
+		return fmt.Errorf("event was already sent successfully")
+	}
+	changedPositions, ok := h.getSendQueue(dbEvt.RoomID).discard(txnID)
+	if !ok {
+		return fmt.Errorf("event is still being sent")
+	}
+	err = h.DB.Event.MarkDiscarded(ctx, dbEvt.RowID)
+	if err != nil {
+		return fmt.Errorf("failed to mark event as discarded: %w", err)
+	}
+	dbEvt.SendError = database.SendErrorDiscarded
+	h.EventHandler(&jsoncmd.SendComplete{Event: dbEvt})
+	h.emitQueuePositions(dbEvt.RoomID, changedPositions)
+	return nil
+}
+
 func (h *HiClient) send(
 	ctx context.Context,
 	roomID id.RoomID,
@@ -326,8 +345,9 @@ func (h *HiClient) send(
 		Unsigned:        []byte("{}"),
 		TransactionID:   txnID,
 		DecryptionError: "",
-		SendError:       "not sent",
+		SendError:       database.SendErrorNotSent,
 		Reactions:       map[string]int{},
+		ReactionSenders: map[string]map[id.UserID]id.EventID{},
 		LastEditRowID:   ptr.Ptr(database.EventRowID(0)),
 	}
 	var overrideTimestamp bool
@@ -376,22 +396,14 @@ func (h *HiClient) send(
 	if synchronous {
 		h.actuallySend(ctx, room, dbEvt, evtType, true, overrideTimestamp)
 	} else {
+		var changedPositions map[string]int
+		dbEvt.QueuePosition, changedPositions = h.getSendQueue(room.ID).enqueue(dbEvt)
+		h.emitQueuePositions(room.ID, changedPositions)
 		go h.actuallySend(ctx, room, dbEvt, evtType, false, overrideTimestamp)
 	}
 	return dbEvt, nil
 }
 
-func (h *HiClient) getSendLock(roomID id.RoomID) *sync.Mutex {
-	h.sendLockLock.Lock()
-	defer h.sendLockLock.Unlock()
-	l, ok := h.sendLock[roomID]
-	if !ok {
-		l = &sync.Mutex{}
-		h.sendLock[roomID] = l
-	}
-	return l
-}
-
 func (h *HiClient) actuallySend(
 	ctx context.Context,
 	room *database.Room,
@@ -400,13 +412,11 @@ func (h *HiClient) actuallySend(
 	synchronous bool,
 	overrideTimestamp bool,
 ) {
-	if !synchronous {
-		l := h.getSendLock(room.ID)
-		l.Lock()
-		defer l.Unlock()
-	}
 	var err error
 	defer func() {
+		if cooldown := h.getSendQueue(room.ID).updateCooldown(room.ID, err); cooldown != nil {
+			h.EventHandler(cooldown)
+		}
 		if dbEvt.SendError != "" {
 			err2 := h.DB.Event.UpdateSendError(ctx, dbEvt.RowID, dbEvt.SendError)
 			if err2 != nil {
@@ -415,12 +425,33 @@ func (h *HiClient) actuallySend(
 			}
 		}
 		if !synchronous {
+			var sendErr error
+			if dbEvt.SendError != "" {
+				sendErr = err
+			}
+			changedPositions := h.getSendQueue(room.ID).done(dbEvt, sendErr)
+			// The event may still be in use by the caller, so the queue position is cleared in a copy
+			completedEvt := *dbEvt
+			completedEvt.QueuePosition = 0
 			h.EventHandler(&jsoncmd.SendComplete{
-				Event: dbEvt,
+				Event: &completedEvt,
 				Error: err,
 			})
+			h.emitQueuePositions(room.ID, changedPositions)
 		}
 	}()
+	if !synchronous {
+		err = h.getSendQueue(room.ID).waitTurn(ctx, dbEvt)
+		if err != nil {
+			dbEvt.SendError = err.Error()
+			return
+		}
+		err = h.resolveLocalReplyFallback(ctx, dbEvt)
+		if err != nil {
+			dbEvt.SendError = fmt.Sprintf("failed to resolve reply fallback: %v", err)
+			return
+		}
+	}
 	if dbEvt.Decrypted != nil && len(dbEvt.Content) <= 2 {
 		var encryptedContent *event.EncryptedEventContent
 		encryptedContent, err = h.Encrypt(ctx, room, evtType, dbEvt.Decrypted)
@@ -561,6 +592,7 @@ func (h *HiClient) shareGroupSession(ctx context.Context, room *database.Room) e
 	} else if err = h.Crypto.ShareGroupSession(ctx, room.ID, users); err != nil {
 		return fmt.Errorf("failed to share group session: %w", err)
 	}
+	h.recordGroupSessionShare(ctx, room.ID, users)
 	return nil
 }
 
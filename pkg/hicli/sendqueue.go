@@ -0,0 +1,299 @@
+// Copyright (c) 2026 Tulir Asokan
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package hicli
+
+import (
+	"context"
+	"errors"
+	"net"
+	"net/http"
+	"slices"
+	"strconv"
+	"sync"
+	"time"
+
+	"go.mau.fi/util/jsontime"
+	"maunium.net/go/mautrix"
+	"maunium.net/go/mautrix/id"
+
+	"go.mau.fi/gomuks/pkg/hicli/database"
+	"go.mau.fi/gomuks/pkg/hicli/jsoncmd"
+)
+
+// MaxSendQueueBlockTime is how long queued events wait for a failed event ahead of them to be resent.
+// After the time runs out, the queued events fail too, so that they're not sent out of order.
+var MaxSendQueueBlockTime = 2 * time.Minute
+
+// DefaultSendCooldown is the cooldown after a rate limit error that doesn't specify how long to wait.
+// It's doubled for each consecutive error up to MaxDefaultSendCooldown.
+var (
+	DefaultSendCooldown    = 5 * time.Second
+	MaxDefaultSendCooldown = 2 * time.Minute
+)
+
+// SendCooldownDecay is how long after a cooldown expires the consecutive rate limit error count is reset.
+var SendCooldownDecay = 5 * time.Minute
+
+var (
+	ErrSendQueueBlocked = errors.New("an earlier event in the room failed to send")
+	ErrSendQueueFlushed = errors.New("send queue was flushed")
+)
+
+// sendQueue makes sure asynchronous sends in a room go out in the order they were made.
+//
+// If an event fails to send with a retryable error, the queue is blocked until the event is resent,
+// the queue is flushed or MaxSendQueueBlockTime passes.
+type sendQueue struct {
+	lock     sync.Mutex
+	items    []*database.Event
+	inFlight *database.Event
+	// Closed and replaced whenever the queue changes, to wake up waiting senders
+	changed chan struct{}
+	// positions are the last queue positions sent to frontends by transaction ID. They're not stored
+	// in the events themselves, as the events may be serialized elsewhere while the queue changes.
+	positions map[string]int
+
+	blockedBy    string
+	blockedUntil time.Time
+
+	// cooldownUntil is when the server is expected to allow sending again after rate limiting the previous send.
+	cooldownUntil time.Time
+	// cooldownCount is the number of consecutive rate limit errors, used when the server doesn't say how long to wait.
+	cooldownCount int
+}
+
+func (h *HiClient) getSendQueue(roomID id.RoomID) *sendQueue {
+	h.sendQueuesLock.Lock()
+	defer h.sendQueuesLock.Unlock()
+	q, ok := h.sendQueues[roomID]
+	if !ok {
+		q = &sendQueue{changed: make(chan struct{})}
+		h.sendQueues[roomID] = q
+	}
+	return q
+}
+
+// notifyChangedLocked wakes up waiters and recalculates the queue positions of events.
+// It returns the positions that changed by transaction ID, which should be sent to frontends.
+func (q *sendQueue) notifyChangedLocked() map[string]int {
+	close(q.changed)
+	q.changed = make(chan struct{})
+	positions := make(map[string]int, len(q.items))
+	changed := make(map[string]int)
+	for i, evt := range q.items {
+		positions[evt.TransactionID] = i
+		if q.positions[evt.TransactionID] != i {
+			changed[evt.TransactionID] = i
+		}
+	}
+	q.positions = positions
+	return changed
+}
+
+func (q *sendQueue) isBlockedLocked() bool {
+	return q.blockedBy != ""
+}
+
+// enqueue adds an event to the queue. If the event is a resend of the event blocking the queue,
+// it's put at the front and the queue is unblocked.
+//
+// The position of the new event is returned separately from the changed positions of other events,
+// as the caller is expected to set it in the event and return it to the frontend directly.
+func (q *sendQueue) enqueue(evt *database.Event) (position int, changed map[string]int) {
+	q.lock.Lock()
+	defer q.lock.Unlock()
+	if q.isBlockedLocked() && q.blockedBy == evt.TransactionID {
+		q.blockedBy = ""
+		insertIdx := 0
+		if q.inFlight != nil {
+			insertIdx = 1
+		}
+		q.items = slices.Insert(q.items, insertIdx, evt)
+	} else {
+		if len(q.items) == 0 && q.isBlockedLocked() && time.Now().After(q.blockedUntil) {
+			// Everything that was queued behind the failed event has already failed, so don't block new events.
+			q.blockedBy = ""
+		}
+		q.items = append(q.items, evt)
+	}
+	changed = q.notifyChangedLocked()
+	position = q.positions[evt.TransactionID]
+	delete(changed, evt.TransactionID)
+	return
+}
+
+// waitTurn blocks until the given event is at the front of the queue and the queue isn't blocked.
+func (q *sendQueue) waitTurn(ctx context.Context, evt *database.Event) error {
+	for {
+		q.lock.Lock()
+		idx := slices.Index(q.items, evt)
+		blocked := q.isBlockedLocked()
+		blockedUntil := q.blockedUntil
+		changed := q.changed
+		if idx == 0 && !blocked {
+			q.inFlight = evt
+		}
+		q.lock.Unlock()
+		if idx == -1 {
+			return ErrSendQueueFlushed
+		} else if idx == 0 && !blocked {
+			return nil
+		} else if idx == 0 && time.Now().After(blockedUntil) {
+			return ErrSendQueueBlocked
+		}
+		var timeout <-chan time.Time
+		var timer *time.Timer
+		if idx == 0 {
+			timer = time.NewTimer(time.Until(blockedUntil))
+			timeout = timer.C
+		}
+		select {
+		case <-changed:
+		case <-timeout:
+		case <-ctx.Done():
+		}
+		if timer != nil {
+			timer.Stop()
+		}
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+	}
+}
+
+// done removes the event from the queue. If it failed with a retryable error, the queue is blocked.
+func (q *sendQueue) done(evt *database.Event, err error) map[string]int {
+	q.lock.Lock()
+	defer q.lock.Unlock()
+	idx := slices.Index(q.items, evt)
+	if idx == -1 {
+		return nil
+	}
+	q.items = slices.Delete(q.items, idx, idx+1)
+	if q.inFlight == evt {
+		q.inFlight = nil
+	}
+	if err != nil && isRetryableSendError(err) && !errors.Is(err, ErrSendQueueBlocked) {
+		q.blockedBy = evt.TransactionID
+		q.blockedUntil = time.Now().Add(MaxSendQueueBlockTime)
+	}
+	return q.notifyChangedLocked()
+}
+
+// flush removes all events that aren't being sent yet and unblocks the queue.
+func (q *sendQueue) flush() int {
+	q.lock.Lock()
+	defer q.lock.Unlock()
+	q.blockedBy = ""
+	dropped := len(q.items)
+	// The event that's already in flight can't be cancelled anymore.
+	if q.inFlight != nil {
+		q.items = []*database.Event{q.inFlight}
+		dropped--
+	} else {
+		q.items = nil
+	}
+	q.notifyChangedLocked()
+	return dropped
+}
+
+// discard unblocks the queue if it's blocked by the given event. It returns false if the event
+// is still in the queue, as only events that have already failed can be discarded.
+func (q *sendQueue) discard(txnID string) (changed map[string]int, ok bool) {
+	q.lock.Lock()
+	defer q.lock.Unlock()
+	if slices.ContainsFunc(q.items, func(evt *database.Event) bool {
+		return evt.TransactionID == txnID
+	}) {
+		return nil, false
+	}
+	if q.blockedBy == txnID {
+		q.blockedBy = ""
+		changed = q.notifyChangedLocked()
+	}
+	return changed, true
+}
+
+// updateCooldown updates the rate limit state after a send finished. It returns nil if the state didn't change.
+func (q *sendQueue) updateCooldown(roomID id.RoomID, err error) *jsoncmd.SendCooldown {
+	q.lock.Lock()
+	defer q.lock.Unlock()
+	now := time.Now()
+	retryAfter, rateLimited := getRetryAfter(err)
+	if !rateLimited {
+		if err != nil || q.cooldownCount == 0 {
+			return nil
+		}
+		q.cooldownCount = 0
+		q.cooldownUntil = time.Time{}
+		return &jsoncmd.SendCooldown{RoomID: roomID}
+	}
+	if now.Sub(q.cooldownUntil) > SendCooldownDecay {
+		q.cooldownCount = 0
+	}
+	q.cooldownCount++
+	if retryAfter <= 0 {
+		// Cap the shift so that a long streak of errors doesn't overflow the duration
+		retryAfter = min(DefaultSendCooldown<<min(q.cooldownCount-1, 16), MaxDefaultSendCooldown)
+	}
+	q.cooldownUntil = now.Add(retryAfter)
+	return &jsoncmd.SendCooldown{
+		RoomID: roomID,
+		Until:  jsontime.UM(q.cooldownUntil),
+		Count:  q.cooldownCount,
+	}
+}
+
+// getRetryAfter checks if the error is a rate limit error and returns the time the server asked to wait, if any.
+func getRetryAfter(err error) (time.Duration, bool) {
+	var httpErr mautrix.HTTPError
+	if !errors.As(err, &httpErr) || httpErr.Response == nil || httpErr.Response.StatusCode != http.StatusTooManyRequests {
+		return 0, false
+	}
+	if httpErr.RespError != nil {
+		if retryAfterMS, ok := httpErr.RespError.ExtraData["retry_after_ms"].(float64); ok && retryAfterMS > 0 {
+			return time.Duration(retryAfterMS) * time.Millisecond, true
+		}
+	}
+	if retryAfterSec, err := strconv.Atoi(httpErr.Response.Header.Get("Retry-After")); err == nil && retryAfterSec > 0 {
+		return time.Duration(retryAfterSec) * time.Second, true
+	}
+	return 0, true
+}
+
+func isRetryableSendError(err error) bool {
+	if errors.Is(err, context.Canceled) {
+		// The send was cancelled on purpose, so there's no point in holding back the rest of the queue
+		return false
+	} else if errors.Is(err, context.DeadlineExceeded) {
+		// Sends aren't cancelled by the caller, so a deadline can only come from a request timing out
+		return true
+	}
+	var httpErr mautrix.HTTPError
+	if errors.As(err, &httpErr) {
+		// Requests that didn't get a response at all failed because of the network
+		return httpErr.Response == nil ||
+			httpErr.Response.StatusCode >= 500 ||
+			httpErr.Response.StatusCode == http.StatusTooManyRequests
+	}
+	// Local errors like failing to encrypt won't go away by waiting, so only network errors are retried
+	var netErr net.Error
+	return errors.As(err, &netErr)
+}
+
+func (h *HiClient) emitQueuePositions(roomID id.RoomID, positions map[string]int) {
+	if len(positions) == 0 {
+		return
+	}
+	h.EventHandler(&jsoncmd.SendQueueUpdate{RoomID: roomID, Positions: positions})
+}
+
+// FlushSendQueue drops all events that are waiting to be sent in the given room.
+// The dropped events are marked as failed, so they can be resent or discarded individually.
+func (h *HiClient) FlushSendQueue(roomID id.RoomID) int {
+	return h.getSendQueue(roomID).flush()
+}
@@ -0,0 +1,424 @@
+// Copyright (c) 2026 Tulir Asokan
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package hicli
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"maps"
+	"net"
+	"net/http"
+	"net/url"
+	"testing"
+	"time"
+
+	"maunium.net/go/mautrix"
+
+	"go.mau.fi/gomuks/pkg/hicli/database"
+)
+
+func httpSendError(status int) error {
+	return mautrix.HTTPError{
+		Request:  &http.Request{},
+		Response: &http.Response{StatusCode: status},
+		Message:  "failed to send",
+	}
+}
+
+func TestIsRetryableSendError(t *testing.T) {
+	tests := []struct {
+		name string
+		err  error
+		want bool
+	}{
+		{"encryption error", errors.New("failed to encrypt"), false},
+		{"wrapped local error", fmt.Errorf("failed to marshal: %w", errors.New("meow")), false},
+		{"connection error", mautrix.HTTPError{WrappedError: errors.New("connection refused")}, true},
+		{"network error", &net.OpError{Op: "dial", Net: "tcp", Err: errors.New("connection refused")}, true},
+		{"timeout", &url.Error{Op: "Put", URL: "https://example.com", Err: timeoutError{}}, true},
+		{"wrapped network error", fmt.Errorf("failed to send: %w", &net.DNSError{Err: "no such host", IsNotFound: true}), true},
+		{"server error", httpSendError(http.StatusBadGateway), true},
+		{"rate limited", httpSendError(http.StatusTooManyRequests), true},
+		{"forbidden", httpSendError(http.StatusForbidden), false},
+		{"bad request", httpSendError(http.StatusBadRequest), false},
+		{"canceled", context.Canceled, false},
+		{"deadline exceeded", context.DeadlineExceeded, true},
+		{"timed out request", mautrix.HTTPError{WrappedError: context.DeadlineExceeded}, true},
+		{"wrapped canceled", fmt.Errorf("failed to send: %w", context.Canceled), false},
+		{"canceled request", mautrix.HTTPError{WrappedError: context.Canceled}, false},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if got := isRetryableSendError(test.err); got != test.want {
+				t.Errorf("isRetryableSendError(%v) = %t, want %t", test.err, got, test.want)
+			}
+		})
+	}
+}
+
+type timeoutError struct{}
+
+func (timeoutError) Error() string   { return "i/o timeout" }
+func (timeoutError) Timeout() bool   { return true }
+func (timeoutError) Temporary() bool { return true }
+
+func newTestQueueEvent(txnID string) *database.Event {
+	return &database.Event{TransactionID: txnID}
+}
+
+func waitTurnResult(q *sendQueue, evt *database.Event) <-chan error {
+	ch := make(chan error, 1)
+	go func() {
+		ch <- q.waitTurn(context.Background(), evt)
+	}()
+	return ch
+}
+
+func expectWaiting(t *testing.T, ch <-chan error) {
+	t.Helper()
+	select {
+	case err := <-ch:
+		t.Fatalf("waitTurn returned %v while it should've been waiting", err)
+	case <-time.After(20 * time.Millisecond):
+	}
+}
+
+func expectTurn(t *testing.T, ch <-chan error, want error) {
+	t.Helper()
+	select {
+	case err := <-ch:
+		if !errors.Is(err, want) {
+			t.Fatalf("waitTurn returned %v, want %v", err, want)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("waitTurn didn't return")
+	}
+}
+
+func TestSendQueue_BlockAndResend(t *testing.T) {
+	q := &sendQueue{changed: make(chan struct{})}
+	first, second := newTestQueueEvent("txn1"), newTestQueueEvent("txn2")
+	q.enqueue(first)
+	if position, changed := q.enqueue(second); len(changed) != 0 {
+		t.Errorf("Enqueueing changed positions of other events: %v", changed)
+	} else if position != 1 {
+		t.Errorf("Second event has queue position %d, want 1", position)
+	}
+	if err := q.waitTurn(context.Background(), first); err != nil {
+		t.Fatalf("First event didn't get its turn: %v", err)
+	}
+	secondTurn := waitTurnResult(q, second)
+	expectWaiting(t, secondTurn)
+
+	changed := q.done(first, httpSendError(http.StatusBadGateway))
+	if q.blockedBy != "txn1" {
+		t.Fatalf("Queue blocked by %q after retryable error, want txn1", q.blockedBy)
+	} else if !maps.Equal(changed, map[string]int{"txn2": 0}) {
+		t.Errorf("Positions changed to %v, want second event at 0", changed)
+	}
+	expectWaiting(t, secondTurn)
+
+	// Resending the failed event puts it back at the front and unblocks the queue
+	resent := newTestQueueEvent("txn1")
+	position, changed := q.enqueue(resent)
+	if q.isBlockedLocked() {
+		t.Error("Queue still blocked after resend")
+	} else if position != 0 {
+		t.Errorf("Resent event has queue position %d, want 0", position)
+	} else if !maps.Equal(changed, map[string]int{"txn2": 1}) {
+		t.Errorf("Positions changed to %v, want second event back at 1", changed)
+	}
+	// Positions are only published through the returned maps, the events are never modified
+	if first.QueuePosition != 0 || second.QueuePosition != 0 || resent.QueuePosition != 0 {
+		t.Error("Queue modified the queue position in an event")
+	}
+	if err := q.waitTurn(context.Background(), resent); err != nil {
+		t.Fatalf("Resent event didn't get its turn: %v", err)
+	}
+	expectWaiting(t, secondTurn)
+	q.done(resent, nil)
+	expectTurn(t, secondTurn, nil)
+}
+
+func TestSendQueue_NonRetryableErrorDoesNotBlock(t *testing.T) {
+	for _, err := range []error{
+		httpSendError(http.StatusForbidden), errors.New("failed to encrypt"), context.Canceled,
+	} {
+		t.Run(err.Error(), func(t *testing.T) {
+			q := &sendQueue{changed: make(chan struct{})}
+			first, second := newTestQueueEvent("txn1"), newTestQueueEvent("txn2")
+			q.enqueue(first)
+			q.enqueue(second)
+			if err := q.waitTurn(context.Background(), first); err != nil {
+				t.Fatalf("First event didn't get its turn: %v", err)
+			}
+			secondTurn := waitTurnResult(q, second)
+			q.done(first, err)
+			if q.isBlockedLocked() {
+				t.Errorf("Queue blocked after %v", err)
+			}
+			expectTurn(t, secondTurn, nil)
+		})
+	}
+}
+
+func TestSendQueue_BlockTimeout(t *testing.T) {
+	origBlockTime := MaxSendQueueBlockTime
+	MaxSendQueueBlockTime = 50 * time.Millisecond
+	t.Cleanup(func() {
+		MaxSendQueueBlockTime = origBlockTime
+	})
+	q := &sendQueue{changed: make(chan struct{})}
+	first, second := newTestQueueEvent("txn1"), newTestQueueEvent("txn2")
+	q.enqueue(first)
+	q.enqueue(second)
+	if err := q.waitTurn(context.Background(), first); err != nil {
+		t.Fatalf("First event didn't get its turn: %v", err)
+	}
+	q.done(first, httpSendError(http.StatusBadGateway))
+	expectTurn(t, waitTurnResult(q, second), ErrSendQueueBlocked)
+	q.done(second, ErrSendQueueBlocked)
+	if q.blockedBy != "txn1" {
+		t.Errorf("Queue blocked by %q after timed out event, want txn1", q.blockedBy)
+	}
+	// Everything queued behind the failed event has failed, so a new event goes out immediately
+	third := newTestQueueEvent("txn3")
+	q.enqueue(third)
+	if q.isBlockedLocked() {
+		t.Error("Queue still blocked for new events after block time ran out")
+	}
+	expectTurn(t, waitTurnResult(q, third), nil)
+}
+
+func TestSendQueue_Flush(t *testing.T) {
+	q := &sendQueue{changed: make(chan struct{})}
+	first, second, third := newTestQueueEvent("txn1"), newTestQueueEvent("txn2"), newTestQueueEvent("txn3")
+	q.enqueue(first)
+	q.enqueue(second)
+	q.enqueue(third)
+	if err := q.waitTurn(context.Background(), first); err != nil {
+		t.Fatalf("First event didn't get its turn: %v", err)
+	}
+	secondTurn := waitTurnResult(q, second)
+	thirdTurn := waitTurnResult(q, third)
+	expectWaiting(t, secondTurn)
+
+	// The in-flight event can't be dropped
+	if dropped := q.flush(); dropped != 2 {
+		t.Errorf("Flush dropped %d events, want 2", dropped)
+	}
+	expectTurn(t, secondTurn, ErrSendQueueFlushed)
+	expectTurn(t, thirdTurn, ErrSendQueueFlushed)
+	if len(q.items) != 1 || q.items[0] != first {
+		t.Errorf("In-flight event was removed by flush")
+	}
+
+	// Flushing also unblocks the queue
+	q.done(first, httpSendError(http.StatusBadGateway))
+	if !q.isBlockedLocked() {
+		t.Fatal("Queue not blocked after retryable error")
+	}
+	if dropped := q.flush(); dropped != 0 {
+		t.Errorf("Flush dropped %d events from empty queue", dropped)
+	}
+	fourth := newTestQueueEvent("txn4")
+	q.enqueue(fourth)
+	expectTurn(t, waitTurnResult(q, fourth), nil)
+}
+
+func TestSendQueue_Discard(t *testing.T) {
+	q := &sendQueue{changed: make(chan struct{})}
+	first, second := newTestQueueEvent("txn1"), newTestQueueEvent("txn2")
+	q.enqueue(first)
+	q.enqueue(second)
+	if _, ok := q.discard("txn1"); ok {
+		t.Error("Discarding an event that's still queued succeeded")
+	}
+	if err := q.waitTurn(context.Background(), first); err != nil {
+		t.Fatalf("First event didn't get its turn: %v", err)
+	}
+	secondTurn := waitTurnResult(q, second)
+	q.done(first, httpSendError(http.StatusBadGateway))
+	expectWaiting(t, secondTurn)
+
+	if _, ok := q.discard("txn-other"); !ok {
+		t.Error("Discarding an unrelated failed event failed")
+	} else if !q.isBlockedLocked() {
+		t.Error("Discarding an unrelated event unblocked the queue")
+	}
+	expectWaiting(t, secondTurn)
+	if _, ok := q.discard("txn1"); !ok {
+		t.Error("Discarding the blocking event failed")
+	} else if q.isBlockedLocked() {
+		t.Error("Discarding the blocking event didn't unblock the queue")
+	}
+	expectTurn(t, secondTurn, nil)
+}
+
+func TestSendQueue_WaitTurnCanceled(t *testing.T) {
+	q := &sendQueue{changed: make(chan struct{})}
+	first, second := newTestQueueEvent("txn1"), newTestQueueEvent("txn2")
+	q.enqueue(first)
+	q.enqueue(second)
+	ctx, cancel := context.WithCancel(context.Background())
+	ch := make(chan error, 1)
+	go func() {
+		ch <- q.waitTurn(ctx, second)
+	}()
+	expectWaiting(t, ch)
+	cancel()
+	expectTurn(t, ch, context.Canceled)
+	// The cancelled event failing must not block the queue for the events after it
+	q.done(second, context.Canceled)
+	if q.isBlockedLocked() {
+		t.Error("Queue blocked after cancelled send")
+	}
+}
+
+func rateLimitError(retryAfterMS float64, retryAfterHeader string) error {
+	err := httpSendError(http.StatusTooManyRequests).(mautrix.HTTPError)
+	err.Response.Header = http.Header{}
+	if retryAfterHeader != "" {
+		err.Response.Header.Set("Retry-After", retryAfterHeader)
+	}
+	err.RespError = &mautrix.RespError{ErrCode: "M_LIMIT_EXCEEDED", ExtraData: map[string]any{}}
+	if retryAfterMS != 0 {
+		err.RespError.ExtraData["retry_after_ms"] = retryAfterMS
+	}
+	return err
+}
+
+func TestGetRetryAfter(t *testing.T) {
+	tests := []struct {
+		name            string
+		err             error
+		wantRetryAfter  time.Duration
+		wantRateLimited bool
+	}{
+		{"no error", nil, 0, false},
+		{"non-HTTP error", errors.New("failed to encrypt"), 0, false},
+		{"connection error", mautrix.HTTPError{WrappedError: errors.New("connection refused")}, 0, false},
+		{"server error", httpSendError(http.StatusBadGateway), 0, false},
+		{"no retry info", rateLimitError(0, ""), 0, true},
+		{"retry_after_ms", rateLimitError(1500, ""), 1500 * time.Millisecond, true},
+		{"Retry-After seconds", rateLimitError(0, "30"), 30 * time.Second, true},
+		{"retry_after_ms preferred over header", rateLimitError(1500, "30"), 1500 * time.Millisecond, true},
+		{"negative retry_after_ms falls back to header", rateLimitError(-1, "30"), 30 * time.Second, true},
+		{"zero Retry-After", rateLimitError(0, "0"), 0, true},
+		{"negative Retry-After", rateLimitError(0, "-5"), 0, true},
+		{"Retry-After date", rateLimitError(0, "Wed, 21 Oct 2015 07:28:00 GMT"), 0, true},
+		{"garbage Retry-After", rateLimitError(0, "meow"), 0, true},
+		{"wrapped", fmt.Errorf("failed to send: %w", rateLimitError(0, "2")), 2 * time.Second, true},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			retryAfter, rateLimited := getRetryAfter(test.err)
+			if retryAfter != test.wantRetryAfter || rateLimited != test.wantRateLimited {
+				t.Errorf("getRetryAfter() = %s, %t, want %s, %t",
+					retryAfter, rateLimited, test.wantRetryAfter, test.wantRateLimited)
+			}
+		})
+	}
+}
+
+func TestSendQueue_UpdateCooldown(t *testing.T) {
+	type step struct {
+		err error
+		// If set, the previous cooldown is moved to have expired this long ago before the step.
+		expiredAgo time.Duration
+		// Whether the step changes the cooldown state, which is emitted to frontends.
+		wantUpdate bool
+		wantCount  int
+		wantWait   time.Duration
+	}
+	limited := rateLimitError(0, "")
+	tests := []struct {
+		name  string
+		steps []step
+	}{
+		{"default cooldown doubles", []step{
+			{err: limited, wantUpdate: true, wantCount: 1, wantWait: 5 * time.Second},
+			{err: limited, wantUpdate: true, wantCount: 2, wantWait: 10 * time.Second},
+			{err: limited, wantUpdate: true, wantCount: 3, wantWait: 20 * time.Second},
+		}},
+		{"default cooldown is capped", []step{
+			{err: limited, wantUpdate: true, wantCount: 1, wantWait: 5 * time.Second},
+			{err: limited, wantUpdate: true, wantCount: 2, wantWait: 10 * time.Second},
+			{err: limited, wantUpdate: true, wantCount: 3, wantWait: 20 * time.Second},
+			{err: limited, wantUpdate: true, wantCount: 4, wantWait: 40 * time.Second},
+			{err: limited, wantUpdate: true, wantCount: 5, wantWait: 80 * time.Second},
+			{err: limited, wantUpdate: true, wantCount: 6, wantWait: 2 * time.Minute},
+			{err: limited, wantUpdate: true, wantCount: 7, wantWait: 2 * time.Minute},
+		}},
+		{"server provided cooldown", []step{
+			{err: rateLimitError(1500, ""), wantUpdate: true, wantCount: 1, wantWait: 1500 * time.Millisecond},
+			{err: rateLimitError(0, "3"), wantUpdate: true, wantCount: 2, wantWait: 3 * time.Second},
+			// The consecutive count still grows, so the default is based on all errors in the streak
+			{err: limited, wantUpdate: true, wantCount: 3, wantWait: 20 * time.Second},
+		}},
+		{"success resets", []step{
+			{err: limited, wantUpdate: true, wantCount: 1, wantWait: 5 * time.Second},
+			{err: limited, wantUpdate: true, wantCount: 2, wantWait: 10 * time.Second},
+			{err: nil, wantUpdate: true, wantCount: 0},
+			{err: nil, wantUpdate: false, wantCount: 0},
+			{err: limited, wantUpdate: true, wantCount: 1, wantWait: 5 * time.Second},
+		}},
+		{"success without cooldown", []step{
+			{err: nil, wantUpdate: false, wantCount: 0},
+		}},
+		{"other errors keep cooldown", []step{
+			{err: limited, wantUpdate: true, wantCount: 1, wantWait: 5 * time.Second},
+			{err: httpSendError(http.StatusBadGateway), wantUpdate: false, wantCount: 1},
+			{err: context.Canceled, wantUpdate: false, wantCount: 1},
+			{err: limited, wantUpdate: true, wantCount: 2, wantWait: 10 * time.Second},
+		}},
+		{"recently expired cooldown keeps count", []step{
+			{err: limited, wantUpdate: true, wantCount: 1, wantWait: 5 * time.Second},
+			{err: limited, expiredAgo: time.Minute, wantUpdate: true, wantCount: 2, wantWait: 10 * time.Second},
+		}},
+		{"count decays after cooldown expiry", []step{
+			{err: limited, wantUpdate: true, wantCount: 1, wantWait: 5 * time.Second},
+			{err: limited, wantUpdate: true, wantCount: 2, wantWait: 10 * time.Second},
+			{err: limited, expiredAgo: SendCooldownDecay + time.Minute, wantUpdate: true, wantCount: 1, wantWait: 5 * time.Second},
+		}},
+	}
+	const roomID = "!room:example.com"
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			q := &sendQueue{changed: make(chan struct{})}
+			for i, step := range test.steps {
+				if step.expiredAgo > 0 {
+					q.cooldownUntil = time.Now().Add(-step.expiredAgo)
+				}
+				before := time.Now()
+				update := q.updateCooldown(roomID, step.err)
+				after := time.Now()
+				if (update != nil) != step.wantUpdate {
+					t.Fatalf("Step %d: got update %+v, want update: %t", i, update, step.wantUpdate)
+				}
+				if q.cooldownCount != step.wantCount {
+					t.Errorf("Step %d: cooldown count is %d, want %d", i, q.cooldownCount, step.wantCount)
+				}
+				if update == nil {
+					continue
+				} else if update.RoomID != roomID || update.Count != step.wantCount {
+					t.Errorf("Step %d: update has room %s and count %d, want %s and %d",
+						i, update.RoomID, update.Count, roomID, step.wantCount)
+				}
+				if step.wantWait == 0 {
+					if !update.Until.IsZero() || !q.cooldownUntil.IsZero() {
+						t.Errorf("Step %d: cooldown wasn't cleared: %s", i, update.Until.Time)
+					}
+				} else if until := update.Until.Time; until.Before(before.Add(step.wantWait).Truncate(time.Millisecond)) ||
+					until.After(after.Add(step.wantWait)) {
+					t.Errorf("Step %d: cooldown ends %s after the send, want %s", i, until.Sub(before), step.wantWait)
+				}
+			}
+		})
+	}
+}
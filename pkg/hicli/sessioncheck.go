@@ -0,0 +1,142 @@
+// Copyright (c) 2026 Tulir Asokan
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package hicli
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/rs/zerolog"
+	"maunium.net/go/mautrix"
+
+	"go.mau.fi/gomuks/pkg/hicli/jsoncmd"
+)
+
+// MaxClockSkew is how much the local clock may differ from the homeserver's clock before a warning is shown.
+var MaxClockSkew = 5 * time.Minute
+
+func unsupportedDatabaseProblem(err error) *jsoncmd.SessionProblem {
+	return &jsoncmd.SessionProblem{
+		Code:    jsoncmd.SessionProblemUnsupportedDatabase,
+		Message: "The local database was created by a newer version of gomuks. Please update gomuks or remove the data directory.",
+		Fatal:   true,
+		Details: err.Error(),
+	}
+}
+
+// checkSession validates the stored session against the homeserver before syncing is started.
+// Network errors are only logged, as they're not a sign of a broken session.
+func (h *HiClient) checkSession(ctx context.Context) []*jsoncmd.SessionProblem {
+	log := zerolog.Ctx(ctx)
+	var problems []*jsoncmd.SessionProblem
+	var whoami mautrix.RespWhoami
+	_, resp, err := h.Client.MakeFullRequestWithResp(ctx, mautrix.FullRequest{
+		Method:       http.MethodGet,
+		URL:          h.Client.BuildClientURL("v3", "account", "whoami"),
+		ResponseJSON: &whoami,
+	})
+	if resp != nil {
+		if problem := checkClockSkew(resp.Header.Get("Date"), time.Now()); problem != nil {
+			log.Warn().Str("details", problem.Details).Msg("Local clock is skewed")
+			problems = append(problems, problem)
+		}
+	}
+	if errors.Is(err, mautrix.MUnknownToken) {
+		return append(problems, &jsoncmd.SessionProblem{
+			Code:    jsoncmd.SessionProblemLoggedOut,
+			Message: "Your session was logged out remotely. Please log in again.",
+			Fatal:   true,
+			Details: err.Error(),
+		})
+	} else if err != nil {
+		log.Warn().Err(err).Msg("Failed to check access token with whoami")
+		return problems
+	} else if whoami.UserID != h.Account.UserID || (whoami.DeviceID != "" && whoami.DeviceID != h.Account.DeviceID) {
+		return append(problems, &jsoncmd.SessionProblem{
+			Code:    jsoncmd.SessionProblemDeviceMismatch,
+			Message: "The access token belongs to a different device than the local data. Please log out and log in again.",
+			Fatal:   true,
+			Details: fmt.Sprintf(
+				"expected %s/%s, server returned %s/%s",
+				h.Account.UserID, h.Account.DeviceID, whoami.UserID, whoami.DeviceID,
+			),
+		})
+	}
+	if problem, err := h.checkIdentityKeys(ctx); err != nil {
+		log.Warn().Err(err).Msg("Failed to check device identity keys")
+	} else if problem != nil {
+		problems = append(problems, problem)
+	}
+	return problems
+}
+
+func checkClockSkew(dateHeader string, now time.Time) *jsoncmd.SessionProblem {
+	if dateHeader == "" {
+		return nil
+	}
+	serverTime, err := http.ParseTime(dateHeader)
+	if err != nil {
+		return nil
+	}
+	// The Date header only has second precision
+	skew := now.Sub(serverTime).Truncate(time.Second)
+	if skew.Abs() <= MaxClockSkew {
+		return nil
+	}
+	direction := "ahead of"
+	if skew < 0 {
+		direction = "behind"
+	}
+	return &jsoncmd.SessionProblem{
+		Code:    jsoncmd.SessionProblemClockSkew,
+		Message: fmt.Sprintf("Your clock is %s %s the homeserver. Please sync your system clock.", skew.Abs(), direction),
+		Details: fmt.Sprintf("local time %s, server time %s", now.UTC().Format(time.RFC1123), serverTime.Format(time.RFC1123)),
+	}
+}
+
+// checkIdentityKeys compares the identity keys of the local olm account against the ones the server has for
+// the device. It's not an error if the server doesn't have any keys yet.
+func (h *HiClient) checkIdentityKeys(ctx context.Context) (*jsoncmd.SessionProblem, error) {
+	own := h.Crypto.OwnIdentity()
+	resp, err := h.Client.QueryKeys(ctx, &mautrix.ReqQueryKeys{
+		DeviceKeys: mautrix.DeviceKeysRequest{own.UserID: mautrix.DeviceIDList{own.DeviceID}},
+	})
+	if err != nil {
+		return nil, err
+	}
+	serverKeys, ok := resp.DeviceKeys[own.UserID][own.DeviceID]
+	if !ok {
+		return nil, nil
+	}
+	mismatches := make([]string, 0, 2)
+	if key := serverKeys.Keys.GetEd25519(own.DeviceID); key != "" && key != own.SigningKey {
+		mismatches = append(mismatches, fmt.Sprintf("ed25519 (local %s, server %s)", own.SigningKey, key))
+	}
+	if key := serverKeys.Keys.GetCurve25519(own.DeviceID); key != "" && key != own.IdentityKey {
+		mismatches = append(mismatches, fmt.Sprintf("curve25519 (local %s, server %s)", own.IdentityKey, key))
+	}
+	if len(mismatches) == 0 {
+		return nil, nil
+	}
+	return &jsoncmd.SessionProblem{
+		Code:    jsoncmd.SessionProblemCryptoMismatch,
+		Message: "The local encryption keys don't match this device. The crypto database may have been restored from a different backup. Please log out and log in again.",
+		Fatal:   true,
+		Details: "mismatching keys: " + strings.Join(mismatches, ", "),
+	}, nil
+}
+
+func hasFatalProblem(problems []*jsoncmd.SessionProblem) bool {
+	return slices.ContainsFunc(problems, func(problem *jsoncmd.SessionProblem) bool {
+		return problem.Fatal
+	})
+}
@@ -0,0 +1,279 @@
+// Copyright (c) 2026 Tulir Asokan
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package hicli
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"maunium.net/go/mautrix"
+	"maunium.net/go/mautrix/id"
+
+	"go.mau.fi/gomuks/pkg/hicli/jsoncmd"
+)
+
+// fakeSessionServer serves the whoami and key query endpoints used by the session checks.
+type fakeSessionServer struct {
+	whoamiStatus int
+	whoamiResp   any
+	keysStatus   int
+	// deviceKeys are the keys the server has for the device. Nil means the device has no keys.
+	deviceKeys map[id.KeyID]string
+	// clockOffset is added to the current time in the Date header.
+	clockOffset time.Duration
+}
+
+func (fss *fakeSessionServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Date", time.Now().Add(fss.clockOffset).UTC().Format(http.TimeFormat))
+	var status int
+	var resp any
+	switch {
+	case strings.HasSuffix(r.URL.Path, "/account/whoami"):
+		status, resp = fss.whoamiStatus, fss.whoamiResp
+	case strings.HasSuffix(r.URL.Path, "/keys/query"):
+		status = fss.keysStatus
+		devices := map[id.DeviceID]any{}
+		if fss.deviceKeys != nil {
+			devices[testDeviceID] = map[string]any{
+				"user_id":    testUserID,
+				"device_id":  testDeviceID,
+				"algorithms": []string{},
+				"keys":       fss.deviceKeys,
+			}
+		}
+		resp = map[string]any{"device_keys": map[id.UserID]any{testUserID: devices}}
+	default:
+		status, resp = http.StatusNotFound, mautrix.MUnrecognized.WithMessage("Unrecognized request")
+	}
+	if status == 0 {
+		status = http.StatusOK
+	}
+	if status != http.StatusOK && resp == nil {
+		resp = mautrix.MUnknown.WithMessage("Internal server error")
+	}
+	// RespError only implements json.Marshaler with a pointer receiver
+	if respErr, isErr := resp.(mautrix.RespError); isErr {
+		resp = &respErr
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	_ = json.NewEncoder(w).Encode(resp)
+}
+
+// newSessionTestClient creates a test client with a loaded olm account that talks to the given fake server.
+func newSessionTestClient(t *testing.T, fss *fakeSessionServer) *HiClient {
+	t.Helper()
+	ctx := context.Background()
+	h, _ := newTestClient(t)
+	if err := h.CryptoStore.DB.Upgrade(ctx); err != nil {
+		t.Fatalf("Failed to upgrade crypto database: %v", err)
+	}
+	h.CryptoStore.AccountID = testUserID.String()
+	h.CryptoStore.DeviceID = testDeviceID
+	if err := h.Crypto.Load(ctx); err != nil {
+		t.Fatalf("Failed to load olm machine: %v", err)
+	}
+	srv := httptest.NewServer(fss)
+	t.Cleanup(srv.Close)
+	h.Client.HomeserverURL, _ = url.Parse(srv.URL)
+	h.Client.AccessToken = "fake"
+	h.Client.DefaultHTTPRetries = 0
+	return h
+}
+
+func problemCodes(problems []*jsoncmd.SessionProblem) []jsoncmd.SessionProblemCode {
+	codes := make([]jsoncmd.SessionProblemCode, len(problems))
+	for i, problem := range problems {
+		codes[i] = problem.Code
+	}
+	return codes
+}
+
+type testServerKeys int
+
+const (
+	serverKeysMatching testServerKeys = iota
+	serverKeysNone
+	serverKeysWrong
+)
+
+func TestCheckSession(t *testing.T) {
+	correctWhoami := map[string]any{"user_id": testUserID, "device_id": testDeviceID}
+	tests := []struct {
+		name      string
+		server    fakeSessionServer
+		keys      testServerKeys
+		want      []jsoncmd.SessionProblemCode
+		wantFatal bool
+	}{
+		{"valid session", fakeSessionServer{whoamiResp: correctWhoami}, serverKeysMatching, nil, false},
+		{"whoami without device ID", fakeSessionServer{whoamiResp: map[string]any{"user_id": testUserID}}, serverKeysMatching, nil, false},
+		{
+			"logged out",
+			fakeSessionServer{whoamiStatus: http.StatusUnauthorized, whoamiResp: mautrix.MUnknownToken.WithMessage("Invalid token")},
+			serverKeysMatching, []jsoncmd.SessionProblemCode{jsoncmd.SessionProblemLoggedOut}, true,
+		},
+		{
+			"different user",
+			fakeSessionServer{whoamiResp: map[string]any{"user_id": "@bob:example.com", "device_id": testDeviceID}},
+			serverKeysMatching, []jsoncmd.SessionProblemCode{jsoncmd.SessionProblemDeviceMismatch}, true,
+		},
+		{
+			"different device",
+			fakeSessionServer{whoamiResp: map[string]any{"user_id": testUserID, "device_id": "OTHERDEVICE"}},
+			serverKeysMatching, []jsoncmd.SessionProblemCode{jsoncmd.SessionProblemDeviceMismatch}, true,
+		},
+		{"whoami server error is ignored", fakeSessionServer{whoamiStatus: http.StatusInternalServerError}, serverKeysMatching, nil, false},
+		{
+			"clock ahead",
+			fakeSessionServer{whoamiResp: correctWhoami, clockOffset: -time.Hour},
+			serverKeysMatching, []jsoncmd.SessionProblemCode{jsoncmd.SessionProblemClockSkew}, false,
+		},
+		{
+			"clock behind",
+			fakeSessionServer{whoamiResp: correctWhoami, clockOffset: time.Hour},
+			serverKeysMatching, []jsoncmd.SessionProblemCode{jsoncmd.SessionProblemClockSkew}, false,
+		},
+		{"small clock difference", fakeSessionServer{whoamiResp: correctWhoami, clockOffset: time.Minute}, serverKeysMatching, nil, false},
+		{
+			"clock skew and logged out",
+			fakeSessionServer{
+				whoamiStatus: http.StatusUnauthorized,
+				whoamiResp:   mautrix.MUnknownToken.WithMessage("Invalid token"),
+				clockOffset:  time.Hour,
+			},
+			serverKeysMatching, []jsoncmd.SessionProblemCode{jsoncmd.SessionProblemClockSkew, jsoncmd.SessionProblemLoggedOut}, true,
+		},
+		{
+			"mismatching identity keys",
+			fakeSessionServer{whoamiResp: correctWhoami},
+			serverKeysWrong, []jsoncmd.SessionProblemCode{jsoncmd.SessionProblemCryptoMismatch}, true,
+		},
+		{"no keys on server", fakeSessionServer{whoamiResp: correctWhoami}, serverKeysNone, nil, false},
+		{
+			"key query error is ignored",
+			fakeSessionServer{whoamiResp: correctWhoami, keysStatus: http.StatusInternalServerError},
+			serverKeysWrong, nil, false,
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			h := newSessionTestClient(t, &test.server)
+			own := h.Crypto.OwnIdentity()
+			switch test.keys {
+			case serverKeysWrong:
+				test.server.deviceKeys = map[id.KeyID]string{
+					id.NewKeyID(id.KeyAlgorithmEd25519, testDeviceID.String()):    "wrongSigningKey",
+					id.NewKeyID(id.KeyAlgorithmCurve25519, testDeviceID.String()): own.IdentityKey.String(),
+				}
+			case serverKeysMatching:
+				test.server.deviceKeys = map[id.KeyID]string{
+					id.NewKeyID(id.KeyAlgorithmEd25519, testDeviceID.String()):    own.SigningKey.String(),
+					id.NewKeyID(id.KeyAlgorithmCurve25519, testDeviceID.String()): own.IdentityKey.String(),
+				}
+			}
+			problems := h.checkSession(context.Background())
+			if codes := problemCodes(problems); !slices.Equal(codes, test.want) {
+				t.Fatalf("checkSession() = %v, want %v", codes, test.want)
+			}
+			if fatal := hasFatalProblem(problems); fatal != test.wantFatal {
+				t.Errorf("hasFatalProblem() = %t, want %t", fatal, test.wantFatal)
+			}
+			for _, problem := range problems {
+				if problem.Message == "" {
+					t.Errorf("Problem %s has no message", problem.Code)
+				}
+			}
+		})
+	}
+}
+
+func TestCheckSession_MismatchDetails(t *testing.T) {
+	fss := &fakeSessionServer{whoamiResp: map[string]any{"user_id": testUserID, "device_id": testDeviceID}}
+	h := newSessionTestClient(t, fss)
+	own := h.Crypto.OwnIdentity()
+	fss.deviceKeys = map[id.KeyID]string{
+		id.NewKeyID(id.KeyAlgorithmEd25519, testDeviceID.String()):    own.SigningKey.String(),
+		id.NewKeyID(id.KeyAlgorithmCurve25519, testDeviceID.String()): "wrongIdentityKey",
+	}
+	problems := h.checkSession(context.Background())
+	if len(problems) != 1 {
+		t.Fatalf("Expected one problem, got %v", problemCodes(problems))
+	} else if !strings.Contains(problems[0].Details, "curve25519") || strings.Contains(problems[0].Details, "ed25519") {
+		t.Errorf("Expected only the curve25519 key in details, got %q", problems[0].Details)
+	}
+}
+
+func TestCheckClockSkew(t *testing.T) {
+	now := time.Date(2026, 1, 1, 12, 0, 0, 0, time.UTC)
+	tests := []struct {
+		name          string
+		header        string
+		wantProblem   bool
+		wantDirection string
+	}{
+		{"no header", "", false, ""},
+		{"invalid header", "yesterday", false, ""},
+		{"in sync", now.Format(http.TimeFormat), false, ""},
+		{"at threshold", now.Add(-MaxClockSkew).Format(http.TimeFormat), false, ""},
+		{"sub-second precision loss", now.Add(MaxClockSkew).Format(http.TimeFormat), false, ""},
+		{"local clock ahead", now.Add(-time.Hour).Format(http.TimeFormat), true, "ahead of"},
+		{"local clock behind", now.Add(time.Hour).Format(http.TimeFormat), true, "behind"},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			problem := checkClockSkew(test.header, now.Add(500*time.Millisecond))
+			if (problem != nil) != test.wantProblem {
+				t.Fatalf("checkClockSkew(%q) = %+v, want problem: %t", test.header, problem, test.wantProblem)
+			} else if problem == nil {
+				return
+			}
+			if problem.Code != jsoncmd.SessionProblemClockSkew || problem.Fatal {
+				t.Errorf("Expected non-fatal clock skew problem, got %+v", problem)
+			}
+			if want := test.wantDirection + " the homeserver"; !strings.Contains(problem.Message, want) {
+				t.Errorf("Expected message to contain %q, got %q", want, problem.Message)
+			}
+		})
+	}
+}
+
+func TestStart_UnsupportedDatabase(t *testing.T) {
+	ctx := context.Background()
+	h, events := newTestClient(t)
+	_, err := h.DB.Exec(ctx, fmt.Sprintf("UPDATE %s SET version=10000, compat=10000", h.DB.VersionTable))
+	if err != nil {
+		t.Fatalf("Failed to bump database version: %v", err)
+	}
+	if err = h.Start(ctx, testUserID, nil); err != nil {
+		t.Fatalf("Start returned error: %v", err)
+	}
+	if codes := problemCodes(h.SessionProblems); !slices.Equal(codes, []jsoncmd.SessionProblemCode{jsoncmd.SessionProblemUnsupportedDatabase}) {
+		t.Fatalf("Expected unsupported database problem, got %v", codes)
+	} else if !h.SessionProblems[0].Fatal {
+		t.Error("Expected unsupported database problem to be fatal")
+	}
+	var state *jsoncmd.ClientState
+	for _, evt := range events.all() {
+		if cs, ok := evt.(*jsoncmd.ClientState); ok {
+			state = cs
+		}
+	}
+	if state == nil {
+		t.Fatal("Client state wasn't dispatched")
+	} else if !state.Initialized || len(state.Problems) != 1 {
+		t.Errorf("Expected initialized state with the problem, got %+v", state)
+	}
+}
@@ -0,0 +1,136 @@
+// Copyright (c) 2025 Tulir Asokan
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package hicli
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"slices"
+
+	"go.mau.fi/util/jsontime"
+	"maunium.net/go/mautrix/event"
+
+	"go.mau.fi/gomuks/pkg/hicli/jsoncmd"
+)
+
+var AccountDataGomuksSettings = event.Type{Type: "fi.mau.gomuks.settings", Class: event.AccountDataEventType}
+
+// SettingsBackupVersion is the current version of the settings backup format.
+const SettingsBackupVersion = 1
+
+// ExportSettings returns the settings backup stored in account data, or an empty backup if there isn't one.
+func (h *HiClient) ExportSettings(ctx context.Context) (*jsoncmd.SettingsBackup, error) {
+	backup := &jsoncmd.SettingsBackup{Version: SettingsBackupVersion}
+	ad, err := h.DB.AccountData.GetGlobal(ctx, h.Account.UserID, AccountDataGomuksSettings)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get settings from database: %w", err)
+	} else if ad != nil && len(ad.Content) > 0 {
+		err = json.Unmarshal(ad.Content, backup)
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse settings: %w", err)
+		}
+	}
+	if backup.Sections == nil {
+		backup.Sections = make(map[string]*jsoncmd.SettingsSection)
+	}
+	return backup, nil
+}
+
+// ImportSettings merges the given settings into the backup in account data.
+// All frontends should route their settings writes through this so that the backup stays in sync.
+func (h *HiClient) ImportSettings(ctx context.Context, params *jsoncmd.ImportSettingsParams) (*jsoncmd.ImportSettingsResponse, error) {
+	if params.Settings == nil {
+		return nil, fmt.Errorf("no settings provided")
+	}
+	h.settingsLock.Lock()
+	defer h.settingsLock.Unlock()
+	stored, err := h.ExportSettings(ctx)
+	if err != nil {
+		return nil, err
+	}
+	conflicts, changed := MergeSettings(stored, params.Settings, params.Override)
+	if changed {
+		content, err := json.Marshal(stored)
+		if err != nil {
+			return nil, fmt.Errorf("failed to marshal settings: %w", err)
+		}
+		err = h.Client.SetAccountData(ctx, AccountDataGomuksSettings.Type, json.RawMessage(content))
+		if err != nil {
+			return nil, fmt.Errorf("failed to save settings: %w", err)
+		}
+		// Store the new content immediately so that another import doesn't have to wait for the sync echo.
+		_, err = h.DB.AccountData.Put(ctx, h.Account.UserID, AccountDataGomuksSettings, content)
+		if err != nil {
+			return nil, fmt.Errorf("failed to save settings to database: %w", err)
+		}
+	}
+	return &jsoncmd.ImportSettingsResponse{
+		Settings:  stored,
+		Conflicts: conflicts,
+	}, nil
+}
+
+// MergeSettings merges the sections of incoming into stored.
+//
+// Each section is merged as a whole: if the stored section was modified more recently than the incoming one,
+// the incoming section is skipped and returned as a conflict, unless override is true. Values that are null
+// in the incoming section are removed, and values that aren't mentioned at all are left as-is, so settings
+// written by other versions of a frontend aren't lost.
+func MergeSettings(stored, incoming *jsoncmd.SettingsBackup, override bool) (conflicts []string, changed bool) {
+	if stored.Sections == nil {
+		stored.Sections = make(map[string]*jsoncmd.SettingsSection)
+	}
+	if incoming.Version > stored.Version {
+		stored.Version = incoming.Version
+		changed = true
+	}
+	now := jsontime.UnixMilliNow()
+	for name, section := range incoming.Sections {
+		if section == nil {
+			continue
+		}
+		modifiedAt := section.ModifiedAt
+		if modifiedAt.IsZero() {
+			modifiedAt = now
+		}
+		existing, ok := stored.Sections[name]
+		if !ok || existing == nil {
+			existing = &jsoncmd.SettingsSection{}
+			stored.Sections[name] = existing
+		} else if existing.ModifiedAt.After(modifiedAt.Time) {
+			if !override {
+				conflicts = append(conflicts, name)
+				continue
+			}
+			// Bump the timestamp so that other devices see the overridden section as the newest one.
+			modifiedAt = now
+		}
+		if existing.Values == nil {
+			existing.Values = make(map[string]json.RawMessage)
+		}
+		for key, value := range section.Values {
+			oldValue, exists := existing.Values[key]
+			if value == nil || string(value) == "null" {
+				if exists {
+					delete(existing.Values, key)
+					changed = true
+				}
+			} else if !exists || !bytes.Equal(oldValue, value) {
+				existing.Values[key] = value
+				changed = true
+			}
+		}
+		if modifiedAt.After(existing.ModifiedAt.Time) {
+			existing.ModifiedAt = modifiedAt
+			changed = true
+		}
+	}
+	slices.Sort(conflicts)
+	return
+}
@@ -0,0 +1,273 @@
+// Copyright (c) 2026 Tulir Asokan
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package hicli
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"go.mau.fi/util/jsontime"
+
+	"go.mau.fi/gomuks/pkg/hicli/jsoncmd"
+)
+
+func parseTestSettings(t *testing.T, data string) *jsoncmd.SettingsBackup {
+	t.Helper()
+	var backup jsoncmd.SettingsBackup
+	if err := json.Unmarshal([]byte(data), &backup); err != nil {
+		t.Fatalf("Failed to parse test settings: %v", err)
+	}
+	return &backup
+}
+
+// settingsValues returns the values of each section as strings for easier comparison.
+func settingsValues(backup *jsoncmd.SettingsBackup) map[string]map[string]string {
+	out := make(map[string]map[string]string, len(backup.Sections))
+	for name, section := range backup.Sections {
+		values := make(map[string]string, len(section.Values))
+		for key, value := range section.Values {
+			values[key] = string(value)
+		}
+		out[name] = values
+	}
+	return out
+}
+
+func TestMergeSettings(t *testing.T) {
+	tests := []struct {
+		name          string
+		stored        string
+		incoming      string
+		override      bool
+		wantValues    map[string]map[string]string
+		wantConflicts []string
+		wantChanged   bool
+		wantVersion   int
+	}{
+		{
+			name:        "new section",
+			stored:      `{"version":1,"sections":{}}`,
+			incoming:    `{"version":1,"sections":{"tui":{"modified_at":1000,"values":{"hide_user_list":true}}}}`,
+			wantValues:  map[string]map[string]string{"tui": {"hide_user_list": "true"}},
+			wantChanged: true,
+			wantVersion: 1,
+		},
+		{
+			name:        "empty stored backup",
+			stored:      `{}`,
+			incoming:    `{"version":1,"sections":{"web":{"modified_at":1000,"values":{"theme":"\"dark\""}}}}`,
+			wantValues:  map[string]map[string]string{"web": {"theme": `"\"dark\""`}},
+			wantChanged: true,
+			wantVersion: 1,
+		},
+		{
+			name:     "keys unknown to the writer are kept",
+			stored:   `{"version":1,"sections":{"tui":{"modified_at":1000,"values":{"hide_user_list":true,"option_from_newer_version":{"nested":[1,2]}}}}}`,
+			incoming: `{"version":1,"sections":{"tui":{"modified_at":2000,"values":{"hide_user_list":false}}}}`,
+			wantValues: map[string]map[string]string{
+				"tui": {"hide_user_list": "false", "option_from_newer_version": `{"nested":[1,2]}`},
+			},
+			wantChanged: true,
+			wantVersion: 1,
+		},
+		{
+			name:        "null removes key",
+			stored:      `{"version":1,"sections":{"tui":{"modified_at":1000,"values":{"a":1,"b":2}}}}`,
+			incoming:    `{"version":1,"sections":{"tui":{"modified_at":2000,"values":{"a":null}}}}`,
+			wantValues:  map[string]map[string]string{"tui": {"b": "2"}},
+			wantChanged: true,
+			wantVersion: 1,
+		},
+		{
+			name:        "other sections are untouched",
+			stored:      `{"version":1,"sections":{"web":{"modified_at":5000,"values":{"theme":"dark"}},"android":{"modified_at":5000,"values":{"x":1}}}}`,
+			incoming:    `{"version":1,"sections":{"tui":{"modified_at":1000,"values":{"a":1}}}}`,
+			wantValues:  map[string]map[string]string{"web": {"theme": `"dark"`}, "android": {"x": "1"}, "tui": {"a": "1"}},
+			wantChanged: true,
+			wantVersion: 1,
+		},
+		{
+			name:          "stored section is newer",
+			stored:        `{"version":1,"sections":{"tui":{"modified_at":2000,"values":{"a":1}}}}`,
+			incoming:      `{"version":1,"sections":{"tui":{"modified_at":1000,"values":{"a":2}}}}`,
+			wantValues:    map[string]map[string]string{"tui": {"a": "1"}},
+			wantConflicts: []string{"tui"},
+			wantVersion:   1,
+		},
+		{
+			name:        "stored section is newer with override",
+			stored:      `{"version":1,"sections":{"tui":{"modified_at":2000,"values":{"a":1,"b":1}}}}`,
+			incoming:    `{"version":1,"sections":{"tui":{"modified_at":1000,"values":{"a":2}}}}`,
+			override:    true,
+			wantValues:  map[string]map[string]string{"tui": {"a": "2", "b": "1"}},
+			wantChanged: true,
+			wantVersion: 1,
+		},
+		{
+			name:        "identical values",
+			stored:      `{"version":1,"sections":{"tui":{"modified_at":1000,"values":{"a":1}}}}`,
+			incoming:    `{"version":1,"sections":{"tui":{"modified_at":1000,"values":{"a":1}}}}`,
+			wantValues:  map[string]map[string]string{"tui": {"a": "1"}},
+			wantVersion: 1,
+		},
+		{
+			name:        "newer version is kept when older client writes",
+			stored:      `{"version":3,"sections":{"tui":{"modified_at":1000,"values":{"a":1}}}}`,
+			incoming:    `{"version":1,"sections":{"tui":{"modified_at":2000,"values":{"a":2}}}}`,
+			wantValues:  map[string]map[string]string{"tui": {"a": "2"}},
+			wantChanged: true,
+			wantVersion: 3,
+		},
+		{
+			name:        "version is raised by newer client",
+			stored:      `{"version":1,"sections":{}}`,
+			incoming:    `{"version":2,"sections":{}}`,
+			wantValues:  map[string]map[string]string{},
+			wantChanged: true,
+			wantVersion: 2,
+		},
+		{
+			name:        "null section is skipped",
+			stored:      `{"version":1,"sections":{"tui":{"modified_at":1000,"values":{"a":1}}}}`,
+			incoming:    `{"version":1,"sections":{"tui":null}}`,
+			wantValues:  map[string]map[string]string{"tui": {"a": "1"}},
+			wantVersion: 1,
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			stored := parseTestSettings(t, test.stored)
+			incoming := parseTestSettings(t, test.incoming)
+			before := time.Now().Truncate(time.Millisecond)
+			conflicts, changed := MergeSettings(stored, incoming, test.override)
+			if !reflect.DeepEqual(conflicts, test.wantConflicts) {
+				t.Errorf("Conflicts = %v, want %v", conflicts, test.wantConflicts)
+			}
+			if changed != test.wantChanged {
+				t.Errorf("Changed = %t, want %t", changed, test.wantChanged)
+			}
+			if stored.Version != test.wantVersion {
+				t.Errorf("Version = %d, want %d", stored.Version, test.wantVersion)
+			}
+			if got := settingsValues(stored); !reflect.DeepEqual(got, test.wantValues) {
+				t.Errorf("Merged values = %v, want %v", got, test.wantValues)
+			}
+			if test.override {
+				// Overriding must make the section the newest one, so other devices don't see it as outdated
+				if modifiedAt := stored.Sections["tui"].ModifiedAt; modifiedAt.Before(before) {
+					t.Errorf("Overridden section has timestamp %s, expected it to be bumped to now", modifiedAt.Time)
+				}
+			}
+		})
+	}
+}
+
+func TestMergeSettings_ZeroTimestampUsesNow(t *testing.T) {
+	stored := parseTestSettings(t, `{"version":1,"sections":{"tui":{"modified_at":1000,"values":{"a":1}}}}`)
+	incoming := &jsoncmd.SettingsBackup{Version: 1, Sections: map[string]*jsoncmd.SettingsSection{
+		"tui": {Values: map[string]json.RawMessage{"a": json.RawMessage("2")}},
+	}}
+	before := time.Now().Truncate(time.Millisecond)
+	conflicts, changed := MergeSettings(stored, incoming, false)
+	if len(conflicts) != 0 || !changed {
+		t.Fatalf("Section without timestamp wasn't merged (conflicts: %v, changed: %t)", conflicts, changed)
+	} else if stored.Sections["tui"].ModifiedAt.Before(before) {
+		t.Errorf("Section without timestamp got modified_at %s, want now", stored.Sections["tui"].ModifiedAt.Time)
+	}
+}
+
+func TestImportSettings(t *testing.T) {
+	ctx := context.Background()
+	h, _ := newTestClient(t)
+	var lock sync.Mutex
+	var puts []string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPut || !strings.HasSuffix(r.URL.Path, "/account_data/"+AccountDataGomuksSettings.Type) {
+			http.NotFound(w, r)
+			return
+		}
+		body, _ := io.ReadAll(r.Body)
+		lock.Lock()
+		puts = append(puts, string(body))
+		lock.Unlock()
+		_, _ = w.Write([]byte("{}"))
+	}))
+	t.Cleanup(srv.Close)
+	h.Client.HomeserverURL, _ = url.Parse(srv.URL)
+	h.Client.AccessToken = "fake"
+
+	// Settings written by a newer frontend, with a section and keys this version doesn't know about
+	initial := `{"version":2,"sections":{"tui":{"modified_at":1000,"values":{"hide_user_list":true,"future_key":"x"}},"future_frontend":{"modified_at":1000,"values":{"k":1}}}}`
+	_, err := h.DB.AccountData.Put(ctx, h.Account.UserID, AccountDataGomuksSettings, json.RawMessage(initial))
+	if err != nil {
+		t.Fatalf("Failed to store initial settings: %v", err)
+	}
+
+	resp, err := h.ImportSettings(ctx, &jsoncmd.ImportSettingsParams{
+		Settings: &jsoncmd.SettingsBackup{Version: SettingsBackupVersion, Sections: map[string]*jsoncmd.SettingsSection{
+			"tui": {ModifiedAt: jsontime.UMInt(2000), Values: map[string]json.RawMessage{"hide_user_list": json.RawMessage("false")}},
+		}},
+	})
+	if err != nil {
+		t.Fatalf("ImportSettings failed: %v", err)
+	} else if len(resp.Conflicts) != 0 {
+		t.Errorf("Unexpected conflicts: %v", resp.Conflicts)
+	}
+	if len(puts) != 1 {
+		t.Fatalf("Expected 1 account data update, got %d", len(puts))
+	}
+	wantValues := map[string]map[string]string{
+		"tui":             {"hide_user_list": "false", "future_key": `"x"`},
+		"future_frontend": {"k": "1"},
+	}
+	uploaded := parseTestSettings(t, puts[0])
+	if got := settingsValues(uploaded); !reflect.DeepEqual(got, wantValues) {
+		t.Errorf("Uploaded settings = %v, want %v", got, wantValues)
+	} else if uploaded.Version != 2 {
+		t.Errorf("Uploaded version = %d, want the newer version 2 to be kept", uploaded.Version)
+	}
+	// The database must be updated right away, without waiting for the sync echo
+	exported, err := h.ExportSettings(ctx)
+	if err != nil {
+		t.Fatalf("ExportSettings failed: %v", err)
+	} else if got := settingsValues(exported); !reflect.DeepEqual(got, wantValues) {
+		t.Errorf("Stored settings = %v, want %v", got, wantValues)
+	}
+
+	// Importing an outdated copy conflicts and doesn't write anything
+	resp, err = h.ImportSettings(ctx, &jsoncmd.ImportSettingsParams{
+		Settings: &jsoncmd.SettingsBackup{Version: SettingsBackupVersion, Sections: map[string]*jsoncmd.SettingsSection{
+			"tui": {ModifiedAt: jsontime.UMInt(1500), Values: map[string]json.RawMessage{"hide_user_list": json.RawMessage("true")}},
+		}},
+	})
+	if err != nil {
+		t.Fatalf("ImportSettings failed: %v", err)
+	} else if !reflect.DeepEqual(resp.Conflicts, []string{"tui"}) {
+		t.Errorf("Conflicts = %v, want [tui]", resp.Conflicts)
+	} else if len(puts) != 1 {
+		t.Errorf("Conflicting import updated account data")
+	}
+}
+
+func TestExportSettings_Empty(t *testing.T) {
+	h, _ := newTestClient(t)
+	backup, err := h.ExportSettings(context.Background())
+	if err != nil {
+		t.Fatalf("ExportSettings failed: %v", err)
+	} else if backup.Version != SettingsBackupVersion || backup.Sections == nil || len(backup.Sections) != 0 {
+		t.Errorf("Unexpected empty backup: %+v", backup)
+	}
+}
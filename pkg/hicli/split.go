@@ -0,0 +1,309 @@
+// Copyright (c) 2025 Tulir Asokan
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package hicli
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/rs/zerolog"
+	"github.com/tidwall/gjson"
+	"github.com/tidwall/sjson"
+	"maunium.net/go/mautrix"
+	"maunium.net/go/mautrix/event"
+	"maunium.net/go/mautrix/format"
+	"maunium.net/go/mautrix/id"
+
+	"go.mau.fi/gomuks/pkg/hicli/database"
+)
+
+// MaxEventSize is the maximum size of an event in bytes as defined in the Matrix spec.
+const MaxEventSize = 65536
+
+const (
+	// eventEnvelopeOverhead is a generous estimate of the size of the fields that are added to events
+	// outside the content, like prev_events, auth_events, hashes and signatures.
+	eventEnvelopeOverhead = 4096
+	// megolmPlaintextOverhead covers the type and room_id fields that are encrypted along with the content,
+	// as well as the header, padding, MAC and signature of the megolm message.
+	megolmPlaintextOverhead = 256
+	// megolmContentOverhead covers the unencrypted fields of m.room.encrypted content,
+	// like the sender key, session ID and a copy of m.relates_to.
+	megolmContentOverhead = 1024
+	// splitNumberingOverhead is reserved in each chunk for the "(1/2)" line.
+	splitNumberingOverhead = 32
+	// maxSplitAttempts is the number of times splitting is retried with a smaller chunk size
+	// if the first attempt results in chunks that are still too large after rendering.
+	maxSplitAttempts = 5
+)
+
+// ErrMessageTooLarge is returned by SendMessage if the message doesn't fit in a single event and can't be
+// split automatically, either because it's not a plain markdown message (e.g. an edit or a caption), or because
+// a code block is too long and splitting it wasn't explicitly allowed. Frontends should offer to send the text
+// as a file instead.
+var ErrMessageTooLarge = mautrix.RespError{
+	ErrCode:    "FI.MAU.GOMUKS.MESSAGE_TOO_LARGE",
+	Err:        "The message is too large to send as a single event",
+	StatusCode: http.StatusRequestEntityTooLarge,
+}
+
+// estimateEventSize estimates the size of the event the server will create from the given content,
+// including the encryption overhead if the room is encrypted.
+func estimateEventSize(roomID id.RoomID, evtType event.Type, content *event.Content, encrypted bool) (int, error) {
+	data, err := json.Marshal(content)
+	if err != nil {
+		return 0, fmt.Errorf("failed to marshal event content: %w", err)
+	}
+	size := len(data)
+	if encrypted {
+		plaintextSize := size + len(evtType.Type) + len(roomID) + megolmPlaintextOverhead
+		size = base64.RawStdEncoding.EncodedLen(plaintextSize) + megolmContentOverhead
+	}
+	return size + eventEnvelopeOverhead, nil
+}
+
+// isTooLarge checks if the given content would exceed the event size limit when sent to the room.
+func (h *HiClient) isTooLarge(
+	ctx context.Context,
+	roomID id.RoomID,
+	evtType event.Type,
+	content *event.MessageEventContent,
+	extra map[string]any,
+	disableEncryption bool,
+) (bool, *database.Room, error) {
+	textLen := len(content.Body) + len(content.FormattedBody)
+	if content.NewContent != nil {
+		textLen += len(content.NewContent.Body) + len(content.NewContent.FormattedBody)
+	}
+	if textLen < MaxEventSize/4 {
+		// Fast path for normal messages, even an encrypted event is at most ~3x larger than the text
+		return false, nil, nil
+	}
+	room, err := h.DB.Room.Get(ctx, roomID)
+	if err != nil {
+		return false, nil, fmt.Errorf("failed to get room metadata: %w", err)
+	} else if room == nil {
+		return false, nil, fmt.Errorf("unknown room")
+	}
+	encrypted := room.EncryptionEvent != nil && !disableEncryption
+	size, err := estimateEventSize(roomID, evtType, &event.Content{Parsed: content, Raw: extra}, encrypted)
+	if err != nil {
+		return false, nil, err
+	}
+	return size > MaxEventSize, room, nil
+}
+
+// sendSplitMessage sends a markdown message that's too large for a single event as a numbered sequence of events.
+// The events go through the normal send queue, so they're sent in order. Only the first event contains the reply
+// and mentions, but all of them stay in the thread if the message was sent in a thread, with a fallback reply
+// to the previous part for clients that don't support threads.
+func (h *HiClient) sendSplitMessage(
+	ctx context.Context,
+	room *database.Room,
+	template *event.MessageEventContent,
+	extra map[string]any,
+	text string,
+	disableEncryption bool,
+	ts int64,
+	splitCodeBlocks bool,
+) (*database.Event, error) {
+	encrypted := room.EncryptionEvent != nil && !disableEncryption
+	fits := func(chunk string) (bool, error) {
+		content := format.RenderMarkdownCustom(chunk, defaultNoHTML)
+		size, err := estimateEventSize(room.ID, event.EventMessage, &event.Content{Parsed: &content, Raw: extra}, encrypted)
+		return size+splitNumberingOverhead <= MaxEventSize, err
+	}
+	// Start with a chunk size based on how much rendering and encryption inflate the whole message, then shrink
+	// it if some chunks turn out to be larger (e.g. if most of the formatting is in one part of the message).
+	fullSize, err := estimateEventSize(room.ID, event.EventMessage, &event.Content{Parsed: template, Raw: extra}, encrypted)
+	if err != nil {
+		return nil, err
+	}
+	maxLen := int(float64(len(text)) * float64(MaxEventSize-splitNumberingOverhead) / float64(fullSize) * 0.9)
+	var chunks []string
+	for attempt := 0; ; attempt++ {
+		var splitCode bool
+		chunks, splitCode = splitMarkdown(text, maxLen)
+		if splitCode && !splitCodeBlocks {
+			return nil, ErrMessageTooLarge
+		}
+		allFit := true
+		for _, chunk := range chunks {
+			allFit, err = fits(chunk)
+			if err != nil {
+				return nil, err
+			} else if !allFit {
+				break
+			}
+		}
+		if allFit {
+			break
+		} else if attempt >= maxSplitAttempts {
+			return nil, ErrMessageTooLarge
+		}
+		maxLen = maxLen * 3 / 4
+	}
+	zerolog.Ctx(ctx).Debug().
+		Int("text_length", len(text)).
+		Int("estimated_size", fullSize).
+		Int("chunk_count", len(chunks)).
+		Msg("Splitting message that's too large for a single event")
+	var firstEvt, prevEvt *database.Event
+	for i, chunk := range chunks {
+		if len(chunks) > 1 {
+			chunk = fmt.Sprintf("(%d/%d)\n\n%s", i+1, len(chunks), chunk)
+		}
+		content := format.RenderMarkdownCustom(chunk, defaultNoHTML)
+		content.MsgType = template.MsgType
+		if i == 0 {
+			content.Mentions = template.Mentions
+			content.BeeperLinkPreviews = template.BeeperLinkPreviews
+			content.RelatesTo = template.RelatesTo
+		} else {
+			content.Mentions = &event.Mentions{}
+			if threadRoot := template.RelatesTo.GetThreadParent(); threadRoot != "" {
+				// The previous part hasn't been sent yet, so the fallback points at its local ID,
+				// which is replaced with the real event ID when it's this part's turn to be sent.
+				content.RelatesTo = (&event.RelatesTo{}).SetThread(threadRoot, prevEvt.ID)
+			}
+		}
+		dbEvt, err := h.send(ctx, room.ID, event.EventMessage, &event.Content{Parsed: content, Raw: extra}, chunk, disableEncryption, false, ts)
+		if err != nil {
+			return firstEvt, fmt.Errorf("failed to send part %d of %d: %w", i+1, len(chunks), err)
+		} else if firstEvt == nil {
+			firstEvt = dbEvt
+		}
+		prevEvt = dbEvt
+	}
+	return firstEvt, nil
+}
+
+// parseCodeFence checks if the given line opens or closes a fenced code block.
+// The returned marker is the run of backticks or tildes at the start of the line.
+func parseCodeFence(line string) (marker string, onlyMarker bool) {
+	trimmed := strings.TrimRight(line, "\r\n")
+	indented := strings.TrimLeft(trimmed, " ")
+	if len(trimmed)-len(indented) > 3 || len(indented) < 3 || (indented[0] != '`' && indented[0] != '~') {
+		return "", false
+	}
+	markerLen := len(indented) - len(strings.TrimLeft(indented, indented[:1]))
+	if markerLen < 3 {
+		return "", false
+	}
+	return indented[:markerLen], strings.TrimSpace(indented[markerLen:]) == ""
+}
+
+// splitLongLine splits a line that's longer than maxLen, preferably at whitespace. It never splits in the middle
+// of a UTF-8 character.
+func splitLongLine(line string, maxLen int) []string {
+	var pieces []string
+	for len(line) > maxLen {
+		cut := maxLen
+		for cut > 0 && !utf8.RuneStart(line[cut]) {
+			cut--
+		}
+		if space := strings.LastIndexAny(line[:cut], " \t"); space > maxLen/2 {
+			cut = space + 1
+		}
+		if cut == 0 {
+			cut = maxLen
+		}
+		pieces = append(pieces, line[:cut])
+		line = line[cut:]
+	}
+	return append(pieces, line)
+}
+
+// splitMarkdown splits markdown text into chunks of at most maxLen bytes. Chunks are split at paragraph
+// boundaries when possible, then at line boundaries and finally in the middle of long lines. If a fenced
+// code block has to be split, the fence is closed at the end of the chunk and reopened in the next one,
+// and splitCodeBlock is set to true.
+func splitMarkdown(text string, maxLen int) (chunks []string, splitCodeBlock bool) {
+	maxLen = max(maxLen, 64)
+	var cur string
+	// boundary is the length of cur at the last paragraph break outside code blocks
+	var boundary int
+	var fenceOpener, fenceMarker string
+	emit := func(chunk string) {
+		if chunk = strings.Trim(chunk, "\r\n"); chunk != "" {
+			chunks = append(chunks, chunk)
+		}
+	}
+	for _, line := range strings.SplitAfter(text, "\n") {
+		for _, piece := range splitLongLine(line, maxLen/2) {
+			var closingLen int
+			if fenceMarker != "" {
+				closingLen = len(fenceMarker) + 1
+			}
+			if len(cur)+len(piece)+closingLen > maxLen && boundary > 0 {
+				emit(cur[:boundary])
+				cur = cur[boundary:]
+				boundary = 0
+			}
+			if len(cur)+len(piece)+closingLen > maxLen {
+				if fenceMarker != "" {
+					if !strings.HasSuffix(cur, "\n") {
+						cur += "\n"
+					}
+					emit(cur + fenceMarker)
+					cur = fenceOpener
+					splitCodeBlock = true
+				} else {
+					emit(cur)
+					cur = ""
+				}
+			}
+			cur += piece
+		}
+		marker, onlyMarker := parseCodeFence(line)
+		if fenceMarker == "" && marker != "" {
+			fenceOpener, fenceMarker = line, marker
+			if !strings.HasSuffix(fenceOpener, "\n") {
+				fenceOpener += "\n"
+			}
+		} else if fenceMarker != "" && onlyMarker && marker[0] == fenceMarker[0] && len(marker) >= len(fenceMarker) {
+			fenceOpener, fenceMarker = "", ""
+			boundary = len(cur)
+		} else if fenceMarker == "" && strings.TrimSpace(line) == "" {
+			boundary = len(cur)
+		}
+	}
+	emit(cur)
+	return
+}
+
+// resolveLocalReplyFallback replaces a reply fallback to a local event ID (see sendSplitMessage) with the real
+// ID of the event. The send queue only gets to this event after the previous one has been sent, so the real ID
+// is known by now, unless sending the previous event failed, in which case the fallback points at the thread root.
+func (h *HiClient) resolveLocalReplyFallback(ctx context.Context, dbEvt *database.Event) error {
+	content := &dbEvt.Content
+	if dbEvt.Decrypted != nil && len(dbEvt.Content) <= 2 {
+		content = &dbEvt.Decrypted
+	}
+	const replyToPath = `m\.relates_to.m\.in_reply_to.event_id`
+	replyTo := gjson.GetBytes(*content, replyToPath).Str
+	if !strings.HasPrefix(replyTo, "~") {
+		return nil
+	}
+	target, err := h.DB.Event.GetByTransactionID(ctx, strings.TrimPrefix(replyTo, "~"))
+	if err != nil {
+		return fmt.Errorf("failed to get previous event: %w", err)
+	}
+	var resolved string
+	if target != nil && target.ID != "" && !strings.HasPrefix(target.ID.String(), "~") {
+		resolved = target.ID.String()
+	} else {
+		resolved = gjson.GetBytes(*content, `m\.relates_to.event_id`).Str
+	}
+	*content, err = sjson.SetBytes(*content, replyToPath, resolved)
+	return err
+}
@@ -0,0 +1,323 @@
+// Copyright (c) 2026 Tulir Asokan
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package hicli
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+	"unicode/utf8"
+
+	"maunium.net/go/mautrix/event"
+	"maunium.net/go/mautrix/format"
+	"maunium.net/go/mautrix/id"
+
+	"go.mau.fi/gomuks/pkg/hicli/jsoncmd"
+)
+
+// testParagraphs returns text of exactly the given length consisting of short paragraphs.
+func testParagraphs(length int) string {
+	var buf strings.Builder
+	for buf.Len() < length {
+		buf.WriteString("lorem ipsum dolor sit amet\n\n")
+	}
+	text := []byte(buf.String()[:length])
+	// Chunks are trimmed, so make sure the text doesn't end with whitespace
+	for i := len(text) - 1; text[i] == '\n' || text[i] == ' '; i-- {
+		text[i] = 'x'
+	}
+	return string(text)
+}
+
+func TestSplitMarkdown_SizeLimit(t *testing.T) {
+	tests := []struct {
+		name       string
+		length     int
+		wantChunks int
+	}{
+		{"well below limit", 1000, 1},
+		{"exactly at limit", MaxEventSize, 1},
+		{"just over limit", MaxEventSize + 1, 2},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			text := testParagraphs(test.length)
+			if len(text) != test.length {
+				t.Fatalf("Test text has length %d, want %d", len(text), test.length)
+			}
+			chunks, splitCode := splitMarkdown(text, MaxEventSize)
+			if len(chunks) != test.wantChunks {
+				t.Fatalf("Got %d chunks, want %d", len(chunks), test.wantChunks)
+			} else if splitCode {
+				t.Error("Code block split reported for text without code blocks")
+			}
+			for i, chunk := range chunks {
+				if len(chunk) > MaxEventSize {
+					t.Errorf("Chunk %d is %d bytes, over the limit", i, len(chunk))
+				}
+			}
+			if len(chunks) == 1 && chunks[0] != text {
+				t.Error("Text that fits in one chunk was modified")
+			}
+		})
+	}
+}
+
+func TestIsTooLarge_Boundary(t *testing.T) {
+	ctx := context.Background()
+	h, _ := newTestClient(t)
+	const roomID id.RoomID = "!split:example.com"
+	putTestMember(t, h, roomID, testUserID, event.MembershipJoin)
+	baseSize, err := estimateEventSize(roomID, event.EventMessage, &event.Content{Parsed: &event.MessageEventContent{MsgType: event.MsgText}}, false)
+	if err != nil {
+		t.Fatalf("Failed to estimate size: %v", err)
+	}
+	for _, extra := range []int{0, 1} {
+		content := &event.MessageEventContent{MsgType: event.MsgText, Body: strings.Repeat("a", MaxEventSize-baseSize+extra)}
+		tooLarge, _, err := h.isTooLarge(ctx, roomID, event.EventMessage, content, nil, false)
+		if err != nil {
+			t.Fatalf("isTooLarge failed: %v", err)
+		} else if tooLarge != (extra > 0) {
+			t.Errorf("isTooLarge at MaxEventSize%+d = %t", extra, tooLarge)
+		}
+	}
+}
+
+func TestSplitMarkdown_ReopensFence(t *testing.T) {
+	tests := []struct {
+		name   string
+		opener string
+		closer string
+	}{
+		{"backticks with language", "```go", "```"},
+		{"backticks with info string", "```python title=\"x.py\"", "```"},
+		{"long tilde fence", "~~~~rust", "~~~~"},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			var buf strings.Builder
+			buf.WriteString("intro\n\n" + test.opener + "\n")
+			for range 40 {
+				buf.WriteString("fmt.Println(\"hello world\")\n")
+			}
+			buf.WriteString(test.closer + "\n\noutro")
+			chunks, splitCode := splitMarkdown(buf.String(), 300)
+			if !splitCode {
+				t.Fatal("Code block split wasn't reported")
+			} else if len(chunks) < 3 {
+				t.Fatalf("Expected the code block to be split into several chunks, got %d", len(chunks))
+			}
+			for i, chunk := range chunks {
+				if len(chunk) > 300 {
+					t.Errorf("Chunk %d is %d bytes, over the limit", i, len(chunk))
+				}
+				lines := strings.Split(chunk, "\n")
+				var fenceLines int
+				for _, line := range lines {
+					if strings.HasPrefix(line, test.closer[:3]) {
+						fenceLines++
+					}
+				}
+				if fenceLines%2 != 0 {
+					t.Errorf("Chunk %d has unbalanced fences: %q", i, chunk)
+				}
+				// Every chunk with code must open the fence itself, with the same info string as the original
+				if strings.Contains(chunk, "fmt.Println") && lines[0] != test.opener {
+					t.Errorf("Chunk %d doesn't reopen the fence with the original info string: %q", i, lines[0])
+				}
+			}
+			if last := chunks[len(chunks)-1]; !strings.HasSuffix(last, "outro") {
+				t.Errorf("Last chunk doesn't end with the text after the code block: %q", last)
+			}
+		})
+	}
+}
+
+func TestSplitMarkdown_MultibyteCutPoint(t *testing.T) {
+	tests := []struct {
+		name string
+		char string
+	}{
+		{"two bytes", "ä"},
+		{"three bytes", "€"},
+		{"four bytes", "🐈"},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			// A single line without spaces, so the only option is to cut in the middle of it.
+			// maxLen/2 isn't a multiple of any of the character sizes.
+			text := "a" + strings.Repeat(test.char, 500)
+			chunks, _ := splitMarkdown(text, 98)
+			if len(chunks) < 2 {
+				t.Fatalf("Expected multiple chunks, got %d", len(chunks))
+			}
+			for i, chunk := range chunks {
+				if !utf8.ValidString(chunk) {
+					t.Errorf("Chunk %d isn't valid UTF-8: %q", i, chunk)
+				}
+			}
+			if joined := strings.Join(chunks, ""); joined != text {
+				t.Error("Joined chunks don't match the original text")
+			}
+		})
+	}
+}
+
+func TestSendSplitMessage_CodeBlockNotAllowed(t *testing.T) {
+	ctx := context.Background()
+	h, _ := newTestClient(t)
+	const roomID id.RoomID = "!split:example.com"
+	putTestMember(t, h, roomID, testUserID, event.MembershipJoin)
+	room, err := h.DB.Room.Get(ctx, roomID)
+	if err != nil {
+		t.Fatalf("Failed to get room: %v", err)
+	}
+	var buf strings.Builder
+	buf.WriteString("```\n")
+	for buf.Len() < MaxEventSize+1000 {
+		buf.WriteString("some code that is too long to fit in one event\n")
+	}
+	buf.WriteString("```")
+	text := buf.String()
+	content := format.RenderMarkdownCustom(text, defaultNoHTML)
+	_, err = h.sendSplitMessage(ctx, room, &content, nil, text, false, 0, false)
+	if !errors.Is(err, ErrMessageTooLarge) {
+		t.Errorf("Expected ErrMessageTooLarge, got %v", err)
+	}
+}
+
+// fakeSendServer records the content of sent events by part index and responds with event IDs based on the index.
+// Parts whose index is in fail are rejected with a non-retryable error.
+type fakeSendServer struct {
+	lock sync.Mutex
+	sent map[int]*event.MessageEventContent
+	fail map[int]bool
+	// parts receives the total number of parts when the first part is sent.
+	parts chan int
+}
+
+var partNumberRegex = regexp.MustCompile(`^\((\d+)/(\d+)\)`)
+
+func (fss *fakeSendServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	if !strings.Contains(r.URL.Path, "/send/m.room.message/") {
+		_, _ = w.Write([]byte("{}"))
+		return
+	}
+	var content event.MessageEventContent
+	_ = json.NewDecoder(r.Body).Decode(&content)
+	match := partNumberRegex.FindStringSubmatch(content.Body)
+	if match == nil {
+		http.Error(w, `{"errcode":"M_UNKNOWN","error":"unnumbered part"}`, http.StatusBadRequest)
+		return
+	}
+	idx, _ := strconv.Atoi(match[1])
+	idx--
+	if idx == 0 {
+		total, _ := strconv.Atoi(match[2])
+		fss.parts <- total
+	}
+	fss.lock.Lock()
+	defer fss.lock.Unlock()
+	if fss.fail[idx] {
+		w.WriteHeader(http.StatusForbidden)
+		_, _ = w.Write([]byte(`{"errcode":"M_FORBIDDEN","error":"nope"}`))
+		return
+	}
+	fss.sent[idx] = &content
+	_ = json.NewEncoder(w).Encode(map[string]any{"event_id": fmt.Sprintf("$part%d", idx+1)})
+}
+
+func TestSendSplitMessage_ThreadFallback(t *testing.T) {
+	for _, failFirst := range []bool{false, true} {
+		t.Run(fmt.Sprintf("first part failed=%t", failFirst), func(t *testing.T) {
+			ctx := context.Background()
+			h, _ := newTestClient(t)
+			fss := &fakeSendServer{
+				sent:  make(map[int]*event.MessageEventContent),
+				fail:  map[int]bool{0: failFirst},
+				parts: make(chan int, 1),
+			}
+			srv := httptest.NewServer(fss)
+			t.Cleanup(srv.Close)
+			h.Client.HomeserverURL, _ = url.Parse(srv.URL)
+			h.Client.AccessToken = "fake"
+			h.Client.DefaultHTTPRetries = 0
+			completed := make(chan *jsoncmd.SendComplete, 64)
+			h.EventHandler = func(evt any) {
+				if sc, ok := evt.(*jsoncmd.SendComplete); ok {
+					completed <- sc
+				}
+			}
+			const roomID id.RoomID = "!split:example.com"
+			putTestMember(t, h, roomID, testUserID, event.MembershipJoin)
+			room, err := h.DB.Room.Get(ctx, roomID)
+			if err != nil {
+				t.Fatalf("Failed to get room: %v", err)
+			}
+
+			text := testParagraphs(MaxEventSize * 2)
+			content := format.RenderMarkdownCustom(text, defaultNoHTML)
+			content.RelatesTo = (&event.RelatesTo{}).SetThread("$root", "$latest")
+			if _, err = h.sendSplitMessage(ctx, room, &content, nil, text, false, 0, false); err != nil {
+				t.Fatalf("Failed to send split message: %v", err)
+			}
+			var parts int
+			select {
+			case parts = <-fss.parts:
+			case <-time.After(5 * time.Second):
+				t.Fatal("Timed out waiting for the first part to be sent")
+			}
+			if parts < 2 {
+				t.Fatalf("Message was split into %d parts", parts)
+			}
+			for range parts {
+				select {
+				case <-completed:
+				case <-time.After(5 * time.Second):
+					t.Fatal("Timed out waiting for parts to be sent")
+				}
+			}
+
+			fss.lock.Lock()
+			defer fss.lock.Unlock()
+			for i := range parts {
+				sent, ok := fss.sent[i]
+				if fss.fail[i] {
+					continue
+				} else if !ok {
+					t.Errorf("Part %d wasn't sent", i+1)
+					continue
+				}
+				wantReplyTo := id.EventID("$latest")
+				if i > 0 && fss.fail[i-1] {
+					wantReplyTo = "$root"
+				} else if i > 0 {
+					wantReplyTo = id.EventID(fmt.Sprintf("$part%d", i))
+				}
+				rel := sent.RelatesTo
+				if rel.GetThreadParent() != "$root" {
+					t.Errorf("Part %d isn't in the thread: %+v", i+1, rel)
+				} else if !rel.IsFallingBack {
+					t.Errorf("Part %d doesn't have is_falling_back set", i+1)
+				} else if rel.GetReplyTo() != wantReplyTo {
+					t.Errorf("Part %d falls back to a reply to %s, want %s", i+1, rel.GetReplyTo(), wantReplyTo)
+				}
+			}
+		})
+	}
+}
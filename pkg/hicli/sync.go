@@ -27,11 +27,11 @@ import (
 	"maunium.net/go/mautrix/crypto"
 	"maunium.net/go/mautrix/crypto/olm"
 	"maunium.net/go/mautrix/event"
-	"maunium.net/go/mautrix/format"
 	"maunium.net/go/mautrix/id"
 	"maunium.net/go/mautrix/pushrules"
 
 	"go.mau.fi/gomuks/pkg/hicli/database"
+	"go.mau.fi/gomuks/pkg/hicli/editsource"
 	"go.mau.fi/gomuks/pkg/hicli/jsoncmd"
 )
 
@@ -41,6 +41,9 @@ type syncContext struct {
 	evt *jsoncmd.SyncComplete
 
 	changedSpaces []id.RoomID
+
+	changedPolicyRooms []id.RoomID
+	newMembers         map[id.RoomID][]id.UserID
 }
 
 func (h *HiClient) markSyncErrored(err error, permanent bool) {
@@ -190,6 +193,9 @@ func (h *HiClient) postProcessSyncResponse(ctx context.Context, resp *mautrix.Re
 	if !syncCtx.evt.IsEmpty() {
 		h.EventHandler(syncCtx.evt)
 	}
+	if len(syncCtx.changedPolicyRooms) > 0 || len(syncCtx.newMembers) > 0 {
+		go h.handlePolicyChanges(ctx, syncCtx.changedPolicyRooms, syncCtx.newMembers)
+	}
 }
 
 func (h *HiClient) asyncPostProcessSyncResponse(ctx context.Context, resp *mautrix.RespSync, since string) {
@@ -329,6 +335,13 @@ func (h *HiClient) processSyncJoinedRoom(ctx context.Context, roomID id.RoomID,
 			// but not the same for all rooms without a timestamp.
 			SortingTimestamp: jsontime.UM(time.UnixMilli(time.Now().Unix())),
 		}
+	} else if !existingRoomData.LeftAt.IsZero() {
+		// The room was rejoined after being archived
+		err = h.DB.Room.SetLeft(ctx, roomID, time.Time{})
+		if err != nil {
+			return fmt.Errorf("failed to unarchive room: %w", err)
+		}
+		existingRoomData.LeftAt = jsontime.UnixMilli{}
 	}
 
 	accountData := make(map[event.Type]*database.AccountData, len(room.AccountData.Events))
@@ -367,6 +380,7 @@ func (h *HiClient) processSyncJoinedRoom(ctx context.Context, roomID id.RoomID,
 		&room.State,
 		&room.Timeline,
 		&room.Summary,
+		room.UnreadNotifications,
 		receiptsList,
 		newOwnReceipts,
 		accountData,
@@ -378,10 +392,11 @@ func (h *HiClient) processSyncJoinedRoom(ctx context.Context, roomID id.RoomID,
 }
 
 func (h *HiClient) processSyncLeftRoom(ctx context.Context, roomID id.RoomID, room *mautrix.SyncLeftRoom) error {
-	zerolog.Ctx(ctx).Debug().Stringer("room_id", roomID).Msg("Deleting left room")
-	err := h.DB.Room.Delete(ctx, roomID)
+	zerolog.Ctx(ctx).Debug().Stringer("room_id", roomID).Msg("Archiving left room")
+	// The room data is kept so that the history can still be browsed until the room is forgotten
+	err := h.DB.Room.SetLeft(ctx, roomID, time.Now())
 	if err != nil {
-		return fmt.Errorf("failed to delete room: %w", err)
+		return fmt.Errorf("failed to mark room as left: %w", err)
 	}
 	err = h.DB.InvitedRoom.Delete(ctx, roomID)
 	if err != nil {
@@ -584,12 +599,7 @@ func (h *HiClient) calculateLocalContent(ctx context.Context, dbEvt *database.Ev
 			if dbEvt.LocalContent != nil && dbEvt.LocalContent.EditSource != "" {
 				editSource = dbEvt.LocalContent.EditSource
 			} else if evt.Sender == h.Account.UserID {
-				editSource, _ = format.HTMLToMarkdownFull(htmlToMarkdownForInput, content.FormattedBody)
-				if content.MsgType == event.MsgEmote {
-					editSource = "/me " + editSource
-				} else if content.MsgType == event.MsgNotice {
-					editSource = "/notice " + editSource
-				}
+				editSource = editsource.FromContent(content)
 			}
 		} else {
 			hasSpecialCharacters := false
@@ -623,6 +633,7 @@ func (h *HiClient) calculateLocalContent(ctx context.Context, dbEvt *database.Ev
 			EditSource:           editSource,
 			ReplyFallbackRemoved: dbEvt.LocalContent.GetReplyFallbackRemoved(),
 			PushRuleID:           dbEvt.LocalContent.GetPushRuleID(),
+			Imported:             dbEvt.LocalContent.GetImported(),
 		}, inlineImages
 	}
 	return dbEvt.LocalContent, nil
@@ -657,11 +668,27 @@ func (h *HiClient) postDecryptProcess(ctx context.Context, llSummary *mautrix.La
 			}
 			dbEvt.LocalContent.PushRuleID = pushRuleID
 		}
+		if dbEvt.RelationType == event.RelAnnotation && h.isReactionToMe(ctx, dbEvt) {
+			dbEvt.UnreadType |= database.UnreadTypeReactionToMe
+		}
 	}
 	dbEvt.LocalContent, inlineImages = h.calculateLocalContent(ctx, dbEvt, evt)
 	return
 }
 
+func (h *HiClient) isReactionToMe(ctx context.Context, dbEvt *database.Event) bool {
+	target, err := h.DB.Event.GetByID(ctx, dbEvt.RelatesTo)
+	if err != nil {
+		zerolog.Ctx(ctx).Err(err).
+			Stringer("event_id", dbEvt.ID).
+			Stringer("target_event_id", dbEvt.RelatesTo).
+			Msg("Failed to get reaction target")
+		return false
+	}
+	// If the target isn't stored yet, the reaction will be flagged when the target is received
+	return target != nil && target.Sender == h.Account.UserID
+}
+
 func (h *HiClient) fillPrevContent(ctx context.Context, evt *event.Event) error {
 	if evt.StateKey != nil && evt.Unsigned.PrevContent == nil && evt.Unsigned.ReplacesState != "" {
 		replacesState, err := h.DB.Event.GetByID(ctx, evt.Unsigned.ReplacesState)
@@ -724,6 +751,12 @@ func (h *HiClient) processEvent(
 	if err != nil {
 		return dbEvt, fmt.Errorf("failed to save event %s: %w", evt.ID, err)
 	}
+	if evt.Sender == h.Account.UserID && evt.StateKey == nil {
+		err = h.DB.Event.MarkReactionsToMe(ctx, evt.RoomID, evt.ID, h.Account.UserID)
+		if err != nil {
+			return dbEvt, fmt.Errorf("failed to mark reactions to %s: %w", evt.ID, err)
+		}
+	}
 	if decryptedMautrixEvt != nil {
 		h.cacheMedia(ctx, decryptedMautrixEvt, dbEvt.RowID)
 	} else {
@@ -767,6 +800,7 @@ func (h *HiClient) processStateAndTimeline(
 	state *mautrix.SyncEventsList,
 	timeline *mautrix.SyncTimeline,
 	summary *mautrix.LazyLoadSummary,
+	serverCounts *mautrix.UnreadNotificationCounts,
 	receipts []*database.Receipt,
 	newOwnReceipts []id.EventID,
 	accountData map[event.Type]*database.AccountData,
@@ -777,6 +811,14 @@ func (h *HiClient) processStateAndTimeline(
 		SortingTimestamp: room.SortingTimestamp,
 		NameQuality:      room.NameQuality,
 		UnreadCounts:     room.UnreadCounts,
+
+		ServerUnreadCounts: room.ServerUnreadCounts,
+	}
+	if serverCounts != nil {
+		updatedRoom.ServerUnreadCounts = database.ServerUnreadCounts{
+			Highlights:    serverCounts.HighlightCount,
+			Notifications: serverCounts.NotificationCount,
+		}
 	}
 	heroesChanged := false
 	if summary.Heroes == nil && summary.JoinedMemberCount == nil && summary.InvitedMemberCount == nil {
@@ -852,6 +894,13 @@ func (h *HiClient) processStateAndTimeline(
 					Event:     dbEvt,
 					Room:      room,
 				})
+			} else if dbEvt.UnreadType.Is(database.UnreadTypeReactionToMe) && h.firstSyncReceived {
+				newNotifications = append(newNotifications, jsoncmd.SyncNotification{
+					RowID:    dbEvt.RowID,
+					Reaction: true,
+					Event:    dbEvt,
+					Room:     room,
+				})
 			}
 			newUnreadCounts.AddOne(dbEvt.UnreadType)
 		}
@@ -880,6 +929,7 @@ func (h *HiClient) processStateAndTimeline(
 				return -1, fmt.Errorf("failed to save current state event ID %s for %s/%s: %w", evt.ID, evt.Type.Type, *evt.StateKey, err)
 			}
 			processImportantEvent(ctx, evt, room, updatedRoom, dbEvt.RowID, sdc)
+			trackPolicyChanges(ctx, room.ID, evt, membership)
 		}
 		allNewEvents = append(allNewEvents, dbEvt)
 		addedEvents[dbEvt.RowID] = struct{}{}
@@ -919,6 +969,8 @@ func (h *HiClient) processStateAndTimeline(
 		}
 	}
 	var timelineRowTuples []database.TimelineRowTuple
+	var newGaps []*database.TimelineGap
+	var timelineReset bool
 	receiptMap := make(map[id.EventID][]*database.Receipt)
 	for _, receipt := range receipts {
 		if receipt.UserID != h.Account.UserID {
@@ -993,22 +1045,58 @@ func (h *HiClient) processStateAndTimeline(
 		if len(decryptionQueue) > 0 {
 			ctx.Value(syncContextKey).(*syncContext).shouldWakeupRequestQueue = true
 		}
+		var gapPrevBatch string
 		if timeline.Limited {
-			err = h.DB.Timeline.Clear(ctx, room.ID)
+			// If there's an existing timeline, keep it and mark a gap that can be filled later.
+			// Otherwise, just reset the timeline and start paginating from the new prev_batch.
+			hasOldTimeline, err := h.DB.Timeline.HasAny(ctx, room.ID)
 			if err != nil {
-				return fmt.Errorf("failed to clear old timeline: %w", err)
+				return fmt.Errorf("failed to check if room has timeline: %w", err)
 			}
-			updatedRoom.PrevBatch = timeline.PrevBatch
-			h.paginationInterrupterLock.Lock()
-			if interrupt, ok := h.paginationInterrupter[room.ID]; ok {
-				interrupt(ErrTimelineReset)
+			if hasOldTimeline && timeline.PrevBatch != "" {
+				gapPrevBatch = timeline.PrevBatch
+			} else {
+				err = h.DB.Timeline.Clear(ctx, room.ID)
+				if err != nil {
+					return fmt.Errorf("failed to clear old timeline: %w", err)
+				}
+				timelineReset = true
+				updatedRoom.PrevBatch = timeline.PrevBatch
+				// Pagination requests in progress would insert events into the cleared timeline
+				h.paginationInterrupterLock.Lock()
+				if interrupt, ok := h.paginationInterrupter[room.ID]; ok {
+					interrupt(ErrTimelineReset)
+				}
+				h.paginationInterrupterLock.Unlock()
 			}
-			h.paginationInterrupterLock.Unlock()
 		}
-		timelineRowTuples, err = h.DB.Timeline.Append(ctx, room.ID, timelineIDs)
+		if gapPrevBatch != "" {
+			timelineRowTuples, err = h.DB.Timeline.AppendAfterGap(ctx, room.ID, timelineIDs)
+		} else {
+			timelineRowTuples, err = h.DB.Timeline.Append(ctx, room.ID, timelineIDs)
+		}
 		if err != nil {
 			return fmt.Errorf("failed to append timeline: %w", err)
 		}
+		// If some of the events were already in the timeline, the new events connect to the old ones and there's no gap
+		if gapPrevBatch != "" && len(timelineRowTuples) == len(timelineIDs) {
+			gap := &database.TimelineGap{
+				RoomID:        room.ID,
+				TimelineRowID: timelineRowTuples[0].Timeline,
+				PrevBatch:     gapPrevBatch,
+			}
+			if serverCounts != nil {
+				// The server's notification count includes the events in the gap, while the local count
+				// only includes events that were actually received, so the difference is what was missed.
+				localCount := room.UnreadNotifications + newUnreadCounts.UnreadNotifications
+				gap.MissedCount = max(serverCounts.NotificationCount-localCount, 0)
+			}
+			err = h.DB.Timeline.PutGap(ctx, gap)
+			if err != nil {
+				return fmt.Errorf("failed to save timeline gap: %w", err)
+			}
+			newGaps = append(newGaps, gap)
+		}
 	} else {
 		timelineRowTuples = make([]database.TimelineRowTuple, 0)
 	}
@@ -1057,7 +1145,7 @@ func (h *HiClient) processStateAndTimeline(
 		updatedRoom.UnreadCounts.Add(newUnreadCounts)
 	}
 	dismissNotifications := room.UnreadNotifications > 0 && updatedRoom.UnreadNotifications == 0 && len(newNotifications) == 0
-	if timeline.PrevBatch != "" && (room.PrevBatch == "" || timeline.Limited) {
+	if timeline.PrevBatch != "" && (room.PrevBatch == "" || timelineReset) {
 		updatedRoom.PrevBatch = timeline.PrevBatch
 	}
 	roomChanged := updatedRoom.CheckChangesAndCopyInto(room)
@@ -1081,7 +1169,8 @@ func (h *HiClient) processStateAndTimeline(
 			Timeline:    timelineRowTuples,
 			AccountData: accountData,
 			State:       changedState,
-			Reset:       timeline.Limited,
+			Reset:       timelineReset,
+			Gaps:        newGaps,
 			Events:      allNewEvents,
 			Receipts:    receiptMap,
 
@@ -1314,7 +1403,7 @@ func processImportantEvent(
 	}
 	switch evt.Type {
 	case event.StateCreate, event.StateTombstone, event.StateRoomName, event.StateCanonicalAlias,
-		event.StateRoomAvatar, event.StateTopic, event.StateEncryption, event.StatePowerLevels:
+		event.StateRoomAvatar, event.StateTopic, event.StateEncryption, event.StatePowerLevels, database.StateRetention:
 		if *evt.StateKey != "" {
 			return
 		}
@@ -1344,6 +1433,8 @@ func processImportantEvent(
 		updatedRoom.CreationContent, _ = evt.Content.Parsed.(*event.CreateEventContent)
 	case event.StateTombstone:
 		updatedRoom.Tombstone, _ = evt.Content.Parsed.(*event.TombstoneEventContent)
+	case database.StateRetention:
+		updatedRoom.Retention, _ = evt.Content.Parsed.(*database.RetentionEventContent)
 	case event.StateEncryption:
 		newEncryption, _ := evt.Content.Parsed.(*event.EncryptionEventContent)
 		if existingRoomData.EncryptionEvent == nil ||
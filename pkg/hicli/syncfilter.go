@@ -0,0 +1,115 @@
+// Copyright (c) 2025 Tulir Asokan
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package hicli
+
+import (
+	"fmt"
+	"slices"
+
+	"maunium.net/go/mautrix"
+	"maunium.net/go/mautrix/event"
+	"maunium.net/go/mautrix/id"
+
+	"go.mau.fi/gomuks/pkg/hicli/jsoncmd"
+)
+
+const (
+	DefaultSyncTimelineLimit = 100
+	MaxSyncTimelineLimit     = 1000
+)
+
+// RequiredSyncEventTypes are event types that can't be excluded from sync,
+// because gomuks needs them for core functionality like tracking membership and encryption.
+var RequiredSyncEventTypes = []event.Type{
+	event.StateCreate,
+	event.StateMember,
+	event.StateEncryption,
+	event.StatePowerLevels,
+	event.StateTombstone,
+	event.EventEncrypted,
+}
+
+// ValidateSyncFilter checks that the given sync filter settings are sane.
+func ValidateSyncFilter(settings *jsoncmd.SyncFilterSettings) error {
+	if settings.TimelineLimit < 0 || settings.TimelineLimit > MaxSyncTimelineLimit {
+		return fmt.Errorf("timeline limit must be between 1 and %d (or 0 for the default)", MaxSyncTimelineLimit)
+	}
+	for _, evtType := range settings.ExcludeEventTypes {
+		if evtType == "" || evtType == "*" {
+			return fmt.Errorf("invalid excluded event type %q", evtType)
+		}
+		if slices.ContainsFunc(RequiredSyncEventTypes, func(required event.Type) bool {
+			return required.Type == evtType
+		}) {
+			return fmt.Errorf("%s events are required and can't be excluded from sync", evtType)
+		}
+	}
+	return nil
+}
+
+// BuildSyncFilter creates the /sync filter for the given settings. If settings is nil, the defaults are used.
+func BuildSyncFilter(settings *jsoncmd.SyncFilterSettings) *mautrix.Filter {
+	if settings == nil {
+		settings = &jsoncmd.SyncFilterSettings{}
+	}
+	timelineLimit := settings.TimelineLimit
+	if timelineLimit <= 0 {
+		timelineLimit = DefaultSyncTimelineLimit
+	}
+	var notTypes []event.Type
+	for _, evtType := range settings.ExcludeEventTypes {
+		notTypes = append(notTypes, event.Type{Type: evtType, Class: event.MessageEventType})
+	}
+	return &mautrix.Filter{
+		Presence: &mautrix.FilterPart{
+			NotRooms: []id.RoomID{"*"},
+		},
+		Room: &mautrix.RoomFilter{
+			State: &mautrix.FilterPart{
+				LazyLoadMembers: !settings.DisableLazyLoadMembers,
+			},
+			Timeline: &mautrix.FilterPart{
+				Limit:           timelineLimit,
+				LazyLoadMembers: !settings.DisableLazyLoadMembers,
+				NotTypes:        notTypes,
+			},
+		},
+	}
+}
+
+// GetSyncFilter returns the current sync filter settings.
+func (h *HiClient) GetSyncFilter() *jsoncmd.SyncFilterSettings {
+	if settings := h.syncFilter.Load(); settings != nil {
+		return settings
+	}
+	return &jsoncmd.SyncFilterSettings{}
+}
+
+// InitSyncFilter sets the sync filter settings without restarting syncing or calling SyncFilterChanged.
+// It's meant to be called with persisted settings before the client is started.
+func (h *HiClient) InitSyncFilter(settings *jsoncmd.SyncFilterSettings) error {
+	if err := ValidateSyncFilter(settings); err != nil {
+		return err
+	}
+	h.syncFilter.Store(settings)
+	return nil
+}
+
+// SetSyncFilter changes the sync filter settings. Filters are created from scratch every time syncing starts,
+// so if syncing is running, it's restarted to upload the new filter.
+func (h *HiClient) SetSyncFilter(settings *jsoncmd.SyncFilterSettings) error {
+	if err := h.InitSyncFilter(settings); err != nil {
+		return err
+	}
+	if h.SyncFilterChanged != nil {
+		h.SyncFilterChanged(settings)
+	}
+	if h.IsSyncing() {
+		go h.Sync()
+	}
+	return nil
+}
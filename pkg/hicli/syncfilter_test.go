@@ -0,0 +1,88 @@
+// Copyright (c) 2026 Tulir Asokan
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package hicli
+
+import (
+	"encoding/json"
+	"reflect"
+	"testing"
+
+	"go.mau.fi/gomuks/pkg/hicli/jsoncmd"
+)
+
+func TestBuildSyncFilter(t *testing.T) {
+	tests := []struct {
+		name     string
+		settings *jsoncmd.SyncFilterSettings
+		want     string
+	}{
+		{"defaults", nil,
+			`{"presence":{"not_rooms":["*"]},"room":{"state":{"lazy_load_members":true},"timeline":{"limit":100,"lazy_load_members":true}}}`},
+		{"empty settings", &jsoncmd.SyncFilterSettings{},
+			`{"presence":{"not_rooms":["*"]},"room":{"state":{"lazy_load_members":true},"timeline":{"limit":100,"lazy_load_members":true}}}`},
+		{"lazy loading disabled", &jsoncmd.SyncFilterSettings{DisableLazyLoadMembers: true},
+			`{"presence":{"not_rooms":["*"]},"room":{"state":{},"timeline":{"limit":100}}}`},
+		{"custom timeline limit", &jsoncmd.SyncFilterSettings{TimelineLimit: 20},
+			`{"presence":{"not_rooms":["*"]},"room":{"state":{"lazy_load_members":true},"timeline":{"limit":20,"lazy_load_members":true}}}`},
+		{"maximum timeline limit", &jsoncmd.SyncFilterSettings{TimelineLimit: MaxSyncTimelineLimit},
+			`{"presence":{"not_rooms":["*"]},"room":{"state":{"lazy_load_members":true},"timeline":{"limit":1000,"lazy_load_members":true}}}`},
+		{"excluded types", &jsoncmd.SyncFilterSettings{DisableLazyLoadMembers: true, ExcludeEventTypes: []string{"m.reaction", "m.sticker"}},
+			`{"presence":{"not_rooms":["*"]},"room":{"state":{},"timeline":{"limit":100,"not_types":["m.reaction","m.sticker"]}}}`},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			gotJSON, err := json.Marshal(BuildSyncFilter(test.settings))
+			if err != nil {
+				t.Fatalf("Failed to marshal filter: %v", err)
+			}
+			var got, want any
+			_ = json.Unmarshal(gotJSON, &got)
+			_ = json.Unmarshal([]byte(test.want), &want)
+			if !reflect.DeepEqual(got, want) {
+				t.Errorf("BuildSyncFilter() = %s, want %s", gotJSON, test.want)
+			}
+		})
+	}
+}
+
+func TestValidateSyncFilter(t *testing.T) {
+	tests := []struct {
+		name     string
+		settings jsoncmd.SyncFilterSettings
+		wantErr  bool
+	}{
+		{"defaults", jsoncmd.SyncFilterSettings{}, false},
+		{"minimum limit", jsoncmd.SyncFilterSettings{TimelineLimit: 1}, false},
+		{"maximum limit", jsoncmd.SyncFilterSettings{TimelineLimit: MaxSyncTimelineLimit}, false},
+		{"negative limit", jsoncmd.SyncFilterSettings{TimelineLimit: -1}, true},
+		{"too large limit", jsoncmd.SyncFilterSettings{TimelineLimit: MaxSyncTimelineLimit + 1}, true},
+		{"optional types", jsoncmd.SyncFilterSettings{ExcludeEventTypes: []string{"m.reaction", "m.room.topic"}}, false},
+		{"empty type", jsoncmd.SyncFilterSettings{ExcludeEventTypes: []string{""}}, true},
+		{"wildcard", jsoncmd.SyncFilterSettings{ExcludeEventTypes: []string{"*"}}, true},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			err := ValidateSyncFilter(&test.settings)
+			if test.wantErr && err == nil {
+				t.Error("Expected an error")
+			} else if !test.wantErr && err != nil {
+				t.Errorf("Unexpected error: %v", err)
+			}
+		})
+	}
+}
+
+func TestValidateSyncFilter_RequiredTypes(t *testing.T) {
+	for _, required := range RequiredSyncEventTypes {
+		t.Run(required.Type, func(t *testing.T) {
+			settings := &jsoncmd.SyncFilterSettings{ExcludeEventTypes: []string{"m.reaction", required.Type}}
+			if err := ValidateSyncFilter(settings); err == nil {
+				t.Errorf("Excluding %s wasn't rejected", required.Type)
+			}
+		})
+	}
+}
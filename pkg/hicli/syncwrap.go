@@ -61,6 +61,7 @@ func (h *hiSyncer) ProcessResponse(ctx context.Context, resp *mautrix.RespSync,
 		}
 	}
 	c.postProcessSyncResponse(ctx, resp, since)
+	c.syncTimings.record(time.Since(c.lastSync))
 	c.syncErrors = 0
 	c.markSyncOK()
 	return nil
@@ -89,20 +90,7 @@ func (h *hiSyncer) GetFilterJSON(_ id.UserID) *mautrix.Filter {
 			},
 		}
 	}
-	return &mautrix.Filter{
-		Presence: &mautrix.FilterPart{
-			NotRooms: []id.RoomID{"*"},
-		},
-		Room: &mautrix.RoomFilter{
-			State: &mautrix.FilterPart{
-				LazyLoadMembers: true,
-			},
-			Timeline: &mautrix.FilterPart{
-				Limit:           100,
-				LazyLoadMembers: true,
-			},
-		},
-	}
+	return BuildSyncFilter(h.syncFilter.Load())
 }
 
 type hiStore HiClient
@@ -0,0 +1,324 @@
+// Copyright (c) 2025 Tulir Asokan
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package hicli
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/rs/zerolog"
+	"go.mau.fi/util/random"
+	"maunium.net/go/mautrix"
+
+	"go.mau.fi/gomuks/pkg/hicli/jsoncmd"
+)
+
+const (
+	errCodeThreePIDInUse      = "M_THREEPID_IN_USE"
+	errCodeThreePIDAuthFailed = "M_THREEPID_AUTH_FAILED"
+
+	// threePIDSessionTimeout is how long a validation email can be used. Homeservers usually expire
+	// validation tokens after an hour, so a new email must be requested after that.
+	threePIDSessionTimeout = 1 * time.Hour
+)
+
+// threePIDSession is a pending email validation. If idServer is set, the session was created on
+// the identity server for binding an address that has already been added to the account.
+type threePIDSession struct {
+	clientSecret  string
+	sessionID     string
+	submitURL     string
+	sendAttempt   int
+	createdAt     time.Time
+	idServer      string
+	idAccessToken string
+}
+
+type respGet3PIDs struct {
+	ThreePIDs []*jsoncmd.ThreePID `json:"threepids"`
+}
+
+type reqRequestEmailToken struct {
+	ClientSecret string `json:"client_secret"`
+	Email        string `json:"email"`
+	SendAttempt  int    `json:"send_attempt"`
+}
+
+type respRequestToken struct {
+	SessionID string `json:"sid"`
+	SubmitURL string `json:"submit_url,omitempty"`
+}
+
+type reqSubmitToken struct {
+	ClientSecret string `json:"client_secret"`
+	SessionID    string `json:"sid"`
+	Token        string `json:"token"`
+}
+
+type respSubmitToken struct {
+	Success bool `json:"success"`
+}
+
+type reqAdd3PID struct {
+	ClientSecret string `json:"client_secret"`
+	SessionID    string `json:"sid"`
+	Auth         any    `json:"auth,omitempty"`
+}
+
+type reqBind3PID struct {
+	ClientSecret  string `json:"client_secret"`
+	IDAccessToken string `json:"id_access_token"`
+	IDServer      string `json:"id_server"`
+	SessionID     string `json:"sid"`
+}
+
+type reqDelete3PID struct {
+	Medium  string `json:"medium"`
+	Address string `json:"address"`
+}
+
+type respIdentityRegister struct {
+	Token string `json:"token"`
+}
+
+func matrixErrCode(err error) string {
+	var httpErr mautrix.HTTPError
+	if errors.As(err, &httpErr) && httpErr.RespError != nil {
+		return httpErr.RespError.ErrCode
+	}
+	return ""
+}
+
+func (h *HiClient) Get3PIDs(ctx context.Context) ([]*jsoncmd.ThreePID, error) {
+	var resp respGet3PIDs
+	_, err := h.Client.MakeRequest(ctx, http.MethodGet, h.Client.BuildClientURL("v3", "account", "3pid"), nil, &resp)
+	if err != nil {
+		return nil, err
+	}
+	if resp.ThreePIDs == nil {
+		resp.ThreePIDs = []*jsoncmd.ThreePID{}
+	}
+	return resp.ThreePIDs, nil
+}
+
+func (h *HiClient) Delete3PID(ctx context.Context, params *jsoncmd.Delete3PIDParams) error {
+	if params.Medium == "" || params.Address == "" {
+		return fmt.Errorf("medium and address are required")
+	}
+	_, err := h.Client.MakeRequest(ctx, http.MethodPost, h.Client.BuildClientURL("v3", "account", "3pid", "delete"), &reqDelete3PID{
+		Medium:  params.Medium,
+		Address: params.Address,
+	}, nil)
+	return err
+}
+
+func (h *HiClient) Add3PIDEmail(ctx context.Context, params *jsoncmd.Add3PIDEmailParams) (*jsoncmd.Add3PIDResponse, error) {
+	email := strings.TrimSpace(params.Email)
+	if email == "" {
+		return nil, fmt.Errorf("email address is required")
+	}
+	h.threePIDLock.Lock()
+	defer h.threePIDLock.Unlock()
+	if h.threePIDSessions == nil {
+		h.threePIDSessions = make(map[string]*threePIDSession)
+	}
+	sess := h.threePIDSessions[email]
+	if sess != nil && time.Since(sess.createdAt) > threePIDSessionTimeout {
+		delete(h.threePIDSessions, email)
+		if !params.Resend {
+			return &jsoncmd.Add3PIDResponse{Status: jsoncmd.Add3PIDSessionExpired, Binding: sess.idServer != ""}, nil
+		}
+		sess = nil
+	}
+	if sess == nil || params.Resend {
+		return h.request3PIDEmailToken(ctx, email, sess, "")
+	}
+	if params.Code != "" {
+		if sess.submitURL == "" {
+			return nil, fmt.Errorf("server doesn't support validation codes, open the link in the email instead")
+		}
+		resp, err := h.submit3PIDToken(ctx, sess, params.Code)
+		if err != nil || resp != nil {
+			return resp, err
+		}
+	}
+	if sess.idServer != "" {
+		return h.bind3PID(ctx, email, sess)
+	}
+	req := &reqAdd3PID{ClientSecret: sess.clientSecret, SessionID: sess.sessionID}
+	uiaResp, err := h.doPasswordUIARequest(ctx, h.Client.BuildClientURL("v3", "account", "3pid", "add"), params.Password, req, &req.Auth)
+	switch matrixErrCode(err) {
+	case errCodeThreePIDAuthFailed:
+		return &jsoncmd.Add3PIDResponse{Status: jsoncmd.Add3PIDNotValidated, CodeSupported: sess.submitURL != ""}, nil
+	case errCodeThreePIDInUse:
+		delete(h.threePIDSessions, email)
+		return &jsoncmd.Add3PIDResponse{Status: jsoncmd.Add3PIDInUse}, nil
+	}
+	if err != nil {
+		return nil, err
+	} else if !uiaResp.Success {
+		return &jsoncmd.Add3PIDResponse{Status: jsoncmd.Add3PIDAuthFailed, UIA: uiaResp.UIA}, nil
+	}
+	delete(h.threePIDSessions, email)
+	zerolog.Ctx(ctx).Info().Msg("Added email address to account")
+	if params.IdentityServer == "" {
+		return &jsoncmd.Add3PIDResponse{Status: jsoncmd.Add3PIDAdded}, nil
+	}
+	// Binding needs a separate validation session on the identity server
+	resp, err := h.request3PIDEmailToken(ctx, email, nil, params.IdentityServer)
+	if err != nil {
+		return &jsoncmd.Add3PIDResponse{Status: jsoncmd.Add3PIDAdded, BindError: err.Error()}, nil
+	}
+	return resp, nil
+}
+
+// request3PIDEmailToken sends a validation email. If sess is nil, a new session is created on the homeserver,
+// or on the identity server if idServer is set. Otherwise, the email is sent again for the existing session.
+func (h *HiClient) request3PIDEmailToken(ctx context.Context, email string, sess *threePIDSession, idServer string) (*jsoncmd.Add3PIDResponse, error) {
+	if sess == nil {
+		sess = &threePIDSession{clientSecret: random.String(32)}
+		if idServer != "" {
+			var err error
+			sess.idServer = idServer
+			sess.idAccessToken, err = h.registerWithIdentityServer(ctx, idServer)
+			if err != nil {
+				return nil, err
+			}
+		}
+	}
+	sess.sendAttempt++
+	req := &reqRequestEmailToken{
+		ClientSecret: sess.clientSecret,
+		Email:        email,
+		SendAttempt:  sess.sendAttempt,
+	}
+	var resp respRequestToken
+	var err error
+	if sess.idServer != "" {
+		err = h.identityServerRequest(ctx, identityServerURL(sess.idServer, "validate", "email", "requestToken"), sess.idAccessToken, req, &resp)
+		resp.SubmitURL = identityServerURL(sess.idServer, "validate", "email", "submitToken")
+	} else {
+		_, err = h.Client.MakeRequest(ctx, http.MethodPost, h.Client.BuildClientURL("v3", "account", "3pid", "email", "requestToken"), req, &resp)
+	}
+	if matrixErrCode(err) == errCodeThreePIDInUse {
+		return &jsoncmd.Add3PIDResponse{Status: jsoncmd.Add3PIDInUse}, nil
+	} else if err != nil {
+		return nil, fmt.Errorf("failed to request validation email: %w", err)
+	}
+	sess.sessionID = resp.SessionID
+	sess.submitURL = resp.SubmitURL
+	sess.createdAt = time.Now()
+	h.threePIDSessions[email] = sess
+	return &jsoncmd.Add3PIDResponse{
+		Status:        jsoncmd.Add3PIDValidationSent,
+		CodeSupported: sess.submitURL != "",
+		Binding:       sess.idServer != "",
+	}, nil
+}
+
+// submit3PIDToken submits the validation code from the email. It returns a non-nil response if the code was rejected.
+func (h *HiClient) submit3PIDToken(ctx context.Context, sess *threePIDSession, code string) (*jsoncmd.Add3PIDResponse, error) {
+	var resp respSubmitToken
+	// The submit URL may be on a different server, so the homeserver access token must not be sent to it
+	err := h.identityServerRequest(ctx, sess.submitURL, sess.idAccessToken, &reqSubmitToken{
+		ClientSecret: sess.clientSecret,
+		SessionID:    sess.sessionID,
+		Token:        strings.TrimSpace(code),
+	}, &resp)
+	var httpErr mautrix.HTTPError
+	if (errors.As(err, &httpErr) && httpErr.IsStatus(http.StatusBadRequest)) || (err == nil && !resp.Success) {
+		return &jsoncmd.Add3PIDResponse{Status: jsoncmd.Add3PIDInvalidCode, CodeSupported: true, Binding: sess.idServer != ""}, nil
+	} else if err != nil {
+		return nil, fmt.Errorf("failed to submit validation code: %w", err)
+	}
+	return nil, nil
+}
+
+func (h *HiClient) bind3PID(ctx context.Context, email string, sess *threePIDSession) (*jsoncmd.Add3PIDResponse, error) {
+	_, err := h.Client.MakeRequest(ctx, http.MethodPost, h.Client.BuildClientURL("v3", "account", "3pid", "bind"), &reqBind3PID{
+		ClientSecret:  sess.clientSecret,
+		IDAccessToken: sess.idAccessToken,
+		IDServer:      sess.idServer,
+		SessionID:     sess.sessionID,
+	}, nil)
+	switch matrixErrCode(err) {
+	case errCodeThreePIDAuthFailed:
+		return &jsoncmd.Add3PIDResponse{Status: jsoncmd.Add3PIDNotValidated, CodeSupported: sess.submitURL != "", Binding: true}, nil
+	case errCodeThreePIDInUse:
+		delete(h.threePIDSessions, email)
+		return &jsoncmd.Add3PIDResponse{Status: jsoncmd.Add3PIDInUse, Binding: true}, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to bind address on identity server: %w", err)
+	}
+	delete(h.threePIDSessions, email)
+	zerolog.Ctx(ctx).Info().Str("identity_server", sess.idServer).Msg("Bound email address on identity server")
+	return &jsoncmd.Add3PIDResponse{Status: jsoncmd.Add3PIDBound, Binding: true}, nil
+}
+
+func identityServerURL(server string, path ...string) string {
+	return (&url.URL{
+		Scheme: "https",
+		Host:   server,
+		Path:   "/_matrix/identity/v2/" + strings.Join(path, "/"),
+	}).String()
+}
+
+// registerWithIdentityServer gets an access token for the identity server using an OpenID token from the homeserver.
+func (h *HiClient) registerWithIdentityServer(ctx context.Context, server string) (string, error) {
+	openIDToken, err := h.Client.RequestOpenIDToken(ctx)
+	if err != nil {
+		return "", fmt.Errorf("failed to get OpenID token: %w", err)
+	}
+	var resp respIdentityRegister
+	err = h.identityServerRequest(ctx, identityServerURL(server, "account", "register"), "", openIDToken, &resp)
+	if err != nil {
+		return "", fmt.Errorf("failed to register with identity server: %w", err)
+	}
+	return resp.Token, nil
+}
+
+// identityServerRequest makes a POST request to a server other than the homeserver, so unlike
+// the mautrix client, it never includes the homeserver access token.
+func (h *HiClient) identityServerRequest(ctx context.Context, reqURL, accessToken string, reqData, respData any) error {
+	body, err := json.Marshal(reqData)
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, reqURL, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if accessToken != "" {
+		req.Header.Set("Authorization", "Bearer "+accessToken)
+	}
+	resp, err := h.Client.Client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	respBody, err := io.ReadAll(io.LimitReader(resp.Body, 1024*1024))
+	if err != nil {
+		return err
+	} else if resp.StatusCode >= 300 {
+		var respErr mautrix.RespError
+		if json.Unmarshal(respBody, &respErr) != nil || respErr.ErrCode == "" {
+			return mautrix.HTTPError{Request: req, Response: resp, ResponseBody: string(respBody)}
+		}
+		return mautrix.HTTPError{Request: req, Response: resp, RespError: &respErr}
+	}
+	return json.Unmarshal(respBody, respData)
+}
@@ -0,0 +1,294 @@
+// Copyright (c) 2026 Tulir Asokan
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package hicli
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+
+	"maunium.net/go/mautrix"
+	"maunium.net/go/mautrix/crypto"
+	"maunium.net/go/mautrix/event"
+	"maunium.net/go/mautrix/id"
+	"maunium.net/go/mautrix/pushrules"
+
+	"go.mau.fi/gomuks/pkg/hicli/database"
+	"go.mau.fi/gomuks/pkg/hicli/jsoncmd"
+)
+
+const (
+	unreadTestRoomID id.RoomID = "!unread:example.com"
+	unreadTestSender id.UserID = "@bob:example.com"
+)
+
+// unreadTestPushRules notifies for all messages and highlights messages that mention the test user.
+const unreadTestPushRules = `{
+	"override": [{
+		"rule_id": ".m.rule.is_user_mention",
+		"default": true,
+		"enabled": true,
+		"conditions": [{"kind": "event_property_contains", "key": "content.m\\.mentions.user_ids", "value": "@alice:example.com"}],
+		"actions": ["notify", {"set_tweak": "highlight"}]
+	}],
+	"underride": [{
+		"rule_id": ".m.rule.message",
+		"default": true,
+		"enabled": true,
+		"conditions": [{"kind": "event_match", "key": "type", "pattern": "m.room.message"}],
+		"actions": ["notify"]
+	}]
+}`
+
+func setUnreadTestPushRules(t *testing.T, h *HiClient) {
+	t.Helper()
+	var rules pushrules.PushRuleset
+	if err := json.Unmarshal([]byte(unreadTestPushRules), &rules); err != nil {
+		t.Fatalf("Failed to parse push rules: %v", err)
+	}
+	h.PushRules.Store(&rules)
+}
+
+func unreadTestEvent(evtID string, sender id.UserID, evtType event.Type, content any) map[string]any {
+	return map[string]any{
+		"event_id":         evtID,
+		"room_id":          unreadTestRoomID,
+		"sender":           sender,
+		"type":             evtType.Type,
+		"origin_server_ts": 1700000000000,
+		"content":          content,
+	}
+}
+
+func unreadTestMessage(evtID string, sender id.UserID, mention bool) map[string]any {
+	content := map[string]any{"msgtype": "m.text", "body": evtID}
+	if mention {
+		content["m.mentions"] = map[string]any{"user_ids": []id.UserID{testUserID}}
+	}
+	return unreadTestEvent(evtID, sender, event.EventMessage, content)
+}
+
+// processTestSync processes a sync response containing only the given joined room in the same way
+// as the syncer, but without the crypto processing. It returns the payload sent to the frontend.
+func processTestSync(t *testing.T, h *HiClient, room map[string]any) *jsoncmd.SyncComplete {
+	t.Helper()
+	rawResp, err := json.Marshal(map[string]any{
+		"next_batch": "next",
+		"rooms":      map[string]any{"join": map[id.RoomID]any{unreadTestRoomID: room}},
+	})
+	if err != nil {
+		t.Fatalf("Failed to marshal sync response: %v", err)
+	}
+	var resp mautrix.RespSync
+	if err = json.Unmarshal(rawResp, &resp); err != nil {
+		t.Fatalf("Failed to parse sync response: %v", err)
+	}
+	syncCtx := &syncContext{evt: &jsoncmd.SyncComplete{Rooms: make(map[id.RoomID]*jsoncmd.SyncRoom)}}
+	ctx := context.WithValue(context.Background(), syncContextKey, syncCtx)
+	err = h.DB.DoTxn(ctx, nil, func(ctx context.Context) error {
+		return h.processSyncResponse(ctx, &resp, "")
+	})
+	if err != nil {
+		t.Fatalf("Failed to process sync: %v", err)
+	}
+	return syncCtx.evt
+}
+
+func getUnreadTestRoom(t *testing.T, h *HiClient) *database.Room {
+	t.Helper()
+	room, err := h.DB.Room.Get(context.Background(), unreadTestRoomID)
+	if err != nil {
+		t.Fatalf("Failed to get room: %v", err)
+	} else if room == nil {
+		t.Fatal("Room not found in database")
+	}
+	return room
+}
+
+func TestSync_ServerUnreadCounts(t *testing.T) {
+	h, _ := newTestClient(t)
+	setUnreadTestPushRules(t, h)
+
+	syncEvt := processTestSync(t, h, map[string]any{
+		"timeline": map[string]any{"events": []any{
+			unreadTestMessage("$own", testUserID, false),
+			unreadTestMessage("$msg1", unreadTestSender, false),
+			unreadTestMessage("$mention1", unreadTestSender, true),
+		}},
+		"unread_notifications": map[string]any{"highlight_count": 1, "notification_count": 2},
+	})
+	wantLocal := database.UnreadCounts{UnreadHighlights: 1, UnreadNotifications: 2, UnreadMessages: 2}
+	wantServer := database.ServerUnreadCounts{Highlights: 1, Notifications: 2}
+	if room := getUnreadTestRoom(t, h); room.UnreadCounts != wantLocal || room.ServerUnreadCounts != wantServer {
+		t.Errorf("Expected local counts %+v and server counts %+v, got %+v and %+v", wantLocal, wantServer, room.UnreadCounts, room.ServerUnreadCounts)
+	} else if meta := syncEvt.Rooms[unreadTestRoomID].Meta; meta.ServerUnreadCounts != wantServer {
+		t.Errorf("Expected server counts %+v in sync payload, got %+v", wantServer, meta.ServerUnreadCounts)
+	}
+
+	// A server with stale counts doesn't affect the locally calculated counts
+	syncEvt = processTestSync(t, h, map[string]any{
+		"timeline":             map[string]any{"events": []any{unreadTestMessage("$msg2", unreadTestSender, false)}},
+		"unread_notifications": map[string]any{"highlight_count": 0, "notification_count": 7},
+	})
+	wantLocal = database.UnreadCounts{UnreadHighlights: 1, UnreadNotifications: 3, UnreadMessages: 3}
+	wantServer = database.ServerUnreadCounts{Highlights: 0, Notifications: 7}
+	if room := getUnreadTestRoom(t, h); room.UnreadCounts != wantLocal || room.ServerUnreadCounts != wantServer {
+		t.Errorf("Expected local counts %+v and server counts %+v, got %+v and %+v", wantLocal, wantServer, room.UnreadCounts, room.ServerUnreadCounts)
+	} else if meta := syncEvt.Rooms[unreadTestRoomID].Meta; meta.ServerUnreadCounts != wantServer {
+		t.Errorf("Expected server counts %+v in sync payload, got %+v", wantServer, meta.ServerUnreadCounts)
+	}
+
+	// Syncs without counts keep the previous server counts
+	processTestSync(t, h, map[string]any{
+		"timeline": map[string]any{"events": []any{unreadTestMessage("$msg3", unreadTestSender, false)}},
+	})
+	wantLocal = database.UnreadCounts{UnreadHighlights: 1, UnreadNotifications: 4, UnreadMessages: 4}
+	if room := getUnreadTestRoom(t, h); room.UnreadCounts != wantLocal || room.ServerUnreadCounts != wantServer {
+		t.Errorf("Expected local counts %+v and server counts %+v, got %+v and %+v", wantLocal, wantServer, room.UnreadCounts, room.ServerUnreadCounts)
+	}
+	if got := wantServer.AsUnreadCounts(); got != (database.UnreadCounts{UnreadNotifications: 7, UnreadMessages: 7}) {
+		t.Errorf("Unexpected server counts in local format: %+v", got)
+	}
+}
+
+func TestSync_GapMissedCount(t *testing.T) {
+	tests := []struct {
+		name         string
+		events       []any
+		serverCounts map[string]any
+		wantGap      bool
+		wantMissed   int
+	}{
+		{
+			"missed messages",
+			[]any{unreadTestMessage("$new", unreadTestSender, false)},
+			map[string]any{"highlight_count": 0, "notification_count": 5},
+			true, 3,
+		},
+		{
+			"server count lower than local count",
+			[]any{unreadTestMessage("$new", unreadTestSender, false)},
+			map[string]any{"highlight_count": 0, "notification_count": 1},
+			true, 0,
+		},
+		{"no server counts", []any{unreadTestMessage("$new", unreadTestSender, false)}, nil, true, 0},
+		{
+			"connected to old timeline",
+			[]any{unreadTestMessage("$old", unreadTestSender, false), unreadTestMessage("$new", unreadTestSender, false)},
+			map[string]any{"highlight_count": 0, "notification_count": 5},
+			false, 0,
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			h, _ := newTestClient(t)
+			setUnreadTestPushRules(t, h)
+			processTestSync(t, h, map[string]any{
+				"timeline": map[string]any{"events": []any{
+					unreadTestMessage("$own", testUserID, false),
+					unreadTestMessage("$old", unreadTestSender, false),
+				}, "prev_batch": "initial"},
+			})
+			room := map[string]any{
+				"timeline": map[string]any{"events": test.events, "limited": true, "prev_batch": "gap"},
+			}
+			if test.serverCounts != nil {
+				room["unread_notifications"] = test.serverCounts
+			}
+			syncEvt := processTestSync(t, h, room)
+			gaps, err := h.DB.Timeline.GetGaps(context.Background(), unreadTestRoomID, 0, 1<<62)
+			if err != nil {
+				t.Fatalf("Failed to get gaps: %v", err)
+			}
+			if !test.wantGap {
+				if len(gaps) != 0 || len(syncEvt.Rooms[unreadTestRoomID].Gaps) != 0 {
+					t.Errorf("Expected no gaps, got %+v", gaps)
+				}
+				return
+			} else if len(gaps) != 1 {
+				t.Fatalf("Expected one gap, got %d", len(gaps))
+			} else if gaps[0].PrevBatch != "gap" || gaps[0].MissedCount != test.wantMissed {
+				t.Errorf("Expected gap with missed count %d, got %+v", test.wantMissed, gaps[0])
+			}
+			if syncGaps := syncEvt.Rooms[unreadTestRoomID].Gaps; len(syncGaps) != 1 || syncGaps[0].MissedCount != test.wantMissed {
+				t.Errorf("Expected the gap to be included in the sync payload, got %+v", syncGaps)
+			}
+		})
+	}
+}
+
+func TestHandleReceivedMegolmSession_RecalculatesUnreads(t *testing.T) {
+	ctx := context.Background()
+	h := newSessionTestClient(t, &fakeSessionServer{})
+	events := &testEvents{}
+	h.EventHandler = events.handle
+	setUnreadTestPushRules(t, h)
+
+	outbound, err := crypto.NewOutboundGroupSession(unreadTestRoomID, nil)
+	if err != nil {
+		t.Fatalf("Failed to create outbound session: %v", err)
+	}
+	outbound.Shared = true
+	if err = h.CryptoStore.AddOutboundGroupSession(ctx, outbound); err != nil {
+		t.Fatalf("Failed to save outbound session: %v", err)
+	}
+	encrypted, err := h.Crypto.EncryptMegolmEvent(ctx, unreadTestRoomID, event.EventMessage, &event.MessageEventContent{
+		MsgType:  event.MsgText,
+		Body:     "hi alice",
+		Mentions: &event.Mentions{UserIDs: []id.UserID{testUserID}},
+	})
+	if err != nil {
+		t.Fatalf("Failed to encrypt event: %v", err)
+	}
+
+	processTestSync(t, h, map[string]any{
+		"timeline": map[string]any{"events": []any{
+			unreadTestMessage("$own", testUserID, false),
+			unreadTestMessage("$msg", unreadTestSender, false),
+			unreadTestEvent("$encrypted", unreadTestSender, event.EventEncrypted, encrypted),
+		}},
+	})
+	// The encrypted event can't be counted before it's decrypted
+	wantBefore := database.UnreadCounts{UnreadNotifications: 1, UnreadMessages: 1}
+	if room := getUnreadTestRoom(t, h); room.UnreadCounts != wantBefore {
+		t.Fatalf("Expected counts %+v before decryption, got %+v", wantBefore, room.UnreadCounts)
+	}
+
+	own := h.Crypto.OwnIdentity()
+	inbound, err := crypto.NewInboundGroupSession(own.IdentityKey, own.SigningKey, unreadTestRoomID, outbound.Internal.Key(), 0, 0, false)
+	if err != nil {
+		t.Fatalf("Failed to create inbound session: %v", err)
+	} else if err = h.CryptoStore.PutGroupSession(ctx, inbound); err != nil {
+		t.Fatalf("Failed to save inbound session: %v", err)
+	}
+	h.handleReceivedMegolmSession(ctx, unreadTestRoomID, inbound.ID(), 0)
+
+	wantAfter := database.UnreadCounts{UnreadHighlights: 1, UnreadNotifications: 2, UnreadMessages: 2}
+	if room := getUnreadTestRoom(t, h); room.UnreadCounts != wantAfter {
+		t.Errorf("Expected counts %+v after decryption, got %+v", wantAfter, room.UnreadCounts)
+	}
+	var decrypted bool
+	var syncMeta *database.Room
+	for _, evt := range events.all() {
+		switch typedEvt := evt.(type) {
+		case *jsoncmd.EventsDecrypted:
+			decrypted = len(typedEvt.Events) == 1 && typedEvt.Events[0].ID == "$encrypted"
+		case *jsoncmd.SyncComplete:
+			if syncRoom, ok := typedEvt.Rooms[unreadTestRoomID]; ok {
+				syncMeta = syncRoom.Meta
+			}
+		}
+	}
+	if !decrypted {
+		t.Error("Decrypted event wasn't dispatched")
+	}
+	if syncMeta == nil {
+		t.Fatal("Recalculated room meta wasn't dispatched")
+	} else if syncMeta.UnreadCounts != wantAfter {
+		t.Errorf("Expected counts %+v in dispatched room meta, got %+v", wantAfter, syncMeta.UnreadCounts)
+	}
+}
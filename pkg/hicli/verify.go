@@ -159,6 +159,13 @@ func (h *HiClient) Verify(ctx context.Context, code string) error {
 	if err != nil {
 		return fmt.Errorf("failed to fetch key backup key: %w", err)
 	}
+	// Sessions that were missing before verifying may be in the backup that can now be decrypted
+	err = h.DB.SessionRequest.ResetBackupChecked(ctx)
+	if err != nil {
+		zerolog.Ctx(ctx).Err(err).Msg("Failed to reset key backup status of queued session requests")
+	} else {
+		h.WakeupRequestQueue()
+	}
 	h.Verified = true
 	if !h.IsSyncing() {
 		go h.Sync()
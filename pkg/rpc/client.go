@@ -8,11 +8,15 @@ package rpc
 
 import (
 	"context"
+	"crypto/tls"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"io"
 	"net/http"
 	"net/http/cookiejar"
 	"net/url"
+	"reflect"
 	"sync"
 	"sync/atomic"
 	"time"
@@ -20,6 +24,7 @@ import (
 	"github.com/coder/websocket"
 	"golang.org/x/net/publicsuffix"
 	"maunium.net/go/mautrix"
+	"maunium.net/go/mautrix/event"
 	"maunium.net/go/mautrix/id"
 
 	"go.mau.fi/gomuks/pkg/hicli/jsoncmd"
@@ -27,39 +32,74 @@ import (
 
 type EventHandler = func(ctx context.Context, event any)
 
+// GomuksRPC is a client for the websocket and HTTP APIs of a gomuks backend. It only handles the connection and
+// requests, see the client package for a version that also keeps track of rooms and events.
 type GomuksRPC struct {
+	// EventHandler is called for every event received from the backend. Handlers for specific
+	// event types can also be registered with OnEvent.
 	EventHandler EventHandler
 	UserAgent    string
 
+	// MinReconnectBackoff and MaxReconnectBackoff control the delay between reconnection attempts.
+	MinReconnectBackoff time.Duration
+	MaxReconnectBackoff time.Duration
+	// MaxReconnectAttempts is the number of reconnection attempts before giving up. Zero means unlimited.
+	MaxReconnectAttempts int
+
 	BaseURL *url.URL
 	http    *http.Client
 	conn    atomic.Pointer[websocket.Conn]
 	connCtx atomic.Pointer[context.Context]
 	stop    atomic.Pointer[context.CancelFunc]
+	// The ID of the last event received, which is read by the reconnect and ping loops.
+	lastReqID atomic.Int64
 
 	pendingRequestsLock sync.RWMutex
 	reqIDCounter        int64
-	lastReqID           int64
 	runID               string
 	pendingRequests     map[int64]chan<- *jsoncmd.Container[json.RawMessage]
+
+	handlersLock sync.RWMutex
+	handlers     map[reflect.Type][]*typedHandler
 }
 
-func NewGomuksRPC(rawBaseURL string) (*GomuksRPC, error) {
+// ConnectionOptions contains extra options for the HTTP and websocket connections to the backend.
+type ConnectionOptions struct {
+	// InsecureSkipVerify disables TLS certificate verification.
+	InsecureSkipVerify bool
+}
+
+func newHTTPClient(opts ConnectionOptions) (*http.Client, error) {
 	jar, err := cookiejar.New(&cookiejar.Options{PublicSuffixList: publicsuffix.List})
 	if err != nil {
 		return nil, fmt.Errorf("failed to create cookie jar: %w", err)
 	}
+	transport := &http.Transport{
+		TLSHandshakeTimeout:   20 * time.Second,
+		ResponseHeaderTimeout: 120 * time.Second,
+	}
+	if opts.InsecureSkipVerify {
+		transport.TLSClientConfig = &tls.Config{InsecureSkipVerify: true}
+	}
+	return &http.Client{
+		Transport: transport,
+		Jar:       jar,
+		Timeout:   180 * time.Second,
+	}, nil
+}
+
+func NewGomuksRPC(rawBaseURL string) (*GomuksRPC, error) {
+	return NewGomuksRPCWithOptions(rawBaseURL, ConnectionOptions{})
+}
+
+func NewGomuksRPCWithOptions(rawBaseURL string, opts ConnectionOptions) (*GomuksRPC, error) {
 	baseURL, err := url.Parse(rawBaseURL)
 	if err != nil {
 		return nil, fmt.Errorf("failed to parse base URL: %w", err)
 	}
-	cli := &http.Client{
-		Transport: &http.Transport{
-			TLSHandshakeTimeout:   20 * time.Second,
-			ResponseHeaderTimeout: 120 * time.Second,
-		},
-		Jar:     jar,
-		Timeout: 180 * time.Second,
+	cli, err := newHTTPClient(opts)
+	if err != nil {
+		return nil, err
 	}
 	return &GomuksRPC{
 		EventHandler:    func(_ context.Context, _ any) {},
@@ -70,6 +110,26 @@ func NewGomuksRPC(rawBaseURL string) (*GomuksRPC, error) {
 	}, nil
 }
 
+// Reconfigure disconnects from the current backend and switches to the given URL.
+//
+// The HTTP client and its cookies are replaced, so Authenticate must be called again before reconnecting.
+func (gr *GomuksRPC) Reconfigure(rawBaseURL string, opts ConnectionOptions) error {
+	baseURL, err := url.Parse(rawBaseURL)
+	if err != nil {
+		return fmt.Errorf("failed to parse base URL: %w", err)
+	}
+	cli, err := newHTTPClient(opts)
+	if err != nil {
+		return err
+	}
+	gr.Disconnect()
+	gr.BaseURL = baseURL
+	gr.http = cli
+	gr.runID = ""
+	gr.lastReqID.Store(0)
+	return nil
+}
+
 type GomuksURLPath []any
 
 func (gup GomuksURLPath) FullPath() []any {
@@ -115,8 +175,14 @@ type DownloadMediaParams struct {
 	FallbackCharacter string
 	AvatarThumbnail   bool
 	Encrypted         bool
+	// The token to send in the image_auth query parameter, if any.
+	ImageAuth string
 }
 
+// ErrMediaUnauthorized is returned by DownloadMedia if the backend responds with HTTP 401,
+// which usually means the image auth token has expired.
+var ErrMediaUnauthorized = errors.New("unauthorized")
+
 func (gr *GomuksRPC) DownloadMedia(ctx context.Context, params DownloadMediaParams) (*http.Response, error) {
 	query := url.Values{}
 	if params.FallbackColor != "" && params.FallbackCharacter != "" {
@@ -128,6 +194,9 @@ func (gr *GomuksRPC) DownloadMedia(ctx context.Context, params DownloadMediaPara
 	if params.Encrypted {
 		query.Set("encrypted", "true")
 	}
+	if params.ImageAuth != "" {
+		query.Set("image_auth", params.ImageAuth)
+	}
 	url := gr.BuildURLWithQuery(GomuksURLPath{"media", params.MXC.Homeserver, params.MXC.FileID}, query)
 	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
 	if err != nil {
@@ -136,9 +205,64 @@ func (gr *GomuksRPC) DownloadMedia(ctx context.Context, params DownloadMediaPara
 	resp, err := gr.http.Do(req)
 	if err != nil {
 		return nil, fmt.Errorf("failed to download media: %w", err)
+	} else if resp.StatusCode == http.StatusUnauthorized {
+		_ = resp.Body.Close()
+		return nil, fmt.Errorf("failed to download media: %w", ErrMediaUnauthorized)
 	} else if resp.StatusCode >= 300 {
 		_ = resp.Body.Close()
 		return nil, fmt.Errorf("failed to download media: HTTP %d", resp.StatusCode)
 	}
 	return resp, err
 }
+
+// ErrImageHasLocation is returned by UploadMedia if StripEXIF is set to ask and the image contains location data.
+// The upload should be retried with StripEXIF set to always or never depending on what the user chooses.
+var ErrImageHasLocation = mautrix.RespError{ErrCode: "FI.MAU.GOMUKS.IMAGE_HAS_LOCATION"}
+
+// ErrMessageTooLarge is returned by SendMessage if the message is too large for a single event and can't be split
+// automatically. The text can be sent as a file instead, or as multiple events with SplitCodeBlocks set.
+var ErrMessageTooLarge = mautrix.RespError{ErrCode: "FI.MAU.GOMUKS.MESSAGE_TOO_LARGE"}
+
+type UploadMediaParams struct {
+	FileName string
+	Encrypt  bool
+	// StripEXIF is always, ask or never. If empty, the image is uploaded as-is.
+	StripEXIF string
+}
+
+func (gr *GomuksRPC) UploadMedia(ctx context.Context, body io.Reader, params UploadMediaParams) (*event.MessageEventContent, error) {
+	query := url.Values{}
+	if params.FileName != "" {
+		query.Set("filename", params.FileName)
+	}
+	if params.Encrypt {
+		query.Set("encrypt", "true")
+	}
+	if params.StripEXIF != "" {
+		query.Set("strip_exif", params.StripEXIF)
+	}
+	addr := gr.BuildURLWithQuery(GomuksURLPath{"upload"}, query)
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, addr, body)
+	if err != nil {
+		return nil, fmt.Errorf("failed to prepare request: %w", err)
+	}
+	req.Header.Set("User-Agent", gr.UserAgent)
+	resp, err := gr.http.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to upload media: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode >= 300 {
+		var respErr mautrix.RespError
+		if json.NewDecoder(resp.Body).Decode(&respErr) == nil && respErr.Err != "" {
+			return nil, fmt.Errorf("failed to upload media: %w", respErr)
+		}
+		return nil, fmt.Errorf("failed to upload media: HTTP %d", resp.StatusCode)
+	}
+	var content event.MessageEventContent
+	err = json.NewDecoder(resp.Body).Decode(&content)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse upload response: %w", err)
+	}
+	return &content, nil
+}
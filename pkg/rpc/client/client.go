@@ -9,12 +9,11 @@ package client
 import (
 	"context"
 	"fmt"
-	"io"
-	"net/url"
 	"slices"
-	"strconv"
 	"sync"
+	"time"
 
+	badGlobalLog "github.com/rs/zerolog/log"
 	"go.mau.fi/util/exsync"
 	"maunium.net/go/mautrix/id"
 
@@ -24,21 +23,39 @@ import (
 	"go.mau.fi/gomuks/pkg/rpc/store"
 )
 
+// GomuksClient is a gomuks RPC client that keeps the rooms and events received from the backend in a store.
 type GomuksClient struct {
 	*rpc.GomuksRPC
 	*store.GomuksStore
 
+	// InitComplete is set once the backend has sent the initial sync after connecting.
 	InitComplete *exsync.Event
+	// EventHandler is called for every event after it has been applied to the store.
+	// Handlers for specific event types can also be registered with rpc.OnEvent.
 	EventHandler rpc.EventHandler
 
+	// SendNotification is called for new messages that should trigger a notification. It's optional.
 	SendNotification func(room *store.RoomStore, notif jsoncmd.SyncNotification)
 
 	stateRequestQueue     []database.RoomStateGUID
 	stateRequestQueueLock sync.Mutex
+
+	eventRequestQueue     []database.EventRowID
+	eventRequestTimer     *time.Timer
+	eventRequestQueueLock sync.Mutex
+
+	imageAuthLock     sync.Mutex
+	imageAuthReceived *exsync.Event
+	imageAuthRefresh  *imageAuthRefresh
+	// onImageAuthRefreshJoin is called with imageAuthLock held when a caller starts waiting for a refresh.
+	onImageAuthRefreshJoin func()
 }
 
-func NewGomuksClient(baseURL string) (*GomuksClient, error) {
-	rpcClient, err := rpc.NewGomuksRPC(baseURL)
+// eventRequestDelay is how long QueueEventRequest waits for more requests before sending a batch.
+const eventRequestDelay = 50 * time.Millisecond
+
+func NewGomuksClient(baseURL string, opts rpc.ConnectionOptions) (*GomuksClient, error) {
+	rpcClient, err := rpc.NewGomuksRPCWithOptions(baseURL, opts)
 	if err != nil {
 		return nil, err
 	}
@@ -46,11 +63,40 @@ func NewGomuksClient(baseURL string) (*GomuksClient, error) {
 		GomuksRPC:    rpcClient,
 		GomuksStore:  store.NewStore(),
 		InitComplete: exsync.NewEvent(),
+
+		imageAuthReceived: exsync.NewEvent(),
 	}
 	rpcClient.EventHandler = gc.handleEvent
+	gc.GomuksStore.RequestEventByRowID = gc.QueueEventRequest
 	return gc, nil
 }
 
+// Reconfigure tears down the current connection, clears the store and connects to the given backend.
+//
+// The normal init and initial sync flow is re-run after reconnecting, so the caller will receive
+// the usual client state, sync status and init complete events.
+func (gc *GomuksClient) Reconfigure(ctx context.Context, baseURL, username, password string, opts rpc.ConnectionOptions) error {
+	err := gc.GomuksRPC.Reconfigure(baseURL, opts)
+	if err != nil {
+		return err
+	}
+	gc.InitComplete.Clear()
+	gc.stateRequestQueueLock.Lock()
+	gc.stateRequestQueue = nil
+	gc.stateRequestQueueLock.Unlock()
+	gc.eventRequestQueueLock.Lock()
+	gc.eventRequestQueue = nil
+	gc.eventRequestQueueLock.Unlock()
+	gc.GomuksStore.Clear()
+	gc.GomuksStore.ClientState = jsoncmd.ClientState{}
+	gc.setImageAuthToken("")
+	err = gc.Authenticate(ctx, username, password)
+	if err != nil {
+		return err
+	}
+	return gc.Connect(ctx)
+}
+
 func (gc *GomuksClient) handleEvent(ctx context.Context, rawEvt any) {
 	switch evt := rawEvt.(type) {
 	case *jsoncmd.ClientState:
@@ -59,13 +105,24 @@ func (gc *GomuksClient) handleEvent(ctx context.Context, rawEvt any) {
 		// TODO
 	case *jsoncmd.InitComplete:
 		gc.InitComplete.Set()
+	case *rpc.ConnectionStatus:
+		if evt.State == rpc.ConnectionStateConnected && !evt.Resumed {
+			// The backend will send the initial sync again
+			gc.InitComplete.Clear()
+		}
 	case *jsoncmd.SyncComplete:
+		if evt.ClearState {
+			gc.GomuksStore.Clear()
+		}
 		gc.GomuksStore.ApplySync(evt)
 		for _, room := range evt.Rooms {
-			if len(room.Notifications) == 0 {
+			if len(room.Notifications) == 0 || gc.SendNotification == nil {
 				continue
 			}
 			roomStore := gc.GomuksStore.GetRoom(room.Meta.ID)
+			if roomStore == nil || roomStore.Hidden {
+				continue
+			}
 			for _, notif := range room.Notifications {
 				notif.Room = roomStore.Meta.Current()
 				notif.Event = roomStore.GetEventByRowID(notif.RowID)
@@ -80,9 +137,13 @@ func (gc *GomuksClient) handleEvent(ctx context.Context, rawEvt any) {
 	case *jsoncmd.SendComplete:
 		callRoomMethod(gc, evt.Event.RoomID, (*store.RoomStore).ApplySendComplete, evt.Event)
 	case *jsoncmd.ImageAuthToken:
-		gc.GomuksStore.ImageAuthToken = string(*evt)
+		gc.setImageAuthToken(string(*evt))
 	case *jsoncmd.Typing:
 		callRoomMethod(gc, evt.RoomID, (*store.RoomStore).ApplyTyping, evt.UserIDs)
+	case *jsoncmd.SendCooldown:
+		callRoomMethod(gc, evt.RoomID, (*store.RoomStore).ApplySendCooldown, evt)
+	case *jsoncmd.SendQueueUpdate:
+		callRoomMethod(gc, evt.RoomID, (*store.RoomStore).ApplySendQueueUpdate, evt)
 	}
 	if gc.EventHandler != nil {
 		gc.EventHandler(ctx, rawEvt)
@@ -102,17 +163,23 @@ func (gc *GomuksClient) RequestEvent(ctx context.Context, room *store.RoomStore,
 }
 
 func (gc *GomuksClient) SendMessage(ctx context.Context, params *jsoncmd.SendMessageParams) error {
-	room := gc.GomuksStore.GetRoom(params.RoomID)
-	if room == nil {
+	if gc.GomuksStore.GetRoom(params.RoomID) == nil {
 		return fmt.Errorf("room not found in store")
 	}
-	dbEvt, err := gc.GomuksRPC.SendMessage(ctx, params)
+	_, err := gc.sendMessage(ctx, params)
+	return err
+}
+
+// ResendEvent retries sending a failed event and marks it as pending in the room store.
+func (gc *GomuksClient) ResendEvent(ctx context.Context, params *jsoncmd.ResendEventParams) (*database.Event, error) {
+	dbEvt, err := gc.GomuksRPC.ResendEvent(ctx, params)
 	if err != nil {
-		return err
-	} else if dbEvt != nil {
-		room.ApplyPending(dbEvt)
+		return nil, err
 	}
-	return nil
+	if room := gc.GomuksStore.GetRoom(dbEvt.RoomID); room != nil {
+		room.ApplySendComplete(dbEvt)
+	}
+	return dbEvt, nil
 }
 
 func (gc *GomuksClient) QueueRoomStateRequest(key database.RoomStateGUID) {
@@ -132,6 +199,49 @@ func (gc *GomuksClient) FlushRoomStateRequests(ctx context.Context) error {
 	return gc.LoadSpecificRoomState(ctx, keys)
 }
 
+// QueueEventRequest queues an event to be fetched from the backend by row ID. Requests are collected
+// for a short while and then sent as a single batch, as the room list may ask for many events at once.
+func (gc *GomuksClient) QueueEventRequest(rowID database.EventRowID) {
+	gc.eventRequestQueueLock.Lock()
+	defer gc.eventRequestQueueLock.Unlock()
+	gc.eventRequestQueue = append(gc.eventRequestQueue, rowID)
+	if gc.eventRequestTimer == nil {
+		gc.eventRequestTimer = time.AfterFunc(eventRequestDelay, gc.flushEventRequests)
+	} else {
+		gc.eventRequestTimer.Reset(eventRequestDelay)
+	}
+}
+
+func (gc *GomuksClient) flushEventRequests() {
+	err := gc.FlushEventRequests(context.TODO())
+	if err != nil {
+		badGlobalLog.Err(err).Msg("Failed to fetch queued events")
+	}
+}
+
+// FlushEventRequests fetches all events queued with QueueEventRequest and applies them to the store.
+func (gc *GomuksClient) FlushEventRequests(ctx context.Context) error {
+	gc.eventRequestQueueLock.Lock()
+	rowIDs := gc.eventRequestQueue
+	gc.eventRequestQueue = nil
+	gc.eventRequestQueueLock.Unlock()
+	if len(rowIDs) == 0 {
+		return nil
+	}
+	resp, err := gc.GomuksRPC.GetEventsByRowIDs(ctx, &jsoncmd.GetEventsByRowIDsParams{RowIDs: rowIDs})
+	if err != nil {
+		return err
+	}
+	for _, evt := range resp {
+		callRoomMethod(gc, evt.RoomID, (*store.RoomStore).ApplyFetchedEvent, evt)
+	}
+	if len(resp) > 0 {
+		// Re-emit the room list so that the fetched previews are rendered
+		gc.GomuksStore.ReversedRoomList.Emit(gc.GomuksStore.ReversedRoomList.Current())
+	}
+	return nil
+}
+
 func (gc *GomuksClient) LoadSpecificRoomState(ctx context.Context, keys []database.RoomStateGUID) error {
 	keys = slices.DeleteFunc(keys, func(guid database.RoomStateGUID) bool {
 		room := gc.GomuksStore.GetRoom(guid.RoomID)
@@ -182,6 +292,9 @@ func (gc *GomuksClient) LoadMoreHistory(ctx context.Context, roomID id.RoomID) e
 	room := gc.GomuksStore.GetRoom(roomID)
 	if room == nil {
 		return fmt.Errorf("room not found in store")
+	} else if !room.HasMoreHistory() {
+		// Already at the beginning of the room, there's nothing more to load
+		return nil
 	} else if !room.Paginating.CompareAndSwap(false, true) {
 		return fmt.Errorf("already paginating room")
 	}
@@ -200,24 +313,40 @@ func (gc *GomuksClient) LoadMoreHistory(ctx context.Context, roomID id.RoomID) e
 	return nil
 }
 
-func (gc *GomuksClient) GetDownloadURL(mxc id.ContentURI, encrypted, preauthed bool) string {
-	query := url.Values{
-		"encrypted": {strconv.FormatBool(encrypted)},
+// LoadLineageHistory loads more messages from the predecessors of a room into the given composed timeline.
+func (gc *GomuksClient) LoadLineageHistory(ctx context.Context, lineage *store.LineageTimeline) error {
+	if !lineage.Paginating.CompareAndSwap(false, true) {
+		return fmt.Errorf("already paginating predecessors")
 	}
-	if preauthed {
-		query.Set("image_auth", gc.GomuksStore.ImageAuthToken)
+	defer lineage.Paginating.Store(false)
+	params := lineage.GetPaginationParams(50)
+	if params == nil {
+		return nil
 	}
-	return gc.BuildURLWithQuery(rpc.GomuksURLPath{"media", mxc.Homeserver, mxc.FileID}, query)
+	resp, err := gc.GomuksRPC.PaginateLineage(ctx, params)
+	if err != nil {
+		return err
+	}
+	lineage.ApplyPagination(resp)
+	return nil
 }
 
-func (gc *GomuksClient) Download(mxc id.ContentURI, encrypted bool) ([]byte, error) {
-	resp, err := gc.GomuksRPC.DownloadMedia(context.TODO(), rpc.DownloadMediaParams{
-		MXC:       mxc,
-		Encrypted: encrypted,
+func (gc *GomuksClient) FillGap(ctx context.Context, roomID id.RoomID, gapRowID database.TimelineRowID) error {
+	room := gc.GomuksStore.GetRoom(roomID)
+	if room == nil {
+		return fmt.Errorf("room not found in store")
+	} else if !room.Paginating.CompareAndSwap(false, true) {
+		return fmt.Errorf("already paginating room")
+	}
+	defer room.Paginating.Store(false)
+	resp, err := gc.GomuksRPC.FillGap(ctx, &jsoncmd.FillGapParams{
+		RoomID:        room.ID,
+		TimelineRowID: gapRowID,
+		Limit:         100,
 	})
 	if err != nil {
-		return nil, err
+		return err
 	}
-	defer resp.Body.Close()
-	return io.ReadAll(resp.Body)
+	room.ApplyGapFill(gapRowID, resp)
+	return nil
 }
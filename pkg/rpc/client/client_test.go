@@ -0,0 +1,192 @@
+// Copyright (c) 2026 Tulir Asokan
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package client
+
+import (
+	"context"
+	"encoding/json"
+	"slices"
+	"sync"
+	"testing"
+	"time"
+
+	"maunium.net/go/mautrix/event"
+	"maunium.net/go/mautrix/id"
+
+	"go.mau.fi/gomuks/pkg/hicli/database"
+	"go.mau.fi/gomuks/pkg/hicli/jsoncmd"
+	"go.mau.fi/gomuks/pkg/rpc"
+	"go.mau.fi/gomuks/pkg/rpc/rpctest"
+)
+
+const testRoomID id.RoomID = "!room:example.com"
+
+func newTestClient(t *testing.T, fb *rpctest.Backend) *GomuksClient {
+	t.Helper()
+	gc, err := NewGomuksClient(fb.URL, rpc.ConnectionOptions{})
+	if err != nil {
+		t.Fatalf("Failed to create client: %v", err)
+	}
+	gc.MinReconnectBackoff = time.Millisecond
+	gc.MaxReconnectBackoff = 10 * time.Millisecond
+	t.Cleanup(gc.Disconnect)
+	return gc
+}
+
+func TestHandleEvent_Dispatch(t *testing.T) {
+	ctx := context.Background()
+	fb := rpctest.NewBackend(t, "run1")
+	gc := newTestClient(t, fb)
+
+	var lock sync.Mutex
+	var calls []string
+	record := func(call string) {
+		lock.Lock()
+		calls = append(calls, call)
+		lock.Unlock()
+	}
+	gc.EventHandler = func(_ context.Context, evt any) {
+		if _, ok := evt.(*jsoncmd.Typing); ok {
+			record("EventHandler")
+		}
+	}
+	rpc.OnEvent(gc.GomuksRPC, func(context.Context, *jsoncmd.Typing) {
+		record("panicking handler")
+		panic("meow")
+	})
+	removed := rpc.OnEvent(gc.GomuksRPC, func(context.Context, *jsoncmd.Typing) {
+		record("removed handler")
+	})
+	removed()
+	typing := make(chan []id.UserID, 1)
+	rpc.OnEvent(gc.GomuksRPC, func(_ context.Context, evt *jsoncmd.Typing) {
+		record("typed handler")
+		// Typed handlers run after the event has been applied to the store
+		typing <- gc.GetRoom(evt.RoomID).Typing.Current()
+	})
+	unknown := make(chan jsoncmd.Name, 1)
+	rpc.OnEvent(gc.GomuksRPC, func(_ context.Context, evt *jsoncmd.Container[json.RawMessage]) {
+		unknown <- evt.Command
+	})
+	connected := make(chan struct{}, 1)
+	gc.OnConnected(func(context.Context) {
+		connected <- struct{}{}
+	})
+
+	if err := gc.Connect(ctx); err != nil {
+		t.Fatalf("Failed to connect: %v", err)
+	}
+	conn := rpctest.WaitFor(t, fb.Connected, "connection")
+	rpctest.WaitFor(t, connected, "OnConnected")
+	for i, evt := range []struct {
+		command jsoncmd.Name
+		data    any
+	}{
+		{jsoncmd.EventClientState, &jsoncmd.ClientState{IsLoggedIn: true, UserID: "@alice:example.com"}},
+		{jsoncmd.EventSyncComplete, &jsoncmd.SyncComplete{
+			ClearState: true,
+			Rooms:      map[id.RoomID]*jsoncmd.SyncRoom{testRoomID: {Meta: &database.Room{ID: testRoomID}}},
+		}},
+		{jsoncmd.EventInitComplete, nil},
+		{jsoncmd.EventTyping, &jsoncmd.Typing{RoomID: testRoomID, TypingEventContent: event.TypingEventContent{
+			UserIDs: []id.UserID{"@bob:example.com"},
+		}}},
+		{"meow_unknown_event", map[string]any{"meow": true}},
+	} {
+		if err := conn.Send(ctx, evt.command, -int64(i+1), evt.data); err != nil {
+			t.Fatalf("Failed to send %s: %v", evt.command, err)
+		}
+	}
+
+	if userIDs := rpctest.WaitFor(t, typing, "typing event"); !slices.Equal(userIDs, []id.UserID{"@bob:example.com"}) {
+		t.Errorf("Room store has typing users %v when the typed handler is called", userIDs)
+	}
+	if command := rpctest.WaitFor(t, unknown, "unknown event"); command != "meow_unknown_event" {
+		t.Errorf("Unknown event handler got %s", command)
+	}
+	if !gc.InitComplete.IsSet() {
+		t.Error("InitComplete isn't set after init_complete event")
+	} else if !gc.ClientState.IsLoggedIn {
+		t.Error("Client state wasn't applied")
+	}
+	lock.Lock()
+	defer lock.Unlock()
+	// EventHandler comes first, then the typed handlers in registration order, even if one of them panics
+	if wantCalls := []string{"EventHandler", "panicking handler", "typed handler"}; !slices.Equal(calls, wantCalls) {
+		t.Errorf("Handlers were called in order %v, want %v", calls, wantCalls)
+	}
+}
+
+func TestConnectionCallbacks(t *testing.T) {
+	tests := []struct {
+		name             string
+		newRunID         string
+		wantResumed      bool
+		wantInitComplete bool
+	}{
+		{"same backend run", "run1", true, true},
+		{"backend restarted", "run2", false, false},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			ctx := context.Background()
+			fb := rpctest.NewBackend(t, "run1")
+			gc := newTestClient(t, fb)
+			connected := make(chan struct{}, 4)
+			resumed := make(chan struct{}, 4)
+			disconnected := make(chan error, 4)
+			gc.OnConnected(func(context.Context) {
+				connected <- struct{}{}
+			})
+			gc.OnResumed(func(context.Context) {
+				resumed <- struct{}{}
+			})
+			gc.OnDisconnected(func(_ context.Context, err error) {
+				disconnected <- err
+			})
+			initComplete := make(chan struct{}, 4)
+			rpc.OnEvent(gc.GomuksRPC, func(context.Context, *jsoncmd.InitComplete) {
+				initComplete <- struct{}{}
+			})
+
+			if err := gc.Connect(ctx); err != nil {
+				t.Fatalf("Failed to connect: %v", err)
+			}
+			conn := rpctest.WaitFor(t, fb.Connected, "first connection")
+			rpctest.WaitFor(t, connected, "OnConnected")
+			if err := conn.Send(ctx, jsoncmd.EventInitComplete, -1, nil); err != nil {
+				t.Fatalf("Failed to send init_complete: %v", err)
+			}
+			rpctest.WaitFor(t, initComplete, "init_complete")
+
+			fb.SetRunID(test.newRunID)
+			conn.Drop()
+			if err := rpctest.WaitFor(t, disconnected, "OnDisconnected"); err == nil {
+				t.Error("OnDisconnected was called without an error")
+			}
+			rpctest.WaitFor(t, fb.Connected, "second connection")
+			if test.wantResumed {
+				rpctest.WaitFor(t, resumed, "OnResumed")
+			} else {
+				rpctest.WaitFor(t, connected, "OnConnected")
+			}
+			// The store is updated before the callbacks are called, so the initial sync state must be final here
+			if gc.InitComplete.IsSet() != test.wantInitComplete {
+				t.Errorf("InitComplete is %t after reconnecting, want %t", gc.InitComplete.IsSet(), test.wantInitComplete)
+			}
+			select {
+			case <-connected:
+				t.Error("OnConnected was called for a resumed connection")
+			case <-resumed:
+				t.Error("OnResumed was called for a new event stream")
+			case <-disconnected:
+				t.Error("OnDisconnected was called twice")
+			default:
+			}
+		})
+	}
+}
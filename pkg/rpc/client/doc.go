@@ -0,0 +1,31 @@
+// Copyright (c) 2025 Tulir Asokan
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+// Package client contains a Go client for a running gomuks backend, which can be used to build bots and other
+// programs on top of gomuks without dealing with the Matrix client-server API, encryption or sync directly.
+//
+// The client is created with NewGomuksClient, authenticated with the backend using Authenticate and then connected
+// with Connect. Connecting starts the websocket event stream, which is reconnected automatically if it's lost.
+// All the commands supported by the backend are available as methods (see the rpc package), and common flows
+// like sending text, replies, reactions and files have shortcuts like SendText, Reply, React and SendFile.
+//
+// Events are applied to the store before they're passed to the handlers. Handlers for specific event types are
+// registered with rpc.OnEvent, and connection state changes with OnConnected, OnResumed and OnDisconnected:
+//
+//	cli, err := client.NewGomuksClient("http://localhost:29325", rpc.ConnectionOptions{})
+//	// handle err
+//	rpc.OnEvent(cli.GomuksRPC, func(ctx context.Context, evt *jsoncmd.SyncComplete) {
+//		// handle new events
+//	})
+//	cli.OnDisconnected(func(ctx context.Context, err error) {
+//		// the client will reconnect automatically
+//	})
+//	err = cli.Authenticate(ctx, username, password)
+//	// handle err
+//	err = cli.Connect(ctx)
+//
+// See examples/echobot for a complete program.
+package client
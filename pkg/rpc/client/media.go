@@ -0,0 +1,146 @@
+// Copyright (c) 2025 Tulir Asokan
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package client
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/url"
+	"strconv"
+	"time"
+
+	"maunium.net/go/mautrix/id"
+
+	"go.mau.fi/gomuks/pkg/rpc"
+)
+
+const (
+	// imageAuthTokenWait is how long to wait for the first image auth token after connecting.
+	imageAuthTokenWait = 5 * time.Second
+	// imageAuthRefreshTimeout is the timeout for requesting a new image auth token from the backend.
+	imageAuthRefreshTimeout = 30 * time.Second
+)
+
+type imageAuthRefresh struct {
+	done chan struct{}
+	err  error
+}
+
+type MediaURLOptions struct {
+	Encrypted bool
+	// Request a small thumbnail suitable for avatars instead of the full file.
+	AvatarThumbnail bool
+	// Don't include the image auth token. This should be used for URLs that are saved or shown as text,
+	// as the token grants access to all media until it expires.
+	WithoutAuth bool
+}
+
+func (gc *GomuksClient) setImageAuthToken(token string) {
+	gc.imageAuthLock.Lock()
+	gc.GomuksStore.ImageAuthToken = token
+	if token != "" {
+		gc.imageAuthReceived.Set()
+	} else {
+		gc.imageAuthReceived.Clear()
+	}
+	gc.imageAuthLock.Unlock()
+}
+
+func (gc *GomuksClient) loadImageAuthToken() string {
+	gc.imageAuthLock.Lock()
+	defer gc.imageAuthLock.Unlock()
+	return gc.GomuksStore.ImageAuthToken
+}
+
+// GetImageAuthToken returns the current image auth token. If a token hasn't been received since connecting,
+// this waits a few seconds for one to arrive before returning an empty string.
+func (gc *GomuksClient) GetImageAuthToken(ctx context.Context) string {
+	_ = gc.imageAuthReceived.WaitTimeoutCtx(ctx, imageAuthTokenWait)
+	return gc.loadImageAuthToken()
+}
+
+// RefreshImageAuthToken requests a new image auth token from the backend.
+// Concurrent calls share the same request instead of each fetching a new token.
+func (gc *GomuksClient) RefreshImageAuthToken(ctx context.Context) error {
+	gc.imageAuthLock.Lock()
+	refresh := gc.imageAuthRefresh
+	if refresh == nil {
+		refresh = &imageAuthRefresh{done: make(chan struct{})}
+		gc.imageAuthRefresh = refresh
+		go gc.doRefreshImageAuthToken(refresh)
+	}
+	if gc.onImageAuthRefreshJoin != nil {
+		gc.onImageAuthRefreshJoin()
+	}
+	gc.imageAuthLock.Unlock()
+	select {
+	case <-refresh.done:
+		return refresh.err
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+func (gc *GomuksClient) doRefreshImageAuthToken(refresh *imageAuthRefresh) {
+	// The request isn't tied to the context of any single caller, as other callers may be waiting for it too.
+	ctx, cancel := context.WithTimeout(context.Background(), imageAuthRefreshTimeout)
+	defer cancel()
+	token, err := gc.GomuksRPC.GetImageAuthToken(ctx)
+	if err == nil {
+		gc.setImageAuthToken(string(token))
+	}
+	gc.imageAuthLock.Lock()
+	refresh.err = err
+	gc.imageAuthRefresh = nil
+	gc.imageAuthLock.Unlock()
+	close(refresh.done)
+}
+
+// BuildMediaURL returns the URL for downloading the given media through the backend.
+// Unless disabled in the options, the URL includes the current image auth token, so it can be
+// opened without other authentication until the token expires. This never waits for a token,
+// so the URL won't have one if it's built before the first token arrives.
+func (gc *GomuksClient) BuildMediaURL(mxc id.ContentURI, opts MediaURLOptions) string {
+	query := url.Values{
+		"encrypted": {strconv.FormatBool(opts.Encrypted)},
+	}
+	if opts.AvatarThumbnail {
+		query.Set("thumbnail", "avatar")
+	}
+	if !opts.WithoutAuth {
+		if token := gc.loadImageAuthToken(); token != "" {
+			query.Set("image_auth", token)
+		}
+	}
+	return gc.BuildURLWithQuery(rpc.GomuksURLPath{"media", mxc.Homeserver, mxc.FileID}, query)
+}
+
+// Download downloads the given media through the backend. If the image auth token has expired,
+// a new one is requested and the download is retried once.
+func (gc *GomuksClient) Download(ctx context.Context, mxc id.ContentURI, encrypted bool) ([]byte, error) {
+	params := rpc.DownloadMediaParams{
+		MXC:       mxc,
+		Encrypted: encrypted,
+		ImageAuth: gc.GetImageAuthToken(ctx),
+	}
+	resp, err := gc.GomuksRPC.DownloadMedia(ctx, params)
+	if errors.Is(err, rpc.ErrMediaUnauthorized) {
+		err = gc.RefreshImageAuthToken(ctx)
+		if err != nil {
+			return nil, fmt.Errorf("failed to refresh image auth token: %w", err)
+		}
+		params.ImageAuth = gc.loadImageAuthToken()
+		resp, err = gc.GomuksRPC.DownloadMedia(ctx, params)
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	return io.ReadAll(resp.Body)
+}
@@ -0,0 +1,298 @@
+// Copyright (c) 2026 Tulir Asokan
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package client
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/url"
+	"slices"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"maunium.net/go/mautrix/id"
+
+	"go.mau.fi/gomuks/pkg/hicli/jsoncmd"
+	"go.mau.fi/gomuks/pkg/rpc"
+	"go.mau.fi/gomuks/pkg/rpc/rpctest"
+)
+
+var testMXC = id.ContentURI{Homeserver: "example.com", FileID: "meow"}
+
+// connectMediaTestClient connects a client to the fake backend and sends it the given image auth token.
+func connectMediaTestClient(t *testing.T, fb *rpctest.Backend, token string) *GomuksClient {
+	t.Helper()
+	ctx := context.Background()
+	gc := newTestClient(t, fb)
+	received := make(chan struct{}, 1)
+	rpc.OnEvent(gc.GomuksRPC, func(context.Context, *jsoncmd.ImageAuthToken) {
+		received <- struct{}{}
+	})
+	if err := gc.Connect(ctx); err != nil {
+		t.Fatalf("Failed to connect: %v", err)
+	}
+	conn := rpctest.WaitFor(t, fb.Connected, "connection")
+	if err := conn.Send(ctx, jsoncmd.EventImageAuthToken, -1, token); err != nil {
+		t.Fatalf("Failed to send image auth token: %v", err)
+	}
+	rpctest.WaitFor(t, received, "image auth token")
+	return gc
+}
+
+// tokenRefresher answers image auth token requests with the given tokens in order.
+type tokenRefresher struct {
+	tokens   []string
+	requests atomic.Int32
+	// If set, requests wait until the channel is closed before responding.
+	release chan struct{}
+	// Receives a value for each request before waiting for release.
+	requested chan struct{}
+}
+
+func (tr *tokenRefresher) handle(cmd *jsoncmd.Container[json.RawMessage]) any {
+	if cmd.Command != jsoncmd.ReqGetImageAuthToken {
+		return nil
+	}
+	n := tr.requests.Add(1)
+	if tr.requested != nil {
+		tr.requested <- struct{}{}
+	}
+	if tr.release != nil {
+		<-tr.release
+	}
+	return tr.tokens[min(int(n), len(tr.tokens))-1]
+}
+
+// mediaServer serves media only for requests with the valid token and records the tokens of all requests.
+type mediaServer struct {
+	lock       sync.Mutex
+	validToken string
+	tokens     []string
+}
+
+func (ms *mediaServer) serve(w http.ResponseWriter, r *http.Request) {
+	token := r.URL.Query().Get("image_auth")
+	ms.lock.Lock()
+	ms.tokens = append(ms.tokens, token)
+	valid := token == ms.validToken
+	ms.lock.Unlock()
+	if !valid {
+		http.Error(w, `{"errcode":"M_UNKNOWN_TOKEN","error":"Invalid image auth token"}`, http.StatusUnauthorized)
+		return
+	}
+	_, _ = w.Write([]byte("meow"))
+}
+
+func (ms *mediaServer) requestTokens() []string {
+	ms.lock.Lock()
+	defer ms.lock.Unlock()
+	return slices.Clone(ms.tokens)
+}
+
+func TestBuildMediaURL(t *testing.T) {
+	fb := rpctest.NewBackend(t, "run1")
+	gc := connectMediaTestClient(t, fb, "token1")
+	tests := []struct {
+		name string
+		opts MediaURLOptions
+		want url.Values
+	}{
+		{"default", MediaURLOptions{}, url.Values{"encrypted": {"false"}, "image_auth": {"token1"}}},
+		{"encrypted", MediaURLOptions{Encrypted: true}, url.Values{"encrypted": {"true"}, "image_auth": {"token1"}}},
+		{
+			"avatar thumbnail", MediaURLOptions{AvatarThumbnail: true},
+			url.Values{"encrypted": {"false"}, "image_auth": {"token1"}, "thumbnail": {"avatar"}},
+		},
+		{"without auth", MediaURLOptions{WithoutAuth: true}, url.Values{"encrypted": {"false"}}},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			parsed, err := url.Parse(gc.BuildMediaURL(testMXC, test.opts))
+			if err != nil {
+				t.Fatalf("Failed to parse URL: %v", err)
+			}
+			if parsed.Path != "/_gomuks/media/example.com/meow" {
+				t.Errorf("Unexpected path %s", parsed.Path)
+			}
+			if query := parsed.Query(); query.Encode() != test.want.Encode() {
+				t.Errorf("Query is %s, want %s", query.Encode(), test.want.Encode())
+			}
+		})
+	}
+}
+
+func TestBuildMediaURL_DoesNotWaitForToken(t *testing.T) {
+	fb := rpctest.NewBackend(t, "run1")
+	gc := newTestClient(t, fb)
+	if err := gc.Connect(context.Background()); err != nil {
+		t.Fatalf("Failed to connect: %v", err)
+	}
+	rpctest.WaitFor(t, fb.Connected, "connection")
+	start := time.Now()
+	parsed, err := url.Parse(gc.BuildMediaURL(testMXC, MediaURLOptions{}))
+	if elapsed := time.Since(start); elapsed >= imageAuthTokenWait {
+		t.Errorf("BuildMediaURL blocked for %s waiting for a token", elapsed)
+	}
+	if err != nil {
+		t.Fatalf("Failed to parse URL: %v", err)
+	} else if parsed.Query().Has("image_auth") {
+		t.Errorf("URL has image_auth before a token was received: %s", parsed)
+	}
+}
+
+func TestGetImageAuthToken_WaitsForFirstToken(t *testing.T) {
+	ctx := context.Background()
+	fb := rpctest.NewBackend(t, "run1")
+	gc := newTestClient(t, fb)
+	if err := gc.Connect(ctx); err != nil {
+		t.Fatalf("Failed to connect: %v", err)
+	}
+	conn := rpctest.WaitFor(t, fb.Connected, "connection")
+	token := make(chan string, 1)
+	go func() {
+		token <- gc.GetImageAuthToken(ctx)
+	}()
+	select {
+	case got := <-token:
+		t.Fatalf("GetImageAuthToken returned %q before a token was received", got)
+	case <-time.After(50 * time.Millisecond):
+	}
+	if err := conn.Send(ctx, jsoncmd.EventImageAuthToken, -1, "token1"); err != nil {
+		t.Fatalf("Failed to send image auth token: %v", err)
+	}
+	if got := rpctest.WaitFor(t, token, "token"); got != "token1" {
+		t.Errorf("GetImageAuthToken() = %q, want token1", got)
+	}
+}
+
+func TestDownload_TokenExpired(t *testing.T) {
+	tests := []struct {
+		name          string
+		newTokens     []string
+		wantErr       bool
+		wantTokens    []string
+		wantRefreshes int32
+	}{
+		{"token still valid", nil, false, []string{"token1"}, 0},
+		{"refresh and retry", []string{"token2"}, false, []string{"expired", "token2"}, 1},
+		{"new token also rejected", []string{"rejected"}, true, []string{"expired", "rejected"}, 1},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			fb := rpctest.NewBackend(t, "run1")
+			initialToken, validToken := "token1", "token1"
+			if len(test.newTokens) > 0 {
+				initialToken, validToken = "expired", "token2"
+			}
+			refresher := &tokenRefresher{tokens: test.newTokens}
+			media := &mediaServer{validToken: validToken}
+			fb.SetRequestHandler(refresher.handle)
+			fb.SetMediaHandler(media.serve)
+			gc := connectMediaTestClient(t, fb, initialToken)
+
+			data, err := gc.Download(context.Background(), testMXC, false)
+			if test.wantErr {
+				if !errors.Is(err, rpc.ErrMediaUnauthorized) {
+					t.Errorf("Expected unauthorized error, got %v", err)
+				}
+			} else if err != nil {
+				t.Errorf("Download returned error: %v", err)
+			} else if string(data) != "meow" {
+				t.Errorf("Download returned %q", data)
+			}
+			if tokens := media.requestTokens(); !slices.Equal(tokens, test.wantTokens) {
+				t.Errorf("Media was requested with tokens %v, want %v", tokens, test.wantTokens)
+			}
+			if refreshes := refresher.requests.Load(); refreshes != test.wantRefreshes {
+				t.Errorf("Token was refreshed %d times, want %d", refreshes, test.wantRefreshes)
+			}
+			if len(test.newTokens) > 0 && gc.GetImageAuthToken(context.Background()) != test.newTokens[0] {
+				t.Error("Refreshed token wasn't stored")
+			}
+		})
+	}
+}
+
+func TestRefreshImageAuthToken_Concurrent(t *testing.T) {
+	fb := rpctest.NewBackend(t, "run1")
+	refresher := &tokenRefresher{
+		tokens:    []string{"token2", "token3"},
+		release:   make(chan struct{}),
+		requested: make(chan struct{}, 4),
+	}
+	fb.SetRequestHandler(refresher.handle)
+	gc := connectMediaTestClient(t, fb, "token1")
+
+	const callers = 5
+	joined := make(chan struct{}, callers)
+	gc.onImageAuthRefreshJoin = func() {
+		joined <- struct{}{}
+	}
+	errs := make(chan error, callers)
+	for range callers {
+		go func() {
+			errs <- gc.RefreshImageAuthToken(context.Background())
+		}()
+	}
+	// The token response is held back until every caller is waiting for the in-flight request
+	for range callers {
+		rpctest.WaitFor(t, joined, "caller to join refresh")
+	}
+	rpctest.WaitFor(t, refresher.requested, "token request")
+	close(refresher.release)
+	for range callers {
+		if err := rpctest.WaitFor(t, errs, "refresh result"); err != nil {
+			t.Errorf("RefreshImageAuthToken returned error: %v", err)
+		}
+	}
+	if refreshes := refresher.requests.Load(); refreshes != 1 {
+		t.Errorf("Concurrent refreshes sent %d requests, want 1", refreshes)
+	}
+	if token := gc.GetImageAuthToken(context.Background()); token != "token2" {
+		t.Errorf("Token is %q after refresh, want token2", token)
+	}
+
+	// Refreshes after the previous one completed send a new request
+	if err := gc.RefreshImageAuthToken(context.Background()); err != nil {
+		t.Fatalf("RefreshImageAuthToken returned error: %v", err)
+	} else if refreshes := refresher.requests.Load(); refreshes != 2 {
+		t.Errorf("Sequential refresh sent %d requests in total, want 2", refreshes)
+	} else if token := gc.GetImageAuthToken(context.Background()); token != "token3" {
+		t.Errorf("Token is %q after second refresh, want token3", token)
+	}
+}
+
+func TestRefreshImageAuthToken_CallerCanceled(t *testing.T) {
+	fb := rpctest.NewBackend(t, "run1")
+	refresher := &tokenRefresher{tokens: []string{"token2"}, release: make(chan struct{}), requested: make(chan struct{}, 1)}
+	fb.SetRequestHandler(refresher.handle)
+	gc := connectMediaTestClient(t, fb, "token1")
+
+	ctx, cancel := context.WithCancel(context.Background())
+	result := make(chan error, 1)
+	go func() {
+		result <- gc.RefreshImageAuthToken(ctx)
+	}()
+	rpctest.WaitFor(t, refresher.requested, "token request")
+	gc.imageAuthLock.Lock()
+	refresh := gc.imageAuthRefresh
+	gc.imageAuthLock.Unlock()
+	cancel()
+	if err := rpctest.WaitFor(t, result, "canceled refresh"); !errors.Is(err, context.Canceled) {
+		t.Errorf("Expected context canceled error, got %v", err)
+	}
+	// The request isn't canceled with the caller, so the new token is still stored
+	close(refresher.release)
+	rpctest.WaitFor(t, refresh.done, "refresh to complete")
+	if token := gc.loadImageAuthToken(); token != "token2" {
+		t.Errorf("Token is %q after the canceled caller's request completed, want token2", token)
+	}
+}
@@ -0,0 +1,126 @@
+// Copyright (c) 2025 Tulir Asokan
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package client
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+
+	"go.mau.fi/util/variationselector"
+	"maunium.net/go/mautrix/event"
+	"maunium.net/go/mautrix/id"
+
+	"go.mau.fi/gomuks/pkg/hicli/database"
+	"go.mau.fi/gomuks/pkg/hicli/jsoncmd"
+	"go.mau.fi/gomuks/pkg/rpc"
+	"go.mau.fi/gomuks/pkg/rpc/store"
+)
+
+// sendMessage sends a message and adds the pending event to the room store if the room is known.
+func (gc *GomuksClient) sendMessage(ctx context.Context, params *jsoncmd.SendMessageParams) (*database.Event, error) {
+	dbEvt, err := gc.GomuksRPC.SendMessage(ctx, params)
+	if err != nil {
+		return nil, err
+	} else if dbEvt != nil {
+		callRoomMethod(gc, dbEvt.RoomID, (*store.RoomStore).ApplyPending, dbEvt)
+	}
+	return dbEvt, nil
+}
+
+// SendText sends a text message to the room. The text is parsed as markdown the same way as messages typed
+// in the frontends. The returned event is the local echo, the send_complete event is emitted once it's sent.
+func (gc *GomuksClient) SendText(ctx context.Context, roomID id.RoomID, text string) (*database.Event, error) {
+	return gc.sendMessage(ctx, &jsoncmd.SendMessageParams{
+		RoomID: roomID,
+		Text:   text,
+	})
+}
+
+// Reply sends a text message as a reply to the given event.
+func (gc *GomuksClient) Reply(ctx context.Context, roomID id.RoomID, replyTo id.EventID, text string) (*database.Event, error) {
+	return gc.sendMessage(ctx, &jsoncmd.SendMessageParams{
+		RoomID:    roomID,
+		Text:      text,
+		RelatesTo: (&event.RelatesTo{}).SetReplyTo(replyTo),
+	})
+}
+
+// React sends a reaction to the given event. Emoji variation selectors are normalized like in the frontends.
+func (gc *GomuksClient) React(ctx context.Context, roomID id.RoomID, eventID id.EventID, key string) (*database.Event, error) {
+	content, err := json.Marshal(&event.ReactionEventContent{RelatesTo: event.RelatesTo{
+		Type:    event.RelAnnotation,
+		EventID: eventID,
+		Key:     variationselector.Add(strings.TrimSpace(key)),
+	}})
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal reaction: %w", err)
+	}
+	return gc.GomuksRPC.SendEvent(ctx, &jsoncmd.SendEventParams{
+		RoomID:    roomID,
+		EventType: event.EventReaction,
+		Content:   content,
+	})
+}
+
+// SendFile uploads a file and sends it to the room with an optional caption. The file is encrypted
+// if the room is encrypted, so the room must be in the store.
+func (gc *GomuksClient) SendFile(ctx context.Context, roomID id.RoomID, file io.Reader, fileName, caption string) (*database.Event, error) {
+	room := gc.GomuksStore.GetRoom(roomID)
+	if room == nil {
+		return nil, fmt.Errorf("room not found in store")
+	}
+	content, err := gc.UploadMedia(ctx, file, rpc.UploadMediaParams{
+		FileName: fileName,
+		Encrypt:  room.Meta.Current().EncryptionEvent != nil,
+	})
+	if err != nil {
+		return nil, err
+	}
+	return gc.sendMessage(ctx, &jsoncmd.SendMessageParams{
+		RoomID:      roomID,
+		BaseContent: content,
+		Text:        caption,
+	})
+}
+
+// SetStateEvent sends a state event with the given content, which is marshaled to JSON.
+func (gc *GomuksClient) SetStateEvent(ctx context.Context, roomID id.RoomID, evtType event.Type, stateKey string, content any) (id.EventID, error) {
+	contentJSON, err := json.Marshal(content)
+	if err != nil {
+		return "", fmt.Errorf("failed to marshal state event content: %w", err)
+	}
+	return gc.GomuksRPC.SetState(ctx, &jsoncmd.SendStateEventParams{
+		RoomID:    roomID,
+		EventType: evtType,
+		StateKey:  stateKey,
+		Content:   contentJSON,
+	})
+}
+
+// SetRoomName changes the name of the room.
+func (gc *GomuksClient) SetRoomName(ctx context.Context, roomID id.RoomID, name string) (id.EventID, error) {
+	return gc.SetStateEvent(ctx, roomID, event.StateRoomName, "", &event.RoomNameEventContent{Name: name})
+}
+
+// SetRoomTopic changes the topic of the room.
+func (gc *GomuksClient) SetRoomTopic(ctx context.Context, roomID id.RoomID, topic string) (id.EventID, error) {
+	return gc.SetStateEvent(ctx, roomID, event.StateTopic, "", &event.TopicEventContent{Topic: topic})
+}
+
+// SetRoomAvatar changes the avatar of the room. An empty URI removes the avatar.
+func (gc *GomuksClient) SetRoomAvatar(ctx context.Context, roomID id.RoomID, avatarURL id.ContentURI) (id.EventID, error) {
+	return gc.SetStateEvent(ctx, roomID, event.StateRoomAvatar, "", &event.RoomAvatarEventContent{URL: avatarURL.CUString()})
+}
+
+// SetPowerLevels replaces the power levels of the room. The current power levels can be found
+// in the room store, which should be cloned and modified rather than built from scratch.
+func (gc *GomuksClient) SetPowerLevels(ctx context.Context, roomID id.RoomID, content *event.PowerLevelsEventContent) (id.EventID, error) {
+	return gc.SetStateEvent(ctx, roomID, event.StatePowerLevels, "", content)
+}
@@ -0,0 +1,106 @@
+// Copyright (c) 2025 Tulir Asokan
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package rpc
+
+import (
+	"context"
+	"reflect"
+	"runtime/debug"
+	"slices"
+
+	"github.com/rs/zerolog"
+)
+
+type typedHandler struct {
+	fn EventHandler
+}
+
+// OnEvent registers a handler for events of type T, e.g. *jsoncmd.SyncComplete or *ConnectionStatus.
+// Events that the client doesn't know how to parse are passed as *jsoncmd.Container[json.RawMessage].
+//
+// Handlers are called from the event loop after EventHandler, in the order they were registered,
+// so they must not block for long. The returned function unregisters the handler.
+func OnEvent[T any](gr *GomuksRPC, handler func(ctx context.Context, evt T)) (remove func()) {
+	return gr.addHandler(reflect.TypeFor[T](), func(ctx context.Context, evt any) {
+		handler(ctx, evt.(T))
+	})
+}
+
+func (gr *GomuksRPC) addHandler(evtType reflect.Type, fn EventHandler) func() {
+	handler := &typedHandler{fn: fn}
+	gr.handlersLock.Lock()
+	defer gr.handlersLock.Unlock()
+	if gr.handlers == nil {
+		gr.handlers = make(map[reflect.Type][]*typedHandler)
+	}
+	// The slices are never modified in place, so that dispatching doesn't need to hold the lock
+	gr.handlers[evtType] = append(slices.Clip(gr.handlers[evtType]), handler)
+	return func() {
+		gr.handlersLock.Lock()
+		defer gr.handlersLock.Unlock()
+		gr.handlers[evtType] = slices.DeleteFunc(slices.Clone(gr.handlers[evtType]), func(item *typedHandler) bool {
+			return item == handler
+		})
+	}
+}
+
+func (gr *GomuksRPC) handleEvent(ctx context.Context, evt any) {
+	callHandler(ctx, gr.EventHandler, evt)
+	gr.handlersLock.RLock()
+	handlers := gr.handlers[reflect.TypeOf(evt)]
+	gr.handlersLock.RUnlock()
+	for _, handler := range handlers {
+		callHandler(ctx, handler.fn, evt)
+	}
+}
+
+func callHandler(ctx context.Context, handler EventHandler, evt any) {
+	defer func() {
+		err := recover()
+		if err != nil {
+			logEvt := zerolog.Ctx(ctx).Error().
+				Bytes(zerolog.ErrorStackFieldName, debug.Stack())
+			if realErr, ok := err.(error); ok {
+				logEvt = logEvt.Err(realErr)
+			} else {
+				logEvt = logEvt.Any(zerolog.ErrorFieldName, err)
+			}
+			logEvt.Msg("Panic in event handler")
+		}
+	}()
+	handler(ctx, evt)
+}
+
+// OnConnected registers a handler that is called when the websocket connects and the backend starts a new event
+// stream. Any state from previous connections should be discarded, as the initial sync will be sent again.
+func (gr *GomuksRPC) OnConnected(handler func(ctx context.Context)) (remove func()) {
+	return OnEvent(gr, func(ctx context.Context, status *ConnectionStatus) {
+		if status.State == ConnectionStateConnected && !status.Resumed {
+			handler(ctx)
+		}
+	})
+}
+
+// OnResumed registers a handler that is called when the websocket reconnects and the backend resumes the previous
+// event stream, which means no events were missed.
+func (gr *GomuksRPC) OnResumed(handler func(ctx context.Context)) (remove func()) {
+	return OnEvent(gr, func(ctx context.Context, status *ConnectionStatus) {
+		if status.State == ConnectionStateConnected && status.Resumed {
+			handler(ctx)
+		}
+	})
+}
+
+// OnDisconnected registers a handler that is called when the websocket connection is lost unexpectedly.
+// It's not called when Disconnect is used. Reconnection attempts are made automatically afterwards.
+func (gr *GomuksRPC) OnDisconnected(handler func(ctx context.Context, err error)) (remove func()) {
+	return OnEvent(gr, func(ctx context.Context, status *ConnectionStatus) {
+		if status.State == ConnectionStateDisconnected {
+			handler(ctx, status.Error)
+		}
+	})
+}
@@ -0,0 +1,135 @@
+// Copyright (c) 2025 Tulir Asokan
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package rpc
+
+import (
+	"context"
+	"math/rand/v2"
+	"time"
+
+	"github.com/coder/websocket"
+	"github.com/rs/zerolog"
+)
+
+type ConnectionState string
+
+const (
+	// ConnectionStateConnected means the websocket is connected and the backend has sent its run ID.
+	ConnectionStateConnected ConnectionState = "connected"
+	// ConnectionStateDisconnected means the websocket was disconnected unexpectedly. It's followed by the
+	// reconnecting state unless Disconnect is called.
+	ConnectionStateDisconnected ConnectionState = "disconnected"
+	// ConnectionStateReconnecting means the websocket was disconnected unexpectedly and a reconnect is pending.
+	ConnectionStateReconnecting ConnectionState = "reconnecting"
+	// ConnectionStateGaveUp means the maximum number of reconnection attempts was reached.
+	ConnectionStateGaveUp ConnectionState = "gave_up"
+)
+
+// ConnectionStatus is emitted through the EventHandler whenever the state of the websocket connection changes.
+type ConnectionStatus struct {
+	State ConnectionState
+	// Attempt is the number of the next reconnection attempt. Only set in the reconnecting state.
+	Attempt int
+	// NextAttempt is the time when the next reconnection attempt will be made. Only set in the reconnecting state.
+	NextAttempt time.Time
+	// Error is the error that caused the disconnection or the last failed reconnection attempt.
+	Error error
+	// Resumed is true if the backend resumed the previous event stream after reconnecting.
+	// If false, the backend will send the initial sync again, so any local state should be discarded.
+	Resumed bool
+}
+
+const (
+	DefaultMinReconnectBackoff = 1 * time.Second
+	DefaultMaxReconnectBackoff = 60 * time.Second
+)
+
+// ReconnectBackoff returns how long to wait before the given reconnection attempt (starting from 1).
+// The delay doubles after each attempt up to the max, and up to 50% of random jitter is added on top.
+func ReconnectBackoff(attempt int, minBackoff, maxBackoff time.Duration) time.Duration {
+	backoff := minBackoff
+	for i := 1; i < attempt && backoff < maxBackoff; i++ {
+		backoff *= 2
+	}
+	backoff = min(backoff, maxBackoff)
+	if backoff <= 0 {
+		return 0
+	}
+	return backoff + rand.N(backoff/2+1)
+}
+
+func (gr *GomuksRPC) getReconnectBackoff(attempt int) time.Duration {
+	minBackoff := gr.MinReconnectBackoff
+	if minBackoff <= 0 {
+		minBackoff = DefaultMinReconnectBackoff
+	}
+	maxBackoff := gr.MaxReconnectBackoff
+	if maxBackoff <= 0 {
+		maxBackoff = DefaultMaxReconnectBackoff
+	}
+	return ReconnectBackoff(attempt, minBackoff, maxBackoff)
+}
+
+func (gr *GomuksRPC) reconnectLoop(ctx context.Context, stopPtr *context.CancelFunc, ws *websocket.Conn, connCtx context.Context) {
+	log := zerolog.Ctx(ctx)
+	for {
+		select {
+		case <-connCtx.Done():
+		case <-ctx.Done():
+			return
+		}
+		if ctx.Err() != nil || gr.stop.Load() != stopPtr {
+			// Disconnect was called or another connection was started
+			return
+		}
+		gr.conn.CompareAndSwap(ws, nil)
+		gr.clearPendingRequests()
+		log.Warn().Msg("Websocket disconnected unexpectedly, reconnecting")
+		gr.handleEvent(ctx, &ConnectionStatus{
+			State: ConnectionStateDisconnected,
+			Error: context.Cause(connCtx),
+		})
+		var err error
+		ws, connCtx, err = gr.reconnect(ctx)
+		if err != nil {
+			return
+		}
+	}
+}
+
+func (gr *GomuksRPC) reconnect(ctx context.Context) (*websocket.Conn, context.Context, error) {
+	log := zerolog.Ctx(ctx)
+	var lastErr error
+	for attempt := 1; gr.MaxReconnectAttempts <= 0 || attempt <= gr.MaxReconnectAttempts; attempt++ {
+		backoff := gr.getReconnectBackoff(attempt)
+		gr.handleEvent(ctx, &ConnectionStatus{
+			State:       ConnectionStateReconnecting,
+			Attempt:     attempt,
+			NextAttempt: time.Now().Add(backoff),
+			Error:       lastErr,
+		})
+		select {
+		case <-time.After(backoff):
+		case <-ctx.Done():
+			return nil, nil, ctx.Err()
+		}
+		ws, connCtx, err := gr.dial(ctx)
+		if err == nil {
+			return ws, connCtx, nil
+		} else if ctx.Err() != nil {
+			return nil, nil, ctx.Err()
+		}
+		log.Err(err).Int("attempt", attempt).Msg("Failed to reconnect to websocket")
+		lastErr = err
+	}
+	log.Error().Msg("Giving up on reconnecting to websocket")
+	gr.handleEvent(ctx, &ConnectionStatus{
+		State: ConnectionStateGaveUp,
+		Error: lastErr,
+	})
+	return nil, nil, lastErr
+}
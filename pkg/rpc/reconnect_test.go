@@ -0,0 +1,184 @@
+// Copyright (c) 2026 Tulir Asokan
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package rpc
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"go.mau.fi/gomuks/pkg/hicli/jsoncmd"
+	"go.mau.fi/gomuks/pkg/rpc/rpctest"
+)
+
+// newTestRPC creates a client for the fake backend that reports connection status changes and other events
+// through the returned channels.
+func newTestRPC(t *testing.T, fb *rpctest.Backend) (*GomuksRPC, <-chan *ConnectionStatus, <-chan any) {
+	t.Helper()
+	gr, err := NewGomuksRPC(fb.URL)
+	if err != nil {
+		t.Fatalf("Failed to create RPC client: %v", err)
+	}
+	gr.MinReconnectBackoff = time.Millisecond
+	gr.MaxReconnectBackoff = 10 * time.Millisecond
+	statuses := make(chan *ConnectionStatus, 64)
+	events := make(chan any, 64)
+	gr.EventHandler = func(_ context.Context, evt any) {
+		if status, ok := evt.(*ConnectionStatus); ok {
+			statuses <- status
+		} else {
+			events <- evt
+		}
+	}
+	t.Cleanup(gr.Disconnect)
+	return gr, statuses, events
+}
+
+func expectStatus(t *testing.T, statuses <-chan *ConnectionStatus, state ConnectionState) *ConnectionStatus {
+	t.Helper()
+	status := rpctest.WaitFor(t, statuses, string(state)+" status")
+	if status.State != state {
+		t.Fatalf("Got %s status (error: %v), want %s", status.State, status.Error, state)
+	}
+	return status
+}
+
+func TestReconnect_AfterDrop(t *testing.T) {
+	tests := []struct {
+		name        string
+		newRunID    string
+		wantResumed bool
+	}{
+		{"same backend run", "run1", true},
+		{"backend restarted", "run2", false},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			ctx := context.Background()
+			fb := rpctest.NewBackend(t, "run1")
+			gr, statuses, events := newTestRPC(t, fb)
+			if err := gr.Connect(ctx); err != nil {
+				t.Fatalf("Failed to connect: %v", err)
+			}
+			conn1 := rpctest.WaitFor(t, fb.Connected, "first connection")
+			if status := expectStatus(t, statuses, ConnectionStateConnected); status.Resumed {
+				t.Error("First connection was reported as resumed")
+			} else if conn1.Query.Has("run_id") {
+				t.Errorf("First connection tried to resume: %s", conn1.Query.Encode())
+			}
+			if err := conn1.Send(ctx, jsoncmd.EventSyncStatus, -3, &jsoncmd.SyncStatus{Type: jsoncmd.SyncStatusOK}); err != nil {
+				t.Fatalf("Failed to send event: %v", err)
+			}
+			for {
+				if _, ok := rpctest.WaitFor(t, events, "sync status event").(*jsoncmd.SyncStatus); ok {
+					break
+				}
+			}
+
+			// A request that's in flight when the connection drops must fail instead of hanging
+			reqErr := make(chan error, 1)
+			go func() {
+				reqErr <- gr.ReportActivity(ctx)
+			}()
+			rpctest.WaitFor(t, conn1.Requests, "report_activity request")
+			fb.SetRunID(test.newRunID)
+			conn1.Drop()
+			if err := rpctest.WaitFor(t, reqErr, "request error"); !errors.Is(err, ErrWebsocketClosedBeforeResponseReceived) {
+				t.Errorf("In-flight request returned %v, want ErrWebsocketClosedBeforeResponseReceived", err)
+			}
+
+			if status := expectStatus(t, statuses, ConnectionStateDisconnected); status.Error == nil {
+				t.Error("Disconnected status doesn't have an error")
+			}
+			if status := expectStatus(t, statuses, ConnectionStateReconnecting); status.Attempt != 1 {
+				t.Errorf("First reconnection attempt has number %d", status.Attempt)
+			}
+			conn2 := rpctest.WaitFor(t, fb.Connected, "second connection")
+			if runID := conn2.Query.Get("run_id"); runID != "run1" {
+				t.Errorf("Reconnection sent run_id %q, want run1", runID)
+			} else if lastEvt := conn2.Query.Get("last_received_event"); lastEvt != "-3" {
+				t.Errorf("Reconnection sent last_received_event %q, want -3", lastEvt)
+			}
+			if status := expectStatus(t, statuses, ConnectionStateConnected); status.Resumed != test.wantResumed {
+				t.Errorf("Reconnection resumed = %t, want %t", status.Resumed, test.wantResumed)
+			}
+		})
+	}
+}
+
+func TestReconnect_GivesUp(t *testing.T) {
+	fb := rpctest.NewBackend(t, "run1")
+	gr, statuses, _ := newTestRPC(t, fb)
+	gr.MaxReconnectAttempts = 3
+	if err := gr.Connect(context.Background()); err != nil {
+		t.Fatalf("Failed to connect: %v", err)
+	}
+	conn := rpctest.WaitFor(t, fb.Connected, "connection")
+	expectStatus(t, statuses, ConnectionStateConnected)
+	fb.SetReject(true)
+	conn.Drop()
+
+	expectStatus(t, statuses, ConnectionStateDisconnected)
+	for attempt := 1; attempt <= 3; attempt++ {
+		status := expectStatus(t, statuses, ConnectionStateReconnecting)
+		if status.Attempt != attempt {
+			t.Errorf("Reconnecting status has attempt %d, want %d", status.Attempt, attempt)
+		} else if (attempt > 1) != (status.Error != nil) {
+			t.Errorf("Unexpected error in reconnecting status for attempt %d: %v", attempt, status.Error)
+		}
+	}
+	if status := expectStatus(t, statuses, ConnectionStateGaveUp); status.Error == nil {
+		t.Error("Gave up status doesn't have an error")
+	}
+	if gr.conn.Load() != nil {
+		t.Error("Client still has a websocket connection after giving up")
+	}
+}
+
+func TestDisconnect_DoesNotReconnect(t *testing.T) {
+	fb := rpctest.NewBackend(t, "run1")
+	gr, statuses, _ := newTestRPC(t, fb)
+	if err := gr.Connect(context.Background()); err != nil {
+		t.Fatalf("Failed to connect: %v", err)
+	}
+	rpctest.WaitFor(t, fb.Connected, "connection")
+	expectStatus(t, statuses, ConnectionStateConnected)
+	gr.Disconnect()
+	select {
+	case status := <-statuses:
+		t.Errorf("Got %s status after Disconnect", status.State)
+	case <-fb.Connected:
+		t.Error("Client reconnected after Disconnect")
+	case <-time.After(100 * time.Millisecond):
+	}
+}
+
+func TestReconnectBackoff(t *testing.T) {
+	tests := []struct {
+		attempt int
+		base    time.Duration
+	}{
+		{1, time.Second},
+		{2, 2 * time.Second},
+		{3, 4 * time.Second},
+		{6, 32 * time.Second},
+		{7, 60 * time.Second},
+		{100, 60 * time.Second},
+	}
+	for _, test := range tests {
+		for range 20 {
+			backoff := ReconnectBackoff(test.attempt, time.Second, 60*time.Second)
+			if backoff < test.base || backoff > test.base+test.base/2 {
+				t.Errorf("ReconnectBackoff(%d) = %s, want between %s and %s", test.attempt, backoff, test.base, test.base+test.base/2)
+			}
+		}
+	}
+	if backoff := ReconnectBackoff(1, 0, 0); backoff != 0 {
+		t.Errorf("ReconnectBackoff with zero limits = %s, want 0", backoff)
+	}
+}
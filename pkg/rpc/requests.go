@@ -8,6 +8,7 @@ package rpc
 
 import (
 	"context"
+	"encoding/json"
 
 	"maunium.net/go/mautrix"
 	"maunium.net/go/mautrix/id"
@@ -32,6 +33,14 @@ func (gr *GomuksRPC) ResendEvent(ctx context.Context, params *jsoncmd.ResendEven
 	return executeRequest(gr, ctx, jsoncmd.ResendEvent, params)
 }
 
+func (gr *GomuksRPC) DiscardEvent(ctx context.Context, params *jsoncmd.DiscardEventParams) error {
+	return executeRequestNoResponse(gr, ctx, jsoncmd.DiscardEvent, params)
+}
+
+func (gr *GomuksRPC) FlushSendQueue(ctx context.Context, params *jsoncmd.FlushSendQueueParams) (int, error) {
+	return executeRequest(gr, ctx, jsoncmd.FlushSendQueue, params)
+}
+
 func (gr *GomuksRPC) ReportEvent(ctx context.Context, params *jsoncmd.ReportEventParams) error {
 	return executeRequestNoResponse(gr, ctx, jsoncmd.ReportEvent, params)
 }
@@ -84,10 +93,26 @@ func (gr *GomuksRPC) GetProfileEncryptionInfo(ctx context.Context, params *jsonc
 	return executeRequest(gr, ctx, jsoncmd.GetProfileEncryptionInfo, params)
 }
 
+func (gr *GomuksRPC) GetEventEncryptionInfo(ctx context.Context, params *jsoncmd.GetEventEncryptionInfoParams) (*jsoncmd.EventEncryptionInfo, error) {
+	return executeRequest(gr, ctx, jsoncmd.GetEventEncryptionInfo, params)
+}
+
+func (gr *GomuksRPC) GetSessionDevices(ctx context.Context, params *jsoncmd.GetSessionDevicesParams) (*jsoncmd.SessionDevices, error) {
+	return executeRequest(gr, ctx, jsoncmd.GetSessionDevices, params)
+}
+
 func (gr *GomuksRPC) GetEvent(ctx context.Context, params *jsoncmd.GetEventParams) (*database.Event, error) {
 	return executeRequest(gr, ctx, jsoncmd.GetEvent, params)
 }
 
+func (gr *GomuksRPC) GetEventContext(ctx context.Context, params *jsoncmd.GetEventContextParams) (*jsoncmd.EventContextResponse, error) {
+	return executeRequest(gr, ctx, jsoncmd.GetEventContext, params)
+}
+
+func (gr *GomuksRPC) GetEventNearTimestamp(ctx context.Context, params *jsoncmd.GetEventNearTimestampParams) (*mautrix.RespTimestampToEvent, error) {
+	return executeRequest(gr, ctx, jsoncmd.GetEventNearTimestamp, params)
+}
+
 func (gr *GomuksRPC) GetRelatedEvents(ctx context.Context, params *jsoncmd.GetRelatedEventsParams) ([]*database.Event, error) {
 	return executeRequest(gr, ctx, jsoncmd.GetRelatedEvents, params)
 }
@@ -100,6 +125,10 @@ func (gr *GomuksRPC) GetSpecificRoomState(ctx context.Context, params *jsoncmd.G
 	return executeRequest(gr, ctx, jsoncmd.GetSpecificRoomState, params)
 }
 
+func (gr *GomuksRPC) GetEventsByRowIDs(ctx context.Context, params *jsoncmd.GetEventsByRowIDsParams) ([]*database.Event, error) {
+	return executeRequest(gr, ctx, jsoncmd.GetEventsByRowIDs, params)
+}
+
 func (gr *GomuksRPC) GetReceipts(ctx context.Context, params *jsoncmd.GetReceiptsParams) (map[id.EventID][]*database.Receipt, error) {
 	return executeRequest(gr, ctx, jsoncmd.GetReceipts, params)
 }
@@ -108,6 +137,22 @@ func (gr *GomuksRPC) Paginate(ctx context.Context, params *jsoncmd.PaginateParam
 	return executeRequest(gr, ctx, jsoncmd.Paginate, params)
 }
 
+func (gr *GomuksRPC) PaginateLineage(ctx context.Context, params *jsoncmd.PaginateLineageParams) (*jsoncmd.PaginateLineageResponse, error) {
+	return executeRequest(gr, ctx, jsoncmd.PaginateLineage, params)
+}
+
+func (gr *GomuksRPC) FillGap(ctx context.Context, params *jsoncmd.FillGapParams) (*jsoncmd.FillGapResponse, error) {
+	return executeRequest(gr, ctx, jsoncmd.FillGap, params)
+}
+
+func (gr *GomuksRPC) ResetRoom(ctx context.Context, params *jsoncmd.ResetRoomParams) error {
+	return executeRequestNoResponse(gr, ctx, jsoncmd.ResetRoom, params)
+}
+
+func (gr *GomuksRPC) ResetSync(ctx context.Context, params *jsoncmd.ResetSyncParams) error {
+	return executeRequestNoResponse(gr, ctx, jsoncmd.ResetSync, params)
+}
+
 func (gr *GomuksRPC) PaginateManual(ctx context.Context, params *jsoncmd.PaginateManualParams) (*jsoncmd.ManualPaginationResponse, error) {
 	return executeRequest(gr, ctx, jsoncmd.PaginateManual, params)
 }
@@ -116,6 +161,10 @@ func (gr *GomuksRPC) GetMentions(ctx context.Context, params *jsoncmd.GetMention
 	return executeRequest(gr, ctx, jsoncmd.GetMentions, params)
 }
 
+func (gr *GomuksRPC) GetReactionsToMe(ctx context.Context, params *jsoncmd.GetReactionsToMeParams) ([]*jsoncmd.ReactionToMe, error) {
+	return executeRequest(gr, ctx, jsoncmd.GetReactionsToMe, params)
+}
+
 func (gr *GomuksRPC) GetRoomSummary(ctx context.Context, params *jsoncmd.GetRoomSummaryParams) (*mautrix.RespRoomSummary, error) {
 	return executeRequest(gr, ctx, jsoncmd.GetRoomSummary, params)
 }
@@ -136,6 +185,10 @@ func (gr *GomuksRPC) LeaveRoom(ctx context.Context, params *jsoncmd.LeaveRoomPar
 	return executeRequest(gr, ctx, jsoncmd.LeaveRoom, params)
 }
 
+func (gr *GomuksRPC) LeaveRooms(ctx context.Context, params *jsoncmd.LeaveRoomsParams) (*jsoncmd.LeaveRoomsResponse, error) {
+	return executeRequest(gr, ctx, jsoncmd.LeaveRooms, params)
+}
+
 func (gr *GomuksRPC) CreateRoom(ctx context.Context, params *mautrix.ReqCreateRoom) (*mautrix.RespCreateRoom, error) {
 	return executeRequest(gr, ctx, jsoncmd.CreateRoom, params)
 }
@@ -164,6 +217,10 @@ func (gr *GomuksRPC) Logout(ctx context.Context) error {
 	return executeRequestNoResponse(gr, ctx, jsoncmd.Logout, nil)
 }
 
+func (gr *GomuksRPC) ReportActivity(ctx context.Context) error {
+	return executeRequestNoResponse(gr, ctx, jsoncmd.ReportActivity, nil)
+}
+
 func (gr *GomuksRPC) Login(ctx context.Context, params *jsoncmd.LoginParams) error {
 	return executeRequestNoResponse(gr, ctx, jsoncmd.Login, params)
 }
@@ -203,3 +260,123 @@ func (gr *GomuksRPC) GetMediaConfig(ctx context.Context) (*mautrix.RespMediaConf
 func (gr *GomuksRPC) CalculateRoomID(ctx context.Context, params *jsoncmd.CalculateRoomIDParams) (id.RoomID, error) {
 	return executeRequest(gr, ctx, jsoncmd.CalculateRoomID, params)
 }
+
+func (gr *GomuksRPC) ImportHistory(ctx context.Context, params *jsoncmd.ImportHistoryParams) (*jsoncmd.ImportHistoryResponse, error) {
+	return executeRequest(gr, ctx, jsoncmd.ImportHistory, params)
+}
+
+func (gr *GomuksRPC) ChangePassword(ctx context.Context, params *jsoncmd.ChangePasswordParams) (*jsoncmd.UIAResponse, error) {
+	return executeRequest(gr, ctx, jsoncmd.ChangePassword, params)
+}
+
+func (gr *GomuksRPC) DeactivateAccount(ctx context.Context, params *jsoncmd.DeactivateAccountParams) (*jsoncmd.UIAResponse, error) {
+	return executeRequest(gr, ctx, jsoncmd.DeactivateAccount, params)
+}
+
+func (gr *GomuksRPC) SetDeviceName(ctx context.Context, params *jsoncmd.SetDeviceNameParams) error {
+	return executeRequestNoResponse(gr, ctx, jsoncmd.SetDeviceName, params)
+}
+
+func (gr *GomuksRPC) CreateAlias(ctx context.Context, params *jsoncmd.CreateAliasParams) error {
+	return executeRequestNoResponse(gr, ctx, jsoncmd.CreateAlias, params)
+}
+
+func (gr *GomuksRPC) DeleteAlias(ctx context.Context, params *jsoncmd.DeleteAliasParams) error {
+	return executeRequestNoResponse(gr, ctx, jsoncmd.DeleteAlias, params)
+}
+
+func (gr *GomuksRPC) GetLocalAliases(ctx context.Context, params *jsoncmd.GetLocalAliasesParams) ([]id.RoomAlias, error) {
+	return executeRequest(gr, ctx, jsoncmd.GetLocalAliases, params)
+}
+
+func (gr *GomuksRPC) SearchPublicRooms(ctx context.Context, params *jsoncmd.SearchPublicRoomsParams) (*mautrix.RespPublicRooms, error) {
+	return executeRequest(gr, ctx, jsoncmd.SearchPublicRooms, params)
+}
+
+func (gr *GomuksRPC) SetLogLevel(ctx context.Context, params *jsoncmd.SetLogLevelParams) (*jsoncmd.LogLevels, error) {
+	return executeRequest(gr, ctx, jsoncmd.SetLogLevel, params)
+}
+
+func (gr *GomuksRPC) GetRecentLogs(ctx context.Context, params *jsoncmd.GetRecentLogsParams) ([]json.RawMessage, error) {
+	return executeRequest(gr, ctx, jsoncmd.GetRecentLogs, params)
+}
+
+func (gr *GomuksRPC) GetLeftRooms(ctx context.Context) ([]*database.Room, error) {
+	return executeRequest(gr, ctx, jsoncmd.GetLeftRooms, nil)
+}
+
+func (gr *GomuksRPC) ForgetRoom(ctx context.Context, params *jsoncmd.ForgetRoomParams) error {
+	return executeRequestNoResponse(gr, ctx, jsoncmd.ForgetRoom, params)
+}
+
+func (gr *GomuksRPC) GetSyncFilter(ctx context.Context) (*jsoncmd.SyncFilterSettings, error) {
+	return executeRequest(gr, ctx, jsoncmd.GetSyncFilter, nil)
+}
+
+func (gr *GomuksRPC) SetSyncFilter(ctx context.Context, params *jsoncmd.SyncFilterSettings) error {
+	return executeRequestNoResponse(gr, ctx, jsoncmd.SetSyncFilter, params)
+}
+
+func (gr *GomuksRPC) ExportSettings(ctx context.Context) (*jsoncmd.SettingsBackup, error) {
+	return executeRequest(gr, ctx, jsoncmd.ExportSettings, nil)
+}
+
+func (gr *GomuksRPC) ImportSettings(ctx context.Context, params *jsoncmd.ImportSettingsParams) (*jsoncmd.ImportSettingsResponse, error) {
+	return executeRequest(gr, ctx, jsoncmd.ImportSettings, params)
+}
+
+func (gr *GomuksRPC) StorageGet(ctx context.Context, params *jsoncmd.StorageKeyParams) (*database.FrontendStoreEntry, error) {
+	return executeRequest(gr, ctx, jsoncmd.StorageGet, params)
+}
+
+func (gr *GomuksRPC) StorageSet(ctx context.Context, params *jsoncmd.StorageSetParams) error {
+	return executeRequestNoResponse(gr, ctx, jsoncmd.StorageSet, params)
+}
+
+func (gr *GomuksRPC) StorageDelete(ctx context.Context, params *jsoncmd.StorageKeyParams) error {
+	return executeRequestNoResponse(gr, ctx, jsoncmd.StorageDelete, params)
+}
+
+func (gr *GomuksRPC) StorageList(ctx context.Context, params *jsoncmd.StorageListParams) ([]*database.FrontendStoreEntry, error) {
+	return executeRequest(gr, ctx, jsoncmd.StorageList, params)
+}
+
+func (gr *GomuksRPC) ExportMembers(ctx context.Context, params *jsoncmd.ExportMembersParams) (*jsoncmd.ExportMembersResponse, error) {
+	return executeRequest(gr, ctx, jsoncmd.ExportMembers, params)
+}
+
+func (gr *GomuksRPC) GetImageAuthToken(ctx context.Context) (jsoncmd.ImageAuthToken, error) {
+	return executeRequest(gr, ctx, jsoncmd.GetImageAuthToken, nil)
+}
+
+func (gr *GomuksRPC) SubscribePolicyRoom(ctx context.Context, params *jsoncmd.SubscribePolicyRoomParams) error {
+	return executeRequestNoResponse(gr, ctx, jsoncmd.SubscribePolicyRoom, params)
+}
+
+func (gr *GomuksRPC) UnsubscribePolicyRoom(ctx context.Context, params *jsoncmd.UnsubscribePolicyRoomParams) error {
+	return executeRequestNoResponse(gr, ctx, jsoncmd.UnsubscribePolicyRoom, params)
+}
+
+func (gr *GomuksRPC) ListPolicyRules(ctx context.Context, params *jsoncmd.ListPolicyRulesParams) (*jsoncmd.ListPolicyRulesResponse, error) {
+	return executeRequest(gr, ctx, jsoncmd.ListPolicyRules, params)
+}
+
+func (gr *GomuksRPC) PolicyDryRun(ctx context.Context, params *jsoncmd.PolicyDryRunParams) ([]*database.PolicyEnforcement, error) {
+	return executeRequest(gr, ctx, jsoncmd.PolicyDryRun, params)
+}
+
+func (gr *GomuksRPC) Get3PIDs(ctx context.Context) ([]*jsoncmd.ThreePID, error) {
+	return executeRequest(gr, ctx, jsoncmd.Get3PIDs, nil)
+}
+
+func (gr *GomuksRPC) Add3PIDEmail(ctx context.Context, params *jsoncmd.Add3PIDEmailParams) (*jsoncmd.Add3PIDResponse, error) {
+	return executeRequest(gr, ctx, jsoncmd.Add3PIDEmail, params)
+}
+
+func (gr *GomuksRPC) Delete3PID(ctx context.Context, params *jsoncmd.Delete3PIDParams) error {
+	return executeRequestNoResponse(gr, ctx, jsoncmd.Delete3PID, params)
+}
+
+func (gr *GomuksRPC) GetDebugStats(ctx context.Context) (*jsoncmd.DebugStats, error) {
+	return executeRequest(gr, ctx, jsoncmd.GetDebugStats, nil)
+}
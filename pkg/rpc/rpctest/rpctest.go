@@ -0,0 +1,160 @@
+// Copyright (c) 2026 Tulir Asokan
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+// Package rpctest contains a fake gomuks backend for testing RPC clients.
+package rpctest
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/coder/websocket"
+
+	"go.mau.fi/gomuks/pkg/hicli/jsoncmd"
+)
+
+// Backend is an in-process gomuks backend websocket. Each connection is sent a run ID first,
+// like the real backend does, and is then passed to the test through the Connected channel.
+type Backend struct {
+	*httptest.Server
+	Connected chan *Conn
+
+	lock   sync.Mutex
+	runID  string
+	reject bool
+	// handleRequest is called for requests from the client other than pings. The return value is sent as the response.
+	handleRequest func(cmd *jsoncmd.Container[json.RawMessage]) any
+	// media serves the media download endpoint.
+	media http.HandlerFunc
+}
+
+// Conn is a single websocket connection to a Backend.
+type Conn struct {
+	WS    *websocket.Conn
+	Query url.Values
+	// Requests receives requests from the client other than pings if the backend has no request handler.
+	Requests chan *jsoncmd.Container[json.RawMessage]
+}
+
+// NewBackend starts a fake backend that is stopped when the test finishes.
+func NewBackend(t testing.TB, runID string) *Backend {
+	t.Helper()
+	fb := &Backend{runID: runID, Connected: make(chan *Conn, 16)}
+	fb.Server = httptest.NewServer(http.HandlerFunc(fb.serve))
+	t.Cleanup(fb.Close)
+	return fb
+}
+
+// SetRunID changes the run ID sent to new connections, which simulates a backend restart.
+func (fb *Backend) SetRunID(runID string) {
+	fb.lock.Lock()
+	fb.runID = runID
+	fb.lock.Unlock()
+}
+
+// SetReject makes new websocket connections fail with HTTP 503.
+func (fb *Backend) SetReject(reject bool) {
+	fb.lock.Lock()
+	fb.reject = reject
+	fb.lock.Unlock()
+}
+
+// SetRequestHandler sets a function that responds to requests on new connections.
+func (fb *Backend) SetRequestHandler(handler func(cmd *jsoncmd.Container[json.RawMessage]) any) {
+	fb.lock.Lock()
+	fb.handleRequest = handler
+	fb.lock.Unlock()
+}
+
+// SetMediaHandler sets the handler for the media download endpoint.
+func (fb *Backend) SetMediaHandler(handler http.HandlerFunc) {
+	fb.lock.Lock()
+	fb.media = handler
+	fb.lock.Unlock()
+}
+
+func (fb *Backend) serve(w http.ResponseWriter, r *http.Request) {
+	fb.lock.Lock()
+	runID, reject, handleRequest, media := fb.runID, fb.reject, fb.handleRequest, fb.media
+	fb.lock.Unlock()
+	if strings.HasPrefix(r.URL.Path, "/_gomuks/media/") && media != nil {
+		media(w, r)
+		return
+	} else if r.URL.Path != "/_gomuks/websocket" {
+		http.NotFound(w, r)
+		return
+	} else if reject {
+		http.Error(w, "backend unavailable", http.StatusServiceUnavailable)
+		return
+	}
+	ws, err := websocket.Accept(w, r, nil)
+	if err != nil {
+		return
+	}
+	defer ws.CloseNow()
+	conn := &Conn{WS: ws, Query: r.URL.Query(), Requests: make(chan *jsoncmd.Container[json.RawMessage], 16)}
+	ctx := r.Context()
+	if conn.Send(ctx, jsoncmd.EventRunID, 0, &jsoncmd.RunData{RunID: runID}) != nil {
+		return
+	}
+	fb.Connected <- conn
+	for {
+		_, data, err := ws.Read(ctx)
+		if err != nil {
+			return
+		}
+		var cmd jsoncmd.Container[json.RawMessage]
+		if json.Unmarshal(data, &cmd) != nil || cmd.Command == jsoncmd.ReqPing {
+			continue
+		} else if handleRequest == nil {
+			conn.Requests <- &cmd
+		} else if conn.Send(ctx, jsoncmd.RespSuccess, cmd.RequestID, handleRequest(&cmd)) != nil {
+			return
+		}
+	}
+}
+
+// Send sends an event or response to the client. Like the real backend, events that can be resumed from
+// should have negative IDs.
+func (conn *Conn) Send(ctx context.Context, command jsoncmd.Name, reqID int64, data any) error {
+	rawData, err := json.Marshal(data)
+	if err != nil {
+		return err
+	}
+	rawCmd, err := json.Marshal(&jsoncmd.Container[json.RawMessage]{
+		Command:   command,
+		RequestID: reqID,
+		Data:      rawData,
+	})
+	if err != nil {
+		return err
+	}
+	return conn.WS.Write(ctx, websocket.MessageText, rawCmd)
+}
+
+// Drop closes the connection without a close handshake, like a network failure would.
+func (conn *Conn) Drop() {
+	_ = conn.WS.CloseNow()
+}
+
+// WaitFor receives a value from the given channel, failing the test if nothing arrives within 5 seconds.
+func WaitFor[T any](t testing.TB, ch <-chan T, what string) T {
+	t.Helper()
+	select {
+	case val := <-ch:
+		return val
+	case <-time.After(5 * time.Second):
+		t.Fatalf("Timed out waiting for %s", what)
+		panic("unreachable")
+	}
+}
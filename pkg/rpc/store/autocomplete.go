@@ -0,0 +1,247 @@
+// Copyright (c) 2025 Tulir Asokan
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package store
+
+import (
+	"cmp"
+	"maps"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+	"unicode"
+
+	"github.com/tidwall/gjson"
+	"go.mau.fi/util/ptr"
+	"golang.org/x/text/unicode/norm"
+	"maunium.net/go/mautrix/event"
+	"maunium.net/go/mautrix/id"
+
+	"go.mau.fi/gomuks/pkg/hicli/database"
+)
+
+// maxRecentUsers is the number of users kept in the recently seen users index.
+// The index is only trimmed after it grows 25% past the limit to avoid sorting on every new user.
+const maxRecentUsers = 1000
+
+// toSearchableString normalizes a string for case- and accent-insensitive searching.
+// Punctuation and whitespace are collapsed into single spaces, so that word boundaries can still be detected.
+func toSearchableString(s string) string {
+	var buf strings.Builder
+	buf.Grow(len(s))
+	lastWasSpace := true
+	for _, r := range norm.NFD.String(s) {
+		switch {
+		case unicode.Is(unicode.Mn, r), unicode.Is(unicode.Cf, r):
+			// Drop combining marks (i.e. accents after NFD) and invisible formatting characters
+		case unicode.IsLetter(r), unicode.IsNumber(r), unicode.IsSymbol(r):
+			buf.WriteRune(unicode.ToLower(r))
+			lastWasSpace = false
+		default:
+			if !lastWasSpace {
+				buf.WriteByte(' ')
+				lastWasSpace = true
+			}
+		}
+	}
+	return strings.TrimSuffix(buf.String(), " ")
+}
+
+type matchRank int
+
+const (
+	matchNone matchRank = iota
+	matchSubstring
+	matchWordBoundary
+	matchPrefix
+)
+
+// rankMatch checks how well the query matches the search string.
+// Both strings must already be normalized with toSearchableString.
+func rankMatch(searchString, query string) matchRank {
+	switch {
+	case strings.HasPrefix(searchString, query):
+		return matchPrefix
+	case strings.Contains(searchString, " "+query):
+		return matchWordBoundary
+	case strings.Contains(searchString, query):
+		return matchSubstring
+	default:
+		return matchNone
+	}
+}
+
+type rankedItem[T any] struct {
+	item T
+	rank matchRank
+}
+
+// rankedSearch returns the items matching the query, best matches first. Items with the same rank are
+// ordered using the tiebreak function. If limit is positive, at most that many items are returned.
+func rankedSearch[T any](items []T, query string, limit int, searchString func(T) string, tiebreak func(a, b T) int) []T {
+	query = toSearchableString(query)
+	var matches []rankedItem[T]
+	for _, item := range items {
+		if rank := rankMatch(searchString(item), query); rank != matchNone {
+			matches = append(matches, rankedItem[T]{item, rank})
+		}
+	}
+	slices.SortStableFunc(matches, func(a, b rankedItem[T]) int {
+		return cmp.Or(cmp.Compare(b.rank, a.rank), tiebreak(a.item, b.item))
+	})
+	if limit > 0 && len(matches) > limit {
+		matches = matches[:limit]
+	}
+	output := make([]T, len(matches))
+	for i, match := range matches {
+		output[i] = match.item
+	}
+	return output
+}
+
+type AutocompleteRoomEntry struct {
+	RoomID       id.RoomID
+	Alias        id.RoomAlias
+	Name         string
+	SearchString string
+}
+
+type AutocompleteUserEntry struct {
+	UserID       id.UserID
+	Displayname  string
+	AvatarURL    id.ContentURI
+	SearchString string
+	LastSeen     time.Time
+}
+
+// AutocompleteIndex contains account-wide indexes of rooms and recently seen users,
+// which are used for autocompleting things that aren't in the current room.
+type AutocompleteIndex struct {
+	lock  sync.RWMutex
+	rooms map[id.RoomID]*AutocompleteRoomEntry
+	users map[id.UserID]*AutocompleteUserEntry
+}
+
+// UpdateRoom adds or updates a room in the index. It's meant to be used as a listener for RoomStore.Meta.
+func (ai *AutocompleteIndex) UpdateRoom(meta *database.Room) {
+	name := ptr.Val(meta.Name)
+	alias := ptr.Val(meta.CanonicalAlias)
+	ai.lock.Lock()
+	defer ai.lock.Unlock()
+	if name == "" && alias == "" {
+		delete(ai.rooms, meta.ID)
+		return
+	}
+	if existing, ok := ai.rooms[meta.ID]; ok && existing.Name == name && existing.Alias == alias {
+		return
+	}
+	if ai.rooms == nil {
+		ai.rooms = make(map[id.RoomID]*AutocompleteRoomEntry)
+	}
+	ai.rooms[meta.ID] = &AutocompleteRoomEntry{
+		RoomID:       meta.ID,
+		Alias:        alias,
+		Name:         name,
+		SearchString: toSearchableString(name + " " + alias.String()),
+	}
+}
+
+func (ai *AutocompleteIndex) RemoveRoom(roomID id.RoomID) {
+	ai.lock.Lock()
+	delete(ai.rooms, roomID)
+	ai.lock.Unlock()
+}
+
+// SeenUser records that the given user sent an event at the given time.
+// The profile is only updated if the event is newer than the last one seen from the user.
+func (ai *AutocompleteIndex) SeenUser(userID id.UserID, displayname string, avatarURL id.ContentURI, ts time.Time) {
+	ai.lock.Lock()
+	defer ai.lock.Unlock()
+	if existing, ok := ai.users[userID]; ok && !ts.After(existing.LastSeen) {
+		return
+	}
+	if ai.users == nil {
+		ai.users = make(map[id.UserID]*AutocompleteUserEntry)
+	}
+	ai.users[userID] = &AutocompleteUserEntry{
+		UserID:       userID,
+		Displayname:  cmp.Or(displayname, userID.Localpart()),
+		AvatarURL:    avatarURL,
+		SearchString: toSearchableString(displayname + " " + string(userID)),
+		LastSeen:     ts,
+	}
+	if len(ai.users) > maxRecentUsers+maxRecentUsers/4 {
+		ai.trimUsers()
+	}
+}
+
+func (ai *AutocompleteIndex) trimUsers() {
+	users := slices.SortedFunc(maps.Values(ai.users), compareLastSeen)
+	for _, user := range users[maxRecentUsers:] {
+		delete(ai.users, user.UserID)
+	}
+}
+
+func compareLastSeen(a, b *AutocompleteUserEntry) int {
+	return b.LastSeen.Compare(a.LastSeen)
+}
+
+func (ai *AutocompleteIndex) Clear() {
+	ai.lock.Lock()
+	clear(ai.rooms)
+	clear(ai.users)
+	ai.lock.Unlock()
+}
+
+// SearchRooms finds rooms whose name or canonical alias matches the query.
+func (ai *AutocompleteIndex) SearchRooms(query string, limit int) []*AutocompleteRoomEntry {
+	ai.lock.RLock()
+	rooms := slices.Collect(maps.Values(ai.rooms))
+	ai.lock.RUnlock()
+	return rankedSearch(rooms, query, limit, func(room *AutocompleteRoomEntry) string {
+		return room.SearchString
+	}, func(a, b *AutocompleteRoomEntry) int {
+		return cmp.Or(cmp.Compare(a.Name, b.Name), cmp.Compare(a.RoomID, b.RoomID))
+	})
+}
+
+// SearchUsers finds recently seen users whose displayname or user ID matches the query.
+// Users with the same match rank are sorted by when they were last seen.
+func (ai *AutocompleteIndex) SearchUsers(query string, limit int) []*AutocompleteUserEntry {
+	ai.lock.RLock()
+	users := slices.Collect(maps.Values(ai.users))
+	ai.lock.RUnlock()
+	return rankedSearch(users, query, limit, func(user *AutocompleteUserEntry) string {
+		return user.SearchString
+	}, compareLastSeen)
+}
+
+// SearchMembers finds joined or invited members of the room whose displayname or user ID matches the query.
+func (rs *RoomStore) SearchMembers(query string, limit int) []*AutocompleteMemberEntry {
+	return rankedSearch(rs.GetMembers(), query, limit, func(member *AutocompleteMemberEntry) string {
+		return member.SearchString
+	}, func(a, b *AutocompleteMemberEntry) int {
+		return cmp.Or(cmp.Compare(a.Displayname, b.Displayname), cmp.Compare(a.UserID, b.UserID))
+	})
+}
+
+// indexTimelineSenders adds the senders of new timeline events to the recently seen users index.
+func (rs *RoomStore) indexTimelineSenders(timeline []database.TimelineRowTuple) {
+	for _, tuple := range timeline {
+		evt, ok := rs.eventsByRowID[tuple.Event]
+		if !ok || evt.Sender == rs.parent.UserID {
+			continue
+		}
+		var displayname string
+		var avatarURL id.ContentURI
+		if memberEvt := rs.eventsByRowID[rs.state[event.StateMember][evt.Sender.String()]]; memberEvt != nil {
+			displayname = gjson.GetBytes(memberEvt.Content, "displayname").Str
+			avatarURL, _ = id.ParseContentURI(gjson.GetBytes(memberEvt.Content, "avatar_url").Str)
+		}
+		rs.parent.Autocomplete.SeenUser(evt.Sender, displayname, avatarURL, evt.Timestamp.Time)
+	}
+}
@@ -0,0 +1,261 @@
+// Copyright (c) 2026 Tulir Asokan
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package store
+
+import (
+	"encoding/json"
+	"fmt"
+	"slices"
+	"testing"
+	"time"
+
+	"go.mau.fi/util/jsontime"
+	"maunium.net/go/mautrix/event"
+	"maunium.net/go/mautrix/id"
+
+	"go.mau.fi/gomuks/pkg/hicli/database"
+	"go.mau.fi/gomuks/pkg/hicli/jsoncmd"
+)
+
+func TestToSearchableString(t *testing.T) {
+	tests := []struct {
+		input string
+		want  string
+	}{
+		{"", ""},
+		{"Alice", "alice"},
+		{"@alice:example.com", "alice example com"},
+		{"#matrix:matrix.org", "matrix matrix org"},
+		{"  Bob   Smith  ", "bob smith"},
+		{"Ünïcödé Ñame", "unicode name"},
+		{"zero\u200bwidth", "zerowidth"},
+		{"Emoji 🐈 cat", "emoji 🐈 cat"},
+		{"日本語", "日本語"},
+		{"a_b-c.d", "a b c d"},
+	}
+	for _, test := range tests {
+		if got := toSearchableString(test.input); got != test.want {
+			t.Errorf("toSearchableString(%q) = %q, want %q", test.input, got, test.want)
+		}
+	}
+}
+
+func TestRankMatch(t *testing.T) {
+	tests := []struct {
+		searchString string
+		query        string
+		want         matchRank
+	}{
+		{"matrix hq", "matrix", matchPrefix},
+		{"matrix hq", "matrix hq", matchPrefix},
+		{"the matrix spec", "matrix", matchWordBoundary},
+		{"the matrix spec", "matrix sp", matchWordBoundary},
+		{"antimatrix", "matrix", matchSubstring},
+		{"gomuks", "matrix", matchNone},
+		{"matrix", "", matchPrefix},
+	}
+	for _, test := range tests {
+		if got := rankMatch(test.searchString, test.query); got != test.want {
+			t.Errorf("rankMatch(%q, %q) = %d, want %d", test.searchString, test.query, got, test.want)
+		}
+	}
+}
+
+func testRoomMeta(roomID id.RoomID, name string, alias id.RoomAlias) *database.Room {
+	meta := &database.Room{ID: roomID}
+	if name != "" {
+		meta.Name = &name
+	}
+	if alias != "" {
+		meta.CanonicalAlias = &alias
+	}
+	return meta
+}
+
+func searchedRoomIDs(entries []*AutocompleteRoomEntry) []id.RoomID {
+	roomIDs := make([]id.RoomID, len(entries))
+	for i, entry := range entries {
+		roomIDs[i] = entry.RoomID
+	}
+	return roomIDs
+}
+
+func searchedUserIDs(entries []*AutocompleteUserEntry) []id.UserID {
+	userIDs := make([]id.UserID, len(entries))
+	for i, entry := range entries {
+		userIDs[i] = entry.UserID
+	}
+	return userIDs
+}
+
+func TestAutocompleteIndex_SearchRooms(t *testing.T) {
+	var ai AutocompleteIndex
+	ai.UpdateRoom(testRoomMeta("!antimatrix:example.com", "Antimatrix", ""))
+	ai.UpdateRoom(testRoomMeta("!spec:example.com", "The Matrix Spec", ""))
+	ai.UpdateRoom(testRoomMeta("!hq:example.com", "Matrix HQ", "#matrix:matrix.org"))
+	ai.UpdateRoom(testRoomMeta("!gomuks:example.com", "gomuks", "#gomuks:maunium.net"))
+	ai.UpdateRoom(testRoomMeta("!alias:example.com", "", "#matrix-dev:example.com"))
+	ai.UpdateRoom(testRoomMeta("!unnamed:example.com", "", ""))
+
+	tests := []struct {
+		query string
+		limit int
+		want  []id.RoomID
+	}{
+		// Rooms with the same rank are sorted by name, so the room with only an alias comes first
+		{"matrix", 0, []id.RoomID{"!alias:example.com", "!hq:example.com", "!spec:example.com", "!antimatrix:example.com"}},
+		{"matrix", 3, []id.RoomID{"!alias:example.com", "!hq:example.com", "!spec:example.com"}},
+		{"MATRIX H", 0, []id.RoomID{"!hq:example.com"}},
+		{"#gomuks", 0, []id.RoomID{"!gomuks:example.com"}},
+		{"maunium", 0, []id.RoomID{"!gomuks:example.com"}},
+		{"matrix-dev", 0, []id.RoomID{"!alias:example.com"}},
+		{"nothing", 0, []id.RoomID{}},
+	}
+	for _, test := range tests {
+		if got := searchedRoomIDs(ai.SearchRooms(test.query, test.limit)); !slices.Equal(got, test.want) {
+			t.Errorf("SearchRooms(%q, %d) = %v, want %v", test.query, test.limit, got, test.want)
+		}
+	}
+}
+
+func TestAutocompleteIndex_UpdateRoom(t *testing.T) {
+	var ai AutocompleteIndex
+	ai.UpdateRoom(testRoomMeta("!room:example.com", "Old name", ""))
+	ai.UpdateRoom(testRoomMeta("!room:example.com", "New name", ""))
+	if got := searchedRoomIDs(ai.SearchRooms("old", 0)); len(got) != 0 {
+		t.Errorf("Expected old name to be removed from the index, got %v", got)
+	}
+	if got := searchedRoomIDs(ai.SearchRooms("new", 0)); !slices.Equal(got, []id.RoomID{"!room:example.com"}) {
+		t.Errorf("Expected new name to be indexed, got %v", got)
+	}
+	ai.UpdateRoom(testRoomMeta("!room:example.com", "", ""))
+	if got := searchedRoomIDs(ai.SearchRooms("", 0)); len(got) != 0 {
+		t.Errorf("Expected room without name or alias to be removed, got %v", got)
+	}
+	ai.UpdateRoom(testRoomMeta("!room:example.com", "Name", ""))
+	ai.RemoveRoom("!room:example.com")
+	if got := searchedRoomIDs(ai.SearchRooms("", 0)); len(got) != 0 {
+		t.Errorf("Expected removed room to be gone, got %v", got)
+	}
+}
+
+func TestAutocompleteIndex_SeenUser(t *testing.T) {
+	var ai AutocompleteIndex
+	base := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	ai.SeenUser("@bob:example.com", "Bob", id.ContentURI{}, base)
+	ai.SeenUser("@bob:example.com", "Old Bob", id.ContentURI{}, base.Add(-time.Minute))
+	ai.SeenUser("@bobby:example.com", "", id.ContentURI{}, base.Add(time.Minute))
+	ai.SeenUser("@robert:example.com", "Bob Robert", id.ContentURI{}, base.Add(2*time.Minute))
+	ai.SeenUser("@carol:example.com", "Carol", id.ContentURI{}, base.Add(3*time.Minute))
+
+	users := ai.SearchUsers("bob", 0)
+	// Prefix matches first, most recently seen first within the same rank
+	want := []id.UserID{"@robert:example.com", "@bobby:example.com", "@bob:example.com"}
+	if got := searchedUserIDs(users); !slices.Equal(got, want) {
+		t.Fatalf("SearchUsers(bob) = %v, want %v", got, want)
+	}
+	if users[2].Displayname != "Bob" {
+		t.Errorf("Expected older event not to override the displayname, got %q", users[2].Displayname)
+	} else if users[1].Displayname != "bobby" {
+		t.Errorf("Expected localpart as fallback displayname, got %q", users[1].Displayname)
+	}
+	if got := searchedUserIDs(ai.SearchUsers("robert", 0)); !slices.Equal(got, []id.UserID{"@robert:example.com"}) {
+		t.Errorf("SearchUsers(robert) = %v", got)
+	}
+
+	ai.SeenUser("@bob:example.com", "Robert Bobson", id.ContentURI{}, base.Add(4*time.Minute))
+	// Bob's new displayname is a prefix match, while @robert only matches at a word boundary
+	want = []id.UserID{"@bob:example.com", "@robert:example.com"}
+	if got := searchedUserIDs(ai.SearchUsers("robert", 0)); !slices.Equal(got, want) {
+		t.Errorf("Expected newer event to update the displayname, SearchUsers(robert) = %v, want %v", got, want)
+	}
+}
+
+func TestAutocompleteIndex_TrimUsers(t *testing.T) {
+	var ai AutocompleteIndex
+	base := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	total := maxRecentUsers + maxRecentUsers/4 + 1
+	for i := range total {
+		ai.SeenUser(id.UserID(fmt.Sprintf("@user%d:example.com", i)), "", id.ContentURI{}, base.Add(time.Duration(i)*time.Second))
+	}
+	users := ai.SearchUsers("user", 0)
+	if len(users) != maxRecentUsers {
+		t.Fatalf("Expected the index to be trimmed to %d users, got %d", maxRecentUsers, len(users))
+	}
+	oldestKept := fmt.Sprintf("@user%d:example.com", total-maxRecentUsers)
+	if !slices.ContainsFunc(users, func(user *AutocompleteUserEntry) bool { return user.UserID == id.UserID(oldestKept) }) {
+		t.Errorf("Expected %s to be kept", oldestKept)
+	} else if slices.ContainsFunc(users, func(user *AutocompleteUserEntry) bool { return user.UserID == "@user0:example.com" }) {
+		t.Error("Expected the least recently seen user to be dropped")
+	}
+}
+
+func TestRoomStore_SearchMembers(t *testing.T) {
+	rs := newTestRoomStore()
+	applyTestState(rs, event.StateMember, "@alice:example.com", `{"membership":"join","displayname":"Alice"}`)
+	applyTestState(rs, event.StateMember, "@malice:example.com", `{"membership":"join","displayname":"Malice"}`)
+	applyTestState(rs, event.StateMember, "@bob:example.com", `{"membership":"invite","displayname":"Bob Alison"}`)
+	applyTestState(rs, event.StateMember, "@alex:example.com", `{"membership":"leave","displayname":"Alex"}`)
+
+	members := rs.SearchMembers("ali", 0)
+	userIDs := make([]id.UserID, len(members))
+	for i, member := range members {
+		userIDs[i] = member.UserID
+	}
+	want := []id.UserID{"@alice:example.com", "@bob:example.com", "@malice:example.com"}
+	if !slices.Equal(userIDs, want) {
+		t.Errorf("SearchMembers(ali) = %v, want %v", userIDs, want)
+	}
+}
+
+func TestGomuksStore_AutocompleteIndex(t *testing.T) {
+	gs := NewStore()
+	gs.UserID = "@me:example.com"
+	const roomID id.RoomID = "!indexed:example.com"
+	bobKey := "@bob:example.com"
+	bobMember := &database.Event{
+		RowID: 1, RoomID: roomID, ID: "$bobmember", Sender: "@bob:example.com", Type: event.StateMember.Type,
+		StateKey: &bobKey, Content: json.RawMessage(`{"membership":"join","displayname":"Bobert"}`),
+	}
+	message := func(rowID database.EventRowID, sender id.UserID) *database.Event {
+		return &database.Event{
+			RowID: rowID, TimelineRowID: database.TimelineRowID(rowID), RoomID: roomID,
+			ID: id.EventID(fmt.Sprintf("$msg%d", rowID)), Sender: sender, Type: event.EventMessage.Type,
+			Timestamp: jsontime.UM(time.Now()), Content: json.RawMessage(`{"msgtype":"m.text","body":"hi"}`),
+		}
+	}
+	bobMessage, myMessage := message(2, "@bob:example.com"), message(3, "@me:example.com")
+	gs.ApplySync(&jsoncmd.SyncComplete{Rooms: map[id.RoomID]*jsoncmd.SyncRoom{roomID: {
+		Meta:     testRoomMeta(roomID, "Original name", ""),
+		Events:   []*database.Event{bobMember, bobMessage, myMessage},
+		State:    map[event.Type]map[string]database.EventRowID{event.StateMember: {bobKey: 1}},
+		Timeline: []database.TimelineRowTuple{{Timeline: 2, Event: 2}, {Timeline: 3, Event: 3}},
+	}}})
+
+	if got := searchedRoomIDs(gs.Autocomplete.SearchRooms("original", 0)); !slices.Equal(got, []id.RoomID{roomID}) {
+		t.Errorf("Expected new room to be indexed, got %v", got)
+	}
+	users := gs.Autocomplete.SearchUsers("", 0)
+	if got := searchedUserIDs(users); !slices.Equal(got, []id.UserID{"@bob:example.com"}) {
+		t.Fatalf("Expected only other senders to be indexed, got %v", got)
+	} else if users[0].Displayname != "Bobert" {
+		t.Errorf("Expected displayname from member event, got %q", users[0].Displayname)
+	}
+
+	renamed := testRoomMeta(roomID, "Renamed", "#renamed:example.com")
+	gs.ApplySync(&jsoncmd.SyncComplete{Rooms: map[id.RoomID]*jsoncmd.SyncRoom{roomID: {Meta: renamed}}})
+	if got := searchedRoomIDs(gs.Autocomplete.SearchRooms("original", 0)); len(got) != 0 {
+		t.Errorf("Expected old room name to be gone after rename, got %v", got)
+	} else if got = searchedRoomIDs(gs.Autocomplete.SearchRooms("renamed", 0)); !slices.Equal(got, []id.RoomID{roomID}) {
+		t.Errorf("Expected renamed room to be indexed, got %v", got)
+	}
+
+	gs.ApplySync(&jsoncmd.SyncComplete{LeftRooms: []id.RoomID{roomID}})
+	if got := searchedRoomIDs(gs.Autocomplete.SearchRooms("", 0)); len(got) != 0 {
+		t.Errorf("Expected left room to be removed from the index, got %v", got)
+	}
+}
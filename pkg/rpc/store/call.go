@@ -0,0 +1,114 @@
+// Copyright (c) 2025 Tulir Asokan
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package store
+
+import (
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/tidwall/gjson"
+	"maunium.net/go/mautrix/event"
+	"maunium.net/go/mautrix/id"
+
+	"go.mau.fi/gomuks/pkg/hicli/database"
+)
+
+var (
+	// StateMSC3401Call is the state event that marks a room as having a call (MSC3401).
+	StateMSC3401Call = event.Type{Type: "org.matrix.msc3401.call", Class: event.StateEventType}
+	// StateMSC3401CallMember is the per-device call membership state event used by Element Call.
+	StateMSC3401CallMember = event.Type{Type: "org.matrix.msc3401.call.member", Class: event.StateEventType}
+)
+
+// RoomTypeMSC3417Call is the room type of dedicated voice/video rooms (MSC3417).
+const RoomTypeMSC3417Call event.RoomType = "org.matrix.msc3417.call"
+
+// CallMember is a device that is currently participating in a call in a room.
+type CallMember struct {
+	UserID   id.UserID
+	DeviceID id.DeviceID
+	Event    *database.Event
+}
+
+// ParseCallMember parses a call member state event. It returns nil if the event doesn't represent an active
+// call membership, i.e. if the content is empty (the device left) or the membership has expired at the given time.
+func ParseCallMember(evt *database.Event, now time.Time) *CallMember {
+	if evt == nil || evt.StateKey == nil {
+		return nil
+	}
+	content := gjson.ParseBytes(evt.GetContent())
+	if !content.IsObject() {
+		return nil
+	}
+	// Legacy events have a list of memberships in a state event keyed by user ID
+	if memberships := content.Get("memberships"); memberships.Exists() {
+		for _, membership := range memberships.Array() {
+			expires := membership.Get("expires_ts")
+			if !expires.Exists() || time.UnixMilli(expires.Int()).After(now) {
+				return &CallMember{
+					UserID:   evt.Sender,
+					DeviceID: id.DeviceID(membership.Get("device_id").Str),
+					Event:    evt,
+				}
+			}
+		}
+		return nil
+	}
+	deviceID := content.Get("device_id")
+	if !deviceID.Exists() {
+		// Empty content means the device has left the call
+		return nil
+	}
+	if expires := content.Get("expires"); expires.Exists() &&
+		evt.Timestamp.Add(time.Duration(expires.Int())*time.Millisecond).Before(now) {
+		return nil
+	}
+	return &CallMember{
+		UserID:   evt.Sender,
+		DeviceID: id.DeviceID(deviceID.Str),
+		Event:    evt,
+	}
+}
+
+// IsCallRoom returns true if the room is a dedicated voice room or has call state.
+func (rs *RoomStore) IsCallRoom() bool {
+	if meta := rs.Meta.Current(); meta.CreationContent != nil && meta.CreationContent.Type == RoomTypeMSC3417Call {
+		return true
+	}
+	rs.lock.RLock()
+	defer rs.lock.RUnlock()
+	return len(rs.state[StateMSC3401Call]) > 0
+}
+
+// GetCallMembers returns the devices currently participating in a call in the room, sorted by user ID.
+func (rs *RoomStore) GetCallMembers() []*CallMember {
+	rs.lock.RLock()
+	defer rs.lock.RUnlock()
+	now := time.Now()
+	members := make([]*CallMember, 0, len(rs.state[StateMSC3401CallMember]))
+	for _, rowID := range rs.state[StateMSC3401CallMember] {
+		if member := ParseCallMember(rs.eventsByRowID[rowID], now); member != nil {
+			members = append(members, member)
+		}
+	}
+	slices.SortFunc(members, func(a, b *CallMember) int {
+		if diff := strings.Compare(a.UserID.String(), b.UserID.String()); diff != 0 {
+			return diff
+		}
+		return strings.Compare(a.DeviceID.String(), b.DeviceID.String())
+	})
+	return members
+}
+
+// CallParticipantCount returns the number of distinct users currently in a call in the room.
+func (rs *RoomStore) CallParticipantCount() int {
+	members := rs.GetCallMembers()
+	return len(slices.CompactFunc(members, func(a, b *CallMember) bool {
+		return a.UserID == b.UserID
+	}))
+}
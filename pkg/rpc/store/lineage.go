@@ -0,0 +1,126 @@
+// Copyright (c) 2025 Tulir Asokan
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package store
+
+import (
+	"slices"
+	"sync"
+	"sync/atomic"
+
+	"maunium.net/go/mautrix/id"
+
+	"go.mau.fi/gomuks/pkg/hicli/database"
+	"go.mau.fi/gomuks/pkg/hicli/jsoncmd"
+)
+
+// LineageSegment is the loaded part of the timeline of one predecessor room.
+type LineageSegment struct {
+	Room *jsoncmd.RoomLineageEntry
+	// Events are the loaded events in chronological order.
+	Events []*database.Event
+
+	hasMore   bool
+	nextBatch string
+}
+
+// LineageTimeline contains messages loaded from the predecessors of a room with the paginate_lineage command.
+// The events are kept separately from the timelines of both the room and its predecessors, so that views can
+// show them before the room's own timeline without affecting the cache of either room.
+type LineageTimeline struct {
+	RoomID     id.RoomID
+	Paginating atomic.Bool
+	// Segments contains the loaded segments, oldest room first.
+	Segments EventDispatcher[[]*LineageSegment]
+
+	lock     sync.RWMutex
+	segments []*LineageSegment
+	next     *jsoncmd.RoomLineageEntry
+	started  bool
+	visited  map[id.RoomID]bool
+}
+
+func NewLineageTimeline(roomID id.RoomID) *LineageTimeline {
+	return &LineageTimeline{
+		RoomID:  roomID,
+		visited: map[id.RoomID]bool{roomID: true},
+	}
+}
+
+// HasMore returns true if there may be more messages to load from the predecessors.
+func (lt *LineageTimeline) HasMore() bool {
+	lt.lock.RLock()
+	defer lt.lock.RUnlock()
+	if !lt.started {
+		return true
+	} else if len(lt.segments) == 0 {
+		return lt.next != nil
+	}
+	oldest := lt.segments[0]
+	return oldest.Room.Error == "" && (oldest.hasMore || lt.next != nil)
+}
+
+// GetPaginationParams returns the parameters for the next paginate_lineage request,
+// or nil if there's nothing more to load.
+func (lt *LineageTimeline) GetPaginationParams(limit int) *jsoncmd.PaginateLineageParams {
+	if !lt.HasMore() {
+		return nil
+	}
+	lt.lock.RLock()
+	defer lt.lock.RUnlock()
+	params := &jsoncmd.PaginateLineageParams{RoomID: lt.RoomID, Limit: limit}
+	if len(lt.segments) > 0 && lt.segments[0].hasMore {
+		oldest := lt.segments[0]
+		params.PredecessorID = oldest.Room.RoomID
+		params.Since = oldest.nextBatch
+		if oldest.Room.Local && len(oldest.Events) > 0 {
+			params.MaxTimelineID = oldest.Events[0].TimelineRowID
+		}
+	} else if lt.next != nil {
+		params.PredecessorID = lt.next.RoomID
+	}
+	return params
+}
+
+// ApplyPagination adds the events from a paginate_lineage response to the start of the composed timeline.
+func (lt *LineageTimeline) ApplyPagination(resp *jsoncmd.PaginateLineageResponse) {
+	lt.lock.Lock()
+	defer lt.lock.Unlock()
+	lt.started = true
+	lt.next = nil
+	if resp.Room == nil {
+		lt.emitLocked()
+		return
+	}
+	// Segments are replaced rather than modified, so that views can compare the emitted slices
+	segment := &LineageSegment{Room: resp.Room, hasMore: resp.HasMore, nextBatch: resp.NextBatch}
+	var existing []*database.Event
+	if len(lt.segments) > 0 && lt.segments[0].Room.RoomID == resp.Room.RoomID {
+		existing = lt.segments[0].Events
+		lt.segments = slices.Clone(lt.segments)
+		lt.segments[0] = segment
+	} else if lt.visited[resp.Room.RoomID] {
+		// Upgrade cycle, stop here
+		lt.emitLocked()
+		return
+	} else {
+		lt.visited[resp.Room.RoomID] = true
+		lt.segments = append([]*LineageSegment{segment}, lt.segments...)
+	}
+	segment.Events = make([]*database.Event, 0, len(resp.Events)+len(existing))
+	for _, evt := range slices.Backward(resp.Events) {
+		segment.Events = append(segment.Events, evt)
+	}
+	segment.Events = append(segment.Events, existing...)
+	if resp.Next != nil && !lt.visited[resp.Next.RoomID] {
+		lt.next = resp.Next
+	}
+	lt.emitLocked()
+}
+
+func (lt *LineageTimeline) emitLocked() {
+	lt.Segments.Emit(lt.segments)
+}
@@ -0,0 +1,113 @@
+// Copyright (c) 2025 Tulir Asokan
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package store
+
+import (
+	"regexp"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"maunium.net/go/mautrix/event"
+	"maunium.net/go/mautrix/id"
+)
+
+var (
+	codeRegex         = regexp.MustCompile("(?s)```.*?(?:```|$)|`[^`\n]*`")
+	markdownLinkRegex = regexp.MustCompile(`\[[^\]]*]\(([^)\s]*)\)`)
+	matrixLinkRegex   = regexp.MustCompile(`(?:https://matrix\.to/#/|matrix:u/)[^\s)>\]"]+`)
+	fullUserIDRegex   = regexp.MustCompile(`^@[a-zA-Z0-9._=/+-]+:[a-zA-Z0-9.-]+(?::[0-9]+)?`)
+)
+
+// isMentionBoundary checks if the text after a possible mention ends the mention.
+func isMentionBoundary(rest string) bool {
+	r, _ := utf8.DecodeRuneInString(rest)
+	return rest == "" || !(unicode.IsLetter(r) || unicode.IsNumber(r) || r == '_')
+}
+
+// FindMentions finds the users and @room mentions in the given markdown text that's about to be sent to the room.
+//
+// Links to users (i.e. completed mention pills) are always mentions. Bare full user IDs, @localparts and
+// @displaynames are only mentions if they match exactly one joined or invited member and are followed by
+// a word boundary, so that e.g. "@Bob" doesn't mention "Bobby". Nothing inside code spans or code blocks
+// is a mention, and @room is only included if the user has the power level to notify the room.
+func (rs *RoomStore) FindMentions(text string) *event.Mentions {
+	mentions := &event.Mentions{}
+	text = codeRegex.ReplaceAllString(text, " ")
+	for _, link := range matrixLinkRegex.FindAllString(text, -1) {
+		uri, err := id.ParseMatrixURIOrMatrixToURL(link)
+		if err == nil && uri.UserID() != "" {
+			mentions.Add(uri.UserID())
+		}
+	}
+	// Drop links entirely so that @ signs in the link text or URL aren't treated as bare mentions
+	text = markdownLinkRegex.ReplaceAllString(text, " ")
+	text = matrixLinkRegex.ReplaceAllString(text, " ")
+
+	var members []*AutocompleteMemberEntry
+	for i := strings.IndexByte(text, '@'); i != -1; i = nextIndexByte(text, i+1, '@') {
+		if i > 0 {
+			prev, _ := utf8.DecodeLastRuneInString(text[:i])
+			if !isMentionBoundary(string(prev)) {
+				// Probably an email address
+				continue
+			}
+		}
+		rest := text[i:]
+		if strings.HasPrefix(rest, "@room") && isMentionBoundary(rest[len("@room"):]) {
+			mentions.Room = true
+			continue
+		} else if userID := fullUserIDRegex.FindString(rest); userID != "" {
+			if member := rs.GetMember(id.UserID(userID)); member != nil &&
+				(member.Membership == event.MembershipJoin || member.Membership == event.MembershipInvite) {
+				mentions.Add(id.UserID(userID))
+			}
+			continue
+		}
+		if members == nil {
+			members = rs.GetMembers()
+		}
+		if userID := matchMemberName(members, rest[1:]); userID != "" {
+			mentions.Add(userID)
+		}
+	}
+	if mentions.Room {
+		pls := rs.GetPowerLevels()
+		mentions.Room = pls.GetUserLevel(rs.parent.UserID) >= pls.Notifications.Room()
+	}
+	return mentions
+}
+
+func nextIndexByte(text string, start int, c byte) int {
+	idx := strings.IndexByte(text[start:], c)
+	if idx == -1 {
+		return -1
+	}
+	return start + idx
+}
+
+// matchMemberName finds the member whose displayname or localpart is at the start of the given text.
+// The longest match wins, and nothing is returned if the longest match is ambiguous.
+func matchMemberName(members []*AutocompleteMemberEntry, text string) (match id.UserID) {
+	var matchLen int
+	var ambiguous bool
+	for _, member := range members {
+		for _, name := range []string{member.Displayname, member.UserID.Localpart()} {
+			if name == "" || len(name) < matchLen || !strings.HasPrefix(text, name) || !isMentionBoundary(text[len(name):]) {
+				continue
+			} else if len(name) > matchLen {
+				match, matchLen, ambiguous = member.UserID, len(name), false
+			} else if match != member.UserID {
+				ambiguous = true
+			}
+		}
+	}
+	if ambiguous {
+		return ""
+	}
+	return match
+}
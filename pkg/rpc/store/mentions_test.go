@@ -0,0 +1,87 @@
+// Copyright (c) 2026 Tulir Asokan
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package store
+
+import (
+	"slices"
+	"testing"
+
+	"maunium.net/go/mautrix/event"
+	"maunium.net/go/mautrix/id"
+)
+
+func newMentionTestRoomStore(canPingRoom bool) *RoomStore {
+	rs := newTestRoomStore()
+	applyTestState(rs, event.StateMember, "@me:example.com", `{"membership":"join","displayname":"Me"}`)
+	applyTestState(rs, event.StateMember, "@bob:example.com", `{"membership":"join","displayname":"Bob"}`)
+	applyTestState(rs, event.StateMember, "@bobby:example.com", `{"membership":"join","displayname":"Bobby"}`)
+	applyTestState(rs, event.StateMember, "@bsmith:example.com", `{"membership":"join","displayname":"Bob Smith"}`)
+	applyTestState(rs, event.StateMember, "@sam1:example.com", `{"membership":"join","displayname":"Sam"}`)
+	applyTestState(rs, event.StateMember, "@sam2:example.com", `{"membership":"join","displayname":"Sam"}`)
+	applyTestState(rs, event.StateMember, "@carol:example.com", `{"membership":"invite","displayname":"Carol"}`)
+	applyTestState(rs, event.StateMember, "@dave:example.com", `{"membership":"leave","displayname":"Dave"}`)
+	myLevel := "0"
+	if canPingRoom {
+		myLevel = "50"
+	}
+	applyTestPowerLevels(rs, `{"users":{"@me:example.com":`+myLevel+`},"notifications":{"room":50}}`)
+	return rs
+}
+
+func TestRoomStore_FindMentions(t *testing.T) {
+	tests := []struct {
+		name      string
+		text      string
+		canPing   bool
+		wantUsers []id.UserID
+		wantRoom  bool
+	}{
+		{"no mentions", "hello world", true, nil, false},
+		{"pill", "hi [Bob](https://matrix.to/#/@bob:example.com)", false, []id.UserID{"@bob:example.com"}, false},
+		{"matrix URI pill", "hi [Bob](matrix:u/bob:example.com)", false, []id.UserID{"@bob:example.com"}, false},
+		{"pill to non-member", "[Eve](https://matrix.to/#/@eve:example.com)", false, []id.UserID{"@eve:example.com"}, false},
+		{"pill text isn't a bare mention", "[@Bobby](https://matrix.to/#/@bob:example.com)", false, []id.UserID{"@bob:example.com"}, false},
+		{"permalink to event", "see https://matrix.to/#/!room:example.com/$event", false, nil, false},
+		{"full user ID", "@bob:example.com: hi", false, []id.UserID{"@bob:example.com"}, false},
+		{"full user ID of non-member", "@eve:example.com hi", false, nil, false},
+		{"full user ID of left member", "@dave:example.com hi", false, nil, false},
+		{"full user ID of invited member", "@carol:example.com hi", false, []id.UserID{"@carol:example.com"}, false},
+		{"localpart", "hey @bobby", false, []id.UserID{"@bobby:example.com"}, false},
+		{"displayname", "@Bob: hi", false, []id.UserID{"@bob:example.com"}, false},
+		{"displayname prefix of another word", "@Bobcat", false, nil, false},
+		{"longest displayname wins", "@Bob Smith hi", false, []id.UserID{"@bsmith:example.com"}, false},
+		{"ambiguous displayname", "@Sam hi", false, nil, false},
+		{"unambiguous localpart of ambiguous name", "@sam1 hi", false, []id.UserID{"@sam1:example.com"}, false},
+		{"left member displayname", "@Dave hi", false, nil, false},
+		{"case sensitive", "@bob smith", false, []id.UserID{"@bob:example.com"}, false},
+		{"email address", "mail bob@example.com", false, nil, false},
+		{"multiple mentions deduplicated", "@Bob @bob [Bob](https://matrix.to/#/@bob:example.com) @Bobby", false, []id.UserID{"@bob:example.com", "@bobby:example.com"}, false},
+		{"code span", "`@Bob` and `[Bob](https://matrix.to/#/@bob:example.com)`", true, nil, false},
+		{"code block", "```\n@Bob @room\n```", true, nil, false},
+		{"unclosed code block", "```\n@Bob", false, nil, false},
+		{"mention after code span", "`code` @Bob", false, []id.UserID{"@bob:example.com"}, false},
+		{"room", "@room wake up", true, nil, true},
+		{"room at end", "hey @room", true, nil, true},
+		{"room without permission", "@room wake up", false, nil, false},
+		{"room prefix of another word", "@roommate", true, nil, false},
+		{"room in code span", "`@room`", true, nil, false},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			rs := newMentionTestRoomStore(test.canPing)
+			mentions := rs.FindMentions(test.text)
+			userIDs := slices.Clone(mentions.UserIDs)
+			slices.Sort(userIDs)
+			if !slices.Equal(userIDs, test.wantUsers) {
+				t.Errorf("FindMentions(%q) users = %v, want %v", test.text, userIDs, test.wantUsers)
+			}
+			if mentions.Room != test.wantRoom {
+				t.Errorf("FindMentions(%q) room = %t, want %t", test.text, mentions.Room, test.wantRoom)
+			}
+		})
+	}
+}
@@ -0,0 +1,59 @@
+// Copyright (c) 2025 Tulir Asokan
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package store
+
+import (
+	"slices"
+	"strings"
+
+	"maunium.net/go/mautrix/id"
+)
+
+// GetViaServers returns a list of servers that are likely to stay in the room, for use in
+// room and event links. The logic matches getViaServers in the web frontend: the user's own
+// server, the server of the first member with elevated power, and the most popular other server.
+func (rs *RoomStore) GetViaServers() []string {
+	ownServerName := rs.parent.UserID.Homeserver()
+	vias := []string{ownServerName}
+	members := slices.Clone(rs.GetMembers())
+	slices.SortFunc(members, func(a, b *AutocompleteMemberEntry) int {
+		return strings.Compare(string(a.UserID), string(b.UserID))
+	})
+	powerLevels := rs.GetPowerLevels()
+	memberCount := make(map[string]int)
+	var powerServer string
+	for _, member := range members {
+		serverName := member.UserID.Homeserver()
+		if serverName == "" || serverName == ownServerName {
+			continue
+		}
+		if powerServer == "" && powerLevels.GetUserLevel(member.UserID) > powerLevels.UsersDefault {
+			powerServer = serverName
+			vias = append(vias, powerServer)
+		}
+		memberCount[serverName]++
+	}
+	var popularServer string
+	for serverName, count := range memberCount {
+		if serverName == powerServer {
+			continue
+		}
+		popularCount := memberCount[popularServer]
+		if count > popularCount || (count == popularCount && serverName < popularServer) {
+			popularServer = serverName
+		}
+	}
+	if popularServer != "" {
+		vias = append(vias, popularServer)
+	}
+	return vias
+}
+
+// GetEventPermalink returns a matrix.to link to the given event in this room.
+func (rs *RoomStore) GetEventPermalink(eventID id.EventID) string {
+	return rs.ID.EventURI(eventID, rs.GetViaServers()...).MatrixToURL()
+}
@@ -0,0 +1,115 @@
+// Copyright (c) 2026 Tulir Asokan
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package store
+
+import (
+	"maunium.net/go/mautrix/event"
+	"maunium.net/go/mautrix/id"
+)
+
+// SendRestriction describes why the current user can't send a type of event to a room.
+// The empty value means sending is allowed.
+type SendRestriction string
+
+const (
+	SendAllowed              SendRestriction = ""
+	SendRestrictedPowerLevel SendRestriction = "power_level"
+	SendRestrictedInvite     SendRestriction = "invite"
+	SendRestrictedNotJoined  SendRestriction = "not_joined"
+	SendRestrictedTombstone  SendRestriction = "tombstone"
+)
+
+// Message returns a human-readable explanation of the restriction for sending messages.
+func (sr SendRestriction) Message() string {
+	switch sr {
+	case SendRestrictedPowerLevel:
+		return "You don't have permission to send messages in this room"
+	case SendRestrictedInvite:
+		return "You haven't accepted the invite yet"
+	case SendRestrictedNotJoined:
+		return "You're not a member of this room"
+	case SendRestrictedTombstone:
+		return "This room has been replaced"
+	default:
+		return ""
+	}
+}
+
+// StateSubsAffectingSend are the state subscription keys that can change the result of GetSendRestriction
+// for the given user. Views should recompute the restriction when any of them is notified.
+func StateSubsAffectingSend(userID id.UserID) []string {
+	return []string{
+		event.StatePowerLevels.Type,
+		event.StateCreate.Type,
+		event.StateTombstone.Type,
+		StateKeySub(event.StateMember, userID.String()),
+	}
+}
+
+// checkSendRestriction is the pure part of GetSendRestriction. A nil member means the membership isn't known,
+// in which case the user is assumed to be joined.
+func checkSendRestriction(
+	userID id.UserID,
+	member *event.MemberEventContent,
+	replacementRoom id.RoomID,
+	pls *event.PowerLevelsEventContent,
+	evtType event.Type,
+) SendRestriction {
+	if member != nil {
+		switch member.Membership {
+		case event.MembershipJoin:
+		case event.MembershipInvite:
+			return SendRestrictedInvite
+		default:
+			return SendRestrictedNotJoined
+		}
+	}
+	if replacementRoom != "" {
+		return SendRestrictedTombstone
+	}
+	if pls.GetUserLevel(userID) < pls.GetEventLevel(evtType) {
+		return SendRestrictedPowerLevel
+	}
+	return SendAllowed
+}
+
+// GetSendRestriction checks whether the current user can send the given event type to the room based on
+// their membership, the room's tombstone and the power levels. Missing state is treated as allowing sending,
+// so that the restriction doesn't flash while the state is loading.
+//
+// In encrypted rooms, the power level of m.room.encrypted is checked instead, as that's what the server sees.
+// Reactions aren't encrypted, so they're always checked as themselves.
+func (rs *RoomStore) GetSendRestriction(evtType event.Type) SendRestriction {
+	if rs.Archived {
+		return SendRestrictedNotJoined
+	}
+	meta := rs.Meta.Current()
+	if meta.EncryptionEvent != nil && evtType.Class == event.MessageEventType &&
+		evtType != event.EventReaction && evtType != event.EventRedaction {
+		evtType = event.EventEncrypted
+	}
+	return checkSendRestriction(
+		rs.parent.UserID,
+		rs.GetMember(rs.parent.UserID),
+		meta.Tombstone.GetReplacementRoom(),
+		rs.GetPowerLevels(),
+		evtType,
+	)
+}
+
+// CanRedact checks whether the current user can redact events sent by the given user.
+// Redacting your own events only requires the power level for sending redactions,
+// while redacting other users' events also requires the redact power level.
+func (rs *RoomStore) CanRedact(sender id.UserID) bool {
+	if rs.GetSendRestriction(event.EventRedaction) != SendAllowed {
+		return false
+	} else if sender == rs.parent.UserID {
+		return true
+	}
+	pls := rs.GetPowerLevels()
+	return pls.GetUserLevel(rs.parent.UserID) >= pls.Redact()
+}
@@ -0,0 +1,167 @@
+// Copyright (c) 2026 Tulir Asokan
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package store
+
+import (
+	"testing"
+
+	"maunium.net/go/mautrix/event"
+	"maunium.net/go/mautrix/id"
+
+	"go.mau.fi/gomuks/pkg/hicli/database"
+)
+
+const (
+	testCreateV11 = `{"creator":"@alice:example.com","room_version":"11"}`
+	testCreateV12 = `{"room_version":"12","additional_creators":["@me:example.com"]}`
+)
+
+type permissionTestRoom struct {
+	create      string
+	powerLevels string
+	membership  event.Membership
+	encrypted   bool
+	tombstone   bool
+	archived    bool
+}
+
+func (ptr permissionTestRoom) build() *RoomStore {
+	meta := &database.Room{ID: testRoomID}
+	if ptr.encrypted {
+		meta.EncryptionEvent = &event.EncryptionEventContent{Algorithm: id.AlgorithmMegolmV1}
+	}
+	if ptr.tombstone {
+		meta.Tombstone = &event.TombstoneEventContent{ReplacementRoom: "!new:example.com"}
+	}
+	gs := NewStore()
+	gs.UserID = "@me:example.com"
+	rs := NewRoomStore(gs, meta)
+	rs.Archived = ptr.archived
+	if ptr.create != "" {
+		applyTestState(rs, event.StateCreate, "", ptr.create)
+	}
+	if ptr.powerLevels != "" {
+		applyTestState(rs, event.StatePowerLevels, "", ptr.powerLevels)
+	}
+	if ptr.membership != "" {
+		applyTestState(rs, event.StateMember, "@me:example.com", `{"membership":"`+string(ptr.membership)+`"}`)
+	}
+	return rs
+}
+
+func TestRoomStore_GetSendRestriction(t *testing.T) {
+	const announcement = `{"events_default":50,"users":{"@alice:example.com":100}}`
+	tests := []struct {
+		name    string
+		room    permissionTestRoom
+		evtType event.Type
+		want    SendRestriction
+	}{
+		{"no state", permissionTestRoom{}, event.EventMessage, SendAllowed},
+		{"no power levels", permissionTestRoom{create: testCreateV11, membership: event.MembershipJoin}, event.EventMessage, SendAllowed},
+		{"power levels without create event", permissionTestRoom{powerLevels: announcement}, event.EventMessage, SendAllowed},
+		{"default power levels", permissionTestRoom{create: testCreateV11, powerLevels: `{}`}, event.EventMessage, SendAllowed},
+		{"announcement room", permissionTestRoom{create: testCreateV11, powerLevels: announcement}, event.EventMessage, SendRestrictedPowerLevel},
+		{
+			"announcement room as moderator",
+			permissionTestRoom{create: testCreateV11, powerLevels: `{"events_default":50,"users":{"@me:example.com":50}}`},
+			event.EventMessage, SendAllowed,
+		},
+		{
+			"announcement room with raised users_default",
+			permissionTestRoom{create: testCreateV11, powerLevels: `{"events_default":50,"users_default":50}`},
+			event.EventMessage, SendAllowed,
+		},
+		{
+			"event type override above user level",
+			permissionTestRoom{create: testCreateV11, powerLevels: `{"events":{"m.room.message":10}}`},
+			event.EventMessage, SendRestrictedPowerLevel,
+		},
+		{
+			"event type override below events_default",
+			permissionTestRoom{create: testCreateV11, powerLevels: `{"events_default":50,"events":{"m.reaction":0}}`},
+			event.EventReaction, SendAllowed,
+		},
+		{
+			"state event uses state_default",
+			permissionTestRoom{create: testCreateV11, powerLevels: `{"state_default":50}`},
+			event.StateRoomName, SendRestrictedPowerLevel,
+		},
+		{
+			"encrypted room checks m.room.encrypted",
+			permissionTestRoom{create: testCreateV11, powerLevels: `{"events":{"m.room.encrypted":50}}`, encrypted: true},
+			event.EventMessage, SendRestrictedPowerLevel,
+		},
+		{
+			"encrypted room ignores m.room.message level",
+			permissionTestRoom{create: testCreateV11, powerLevels: `{"events":{"m.room.message":50}}`, encrypted: true},
+			event.EventMessage, SendAllowed,
+		},
+		{
+			"encrypted room checks reactions as themselves",
+			permissionTestRoom{create: testCreateV11, powerLevels: `{"events":{"m.reaction":50}}`, encrypted: true},
+			event.EventReaction, SendRestrictedPowerLevel,
+		},
+		{"v12 creator has infinite power", permissionTestRoom{create: testCreateV12, powerLevels: `{"events_default":100}`}, event.EventMessage, SendAllowed},
+		{
+			"v12 non-creator",
+			permissionTestRoom{create: `{"room_version":"12"}`, powerLevels: `{"events_default":100}`},
+			event.EventMessage, SendRestrictedPowerLevel,
+		},
+		{"invited", permissionTestRoom{create: testCreateV11, powerLevels: `{}`, membership: event.MembershipInvite}, event.EventMessage, SendRestrictedInvite},
+		{"left", permissionTestRoom{create: testCreateV11, powerLevels: `{}`, membership: event.MembershipLeave}, event.EventMessage, SendRestrictedNotJoined},
+		{"banned", permissionTestRoom{create: testCreateV11, powerLevels: `{}`, membership: event.MembershipBan}, event.EventMessage, SendRestrictedNotJoined},
+		{"archived", permissionTestRoom{create: testCreateV11, powerLevels: `{}`, archived: true}, event.EventMessage, SendRestrictedNotJoined},
+		{"tombstone", permissionTestRoom{create: testCreateV11, powerLevels: `{}`, tombstone: true}, event.EventMessage, SendRestrictedTombstone},
+		{
+			"invite takes precedence over tombstone",
+			permissionTestRoom{create: testCreateV11, membership: event.MembershipInvite, tombstone: true},
+			event.EventMessage, SendRestrictedInvite,
+		},
+		{
+			"tombstone takes precedence over power levels",
+			permissionTestRoom{create: testCreateV11, powerLevels: announcement, tombstone: true},
+			event.EventMessage, SendRestrictedTombstone,
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			rs := test.room.build()
+			if got := rs.GetSendRestriction(test.evtType); got != test.want {
+				t.Errorf("GetSendRestriction(%s) = %q, want %q", test.evtType.Type, got, test.want)
+			}
+		})
+	}
+}
+
+func TestRoomStore_CanRedact(t *testing.T) {
+	tests := []struct {
+		name        string
+		powerLevels string
+		sender      id.UserID
+		want        bool
+	}{
+		{"own event", `{}`, "@me:example.com", true},
+		{"other user's event", `{}`, "@alice:example.com", false},
+		{"other user's event as moderator", `{"users":{"@me:example.com":50}}`, "@alice:example.com", true},
+		{"other user's event with lowered redact level", `{"redact":0}`, "@alice:example.com", true},
+		{"own event without permission to send redactions", `{"events":{"m.room.redaction":50}}`, "@me:example.com", false},
+		{
+			"other user's event without permission to send redactions",
+			`{"redact":0,"events":{"m.room.redaction":50}}`,
+			"@alice:example.com", false,
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			rs := permissionTestRoom{create: testCreateV11, powerLevels: test.powerLevels}.build()
+			if got := rs.CanRedact(test.sender); got != test.want {
+				t.Errorf("CanRedact(%s) = %t, want %t", test.sender, got, test.want)
+			}
+		})
+	}
+}
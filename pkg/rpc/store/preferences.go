@@ -42,6 +42,9 @@ type Preferences struct {
 	Favicon                 string `json:"favicon,omitempty"`
 	LowBandwidth            bool   `json:"low_bandwidth,omitempty"`
 	WebPush                 bool   `json:"web_push,omitempty"`
+	HideRoom                bool   `json:"hide_room,omitempty"`
+	NotificationSound       string `json:"notification_sound,omitempty"`
+	ConfirmSensitiveContent bool   `json:"confirm_sensitive_content,omitempty"`
 }
 
 var DefaultPreferences = Preferences{
@@ -59,6 +62,7 @@ var DefaultPreferences = Preferences{
 	ShowDateSeparators:      true,
 	ShowRoomEmojiPacks:      true,
 	UploadDialog:            true,
+	ConfirmSensitiveContent: true,
 }
 
 func init() {
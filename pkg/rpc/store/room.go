@@ -15,6 +15,7 @@ import (
 	"strings"
 	"sync"
 	"sync/atomic"
+	"time"
 
 	badGlobalLog "github.com/rs/zerolog/log"
 	"github.com/tidwall/gjson"
@@ -26,6 +27,7 @@ import (
 	"go.mau.fi/gomuks/pkg/hicli/cmdspec"
 	"go.mau.fi/gomuks/pkg/hicli/database"
 	"go.mau.fi/gomuks/pkg/hicli/jsoncmd"
+	"go.mau.fi/gomuks/pkg/serveracl"
 )
 
 type AutocompleteMemberEntry struct {
@@ -42,16 +44,21 @@ func StateKeySub(evtType event.Type, stateKey string) string {
 }
 
 type RoomStore struct {
-	parent     *GomuksStore
-	lock       sync.RWMutex
-	ID         id.RoomID
-	Meta       EventDispatcher[*database.Room]
-	Hidden     bool
+	parent *GomuksStore
+	lock   sync.RWMutex
+	ID     id.RoomID
+	Meta   EventDispatcher[*database.Room]
+	// Hidden is true for rooms that are left out of the room list, see GomuksStore.shouldHideRoom.
+	Hidden bool
+	// Archived is true for rooms the user has left, which are only opened for reading the local history.
+	Archived   bool
 	Paginating atomic.Bool
 
 	TimelineCache     EventDispatcher[*[]*database.Event]
 	accountData       map[event.Type]*database.AccountData
 	timeline          []database.TimelineRowTuple
+	timelineEvents    exmaps.Set[database.EventRowID]
+	gaps              map[database.TimelineRowID]*database.TimelineGap
 	hasMoreHistory    bool
 	editTargets       []database.EventRowID
 	eventsByRowID     map[database.EventRowID]*database.Event
@@ -66,11 +73,19 @@ type RoomStore struct {
 	FullMembersLoaded atomic.Bool
 	requestedMembers  exmaps.Set[id.UserID]
 	pendingEvents     []database.EventRowID
+	failedEvents      exmaps.Set[database.EventRowID]
 	membersCache      []*AutocompleteMemberEntry
 	botCommandCache   []*WrappedCommand
+	serverACLCache    *serveracl.ACL
+	serverACLLoaded   bool
+	previewText       atomic.Pointer[string]
 	Typing            EventDispatcher[[]id.UserID]
+	SendCooldown      EventDispatcher[time.Time]
 	PreferenceCache   EventDispatcher[*Preferences]
 	lastMarkedRead    database.EventRowID
+	// ReadByOthers is the timeline row ID of the newest event that another user has sent a read receipt for.
+	ReadByOthers EventDispatcher[database.TimelineRowID]
+	receipts     map[id.UserID]*database.Receipt
 }
 
 type WrappedCommand struct {
@@ -85,11 +100,15 @@ func NewRoomStore(parent *GomuksStore, meta *database.Room) *RoomStore {
 		Meta:             *NewEventDispatcherWithValue(meta),
 		accountData:      make(map[event.Type]*database.AccountData),
 		state:            make(map[event.Type]map[string]database.EventRowID),
+		timelineEvents:   make(exmaps.Set[database.EventRowID]),
+		gaps:             make(map[database.TimelineRowID]*database.TimelineGap),
 		hasMoreHistory:   true,
 		eventsByRowID:    make(map[database.EventRowID]*database.Event),
 		eventsByID:       make(map[id.EventID]*database.Event),
 		requestedEvents:  make(exmaps.Set[database.EventRowID]),
 		requestedMembers: make(exmaps.Set[id.UserID]),
+		failedEvents:     make(exmaps.Set[database.EventRowID]),
+		receipts:         make(map[id.UserID]*database.Receipt),
 	}
 }
 
@@ -133,9 +152,55 @@ func (rs *RoomStore) notifyTimelineWatchers() {
 	rs.editTargets = ownMessages
 }
 
+// dedupNewTimeline removes new entries referencing an event that is already in the timeline, which can
+// happen if the same event arrives via both sync and pagination. The existing entry is kept, like in the
+// backend. The events of the remaining entries are added to timelineEvents, so the whole timeline never
+// needs to be scanned. The input slice is not modified.
+func (rs *RoomStore) dedupNewTimeline(entries []database.TimelineRowTuple) []database.TimelineRowTuple {
+	var deduped []database.TimelineRowTuple
+	for i, tuple := range entries {
+		if rs.timelineEvents.Add(tuple.Event) {
+			if deduped != nil {
+				deduped = append(deduped, tuple)
+			}
+		} else if deduped == nil {
+			deduped = make([]database.TimelineRowTuple, i, len(entries)-1)
+			copy(deduped, entries[:i])
+		}
+	}
+	if deduped == nil {
+		return entries
+	}
+	return deduped
+}
+
+// mergeTimeline merges sorted new timeline entries into the existing timeline. Existing entries for
+// the same events are dropped, as the backend may have moved them to make room for the new ones.
+func mergeTimeline(timeline, newEntries []database.TimelineRowTuple) []database.TimelineRowTuple {
+	moved := make(map[database.EventRowID]struct{}, len(newEntries))
+	for _, tuple := range newEntries {
+		moved[tuple.Event] = struct{}{}
+	}
+	merged := make([]database.TimelineRowTuple, 0, len(timeline)+len(newEntries))
+	for _, tuple := range timeline {
+		if _, ok := moved[tuple.Event]; ok {
+			continue
+		}
+		for len(newEntries) > 0 && newEntries[0].Timeline < tuple.Timeline {
+			merged = append(merged, newEntries[0])
+			newEntries = newEntries[1:]
+		}
+		merged = append(merged, tuple)
+	}
+	return append(merged, newEntries...)
+}
+
 func (rs *RoomStore) ApplySync(sync *jsoncmd.SyncRoom) {
 	rs.lock.Lock()
 	defer rs.lock.Unlock()
+	if rs.Meta.Current().PreviewEventRowID != sync.Meta.PreviewEventRowID {
+		rs.invalidatePreview()
+	}
 	if !rs.Meta.Current().VisibleMetaIsEqual(sync.Meta) {
 		rs.Meta.Emit(sync.Meta)
 	} else {
@@ -165,20 +230,33 @@ func (rs *RoomStore) ApplySync(sync *jsoncmd.SyncRoom) {
 		rs.invalidateStateCaches(evtType, slices.Collect(maps.Keys(stateMap))...)
 	}
 	if sync.Reset {
-		rs.timeline = sync.Timeline
+		clear(rs.timelineEvents)
+		rs.timeline = rs.dedupNewTimeline(sync.Timeline)
+		rs.hasMoreHistory = true
 		rs.pendingEvents = rs.pendingEvents[:0]
+		clear(rs.failedEvents)
+		clear(rs.gaps)
 	} else {
-		rs.timeline = append(rs.timeline, sync.Timeline...)
+		rs.timeline = append(rs.timeline, rs.dedupNewTimeline(sync.Timeline)...)
+	}
+	for _, gap := range sync.Gaps {
+		rs.gaps[gap.TimelineRowID] = gap
 	}
+	rs.indexTimelineSenders(sync.Timeline)
 	if sync.Reset || len(sync.Timeline) > 0 {
 		rs.notifyTimelineWatchers()
 	}
+	rs.applyReceipts(sync.Receipts, true)
 }
 
 func (rs *RoomStore) ApplyTyping(typing []id.UserID) {
 	rs.Typing.Emit(typing)
 }
 
+func (rs *RoomStore) ApplySendCooldown(cooldown *jsoncmd.SendCooldown) {
+	rs.SendCooldown.Emit(cooldown.Until.Time)
+}
+
 func (rs *RoomStore) ApplyPending(evt *database.Event) {
 	rs.lock.Lock()
 	defer rs.lock.Unlock()
@@ -196,6 +274,7 @@ func (rs *RoomStore) ApplyPending(evt *database.Event) {
 			content.FormattedBody = evt.LocalContent.SanitizedHTML
 			content.Format = event.FormatHTML
 		}
+		rs.timelineEvents.Add(evt.RowID)
 		rs.timeline = append(rs.timeline, database.TimelineRowTuple{
 			Timeline: evt.TimelineRowID,
 			Event:    evt.RowID,
@@ -211,9 +290,56 @@ func (rs *RoomStore) ApplySendComplete(evt *database.Event) {
 		return
 	}
 	rs.applyEvent(evt, true)
+	if evt.SendError == database.SendErrorDiscarded {
+		if pendingIdx := slices.Index(rs.pendingEvents, evt.RowID); pendingIdx != -1 {
+			rs.pendingEvents = slices.Delete(rs.pendingEvents, pendingIdx, pendingIdx+1)
+		}
+	}
 	rs.notifyTimelineWatchers()
 }
 
+// ApplySendQueueUpdate updates the queue positions of pending events.
+func (rs *RoomStore) ApplySendQueueUpdate(update *jsoncmd.SendQueueUpdate) {
+	rs.lock.Lock()
+	defer rs.lock.Unlock()
+	changed := false
+	for _, rowID := range rs.pendingEvents {
+		evt, ok := rs.eventsByRowID[rowID]
+		if !ok || !evt.Pending {
+			continue
+		}
+		position, ok := update.Positions[evt.TransactionID]
+		if !ok || evt.QueuePosition == position {
+			continue
+		}
+		// Events are shared with frontends, so they're replaced rather than modified in place
+		updated := *evt
+		updated.QueuePosition = position
+		rs.eventsByRowID[rowID] = &updated
+		rs.eventsByID[updated.ID] = &updated
+		rs.EventSubs.Notify(updated.ID)
+		changed = true
+	}
+	if changed {
+		rs.notifyTimelineWatchers()
+	}
+}
+
+// HasMoreHistory returns false if pagination has reached the beginning of the room,
+// i.e. the timeline contains the oldest available event.
+func (rs *RoomStore) HasMoreHistory() bool {
+	rs.lock.RLock()
+	defer rs.lock.RUnlock()
+	return rs.hasMoreHistory
+}
+
+// CachedEventCount returns the number of events currently held in memory for the room.
+func (rs *RoomStore) CachedEventCount() int {
+	rs.lock.RLock()
+	defer rs.lock.RUnlock()
+	return len(rs.eventsByRowID)
+}
+
 func (rs *RoomStore) ApplyPagination(resp *jsoncmd.PaginationResponse) {
 	rs.lock.Lock()
 	defer rs.lock.Unlock()
@@ -231,8 +357,74 @@ func (rs *RoomStore) ApplyPagination(resp *jsoncmd.PaginationResponse) {
 			rs.applyEvent(evt, false)
 		}
 	}
-	rs.timeline = append(newTimeline, rs.timeline...)
+	for _, gap := range resp.Gaps {
+		rs.gaps[gap.TimelineRowID] = gap
+	}
+	rs.timeline = append(rs.dedupNewTimeline(newTimeline), rs.timeline...)
 	rs.notifyTimelineWatchers()
+	rs.applyReceipts(resp.Receipts, false)
+}
+
+func (rs *RoomStore) ApplyGapFill(gapRowID database.TimelineRowID, resp *jsoncmd.FillGapResponse) {
+	rs.lock.Lock()
+	defer rs.lock.Unlock()
+	for _, evt := range resp.Events {
+		rs.applyEvent(evt, false)
+	}
+	if len(resp.Timeline) > 0 {
+		rs.timeline = mergeTimeline(rs.timeline, resp.Timeline)
+		for _, tuple := range resp.Timeline {
+			rs.timelineEvents.Add(tuple.Event)
+		}
+	}
+	for gap := range rs.gaps {
+		if gap >= gapRowID {
+			delete(rs.gaps, gap)
+		}
+	}
+	for _, gap := range resp.Gaps {
+		rs.gaps[gap.TimelineRowID] = gap
+	}
+	rs.notifyTimelineWatchers()
+}
+
+// applyReceipts stores the latest read receipts of other users and updates ReadByOthers.
+// Receipts from pagination are older than the ones from sync, so they only fill in users that aren't known yet.
+func (rs *RoomStore) applyReceipts(receipts map[id.EventID][]*database.Receipt, override bool) {
+	for _, evtReceipts := range receipts {
+		for _, receipt := range evtReceipts {
+			if receipt.UserID == rs.parent.UserID ||
+				receipt.ReceiptType != event.ReceiptTypeRead ||
+				(receipt.ThreadID != "" && receipt.ThreadID != event.ReadReceiptThreadMain) {
+				continue
+			} else if _, exists := rs.receipts[receipt.UserID]; exists && !override {
+				continue
+			}
+			rs.receipts[receipt.UserID] = receipt
+		}
+	}
+	var readUpTo database.TimelineRowID
+	for _, receipt := range rs.receipts {
+		evt, ok := rs.eventsByID[receipt.EventID]
+		if ok && evt.TimelineRowID > readUpTo {
+			readUpTo = evt.TimelineRowID
+		}
+	}
+	if readUpTo != rs.ReadByOthers.Current() {
+		rs.ReadByOthers.Emit(readUpTo)
+	}
+}
+
+// IsReadByOthers returns true if a read receipt from another user covers the given event.
+func (rs *RoomStore) IsReadByOthers(evt *database.Event) bool {
+	return evt.TimelineRowID > 0 && evt.TimelineRowID <= rs.ReadByOthers.Current()
+}
+
+// GetGapBefore returns the gap right before the given timeline row ID, or nil if there are no missing events there.
+func (rs *RoomStore) GetGapBefore(rowID database.TimelineRowID) *database.TimelineGap {
+	rs.lock.RLock()
+	defer rs.lock.RUnlock()
+	return rs.gaps[rowID]
 }
 
 func (rs *RoomStore) ApplyDecrypted(resp *jsoncmd.EventsDecrypted) {
@@ -251,12 +443,20 @@ func (rs *RoomStore) ApplyDecrypted(resp *jsoncmd.EventsDecrypted) {
 		rs.notifyTimelineWatchers()
 	}
 	if resp.PreviewEventRowID != 0 {
+		rs.invalidatePreview()
 		meta := rs.Meta.Current()
 		meta.PreviewEventRowID = resp.PreviewEventRowID
 		rs.Meta.Emit(meta)
 	}
 }
 
+// ApplyFetchedEvent stores an event that was fetched separately, such as a room list preview event.
+func (rs *RoomStore) ApplyFetchedEvent(evt *database.Event) {
+	rs.lock.Lock()
+	defer rs.lock.Unlock()
+	rs.applyEvent(evt, false)
+}
+
 func (rs *RoomStore) ApplyState(evt *database.Event) {
 	evtType := event.Type{Type: evt.Type, Class: event.StateEventType}
 	rs.applyEvent(evt, false)
@@ -277,11 +477,15 @@ func (rs *RoomStore) invalidateStateCaches(evtType event.Type, stateKeys ...stri
 		for _, key := range stateKeys {
 			rs.requestedMembers.Remove(id.UserID(key))
 		}
+		// The preview includes the sender's name
+		rs.invalidatePreview()
 		fallthrough
 	case event.StatePowerLevels:
 		rs.membersCache = nil
 	case event.StateMSC4391BotCommand:
 		rs.botCommandCache = nil
+	case event.StateServerACL:
+		rs.serverACLLoaded = false
 	}
 	rs.StateSubs.Notify(evtType.Type)
 	for _, stateKey := range stateKeys {
@@ -307,6 +511,7 @@ func (rs *RoomStore) ApplyFullState(events []*database.Event, omitMembers bool)
 		rs.membersCache = nil
 	}
 	rs.botCommandCache = nil
+	rs.serverACLLoaded = false
 	rs.state = newStateMap
 	rs.StateLoaded.Store(true)
 	if !omitMembers {
@@ -336,9 +541,17 @@ func (rs *RoomStore) applyEvent(evt *database.Event, pending bool) {
 			rs.EventSubs.Notify(editTarget.ID)
 		}
 	}
+	if rs.previewAffectedBy(evt) {
+		rs.invalidatePreview()
+	}
 	rs.eventsByRowID[evt.RowID] = evt
 	rs.eventsByID[evt.ID] = evt
 	rs.requestedEvents.Remove(evt.RowID)
+	if evt.SendFailed() {
+		rs.failedEvents.Add(evt.RowID)
+	} else {
+		rs.failedEvents.Remove(evt.RowID)
+	}
 	if !pending {
 		if pendingIdx := slices.Index(rs.pendingEvents, evt.RowID); pendingIdx != -1 {
 			rs.pendingEvents = slices.Delete(rs.pendingEvents, pendingIdx, pendingIdx+1)
@@ -347,11 +560,6 @@ func (rs *RoomStore) applyEvent(evt *database.Event, pending bool) {
 	rs.EventSubs.Notify(evt.ID)
 }
 
-func toSearchableString(s string) string {
-	// TODO
-	return s
-}
-
 func (rs *RoomStore) fillMembersCache() {
 	memberEvtIDs, ok := rs.state[event.StateMember]
 	if !ok {
@@ -375,7 +583,7 @@ func (rs *RoomStore) fillMembersCache() {
 			AvatarURL:    avatarURL,
 			Event:        evt,
 			Membership:   membership,
-			SearchString: toSearchableString(displayName + stateKey[1:]),
+			SearchString: toSearchableString(displayName + " " + stateKey),
 		})
 	}
 	rs.membersCache = entries
@@ -449,12 +657,78 @@ func (rs *RoomStore) GetBotCommands() []*WrappedCommand {
 	return cache
 }
 
+func (rs *RoomStore) fillServerACLCache() {
+	rs.serverACLCache = nil
+	rowID, ok := rs.state[event.StateServerACL][""]
+	if !ok {
+		rs.serverACLLoaded = true
+		return
+	}
+	evt, ok := rs.eventsByRowID[rowID]
+	if !ok {
+		// The event hasn't been fetched yet, try again next time
+		return
+	}
+	// allow_ip_literals defaults to true if it's not specified
+	content := event.ServerACLEventContent{AllowIPLiterals: true}
+	if evt.RedactedBy == "" && json.Unmarshal(evt.Content, &content) == nil {
+		rs.serverACLCache = serveracl.Compile(&content)
+	}
+	rs.serverACLLoaded = true
+}
+
+// GetServerACL returns the compiled m.room.server_acl of the room. If the room doesn't have an ACL,
+// the returned value is nil, which allows all servers.
+func (rs *RoomStore) GetServerACL() *serveracl.ACL {
+	rs.lock.RLock()
+	acl, loaded := rs.serverACLCache, rs.serverACLLoaded
+	rs.lock.RUnlock()
+	if !loaded {
+		rs.lock.Lock()
+		defer rs.lock.Unlock()
+		if !rs.serverACLLoaded {
+			rs.fillServerACLCache()
+		}
+		acl = rs.serverACLCache
+	}
+	return acl
+}
+
+// IsServerDenied returns true if the server of the given user is denied by the server ACL of the room.
+// Users on denied servers can't see new events sent to the room.
+func (rs *RoomStore) IsServerDenied(userID id.UserID) bool {
+	return !rs.GetServerACL().AllowsUser(userID)
+}
+
 func (rs *RoomStore) GetEventByRowID(rowID database.EventRowID) *database.Event {
 	rs.lock.RLock()
 	defer rs.lock.RUnlock()
 	return rs.eventsByRowID[rowID]
 }
 
+// FailedEventCount returns the number of outgoing events in the room that failed to send.
+func (rs *RoomStore) FailedEventCount() int {
+	rs.lock.RLock()
+	defer rs.lock.RUnlock()
+	return len(rs.failedEvents)
+}
+
+// GetFailedEvents returns the outgoing events in the room that failed to send, oldest first.
+func (rs *RoomStore) GetFailedEvents() []*database.Event {
+	rs.lock.RLock()
+	defer rs.lock.RUnlock()
+	events := make([]*database.Event, 0, len(rs.failedEvents))
+	for rowID := range rs.failedEvents {
+		if evt, ok := rs.eventsByRowID[rowID]; ok {
+			events = append(events, evt)
+		}
+	}
+	slices.SortFunc(events, func(a, b *database.Event) int {
+		return cmp.Or(a.Timestamp.Compare(b.Timestamp.Time), cmp.Compare(a.RowID, b.RowID))
+	})
+	return events
+}
+
 func (rs *RoomStore) GetEventByID(evtID id.EventID) *database.Event {
 	if evtID == "" {
 		return nil
@@ -464,6 +738,24 @@ func (rs *RoomStore) GetEventByID(evtID id.EventID) *database.Event {
 	return rs.eventsByID[evtID]
 }
 
+// GetMyReaction returns the ID of the current user's reaction to the given event with the given key,
+// or an empty string if the user hasn't reacted with that key or the event isn't loaded.
+func (rs *RoomStore) GetMyReaction(evtID id.EventID, key string) id.EventID {
+	evt := rs.GetEventByID(evtID)
+	if evt == nil {
+		return ""
+	}
+	rs.lock.RLock()
+	defer rs.lock.RUnlock()
+	return evt.ReactionSenders[key][rs.parent.UserID]
+}
+
+func (rs *RoomStore) GetAccountData(evtType event.Type) *database.AccountData {
+	rs.lock.RLock()
+	defer rs.lock.RUnlock()
+	return rs.accountData[evtType]
+}
+
 func (rs *RoomStore) GetStateEvent(evtType event.Type, stateKey string) *database.Event {
 	rs.lock.RLock()
 	defer rs.lock.RUnlock()
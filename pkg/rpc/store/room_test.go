@@ -0,0 +1,257 @@
+// Copyright (c) 2026 Tulir Asokan
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package store
+
+import (
+	"encoding/json"
+	"fmt"
+	"slices"
+	"testing"
+
+	"maunium.net/go/mautrix/event"
+	"maunium.net/go/mautrix/id"
+
+	"go.mau.fi/gomuks/pkg/hicli/database"
+	"go.mau.fi/gomuks/pkg/hicli/jsoncmd"
+)
+
+const testRoomID id.RoomID = "!room:example.com"
+
+func newTestRoomStore() *RoomStore {
+	gs := NewStore()
+	gs.UserID = "@me:example.com"
+	return NewRoomStore(gs, &database.Room{ID: testRoomID})
+}
+
+func testMessage(rowID database.EventRowID, timelineRowID database.TimelineRowID) *database.Event {
+	return &database.Event{
+		RowID:         rowID,
+		TimelineRowID: timelineRowID,
+		RoomID:        testRoomID,
+		ID:            id.EventID("$" + string(rune('a'+rowID-1))),
+		Sender:        "@alice:example.com",
+		Type:          event.EventMessage.Type,
+		Content:       json.RawMessage(`{"msgtype":"m.text","body":"hello"}`),
+	}
+}
+
+func testSync(events ...*database.Event) *jsoncmd.SyncRoom {
+	timeline := make([]database.TimelineRowTuple, len(events))
+	for i, evt := range events {
+		timeline[i] = database.TimelineRowTuple{Timeline: evt.TimelineRowID, Event: evt.RowID}
+	}
+	return &jsoncmd.SyncRoom{
+		Meta:     &database.Room{ID: testRoomID},
+		Timeline: timeline,
+		Events:   events,
+	}
+}
+
+// applyTestState adds a state event with the given content to the room store.
+func applyTestState(rs *RoomStore, evtType event.Type, stateKey, content string) {
+	rowID := database.EventRowID(len(rs.eventsByRowID) + 1)
+	rs.ApplyState(&database.Event{
+		RowID:    rowID,
+		RoomID:   rs.ID,
+		ID:       id.EventID(fmt.Sprintf("$state%d", rowID)),
+		Sender:   "@alice:example.com",
+		Type:     evtType.Type,
+		StateKey: &stateKey,
+		Content:  json.RawMessage(content),
+	})
+}
+
+// applyTestPowerLevels adds a v11 create event and the given power levels to the room store.
+func applyTestPowerLevels(rs *RoomStore, content string) {
+	applyTestState(rs, event.StateCreate, "", `{"creator":"@alice:example.com","room_version":"11"}`)
+	applyTestState(rs, event.StatePowerLevels, "", content)
+}
+
+// renderedTimeline returns the event row IDs in the timeline cache, which is what frontends render.
+func renderedTimeline(rs *RoomStore) []database.EventRowID {
+	cache := *rs.TimelineCache.Current()
+	rowIDs := make([]database.EventRowID, len(cache))
+	for i, evt := range cache {
+		rowIDs[i] = evt.RowID
+	}
+	return rowIDs
+}
+
+func TestRoomStore_DedupNewTimeline(t *testing.T) {
+	tuple := func(timeline database.TimelineRowID, evt database.EventRowID) database.TimelineRowTuple {
+		return database.TimelineRowTuple{Timeline: timeline, Event: evt}
+	}
+	tests := []struct {
+		name     string
+		existing []database.EventRowID
+		input    []database.TimelineRowTuple
+		want     []database.TimelineRowTuple
+	}{
+		{"empty", nil, nil, nil},
+		{"no duplicates", []database.EventRowID{9}, []database.TimelineRowTuple{tuple(1, 10), tuple(2, 11)}, []database.TimelineRowTuple{tuple(1, 10), tuple(2, 11)}},
+		{
+			"duplicate within input",
+			nil,
+			[]database.TimelineRowTuple{tuple(1, 10), tuple(2, 11), tuple(3, 10)},
+			[]database.TimelineRowTuple{tuple(1, 10), tuple(2, 11)},
+		},
+		{
+			"existing entry kept",
+			[]database.EventRowID{10},
+			[]database.TimelineRowTuple{tuple(-3, 10), tuple(6, 11)},
+			[]database.TimelineRowTuple{tuple(6, 11)},
+		},
+		{
+			"multiple duplicates",
+			[]database.EventRowID{10, 11},
+			[]database.TimelineRowTuple{tuple(-2, 11), tuple(-1, 10), tuple(3, 12), tuple(4, 12)},
+			[]database.TimelineRowTuple{tuple(3, 12)},
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			rs := newTestRoomStore()
+			for _, evtRowID := range test.existing {
+				rs.timelineEvents.Add(evtRowID)
+			}
+			input := slices.Clone(test.input)
+			got := rs.dedupNewTimeline(input)
+			if !slices.Equal(got, test.want) {
+				t.Errorf("dedupNewTimeline(%v) = %v, want %v", test.input, got, test.want)
+			} else if !slices.Equal(input, test.input) {
+				t.Errorf("dedupNewTimeline modified its input: %v", input)
+			}
+			for _, tuple := range test.input {
+				if !rs.timelineEvents.Has(tuple.Event) {
+					t.Errorf("Event %d wasn't added to the timeline event set", tuple.Event)
+				}
+			}
+		})
+	}
+}
+
+func TestRoomStore_SyncPaginationOverlap(t *testing.T) {
+	rs := newTestRoomStore()
+	rs.ApplySync(testSync(testMessage(3, 1), testMessage(4, 2)))
+	// Pagination returns events newest first. The newest one was already received via sync,
+	// e.g. because the pagination token was from before the sync.
+	rs.ApplyPagination(&jsoncmd.PaginationResponse{
+		Events:  []*database.Event{testMessage(3, -2), testMessage(2, -3), testMessage(1, -4)},
+		HasMore: true,
+	})
+	want := []database.EventRowID{1, 2, 3, 4}
+	if got := renderedTimeline(rs); !slices.Equal(got, want) {
+		t.Errorf("Rendered timeline after pagination is %v, want %v", got, want)
+	}
+
+	// A sync repeating an event that pagination already added must not render it twice either
+	rs.ApplySync(testSync(testMessage(2, 3), testMessage(5, 4)))
+	want = []database.EventRowID{1, 2, 3, 4, 5}
+	if got := renderedTimeline(rs); !slices.Equal(got, want) {
+		t.Errorf("Rendered timeline after overlapping sync is %v, want %v", got, want)
+	}
+}
+
+func TestRoomStore_GapFill(t *testing.T) {
+	rs := newTestRoomStore()
+	rs.ApplySync(testSync(testMessage(1, 1), testMessage(2, 2)))
+	sync := testSync(testMessage(5, 100), testMessage(6, 101))
+	sync.Gaps = []*database.TimelineGap{{RoomID: testRoomID, TimelineRowID: 100}}
+	rs.ApplySync(sync)
+	if rs.GetGapBefore(100) == nil {
+		t.Fatal("Gap wasn't stored")
+	}
+	// The missing events are given free row IDs right before the gap without touching other entries
+	rs.ApplyGapFill(100, &jsoncmd.FillGapResponse{
+		Events:   []*database.Event{testMessage(4, 99), testMessage(3, 98)},
+		Timeline: []database.TimelineRowTuple{{Timeline: 98, Event: 3}, {Timeline: 99, Event: 4}},
+		Gaps:     []*database.TimelineGap{{RoomID: testRoomID, TimelineRowID: 98}},
+	})
+	want := []database.EventRowID{1, 2, 3, 4, 5, 6}
+	if got := renderedTimeline(rs); !slices.Equal(got, want) {
+		t.Errorf("Rendered timeline after gap fill is %v, want %v", got, want)
+	}
+	if rs.GetGapBefore(100) != nil {
+		t.Error("Filled gap wasn't removed")
+	} else if rs.GetGapBefore(98) == nil {
+		t.Error("Remaining gap wasn't stored")
+	}
+}
+
+func TestRoomStore_GapFillMovesTail(t *testing.T) {
+	rs := newTestRoomStore()
+	rs.ApplySync(testSync(testMessage(1, 1), testMessage(2, 2)))
+	sync := testSync(testMessage(5, 3), testMessage(6, 4))
+	sync.Gaps = []*database.TimelineGap{{RoomID: testRoomID, TimelineRowID: 3}}
+	rs.ApplySync(sync)
+	// If there's no room before the gap, the backend reinserts the tail with new row IDs
+	rs.ApplyGapFill(3, &jsoncmd.FillGapResponse{
+		Events: []*database.Event{testMessage(4, 6), testMessage(3, 5)},
+		Timeline: []database.TimelineRowTuple{
+			{Timeline: 5, Event: 3},
+			{Timeline: 6, Event: 4},
+			{Timeline: 7, Event: 5},
+			{Timeline: 8, Event: 6},
+		},
+	})
+	want := []database.EventRowID{1, 2, 3, 4, 5, 6}
+	if got := renderedTimeline(rs); !slices.Equal(got, want) {
+		t.Errorf("Rendered timeline after gap fill is %v, want %v", got, want)
+	}
+	if len(rs.timeline) != len(want) {
+		t.Errorf("Timeline has %d entries after gap fill, want %d", len(rs.timeline), len(want))
+	}
+	if rs.GetGapBefore(3) != nil {
+		t.Error("Filled gap wasn't removed")
+	}
+}
+
+func TestRoomStore_GetServerACL(t *testing.T) {
+	rs := newTestRoomStore()
+	if acl := rs.GetServerACL(); acl != nil || !acl.Allows("1.2.3.4") {
+		t.Fatalf("Expected rooms without an ACL to allow all servers, got %+v", acl)
+	}
+	applyTestState(rs, event.StateServerACL, "", `{"allow":["*"],"deny":["evil.com"]}`)
+	acl := rs.GetServerACL()
+	if acl.Allows("evil.com") || !acl.Allows("example.com") {
+		t.Error("Expected new ACL to be used after the state changed")
+	} else if !acl.Allows("1.2.3.4") {
+		t.Error("Expected IP literals to be allowed when allow_ip_literals is missing")
+	}
+	applyTestState(rs, event.StateServerACL, "", `{"allow":["*"],"allow_ip_literals":false}`)
+	if rs.GetServerACL().Allows("1.2.3.4") {
+		t.Error("Expected IP literals to be denied when allow_ip_literals is false")
+	}
+}
+
+func TestRoomStore_HasMoreHistory(t *testing.T) {
+	rs := newTestRoomStore()
+	if !rs.HasMoreHistory() {
+		t.Fatal("Expected new room to have more history")
+	}
+	rs.ApplySync(testSync(testMessage(3, 3), testMessage(4, 4)))
+	rs.ApplyPagination(&jsoncmd.PaginationResponse{Events: []*database.Event{testMessage(2, 2)}, HasMore: true})
+	if !rs.HasMoreHistory() {
+		t.Error("Expected room to have more history after a pagination with more events")
+	}
+	rs.ApplyPagination(&jsoncmd.PaginationResponse{Events: []*database.Event{testMessage(1, 1)}, HasMore: false})
+	if rs.HasMoreHistory() {
+		t.Error("Expected pagination without more events to mark history as exhausted")
+	} else if got := renderedTimeline(rs); !slices.Equal(got, []database.EventRowID{1, 2, 3, 4}) {
+		t.Errorf("Expected last page to still be added to the timeline, got %v", got)
+	}
+	rs.ApplySync(testSync(testMessage(5, 5)))
+	if rs.HasMoreHistory() {
+		t.Error("Expected new messages not to reset the exhausted history flag")
+	}
+	reset := testSync(testMessage(6, 6))
+	reset.Reset = true
+	rs.ApplySync(reset)
+	if !rs.HasMoreHistory() {
+		t.Error("Expected timeline reset to allow paginating again")
+	}
+}
@@ -0,0 +1,155 @@
+// Copyright (c) 2025 Tulir Asokan
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package store
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/tidwall/gjson"
+	"maunium.net/go/mautrix/event"
+	"maunium.net/go/mautrix/id"
+
+	"go.mau.fi/gomuks/pkg/hicli/database"
+)
+
+// maxPreviewLength is the maximum number of runes in preview texts.
+const maxPreviewLength = 100
+
+// maxSubtitleHeroes is the maximum number of hero names listed in member-based subtitles.
+const maxSubtitleHeroes = 2
+
+// GetPreviewText returns a single-line preview of the latest message in the room, e.g. "Alice: hello".
+// If the preview event isn't loaded yet, it's requested from the backend and an empty string is returned.
+func (rs *RoomStore) GetPreviewText() string {
+	rs.lock.RLock()
+	if cached := rs.previewText.Load(); cached != nil {
+		rs.lock.RUnlock()
+		return *cached
+	}
+	rowID := rs.Meta.Current().PreviewEventRowID
+	evt := rs.eventsByRowID[rowID]
+	if evt == nil {
+		rs.lock.RUnlock()
+		if rowID != 0 {
+			rs.requestPreviewEvent(rowID)
+		}
+		return ""
+	}
+	// The cache is only invalidated while holding the write lock, so storing it here can't race with that.
+	text := rs.renderPreview(evt)
+	rs.previewText.Store(&text)
+	rs.lock.RUnlock()
+	return text
+}
+
+func (rs *RoomStore) requestPreviewEvent(rowID database.EventRowID) {
+	rs.lock.Lock()
+	alreadyRequested := rs.requestedEvents.Has(rowID) || rs.eventsByRowID[rowID] != nil
+	rs.requestedEvents.Add(rowID)
+	rs.lock.Unlock()
+	if !alreadyRequested && rs.parent.RequestEventByRowID != nil {
+		rs.parent.RequestEventByRowID(rowID)
+	}
+}
+
+// invalidatePreview clears the cached preview text. The caller must hold the write lock.
+func (rs *RoomStore) invalidatePreview() {
+	rs.previewText.Store(nil)
+}
+
+func (rs *RoomStore) previewAffectedBy(evt *database.Event) bool {
+	previewRowID := rs.Meta.Current().PreviewEventRowID
+	if evt.RowID == previewRowID {
+		return true
+	}
+	preview := rs.eventsByRowID[previewRowID]
+	return preview != nil && evt.RelatesTo == preview.ID
+}
+
+func (rs *RoomStore) renderPreview(evt *database.Event) string {
+	sender := rs.getDisplaynameLocked(evt.Sender)
+	if evt.Sender == rs.parent.ClientState.UserID {
+		sender = "You"
+	}
+	if evt.RedactedBy != "" {
+		return fmt.Sprintf("%s: Message deleted", sender)
+	}
+	evtType := evt.GetType()
+	if evtType == event.EventEncrypted {
+		return fmt.Sprintf("%s: Encrypted message", sender)
+	}
+	content := gjson.ParseBytes(evt.GetContent())
+	var text string
+	switch evtType {
+	case event.EventSticker:
+		text = "Sticker: " + content.Get("body").Str
+	case event.EventMessage:
+		body := content.Get("body").Str
+		switch event.MessageType(content.Get("msgtype").Str) {
+		case event.MsgEmote:
+			return truncatePreview(fmt.Sprintf("* %s %s", sender, body))
+		case event.MsgImage:
+			text = "Image: " + body
+		case event.MsgVideo:
+			text = "Video: " + body
+		case event.MsgAudio:
+			text = "Audio: " + body
+		case event.MsgFile:
+			text = "File: " + body
+		case event.MsgLocation:
+			text = "Location"
+		default:
+			text = body
+		}
+	default:
+		return ""
+	}
+	return truncatePreview(fmt.Sprintf("%s: %s", sender, text))
+}
+
+func truncatePreview(text string) string {
+	text = strings.Join(strings.Fields(text), " ")
+	if runes := []rune(text); len(runes) > maxPreviewLength {
+		text = string(runes[:maxPreviewLength-1]) + "…"
+	}
+	return text
+}
+
+// getDisplaynameLocked is GetDisplayname for callers that already hold the lock.
+func (rs *RoomStore) getDisplaynameLocked(userID id.UserID) string {
+	evt := rs.eventsByRowID[rs.state[event.StateMember][userID.String()]]
+	if evt != nil {
+		if name := gjson.GetBytes(evt.GetContent(), "displayname").Str; name != "" {
+			return name
+		}
+	}
+	return userID.Localpart()
+}
+
+// GetSubtitle returns the second line shown for the room in the room list. This is the latest message preview
+// if one is available, otherwise a listing of the room's members based on the sync summary, e.g. "Alice, Bob +3".
+func (rs *RoomStore) GetSubtitle() string {
+	if preview := rs.GetPreviewText(); preview != "" {
+		return preview
+	}
+	summary := rs.Meta.Current().LazyLoadSummary
+	if summary == nil || len(summary.Heroes) == 0 {
+		return ""
+	}
+	heroes := summary.Heroes[:min(len(summary.Heroes), maxSubtitleHeroes)]
+	names := make([]string, len(heroes))
+	for i, userID := range heroes {
+		names[i] = rs.GetDisplayname(userID)
+	}
+	subtitle := strings.Join(names, ", ")
+	// The member count includes the current user, who isn't in the heroes list
+	if others := summary.MemberCount() - 1 - len(heroes); others > 0 {
+		subtitle = fmt.Sprintf("%s +%d", subtitle, others)
+	}
+	return subtitle
+}
@@ -8,7 +8,9 @@ package store
 
 import (
 	"encoding/json"
+	"maps"
 	"slices"
+	"strings"
 	"sync"
 	"time"
 
@@ -32,6 +34,7 @@ type RoomListEntry struct {
 	MarkedUnread     bool
 	IsInvite         bool
 	database.UnreadCounts
+	ServerUnreadCounts database.ServerUnreadCounts
 }
 
 type GomuksStore struct {
@@ -43,9 +46,16 @@ type GomuksStore struct {
 	rooms            map[id.RoomID]*RoomStore
 	roomList         []*RoomListEntry
 	ReversedRoomList EventDispatcher[[]*RoomListEntry]
+	UnreadSummary    EventDispatcher[UnreadSummary]
+	unread           unreadTracker
 	accountData      map[event.Type]*database.AccountData
 	AccountDataSubs  MultiNotifier[event.Type]
 	PreferenceCache  EventDispatcher[*Preferences]
+	Autocomplete     AutocompleteIndex
+
+	// RequestEventByRowID is called when the store needs an event that isn't loaded, e.g. a room list preview.
+	// The fetched event should be passed to RoomStore.ApplyFetchedEvent.
+	RequestEventByRowID func(rowID database.EventRowID)
 }
 
 func NewStore() *GomuksStore {
@@ -57,19 +67,37 @@ func NewStore() *GomuksStore {
 	return gs
 }
 
+func hasPreferenceChange(entry *jsoncmd.SyncRoom) bool {
+	for evtType := range entry.AccountData {
+		if evtType.Type == AccountDataGomuksPreferences.Type {
+			return true
+		}
+	}
+	return false
+}
+
 func roomListEntryChanged(entry *jsoncmd.SyncRoom, oldMeta *database.Room) bool {
 	return entry.Meta.SortingTimestamp != oldMeta.SortingTimestamp ||
 		entry.Meta.UnreadCounts != oldMeta.UnreadCounts ||
+		entry.Meta.ServerUnreadCounts != oldMeta.ServerUnreadCounts ||
 		entry.Meta.MarkedUnread != oldMeta.MarkedUnread ||
 		entry.Meta.PreviewEventRowID != oldMeta.PreviewEventRowID ||
 		ptr.Val(entry.Meta.Name) != ptr.Val(oldMeta.Name) ||
 		ptr.Val(entry.Meta.Avatar) != ptr.Val(oldMeta.Avatar) ||
+		ptr.Val(entry.Meta.DMUserID) != ptr.Val(oldMeta.DMUserID) ||
+		!entry.Meta.LazyLoadSummary.Equal(oldMeta.LazyLoadSummary) ||
+		hasPreferenceChange(entry) ||
 		slices.ContainsFunc(entry.Timeline, func(tuple database.TimelineRowTuple) bool {
 			return tuple.Event == entry.Meta.PreviewEventRowID
 		})
 }
 
-func (gs *GomuksStore) shouldHideRoom(entry *database.Room) bool {
+// shouldHideRoom decides whether the room should be left out of the room list.
+// Rooms are hidden if they're not normal chat rooms (e.g. spaces, which are rendered separately),
+// if they've been replaced by a room upgrade, if the user hid them with the hide_room preference,
+// or if they're DMs where everyone except the user has left.
+func (gs *GomuksStore) shouldHideRoom(roomStore *RoomStore) bool {
+	entry := roomStore.Meta.Current()
 	switch entry.CreationContent.Type {
 	default:
 		// The room is not a normal room
@@ -85,16 +113,32 @@ func (gs *GomuksStore) shouldHideRoom(entry *database.Room) bool {
 			return true
 		}
 	}
+	if prefs := roomStore.PreferenceCache.Current(); prefs != nil && prefs.HideRoom {
+		return true
+	}
+	if summary := entry.LazyLoadSummary; ptr.Val(entry.DMUserID) != "" && summary != nil &&
+		summary.JoinedMemberCount != nil && *summary.JoinedMemberCount <= 1 &&
+		ptr.Val(summary.InvitedMemberCount) == 0 {
+		// The other side of the DM has left and nobody is invited
+		return true
+	}
 	// Otherwise don't hide the room.
 	return false
 }
 
 func (gs *GomuksStore) makeRoomListEntry(roomStore *RoomStore) *RoomListEntry {
-	meta := roomStore.Meta.Current()
-	roomStore.Hidden = gs.shouldHideRoom(meta)
+	if roomStore.Archived {
+		return nil
+	}
+	roomStore.Hidden = gs.shouldHideRoom(roomStore)
 	if roomStore.Hidden {
 		return nil
 	}
+	return newRoomListEntry(roomStore)
+}
+
+func newRoomListEntry(roomStore *RoomStore) *RoomListEntry {
+	meta := roomStore.Meta.Current()
 	name := ptr.Val(meta.Name)
 	if name == "" {
 		name = "Unnamed room"
@@ -109,6 +153,8 @@ func (gs *GomuksStore) makeRoomListEntry(roomStore *RoomStore) *RoomListEntry {
 		Avatar:           ptr.Val(meta.Avatar),
 		MarkedUnread:     ptr.Val(meta.MarkedUnread),
 		UnreadCounts:     meta.UnreadCounts,
+
+		ServerUnreadCounts: meta.ServerUnreadCounts,
 	}
 	if entry.PreviewEvent != nil {
 		entry.PreviewSender = roomStore.GetStateEvent(event.StateMember, entry.PreviewEvent.Sender.String())
@@ -141,21 +187,36 @@ func (gs *GomuksStore) ApplySync(sync *jsoncmd.SyncComplete) {
 	for roomID, data := range sync.Rooms {
 		data.Meta.EnsureNotNil()
 		roomStore, existingRoom := gs.rooms[roomID]
+		if existingRoom && roomStore.Archived {
+			// The room was rejoined while the archived version was open, so start over
+			existingRoom = false
+		}
 		if !existingRoom {
 			roomStore = NewRoomStore(gs, data.Meta)
 			gs.rooms[roomID] = roomStore
+			delete(gs.invitedRooms, roomID)
+			gs.Autocomplete.UpdateRoom(data.Meta)
+			roomStore.Meta.Listen(gs.Autocomplete.UpdateRoom)
 		}
 		entryChanged := !resyncRoomList && (!existingRoom || roomListEntryChanged(data, roomStore.Meta.Current()))
 		roomStore.ApplySync(data)
 		if entryChanged {
 			changedRoomListEntries[roomID] = gs.makeRoomListEntry(roomStore)
 		}
-		if !existingRoom && !resyncRoomList && data.Meta.CreationContent.GetPredecessor().RoomID != "" {
-			changedRoomListEntries[data.Meta.CreationContent.GetPredecessor().RoomID] = nil
+		if !existingRoom && !resyncRoomList {
+			// When we join a valid replacement room, hide the tombstoned room.
+			predecessorID := data.Meta.CreationContent.GetPredecessor().RoomID
+			predecessor, ok := gs.rooms[predecessorID]
+			if ok && predecessor.Meta.Current().Tombstone.GetReplacementRoom() == roomID {
+				changedRoomListEntries[predecessorID] = nil
+				predecessor.Hidden = true
+			}
 		}
 	}
 	for _, roomID := range sync.LeftRooms {
 		delete(gs.rooms, roomID)
+		delete(gs.invitedRooms, roomID)
+		gs.Autocomplete.RemoveRoom(roomID)
 		changedRoomListEntries[roomID] = nil
 	}
 	var updatedRoomList []*RoomListEntry
@@ -198,6 +259,14 @@ func (gs *GomuksStore) ApplySync(sync *jsoncmd.SyncComplete) {
 			}
 		}
 	}
+	if resyncRoomList {
+		gs.unread.reset(updatedRoomList)
+	} else {
+		for roomID, entry := range changedRoomListEntries {
+			gs.unread.update(roomID, entry)
+		}
+	}
+	gs.emitUnreadSummary()
 	if updatedRoomList != nil {
 		gs.roomList = updatedRoomList
 		reversed := slices.Clone(updatedRoomList)
@@ -206,12 +275,80 @@ func (gs *GomuksStore) ApplySync(sync *jsoncmd.SyncComplete) {
 	}
 }
 
+// GetHiddenRoomList returns room list entries for rooms that are hidden from the normal room list,
+// e.g. for including them in search results.
+func (gs *GomuksStore) GetHiddenRoomList() []*RoomListEntry {
+	gs.lock.RLock()
+	defer gs.lock.RUnlock()
+	var entries []*RoomListEntry
+	for _, roomStore := range gs.rooms {
+		if roomStore.Hidden && !roomStore.Archived {
+			entries = append(entries, newRoomListEntry(roomStore))
+		}
+	}
+	slices.SortFunc(entries, func(a, b *RoomListEntry) int {
+		return b.SortingTimestamp.Compare(a.SortingTimestamp)
+	})
+	return entries
+}
+
 func (gs *GomuksStore) GetRoom(roomID id.RoomID) *RoomStore {
 	gs.lock.RLock()
 	defer gs.lock.RUnlock()
 	return gs.rooms[roomID]
 }
 
+// GetRooms returns all rooms in the store in no particular order.
+func (gs *GomuksStore) GetRooms() []*RoomStore {
+	gs.lock.RLock()
+	defer gs.lock.RUnlock()
+	return slices.Collect(maps.Values(gs.rooms))
+}
+
+// GetJoinedSpaces returns the spaces that the user is currently joined to, sorted by name.
+func (gs *GomuksStore) GetJoinedSpaces() []*RoomStore {
+	gs.lock.RLock()
+	defer gs.lock.RUnlock()
+	var spaces []*RoomStore
+	for _, roomStore := range gs.rooms {
+		meta := roomStore.Meta.Current()
+		if !roomStore.Archived && meta.CreationContent != nil && meta.CreationContent.Type == event.RoomTypeSpace {
+			spaces = append(spaces, roomStore)
+		}
+	}
+	slices.SortFunc(spaces, func(a, b *RoomStore) int {
+		return strings.Compare(
+			strings.ToLower(ptr.Val(a.Meta.Current().Name)),
+			strings.ToLower(ptr.Val(b.Meta.Current().Name)),
+		)
+	})
+	return spaces
+}
+
+// OpenArchivedRoom adds a room that the user has left to the store, so that its local history can be viewed.
+// Archived rooms are not included in the room list.
+func (gs *GomuksStore) OpenArchivedRoom(meta *database.Room) *RoomStore {
+	gs.lock.Lock()
+	defer gs.lock.Unlock()
+	if existing, ok := gs.rooms[meta.ID]; ok {
+		return existing
+	}
+	meta.EnsureNotNil()
+	roomStore := NewRoomStore(gs, meta)
+	roomStore.Archived = true
+	gs.rooms[meta.ID] = roomStore
+	return roomStore
+}
+
+// RemoveArchivedRoom removes an archived room from the store after it has been forgotten.
+func (gs *GomuksStore) RemoveArchivedRoom(roomID id.RoomID) {
+	gs.lock.Lock()
+	defer gs.lock.Unlock()
+	if room, ok := gs.rooms[roomID]; ok && room.Archived {
+		delete(gs.rooms, roomID)
+	}
+}
+
 func (gs *GomuksStore) GetInviteRoom(roomID id.RoomID) *InvitedRoom {
 	gs.lock.RLock()
 	defer gs.lock.RUnlock()
@@ -224,7 +361,10 @@ func (gs *GomuksStore) Clear() {
 	clear(gs.rooms)
 	clear(gs.invitedRooms)
 	clear(gs.accountData)
+	gs.Autocomplete.Clear()
 	gs.PreferenceCache.Emit(nil)
 	gs.roomList = nil
+	gs.unread.reset(nil)
+	gs.emitUnreadSummary()
 	gs.ReversedRoomList.Emit([]*RoomListEntry{})
 }
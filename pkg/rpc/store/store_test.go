@@ -0,0 +1,157 @@
+// Copyright (c) 2026 Tulir Asokan
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package store
+
+import (
+	"encoding/json"
+	"slices"
+	"testing"
+
+	"go.mau.fi/util/ptr"
+	"maunium.net/go/mautrix"
+	"maunium.net/go/mautrix/event"
+	"maunium.net/go/mautrix/id"
+
+	"go.mau.fi/gomuks/pkg/hicli/database"
+	"go.mau.fi/gomuks/pkg/hicli/jsoncmd"
+)
+
+func roomListIDs(entries []*RoomListEntry) []id.RoomID {
+	roomIDs := make([]id.RoomID, len(entries))
+	for i, entry := range entries {
+		roomIDs[i] = entry.RoomID
+	}
+	slices.Sort(roomIDs)
+	return roomIDs
+}
+
+func hideRoomAccountData(roomID id.RoomID, hide bool) map[event.Type]*database.AccountData {
+	content, _ := json.Marshal(map[string]bool{"hide_room": hide})
+	return map[event.Type]*database.AccountData{AccountDataGomuksPreferences: {
+		RoomID:  roomID,
+		Type:    AccountDataGomuksPreferences.Type,
+		Content: content,
+	}}
+}
+
+func dmSummary(joined, invited int) *mautrix.LazyLoadSummary {
+	return &mautrix.LazyLoadSummary{JoinedMemberCount: &joined, InvitedMemberCount: &invited}
+}
+
+func TestGomuksStore_HiddenRooms(t *testing.T) {
+	rooms := map[id.RoomID]*jsoncmd.SyncRoom{
+		"!normal:example.com": {Meta: &database.Room{ID: "!normal:example.com"}},
+		"!call:example.com": {Meta: &database.Room{
+			ID: "!call:example.com", CreationContent: &event.CreateEventContent{Type: "org.matrix.msc3417.call"},
+		}},
+		"!space:example.com": {Meta: &database.Room{
+			ID: "!space:example.com", CreationContent: &event.CreateEventContent{Type: event.RoomTypeSpace},
+		}},
+		"!hidden:example.com": {
+			Meta:        &database.Room{ID: "!hidden:example.com"},
+			AccountData: hideRoomAccountData("!hidden:example.com", true),
+		},
+		"!dm:example.com": {Meta: &database.Room{
+			ID: "!dm:example.com", DMUserID: ptr.Ptr[id.UserID]("@bob:example.com"), LazyLoadSummary: dmSummary(2, 0),
+		}},
+		"!emptydm:example.com": {Meta: &database.Room{
+			ID: "!emptydm:example.com", DMUserID: ptr.Ptr[id.UserID]("@bob:example.com"), LazyLoadSummary: dmSummary(1, 0),
+		}},
+		"!inviteddm:example.com": {Meta: &database.Room{
+			ID: "!inviteddm:example.com", DMUserID: ptr.Ptr[id.UserID]("@bob:example.com"), LazyLoadSummary: dmSummary(1, 1),
+		}},
+		"!emptygroup:example.com": {Meta: &database.Room{ID: "!emptygroup:example.com", LazyLoadSummary: dmSummary(1, 0)}},
+		"!old:example.com": {Meta: &database.Room{
+			ID: "!old:example.com", Tombstone: &event.TombstoneEventContent{ReplacementRoom: "!new:example.com"},
+		}},
+		"!new:example.com": {Meta: &database.Room{
+			ID: "!new:example.com", CreationContent: &event.CreateEventContent{Predecessor: &event.Predecessor{RoomID: "!old:example.com"}},
+		}},
+		"!badtombstone:example.com": {Meta: &database.Room{
+			ID: "!badtombstone:example.com", Tombstone: &event.TombstoneEventContent{ReplacementRoom: "!unrelated:example.com"},
+		}},
+		"!unrelated:example.com": {Meta: &database.Room{ID: "!unrelated:example.com"}},
+	}
+	gs := NewStore()
+	gs.ApplySync(&jsoncmd.SyncComplete{Rooms: rooms})
+
+	wantVisible := []id.RoomID{
+		"!badtombstone:example.com", "!call:example.com", "!dm:example.com", "!emptygroup:example.com",
+		"!inviteddm:example.com", "!new:example.com", "!normal:example.com", "!unrelated:example.com",
+	}
+	wantHidden := []id.RoomID{"!emptydm:example.com", "!hidden:example.com", "!old:example.com", "!space:example.com"}
+	assertRoomList := func(t *testing.T, wantVisible, wantHidden []id.RoomID) {
+		t.Helper()
+		if got := roomListIDs(gs.ReversedRoomList.Current()); !slices.Equal(got, wantVisible) {
+			t.Errorf("Room list = %v, want %v", got, wantVisible)
+		}
+		if got := roomListIDs(gs.GetHiddenRoomList()); !slices.Equal(got, wantHidden) {
+			t.Errorf("Hidden room list = %v, want %v", got, wantHidden)
+		}
+		for _, roomID := range wantHidden {
+			if !gs.GetRoom(roomID).Hidden {
+				t.Errorf("Expected %s to have the hidden flag", roomID)
+			}
+		}
+		for _, roomID := range wantVisible {
+			if gs.GetRoom(roomID).Hidden {
+				t.Errorf("Expected %s not to have the hidden flag", roomID)
+			}
+		}
+	}
+	assertRoomList(t, wantVisible, wantHidden)
+
+	t.Run("hide preference", func(t *testing.T) {
+		gs.ApplySync(&jsoncmd.SyncComplete{Rooms: map[id.RoomID]*jsoncmd.SyncRoom{"!normal:example.com": {
+			Meta:        &database.Room{ID: "!normal:example.com"},
+			AccountData: hideRoomAccountData("!normal:example.com", true),
+		}}})
+		assertRoomList(t,
+			slices.DeleteFunc(slices.Clone(wantVisible), func(roomID id.RoomID) bool { return roomID == "!normal:example.com" }),
+			[]id.RoomID{"!emptydm:example.com", "!hidden:example.com", "!normal:example.com", "!old:example.com", "!space:example.com"},
+		)
+		gs.ApplySync(&jsoncmd.SyncComplete{Rooms: map[id.RoomID]*jsoncmd.SyncRoom{"!normal:example.com": {
+			Meta:        &database.Room{ID: "!normal:example.com"},
+			AccountData: hideRoomAccountData("!normal:example.com", false),
+		}}})
+		assertRoomList(t, wantVisible, wantHidden)
+	})
+
+	t.Run("other user joins empty DM", func(t *testing.T) {
+		gs.ApplySync(&jsoncmd.SyncComplete{Rooms: map[id.RoomID]*jsoncmd.SyncRoom{"!emptydm:example.com": {Meta: &database.Room{
+			ID: "!emptydm:example.com", DMUserID: ptr.Ptr[id.UserID]("@bob:example.com"), LazyLoadSummary: dmSummary(2, 0),
+		}}}})
+		wantVisible = append(wantVisible, "!emptydm:example.com")
+		slices.Sort(wantVisible)
+		wantHidden = wantHidden[1:]
+		assertRoomList(t, wantVisible, wantHidden)
+	})
+
+	t.Run("replacement room joined", func(t *testing.T) {
+		gs.ApplySync(&jsoncmd.SyncComplete{Rooms: map[id.RoomID]*jsoncmd.SyncRoom{"!unrelated2:example.com": {Meta: &database.Room{
+			ID:              "!unrelated2:example.com",
+			CreationContent: &event.CreateEventContent{Predecessor: &event.Predecessor{RoomID: "!normal:example.com"}},
+		}}}})
+		wantVisible = append(wantVisible, "!unrelated2:example.com")
+		slices.Sort(wantVisible)
+		assertRoomList(t, wantVisible, wantHidden)
+
+		gs.ApplySync(&jsoncmd.SyncComplete{Rooms: map[id.RoomID]*jsoncmd.SyncRoom{"!badtombstone:example.com": {Meta: &database.Room{
+			ID: "!badtombstone:example.com", Tombstone: &event.TombstoneEventContent{ReplacementRoom: "!upgraded:example.com"},
+		}}}})
+		gs.ApplySync(&jsoncmd.SyncComplete{Rooms: map[id.RoomID]*jsoncmd.SyncRoom{"!upgraded:example.com": {Meta: &database.Room{
+			ID:              "!upgraded:example.com",
+			CreationContent: &event.CreateEventContent{Predecessor: &event.Predecessor{RoomID: "!badtombstone:example.com"}},
+		}}}})
+		wantVisible = slices.DeleteFunc(wantVisible, func(roomID id.RoomID) bool { return roomID == "!badtombstone:example.com" })
+		wantVisible = append(wantVisible, "!upgraded:example.com")
+		slices.Sort(wantVisible)
+		wantHidden = append(wantHidden, "!badtombstone:example.com")
+		slices.Sort(wantHidden)
+		assertRoomList(t, wantVisible, wantHidden)
+	})
+}
@@ -0,0 +1,102 @@
+// Copyright (c) 2026 Tulir Asokan
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package store
+
+import (
+	"maunium.net/go/mautrix/id"
+)
+
+// UnreadSummary contains the unread totals of all rooms in the room list.
+type UnreadSummary struct {
+	// The number of joined rooms with unread messages or that are marked as unread.
+	UnreadRooms int
+	// The total number of unread highlights in all rooms.
+	Mentions int
+	// The number of rooms with unread highlights.
+	MentionRooms int
+	Invites      int
+}
+
+func (us UnreadSummary) add(entry *RoomListEntry, sign int) UnreadSummary {
+	if entry.IsInvite {
+		us.Invites += sign
+		return us
+	}
+	if entry.UnreadMessages > 0 || entry.MarkedUnread {
+		us.UnreadRooms += sign
+	}
+	if entry.UnreadHighlights > 0 {
+		us.Mentions += sign * entry.UnreadHighlights
+		us.MentionRooms += sign
+	}
+	return us
+}
+
+// unreadTracker keeps the unread summary up to date as room list entries change,
+// so that the totals don't have to be recalculated from every room.
+type unreadTracker struct {
+	entries map[id.RoomID]*RoomListEntry
+	summary UnreadSummary
+}
+
+func (ut *unreadTracker) update(roomID id.RoomID, entry *RoomListEntry) {
+	if old, ok := ut.entries[roomID]; ok {
+		ut.summary = ut.summary.add(old, -1)
+		delete(ut.entries, roomID)
+	}
+	if entry == nil {
+		return
+	}
+	if ut.entries == nil {
+		ut.entries = make(map[id.RoomID]*RoomListEntry)
+	}
+	ut.entries[roomID] = entry
+	ut.summary = ut.summary.add(entry, 1)
+}
+
+func (ut *unreadTracker) reset(entries []*RoomListEntry) {
+	clear(ut.entries)
+	ut.summary = UnreadSummary{}
+	for _, entry := range entries {
+		ut.update(entry.RoomID, entry)
+	}
+}
+
+// nextMention finds the room that has had unread highlights for the longest time, i.e. the one with
+// the oldest sorting timestamp. The given room is skipped so that repeatedly jumping moves forward
+// even if the read receipt for the current room hasn't come back from the server yet.
+func (ut *unreadTracker) nextMention(skip id.RoomID) id.RoomID {
+	var next *RoomListEntry
+	for roomID, entry := range ut.entries {
+		if roomID == skip || entry.IsInvite || entry.UnreadHighlights == 0 {
+			continue
+		}
+		if next == nil || entry.SortingTimestamp.Before(next.SortingTimestamp) ||
+			(entry.SortingTimestamp.Equal(next.SortingTimestamp) && roomID < next.RoomID) {
+			next = entry
+		}
+	}
+	if next == nil {
+		return ""
+	}
+	return next.RoomID
+}
+
+// NextUnreadMention returns the room that should be triaged next: the one that has had unread highlights
+// for the longest time. The current room is skipped, so calling this again after reading the highlights
+// in a room moves to the next one. An empty room ID is returned if there are no other rooms with mentions.
+func (gs *GomuksStore) NextUnreadMention(current id.RoomID) id.RoomID {
+	gs.lock.RLock()
+	defer gs.lock.RUnlock()
+	return gs.unread.nextMention(current)
+}
+
+func (gs *GomuksStore) emitUnreadSummary() {
+	if gs.UnreadSummary.Current() != gs.unread.summary {
+		gs.UnreadSummary.Emit(gs.unread.summary)
+	}
+}
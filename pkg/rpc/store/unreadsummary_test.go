@@ -0,0 +1,160 @@
+// Copyright (c) 2026 Tulir Asokan
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package store
+
+import (
+	"testing"
+	"time"
+
+	"go.mau.fi/util/jsontime"
+	"go.mau.fi/util/ptr"
+	"maunium.net/go/mautrix/event"
+	"maunium.net/go/mautrix/id"
+
+	"go.mau.fi/gomuks/pkg/hicli/database"
+	"go.mau.fi/gomuks/pkg/hicli/jsoncmd"
+)
+
+var unreadTestTime = time.Date(2026, 1, 1, 12, 0, 0, 0, time.UTC)
+
+// unreadRoom returns a sync entry for a room with the given unread counts, sorted by the given offset in minutes.
+func unreadRoom(roomID id.RoomID, minute int, counts database.UnreadCounts) *jsoncmd.SyncRoom {
+	return &jsoncmd.SyncRoom{Meta: &database.Room{
+		ID:               roomID,
+		SortingTimestamp: jsontime.UM(unreadTestTime.Add(time.Duration(minute) * time.Minute)),
+		UnreadCounts:     counts,
+	}}
+}
+
+func TestUnreadSummary_Add(t *testing.T) {
+	tests := []struct {
+		name  string
+		entry RoomListEntry
+		want  UnreadSummary
+	}{
+		{"read room", RoomListEntry{}, UnreadSummary{}},
+		{"notifications without messages", RoomListEntry{UnreadCounts: database.UnreadCounts{UnreadNotifications: 2}}, UnreadSummary{}},
+		{"unread messages", RoomListEntry{UnreadCounts: database.UnreadCounts{UnreadMessages: 5}}, UnreadSummary{UnreadRooms: 1}},
+		{"marked unread", RoomListEntry{MarkedUnread: true}, UnreadSummary{UnreadRooms: 1}},
+		{
+			"highlights",
+			RoomListEntry{UnreadCounts: database.UnreadCounts{UnreadMessages: 5, UnreadHighlights: 3}},
+			UnreadSummary{UnreadRooms: 1, Mentions: 3, MentionRooms: 1},
+		},
+		{"invite", RoomListEntry{IsInvite: true, UnreadCounts: database.UnreadCounts{UnreadHighlights: 1}}, UnreadSummary{Invites: 1}},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if got := (UnreadSummary{}).add(&test.entry, 1); got != test.want {
+				t.Errorf("add() = %+v, want %+v", got, test.want)
+			}
+			if got := test.want.add(&test.entry, -1); got != (UnreadSummary{}) {
+				t.Errorf("Removing the entry again left %+v", got)
+			}
+		})
+	}
+}
+
+func TestGomuksStore_UnreadSummary(t *testing.T) {
+	gs := NewStore()
+	var emitted []UnreadSummary
+	gs.UnreadSummary.Listen(func(summary UnreadSummary) {
+		emitted = append(emitted, summary)
+	})
+	assertSummary := func(t *testing.T, want UnreadSummary) {
+		t.Helper()
+		if got := gs.UnreadSummary.Current(); got != want {
+			t.Errorf("UnreadSummary = %+v, want %+v", got, want)
+		}
+	}
+
+	space := unreadRoom("!space:example.com", 5, database.UnreadCounts{UnreadMessages: 10, UnreadHighlights: 10})
+	space.Meta.CreationContent = &event.CreateEventContent{Type: event.RoomTypeSpace}
+	marked := unreadRoom("!marked:example.com", 3, database.UnreadCounts{})
+	marked.Meta.MarkedUnread = ptr.Ptr(true)
+	gs.ApplySync(&jsoncmd.SyncComplete{
+		Rooms: map[id.RoomID]*jsoncmd.SyncRoom{
+			"!messages:example.com": unreadRoom("!messages:example.com", 1, database.UnreadCounts{UnreadMessages: 3}),
+			"!mentions:example.com": unreadRoom("!mentions:example.com", 2, database.UnreadCounts{UnreadMessages: 4, UnreadHighlights: 2}),
+			"!marked:example.com":   marked,
+			"!read:example.com":     unreadRoom("!read:example.com", 4, database.UnreadCounts{}),
+			"!space:example.com":    space,
+		},
+		InvitedRooms: []*database.InvitedRoom{{ID: "!invite:example.com", CreatedAt: jsontime.UM(unreadTestTime)}},
+	})
+	t.Run("initial sync", func(t *testing.T) {
+		assertSummary(t, UnreadSummary{UnreadRooms: 3, Mentions: 2, MentionRooms: 1, Invites: 1})
+	})
+
+	t.Run("read room", func(t *testing.T) {
+		gs.ApplySync(&jsoncmd.SyncComplete{Rooms: map[id.RoomID]*jsoncmd.SyncRoom{
+			"!messages:example.com": unreadRoom("!messages:example.com", 1, database.UnreadCounts{}),
+		}})
+		assertSummary(t, UnreadSummary{UnreadRooms: 2, Mentions: 2, MentionRooms: 1, Invites: 1})
+	})
+
+	t.Run("new mention", func(t *testing.T) {
+		gs.ApplySync(&jsoncmd.SyncComplete{Rooms: map[id.RoomID]*jsoncmd.SyncRoom{
+			"!read:example.com": unreadRoom("!read:example.com", 6, database.UnreadCounts{UnreadMessages: 1, UnreadHighlights: 1}),
+		}})
+		assertSummary(t, UnreadSummary{UnreadRooms: 3, Mentions: 3, MentionRooms: 2, Invites: 1})
+	})
+
+	t.Run("unchanged room", func(t *testing.T) {
+		emittedBefore := len(emitted)
+		gs.ApplySync(&jsoncmd.SyncComplete{Rooms: map[id.RoomID]*jsoncmd.SyncRoom{
+			"!read:example.com": unreadRoom("!read:example.com", 6, database.UnreadCounts{UnreadMessages: 1, UnreadHighlights: 1}),
+		}})
+		assertSummary(t, UnreadSummary{UnreadRooms: 3, Mentions: 3, MentionRooms: 2, Invites: 1})
+		if len(emitted) != emittedBefore {
+			t.Errorf("Expected unchanged summary not to be emitted again, got %+v", emitted[emittedBefore:])
+		}
+	})
+
+	t.Run("space updated", func(t *testing.T) {
+		space := unreadRoom("!space:example.com", 7, database.UnreadCounts{UnreadMessages: 11, UnreadHighlights: 11})
+		space.Meta.CreationContent = &event.CreateEventContent{Type: event.RoomTypeSpace}
+		gs.ApplySync(&jsoncmd.SyncComplete{Rooms: map[id.RoomID]*jsoncmd.SyncRoom{"!space:example.com": space}})
+		assertSummary(t, UnreadSummary{UnreadRooms: 3, Mentions: 3, MentionRooms: 2, Invites: 1})
+	})
+
+	t.Run("next mention", func(t *testing.T) {
+		if next := gs.NextUnreadMention(""); next != "!mentions:example.com" {
+			t.Errorf("NextUnreadMention() = %s, want the oldest room with mentions", next)
+		}
+		if next := gs.NextUnreadMention("!mentions:example.com"); next != "!read:example.com" {
+			t.Errorf("NextUnreadMention() = %s, want the current room to be skipped", next)
+		}
+	})
+
+	t.Run("invite accepted", func(t *testing.T) {
+		gs.ApplySync(&jsoncmd.SyncComplete{Rooms: map[id.RoomID]*jsoncmd.SyncRoom{
+			"!invite:example.com": unreadRoom("!invite:example.com", 8, database.UnreadCounts{UnreadMessages: 1}),
+		}})
+		assertSummary(t, UnreadSummary{UnreadRooms: 4, Mentions: 3, MentionRooms: 2})
+		if gs.GetInviteRoom("!invite:example.com") != nil {
+			t.Error("Expected invite to be removed after joining")
+		}
+	})
+
+	t.Run("room left", func(t *testing.T) {
+		gs.ApplySync(&jsoncmd.SyncComplete{LeftRooms: []id.RoomID{"!mentions:example.com"}})
+		assertSummary(t, UnreadSummary{UnreadRooms: 3, Mentions: 1, MentionRooms: 1})
+	})
+
+	t.Run("clear", func(t *testing.T) {
+		gs.Clear()
+		assertSummary(t, UnreadSummary{})
+		if next := gs.NextUnreadMention(""); next != "" {
+			t.Errorf("NextUnreadMention() = %s after clear, want none", next)
+		}
+	})
+
+	if len(emitted) == 0 || emitted[len(emitted)-1] != (UnreadSummary{}) {
+		t.Errorf("Expected listener to receive the summaries, got %+v", emitted)
+	}
+}
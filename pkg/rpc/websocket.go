@@ -15,7 +15,6 @@ import (
 	"io"
 	"net/http"
 	"net/url"
-	"runtime/debug"
 	"strconv"
 	"strings"
 	"time"
@@ -23,6 +22,7 @@ import (
 	"github.com/coder/websocket"
 	"github.com/rs/zerolog"
 	"go.mau.fi/util/ptr"
+	"maunium.net/go/mautrix"
 
 	"go.mau.fi/gomuks/pkg/hicli/jsoncmd"
 )
@@ -37,17 +37,35 @@ type wrappedEvent struct {
 	ReqID int64
 }
 
+// Connect connects to the backend websocket. If the connection is lost after this returns successfully,
+// it will be automatically re-established with exponential backoff until Disconnect is called or the
+// context is canceled. Changes in the connection state are emitted as *ConnectionStatus events.
 func (gr *GomuksRPC) Connect(ctx context.Context) error {
 	ctx, cancel := context.WithCancel(ctx)
-	if stopFn := gr.stop.Swap(&cancel); stopFn != nil {
+	stopPtr := &cancel
+	if stopFn := gr.stop.Swap(stopPtr); stopFn != nil {
 		(*stopFn)()
 	}
+	ws, connCtx, err := gr.dial(ctx)
+	if err != nil {
+		cancel()
+		return err
+	}
+	go gr.reconnectLoop(ctx, stopPtr, ws, connCtx)
+	return nil
+}
+
+// dial connects to the websocket. The returned context is canceled when the connection is closed,
+// with the read error as the cause.
+func (gr *GomuksRPC) dial(ctx context.Context) (*websocket.Conn, context.Context, error) {
 	wsURL := gr.BuildRawURL(GomuksURLPath{"websocket"})
 	wsURL.Scheme = strings.Replace(wsURL.Scheme, "http", "ws", 1)
 	query := url.Values{}
-	if gr.runID != "" && gr.lastReqID != 0 {
+	var resumeRunID string
+	if lastReqID := gr.lastReqID.Load(); gr.runID != "" && lastReqID != 0 {
+		resumeRunID = gr.runID
 		query.Set("run_id", gr.runID)
-		query.Set("last_received_event", strconv.FormatInt(gr.lastReqID, 10))
+		query.Set("last_received_event", strconv.FormatInt(lastReqID, 10))
 	}
 	wsURL.RawQuery = query.Encode()
 	zerolog.Ctx(ctx).Info().Stringer("url", wsURL).Msg("Connecting to websocket")
@@ -56,20 +74,22 @@ func (gr *GomuksRPC) Connect(ctx context.Context) error {
 		HTTPHeader: http.Header{"User-Agent": {gr.UserAgent}},
 	})
 	if err != nil {
-		cancel()
-		return fmt.Errorf("failed to connect to websocket: %w", err)
+		return nil, nil, fmt.Errorf("failed to connect to websocket: %w", err)
 	}
 	ws.SetReadLimit(50 * 1024 * 1024)
+	connCtx, cancel := context.WithCancelCause(ctx)
 	evtChan := make(chan wrappedEvent, 256)
-	go gr.eventLoop(ctx, evtChan)
-	go gr.readLoop(ctx, ws, cancel, evtChan)
-	go gr.pingLoop(ctx, ws)
-	gr.connCtx.Store(&ctx)
+	go gr.eventLoop(connCtx, evtChan)
+	go gr.readLoop(connCtx, ws, cancel, evtChan, resumeRunID)
+	go gr.pingLoop(connCtx, ws)
+	gr.connCtx.Store(&connCtx)
 	gr.conn.Store(ws)
-	return nil
+	return ws, connCtx, nil
 }
 
 func (gr *GomuksRPC) Disconnect() {
+	// Swap out the stop function before closing the connection so that the reconnect loop doesn't try to reconnect
+	stopFn := gr.stop.Swap(nil)
 	connCtx := gr.connCtx.Swap(nil)
 	if connCtx == nil {
 		connCtx = ptr.Ptr(context.Background())
@@ -80,7 +100,7 @@ func (gr *GomuksRPC) Disconnect() {
 			zerolog.Ctx(*connCtx).Warn().Err(err).Msg("Failed to send close notice to websocket")
 		}
 	}
-	if stopFn := gr.stop.Swap(nil); stopFn != nil {
+	if stopFn != nil {
 		(*stopFn)()
 	}
 	gr.clearPendingRequests()
@@ -191,6 +211,10 @@ func (gr *GomuksRPC) rawRequest(
 			if errMsg == "" {
 				errMsg = string(resp.Data)
 			}
+			// Errors with gomuks-specific error codes are turned back into RespErrors, so they can be checked with errors.Is
+			if errCode, message, ok := strings.Cut(errMsg, ": "); ok && strings.HasPrefix(errCode, "FI.MAU.GOMUKS.") {
+				return nil, mautrix.RespError{ErrCode: errCode, Err: message}
+			}
 			return nil, errors.New(errMsg)
 		}
 		return resp.Data, nil
@@ -208,30 +232,15 @@ func (gr *GomuksRPC) eventLoop(ctx context.Context, evtChan <-chan wrappedEvent)
 				return
 			}
 			gr.handleEvent(ctx, evt.Data)
-			gr.lastReqID = evt.ReqID
+			if evt.ReqID != 0 {
+				gr.lastReqID.Store(evt.ReqID)
+			}
 		case <-ctx.Done():
 			return
 		}
 	}
 }
 
-func (gr *GomuksRPC) handleEvent(ctx context.Context, evt any) {
-	defer func() {
-		err := recover()
-		if err != nil {
-			logEvt := zerolog.Ctx(ctx).Error().
-				Bytes(zerolog.ErrorStackFieldName, debug.Stack())
-			if realErr, ok := err.(error); ok {
-				logEvt = logEvt.Err(realErr)
-			} else {
-				logEvt = logEvt.Any(zerolog.ErrorFieldName, err)
-			}
-			logEvt.Msg("Panic in event handler")
-		}
-	}()
-	gr.EventHandler(ctx, evt)
-}
-
 const PingInterval = 15 * time.Second
 
 func (gr *GomuksRPC) pingLoop(ctx context.Context, ws *websocket.Conn) {
@@ -243,7 +252,7 @@ func (gr *GomuksRPC) pingLoop(ctx context.Context, ws *websocket.Conn) {
 				Command:   jsoncmd.ReqPing,
 				RequestID: gr.getNextRequestIDNoWait(),
 				Data: jsoncmd.PingParams{
-					LastReceivedID: gr.lastReqID,
+					LastReceivedID: gr.lastReqID.Load(),
 				},
 			})
 			if err != nil {
@@ -255,12 +264,15 @@ func (gr *GomuksRPC) pingLoop(ctx context.Context, ws *websocket.Conn) {
 	}
 }
 
-func (gr *GomuksRPC) readLoop(ctx context.Context, ws *websocket.Conn, cancelFunc context.CancelFunc, evtChan chan<- wrappedEvent) {
+func (gr *GomuksRPC) readLoop(ctx context.Context, ws *websocket.Conn, cancelFunc context.CancelCauseFunc, evtChan chan<- wrappedEvent, resumeRunID string) {
 	log := zerolog.Ctx(ctx)
-	defer cancelFunc()
+	var err error
+	defer func() {
+		cancelFunc(err)
+	}()
 	defer close(evtChan)
 	for {
-		if !gr.readLoopItem(ctx, log, ws, evtChan) {
+		if err = gr.readLoopItem(ctx, log, ws, evtChan, resumeRunID); err != nil {
 			break
 		}
 	}
@@ -281,6 +293,16 @@ func parseEvent(ctx context.Context, evt *jsoncmd.Container[json.RawMessage]) an
 		data = &jsoncmd.SendComplete{}
 	case jsoncmd.EventClientState:
 		data = &jsoncmd.ClientState{}
+	case jsoncmd.EventStorageChanged:
+		data = &jsoncmd.StorageChanged{}
+	case jsoncmd.EventPolicyEnforced:
+		data = &jsoncmd.PolicyEnforced{}
+	case jsoncmd.EventSendCooldown:
+		data = &jsoncmd.SendCooldown{}
+	case jsoncmd.EventSendQueueUpdate:
+		data = &jsoncmd.SendQueueUpdate{}
+	case jsoncmd.EventLeaveRoomsProgress:
+		data = &jsoncmd.LeaveRoomsProgress{}
 	case jsoncmd.EventRunID:
 		data = &jsoncmd.RunData{}
 	case jsoncmd.EventImageAuthToken:
@@ -303,7 +325,8 @@ func parseEvent(ctx context.Context, evt *jsoncmd.Container[json.RawMessage]) an
 
 var newlineBytes = []byte("\n")
 
-func (gr *GomuksRPC) readLoopItem(ctx context.Context, log *zerolog.Logger, ws *websocket.Conn, evtHandler chan<- wrappedEvent) bool {
+// readLoopItem reads and handles one message from the websocket. It returns an error if the read loop should stop.
+func (gr *GomuksRPC) readLoopItem(ctx context.Context, log *zerolog.Logger, ws *websocket.Conn, evtHandler chan<- wrappedEvent, resumeRunID string) error {
 	var cmd *jsoncmd.Container[json.RawMessage]
 	msgType, reader, err := ws.Reader(ctx)
 	defer func() {
@@ -318,7 +341,7 @@ func (gr *GomuksRPC) readLoopItem(ctx context.Context, log *zerolog.Logger, ws *
 	}()
 	if err != nil {
 		log.Err(err).Msg("Error reading from websocket")
-		return false
+		return err
 	} else if msgType != websocket.MessageText {
 		log.Warn().Msg("Unexpected message type from websocket")
 	} else if err = json.NewDecoder(reader).Decode(&cmd); err != nil {
@@ -349,6 +372,17 @@ func (gr *GomuksRPC) readLoopItem(ctx context.Context, log *zerolog.Logger, ws *
 		switch typedCmd := parsedCmd.(type) {
 		case *jsoncmd.RunData:
 			gr.runID = typedCmd.RunID
+			// The run ID is always the first thing the backend sends, so use it to tell the
+			// event handler whether the previous event stream was resumed.
+			status := &ConnectionStatus{
+				State:   ConnectionStateConnected,
+				Resumed: resumeRunID != "" && resumeRunID == typedCmd.RunID,
+			}
+			select {
+			case evtHandler <- wrappedEvent{Data: status}:
+			case <-ctx.Done():
+				return ctx.Err()
+			}
 		}
 		we := wrappedEvent{Data: parsedCmd, ReqID: cmd.RequestID}
 		select {
@@ -365,11 +399,11 @@ func (gr *GomuksRPC) readLoopItem(ctx context.Context, log *zerolog.Logger, ws *
 					Stringer("command", cmd.Command).
 					Msg("Event channel accepted event")
 			case <-ctx.Done():
-				return false
+				return ctx.Err()
 			}
 		}
 	}
-	return true
+	return nil
 }
 
 func (gr *GomuksRPC) clearPendingRequests() {
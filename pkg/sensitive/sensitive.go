@@ -0,0 +1,178 @@
+// Copyright (c) 2026 Tulir Asokan
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+// Package sensitive finds text that looks like secrets, such as private keys and access tokens,
+// so that clients can ask for confirmation before sending it.
+package sensitive
+
+import (
+	"fmt"
+	"math"
+	"regexp"
+	"slices"
+	"strings"
+	"unicode"
+)
+
+// Redaction is the text that matched spans are replaced with by Redact.
+const Redaction = "████"
+
+// SecretGroup is the name of the capture group that contains the secret part of a match.
+// If a pattern doesn't have the group, the whole match is treated as the secret.
+const SecretGroup = "secret"
+
+// Detector finds one kind of secret in text.
+type Detector struct {
+	Name    string
+	Pattern *regexp.Regexp
+	// MinEntropy is the minimum Shannon entropy per character (in bits) that the secret must have.
+	// It's used to avoid matching long strings that aren't random, such as words joined with underscores.
+	MinEntropy float64
+	// Exclude is an optional function that can reject a match based on the surrounding text.
+	// Start and end are the byte offsets of the secret in the text.
+	Exclude func(text string, start, end int) bool
+
+	secretGroup int
+}
+
+// NewDetector compiles a detector from a regular expression.
+func NewDetector(name, pattern string, minEntropy float64) (*Detector, error) {
+	re, err := regexp.Compile(pattern)
+	if err != nil {
+		return nil, fmt.Errorf("invalid pattern for %s: %w", name, err)
+	}
+	return &Detector{
+		Name:        name,
+		Pattern:     re,
+		MinEntropy:  minEntropy,
+		secretGroup: re.SubexpIndex(SecretGroup),
+	}, nil
+}
+
+func mustDetector(name, pattern string, minEntropy float64) *Detector {
+	det, err := NewDetector(name, pattern, minEntropy)
+	if err != nil {
+		panic(err)
+	}
+	return det
+}
+
+// isIdentifierOrLink checks if the match is the hash part of a Matrix event ID (which is 43 characters
+// of unpadded base64 in modern room versions) or a part of a URL, such as a matrix.to link, a matrix: URI,
+// a commit link or an mxc:// URI. Random-looking path segments in links are almost always IDs, not secrets.
+func isIdentifierOrLink(text string, start, end int) bool {
+	if start > 0 && text[start-1] == '$' {
+		return true
+	}
+	wordStart := strings.LastIndexFunc(text[:start], unicode.IsSpace) + 1
+	wordEnd := strings.IndexFunc(text[end:], unicode.IsSpace)
+	if wordEnd == -1 {
+		wordEnd = len(text)
+	} else {
+		wordEnd += end
+	}
+	word := text[wordStart:wordEnd]
+	return strings.Contains(word, "://") ||
+		strings.Contains(word, "matrix.to/") ||
+		strings.Contains(word, "matrix:") ||
+		strings.HasPrefix(strings.TrimLeft(word, "([<\"'"), "mxc:")
+}
+
+func withExclude(det *Detector, exclude func(text string, start, end int) bool) *Detector {
+	det.Exclude = exclude
+	return det
+}
+
+// DefaultDetectors are the built-in detectors. The high_entropy detector is the most likely to have
+// false positives, so it has to be easy to disable separately.
+var DefaultDetectors = []*Detector{
+	mustDetector("private_key", `-----BEGIN (?:[A-Z0-9]+ )*PRIVATE KEY(?: BLOCK)?-----(?s:.*?)(?:-----END (?:[A-Z0-9]+ )*PRIVATE KEY(?: BLOCK)?-----|$)`, 0),
+	mustDetector("aws_access_key", `\b(?:AKIA|ASIA|AGPA|AIDA|AROA)[0-9A-Z]{16}\b`, 0),
+	mustDetector("github_token", `\b(?:gh[pousr]_[A-Za-z0-9]{36,}|github_pat_[A-Za-z0-9_]{50,})\b`, 0),
+	mustDetector("slack_token", `\bxox[abposr]-[A-Za-z0-9-]{10,}`, 0),
+	mustDetector("matrix_access_token", `\bsyt_[A-Za-z0-9]+_[A-Za-z0-9]{20}_[A-Za-z0-9]{6}\b`, 0),
+	mustDetector("password", `(?i)\b(?:password|passwd|passphrase|pwd)\s*[:=]\s*(?P<secret>\S+)`, 0),
+	withExclude(mustDetector("high_entropy", `[A-Za-z0-9+/_\-]{32,}={0,2}`, 4.2), isIdentifierOrLink),
+}
+
+// Match is a span of text that looks like a secret. Start and End are byte offsets.
+type Match struct {
+	Detector string
+	Start    int
+	End      int
+}
+
+// Entropy returns the Shannon entropy of the string in bits per character.
+func Entropy(str string) float64 {
+	if len(str) == 0 {
+		return 0
+	}
+	counts := make(map[rune]int)
+	total := 0
+	for _, char := range str {
+		counts[char]++
+		total++
+	}
+	var entropy float64
+	for _, count := range counts {
+		p := float64(count) / float64(total)
+		entropy -= p * math.Log2(p)
+	}
+	return entropy
+}
+
+// Find runs the detectors on the text and returns the matched spans sorted by position.
+// Overlapping matches are merged, and the name of the detector that matched first is kept.
+func Find(text string, detectors []*Detector) []Match {
+	var matches []Match
+	for _, det := range detectors {
+		for _, loc := range det.Pattern.FindAllStringSubmatchIndex(text, -1) {
+			start, end := loc[0], loc[1]
+			if det.secretGroup > 0 {
+				start, end = loc[det.secretGroup*2], loc[det.secretGroup*2+1]
+			}
+			if start < 0 || start == end {
+				continue
+			} else if det.MinEntropy > 0 && Entropy(text[start:end]) < det.MinEntropy {
+				continue
+			} else if det.Exclude != nil && det.Exclude(text, start, end) {
+				continue
+			}
+			matches = append(matches, Match{Detector: det.Name, Start: start, End: end})
+		}
+	}
+	if len(matches) < 2 {
+		return matches
+	}
+	slices.SortStableFunc(matches, func(a, b Match) int {
+		return a.Start - b.Start
+	})
+	merged := matches[:1]
+	for _, match := range matches[1:] {
+		last := &merged[len(merged)-1]
+		if match.Start < last.End {
+			last.End = max(last.End, match.End)
+		} else {
+			merged = append(merged, match)
+		}
+	}
+	return merged
+}
+
+// Redact replaces the matched spans with Redaction. The matches must be sorted and non-overlapping,
+// like the ones returned by Find.
+func Redact(text string, matches []Match) string {
+	var buf strings.Builder
+	buf.Grow(len(text))
+	prevEnd := 0
+	for _, match := range matches {
+		buf.WriteString(text[prevEnd:match.Start])
+		buf.WriteString(Redaction)
+		prevEnd = match.End
+	}
+	buf.WriteString(text[prevEnd:])
+	return buf.String()
+}
@@ -0,0 +1,113 @@
+// Copyright (c) 2026 Tulir Asokan
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+// Package serveracl implements matching server names against m.room.server_acl events and
+// server entries in moderation policy lists.
+//
+// See https://spec.matrix.org/v1.16/client-server-api/#server-access-control-lists-acls-for-rooms
+package serveracl
+
+import (
+	"net/netip"
+	"strings"
+
+	"go.mau.fi/util/glob"
+	"maunium.net/go/mautrix/event"
+	"maunium.net/go/mautrix/id"
+)
+
+// ServerName returns the server name without the port, which is what server patterns are matched against.
+// Server names are case-insensitive, so the result is lowercased.
+func ServerName(server string) string {
+	if strings.HasPrefix(server, "[") {
+		if end := strings.IndexByte(server, ']'); end != -1 {
+			return strings.ToLower(server[:end+1])
+		}
+	} else if colon := strings.LastIndexByte(server, ':'); colon != -1 {
+		server = server[:colon]
+	}
+	return strings.ToLower(server)
+}
+
+// UserServer returns the server name of the given user, without the port.
+func UserServer(userID id.UserID) string {
+	return ServerName(userID.Homeserver())
+}
+
+// IsIPLiteral returns true if the server name is an IPv4 address or a bracketed IPv6 address.
+func IsIPLiteral(server string) bool {
+	server = ServerName(server)
+	if strings.HasPrefix(server, "[") && strings.HasSuffix(server, "]") {
+		addr, err := netip.ParseAddr(server[1 : len(server)-1])
+		return err == nil && addr.Is6()
+	}
+	addr, err := netip.ParseAddr(server)
+	return err == nil && addr.Is4()
+}
+
+// CompilePattern compiles a server name glob. The pattern is lowercased, so it should be matched against names
+// returned by ServerName.
+func CompilePattern(pattern string) glob.Glob {
+	return glob.Compile(strings.ToLower(pattern))
+}
+
+func compilePatterns(patterns []string) []glob.Glob {
+	globs := make([]glob.Glob, len(patterns))
+	for i, pattern := range patterns {
+		globs[i] = CompilePattern(pattern)
+	}
+	return globs
+}
+
+func matchAny(globs []glob.Glob, server string) bool {
+	for _, g := range globs {
+		if g.Match(server) {
+			return true
+		}
+	}
+	return false
+}
+
+// ACL is a compiled m.room.server_acl event.
+type ACL struct {
+	allow           []glob.Glob
+	deny            []glob.Glob
+	allowIPLiterals bool
+}
+
+// Compile compiles the content of a server ACL event. A nil content returns a nil ACL, which allows all servers.
+func Compile(content *event.ServerACLEventContent) *ACL {
+	if content == nil {
+		return nil
+	}
+	return &ACL{
+		allow:           compilePatterns(content.Allow),
+		deny:            compilePatterns(content.Deny),
+		allowIPLiterals: content.AllowIPLiterals,
+	}
+}
+
+// Allows checks if the given server is allowed to participate in the room. The server name may include a port.
+//
+// IP literals are denied first unless explicitly allowed, then deny rules are checked, and finally the server
+// must match at least one allow rule. An ACL with no allow rules denies every server.
+func (acl *ACL) Allows(server string) bool {
+	if acl == nil {
+		return true
+	}
+	server = ServerName(server)
+	if !acl.allowIPLiterals && IsIPLiteral(server) {
+		return false
+	} else if matchAny(acl.deny, server) {
+		return false
+	}
+	return matchAny(acl.allow, server)
+}
+
+// AllowsUser checks if the server of the given user is allowed to participate in the room.
+func (acl *ACL) AllowsUser(userID id.UserID) bool {
+	return acl.Allows(userID.Homeserver())
+}
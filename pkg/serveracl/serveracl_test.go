@@ -0,0 +1,113 @@
+// Copyright (c) 2026 Tulir Asokan
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package serveracl
+
+import (
+	"testing"
+
+	"maunium.net/go/mautrix/event"
+	"maunium.net/go/mautrix/id"
+)
+
+func TestServerName(t *testing.T) {
+	tests := map[string]string{
+		"example.com":      "example.com",
+		"Example.COM:8448": "example.com",
+		"1.2.3.4:8448":     "1.2.3.4",
+		"[::1]":            "[::1]",
+		"[2001:DB8::1]:80": "[2001:db8::1]",
+		"":                 "",
+	}
+	for input, want := range tests {
+		if got := ServerName(input); got != want {
+			t.Errorf("ServerName(%q) = %q, want %q", input, got, want)
+		}
+	}
+}
+
+func TestIsIPLiteral(t *testing.T) {
+	tests := map[string]bool{
+		"1.2.3.4":          true,
+		"1.2.3.4:8448":     true,
+		"[::1]":            true,
+		"[2001:db8::1]:80": true,
+		"[1.2.3.4]":        false,
+		"example.com":      false,
+		"1.2.3.4.5":        false,
+		"256.1.1.1":        false,
+		"1.2.3.example":    false,
+	}
+	for input, want := range tests {
+		if got := IsIPLiteral(input); got != want {
+			t.Errorf("IsIPLiteral(%q) = %t, want %t", input, got, want)
+		}
+	}
+}
+
+func TestACL_Allows(t *testing.T) {
+	tests := []struct {
+		name    string
+		content *event.ServerACLEventContent
+		server  string
+		want    bool
+	}{
+		{"no ACL", nil, "1.2.3.4", true},
+		{"no allow rules", &event.ServerACLEventContent{}, "example.com", false},
+
+		{"wildcard", &event.ServerACLEventContent{Allow: []string{"*"}}, "example.com", true},
+		{"wildcard with port", &event.ServerACLEventContent{Allow: []string{"*"}}, "example.com:8448", true},
+		{"exact", &event.ServerACLEventContent{Allow: []string{"example.com"}}, "example.com", true},
+		{"exact other server", &event.ServerACLEventContent{Allow: []string{"example.com"}}, "example.org", false},
+		{"case insensitive server", &event.ServerACLEventContent{Allow: []string{"example.com"}}, "EXAMPLE.com", true},
+		{"case insensitive pattern", &event.ServerACLEventContent{Allow: []string{"*.EXAMPLE.COM"}}, "matrix.example.com", true},
+		{"subdomain wildcard", &event.ServerACLEventContent{Allow: []string{"*.example.com"}}, "matrix.example.com", true},
+		{"subdomain wildcard doesn't match apex", &event.ServerACLEventContent{Allow: []string{"*.example.com"}}, "example.com", false},
+		{"wildcard matches dots", &event.ServerACLEventContent{Allow: []string{"*.com"}}, "a.b.example.com", true},
+		{"question mark", &event.ServerACLEventContent{Allow: []string{"matrix?.example.com"}}, "matrix1.example.com", true},
+		{"question mark needs a character", &event.ServerACLEventContent{Allow: []string{"matrix?.example.com"}}, "matrix.example.com", false},
+		{"question mark is one character", &event.ServerACLEventContent{Allow: []string{"matrix?.example.com"}}, "matrix12.example.com", false},
+		{"regex characters are literal", &event.ServerACLEventContent{Allow: []string{"example.com"}}, "exampleXcom", false},
+
+		{"deny wins over allow", &event.ServerACLEventContent{Allow: []string{"*"}, Deny: []string{"evil.com"}}, "evil.com", false},
+		{"deny wildcard", &event.ServerACLEventContent{Allow: []string{"*"}, Deny: []string{"*.evil.com"}}, "a.evil.com", false},
+		{"deny other server", &event.ServerACLEventContent{Allow: []string{"*"}, Deny: []string{"evil.com"}}, "good.com", true},
+		{"deny with port", &event.ServerACLEventContent{Allow: []string{"*"}, Deny: []string{"evil.com"}}, "evil.com:8448", false},
+
+		{"IPv4 denied", &event.ServerACLEventContent{Allow: []string{"*"}}, "1.2.3.4", false},
+		{"IPv4 with port denied", &event.ServerACLEventContent{Allow: []string{"*"}}, "1.2.3.4:8448", false},
+		{"IPv6 denied", &event.ServerACLEventContent{Allow: []string{"*"}}, "[::1]:8448", false},
+		{"IPv4 allowed", &event.ServerACLEventContent{Allow: []string{"*"}, AllowIPLiterals: true}, "1.2.3.4", true},
+		{"IPv6 allowed", &event.ServerACLEventContent{Allow: []string{"*"}, AllowIPLiterals: true}, "[::1]", true},
+		{"IP literal must still be allowed", &event.ServerACLEventContent{Allow: []string{"example.com"}, AllowIPLiterals: true}, "1.2.3.4", false},
+		{"IP literal denied by pattern", &event.ServerACLEventContent{Allow: []string{"*"}, Deny: []string{"1.2.3.*"}, AllowIPLiterals: true}, "1.2.3.4", false},
+		{"numeric hostname isn't an IP literal", &event.ServerACLEventContent{Allow: []string{"*"}}, "1.2.3.4.example.com", true},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if got := Compile(test.content).Allows(test.server); got != test.want {
+				t.Errorf("Allows(%q) = %t, want %t", test.server, got, test.want)
+			}
+		})
+	}
+}
+
+func TestACL_AllowsUser(t *testing.T) {
+	acl := Compile(&event.ServerACLEventContent{Allow: []string{"*"}, Deny: []string{"evil.com"}})
+	tests := map[id.UserID]bool{
+		"@alice:example.com":      true,
+		"@alice:evil.com":         false,
+		"@alice:evil.com:8448":    false,
+		"@alice:[::1]:8448":       false,
+		"@alice:sub.evil.com":     true,
+		"@alice:example.com:8448": true,
+	}
+	for userID, want := range tests {
+		if got := acl.AllowsUser(userID); got != want {
+			t.Errorf("AllowsUser(%s) = %t, want %t", userID, got, want)
+		}
+	}
+}
@@ -113,16 +113,20 @@ func (d *Driver) Open(connectionString string) (conn driver.Conn, retErr error)
 //	return nil, fmt.Errorf("not implemented")
 //}
 
+// registeredDriver is the driver instance registered in init, used by the storage management functions.
+var registeredDriver *Driver
+
 func init() {
 	val := js.Global().Get("sqlite3")
 	if !val.IsUndefined() {
-		sql.Register("sqlite-wasm-js", &Driver{
+		registeredDriver = &Driver{
 			SQLite: val,
 
 			OO1:  val.Get("oo1"),
 			CAPI: val.Get("capi"),
 			WASM: val.Get("wasm"),
 			Meow: val.Get("meow"),
-		})
+		}
+		sql.Register("sqlite-wasm-js", registeredDriver)
 	}
 }
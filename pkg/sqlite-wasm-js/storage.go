@@ -0,0 +1,64 @@
+// Copyright (c) 2025 Tulir Asokan
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+//go:build js
+
+package sqlite_wasm_js
+
+import (
+	"errors"
+	"syscall/js"
+)
+
+var ErrPoolNotAvailable = errors.New("opfs-sahpool VFS is not installed")
+
+func getPoolUtil() (js.Value, error) {
+	if registeredDriver == nil {
+		return js.Undefined(), ErrPoolNotAvailable
+	}
+	poolUtil := registeredDriver.SQLite.Get("PoolUtil")
+	if poolUtil.IsUndefined() || poolUtil.IsNull() {
+		return js.Undefined(), ErrPoolNotAvailable
+	}
+	return poolUtil, nil
+}
+
+// FileNames returns the names of the database files stored in the opfs-sahpool VFS.
+func FileNames() (names []string, err error) {
+	defer catchIntoErrorFmt(&err, "failed to get file names: %w")
+	poolUtil, err := getPoolUtil()
+	if err != nil {
+		return nil, err
+	}
+	jsNames := poolUtil.Call("getFileNames")
+	names = make([]string, jsNames.Length())
+	for i := range names {
+		names[i] = jsNames.Index(i).String()
+	}
+	return names, nil
+}
+
+// DeleteFile deletes a single file from the opfs-sahpool VFS. The file must not be open.
+// It returns false if the file didn't exist.
+func DeleteFile(name string) (deleted bool, err error) {
+	defer catchIntoErrorFmt(&err, "failed to delete %s: %w", name)
+	poolUtil, err := getPoolUtil()
+	if err != nil {
+		return false, err
+	}
+	return poolUtil.Call("unlink", name).Bool(), nil
+}
+
+// WipeFiles deletes all files in the opfs-sahpool VFS. All database connections must be closed first.
+func WipeFiles() (err error) {
+	defer catchIntoErrorFmt(&err, "failed to wipe files: %w")
+	poolUtil, err := getPoolUtil()
+	if err != nil {
+		return err
+	}
+	_, err = AwaitPromise(poolUtil.Call("wipeFiles"))
+	return err
+}
@@ -11,6 +11,7 @@ package sqlite_wasm_js
 import (
 	"fmt"
 	"runtime/debug"
+	"syscall/js"
 )
 
 type jsError struct {
@@ -46,3 +47,26 @@ func catchIntoErrorFmt(into *error, format string, args ...any) {
 		*into = fmt.Errorf(format, args...)
 	}
 }
+
+// AwaitPromise waits for the given JS promise to settle. It must not be called from a JS callback,
+// as the promise can't resolve while the event loop is blocked.
+func AwaitPromise(promise js.Value) (js.Value, error) {
+	type result struct {
+		val js.Value
+		err error
+	}
+	ch := make(chan result, 1)
+	onResolve := js.FuncOf(func(_ js.Value, args []js.Value) any {
+		ch <- result{val: args[0]}
+		return nil
+	})
+	defer onResolve.Release()
+	onReject := js.FuncOf(func(_ js.Value, args []js.Value) any {
+		ch <- result{err: jsError{val: js.Error{Value: args[0]}}}
+		return nil
+	})
+	defer onReject.Release()
+	promise.Call("then", onResolve, onReject)
+	res := <-ch
+	return res.val, res.err
+}
@@ -0,0 +1,107 @@
+// Copyright (c) 2025 Tulir Asokan
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+// Package usercolor contains the algorithm that gomuks frontends use to pick a color for a user.
+//
+// The color index is the sum of the UTF-16 code units of the user ID modulo the number of colors.
+// The same algorithm is implemented in getUserColorIndex in web/src/api/media.ts, and the palettes
+// match the --sender-color-N variables in web/src/index.css, so all of these must be changed together.
+package usercolor
+
+import (
+	"fmt"
+	"math"
+	"unicode/utf16"
+)
+
+// Color is a 24-bit RGB color in the form 0xRRGGBB.
+type Color uint32
+
+func (c Color) RGB() (r, g, b uint8) {
+	return uint8(c >> 16), uint8(c >> 8), uint8(c)
+}
+
+func (c Color) Hex() string {
+	return fmt.Sprintf("#%06x", uint32(c)&0xffffff)
+}
+
+// Background is a hint about whether the color is going to be displayed on a dark or light background.
+type Background string
+
+const (
+	BackgroundDark  Background = "dark"
+	BackgroundLight Background = "light"
+)
+
+// Count is the number of colors in each palette.
+const Count = 10
+
+// MinContrast is the minimum contrast ratio that every palette color has against its background.
+// The WCAG AA requirement for normal text is 4.5:1, the palettes are all above 7:1.
+const MinContrast = 4.5
+
+// DarkBackgroundPalette contains light colors meant to be displayed on dark backgrounds.
+var DarkBackgroundPalette = [Count]Color{
+	0xff877c, 0xf6913d, 0xdb9f00, 0x56c02c, 0x1fc090, 0x21bacd, 0x7aacf4, 0xad9cfe, 0xd991de, 0xfe84a2,
+}
+
+// LightBackgroundPalette contains dark colors meant to be displayed on light backgrounds.
+var LightBackgroundPalette = [Count]Color{
+	0xa4041d, 0x9b2200, 0x803f00, 0x005f00, 0x005c45, 0x00548c, 0x064ab1, 0x5d26cd, 0x822198, 0x9f0850,
+}
+
+// Index returns the palette index for the given user ID.
+func Index(userID string) int {
+	var sum uint64
+	for _, unit := range utf16.Encode([]rune(userID)) {
+		sum += uint64(unit)
+	}
+	return int(sum % Count)
+}
+
+// Palette returns the palette to use on the given background. Unknown backgrounds are treated as dark.
+func Palette(bg Background) *[Count]Color {
+	if bg == BackgroundLight {
+		return &LightBackgroundPalette
+	}
+	return &DarkBackgroundPalette
+}
+
+// Get returns the color for the given user ID on the given background.
+func Get(userID string, bg Background) Color {
+	return Palette(bg)[Index(userID)]
+}
+
+func relativeLuminance(c Color) float64 {
+	r, g, b := c.RGB()
+	channel := func(v uint8) float64 {
+		f := float64(v) / 255
+		if f <= 0.03928 {
+			return f / 12.92
+		}
+		return math.Pow((f+0.055)/1.055, 2.4)
+	}
+	return 0.2126*channel(r) + 0.7152*channel(g) + 0.0722*channel(b)
+}
+
+// ContrastRatio returns the WCAG contrast ratio between two colors, which ranges from 1 to 21.
+func ContrastRatio(a, b Color) float64 {
+	la, lb := relativeLuminance(a), relativeLuminance(b)
+	return (max(la, lb) + 0.05) / (min(la, lb) + 0.05)
+}
+
+// ReferenceBackground returns the background color that the palettes are checked against.
+func ReferenceBackground(bg Background) Color {
+	if bg == BackgroundLight {
+		return 0xffffff
+	}
+	return 0x000000
+}
+
+// IsReadable returns true if the given color has enough contrast against the given background.
+func IsReadable(c Color, bg Background) bool {
+	return ContrastRatio(c, ReferenceBackground(bg)) >= MinContrast
+}
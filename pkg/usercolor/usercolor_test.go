@@ -0,0 +1,134 @@
+// Copyright (c) 2026 Tulir Asokan
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package usercolor
+
+import (
+	"bytes"
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"testing"
+)
+
+var update = flag.Bool("update", false, "update the golden files in testdata")
+
+// goldenUserIDs are the user IDs whose colors are recorded in the golden file. They include characters outside
+// the basic multilingual plane, which are two UTF-16 code units each, as that's what the web frontend sums.
+var goldenUserIDs = []string{
+	"@alice:example.com",
+	"@bob:example.com",
+	"@carol:example.com",
+	"@dave:example.com",
+	"@eve:example.com",
+	"@tulir:maunium.net",
+	"@matrix:matrix.org",
+	"@_bridge_bot:example.com",
+	"@user123:localhost:8448",
+	"@a:b",
+	"@ünïcödé:example.com",
+	"@\U0001F408:example.com",
+	"@日本語:example.com",
+	"",
+	"-->",
+}
+
+func renderGolden() []byte {
+	var buf bytes.Buffer
+	buf.WriteString("# user ID\tindex\tdark background\tlight background\n")
+	for _, userID := range goldenUserIDs {
+		_, _ = fmt.Fprintf(&buf, "%q\t%d\t%s\t%s\n",
+			userID, Index(userID), Get(userID, BackgroundDark).Hex(), Get(userID, BackgroundLight).Hex())
+	}
+	return buf.Bytes()
+}
+
+// TestGolden checks that users keep the same colors. Run with -update if the change is intentional,
+// but remember that the web frontend must be changed in the same way.
+func TestGolden(t *testing.T) {
+	path := filepath.Join("testdata", "colors.golden")
+	got := renderGolden()
+	if *update {
+		if err := os.WriteFile(path, got, 0644); err != nil {
+			t.Fatalf("Failed to update golden file: %v", err)
+		}
+		return
+	}
+	want, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("Failed to read golden file: %v", err)
+	}
+	if !bytes.Equal(got, want) {
+		t.Errorf("User colors don't match %s (run with -update if the change is intentional):\ngot:\n%s\nwant:\n%s", path, got, want)
+	}
+}
+
+func TestPalettesAreReadable(t *testing.T) {
+	for _, bg := range []Background{BackgroundDark, BackgroundLight} {
+		for i, color := range Palette(bg) {
+			if !IsReadable(color, bg) {
+				t.Errorf("Color %d (%s) has contrast %.2f against the %s background", i, color.Hex(),
+					ContrastRatio(color, ReferenceBackground(bg)), bg)
+			}
+		}
+	}
+}
+
+func TestPalette_UnknownBackground(t *testing.T) {
+	if Palette("") != &DarkBackgroundPalette || Palette("sepia") != &DarkBackgroundPalette {
+		t.Error("Expected unknown backgrounds to use the dark background palette")
+	}
+}
+
+func TestContrastRatio(t *testing.T) {
+	tests := []struct {
+		a, b Color
+		want float64
+	}{
+		{0x000000, 0xffffff, 21},
+		{0xffffff, 0x000000, 21},
+		{0x777777, 0x777777, 1},
+		{0x767676, 0xffffff, 4.54},
+	}
+	for _, test := range tests {
+		if got := ContrastRatio(test.a, test.b); got < test.want-0.01 || got > test.want+0.01 {
+			t.Errorf("ContrastRatio(%s, %s) = %.3f, want %.2f", test.a.Hex(), test.b.Hex(), got, test.want)
+		}
+	}
+}
+
+var cssSenderColorRegex = regexp.MustCompile(`--sender-color-(\d+): #([0-9a-f]{6});`)
+
+// TestPalettesMatchWeb checks that the palettes are the same as the sender colors in the web frontend's CSS.
+// The light theme colors come first in the CSS file and the dark theme overrides them later.
+func TestPalettesMatchWeb(t *testing.T) {
+	css, err := os.ReadFile(filepath.Join("..", "..", "web", "src", "index.css"))
+	if os.IsNotExist(err) {
+		t.Skip("Web frontend source not found")
+	} else if err != nil {
+		t.Fatalf("Failed to read CSS: %v", err)
+	}
+	matches := cssSenderColorRegex.FindAllSubmatch(css, -1)
+	if len(matches) != Count*2 {
+		t.Fatalf("Expected %d sender colors in CSS, found %d", Count*2, len(matches))
+	}
+	for i, match := range matches {
+		palette, bg := &LightBackgroundPalette, BackgroundLight
+		if i >= Count {
+			palette, bg = &DarkBackgroundPalette, BackgroundDark
+		}
+		index, _ := strconv.Atoi(string(match[1]))
+		value, _ := strconv.ParseUint(string(match[2]), 16, 32)
+		if index != i%Count {
+			t.Fatalf("Unexpected sender color order in CSS: %s", match[0])
+		} else if palette[index] != Color(value) {
+			t.Errorf("%s background color %d is %s, but the web frontend uses #%s", bg, index, palette[index].Hex(), match[2])
+		}
+	}
+}
@@ -0,0 +1,261 @@
+// Copyright (c) 2025 Tulir Asokan
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+// Package widgetapi implements the capability negotiation and enforcement parts of the Matrix widget API
+// (MSC2762, MSC3819 and related proposals), which decide what a hosted widget is allowed to read and write.
+package widgetapi
+
+import (
+	"slices"
+	"strings"
+
+	"github.com/tidwall/gjson"
+	"maunium.net/go/mautrix/event"
+	"maunium.net/go/mautrix/id"
+)
+
+// Kind is the type of a widget capability, i.e. the part of the capability string before the colon.
+type Kind string
+
+const (
+	KindAlwaysOnScreen     Kind = "m.always_on_screen"
+	KindRequiresClient     Kind = "io.element.requires_client"
+	KindSendEvent          Kind = "org.matrix.msc2762.send.event"
+	KindReceiveEvent       Kind = "org.matrix.msc2762.receive.event"
+	KindSendStateEvent     Kind = "org.matrix.msc2762.send.state_event"
+	KindReceiveStateEvent  Kind = "org.matrix.msc2762.receive.state_event"
+	KindSendToDevice       Kind = "org.matrix.msc3819.send.to_device"
+	KindReceiveToDevice    Kind = "org.matrix.msc3819.receive.to_device"
+	KindTimeline           Kind = "org.matrix.msc2762.timeline"
+	KindTurnServers        Kind = "org.matrix.msc3846.turn_servers"
+	KindSendDelayedEvent   Kind = "org.matrix.msc4157.send.delayed_event"
+	KindUpdateDelayedEvent Kind = "org.matrix.msc4157.update_delayed_event"
+	KindReadRelations      Kind = "org.matrix.msc3869.read_relations"
+	KindDownloadFile       Kind = "org.matrix.msc4039.download_file"
+	KindUploadFile         Kind = "org.matrix.msc4039.upload_file"
+	KindNavigate           Kind = "org.matrix.msc2931.navigate"
+	KindRequiresEncryption Kind = "io.element.requires_encryption"
+)
+
+// Capability is a single parsed widget capability.
+type Capability struct {
+	Kind Kind
+	// The event type for event and to-device capabilities, or the room ID for timeline capabilities.
+	Target string
+	// The msgtype for m.room.message event capabilities, or the state key for state event capabilities.
+	// If nil, any msgtype or state key is allowed.
+	Detail *string
+}
+
+// ParseCapability parses a capability string. Event type, msgtype and state key parts are not validated.
+func ParseCapability(raw string) Capability {
+	kind, target, hasTarget := strings.Cut(raw, ":")
+	capability := Capability{Kind: Kind(kind)}
+	if !hasTarget {
+		return capability
+	}
+	if capability.Kind == KindTimeline {
+		capability.Target = target
+		return capability
+	}
+	evtType, detail, hasDetail := strings.Cut(target, "#")
+	capability.Target = evtType
+	if hasDetail {
+		capability.Detail = &detail
+	}
+	return capability
+}
+
+// String returns the capability in the wire format used by the widget API.
+func (c Capability) String() string {
+	if c.Target == "" {
+		return string(c.Kind)
+	} else if c.Detail == nil {
+		return string(c.Kind) + ":" + c.Target
+	}
+	return string(c.Kind) + ":" + c.Target + "#" + *c.Detail
+}
+
+func (c Capability) matchesEvent(evtType string, detail *string) bool {
+	if c.Target != evtType {
+		return false
+	}
+	return c.Detail == nil || (detail != nil && *c.Detail == *detail)
+}
+
+// protectedStateTypes are state event types that widgets are never allowed to change,
+// as they control room access and permissions.
+var protectedStateTypes = []string{
+	event.StateCreate.Type,
+	event.StateMember.Type,
+	event.StatePowerLevels.Type,
+	event.StateJoinRules.Type,
+	event.StateHistoryVisibility.Type,
+	event.StateGuestAccess.Type,
+	event.StateEncryption.Type,
+	event.StateServerACL.Type,
+	event.StateTombstone.Type,
+	event.StateCanonicalAlias.Type,
+}
+
+// Context contains the information that capability negotiation and enforcement depends on.
+type Context struct {
+	// The room the widget is embedded in.
+	RoomID id.RoomID
+	// The user and device the widget is acting as.
+	UserID   id.UserID
+	DeviceID id.DeviceID
+}
+
+// ownsStateKey checks if the given state key belongs to the current user. State keys that contain a user ID
+// (either directly or with the `_` prefix that MSC3779 uses to allow suffixes) may only be written by that user.
+func (ctx *Context) ownsStateKey(stateKey string) bool {
+	userPart := strings.TrimPrefix(stateKey, "_")
+	if !strings.HasPrefix(userPart, "@") {
+		return true
+	}
+	ownID := ctx.UserID.String()
+	return userPart == ownID || strings.HasPrefix(userPart, ownID+"_")
+}
+
+// allowed checks whether the given capability may be granted to a widget in the given context.
+func (ctx *Context) allowed(c Capability) bool {
+	switch c.Kind {
+	case KindAlwaysOnScreen, KindRequiresClient, KindTurnServers, KindSendDelayedEvent, KindUpdateDelayedEvent,
+		KindReadRelations, KindRequiresEncryption:
+		return true
+	case KindReceiveEvent, KindReceiveStateEvent, KindReceiveToDevice:
+		return c.Target != ""
+	case KindSendEvent:
+		return c.Target != "" && c.Target != event.EventRedaction.Type && c.Target != event.EventEncrypted.Type
+	case KindSendStateEvent:
+		return c.Target != "" && c.Detail != nil && !slices.Contains(protectedStateTypes, c.Target) && ctx.ownsStateKey(*c.Detail)
+	case KindSendToDevice:
+		return c.Target != "" && c.Target != event.ToDeviceEncrypted.Type
+	case KindTimeline:
+		return c.Target == ctx.RoomID.String()
+	default:
+		// Navigation, file access and unknown capabilities are not supported.
+		return false
+	}
+}
+
+// Negotiate parses the capabilities requested by a widget and returns the subset that can be granted.
+// Only capabilities that the user approved are granted, anything else the widget requested is dropped.
+// Capabilities are substituted with the current user and device ID where the widget API uses placeholders.
+func (ctx *Context) Negotiate(requested, approved []string) *Capabilities {
+	replacer := strings.NewReplacer("{userId}", ctx.UserID.String(), "{deviceId}", ctx.DeviceID.String())
+	approvedSet := make(map[string]struct{}, len(approved))
+	for _, raw := range approved {
+		approvedSet[ParseCapability(replacer.Replace(raw)).String()] = struct{}{}
+	}
+	granted := &Capabilities{ctx: ctx}
+	for _, raw := range requested {
+		c := ParseCapability(replacer.Replace(raw))
+		str := c.String()
+		if _, isApproved := approvedSet[str]; isApproved && ctx.allowed(c) && !slices.Contains(granted.Strings(), str) {
+			granted.list = append(granted.list, c)
+		}
+	}
+	return granted
+}
+
+// Capabilities is a set of capabilities that has been granted to a widget.
+type Capabilities struct {
+	ctx  *Context
+	list []Capability
+}
+
+// Strings returns the granted capabilities in the wire format, to be sent to the widget in notify_capabilities.
+func (caps *Capabilities) Strings() []string {
+	out := make([]string, len(caps.list))
+	for i, c := range caps.list {
+		out[i] = c.String()
+	}
+	return out
+}
+
+// Has checks if a capability of the given kind without a target has been granted.
+func (caps *Capabilities) Has(kind Kind) bool {
+	return slices.ContainsFunc(caps.list, func(c Capability) bool {
+		return c.Kind == kind
+	})
+}
+
+func (caps *Capabilities) hasEvent(kind Kind, evtType string, detail *string) bool {
+	return slices.ContainsFunc(caps.list, func(c Capability) bool {
+		return c.Kind == kind && c.matchesEvent(evtType, detail)
+	})
+}
+
+// CanAccessRoom checks if the widget may read from or write to the given room. Widgets can always access
+// the room they're embedded in, other rooms require a timeline capability.
+func (caps *Capabilities) CanAccessRoom(roomID id.RoomID) bool {
+	if roomID == "" || roomID == caps.ctx.RoomID {
+		return true
+	}
+	return slices.ContainsFunc(caps.list, func(c Capability) bool {
+		return c.Kind == KindTimeline && c.Target == roomID.String()
+	})
+}
+
+// CanSendEvent checks if the widget may send a message event of the given type into the given room.
+// The content is used for checking the msgtype of m.room.message events.
+func (caps *Capabilities) CanSendEvent(roomID id.RoomID, evtType event.Type, content []byte) bool {
+	return caps.CanAccessRoom(roomID) && caps.hasEvent(KindSendEvent, evtType.Type, getMsgType(evtType, content))
+}
+
+// CanSendStateEvent checks if the widget may send a state event with the given type and state key into the given room.
+func (caps *Capabilities) CanSendStateEvent(roomID id.RoomID, evtType event.Type, stateKey string) bool {
+	return caps.CanAccessRoom(roomID) &&
+		// Re-check the static rules in case the widget was granted a capability with a placeholder
+		!slices.Contains(protectedStateTypes, evtType.Type) && caps.ctx.ownsStateKey(stateKey) &&
+		caps.hasEvent(KindSendStateEvent, evtType.Type, &stateKey)
+}
+
+// CanReceiveEvent checks if the widget may see the given event. For encrypted events, the decrypted type
+// and content must be passed, as capabilities refer to the cleartext event types.
+func (caps *Capabilities) CanReceiveEvent(roomID id.RoomID, evtType event.Type, stateKey *string, content []byte) bool {
+	if !caps.CanAccessRoom(roomID) {
+		return false
+	} else if stateKey != nil {
+		return caps.hasEvent(KindReceiveStateEvent, evtType.Type, stateKey)
+	}
+	return caps.hasEvent(KindReceiveEvent, evtType.Type, getMsgType(evtType, content))
+}
+
+// CanReadState checks if the widget may read state events of the given type. If stateKey is nil,
+// the widget is asking for all state keys, which is only allowed if it has an unrestricted capability.
+func (caps *Capabilities) CanReadState(roomID id.RoomID, evtType event.Type, stateKey *string) bool {
+	return caps.CanAccessRoom(roomID) && caps.hasEvent(KindReceiveStateEvent, evtType.Type, stateKey)
+}
+
+// CanSendToDevice checks if the widget may send to-device events of the given type.
+func (caps *Capabilities) CanSendToDevice(evtType event.Type) bool {
+	return caps.hasEvent(KindSendToDevice, evtType.Type, nil)
+}
+
+// CanReceiveToDevice checks if the widget may receive to-device events of the given type.
+func (caps *Capabilities) CanReceiveToDevice(evtType event.Type) bool {
+	return caps.hasEvent(KindReceiveToDevice, evtType.Type, nil)
+}
+
+// WantsToDevice returns true if any to-device receive capability was granted,
+// which means the client has to include to-device events in sync payloads.
+func (caps *Capabilities) WantsToDevice() bool {
+	return caps.Has(KindReceiveToDevice)
+}
+
+func getMsgType(evtType event.Type, content []byte) *string {
+	if evtType != event.EventMessage || content == nil {
+		return nil
+	}
+	msgtype := gjson.GetBytes(content, "msgtype")
+	if msgtype.Type != gjson.String {
+		return nil
+	}
+	return &msgtype.Str
+}
@@ -0,0 +1,268 @@
+// Copyright (c) 2026 Tulir Asokan
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package widgetapi
+
+import (
+	"slices"
+	"testing"
+
+	"maunium.net/go/mautrix/event"
+	"maunium.net/go/mautrix/id"
+)
+
+const (
+	testRoomID   id.RoomID   = "!room:example.com"
+	testUserID   id.UserID   = "@alice:example.com"
+	testDeviceID id.DeviceID = "DEVICEID"
+)
+
+func ptr(s string) *string {
+	return &s
+}
+
+func testContext() *Context {
+	return &Context{RoomID: testRoomID, UserID: testUserID, DeviceID: testDeviceID}
+}
+
+// grant negotiates the given capabilities with all of them approved.
+func grant(caps ...string) *Capabilities {
+	return testContext().Negotiate(caps, caps)
+}
+
+func TestParseCapability(t *testing.T) {
+	tests := []struct {
+		raw    string
+		kind   Kind
+		target string
+		detail *string
+	}{
+		{"m.always_on_screen", KindAlwaysOnScreen, "", nil},
+		{"org.matrix.msc2762.send.event:m.room.message", KindSendEvent, "m.room.message", nil},
+		{"org.matrix.msc2762.send.event:m.room.message#m.text", KindSendEvent, "m.room.message", ptr("m.text")},
+		{"org.matrix.msc2762.receive.state_event:m.room.name#", KindReceiveStateEvent, "m.room.name", ptr("")},
+		{"org.matrix.msc2762.send.state_event:com.example#@alice:example.com", KindSendStateEvent, "com.example", ptr("@alice:example.com")},
+		{"org.matrix.msc2762.timeline:!room:example.com", KindTimeline, "!room:example.com", nil},
+		{"org.matrix.msc2762.timeline:*", KindTimeline, "*", nil},
+		{"org.matrix.msc3819.send.to_device:m.call.invite", KindSendToDevice, "m.call.invite", nil},
+	}
+	for _, test := range tests {
+		t.Run(test.raw, func(t *testing.T) {
+			c := ParseCapability(test.raw)
+			if c.Kind != test.kind || c.Target != test.target {
+				t.Errorf("ParseCapability(%q) = %q/%q, want %q/%q", test.raw, c.Kind, c.Target, test.kind, test.target)
+			}
+			if (c.Detail == nil) != (test.detail == nil) || (c.Detail != nil && *c.Detail != *test.detail) {
+				t.Errorf("ParseCapability(%q) detail = %v, want %v", test.raw, c.Detail, test.detail)
+			}
+			if str := c.String(); str != test.raw {
+				t.Errorf("ParseCapability(%q).String() = %q", test.raw, str)
+			}
+		})
+	}
+}
+
+func TestCapability_MatchesEvent(t *testing.T) {
+	tests := []struct {
+		name    string
+		raw     string
+		evtType string
+		detail  *string
+		want    bool
+	}{
+		{"any msgtype", "org.matrix.msc2762.send.event:m.room.message", "m.room.message", ptr("m.text"), true},
+		{"any msgtype without detail", "org.matrix.msc2762.send.event:m.room.message", "m.room.message", nil, true},
+		{"msgtype match", "org.matrix.msc2762.send.event:m.room.message#m.text", "m.room.message", ptr("m.text"), true},
+		{"msgtype mismatch", "org.matrix.msc2762.send.event:m.room.message#m.text", "m.room.message", ptr("m.notice"), false},
+		{"msgtype restricted without detail", "org.matrix.msc2762.send.event:m.room.message#m.text", "m.room.message", nil, false},
+		{"type mismatch", "org.matrix.msc2762.send.event:m.room.message", "m.sticker", nil, false},
+		{"state key match", "org.matrix.msc2762.receive.state_event:m.room.name#", "m.room.name", ptr(""), true},
+		{"state key mismatch", "org.matrix.msc2762.receive.state_event:com.example#foo", "com.example", ptr("bar"), false},
+		{"state key restricted without detail", "org.matrix.msc2762.receive.state_event:com.example#foo", "com.example", nil, false},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if got := ParseCapability(test.raw).matchesEvent(test.evtType, test.detail); got != test.want {
+				t.Errorf("matchesEvent(%q, %v) = %v, want %v", test.evtType, test.detail, got, test.want)
+			}
+		})
+	}
+}
+
+func TestNegotiate(t *testing.T) {
+	tests := []struct {
+		name      string
+		requested []string
+		approved  []string
+		want      []string
+	}{{
+		name:      "user and device substitution",
+		requested: []string{"org.matrix.msc2762.send.state_event:com.example#{userId}_{deviceId}"},
+		approved:  []string{"org.matrix.msc2762.send.state_event:com.example#{userId}_{deviceId}"},
+		want:      []string{"org.matrix.msc2762.send.state_event:com.example#@alice:example.com_DEVICEID"},
+	}, {
+		name:      "approval after substitution",
+		requested: []string{"org.matrix.msc2762.send.state_event:com.example#{userId}"},
+		approved:  []string{"org.matrix.msc2762.send.state_event:com.example#@alice:example.com"},
+		want:      []string{"org.matrix.msc2762.send.state_event:com.example#@alice:example.com"},
+	}, {
+		name:      "unapproved capabilities are dropped",
+		requested: []string{"m.always_on_screen", "org.matrix.msc2762.receive.event:m.room.message", "org.matrix.msc3846.turn_servers"},
+		approved:  []string{"org.matrix.msc2762.receive.event:m.room.message"},
+		want:      []string{"org.matrix.msc2762.receive.event:m.room.message"},
+	}, {
+		name:      "nothing approved",
+		requested: []string{"org.matrix.msc3819.send.to_device:m.call.invite", "org.matrix.msc3846.turn_servers"},
+		approved:  nil,
+		want:      []string{},
+	}, {
+		name:      "approved but not requested",
+		requested: []string{"m.always_on_screen"},
+		approved:  []string{"m.always_on_screen", "org.matrix.msc3846.turn_servers"},
+		want:      []string{"m.always_on_screen"},
+	}, {
+		name:      "duplicates",
+		requested: []string{"m.always_on_screen", "m.always_on_screen"},
+		approved:  []string{"m.always_on_screen"},
+		want:      []string{"m.always_on_screen"},
+	}, {
+		name: "disallowed capabilities",
+		requested: []string{
+			"org.matrix.msc2762.send.event:m.room.redaction",
+			"org.matrix.msc2762.send.event:m.room.encrypted",
+			"org.matrix.msc3819.send.to_device:m.room.encrypted",
+			"org.matrix.msc2762.timeline:!other:example.com",
+			"org.matrix.msc2931.navigate",
+			"org.matrix.msc2762.send.state_event:com.example",
+		},
+		approved: []string{
+			"org.matrix.msc2762.send.event:m.room.redaction",
+			"org.matrix.msc2762.send.event:m.room.encrypted",
+			"org.matrix.msc3819.send.to_device:m.room.encrypted",
+			"org.matrix.msc2762.timeline:!other:example.com",
+			"org.matrix.msc2931.navigate",
+			"org.matrix.msc2762.send.state_event:com.example",
+		},
+		want: []string{},
+	}}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			got := testContext().Negotiate(test.requested, test.approved).Strings()
+			if !slices.Equal(got, test.want) {
+				t.Errorf("Negotiate() = %v, want %v", got, test.want)
+			}
+		})
+	}
+}
+
+func TestContext_OwnsStateKey(t *testing.T) {
+	tests := []struct {
+		stateKey string
+		want     bool
+	}{
+		{"", true},
+		{"foo", true},
+		{"@alice:example.com", true},
+		{"_@alice:example.com", true},
+		{"_@alice:example.com_DEVICEID", true},
+		{"@alice:example.com_DEVICEID", true},
+		{"@bob:example.com", false},
+		{"_@bob:example.com_DEVICEID", false},
+		{"@alice:example.com.evil", false},
+	}
+	ctx := testContext()
+	for _, test := range tests {
+		t.Run(test.stateKey, func(t *testing.T) {
+			if got := ctx.ownsStateKey(test.stateKey); got != test.want {
+				t.Errorf("ownsStateKey(%q) = %v, want %v", test.stateKey, got, test.want)
+			}
+		})
+	}
+}
+
+func TestCapabilities_CanSendStateEvent_Protected(t *testing.T) {
+	for _, evtType := range protectedStateTypes {
+		t.Run(evtType, func(t *testing.T) {
+			raw := "org.matrix.msc2762.send.state_event:" + evtType + "#"
+			caps := grant(raw)
+			if len(caps.Strings()) != 0 {
+				t.Errorf("Protected state capability %q was granted", raw)
+			}
+			caps.list = append(caps.list, ParseCapability(raw))
+			if caps.CanSendStateEvent(testRoomID, event.Type{Type: evtType, Class: event.StateEventType}, "") {
+				t.Errorf("Sending protected state event %s was allowed", evtType)
+			}
+		})
+	}
+	caps := grant("org.matrix.msc2762.send.state_event:com.example#{userId}")
+	if !caps.CanSendStateEvent(testRoomID, event.Type{Type: "com.example", Class: event.StateEventType}, testUserID.String()) {
+		t.Error("Sending own state event was not allowed")
+	}
+	if caps.CanSendStateEvent(testRoomID, event.Type{Type: "com.example", Class: event.StateEventType}, "@bob:example.com") {
+		t.Error("Sending state event with another user's key was allowed")
+	}
+}
+
+func TestCapabilities_CanAccessRoom(t *testing.T) {
+	tests := []struct {
+		name   string
+		caps   []string
+		roomID id.RoomID
+		want   bool
+	}{
+		{"embedded room", nil, testRoomID, true},
+		{"empty room ID", nil, "", true},
+		{"foreign room", nil, "!other:example.com", false},
+		{"foreign room with other timeline", []string{"org.matrix.msc2762.timeline:!third:example.com"}, "!other:example.com", false},
+		{"embedded room timeline", []string{"org.matrix.msc2762.timeline:!room:example.com"}, testRoomID, true},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if got := grant(test.caps...).CanAccessRoom(test.roomID); got != test.want {
+				t.Errorf("CanAccessRoom(%q) = %v, want %v", test.roomID, got, test.want)
+			}
+		})
+	}
+}
+
+func TestCapabilities_CanReadState(t *testing.T) {
+	evtType := event.Type{Type: "com.example", Class: event.StateEventType}
+	tests := []struct {
+		name     string
+		caps     []string
+		roomID   id.RoomID
+		stateKey *string
+		want     bool
+	}{
+		{"unrestricted, all keys", []string{"org.matrix.msc2762.receive.state_event:com.example"}, testRoomID, nil, true},
+		{"unrestricted, single key", []string{"org.matrix.msc2762.receive.state_event:com.example"}, testRoomID, ptr("foo"), true},
+		{"restricted, all keys", []string{"org.matrix.msc2762.receive.state_event:com.example#foo"}, testRoomID, nil, false},
+		{"restricted, matching key", []string{"org.matrix.msc2762.receive.state_event:com.example#foo"}, testRoomID, ptr("foo"), true},
+		{"restricted, other key", []string{"org.matrix.msc2762.receive.state_event:com.example#foo"}, testRoomID, ptr("bar"), false},
+		{"message event capability", []string{"org.matrix.msc2762.receive.event:com.example"}, testRoomID, nil, false},
+		{"foreign room", []string{"org.matrix.msc2762.receive.state_event:com.example"}, "!other:example.com", nil, false},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if got := grant(test.caps...).CanReadState(test.roomID, evtType, test.stateKey); got != test.want {
+				t.Errorf("CanReadState() = %v, want %v", got, test.want)
+			}
+		})
+	}
+}
+
+func TestCapabilities_CanReceiveEvent(t *testing.T) {
+	caps := grant("org.matrix.msc2762.receive.event:m.room.message#m.text")
+	if !caps.CanReceiveEvent(testRoomID, event.EventMessage, nil, []byte(`{"msgtype":"m.text"}`)) {
+		t.Error("m.text message was not allowed")
+	}
+	if caps.CanReceiveEvent(testRoomID, event.EventMessage, nil, []byte(`{"msgtype":"m.notice"}`)) {
+		t.Error("m.notice message was allowed")
+	}
+	if caps.CanReceiveEvent(testRoomID, event.EventMessage, nil, []byte(`{}`)) {
+		t.Error("Message without msgtype was allowed")
+	}
+}
@@ -0,0 +1,157 @@
+// gomuks - A terminal Matrix client written in Go.
+// Copyright (C) 2026 Tulir Asokan
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+package tui
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"maunium.net/go/mautrix"
+	"maunium.net/go/mautrix/id"
+
+	"go.mau.fi/gomuks/pkg/hicli/jsoncmd"
+	"go.mau.fi/gomuks/tui/debug"
+)
+
+// uiaFailureMessage returns a human-readable description of why a UIA request failed,
+// and whether the failure was caused by a wrong password.
+func uiaFailureMessage(resp *jsoncmd.UIAResponse) (string, bool) {
+	if resp.UIA == nil {
+		return "unknown error", false
+	} else if resp.UIA.ErrCode == mautrix.MForbidden.ErrCode {
+		return "incorrect password", true
+	}
+	stages := make([]string, 0, len(resp.UIA.Flows))
+	for _, flow := range resp.UIA.Flows {
+		flowStages := make([]string, len(flow.Stages))
+		for i, stage := range flow.Stages {
+			flowStages[i] = string(stage)
+		}
+		stages = append(stages, strings.Join(flowStages, " -> "))
+	}
+	return "server requires unsupported authentication stages: " + strings.Join(stages, ", "), false
+}
+
+func (view *RoomView) ChangePassword(logoutDevices bool) {
+	defer debug.Recover()
+	main := view.parent
+	oldPassword, ok := main.AskPassword("Change password", "current password", "", false)
+	if !ok {
+		return
+	}
+	newPassword, ok := main.AskPassword("Change password", "new password", "", true)
+	if !ok {
+		return
+	}
+	for {
+		resp, err := main.matrix.ChangePassword(context.TODO(), &jsoncmd.ChangePasswordParams{
+			OldPassword:   oldPassword,
+			NewPassword:   newPassword,
+			LogoutDevices: logoutDevices,
+		})
+		if err != nil {
+			view.AddServiceMessage("Failed to change password: %v", err)
+		} else if !resp.Success {
+			msg, wrongPassword := uiaFailureMessage(resp)
+			view.AddServiceMessage("Failed to change password: %s", msg)
+			if wrongPassword {
+				main.parent.Render()
+				oldPassword, ok = main.AskPassword("Change password", "current password", "", false)
+				if ok {
+					continue
+				}
+			}
+		} else {
+			view.AddServiceMessage("Password changed successfully")
+		}
+		main.parent.Render()
+		return
+	}
+}
+
+func (view *RoomView) DeactivateAccount(confirmUserID id.UserID, erase bool) {
+	defer debug.Recover()
+	main := view.parent
+	if confirmUserID != main.matrix.UserID {
+		view.AddServiceMessage(
+			"Deactivating your account is permanent and can't be undone. To confirm, run /deactivate %s",
+			main.matrix.UserID,
+		)
+		main.parent.Render()
+		return
+	}
+	for {
+		password, ok := main.AskPassword("Deactivate account", "password", "", false)
+		if !ok {
+			return
+		}
+		resp, err := main.matrix.DeactivateAccount(context.TODO(), &jsoncmd.DeactivateAccountParams{
+			Password: password,
+			Erase:    erase,
+		})
+		if err != nil {
+			view.AddServiceMessage("Failed to deactivate account: %v", err)
+		} else if !resp.Success {
+			msg, wrongPassword := uiaFailureMessage(resp)
+			view.AddServiceMessage("Failed to deactivate account: %s", msg)
+			if wrongPassword {
+				main.parent.Render()
+				continue
+			}
+		} else {
+			view.AddServiceMessage("Account deactivated")
+		}
+		main.parent.Render()
+		return
+	}
+}
+
+// describeSession returns the device ID and name of the current session, which are needed to find it
+// in the session lists of other clients, e.g. when verifying it.
+func describeSession(state *jsoncmd.ClientState) string {
+	if state.DeviceName == "" {
+		return state.DeviceID.String()
+	}
+	return fmt.Sprintf("%s (%s)", state.DeviceID, state.DeviceName)
+}
+
+// SetDeviceName renames the current session, or shows the current name if the new name is empty.
+func (view *RoomView) SetDeviceName(name string) {
+	defer debug.Recover()
+	main := view.parent
+	name = strings.TrimSpace(name)
+	if name == "" {
+		view.AddServiceMessage("This session is %s", describeSession(&main.matrix.ClientState))
+	} else if err := main.matrix.SetDeviceName(context.TODO(), &jsoncmd.SetDeviceNameParams{Name: name}); err != nil {
+		view.AddServiceMessage("Failed to set session name: %v", err)
+	} else {
+		view.AddServiceMessage("Session name changed to %s", name)
+	}
+	main.parent.Render()
+}
+
+func (view *RoomView) SetNick(name string) {
+	defer debug.Recover()
+	err := view.parent.SetGlobalDisplayname(name)
+	if err != nil {
+		view.AddServiceMessage("%v", err)
+	} else {
+		view.AddServiceMessage("Display name changed to %s", strings.TrimSpace(name))
+	}
+	view.parent.parent.Render()
+}
@@ -0,0 +1,76 @@
+// gomuks - A terminal Matrix client written in Go.
+// Copyright (C) 2026 Tulir Asokan
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+package tui
+
+import (
+	"context"
+	"time"
+
+	"go.mau.fi/gomuks/pkg/hicli/jsoncmd"
+	"go.mau.fi/gomuks/tui/debug"
+)
+
+// RejoinRoom joins an archived room again. The room is moved back to the normal room list
+// when it comes down in the next sync.
+func (view *RoomView) RejoinRoom() {
+	defer debug.Recover()
+	main := view.parent
+	if !view.Room.Archived {
+		view.AddServiceMessage("You're already in this room")
+		main.parent.Render()
+		return
+	}
+	roomID := view.Room.ID
+	_, err := main.matrix.JoinRoom(context.TODO(), &jsoncmd.JoinRoomParams{
+		RoomIDOrAlias: roomID.String(),
+	})
+	if err != nil {
+		view.AddServiceMessage("Failed to rejoin room: %v", err)
+		main.parent.Render()
+		return
+	}
+	main.roomList.RemoveArchived(roomID)
+	// Wait for the sync to replace the archived room with the joined one
+	for range 20 {
+		if room := main.matrix.GetRoom(roomID); room != nil && !room.Archived {
+			main.SwitchRoom(roomID)
+			return
+		}
+		time.Sleep(500 * time.Millisecond)
+	}
+}
+
+// ForgetRoom forgets an archived room, which deletes all of its local history.
+func (view *RoomView) ForgetRoom() {
+	defer debug.Recover()
+	main := view.parent
+	if !view.Room.Archived {
+		view.AddServiceMessage("Leave the room before forgetting it")
+		main.parent.Render()
+		return
+	}
+	roomID := view.Room.ID
+	err := main.matrix.ForgetRoom(context.TODO(), &jsoncmd.ForgetRoomParams{RoomID: roomID})
+	if err != nil {
+		view.AddServiceMessage("Failed to forget room: %v", err)
+		main.parent.Render()
+		return
+	}
+	main.roomList.RemoveArchived(roomID)
+	main.matrix.RemoveArchivedRoom(roomID)
+	main.SwitchRoom(main.roomList.Next())
+}
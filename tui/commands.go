@@ -19,10 +19,13 @@ package tui
 import (
 	"context"
 	"fmt"
+	"slices"
+	"strings"
 
 	"github.com/tidwall/gjson"
 	"maunium.net/go/mautrix/event"
 	"maunium.net/go/mautrix/event/cmdschema"
+	"maunium.net/go/mautrix/id"
 
 	"go.mau.fi/gomuks/pkg/hicli/cmdspec"
 	"go.mau.fi/gomuks/pkg/hicli/jsoncmd"
@@ -37,6 +40,47 @@ const (
 	CmdQuit   = "quit"
 	CmdEdit   = "edit"
 	CmdCopy   = "copy"
+	CmdPaste  = "paste"
+	CmdSource = "source"
+	CmdReveal = "reveal"
+	CmdQuote  = "quote"
+
+	CmdQuoteNoReply = "quote-noreply"
+
+	CmdSpoiler   = "spoiler"
+	CmdNoPreview = "nopreview"
+	CmdFixed     = "fixed"
+
+	CmdChangePassword    = "password"
+	CmdDeactivateAccount = "deactivate"
+	CmdMyProfile         = "myprofile"
+	Cmd3PID              = "3pid"
+	CmdNick              = "nick"
+	CmdDeviceName        = "devicename"
+	CmdDirectory         = "directory"
+	CmdLogs              = "logs"
+	CmdLogLevel          = "loglevel"
+	CmdStats             = "stats"
+	CmdReactions         = "reactions"
+	CmdArchived          = "archived"
+	CmdRejoin            = "rejoin"
+	CmdForget            = "forget"
+	CmdLeaveRooms        = "leave-rooms"
+	CmdHide              = "hide"
+	CmdUnhide            = "unhide"
+	CmdNotifySound       = "notify-sound"
+	CmdSaveView          = "save-view"
+	CmdFlushQueue        = "flush-queue"
+	CmdPrivacy           = "privacy"
+	CmdEncrypt           = "encrypt"
+	CmdSessionDevices    = "devices-for-session"
+	CmdMsgType           = "msgtype"
+	CmdJSON              = "json"
+	CmdRawState          = "rawstate"
+	CmdMembers           = "members"
+	CmdPolicy            = "policy"
+	CmdFilter            = "filter"
+	CmdLineage           = "lineage"
 )
 
 var LocalCommands = []*cmdschema.EventContent{{
@@ -73,14 +117,329 @@ var LocalCommands = []*cmdschema.EventContent{{
 	Description: event.MakeExtensibleText("Start editing an event"),
 }, {
 	Command:     CmdCopy,
-	Description: event.MakeExtensibleText("Copy text from an event"),
+	Description: event.MakeExtensibleText("Copy the text, markdown source, ID or link of an event"),
 	Parameters: []*cmdschema.Parameter{{
+		Key:          "what",
+		Schema:       cmdschema.Enum("text", "source", "id", "link"),
+		Description:  event.MakeExtensibleText("What to copy from the event"),
+		Optional:     true,
+		DefaultValue: "text",
+	}, {
 		Key:          "register",
 		Schema:       cmdschema.Enum("clipboard", "primary"),
 		Optional:     true,
 		DefaultValue: "clipboard",
 	}},
-	TailParam: "clipboard",
+}, {
+	Command:     CmdSource,
+	Description: event.MakeExtensibleText("View the raw source of an event"),
+}, {
+	Command:     CmdReveal,
+	Description: event.MakeExtensibleText("Show or hide the content of a message removed by a ban"),
+}, {
+	Command:     CmdQuote,
+	Description: event.MakeExtensibleText("Quote lines of an event and reply to it"),
+	Parameters: []*cmdschema.Parameter{{
+		Key:         "lines",
+		Schema:      cmdschema.PrimitiveTypeString.Schema(),
+		Description: event.MakeExtensibleText("The lines to quote, like 3 or 2-4. If omitted, the lines can be picked with the arrow keys"),
+		Optional:    true,
+	}},
+}, {
+	Command:     CmdQuoteNoReply,
+	Description: event.MakeExtensibleText("Quote lines of an event without replying to it"),
+	Parameters: []*cmdschema.Parameter{{
+		Key:         "lines",
+		Schema:      cmdschema.PrimitiveTypeString.Schema(),
+		Description: event.MakeExtensibleText("The lines to quote, like 3 or 2-4. If omitted, the lines can be picked with the arrow keys"),
+		Optional:    true,
+	}},
+}, {
+	Command:     CmdPaste,
+	Description: event.MakeExtensibleText("Send an image from the clipboard"),
+	Parameters: []*cmdschema.Parameter{{
+		Key:         "caption",
+		Schema:      cmdschema.PrimitiveTypeString.Schema(),
+		Description: event.MakeExtensibleText("A caption to send with the image"),
+		Optional:    true,
+	}},
+	TailParam: "caption",
+}, {
+	Command:     CmdSpoiler,
+	Description: event.MakeExtensibleText("Send a message hidden behind a spoiler"),
+	Parameters: []*cmdschema.Parameter{{
+		Key:         "text",
+		Schema:      cmdschema.PrimitiveTypeString.Schema(),
+		Description: event.MakeExtensibleText("The text to hide, optionally prefixed with a reason and a colon"),
+	}},
+	TailParam: "text",
+}, {
+	Command:     CmdNoPreview,
+	Description: event.MakeExtensibleText("Send a message without URL previews"),
+	Parameters: []*cmdschema.Parameter{{
+		Key:         "text",
+		Schema:      cmdschema.PrimitiveTypeString.Schema(),
+		Description: event.MakeExtensibleText("The message to send"),
+	}},
+	TailParam: "text",
+}, {
+	Command:     CmdFixed,
+	Description: event.MakeExtensibleText("Send the text as a code block"),
+	Parameters: []*cmdschema.Parameter{{
+		Key:         "text",
+		Schema:      cmdschema.PrimitiveTypeString.Schema(),
+		Description: event.MakeExtensibleText("The text to send, formatting is not applied"),
+	}},
+	TailParam: "text",
+}, {
+	Command:     CmdChangePassword,
+	Description: event.MakeExtensibleText("Change your account password"),
+	Parameters: []*cmdschema.Parameter{{
+		Key:         "logout_devices",
+		Schema:      cmdschema.PrimitiveTypeBoolean.Schema(),
+		Description: event.MakeExtensibleText("Log out all other devices after changing the password"),
+		Optional:    true,
+	}},
+}, {
+	Command:     CmdDeactivateAccount,
+	Description: event.MakeExtensibleText("Permanently deactivate your account"),
+	Parameters: []*cmdschema.Parameter{{
+		Key:         "user_id",
+		Schema:      cmdschema.PrimitiveTypeUserID.Schema(),
+		Description: event.MakeExtensibleText("Your own user ID to confirm the deactivation"),
+		Optional:    true,
+	}, {
+		Key:         "erase",
+		Schema:      cmdschema.PrimitiveTypeBoolean.Schema(),
+		Description: event.MakeExtensibleText("Ask the server to forget sent messages"),
+		Optional:    true,
+	}},
+}, {
+	Command:     CmdMyProfile,
+	Description: event.MakeExtensibleText("View and change your global display name and avatar"),
+}, {
+	Command:     Cmd3PID,
+	Description: event.MakeExtensibleText("View, add and remove the email addresses linked to your account"),
+}, {
+	Command:     CmdNick,
+	Description: event.MakeExtensibleText("Set your global display name"),
+	Parameters: []*cmdschema.Parameter{{
+		Key:         "name",
+		Schema:      cmdschema.PrimitiveTypeString.Schema(),
+		Description: event.MakeExtensibleText("The new display name"),
+	}},
+	TailParam: "name",
+}, {
+	Command:     CmdDeviceName,
+	Description: event.MakeExtensibleText("Show or change the name of this session"),
+	Parameters: []*cmdschema.Parameter{{
+		Key:         "name",
+		Schema:      cmdschema.PrimitiveTypeString.Schema(),
+		Description: event.MakeExtensibleText("The new session name shown to your other clients"),
+		Optional:    true,
+	}},
+	TailParam: "name",
+}, {
+	Command:     CmdDirectory,
+	Description: event.MakeExtensibleText("Browse the public room directory"),
+	Parameters: []*cmdschema.Parameter{{
+		Key:         "server",
+		Schema:      cmdschema.PrimitiveTypeServerName.Schema(),
+		Description: event.MakeExtensibleText("The server whose directory to browse"),
+		Optional:    true,
+	}},
+}, {
+	Command:     CmdLogs,
+	Description: event.MakeExtensibleText("View recent log entries"),
+}, {
+	Command:     CmdLogLevel,
+	Description: event.MakeExtensibleText("Change the log level until gomuks is restarted"),
+	Parameters: []*cmdschema.Parameter{{
+		Key:         "level",
+		Schema:      cmdschema.Enum("trace", "debug", "info", "warn", "error", "reset"),
+		Description: event.MakeExtensibleText("The new minimum log level, or reset to remove a component override"),
+	}, {
+		Key:         "component",
+		Schema:      cmdschema.PrimitiveTypeString.Schema(),
+		Description: event.MakeExtensibleText("The component to change the level of, like sync, crypto or rpc"),
+		Optional:    true,
+	}},
+	TailParam: "component",
+}, {
+	Command:     CmdStats,
+	Description: event.MakeExtensibleText("Show cache sizes and backend runtime stats for diagnosing performance problems"),
+}, {
+	Command:     CmdReactions,
+	Description: event.MakeExtensibleText("Show recent reactions to your messages"),
+}, {
+	Command:     CmdArchived,
+	Description: event.MakeExtensibleText("Show or hide rooms you have left in the room list"),
+}, {
+	Command:     CmdRejoin,
+	Description: event.MakeExtensibleText("Join the current archived room again"),
+}, {
+	Command:     CmdForget,
+	Description: event.MakeExtensibleText("Forget the current archived room and delete its local history"),
+}, {
+	Command:     CmdLeaveRooms,
+	Description: event.MakeExtensibleText("Leave all rooms in a space, all rooms with only users from a server, or a list of rooms"),
+	Parameters: []*cmdschema.Parameter{{
+		Key:         "target",
+		Schema:      cmdschema.PrimitiveTypeString.Schema(),
+		Description: event.MakeExtensibleText("A space ID, a server name or comma-separated room IDs"),
+	}, {
+		Key:         "reason",
+		Schema:      cmdschema.PrimitiveTypeString.Schema(),
+		Description: event.MakeExtensibleText("Reason for leaving"),
+		Optional:    true,
+	}},
+	TailParam: "reason",
+}, {
+	Command:     CmdHide,
+	Description: event.MakeExtensibleText("Hide the current room from the room list and notifications"),
+}, {
+	Command:     CmdUnhide,
+	Description: event.MakeExtensibleText("Show the current room in the room list again"),
+}, {
+	Command:     CmdNotifySound,
+	Description: event.MakeExtensibleText("Always or never play a sound for notifications in the current room"),
+	Parameters: []*cmdschema.Parameter{{
+		Key:         "mode",
+		Schema:      cmdschema.Enum("always", "never", "default"),
+		Description: event.MakeExtensibleText("Whether to always or never play a sound, or follow the global settings"),
+	}},
+}, {
+	Command:     CmdSaveView,
+	Description: event.MakeExtensibleText("Save the loaded conversation as plain text"),
+	Parameters: []*cmdschema.Parameter{{
+		Key:         "path",
+		Schema:      cmdschema.PrimitiveTypeString.Schema(),
+		Description: event.MakeExtensibleText("The file to save to"),
+	}},
+	TailParam: "path",
+}, {
+	Command:     CmdFlushQueue,
+	Description: event.MakeExtensibleText("Drop all messages waiting to be sent in the current room"),
+}, {
+	Command:     CmdPrivacy,
+	Description: event.MakeExtensibleText("View and change who can join the current room and read its history"),
+}, {
+	Command:     CmdEncrypt,
+	Description: event.MakeExtensibleText("Enable end-to-end encryption in the current room"),
+	Parameters: []*cmdschema.Parameter{{
+		Key:         "confirm",
+		Schema:      cmdschema.Enum("confirm"),
+		Description: event.MakeExtensibleText("Confirm that encryption can't be disabled afterwards"),
+		Optional:    true,
+	}},
+}, {
+	Command:     CmdSessionDevices,
+	Description: event.MakeExtensibleText("Show which of your devices have a megolm session of the current room"),
+	Parameters: []*cmdschema.Parameter{{
+		Key:         "session_id",
+		Schema:      cmdschema.PrimitiveTypeString.Schema(),
+		Description: event.MakeExtensibleText("The session ID, as shown in the view source modal"),
+	}},
+}, {
+	Command:     CmdMsgType,
+	Description: event.MakeExtensibleText("Send a message with a custom msgtype"),
+	Parameters: []*cmdschema.Parameter{{
+		Key:         "msgtype",
+		Schema:      cmdschema.PrimitiveTypeString.Schema(),
+		Description: event.MakeExtensibleText("The msgtype to send, like m.notice"),
+	}, {
+		Key:         "text",
+		Schema:      cmdschema.PrimitiveTypeString.Schema(),
+		Description: event.MakeExtensibleText("The message to send"),
+	}},
+	TailParam: "text",
+}, {
+	Command:     CmdJSON,
+	Description: event.MakeExtensibleText("Send an event with raw JSON content"),
+	Parameters: []*cmdschema.Parameter{{
+		Key:          "type",
+		Schema:       cmdschema.PrimitiveTypeString.Schema(),
+		Description:  event.MakeExtensibleText("The event type to send"),
+		Optional:     true,
+		DefaultValue: event.EventMessage.Type,
+	}},
+}, {
+	Command:     CmdRawState,
+	Description: event.MakeExtensibleText("Send a state event with raw JSON content"),
+	Parameters: []*cmdschema.Parameter{{
+		Key:         "type",
+		Schema:      cmdschema.PrimitiveTypeString.Schema(),
+		Description: event.MakeExtensibleText("The state event type to send"),
+	}, {
+		Key:         "state_key",
+		Schema:      cmdschema.PrimitiveTypeString.Schema(),
+		Description: event.MakeExtensibleText("The state key, empty by default"),
+		Optional:    true,
+	}},
+}, {
+	Command:     CmdMembers,
+	Description: event.MakeExtensibleText("Export the member list of the current room into a CSV or JSON file"),
+	Parameters: []*cmdschema.Parameter{{
+		Key:         "action",
+		Schema:      cmdschema.Enum("export"),
+		Description: event.MakeExtensibleText("The action to perform"),
+	}, {
+		Key:         "path",
+		Schema:      cmdschema.PrimitiveTypeString.Schema(),
+		Description: event.MakeExtensibleText("The file to export into, JSON is used if the name ends with .json"),
+	}, {
+		Key:         "memberships",
+		Schema:      cmdschema.PrimitiveTypeString.Schema(),
+		Description: event.MakeExtensibleText("Only include these memberships, like join or ban, separated by spaces"),
+		Optional:    true,
+	}},
+	TailParam: "memberships",
+}, {
+	Command:     CmdPolicy,
+	Description: event.MakeExtensibleText("Enforce ban rules from policy list rooms in this room or all rooms"),
+	Parameters: []*cmdschema.Parameter{{
+		Key:         "action",
+		Schema:      cmdschema.Enum("subscribe", "unsubscribe", "rules", "dryrun"),
+		Description: event.MakeExtensibleText("The action to perform"),
+	}, {
+		Key:         "room",
+		Schema:      cmdschema.PrimitiveTypeString.Schema(),
+		Description: event.MakeExtensibleText("The ID of the policy list room"),
+		Optional:    true,
+	}, {
+		Key:         "scope",
+		Schema:      cmdschema.Enum("here", "all"),
+		Description: event.MakeExtensibleText("Whether to apply to the current room or all joined rooms, defaults to here"),
+		Optional:    true,
+	}, {
+		Key:         "mode",
+		Schema:      cmdschema.Enum("ban", "flag"),
+		Description: event.MakeExtensibleText("Whether to ban matching users or only flag them, defaults to ban"),
+		Optional:    true,
+	}},
+}, {
+	Command:     CmdFilter,
+	Description: event.MakeExtensibleText("Only show matching messages in the timeline"),
+	Parameters: []*cmdschema.Parameter{{
+		Key:         "mode",
+		Schema:      cmdschema.Enum(string(FilterMedia), string(FilterMentions), string(FilterFrom), string(FilterText), "clear"),
+		Description: event.MakeExtensibleText("What to filter by, or clear to show all messages again"),
+	}, {
+		Key:         "query",
+		Schema:      cmdschema.PrimitiveTypeString.Schema(),
+		Description: event.MakeExtensibleText("The user ID for the from filter or the text to search for"),
+		Optional:    true,
+	}},
+	TailParam: "query",
+}, {
+	Command:     CmdLineage,
+	Description: event.MakeExtensibleText("Continue loading history from the rooms this room was upgraded from"),
+	Parameters: []*cmdschema.Parameter{{
+		Key:         "enabled",
+		Schema:      cmdschema.Enum("on", "off"),
+		Description: event.MakeExtensibleText("Whether to enable or disable continuous history, toggles if omitted"),
+		Optional:    true,
+	}},
 }, {
 	Command:     CmdQuit,
 	Description: event.MakeExtensibleText("Quit gomuks terminal"),
@@ -95,6 +454,11 @@ func (view *RoomView) allCommands(yield func(command *store.WrappedCommand) bool
 			return
 		}
 	}
+	for cmd := range view.snippetCommands {
+		if !yield(cmd) {
+			return
+		}
+	}
 	for _, cmd := range cmdspec.CommandDefinitions {
 		if !yield(&store.WrappedCommand{
 			EventContent: cmd,
@@ -110,9 +474,45 @@ func (view *RoomView) allCommands(yield func(command *store.WrappedCommand) bool
 	}
 }
 
+// CompleteCommand returns the commands whose name starts with the given prefix, sorted by name.
+func (view *RoomView) CompleteCommand(prefix string) []*cmdschema.EventContent {
+	seen := make(map[string]struct{})
+	var matches []*cmdschema.EventContent
+	add := func(cmd *cmdschema.EventContent) {
+		if _, alreadySeen := seen[cmd.Command]; alreadySeen || !strings.HasPrefix(cmd.Command, prefix) {
+			return
+		}
+		seen[cmd.Command] = struct{}{}
+		matches = append(matches, cmd)
+	}
+	for _, cmd := range PassthroughCommands {
+		add(cmd)
+	}
+	for cmd := range view.allCommands {
+		add(cmd.EventContent)
+	}
+	slices.SortFunc(matches, func(a, b *cmdschema.EventContent) int {
+		return strings.Compare(a.Command, b.Command)
+	})
+	return matches
+}
+
+var copyTargets = map[string]SelectReason{
+	"":       SelectCopy,
+	"text":   SelectCopy,
+	"source": SelectCopySrc,
+	"id":     SelectCopyID,
+	"link":   SelectCopyLink,
+}
+
 var cmdSigils = []string{"/"}
 
 func (view *RoomView) ParseCommand(input string) (*event.MessageEventContent, error) {
+	if isPassthroughCommand(input) {
+		// Formatting prefixes are handled by the backend, so don't let snippets or bot commands with the same name
+		// intercept them.
+		return nil, nil
+	}
 	var firstError error
 	view.Room.GetPowerLevels()
 	for cmd := range view.allCommands {
@@ -158,11 +558,127 @@ func (view *RoomView) handleInternalCommand(cmd *event.MSC4391BotCommandInput) b
 	case CmdEdit:
 		view.StartSelecting(SelectEdit, "")
 	case CmdCopy:
-		view.StartSelecting(SelectCopy, gjson.GetBytes(cmd.Arguments, "register").Str)
+		view.StartSelecting(copyTargets[gjson.GetBytes(cmd.Arguments, "what").Str], gjson.GetBytes(cmd.Arguments, "register").Str)
+	case CmdSource:
+		view.StartSelecting(SelectSource, "")
+	case CmdReveal:
+		view.StartSelecting(SelectReveal, "")
+	case CmdQuote:
+		view.StartSelecting(SelectQuote, gjson.GetBytes(cmd.Arguments, "lines").Str)
+	case CmdQuoteNoReply:
+		view.StartSelecting(SelectQuoteOnly, gjson.GetBytes(cmd.Arguments, "lines").Str)
+	case CmdPaste:
+		view.PasteImage(gjson.GetBytes(cmd.Arguments, "caption").Str)
+	case CmdSpoiler:
+		view.SendSpoiler(gjson.GetBytes(cmd.Arguments, "text").Str)
+	case CmdFixed:
+		if text := gjson.GetBytes(cmd.Arguments, "text").Str; text != "" {
+			go view.SendMessage(event.MsgText, "/html "+fixedHTML(text))
+		}
+	case CmdNoPreview:
+		if text := gjson.GetBytes(cmd.Arguments, "text").Str; text != "" {
+			view.sendMessage(text, true)
+		}
+	case CmdChangePassword:
+		view.ChangePassword(gjson.GetBytes(cmd.Arguments, "logout_devices").Bool())
+	case CmdDeactivateAccount:
+		view.DeactivateAccount(
+			id.UserID(gjson.GetBytes(cmd.Arguments, "user_id").Str),
+			gjson.GetBytes(cmd.Arguments, "erase").Bool(),
+		)
+	case CmdMyProfile:
+		view.parent.ShowModal(NewProfileModal(view.parent, view))
+		view.parent.parent.Render()
+	case Cmd3PID:
+		view.parent.ShowModal(NewThreePIDModal(view.parent))
+		view.parent.parent.Render()
+	case CmdNick:
+		go view.SetNick(gjson.GetBytes(cmd.Arguments, "name").Str)
+	case CmdDeviceName:
+		go view.SetDeviceName(gjson.GetBytes(cmd.Arguments, "name").Str)
+	case CmdDirectory:
+		view.parent.ShowModal(NewDirectoryModal(view.parent, gjson.GetBytes(cmd.Arguments, "server").Str, 80, 30))
+		view.parent.parent.Render()
+	case CmdLogs:
+		view.parent.ShowModal(NewLogsModal(view.parent, 100, 30))
+		view.parent.parent.Render()
+	case CmdLogLevel:
+		view.SetLogLevel(gjson.GetBytes(cmd.Arguments, "level").Str, gjson.GetBytes(cmd.Arguments, "component").Str)
+	case CmdStats:
+		go view.ShowDebugStats()
+	case CmdReactions:
+		go view.ShowReactionsToMe()
+	case CmdArchived:
+		view.parent.roomList.ToggleArchived()
+		view.parent.parent.Render()
+	case CmdRejoin:
+		view.RejoinRoom()
+	case CmdForget:
+		view.ForgetRoom()
+	case CmdLeaveRooms:
+		params := view.parent.ParseLeaveRoomsTarget(gjson.GetBytes(cmd.Arguments, "target").Str, gjson.GetBytes(cmd.Arguments, "reason").Str)
+		view.parent.ShowModal(NewLeaveRoomsModal(view.parent, params))
+	case CmdHide:
+		go view.SetHidden(true)
+	case CmdUnhide:
+		go view.SetHidden(false)
+	case CmdNotifySound:
+		go view.SetNotificationSound(gjson.GetBytes(cmd.Arguments, "mode").Str)
+	case CmdSaveView:
+		path := strings.TrimSpace(gjson.GetBytes(cmd.Arguments, "path").Str)
+		if path == "" {
+			view.AddServiceMessage("Usage: /save-view <path>")
+		} else if err := view.parent.SaveView(view, path); err != nil {
+			view.AddServiceMessage("Failed to save view: %v", err)
+		} else {
+			view.AddServiceMessage("Saved conversation to %s", path)
+		}
+	case CmdFlushQueue:
+		go view.FlushSendQueue()
+	case CmdPrivacy:
+		view.parent.ShowModal(NewPrivacyModal(view.parent, view))
+		view.parent.parent.Render()
+	case CmdEncrypt:
+		go view.EnableEncryption(gjson.GetBytes(cmd.Arguments, "confirm").Str == "confirm")
+	case CmdSessionDevices:
+		go view.ShowSessionDevices(id.SessionID(strings.TrimSpace(gjson.GetBytes(cmd.Arguments, "session_id").Str)))
+	case CmdMsgType:
+		go view.SendWithMsgType(event.MessageType(gjson.GetBytes(cmd.Arguments, "msgtype").Str), gjson.GetBytes(cmd.Arguments, "text").Str)
+	case CmdJSON:
+		evtType := gjson.GetBytes(cmd.Arguments, "type").Str
+		if evtType == "" {
+			evtType = event.EventMessage.Type
+		}
+		view.parent.ShowModal(NewRawEventModal(view.parent, view, event.Type{Type: evtType, Class: event.MessageEventType}, nil))
+		view.parent.parent.Render()
+	case CmdRawState:
+		evtType := gjson.GetBytes(cmd.Arguments, "type").Str
+		stateKey := gjson.GetBytes(cmd.Arguments, "state_key").Str
+		view.parent.ShowModal(NewRawEventModal(view.parent, view, event.Type{Type: evtType, Class: event.StateEventType}, &stateKey))
+		view.parent.parent.Render()
+	case CmdMembers:
+		go view.ExportMembers(gjson.GetBytes(cmd.Arguments, "path").Str, gjson.GetBytes(cmd.Arguments, "memberships").Str)
+	case CmdFilter:
+		if mode := gjson.GetBytes(cmd.Arguments, "mode").Str; mode == "clear" {
+			view.ClearTimelineFilter()
+		} else {
+			view.SetTimelineFilter(TimelineFilterMode(mode), gjson.GetBytes(cmd.Arguments, "query").Str)
+		}
+	case CmdLineage:
+		view.ToggleContinuousHistory(gjson.GetBytes(cmd.Arguments, "enabled").Str)
+	case CmdPolicy:
+		go view.PolicyCommand(
+			gjson.GetBytes(cmd.Arguments, "action").Str,
+			gjson.GetBytes(cmd.Arguments, "room").Str,
+			gjson.GetBytes(cmd.Arguments, "scope").Str,
+			gjson.GetBytes(cmd.Arguments, "mode").Str,
+		)
+	case cmdspec.JumpToDate:
+		go view.JumpToDate(gjson.GetBytes(cmd.Arguments, "date").Str)
 	case CmdQuit:
 		view.parent.parent.Stop()
 	default:
-		return false
+		return view.SendSnippet(cmd.Command, gjson.GetBytes(cmd.Arguments, "text").Str)
 	}
 	return true
 }
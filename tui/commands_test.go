@@ -0,0 +1,119 @@
+// gomuks - A terminal Matrix client written in Go.
+// Copyright (C) 2026 Tulir Asokan
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+package tui
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+
+	"go.mau.fi/mauview"
+	"maunium.net/go/mautrix/id"
+
+	"go.mau.fi/gomuks/pkg/hicli/database"
+	"go.mau.fi/gomuks/pkg/hicli/jsoncmd"
+	"go.mau.fi/gomuks/pkg/rpc"
+	"go.mau.fi/gomuks/pkg/rpc/client"
+	"go.mau.fi/gomuks/pkg/rpc/rpctest"
+	"go.mau.fi/gomuks/tui/config"
+)
+
+// newSendTestRoomView creates a room view connected to a fake backend. The parameters of send_message requests
+// are sent to the returned channel.
+func newSendTestRoomView(t *testing.T, cfg *config.Config) (*RoomView, <-chan *jsoncmd.SendMessageParams) {
+	t.Helper()
+	const roomID id.RoomID = "!room:example.com"
+	sent := make(chan *jsoncmd.SendMessageParams, 4)
+	fb := rpctest.NewBackend(t, "run1")
+	fb.SetRequestHandler(func(cmd *jsoncmd.Container[json.RawMessage]) any {
+		if cmd.Command != jsoncmd.ReqSendMessage {
+			return nil
+		}
+		var params jsoncmd.SendMessageParams
+		if err := json.Unmarshal(cmd.Data, &params); err != nil {
+			t.Errorf("Failed to parse send_message params: %v", err)
+		}
+		sent <- &params
+		return &database.Event{RowID: 1, RoomID: params.RoomID, ID: "~txn"}
+	})
+	gc, err := client.NewGomuksClient(fb.URL, rpc.ConnectionOptions{})
+	if err != nil {
+		t.Fatalf("Failed to create client: %v", err)
+	}
+	t.Cleanup(gc.Disconnect)
+	if err = gc.Connect(context.Background()); err != nil {
+		t.Fatalf("Failed to connect: %v", err)
+	}
+	conn := rpctest.WaitFor(t, fb.Connected, "connection")
+	synced := make(chan struct{}, 1)
+	rpc.OnEvent(gc.GomuksRPC, func(context.Context, *jsoncmd.SyncComplete) {
+		synced <- struct{}{}
+	})
+	err = conn.Send(context.Background(), jsoncmd.EventSyncComplete, -1, &jsoncmd.SyncComplete{
+		ClearState: true,
+		Rooms:      map[id.RoomID]*jsoncmd.SyncRoom{roomID: {Meta: &database.Room{ID: roomID}}},
+	})
+	if err != nil {
+		t.Fatalf("Failed to send sync: %v", err)
+	}
+	rpctest.WaitFor(t, synced, "sync")
+	mainView := &MainView{
+		matrix:       gc,
+		config:       cfg,
+		parent:       &GomuksTUI{app: mauview.NewApplication()},
+		screenReader: NewScreenReader(cfg),
+	}
+	view := NewRoomView(mainView, gc.GetRoom(roomID))
+	t.Cleanup(view.Unload)
+	return view, sent
+}
+
+func TestRoomView_InputSubmit_Passthrough(t *testing.T) {
+	tests := []struct {
+		name     string
+		input    string
+		wantText string
+	}{
+		{"rainbow", "/rainbow hello world", "/rainbow hello world"},
+		{"plain", "/plain **not bold**", "/plain **not bold**"},
+		{"html", "/html <b>bold</b>", "/html <b>bold</b>"},
+		{"me", "/me waves", "/me waves"},
+		{"notice", "/notice beep boop", "/notice beep boop"},
+		{"unencrypted", "/unencrypted hi", "/unencrypted hi"},
+		{"multiline", "/rainbow line one\nline two", "/rainbow line one\nline two"},
+		{"escaped rainbow", "//rainbow isn't a command", "//rainbow isn't a command"},
+		{"escaped me", "//me isn't an emote", "//me isn't an emote"},
+		{"escaped local command", "//fixed text", "//fixed text"},
+		{"double slash path", "//usr/bin", "//usr/bin"},
+		{"notice without text", "/notice", "/notice"},
+		{"fixed", "/fixed a < b", "/html <pre><code>a &lt; b</code></pre>"},
+	}
+	// A snippet with the same name as a passthrough command must not intercept it
+	cfg := &config.Config{Snippets: map[string]string{"rainbow": "intercepted"}}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			view, sent := newSendTestRoomView(t, cfg)
+			view.InputSubmit(test.input)
+			params := rpctest.WaitFor(t, sent, "send_message request")
+			if params.BaseContent != nil {
+				t.Errorf("Input was parsed as a command with body %q", params.BaseContent.Body)
+			} else if params.Text != test.wantText {
+				t.Errorf("SendMessageParams.Text = %q, want %q", params.Text, test.wantText)
+			}
+		})
+	}
+}
@@ -25,6 +25,7 @@ import (
 	"runtime"
 	"strconv"
 	"strings"
+	"time"
 
 	"codeberg.org/tslocum/cbind"
 	"github.com/gdamore/tcell/v2"
@@ -33,7 +34,9 @@ import (
 	"go.mau.fi/util/ptr"
 	"go.mau.fi/zeroconfig"
 	"gopkg.in/yaml.v3"
+	"maunium.net/go/mautrix/id"
 
+	"go.mau.fi/gomuks/pkg/usercolor"
 	"go.mau.fi/gomuks/tui/debug"
 )
 
@@ -41,6 +44,7 @@ type UserPreferences struct {
 	HideUserList         bool `yaml:"hide_user_list"`
 	HideRoomList         bool `yaml:"hide_room_list"`
 	HideTimestamp        bool `yaml:"hide_timestamp"`
+	HideDeliveryStatus   bool `yaml:"hide_delivery_status"`
 	BareMessageView      bool `yaml:"bare_message_view"`
 	DisableImages        bool `yaml:"disable_images"`
 	DisableTypingNotifs  bool `yaml:"disable_typing_notifs"`
@@ -50,8 +54,30 @@ type UserPreferences struct {
 	DisableDownloads     bool `yaml:"disable_downloads"`
 	DisableNotifications bool `yaml:"disable_notifications"`
 	DisableShowURLs      bool `yaml:"disable_show_urls"`
-
-	InlineURLMode string `yaml:"inline_url_mode"`
+	DisableRoomPreviews  bool `yaml:"disable_room_previews"`
+	AskPasteCaption      bool `yaml:"ask_paste_caption"`
+	RevealSpoilers       bool `yaml:"reveal_spoilers"`
+	RevealBanRemoved     bool `yaml:"reveal_ban_removed"`
+	ScreenReaderMode     bool `yaml:"screen_reader_mode"`
+	ContinuousHistory    bool `yaml:"continuous_history"`
+	GroupMessages        bool `yaml:"group_messages"`
+	GroupMessagesMinutes int  `yaml:"group_messages_minutes"`
+	IdleTimeoutMinutes   int  `yaml:"idle_timeout_minutes"`
+
+	DisableSyntaxHighlight bool   `yaml:"disable_syntax_highlight"`
+	SyntaxHighlightStyle   string `yaml:"syntax_highlight_style"`
+	WrapCodeBlocks         bool   `yaml:"wrap_code_blocks"`
+
+	InlineURLMode          string `yaml:"inline_url_mode"`
+	MathRendering          string `yaml:"math_rendering"`
+	PerMessageProfiles     string `yaml:"per_message_profiles"`
+	URLPreviewsWhenSending string `yaml:"url_previews_when_sending"`
+	MembershipNoise        string `yaml:"membership_noise"`
+	UnreadCountSource      string `yaml:"unread_count_source"`
+	BackgroundHint         string `yaml:"background_hint"`
+	FocusDetection         string `yaml:"focus_detection"`
+	StripEXIF              string `yaml:"strip_exif"`
+	EmojiDisplay           string `yaml:"emoji_display"`
 }
 
 var InlineURLsProbablySupported bool
@@ -70,12 +96,211 @@ func (up *UserPreferences) EnableInlineURLs() bool {
 	return up.InlineURLMode == "enable" || (InlineURLsProbablySupported && up.InlineURLMode != "disable")
 }
 
+const DefaultGroupMessagesMinutes = 5
+
+// GroupingInterval returns the maximum time between consecutive messages from the same sender
+// for them to be grouped together when GroupMessages is enabled.
+func (up *UserPreferences) GroupingInterval() time.Duration {
+	if up.GroupMessagesMinutes <= 0 {
+		return DefaultGroupMessagesMinutes * time.Minute
+	}
+	return time.Duration(up.GroupMessagesMinutes) * time.Minute
+}
+
+const DefaultIdleTimeoutMinutes = 5
+
+// IdleTimeout returns how long the user can go without any input before they're considered away,
+// which stops gomuks from sending read receipts and typing notifications. Negative values disable it.
+func (up *UserPreferences) IdleTimeout() time.Duration {
+	if up.IdleTimeoutMinutes < 0 {
+		return 0
+	} else if up.IdleTimeoutMinutes == 0 {
+		return DefaultIdleTimeoutMinutes * time.Minute
+	}
+	return time.Duration(up.IdleTimeoutMinutes) * time.Minute
+}
+
+const (
+	MathRenderingUnicode = "unicode"
+	MathRenderingSource  = "source"
+	MathRenderingHidden  = "hidden"
+)
+
+// GetMathRendering returns how LaTeX math in messages should be displayed.
+func (up *UserPreferences) GetMathRendering() string {
+	switch up.MathRendering {
+	case MathRenderingSource, MathRenderingHidden:
+		return up.MathRendering
+	default:
+		return MathRenderingUnicode
+	}
+}
+
+const (
+	PerMessageProfilesShow     = "show"
+	PerMessageProfilesHide     = "hide"
+	PerMessageProfilesAnnotate = "annotate"
+)
+
+// GetPerMessageProfiles returns how per-message profiles (MSC4144) set by bridges should be displayed.
+func (up *UserPreferences) GetPerMessageProfiles() string {
+	switch up.PerMessageProfiles {
+	case PerMessageProfilesHide, PerMessageProfilesAnnotate:
+		return up.PerMessageProfiles
+	default:
+		return PerMessageProfilesShow
+	}
+}
+
+const (
+	URLPreviewsAlways = "always"
+	URLPreviewsNever  = "never"
+	URLPreviewsAsk    = "ask"
+)
+
+// GetURLPreviewsWhenSending returns whether other clients should be allowed to show URL previews
+// for links in sent messages, or if the user should be asked every time.
+func (up *UserPreferences) GetURLPreviewsWhenSending() string {
+	switch up.URLPreviewsWhenSending {
+	case URLPreviewsNever, URLPreviewsAsk:
+		return up.URLPreviewsWhenSending
+	default:
+		return URLPreviewsAlways
+	}
+}
+
+const (
+	MembershipNoiseAll      = "all"
+	MembershipNoiseCollapse = "collapse"
+	MembershipNoiseHide     = "hide"
+)
+
+// GetMembershipNoise returns how membership events that only change the display name or avatar of a user
+// should be displayed in the timeline.
+func (up *UserPreferences) GetMembershipNoise() string {
+	switch up.MembershipNoise {
+	case MembershipNoiseCollapse, MembershipNoiseHide:
+		return up.MembershipNoise
+	default:
+		return MembershipNoiseAll
+	}
+}
+
+const (
+	UnreadCountSourceLocal  = "local"
+	UnreadCountSourceServer = "server"
+)
+
+// GetUnreadCountSource returns whether the room list should use the unread counts calculated locally
+// from the events in the database, or the counts reported by the homeserver.
+func (up *UserPreferences) GetUnreadCountSource() string {
+	if up.UnreadCountSource == UnreadCountSourceServer {
+		return UnreadCountSourceServer
+	}
+	return UnreadCountSourceLocal
+}
+
+// GetBackgroundHint returns whether the terminal has a dark or light background,
+// which is used to pick readable colors for user names.
+func (up *UserPreferences) GetBackgroundHint() usercolor.Background {
+	if up.BackgroundHint == string(usercolor.BackgroundLight) {
+		return usercolor.BackgroundLight
+	}
+	return usercolor.BackgroundDark
+}
+
+const (
+	FocusDetectionAuto    = "auto"
+	FocusDetectionEnable  = "enable"
+	FocusDetectionDisable = "disable"
+)
+
+// GetFocusDetection returns whether terminal focus events should be used to decide if the user is looking at gomuks.
+// In auto mode, focus events are used once the terminal has reported one. Enable assumes the terminal reports them
+// from the start, and disable always uses the time since the last input instead.
+func (up *UserPreferences) GetFocusDetection() string {
+	switch up.FocusDetection {
+	case FocusDetectionEnable, FocusDetectionDisable:
+		return up.FocusDetection
+	default:
+		return FocusDetectionAuto
+	}
+}
+
+const (
+	StripEXIFAlways = "always"
+	StripEXIFAsk    = "ask"
+	StripEXIFNever  = "never"
+)
+
+// GetStripEXIF returns whether metadata like GPS coordinates should be removed from images before uploading them,
+// or if the user should be asked when an image contains location data.
+func (up *UserPreferences) GetStripEXIF() string {
+	switch up.StripEXIF {
+	case StripEXIFAlways, StripEXIFNever:
+		return up.StripEXIF
+	default:
+		return StripEXIFAsk
+	}
+}
+
+const (
+	EmojiDisplayNative    = "native"
+	EmojiDisplayShortcode = "shortcode"
+	EmojiDisplayStrip     = "strip"
+)
+
+// GetEmojiDisplay returns whether emojis in messages and reactions should be displayed as-is,
+// replaced with their shortcodes or removed entirely, for terminals that can't render them properly.
+func (up *UserPreferences) GetEmojiDisplay() string {
+	switch up.EmojiDisplay {
+	case EmojiDisplayShortcode, EmojiDisplayStrip:
+		return up.EmojiDisplay
+	default:
+		return EmojiDisplayNative
+	}
+}
+
+const DefaultSyntaxHighlightStyle = "solarized-dark"
+
+// GetSyntaxHighlightStyle returns the name of the chroma style used for code blocks.
+func (up *UserPreferences) GetSyntaxHighlightStyle() string {
+	if up.SyntaxHighlightStyle == "" {
+		return DefaultSyntaxHighlightStyle
+	}
+	return up.SyntaxHighlightStyle
+}
+
 type Keybind struct {
 	Mod tcell.ModMask
 	Key tcell.Key
 	Ch  rune
 }
 
+// String returns the keybinding in the same format as used in the keybindings file.
+func (kb Keybind) String() string {
+	str, err := cbind.Encode(kb.Mod, kb.Key, kb.Ch)
+	if err != nil {
+		return "?"
+	}
+	return str
+}
+
+// FindKeybind returns the shortest keybinding for the given action, or an empty string if there is none.
+func FindKeybind(bindings map[Keybind]string, action string) string {
+	var found string
+	for kb, kbAction := range bindings {
+		if kbAction != action {
+			continue
+		}
+		str := kb.String()
+		if found == "" || len(str) < len(found) || (len(str) == len(found) && str < found) {
+			found = str
+		}
+	}
+	return found
+}
+
 type ParsedKeybindings struct {
 	Main   map[Keybind]string
 	Room   map[Keybind]string
@@ -95,20 +320,67 @@ type Config struct {
 	Server   string `yaml:"server"`
 	Username string `yaml:"username"`
 	Password string `yaml:"password"`
+	// InsecureSkipVerify disables TLS certificate verification when connecting to the backend.
+	InsecureSkipVerify bool `yaml:"insecure_skip_verify,omitempty"`
 
+	// NotifySound is the global switch for notification sounds. If it's disabled, no sounds are played at all.
 	NotifySound bool `yaml:"notify_sound"`
+	// SoundOnHighlight and SoundOnMessage control whether notifications for highlights (e.g. mentions)
+	// and other messages play a sound. Sounds are only played if the push rules request one,
+	// unless the room has the notification_sound preference set to always.
+	SoundOnHighlight bool `yaml:"sound_on_highlight"`
+	SoundOnMessage   bool `yaml:"sound_on_message"`
+	// NotificationSoundFile is a custom sound file to play instead of the default notification sound.
+	NotificationSoundFile string `yaml:"notification_sound_file,omitempty"`
+	// ReactionNotifications enables notifications for reactions other users send to your messages.
+	ReactionNotifications bool `yaml:"reaction_notifications"`
+
+	// NotificationCommand is an optional command that is executed for every notification.
+	// The first item is the program and the rest are arguments. The room name, sender name,
+	// message body and highlight flag (true/false) are appended as four additional arguments.
+	// If the program parses options, end the list with "--" so that values starting with a dash
+	// aren't parsed as options.
+	// The same values are also available in the environment variables GOMUKS_NOTIFY_ROOM_NAME,
+	// GOMUKS_NOTIFY_ROOM_ID, GOMUKS_NOTIFY_SENDER, GOMUKS_NOTIFY_SENDER_ID, GOMUKS_NOTIFY_BODY
+	// and GOMUKS_NOTIFY_HIGHLIGHT. The command is not run through a shell.
+	NotificationCommand []string `yaml:"notification_command,omitempty"`
+	// NotificationCommandOnly disables the built-in desktop notifications when NotificationCommand is set.
+	NotificationCommandOnly bool `yaml:"notification_command_only,omitempty"`
 
 	Backspace1RemovesWord bool `yaml:"backspace1_removes_word"`
 	Backspace2RemovesWord bool `yaml:"backspace2_removes_word"`
+	// KillToClipboard makes the readline-style kill keybindings (e.g. Ctrl+W and Ctrl+U) copy the removed
+	// text to the system clipboard, and the yank keybinding paste from it, instead of using an internal register.
+	KillToClipboard bool `yaml:"kill_to_clipboard"`
+	// UserColors overrides the colors of specific users. The values can be color names or hex codes.
+	UserColors map[id.UserID]string `yaml:"user_colors,omitempty"`
+	// Snippets are custom text transformer commands, mapping the command name to the markdown to send.
+	// $text in the expansion is replaced with the text after the command, and $$ can be used for a literal $.
+	Snippets map[string]string `yaml:"snippets,omitempty"`
+
+	// SensitiveContent configures the confirmation shown before sending messages that look like they contain secrets.
+	SensitiveContent SensitiveContentConfig `yaml:"sensitive_content"`
 
 	AlwaysClearScreen bool `yaml:"always_clear_screen"`
+	// ScreenReaderOutput is the file or FIFO where new messages and navigation events are written in the
+	// screen reader mode. If empty, they're written to stdout.
+	ScreenReaderOutput string `yaml:"screen_reader_output,omitempty"`
 
 	LogConfig zeroconfig.Config `yaml:"log_config"`
 
+	// RecentRooms is the recent room stack, persisted across restarts.
+	RecentRooms []id.RoomID `yaml:"recent_rooms,omitempty"`
+	// SplitPane is true if the room view is split into two panes, and SplitPaneRoom is the room in the right pane.
+	SplitPane     bool      `yaml:"split_pane"`
+	SplitPaneRoom id.RoomID `yaml:"split_pane_room,omitempty"`
+
 	Dir string `yaml:"-"`
 
-	Preferences UserPreferences   `yaml:"-"`
-	Keybindings ParsedKeybindings `yaml:"-"`
+	Preferences UserPreferences `yaml:"preferences"`
+	// PreferencesModifiedAt is the unix millisecond timestamp of when the preferences were last changed or
+	// restored from the settings backup. If it's not set, the preferences are restored from the backup after connecting.
+	PreferencesModifiedAt int64             `yaml:"preferences_modified_at,omitempty"`
+	Keybindings           ParsedKeybindings `yaml:"-"`
 
 	nosave bool
 }
@@ -144,6 +416,8 @@ func NewConfig() *Config {
 		Dir: GetConfigDirectory(),
 
 		NotifySound:           true,
+		SoundOnHighlight:      true,
+		SoundOnMessage:        true,
 		Backspace1RemovesWord: true,
 		AlwaysClearScreen:     true,
 
@@ -162,6 +436,38 @@ func NewConfig() *Config {
 	}
 }
 
+// Values for the notification_sound room preference.
+const (
+	RoomSoundDefault = ""
+	RoomSoundAlways  = "always"
+	RoomSoundNever   = "never"
+)
+
+// ShouldPlaySound decides whether a notification should play a sound.
+//
+// The global NotifySound switch always wins. After that, the room preference can force the sound on or off,
+// and otherwise the sound requested by the push rules is played if it's enabled for the type of notification.
+func ShouldPlaySound(global, onHighlight, onMessage bool, roomOverride string, highlight, pushRuleSound bool) bool {
+	if !global {
+		return false
+	}
+	switch roomOverride {
+	case RoomSoundAlways:
+		return true
+	case RoomSoundNever:
+		return false
+	}
+	if highlight {
+		return pushRuleSound && onHighlight
+	}
+	return pushRuleSound && onMessage
+}
+
+// ShouldPlaySound decides whether a notification should play a sound based on the config, see ShouldPlaySound.
+func (config *Config) ShouldPlaySound(roomOverride string, highlight, pushRuleSound bool) bool {
+	return ShouldPlaySound(config.NotifySound, config.SoundOnHighlight, config.SoundOnMessage, roomOverride, highlight, pushRuleSound)
+}
+
 func (config *Config) LoadAll() {
 	config.Load()
 	config.LoadKeybindings()
@@ -0,0 +1,75 @@
+// gomuks - A terminal Matrix client written in Go.
+// Copyright (C) 2026 Tulir Asokan
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+package config
+
+import (
+	"fmt"
+	"testing"
+)
+
+func TestShouldPlaySound(t *testing.T) {
+	tests := []struct {
+		global, onHighlight, onMessage bool
+		roomOverride                   string
+		highlight, pushRuleSound       bool
+		want                           bool
+	}{
+		// Global switch off wins over everything
+		{false, true, true, RoomSoundDefault, true, true, false},
+		{false, true, true, RoomSoundDefault, false, true, false},
+		{false, true, true, RoomSoundAlways, true, true, false},
+		{false, true, true, RoomSoundAlways, false, false, false},
+		// Default room preference follows the push rules and the per-type switches
+		{true, true, true, RoomSoundDefault, true, true, true},
+		{true, true, true, RoomSoundDefault, false, true, true},
+		{true, true, true, RoomSoundDefault, true, false, false},
+		{true, true, true, RoomSoundDefault, false, false, false},
+		// Highlight-only
+		{true, true, false, RoomSoundDefault, true, true, true},
+		{true, true, false, RoomSoundDefault, false, true, false},
+		// Messages only
+		{true, false, true, RoomSoundDefault, true, true, false},
+		{true, false, true, RoomSoundDefault, false, true, true},
+		// Both types off
+		{true, false, false, RoomSoundDefault, true, true, false},
+		{true, false, false, RoomSoundDefault, false, true, false},
+		// Room forced on ignores push rules and per-type switches
+		{true, false, false, RoomSoundAlways, false, false, true},
+		{true, false, false, RoomSoundAlways, true, false, true},
+		{true, true, true, RoomSoundAlways, true, true, true},
+		// Room forced off
+		{true, true, true, RoomSoundNever, true, true, false},
+		{true, true, true, RoomSoundNever, false, true, false},
+		// Unknown room preference values behave like the default
+		{true, true, false, "sometimes", true, true, true},
+		{true, true, false, "sometimes", false, true, false},
+	}
+	for _, test := range tests {
+		name := fmt.Sprintf("global=%t,highlight=%t,message=%t,room=%q,isHighlight=%t,pushSound=%t",
+			test.global, test.onHighlight, test.onMessage, test.roomOverride, test.highlight, test.pushRuleSound)
+		t.Run(name, func(t *testing.T) {
+			got := ShouldPlaySound(test.global, test.onHighlight, test.onMessage, test.roomOverride, test.highlight, test.pushRuleSound)
+			if got != test.want {
+				t.Errorf("ShouldPlaySound() = %t, want %t", got, test.want)
+			}
+			cfg := &Config{NotifySound: test.global, SoundOnHighlight: test.onHighlight, SoundOnMessage: test.onMessage}
+			if got = cfg.ShouldPlaySound(test.roomOverride, test.highlight, test.pushRuleSound); got != test.want {
+				t.Errorf("Config.ShouldPlaySound() = %t, want %t", got, test.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,65 @@
+// gomuks - A terminal Matrix client written in Go.
+// Copyright (C) 2026 Tulir Asokan
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+package config
+
+import (
+	"errors"
+	"maps"
+	"slices"
+
+	"go.mau.fi/gomuks/pkg/sensitive"
+)
+
+type SensitiveContentConfig struct {
+	// Disabled turns off the check for sensitive content entirely.
+	Disabled bool `yaml:"disabled"`
+	// DisabledDetectors lists built-in detectors that shouldn't be used, e.g. high_entropy if it has too many
+	// false positives. The built-in detectors are listed in sensitive.DefaultDetectors.
+	DisabledDetectors []string `yaml:"disabled_detectors,omitempty"`
+	// CustomDetectors maps detector names to additional regular expressions. A custom detector with the same name
+	// as a built-in one replaces it. If the pattern has a capture group named "secret", only that part of the match
+	// is highlighted and redacted.
+	CustomDetectors map[string]string `yaml:"custom_detectors,omitempty"`
+}
+
+// Detectors returns the enabled detectors. Custom detectors with invalid patterns are skipped
+// and the errors are returned along with the other detectors.
+func (scc *SensitiveContentConfig) Detectors() ([]*sensitive.Detector, error) {
+	if scc.Disabled {
+		return nil, nil
+	}
+	detectors := make([]*sensitive.Detector, 0, len(sensitive.DefaultDetectors)+len(scc.CustomDetectors))
+	for _, det := range sensitive.DefaultDetectors {
+		_, overridden := scc.CustomDetectors[det.Name]
+		if !overridden && !slices.Contains(scc.DisabledDetectors, det.Name) {
+			detectors = append(detectors, det)
+		}
+	}
+	var errs []error
+	for _, name := range slices.Sorted(maps.Keys(scc.CustomDetectors)) {
+		if slices.Contains(scc.DisabledDetectors, name) {
+			continue
+		}
+		det, err := sensitive.NewDetector(name, scc.CustomDetectors[name], 0)
+		if err != nil {
+			errs = append(errs, err)
+		} else {
+			detectors = append(detectors, det)
+		}
+	}
+	return detectors, errors.Join(errs...)
+}
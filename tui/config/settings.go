@@ -0,0 +1,86 @@
+// gomuks - A terminal Matrix client written in Go.
+// Copyright (C) 2025 Tulir Asokan
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+package config
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"gopkg.in/yaml.v3"
+)
+
+// SettingsSection is the name of the section used for terminal preferences in the settings backup.
+const SettingsSection = "tui"
+
+// IsDefault returns true if none of the preferences have been changed from the defaults.
+func (up *UserPreferences) IsDefault() bool {
+	return *up == UserPreferences{}
+}
+
+// Export converts the preferences into settings backup values, using the same keys as the config file.
+func (up *UserPreferences) Export() (map[string]json.RawMessage, error) {
+	yamlData, err := yaml.Marshal(up)
+	if err != nil {
+		return nil, err
+	}
+	var rawValues map[string]any
+	err = yaml.Unmarshal(yamlData, &rawValues)
+	if err != nil {
+		return nil, err
+	}
+	values := make(map[string]json.RawMessage, len(rawValues))
+	for key, value := range rawValues {
+		values[key], err = json.Marshal(value)
+		if err != nil {
+			return nil, fmt.Errorf("failed to marshal %s: %w", key, err)
+		}
+	}
+	return values, nil
+}
+
+// Import applies values from the settings backup on top of the current preferences.
+// Keys that aren't known to this version of gomuks are ignored.
+func (up *UserPreferences) Import(values map[string]json.RawMessage) error {
+	yamlData, err := yaml.Marshal(up)
+	if err != nil {
+		return err
+	}
+	var merged map[string]any
+	err = yaml.Unmarshal(yamlData, &merged)
+	if err != nil {
+		return err
+	}
+	for key, rawValue := range values {
+		var value any
+		err = json.Unmarshal(rawValue, &value)
+		if err != nil {
+			return fmt.Errorf("failed to parse %s: %w", key, err)
+		}
+		merged[key] = value
+	}
+	yamlData, err = yaml.Marshal(merged)
+	if err != nil {
+		return err
+	}
+	var newPrefs UserPreferences
+	err = yaml.Unmarshal(yamlData, &newPrefs)
+	if err != nil {
+		return err
+	}
+	*up = newPrefs
+	return nil
+}
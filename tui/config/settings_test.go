@@ -0,0 +1,118 @@
+// gomuks - A terminal Matrix client written in Go.
+// Copyright (C) 2026 Tulir Asokan
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+package config
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+func TestUserPreferences_ExportImportRoundtrip(t *testing.T) {
+	prefs := UserPreferences{
+		HideUserList:         true,
+		GroupMessagesMinutes: 5,
+		SyntaxHighlightStyle: "monokai",
+		MathRendering:        "unicode",
+	}
+	values, err := prefs.Export()
+	if err != nil {
+		t.Fatalf("Export failed: %v", err)
+	}
+	if string(values["hide_user_list"]) != "true" || string(values["syntax_highlight_style"]) != `"monokai"` {
+		t.Errorf("Export didn't use config file keys: %s / %s", values["hide_user_list"], values["syntax_highlight_style"])
+	}
+	var imported UserPreferences
+	if err = imported.Import(values); err != nil {
+		t.Fatalf("Import failed: %v", err)
+	} else if imported != prefs {
+		t.Errorf("Roundtrip changed preferences: %+v, want %+v", imported, prefs)
+	}
+}
+
+func TestUserPreferences_Import(t *testing.T) {
+	tests := []struct {
+		name    string
+		current UserPreferences
+		values  map[string]string
+		want    UserPreferences
+		wantErr bool
+	}{
+		{
+			name:    "partial update keeps other preferences",
+			current: UserPreferences{HideUserList: true, SyntaxHighlightStyle: "monokai"},
+			values:  map[string]string{"hide_room_list": "true"},
+			want:    UserPreferences{HideUserList: true, HideRoomList: true, SyntaxHighlightStyle: "monokai"},
+		},
+		{
+			name:    "overwrites values",
+			current: UserPreferences{HideUserList: true, GroupMessagesMinutes: 5},
+			values:  map[string]string{"hide_user_list": "false", "group_messages_minutes": "10"},
+			want:    UserPreferences{GroupMessagesMinutes: 10},
+		},
+		{
+			name:    "unknown keys from newer versions are ignored",
+			current: UserPreferences{HideTimestamp: true},
+			values: map[string]string{
+				"disable_images":      "true",
+				"some_future_option":  `{"nested":[1,2,3]}`,
+				"another_new_setting": `"value"`,
+			},
+			want: UserPreferences{HideTimestamp: true, DisableImages: true},
+		},
+		{
+			name:    "invalid JSON",
+			current: UserPreferences{HideTimestamp: true},
+			values:  map[string]string{"disable_images": "{"},
+			want:    UserPreferences{HideTimestamp: true},
+			wantErr: true,
+		},
+		{
+			name:    "wrong type",
+			current: UserPreferences{HideTimestamp: true},
+			values:  map[string]string{"group_messages_minutes": `"many"`},
+			want:    UserPreferences{HideTimestamp: true},
+			wantErr: true,
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			values := make(map[string]json.RawMessage, len(test.values))
+			for key, value := range test.values {
+				values[key] = json.RawMessage(value)
+			}
+			prefs := test.current
+			err := prefs.Import(values)
+			if test.wantErr && err == nil {
+				t.Error("Expected an error")
+			} else if !test.wantErr && err != nil {
+				t.Errorf("Unexpected error: %v", err)
+			}
+			// Failed imports must not partially apply
+			if prefs != test.want {
+				t.Errorf("Preferences = %+v, want %+v", prefs, test.want)
+			}
+		})
+	}
+}
+
+func TestUserPreferences_IsDefault(t *testing.T) {
+	if !(&UserPreferences{}).IsDefault() {
+		t.Error("Zero preferences aren't default")
+	} else if (&UserPreferences{BareMessageView: true}).IsDefault() {
+		t.Error("Changed preferences are default")
+	}
+}
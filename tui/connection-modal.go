@@ -0,0 +1,209 @@
+// gomuks - A terminal Matrix client written in Go.
+// Copyright (C) 2025 Tulir Asokan
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+package tui
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/gdamore/tcell/v2"
+	"go.mau.fi/mauview"
+
+	"go.mau.fi/gomuks/pkg/hicli/jsoncmd"
+	"go.mau.fi/gomuks/tui/config"
+	"go.mau.fi/gomuks/tui/debug"
+)
+
+type ConnectionModal struct {
+	mauview.Component
+
+	form *mauview.Form
+
+	serverInput   *mauview.InputField
+	usernameInput *mauview.InputField
+	passwordInput *mauview.InputField
+	insecure      *mauview.Button
+	status        *mauview.TextField
+
+	cancel  *mauview.Button
+	connect *mauview.Button
+
+	insecureSkipVerify bool
+	connecting         bool
+
+	parent *MainView
+}
+
+func NewConnectionModal(parent *MainView, reason string) *ConnectionModal {
+	cfg := parent.parent.Config
+	cm := &ConnectionModal{
+		parent:             parent,
+		form:               mauview.NewForm(),
+		insecureSkipVerify: cfg.InsecureSkipVerify,
+	}
+
+	cm.form.
+		SetColumns([]int{1, 10, 1, 34, 1}).
+		SetRows([]int{1, 1, 1, 1, 1, 1, 1, 1, 1, 1, 1, 1})
+
+	cm.serverInput = mauview.NewInputField().SetPlaceholder("http://localhost:29325").SetText(cfg.Server)
+	cm.usernameInput = mauview.NewInputField().SetPlaceholder("username").SetText(cfg.Username)
+	cm.passwordInput = mauview.NewInputField().SetPlaceholder("password").SetMaskCharacter('*').SetText(cfg.Password)
+	cm.insecure = mauview.NewButton("").SetOnClick(cm.toggleInsecure)
+	cm.updateInsecureText()
+	cm.status = mauview.NewTextField()
+	if reason != "" {
+		cm.status.SetTextColor(tcell.ColorRed).SetText(reason)
+	} else {
+		cm.status.SetText("Disconnected")
+	}
+
+	cm.form.AddComponent(mauview.NewTextField().SetText("Backend"), 1, 1, 1, 1)
+	cm.form.AddFormItem(cm.serverInput, 3, 1, 1, 1)
+	cm.form.AddComponent(mauview.NewTextField().SetText("Username"), 1, 3, 1, 1)
+	cm.form.AddFormItem(cm.usernameInput, 3, 3, 1, 1)
+	cm.form.AddComponent(mauview.NewTextField().SetText("Password"), 1, 5, 1, 1)
+	cm.form.AddFormItem(cm.passwordInput, 3, 5, 1, 1)
+	cm.form.AddFormItem(cm.insecure, 1, 7, 3, 1)
+	cm.form.AddComponent(cm.status, 1, 8, 3, 1)
+
+	cm.cancel = mauview.NewButton("Quit").SetOnClick(parent.parent.Stop)
+	cm.connect = mauview.NewButton("Connect").SetOnClick(cm.ClickConnect)
+	cm.form.AddFormItem(cm.cancel, 1, 10, 1, 1)
+	cm.form.AddFormItem(cm.connect, 3, 10, 1, 1)
+
+	box := mauview.NewBox(cm.form).SetTitle("Connection to gomuks backend")
+	center := mauview.Center(box, 49, 14).SetAlwaysFocusChild(true)
+	center.Focus()
+	cm.form.FocusNextItem()
+	cm.Component = center
+	return cm
+}
+
+func (cm *ConnectionModal) updateInsecureText() {
+	if cm.insecureSkipVerify {
+		cm.insecure.SetText("[x] Skip TLS certificate verification")
+	} else {
+		cm.insecure.SetText("[ ] Skip TLS certificate verification")
+	}
+}
+
+func (cm *ConnectionModal) toggleInsecure() {
+	cm.insecureSkipVerify = !cm.insecureSkipVerify
+	cm.updateInsecureText()
+}
+
+func (cm *ConnectionModal) OnKeyEvent(event mauview.KeyEvent) bool {
+	kb := config.Keybind{
+		Key: event.Key(),
+		Ch:  event.Rune(),
+		Mod: event.Modifiers(),
+	}
+	if cm.parent.config.Keybindings.Modal[kb] == "confirm" && !cm.connecting {
+		cm.ClickConnect()
+		return true
+	}
+	return cm.Component.OnKeyEvent(event)
+}
+
+// SetStatus updates the connection status text shown in the modal.
+func (cm *ConnectionModal) SetStatus(color tcell.Color, format string, args ...any) {
+	cm.status.SetTextColor(color).SetText(fmt.Sprintf(format, args...))
+	cm.parent.parent.Render()
+}
+
+// HandleEvent updates the status based on client state events from the backend.
+func (cm *ConnectionModal) HandleEvent(rawEvt any) {
+	switch evt := rawEvt.(type) {
+	case *jsoncmd.ClientState:
+		if len(evt.Problems) > 0 && evt.Problems[0].Fatal {
+			cm.SetStatus(tcell.ColorRed, "%s", evt.Problems[0].Message)
+		} else if !evt.IsLoggedIn {
+			cm.SetStatus(tcell.ColorDefault, "Connected, backend is not logged in")
+		} else {
+			cm.SetStatus(tcell.ColorDefault, "Connected as %s, waiting for sync...", evt.UserID)
+		}
+	case *jsoncmd.SyncStatus:
+		if evt.Type == jsoncmd.SyncStatusErroring || evt.Type == jsoncmd.SyncStatusFailed {
+			cm.SetStatus(tcell.ColorRed, "Sync error: %s", evt.Error)
+		} else {
+			cm.SetStatus(tcell.ColorDefault, "Syncing...")
+		}
+	case *jsoncmd.InitComplete:
+		cm.close()
+	}
+}
+
+func (cm *ConnectionModal) close() {
+	if cm.parent.connectionModal == cm {
+		cm.parent.connectionModal = nil
+	}
+	// Another modal (e.g. session problems) may have been opened on top of this one
+	if cm.parent.modal == cm {
+		cm.parent.HideModal()
+	}
+	cm.parent.parent.Render()
+}
+
+func (cm *ConnectionModal) ClickConnect() {
+	if cm.connecting {
+		return
+	}
+	server := strings.TrimSpace(cm.serverInput.GetText())
+	if server == "" {
+		cm.SetStatus(tcell.ColorRed, "Backend address can't be empty")
+		return
+	}
+	cm.connecting = true
+	cm.SetStatus(tcell.ColorDefault, "Connecting...")
+	go cm.doConnect(server, cm.usernameInput.GetText(), cm.passwordInput.GetText(), cm.insecureSkipVerify)
+}
+
+func (cm *ConnectionModal) doConnect(server, username, password string, insecure bool) {
+	defer debug.Recover()
+	defer func() {
+		cm.connecting = false
+	}()
+	ui := cm.parent.parent
+	ui.Config.Server = server
+	ui.Config.Username = username
+	ui.Config.Password = password
+	ui.Config.InsecureSkipVerify = insecure
+	ui.Config.Save()
+	err := ui.gmx.Reconfigure(context.TODO(), server, username, password, ui.connectionOptions())
+	if err != nil {
+		debug.Print("Failed to reconnect to backend:", err)
+		cm.SetStatus(tcell.ColorRed, "%v", err)
+		return
+	}
+	cm.parent.ResetRooms()
+	cm.SetStatus(tcell.ColorDefault, "Authenticated, waiting for backend...")
+}
+
+// ShowConnectionModal opens the backend connection modal, optionally with an error explaining why it was opened.
+func (view *MainView) ShowConnectionModal(reason string) {
+	view.connectionModal = NewConnectionModal(view, reason)
+	view.ShowModal(view.connectionModal)
+	view.parent.Render()
+}
+
+// Disconnect disconnects from the backend and opens the connection modal.
+func (view *MainView) Disconnect() {
+	view.matrix.Disconnect()
+	view.ShowConnectionModal("")
+}
@@ -0,0 +1,304 @@
+// gomuks - A terminal Matrix client written in Go.
+// Copyright (C) 2026 Tulir Asokan
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses/>.
+package tui
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gdamore/tcell/v2"
+	"go.mau.fi/mauview"
+	"go.mau.fi/util/jsontime"
+	"maunium.net/go/mautrix"
+	"maunium.net/go/mautrix/id"
+
+	"go.mau.fi/gomuks/pkg/hicli/database"
+	"go.mau.fi/gomuks/pkg/hicli/jsoncmd"
+	"go.mau.fi/gomuks/tui/config"
+	"go.mau.fi/gomuks/tui/debug"
+	"go.mau.fi/gomuks/tui/messages"
+)
+
+const (
+	dateContextLimit      = 20
+	dateContextPageLimit  = 50
+	dateContextDateFormat = "Monday, January 2, 2006"
+)
+
+var errInvalidDate = errors.New("invalid date, expected e.g. 2025-03-03, yesterday or last monday")
+
+var weekdayNames = map[string]time.Weekday{
+	"sunday":    time.Sunday,
+	"monday":    time.Monday,
+	"tuesday":   time.Tuesday,
+	"wednesday": time.Wednesday,
+	"thursday":  time.Thursday,
+	"friday":    time.Friday,
+	"saturday":  time.Saturday,
+}
+
+// parseJumpDate parses a date typed by the user. ISO dates (2025-03-03), "today", "yesterday" and weekdays
+// ("monday" or "last monday", both meaning the most recent one before today) are supported.
+// The returned time is the start of the day in the local timezone.
+func parseJumpDate(input string, now time.Time) (time.Time, error) {
+	input = strings.ToLower(strings.TrimSpace(input))
+	year, month, day := now.Date()
+	today := time.Date(year, month, day, 0, 0, 0, 0, now.Location())
+	switch input {
+	case "today":
+		return today, nil
+	case "yesterday":
+		return today.AddDate(0, 0, -1), nil
+	}
+	if weekday, ok := weekdayNames[strings.TrimSpace(strings.TrimPrefix(input, "last "))]; ok {
+		daysAgo := (int(today.Weekday()) - int(weekday) + 7) % 7
+		if daysAgo == 0 {
+			daysAgo = 7
+		}
+		return today.AddDate(0, 0, -daysAgo), nil
+	}
+	date, err := time.ParseInLocation(time.DateOnly, input, now.Location())
+	if err != nil {
+		return time.Time{}, errInvalidDate
+	}
+	return date, nil
+}
+
+// JumpToDate opens a view of the messages sent around the given date. If the date is empty,
+// it's asked for first. This must be called in a goroutine, as it blocks until the request is done.
+func (view *RoomView) JumpToDate(input string) {
+	defer debug.Recover()
+	if input == "" {
+		var ok bool
+		input, ok = view.parent.AskText("Jump to date", "date", time.Now().Format(time.DateOnly))
+		if !ok || strings.TrimSpace(input) == "" {
+			return
+		}
+	}
+	date, err := parseJumpDate(input, time.Now())
+	if err != nil {
+		view.AddServiceMessage("Failed to jump to %s: %v", input, err)
+		view.parent.parent.Render()
+		return
+	}
+	resp, err := view.parent.matrix.GetEventNearTimestamp(context.TODO(), &jsoncmd.GetEventNearTimestampParams{
+		RoomID:    view.Room.ID,
+		Timestamp: jsontime.UM(date),
+		Direction: mautrix.DirectionForward,
+	})
+	if err != nil {
+		view.AddServiceMessage("Failed to jump to %s: %v", input, err)
+		view.parent.parent.Render()
+		return
+	}
+	dcm := NewDateContextModal(view, date.Format(dateContextDateFormat), resp.EventID)
+	view.parent.ShowModal(dcm)
+	view.parent.parent.Render()
+	dcm.load()
+}
+
+// DateContextModal shows the messages around an event outside the main timeline, as there's no safe way
+// to merge them into it. The messages are rendered as plain text like in saved views.
+type DateContextModal struct {
+	mauview.Component
+
+	container *mauview.Box
+	status    *mauview.TextField
+	text      *mauview.TextView
+
+	lock    sync.Mutex
+	loading bool
+	events  []*database.Event
+	start   string
+	end     string
+	target  id.EventID
+
+	parent *RoomView
+}
+
+func NewDateContextModal(parent *RoomView, label string, target id.EventID) *DateContextModal {
+	dcm := &DateContextModal{
+		parent:  parent,
+		target:  target,
+		loading: true,
+		status:  mauview.NewTextField().SetText("Loading messages..."),
+		text:    mauview.NewTextView().SetDynamicColors(true).SetWrap(true).SetWordWrap(true),
+	}
+	form := mauview.NewForm()
+	form.SetColumns([]int{1, 12, 1, 12, 1, 0, 1, 7, 1})
+	form.SetRows([]int{1})
+	form.AddFormItem(mauview.NewButton("Load older").SetOnClick(dcm.LoadOlder), 1, 0, 1, 1)
+	form.AddFormItem(mauview.NewButton("Load newer").SetOnClick(dcm.LoadNewer), 3, 0, 1, 1)
+	form.AddFormItem(mauview.NewButton("Close").SetOnClick(dcm.close), 7, 0, 1, 1)
+
+	flex := mauview.NewFlex().
+		SetDirection(mauview.FlexRow).
+		AddProportionalComponent(dcm.text, 1).
+		AddFixedComponent(dcm.status, 1).
+		AddFixedComponent(form, 1)
+	dcm.container = mauview.NewBox(flex).SetTitle("Messages around " + label)
+	center := mauview.Center(dcm.container, 100, 30).SetAlwaysFocusChild(true)
+	center.Focus()
+	form.FocusNextItem()
+	dcm.Component = center
+	return dcm
+}
+
+func (dcm *DateContextModal) load() {
+	resp, err := dcm.parent.parent.matrix.GetEventContext(context.TODO(), &jsoncmd.GetEventContextParams{
+		RoomID:  dcm.parent.Room.ID,
+		EventID: dcm.target,
+		Limit:   dateContextLimit,
+	})
+	dcm.lock.Lock()
+	dcm.loading = false
+	if err != nil {
+		dcm.lock.Unlock()
+		dcm.setStatus("Failed to load messages: %v", err)
+		return
+	}
+	// Events before the target are in reverse chronological order
+	dcm.events = make([]*database.Event, 0, len(resp.Before)+1+len(resp.After))
+	for i := len(resp.Before) - 1; i >= 0; i-- {
+		dcm.events = append(dcm.events, resp.Before[i])
+	}
+	dcm.events = append(dcm.events, resp.Event)
+	dcm.events = append(dcm.events, resp.After...)
+	dcm.start = resp.Start
+	dcm.end = resp.End
+	dcm.lock.Unlock()
+	dcm.render()
+}
+
+func (dcm *DateContextModal) paginate(direction mautrix.Direction) {
+	defer debug.Recover()
+	dcm.lock.Lock()
+	since := dcm.start
+	if direction == mautrix.DirectionForward {
+		since = dcm.end
+	}
+	if dcm.loading || since == "" {
+		dcm.lock.Unlock()
+		return
+	}
+	dcm.loading = true
+	dcm.lock.Unlock()
+	dcm.setStatus("Loading messages...")
+	resp, err := dcm.parent.parent.matrix.PaginateManual(context.TODO(), &jsoncmd.PaginateManualParams{
+		RoomID:    dcm.parent.Room.ID,
+		Since:     since,
+		Direction: direction,
+		Limit:     dateContextPageLimit,
+	})
+	dcm.lock.Lock()
+	dcm.loading = false
+	if err != nil {
+		dcm.lock.Unlock()
+		dcm.setStatus("Failed to load messages: %v", err)
+		return
+	}
+	if direction == mautrix.DirectionForward {
+		dcm.events = append(dcm.events, resp.Events...)
+		dcm.end = resp.NextBatch
+	} else {
+		older := make([]*database.Event, 0, len(resp.Events)+len(dcm.events))
+		for i := len(resp.Events) - 1; i >= 0; i-- {
+			older = append(older, resp.Events[i])
+		}
+		dcm.events = append(older, dcm.events...)
+		dcm.start = resp.NextBatch
+	}
+	dcm.lock.Unlock()
+	dcm.render()
+}
+
+func (dcm *DateContextModal) LoadOlder() {
+	go dcm.paginate(mautrix.DirectionBackward)
+}
+
+func (dcm *DateContextModal) LoadNewer() {
+	go dcm.paginate(mautrix.DirectionForward)
+}
+
+func (dcm *DateContextModal) setStatus(format string, args ...any) {
+	dcm.status.SetText(fmt.Sprintf(format, args...))
+	dcm.parent.parent.parent.Render()
+}
+
+func (dcm *DateContextModal) render() {
+	dcm.lock.Lock()
+	defer dcm.lock.Unlock()
+	dcm.text.Clear()
+	var prevDate string
+	for _, evt := range dcm.events {
+		msg := messages.ParseEvent(dcm.parent.parent.matrix, &dcm.parent.config.Preferences, dcm.parent.Room, evt)
+		if msg == nil {
+			continue
+		}
+		if date := msg.FormatDate(); date != prevDate {
+			_, _ = fmt.Fprintf(dcm.text, "[gray]-- %s --[-]\n", mauview.Escape(date))
+			prevDate = date
+		}
+		var buf strings.Builder
+		dcm.parent.content.formatPlaintextMessage(&buf, msg)
+		if evt.ID == dcm.target {
+			_, _ = fmt.Fprintf(dcm.text, "[yellow]%s[-]", mauview.Escape(buf.String()))
+		} else {
+			_, _ = fmt.Fprint(dcm.text, mauview.Escape(buf.String()))
+		}
+	}
+	var edges []string
+	if dcm.start == "" {
+		edges = append(edges, "no older messages")
+	}
+	if dcm.end == "" {
+		edges = append(edges, "no newer messages")
+	}
+	status := fmt.Sprintf("Showing %d events", len(dcm.events))
+	if len(edges) > 0 {
+		status += " (" + strings.Join(edges, ", ") + ")"
+	}
+	dcm.status.SetText(status)
+	dcm.parent.parent.parent.Render()
+}
+
+func (dcm *DateContextModal) close() {
+	dcm.parent.parent.HideModal()
+	dcm.parent.parent.parent.Render()
+}
+
+func (dcm *DateContextModal) OnKeyEvent(event mauview.KeyEvent) bool {
+	kb := config.Keybind{
+		Key: event.Key(),
+		Ch:  event.Rune(),
+		Mod: event.Modifiers(),
+	}
+	if dcm.parent.config.Keybindings.Modal[kb] == "cancel" {
+		dcm.close()
+		return true
+	}
+	switch event.Key() {
+	case tcell.KeyUp, tcell.KeyDown, tcell.KeyPgUp, tcell.KeyPgDn:
+		dcm.lock.Lock()
+		defer dcm.lock.Unlock()
+		return dcm.text.OnKeyEvent(event)
+	}
+	return dcm.Component.OnKeyEvent(event)
+}
@@ -0,0 +1,72 @@
+// gomuks - A terminal Matrix client written in Go.
+// Copyright (C) 2026 Tulir Asokan
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+package tui
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestParseJumpDate(t *testing.T) {
+	// 01:30 on Monday, 2026-03-02 in UTC+3 is still Sunday in UTC, so this also checks that
+	// the day boundaries are in the timezone of now rather than UTC.
+	loc := time.FixedZone("UTC+3", 3*60*60)
+	now := time.Date(2026, 3, 2, 1, 30, 0, 0, loc)
+	day := func(year int, month time.Month, day int) time.Time {
+		return time.Date(year, month, day, 0, 0, 0, 0, loc)
+	}
+	tests := []struct {
+		name  string
+		input string
+		want  time.Time
+	}{
+		{"today", "today", day(2026, 3, 2)},
+		{"yesterday", "yesterday", day(2026, 3, 1)},
+		{"same weekday wraps to last week", "monday", day(2026, 2, 23)},
+		{"previous day by weekday", "sunday", day(2026, 3, 1)},
+		{"earlier in last week", "tuesday", day(2026, 2, 24)},
+		{"last prefix", "last monday", day(2026, 2, 23)},
+		{"last prefix with other weekday", "last friday", day(2026, 2, 27)},
+		{"case and whitespace", "  Last Saturday ", day(2026, 2, 28)},
+		{"ISO date", "2025-03-03", day(2025, 3, 3)},
+		{"ISO date in the future", "2026-12-31", day(2026, 12, 31)},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			got, err := parseJumpDate(test.input, now)
+			if err != nil {
+				t.Fatalf("parseJumpDate(%q) returned error: %v", test.input, err)
+			}
+			if !got.Equal(test.want) || got.Location() != loc {
+				t.Errorf("parseJumpDate(%q) = %v, want %v", test.input, got, test.want)
+			}
+		})
+	}
+}
+
+func TestParseJumpDate_Invalid(t *testing.T) {
+	now := time.Date(2026, 3, 2, 12, 0, 0, 0, time.UTC)
+	for _, input := range []string{"", "someday", "last", "last week", "next monday", "2025-13-01", "03/03/2025"} {
+		t.Run(input, func(t *testing.T) {
+			got, err := parseJumpDate(input, now)
+			if !errors.Is(err, errInvalidDate) {
+				t.Errorf("parseJumpDate(%q) = %v, %v, want error %v", input, got, err, errInvalidDate)
+			}
+		})
+	}
+}
@@ -0,0 +1,95 @@
+// gomuks - A terminal Matrix client written in Go.
+// Copyright (C) 2026 Tulir Asokan
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+package tui
+
+import (
+	"cmp"
+	"context"
+	"fmt"
+	"runtime"
+	"slices"
+	"strings"
+
+	"go.mau.fi/util/ptr"
+
+	"go.mau.fi/gomuks/pkg/rpc/store"
+	"go.mau.fi/gomuks/tui/debug"
+)
+
+// debugStatsTopRooms is how many rooms with the most cached events are listed by /stats.
+const debugStatsTopRooms = 5
+
+type roomCacheSize struct {
+	room  *store.RoomStore
+	count int
+}
+
+// ShowDebugStats prints the stats of the local room cache, and the backend's runtime stats if they're enabled.
+func (view *RoomView) ShowDebugStats() {
+	defer debug.Recover()
+	main := view.parent
+	rooms := main.matrix.GetRooms()
+	sizes := make([]roomCacheSize, 0, len(rooms))
+	total := 0
+	for _, room := range rooms {
+		count := room.CachedEventCount()
+		total += count
+		if count > 0 {
+			sizes = append(sizes, roomCacheSize{room, count})
+		}
+	}
+	slices.SortFunc(sizes, func(a, b roomCacheSize) int {
+		return cmp.Compare(b.count, a.count)
+	})
+	top := make([]string, 0, debugStatsTopRooms)
+	for _, size := range sizes[:min(len(sizes), debugStatsTopRooms)] {
+		name := ptr.Val(size.room.Meta.Current().Name)
+		if name == "" {
+			name = size.room.ID.String()
+		}
+		top = append(top, fmt.Sprintf("%s: %d", name, size.count))
+	}
+	view.AddServiceMessage(
+		"Client: %d goroutines, %d cached events in %d rooms (%s)",
+		runtime.NumGoroutine(), total, len(sizes), strings.Join(top, ", "),
+	)
+
+	stats, err := main.matrix.GetDebugStats(context.TODO())
+	if err != nil {
+		view.AddServiceMessage("Failed to get backend stats: %v", err)
+		main.parent.Render()
+		return
+	}
+	view.AddServiceMessage(
+		"Backend: %d goroutines, %.1f MiB heap in %d objects, %d GCs",
+		stats.Goroutines, float64(stats.HeapAlloc)/1024/1024, stats.HeapObjects, stats.NumGC,
+	)
+	view.AddServiceMessage(
+		"Sync processing over %d syncs: p50 %d ms, p90 %d ms, p99 %d ms, max %d ms",
+		stats.Sync.Count, stats.Sync.P50MS, stats.Sync.P90MS, stats.Sync.P99MS, stats.Sync.MaxMS,
+	)
+	view.AddServiceMessage(
+		"Database: %d open connections (%d in use, %d idle), waited %d times for %d ms",
+		stats.Database.OpenConnections, stats.Database.InUse, stats.Database.Idle,
+		stats.Database.WaitCount, stats.Database.WaitDurationMS,
+	)
+	view.AddServiceMessage("Queues: %d sessions waiting for keys, %d events waiting to be sent", stats.DecryptionQueue, stats.SendQueue)
+	if eb := stats.EventBuffer; eb != nil {
+		view.AddServiceMessage("Event buffer: %d/%d events, %d listeners", eb.Length, eb.MaxSize, eb.Listeners)
+	}
+	main.parent.Render()
+}
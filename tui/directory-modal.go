@@ -0,0 +1,360 @@
+// gomuks - A terminal Matrix client written in Go.
+// Copyright (C) 2026 Tulir Asokan
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+package tui
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gdamore/tcell/v2"
+	"go.mau.fi/mauview"
+	"maunium.net/go/mautrix"
+	"maunium.net/go/mautrix/event"
+
+	"go.mau.fi/gomuks/pkg/hicli/jsoncmd"
+	"go.mau.fi/gomuks/tui/config"
+	"go.mau.fi/gomuks/tui/debug"
+)
+
+const (
+	directorySearchDebounce = 500 * time.Millisecond
+	directoryPageSize       = 30
+	directoryTopicLength    = 60
+)
+
+type DirectoryModal struct {
+	mauview.Component
+
+	container *mauview.Box
+
+	search  *mauview.InputArea
+	status  *mauview.TextField
+	results *mauview.TextView
+	details *mauview.TextView
+
+	server string
+
+	lock          sync.Mutex
+	rooms         []*mautrix.PublicRoomInfo
+	nextBatch     string
+	selected      int
+	searchTerm    string
+	debounceTimer *time.Timer
+	cancelSearch  context.CancelFunc
+	loading       bool
+
+	parent *MainView
+}
+
+func NewDirectoryModal(mainView *MainView, server string, width, height int) *DirectoryModal {
+	dm := &DirectoryModal{
+		parent: mainView,
+		server: server,
+	}
+
+	dm.results = mauview.NewTextView().SetRegions(true)
+	dm.details = mauview.NewTextView().SetWordWrap(true).SetTextColor(tcell.ColorGray)
+	dm.status = mauview.NewTextField()
+	dm.search = mauview.NewInputArea().
+		SetPlaceholder("Search for public rooms...").
+		SetChangedFunc(dm.changeHandler).
+		SetTextColor(tcell.ColorWhite).
+		SetBackgroundColor(tcell.ColorDarkCyan)
+	dm.search.Focus()
+
+	flex := mauview.NewFlex().
+		SetDirection(mauview.FlexRow).
+		AddFixedComponent(dm.search, 1).
+		AddFixedComponent(dm.status, 1).
+		AddProportionalComponent(dm.results, 1).
+		AddFixedComponent(dm.details, 4)
+
+	title := "Room Directory"
+	if server != "" {
+		title = fmt.Sprintf("Room Directory (%s)", server)
+	}
+	dm.container = mauview.NewBox(flex).
+		SetBorder(true).
+		SetTitle(title).
+		SetBlurCaptureFunc(func() bool {
+			dm.close()
+			return true
+		})
+
+	dm.Component = mauview.Center(dm.container, width, height).SetAlwaysFocusChild(true)
+
+	go dm.load("", "")
+
+	return dm
+}
+
+func (dm *DirectoryModal) Focus() {
+	dm.container.Focus()
+}
+
+func (dm *DirectoryModal) Blur() {
+	dm.container.Blur()
+}
+
+func (dm *DirectoryModal) close() {
+	dm.lock.Lock()
+	if dm.debounceTimer != nil {
+		dm.debounceTimer.Stop()
+	}
+	if dm.cancelSearch != nil {
+		dm.cancelSearch()
+	}
+	dm.lock.Unlock()
+	dm.parent.HideModal()
+}
+
+func (dm *DirectoryModal) changeHandler(text string) {
+	dm.lock.Lock()
+	defer dm.lock.Unlock()
+	if dm.debounceTimer != nil {
+		dm.debounceTimer.Stop()
+	}
+	dm.debounceTimer = time.AfterFunc(directorySearchDebounce, func() {
+		dm.load(strings.TrimSpace(text), "")
+	})
+}
+
+// load fetches a page of results. If since is empty, the current results are replaced,
+// otherwise the new results are appended. Any previous in-flight request is cancelled.
+func (dm *DirectoryModal) load(term, since string) {
+	defer debug.Recover()
+	ctx, cancel := context.WithCancel(context.Background())
+	dm.lock.Lock()
+	if dm.cancelSearch != nil {
+		dm.cancelSearch()
+	}
+	dm.cancelSearch = cancel
+	dm.searchTerm = term
+	dm.loading = true
+	dm.lock.Unlock()
+	dm.setStatus("Loading...")
+
+	resp, err := dm.parent.matrix.SearchPublicRooms(ctx, &jsoncmd.SearchPublicRoomsParams{
+		Server: dm.server,
+		Filter: term,
+		Since:  since,
+		Limit:  directoryPageSize,
+	})
+	if errors.Is(err, context.Canceled) {
+		return
+	}
+	defer cancel()
+
+	dm.lock.Lock()
+	dm.loading = false
+	if err != nil {
+		dm.lock.Unlock()
+		dm.setStatus(fmt.Sprintf("Failed to search rooms: %v", err))
+		return
+	}
+	if since == "" {
+		dm.rooms = resp.Chunk
+		dm.selected = 0
+	} else {
+		dm.rooms = append(dm.rooms, resp.Chunk...)
+	}
+	dm.nextBatch = resp.NextBatch
+	count := len(dm.rooms)
+	dm.lock.Unlock()
+
+	if count == 0 {
+		dm.setStatus("No rooms found")
+	} else if resp.TotalRoomCountEstimate > 0 {
+		dm.setStatus(fmt.Sprintf("Showing %d of about %d rooms", count, resp.TotalRoomCountEstimate))
+	} else {
+		dm.setStatus(fmt.Sprintf("Showing %d rooms", count))
+	}
+	dm.render()
+}
+
+func (dm *DirectoryModal) setStatus(text string) {
+	dm.status.SetText(text)
+	dm.parent.parent.Render()
+}
+
+func truncateTopic(topic string) string {
+	topic = strings.Join(strings.Fields(topic), " ")
+	if len([]rune(topic)) > directoryTopicLength {
+		return string([]rune(topic)[:directoryTopicLength-1]) + "…"
+	}
+	return topic
+}
+
+func (dm *DirectoryModal) render() {
+	dm.lock.Lock()
+	defer dm.lock.Unlock()
+	dm.results.Clear()
+	for i, room := range dm.rooms {
+		name := room.Name
+		if name == "" {
+			name = room.CanonicalAlias.String()
+		}
+		if name == "" {
+			name = room.RoomID.String()
+		}
+		var extra []string
+		if room.CanonicalAlias != "" && name != room.CanonicalAlias.String() {
+			extra = append(extra, room.CanonicalAlias.String())
+		}
+		extra = append(extra, fmt.Sprintf("%d members", room.NumJoinedMembers))
+		if existing := dm.parent.matrix.GetRoom(room.RoomID); existing != nil {
+			extra = append(extra, "joined")
+		} else if room.JoinRule == event.JoinRuleKnock {
+			extra = append(extra, "knock")
+		}
+		_, _ = fmt.Fprintf(dm.results, `["%d"]%s (%s)`, i, name, strings.Join(extra, ", "))
+		if topic := truncateTopic(room.Topic); topic != "" {
+			_, _ = fmt.Fprintf(dm.results, "\n  %s", topic)
+		}
+		_, _ = fmt.Fprint(dm.results, `[""]`+"\n")
+	}
+	if len(dm.rooms) > 0 {
+		dm.selected = min(dm.selected, len(dm.rooms)-1)
+		dm.results.Highlight(strconv.Itoa(dm.selected))
+		dm.results.ScrollToHighlight()
+	} else {
+		dm.results.Highlight()
+	}
+	dm.details.SetText("")
+	dm.parent.parent.Render()
+}
+
+func (dm *DirectoryModal) moveSelection(diff int) {
+	dm.lock.Lock()
+	if len(dm.rooms) == 0 {
+		dm.lock.Unlock()
+		return
+	}
+	dm.selected = max(0, min(dm.selected+diff, len(dm.rooms)-1))
+	dm.results.Highlight(strconv.Itoa(dm.selected))
+	dm.results.ScrollToHighlight()
+	dm.details.SetText("")
+	// Load the next page when the selection gets close to the end of the list
+	shouldLoadMore := dm.selected >= len(dm.rooms)-3 && dm.nextBatch != "" && !dm.loading
+	term, since := dm.searchTerm, dm.nextBatch
+	dm.lock.Unlock()
+	if shouldLoadMore {
+		go dm.load(term, since)
+	}
+}
+
+func (dm *DirectoryModal) getSelected() *mautrix.PublicRoomInfo {
+	dm.lock.Lock()
+	defer dm.lock.Unlock()
+	if dm.selected < 0 || dm.selected >= len(dm.rooms) {
+		return nil
+	}
+	return dm.rooms[dm.selected]
+}
+
+func (dm *DirectoryModal) via() []string {
+	if dm.server != "" {
+		return []string{dm.server}
+	}
+	return nil
+}
+
+func (dm *DirectoryModal) preview(room *mautrix.PublicRoomInfo) {
+	defer debug.Recover()
+	dm.details.SetText("Loading room preview...")
+	dm.parent.parent.Render()
+	summary, err := dm.parent.matrix.GetRoomSummary(context.TODO(), &jsoncmd.GetRoomSummaryParams{
+		RoomIDOrAlias: room.RoomID.String(),
+		Via:           dm.via(),
+	})
+	if err != nil {
+		dm.details.SetText(fmt.Sprintf("Failed to get room preview: %v", err))
+	} else {
+		var buf strings.Builder
+		_, _ = fmt.Fprintf(&buf, "%s - %d members, join rule: %s", room.RoomID, summary.NumJoinedMembers, summary.JoinRule)
+		if summary.Encryption != "" {
+			buf.WriteString(", encrypted")
+		}
+		if summary.Topic != "" {
+			buf.WriteString("\n")
+			buf.WriteString(strings.Join(strings.Fields(summary.Topic), " "))
+		}
+		dm.details.SetText(buf.String())
+	}
+	dm.parent.parent.Render()
+}
+
+func (dm *DirectoryModal) join(room *mautrix.PublicRoomInfo) {
+	defer debug.Recover()
+	if dm.parent.matrix.GetRoom(room.RoomID) != nil {
+		dm.close()
+		dm.parent.SwitchRoom(room.RoomID)
+		return
+	}
+	dm.details.SetText("Joining room...")
+	dm.parent.parent.Render()
+	target := room.RoomID.String()
+	if room.CanonicalAlias != "" {
+		target = room.CanonicalAlias.String()
+	}
+	_, err := dm.parent.matrix.JoinRoom(context.TODO(), &jsoncmd.JoinRoomParams{
+		RoomIDOrAlias: target,
+		Via:           dm.via(),
+	})
+	if err != nil {
+		dm.details.SetText(fmt.Sprintf("Failed to join room: %v", err))
+		dm.parent.parent.Render()
+		return
+	}
+	dm.close()
+	dm.parent.SwitchRoomWhenJoined(room.RoomID)
+}
+
+func (dm *DirectoryModal) OnKeyEvent(event mauview.KeyEvent) bool {
+	kb := config.Keybind{
+		Key: event.Key(),
+		Ch:  event.Rune(),
+		Mod: event.Modifiers(),
+	}
+	switch dm.parent.config.Keybindings.Modal[kb] {
+	case "cancel":
+		dm.close()
+		return true
+	case "select_next":
+		dm.moveSelection(1)
+		return true
+	case "select_prev":
+		dm.moveSelection(-1)
+		return true
+	case "preview":
+		if room := dm.getSelected(); room != nil {
+			go dm.preview(room)
+		}
+		return true
+	case "confirm":
+		if room := dm.getSelected(); room != nil {
+			go dm.join(room)
+		}
+		return true
+	}
+	return dm.search.OnKeyEvent(event)
+}
@@ -0,0 +1,179 @@
+// gomuks - A terminal Matrix client written in Go.
+// Copyright (C) 2025 Tulir Asokan
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+package tui
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+
+	"maunium.net/go/mautrix/event"
+	"maunium.net/go/mautrix/id"
+
+	"go.mau.fi/gomuks/pkg/hicli/jsoncmd"
+	"go.mau.fi/gomuks/tui/debug"
+)
+
+// EnableEncryption sends an m.room.encryption event with the default Megolm settings.
+// Nothing is sent unless confirm is true, as encryption can't be disabled afterwards.
+func (view *RoomView) EnableEncryption(confirm bool) {
+	defer debug.Recover()
+	defer view.parent.parent.Render()
+	if view.Room.Meta.Current().EncryptionEvent != nil {
+		view.AddServiceMessage("Encryption is already enabled in this room")
+		return
+	}
+	pls := view.Room.GetPowerLevels()
+	if required := pls.GetEventLevel(event.StateEncryption); pls.GetUserLevel(view.parent.matrix.UserID) < required {
+		view.AddServiceMessage("You don't have permission to enable encryption (power level %d required)", required)
+		return
+	}
+	if !confirm {
+		view.AddServiceMessage(
+			"Enabling end-to-end encryption is permanent and can't be undone. " +
+				"Bridges and bots may stop working and new members won't be able to read older messages. " +
+				"To confirm, run /encrypt confirm",
+		)
+		return
+	}
+	content, err := json.Marshal(&event.EncryptionEventContent{
+		Algorithm:              id.AlgorithmMegolmV1,
+		RotationPeriodMillis:   7 * 24 * 60 * 60 * 1000,
+		RotationPeriodMessages: 100,
+	})
+	if err != nil {
+		view.AddServiceMessage("Failed to marshal encryption event: %v", err)
+		return
+	}
+	_, err = view.parent.matrix.SetState(context.TODO(), &jsoncmd.SendStateEventParams{
+		RoomID:    view.Room.ID,
+		EventType: event.StateEncryption,
+		Content:   content,
+	})
+	if err != nil {
+		view.AddServiceMessage("Failed to enable encryption: %v", err)
+	}
+}
+
+// onEncryptionEnabled is called when an encryption event arrives for a room that was previously unencrypted.
+// It shows a notice and shares the Megolm session right away, so that the first encrypted message isn't slow.
+func (view *RoomView) onEncryptionEnabled() {
+	defer debug.Recover()
+	enabledBy := "someone"
+	if evt := view.Room.GetStateEvent(event.StateEncryption, ""); evt != nil {
+		enabledBy = view.Room.GetDisplayname(evt.Sender)
+	}
+	view.AddServiceMessage("Encryption enabled by %s - messages are now end-to-end encrypted", enabledBy)
+	view.parent.parent.Render()
+	err := view.parent.matrix.EnsureGroupSessionShared(context.TODO(), &jsoncmd.EnsureGroupSessionSharedParams{
+		RoomID: view.Room.ID,
+	})
+	if err != nil {
+		debug.Print("Failed to share group session after encryption was enabled:", err)
+	}
+}
+
+// formatEncryptionInfo formats the encryption details of an event for the view source modal.
+func formatEncryptionInfo(info *jsoncmd.EventEncryptionInfo) string {
+	var buf strings.Builder
+	buf.WriteString("Encryption:\n")
+	if !info.Encrypted {
+		buf.WriteString("  Not encrypted\n")
+		return buf.String()
+	}
+	_, _ = fmt.Fprintf(&buf, "  Algorithm:     %s\n", info.Algorithm)
+	_, _ = fmt.Fprintf(&buf, "  Session ID:    %s\n", info.SessionID)
+	if info.DecryptionError != "" {
+		_, _ = fmt.Fprintf(&buf, "  Error:         %s\n", info.DecryptionError)
+	}
+	if !info.HasSession {
+		buf.WriteString("  This device doesn't have the session\n")
+		return buf.String()
+	}
+	if info.SenderDevice != nil {
+		_, _ = fmt.Fprintf(&buf, "  Sender device: %s", info.SenderDevice.DeviceID)
+		if info.SenderDevice.Name != "" {
+			_, _ = fmt.Fprintf(&buf, " (%s)", info.SenderDevice.Name)
+		}
+		buf.WriteByte('\n')
+	}
+	if info.SenderTrust != nil {
+		_, _ = fmt.Fprintf(&buf, "  Trust:         %s\n", info.SenderTrust.String())
+	}
+	if info.Outbound {
+		if info.Share != nil {
+			_, _ = fmt.Fprintf(
+				&buf, "  Shared with:   %d devices of %d users at %s\n",
+				info.Share.DeviceCount, info.Share.UserCount, info.Share.SharedAt.Time.Format(time.DateTime),
+			)
+		} else {
+			buf.WriteString("  Shared with:   unknown (the session was shared before share counts were recorded)\n")
+		}
+	} else {
+		source := "directly from the sender"
+		if info.Forwarded {
+			source = "forwarded by another device"
+		}
+		if info.KeyBackupVersion != "" {
+			source += fmt.Sprintf(", stored in key backup version %s", info.KeyBackupVersion)
+		}
+		_, _ = fmt.Fprintf(&buf, "  Received:      %s, %s\n", info.ReceivedAt.Time.Format(time.DateTime), source)
+	}
+	_, _ = fmt.Fprintf(&buf, "  Use /devices-for-session %s to see which of your devices have the session\n", info.SessionID)
+	return buf.String()
+}
+
+// ShowSessionDevices lists which of the user's own devices have the given megolm session.
+func (view *RoomView) ShowSessionDevices(sessionID id.SessionID) {
+	defer debug.Recover()
+	defer view.parent.parent.Render()
+	if sessionID == "" {
+		view.AddServiceMessage("Usage: /devices-for-session <session ID>")
+		return
+	}
+	resp, err := view.parent.matrix.GetSessionDevices(context.TODO(), &jsoncmd.GetSessionDevicesParams{
+		RoomID:    view.Room.ID,
+		SessionID: sessionID,
+	})
+	if err != nil {
+		view.AddServiceMessage("Failed to get session devices: %v", err)
+		return
+	}
+	var buf strings.Builder
+	if resp.Outbound {
+		_, _ = fmt.Fprintf(&buf, "Session %s was created by this device:", sessionID)
+	} else {
+		_, _ = fmt.Fprintf(&buf, "Session %s wasn't created by this device, so other devices can't be checked:", sessionID)
+	}
+	for _, device := range resp.Devices {
+		status := "doesn't have the session"
+		if device.HasSession {
+			status = "has the session"
+		}
+		name := device.DeviceID.String()
+		if device.Name != "" {
+			name = fmt.Sprintf("%s (%s)", device.DeviceID, device.Name)
+		}
+		if device.Current {
+			name += " [this device]"
+		}
+		_, _ = fmt.Fprintf(&buf, "\n* %s %s, trust: %s", name, status, device.Trust.String())
+	}
+	view.AddServiceMessage(buf.String())
+}
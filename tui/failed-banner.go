@@ -0,0 +1,109 @@
+// gomuks - A terminal Matrix client written in Go.
+// Copyright (C) 2025 Tulir Asokan
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+package tui
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/gdamore/tcell/v2"
+	"go.mau.fi/mauview"
+
+	"go.mau.fi/gomuks/pkg/hicli/jsoncmd"
+	"go.mau.fi/gomuks/tui/debug"
+	"go.mau.fi/gomuks/tui/widget"
+)
+
+// failedBannerHeight returns the number of rows the failed message banner takes.
+func (view *RoomView) failedBannerHeight() int {
+	if view.Room.FailedEventCount() == 0 {
+		return 0
+	}
+	return 1
+}
+
+func (view *RoomView) drawFailedBanner(screen mauview.Screen) {
+	count := view.Room.FailedEventCount()
+	if count == 0 {
+		return
+	}
+	var text string
+	if view.failedBusy.Load() {
+		text = "Processing failed messages..."
+	} else if count == 1 {
+		text = "1 message failed to send — press R to retry, D to discard"
+	} else {
+		text = fmt.Sprintf("%d messages failed to send — press R to retry all, D to discard", count)
+	}
+	widget.WriteLineSimpleColor(screen, text, 0, 0, tcell.ColorRed)
+}
+
+// onFailedBannerKey handles the retry and discard keys of the failed message banner. The keys are only
+// captured while the input is empty, so that typing a message isn't interrupted.
+func (view *RoomView) onFailedBannerKey(event mauview.KeyEvent) bool {
+	if event.Key() != tcell.KeyRune || view.input.GetText() != "" || view.Room.FailedEventCount() == 0 {
+		return false
+	}
+	switch event.Rune() {
+	case 'R':
+		go view.RetryFailed()
+	case 'D':
+		go view.DiscardFailed()
+	default:
+		return false
+	}
+	return true
+}
+
+// RetryFailed resends all failed messages in the room in chronological order. It stops at the first message
+// that can't be resent, so that the remaining ones don't get sent out of order.
+func (view *RoomView) RetryFailed() {
+	defer debug.Recover()
+	if !view.failedBusy.CompareAndSwap(false, true) {
+		return
+	}
+	defer view.failedBusy.Store(false)
+	for _, evt := range view.Room.GetFailedEvents() {
+		_, err := view.parent.matrix.ResendEvent(context.TODO(), &jsoncmd.ResendEventParams{
+			TransactionID: evt.TransactionID,
+		})
+		if err != nil {
+			view.AddServiceMessage("Failed to resend message: %v", err)
+			break
+		}
+	}
+	view.parent.parent.Render()
+}
+
+// DiscardFailed drops all failed messages in the room, so that they won't be shown again.
+func (view *RoomView) DiscardFailed() {
+	defer debug.Recover()
+	if !view.failedBusy.CompareAndSwap(false, true) {
+		return
+	}
+	defer view.failedBusy.Store(false)
+	for _, evt := range view.Room.GetFailedEvents() {
+		err := view.parent.matrix.DiscardEvent(context.TODO(), &jsoncmd.DiscardEventParams{
+			TransactionID: evt.TransactionID,
+		})
+		if err != nil {
+			view.AddServiceMessage("Failed to discard message: %v", err)
+			break
+		}
+	}
+	view.parent.parent.Render()
+}
@@ -0,0 +1,135 @@
+// gomuks - A terminal Matrix client written in Go.
+// Copyright (C) 2026 Tulir Asokan
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+package tui
+
+import (
+	"sync"
+	"time"
+
+	"maunium.net/go/mautrix/id"
+
+	"go.mau.fi/gomuks/tui/config"
+	"go.mau.fi/gomuks/tui/debug"
+)
+
+// recentInputWindow is how recent the last input has to be for the user to count as looking at the current room
+// when the terminal doesn't report focus events.
+const recentInputWindow = 30 * time.Second
+
+// focusReporter is implemented by applications that can forward terminal focus events.
+// Setting a handler also enables focus reporting in terminals that support it.
+type focusReporter interface {
+	SetFocusHandler(handler func(focused bool))
+}
+
+// TerminalFocus keeps track of whether the terminal window is focused. Terminals don't advertise support for
+// focus reporting, so the state is only known after the first focus event has been received.
+type TerminalFocus struct {
+	lock      sync.Mutex
+	focused   bool
+	supported bool
+}
+
+// Set records a focus event from the terminal.
+func (tf *TerminalFocus) Set(focused bool) {
+	tf.lock.Lock()
+	defer tf.lock.Unlock()
+	if !tf.supported {
+		debug.Print("Terminal supports focus events")
+	}
+	tf.focused = focused
+	tf.supported = true
+}
+
+// Get returns whether the terminal is focused, and whether the terminal has reported any focus events.
+func (tf *TerminalFocus) Get() (focused, supported bool) {
+	tf.lock.Lock()
+	defer tf.lock.Unlock()
+	return tf.focused, tf.supported
+}
+
+// TerminalFocused returns whether the terminal is focused according to focus events, taking the focus detection
+// preference into account. If focus events aren't available, ok is false and the idle tracker should be used instead.
+func (view *MainView) TerminalFocused() (focused, ok bool) {
+	switch view.config.Preferences.GetFocusDetection() {
+	case config.FocusDetectionDisable:
+		return false, false
+	case config.FocusDetectionEnable:
+		focused, supported := view.focus.Get()
+		// Assume the terminal is focused until it says otherwise
+		return focused || !supported, true
+	default:
+		return view.focus.Get()
+	}
+}
+
+// IsPresent returns true if the user is probably looking at gomuks.
+func (view *MainView) IsPresent() bool {
+	if focused, ok := view.TerminalFocused(); ok {
+		return focused
+	}
+	return !view.idle.IsIdle()
+}
+
+// shouldNotify decides whether a message in a room needs a notification. Messages in the room open in the focused
+// pane don't need one while the user is looking at gomuks. When the terminal doesn't report focus events, focused is
+// only a guess based on recent input, so highlights are notified even in the current room.
+func shouldNotify(isCurrentRoom, focused, focusReported, highlight bool) bool {
+	if !isCurrentRoom || !focused {
+		return true
+	}
+	return highlight && !focusReported
+}
+
+// shouldNotify decides whether a message in the given room needs a notification, see shouldNotify.
+func (view *MainView) shouldNotify(roomID id.RoomID, highlight bool) bool {
+	focused, ok := view.TerminalFocused()
+	if !ok {
+		focused = !view.idle.IsIdle() && view.idle.SinceLastInput() < recentInputWindow
+	}
+	return shouldNotify(view.isCurrentRoom(roomID), focused, ok, highlight)
+}
+
+// HandleTerminalFocus is called when the terminal window gains or loses focus.
+func (view *MainView) HandleTerminalFocus(focused bool) {
+	view.focus.Set(focused)
+	if view.config.Preferences.GetFocusDetection() == config.FocusDetectionDisable {
+		return
+	}
+	if !focused {
+		debug.Print("Terminal lost focus, stopping typing notifications")
+		view.StopTyping()
+		return
+	}
+	view.idle.Bump()
+	if view.currentRoom != nil {
+		view.MarkReadIfActive(view.currentRoom)
+	}
+}
+
+func (ui *GomuksTUI) enableFocusReporting() {
+	reporter, ok := any(ui.app).(focusReporter)
+	if !ok {
+		debug.Print("Focus events aren't available, using input activity to detect presence")
+		return
+	}
+	reporter.SetFocusHandler(func(focused bool) {
+		if ui.MainView != nil {
+			ui.MainView.HandleTerminalFocus(focused)
+		}
+	})
+}
@@ -0,0 +1,59 @@
+// gomuks - A terminal Matrix client written in Go.
+// Copyright (C) 2026 Tulir Asokan
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+package tui
+
+import (
+	"fmt"
+	"testing"
+)
+
+func TestShouldNotify(t *testing.T) {
+	tests := []struct {
+		currentRoom, focused, focusReported, highlight bool
+		want                                           bool
+	}{
+		// Other rooms are always notified
+		{false, false, false, false, true},
+		{false, false, false, true, true},
+		{false, false, true, false, true},
+		{false, false, true, true, true},
+		{false, true, false, false, true},
+		{false, true, false, true, true},
+		{false, true, true, false, true},
+		{false, true, true, true, true},
+		// The current room is notified when the user isn't looking
+		{true, false, false, false, true},
+		{true, false, false, true, true},
+		{true, false, true, false, true},
+		{true, false, true, true, true},
+		// The current room isn't notified when the terminal says it's focused
+		{true, true, true, false, false},
+		{true, true, true, true, false},
+		// Recent input only suppresses normal messages
+		{true, true, false, false, false},
+		{true, true, false, true, true},
+	}
+	for _, test := range tests {
+		name := fmt.Sprintf("currentRoom=%t,focused=%t,focusReported=%t,highlight=%t",
+			test.currentRoom, test.focused, test.focusReported, test.highlight)
+		t.Run(name, func(t *testing.T) {
+			if got := shouldNotify(test.currentRoom, test.focused, test.focusReported, test.highlight); got != test.want {
+				t.Errorf("shouldNotify() = %t, want %t", got, test.want)
+			}
+		})
+	}
+}
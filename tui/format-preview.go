@@ -0,0 +1,171 @@
+// gomuks - A terminal Matrix client written in Go.
+// Copyright (C) 2026 Tulir Asokan
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+package tui
+
+import (
+	"html"
+	"strings"
+	"unicode"
+
+	"github.com/gdamore/tcell/v2"
+	"github.com/mattn/go-runewidth"
+	"maunium.net/go/mautrix/event"
+	"maunium.net/go/mautrix/event/cmdschema"
+	"maunium.net/go/mautrix/format"
+
+	"go.mau.fi/gomuks/pkg/rainbow"
+)
+
+func makePassthroughCommand(name, description string) *cmdschema.EventContent {
+	return &cmdschema.EventContent{
+		Command:     name,
+		Description: event.MakeExtensibleText(description),
+		Parameters: []*cmdschema.Parameter{{
+			Key:    "text",
+			Schema: cmdschema.PrimitiveTypeString.Schema(),
+		}},
+		TailParam: "text",
+	}
+}
+
+// PassthroughCommands are prefixes that the backend handles inside SendMessage. They're only listed here for
+// autocompletion: messages starting with them are sent as-is instead of being parsed as commands.
+var PassthroughCommands = []*cmdschema.EventContent{
+	makePassthroughCommand("plain", "Send a plain text message without any formatting"),
+	makePassthroughCommand("html", "Send a formatted message with only HTML (no markdown)"),
+	makePassthroughCommand("rainbow", "Send a message with rainbow colors (markdown allowed)"),
+	makePassthroughCommand("me", "Send an m.emote message"),
+	makePassthroughCommand("notice", "Send an m.notice message"),
+	makePassthroughCommand("unencrypted", "Send an unencrypted message even if the room is encrypted"),
+	makePassthroughCommand("rawinputbody", "Use the input text as the body field as-is, rather than re-parsing generated HTML"),
+	makePassthroughCommand("timestamp", "Send a message with a custom timestamp, given before the text"),
+}
+
+// isPassthroughCommand checks if the input starts with a prefix that must be forwarded to the backend untouched.
+// Inputs starting with a double slash aren't passthrough commands, the backend strips one slash and sends them as text.
+func isPassthroughCommand(text string) bool {
+	for _, cmd := range PassthroughCommands {
+		if strings.HasPrefix(text, "/"+cmd.Command+" ") {
+			return true
+		}
+	}
+	return false
+}
+
+// commandDescription returns the plaintext description of a command, or an empty string if it doesn't have one.
+func commandDescription(cmd *cmdschema.EventContent) string {
+	if cmd.Description == nil {
+		return ""
+	}
+	for _, text := range cmd.Description.Text {
+		if text.MimeType == "" || text.MimeType == "text/plain" {
+			return text.Body
+		}
+	}
+	return ""
+}
+
+type previewSegment struct {
+	text  string
+	color tcell.Color
+}
+
+// rainbowSegments colors each non-space character of the text like the backend's rainbow formatter does.
+func rainbowSegments(text string) []previewSegment {
+	count := 0
+	for _, r := range text {
+		if !unicode.IsSpace(r) {
+			count++
+		}
+	}
+	segments := make([]previewSegment, 0, len(text))
+	i := 0
+	for _, r := range text {
+		if unicode.IsSpace(r) {
+			segments = append(segments, previewSegment{" ", tcell.ColorDefault})
+			continue
+		}
+		red, green, blue := rainbow.Gradient.GetInterpolatedColorFor(float64(i) / float64(count)).RGB255()
+		segments = append(segments, previewSegment{string(r), tcell.NewRGBColor(int32(red), int32(green), int32(blue))})
+		i++
+	}
+	return segments
+}
+
+// formatPreview returns an approximation of how the message in the input box will look after the backend
+// applies the formatting command at the start of it. If the input doesn't start with a formatting command,
+// this returns an empty label.
+func (view *RoomView) formatPreview(text string) (label string, segments []previewSegment) {
+	cmd, body, found := strings.Cut(text, " ")
+	if !found || !strings.HasPrefix(cmd, "/") || strings.TrimSpace(body) == "" {
+		return "", nil
+	}
+	body = strings.Join(strings.Fields(body), " ")
+	switch cmd[1:] {
+	case "rainbow":
+		return "Rainbow: ", rainbowSegments(body)
+	case "html":
+		parsed := format.HTMLToText(strings.ReplaceAll(text[len(cmd)+1:], "\n", "<br>"))
+		return "HTML: ", []previewSegment{{strings.Join(strings.Fields(parsed), " "), tcell.ColorDefault}}
+	case "plain":
+		return "Plain: ", []previewSegment{{body, tcell.ColorDefault}}
+	case "me":
+		ownName := view.parent.matrix.UserID.String()
+		if member := view.Room.GetMember(view.parent.matrix.UserID); member != nil && member.Displayname != "" {
+			ownName = member.Displayname
+		}
+		return "Emote: ", []previewSegment{{"* " + ownName + " " + body, tcell.ColorDefault}}
+	case "notice":
+		return "Notice: ", []previewSegment{{body, tcell.ColorGray}}
+	case CmdSpoiler:
+		return "Spoiler: ", []previewSegment{{body, tcell.ColorDarkGray}}
+	case CmdFixed:
+		return "Code: ", []previewSegment{{body, tcell.ColorGreen}}
+	default:
+		return "", nil
+	}
+}
+
+func (view *RoomView) formatPreviewHeight() int {
+	if label, _ := view.formatPreview(view.input.GetText()); label != "" {
+		return 1
+	}
+	return 0
+}
+
+func (view *RoomView) drawFormatPreview(write func(text string, y int, color tcell.Color), width, y int) {
+	label, segments := view.formatPreview(view.input.GetText())
+	if label == "" {
+		return
+	}
+	write(label, y, tcell.ColorGray)
+	remaining := width - runewidth.StringWidth(label)
+	for _, segment := range segments {
+		segmentWidth := runewidth.StringWidth(segment.text)
+		if segmentWidth > remaining {
+			write(runewidth.Truncate(segment.text, remaining, "…"), y, segment.color)
+			return
+		}
+		write(segment.text, y, segment.color)
+		remaining -= segmentWidth
+	}
+}
+
+// fixedHTML formats the text as a code block for the /fixed command.
+func fixedHTML(text string) string {
+	return "<pre><code>" + html.EscapeString(text) + "</code></pre>"
+}
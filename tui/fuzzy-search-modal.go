@@ -17,7 +17,9 @@
 package tui
 
 import (
+	"cmp"
 	"fmt"
+	"slices"
 	"sort"
 	"strconv"
 
@@ -41,20 +43,19 @@ type FuzzySearchModal struct {
 	matches  fuzzy.Ranks
 	selected int
 
-	roomList   []*store.RoomListEntry
-	roomTitles []string
+	roomList      []*store.RoomListEntry
+	roomTitles    []string
+	includeHidden bool
 
 	parent *MainView
 }
 
-func NewFuzzySearchModal(mainView *MainView, width int, height int) *FuzzySearchModal {
+// NewFuzzySearchModal creates a room switcher. Hidden rooms are only included if includeHidden is set,
+// but can also be toggled with a keybinding.
+func NewFuzzySearchModal(mainView *MainView, width int, height int, includeHidden bool) *FuzzySearchModal {
 	fs := &FuzzySearchModal{
-		parent:   mainView,
-		roomList: mainView.matrix.ReversedRoomList.Current(),
-	}
-	fs.roomTitles = make([]string, len(fs.roomList))
-	for i, room := range fs.roomList {
-		fs.roomTitles[i] = room.Name
+		parent:        mainView,
+		includeHidden: includeHidden,
 	}
 
 	fs.results = mauview.NewTextView().SetRegions(true)
@@ -78,10 +79,26 @@ func NewFuzzySearchModal(mainView *MainView, width int, height int) *FuzzySearch
 		})
 
 	fs.Component = mauview.Center(fs.container, width, height).SetAlwaysFocusChild(true)
+	fs.loadRooms()
+	fs.changeHandler("")
 
 	return fs
 }
 
+func (fs *FuzzySearchModal) loadRooms() {
+	fs.roomList = fs.parent.matrix.ReversedRoomList.Current()
+	if fs.includeHidden {
+		fs.roomList = append(slices.Clone(fs.roomList), fs.parent.matrix.GetHiddenRoomList()...)
+		fs.container.SetTitle("Quick Room Switcher (including hidden rooms)")
+	} else {
+		fs.container.SetTitle("Quick Room Switcher")
+	}
+	fs.roomTitles = make([]string, len(fs.roomList))
+	for i, room := range fs.roomList {
+		fs.roomTitles[i] = room.Name
+	}
+}
+
 func (fs *FuzzySearchModal) Focus() {
 	fs.container.Focus()
 }
@@ -90,11 +107,33 @@ func (fs *FuzzySearchModal) Blur() {
 	fs.container.Blur()
 }
 
+// recentMatches returns the recently visited rooms as pseudo-matches, used when the search is empty.
+func (fs *FuzzySearchModal) recentMatches() fuzzy.Ranks {
+	var matches fuzzy.Ranks
+	for _, roomID := range fs.parent.RecentRooms() {
+		idx := slices.IndexFunc(fs.roomList, func(entry *store.RoomListEntry) bool {
+			return entry.RoomID == roomID
+		})
+		if idx >= 0 {
+			matches = append(matches, fuzzy.Rank{Target: fs.roomTitles[idx], OriginalIndex: idx})
+		}
+	}
+	return matches
+}
+
 func (fs *FuzzySearchModal) changeHandler(str string) {
 	// Get matches and display in result box
-	fs.matches = fuzzy.RankFindFold(str, fs.roomTitles)
-	if len(str) > 0 && len(fs.matches) > 0 {
+	if len(str) == 0 {
+		fs.matches = fs.recentMatches()
+	} else {
+		fs.matches = fuzzy.RankFindFold(str, fs.roomTitles)
 		sort.Sort(fs.matches)
+		// Recently visited rooms are ranked first, in the order they were visited
+		slices.SortStableFunc(fs.matches, func(a, b fuzzy.Rank) int {
+			return cmp.Compare(fs.recencyRank(a), fs.recencyRank(b))
+		})
+	}
+	if len(fs.matches) > 0 {
 		fs.results.Clear()
 		for _, match := range fs.matches {
 			_, _ = fmt.Fprintf(fs.results, `["%d"]%s[""]%s`, match.OriginalIndex, match.Target, "\n")
@@ -109,6 +148,14 @@ func (fs *FuzzySearchModal) changeHandler(str string) {
 	}
 }
 
+func (fs *FuzzySearchModal) recencyRank(match fuzzy.Rank) int {
+	rank := fs.parent.history.RecencyRank(fs.roomList[match.OriginalIndex].RoomID)
+	if rank == -1 {
+		return MaxRecentRooms
+	}
+	return rank
+}
+
 func (fs *FuzzySearchModal) OnKeyEvent(event mauview.KeyEvent) bool {
 	highlights := fs.results.GetHighlights()
 	kb := config.Keybind{
@@ -121,6 +168,11 @@ func (fs *FuzzySearchModal) OnKeyEvent(event mauview.KeyEvent) bool {
 		// Close room finder
 		fs.parent.HideModal()
 		return true
+	case "toggle_hidden":
+		fs.includeHidden = !fs.includeHidden
+		fs.loadRooms()
+		fs.changeHandler(fs.search.GetText())
+		return true
 	case "select_next":
 		// Cycle highlighted area to next match
 		if len(highlights) > 0 {
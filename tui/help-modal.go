@@ -12,6 +12,16 @@ const helpText = `# General
 /quit           - Quit gomuks.
 /clearcache     - Clear cache and quit gomuks.
 /logout         - Log out of Matrix.
+/password       - Change your account password.
+/deactivate     - Permanently deactivate your account.
+/myprofile      - View and change your global display name and avatar.
+/nick <name>    - Set your global display name (see /myroomnick for rooms).
+/devicename [name]
+                - Show or change the name of this session in other clients.
+/reactions      - Show recent reactions to your messages.
+/logs           - View recent log entries.
+/loglevel <level> [component]
+                - Change the log level until restart (e.g. /loglevel debug sync).
 /toggle <thing> - Temporary command to toggle various UI features.
                   Run /toggle without arguments to see the list of toggles.
 
@@ -19,16 +29,39 @@ const helpText = `# General
 /download [path] - Downloads file from selected message.
 /open [path]     - Download file from selected message and open it with xdg-open.
 /upload <path>   - Upload the file at the given path to the current room.
+/paste [caption] - Send an image from the clipboard to the current room.
 
 # Sending special messages
 /me <message>        - Send an emote message.
 /notice <message>    - Send a notice (generally used for bot messages).
 /rainbow <message>   - Send rainbow text.
 /rainbowme <message> - Send rainbow text in an emote.
+/spoiler [reason:] <message>
+                     - Send a message hidden behind a spoiler.
+                       ||text|| also works in normal messages.
+/nopreview <message> - Send a message without URL previews.
 /reply [text]        - Reply to the selected message.
 /react <reaction>    - React to the selected message.
+                       Reacting again with the same key removes the reaction.
 /redact [reason]     - Redact the selected message.
+/source              - View the raw source of the selected message.
+/reveal              - Show or hide the content of a message removed because
+                       its sender was banned. In visual mode, r does the same.
+/copy [what] [register]
+                     - Copy the text, source, id or link of the selected message
+                       to the clipboard or primary selection. In visual mode,
+                       y, Y, i and u copy the text, source, ID and link.
 /edit                - Edit the selected message.
+/quote [lines]       - Quote lines of the selected message and reply to it.
+                       Lines are given like 3 or 2-4. Without them, pick the
+                       lines with Up/Down and Space, then press Enter. In visual
+                       mode, q does the same.
+/quote-noreply [lines]
+                     - Quote lines without replying. In visual mode, press Q.
+/save-view <path>    - Save the loaded messages of the room as plain text.
+/flush-queue         - Drop all messages waiting to be sent in the current room.
+                       When messages have failed to send, press R with an empty
+                       input to retry them all or D to discard them.
 
 # Encryption
 /fingerprint - View the fingerprint of your device.
@@ -55,6 +88,7 @@ const helpText = `# General
       Run without arguments for help.
 
 # Rooms
+/directory [server]   - Browse the public room directory.
 /pm <user id> <...>   - Create a private chat with the given user(s).
 /create [room name]   - Create a room.
 
@@ -62,14 +96,34 @@ const helpText = `# General
 /accept               - Accept the invite.
 /reject               - Reject the invite.
 
+/archived             - Show or hide rooms you have left.
+/rejoin               - Join the current archived room again.
+/forget               - Forget the current archived room.
+/lineage [on|off]     - Continue loading history from the rooms this room
+                        was upgraded from when scrolling past its beginning.
+/hide                 - Hide the room from the room list and notifications.
+/unhide               - Show the hidden room in the room list again.
+/notify-sound <mode>  - Always or never play a sound for notifications in
+                        the room, or use the default settings.
+
 /invite <user id>     - Invite the given user to the room.
 /roomnick <name>      - Change your per-room displayname.
 /tag <tag> <priority> - Add the room to <tag>.
 /untag <tag>          - Remove the room from <tag>.
 /tags                 - List the tags the room is in.
 /alias <act> <name>   - Add or remove local addresses.
-
-/leave                     - Leave the current room.
+/privacy              - View and change who can join the room and read its history.
+/encrypt [confirm]    - Enable end-to-end encryption in the room. This can't be undone.
+/devices-for-session <session id>
+                      - Show which of your devices have a megolm session. The
+                        session ID of a message is shown in its view source modal.
+
+/leave [reason]            - Leave the current room.
+/leave-rooms <target> [reason]
+                           - Leave many rooms at once. The target is a space ID
+                             (all rooms in the space), a server name (rooms where
+                             everyone else is on that server) or comma-separated
+                             room IDs. The rooms are listed for confirmation first.
 /kick   <user id> [reason] - Kick a user.
 /ban    <user id> [reason] - Ban a user.
 /unban  <user id>          - Unban a user.`
@@ -0,0 +1,123 @@
+// gomuks - A terminal Matrix client written in Go.
+// Copyright (C) 2026 Tulir Asokan
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+package tui
+
+import (
+	"sync"
+	"time"
+)
+
+// IdleClock is the source of time for IdleTracker. It can be replaced with a fake clock in tests.
+type IdleClock interface {
+	Now() time.Time
+	AfterFunc(d time.Duration, fn func()) IdleTimer
+}
+
+// IdleTimer is the subset of *time.Timer methods that IdleTracker uses.
+type IdleTimer interface {
+	Stop() bool
+	Reset(d time.Duration) bool
+}
+
+type realIdleClock struct{}
+
+func (realIdleClock) Now() time.Time {
+	return time.Now()
+}
+
+func (realIdleClock) AfterFunc(d time.Duration, fn func()) IdleTimer {
+	return time.AfterFunc(d, fn)
+}
+
+// IdleTracker keeps track of when the user last interacted with gomuks, so that read receipts
+// and typing notifications aren't sent while they're away from the keyboard.
+type IdleTracker struct {
+	// Threshold returns how long without input counts as idle. Zero or negative disables idle detection.
+	Threshold func() time.Duration
+	// OnIdle is called in a separate goroutine when the user becomes idle.
+	OnIdle func()
+	// Clock is used for the current time and the idle timer.
+	Clock IdleClock
+
+	lock      sync.Mutex
+	lastInput time.Time
+	timer     IdleTimer
+}
+
+func NewIdleTracker(threshold func() time.Duration, onIdle func()) *IdleTracker {
+	return newIdleTrackerWithClock(threshold, onIdle, realIdleClock{})
+}
+
+func newIdleTrackerWithClock(threshold func() time.Duration, onIdle func(), clock IdleClock) *IdleTracker {
+	return &IdleTracker{
+		Threshold: threshold,
+		OnIdle:    onIdle,
+		Clock:     clock,
+		lastInput: clock.Now(),
+	}
+}
+
+// Bump records that the user did something and restarts the idle timer.
+func (it *IdleTracker) Bump() {
+	it.lock.Lock()
+	defer it.lock.Unlock()
+	it.lastInput = it.Clock.Now()
+	threshold := it.Threshold()
+	if threshold <= 0 || it.OnIdle == nil {
+		if it.timer != nil {
+			it.timer.Stop()
+		}
+	} else if it.timer == nil {
+		it.timer = it.Clock.AfterFunc(threshold, it.checkIdle)
+	} else {
+		it.timer.Reset(threshold)
+	}
+}
+
+// checkIdle is called by the idle timer. If the threshold was raised after the timer was started,
+// the user isn't idle yet, so the timer is restarted for the remaining time.
+func (it *IdleTracker) checkIdle() {
+	it.lock.Lock()
+	now := it.Clock.Now()
+	idle := it.isIdle(now)
+	if threshold := it.Threshold(); !idle && threshold > 0 {
+		it.timer.Reset(threshold - now.Sub(it.lastInput))
+	}
+	it.lock.Unlock()
+	if idle {
+		it.OnIdle()
+	}
+}
+
+func (it *IdleTracker) isIdle(now time.Time) bool {
+	threshold := it.Threshold()
+	return threshold > 0 && now.Sub(it.lastInput) >= threshold
+}
+
+// IsIdle returns true if there haven't been any input events within the idle threshold.
+func (it *IdleTracker) IsIdle() bool {
+	it.lock.Lock()
+	defer it.lock.Unlock()
+	return it.isIdle(it.Clock.Now())
+}
+
+// SinceLastInput returns how long ago the last input event happened.
+func (it *IdleTracker) SinceLastInput() time.Duration {
+	it.lock.Lock()
+	defer it.lock.Unlock()
+	return it.Clock.Now().Sub(it.lastInput)
+}
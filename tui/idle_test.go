@@ -0,0 +1,184 @@
+// gomuks - A terminal Matrix client written in Go.
+// Copyright (C) 2026 Tulir Asokan
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+package tui
+
+import (
+	"testing"
+	"time"
+)
+
+// fakeClock is an IdleClock whose time only moves when Advance is called.
+// Due timers are fired synchronously inside Advance.
+type fakeClock struct {
+	now    time.Time
+	timers []*fakeTimer
+}
+
+type fakeTimer struct {
+	clock  *fakeClock
+	when   time.Time
+	fn     func()
+	active bool
+}
+
+func newFakeClock() *fakeClock {
+	return &fakeClock{now: time.Date(2026, 1, 1, 12, 0, 0, 0, time.UTC)}
+}
+
+func (fc *fakeClock) Now() time.Time {
+	return fc.now
+}
+
+func (fc *fakeClock) AfterFunc(d time.Duration, fn func()) IdleTimer {
+	timer := &fakeTimer{clock: fc, fn: fn}
+	timer.Reset(d)
+	fc.timers = append(fc.timers, timer)
+	return timer
+}
+
+func (ft *fakeTimer) Stop() bool {
+	wasActive := ft.active
+	ft.active = false
+	return wasActive
+}
+
+func (ft *fakeTimer) Reset(d time.Duration) bool {
+	wasActive := ft.active
+	ft.when = ft.clock.now.Add(d)
+	ft.active = true
+	return wasActive
+}
+
+// Advance moves the clock forward, firing each timer at the time it's due.
+func (fc *fakeClock) Advance(d time.Duration) {
+	target := fc.now.Add(d)
+	for {
+		var next *fakeTimer
+		for _, timer := range fc.timers {
+			if timer.active && !timer.when.After(target) && (next == nil || timer.when.Before(next.when)) {
+				next = timer
+			}
+		}
+		if next == nil {
+			break
+		}
+		fc.now = next.when
+		next.active = false
+		next.fn()
+	}
+	fc.now = target
+}
+
+type idleTestTracker struct {
+	*IdleTracker
+	clock     *fakeClock
+	threshold time.Duration
+	idleCount int
+}
+
+func newIdleTestTracker(threshold time.Duration) *idleTestTracker {
+	itt := &idleTestTracker{clock: newFakeClock(), threshold: threshold}
+	itt.IdleTracker = newIdleTrackerWithClock(func() time.Duration {
+		return itt.threshold
+	}, func() {
+		itt.idleCount++
+	}, itt.clock)
+	return itt
+}
+
+func (itt *idleTestTracker) assertIdle(t *testing.T, wantIdle bool, wantCount int) {
+	t.Helper()
+	if idle := itt.IsIdle(); idle != wantIdle {
+		t.Errorf("IsIdle() = %t, want %t", idle, wantIdle)
+	}
+	if itt.idleCount != wantCount {
+		t.Errorf("OnIdle called %d times, want %d", itt.idleCount, wantCount)
+	}
+}
+
+func TestIdleTracker_BecomesIdle(t *testing.T) {
+	itt := newIdleTestTracker(time.Minute)
+	itt.assertIdle(t, false, 0)
+	itt.Bump()
+	itt.clock.Advance(time.Minute - time.Second)
+	itt.assertIdle(t, false, 0)
+	itt.clock.Advance(time.Second)
+	itt.assertIdle(t, true, 1)
+	itt.clock.Advance(time.Hour)
+	itt.assertIdle(t, true, 1)
+}
+
+func TestIdleTracker_BumpResetsTimer(t *testing.T) {
+	itt := newIdleTestTracker(time.Minute)
+	itt.Bump()
+	itt.clock.Advance(50 * time.Second)
+	itt.Bump()
+	itt.clock.Advance(50 * time.Second)
+	itt.assertIdle(t, false, 0)
+	itt.clock.Advance(10 * time.Second)
+	itt.assertIdle(t, true, 1)
+
+	itt.Bump()
+	itt.assertIdle(t, false, 1)
+	itt.clock.Advance(time.Minute)
+	itt.assertIdle(t, true, 2)
+}
+
+func TestIdleTracker_Disabled(t *testing.T) {
+	itt := newIdleTestTracker(0)
+	itt.Bump()
+	itt.clock.Advance(time.Hour)
+	itt.assertIdle(t, false, 0)
+
+	itt.threshold = time.Minute
+	itt.Bump()
+	itt.threshold = -1
+	itt.Bump()
+	itt.clock.Advance(time.Hour)
+	itt.assertIdle(t, false, 0)
+}
+
+func TestIdleTracker_ThresholdChanged(t *testing.T) {
+	itt := newIdleTestTracker(time.Minute)
+	itt.Bump()
+	itt.threshold = 2 * time.Minute
+	itt.clock.Advance(time.Minute)
+	itt.assertIdle(t, false, 0)
+	itt.clock.Advance(time.Minute)
+	itt.assertIdle(t, true, 1)
+
+	itt.Bump()
+	itt.threshold = 30 * time.Second
+	itt.assertIdle(t, false, 1)
+	itt.clock.Advance(30 * time.Second)
+	if !itt.IsIdle() {
+		t.Error("Expected lowered threshold to apply immediately to IsIdle")
+	}
+}
+
+func TestIdleTracker_SinceLastInput(t *testing.T) {
+	itt := newIdleTestTracker(time.Minute)
+	itt.clock.Advance(5 * time.Second)
+	if since := itt.SinceLastInput(); since != 5*time.Second {
+		t.Errorf("SinceLastInput() = %s, want 5s", since)
+	}
+	itt.Bump()
+	itt.clock.Advance(2 * time.Second)
+	if since := itt.SinceLastInput(); since != 2*time.Second {
+		t.Errorf("SinceLastInput() = %s, want 2s", since)
+	}
+}
@@ -0,0 +1,158 @@
+// gomuks - A terminal Matrix client written in Go.
+// Copyright (C) 2025 Tulir Asokan
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+package tui
+
+import (
+	"strings"
+	"unicode"
+
+	"github.com/zyedidia/clipboard"
+
+	"go.mau.fi/gomuks/tui/debug"
+)
+
+// isWordRune returns true for runes that are part of a word for readline-style word movement.
+// The sigils and separators of Matrix identifiers are included, so that user IDs, room aliases
+// and event IDs are treated as a single word.
+func isWordRune(r rune) bool {
+	if unicode.IsLetter(r) || unicode.IsDigit(r) || unicode.IsMark(r) {
+		return true
+	}
+	return strings.ContainsRune("@#!$+:._-=/", r)
+}
+
+// previousWordStart returns the index of the start of the word before the cursor, skipping any
+// non-word characters directly before the cursor first.
+func previousWordStart(text []rune, cursor int) int {
+	cursor = min(max(cursor, 0), len(text))
+	for cursor > 0 && !isWordRune(text[cursor-1]) {
+		cursor--
+	}
+	for cursor > 0 && isWordRune(text[cursor-1]) {
+		cursor--
+	}
+	return cursor
+}
+
+// nextWordEnd returns the index of the end of the word after the cursor, skipping any non-word
+// characters directly after the cursor first.
+func nextWordEnd(text []rune, cursor int) int {
+	cursor = min(max(cursor, 0), len(text))
+	for cursor < len(text) && !isWordRune(text[cursor]) {
+		cursor++
+	}
+	for cursor < len(text) && isWordRune(text[cursor]) {
+		cursor++
+	}
+	return cursor
+}
+
+// lineStart returns the index of the start of the line the cursor is on.
+func lineStart(text []rune, cursor int) int {
+	cursor = min(max(cursor, 0), len(text))
+	for cursor > 0 && text[cursor-1] != '\n' {
+		cursor--
+	}
+	return cursor
+}
+
+// lineEnd returns the index of the end of the line the cursor is on.
+func lineEnd(text []rune, cursor int) int {
+	cursor = min(max(cursor, 0), len(text))
+	for cursor < len(text) && text[cursor] != '\n' {
+		cursor++
+	}
+	return cursor
+}
+
+// killRange removes the given range of text and returns the new text and the removed part.
+func killRange(text []rune, start, end int) (newText []rune, killed string) {
+	if start >= end {
+		return text, ""
+	}
+	killed = string(text[start:end])
+	newText = make([]rune, 0, len(text)-(end-start))
+	newText = append(newText, text[:start]...)
+	newText = append(newText, text[end:]...)
+	return newText, killed
+}
+
+// OnInputEditKey handles the readline-style editing actions from the room keybindings.
+// It returns false if the action isn't an input editing action.
+func (view *RoomView) OnInputEditKey(action string) bool {
+	text := []rune(view.input.GetText())
+	cursor := min(view.input.GetCursorOffset(), len(text))
+	var killed string
+	newCursor := cursor
+	switch action {
+	case "input_home":
+		newCursor = lineStart(text, cursor)
+	case "input_end":
+		newCursor = lineEnd(text, cursor)
+	case "input_word_left":
+		newCursor = previousWordStart(text, cursor)
+	case "input_word_right":
+		newCursor = nextWordEnd(text, cursor)
+	case "input_delete_word":
+		newCursor = previousWordStart(text, cursor)
+		text, killed = killRange(text, newCursor, cursor)
+	case "input_kill_line":
+		newCursor = lineStart(text, cursor)
+		text, killed = killRange(text, newCursor, cursor)
+	case "input_kill_to_end":
+		end := lineEnd(text, cursor)
+		if end == cursor && end < len(text) {
+			// At the end of a line, kill the newline like readline does
+			end++
+		}
+		text, killed = killRange(text, cursor, end)
+	case "input_yank":
+		yanked := []rune(view.readKillRegister())
+		text = append(text[:cursor:cursor], append(yanked, text[cursor:]...)...)
+		newCursor = cursor + len(yanked)
+	default:
+		return false
+	}
+	if killed != "" {
+		view.writeKillRegister(killed)
+	}
+	if killed != "" || action == "input_yank" {
+		view.input.SetText(string(text))
+	}
+	view.input.SetCursorOffset(newCursor)
+	return true
+}
+
+func (view *RoomView) writeKillRegister(text string) {
+	view.parent.killRegister = text
+	if view.config.KillToClipboard {
+		if err := clipboard.WriteAll(text, "clipboard"); err != nil {
+			debug.Print("Failed to write killed text to clipboard:", err)
+		}
+	}
+}
+
+func (view *RoomView) readKillRegister() string {
+	if view.config.KillToClipboard {
+		text, err := clipboard.ReadAll("clipboard")
+		if err == nil {
+			return text
+		}
+		debug.Print("Failed to read clipboard for yank:", err)
+	}
+	return view.parent.killRegister
+}
@@ -0,0 +1,115 @@
+// gomuks - A terminal Matrix client written in Go.
+// Copyright (C) 2026 Tulir Asokan
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+package tui
+
+import (
+	"testing"
+)
+
+func TestWordBoundaries(t *testing.T) {
+	tests := []struct {
+		name      string
+		text      string
+		cursor    int
+		wantStart int
+		wantEnd   int
+	}{
+		{"empty", "", 0, 0, 0},
+		{"middle of word", "hello world", 8, 6, 11},
+		{"start of word", "hello world", 6, 0, 11},
+		{"end of word", "hello world", 5, 0, 11},
+		{"after trailing spaces", "hello   ", 8, 0, 8},
+		{"punctuation between words", "hello, world", 7, 0, 12},
+		{"sigils at the end of a word", "hello, world!", 7, 0, 13},
+		{"user ID", "ping @alice:example.com now", 23, 5, 27},
+		{"user ID from inside", "ping @alice:example.com now", 10, 5, 23},
+		{"room alias", "see #room:example.org.", 22, 4, 22},
+		{"event ID", "$abc123_DEF-xyz", 15, 0, 15},
+		{"URL-like", "go to example.com/path ok", 20, 6, 22},
+		{"unicode letters", "привет мир", 10, 7, 10},
+		{"combining marks", "été done", 4, 0, 5},
+		{"CJK", "日本語 テキスト", 8, 4, 8},
+		{"emoji aren't words", "hi \U0001F408 there", 4, 0, 10},
+		{"newline", "first line\nsecond", 11, 6, 17},
+		{"cursor past end", "abc", 10, 0, 3},
+		{"negative cursor", "abc", -1, 0, 3},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			text := []rune(test.text)
+			if got := previousWordStart(text, test.cursor); got != test.wantStart {
+				t.Errorf("previousWordStart(%q, %d) = %d, want %d", test.text, test.cursor, got, test.wantStart)
+			}
+			if got := nextWordEnd(text, test.cursor); got != test.wantEnd {
+				t.Errorf("nextWordEnd(%q, %d) = %d, want %d", test.text, test.cursor, got, test.wantEnd)
+			}
+		})
+	}
+}
+
+func TestLineBoundaries(t *testing.T) {
+	text := []rune("first\nsecond line\n\nlast")
+	tests := []struct {
+		cursor    int
+		wantStart int
+		wantEnd   int
+	}{
+		{0, 0, 5},
+		{3, 0, 5},
+		{5, 0, 5},
+		{6, 6, 17},
+		{10, 6, 17},
+		{18, 18, 18},
+		{19, 19, 23},
+		{23, 19, 23},
+		{100, 19, 23},
+	}
+	for _, test := range tests {
+		if got := lineStart(text, test.cursor); got != test.wantStart {
+			t.Errorf("lineStart(%d) = %d, want %d", test.cursor, got, test.wantStart)
+		}
+		if got := lineEnd(text, test.cursor); got != test.wantEnd {
+			t.Errorf("lineEnd(%d) = %d, want %d", test.cursor, got, test.wantEnd)
+		}
+	}
+}
+
+func TestKillRange(t *testing.T) {
+	tests := []struct {
+		text       string
+		start, end int
+		wantText   string
+		wantKilled string
+	}{
+		{"hello world", 6, 11, "hello ", "world"},
+		{"hello world", 0, 6, "world", "hello "},
+		{"hello", 2, 2, "hello", ""},
+		{"hello", 3, 1, "hello", ""},
+		{"héllo wörld", 6, 11, "héllo ", "wörld"},
+	}
+	for _, test := range tests {
+		text := []rune(test.text)
+		newText, killed := killRange(text, test.start, test.end)
+		if string(newText) != test.wantText || killed != test.wantKilled {
+			t.Errorf("killRange(%q, %d, %d) = %q, %q, want %q, %q",
+				test.text, test.start, test.end, string(newText), killed, test.wantText, test.wantKilled)
+		}
+		if string(text) != test.text {
+			t.Errorf("killRange modified the input text to %q", string(text))
+		}
+	}
+}
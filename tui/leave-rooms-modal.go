@@ -0,0 +1,347 @@
+// gomuks - A terminal Matrix client written in Go.
+// Copyright (C) 2026 Tulir Asokan
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+package tui
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gdamore/tcell/v2"
+	"go.mau.fi/mauview"
+	"go.mau.fi/util/ptr"
+	"maunium.net/go/mautrix/event"
+	"maunium.net/go/mautrix/id"
+
+	"go.mau.fi/gomuks/pkg/hicli/jsoncmd"
+	"go.mau.fi/gomuks/tui/config"
+	"go.mau.fi/gomuks/tui/debug"
+)
+
+// ParseLeaveRoomsTarget turns the target of the /leave-rooms command into a dry run request. The target is either
+// the ID of a space, a comma-separated list of room IDs or a server name.
+func (view *MainView) ParseLeaveRoomsTarget(target, reason string) *jsoncmd.LeaveRoomsParams {
+	params := &jsoncmd.LeaveRoomsParams{DryRun: true, Reason: reason}
+	if !strings.HasPrefix(target, "!") {
+		params.ServerName = target
+		return params
+	}
+	for _, roomID := range strings.Split(target, ",") {
+		params.RoomIDs = append(params.RoomIDs, id.RoomID(strings.TrimSpace(roomID)))
+	}
+	if len(params.RoomIDs) == 1 {
+		room := view.matrix.GetRoom(params.RoomIDs[0])
+		if room != nil {
+			meta := room.Meta.Current()
+			if meta.CreationContent != nil && meta.CreationContent.Type == event.RoomTypeSpace {
+				params.SpaceID, params.RoomIDs = params.RoomIDs[0], nil
+			}
+		}
+	}
+	return params
+}
+
+// LeaveRoomsModal lists the rooms that a bulk leave would leave, lets the user exclude some of them
+// and then shows the progress of leaving the rest.
+type LeaveRoomsModal struct {
+	mauview.Component
+
+	container *mauview.Box
+	list      *mauview.TextView
+	status    *mauview.TextField
+
+	lock     sync.Mutex
+	params   *jsoncmd.LeaveRoomsParams
+	dryRunID string
+	rooms    []*jsoncmd.LeaveRoomsEntry
+	excluded map[id.RoomID]bool
+	selected int
+	running  bool
+	finished bool
+	cancel   context.CancelFunc
+
+	parent *MainView
+}
+
+func NewLeaveRoomsModal(parent *MainView, params *jsoncmd.LeaveRoomsParams) *LeaveRoomsModal {
+	lm := &LeaveRoomsModal{
+		parent:   parent,
+		params:   params,
+		excluded: make(map[id.RoomID]bool),
+	}
+
+	lm.list = mauview.NewTextView().SetRegions(true).SetDynamicColors(true)
+	lm.status = mauview.NewTextField().SetText("Finding rooms to leave...")
+
+	flex := mauview.NewFlex().
+		SetDirection(mauview.FlexRow).
+		AddProportionalComponent(lm.list, 1).
+		AddFixedComponent(lm.status, 1)
+
+	var title string
+	switch {
+	case params.SpaceID != "":
+		title = fmt.Sprintf("Leave rooms in %s", lm.roomName(params.SpaceID, ""))
+	case params.ServerName != "":
+		title = fmt.Sprintf("Leave rooms with only %s users", params.ServerName)
+	default:
+		title = "Leave rooms"
+	}
+	lm.container = mauview.NewBox(flex).
+		SetBorder(true).
+		SetTitle(title).
+		SetBlurCaptureFunc(func() bool {
+			lm.close()
+			return true
+		})
+	lm.Component = mauview.Center(lm.container, 80, 30).SetAlwaysFocusChild(true)
+
+	go lm.dryRun()
+	return lm
+}
+
+func (lm *LeaveRoomsModal) Focus() {
+	lm.container.Focus()
+}
+
+func (lm *LeaveRoomsModal) Blur() {
+	lm.container.Blur()
+}
+
+// close hides the modal. If rooms are still being left, the rest of the request is cancelled.
+func (lm *LeaveRoomsModal) close() {
+	lm.lock.Lock()
+	if lm.cancel != nil {
+		lm.cancel()
+	}
+	lm.lock.Unlock()
+	lm.parent.HideModal()
+}
+
+func (lm *LeaveRoomsModal) setStatus(color tcell.Color, text string) {
+	lm.status.SetTextColor(color).SetText(text)
+	lm.parent.parent.Render()
+}
+
+func (lm *LeaveRoomsModal) roomName(roomID id.RoomID, name string) string {
+	if name != "" {
+		return name
+	} else if room := lm.parent.matrix.GetRoom(roomID); room != nil {
+		if name = ptr.Val(room.Meta.Current().Name); name != "" {
+			return name
+		}
+	}
+	return roomID.String()
+}
+
+func (lm *LeaveRoomsModal) dryRun() {
+	defer debug.Recover()
+	resp, err := lm.parent.matrix.LeaveRooms(context.TODO(), lm.params)
+	if err != nil {
+		lm.setStatus(tcell.ColorRed, fmt.Sprintf("Failed to find rooms: %v", err))
+		return
+	}
+	lm.lock.Lock()
+	lm.dryRunID = resp.DryRunID
+	lm.rooms = resp.Rooms
+	lm.renderLocked()
+	lm.lock.Unlock()
+	if len(resp.Rooms) == 0 {
+		lm.setStatus(tcell.ColorDefault, "No joined rooms match the filter")
+	} else {
+		lm.setStatus(tcell.ColorDefault, "Press Enter to toggle a room, then select the leave button")
+	}
+}
+
+func (lm *LeaveRoomsModal) leaveCountLocked() int {
+	count := 0
+	for _, room := range lm.rooms {
+		if !lm.excluded[room.RoomID] {
+			count++
+		}
+	}
+	return count
+}
+
+func (lm *LeaveRoomsModal) renderLocked() {
+	lm.list.Clear()
+	for i, room := range lm.rooms {
+		var marker string
+		switch {
+		case room.Left:
+			marker = "[green]left[-]"
+		case room.Error != "":
+			marker = "[red]failed[-]"
+		case lm.excluded[room.RoomID]:
+			marker = "[ []"
+		default:
+			marker = "[x[]"
+		}
+		_, _ = fmt.Fprintf(lm.list, `["%d"]%s %s[""]`+"\n", i, marker, mauview.Escape(lm.roomName(room.RoomID, room.Name)))
+		if room.Error != "" {
+			_, _ = fmt.Fprintf(lm.list, "    [gray]%s[-]\n", mauview.Escape(room.Error))
+		}
+	}
+	if len(lm.rooms) > 0 && !lm.running && !lm.finished {
+		_, _ = fmt.Fprintf(lm.list, `%s["%d"][::b]Leave %d rooms[::-][""]`+"\n", "\n", len(lm.rooms), lm.leaveCountLocked())
+	}
+	lm.selected = max(0, min(lm.selected, lm.rowCountLocked()-1))
+	lm.list.Highlight(strconv.Itoa(lm.selected))
+	lm.list.ScrollToHighlight()
+}
+
+func (lm *LeaveRoomsModal) rowCountLocked() int {
+	if lm.running || lm.finished {
+		return len(lm.rooms)
+	}
+	// The last row is the leave button
+	return len(lm.rooms) + 1
+}
+
+func (lm *LeaveRoomsModal) moveSelection(diff int) {
+	lm.lock.Lock()
+	defer lm.lock.Unlock()
+	if len(lm.rooms) == 0 {
+		return
+	}
+	lm.selected = max(0, min(lm.selected+diff, lm.rowCountLocked()-1))
+	lm.list.Highlight(strconv.Itoa(lm.selected))
+	lm.list.ScrollToHighlight()
+}
+
+func (lm *LeaveRoomsModal) confirm() {
+	lm.lock.Lock()
+	defer lm.lock.Unlock()
+	if lm.running || lm.finished || len(lm.rooms) == 0 {
+		return
+	} else if lm.selected < len(lm.rooms) {
+		roomID := lm.rooms[lm.selected].RoomID
+		lm.excluded[roomID] = !lm.excluded[roomID]
+		lm.renderLocked()
+		return
+	}
+	count := lm.leaveCountLocked()
+	if count == 0 {
+		lm.setStatus(tcell.ColorRed, "Select at least one room to leave")
+		return
+	}
+	var exclude []id.RoomID
+	for roomID, excluded := range lm.excluded {
+		if excluded {
+			exclude = append(exclude, roomID)
+		}
+	}
+	var ctx context.Context
+	ctx, lm.cancel = context.WithCancel(context.Background())
+	lm.running = true
+	lm.selected = 0
+	lm.renderLocked()
+	lm.setStatus(tcell.ColorDefault, fmt.Sprintf("Left 0/%d rooms", count))
+	go lm.leave(ctx, &jsoncmd.LeaveRoomsParams{
+		DryRunID:       lm.dryRunID,
+		ExcludeRoomIDs: exclude,
+		Reason:         lm.params.Reason,
+	})
+}
+
+func (lm *LeaveRoomsModal) leave(ctx context.Context, params *jsoncmd.LeaveRoomsParams) {
+	defer debug.Recover()
+	resp, err := lm.parent.matrix.LeaveRooms(ctx, params)
+	lm.lock.Lock()
+	lm.running = false
+	lm.finished = true
+	if lm.cancel != nil {
+		lm.cancel()
+		lm.cancel = nil
+	}
+	if err != nil {
+		lm.renderLocked()
+		lm.lock.Unlock()
+		lm.setStatus(tcell.ColorRed, fmt.Sprintf("Failed to leave rooms: %v", err))
+		return
+	}
+	lm.rooms = resp.Rooms
+	var failed int
+	for _, room := range resp.Rooms {
+		if room.Error != "" {
+			failed++
+		}
+	}
+	lm.renderLocked()
+	lm.lock.Unlock()
+	if failed > 0 {
+		lm.setStatus(tcell.ColorRed, fmt.Sprintf("Left %d rooms, failed to leave %d", len(resp.Rooms)-failed, failed))
+	} else {
+		lm.setStatus(tcell.ColorGreen, fmt.Sprintf("Left %d rooms", len(resp.Rooms)))
+	}
+}
+
+// HandleProgress updates the modal with the progress of the bulk leave it started.
+func (lm *LeaveRoomsModal) HandleProgress(evt *jsoncmd.LeaveRoomsProgress) {
+	lm.lock.Lock()
+	if evt.DryRunID != lm.dryRunID || !lm.running {
+		lm.lock.Unlock()
+		return
+	}
+	for _, room := range lm.rooms {
+		if room.RoomID == evt.RoomID && evt.RetryAt.IsZero() {
+			room.Error = evt.Error
+			room.Left = evt.Error == ""
+		}
+	}
+	lm.renderLocked()
+	lm.lock.Unlock()
+	if !evt.RetryAt.IsZero() {
+		wait := max(time.Until(evt.RetryAt.Time).Round(time.Second), time.Second)
+		lm.setStatus(tcell.ColorYellow, fmt.Sprintf("Left %d/%d rooms, rate limited for %s", evt.Done, evt.Total, wait))
+	} else {
+		lm.setStatus(tcell.ColorDefault, fmt.Sprintf("Left %d/%d rooms", evt.Done, evt.Total))
+	}
+}
+
+// HandleLeaveRoomsProgress passes bulk leave progress to the leave rooms modal if it's open.
+func (view *MainView) HandleLeaveRoomsProgress(evt *jsoncmd.LeaveRoomsProgress) {
+	if modal, ok := view.modal.(*LeaveRoomsModal); ok {
+		modal.HandleProgress(evt)
+	}
+}
+
+func (lm *LeaveRoomsModal) OnKeyEvent(event mauview.KeyEvent) bool {
+	kb := config.Keybind{
+		Key: event.Key(),
+		Ch:  event.Rune(),
+		Mod: event.Modifiers(),
+	}
+	switch lm.parent.config.Keybindings.Modal[kb] {
+	case "cancel":
+		lm.close()
+		return true
+	case "select_next":
+		lm.moveSelection(1)
+		return true
+	case "select_prev":
+		lm.moveSelection(-1)
+		return true
+	case "confirm":
+		lm.confirm()
+		return true
+	}
+	return false
+}
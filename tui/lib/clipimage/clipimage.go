@@ -0,0 +1,73 @@
+// gomuks - A terminal Matrix client written in Go.
+// Copyright (C) 2026 Tulir Asokan
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+package clipimage
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"os/exec"
+	"strings"
+)
+
+var (
+	ErrNoHelper = errors.New("no clipboard helper program found")
+	ErrNoImage  = errors.New("clipboard doesn't contain an image")
+)
+
+type helper struct {
+	Command string
+	Args    []string
+}
+
+// Read reads an image from the system clipboard. It returns the raw image data and the detected mime type.
+func Read(ctx context.Context) ([]byte, string, error) {
+	helpers := getHelpers()
+	for _, h := range helpers {
+		path, err := exec.LookPath(h.Command)
+		if err != nil {
+			continue
+		}
+		var stdout, stderr bytes.Buffer
+		cmd := exec.CommandContext(ctx, path, h.Args...)
+		cmd.Stdout = &stdout
+		cmd.Stderr = &stderr
+		err = cmd.Run()
+		if err != nil {
+			if msg := strings.TrimSpace(stderr.String()); msg != "" {
+				return nil, "", fmt.Errorf("%w (%s: %s)", ErrNoImage, h.Command, msg)
+			}
+			return nil, "", fmt.Errorf("%w (%s: %v)", ErrNoImage, h.Command, err)
+		}
+		data := stdout.Bytes()
+		mimeType := http.DetectContentType(data)
+		if len(data) == 0 || !strings.HasPrefix(mimeType, "image/") {
+			return nil, "", ErrNoImage
+		}
+		return data, mimeType, nil
+	}
+	if len(helpers) == 0 {
+		return nil, "", fmt.Errorf("%w: reading images from the clipboard is not supported on this platform", ErrNoHelper)
+	}
+	names := make([]string, len(helpers))
+	for i, h := range helpers {
+		names[i] = h.Command
+	}
+	return nil, "", fmt.Errorf("%w: install one of %s", ErrNoHelper, strings.Join(names, ", "))
+}
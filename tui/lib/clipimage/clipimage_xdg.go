@@ -0,0 +1,31 @@
+//go:build !windows && !darwin
+
+// gomuks - A terminal Matrix client written in Go.
+// Copyright (C) 2026 Tulir Asokan
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+package clipimage
+
+import (
+	"os"
+)
+
+func getHelpers() []helper {
+	xclip := helper{Command: "xclip", Args: []string{"-selection", "clipboard", "-t", "image/png", "-o"}}
+	if os.Getenv("WAYLAND_DISPLAY") != "" {
+		return []helper{{Command: "wl-paste", Args: []string{"--no-newline", "--type", "image/png"}}, xclip}
+	}
+	return []helper{xclip}
+}
@@ -0,0 +1,139 @@
+// gomuks - A terminal Matrix client written in Go.
+// Copyright (C) 2026 Tulir Asokan
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+// Package emoji replaces emojis in text for terminals that can't display them.
+package emoji
+
+//go:generate go run generate.go
+
+import (
+	"strings"
+	"unicode/utf8"
+
+	"github.com/rivo/uniseg"
+)
+
+const (
+	variationText   = '\ufe0e'
+	variationEmoji  = '\ufe0f'
+	zeroWidthJoiner = "\u200d"
+)
+
+// ToShortcodes replaces emojis in the given text with their shortcodes, e.g. 👍 becomes :+1:.
+//
+// Sequences that aren't known as a whole (e.g. new ZWJ sequences or skin tone variations) are replaced
+// component by component, so 👋🏽 becomes :wave::skin-tone-4:.
+func ToShortcodes(text string) string {
+	return replace(text, true)
+}
+
+// Strip removes emojis from the given text.
+func Strip(text string) string {
+	return replace(text, false)
+}
+
+func isASCII(text string) bool {
+	for i := 0; i < len(text); i++ {
+		if text[i] >= utf8.RuneSelf {
+			return false
+		}
+	}
+	return true
+}
+
+func replace(text string, toShortcodes bool) string {
+	if isASCII(text) {
+		return text
+	}
+	var buf strings.Builder
+	buf.Grow(len(text))
+	var cluster string
+	state := -1
+	for len(text) > 0 {
+		cluster, text, _, state = uniseg.FirstGraphemeClusterInString(text, state)
+		replaceCluster(&buf, cluster, toShortcodes)
+	}
+	return buf.String()
+}
+
+func normalize(sequence string) string {
+	return strings.Map(func(r rune) rune {
+		if r == variationText || r == variationEmoji {
+			return -1
+		}
+		return r
+	}, sequence)
+}
+
+func lookup(sequence string, emojiVariation bool) (string, bool) {
+	code, ok := shortcodes[sequence]
+	if !ok {
+		return "", false
+	} else if char, size := utf8.DecodeRuneInString(sequence); size == len(sequence) && !emojiVariation {
+		if _, isText := textPresentation[char]; isText {
+			return "", false
+		}
+	}
+	return code, true
+}
+
+// replaceCluster writes a single grapheme cluster to the buffer with emojis replaced. The whole cluster is preferred,
+// but if it isn't known, each part of a ZWJ sequence is looked up separately, and finally each character.
+func replaceCluster(buf *strings.Builder, cluster string, toShortcodes bool) {
+	if len(cluster) == 1 {
+		buf.WriteString(cluster)
+		return
+	}
+	emojiVariation := strings.ContainsRune(cluster, variationEmoji)
+	normalized := normalize(cluster)
+	writeCode := func(buf *strings.Builder, code string) {
+		if toShortcodes {
+			buf.WriteByte(':')
+			buf.WriteString(code)
+			buf.WriteByte(':')
+		}
+	}
+	if strings.ContainsRune(cluster, variationText) {
+		// Text presentation was requested explicitly, so leave the cluster as-is
+		buf.WriteString(cluster)
+		return
+	} else if code, ok := lookup(normalized, emojiVariation); ok {
+		writeCode(buf, code)
+		return
+	}
+	var fallback strings.Builder
+	var found bool
+	for _, part := range strings.Split(normalized, zeroWidthJoiner) {
+		if code, ok := lookup(part, emojiVariation); ok {
+			writeCode(&fallback, code)
+			found = true
+			continue
+		}
+		for _, char := range part {
+			if code, ok := lookup(string(char), emojiVariation); ok {
+				writeCode(&fallback, code)
+				found = true
+			} else {
+				fallback.WriteRune(char)
+			}
+		}
+	}
+	if found {
+		buf.WriteString(fallback.String())
+	} else {
+		buf.WriteString(cluster)
+	}
+}
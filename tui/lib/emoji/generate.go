@@ -0,0 +1,86 @@
+// gomuks - A terminal Matrix client written in Go.
+// Copyright (C) 2026 Tulir Asokan
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+//go:build ignore
+
+// This generates shortcodes.go from the emoji data of the web frontend,
+// so run the generator in web/src/util/emoji first when updating emojis.
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"go/format"
+	"maps"
+	"os"
+	"slices"
+	"strings"
+	"unicode/utf8"
+
+	"go.mau.fi/util/exerrors"
+)
+
+type inputEmoji struct {
+	Unicode string `json:"u"`
+	Name    string `json:"n"`
+}
+
+type inputData struct {
+	Emojis []*inputEmoji `json:"e"`
+}
+
+func main() {
+	var data inputData
+	file := exerrors.Must(os.Open("../../../web/src/util/emoji/data.json"))
+	exerrors.PanicIfNotNil(json.NewDecoder(file).Decode(&data))
+	exerrors.PanicIfNotNil(file.Close())
+
+	shortcodes := make(map[string]string, len(data.Emojis))
+	var textPresentation []rune
+	for _, emoji := range data.Emojis {
+		normalized := normalize(emoji.Unicode)
+		if _, exists := shortcodes[normalized]; exists {
+			continue
+		}
+		shortcodes[normalized] = emoji.Name
+		// The web data has variation selectors added to characters that are displayed as text by default
+		if base, size := utf8.DecodeRuneInString(normalized); size == len(normalized) && normalized != emoji.Unicode && base < 0x10000 {
+			textPresentation = append(textPresentation, base)
+		}
+	}
+	slices.Sort(textPresentation)
+
+	var buf bytes.Buffer
+	buf.WriteString("// Code generated by go generate; DO NOT EDIT.\n\npackage emoji\n\n")
+	buf.WriteString("// shortcodes maps emojis without variation selectors to their primary shortcode.\n")
+	buf.WriteString("var shortcodes = map[string]string{\n")
+	for _, unicode := range slices.Sorted(maps.Keys(shortcodes)) {
+		_, _ = fmt.Fprintf(&buf, "\t%q: %q,\n", unicode, shortcodes[unicode])
+	}
+	buf.WriteString("}\n\n")
+	buf.WriteString("// textPresentation contains characters that are only emojis when followed by an emoji variation selector.\n")
+	buf.WriteString("var textPresentation = map[rune]struct{}{\n")
+	for _, char := range textPresentation {
+		_, _ = fmt.Fprintf(&buf, "\t%q: {},\n", char)
+	}
+	buf.WriteString("}\n")
+	exerrors.PanicIfNotNil(os.WriteFile("shortcodes.go", exerrors.Must(format.Source(buf.Bytes())), 0644))
+}
+
+func normalize(unicode string) string {
+	return strings.NewReplacer("\ufe0e", "", "\ufe0f", "").Replace(unicode)
+}
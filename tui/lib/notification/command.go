@@ -0,0 +1,96 @@
+// gomuks - A terminal Matrix client written in Go.
+// Copyright (C) 2026 Tulir Asokan
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+package notification
+
+import (
+	"context"
+	"os"
+	"os/exec"
+	"strconv"
+	"strings"
+	"time"
+
+	"go.mau.fi/gomuks/tui/debug"
+)
+
+// CommandTimeout is the maximum time a notification command is allowed to run before it's killed.
+const CommandTimeout = 30 * time.Second
+
+// Environment variables passed to notification commands.
+const (
+	EnvRoomName  = "GOMUKS_NOTIFY_ROOM_NAME"
+	EnvRoomID    = "GOMUKS_NOTIFY_ROOM_ID"
+	EnvSender    = "GOMUKS_NOTIFY_SENDER"
+	EnvSenderID  = "GOMUKS_NOTIFY_SENDER_ID"
+	EnvBody      = "GOMUKS_NOTIFY_BODY"
+	EnvHighlight = "GOMUKS_NOTIFY_HIGHLIGHT"
+)
+
+// CommandInfo contains the data passed to a notification command.
+type CommandInfo struct {
+	RoomName  string
+	RoomID    string
+	Sender    string
+	SenderID  string
+	Body      string
+	Highlight bool
+}
+
+// BuildCommand returns the argument list and extra environment variables for running the given notification command.
+//
+// The room name, sender, body and highlight flag are appended to the configured arguments as separate values,
+// so they're never interpreted by a shell. Commands that parse options should be configured with a trailing "--",
+// so that a message body like "--help" isn't parsed as an option.
+func BuildCommand(command []string, info CommandInfo) (args, env []string) {
+	highlight := strconv.FormatBool(info.Highlight)
+	args = make([]string, 0, len(command)+4)
+	args = append(args, command...)
+	args = append(args, info.RoomName, info.Sender, info.Body, highlight)
+	env = []string{
+		EnvRoomName + "=" + info.RoomName,
+		EnvRoomID + "=" + info.RoomID,
+		EnvSender + "=" + info.Sender,
+		EnvSenderID + "=" + info.SenderID,
+		EnvBody + "=" + info.Body,
+		EnvHighlight + "=" + highlight,
+	}
+	return
+}
+
+// RunCommand runs the given notification command in the background and logs its output to the debug log.
+func RunCommand(command []string, info CommandInfo) {
+	if len(command) == 0 || command[0] == "" {
+		return
+	}
+	args, env := BuildCommand(command, info)
+	go func() {
+		defer debug.Recover()
+		ctx, cancel := context.WithTimeout(context.Background(), CommandTimeout)
+		defer cancel()
+		cmd := exec.CommandContext(ctx, args[0], args[1:]...)
+		cmd.Env = append(os.Environ(), env...)
+		output, err := cmd.CombinedOutput()
+		if trimmed := strings.TrimSpace(string(output)); trimmed != "" {
+			debug.Printf("Notification command output: %s", trimmed)
+		}
+		if ctx.Err() != nil {
+			debug.Printf("Notification command %s timed out after %s", args[0], CommandTimeout)
+		} else if err != nil {
+			debug.Printf("Failed to run notification command %s: %v", args[0], err)
+		}
+	}()
+}
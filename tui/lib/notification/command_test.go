@@ -0,0 +1,97 @@
+// gomuks - A terminal Matrix client written in Go.
+// Copyright (C) 2026 Tulir Asokan
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+package notification
+
+import (
+	"slices"
+	"testing"
+)
+
+func TestBuildCommand(t *testing.T) {
+	tests := []struct {
+		name     string
+		command  []string
+		info     CommandInfo
+		wantArgs []string
+	}{{
+		name:     "plain",
+		command:  []string{"notify-send"},
+		info:     CommandInfo{RoomName: "Room", Sender: "Alice", Body: "Hello", Highlight: true},
+		wantArgs: []string{"notify-send", "Room", "Alice", "Hello", "true"},
+	}, {
+		name:     "configured arguments are kept",
+		command:  []string{"my-notifier", "--urgency", "low"},
+		info:     CommandInfo{RoomName: "Room", Sender: "Alice", Body: "Hello"},
+		wantArgs: []string{"my-notifier", "--urgency", "low", "Room", "Alice", "Hello", "false"},
+	}, {
+		name:     "configured separator before options in values",
+		command:  []string{"notify-send", "--"},
+		info:     CommandInfo{RoomName: "-u critical", Sender: "--app-name=evil", Body: "--help"},
+		wantArgs: []string{"notify-send", "--", "-u critical", "--app-name=evil", "--help", "false"},
+	}, {
+		name:     "shell syntax",
+		command:  []string{"notify-send"},
+		info:     CommandInfo{RoomName: "$(reboot)", Sender: "`id`", Body: "a; rm -rf ~ && echo 'b'"},
+		wantArgs: []string{"notify-send", "$(reboot)", "`id`", "a; rm -rf ~ && echo 'b'", "false"},
+	}, {
+		name:     "empty values",
+		command:  []string{"notify-send"},
+		info:     CommandInfo{},
+		wantArgs: []string{"notify-send", "", "", "", "false"},
+	}}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			args, _ := BuildCommand(test.command, test.info)
+			if !slices.Equal(args, test.wantArgs) {
+				t.Errorf("BuildCommand() args = %q, want %q", args, test.wantArgs)
+			}
+		})
+	}
+}
+
+func TestBuildCommand_Env(t *testing.T) {
+	info := CommandInfo{
+		RoomName:  "Room",
+		RoomID:    "!room:example.com",
+		Sender:    "Alice",
+		SenderID:  "@alice:example.com",
+		Body:      "line 1\nline 2",
+		Highlight: true,
+	}
+	_, env := BuildCommand([]string{"notify-send"}, info)
+	wantEnv := []string{
+		EnvRoomName + "=Room",
+		EnvRoomID + "=!room:example.com",
+		EnvSender + "=Alice",
+		EnvSenderID + "=@alice:example.com",
+		EnvBody + "=line 1\nline 2",
+		EnvHighlight + "=true",
+	}
+	if !slices.Equal(env, wantEnv) {
+		t.Errorf("BuildCommand() env = %q, want %q", env, wantEnv)
+	}
+}
+
+func TestBuildCommand_DoesNotModifyConfig(t *testing.T) {
+	command := make([]string, 1, 10)
+	command[0] = "notify-send"
+	BuildCommand(command, CommandInfo{Body: "first"})
+	args, _ := BuildCommand(command, CommandInfo{Body: "second"})
+	if len(command) != 1 || args[3] != "second" {
+		t.Errorf("Configured command was modified: %q / %q", command, args)
+	}
+}
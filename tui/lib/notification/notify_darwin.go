@@ -34,7 +34,17 @@ const sendScript = `on run {notifText, notifTitle}
 	display notification notifText with title "gomuks" subtitle notifTitle
 end run`
 
-func Send(title, text string, critical, sound bool) error {
+func beep() {
+	_ = exec.Command("osascript", "-e", "beep").Run()
+}
+
+// Send sends a desktop notification. If sound is true, soundFile is played with afplay if it's set,
+// with the default notification sound as a fallback.
+func Send(title, text string, critical, sound bool, soundFile string) error {
+	if sound && customSoundAvailable(soundFile) {
+		playSound(exec.Command("afplay", soundFile), beep)
+		sound = false
+	}
 	if terminalNotifierAvailable {
 		args := []string{"-title", "gomuks", "-subtitle", title, "-message", text}
 		if critical {
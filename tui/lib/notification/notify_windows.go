@@ -17,10 +17,31 @@
 package notification
 
 import (
+	"os"
+	"os/exec"
+	"syscall"
+
 	"gopkg.in/toast.v1"
 )
 
-func Send(title, text string, critical, sound bool) error {
+var messageBeep = syscall.NewLazyDLL("user32.dll").NewProc("MessageBeep")
+
+func beep() {
+	_, _, _ = messageBeep.Call(0)
+}
+
+// The path is passed in an environment variable to avoid having to quote it for PowerShell
+const playSoundScript = `(New-Object Media.SoundPlayer $env:GOMUKS_NOTIFY_SOUND_FILE).PlaySync()`
+
+// Send sends a desktop notification. If sound is true, soundFile is played with PowerShell if it's set,
+// with the default notification sound as a fallback. Only WAV files are supported.
+func Send(title, text string, critical, sound bool, soundFile string) error {
+	if sound && customSoundAvailable(soundFile) {
+		cmd := exec.Command("powershell", "-NoProfile", "-NonInteractive", "-Command", playSoundScript)
+		cmd.Env = append(os.Environ(), "GOMUKS_NOTIFY_SOUND_FILE="+soundFile)
+		playSound(cmd, beep)
+		sound = false
+	}
 	notification := toast.Notification{
 		AppID:    "gomuks",
 		Title:    title,
@@ -21,11 +21,16 @@ package notification
 import (
 	"os"
 	"os/exec"
+	"path/filepath"
 )
 
 var notifySendPath string
 var audioCommand string
+var customAudioCommand string
 var tryAudioCommands = []string{"ogg123", "paplay", "pw-cat"}
+
+// Custom sound files may be in any format, so prefer players that aren't limited to ogg
+var tryCustomAudioCommands = []string{"paplay", "pw-cat", "ogg123"}
 var soundNormal = "/usr/share/sounds/freedesktop/stereo/message-new-instant.oga"
 var soundCritical = "/usr/share/sounds/freedesktop/stereo/complete.oga"
 var audioCommandArgs = map[string]string{
@@ -57,11 +62,36 @@ func init() {
 			break
 		}
 	}
+	for _, cmd := range tryCustomAudioCommands {
+		if customAudioCommand, err = exec.LookPath(cmd); err == nil {
+			break
+		}
+	}
 	soundNormal = getSoundPath("GOMUKS_SOUND_NORMAL", soundNormal)
 	soundCritical = getSoundPath("GOMUKS_SOUND_CRITICAL", soundCritical)
 }
 
-func Send(title, text string, critical, sound bool) error {
+func audioCommandFor(command, audioFile string) *exec.Cmd {
+	if extraArg := audioCommandArgs[filepath.Base(command)]; extraArg != "" {
+		return exec.Command(command, extraArg, audioFile)
+	}
+	return exec.Command(command, audioFile)
+}
+
+func playDefaultSound(critical bool) {
+	if len(audioCommand) == 0 || len(soundNormal) == 0 {
+		return
+	}
+	audioFile := soundNormal
+	if critical && len(soundCritical) > 0 {
+		audioFile = soundCritical
+	}
+	playSound(audioCommandFor(audioCommand, audioFile), nil)
+}
+
+// Send sends a desktop notification. If sound is true, soundFile is played if it's set,
+// with the default sound (for normal or critical notifications) as a fallback.
+func Send(title, text string, critical, sound bool, soundFile string) error {
 	if len(notifySendPath) == 0 {
 		return nil
 	}
@@ -74,21 +104,12 @@ func Send(title, text string, critical, sound bool) error {
 	//	args = append(args, "-i", iconPath)
 	//}
 	args = append(args, title, text)
-	if sound && len(audioCommand) > 0 && len(soundNormal) > 0 {
-		audioFile := soundNormal
-		if critical && len(soundCritical) > 0 {
-			audioFile = soundCritical
-		}
-		extraArg := audioCommandArgs[audioCommand]
-		var audioArgs []string
-		if extraArg != "" {
-			audioArgs = []string{extraArg, audioFile}
-		} else {
-			audioArgs = []string{audioFile}
-		}
-		go func() {
-			_ = exec.Command(audioCommand, audioArgs...).Run()
-		}()
+	if sound && len(customAudioCommand) > 0 && customSoundAvailable(soundFile) {
+		playSound(audioCommandFor(customAudioCommand, soundFile), func() {
+			playDefaultSound(critical)
+		})
+	} else if sound {
+		playDefaultSound(critical)
 	}
 	return exec.Command(notifySendPath, args...).Run()
 }
@@ -0,0 +1,50 @@
+// gomuks - A terminal Matrix client written in Go.
+// Copyright (C) 2026 Tulir Asokan
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+package notification
+
+import (
+	"os"
+	"os/exec"
+
+	"go.mau.fi/gomuks/tui/debug"
+)
+
+// customSoundAvailable checks that a custom sound file is configured and exists,
+// so that the default sound can be used right away otherwise.
+func customSoundAvailable(path string) bool {
+	if len(path) == 0 {
+		return false
+	} else if _, err := os.Stat(path); err != nil {
+		debug.Printf("Custom notification sound %s can't be used: %v", path, err)
+		return false
+	}
+	return true
+}
+
+// playSound runs the given audio player command in the background.
+// If the command fails, the fallback is called instead (unless it's nil).
+func playSound(cmd *exec.Cmd, fallback func()) {
+	go func() {
+		defer debug.Recover()
+		if err := cmd.Run(); err != nil {
+			debug.Printf("Failed to play notification sound with %s: %v", cmd.Path, err)
+			if fallback != nil {
+				fallback()
+			}
+		}
+	}()
+}
@@ -0,0 +1,245 @@
+// gomuks - A terminal Matrix client written in Go.
+// Copyright (C) 2026 Tulir Asokan
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+package tui
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gdamore/tcell/v2"
+	"github.com/tidwall/gjson"
+	"go.mau.fi/mauview"
+
+	"go.mau.fi/gomuks/pkg/hicli/jsoncmd"
+	"go.mau.fi/gomuks/tui/config"
+	"go.mau.fi/gomuks/tui/debug"
+)
+
+const (
+	logsRefreshInterval = 2 * time.Second
+	logsFetchLimit      = 500
+)
+
+var logLevelColors = map[string]string{
+	"trace": "gray",
+	"debug": "darkcyan",
+	"info":  "green",
+	"warn":  "yellow",
+	"error": "red",
+	"fatal": "fuchsia",
+	"panic": "fuchsia",
+}
+
+type LogsModal struct {
+	mauview.Component
+
+	container *mauview.Box
+
+	filter *mauview.InputArea
+	status *mauview.TextField
+	logs   *mauview.TextView
+
+	lock  sync.Mutex
+	lines []json.RawMessage
+	term  string
+	stop  context.CancelFunc
+
+	parent *MainView
+}
+
+func NewLogsModal(mainView *MainView, width, height int) *LogsModal {
+	lm := &LogsModal{
+		parent: mainView,
+	}
+
+	// The text view keeps its scroll position when cleared, and follows new lines when scrolled to the end
+	lm.logs = mauview.NewTextView().SetDynamicColors(true).SetWrap(true).ScrollToEnd()
+	lm.status = mauview.NewTextField()
+	lm.filter = mauview.NewInputArea().
+		SetPlaceholder("Filter logs...").
+		SetChangedFunc(lm.changeHandler).
+		SetTextColor(tcell.ColorWhite).
+		SetBackgroundColor(tcell.ColorDarkCyan)
+	lm.filter.Focus()
+
+	flex := mauview.NewFlex().
+		SetDirection(mauview.FlexRow).
+		AddFixedComponent(lm.filter, 1).
+		AddFixedComponent(lm.status, 1).
+		AddProportionalComponent(lm.logs, 1)
+
+	lm.container = mauview.NewBox(flex).
+		SetBorder(true).
+		SetTitle("Recent logs").
+		SetBlurCaptureFunc(func() bool {
+			lm.close()
+			return true
+		})
+
+	lm.Component = mauview.Center(lm.container, width, height).SetAlwaysFocusChild(true)
+
+	// Logs are only polled while the modal is open
+	ctx, cancel := context.WithCancel(context.Background())
+	lm.stop = cancel
+	go lm.refreshLoop(ctx)
+
+	return lm
+}
+
+func (lm *LogsModal) Focus() {
+	lm.container.Focus()
+}
+
+func (lm *LogsModal) Blur() {
+	lm.container.Blur()
+}
+
+func (lm *LogsModal) close() {
+	lm.stop()
+	lm.parent.HideModal()
+}
+
+func (lm *LogsModal) changeHandler(text string) {
+	lm.lock.Lock()
+	lm.term = strings.ToLower(strings.TrimSpace(text))
+	lm.lock.Unlock()
+	lm.render()
+}
+
+func (lm *LogsModal) refreshLoop(ctx context.Context) {
+	defer debug.Recover()
+	ticker := time.NewTicker(logsRefreshInterval)
+	defer ticker.Stop()
+	for {
+		lm.fetch(ctx)
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+func (lm *LogsModal) fetch(ctx context.Context) {
+	lines, err := lm.parent.matrix.GetRecentLogs(ctx, &jsoncmd.GetRecentLogsParams{Limit: logsFetchLimit})
+	if ctx.Err() != nil {
+		return
+	} else if err != nil {
+		lm.status.SetText(fmt.Sprintf("Failed to fetch logs: %v", err))
+		lm.parent.parent.Render()
+		return
+	}
+	lm.lock.Lock()
+	lm.lines = lines
+	lm.lock.Unlock()
+	lm.render()
+}
+
+func formatLogLine(line json.RawMessage) string {
+	res := gjson.GetManyBytes(line, "time", "level", "component", "action", "message", "error")
+	var buf strings.Builder
+	if ts, err := time.Parse(time.RFC3339Nano, res[0].Str); err == nil {
+		buf.WriteString(ts.Local().Format("15:04:05.000 "))
+	}
+	level := res[1].Str
+	color, ok := logLevelColors[level]
+	if !ok {
+		color = "white"
+	}
+	_, _ = fmt.Fprintf(&buf, "[%s]%-5s[-] ", color, strings.ToUpper(level))
+	if res[2].Str != "" {
+		_, _ = fmt.Fprintf(&buf, "[gray]%s[-] ", mauview.Escape(res[2].Str))
+	} else if res[3].Str != "" {
+		_, _ = fmt.Fprintf(&buf, "[gray]%s[-] ", mauview.Escape(res[3].Str))
+	}
+	buf.WriteString(mauview.Escape(res[4].Str))
+	if res[5].Exists() {
+		_, _ = fmt.Fprintf(&buf, " [red]error=%s[-]", mauview.Escape(res[5].String()))
+	}
+	return buf.String()
+}
+
+func (lm *LogsModal) render() {
+	lm.lock.Lock()
+	defer lm.lock.Unlock()
+	lm.logs.Clear()
+	shown := 0
+	for _, line := range lm.lines {
+		if lm.term != "" && !strings.Contains(strings.ToLower(string(line)), lm.term) {
+			continue
+		}
+		if shown > 0 {
+			_, _ = fmt.Fprint(lm.logs, "\n")
+		}
+		_, _ = fmt.Fprint(lm.logs, formatLogLine(line))
+		shown++
+	}
+	if lm.term != "" {
+		lm.status.SetText(fmt.Sprintf("Showing %d of %d lines", shown, len(lm.lines)))
+	} else {
+		lm.status.SetText(fmt.Sprintf("Showing %d lines", len(lm.lines)))
+	}
+	lm.parent.parent.Render()
+}
+
+func (lm *LogsModal) OnKeyEvent(event mauview.KeyEvent) bool {
+	kb := config.Keybind{
+		Key: event.Key(),
+		Ch:  event.Rune(),
+		Mod: event.Modifiers(),
+	}
+	switch lm.parent.config.Keybindings.Modal[kb] {
+	case "cancel":
+		lm.close()
+		return true
+	}
+	switch event.Key() {
+	case tcell.KeyUp, tcell.KeyDown, tcell.KeyPgUp, tcell.KeyPgDn:
+		lm.lock.Lock()
+		defer lm.lock.Unlock()
+		return lm.logs.OnKeyEvent(event)
+	}
+	return lm.filter.OnKeyEvent(event)
+}
+
+func (view *RoomView) SetLogLevel(level, component string) {
+	defer debug.Recover()
+	levels, err := view.parent.matrix.SetLogLevel(context.TODO(), &jsoncmd.SetLogLevelParams{
+		Component: component,
+		Level:     level,
+	})
+	if err != nil {
+		view.AddServiceMessage("Failed to change log level: %v", err)
+	} else {
+		overrides := make([]string, 0, len(levels.Components))
+		for comp, lvl := range levels.Components {
+			overrides = append(overrides, fmt.Sprintf("%s=%s", comp, lvl))
+		}
+		slices.Sort(overrides)
+		if len(overrides) > 0 {
+			view.AddServiceMessage("Log level is now %s (%s) until gomuks is restarted", levels.Global, strings.Join(overrides, ", "))
+		} else {
+			view.AddServiceMessage("Log level is now %s until gomuks is restarted", levels.Global)
+		}
+	}
+	view.parent.parent.Render()
+}
@@ -0,0 +1,92 @@
+// gomuks - A terminal Matrix client written in Go.
+// Copyright (C) 2026 Tulir Asokan
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+package tui
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"go.mau.fi/gomuks/pkg/hicli/jsoncmd"
+	"go.mau.fi/gomuks/pkg/rpc"
+	"go.mau.fi/gomuks/tui/debug"
+)
+
+// tooLargePrompt is a message that the backend couldn't split into multiple events automatically,
+// because it has a code block that doesn't fit in a single event.
+type tooLargePrompt struct {
+	params *jsoncmd.SendMessageParams
+}
+
+const tooLargePromptStatus = "Message is too long for one event: [f] send as text file, [s] split anyway, [n] cancel"
+
+// resolveTooLargePrompt handles the answer to the too large message question. The answer is f to upload the text
+// as a file, s to split code blocks into multiple events or n to put the text back in the input box.
+func (view *RoomView) resolveTooLargePrompt(answer rune) {
+	prompt := view.tooLargePrompt
+	view.tooLargePrompt = nil
+	switch answer {
+	case 'f':
+		go view.sendTextAsFile(prompt.params)
+	case 's':
+		prompt.params.SplitCodeBlocks = true
+		go view.sendMessageParams(prompt.params)
+	default:
+		if view.input.GetText() == "" {
+			view.SetInputText(prompt.params.Text)
+		}
+	}
+}
+
+// sendMessageParams sends a message and asks what to do if it's too large for a single event.
+func (view *RoomView) sendMessageParams(params *jsoncmd.SendMessageParams) {
+	defer debug.Recover()
+	err := view.parent.matrix.SendMessage(context.TODO(), params)
+	if errors.Is(err, rpc.ErrMessageTooLarge) {
+		view.tooLargePrompt = &tooLargePrompt{params: params}
+	} else if err != nil {
+		debug.Print("Failed to send message:", err)
+		view.AddServiceMessage("Failed to send message: %v", err)
+	}
+	debug.Print("Rendering after sending message")
+	view.parent.parent.Render()
+}
+
+// sendTextAsFile uploads the text of a message as a plain text file and sends it to the room.
+func (view *RoomView) sendTextAsFile(params *jsoncmd.SendMessageParams) {
+	defer debug.Recover()
+	defer view.parent.parent.Render()
+	content, err := view.parent.matrix.UploadMedia(context.TODO(), strings.NewReader(params.Text), rpc.UploadMediaParams{
+		FileName: fmt.Sprintf("Message %s.txt", time.Now().Format("2006-01-02 15-04-05")),
+		Encrypt:  view.Room.Meta.Current().EncryptionEvent != nil,
+	})
+	if err != nil {
+		view.AddServiceMessage("Failed to upload message as file: %v", err)
+		return
+	}
+	err = view.parent.matrix.SendMessage(context.TODO(), &jsoncmd.SendMessageParams{
+		RoomID:      view.Room.ID,
+		BaseContent: content,
+		RelatesTo:   params.RelatesTo,
+		Mentions:    params.Mentions,
+	})
+	if err != nil {
+		view.AddServiceMessage("Failed to send message as file: %v", err)
+	}
+}
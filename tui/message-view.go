@@ -17,21 +17,28 @@
 package tui
 
 import (
+	"context"
 	"fmt"
 	"math"
+	"slices"
 	"strings"
 	"sync"
 	"sync/atomic"
+	"time"
 
 	"github.com/gdamore/tcell/v2"
 	"github.com/mattn/go-runewidth"
 	"go.mau.fi/mauview"
 	"maunium.net/go/mautrix/event"
 	"maunium.net/go/mautrix/format"
+	"maunium.net/go/mautrix/id"
 
 	"go.mau.fi/gomuks/pkg/hicli/database"
+	"go.mau.fi/gomuks/pkg/hicli/jsoncmd"
 	"go.mau.fi/gomuks/pkg/rpc/client"
+	"go.mau.fi/gomuks/pkg/rpc/store"
 	"go.mau.fi/gomuks/tui/config"
+	"go.mau.fi/gomuks/tui/debug"
 	"go.mau.fi/gomuks/tui/messages"
 	"go.mau.fi/gomuks/tui/widget"
 )
@@ -55,6 +62,28 @@ type MessageView struct {
 	prevTimeline *[]*database.Event
 	prevWidth    int
 	selected     database.EventRowID
+
+	revealedSpoilers map[database.EventRowID]bool
+	// revealedBanRemoved contains messages removed by a ban that the user has chosen to view anyway.
+	// It's intentionally not persisted, use the reveal_ban_removed preference to always show them.
+	revealedBanRemoved map[database.EventRowID]bool
+
+	filter *TimelineFilter
+	// filterAnchor is the scroll position before the filter was applied, restored when it's cleared.
+	filterAnchor *scrollAnchor
+	// restoreAnchor is set when the filter changes, as the scroll position in the old buffer doesn't apply to the new one.
+	restoreAnchor *scrollAnchor
+	filterChanged bool
+
+	// replyStates contains the results of fetching replied-to events that weren't loaded locally.
+	replyStates map[id.EventID]messages.ReplyState
+	// predecessorLine is the screen row of the link to the predecessor room, or -1 if it's not visible.
+	predecessorLine int
+
+	// lineage contains messages from the rooms this room was upgraded from, shown before the room's own timeline.
+	// It's nil unless continuous history through room upgrades is enabled.
+	lineage      *store.LineageTimeline
+	prevSegments []*store.LineageSegment
 }
 
 func NewMessageView(parent *RoomView) *MessageView {
@@ -65,6 +94,14 @@ func NewMessageView(parent *RoomView) *MessageView {
 
 		SenderWidth:    15,
 		TimestampWidth: len(messages.TimeFormat),
+
+		revealedSpoilers:   make(map[database.EventRowID]bool),
+		revealedBanRemoved: make(map[database.EventRowID]bool),
+		replyStates:        make(map[id.EventID]messages.ReplyState),
+		predecessorLine:    -1,
+	}
+	if mv.config.Preferences.ContinuousHistory && parent.Room.Meta.Current().CreationContent.GetPredecessor().RoomID != "" {
+		mv.lineage = store.NewLineageTimeline(parent.Room.ID)
 	}
 	return mv
 }
@@ -87,7 +124,124 @@ func (view *MessageView) GetSelected() *messages.UIMessage {
 	return evt.RenderMeta.(*messages.UIMessage)
 }
 
+// ToggleSpoilers toggles whether spoilers in the given message are revealed.
+func (view *MessageView) ToggleSpoilers(message *messages.UIMessage) {
+	if message == nil {
+		return
+	} else if view.revealedSpoilers[message.RowID] {
+		delete(view.revealedSpoilers, message.RowID)
+	} else {
+		view.revealedSpoilers[message.RowID] = true
+	}
+}
+
+// ToggleBanRemoved toggles whether the original content of a message removed by a ban is shown.
+func (view *MessageView) ToggleBanRemoved(message *messages.UIMessage) {
+	if message == nil || message.IsService {
+		return
+	}
+	view.lock.Lock()
+	defer view.lock.Unlock()
+	if view.revealedBanRemoved[message.RowID] {
+		delete(view.revealedBanRemoved, message.RowID)
+	} else if message.BanRemoved {
+		view.revealedBanRemoved[message.RowID] = true
+	} else {
+		return
+	}
+	message.Event.RenderMeta = nil
+	// Force the buffer to be rebuilt on the next draw
+	view.prevTimeline = nil
+}
+
+// removingBan returns the ban event that the given message should be hidden by, or nil if it should be shown.
+func (view *MessageView) removingBan(evt *database.Event) *database.Event {
+	if view.config.Preferences.RevealBanRemoved || view.revealedBanRemoved[evt.RowID] {
+		return nil
+	}
+	return messages.GetRemovingBan(view.parent.Room, evt)
+}
+
+// SetFilter rebuilds the message buffer with only messages matching the given filter.
+func (view *MessageView) SetFilter(filter *TimelineFilter) {
+	view.lock.Lock()
+	defer view.lock.Unlock()
+	if view.filter == nil {
+		view.filterAnchor = nil
+		if offset := view.GetScrollOffset(); offset > 0 {
+			view.filterAnchor = findScrollAnchor(view.msgBuffer, offset, view.Height())
+		}
+	}
+	view.filter = filter
+	view.ScrollOffset.Store(0)
+	view.restoreAnchor = nil
+	view.filterChanged = true
+	view.prevTimeline = nil
+}
+
+// ClearFilter removes the timeline filter and restores the scroll position from before it was applied.
+// It returns false if there was no filter.
+func (view *MessageView) ClearFilter() bool {
+	view.lock.Lock()
+	defer view.lock.Unlock()
+	if view.filter == nil {
+		return false
+	}
+	view.filter = nil
+	view.ScrollOffset.Store(0)
+	view.restoreAnchor = view.filterAnchor
+	view.filterAnchor = nil
+	view.filterChanged = true
+	view.prevTimeline = nil
+	return true
+}
+
+// GetFilter returns the current timeline filter, or nil if all messages are shown.
+func (view *MessageView) GetFilter() *TimelineFilter {
+	view.lock.RLock()
+	defer view.lock.RUnlock()
+	return view.filter
+}
+
+// ToggleProfileChanges expands or collapses the run of profile changes that the given message is a part of.
+func (view *MessageView) ToggleProfileChanges(message *messages.UIMessage) {
+	if message == nil || len(message.ProfileChangeRun) == 0 {
+		return
+	}
+	view.lock.Lock()
+	head := message.ProfileChangeRun[0]
+	head.ExpandProfileChanges = !head.ExpandProfileChanges
+	// Force the buffer to be rebuilt on the next draw
+	view.prevTimeline = nil
+	view.lock.Unlock()
+}
+
+// SetContinuousHistory enables or disables loading messages from predecessor rooms when scrolling past the beginning
+// of the room. It does nothing if the room wasn't upgraded from another room.
+func (view *MessageView) SetContinuousHistory(enabled bool) {
+	view.lock.Lock()
+	defer view.lock.Unlock()
+	if !enabled {
+		view.lineage = nil
+	} else if view.lineage == nil && view.parent.Room.Meta.Current().CreationContent.GetPredecessor().RoomID != "" {
+		view.lineage = store.NewLineageTimeline(view.parent.Room.ID)
+	}
+	// Force the buffer to be rebuilt on the next draw
+	view.prevTimeline = nil
+}
+
+// Lineage returns the composed timeline of predecessor rooms, or nil if continuous history isn't enabled.
+func (view *MessageView) Lineage() *store.LineageTimeline {
+	view.lock.RLock()
+	defer view.lock.RUnlock()
+	return view.lineage
+}
+
 func (view *MessageView) handleMessageClick(message *messages.UIMessage, mod tcell.ModMask) bool {
+	if message.IsGap {
+		go view.parent.parent.FillGap(view.parent.Room.ID, message.TimelineRowID)
+		return false
+	}
 	//if msg, ok := message.Renderer.(*messages.FileMessage); ok && mod > 0 && !msg.Thumbnail.IsEmpty() {
 	//	debug.Print("Opening thumbnail", msg.ThumbnailPath())
 	//	open.Open(msg.ThumbnailPath())
@@ -145,7 +299,7 @@ func (view *MessageView) OnMouseEvent(event mauview.MouseEvent) bool {
 	switch event.Buttons() {
 	case tcell.WheelUp:
 		if view.IsAtTop() {
-			go view.parent.parent.LoadHistory(view.parent.Room.ID)
+			view.parent.LoadHistory()
 		} else {
 			view.AddScrollOffset(WheelScrollOffsetDiff)
 			return true
@@ -157,7 +311,10 @@ func (view *MessageView) OnMouseEvent(event mauview.MouseEvent) bool {
 	case tcell.Button1:
 		x, y := event.Position()
 		line := view.TotalHeight() - int(view.ScrollOffset.Load()) - view.Height() + y
-		if line < 0 || line >= view.TotalHeight() {
+		if line < 0 && y == view.predecessorLine {
+			go view.parent.OpenPredecessor()
+			return false
+		} else if line < 0 || line >= view.TotalHeight() {
 			return false
 		}
 
@@ -177,6 +334,9 @@ func (view *MessageView) OnMouseEvent(event mauview.MouseEvent) bool {
 
 		if x >= messageX {
 			return view.handleMessageClick(message, event.Modifiers())
+		} else if message.IsContinuation && x != usernameX+view.SenderWidth-1 {
+			// Only the continuation indicator is clickable when the sender name is hidden
+			return false
 		} else if x >= usernameX && x < messageX-SenderMessageGap {
 			return view.handleUsernameClick(message, prevMessage)
 		}
@@ -205,6 +365,30 @@ func (view *MessageView) AddScrollOffset(diff int) {
 	view.ScrollOffset.Store(int32(scrollOffset))
 }
 
+// ScrollToEvent scrolls the view so that the message with the given row ID is in the middle of the screen.
+// It returns false if the message isn't in the currently loaded timeline.
+func (view *MessageView) ScrollToEvent(rowID database.EventRowID) bool {
+	view.lock.RLock()
+	firstLine, lastLine := -1, -1
+	for i, msg := range view.msgBuffer {
+		if msg.RowID == rowID && !msg.IsService {
+			if firstLine == -1 {
+				firstLine = i
+			}
+			lastLine = i
+		}
+	}
+	view.lock.RUnlock()
+	if firstLine == -1 {
+		return false
+	}
+	height := view.Height()
+	padding := max(0, (height-(lastLine-firstLine+1))/2)
+	scrollOffset := view.TotalHeight() - lastLine - 1 - padding
+	view.ScrollOffset.Store(int32(max(0, min(scrollOffset, view.TotalHeight()-height+PaddingAtTop))))
+	return true
+}
+
 func (view *MessageView) Height() int {
 	return int(view.height.Load())
 }
@@ -217,12 +401,30 @@ func (view *MessageView) IsAtTop() bool {
 	return view.GetScrollOffset() >= view.TotalHeight()-view.Height()+PaddingAtTop
 }
 
+// ContinuationChar is drawn in the sender column instead of the sender name
+// for grouped messages that continue the previous message from the same sender.
+const ContinuationChar = '┆'
+
 const (
 	TimestampSenderGap = 1
 	SenderSeparatorGap = 1
 	SenderMessageGap   = 3
+	// DeliveryIndicatorWidth is the space reserved at the end of the line for the delivery indicator.
+	DeliveryIndicatorWidth = 3
 )
 
+// showDeliveryIndicators returns true if space should be reserved for delivery indicators of own messages.
+func (view *MessageView) showDeliveryIndicators() bool {
+	return !view.config.Preferences.HideDeliveryStatus && !view.config.Preferences.BareMessageView
+}
+
+// drawDeliveryIndicator draws the delivery indicator of a message right-aligned in the column starting at x.
+func drawDeliveryIndicator(screen mauview.Screen, msg *messages.UIMessage, x, line int) {
+	if indicator, color := msg.DeliveryIndicator(); indicator != "" {
+		widget.WriteLineColor(screen, mauview.AlignRight, indicator, x, line, DeliveryIndicatorWidth, color)
+	}
+}
+
 func getScrollbarStyle(scrollbarHere, isTop, isBottom bool) (char rune, style tcell.Style) {
 	char = '│'
 	style = tcell.StyleDefault
@@ -258,9 +460,38 @@ func (view *MessageView) calculateScrollBar(height int) (scrollBarHeight, scroll
 	return
 }
 
+// drawBeginning draws a summary of the room creation event in place of the load more prompt
+// when the timeline goes all the way back to the beginning of the room.
+func (view *MessageView) drawBeginning(screen mauview.Screen, messageX int) {
+	widget.WriteLineSimpleColor(screen, "— beginning of conversation —", messageX, 0, tcell.ColorGreen)
+	createEvt := view.parent.Room.GetStateEvent(event.StateCreate, "")
+	if createEvt == nil {
+		return
+	}
+	created := fmt.Sprintf("Created by %s on %s", view.parent.Room.GetDisplayname(createEvt.Sender), createEvt.Timestamp.Format(messages.DateFormat))
+	widget.WriteLineSimpleColor(screen, created, messageX, 1, tcell.ColorGreen)
+	if meta := view.parent.Room.Meta.Current(); meta.CreationContent != nil && meta.CreationContent.Predecessor != nil {
+		widget.WriteLineSimpleColor(screen, "This room continues an older room — click here to open it", messageX, 2, tcell.ColorYellow)
+		view.predecessorLine = 2
+	}
+}
+
 func (view *MessageView) getIndexOffset(screen mauview.Screen, height, messageX int) (indexOffset int) {
 	indexOffset = view.TotalHeight() - view.GetScrollOffset() - height
-	if indexOffset <= -PaddingAtTop {
+	view.predecessorLine = -1
+	if indexOffset > -PaddingAtTop {
+		return
+	}
+	roomHasMore := view.parent.Room.HasMoreHistory()
+	if !roomHasMore && view.lineage != nil && view.lineage.HasMore() {
+		message := "Scroll up to load messages from the previous room."
+		if view.lineage.Paginating.Load() {
+			message = "Loading messages from the previous room..."
+		}
+		widget.WriteLineSimpleColor(screen, message, messageX, 0, tcell.ColorGreen)
+	} else if !roomHasMore {
+		view.drawBeginning(screen, messageX)
+	} else {
 		message := "Scroll up to load more messages."
 		if view.parent.Room.Paginating.Load() {
 			message = "Loading more messages..."
@@ -270,31 +501,133 @@ func (view *MessageView) getIndexOffset(screen mauview.Screen, height, messageX
 	return
 }
 
-func (view *MessageView) CapturePlaintext(height int) string {
-	var buf strings.Builder
-	indexOffset := view.TotalHeight() - view.GetScrollOffset() - height
-	var prevMessage *messages.UIMessage
+// capturedMessages returns the messages that end at the bottom of the viewport, oldest first.
+// Each message is included only once regardless of how many rows it takes. If maxMessages is
+// zero, all loaded messages up to the bottom of the viewport are returned.
+func (view *MessageView) capturedMessages(maxMessages int) []*messages.UIMessage {
 	view.lock.RLock()
-	for line := 0; line < height; line++ {
-		index := indexOffset + line
-		if index < 0 {
+	defer view.lock.RUnlock()
+	lastIndex := min(len(view.msgBuffer), view.TotalHeight()-view.GetScrollOffset()) - 1
+	var msgs []*messages.UIMessage
+	var prevMessage *messages.UIMessage
+	for i := lastIndex; i >= 0; i-- {
+		message := view.msgBuffer[i]
+		if message == prevMessage {
 			continue
 		}
+		prevMessage = message
+		msgs = append(msgs, message)
+		if maxMessages > 0 && len(msgs) >= maxMessages {
+			break
+		}
+	}
+	slices.Reverse(msgs)
+	return msgs
+}
 
-		message := view.msgBuffer[index]
-		if message != prevMessage {
-			var sender string
-			if len(message.GetSenderName()) > 0 {
-				sender = fmt.Sprintf(" <%s>", message.GetSenderName())
-			} else if message.MsgType == event.MsgEmote {
-				sender = fmt.Sprintf(" * %s", message.GetRawSenderName())
-			}
-			fmt.Fprintf(&buf, "%s%s %s\n", message.FormatTime(), sender, message.PlainText())
-			prevMessage = message
+// VisibleMessageCount returns the number of distinct messages that are at least partially visible in the viewport.
+func (view *MessageView) VisibleMessageCount() int {
+	view.lock.RLock()
+	defer view.lock.RUnlock()
+	lastIndex := min(len(view.msgBuffer), view.TotalHeight()-view.GetScrollOffset()) - 1
+	firstIndex := max(0, lastIndex-view.Height()+1)
+	count := 0
+	var prevMessage *messages.UIMessage
+	for i := firstIndex; i <= lastIndex; i++ {
+		if view.msgBuffer[i] != prevMessage {
+			count++
+			prevMessage = view.msgBuffer[i]
 		}
 	}
-	view.lock.RUnlock()
-	return buf.String()
+	return count
+}
+
+const replySnippetLength = 50
+
+// resolveReply applies the result of fetching the replied-to event of the message, or starts fetching it
+// if that hasn't been done yet. It must be called with the lock held.
+func (view *MessageView) resolveReply(msg *messages.UIMessage) {
+	state, ok := view.replyStates[msg.ReplyToID]
+	if !ok {
+		view.replyStates[msg.ReplyToID] = messages.ReplyStateLoading
+		go view.fetchReply(msg.ReplyToID)
+	} else if state != messages.ReplyStateLoading {
+		msg.ReplyState = state
+	}
+}
+
+func (view *MessageView) fetchReply(eventID id.EventID) {
+	defer debug.Recover()
+	room := view.parent.Room
+	evt, err := view.matrix.GetEvent(context.TODO(), &jsoncmd.GetEventParams{
+		RoomID:  room.ID,
+		EventID: eventID,
+	})
+	view.lock.Lock()
+	if err != nil {
+		debug.Print("Failed to fetch replied-to event", eventID, "in", room.ID, err)
+		view.replyStates[eventID] = messages.ClassifyReplyFetchError(err)
+	} else if evt == nil {
+		view.replyStates[eventID] = messages.ReplyStateUnknown
+	} else {
+		room.ApplyFetchedEvent(evt)
+		delete(view.replyStates, eventID)
+	}
+	// Force the buffer to be rebuilt on the next draw
+	view.prevTimeline = nil
+	view.lock.Unlock()
+	view.parent.parent.parent.Render()
+}
+
+func (view *MessageView) formatPlaintextMessage(buf *strings.Builder, message *messages.UIMessage) {
+	timestamp := message.FormatTime()
+	indent := strings.Repeat(" ", len(timestamp))
+	if message.ReplyTo != nil {
+		snippet, _, _ := strings.Cut(message.ReplyTo.PlainText(), "\n")
+		if len(snippet) > replySnippetLength {
+			snippet = snippet[:replySnippetLength] + "…"
+		}
+		fmt.Fprintf(buf, "%s ↳ replying to %s: %s\n", indent, message.ReplyTo.GetRawSenderName(), snippet)
+	} else if message.ReplyState != messages.ReplyStateNone {
+		fmt.Fprintf(buf, "%s ↳ %s\n", indent, message.ReplyState.Header())
+	}
+	if message.IsContinuation {
+		fmt.Fprintf(buf, "%s %s\n", indent, message.PlainText())
+	} else {
+		// Emotes don't have a sender name, the "* name" prefix is already in the plaintext body
+		var sender string
+		if len(message.GetSenderName()) > 0 {
+			sender = fmt.Sprintf(" <%s>", message.GetSenderName())
+		}
+		fmt.Fprintf(buf, "%s%s %s\n", timestamp, sender, message.PlainText())
+	}
+	if mxc, encrypted, ok := message.MediaURL(); ok {
+		fmt.Fprintf(buf, "%s   %s %s\n", indent, mxc, view.matrix.BuildMediaURL(mxc, client.MediaURLOptions{Encrypted: encrypted, WithoutAuth: true}))
+	}
+	if reactions := message.ReactionSummary(); reactions != "" {
+		fmt.Fprintf(buf, "%s   [%s]\n", indent, reactions)
+	}
+}
+
+// CapturePlaintext returns a plaintext version of the messages ending at the bottom of the viewport,
+// including reply context, media links and reactions. At most maxMessages messages are included
+// (all loaded messages if zero), and if maxBytes is positive, older messages are dropped to fit in it.
+func (view *MessageView) CapturePlaintext(maxMessages, maxBytes int) string {
+	msgs := view.capturedMessages(maxMessages)
+	formatted := make([]string, len(msgs))
+	totalSize := 0
+	firstIncluded := len(msgs)
+	for i := len(msgs) - 1; i >= 0; i-- {
+		var buf strings.Builder
+		view.formatPlaintextMessage(&buf, msgs[i])
+		if maxBytes > 0 && totalSize+buf.Len() > maxBytes {
+			break
+		}
+		formatted[i] = buf.String()
+		totalSize += buf.Len()
+		firstIncluded = i
+	}
+	return strings.Join(formatted[firstIncluded:], "")
 }
 
 func (view *MessageView) Draw(screen mauview.Screen) {
@@ -328,7 +661,7 @@ func (view *MessageView) Draw(screen mauview.Screen) {
 		viewStart = -indexOffset
 	}
 
-	if !bareMode {
+	if !bareMode && !widget.HideDecorations.Load() {
 		separatorX := usernameX + view.SenderWidth + SenderSeparatorGap
 		scrollBarHeight, scrollBarPos := view.calculateScrollBar(height)
 
@@ -353,99 +686,259 @@ func (view *MessageView) Draw(screen mauview.Screen) {
 			}
 		}
 
-		if len(msg.FormatTime()) > 0 && !view.config.Preferences.HideTimestamp {
+		msg.IsSelected = view.selected != 0 && msg.RowID == view.selected
+		showTimestamp := !msg.IsContinuation || msg.IsSelected
+		if len(msg.FormatTime()) > 0 && !view.config.Preferences.HideTimestamp && showTimestamp {
 			widget.WriteLineSimpleColor(screen, msg.FormatTime(), 0, line, msg.TimestampColor())
 		}
-		// TODO hiding senders might not be that nice after all, maybe an option? (disabled for now)
-		//if !bareMode && (prevMsg == nil || meta.Sender() != prevMsg.Sender()) {
-		widget.WriteLineColor(
-			screen, mauview.AlignRight, msg.GetSenderName(),
-			usernameX, line, view.SenderWidth,
-			msg.SenderColor())
-		//}
+		if msg.IsContinuation {
+			screen.SetCell(usernameX+view.SenderWidth-1, line, tcell.StyleDefault.Foreground(msg.SenderColor()), ContinuationChar)
+		} else {
+			widget.WriteLineColor(
+				screen, mauview.AlignRight, msg.GetSenderName(),
+				usernameX, line, view.SenderWidth,
+				msg.SenderColor())
+		}
 		if msg.LastEditRef != nil {
 			// TODO add better indicator for edits
 			screen.SetCell(usernameX+view.SenderWidth, line, tcell.StyleDefault.Foreground(tcell.ColorDarkRed), '*')
 		}
 
-		msg.IsSelected = view.selected != 0 && msg.RowID == view.selected
+		msg.RevealSpoilers = view.config.Preferences.RevealSpoilers || view.revealedSpoilers[msg.RowID]
+		if msg.ReplyTo != nil {
+			msg.ReplyTo.RevealSpoilers = view.config.Preferences.RevealSpoilers
+		}
+		if view.showDeliveryIndicators() && msg.Sender == view.matrix.UserID {
+			drawDeliveryIndicator(screen, msg, width-DeliveryIndicatorWidth, line)
+		}
 		msg.Draw(mauview.NewProxyScreen(screen, messageX, line, width-messageX, msg.Height()))
 		line += msg.Height()
 	}
 }
 
+// scrollAnchor is a line of a message that should stay in the same place on screen when the
+// message buffer is rebuilt, e.g. when history is loaded while the user is reading older messages.
+type scrollAnchor struct {
+	rowID database.EventRowID
+	// lineInMessage is the line within the message that the anchor points at.
+	lineInMessage int
+	// screenLine is the row in the viewport where that line was drawn.
+	screenLine int
+}
+
+// findScrollAnchor returns the topmost message that is fully visible in the viewport. If the
+// viewport doesn't contain a whole message, the line at the top of the viewport is used instead.
+func findScrollAnchor(buffer []*messages.UIMessage, scrollOffset, height int) *scrollAnchor {
+	viewTop := len(buffer) - scrollOffset - height
+	viewBottom := min(len(buffer), viewTop+height)
+	var partial *scrollAnchor
+	for i := max(0, viewTop); i < viewBottom; i++ {
+		msg := buffer[i]
+		if msg.RowID == 0 || msg.IsService {
+			continue
+		}
+		firstLine := i
+		for firstLine > 0 && buffer[firstLine-1] == msg {
+			firstLine--
+		}
+		if firstLine == i && i+msg.Height() <= viewBottom {
+			return &scrollAnchor{rowID: msg.RowID, screenLine: i - viewTop}
+		} else if partial == nil {
+			partial = &scrollAnchor{rowID: msg.RowID, lineInMessage: i - firstLine, screenLine: i - viewTop}
+		}
+	}
+	return partial
+}
+
+// restore returns the scroll offset that puts the anchor back where it was on screen.
+// The message may have a different height than before (e.g. if it was decrypted or edited),
+// in which case the anchored line is clamped to the new height of the message.
+func (anchor *scrollAnchor) restore(buffer []*messages.UIMessage, height int) (int, bool) {
+	for i, msg := range buffer {
+		if msg.RowID == anchor.rowID && !msg.IsService {
+			line := i + min(anchor.lineInMessage, msg.Height()-1)
+			return len(buffer) - height - (line - anchor.screenLine), true
+		}
+	}
+	return 0, false
+}
+
 func (view *MessageView) update(width int) {
 	timelinePtr := view.parent.Room.TimelineCache.Current()
-	if timelinePtr == nil || timelinePtr == view.prevTimeline && width == view.prevWidth {
+	var segments []*store.LineageSegment
+	if view.lineage != nil {
+		segments = view.lineage.Segments.Current()
+	}
+	if timelinePtr == nil || timelinePtr == view.prevTimeline && width == view.prevWidth && slices.Equal(segments, view.prevSegments) {
 		return
 	}
 	timeline := *timelinePtr
-	var prevTimeline []*database.Event
-	if view.prevTimeline != nil {
-		prevTimeline = *view.prevTimeline
-	}
 
 	newBuffer := make([]*messages.UIMessage, 0, len(timeline)*2)
-	var lastRowIDInPrevTimeline database.EventRowID
-	if len(prevTimeline) > 0 {
-		lastRowIDInPrevTimeline = prevTimeline[len(prevTimeline)-1].RowID
-	}
-	increaseScrollOffset := false
 	bare := view.config.Preferences.BareMessageView
 	if !bare {
 		width -= view.SenderWidth + SenderMessageGap
 		if !view.config.Preferences.HideTimestamp {
 			width -= view.TimestampWidth + TimestampSenderGap
 		}
+		if view.showDeliveryIndicators() {
+			width -= DeliveryIndicatorWidth
+		}
 	}
+	height := view.Height()
 	scrollOffset := view.GetScrollOffset()
-	newScrollOffset := scrollOffset
+	var anchor *scrollAnchor
+	if view.filterChanged {
+		anchor = view.restoreAnchor
+		view.restoreAnchor = nil
+		view.filterChanged = false
+	} else if scrollOffset > 0 {
+		anchor = findScrollAnchor(view.msgBuffer, scrollOffset, height)
+	}
+	grouping := view.config.Preferences.GroupMessages && !bare
+	groupingInterval := view.config.Preferences.GroupingInterval()
+	var lastAppended *messages.UIMessage
 	appendBuffer := func(msg *messages.UIMessage) {
 		if width < 5 {
 			return
 		}
+		msg.IsContinuation = grouping && msg.CanGroupWith(lastAppended, groupingInterval)
+		lastAppended = msg
 		msg.CalculateBuffer(view.config.Preferences, width)
-		height := msg.Height()
-		for i := 0; i < height; i++ {
+		for i := 0; i < msg.Height(); i++ {
 			newBuffer = append(newBuffer, msg)
 		}
-		if scrollOffset > 0 && increaseScrollOffset {
-			newScrollOffset += height
-		}
 	}
 	var prev *messages.UIMessage
-	prevLastEventNotFound := lastRowIDInPrevTimeline != 0
+	var clock time.Time
+	now := time.Now()
+	appendMessage := func(uiMsg *messages.UIMessage) {
+		clock = uiMsg.ApplyTimelineOrder(clock, now)
+		if uiMsg.NeedsDateSeparator(prev) {
+			dateChange := messages.NewDateChangeMessage(view.parent.Room, fmt.Sprintf("Date changed to %s", uiMsg.FormatDate()))
+			appendBuffer(dateChange)
+		}
+		appendBuffer(uiMsg)
+		if uiMsg.TimestampAnomaly == messages.TimestampNormal {
+			prev = uiMsg
+		}
+	}
+	membershipNoise := view.config.Preferences.GetMembershipNoise()
+	filter := view.filter
+	filterMatches := 0
+	var profileRun []*messages.UIMessage
+	flushProfileRun := func() {
+		if len(profileRun) == 0 {
+			return
+		} else if len(profileRun) == 1 {
+			profileRun[0].ProfileChangeRun = nil
+			appendMessage(profileRun[0])
+		} else {
+			for _, msg := range profileRun {
+				msg.ProfileChangeRun = profileRun
+			}
+			if profileRun[0].ExpandProfileChanges {
+				for _, msg := range profileRun {
+					appendMessage(msg)
+				}
+			} else {
+				appendMessage(messages.NewProfileChangeGroup(view.parent.Room, profileRun))
+			}
+		}
+		profileRun = nil
+	}
+	for _, segment := range segments {
+		// Messages from predecessors are parsed in the context of the old room if it's known,
+		// but they're not added to its timeline, so they don't affect the old room's own view.
+		segmentRoom := view.matrix.GetRoom(segment.Room.RoomID)
+		if segmentRoom == nil {
+			segmentRoom = view.parent.Room
+		}
+		for _, evt := range segment.Events {
+			if evt.RenderMeta == nil {
+				evt.RenderMeta = messages.ParseEvent(view.matrix, &view.config.Preferences, segmentRoom, evt)
+			}
+			uiMsg := evt.RenderMeta.(*messages.UIMessage)
+			if uiMsg == nil || (uiMsg.IsProfileChange && membershipNoise != config.MembershipNoiseAll) {
+				continue
+			} else if filter != nil {
+				if filter.Match(evt) {
+					filterMatches++
+					appendMessage(uiMsg)
+				}
+				continue
+			}
+			appendMessage(uiMsg)
+		}
+		if filter == nil {
+			appendBuffer(messages.NewUpgradeMessage(view.parent.Room, segment.Room))
+		}
+	}
 	for _, evt := range timeline {
-		startIncreasingScrollOffset := false
-		if !increaseScrollOffset && scrollOffset > 0 && evt.RowID != 0 && evt.RowID == lastRowIDInPrevTimeline {
-			startIncreasingScrollOffset = true
-			prevLastEventNotFound = true
+		if cached, ok := evt.RenderMeta.(*messages.UIMessage); ok && cached != nil && cached.ReplyState == messages.ReplyStateLoading &&
+			view.parent.Room.GetEventByID(cached.ReplyToID) != nil {
+			// The replied-to event was fetched after this message was parsed
+			evt.RenderMeta = nil
+		}
+		// Bans only affect the loaded timeline, as they're checked from the current member state when rendering
+		banEvt := view.removingBan(evt)
+		if cached, ok := evt.RenderMeta.(*messages.UIMessage); ok && cached != nil && cached.BanRemoved != (banEvt != nil) {
+			// The sender was banned or unbanned after this message was parsed
+			evt.RenderMeta = nil
 		}
 		if evt.RenderMeta == nil {
-			evt.RenderMeta = messages.ParseEvent(view.matrix, &view.config.Preferences, view.parent.Room, evt)
+			if banEvt != nil {
+				evt.RenderMeta = messages.NewBanRemovedMessage(evt, view.parent.Room, banEvt)
+			} else {
+				evt.RenderMeta = messages.ParseEvent(view.matrix, &view.config.Preferences, view.parent.Room, evt)
+			}
 		}
 		uiMsg := evt.RenderMeta.(*messages.UIMessage)
 		if uiMsg == nil {
 			continue
 		}
-		if !uiMsg.SameDate(prev) {
-			dateChange := messages.NewDateChangeMessage(view.parent.Room, fmt.Sprintf("Date changed to %s", uiMsg.FormatDate()))
-			appendBuffer(dateChange)
+		if uiMsg.ReplyState == messages.ReplyStateLoading {
+			view.resolveReply(uiMsg)
 		}
-		appendBuffer(uiMsg)
-		prev = uiMsg
-		if startIncreasingScrollOffset {
-			increaseScrollOffset = true
+		if filter != nil {
+			if filter.Match(evt) {
+				filterMatches++
+				appendMessage(uiMsg)
+			}
+			continue
+		}
+		if gap := view.parent.Room.GetGapBefore(evt.TimelineRowID); evt.TimelineRowID != 0 && gap != nil {
+			flushProfileRun()
+			appendBuffer(messages.NewGapMessage(view.parent.Room, gap, evt.Timestamp))
 		}
+		if uiMsg.IsProfileChange && membershipNoise != config.MembershipNoiseAll {
+			if membershipNoise == config.MembershipNoiseCollapse {
+				profileRun = append(profileRun, uiMsg)
+			}
+			continue
+		}
+		flushProfileRun()
+		appendMessage(uiMsg)
+	}
+	flushProfileRun()
+	if filter != nil {
+		filter.shown.Store(int32(filterMatches))
 	}
-	if scrollOffset > 0 && !increaseScrollOffset && !prevLastEventNotFound {
-		// Previous last message wasn't found, so reset scroll position
-		newScrollOffset = 0
+	newScrollOffset := scrollOffset
+	if anchor != nil {
+		var found bool
+		newScrollOffset, found = anchor.restore(newBuffer, height)
+		if !found {
+			// The message that was on screen isn't in the timeline anymore, so reset scroll position
+			newScrollOffset = 0
+		}
 	}
+	newScrollOffset = max(0, min(newScrollOffset, len(newBuffer)-height+PaddingAtTop))
 	if newScrollOffset != scrollOffset {
 		view.ScrollOffset.Store(int32(newScrollOffset))
 	}
 	view.msgBuffer = newBuffer
 	view.totalHeight.Store(uint32(len(newBuffer)))
 	view.prevTimeline = timelinePtr
+	view.prevSegments = segments
 }
@@ -0,0 +1,394 @@
+// gomuks - A terminal Matrix client written in Go.
+// Copyright (C) 2026 Tulir Asokan
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+package tui
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/gdamore/tcell/v2"
+	"maunium.net/go/mautrix/event"
+	"maunium.net/go/mautrix/id"
+
+	"go.mau.fi/gomuks/pkg/hicli/database"
+	"go.mau.fi/gomuks/pkg/hicli/jsoncmd"
+	"go.mau.fi/gomuks/pkg/rpc/store"
+	"go.mau.fi/gomuks/tui/config"
+	"go.mau.fi/gomuks/tui/messages"
+	"go.mau.fi/gomuks/tui/messages/tstring"
+)
+
+// scrollTestMessage is a message in a simulated timeline. Each word is 9 cells wide,
+// so at width 10 the message has one line per word.
+type scrollTestMessage struct {
+	rowID database.EventRowID
+	words int
+}
+
+// buildScrollTestBuffer renders the messages at the given width into a message buffer like MessageView.update does.
+func buildScrollTestBuffer(room *store.RoomStore, msgs []scrollTestMessage, width int) []*messages.UIMessage {
+	var buffer []*messages.UIMessage
+	for _, testMsg := range msgs {
+		msg := messages.NewExpandedTextMessage(
+			&database.Event{RowID: testMsg.rowID, Sender: "@alice:example.com"},
+			room,
+			tstring.NewTString(strings.TrimSpace(strings.Repeat("xxxxxxxxx ", testMsg.words))),
+		)
+		msg.CalculateBuffer(config.UserPreferences{}, width)
+		for i := 0; i < msg.Height(); i++ {
+			buffer = append(buffer, msg)
+		}
+	}
+	return buffer
+}
+
+func scrollTestRange(from, to database.EventRowID, words int) []scrollTestMessage {
+	var msgs []scrollTestMessage
+	for rowID := from; rowID <= to; rowID++ {
+		msgs = append(msgs, scrollTestMessage{rowID: rowID, words: words})
+	}
+	return msgs
+}
+
+// anchorScreenLine returns the row in the viewport where the given line of the anchored message is drawn.
+func anchorScreenLine(buffer []*messages.UIMessage, anchor *scrollAnchor, scrollOffset, height int) int {
+	viewTop := len(buffer) - scrollOffset - height
+	for i, msg := range buffer {
+		if msg.RowID == anchor.rowID {
+			return i + min(anchor.lineInMessage, msg.Height()-1) - viewTop
+		}
+	}
+	return -1
+}
+
+func TestScrollAnchor(t *testing.T) {
+	const height = 10
+	tests := []struct {
+		name         string
+		before       []scrollTestMessage
+		after        []scrollTestMessage
+		scrollOffset int
+		widthBefore  int
+		widthAfter   int
+		wantRowID    database.EventRowID
+		wantLine     int
+	}{
+		{
+			name:         "pagination",
+			before:       scrollTestRange(10, 20, 3),
+			after:        scrollTestRange(1, 20, 3),
+			scrollOffset: 7,
+			widthBefore:  10, widthAfter: 10,
+			wantRowID: 16,
+		},
+		{
+			name:         "pagination and sync at the same time",
+			before:       scrollTestRange(10, 20, 3),
+			after:        scrollTestRange(1, 25, 3),
+			scrollOffset: 7,
+			widthBefore:  10, widthAfter: 10,
+			wantRowID: 16,
+		},
+		{
+			name:         "width change",
+			before:       scrollTestRange(10, 20, 4),
+			after:        scrollTestRange(10, 20, 4),
+			scrollOffset: 9,
+			widthBefore:  10, widthAfter: 20,
+			wantRowID: 17,
+		},
+		{
+			name:         "pagination, sync and width change",
+			before:       scrollTestRange(10, 20, 4),
+			after:        scrollTestRange(1, 30, 4),
+			scrollOffset: 9,
+			widthBefore:  10, widthAfter: 20,
+			wantRowID: 17,
+		},
+		{
+			name:   "anchored message grew",
+			before: scrollTestRange(10, 20, 2),
+			after: append(append(scrollTestRange(10, 13, 2),
+				scrollTestMessage{rowID: 14, words: 6}), scrollTestRange(15, 20, 2)...),
+			scrollOffset: 4,
+			widthBefore:  10, widthAfter: 10,
+			wantRowID: 14,
+		},
+		{
+			name:         "message taller than the viewport",
+			before:       []scrollTestMessage{{rowID: 1, words: 30}, {rowID: 2, words: 1}},
+			after:        []scrollTestMessage{{rowID: 0, words: 5}, {rowID: 1, words: 30}, {rowID: 2, words: 1}, {rowID: 3, words: 2}},
+			scrollOffset: 6,
+			widthBefore:  10, widthAfter: 10,
+			wantRowID: 1,
+			wantLine:  15,
+		},
+		{
+			name:         "partially visible message shrunk",
+			before:       []scrollTestMessage{{rowID: 1, words: 30}, {rowID: 2, words: 1}},
+			after:        []scrollTestMessage{{rowID: 1, words: 8}, {rowID: 2, words: 1}},
+			scrollOffset: 6,
+			widthBefore:  10, widthAfter: 10,
+			wantRowID: 1,
+			wantLine:  15,
+		},
+	}
+	room := store.NewRoomStore(store.NewStore(), &database.Room{ID: "!room:example.com"})
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			before := buildScrollTestBuffer(room, test.before, test.widthBefore)
+			anchor := findScrollAnchor(before, test.scrollOffset, height)
+			if anchor == nil {
+				t.Fatal("No anchor found")
+			} else if anchor.rowID != test.wantRowID || anchor.lineInMessage != test.wantLine {
+				t.Fatalf("Anchor is line %d of row %d, want line %d of row %d", anchor.lineInMessage, anchor.rowID, test.wantLine, test.wantRowID)
+			}
+			after := buildScrollTestBuffer(room, test.after, test.widthAfter)
+			newScrollOffset, found := anchor.restore(after, height)
+			if !found {
+				t.Fatal("Anchor wasn't found after rebuilding")
+			}
+			if line := anchorScreenLine(after, anchor, newScrollOffset, height); line != anchor.screenLine {
+				t.Errorf("Anchor moved from screen line %d to %d (scroll offset %d → %d)", anchor.screenLine, line, test.scrollOffset, newScrollOffset)
+			}
+		})
+	}
+}
+
+func TestScrollAnchor_Removed(t *testing.T) {
+	room := store.NewRoomStore(store.NewStore(), &database.Room{ID: "!room:example.com"})
+	before := buildScrollTestBuffer(room, scrollTestRange(1, 10, 2), 10)
+	anchor := findScrollAnchor(before, 4, 5)
+	if anchor == nil {
+		t.Fatal("No anchor found")
+	}
+	after := buildScrollTestBuffer(room, scrollTestRange(anchor.rowID+1, 20, 2), 10)
+	if _, found := anchor.restore(after, 5); found {
+		t.Error("Expected anchor to not be found after the message was removed")
+	}
+}
+
+func TestFindScrollAnchor_SkipsServiceMessages(t *testing.T) {
+	room := store.NewRoomStore(store.NewStore(), &database.Room{ID: "!room:example.com"})
+	buffer := buildScrollTestBuffer(room, scrollTestRange(1, 5, 1), 10)
+	service := messages.NewServiceMessage("Loading more messages...")
+	service.CalculateBuffer(config.UserPreferences{}, 10)
+	buffer = append([]*messages.UIMessage{service}, buffer...)
+	if anchor := findScrollAnchor(buffer, 1, 5); anchor == nil || anchor.rowID != 1 {
+		t.Errorf("Expected the first real message to be the anchor, got %+v", anchor)
+	}
+}
+
+// renderDeliveryIndicator draws the delivery indicator of the current version of the event
+// and returns the drawn text and its color.
+func renderDeliveryIndicator(t *testing.T, room *store.RoomStore, rowID database.EventRowID) (string, tcell.Color) {
+	t.Helper()
+	evt := room.GetEventByRowID(rowID)
+	if evt == nil {
+		t.Fatalf("Event %d not found in room store", rowID)
+	}
+	msg := messages.ParseEvent(nil, &config.UserPreferences{DisableDownloads: true}, room, evt)
+	screen := tcell.NewSimulationScreen("")
+	if err := screen.Init(); err != nil {
+		t.Fatalf("Failed to initialize screen: %v", err)
+	}
+	defer screen.Fini()
+	screen.SetSize(DeliveryIndicatorWidth, 1)
+	drawDeliveryIndicator(screen, msg, 0, 0)
+	screen.Show()
+	cells, _, _ := screen.GetContents()
+	var text strings.Builder
+	color := tcell.ColorDefault
+	for _, cell := range cells {
+		if len(cell.Runes) == 0 || cell.Runes[0] == ' ' {
+			continue
+		}
+		text.WriteString(string(cell.Runes))
+		color, _, _ = cell.Style.Decompose()
+	}
+	return text.String(), color
+}
+
+func TestDeliveryIndicator(t *testing.T) {
+	const me id.UserID = "@alice:example.com"
+	const roomID id.RoomID = "!room:example.com"
+	const rowID database.EventRowID = 5
+	newEvent := func(evtID id.EventID) *database.Event {
+		return &database.Event{
+			RowID:         rowID,
+			RoomID:        roomID,
+			ID:            evtID,
+			TransactionID: "txn1",
+			Sender:        me,
+			Type:          event.EventMessage.Type,
+			Content:       json.RawMessage(`{"msgtype":"m.text","body":"hello"}`),
+		}
+	}
+	newRoom := func() *store.RoomStore {
+		gs := store.NewStore()
+		gs.UserID = me
+		room := store.NewRoomStore(gs, &database.Room{ID: roomID})
+		room.ApplyPending(newEvent("~txn1"))
+		return room
+	}
+	type step struct {
+		name      string
+		apply     func(room *store.RoomStore)
+		wantText  string
+		wantColor tcell.Color
+	}
+	pending := step{"pending", func(*store.RoomStore) {}, "⧗", tcell.ColorGray}
+	tests := []struct {
+		name  string
+		steps []step
+	}{
+		{"pending→sent→read", []step{
+			pending,
+			{"queued", func(room *store.RoomStore) {
+				room.ApplySendQueueUpdate(&jsoncmd.SendQueueUpdate{Positions: map[string]int{"txn1": 2}})
+			}, "⧗", tcell.ColorGray},
+			{"sent", func(room *store.RoomStore) {
+				room.ApplySendComplete(newEvent("$evt1"))
+			}, "✓", tcell.ColorDefault},
+			{"remote echo", func(room *store.RoomStore) {
+				evt := newEvent("$evt1")
+				evt.TimelineRowID = 10
+				room.ApplySync(&jsoncmd.SyncRoom{
+					Meta:     &database.Room{ID: roomID},
+					Events:   []*database.Event{evt},
+					Timeline: []database.TimelineRowTuple{{Timeline: 10, Event: rowID}},
+					Receipts: map[id.EventID][]*database.Receipt{"$evt1": {
+						{UserID: me, ReceiptType: event.ReceiptTypeRead, EventID: "$evt1"},
+					}},
+				})
+			}, "✓", tcell.ColorDefault},
+			{"read", func(room *store.RoomStore) {
+				room.ApplySync(&jsoncmd.SyncRoom{
+					Meta: &database.Room{ID: roomID},
+					Receipts: map[id.EventID][]*database.Receipt{"$evt1": {
+						{UserID: "@bob:example.com", ReceiptType: event.ReceiptTypeRead, EventID: "$evt1"},
+					}},
+				})
+			}, "✓✓", tcell.ColorGreen},
+		}},
+		{"pending→failed", []step{
+			pending,
+			{"failed", func(room *store.RoomStore) {
+				evt := newEvent("~txn1")
+				evt.SendError = "M_FORBIDDEN"
+				room.ApplySendComplete(evt)
+			}, "", tcell.ColorDefault},
+		}},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			room := newRoom()
+			for _, step := range test.steps {
+				step.apply(room)
+				text, color := renderDeliveryIndicator(t, room, rowID)
+				if text != step.wantText || color != step.wantColor {
+					t.Errorf("Indicator after %s is %q (%v), want %q (%v)", step.name, text, color, step.wantText, step.wantColor)
+				}
+			}
+		})
+	}
+}
+
+// newBanTestRoom creates a room where @bob:example.com was banned by a moderator with redact_events set.
+func newBanTestRoom() *store.RoomStore {
+	const roomID id.RoomID = "!room:example.com"
+	room := store.NewRoomStore(store.NewStore(), &database.Room{ID: roomID})
+	for i, state := range []struct {
+		evtType  event.Type
+		stateKey string
+		content  string
+	}{
+		{event.StateCreate, "", `{"room_version":"11"}`},
+		{event.StatePowerLevels, "", `{"users":{"@mod:example.com":100}}`},
+		{event.StateMember, "@mod:example.com", `{"membership":"join","displayname":"Mod"}`},
+		{event.StateMember, "@bob:example.com", `{"membership":"ban","org.matrix.msc4293.redact_events":true}`},
+	} {
+		room.ApplyState(&database.Event{
+			RowID:    database.EventRowID(100 + i),
+			RoomID:   roomID,
+			ID:       id.EventID(fmt.Sprintf("$state%d", i)),
+			Sender:   "@mod:example.com",
+			Type:     state.evtType.Type,
+			StateKey: &state.stateKey,
+			Content:  json.RawMessage(state.content),
+		})
+	}
+	return room
+}
+
+func TestMessageView_ToggleBanRemoved(t *testing.T) {
+	room := newBanTestRoom()
+	evt := &database.Event{
+		RowID:   1,
+		RoomID:  room.ID,
+		ID:      "$spam",
+		Sender:  "@bob:example.com",
+		Type:    event.EventMessage.Type,
+		Content: json.RawMessage(`{"msgtype":"m.text","body":"spam"}`),
+	}
+	room.ApplyFetchedEvent(evt)
+	cfg := &config.Config{}
+	newView := func() *MessageView {
+		return NewMessageView(&RoomView{Room: room, config: cfg, parent: &MainView{}})
+	}
+
+	view := newView()
+	banEvt := view.removingBan(evt)
+	if banEvt == nil {
+		t.Fatal("Message from banned user isn't removed")
+	}
+	removed := messages.NewBanRemovedMessage(evt, room, banEvt)
+	evt.RenderMeta = removed
+	view.ToggleBanRemoved(removed)
+	if view.removingBan(evt) != nil {
+		t.Error("Message is still removed after revealing it")
+	} else if evt.RenderMeta != nil {
+		t.Error("Revealing didn't clear the cached placeholder")
+	}
+
+	revealed := messages.ParseEvent(nil, &cfg.Preferences, room, evt)
+	evt.RenderMeta = revealed
+	view.ToggleBanRemoved(revealed)
+	if view.removingBan(evt) == nil {
+		t.Error("Message isn't removed again after hiding it")
+	} else if evt.RenderMeta != nil {
+		t.Error("Hiding didn't clear the cached message")
+	}
+
+	// Toggling a message that isn't removed does nothing
+	evt.RenderMeta = revealed
+	view.ToggleBanRemoved(revealed)
+	if view.removingBan(evt) == nil || evt.RenderMeta != revealed {
+		t.Error("Toggling a message that wasn't removed changed it")
+	}
+
+	view.ToggleBanRemoved(removed)
+	if newView().removingBan(evt) == nil {
+		t.Error("Revealed message stayed revealed in a new message view")
+	}
+	cfg.Preferences.RevealBanRemoved = true
+	if newView().removingBan(evt) != nil {
+		t.Error("Message is removed even though reveal_ban_removed is enabled")
+	}
+}
@@ -20,15 +20,18 @@ import (
 	"fmt"
 	"maps"
 	"slices"
+	"strings"
 	"time"
 
 	"github.com/gdamore/tcell/v2"
 	"go.mau.fi/mauview"
 	"maunium.net/go/mautrix/event"
+	"maunium.net/go/mautrix/id"
 
 	"go.mau.fi/gomuks/pkg/hicli/database"
 	"go.mau.fi/gomuks/pkg/rpc/store"
 	"go.mau.fi/gomuks/tui/config"
+	"go.mau.fi/gomuks/tui/messages/html"
 	"go.mau.fi/gomuks/tui/widget"
 )
 
@@ -72,11 +75,37 @@ type UIMessage struct {
 	OverrideSenderName string
 	DefaultSenderColor tcell.Color
 	IsService          bool
+	IsGap              bool
 	IsSelected         bool
+	RevealSpoilers     bool
+	IsContinuation     bool
 	ReplyTo            *UIMessage
 	IsReplyBubble      bool
+	ProfileMode        string
+	EmojiDisplay       string
 	Renderer           MessageRenderer
 	bufferedWidth      int
+
+	// ReplyToID is the ID of the replied-to event, and ReplyState is set if it can't be rendered in ReplyTo.
+	ReplyToID  id.EventID
+	ReplyState ReplyState
+
+	// IsProfileChange is set for member events that only change the display name or avatar.
+	IsProfileChange bool
+	// ProfileChangeRun is the run of consecutive profile changes that this message belongs to,
+	// or that this message stands in for if it's a collapsed group. It's only set for runs of
+	// more than one message when the membership noise preference is set to collapse.
+	ProfileChangeRun []*UIMessage
+	// ExpandProfileChanges is set on the first message of a run of profile changes
+	// when the run should be shown in full instead of as a single collapsed line.
+	ExpandProfileChanges bool
+
+	// BanRemoved is set if this is a placeholder for a message removed by a ban (see GetRemovingBan).
+	BanRemoved bool
+
+	// TimestampAnomaly is set by ApplyTimelineOrder if the timestamp doesn't match the timeline order.
+	TimestampAnomaly TimestampAnomaly
+	displayTime      time.Time
 }
 
 func (msg *UIMessage) GetEvent() *database.Event {
@@ -115,12 +144,15 @@ func newUIMessage(
 // GetSenderName gets the string that should be displayed as the sender of this message.
 //
 // If the message is being sent, the sender is "Sending...".
+// If the message is waiting for earlier messages to be sent, the sender is "Queued (N)".
 // If sending has failed, the sender is "Error".
 // If the message is an emote, the sender is blank.
 // In any other case, the sender is the display name of the user who sent the message.
 func (msg *UIMessage) GetSenderName() string {
 	if msg.Event.SendError != "" && msg.Event.SendError != "not sent" {
 		return "Error"
+	} else if msg.Event.Pending && msg.Event.QueuePosition > 0 {
+		return fmt.Sprintf("Queued (%d)", msg.Event.QueuePosition)
 	} else if msg.Event.Pending {
 		return "Sending..."
 	}
@@ -136,10 +168,49 @@ func (msg *UIMessage) GetSenderName() string {
 func (msg *UIMessage) GetRawSenderName() string {
 	if msg.OverrideSenderName != "" {
 		return msg.OverrideSenderName
-	} else if msgContent, ok := msg.GetMautrixContent().Parsed.(*event.MessageEventContent); ok && msgContent.BeeperPerMessageProfile != nil && msgContent.BeeperPerMessageProfile.Displayname != "" {
-		return msgContent.BeeperPerMessageProfile.Displayname
 	}
-	return msg.Room.GetDisplayname(msg.Sender)
+	return SenderDisplayName(msg.ProfileMode, msg.Room, msg.Event)
+}
+
+// GetPerMessageProfile returns the MSC4144 per-message profile of the given event, if it has one with a name.
+func GetPerMessageProfile(evt *database.Event) *event.BeeperPerMessageProfile {
+	if evt == nil {
+		return nil
+	}
+	msgContent, ok := evt.GetMautrixContent().Parsed.(*event.MessageEventContent)
+	if !ok || msgContent.BeeperPerMessageProfile == nil || msgContent.BeeperPerMessageProfile.Displayname == "" {
+		return nil
+	}
+	return msgContent.BeeperPerMessageProfile
+}
+
+// SenderDisplayName returns the name to show for the sender of the given event. Depending on the mode,
+// the per-message profile name is used as-is, ignored, or annotated with the real Matrix sender.
+func SenderDisplayName(mode string, room *store.RoomStore, evt *database.Event) string {
+	if profile := GetPerMessageProfile(evt); profile != nil {
+		switch mode {
+		case config.PerMessageProfilesHide:
+		case config.PerMessageProfilesAnnotate:
+			return fmt.Sprintf("%s (via %s)", profile.Displayname, evt.Sender)
+		default:
+			return profile.Displayname
+		}
+	}
+	return room.GetDisplayname(evt.Sender)
+}
+
+// SenderColorKey returns the string used for hashing the sender color of the given event. When per-message
+// profiles are shown, each profile gets its own color so that different bridged users are distinguishable.
+func SenderColorKey(mode string, evt *database.Event) string {
+	if profile := GetPerMessageProfile(evt); profile != nil && mode != config.PerMessageProfilesHide && profile.ID != "" {
+		return evt.Sender.String() + "\x00" + profile.ID
+	}
+	return evt.Sender.String()
+}
+
+func (msg *UIMessage) applyProfileMode(prefs *config.UserPreferences) {
+	msg.ProfileMode = prefs.GetPerMessageProfiles()
+	msg.DefaultSenderColor = widget.GetHashColor(SenderColorKey(msg.ProfileMode, msg.Event))
 }
 
 func (msg *UIMessage) NotificationContent() string {
@@ -202,13 +273,32 @@ func (msg *UIMessage) TextColor() tcell.Color {
 func (msg *UIMessage) TimestampColor() tcell.Color {
 	if msg.IsService {
 		return tcell.ColorGray
+	} else if msg.TimestampAnomaly == TimestampFuture {
+		return tcell.ColorYellow
 	}
 	return msg.getStateSpecificColor()
 }
 
+// DeliveryIndicator returns the marker showing whether a message sent by the current user is still pending (⧗),
+// was accepted by the server (✓) or has been read by someone else (✓✓). Failed sends have no marker,
+// as the sender name already shows the error.
+func (msg *UIMessage) DeliveryIndicator() (string, tcell.Color) {
+	if msg.IsService || msg.StateKey != nil || msg.Event.SendError != "" {
+		return "", tcell.ColorDefault
+	} else if msg.Event.QueuePosition > 0 || strings.HasPrefix(string(msg.ID), "~") {
+		return "⧗", tcell.ColorGray
+	} else if msg.Room != nil && msg.Room.IsReadByOthers(msg.Event) {
+		return "✓✓", tcell.ColorGreen
+	}
+	return "✓", tcell.ColorDefault
+}
+
 func (msg *UIMessage) ReplyHeight() int {
 	if msg.ReplyTo != nil {
 		return 1 + msg.ReplyTo.Height()
+	} else if msg.ReplyState != ReplyStateNone {
+		// Degraded reply headers are a single line without a quoted message
+		return 1
 	}
 	return 0
 }
@@ -222,7 +312,7 @@ func (msg *UIMessage) ReactionHeight() int {
 
 // Height returns the number of rows in the computed buffer (see Buffer()).
 func (msg *UIMessage) Height() int {
-	return msg.ReplyHeight() + msg.Renderer.Height() + msg.ReactionHeight()
+	return msg.TimestampNoteHeight() + msg.ReplyHeight() + msg.Renderer.Height() + msg.ReactionHeight()
 }
 
 func (msg *UIMessage) Time() time.Time {
@@ -231,23 +321,44 @@ func (msg *UIMessage) Time() time.Time {
 
 // FormatTime returns the formatted time when the message was sent.
 func (msg *UIMessage) FormatTime() string {
-	return msg.Timestamp.Format(TimeFormat)
+	return msg.DisplayTime().Format(TimeFormat)
 }
 
 // FormatDate returns the formatted date when the message was sent.
 func (msg *UIMessage) FormatDate() string {
-	return msg.Timestamp.Format(DateFormat)
+	return msg.DisplayTime().Format(DateFormat)
 }
 
 func (msg *UIMessage) SameDate(message *UIMessage) bool {
 	if message == nil {
 		return false
 	}
-	year1, month1, day1 := msg.Timestamp.Date()
-	year2, month2, day2 := message.Timestamp.Date()
+	year1, month1, day1 := msg.DisplayTime().Date()
+	year2, month2, day2 := message.DisplayTime().Date()
 	return day1 == day2 && month1 == month2 && year1 == year2
 }
 
+// CanGroupWith returns true if this message is a continuation of the given previous message,
+// i.e. both were sent by the same sender on the same day within maxGap of each other.
+func (msg *UIMessage) CanGroupWith(prev *UIMessage, maxGap time.Duration) bool {
+	if prev == nil || msg.IsService || prev.IsService || msg.IsGap || prev.IsGap || msg.Sender != prev.Sender {
+		return false
+	} else if msg.TimestampAnomaly != TimestampNormal || prev.TimestampAnomaly != TimestampNormal {
+		return false
+	}
+	senderName := msg.GetSenderName()
+	switch senderName {
+	case "", "---", "-->", "<--":
+		// State events and emotes always show the sender
+		return false
+	}
+	if senderName != prev.GetSenderName() || !msg.SameDate(prev) {
+		return false
+	}
+	diff := msg.Timestamp.Sub(prev.Timestamp.Time)
+	return diff >= 0 && diff < maxGap
+}
+
 func (msg *UIMessage) DrawReactions(screen mauview.Screen) {
 	if len(msg.Event.Reactions) == 0 || msg.IsReplyBubble {
 		return
@@ -262,7 +373,11 @@ func (msg *UIMessage) DrawReactions(screen mauview.Screen) {
 		if count == 0 {
 			continue
 		}
-		_, drawn := mauview.PrintWithStyle(screen, fmt.Sprintf("%d×%s", count, reaction), x, 0, width-x, mauview.AlignLeft, tcell.StyleDefault.Foreground(mauview.Styles.PrimaryTextColor).Background(tcell.ColorDarkGreen))
+		background := tcell.ColorDarkGreen
+		if msg.Room != nil && msg.Room.GetMyReaction(msg.ID, reaction) != "" {
+			background = tcell.ColorDarkCyan
+		}
+		_, drawn := mauview.PrintWithStyle(screen, fmt.Sprintf("%d×%s", count, html.ApplyEmojiDisplay(reaction, msg.EmojiDisplay)), x, 0, width-x, mauview.AlignLeft, tcell.StyleDefault.Foreground(mauview.Styles.PrimaryTextColor).Background(background))
 		x += drawn + 1
 		if x >= width {
 			break
@@ -270,8 +385,31 @@ func (msg *UIMessage) DrawReactions(screen mauview.Screen) {
 	}
 }
 
+// ReactionSummary returns the reactions to the message on a single line, e.g. "2×👍 1×❤️".
+func (msg *UIMessage) ReactionSummary() string {
+	if msg.Event == nil || len(msg.Event.Reactions) == 0 {
+		return ""
+	}
+	var parts []string
+	for _, reaction := range slices.Sorted(maps.Keys(msg.Event.Reactions)) {
+		if count := msg.Event.Reactions[reaction]; count > 0 {
+			parts = append(parts, fmt.Sprintf("%d×%s", count, reaction))
+		}
+	}
+	return strings.Join(parts, " ")
+}
+
+// MediaURL returns the content URI of the file in a media message.
+func (msg *UIMessage) MediaURL() (uri id.ContentURI, encrypted, ok bool) {
+	fileMsg, ok := msg.Renderer.(*FileMessage)
+	if !ok || fileMsg.URL.IsEmpty() {
+		return id.ContentURI{}, false, false
+	}
+	return fileMsg.URL, fileMsg.IsEncrypted, true
+}
+
 func (msg *UIMessage) Draw(screen mauview.Screen) {
-	proxyScreen := msg.DrawReply(screen)
+	proxyScreen := msg.DrawReply(msg.DrawTimestampNote(screen))
 	msg.Renderer.Draw(proxyScreen, msg)
 	msg.DrawReactions(proxyScreen)
 	if msg.IsSelected {
@@ -291,6 +429,7 @@ func (msg *UIMessage) Draw(screen mauview.Screen) {
 func (msg *UIMessage) Clone() *UIMessage {
 	clone := *msg
 	clone.ReplyTo = nil
+	clone.ReplyState = ReplyStateNone
 	clone.Renderer = clone.Renderer.Clone()
 	return &clone
 }
@@ -307,6 +446,7 @@ func (msg *UIMessage) CalculateBuffer(preferences config.UserPreferences, width
 	if msg.bufferedWidth == width {
 		return
 	}
+	msg.EmojiDisplay = preferences.GetEmojiDisplay()
 	msg.Renderer.CalculateBuffer(preferences, width, msg)
 	msg.calculateReplyBuffer(preferences, width)
 	msg.bufferedWidth = width
@@ -314,7 +454,7 @@ func (msg *UIMessage) CalculateBuffer(preferences config.UserPreferences, width
 
 func (msg *UIMessage) DrawReply(screen mauview.Screen) mauview.Screen {
 	if msg.ReplyTo == nil {
-		return screen
+		return msg.drawDegradedReply(screen)
 	}
 	width, height := screen.Size()
 	replyHeight := msg.ReplyTo.Height()
@@ -328,6 +468,16 @@ func (msg *UIMessage) DrawReply(screen mauview.Screen) mauview.Screen {
 	return mauview.NewProxyScreen(screen, 0, replyHeight+1, width, height-replyHeight-1)
 }
 
+func (msg *UIMessage) drawDegradedReply(screen mauview.Screen) mauview.Screen {
+	if msg.ReplyState == ReplyStateNone {
+		return screen
+	}
+	width, height := screen.Size()
+	screen.SetCell(0, 0, tcell.StyleDefault, '▊')
+	widget.WriteLine(screen, mauview.AlignLeft, msg.ReplyState.Header(), 1, 0, width-1, msg.ReplyState.headerStyle())
+	return mauview.NewProxyScreen(screen, 0, 1, width, height-1)
+}
+
 func (msg *UIMessage) String() string {
 	return fmt.Sprintf(`&messages.UIMessage{
     ID="%s", TxnID="%s",
@@ -0,0 +1,121 @@
+// gomuks - A terminal Matrix client written in Go.
+// Copyright (C) 2026 Tulir Asokan
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+package messages
+
+import (
+	"encoding/json"
+	"testing"
+
+	"maunium.net/go/mautrix/event"
+	"maunium.net/go/mautrix/id"
+
+	"go.mau.fi/gomuks/pkg/hicli/database"
+	"go.mau.fi/gomuks/tui/config"
+	"go.mau.fi/gomuks/tui/widget"
+)
+
+const testBridgeBot id.UserID = "@bridge:example.com"
+
+func newTestMessage(rowID database.EventRowID, sender id.UserID, content string) *database.Event {
+	return &database.Event{
+		RowID:   rowID,
+		RoomID:  testRoomID,
+		ID:      id.EventID("$msg" + string(rune('a'+rowID))),
+		Sender:  sender,
+		Type:    event.EventMessage.Type,
+		Content: json.RawMessage(content),
+	}
+}
+
+func TestPerMessageProfiles(t *testing.T) {
+	const profileContent = `{"msgtype":"m.text","body":"hi","com.beeper.per_message_profile":{"id":"user1","displayname":"Bridged User"}}`
+	plainColor := widget.GetHashColor(testBridgeBot)
+	profileColor := widget.GetHashColor(testBridgeBot.String() + "\x00user1")
+	tests := []struct {
+		mode      string
+		content   string
+		wantName  string
+		wantColor bool
+	}{
+		{"", profileContent, "Bridged User", true},
+		{config.PerMessageProfilesShow, profileContent, "Bridged User", true},
+		{config.PerMessageProfilesHide, profileContent, "bridge", false},
+		{config.PerMessageProfilesAnnotate, profileContent, "Bridged User (via @bridge:example.com)", true},
+		{"invalid", profileContent, "Bridged User", true},
+		{config.PerMessageProfilesAnnotate, `{"msgtype":"m.text","body":"hi"}`, "bridge", false},
+		{
+			config.PerMessageProfilesShow,
+			`{"msgtype":"m.text","body":"hi","com.beeper.per_message_profile":{"id":"user1"}}`,
+			"bridge", false,
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.mode+" "+test.wantName, func(t *testing.T) {
+			prefs := &config.UserPreferences{PerMessageProfiles: test.mode, DisableDownloads: true}
+			room := newTestRoom()
+			evt := newTestMessage(10, testBridgeBot, test.content)
+			msg := ParseEvent(nil, prefs, room, evt)
+			if msg == nil {
+				t.Fatal("ParseEvent() returned nil")
+			}
+			if got := msg.GetRawSenderName(); got != test.wantName {
+				t.Errorf("GetRawSenderName() = %q, want %q", got, test.wantName)
+			}
+			if got := SenderDisplayName(prefs.GetPerMessageProfiles(), room, evt); got != test.wantName {
+				t.Errorf("SenderDisplayName() = %q, want %q", got, test.wantName)
+			}
+			wantColor := plainColor
+			if test.wantColor {
+				wantColor = profileColor
+			}
+			if msg.DefaultSenderColor != wantColor {
+				t.Errorf("DefaultSenderColor = %v, want %v", msg.DefaultSenderColor, wantColor)
+			}
+		})
+	}
+}
+
+func TestPerMessageProfiles_ReplyHeader(t *testing.T) {
+	for _, test := range []struct {
+		mode     string
+		wantName string
+	}{
+		{config.PerMessageProfilesShow, "Bridged User"},
+		{config.PerMessageProfilesHide, "bridge"},
+		{config.PerMessageProfilesAnnotate, "Bridged User (via @bridge:example.com)"},
+	} {
+		t.Run(test.mode, func(t *testing.T) {
+			prefs := &config.UserPreferences{PerMessageProfiles: test.mode}
+			room := newTestRoom()
+			target := newTestMessage(10, testBridgeBot,
+				`{"msgtype":"m.text","body":"hi","com.beeper.per_message_profile":{"id":"user1","displayname":"Bridged User"}}`)
+			room.ApplyFetchedEvent(target)
+			reply := newTestMessage(11, testSender,
+				`{"msgtype":"m.text","body":"hello","m.relates_to":{"m.in_reply_to":{"event_id":"`+target.ID.String()+`"}}}`)
+			msg := ParseEvent(nil, prefs, room, reply)
+			if msg == nil || msg.ReplyTo == nil {
+				t.Fatalf("Expected reply target to be rendered, got %+v", msg)
+			}
+			if got := msg.ReplyTo.GetRawSenderName(); got != test.wantName {
+				t.Errorf("Reply header sender = %q, want %q", got, test.wantName)
+			}
+			if got := msg.GetRawSenderName(); got != "Alice" {
+				t.Errorf("Reply sender = %q, want Alice", got)
+			}
+		})
+	}
+}
@@ -24,8 +24,10 @@ import (
 	"go.mau.fi/mauview"
 	"go.mau.fi/util/jsontime"
 	"maunium.net/go/mautrix/event"
+	"maunium.net/go/mautrix/id"
 
 	"go.mau.fi/gomuks/pkg/hicli/database"
+	"go.mau.fi/gomuks/pkg/hicli/jsoncmd"
 	"go.mau.fi/gomuks/pkg/rpc/store"
 	"go.mau.fi/gomuks/tui/config"
 	"go.mau.fi/gomuks/tui/messages/tstring"
@@ -76,6 +78,84 @@ func NewDateChangeMessage(room *store.RoomStore, text string) *UIMessage {
 	}
 }
 
+// NewGapMessage creates a service message that marks missing events right before the given timeline row.
+// The timestamp should be the timestamp of the event after the gap.
+func NewGapMessage(room *store.RoomStore, gap *database.TimelineGap, ts jsontime.UnixMilli) *UIMessage {
+	text := "Missed messages — click to load"
+	if gap.MissedCount == 1 {
+		text = "1 missed message — click to load"
+	} else if gap.MissedCount > 1 {
+		text = fmt.Sprintf("%d missed messages — click to load", gap.MissedCount)
+	}
+	return &UIMessage{
+		Room: room,
+		Event: &database.Event{
+			Sender:        "*",
+			Timestamp:     ts,
+			TimelineRowID: gap.TimelineRowID,
+		},
+		OverrideSenderName: "*",
+		IsService:          true,
+		IsGap:              true,
+		Renderer: &ExpandedTextMessage{
+			Text: tstring.NewColorTString(text, tcell.ColorYellow),
+		},
+	}
+}
+
+// NewUpgradeMessage creates a service message that separates the messages of a predecessor room from the messages
+// of the room that replaced it. If the history of the predecessor couldn't be loaded, the reason is included.
+func NewUpgradeMessage(room *store.RoomStore, entry *jsoncmd.RoomLineageEntry) *UIMessage {
+	name := entry.Name
+	if name == "" {
+		name = entry.RoomID.String()
+	}
+	text := fmt.Sprintf("Room was upgraded from %s", name)
+	if !entry.UpgradedAt.IsZero() {
+		text += " on " + entry.UpgradedAt.Format(DateFormat)
+	}
+	color := tcell.ColorGreen
+	if entry.Error != "" {
+		text += fmt.Sprintf(" (older messages unavailable: %s)", entry.Error)
+		color = tcell.ColorYellow
+	}
+	return &UIMessage{
+		Room: room,
+		Event: &database.Event{
+			Sender:    "*",
+			Timestamp: entry.UpgradedAt,
+		},
+		OverrideSenderName: "*",
+		IsService:          true,
+		Renderer: &ExpandedTextMessage{
+			Text: tstring.NewColorTString(text, color),
+		},
+	}
+}
+
+// NewProfileChangeGroup creates a message that stands in for a run of consecutive profile-only membership changes.
+// The group uses the event of the first change, so selecting it selects the first change.
+func NewProfileChangeGroup(room *store.RoomStore, changes []*UIMessage) *UIMessage {
+	users := make(map[id.UserID]struct{}, len(changes))
+	for _, change := range changes {
+		users[id.UserID(*change.StateKey)] = struct{}{}
+	}
+	text := fmt.Sprintf("%d profile updates", len(changes))
+	if len(users) > 1 {
+		text += fmt.Sprintf(" from %d users", len(users))
+	}
+	return &UIMessage{
+		Room:               room,
+		Event:              changes[0].Event,
+		OverrideSenderName: "---",
+		DefaultSenderColor: tcell.ColorGreen,
+		ProfileChangeRun:   changes,
+		Renderer: &ExpandedTextMessage{
+			Text: tstring.NewColorTString(text, tcell.ColorGreen),
+		},
+	}
+}
+
 func (msg *ExpandedTextMessage) Clone() MessageRenderer {
 	return &ExpandedTextMessage{
 		Text: msg.Text.Clone(),
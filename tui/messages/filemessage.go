@@ -99,7 +99,7 @@ func (msg *FileMessage) NotificationContent() string {
 }
 
 func (msg *FileMessage) PlainText() string {
-	return fmt.Sprintf("%s: %s", msg.Body, msg.matrix.GetDownloadURL(msg.URL, msg.IsEncrypted, true))
+	return fmt.Sprintf("%s: %s", msg.Body, msg.matrix.BuildMediaURL(msg.URL, client.MediaURLOptions{Encrypted: msg.IsEncrypted}))
 }
 
 func (msg *FileMessage) String() string {
@@ -120,7 +120,7 @@ func (msg *FileMessage) DownloadPreview() {
 	//	return
 	//}
 	//debug.Print("Loading file:", url)
-	//data, err := msg.matrix.Download(url, file != nil)
+	//data, err := msg.matrix.Download(context.TODO(), url, file != nil)
 	//if err != nil {
 	//	debug.Printf("Failed to download file %s: %v", url, err)
 	//	return
@@ -140,7 +140,7 @@ func (msg *FileMessage) CalculateBuffer(prefs config.UserPreferences, width int,
 	}
 
 	if prefs.BareMessageView || prefs.DisableImages || len(msg.imageData) == 0 {
-		url := msg.matrix.GetDownloadURL(msg.URL, msg.IsEncrypted, true)
+		url := msg.matrix.BuildMediaURL(msg.URL, client.MediaURLOptions{Encrypted: msg.IsEncrypted})
 		var urlTString tstring.TString
 		if prefs.EnableInlineURLs() {
 			urlTString = tstring.NewStyleTString("Download media", tcell.StyleDefault.Url(url).UrlId(msg.eventID.String()))
@@ -0,0 +1,186 @@
+// gomuks - A terminal Matrix client written in Go.
+// Copyright (C) 2026 Tulir Asokan
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+package html
+
+import (
+	"slices"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/rivo/uniseg"
+	"golang.org/x/text/unicode/bidi"
+
+	"go.mau.fi/gomuks/tui/debug"
+)
+
+const (
+	leftToRightMark = "\u200e"
+	rightToLeftMark = "\u200f"
+)
+
+func isRTLClass(class bidi.Class) bool {
+	return class == bidi.R || class == bidi.AL
+}
+
+// isRTLParagraph checks if the first strongly directional character in the text is right-to-left
+// (rules P2 and P3 of the Unicode bidirectional algorithm).
+func isRTLParagraph(text string) bool {
+	for _, char := range text {
+		props, _ := bidi.LookupRune(char)
+		switch class := props.Class(); {
+		case class == bidi.L:
+			return false
+		case isRTLClass(class):
+			return true
+		}
+	}
+	return false
+}
+
+func containsRTL(text string) bool {
+	for _, char := range text {
+		if props, _ := bidi.LookupRune(char); isRTLClass(props.Class()) {
+			return true
+		}
+	}
+	return false
+}
+
+// runeLevels approximates the embedding level of each rune in the line based on the directional runs.
+//
+// The bidi package only reports the direction of runs, but the levels are needed to reorder runs correctly
+// when numbers appear inside right-to-left text. Explicit embeddings and isolates aren't supported.
+func runeLevels(ordering bidi.Ordering, length int, rtl bool) []int {
+	levels := make([]int, length)
+	prevRTL := false
+	for i := range ordering.NumRuns() {
+		run := ordering.Run(i)
+		start, end := run.Pos()
+		runRTL := run.Direction() == bidi.RightToLeft
+		level := 0
+		if runRTL {
+			level = 1
+		} else if rtl {
+			level = 2
+		} else if prevRTL {
+			// In left-to-right paragraphs, numbers right after right-to-left text are on level 2,
+			// while everything from the first left-to-right character is back on level 0.
+			runes := []rune(run.String())
+			numbersEnd := 0
+			for j, char := range runes {
+				props, _ := bidi.LookupRune(char)
+				if props.Class() == bidi.L {
+					break
+				} else if props.Class() == bidi.EN || props.Class() == bidi.AN {
+					numbersEnd = j + 1
+				}
+			}
+			for j := start; j < start+numbersEnd; j++ {
+				levels[j] = 2
+			}
+			start += numbersEnd
+		}
+		for j := start; j <= end; j++ {
+			levels[j] = level
+		}
+		prevRTL = runRTL
+	}
+	return levels
+}
+
+type bidiCluster struct {
+	text  string
+	level int
+}
+
+// visualOrder reorders a single line of text from logical order to the order it should be drawn in.
+// Grapheme clusters are kept intact, so combining characters stay attached to their base characters.
+// The line is returned as-is if it doesn't contain right-to-left text.
+func visualOrder(line string, rtl bool) (visual string) {
+	if !containsRTL(line) {
+		return line
+	}
+	defer func() {
+		if err := recover(); err != nil {
+			debug.Print("Panic while reordering bidi text:", err)
+			visual = line
+		}
+	}()
+	// The mark forces the paragraph direction, as the bidi package would otherwise guess it from the line.
+	mark := leftToRightMark
+	if rtl {
+		mark = rightToLeftMark
+	}
+	input := mark + line
+	var para bidi.Paragraph
+	if n, err := para.SetString(input); err != nil || n != len(input) {
+		return line
+	}
+	ordering, err := para.Order()
+	if err != nil {
+		return line
+	}
+	levels := runeLevels(ordering, utf8.RuneCountInString(input), rtl)[1:]
+
+	var clusters []bidiCluster
+	maxLevel := 0
+	runeIndex := 0
+	state := -1
+	var cluster string
+	for len(line) > 0 {
+		cluster, line, _, state = uniseg.FirstGraphemeClusterInString(line, state)
+		level := levels[runeIndex]
+		runeIndex += utf8.RuneCountInString(cluster)
+		if level%2 == 1 {
+			cluster = mirrorBrackets(cluster)
+		}
+		clusters = append(clusters, bidiCluster{text: cluster, level: level})
+		maxLevel = max(maxLevel, level)
+	}
+	// Rule L2: reverse every sequence of clusters at the given level or higher, from the highest level
+	// to the lowest odd level.
+	for level := maxLevel; level >= 1; level-- {
+		for i := 0; i < len(clusters); i++ {
+			if clusters[i].level < level {
+				continue
+			}
+			j := i
+			for j < len(clusters) && clusters[j].level >= level {
+				j++
+			}
+			slices.Reverse(clusters[i:j])
+			i = j
+		}
+	}
+	var buf strings.Builder
+	buf.Grow(len(input))
+	for _, cl := range clusters {
+		buf.WriteString(cl.text)
+	}
+	return buf.String()
+}
+
+// mirrorBrackets replaces brackets with their counterparts, as brackets in right-to-left text
+// are drawn mirrored (rule L4).
+func mirrorBrackets(cluster string) string {
+	if utf8.RuneCountInString(cluster) != 1 {
+		return cluster
+	} else if props, _ := bidi.LookupString(cluster); !props.IsBracket() {
+		return cluster
+	}
+	return bidi.ReverseString(cluster)
+}
@@ -17,13 +17,35 @@
 package html
 
 import (
+	"strings"
+
+	"github.com/alecthomas/chroma/v2"
+	"github.com/alecthomas/chroma/v2/lexers"
+	"github.com/alecthomas/chroma/v2/styles"
 	"github.com/gdamore/tcell/v2"
+	"github.com/mattn/go-runewidth"
 	"go.mau.fi/mauview"
 )
 
+// CodeBlockTruncationIndicator is drawn in the last column of code block lines that don't fit.
+const CodeBlockTruncationIndicator = '…'
+
 type CodeBlockEntity struct {
 	*ContainerEntity
 	Background tcell.Style
+
+	// The source code in the block. If set, the children are generated lazily on the first render.
+	Source string
+	// The language to highlight the source as. If empty, the language is guessed from the source.
+	Language string
+	// The chroma style to highlight with. If empty, syntax highlighting is disabled.
+	StyleName string
+	// Whether long lines should be wrapped instead of truncated.
+	Wrap bool
+
+	highlighted bool
+	lineWidths  []int
+	truncated   bool
 }
 
 func NewCodeBlockEntity(children []Entity, background tcell.Style) *CodeBlockEntity {
@@ -35,24 +57,164 @@ func NewCodeBlockEntity(children []Entity, background tcell.Style) *CodeBlockEnt
 			},
 			Children: children,
 		},
-		Background: background,
+		Background:  background,
+		highlighted: true,
+		Wrap:        true,
+	}
+}
+
+// NewSourceCodeBlockEntity creates a code block that is syntax highlighted when it's first rendered.
+func NewSourceCodeBlockEntity(source, language, styleName string, wrap bool) *CodeBlockEntity {
+	ce := NewCodeBlockEntity(nil, tcell.StyleDefault)
+	ce.Source = source
+	ce.Language = language
+	ce.StyleName = styleName
+	ce.Wrap = wrap
+	ce.highlighted = false
+	lines := strings.Split(strings.TrimSuffix(source, "\n"), "\n")
+	ce.lineWidths = make([]int, len(lines))
+	for i, line := range lines {
+		ce.lineWidths[i] = runewidth.StringWidth(line)
 	}
+	return ce
 }
 
 func (ce *CodeBlockEntity) Clone() Entity {
 	return &CodeBlockEntity{
 		ContainerEntity: ce.ContainerEntity.Clone().(*ContainerEntity),
 		Background:      ce.Background,
+		Source:          ce.Source,
+		Language:        ce.Language,
+		StyleName:       ce.StyleName,
+		Wrap:            ce.Wrap,
+		highlighted:     ce.highlighted,
+		lineWidths:      ce.lineWidths,
 	}
 }
 
+func colourToColor(colour chroma.Colour) tcell.Color {
+	if !colour.IsSet() {
+		return tcell.ColorDefault
+	}
+	// tcell maps RGB colors to the closest palette color if the terminal doesn't support truecolor.
+	return tcell.NewRGBColor(int32(colour.Red()), int32(colour.Green()), int32(colour.Blue()))
+}
+
+func styleEntryToStyle(se chroma.StyleEntry) tcell.Style {
+	return tcell.StyleDefault.
+		Bold(se.Bold == chroma.Yes).
+		Italic(se.Italic == chroma.Yes).
+		Underline(se.Underline == chroma.Yes).
+		Foreground(colourToColor(se.Colour)).
+		Background(colourToColor(se.Background))
+}
+
+func tokenToTextEntity(style *chroma.Style, token *chroma.Token) *TextEntity {
+	return &TextEntity{
+		BaseEntity: &BaseEntity{
+			Tag:           token.Type.String(),
+			Style:         styleEntryToStyle(style.Get(token.Type)),
+			DefaultHeight: 1,
+		},
+		Text: token.Value,
+	}
+}
+
+func getLexer(source, language string) chroma.Lexer {
+	var lexer chroma.Lexer
+	if language != "" {
+		lexer = lexers.Get(strings.ToLower(language))
+	} else {
+		lexer = lexers.Analyse(source)
+	}
+	if lexer == nil {
+		lexer = lexers.Fallback
+	}
+	return chroma.Coalesce(lexer)
+}
+
+func (ce *CodeBlockEntity) highlight() {
+	if ce.highlighted {
+		return
+	}
+	ce.highlighted = true
+	if ce.StyleName == "" {
+		ce.Children = textToHTMLEntities(ce.Source)
+		return
+	}
+	iter, err := getLexer(ce.Source, ce.Language).Tokenise(nil, ce.Source)
+	if err != nil {
+		ce.Children = textToHTMLEntities(ce.Source)
+		return
+	}
+	style := styles.Get(ce.StyleName)
+	ce.Background = styleEntryToStyle(style.Get(chroma.Background))
+
+	var children []Entity
+	for _, token := range iter.Tokens() {
+		lines := strings.SplitAfter(token.Value, "\n")
+		for _, line := range lines {
+			lineLen := len(line)
+			if lineLen == 0 {
+				continue
+			}
+			t := token.Clone()
+
+			if line[lineLen-1:] == "\n" {
+				t.Value = line[:lineLen-1]
+				children = append(children, tokenToTextEntity(style, &t), NewBreakEntity())
+			} else {
+				t.Value = line
+				children = append(children, tokenToTextEntity(style, &t))
+			}
+		}
+	}
+	ce.Children = children
+}
+
+// CalculateBuffer highlights the code if it hasn't been highlighted yet and prepares the children
+// for rendering. If wrapping is disabled, the children are laid out at their natural width
+// and cut off when drawing.
+func (ce *CodeBlockEntity) CalculateBuffer(width, startX int, ctx DrawContext) int {
+	ce.highlight()
+	ce.truncated = false
+	if !ce.Wrap {
+		maxLineWidth := 0
+		for _, lineWidth := range ce.lineWidths {
+			maxLineWidth = max(maxLineWidth, lineWidth)
+		}
+		if maxLineWidth > width {
+			ce.truncated = true
+			width = maxLineWidth + 1
+		}
+	}
+	return ce.ContainerEntity.CalculateBuffer(width, startX, ctx)
+}
+
 func (ce *CodeBlockEntity) Draw(screen mauview.Screen, ctx DrawContext) {
 	screen.Fill(' ', ce.Background)
 	ce.ContainerEntity.Draw(screen, ctx)
+	if !ce.truncated {
+		return
+	}
+	width, _ := screen.Size()
+	for y, lineWidth := range ce.lineWidths {
+		if lineWidth > width {
+			screen.SetContent(width-1, y, CodeBlockTruncationIndicator, nil, ce.Background.Foreground(tcell.ColorGray))
+		}
+	}
+}
+
+func (ce *CodeBlockEntity) PlainText() string {
+	if !ce.highlighted {
+		return ce.Source
+	}
+	return ce.ContainerEntity.PlainText()
 }
 
 func (ce *CodeBlockEntity) AdjustStyle(fn AdjustStyleFunc, reason AdjustStyleReason) Entity {
 	if reason != AdjustStyleReasonNormal {
+		ce.highlight()
 		ce.ContainerEntity.AdjustStyle(fn, reason)
 	}
 	return ce
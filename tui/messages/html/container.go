@@ -104,20 +104,42 @@ func (ce *ContainerEntity) Draw(screen mauview.Screen, ctx DrawContext) {
 	}
 	width, _ := screen.Size()
 	prevBreak := false
+	started := false
 	proxyScreen := &mauview.ProxyScreen{Parent: screen, OffsetX: ce.Indent, Width: width - ce.Indent, Style: ce.Style}
-	for i, entity := range ce.Children {
-		if i != 0 && entity.getStartX() == 0 {
-			proxyScreen.OffsetY++
-		}
-		proxyScreen.Height = entity.Height()
-		entity.Draw(proxyScreen, ctx)
-		proxyScreen.SetStyle(ce.Style)
-		proxyScreen.OffsetY += entity.Height() - 1
+	for _, entity := range ce.Children {
 		_, isBreak := entity.(*BreakEntity)
 		if prevBreak && isBreak {
 			proxyScreen.OffsetY++
 		}
 		prevBreak = isBreak
+		// Entities without any lines (e.g. breaks and empty text) don't take any space,
+		// so they must not move the offset in either direction.
+		height := entity.Height()
+		if height <= 0 {
+			continue
+		}
+		if started && entity.getStartX() == 0 {
+			proxyScreen.OffsetY++
+		}
+		started = true
+		proxyScreen.Height = height
+		entity.Draw(proxyScreen, ctx)
+		proxyScreen.SetStyle(ce.Style)
+		proxyScreen.OffsetY += height - 1
+	}
+}
+
+// rightAligner is implemented by entities that right-align their lines, i.e. right-to-left paragraphs.
+type rightAligner interface {
+	disableRightAlign()
+}
+
+// disableRightAlign disables right-alignment of the last child, as something continues on the same line.
+func (ce *ContainerEntity) disableRightAlign() {
+	if len(ce.Children) == 0 {
+		return
+	} else if ra, ok := ce.Children[len(ce.Children)-1].(rightAligner); ok {
+		ra.disableRightAlign()
 	}
 }
 
@@ -128,17 +150,26 @@ func (ce *ContainerEntity) CalculateBuffer(width, startX int, ctx DrawContext) i
 		ce.height = 0
 		childStartX := ce.startX
 		prevBreak := false
+		var prevEntity Entity
 		for _, entity := range ce.Children {
-			if entity.IsBlock() || childStartX == 0 || ce.height == 0 {
-				ce.height++
-			}
-			childStartX = entity.CalculateBuffer(width-ce.Indent, childStartX, ctx)
-			ce.height += entity.Height() - 1
 			_, isBreak := entity.(*BreakEntity)
 			if prevBreak && isBreak {
 				ce.height++
 			}
 			prevBreak = isBreak
+			newLine := entity.IsBlock() || childStartX == 0 || ce.height == 0
+			childStartX = entity.CalculateBuffer(width-ce.Indent, childStartX, ctx)
+			// Keep this in sync with Draw: entities without any lines don't take any space
+			if entity.Height() <= 0 {
+				continue
+			}
+			if newLine {
+				ce.height++
+			} else if ra, ok := prevEntity.(rightAligner); ok {
+				ra.disableRightAlign()
+			}
+			ce.height += entity.Height() - 1
+			prevEntity = entity
 		}
 		if !ce.Block {
 			return childStartX
@@ -32,8 +32,14 @@ const (
 )
 
 type DrawContext struct {
-	IsSelected   bool
-	BareMessages bool
+	IsSelected     bool
+	BareMessages   bool
+	RevealSpoilers bool
+	// EmojiDisplay is the emoji display mode from the user preferences (see ApplyEmojiDisplay).
+	EmojiDisplay string
+
+	// maskSpoilers is set by SpoilerEntity while drawing its children if spoilers aren't revealed.
+	maskSpoilers bool
 }
 
 type Entity interface {
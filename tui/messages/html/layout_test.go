@@ -0,0 +1,220 @@
+// gomuks - A terminal Matrix client written in Go.
+// Copyright (C) 2026 Tulir Asokan
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+package html
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+	"unicode/utf8"
+
+	"github.com/gdamore/tcell/v2"
+	"github.com/mattn/go-runewidth"
+	"github.com/rivo/uniseg"
+	"go.mau.fi/mauview"
+
+	"go.mau.fi/gomuks/pkg/hicli/database"
+	"go.mau.fi/gomuks/pkg/rpc/store"
+	"go.mau.fi/gomuks/tui/config"
+)
+
+// recordingScreen is a mauview.Screen of a fixed size that records every cell written to it.
+// Unlike mauview.ProxyScreen, it doesn't clip writes, so that entities drawing outside their area can be detected.
+type recordingScreen struct {
+	width, height int
+	cells         [][]rune
+	outside       []string
+}
+
+var _ mauview.Screen = (*recordingScreen)(nil)
+
+func newRecordingScreen(width, height int) *recordingScreen {
+	cells := make([][]rune, height)
+	for y := range cells {
+		cells[y] = []rune(strings.Repeat(" ", width))
+	}
+	return &recordingScreen{width: width, height: height, cells: cells}
+}
+
+func (rs *recordingScreen) record(x, y int, mainc rune) {
+	if x < 0 || y < 0 || x >= rs.width || y >= rs.height {
+		rs.outside = append(rs.outside, fmt.Sprintf("%q at %d,%d", mainc, x, y))
+	} else if mainc != 0 {
+		rs.cells[y][x] = mainc
+	}
+}
+
+// Lines returns the drawn content of the screen with trailing spaces removed from each line.
+func (rs *recordingScreen) Lines() []string {
+	lines := make([]string, len(rs.cells))
+	for y, line := range rs.cells {
+		lines[y] = strings.TrimRight(string(line), " ")
+	}
+	return lines
+}
+
+func (rs *recordingScreen) Clear()                     {}
+func (rs *recordingScreen) Fill(rune, tcell.Style)     {}
+func (rs *recordingScreen) SetStyle(tcell.Style)       {}
+func (rs *recordingScreen) ShowCursor(int, int)        {}
+func (rs *recordingScreen) HideCursor()                {}
+func (rs *recordingScreen) Size() (int, int)           { return rs.width, rs.height }
+func (rs *recordingScreen) Colors() int                { return 256 }
+func (rs *recordingScreen) CharacterSet() string       { return "UTF-8" }
+func (rs *recordingScreen) CanDisplay(rune, bool) bool { return true }
+func (rs *recordingScreen) HasKey(tcell.Key) bool      { return true }
+
+func (rs *recordingScreen) SetCell(x, y int, _ tcell.Style, ch ...rune) {
+	var mainc rune
+	if len(ch) > 0 {
+		mainc = ch[0]
+	}
+	rs.record(x, y, mainc)
+}
+
+func (rs *recordingScreen) GetContent(x, y int) (rune, []rune, tcell.Style, int) {
+	if x < 0 || y < 0 || x >= rs.width || y >= rs.height {
+		return ' ', nil, tcell.StyleDefault, 1
+	}
+	return rs.cells[y][x], nil, tcell.StyleDefault, 1
+}
+
+func (rs *recordingScreen) SetContent(x, y int, mainc rune, _ []rune, _ tcell.Style) {
+	rs.record(x, y, mainc)
+}
+
+// checkLayout calculates the buffer of the entity at the given width, draws it onto a screen of the reported height
+// and checks that nothing was drawn outside the screen. The screen is returned for checking the drawn content.
+func checkLayout(t *testing.T, entity Entity, width int, ctx DrawContext) *recordingScreen {
+	t.Helper()
+	entity.CalculateBuffer(width, 0, ctx)
+	height := entity.Height()
+	if height < 0 {
+		t.Fatalf("entity reported negative height %d", height)
+	}
+	screen := newRecordingScreen(width, height)
+	entity.Draw(screen, ctx)
+	if len(screen.outside) > 0 {
+		t.Fatalf("entity with height %d drew %d cells outside the %dx%d area, first: %s",
+			height, len(screen.outside), width, height, screen.outside[0])
+	}
+	if te, ok := entity.(*TextEntity); ok {
+		if len(te.buffer) != height {
+			t.Fatalf("text entity has %d lines, but reported height %d", len(te.buffer), height)
+		}
+		for i, line := range te.buffer {
+			lineWidth := runewidth.StringWidth(strings.TrimRight(line, " "))
+			if i == 0 {
+				lineWidth += te.startX
+			}
+			// A single character that's wider than the whole area is force-broken onto its own line
+			if lineWidth > width && uniseg.GraphemeClusterCount(line) > 1 {
+				t.Fatalf("line %d %q is %d cells wide, which doesn't fit in %d", i, line, lineWidth, width)
+			}
+		}
+	}
+	return screen
+}
+
+var pathologicalText = []string{
+	"hello world",
+	"https://example.com/" + strings.Repeat("a", 200),
+	strings.Repeat("QUFBQUFB", 40),
+	strings.Repeat("\U0001F469\u200d\U0001F469\u200d\U0001F467\u200d\U0001F466", 7),
+	"a\u200db\u200dc\u200bd\u200be\u2060f\ufeffg",
+	"\u200b\u200b\u200b\u200b\u200b",
+	"שלום עולם, זה טקסט ארוך מאוד שאמור להישבר לכמה שורות",
+	"مرحبا بالعالم 123 (test) مرحبا",
+	"mixed עברית and English 42 text",
+	"é́́́ä̈̈o̧̧",
+	"漢字漢字漢字漢字漢字漢字漢字漢字漢字漢字漢字漢字",
+	"\u202eoverride\u202c \u2067isolate\u2069",
+	"tabs\tand\tnewlines\nin\nthe\ntext",
+	"    leading and trailing spaces    ",
+}
+
+func FuzzTextLayout(f *testing.F) {
+	for _, text := range pathologicalText {
+		for _, width := range []int{1, 2, 3, 10, 80} {
+			f.Add(text, width, false)
+		}
+	}
+	f.Add("bare message text", 5, true)
+	f.Fuzz(func(t *testing.T, text string, width int, bare bool) {
+		if width < 1 || width > 200 || !utf8.ValidString(text) {
+			t.Skip()
+		}
+		entity := TextToEntity(text, "$event", true)
+		if entity == nil {
+			return
+		}
+		checkLayout(t, entity, width, DrawContext{BareMessages: bare})
+	})
+}
+
+func FuzzHTMLLayout(f *testing.F) {
+	seeds := []string{
+		"<p>hello <b>world</b></p>",
+		"<blockquote><p>שלום <i>עולם</i></p></blockquote>",
+		"<ul><li>" + strings.Repeat("x", 100) + "</li><li>\U0001F469\u200d\U0001F469\u200d\U0001F467\u200d\U0001F466</li></ul>",
+		"<ol start=\"99\"><li>a‍b</li><li>é́</li></ol>",
+		"<span data-mx-spoiler>spoiler " + strings.Repeat("ab", 50) + "</span>",
+		"<pre><code>" + strings.Repeat("long code line ", 20) + "\nعربى</code></pre>",
+		"<table><tr><th>漢字</th><th>b</th></tr><tr><td>" + strings.Repeat("c", 60) + "</td><td>d</td></tr></table>",
+		"<h1>header</h1>text<hr>more<br><br>text",
+		"<a href=\"https://matrix.to/#/@user:example.com\">user</a> <a href=\"https://example.com\">link</a>",
+		"<font color=\"#ff0000\">red</font><del>struck</del><code>inline</code>",
+	}
+	for _, seed := range seeds {
+		for _, width := range []int{2, 7, 40} {
+			f.Add(seed, width)
+		}
+	}
+	prefs := &config.UserPreferences{InlineURLMode: "disable"}
+	room := store.NewRoomStore(store.NewStore(), &database.Room{ID: "!room:example.com"})
+	evt := &database.Event{ID: "$event"}
+	f.Fuzz(func(t *testing.T, htmlData string, width int) {
+		if width < 2 || width > 200 || !utf8.ValidString(htmlData) {
+			t.Skip()
+		}
+		parser := htmlParser{prefs: prefs, room: room, evt: evt}
+		entity := parser.Parse(htmlData)
+		if entity == nil {
+			return
+		}
+		checkLayout(t, entity, width, DrawContext{})
+		checkLayout(t, entity, width, DrawContext{RevealSpoilers: true})
+	})
+}
+
+func FuzzVisualOrder(f *testing.F) {
+	for _, text := range pathologicalText {
+		f.Add(text, false)
+		f.Add(text, true)
+	}
+	f.Fuzz(func(t *testing.T, line string, rtl bool) {
+		if !utf8.ValidString(line) {
+			t.Skip()
+		}
+		visual := visualOrder(line, rtl)
+		// Reordering only moves grapheme clusters around, so the drawn width must stay the same
+		if runewidth.StringWidth(visual) != runewidth.StringWidth(line) {
+			t.Fatalf("visualOrder(%q, %t) = %q changed the width from %d to %d",
+				line, rtl, visual, runewidth.StringWidth(line), runewidth.StringWidth(visual))
+		}
+	})
+}
@@ -0,0 +1,251 @@
+// gomuks - A terminal Matrix client written in Go.
+// Copyright (C) 2022 Tulir Asokan
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+package html
+
+import (
+	"strings"
+	"unicode"
+)
+
+var latexSymbols = map[string]string{
+	"alpha": "α", "beta": "β", "gamma": "γ", "delta": "δ", "epsilon": "ϵ", "varepsilon": "ε",
+	"zeta": "ζ", "eta": "η", "theta": "θ", "vartheta": "ϑ", "iota": "ι", "kappa": "κ",
+	"lambda": "λ", "mu": "μ", "nu": "ν", "xi": "ξ", "pi": "π", "varpi": "ϖ", "rho": "ρ",
+	"varrho": "ϱ", "sigma": "σ", "varsigma": "ς", "tau": "τ", "upsilon": "υ", "phi": "ϕ",
+	"varphi": "φ", "chi": "χ", "psi": "ψ", "omega": "ω",
+	"Gamma": "Γ", "Delta": "Δ", "Theta": "Θ", "Lambda": "Λ", "Xi": "Ξ", "Pi": "Π",
+	"Sigma": "Σ", "Upsilon": "Υ", "Phi": "Φ", "Psi": "Ψ", "Omega": "Ω",
+
+	"infty": "∞", "pm": "±", "mp": "∓", "times": "×", "div": "÷", "cdot": "·", "ast": "∗",
+	"leq": "≤", "le": "≤", "geq": "≥", "ge": "≥", "neq": "≠", "ne": "≠", "approx": "≈",
+	"equiv": "≡", "sim": "∼", "simeq": "≃", "cong": "≅", "propto": "∝", "ll": "≪", "gg": "≫",
+	"to": "→", "rightarrow": "→", "leftarrow": "←", "leftrightarrow": "↔", "mapsto": "↦",
+	"Rightarrow": "⇒", "Leftarrow": "⇐", "Leftrightarrow": "⇔", "implies": "⟹", "iff": "⟺",
+	"sum": "∑", "prod": "∏", "int": "∫", "iint": "∬", "oint": "∮", "partial": "∂", "nabla": "∇",
+	"in": "∈", "notin": "∉", "ni": "∋", "subset": "⊂", "subseteq": "⊆", "supset": "⊃",
+	"supseteq": "⊇", "cup": "∪", "cap": "∩", "emptyset": "∅", "varnothing": "∅",
+	"forall": "∀", "exists": "∃", "neg": "¬", "lnot": "¬", "land": "∧", "wedge": "∧",
+	"lor": "∨", "vee": "∨", "oplus": "⊕", "otimes": "⊗", "circ": "∘", "bullet": "•",
+	"cdots": "⋯", "ldots": "…", "dots": "…", "vdots": "⋮", "ddots": "⋱", "prime": "′",
+	"hbar": "ℏ", "ell": "ℓ", "Re": "ℜ", "Im": "ℑ", "aleph": "ℵ", "angle": "∠", "degree": "°",
+	"langle": "⟨", "rangle": "⟩", "lfloor": "⌊", "rfloor": "⌋", "lceil": "⌈", "rceil": "⌉",
+	"mid": "∣", "parallel": "∥", "perp": "⊥",
+	"{": "{", "}": "}", "%": "%", "$": "$", "&": "&", "#": "#", "_": "_", "|": "‖",
+	",": " ", ";": " ", ":": " ", "!": "", " ": " ", "quad": "  ", "qquad": "    ",
+}
+
+var latexFunctions = map[string]struct{}{
+	"sin": {}, "cos": {}, "tan": {}, "cot": {}, "sec": {}, "csc": {}, "arcsin": {}, "arccos": {},
+	"arctan": {}, "sinh": {}, "cosh": {}, "tanh": {}, "log": {}, "ln": {}, "lg": {}, "exp": {},
+	"lim": {}, "max": {}, "min": {}, "sup": {}, "inf": {}, "det": {}, "gcd": {}, "deg": {},
+	"dim": {}, "ker": {}, "arg": {}, "mod": {},
+}
+
+var latexTextCommands = map[string]struct{}{
+	"text": {}, "textrm": {}, "textit": {}, "textbf": {}, "mathrm": {}, "mathit": {}, "mathbf": {},
+	"mathsf": {}, "mathtt": {}, "mathnormal": {}, "operatorname": {}, "boldsymbol": {}, "bm": {},
+}
+
+var superscripts = map[rune]rune{
+	'0': '⁰', '1': '¹', '2': '²', '3': '³', '4': '⁴', '5': '⁵', '6': '⁶', '7': '⁷', '8': '⁸', '9': '⁹',
+	'+': '⁺', '-': '⁻', '−': '⁻', '=': '⁼', '(': '⁽', ')': '⁾',
+	'a': 'ᵃ', 'b': 'ᵇ', 'c': 'ᶜ', 'd': 'ᵈ', 'e': 'ᵉ', 'f': 'ᶠ', 'g': 'ᵍ', 'h': 'ʰ', 'i': 'ⁱ',
+	'j': 'ʲ', 'k': 'ᵏ', 'l': 'ˡ', 'm': 'ᵐ', 'n': 'ⁿ', 'o': 'ᵒ', 'p': 'ᵖ', 'r': 'ʳ', 's': 'ˢ',
+	't': 'ᵗ', 'u': 'ᵘ', 'v': 'ᵛ', 'w': 'ʷ', 'x': 'ˣ', 'y': 'ʸ', 'z': 'ᶻ',
+	'T': 'ᵀ', '′': '′', '∗': '*',
+}
+
+var subscripts = map[rune]rune{
+	'0': '₀', '1': '₁', '2': '₂', '3': '₃', '4': '₄', '5': '₅', '6': '₆', '7': '₇', '8': '₈', '9': '₉',
+	'+': '₊', '-': '₋', '−': '₋', '=': '₌', '(': '₍', ')': '₎',
+	'a': 'ₐ', 'e': 'ₑ', 'h': 'ₕ', 'i': 'ᵢ', 'j': 'ⱼ', 'k': 'ₖ', 'l': 'ₗ', 'm': 'ₘ', 'n': 'ₙ',
+	'o': 'ₒ', 'p': 'ₚ', 'r': 'ᵣ', 's': 'ₛ', 't': 'ₜ', 'u': 'ᵤ', 'v': 'ᵥ', 'x': 'ₓ',
+}
+
+// LaTeXToUnicode converts a simple LaTeX math expression into a best-effort plain Unicode approximation.
+//
+// Greek letters and common symbols are replaced with the corresponding Unicode characters,
+// super- and subscripts use the Unicode super/subscript characters when possible (and ^(...)/_(...) otherwise),
+// and fractions and roots are flattened into a/b and √x. If the expression contains anything that can't
+// be approximated (e.g. environments, unknown commands or unbalanced braces), ok is false.
+func LaTeXToUnicode(latex string) (output string, ok bool) {
+	conv := &latexConverter{input: []rune(strings.TrimSpace(latex))}
+	output = conv.parseUntil(0)
+	if conv.failed || conv.pos < len(conv.input) {
+		return "", false
+	}
+	return strings.TrimSpace(output), true
+}
+
+type latexConverter struct {
+	input  []rune
+	pos    int
+	failed bool
+}
+
+func (lc *latexConverter) parseUntil(end rune) string {
+	var buf strings.Builder
+	prevSpace := false
+	for lc.pos < len(lc.input) && !lc.failed {
+		char := lc.input[lc.pos]
+		if char == end {
+			return buf.String()
+		}
+		if unicode.IsSpace(char) {
+			lc.pos++
+			if !prevSpace && buf.Len() > 0 {
+				buf.WriteRune(' ')
+			}
+			prevSpace = true
+			continue
+		}
+		prevSpace = false
+		switch char {
+		case '}':
+			lc.failed = true
+		case '^':
+			lc.pos++
+			buf.WriteString(convertScript(lc.parseArg(), superscripts, '^'))
+		case '_':
+			lc.pos++
+			buf.WriteString(convertScript(lc.parseArg(), subscripts, '_'))
+		case '\'':
+			lc.pos++
+			buf.WriteRune('′')
+		case '&':
+			// Alignment markers are only used in environments, which aren't supported
+			lc.failed = true
+		default:
+			buf.WriteString(lc.parseArg())
+		}
+	}
+	if end != 0 {
+		// Reached end of input without finding the closing brace
+		lc.failed = true
+	}
+	return buf.String()
+}
+
+func (lc *latexConverter) parseArg() string {
+	for lc.pos < len(lc.input) && unicode.IsSpace(lc.input[lc.pos]) {
+		lc.pos++
+	}
+	if lc.pos >= len(lc.input) {
+		lc.failed = true
+		return ""
+	}
+	char := lc.input[lc.pos]
+	switch char {
+	case '{':
+		lc.pos++
+		content := lc.parseUntil('}')
+		lc.pos++
+		return content
+	case '\\':
+		lc.pos++
+		return lc.parseCommand()
+	case '}', '^', '_', '&':
+		lc.failed = true
+		return ""
+	default:
+		lc.pos++
+		if char == '-' {
+			return "−"
+		}
+		return string(char)
+	}
+}
+
+func (lc *latexConverter) readCommandName() string {
+	start := lc.pos
+	for lc.pos < len(lc.input) && isASCIILetter(lc.input[lc.pos]) {
+		lc.pos++
+	}
+	if lc.pos == start && lc.pos < len(lc.input) {
+		// Single non-letter command like \, or \{
+		lc.pos++
+	}
+	return string(lc.input[start:lc.pos])
+}
+
+func (lc *latexConverter) parseCommand() string {
+	name := lc.readCommandName()
+	if symbol, ok := latexSymbols[name]; ok {
+		return symbol
+	} else if _, ok = latexFunctions[name]; ok {
+		return name
+	} else if _, ok = latexTextCommands[name]; ok {
+		return lc.parseArg()
+	}
+	switch name {
+	case "frac", "dfrac", "tfrac":
+		numerator := lc.parseArg()
+		denominator := lc.parseArg()
+		return wrapCompound(numerator) + "/" + wrapCompound(denominator)
+	case "sqrt":
+		root := "√"
+		if lc.pos < len(lc.input) && lc.input[lc.pos] == '[' {
+			lc.pos++
+			index := lc.parseUntil(']')
+			lc.pos++
+			root = convertScript(index, superscripts, '^') + root
+		}
+		return root + wrapCompound(lc.parseArg())
+	case "left", "right", "big", "Big", "bigg", "Bigg":
+		if lc.pos < len(lc.input) && lc.input[lc.pos] == '.' {
+			lc.pos++
+			return ""
+		}
+		return lc.parseArg()
+	case "displaystyle", "textstyle", "limits", "nolimits":
+		return ""
+	default:
+		lc.failed = true
+		return ""
+	}
+}
+
+func isASCIILetter(char rune) bool {
+	return (char >= 'a' && char <= 'z') || (char >= 'A' && char <= 'Z')
+}
+
+// wrapCompound adds parentheses around the given expression if it consists of more than one term.
+func wrapCompound(expr string) string {
+	expr = strings.TrimSpace(expr)
+	if strings.ContainsAny(expr, " +−-*/·×=") && !(strings.HasPrefix(expr, "(") && strings.HasSuffix(expr, ")")) {
+		return "(" + expr + ")"
+	}
+	return expr
+}
+
+// convertScript converts the given expression to super- or subscript characters.
+// If any character can't be represented, the expression is written with a normal ^ or _ marker instead.
+func convertScript(expr string, table map[rune]rune, marker rune) string {
+	expr = strings.TrimSpace(expr)
+	var buf strings.Builder
+	for _, char := range expr {
+		converted, ok := table[char]
+		if !ok {
+			if len([]rune(expr)) == 1 {
+				return string(marker) + expr
+			}
+			return string(marker) + "(" + expr + ")"
+		}
+		buf.WriteRune(converted)
+	}
+	return buf.String()
+}
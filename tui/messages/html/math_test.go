@@ -0,0 +1,74 @@
+// gomuks - A terminal Matrix client written in Go.
+// Copyright (C) 2026 Tulir Asokan
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+package html
+
+import (
+	"testing"
+)
+
+func TestLaTeXToUnicode(t *testing.T) {
+	tests := []struct {
+		name   string
+		latex  string
+		want   string
+		wantOK bool
+	}{
+		{"superscript", `x^2`, "x²", true},
+		{"braced superscript", `x^{10}`, "x¹⁰", true},
+		{"negative exponent", `x^{-1}`, "x⁻¹", true},
+		{"subscript", `a_i`, "aᵢ", true},
+		{"braced subscript", `x_{n+1}`, "xₙ₊₁", true},
+		{"sub and superscript", `x_{i}^{2}`, "xᵢ²", true},
+		{"unsupported superscript character", `e^{i\pi}`, "e^(iπ)", true},
+		{"unsupported single subscript character", `y_b`, "y_b", true},
+		{"prime", `f'(x)`, "f′(x)", true},
+		{"fraction", `\frac{1}{2}`, "1/2", true},
+		{"fraction without braces", `\frac12`, "1/2", true},
+		{"compound fraction", `\frac{a+b}{c}`, "(a+b)/c", true},
+		{"lowercase greek", `\alpha + \beta`, "α + β", true},
+		{"uppercase greek", `\Omega`, "Ω", true},
+		{"variant greek", `\varepsilon \varphi`, "ε φ", true},
+		{"function", `\sin x`, "sin x", true},
+		{"square root", `\sqrt{x^2 + y^2}`, "√(x² + y²)", true},
+		{"nth root", `\sqrt[3]{x}`, "³√x", true},
+		{"sum with limits", `\sum_{i=1}^{n} i^2`, "∑ᵢ₌₁ⁿ i²", true},
+		{"nested fraction in root in fraction", `\frac{\sqrt{\frac{a}{b}}}{2}`, "(√(a/b))/2", true},
+		{"nested superscript", `x^{y^{2}}`, "x^(y²)", true},
+		{"nested braces", `{{a}+{b}}`, "a+b", true},
+		{"delimiters", `\left( \frac{1}{2} \right)`, "( 1/2 )", true},
+		{"empty", `  `, "", true},
+
+		{"unknown command", `\unknowncmd`, "", false},
+		{"environment", `\begin{matrix} a \end{matrix}`, "", false},
+		{"alignment", `a & b`, "", false},
+		{"missing fraction argument", `\frac{1}`, "", false},
+		{"unclosed brace", `{x`, "", false},
+		{"unopened brace", `x}`, "", false},
+		{"dangling superscript", `x^`, "", false},
+		{"unknown command inside nesting", `\frac{\sqrt{\foo}}{2}`, "", false},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			got, ok := LaTeXToUnicode(test.latex)
+			if ok != test.wantOK {
+				t.Fatalf("LaTeXToUnicode(%q) ok = %t, want %t (output %q)", test.latex, ok, test.wantOK, got)
+			} else if got != test.want {
+				t.Errorf("LaTeXToUnicode(%q) = %q, want %q", test.latex, got, test.want)
+			}
+		})
+	}
+}
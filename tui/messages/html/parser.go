@@ -22,9 +22,6 @@ import (
 	"strconv"
 	"strings"
 
-	"github.com/alecthomas/chroma/v2"
-	"github.com/alecthomas/chroma/v2/lexers"
-	"github.com/alecthomas/chroma/v2/styles"
 	"github.com/gdamore/tcell/v2"
 	"github.com/lucasb-eyer/go-colorful"
 	"golang.org/x/net/html"
@@ -194,6 +191,57 @@ func (parser *htmlParser) headerToEntity(node *html.Node) Entity {
 	}).AdjustStyle(AdjustStyleBold, AdjustStyleReasonNormal)
 }
 
+func (parser *htmlParser) tableCellToEntity(node *html.Node) Entity {
+	cell := &ContainerEntity{
+		BaseEntity: &BaseEntity{
+			Tag:   node.Data,
+			Block: true,
+		},
+		Children: parser.nodeToEntities(node.FirstChild),
+	}
+	if node.Data == "th" {
+		cell.AdjustStyle(AdjustStyleBold, AdjustStyleReasonNormal)
+	}
+	return cell
+}
+
+func (parser *htmlParser) tableToEntity(node *html.Node) Entity {
+	var rows [][]Entity
+	headerRows := 0
+	var collectRows func(node *html.Node, isHead bool)
+	collectRows = func(node *html.Node, isHead bool) {
+		for child := node.FirstChild; child != nil; child = child.NextSibling {
+			if child.Type != html.ElementNode {
+				continue
+			}
+			switch child.Data {
+			case "thead":
+				collectRows(child, true)
+			case "tbody", "tfoot":
+				collectRows(child, false)
+			case "tr":
+				var row []Entity
+				allHeaders := true
+				for cell := child.FirstChild; cell != nil; cell = cell.NextSibling {
+					if cell.Type == html.ElementNode && (cell.Data == "td" || cell.Data == "th") {
+						row = append(row, parser.tableCellToEntity(cell))
+						allHeaders = allHeaders && cell.Data == "th"
+					}
+				}
+				if len(row) == 0 {
+					continue
+				}
+				if headerRows == len(rows) && (isHead || allHeaders) {
+					headerRows++
+				}
+				rows = append(rows, row)
+			}
+		}
+	}
+	collectRows(node, false)
+	return NewTableEntity(rows, headerRows)
+}
+
 func (parser *htmlParser) blockquoteToEntity(node *html.Node) Entity {
 	return NewBlockquoteEntity(parser.nodeToEntities(node.FirstChild))
 }
@@ -224,10 +272,11 @@ func (parser *htmlParser) linkToEntity(node *html.Node) Entity {
 	if matrixURI != nil && (matrixURI.Sigil1 == '@' || matrixURI.Sigil1 == '#') && matrixURI.Sigil2 == 0 {
 		text := NewTextEntity(matrixURI.PrimaryIdentifier())
 		if matrixURI.Sigil1 == '@' {
+			// Mentions of users whose member event isn't loaded keep the user ID, but are still colored
 			if member := parser.room.GetMember(matrixURI.UserID()); member != nil {
 				text.Text = member.Displayname
-				text.Style = text.Style.Foreground(widget.GetHashColor(matrixURI.UserID()))
 			}
+			text.Style = text.Style.Foreground(widget.GetHashColor(matrixURI.UserID()))
 			entity.Children = []Entity{text}
 		} else if matrixURI.Sigil1 == '#' {
 			entity.Children = []Entity{text}
@@ -260,73 +309,8 @@ func (parser *htmlParser) imageToEntity(node *html.Node) Entity {
 	return entity
 }
 
-func colourToColor(colour chroma.Colour) tcell.Color {
-	if !colour.IsSet() {
-		return tcell.ColorDefault
-	}
-	return tcell.NewRGBColor(int32(colour.Red()), int32(colour.Green()), int32(colour.Blue()))
-}
-
-func styleEntryToStyle(se chroma.StyleEntry) tcell.Style {
-	return tcell.StyleDefault.
-		Bold(se.Bold == chroma.Yes).
-		Italic(se.Italic == chroma.Yes).
-		Underline(se.Underline == chroma.Yes).
-		Foreground(colourToColor(se.Colour)).
-		Background(colourToColor(se.Background))
-}
-
-func tokenToTextEntity(style *chroma.Style, token *chroma.Token) *TextEntity {
-	return &TextEntity{
-		BaseEntity: &BaseEntity{
-			Tag:           token.Type.String(),
-			Style:         styleEntryToStyle(style.Get(token.Type)),
-			DefaultHeight: 1,
-		},
-		Text: token.Value,
-	}
-}
-
-func (parser *htmlParser) syntaxHighlight(text, language string) Entity {
-	lexer := lexers.Get(strings.ToLower(language))
-	if lexer == nil {
-		lexer = lexers.Get("plaintext")
-	}
-	iter, err := lexer.Tokenise(nil, text)
-	if err != nil {
-		return nil
-	}
-	// TODO allow changing theme
-	style := styles.Get("solarized-dark")
-
-	tokens := iter.Tokens()
-
-	var children []Entity
-	for _, token := range tokens {
-		lines := strings.SplitAfter(token.Value, "\n")
-		for _, line := range lines {
-			line_len := len(line)
-			if line_len == 0 {
-				continue
-			}
-			t := token.Clone()
-
-			if line[line_len-1:] == "\n" {
-				t.Value = line[:line_len-1]
-				children = append(children, tokenToTextEntity(style, &t), NewBreakEntity())
-			} else {
-				t.Value = line
-				children = append(children, tokenToTextEntity(style, &t))
-			}
-		}
-	}
-
-	return NewCodeBlockEntity(children, styleEntryToStyle(style.Get(chroma.Background)))
-}
-
 func (parser *htmlParser) codeblockToEntity(node *html.Node) Entity {
-	lang := "plaintext"
-	// TODO allow disabling syntax highlighting
+	lang := ""
 	if node.FirstChild != nil && node.FirstChild.Type == html.ElementNode && node.FirstChild.Data == "code" {
 		node = node.FirstChild
 		attr := parser.getAttribute(node, "class")
@@ -342,10 +326,45 @@ func (parser *htmlParser) codeblockToEntity(node *html.Node) Entity {
 		Children: parser.nodeToEntities(node.FirstChild),
 	}).PlainText()
 	parser.preserveWhitespace = false
-	return parser.syntaxHighlight(text, lang)
+	styleName := parser.prefs.GetSyntaxHighlightStyle()
+	if parser.prefs.DisableSyntaxHighlight {
+		styleName = ""
+	}
+	return NewSourceCodeBlockEntity(text, lang, styleName, parser.prefs.WrapCodeBlocks)
+}
+
+func (parser *htmlParser) mathToEntity(node *html.Node, latex string) Entity {
+	entity := &ContainerEntity{
+		BaseEntity: &BaseEntity{
+			Tag:   node.Data,
+			Block: parser.isBlockTag(node.Data),
+		},
+	}
+	switch parser.prefs.GetMathRendering() {
+	case config.MathRenderingHidden:
+		entity.Children = []Entity{NewTextEntity("[math]")}
+		entity.AdjustStyle(AdjustStyleTextColor(tcell.ColorGray), AdjustStyleReasonNormal)
+	case config.MathRenderingSource:
+		entity.Children = []Entity{textToHTMLEntity(latex)}
+		entity.AdjustStyle(AdjustStyleBackgroundColor(tcell.ColorDarkSlateGray), AdjustStyleReasonNormal)
+		entity.AdjustStyle(AdjustStyleTextColor(tcell.ColorWhite), AdjustStyleReasonNormal)
+	default:
+		if converted, ok := LaTeXToUnicode(latex); ok {
+			entity.Children = []Entity{NewTextEntity(converted)}
+		} else {
+			// Fall back to the annotation text, which is generally the LaTeX source in a code tag
+			entity.Children = parser.nodeToEntities(node.FirstChild)
+		}
+	}
+	return entity
 }
 
 func (parser *htmlParser) tagNodeToEntity(node *html.Node) Entity {
+	if node.Data == "span" || node.Data == "div" {
+		if latex, isMath := parser.maybeGetAttribute(node, "data-mx-maths"); isMath {
+			return parser.mathToEntity(node, latex)
+		}
+	}
 	switch node.Data {
 	case "blockquote":
 		return parser.blockquoteToEntity(node)
@@ -365,6 +384,8 @@ func (parser *htmlParser) tagNodeToEntity(node *html.Node) Entity {
 		return parser.codeblockToEntity(node)
 	case "hr":
 		return NewHorizontalLineEntity()
+	case "table":
+		return parser.tableToEntity(node)
 	case "mx-reply":
 		return nil
 	default:
@@ -509,7 +530,7 @@ func (parser *htmlParser) Parse(htmlData string) Entity {
 const TabLength = 4
 
 // Parse parses a HTML-formatted Matrix event into a UIMessage.
-func Parse(prefs *config.UserPreferences, room *store.RoomStore, content *event.MessageEventContent, evt *database.Event, senderDisplayname string) Entity {
+func Parse(prefs *config.UserPreferences, room *store.RoomStore, content *event.MessageEventContent, evt *database.Event) Entity {
 	htmlData := content.FormattedBody
 
 	if content.Format != event.FormatHTML {
@@ -534,19 +555,52 @@ func Parse(prefs *config.UserPreferences, room *store.RoomStore, content *event.
 		}
 	}
 
-	if content.MsgType == event.MsgEmote {
-		root = &ContainerEntity{
-			BaseEntity: &BaseEntity{
-				Tag: "emote",
-			},
-			Children: []Entity{
-				NewTextEntity("* "),
-				NewTextEntity(senderDisplayname).AdjustStyle(AdjustStyleTextColor(widget.GetHashColor(evt.Sender)), AdjustStyleReasonNormal),
-				NewTextEntity(" "),
-				root,
-			},
+	return root
+}
+
+// NewEmoteEntity prefixes the body of an m.emote with "* displayname", with the name in the given color.
+// The result is an inline container, so the body flows right after the name and wraps like a normal paragraph.
+// Leading block containers in the body (e.g. a <p> or <div>) are made inline so that the first line
+// of the body isn't pushed below the name.
+func NewEmoteEntity(senderColor tcell.Color, senderDisplayname string, body Entity) Entity {
+	for entity := body; ; {
+		container, ok := entity.(*ContainerEntity)
+		if !ok {
+			break
+		}
+		container.Block = false
+		if len(container.Children) == 0 {
+			break
+		}
+		entity = container.Children[0]
+	}
+	return &ContainerEntity{
+		BaseEntity: &BaseEntity{
+			Tag: "emote",
+		},
+		Children: []Entity{
+			NewTextEntity("* "),
+			NewTextEntity(senderDisplayname).AdjustStyle(AdjustStyleTextColor(senderColor), AdjustStyleReasonNormal),
+			NewTextEntity(" "),
+			body,
+		},
+	}
+}
+
+// ParseTopic parses the topic of a room into an entity. The HTML version in the extensible m.topic field
+// is preferred, with the plaintext topic as a fallback.
+func ParseTopic(prefs *config.UserPreferences, room *store.RoomStore, evt *database.Event, content *event.TopicEventContent) Entity {
+	htmlData := event.TextToHTML(content.Topic)
+	if content.ExtensibleTopic != nil {
+		for _, text := range content.ExtensibleTopic.Text {
+			if text.MimeType == "text/html" && len(text.Body) > 0 {
+				htmlData = text.Body
+				break
+			}
 		}
 	}
+	htmlData = strings.ReplaceAll(htmlData, "\t", strings.Repeat(" ", TabLength))
 
-	return root
+	parser := htmlParser{room: room, prefs: prefs, evt: evt}
+	return parser.Parse(htmlData)
 }
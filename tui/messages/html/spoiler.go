@@ -19,68 +19,77 @@ package html
 import (
 	"fmt"
 	"strings"
+	"unicode"
+	"unicode/utf8"
 
 	"github.com/gdamore/tcell/v2"
+	"github.com/mattn/go-runewidth"
+	"github.com/rivo/uniseg"
 	"go.mau.fi/mauview"
 )
 
+// SpoilerEntity is a container whose text is redacted until the spoilers in the message are revealed.
+//
+// The same entity tree is used for both states and only the drawing differs,
+// so revealing a spoiler never changes the height of the message.
 type SpoilerEntity struct {
-	reason  string
-	hidden  *ContainerEntity
-	visible *ContainerEntity
+	*ContainerEntity
+	reason string
 }
 
 const SpoilerColor = tcell.ColorYellow
 
-func NewSpoilerEntity(visible *ContainerEntity, reason string) *SpoilerEntity {
-	hidden := visible.Clone().(*ContainerEntity)
-	hidden.AdjustStyle(func(style tcell.Style) tcell.Style {
-		return style.Foreground(SpoilerColor).Background(SpoilerColor)
-	}, AdjustStyleReasonHideSpoiler)
+// SpoilerMaskChar is the character used to draw redacted spoiler text.
+const SpoilerMaskChar = '█'
+
+func NewSpoilerEntity(content *ContainerEntity, reason string) *SpoilerEntity {
 	if len(reason) > 0 {
-		reasonEnt := NewTextEntity(fmt.Sprintf("(%s)", reason))
-		hidden.Children = append([]Entity{reasonEnt}, hidden.Children...)
-		visible.Children = append([]Entity{reasonEnt}, visible.Children...)
+		reasonEnt := &spoilerReasonEntity{NewTextEntity(fmt.Sprintf("(%s) ", reason))}
+		content.Children = append([]Entity{reasonEnt}, content.Children...)
 	}
 	return &SpoilerEntity{
-		reason:  reason,
-		hidden:  hidden,
-		visible: visible,
+		ContainerEntity: content,
+		reason:          reason,
 	}
 }
 
-func (se *SpoilerEntity) Clone() Entity {
-	return &SpoilerEntity{
-		reason:  se.reason,
-		hidden:  se.hidden.Clone().(*ContainerEntity),
-		visible: se.visible.Clone().(*ContainerEntity),
+// MaskSpoilerText replaces all non-whitespace characters in the given text with SpoilerMaskChar.
+// Wide characters are replaced with multiple mask characters so that the width of the text stays the same.
+func MaskSpoilerText(text string) string {
+	var buf strings.Builder
+	state := -1
+	var cluster string
+	for len(text) > 0 {
+		// Mask whole grapheme clusters so that the masked text has the same width as the original
+		cluster, text, _, state = uniseg.FirstGraphemeClusterInString(text, state)
+		if char, _ := utf8.DecodeRuneInString(cluster); unicode.IsSpace(char) {
+			buf.WriteString(cluster)
+		} else {
+			for range runewidth.StringWidth(cluster) {
+				buf.WriteRune(SpoilerMaskChar)
+			}
+		}
 	}
+	return buf.String()
 }
 
-func (se *SpoilerEntity) IsBlock() bool {
-	return false
+func (se *SpoilerEntity) Clone() Entity {
+	return &SpoilerEntity{
+		ContainerEntity: se.ContainerEntity.Clone().(*ContainerEntity),
+		reason:          se.reason,
+	}
 }
 
-func (se *SpoilerEntity) GetTag() string {
-	return "span"
+func (se *SpoilerEntity) AdjustStyle(fn AdjustStyleFunc, reason AdjustStyleReason) Entity {
+	se.ContainerEntity.AdjustStyle(fn, reason)
+	return se
 }
 
 func (se *SpoilerEntity) Draw(screen mauview.Screen, ctx DrawContext) {
-	if ctx.IsSelected {
-		se.visible.Draw(screen, ctx)
-	} else {
-		se.hidden.Draw(screen, ctx)
-	}
-}
-
-func (se *SpoilerEntity) AdjustStyle(fn AdjustStyleFunc, reason AdjustStyleReason) Entity {
-	if reason != AdjustStyleReasonHideSpoiler {
-		se.hidden.AdjustStyle(func(style tcell.Style) tcell.Style {
-			return fn(style).Foreground(SpoilerColor).Background(SpoilerColor)
-		}, reason)
-		se.visible.AdjustStyle(fn, reason)
+	if !ctx.RevealSpoilers {
+		ctx.maskSpoilers = true
 	}
-	return se
+	se.ContainerEntity.Draw(screen, ctx)
 }
 
 func (se *SpoilerEntity) PlainText() string {
@@ -94,27 +103,27 @@ func (se *SpoilerEntity) PlainText() string {
 func (se *SpoilerEntity) String() string {
 	var buf strings.Builder
 	_, _ = fmt.Fprintf(&buf, `&html.SpoilerEntity{reason=%s`, se.reason)
-	buf.WriteString("\n    visible=")
-	buf.WriteString(strings.Join(strings.Split(strings.TrimRight(se.visible.String(), "\n"), "\n"), "\n    "))
-	buf.WriteString("\n    hidden=")
-	buf.WriteString(strings.Join(strings.Split(strings.TrimRight(se.hidden.String(), "\n"), "\n"), "\n    "))
+	buf.WriteString("\n    content=")
+	buf.WriteString(strings.Join(strings.Split(strings.TrimRight(se.ContainerEntity.String(), "\n"), "\n"), "\n    "))
 	buf.WriteString("\n]},")
 	return buf.String()
 }
 
-func (se *SpoilerEntity) Height() int {
-	return se.visible.Height()
+// spoilerReasonEntity is the reason prefix of a spoiler, which is always drawn unmasked.
+type spoilerReasonEntity struct {
+	*TextEntity
 }
 
-func (se *SpoilerEntity) CalculateBuffer(width, startX int, ctx DrawContext) int {
-	se.hidden.CalculateBuffer(width, startX, ctx)
-	return se.visible.CalculateBuffer(width, startX, ctx)
+func (sre *spoilerReasonEntity) Clone() Entity {
+	return &spoilerReasonEntity{sre.TextEntity.Clone().(*TextEntity)}
 }
 
-func (se *SpoilerEntity) getStartX() int {
-	return se.visible.getStartX()
+func (sre *spoilerReasonEntity) AdjustStyle(fn AdjustStyleFunc, reason AdjustStyleReason) Entity {
+	sre.TextEntity.AdjustStyle(fn, reason)
+	return sre
 }
 
-func (se *SpoilerEntity) IsEmpty() bool {
-	return se.visible.IsEmpty()
+func (sre *spoilerReasonEntity) Draw(screen mauview.Screen, ctx DrawContext) {
+	ctx.maskSpoilers = false
+	sre.TextEntity.Draw(screen, ctx)
 }
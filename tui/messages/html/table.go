@@ -0,0 +1,325 @@
+// gomuks - A terminal Matrix client written in Go.
+// Copyright (C) 2022 Tulir Asokan
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+package html
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/mattn/go-runewidth"
+	"go.mau.fi/mauview"
+
+	"go.mau.fi/gomuks/tui/widget"
+)
+
+// TableEntity renders a HTML table with box-drawing borders. If the columns don't fit in the
+// available width, the table is rendered as a list of "header: value" pairs instead.
+type TableEntity struct {
+	*BaseEntity
+
+	// The cells of the table. Rows may have different numbers of cells.
+	Rows [][]Entity
+	// Number of rows at the start of the table that are headers.
+	HeaderRows int
+
+	columns    int
+	colWidths  []int
+	rowHeights []int
+	keyWidth   int
+	listMode   bool
+}
+
+const (
+	tableCellPadding = 1
+	// tableMaxKeyWidth is the maximum fraction of the width used for keys in list mode.
+	tableMaxKeyWidth = 2
+)
+
+func NewTableEntity(rows [][]Entity, headerRows int) *TableEntity {
+	if headerRows >= len(rows) {
+		// A table with only headers is rendered as a normal table without a header separator
+		headerRows = 0
+	}
+	te := &TableEntity{
+		BaseEntity: &BaseEntity{
+			Tag:   "table",
+			Block: true,
+		},
+		Rows:       rows,
+		HeaderRows: headerRows,
+	}
+	for _, row := range rows {
+		te.columns = max(te.columns, len(row))
+	}
+	return te
+}
+
+func (te *TableEntity) IsEmpty() bool {
+	return te.columns == 0
+}
+
+func (te *TableEntity) AdjustStyle(fn AdjustStyleFunc, reason AdjustStyleReason) Entity {
+	for _, row := range te.Rows {
+		for _, cell := range row {
+			cell.AdjustStyle(fn, reason)
+		}
+	}
+	te.Style = fn(te.Style)
+	return te
+}
+
+func (te *TableEntity) Clone() Entity {
+	rows := make([][]Entity, len(te.Rows))
+	for i, row := range te.Rows {
+		rows[i] = make([]Entity, len(row))
+		for j, cell := range row {
+			rows[i][j] = cell.Clone()
+		}
+	}
+	clone := NewTableEntity(rows, te.HeaderRows)
+	clone.BaseEntity = te.BaseEntity.Clone().(*BaseEntity)
+	return clone
+}
+
+func (te *TableEntity) cell(row, col int) Entity {
+	if col < len(te.Rows[row]) {
+		return te.Rows[row][col]
+	}
+	return nil
+}
+
+// cellWidths returns the width of the longest line and the longest word in the given cell.
+func cellWidths(cell Entity, ctx DrawContext) (natural, minimum int) {
+	if cell == nil {
+		return 0, 0
+	}
+	for _, line := range strings.Split(ApplyEmojiDisplay(cell.PlainText(), ctx.EmojiDisplay), "\n") {
+		natural = max(natural, runewidth.StringWidth(line))
+		for _, word := range strings.Fields(line) {
+			minimum = max(minimum, runewidth.StringWidth(word))
+		}
+	}
+	return
+}
+
+// calculateColumnWidths fits the columns into the given width. It returns false if the columns
+// can't fit even when wrapping every cell as much as possible.
+func (te *TableEntity) calculateColumnWidths(width int, ctx DrawContext) bool {
+	natural := make([]int, te.columns)
+	minimum := make([]int, te.columns)
+	for _, row := range te.Rows {
+		for col, cell := range row {
+			cellNatural, cellMinimum := cellWidths(cell, ctx)
+			natural[col] = max(natural[col], cellNatural, 1)
+			minimum[col] = max(minimum[col], cellMinimum, 1)
+		}
+	}
+	available := width - (te.columns + 1) - te.columns*2*tableCellPadding
+	te.colWidths = make([]int, te.columns)
+	total := 0
+	for col := range te.colWidths {
+		te.colWidths[col] = max(minimum[col], 1)
+		total += te.colWidths[col]
+	}
+	if total > available {
+		return false
+	}
+	for extra := available - total; extra > 0; {
+		grew := false
+		for col := range te.colWidths {
+			if extra > 0 && te.colWidths[col] < natural[col] {
+				te.colWidths[col]++
+				extra--
+				grew = true
+			}
+		}
+		if !grew {
+			break
+		}
+	}
+	return true
+}
+
+func (te *TableEntity) headerText(col int, ctx DrawContext) string {
+	if te.HeaderRows > 0 {
+		if header := te.cell(0, col); header != nil {
+			if text := strings.ReplaceAll(ApplyEmojiDisplay(header.PlainText(), ctx.EmojiDisplay), "\n", " "); text != "" {
+				return text
+			}
+		}
+	}
+	return fmt.Sprintf("Column %d", col+1)
+}
+
+func (te *TableEntity) calculateListBuffer(width int, ctx DrawContext) {
+	te.keyWidth = 0
+	for col := 0; col < te.columns; col++ {
+		te.keyWidth = max(te.keyWidth, runewidth.StringWidth(te.headerText(col, ctx))+2)
+	}
+	te.keyWidth = min(te.keyWidth, width/tableMaxKeyWidth)
+	te.height = 0
+	for i := te.HeaderRows; i < len(te.Rows); i++ {
+		if i != te.HeaderRows {
+			te.height++
+		}
+		rowHeight := 0
+		for _, cell := range te.Rows[i] {
+			cell.CalculateBuffer(width-te.keyWidth, 0, ctx)
+			rowHeight += max(cell.Height(), 1)
+		}
+		te.rowHeights[i] = rowHeight
+		te.height += rowHeight
+	}
+}
+
+// CalculateBuffer prepares this entity and all its cells for rendering with the given parameters.
+func (te *TableEntity) CalculateBuffer(width, startX int, ctx DrawContext) int {
+	te.BaseEntity.CalculateBuffer(width, startX, ctx)
+	if te.columns == 0 {
+		return te.startX
+	}
+	te.rowHeights = make([]int, len(te.Rows))
+	te.listMode = !te.calculateColumnWidths(width, ctx)
+	if te.listMode {
+		te.calculateListBuffer(width, ctx)
+		return te.startX
+	}
+	// Top and bottom borders
+	te.height = 2
+	if te.HeaderRows > 0 && te.HeaderRows < len(te.Rows) {
+		te.height++
+	}
+	for i, row := range te.Rows {
+		rowHeight := 1
+		for col, cell := range row {
+			cell.CalculateBuffer(te.colWidths[col], 0, ctx)
+			rowHeight = max(rowHeight, cell.Height())
+		}
+		te.rowHeights[i] = rowHeight
+		te.height += rowHeight
+	}
+	return te.startX
+}
+
+func (te *TableEntity) drawBorder(screen mauview.Screen, y int, left, middle, right rune) {
+	x := 0
+	screen.SetContent(x, y, left, nil, te.Style)
+	for col, width := range te.colWidths {
+		for i := 0; i < width+2*tableCellPadding; i++ {
+			x++
+			screen.SetContent(x, y, '─', nil, te.Style)
+		}
+		x++
+		if col == len(te.colWidths)-1 {
+			screen.SetContent(x, y, right, nil, te.Style)
+		} else {
+			screen.SetContent(x, y, middle, nil, te.Style)
+		}
+	}
+}
+
+func (te *TableEntity) drawList(screen mauview.Screen, ctx DrawContext) {
+	width, _ := screen.Size()
+	y := 0
+	for i := te.HeaderRows; i < len(te.Rows); i++ {
+		if i != te.HeaderRows {
+			y++
+		}
+		for col, cell := range te.Rows[i] {
+			key := te.headerText(col, ctx)
+			if te.keyWidth > 2 {
+				// Truncate long headers so that the separator stays visible
+				key = runewidth.Truncate(key, te.keyWidth-2, "…")
+			}
+			key += ": "
+			widget.WriteLine(screen, mauview.AlignLeft, key, 0, y, te.keyWidth, te.Style.Bold(true))
+			cell.Draw(&mauview.ProxyScreen{
+				Parent:  screen,
+				OffsetX: te.keyWidth,
+				OffsetY: y,
+				Width:   width - te.keyWidth,
+				Height:  cell.Height(),
+				Style:   te.Style,
+			}, ctx)
+			y += max(cell.Height(), 1)
+		}
+	}
+}
+
+// Draw draws this entity onto the given mauview Screen.
+func (te *TableEntity) Draw(screen mauview.Screen, ctx DrawContext) {
+	if te.columns == 0 {
+		return
+	} else if te.listMode {
+		te.drawList(screen, ctx)
+		return
+	}
+	te.drawBorder(screen, 0, '┌', '┬', '┐')
+	y := 1
+	for i := range te.Rows {
+		if i == te.HeaderRows && i != 0 {
+			te.drawBorder(screen, y, '├', '┼', '┤')
+			y++
+		}
+		x := 0
+		for col, width := range te.colWidths {
+			for line := 0; line < te.rowHeights[i]; line++ {
+				screen.SetContent(x, y+line, '│', nil, te.Style)
+			}
+			x += 1 + tableCellPadding
+			if cell := te.cell(i, col); cell != nil {
+				cell.Draw(&mauview.ProxyScreen{
+					Parent:  screen,
+					OffsetX: x,
+					OffsetY: y,
+					Width:   width,
+					Height:  te.rowHeights[i],
+					Style:   te.Style,
+				}, ctx)
+			}
+			x += width + tableCellPadding
+		}
+		for line := 0; line < te.rowHeights[i]; line++ {
+			screen.SetContent(x, y+line, '│', nil, te.Style)
+		}
+		y += te.rowHeights[i]
+	}
+	te.drawBorder(screen, y, '└', '┴', '┘')
+}
+
+// PlainText returns the rows of the table with cells separated by vertical bars.
+func (te *TableEntity) PlainText() string {
+	var buf strings.Builder
+	for i, row := range te.Rows {
+		cells := make([]string, len(row))
+		for j, cell := range row {
+			cells[j] = strings.ReplaceAll(cell.PlainText(), "\n", " ")
+		}
+		buf.WriteString(strings.Join(cells, " | "))
+		buf.WriteRune('\n')
+		if i == te.HeaderRows-1 && i != len(te.Rows)-1 {
+			buf.WriteString(strings.Repeat("-", 5))
+			buf.WriteRune('\n')
+		}
+	}
+	return strings.TrimSpace(buf.String())
+}
+
+func (te *TableEntity) String() string {
+	return fmt.Sprintf("&html.TableEntity{Rows=%d, Columns=%d, HeaderRows=%d, Base=%s},\n",
+		len(te.Rows), te.columns, te.HeaderRows, te.BaseEntity)
+}
@@ -0,0 +1,138 @@
+// gomuks - A terminal Matrix client written in Go.
+// Copyright (C) 2026 Tulir Asokan
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+package html
+
+import (
+	"slices"
+	"strings"
+	"testing"
+
+	"go.mau.fi/gomuks/pkg/hicli/database"
+	"go.mau.fi/gomuks/pkg/rpc/store"
+	"go.mau.fi/gomuks/tui/config"
+)
+
+func parseTestHTML(htmlData string) Entity {
+	parser := htmlParser{
+		prefs: &config.UserPreferences{InlineURLMode: "disable"},
+		room:  store.NewRoomStore(store.NewStore(), &database.Room{ID: "!room:example.com"}),
+		evt:   &database.Event{ID: "$event"},
+	}
+	return parser.Parse(htmlData)
+}
+
+const (
+	testTableWithHeader = `<table><thead><tr><th>Name</th><th>Value</th></tr></thead>` +
+		`<tbody><tr><td><b>foo</b></td><td>1</td></tr><tr><td>bar</td><td><code>x</code></td></tr></tbody></table>`
+	testTableWrapping = `<table><tr><td>a long cell that wraps</td><td>b</td></tr></table>`
+	testTableRagged   = `<table><tr><td>a</td></tr><tr><td>b</td><td>c</td></tr></table>`
+)
+
+func TestTableEntity_Draw(t *testing.T) {
+	tests := []struct {
+		name     string
+		htmlData string
+		width    int
+		want     []string
+	}{
+		{"header", testTableWithHeader, 40, []string{
+			"┌──────┬───────┐",
+			"│ Name │ Value │",
+			"├──────┼───────┤",
+			"│ foo  │ 1     │",
+			"│ bar  │ x     │",
+			"└──────┴───────┘",
+		}},
+		{"header as list", testTableWithHeader, 14, []string{
+			"Name:  foo",
+			"Value: 1",
+			"",
+			"Name:  bar",
+			"Value: x",
+		}},
+		{"truncated list keys", testTableWithHeader, 12, []string{
+			"Name: foo",
+			"Val…: 1",
+			"",
+			"Name: bar",
+			"Val…: x",
+		}},
+		{"no wrapping needed", testTableWrapping, 40, []string{
+			"┌────────────────────────┬───┐",
+			"│ a long cell that wraps │ b │",
+			"└────────────────────────┴───┘",
+		}},
+		{"wrapped cell", testTableWrapping, 20, []string{
+			"┌──────────────┬───┐",
+			"│ a long cell  │ b │",
+			"│ that wraps   │   │",
+			"└──────────────┴───┘",
+		}},
+		{"list without header", testTableWrapping, 12, []string{
+			"Col…: a long",
+			"      cell",
+			"      that",
+			"      wraps",
+			"Col…: b",
+		}},
+		{"ragged rows", testTableRagged, 20, []string{
+			"┌───┬───┐",
+			"│ a │   │",
+			"│ b │ c │",
+			"└───┴───┘",
+		}},
+		{"surrounding paragraphs", "<p>before</p>" + testTableRagged + "<p>after</p>", 20, []string{
+			"before",
+			"┌───┬───┐",
+			"│ a │   │",
+			"│ b │ c │",
+			"└───┴───┘",
+			"after",
+		}},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			screen := checkLayout(t, parseTestHTML(test.htmlData), test.width, DrawContext{})
+			if got := screen.Lines(); !slices.Equal(got, test.want) {
+				t.Errorf("Table drawn at width %d doesn't match:\ngot:\n%s\nwant:\n%s",
+					test.width, strings.Join(got, "\n"), strings.Join(test.want, "\n"))
+			}
+		})
+	}
+}
+
+func TestTableEntity_Parse(t *testing.T) {
+	var table *TableEntity
+	if container, ok := parseTestHTML(testTableWithHeader).(*ContainerEntity); ok && len(container.Children) == 1 {
+		table, _ = container.Children[0].(*TableEntity)
+	}
+	if table == nil {
+		t.Fatalf("Expected a table entity, got %s", parseTestHTML(testTableWithHeader))
+	}
+	if table.HeaderRows != 1 || len(table.Rows) != 3 {
+		t.Errorf("Expected 1 header row and 3 rows total, got %d and %d", table.HeaderRows, len(table.Rows))
+	}
+	if cell := table.Rows[1][0].String(); !strings.Contains(cell, `Tag="b"`) {
+		t.Errorf("Expected bold formatting to be kept inside the cell, got %s", cell)
+	}
+	if want := "Name | Value\n-----\nfoo | 1\nbar | x"; table.PlainText() != want {
+		t.Errorf("PlainText() = %q, want %q", table.PlainText(), want)
+	}
+	if !NewTableEntity(nil, 0).IsEmpty() {
+		t.Error("Expected table without cells to be empty")
+	}
+}
@@ -19,11 +19,15 @@ package html
 import (
 	"fmt"
 	"regexp"
+	"strings"
 
 	"github.com/mattn/go-runewidth"
+	"github.com/rivo/uniseg"
 
 	"go.mau.fi/mauview"
 
+	"go.mau.fi/gomuks/tui/config"
+	"go.mau.fi/gomuks/tui/lib/emoji"
 	"go.mau.fi/gomuks/tui/widget"
 )
 
@@ -32,7 +36,8 @@ type TextEntity struct {
 	// Text in this entity.
 	Text string
 
-	buffer []string
+	buffer     []string
+	alignRight bool
 }
 
 // NewTextEntity creates a new text-only Entity.
@@ -45,6 +50,18 @@ func NewTextEntity(text string) *TextEntity {
 	}
 }
 
+// ApplyEmojiDisplay replaces or removes emojis in the given text according to the emoji display preference.
+func ApplyEmojiDisplay(text, mode string) string {
+	switch mode {
+	case config.EmojiDisplayShortcode:
+		return emoji.ToShortcodes(text)
+	case config.EmojiDisplayStrip:
+		return emoji.Strip(text)
+	default:
+		return text
+	}
+}
+
 func (te *TextEntity) IsEmpty() bool {
 	return len(te.Text) == 0
 }
@@ -72,15 +89,42 @@ func (te *TextEntity) String() string {
 func (te *TextEntity) Draw(screen mauview.Screen, ctx DrawContext) {
 	width, _ := screen.Size()
 	x := te.startX
+	align := mauview.AlignLeft
+	if te.alignRight {
+		align = mauview.AlignRight
+	}
+	style := te.Style
+	if ctx.maskSpoilers {
+		style = style.Foreground(SpoilerColor)
+	}
 	for y, line := range te.buffer {
-		widget.WriteLine(screen, mauview.AlignLeft, line, x, y, width, te.Style)
+		if ctx.maskSpoilers {
+			line = MaskSpoilerText(line)
+		}
+		widget.WriteLine(screen, align, line, x, y, width-x, style)
 		x = 0
 	}
 }
 
+// disableRightAlign is called by containers when another entity continues on the last line of this entity.
+func (te *TextEntity) disableRightAlign() {
+	te.alignRight = false
+}
+
+// forceBreak returns the first grapheme cluster of the text. It's used when not even a single character fits
+// on an empty line, as nothing would ever fit and the text would never get consumed otherwise.
+func forceBreak(text string) string {
+	cluster, _, _, _ := uniseg.FirstGraphemeClusterInString(text, -1)
+	return cluster
+}
+
 func (te *TextEntity) CalculateBuffer(width, startX int, ctx DrawContext) int {
 	te.BaseEntity.CalculateBuffer(width, startX, ctx)
-	if len(te.Text) == 0 {
+	te.alignRight = false
+	// Emojis are replaced here rather than when parsing, so that the wrapping is based on the replaced text
+	text := ApplyEmojiDisplay(te.Text, ctx.EmojiDisplay)
+	if len(text) == 0 {
+		te.buffer = te.buffer[:0]
 		return te.startX
 	}
 	te.height = 0
@@ -89,12 +133,13 @@ func (te *TextEntity) CalculateBuffer(width, startX int, ctx DrawContext) int {
 		te.buffer = []string{}
 	}
 	bufPtr := 0
-	text := te.Text
 	textStartX := te.startX
+	var extract string
 	for {
 		// TODO add option no wrap and character wrap options
-		extract := runewidth.Truncate(text, width-textStartX, "")
-		extract, wordWrapped := trim(extract, text, ctx.BareMessages)
+		var wordWrapped bool
+		extract = runewidth.Truncate(text, width-textStartX, "")
+		extract, wordWrapped = trim(extract, text, ctx.BareMessages)
 		if !wordWrapped && textStartX > 0 {
 			if bufPtr < len(te.buffer) {
 				te.buffer[bufPtr] = ""
@@ -104,6 +149,8 @@ func (te *TextEntity) CalculateBuffer(width, startX int, ctx DrawContext) int {
 			bufPtr++
 			textStartX = 0
 			continue
+		} else if len(extract) == 0 {
+			extract = forceBreak(text)
 		}
 		if bufPtr < len(te.buffer) {
 			te.buffer[bufPtr] = extract
@@ -113,17 +160,29 @@ func (te *TextEntity) CalculateBuffer(width, startX int, ctx DrawContext) int {
 		bufPtr++
 		text = text[len(extract):]
 		if len(text) == 0 {
-			te.buffer = te.buffer[:bufPtr]
-			te.height += len(te.buffer)
-			// This entity is over, return the startX for the next entity
-			if te.Block {
-				// ...except if it's a block entity
-				return 0
-			}
-			return textStartX + runewidth.StringWidth(extract)
+			break
 		}
 		textStartX = 0
 	}
+	te.buffer = te.buffer[:bufPtr]
+	te.height += len(te.buffer)
+	// Right-to-left paragraphs are right-aligned, unless there's something else on the same line before them.
+	// Containers disable the alignment if there's something after them on the same line too.
+	rtl := isRTLParagraph(te.Text)
+	te.alignRight = rtl && te.startX == 0
+	for i, line := range te.buffer {
+		if rtl {
+			line = strings.TrimRight(line, " ")
+		}
+		te.buffer[i] = visualOrder(line, rtl)
+	}
+	// This entity is over, return the startX for the next entity
+	if te.Block {
+		// ...except if it's a block entity
+		return 0
+	}
+	// Trailing spaces may go past the width, but the next entity must still start inside the line
+	return max(min(textStartX+runewidth.StringWidth(extract), width), 0)
 }
 
 var (
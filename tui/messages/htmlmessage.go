@@ -55,7 +55,11 @@ func (hw *HTMLMessage) Draw(screen mauview.Screen, msg *UIMessage) {
 		}, html.AdjustStyleReasonNormal)
 	}
 	screen.Clear()
-	hw.Root.Draw(screen, html.DrawContext{IsSelected: msg.IsSelected})
+	hw.Root.Draw(screen, html.DrawContext{
+		IsSelected:     msg.IsSelected,
+		RevealSpoilers: msg.RevealSpoilers,
+		EmojiDisplay:   msg.EmojiDisplay,
+	})
 }
 
 func (hw *HTMLMessage) OnKeyEvent(event mauview.KeyEvent) bool {
@@ -80,11 +84,14 @@ func (hw *HTMLMessage) CalculateBuffer(preferences config.UserPreferences, width
 	hw.Root.CalculateBuffer(width, startX, html.DrawContext{
 		IsSelected:   msg.IsSelected,
 		BareMessages: preferences.BareMessageView,
+		EmojiDisplay: msg.EmojiDisplay,
 	})
 }
 
+// Height returns the height of the root entity. Messages always take at least one line,
+// so that the sender and timestamp are visible even if the content renders as nothing.
 func (hw *HTMLMessage) Height() int {
-	return hw.Root.Height()
+	return max(hw.Root.Height(), 1)
 }
 
 func (hw *HTMLMessage) PlainText() string {
@@ -0,0 +1,61 @@
+// gomuks - A terminal Matrix client written in Go.
+// Copyright (C) 2026 Tulir Asokan
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+package messages
+
+import (
+	"fmt"
+
+	"github.com/gdamore/tcell/v2"
+	"maunium.net/go/mautrix/event"
+
+	"go.mau.fi/gomuks/pkg/hicli/database"
+	"go.mau.fi/gomuks/pkg/rpc/store"
+	"go.mau.fi/gomuks/tui/messages/tstring"
+)
+
+// GetRemovingBan returns the ban event that removes the given message under MSC4293, or nil if the message
+// should be shown normally. Messages are removed if the current membership of the sender is a ban with the
+// redact_events flag set by a user who would be allowed to redact the messages. Nothing is stored for
+// removed messages, so unbanning the user makes the messages visible again.
+func GetRemovingBan(room *store.RoomStore, evt *database.Event) *database.Event {
+	if evt.RedactedBy != "" || evt.RelationType == event.RelReplace {
+		return nil
+	} else if evtType := evt.GetType(); evtType != event.EventMessage && evtType != event.EventSticker {
+		return nil
+	}
+	memberEvt := room.GetStateEvent(event.StateMember, evt.Sender.String())
+	if memberEvt == nil {
+		return nil
+	}
+	content := memberEvt.GetMautrixContent().AsMember()
+	if content.Membership != event.MembershipBan || !content.MSC4293RedactEvents {
+		return nil
+	}
+	pls := room.GetPowerLevels()
+	if pls.GetUserLevel(memberEvt.Sender) < pls.Redact() {
+		return nil
+	}
+	return memberEvt
+}
+
+// NewBanRemovedMessage creates a placeholder for a message that was removed by the given ban event.
+func NewBanRemovedMessage(evt *database.Event, room *store.RoomStore, banEvt *database.Event) *UIMessage {
+	text := fmt.Sprintf("Message removed (user banned by %s)", room.GetDisplayname(banEvt.Sender))
+	msg := NewExpandedTextMessage(evt, room, tstring.NewStyleTString(text, tcell.StyleDefault.Italic(true)))
+	msg.BanRemoved = true
+	return msg
+}
@@ -0,0 +1,133 @@
+// gomuks - A terminal Matrix client written in Go.
+// Copyright (C) 2026 Tulir Asokan
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+package messages
+
+import (
+	"encoding/json"
+	"testing"
+
+	"maunium.net/go/mautrix/event"
+	"maunium.net/go/mautrix/id"
+
+	"go.mau.fi/gomuks/pkg/hicli/database"
+	"go.mau.fi/gomuks/pkg/rpc/store"
+	"go.mau.fi/gomuks/tui/config"
+)
+
+const (
+	testModerator id.UserID = "@mod:example.com"
+	testBanned    id.UserID = "@bob:example.com"
+)
+
+func newModerationTestRoom() *store.RoomStore {
+	room := newTestRoom()
+	stateKey := ""
+	room.ApplyState(&database.Event{
+		RowID:    49,
+		RoomID:   testRoomID,
+		ID:       "$create",
+		Sender:   testModerator,
+		Type:     event.StateCreate.Type,
+		StateKey: &stateKey,
+		Content:  json.RawMessage(`{"room_version":"11"}`),
+	})
+	room.ApplyState(&database.Event{
+		RowID:    50,
+		RoomID:   testRoomID,
+		ID:       "$powerlevels",
+		Sender:   testModerator,
+		Type:     event.StatePowerLevels.Type,
+		StateKey: &stateKey,
+		Content:  json.RawMessage(`{"users":{"@mod:example.com":100},"redact":50}`),
+	})
+	applyTestMembership(room, 51, testModerator, testModerator, `{"membership":"join","displayname":"Mod"}`)
+	return room
+}
+
+func applyTestMembership(room *store.RoomStore, rowID database.EventRowID, sender, target id.UserID, content string) *database.Event {
+	stateKey := target.String()
+	evt := &database.Event{
+		RowID:    rowID,
+		RoomID:   testRoomID,
+		ID:       id.EventID("$member" + string(rune('a'+rowID-50))),
+		Sender:   sender,
+		Type:     event.StateMember.Type,
+		StateKey: &stateKey,
+		Content:  json.RawMessage(content),
+	}
+	room.ApplyState(evt)
+	return evt
+}
+
+func TestGetRemovingBan_BanUnban(t *testing.T) {
+	room := newModerationTestRoom()
+	msgEvt := newTestMessage(10, testBanned, `{"msgtype":"m.text","body":"spam"}`)
+	if GetRemovingBan(room, msgEvt) != nil {
+		t.Fatal("Message from a joined user was removed")
+	}
+	banEvt := applyTestMembership(room, 52, testModerator, testBanned, `{"membership":"ban","org.matrix.msc4293.redact_events":true}`)
+	if got := GetRemovingBan(room, msgEvt); got == nil || got.ID != banEvt.ID {
+		t.Fatalf("GetRemovingBan() after ban = %v, want %s", got, banEvt.ID)
+	}
+	placeholder := NewBanRemovedMessage(msgEvt, room, banEvt)
+	if !placeholder.BanRemoved {
+		t.Error("Placeholder isn't marked as removed by a ban")
+	} else if got := placeholder.PlainText(); got != "Message removed (user banned by Mod)" {
+		t.Errorf("Placeholder text = %q", got)
+	}
+	applyTestMembership(room, 53, testModerator, testBanned, `{"membership":"leave"}`)
+	if got := GetRemovingBan(room, msgEvt); got != nil {
+		t.Errorf("Message is still removed by %s after unban", got.ID)
+	}
+	msg := ParseEvent(nil, &config.UserPreferences{DisableDownloads: true}, room, msgEvt)
+	if msg == nil || msg.BanRemoved || msg.PlainText() != "spam" {
+		t.Errorf("Message after unban wasn't rendered normally: %v", msg)
+	}
+}
+
+func TestGetRemovingBan(t *testing.T) {
+	tests := []struct {
+		name       string
+		banSender  id.UserID
+		banContent string
+		msgType    event.Type
+		redacted   bool
+		want       bool
+	}{
+		{"ban with redact_events", testModerator, `{"membership":"ban","org.matrix.msc4293.redact_events":true}`, event.EventMessage, false, true},
+		{"sticker", testModerator, `{"membership":"ban","org.matrix.msc4293.redact_events":true}`, event.EventSticker, false, true},
+		{"ban without redact_events", testModerator, `{"membership":"ban"}`, event.EventMessage, false, false},
+		{"kick with redact_events", testModerator, `{"membership":"leave","org.matrix.msc4293.redact_events":true}`, event.EventMessage, false, false},
+		{"banned by user without redact permission", testSender, `{"membership":"ban","org.matrix.msc4293.redact_events":true}`, event.EventMessage, false, false},
+		{"already redacted", testModerator, `{"membership":"ban","org.matrix.msc4293.redact_events":true}`, event.EventMessage, true, false},
+		{"reaction", testModerator, `{"membership":"ban","org.matrix.msc4293.redact_events":true}`, event.EventReaction, false, false},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			room := newModerationTestRoom()
+			applyTestMembership(room, 52, test.banSender, testBanned, test.banContent)
+			msgEvt := newTestMessage(10, testBanned, `{"msgtype":"m.text","body":"spam"}`)
+			msgEvt.Type = test.msgType.Type
+			if test.redacted {
+				msgEvt.RedactedBy = "$redaction"
+			}
+			if got := GetRemovingBan(room, msgEvt) != nil; got != test.want {
+				t.Errorf("GetRemovingBan() returned ban: %t, want %t", got, test.want)
+			}
+		})
+	}
+}
@@ -18,6 +18,8 @@ package messages
 
 import (
 	"fmt"
+	"maps"
+	"slices"
 	"strings"
 
 	"github.com/gdamore/tcell/v2"
@@ -39,29 +41,41 @@ func ParseEvent(matrix *client.GomuksClient, prefs *config.UserPreferences, room
 	if msg == nil {
 		return nil
 	}
+	msg.applyProfileMode(prefs)
 	if replyTo := evt.GetReplyTo(); len(replyTo) > 0 {
-		if replyToEvt := room.GetEventByID(replyTo); replyToEvt != nil {
-			if replyToMsg, ok := replyToEvt.RenderMeta.(*UIMessage); ok {
-				if replyToMsg != nil {
-					msg.ReplyTo = replyToMsg.Clone()
-					msg.ReplyTo.IsReplyBubble = true
-				} else {
-					// TODO something
-				}
-			} else if replyToMsg = directParseEvent(matrix, prefs, room, replyToEvt); replyToMsg != nil {
-				msg.ReplyTo = replyToMsg
-				msg.ReplyTo.IsReplyBubble = true
-			} else {
-				// TODO add unrenderable reply header
-			}
-		} else {
-			// TODO request reply event from backend
-			// TODO add unknown reply header
-		}
+		msg.ReplyToID = replyTo
+		msg.ReplyTo, msg.ReplyState = parseReplyTarget(matrix, prefs, room, replyTo)
 	}
 	return msg
 }
 
+// parseReplyTarget renders the replied-to event as a reply bubble. If it can't be rendered, the returned
+// state describes why. Events that aren't loaded locally get the loading state, and the room view is
+// expected to fetch them and classify the result.
+func parseReplyTarget(matrix *client.GomuksClient, prefs *config.UserPreferences, room *store.RoomStore, replyTo id.EventID) (*UIMessage, ReplyState) {
+	replyToEvt := room.GetEventByID(replyTo)
+	if replyToEvt == nil {
+		return nil, ReplyStateLoading
+	} else if replyToEvt.RedactedBy != "" {
+		return nil, ReplyStateDeleted
+	}
+	var replyToMsg *UIMessage
+	if cached, ok := replyToEvt.RenderMeta.(*UIMessage); ok {
+		if cached == nil {
+			return nil, ReplyStateUnknown
+		}
+		replyToMsg = cached.Clone()
+	} else if banEvt := GetRemovingBan(room, replyToEvt); banEvt != nil && !prefs.RevealBanRemoved {
+		replyToMsg = NewBanRemovedMessage(replyToEvt, room, banEvt)
+	} else if replyToMsg = directParseEvent(matrix, prefs, room, replyToEvt); replyToMsg != nil {
+		replyToMsg.applyProfileMode(prefs)
+	} else {
+		return nil, ReplyStateUnknown
+	}
+	replyToMsg.IsReplyBubble = true
+	return replyToMsg, ReplyStateNone
+}
+
 func directParseEvent(matrix *client.GomuksClient, prefs *config.UserPreferences, room *store.RoomStore, evt *database.Event) *UIMessage {
 	if evt.DecryptionError != "" {
 		return NewExpandedTextMessage(evt, room, tstring.NewStyleTString(evt.DecryptionError, tcell.StyleDefault.Italic(true)))
@@ -74,15 +88,223 @@ func directParseEvent(matrix *client.GomuksClient, prefs *config.UserPreferences
 			return NewRedactedMessage(evt, room)
 		}
 		return ParseMessage(matrix, prefs, room, evt)
-	case event.StateTopic, event.StateRoomName, event.StateCanonicalAlias:
+	case event.StateTopic, event.StateRoomName, event.StateRoomAvatar, event.StateCanonicalAlias, event.StateThirdPartyInvite,
+		event.StateEncryption, event.StateJoinRules, event.StateHistoryVisibility, event.StateGuestAccess, event.StatePowerLevels,
+		event.StateServerACL:
 		return ParseStateEvent(room, evt)
 	case event.StateMember:
 		return ParseMembershipEvent(room, evt)
+	case store.StateMSC3401CallMember:
+		return ParseCallMemberEvent(room, evt)
 	default:
 		return nil
 	}
 }
 
+// parsePrevContent parses the prev_content of a state event, or returns nil if it's missing or not the expected type.
+func parsePrevContent[T any](mEvt *event.Event) *T {
+	if mEvt.Unsigned.PrevContent == nil {
+		return nil
+	}
+	_ = mEvt.Unsigned.PrevContent.ParseRaw(mEvt.Type)
+	parsed, _ := mEvt.Unsigned.PrevContent.Parsed.(*T)
+	return parsed
+}
+
+func describeJoinRule(content, prevContent *event.JoinRulesEventContent) tstring.TString {
+	var spaceCount int
+	for _, allow := range content.Allow {
+		if allow.Type == event.JoinRuleAllowRoomMembership {
+			spaceCount++
+		}
+	}
+	spaces := "1 space"
+	if spaceCount != 1 {
+		spaces = fmt.Sprintf("%d spaces", spaceCount)
+	}
+	var desc string
+	switch content.JoinRule {
+	case event.JoinRulePublic:
+		desc = "made the room public."
+	case event.JoinRuleInvite:
+		desc = "made the room invite-only."
+	case event.JoinRuleKnock:
+		desc = "made the room invite-only and allowed anyone to ask to join."
+	case event.JoinRulePrivate:
+		desc = "made the room private."
+	case event.JoinRuleRestricted:
+		if prevContent != nil && prevContent.JoinRule == content.JoinRule {
+			desc = fmt.Sprintf("changed the room to be joinable by members of %s.", spaces)
+		} else {
+			desc = fmt.Sprintf("made the room joinable by members of %s.", spaces)
+		}
+	case event.JoinRuleKnockRestricted:
+		desc = fmt.Sprintf("made the room joinable by members of %s and allowed anyone else to ask to join.", spaces)
+	default:
+		return tstring.NewColorTString("changed the join rule to ", tcell.ColorGreen).
+			AppendStyle(string(content.JoinRule), tcell.StyleDefault.Underline(true)).
+			AppendColor(".", tcell.ColorGreen)
+	}
+	return tstring.NewColorTString(desc, tcell.ColorGreen)
+}
+
+func describeHistoryVisibility(visibility event.HistoryVisibility) string {
+	switch visibility {
+	case event.HistoryVisibilityWorldReadable:
+		return "anyone"
+	case event.HistoryVisibilityShared:
+		return "members"
+	case event.HistoryVisibilityInvited:
+		return "members since they were invited"
+	case event.HistoryVisibilityJoined:
+		return "members since they joined"
+	default:
+		return string(visibility)
+	}
+}
+
+// findPatternDifference returns the patterns that were added to and removed from a server ACL list.
+func findPatternDifference(newList, oldList []string) (added, removed []string) {
+	for _, pattern := range newList {
+		if !slices.Contains(oldList, pattern) {
+			added = append(added, pattern)
+		}
+	}
+	for _, pattern := range oldList {
+		if !slices.Contains(newList, pattern) {
+			removed = append(removed, pattern)
+		}
+	}
+	return
+}
+
+func joinServerPatterns(prefix string, patterns []string) tstring.TString {
+	items := make([]tstring.TString, len(patterns))
+	for i, pattern := range patterns {
+		items[i] = tstring.NewStyleTString(pattern, tcell.StyleDefault.Underline(true))
+	}
+	text := tstring.NewColorTString(prefix, tcell.ColorGreen)
+	if len(items) == 1 {
+		return text.AppendTString(items[0])
+	}
+	return text.AppendTString(tstring.Join(items[:len(items)-1], ", ")).
+		AppendColor(" and ", tcell.ColorGreen).
+		AppendTString(items[len(items)-1])
+}
+
+// withServerACLDefaults applies the default of allow_ip_literals being true when the field is missing.
+func withServerACLDefaults(content *event.ServerACLEventContent, raw map[string]any) *event.ServerACLEventContent {
+	if _, ok := raw["allow_ip_literals"]; ok {
+		return content
+	}
+	withDefault := *content
+	withDefault.AllowIPLiterals = true
+	return &withDefault
+}
+
+// describeServerACL summarizes the changes to the server ACL. If there's no previous ACL, the changes are
+// compared to the default of allowing all servers.
+func describeServerACL(content, prevContent *event.ServerACLEventContent) tstring.TString {
+	if prevContent == nil {
+		prevContent = &event.ServerACLEventContent{Allow: []string{"*"}, AllowIPLiterals: true}
+	}
+	var parts []tstring.TString
+	addedDeny, removedDeny := findPatternDifference(content.Deny, prevContent.Deny)
+	addedAllow, removedAllow := findPatternDifference(content.Allow, prevContent.Allow)
+	if len(addedDeny) > 0 {
+		parts = append(parts, joinServerPatterns("banned servers matching ", addedDeny))
+	}
+	if len(removedDeny) > 0 {
+		parts = append(parts, joinServerPatterns("unbanned servers matching ", removedDeny))
+	}
+	if len(addedAllow) > 0 {
+		parts = append(parts, joinServerPatterns("allowed servers matching ", addedAllow))
+	}
+	if len(removedAllow) > 0 {
+		parts = append(parts, joinServerPatterns("stopped allowing servers matching ", removedAllow))
+	}
+	if content.AllowIPLiterals != prevContent.AllowIPLiterals {
+		if content.AllowIPLiterals {
+			parts = append(parts, tstring.NewColorTString("allowed servers using IP addresses", tcell.ColorGreen))
+		} else {
+			parts = append(parts, tstring.NewColorTString("banned servers using IP addresses", tcell.ColorGreen))
+		}
+	}
+	switch len(parts) {
+	case 0:
+		return tstring.NewColorTString("changed the server ACL without changing anything.", tcell.ColorGreen)
+	case 1:
+		return parts[0].AppendColor(".", tcell.ColorGreen)
+	default:
+		return tstring.Join(parts[:len(parts)-1], ", ").
+			AppendColor(" and ", tcell.ColorGreen).
+			AppendTString(parts[len(parts)-1]).
+			AppendColor(".", tcell.ColorGreen)
+	}
+}
+
+// findPowerLevelDifference lists the power levels that changed between the previous and new content
+// as "name old→new" strings. Users who aren't listed explicitly have the users_default level.
+func findPowerLevelDifference(room *store.RoomStore, content, prevContent *event.PowerLevelsEventContent) []tstring.TString {
+	var changes []tstring.TString
+	addChange := func(name tstring.TString, oldLevel, newLevel int) {
+		if oldLevel != newLevel {
+			changes = append(changes, name.Append(fmt.Sprintf(" %d→%d", oldLevel, newLevel)))
+		}
+	}
+	notificationLevel := func(pl *event.PowerLevelsEventContent) int {
+		if pl.Notifications != nil && pl.Notifications.RoomPtr != nil {
+			return *pl.Notifications.RoomPtr
+		}
+		return 50
+	}
+	addChange(tstring.NewTString("ban"), prevContent.Ban(), content.Ban())
+	addChange(tstring.NewTString("kick"), prevContent.Kick(), content.Kick())
+	addChange(tstring.NewTString("redact"), prevContent.Redact(), content.Redact())
+	addChange(tstring.NewTString("invite"), prevContent.Invite(), content.Invite())
+	addChange(tstring.NewTString("state_default"), prevContent.StateDefault(), content.StateDefault())
+	addChange(tstring.NewTString("events_default"), prevContent.EventsDefault, content.EventsDefault)
+	addChange(tstring.NewTString("users_default"), prevContent.UsersDefault, content.UsersDefault)
+	addChange(tstring.NewTString("notifications.room"), notificationLevel(prevContent), notificationLevel(content))
+
+	eventTypes := slices.Sorted(maps.Keys(content.Events))
+	for evtType := range prevContent.Events {
+		if _, ok := content.Events[evtType]; !ok {
+			eventTypes = append(eventTypes, evtType)
+		}
+	}
+	for _, evtType := range eventTypes {
+		oldLevel, ok := prevContent.Events[evtType]
+		if !ok {
+			oldLevel = prevContent.EventsDefault
+		}
+		newLevel, ok := content.Events[evtType]
+		if !ok {
+			newLevel = content.EventsDefault
+		}
+		addChange(tstring.NewTString(evtType), oldLevel, newLevel)
+	}
+
+	userIDs := slices.Sorted(maps.Keys(content.Users))
+	for userID := range prevContent.Users {
+		if _, ok := content.Users[userID]; !ok {
+			userIDs = append(userIDs, userID)
+		}
+	}
+	for _, userID := range userIDs {
+		oldLevel, ok := prevContent.Users[userID]
+		if !ok {
+			oldLevel = prevContent.UsersDefault
+		}
+		newLevel, ok := content.Users[userID]
+		if !ok {
+			newLevel = content.UsersDefault
+		}
+		addChange(tstring.NewColorTString(room.GetDisplayname(userID), widget.GetHashColor(userID)), oldLevel, newLevel)
+	}
+	return changes
+}
+
 func findAltAliasDifference(newList, oldList []id.RoomAlias) (addedStr, removedStr tstring.TString) {
 	var addedList, removedList []tstring.TString
 OldLoop:
@@ -145,6 +367,79 @@ func ParseStateEvent(room *store.RoomStore, evt *database.Event) *UIMessage {
 				AppendStyle(content.Name, tcell.StyleDefault.Underline(true)).
 				AppendColor(".", tcell.ColorGreen)
 		}
+	case *event.RoomAvatarEventContent:
+		prevContent := &event.RoomAvatarEventContent{}
+		if mEvt.Unsigned.PrevContent != nil {
+			_ = mEvt.Unsigned.PrevContent.ParseRaw(mEvt.Type)
+			if parsed, ok := mEvt.Unsigned.PrevContent.Parsed.(*event.RoomAvatarEventContent); ok {
+				prevContent = parsed
+			}
+		}
+		if len(content.URL) == 0 {
+			text = text.AppendColor("removed the room avatar.", tcell.ColorGreen)
+		} else if len(prevContent.URL) == 0 {
+			text = text.AppendColor("set the room avatar.", tcell.ColorGreen)
+		} else {
+			text = text.AppendColor("changed the room avatar.", tcell.ColorGreen)
+		}
+	case *event.ThirdPartyInviteEventContent:
+		if len(content.DisplayName) == 0 {
+			prevContent := &event.ThirdPartyInviteEventContent{}
+			if mEvt.Unsigned.PrevContent != nil {
+				_ = mEvt.Unsigned.PrevContent.ParseRaw(mEvt.Type)
+				if parsed, ok := mEvt.Unsigned.PrevContent.Parsed.(*event.ThirdPartyInviteEventContent); ok {
+					prevContent = parsed
+				}
+			}
+			if len(prevContent.DisplayName) == 0 {
+				text = text.AppendColor("revoked an invite sent via email.", tcell.ColorRed)
+			} else {
+				text = text.AppendColor("revoked the invite for ", tcell.ColorRed).
+					AppendStyle(prevContent.DisplayName, tcell.StyleDefault.Underline(true)).
+					AppendColor(" sent via email.", tcell.ColorRed)
+			}
+		} else {
+			text = text.AppendColor("invited ", tcell.ColorGreen).
+				AppendStyle(content.DisplayName, tcell.StyleDefault.Underline(true)).
+				AppendColor(" via email.", tcell.ColorGreen)
+		}
+	case *event.EncryptionEventContent:
+		if content.Algorithm == id.AlgorithmMegolmV1 {
+			text = text.AppendColor("enabled end-to-end encryption.", tcell.ColorGreen)
+		} else {
+			text = text.AppendColor("enabled end-to-end encryption with an unsupported algorithm ", tcell.ColorRed).
+				AppendStyle(string(content.Algorithm), tcell.StyleDefault.Underline(true)).
+				AppendColor(".", tcell.ColorRed)
+		}
+	case *event.JoinRulesEventContent:
+		text = text.AppendTString(describeJoinRule(content, parsePrevContent[event.JoinRulesEventContent](mEvt)))
+	case *event.HistoryVisibilityEventContent:
+		text = text.AppendColor("set history visibility to: ", tcell.ColorGreen).
+			AppendStyle(describeHistoryVisibility(content.HistoryVisibility), tcell.StyleDefault.Underline(true)).
+			AppendColor(".", tcell.ColorGreen)
+	case *event.GuestAccessEventContent:
+		if content.GuestAccess == event.GuestAccessCanJoin {
+			text = text.AppendColor("allowed guests to join the room.", tcell.ColorGreen)
+		} else {
+			text = text.AppendColor("prevented guests from joining the room.", tcell.ColorGreen)
+		}
+	case *event.PowerLevelsEventContent:
+		prevContent := parsePrevContent[event.PowerLevelsEventContent](mEvt)
+		if prevContent == nil {
+			text = text.AppendColor("set the power levels.", tcell.ColorGreen)
+		} else if changes := findPowerLevelDifference(room, content, prevContent); len(changes) == 0 {
+			text = text.AppendColor("changed the power levels without changing any values.", tcell.ColorGreen)
+		} else {
+			text = text.AppendColor("changed power levels: ", tcell.ColorGreen).
+				AppendTString(tstring.Join(changes, ", ")).
+				AppendColor(".", tcell.ColorGreen)
+		}
+	case *event.ServerACLEventContent:
+		prevContent := parsePrevContent[event.ServerACLEventContent](mEvt)
+		if prevContent != nil {
+			prevContent = withServerACLDefaults(prevContent, mEvt.Unsigned.PrevContent.Raw)
+		}
+		text = text.AppendTString(describeServerACL(withServerACLDefaults(content, mEvt.Content.Raw), prevContent))
 	case *event.CanonicalAliasEventContent:
 		prevContent := &event.CanonicalAliasEventContent{}
 		if mEvt.Unsigned.PrevContent != nil {
@@ -184,11 +479,10 @@ func ParseMessage(matrix *client.GomuksClient, prefs *config.UserPreferences, ro
 	content := evt.GetMautrixContent().AsMessage()
 	switch content.MsgType {
 	case event.MsgText, event.MsgNotice, event.MsgEmote:
+		StripReplyFallback(evt, content)
 		var htmlEntity html.Entity
 		if content.Format == event.FormatHTML && len(content.FormattedBody) > 0 {
-			// TODO make this update
-			displayname := room.GetDisplayname(evt.Sender)
-			htmlEntity = html.Parse(prefs, room, content, evt, displayname)
+			htmlEntity = html.Parse(prefs, room, content, evt)
 			if htmlEntity == nil {
 				htmlEntity = html.NewTextEntity("Malformed message")
 				htmlEntity.AdjustStyle(html.AdjustStyleTextColor(tcell.ColorRed), html.AdjustStyleReasonNormal)
@@ -200,6 +494,12 @@ func ParseMessage(matrix *client.GomuksClient, prefs *config.UserPreferences, ro
 			htmlEntity = html.NewTextEntity("Blank message")
 			htmlEntity.AdjustStyle(html.AdjustStyleTextColor(tcell.ColorRed), html.AdjustStyleReasonNormal)
 		}
+		if content.MsgType == event.MsgEmote {
+			// TODO make this update
+			mode := prefs.GetPerMessageProfiles()
+			senderColor := widget.GetHashColor(SenderColorKey(mode, evt))
+			htmlEntity = html.NewEmoteEntity(senderColor, SenderDisplayName(mode, room, evt), htmlEntity)
+		}
 		return NewHTMLMessage(room, evt, content, htmlEntity)
 	case event.MsgImage, event.MsgVideo, event.MsgAudio, event.MsgFile:
 		msg := NewFileMessage(room, matrix, evt, content)
@@ -212,47 +512,97 @@ func ParseMessage(matrix *client.GomuksClient, prefs *config.UserPreferences, ro
 	return nil
 }
 
+// appendMembershipReason appends the MSC4293 redaction flag and the reason of a membership change,
+// or just a period if there's no reason.
+func appendMembershipReason(text tstring.TString, content *event.MemberEventContent, color tcell.Color) tstring.TString {
+	if content.MSC4293RedactEvents {
+		text = text.AppendColor(" and their messages were removed", color)
+	}
+	if content.Reason != "" {
+		return text.AppendColor(": "+content.Reason, color)
+	}
+	return text.AppendColor(".", color)
+}
+
 func getMembershipChangeMessage(evt *database.Event, content *event.MemberEventContent, prevMembership event.Membership, senderDisplayname, displayname, prevDisplayname string) (sender string, text tstring.TString) {
+	senderColor := widget.GetHashColor(evt.Sender)
+	targetColor := widget.GetHashColor(evt.StateKey)
 	switch content.Membership {
-	case "invite":
+	case event.MembershipInvite:
 		sender = "---"
-		text = tstring.NewColorTString(fmt.Sprintf("%s invited %s.", senderDisplayname, displayname), tcell.ColorGreen)
-		text.Colorize(0, len(senderDisplayname), widget.GetHashColor(evt.Sender))
-		text.Colorize(len(senderDisplayname)+len(" invited "), len(displayname), widget.GetHashColor(evt.StateKey))
-	case "join":
+		text = tstring.NewColorTString(senderDisplayname, senderColor)
+		if prevMembership == event.MembershipKnock {
+			text = text.
+				AppendColor(" accepted the request to join from ", tcell.ColorGreen).
+				AppendColor(displayname, targetColor)
+		} else {
+			text = text.
+				AppendColor(" invited ", tcell.ColorGreen).
+				AppendColor(displayname, targetColor)
+		}
+		if content.ThirdPartyInvite != nil {
+			text = text.AppendColor(" via a third-party invite", tcell.ColorGreen)
+			if content.ThirdPartyInvite.DisplayName != "" {
+				text = text.AppendColor(" for "+content.ThirdPartyInvite.DisplayName, tcell.ColorGreen)
+			}
+		}
+		text = appendMembershipReason(text, content, tcell.ColorGreen)
+	case event.MembershipJoin:
 		sender = "-->"
+		text = tstring.NewColorTString(displayname, targetColor)
 		if prevMembership == event.MembershipInvite {
-			text = tstring.NewColorTString(fmt.Sprintf("%s accepted the invite.", displayname), tcell.ColorGreen)
+			text = text.AppendColor(" accepted the invite", tcell.ColorGreen)
 		} else {
-			text = tstring.NewColorTString(fmt.Sprintf("%s joined the room.", displayname), tcell.ColorGreen)
+			text = text.AppendColor(" joined the room", tcell.ColorGreen)
 		}
-		text.Colorize(0, len(displayname), widget.GetHashColor(evt.StateKey))
-	case "leave":
+		text = appendMembershipReason(text, content, tcell.ColorGreen)
+	case event.MembershipKnock:
+		sender = "---"
+		text = tstring.NewColorTString(displayname, targetColor).
+			AppendColor(" requested to join", tcell.ColorGreen)
+		text = appendMembershipReason(text, content, tcell.ColorGreen)
+	case event.MembershipLeave:
 		sender = "<--"
+		if displayname == *evt.StateKey {
+			// Leave events usually don't have a display name, so use the one from before leaving
+			displayname = prevDisplayname
+		}
 		if evt.Sender != id.UserID(*evt.StateKey) {
-			if prevMembership == event.MembershipBan {
-				text = tstring.NewColorTString(fmt.Sprintf("%s unbanned %s", senderDisplayname, displayname), tcell.ColorGreen)
-				text.Colorize(len(senderDisplayname)+len(" unbanned "), len(displayname), widget.GetHashColor(evt.StateKey))
-			} else {
-				text = tstring.NewColorTString(fmt.Sprintf("%s kicked %s: %s", senderDisplayname, displayname, content.Reason), tcell.ColorRed)
-				text.Colorize(len(senderDisplayname)+len(" kicked "), len(displayname), widget.GetHashColor(evt.StateKey))
+			text = tstring.NewColorTString(senderDisplayname, senderColor)
+			color := tcell.ColorRed
+			switch prevMembership {
+			case event.MembershipBan:
+				color = tcell.ColorGreen
+				text = text.AppendColor(" unbanned ", color).AppendColor(displayname, targetColor)
+			case event.MembershipInvite:
+				text = text.AppendColor(" revoked the invite for ", color).AppendColor(displayname, targetColor)
+			case event.MembershipKnock:
+				text = text.AppendColor(" rejected the request to join from ", color).AppendColor(displayname, targetColor)
+			default:
+				text = text.AppendColor(" kicked ", color).AppendColor(displayname, targetColor)
 			}
-			text.Colorize(0, len(senderDisplayname), widget.GetHashColor(evt.Sender))
+			text = appendMembershipReason(text, content, color)
 		} else {
-			if displayname == *evt.StateKey {
-				displayname = prevDisplayname
-			}
-			if prevMembership == event.MembershipInvite {
-				text = tstring.NewColorTString(fmt.Sprintf("%s rejected the invite.", displayname), tcell.ColorRed)
-			} else {
-				text = tstring.NewColorTString(fmt.Sprintf("%s left the room.", displayname), tcell.ColorRed)
+			text = tstring.NewColorTString(displayname, targetColor)
+			switch prevMembership {
+			case event.MembershipInvite:
+				text = text.AppendColor(" rejected the invite", tcell.ColorRed)
+			case event.MembershipKnock:
+				text = text.AppendColor(" retracted their request to join", tcell.ColorRed)
+			default:
+				text = text.AppendColor(" left the room", tcell.ColorRed)
 			}
-			text.Colorize(0, len(displayname), widget.GetHashColor(evt.StateKey))
+			text = appendMembershipReason(text, content, tcell.ColorRed)
 		}
-	case "ban":
-		text = tstring.NewColorTString(fmt.Sprintf("%s banned %s: %s", senderDisplayname, displayname, content.Reason), tcell.ColorRed)
-		text.Colorize(len(senderDisplayname)+len(" banned "), len(displayname), widget.GetHashColor(evt.StateKey))
-		text.Colorize(0, len(senderDisplayname), widget.GetHashColor(evt.Sender))
+	case event.MembershipBan:
+		sender = "<--"
+		if displayname == *evt.StateKey {
+			displayname = prevDisplayname
+		}
+		text = tstring.NewColorTString(senderDisplayname, senderColor).
+			AppendColor(" banned ", tcell.ColorRed).
+			AppendColor(displayname, targetColor)
+		text = appendMembershipReason(text, content, tcell.ColorRed)
 	}
 	return
 }
@@ -273,11 +623,13 @@ func getMembershipEventContent(room *store.RoomStore, evt *database.Event) (send
 
 	prevMembership := event.MembershipLeave
 	prevDisplayname := *evt.StateKey
+	prevAvatarURL := content.AvatarURL
 	if mEvt.Unsigned.PrevContent != nil {
 		_ = mEvt.Unsigned.PrevContent.ParseRaw(mEvt.Type)
 		prevContent := mEvt.Unsigned.PrevContent.AsMember()
 		prevMembership = prevContent.Membership
 		prevDisplayname = prevContent.Displayname
+		prevAvatarURL = prevContent.AvatarURL
 		if len(prevDisplayname) == 0 {
 			prevDisplayname = *evt.StateKey
 		}
@@ -293,10 +645,29 @@ func getMembershipEventContent(room *store.RoomStore, evt *database.Event) (send
 			AppendColor(" changed their display name to ", tcell.ColorGreen).
 			AppendColor(displayname, color).
 			AppendColor(".", tcell.ColorGreen)
+	} else if content.AvatarURL != prevAvatarURL {
+		sender = "---"
+		text = tstring.NewColorTString(displayname, widget.GetHashColor(evt.StateKey))
+		if content.AvatarURL == "" {
+			text = text.AppendColor(" removed their avatar.", tcell.ColorGreen)
+		} else {
+			text = text.AppendColor(" changed their avatar.", tcell.ColorGreen)
+		}
 	}
 	return
 }
 
+// isProfileChange returns true if the member event only changes the display name or avatar of the user,
+// i.e. the membership itself stays the same.
+func isProfileChange(evt *database.Event) bool {
+	mEvt := evt.AsMautrix()
+	if mEvt.Unsigned.PrevContent == nil {
+		return false
+	}
+	_ = mEvt.Unsigned.PrevContent.ParseRaw(mEvt.Type)
+	return mEvt.Content.AsMember().Membership == mEvt.Unsigned.PrevContent.AsMember().Membership
+}
+
 func ParseMembershipEvent(room *store.RoomStore, evt *database.Event) *UIMessage {
 	displayname, text := getMembershipEventContent(room, evt)
 	if len(text) == 0 {
@@ -305,5 +676,34 @@ func ParseMembershipEvent(room *store.RoomStore, evt *database.Event) *UIMessage
 
 	ui := NewExpandedTextMessage(evt, room, text)
 	ui.OverrideSenderName = displayname
+	ui.IsProfileChange = isProfileChange(evt)
 	return ui
 }
+
+// ParseCallMemberEvent renders a call membership change as a "joined/left the call" message.
+// Events that neither start nor end a membership (e.g. membership refreshes) are not rendered.
+func ParseCallMemberEvent(room *store.RoomStore, evt *database.Event) *UIMessage {
+	mEvt := evt.AsMautrix()
+	joined := store.ParseCallMember(evt, evt.Timestamp.Time) != nil
+	wasJoined := false
+	if mEvt.Unsigned.PrevContent != nil && len(mEvt.Unsigned.PrevContent.VeryRaw) > 0 {
+		prevEvt := &database.Event{
+			Sender:    evt.Sender,
+			StateKey:  evt.StateKey,
+			Timestamp: evt.Timestamp,
+			Content:   mEvt.Unsigned.PrevContent.VeryRaw,
+		}
+		wasJoined = store.ParseCallMember(prevEvt, evt.Timestamp.Time) != nil
+	}
+	displayname := room.GetDisplayname(evt.Sender)
+	text := tstring.NewColorTString(displayname, widget.GetHashColor(evt.Sender)).Append(" ")
+	switch {
+	case joined && !wasJoined:
+		text = text.AppendColor("joined the call.", tcell.ColorGreen)
+	case !joined && wasJoined:
+		text = text.AppendColor("left the call.", tcell.ColorRed)
+	default:
+		return nil
+	}
+	return NewExpandedTextMessage(evt, room, text)
+}
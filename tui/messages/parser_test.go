@@ -0,0 +1,433 @@
+// gomuks - A terminal Matrix client written in Go.
+// Copyright (C) 2026 Tulir Asokan
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+package messages
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/gdamore/tcell/v2"
+	"maunium.net/go/mautrix/event"
+	"maunium.net/go/mautrix/id"
+
+	"go.mau.fi/gomuks/pkg/hicli/database"
+	"go.mau.fi/gomuks/pkg/rpc/store"
+	"go.mau.fi/gomuks/tui/config"
+	"go.mau.fi/gomuks/tui/widget"
+)
+
+var update = flag.Bool("update", false, "update the golden files in testdata")
+
+const (
+	testRoomID id.RoomID = "!room:example.com"
+	testSender id.UserID = "@alice:example.com"
+)
+
+func newTestRoom() *store.RoomStore {
+	room := store.NewRoomStore(store.NewStore(), &database.Room{ID: testRoomID})
+	for i, member := range []struct {
+		userID      id.UserID
+		displayname string
+	}{{testSender, "Alice"}, {"@bob:example.com", "Bob"}} {
+		stateKey := member.userID.String()
+		room.ApplyState(&database.Event{
+			RowID:    database.EventRowID(i + 1),
+			RoomID:   testRoomID,
+			ID:       id.EventID("$member" + stateKey),
+			Sender:   member.userID,
+			Type:     event.StateMember.Type,
+			StateKey: &stateKey,
+			Content:  json.RawMessage(`{"membership":"join","displayname":"` + member.displayname + `"}`),
+		})
+	}
+	return room
+}
+
+func newTestStateEvent(evtType event.Type, content, prevContent string) *database.Event {
+	stateKey := ""
+	unsigned := `{}`
+	if prevContent != "" {
+		unsigned = `{"prev_content":` + prevContent + `}`
+	}
+	return &database.Event{
+		RowID:    100,
+		RoomID:   testRoomID,
+		ID:       "$state",
+		Sender:   testSender,
+		Type:     evtType.Type,
+		StateKey: &stateKey,
+		Content:  json.RawMessage(content),
+		Unsigned: json.RawMessage(unsigned),
+	}
+}
+
+func TestParseStateEvent(t *testing.T) {
+	tests := []struct {
+		name        string
+		evtType     event.Type
+		content     string
+		prevContent string
+		want        string
+	}{
+		{"join rule public", event.StateJoinRules, `{"join_rule":"public"}`, `{"join_rule":"invite"}`, "Alice made the room public."},
+		{"join rule invite", event.StateJoinRules, `{"join_rule":"invite"}`, "", "Alice made the room invite-only."},
+		{"join rule knock", event.StateJoinRules, `{"join_rule":"knock"}`, "", "Alice made the room invite-only and allowed anyone to ask to join."},
+		{"join rule private", event.StateJoinRules, `{"join_rule":"private"}`, "", "Alice made the room private."},
+		{
+			"join rule restricted",
+			event.StateJoinRules,
+			`{"join_rule":"restricted","allow":[{"type":"m.room_membership","room_id":"!space:example.com"}]}`,
+			`{"join_rule":"invite"}`,
+			"Alice made the room joinable by members of 1 space.",
+		},
+		{
+			"join rule restricted spaces changed",
+			event.StateJoinRules,
+			`{"join_rule":"restricted","allow":[{"type":"m.room_membership","room_id":"!a:example.com"},{"type":"m.room_membership","room_id":"!b:example.com"}]}`,
+			`{"join_rule":"restricted","allow":[{"type":"m.room_membership","room_id":"!a:example.com"}]}`,
+			"Alice changed the room to be joinable by members of 2 spaces.",
+		},
+		{
+			"join rule knock restricted",
+			event.StateJoinRules,
+			`{"join_rule":"knock_restricted","allow":[{"type":"m.room_membership","room_id":"!a:example.com"}]}`,
+			"",
+			"Alice made the room joinable by members of 1 space and allowed anyone else to ask to join.",
+		},
+		{"join rule unknown", event.StateJoinRules, `{"join_rule":"fi.mau.custom"}`, "", "Alice changed the join rule to fi.mau.custom."},
+
+		{"history visibility anyone", event.StateHistoryVisibility, `{"history_visibility":"world_readable"}`, "", "Alice set history visibility to: anyone."},
+		{"history visibility shared", event.StateHistoryVisibility, `{"history_visibility":"shared"}`, `{"history_visibility":"joined"}`, "Alice set history visibility to: members."},
+		{
+			"history visibility invited",
+			event.StateHistoryVisibility, `{"history_visibility":"invited"}`, "",
+			"Alice set history visibility to: members since they were invited.",
+		},
+		{
+			"history visibility joined",
+			event.StateHistoryVisibility, `{"history_visibility":"joined"}`, "",
+			"Alice set history visibility to: members since they joined.",
+		},
+		{"history visibility unknown", event.StateHistoryVisibility, `{"history_visibility":"custom"}`, "", "Alice set history visibility to: custom."},
+
+		{"guest access allowed", event.StateGuestAccess, `{"guest_access":"can_join"}`, "", "Alice allowed guests to join the room."},
+		{"guest access forbidden", event.StateGuestAccess, `{"guest_access":"forbidden"}`, `{"guest_access":"can_join"}`, "Alice prevented guests from joining the room."},
+
+		{"encryption", event.StateEncryption, `{"algorithm":"m.megolm.v1.aes-sha2"}`, "", "Alice enabled end-to-end encryption."},
+		{
+			"encryption unsupported algorithm",
+			event.StateEncryption, `{"algorithm":"m.fake"}`, "",
+			"Alice enabled end-to-end encryption with an unsupported algorithm m.fake.",
+		},
+
+		{"power levels without prev content", event.StatePowerLevels, `{"users":{"@alice:example.com":100}}`, "", "Alice set the power levels."},
+		{
+			"power levels unchanged",
+			event.StatePowerLevels,
+			`{"users":{"@alice:example.com":100},"events_default":0}`,
+			`{"users":{"@alice:example.com":100}}`,
+			"Alice changed the power levels without changing any values.",
+		},
+		{
+			"power levels user and default",
+			event.StatePowerLevels,
+			`{"users":{"@alice:example.com":100,"@bob:example.com":50},"events_default":50}`,
+			`{"users":{"@alice:example.com":100}}`,
+			"Alice changed power levels: events_default 0→50, Bob 0→50.",
+		},
+		{
+			"power levels user removed",
+			event.StatePowerLevels,
+			`{"users":{"@alice:example.com":100},"users_default":10}`,
+			`{"users":{"@alice:example.com":100,"@bob:example.com":50},"users_default":10}`,
+			"Alice changed power levels: Bob 50→10.",
+		},
+		{
+			"power levels unknown user",
+			event.StatePowerLevels,
+			`{"users":{"@carol:example.com":50}}`,
+			`{}`,
+			"Alice changed power levels: carol 0→50.",
+		},
+		{
+			"power levels event overrides",
+			event.StatePowerLevels,
+			`{"events":{"m.room.name":100,"m.reaction":0},"events_default":50}`,
+			`{"events":{"m.room.name":50,"m.room.topic":50},"events_default":0}`,
+			"Alice changed power levels: events_default 0→50, m.room.name 50→100.",
+		},
+		{
+			"power levels actions",
+			event.StatePowerLevels,
+			`{"ban":100,"kick":75,"redact":0,"invite":50,"state_default":100,"notifications":{"room":100}}`,
+			`{}`,
+			"Alice changed power levels: ban 50→100, kick 50→75, redact 50→0, invite 0→50, state_default 50→100, notifications.room 50→100.",
+		},
+
+		{"room avatar set", event.StateRoomAvatar, `{"url":"mxc://example.com/avatar"}`, "", "Alice set the room avatar."},
+		{"room avatar changed", event.StateRoomAvatar, `{"url":"mxc://example.com/new"}`, `{"url":"mxc://example.com/old"}`, "Alice changed the room avatar."},
+		{"room avatar removed", event.StateRoomAvatar, `{}`, `{"url":"mxc://example.com/old"}`, "Alice removed the room avatar."},
+
+		{"third-party invite", event.StateThirdPartyInvite, `{"display_name":"b...@example.com"}`, "", "Alice invited b...@example.com via email."},
+		{
+			"third-party invite revoked",
+			event.StateThirdPartyInvite, `{}`, `{"display_name":"b...@example.com"}`,
+			"Alice revoked the invite for b...@example.com sent via email.",
+		},
+		{"third-party invite revoked without name", event.StateThirdPartyInvite, `{}`, "", "Alice revoked an invite sent via email."},
+
+		{
+			"server ACL without prev content",
+			event.StateServerACL, `{"allow":["*"],"deny":["evil.com"]}`, "",
+			"Alice banned servers matching evil.com.",
+		},
+		{
+			"server ACL banning IP literals",
+			event.StateServerACL, `{"allow":["*"],"deny":[],"allow_ip_literals":false}`, `{"allow":["*"]}`,
+			"Alice banned servers using IP addresses.",
+		},
+		{
+			"server ACL allow changed",
+			event.StateServerACL, `{"allow":["*.example.com"],"allow_ip_literals":true}`, `{"allow":["*"],"allow_ip_literals":true}`,
+			"Alice allowed servers matching *.example.com and stopped allowing servers matching *.",
+		},
+		{
+			"server ACL unchanged",
+			event.StateServerACL, `{"allow":["*"],"allow_ip_literals":true}`, `{"allow":["*"]}`,
+			"Alice changed the server ACL without changing anything.",
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			room := newTestRoom()
+			msg := ParseStateEvent(room, newTestStateEvent(test.evtType, test.content, test.prevContent))
+			renderer, ok := msg.Renderer.(*ExpandedTextMessage)
+			if !ok {
+				t.Fatalf("Expected expanded text message, got %T", msg.Renderer)
+			}
+			if got := renderer.Text.String(); got != test.want {
+				t.Errorf("ParseStateEvent() = %q, want %q", got, test.want)
+			}
+			if fg, _, _ := renderer.Text[0].Style.Decompose(); fg != widget.GetHashColor(testSender) {
+				t.Errorf("Expected sender name to have the sender's color, got %v", fg)
+			}
+		})
+	}
+}
+
+func newTestMemberEvent(sender, target id.UserID, content, prevContent string) *database.Event {
+	evt := newTestStateEvent(event.StateMember, content, prevContent)
+	stateKey := target.String()
+	evt.Sender = sender
+	evt.StateKey = &stateKey
+	return evt
+}
+
+func TestParseMembershipEvent(t *testing.T) {
+	const bob id.UserID = "@bob:example.com"
+	tests := []struct {
+		name        string
+		sender      id.UserID
+		content     string
+		prevContent string
+		wantSender  string
+		want        string
+	}{
+		{"join", bob, `{"membership":"join","displayname":"Bob"}`, "", "-->", "Bob joined the room."},
+		{"join with reason", bob, `{"membership":"join","displayname":"Bob","reason":"hi"}`, "", "-->", "Bob joined the room: hi"},
+		{"accept invite", bob, `{"membership":"join","displayname":"Bob"}`, `{"membership":"invite"}`, "-->", "Bob accepted the invite."},
+		{"invite", testSender, `{"membership":"invite","displayname":"Bob"}`, "", "---", "Alice invited Bob."},
+		{"invite with reason", testSender, `{"membership":"invite","displayname":"Bob","reason":"come chat"}`, "", "---", "Alice invited Bob: come chat"},
+		{
+			"third-party invite",
+			testSender,
+			`{"membership":"invite","displayname":"Bob","third_party_invite":{"display_name":"b...@example.com","signed":{"mxid":"@bob:example.com","token":"abc","signatures":{}}}}`,
+			"", "---", "Alice invited Bob via a third-party invite for b...@example.com.",
+		},
+		{"knock", bob, `{"membership":"knock","displayname":"Bob"}`, "", "---", "Bob requested to join."},
+		{"knock with reason", bob, `{"membership":"knock","displayname":"Bob","reason":"let me in"}`, "", "---", "Bob requested to join: let me in"},
+		{
+			"knock accepted",
+			testSender, `{"membership":"invite","displayname":"Bob"}`, `{"membership":"knock","displayname":"Bob"}`,
+			"---", "Alice accepted the request to join from Bob.",
+		},
+		{"knock to join", bob, `{"membership":"join","displayname":"Bob"}`, `{"membership":"knock","displayname":"Bob"}`, "-->", "Bob joined the room."},
+		{
+			"knock retracted",
+			bob, `{"membership":"leave"}`, `{"membership":"knock","displayname":"Bob"}`,
+			"<--", "Bob retracted their request to join.",
+		},
+		{
+			"knock rejected",
+			testSender, `{"membership":"leave"}`, `{"membership":"knock","displayname":"Bob"}`,
+			"<--", "Alice rejected the request to join from Bob.",
+		},
+		{"leave", bob, `{"membership":"leave"}`, `{"membership":"join","displayname":"Bob"}`, "<--", "Bob left the room."},
+		{"reject invite", bob, `{"membership":"leave"}`, `{"membership":"invite","displayname":"Bob"}`, "<--", "Bob rejected the invite."},
+		{"kick", testSender, `{"membership":"leave","reason":"spam"}`, `{"membership":"join","displayname":"Bob"}`, "<--", "Alice kicked Bob: spam"},
+		{"revoke invite", testSender, `{"membership":"leave"}`, `{"membership":"invite","displayname":"Bob"}`, "<--", "Alice revoked the invite for Bob."},
+		{"ban", testSender, `{"membership":"ban","reason":"spam"}`, `{"membership":"join","displayname":"Bob"}`, "<--", "Alice banned Bob: spam"},
+		{
+			"ban with redactions",
+			testSender, `{"membership":"ban","reason":"spam","org.matrix.msc4293.redact_events":true}`, `{"membership":"join"}`,
+			"<--", "Alice banned @bob:example.com and their messages were removed: spam",
+		},
+		{"unban", testSender, `{"membership":"leave"}`, `{"membership":"ban"}`, "<--", "Alice unbanned @bob:example.com."},
+		{
+			"displayname change",
+			bob, `{"membership":"join","displayname":"Robert"}`, `{"membership":"join","displayname":"Bob"}`,
+			"---", "Bob changed their display name to Robert.",
+		},
+		{
+			"avatar change",
+			bob, `{"membership":"join","displayname":"Bob","avatar_url":"mxc://example.com/new"}`,
+			`{"membership":"join","displayname":"Bob","avatar_url":"mxc://example.com/old"}`,
+			"---", "Bob changed their avatar.",
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			msg := ParseMembershipEvent(newTestRoom(), newTestMemberEvent(test.sender, bob, test.content, test.prevContent))
+			if msg == nil {
+				t.Fatal("ParseMembershipEvent() returned nil")
+			}
+			renderer, ok := msg.Renderer.(*ExpandedTextMessage)
+			if !ok {
+				t.Fatalf("Expected expanded text message, got %T", msg.Renderer)
+			}
+			if got := renderer.Text.String(); got != test.want {
+				t.Errorf("ParseMembershipEvent() = %q, want %q", got, test.want)
+			}
+			if msg.OverrideSenderName != test.wantSender {
+				t.Errorf("Expected sender glyph %q, got %q", test.wantSender, msg.OverrideSenderName)
+			}
+			if fg, _, _ := renderer.Text[0].Style.Decompose(); fg != widget.GetHashColor(test.sender) {
+				t.Errorf("Expected first name to have the sender's color, got %v", fg)
+			}
+		})
+	}
+}
+
+func TestParseMembershipEvent_NoChange(t *testing.T) {
+	evt := newTestMemberEvent(testSender, testSender, `{"membership":"join","displayname":"Alice"}`, `{"membership":"join","displayname":"Alice"}`)
+	if msg := ParseMembershipEvent(newTestRoom(), evt); msg != nil {
+		t.Errorf("Expected no message for a member event without changes, got %q", msg.Renderer.(*ExpandedTextMessage).Text.String())
+	}
+}
+
+// renderGolden draws the message at the given width and returns the drawn lines between | characters,
+// with text in a non-default color marked as [#rrggbb]text[-].
+// assertGolden compares a rendered message with testdata/<name>.golden, or updates the file if -update is set.
+func assertGolden(t *testing.T, name, got string) {
+	t.Helper()
+	path := filepath.Join("testdata", name+".golden")
+	if *update {
+		if err := os.WriteFile(path, []byte(got), 0644); err != nil {
+			t.Fatalf("Failed to update golden file: %v", err)
+		}
+		return
+	}
+	want, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("Failed to read golden file: %v", err)
+	}
+	if got != string(want) {
+		t.Errorf("Rendered message doesn't match %s (run with -update if the change is intentional):\ngot:\n%s\nwant:\n%s", path, got, want)
+	}
+}
+
+func renderGolden(t *testing.T, msg *UIMessage, width int) string {
+	t.Helper()
+	msg.CalculateBuffer(config.UserPreferences{}, width)
+	screen := tcell.NewSimulationScreen("")
+	if err := screen.Init(); err != nil {
+		t.Fatalf("Failed to initialize screen: %v", err)
+	}
+	defer screen.Fini()
+	screen.SetSize(width, msg.Height())
+	msg.Draw(screen)
+	screen.Show()
+	cells, w, h := screen.GetContents()
+	var buf strings.Builder
+	_, _ = fmt.Fprintf(&buf, "width %d:\n", width)
+	for y := range h {
+		buf.WriteByte('|')
+		current := tcell.ColorDefault
+		for _, cell := range cells[y*w : (y+1)*w] {
+			if fg, _, _ := cell.Style.Decompose(); fg != current {
+				if current != tcell.ColorDefault {
+					buf.WriteString("[-]")
+				}
+				if fg != tcell.ColorDefault {
+					_, _ = fmt.Fprintf(&buf, "[#%06x]", fg.Hex())
+				}
+				current = fg
+			}
+			if len(cell.Runes) == 0 {
+				buf.WriteByte(' ')
+			} else {
+				buf.WriteString(string(cell.Runes))
+			}
+		}
+		if current != tcell.ColorDefault {
+			buf.WriteString("[-]")
+		}
+		buf.WriteString("|\n")
+	}
+	return buf.String()
+}
+
+// TestParseMessage_EmoteGolden checks that emotes are rendered as a single paragraph starting with
+// "* name" in the sender's color, and that wrapped lines start at the left edge of the message.
+// Run with -update if the change is intentional.
+func TestParseMessage_EmoteGolden(t *testing.T) {
+	tests := []struct {
+		name    string
+		sender  id.UserID
+		content string
+	}{
+		{"plain", testSender, `{"msgtype":"m.emote","body":"waves at everyone in the room"}`},
+		{"formatted", testSender, `{"msgtype":"m.emote","body":"pokes Bob and Carol","format":"org.matrix.custom.html",` +
+			`"formatted_body":"<em>pokes</em> <a href=\"https://matrix.to/#/@bob:example.com\">Bob</a> and ` +
+			`<a href=\"https://matrix.to/#/@carol:example.com\">Carol</a>"}`},
+		{"multiline", testSender, `{"msgtype":"m.emote","body":"sighs deeply\nand leaves"}`},
+		{"paragraphs", testSender, `{"msgtype":"m.emote","body":"sighs deeply\n\nand leaves","format":"org.matrix.custom.html",` +
+			`"formatted_body":"<p>sighs deeply</p><p>and leaves</p>"}`},
+		{"div", testSender, `{"msgtype":"m.emote","body":"dances around","format":"org.matrix.custom.html",` +
+			`"formatted_body":"<div>dances around</div>"}`},
+		{"per-message-profile", testBridgeBot, `{"msgtype":"m.emote","body":"waves",` +
+			`"com.beeper.per_message_profile":{"id":"user1","displayname":"Bridged User"}}`},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			msg := ParseEvent(nil, &config.UserPreferences{}, newTestRoom(), newTestMessage(1, test.sender, test.content))
+			if msg == nil {
+				t.Fatal("ParseEvent() returned nil")
+			}
+			var buf strings.Builder
+			for _, width := range []int{9, 16} {
+				buf.WriteString(renderGolden(t, msg, width))
+			}
+			assertGolden(t, "emote-"+test.name, buf.String())
+		})
+	}
+}
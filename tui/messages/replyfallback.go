@@ -0,0 +1,51 @@
+// gomuks - A terminal Matrix client written in Go.
+// Copyright (C) 2026 Tulir Asokan
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+package messages
+
+import (
+	"strings"
+
+	"maunium.net/go/mautrix/event"
+
+	"go.mau.fi/gomuks/pkg/hicli/database"
+)
+
+// TrimReplyFallbackText removes the quoted reply fallback from the start of a plaintext body.
+// The fallback is every line before the first blank line, and it's only removed if all of those lines
+// are quoted and the first one starts with the quoted sender, so that messages which merely start
+// with a quote aren't mangled.
+func TrimReplyFallbackText(body string) string {
+	fallback, rest, found := strings.Cut(body, "\n\n")
+	if !found || (!strings.HasPrefix(fallback, "> <") && !strings.HasPrefix(fallback, "> * <")) {
+		return body
+	}
+	for line := range strings.SplitSeq(fallback, "\n") {
+		if !strings.HasPrefix(line, ">") {
+			return body
+		}
+	}
+	return rest
+}
+
+// StripReplyFallback removes the plaintext reply fallback from the content of a reply, unless the backend already
+// removed it. HTML fallbacks don't need to be removed here, as the HTML parser drops mx-reply elements.
+func StripReplyFallback(evt *database.Event, content *event.MessageEventContent) {
+	if evt.LocalContent.GetReplyFallbackRemoved() || content.RelatesTo.GetReplyTo() == "" {
+		return
+	}
+	content.Body = TrimReplyFallbackText(content.Body)
+}
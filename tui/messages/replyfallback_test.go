@@ -0,0 +1,102 @@
+// gomuks - A terminal Matrix client written in Go.
+// Copyright (C) 2026 Tulir Asokan
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+package messages
+
+import (
+	"testing"
+
+	"go.mau.fi/gomuks/pkg/hicli/database"
+	"go.mau.fi/gomuks/tui/config"
+)
+
+func TestTrimReplyFallbackText(t *testing.T) {
+	tests := []struct {
+		name string
+		body string
+		want string
+	}{
+		{"element", "> <@bob:example.com> original message\n\nreply", "reply"},
+		{"element multiline", "> <@bob:example.com> first line\n> second line\n\nreply\n\nsecond paragraph", "reply\n\nsecond paragraph"},
+		{"element emote", "> * <@bob:example.com> waves\n\nreply", "reply"},
+		{"element file", "> <@bob:example.com> sent a file.\n\nnice file", "nice file"},
+		{"quote in reply", "> <@bob:example.com> original\n\n> quoted\n\nreply", "> quoted\n\nreply"},
+		{"no fallback", "just a reply", "just a reply"},
+		{"plain quote", "> some quote\n\nmy comment", "> some quote\n\nmy comment"},
+		{"unquoted line in fallback", "> <@bob:example.com> original\nnot quoted\n\nreply", "> <@bob:example.com> original\nnot quoted\n\nreply"},
+		{"no blank line", "> <@bob:example.com> original\nreply", "> <@bob:example.com> original\nreply"},
+		{"only fallback", "> <@bob:example.com> original\n\n", ""},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if got := TrimReplyFallbackText(test.body); got != test.want {
+				t.Errorf("TrimReplyFallbackText(%q) = %q, want %q", test.body, got, test.want)
+			}
+		})
+	}
+}
+
+func TestParseMessage_ReplyFallback(t *testing.T) {
+	const replyRelation = `"m.relates_to":{"m.in_reply_to":{"event_id":"$target"}}`
+	tests := []struct {
+		name            string
+		content         string
+		fallbackRemoved bool
+		wantPlainText   string
+	}{
+		{
+			"plaintext reply",
+			`{"msgtype":"m.text","body":"> <@bob:example.com> original\n\nreply",` + replyRelation + `}`,
+			false, "reply",
+		},
+		{
+			"html reply",
+			`{"msgtype":"m.text","body":"> <@bob:example.com> original\n\nreply","format":"org.matrix.custom.html",` +
+				`"formatted_body":"<mx-reply><blockquote><a href=\"https://matrix.to/#/!room/$target\">In reply to</a> ` +
+				`<a href=\"https://matrix.to/#/@bob:example.com\">@bob:example.com</a><br>original</blockquote></mx-reply>reply",` +
+				replyRelation + `}`,
+			false, "reply",
+		},
+		{
+			"reply quoting a sender",
+			`{"msgtype":"m.text","body":"> <@bob:example.com> said this\n\nreply",` + replyRelation + `}`,
+			true, "> <@bob:example.com> said this\n\nreply",
+		},
+		{
+			"not a reply",
+			`{"msgtype":"m.text","body":"> <@bob:example.com> said this\n\nmy comment"}`,
+			false, "> <@bob:example.com> said this\n\nmy comment",
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			evt := newTestMessage(10, testSender, test.content)
+			if test.fallbackRemoved {
+				evt.LocalContent = &database.LocalContent{ReplyFallbackRemoved: true}
+			}
+			msg := ParseMessage(nil, &config.UserPreferences{DisableDownloads: true}, newTestRoom(), evt)
+			if msg == nil {
+				t.Fatal("ParseMessage() returned nil")
+			}
+			if got := msg.PlainText(); got != test.wantPlainText {
+				t.Errorf("PlainText() = %q, want %q", got, test.wantPlainText)
+			}
+			if string(evt.Content) != test.content {
+				t.Errorf("Expected the stored event content to stay unchanged, got %s", evt.Content)
+			}
+		})
+	}
+}
@@ -0,0 +1,83 @@
+// gomuks - A terminal Matrix client written in Go.
+// Copyright (C) 2026 Tulir Asokan
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+package messages
+
+import (
+	"strings"
+
+	"github.com/gdamore/tcell/v2"
+	"maunium.net/go/mautrix"
+)
+
+// ReplyState describes how the reply header of a message is rendered when the replied-to message
+// can't be shown in full.
+type ReplyState int
+
+const (
+	// ReplyStateNone means the message isn't a reply or the replied-to message is rendered normally.
+	ReplyStateNone ReplyState = iota
+	// ReplyStateLoading means the replied-to message isn't loaded locally and is being fetched.
+	ReplyStateLoading
+	// ReplyStateDeleted means the replied-to message was redacted.
+	ReplyStateDeleted
+	// ReplyStateInaccessible means the server refused to return the replied-to message,
+	// usually because it was sent before the user joined.
+	ReplyStateInaccessible
+	// ReplyStateUnknown means the replied-to message couldn't be fetched or rendered for another reason.
+	ReplyStateUnknown
+)
+
+// Header returns the one-line reply header text for degraded reply states.
+func (rs ReplyState) Header() string {
+	switch rs {
+	case ReplyStateLoading:
+		return "In reply to a message that is loading…"
+	case ReplyStateDeleted:
+		return "In reply to a deleted message"
+	case ReplyStateInaccessible:
+		return "In reply to a message you can't see (before you joined)"
+	case ReplyStateUnknown:
+		return "In reply to an unknown message"
+	default:
+		return ""
+	}
+}
+
+func (rs ReplyState) headerStyle() tcell.Style {
+	style := tcell.StyleDefault.Italic(true)
+	switch rs {
+	case ReplyStateDeleted:
+		return style.Foreground(tcell.ColorRed)
+	case ReplyStateInaccessible:
+		return style.Foreground(tcell.ColorYellow)
+	default:
+		return style.Foreground(tcell.ColorGray)
+	}
+}
+
+// ClassifyReplyFetchError returns the reply state to use when fetching the replied-to event failed.
+// Errors from the backend only contain the error message, so the Matrix error code is matched from the text.
+func ClassifyReplyFetchError(err error) ReplyState {
+	if err == nil {
+		return ReplyStateNone
+	}
+	errMsg := err.Error()
+	if strings.Contains(errMsg, mautrix.MNotFound.ErrCode) || strings.Contains(errMsg, mautrix.MForbidden.ErrCode) {
+		return ReplyStateInaccessible
+	}
+	return ReplyStateUnknown
+}
@@ -0,0 +1,124 @@
+// gomuks - A terminal Matrix client written in Go.
+// Copyright (C) 2026 Tulir Asokan
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+package messages
+
+import (
+	"errors"
+	"testing"
+
+	"maunium.net/go/mautrix/id"
+
+	"go.mau.fi/gomuks/pkg/hicli/database"
+	"go.mau.fi/gomuks/pkg/rpc/store"
+	"go.mau.fi/gomuks/tui/config"
+)
+
+func TestClassifyReplyFetchError(t *testing.T) {
+	tests := []struct {
+		err  error
+		want ReplyState
+	}{
+		{nil, ReplyStateNone},
+		{errors.New("failed to get event: M_FORBIDDEN (HTTP 403): You don't have permission to view this event"), ReplyStateInaccessible},
+		{errors.New("failed to get event: M_NOT_FOUND (HTTP 404): Event not found"), ReplyStateInaccessible},
+		{errors.New("failed to get event: M_UNKNOWN (HTTP 500): Internal server error"), ReplyStateUnknown},
+		{errors.New("context deadline exceeded"), ReplyStateUnknown},
+	}
+	for _, test := range tests {
+		if got := ClassifyReplyFetchError(test.err); got != test.want {
+			t.Errorf("ClassifyReplyFetchError(%v) = %d, want %d", test.err, got, test.want)
+		}
+	}
+}
+
+func TestParseEvent_ReplyHeaderGolden(t *testing.T) {
+	const target id.EventID = "$target"
+	const replyContent = `{"msgtype":"m.text","body":"reply text","m.relates_to":{"m.in_reply_to":{"event_id":"$target"}}}`
+	newTarget := func() *database.Event {
+		evt := newTestMessage(20, "@bob:example.com", `{"msgtype":"m.text","body":"original message"}`)
+		evt.ID = target
+		return evt
+	}
+	tests := []struct {
+		name string
+		// setup adds the replied-to event to the room if it should be loaded
+		setup func(room *store.RoomStore)
+		// fetchErr is the error from fetching the replied-to event, which the message view stores in the message
+		fetchErr  error
+		wantState ReplyState
+	}{
+		{
+			name:      "loaded",
+			setup:     func(room *store.RoomStore) { room.ApplyFetchedEvent(newTarget()) },
+			wantState: ReplyStateNone,
+		},
+		{
+			name: "deleted",
+			setup: func(room *store.RoomStore) {
+				evt := newTarget()
+				evt.RedactedBy = "$redaction"
+				room.ApplyFetchedEvent(evt)
+			},
+			wantState: ReplyStateDeleted,
+		},
+		{
+			name:      "not-loaded",
+			setup:     func(room *store.RoomStore) {},
+			wantState: ReplyStateLoading,
+		},
+		{
+			name:      "cant-see",
+			setup:     func(room *store.RoomStore) {},
+			fetchErr:  errors.New("failed to get event: M_FORBIDDEN (HTTP 403): You don't have permission to view this event"),
+			wantState: ReplyStateInaccessible,
+		},
+		{
+			name:      "fetch-failed",
+			setup:     func(room *store.RoomStore) {},
+			fetchErr:  errors.New("context deadline exceeded"),
+			wantState: ReplyStateUnknown,
+		},
+		{
+			name: "unrenderable",
+			setup: func(room *store.RoomStore) {
+				evt := newTarget()
+				evt.RenderMeta = (*UIMessage)(nil)
+				room.ApplyFetchedEvent(evt)
+			},
+			wantState: ReplyStateUnknown,
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			room := newTestRoom()
+			test.setup(room)
+			msg := ParseEvent(nil, &config.UserPreferences{DisableDownloads: true}, room, newTestMessage(21, testSender, replyContent))
+			if msg == nil {
+				t.Fatal("ParseEvent() returned nil")
+			} else if msg.ReplyToID != target {
+				t.Errorf("ReplyToID = %q, want %q", msg.ReplyToID, target)
+			}
+			if test.fetchErr != nil {
+				msg.ReplyState = ClassifyReplyFetchError(test.fetchErr)
+			}
+			if msg.ReplyState != test.wantState {
+				t.Errorf("ReplyState = %d, want %d", msg.ReplyState, test.wantState)
+			}
+			assertGolden(t, "reply-"+test.name, renderGolden(t, msg, 60))
+		})
+	}
+}
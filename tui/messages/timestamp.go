@@ -0,0 +1,133 @@
+// gomuks - A terminal Matrix client written in Go.
+// Copyright (C) 2025 Tulir Asokan
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+package messages
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/gdamore/tcell/v2"
+	"go.mau.fi/mauview"
+
+	"go.mau.fi/gomuks/tui/widget"
+)
+
+// TimestampAnomaly describes how a message's timestamp disagrees with its position in the timeline.
+type TimestampAnomaly int
+
+const (
+	// TimestampNormal means the timestamp is consistent with the timeline order.
+	TimestampNormal TimestampAnomaly = iota
+	// TimestampOutOfOrder means the message is much older than the messages before it in the timeline,
+	// which usually happens when bridges backfill messages with their original timestamps.
+	TimestampOutOfOrder
+	// TimestampFuture means the timestamp is in the future, which is usually a broken or malicious server.
+	TimestampFuture
+)
+
+const (
+	// OutOfOrderThreshold is how much older than the previous message a message has to be
+	// before it's considered out of order.
+	OutOfOrderThreshold = 30 * time.Minute
+	// FutureThreshold is how far in the future a timestamp can be before it's considered bogus.
+	// Some slack is allowed for clock skew between servers.
+	FutureThreshold = 5 * time.Minute
+)
+
+const timestampNoteFormat = "January _2, 2006 15:04:05"
+
+// CheckTimestamp compares a message timestamp to the timeline clock, which is the display time
+// of the latest in-order message before it. It returns the time the message should be displayed
+// with and the kind of anomaly detected, if any. Far-future timestamps are clamped to the clock,
+// or to the current time if there are no earlier messages.
+func CheckTimestamp(ts, clock, now time.Time) (time.Time, TimestampAnomaly) {
+	if ts.After(now.Add(FutureThreshold)) {
+		if clock.IsZero() {
+			return now, TimestampFuture
+		}
+		return clock, TimestampFuture
+	} else if !clock.IsZero() && clock.Sub(ts) > OutOfOrderThreshold {
+		return ts, TimestampOutOfOrder
+	}
+	return ts, TimestampNormal
+}
+
+// ApplyTimelineOrder checks the message timestamp against the timeline clock (see CheckTimestamp)
+// and returns the clock for the next message. Out-of-order and future messages don't advance the clock.
+func (msg *UIMessage) ApplyTimelineOrder(clock, now time.Time) time.Time {
+	var displayTime time.Time
+	displayTime, msg.TimestampAnomaly = CheckTimestamp(msg.Timestamp.Time, clock, now)
+	if msg.TimestampAnomaly == TimestampFuture {
+		msg.displayTime = displayTime
+	} else {
+		msg.displayTime = time.Time{}
+	}
+	if msg.TimestampAnomaly != TimestampNormal {
+		return clock
+	}
+	return displayTime
+}
+
+// DisplayTime returns the time the message should be displayed with.
+// This is the same as the timestamp unless the timestamp was clamped.
+func (msg *UIMessage) DisplayTime() time.Time {
+	if !msg.displayTime.IsZero() {
+		return msg.displayTime
+	}
+	return msg.Timestamp.Time
+}
+
+// NeedsDateSeparator returns true if a date change separator should be drawn between the previous in-order
+// message and this one. Messages with timestamp anomalies have an explicit date annotation instead,
+// so they never get a separator and shouldn't be passed as prev for the next message either.
+func (msg *UIMessage) NeedsDateSeparator(prev *UIMessage) bool {
+	return msg.TimestampAnomaly == TimestampNormal && !msg.SameDate(prev)
+}
+
+// TimestampNote returns the annotation shown above messages with unusual timestamps, or an empty string.
+func (msg *UIMessage) TimestampNote() string {
+	switch msg.TimestampAnomaly {
+	case TimestampOutOfOrder:
+		return fmt.Sprintf("sent %s", msg.Timestamp.Format(timestampNoteFormat))
+	case TimestampFuture:
+		return fmt.Sprintf("⚠ claims to be sent in the future (%s)", msg.Timestamp.Format(timestampNoteFormat))
+	default:
+		return ""
+	}
+}
+
+func (msg *UIMessage) TimestampNoteHeight() int {
+	if msg.TimestampAnomaly != TimestampNormal && !msg.IsReplyBubble {
+		return 1
+	}
+	return 0
+}
+
+// DrawTimestampNote draws the timestamp annotation if there is one and
+// returns the part of the screen below it for drawing the rest of the message.
+func (msg *UIMessage) DrawTimestampNote(screen mauview.Screen) mauview.Screen {
+	if msg.TimestampNoteHeight() == 0 {
+		return screen
+	}
+	color := tcell.ColorGray
+	if msg.TimestampAnomaly == TimestampFuture {
+		color = tcell.ColorYellow
+	}
+	widget.WriteLineSimpleColor(screen, msg.TimestampNote(), 0, 0, color)
+	width, height := screen.Size()
+	return mauview.NewProxyScreen(screen, 0, 1, width, height-1)
+}
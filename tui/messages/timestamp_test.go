@@ -0,0 +1,179 @@
+// gomuks - A terminal Matrix client written in Go.
+// Copyright (C) 2026 Tulir Asokan
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+package messages
+
+import (
+	"slices"
+	"testing"
+	"time"
+
+	"go.mau.fi/util/jsontime"
+
+	"go.mau.fi/gomuks/pkg/hicli/database"
+	"go.mau.fi/gomuks/tui/messages/tstring"
+)
+
+var timestampTestNow = time.Date(2026, 3, 10, 12, 0, 0, 0, time.UTC)
+
+func TestCheckTimestamp(t *testing.T) {
+	now := timestampTestNow
+	clock := now.Add(-time.Hour)
+	tests := []struct {
+		name        string
+		ts          time.Time
+		clock       time.Time
+		wantTime    time.Time
+		wantAnomaly TimestampAnomaly
+	}{
+		{"first message", now.Add(-24 * time.Hour), time.Time{}, now.Add(-24 * time.Hour), TimestampNormal},
+		{"after clock", now.Add(-time.Minute), clock, now.Add(-time.Minute), TimestampNormal},
+		{"slightly before clock", clock.Add(-OutOfOrderThreshold), clock, clock.Add(-OutOfOrderThreshold), TimestampNormal},
+		{"far before clock", clock.Add(-OutOfOrderThreshold - time.Second), clock, clock.Add(-OutOfOrderThreshold - time.Second), TimestampOutOfOrder},
+		{"backfilled years ago", now.AddDate(-3, 0, 0), clock, now.AddDate(-3, 0, 0), TimestampOutOfOrder},
+		{"clock skew", now.Add(FutureThreshold), clock, now.Add(FutureThreshold), TimestampNormal},
+		{"future", now.Add(FutureThreshold + time.Second), clock, clock, TimestampFuture},
+		{"far future", now.AddDate(10, 0, 0), clock, clock, TimestampFuture},
+		{"future first message", now.AddDate(10, 0, 0), time.Time{}, now, TimestampFuture},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			gotTime, gotAnomaly := CheckTimestamp(test.ts, test.clock, now)
+			if !gotTime.Equal(test.wantTime) || gotAnomaly != test.wantAnomaly {
+				t.Errorf("CheckTimestamp() = %s, %d, want %s, %d", gotTime, gotAnomaly, test.wantTime, test.wantAnomaly)
+			}
+		})
+	}
+}
+
+// timelineResult is what the message view would render for one message of a synthetic timeline.
+type timelineResult struct {
+	anomaly   TimestampAnomaly
+	separator bool
+	time      string
+}
+
+// simulateTimeline runs the messages through ApplyTimelineOrder and the date separator logic
+// in the same way as MessageView.update.
+func simulateTimeline(timestamps []time.Time) []timelineResult {
+	var clock time.Time
+	var prev *UIMessage
+	results := make([]timelineResult, len(timestamps))
+	for i, ts := range timestamps {
+		msg := NewExpandedTextMessage(&database.Event{
+			RowID:     database.EventRowID(i + 1),
+			Sender:    testSender,
+			Timestamp: jsontime.UM(ts),
+		}, newTestRoom(), tstring.NewTString("message"))
+		clock = msg.ApplyTimelineOrder(clock, timestampTestNow)
+		results[i] = timelineResult{
+			anomaly:   msg.TimestampAnomaly,
+			separator: msg.NeedsDateSeparator(prev),
+			time:      msg.DisplayTime().UTC().Format("Jan 2 15:04"),
+		}
+		if msg.TimestampAnomaly == TimestampNormal {
+			prev = msg
+		}
+	}
+	return results
+}
+
+func TestTimelineOrder(t *testing.T) {
+	day := func(d, hour int) time.Time {
+		return time.Date(2026, 3, d, hour, 0, 0, 0, time.UTC)
+	}
+	tests := []struct {
+		name       string
+		timestamps []time.Time
+		want       []timelineResult
+	}{
+		{
+			"in order",
+			[]time.Time{day(9, 10), day(9, 11), day(10, 9)},
+			[]timelineResult{
+				{TimestampNormal, true, "Mar 9 10:00"},
+				{TimestampNormal, false, "Mar 9 11:00"},
+				{TimestampNormal, true, "Mar 10 09:00"},
+			},
+		},
+		{
+			"single backfilled message",
+			[]time.Time{day(9, 10), day(1, 10), day(9, 11)},
+			[]timelineResult{
+				{TimestampNormal, true, "Mar 9 10:00"},
+				{TimestampOutOfOrder, false, "Mar 1 10:00"},
+				{TimestampNormal, false, "Mar 9 11:00"},
+			},
+		},
+		{
+			"backfilled batch",
+			[]time.Time{day(9, 10), day(1, 10), day(2, 10), day(9, 11)},
+			[]timelineResult{
+				{TimestampNormal, true, "Mar 9 10:00"},
+				{TimestampOutOfOrder, false, "Mar 1 10:00"},
+				{TimestampOutOfOrder, false, "Mar 2 10:00"},
+				{TimestampNormal, false, "Mar 9 11:00"},
+			},
+		},
+		{
+			"far future message",
+			[]time.Time{day(9, 10), time.Date(2099, 1, 1, 0, 0, 0, 0, time.UTC), day(9, 11), day(10, 8)},
+			[]timelineResult{
+				{TimestampNormal, true, "Mar 9 10:00"},
+				{TimestampFuture, false, "Mar 9 10:00"},
+				{TimestampNormal, false, "Mar 9 11:00"},
+				{TimestampNormal, true, "Mar 10 08:00"},
+			},
+		},
+		{
+			"far future first message",
+			[]time.Time{time.Date(2099, 1, 1, 0, 0, 0, 0, time.UTC), day(9, 10)},
+			[]timelineResult{
+				{TimestampFuture, false, "Mar 10 12:00"},
+				{TimestampNormal, true, "Mar 9 10:00"},
+			},
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if got := simulateTimeline(test.timestamps); !slices.Equal(got, test.want) {
+				t.Errorf("Timeline rendered as\n%+v\nwant\n%+v", got, test.want)
+			}
+		})
+	}
+}
+
+func TestTimestampNote(t *testing.T) {
+	msg := NewExpandedTextMessage(&database.Event{
+		Sender:    testSender,
+		Timestamp: jsontime.UM(time.Date(2026, 3, 1, 10, 0, 0, 0, time.Local)),
+	}, newTestRoom(), tstring.NewTString("message"))
+	msg.ApplyTimelineOrder(timestampTestNow.Add(-time.Hour), timestampTestNow)
+	if note := msg.TimestampNote(); note != "sent March  1, 2026 10:00:00" {
+		t.Errorf("Unexpected out-of-order note %q", note)
+	} else if msg.TimestampNoteHeight() != 1 {
+		t.Errorf("Expected note to take one line, got %d", msg.TimestampNoteHeight())
+	}
+	msg.IsReplyBubble = true
+	if msg.TimestampNoteHeight() != 0 {
+		t.Error("Expected reply bubbles not to have timestamp notes")
+	}
+	msg.IsReplyBubble = false
+	msg.ApplyTimelineOrder(time.Time{}, timestampTestNow)
+	if note := msg.TimestampNote(); note != "" || msg.TimestampNoteHeight() != 0 {
+		t.Errorf("Expected no note for a normal message, got %q", note)
+	}
+}
@@ -33,25 +33,26 @@ func NewBlankTString() TString {
 }
 
 func NewTString(str string) TString {
-	newStr := make(TString, len(str))
-	for i, char := range str {
-		newStr[i] = NewCell(char)
+	// The length in bytes is only used as the capacity, as there's one cell per rune rather than per byte
+	newStr := make(TString, 0, len(str))
+	for _, char := range str {
+		newStr = append(newStr, NewCell(char))
 	}
 	return newStr
 }
 
 func NewColorTString(str string, color tcell.Color) TString {
-	newStr := make(TString, len(str))
-	for i, char := range str {
-		newStr[i] = NewColorCell(char, color)
+	newStr := make(TString, 0, len(str))
+	for _, char := range str {
+		newStr = append(newStr, NewColorCell(char, color))
 	}
 	return newStr
 }
 
 func NewStyleTString(str string, style tcell.Style) TString {
-	newStr := make(TString, len(str))
-	for i, char := range str {
-		newStr[i] = NewStyleCell(char, style)
+	newStr := make(TString, 0, len(str))
+	for _, char := range str {
+		newStr = append(newStr, NewStyleCell(char, style))
 	}
 	return newStr
 }
@@ -137,10 +138,10 @@ func (str TString) AppendStyle(data string, style tcell.Style) TString {
 }
 
 func (str TString) AppendCustom(data string, cellCreator func(rune) Cell) TString {
-	newStr := make(TString, len(str)+len(data))
+	newStr := make(TString, len(str), len(str)+len(data))
 	copy(newStr, str)
-	for i, char := range data {
-		newStr[i+len(str)] = cellCreator(char)
+	for _, char := range data {
+		newStr = append(newStr, cellCreator(char))
 	}
 	return newStr
 }
@@ -164,12 +165,11 @@ func (str TString) PrependStyle(data string, style tcell.Style) TString {
 }
 
 func (str TString) PrependCustom(data string, cellCreator func(rune) Cell) TString {
-	newStr := make(TString, len(str)+len(data))
-	copy(newStr[len(data):], str)
-	for i, char := range data {
-		newStr[i] = cellCreator(char)
+	newStr := make(TString, 0, len(str)+len(data))
+	for _, char := range data {
+		newStr = append(newStr, cellCreator(char))
 	}
-	return newStr
+	return append(newStr, str...)
 }
 
 func (str TString) Colorize(from, length int, color tcell.Color) {
@@ -41,6 +41,7 @@ type PasswordModal struct {
 	cancel *mauview.Button
 	submit *mauview.Button
 
+	title  string
 	parent *MainView
 }
 
@@ -51,14 +52,28 @@ func (view *MainView) AskPassword(title, thing, placeholder string, isNew bool)
 	return pwm.Wait()
 }
 
+// AskText is like AskPassword, but the input isn't masked. It's meant for short values like email addresses
+// or verification codes.
+func (view *MainView) AskText(title, thing, placeholder string) (string, bool) {
+	pwm := newInputModal(view, title, thing, placeholder, false, 0)
+	view.ShowModal(pwm)
+	view.parent.Render()
+	return pwm.Wait()
+}
+
 func NewPasswordModal(parent *MainView, title, thing, placeholder string, isNew bool) *PasswordModal {
 	if placeholder == "" {
 		placeholder = "correct horse battery staple"
 	}
+	return newInputModal(parent, title, thing, placeholder, isNew, '*')
+}
+
+func newInputModal(parent *MainView, title, thing, placeholder string, isNew bool, mask rune) *PasswordModal {
 	if thing == "" {
 		thing = strings.ToLower(title)
 	}
 	pwm := &PasswordModal{
+		title:      title,
 		parent:     parent,
 		form:       mauview.NewForm(),
 		outputChan: make(chan string, 1),
@@ -78,16 +93,17 @@ func NewPasswordModal(parent *MainView, title, thing, placeholder string, isNew
 	} else {
 		pwm.text.SetText(fmt.Sprintf("Enter the %s", thing))
 	}
-	pwm.input = mauview.NewInputField().
-		SetMaskCharacter('*').
-		SetPlaceholder(placeholder)
+	pwm.input = mauview.NewInputField().SetPlaceholder(placeholder)
+	if mask != 0 {
+		pwm.input.SetMaskCharacter(mask)
+	}
 	pwm.form.AddComponent(pwm.text, 1, 1, 3, 1)
 	pwm.form.AddFormItem(pwm.input, 1, 2, 3, 1)
 
 	if isNew {
 		height += 3
 		pwm.confirmInput = mauview.NewInputField().
-			SetMaskCharacter('*').
+			SetMaskCharacter(mask).
 			SetPlaceholder(placeholder).
 			SetChangedFunc(pwm.HandleChange)
 		pwm.input.SetChangedFunc(pwm.HandleChange)
@@ -0,0 +1,143 @@
+// gomuks - A terminal Matrix client written in Go.
+// Copyright (C) 2026 Tulir Asokan
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+package tui
+
+import (
+	"context"
+	"fmt"
+	"html"
+	"strings"
+
+	"maunium.net/go/mautrix/id"
+
+	"go.mau.fi/gomuks/pkg/hicli/database"
+	"go.mau.fi/gomuks/pkg/hicli/jsoncmd"
+	"go.mau.fi/gomuks/tui/debug"
+)
+
+func describeEnforcement(enf *database.PolicyEnforcement) string {
+	var verb string
+	switch enf.Action {
+	case database.PolicyActionBan:
+		verb = "Banned"
+	case database.PolicyActionFlag:
+		verb = "Flagged"
+	case database.PolicyActionUnban:
+		verb = "Unbanned"
+	case database.PolicyActionUnflag:
+		verb = "Unflagged"
+	default:
+		verb = string(enf.Action)
+	}
+	text := fmt.Sprintf("%s %s (rule %s from %s)", verb, enf.UserID, enf.Entity, enf.PolicyRoomID)
+	if enf.Reason != "" && (enf.Action == database.PolicyActionBan || enf.Action == database.PolicyActionFlag) {
+		text += ": " + enf.Reason
+	}
+	return text
+}
+
+// HandlePolicyEnforced shows a service message in the room where a policy list rule was enforced.
+func (view *MainView) HandlePolicyEnforced(evt *jsoncmd.PolicyEnforced) {
+	room := view.matrix.GetRoom(evt.RoomID)
+	if room == nil {
+		return
+	}
+	text := describeEnforcement(evt.PolicyEnforcement)
+	if evt.Error != "" {
+		text = fmt.Sprintf("Failed to enforce policy: %s: %s", text, evt.Error)
+	}
+	room.ApplyPending(database.MakeFakeEvent(room.ID, html.EscapeString(text)))
+	view.parent.Render()
+}
+
+// PolicyCommand manages policy list subscriptions. The scope is either "here" for the current room
+// or "all" for all joined rooms.
+func (view *RoomView) PolicyCommand(action, policyRoom, scope, mode string) {
+	defer debug.Recover()
+	defer view.parent.parent.Render()
+	if policyRoom == "here" || policyRoom == "all" {
+		// Allow omitting the policy room for dry runs, e.g. `/policy dryrun all`
+		scope, policyRoom = policyRoom, ""
+	}
+	var targetRoomID id.RoomID
+	if scope != "all" {
+		targetRoomID = view.Room.ID
+	}
+	policyRoomID := id.RoomID(strings.TrimSpace(policyRoom))
+	ctx := context.TODO()
+	switch action {
+	case "subscribe", "unsubscribe":
+		if policyRoomID == "" {
+			view.AddServiceMessage("Usage: /policy %s <policy room ID> [here|all] [ban|flag]", action)
+			return
+		}
+		var err error
+		if action == "subscribe" {
+			err = view.parent.matrix.SubscribePolicyRoom(ctx, &jsoncmd.SubscribePolicyRoomParams{
+				PolicyRoomID: policyRoomID,
+				TargetRoomID: targetRoomID,
+				Action:       database.PolicyAction(mode),
+			})
+		} else {
+			err = view.parent.matrix.UnsubscribePolicyRoom(ctx, &jsoncmd.UnsubscribePolicyRoomParams{
+				PolicyRoomID: policyRoomID,
+				TargetRoomID: targetRoomID,
+			})
+		}
+		if err != nil {
+			view.AddServiceMessage("Failed to %s: %v", action, err)
+		} else if action == "subscribe" {
+			view.AddServiceMessage("Subscribed to policy list %s", policyRoomID)
+		} else {
+			view.AddServiceMessage("Unsubscribed from policy list %s", policyRoomID)
+		}
+	case "rules":
+		resp, err := view.parent.matrix.ListPolicyRules(ctx, &jsoncmd.ListPolicyRulesParams{PolicyRoomID: policyRoomID})
+		if err != nil {
+			view.AddServiceMessage("Failed to list policy rules: %v", err)
+			return
+		}
+		var buf strings.Builder
+		_, _ = fmt.Fprintf(&buf, "%d subscriptions, %d ban rules, %d enforcements", len(resp.Subscriptions), len(resp.Rules), len(resp.Enforcements))
+		for _, sub := range resp.Subscriptions {
+			target := "all rooms"
+			if sub.TargetRoomID != "" {
+				target = sub.TargetRoomID.String()
+			}
+			_, _ = fmt.Fprintf(&buf, "\n* %s → %s (%s)", sub.PolicyRoomID, target, sub.Action)
+		}
+		for _, rule := range resp.Rules {
+			_, _ = fmt.Fprintf(&buf, "\n* %s %s: %s", rule.EntityType, rule.Entity, rule.Reason)
+		}
+		view.AddServiceMessage(buf.String())
+	case "dryrun":
+		plan, err := view.parent.matrix.PolicyDryRun(ctx, &jsoncmd.PolicyDryRunParams{TargetRoomID: targetRoomID})
+		if err != nil {
+			view.AddServiceMessage("Failed to check policies: %v", err)
+			return
+		} else if len(plan) == 0 {
+			view.AddServiceMessage("No changes would be made")
+			return
+		}
+		var buf strings.Builder
+		_, _ = fmt.Fprintf(&buf, "%d changes would be made:", len(plan))
+		for _, enf := range plan {
+			_, _ = fmt.Fprintf(&buf, "\n* %s in %s", describeEnforcement(enf), enf.RoomID)
+		}
+		view.AddServiceMessage(buf.String())
+	}
+}
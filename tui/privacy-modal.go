@@ -0,0 +1,536 @@
+// gomuks - A terminal Matrix client written in Go.
+// Copyright (C) 2026 Tulir Asokan
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+package tui
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gdamore/tcell/v2"
+	"github.com/tidwall/gjson"
+	"go.mau.fi/mauview"
+	"go.mau.fi/util/ptr"
+	"maunium.net/go/mautrix/event"
+	"maunium.net/go/mautrix/id"
+
+	"go.mau.fi/gomuks/pkg/hicli/database"
+	"go.mau.fi/gomuks/pkg/hicli/jsoncmd"
+	"go.mau.fi/gomuks/pkg/rpc/store"
+	"go.mau.fi/gomuks/tui/config"
+	"go.mau.fi/gomuks/tui/debug"
+)
+
+type privacyOption struct {
+	value       string
+	label       string
+	description string
+}
+
+type privacySection struct {
+	title   string
+	evtType event.Type
+	field   string
+	// defaultValue is the value that applies if the room doesn't have the state event at all.
+	defaultValue string
+	options      []privacyOption
+}
+
+var privacySections = []*privacySection{{
+	title:        "Who can join",
+	evtType:      event.StateJoinRules,
+	field:        "join_rule",
+	defaultValue: string(event.JoinRuleInvite),
+	options: []privacyOption{
+		{string(event.JoinRuleInvite), "Invite only", "Only people who have been invited can join."},
+		{string(event.JoinRuleKnock), "Ask to join", "Anyone can ask to join, but someone in the room has to accept the request."},
+		{string(event.JoinRuleRestricted), "Space members", "Members of the selected spaces can join directly, anyone else needs an invite."},
+		{string(event.JoinRuleKnockRestricted), "Space members or ask to join", "Members of the selected spaces can join directly, anyone else can ask to join."},
+		{string(event.JoinRulePublic), "Public", "Anyone can join without an invite."},
+	},
+}, {
+	title:        "Who can read history",
+	evtType:      event.StateHistoryVisibility,
+	field:        "history_visibility",
+	defaultValue: string(event.HistoryVisibilityShared),
+	options: []privacyOption{
+		{string(event.HistoryVisibilityWorldReadable), "Anyone", "Anyone can read the history, even without joining the room."},
+		{string(event.HistoryVisibilityShared), "Members", "Members can read the whole history, including messages sent before they joined."},
+		{string(event.HistoryVisibilityInvited), "Members, since they were invited", "Members can only read messages sent after they were invited."},
+		{string(event.HistoryVisibilityJoined), "Members, since they joined", "Members can only read messages sent after they joined."},
+	},
+}, {
+	title:        "Guest access",
+	evtType:      event.StateGuestAccess,
+	field:        "guest_access",
+	defaultValue: string(event.GuestAccessForbidden),
+	options: []privacyOption{
+		{string(event.GuestAccessCanJoin), "Allowed", "Guest accounts can join if the join rule allows them to."},
+		{string(event.GuestAccessForbidden), "Forbidden", "Guest accounts can't join the room."},
+	},
+}}
+
+// formatRetention describes how long messages are kept in a room with the given maximum lifetime.
+func formatRetention(maxAge time.Duration) string {
+	hours := max(int(maxAge.Round(time.Hour)/time.Hour), 1)
+	if hours == 1 {
+		return "Messages are deleted after 1 hour"
+	} else if hours < 48 {
+		return fmt.Sprintf("Messages are deleted after %d hours", hours)
+	}
+	return fmt.Sprintf("Messages are deleted after %d days", (hours+12)/24)
+}
+
+func isRestrictedJoinRule(value string) bool {
+	return value == string(event.JoinRuleRestricted) || value == string(event.JoinRuleKnockRestricted)
+}
+
+// privacyChange is a change that has been sent to the server, but hasn't come back down sync yet.
+type privacyChange struct {
+	option  *privacyOption
+	allow   []event.JoinRuleAllow
+	eventID id.EventID
+}
+
+type privacyRow struct {
+	section *privacySection
+	option  *privacyOption
+	space   id.RoomID
+	save    bool
+}
+
+type PrivacyModal struct {
+	mauview.Component
+
+	container *mauview.Box
+	list      *mauview.TextView
+	status    *mauview.TextField
+
+	lock     sync.Mutex
+	rows     []privacyRow
+	selected int
+	pending  map[*privacySection]*privacyChange
+	// picking is the restricted join rule that the space picker is open for, or nil when the picker isn't open.
+	picking        *privacyOption
+	spaces         []id.RoomID
+	selectedSpaces map[id.RoomID]bool
+	unsubscribe    []func()
+
+	room   *RoomView
+	parent *MainView
+}
+
+func NewPrivacyModal(parent *MainView, room *RoomView) *PrivacyModal {
+	pm := &PrivacyModal{
+		parent:  parent,
+		room:    room,
+		pending: make(map[*privacySection]*privacyChange),
+	}
+
+	pm.list = mauview.NewTextView().SetRegions(true).SetDynamicColors(true)
+	pm.status = mauview.NewTextField()
+
+	flex := mauview.NewFlex().
+		SetDirection(mauview.FlexRow).
+		AddProportionalComponent(pm.list, 1).
+		AddFixedComponent(pm.status, 1)
+
+	pm.container = mauview.NewBox(flex).
+		SetBorder(true).
+		SetTitle(fmt.Sprintf("Privacy of %s", room.Room.Meta.Current().ID)).
+		SetBlurCaptureFunc(func() bool {
+			pm.close()
+			return true
+		})
+	if name := ptr.Val(room.Room.Meta.Current().Name); name != "" {
+		pm.container.SetTitle(fmt.Sprintf("Privacy of %s", name))
+	}
+	pm.Component = mauview.Center(pm.container, 90, 34).SetAlwaysFocusChild(true)
+
+	for _, section := range privacySections {
+		pm.unsubscribe = append(pm.unsubscribe, room.Room.StateSubs.Listen(store.StateKeySub(section.evtType, ""), func() {
+			// State notifications are sent while the room store is locked, so don't read the state synchronously
+			go pm.onStateChange()
+		}))
+	}
+	pm.unsubscribe = append(pm.unsubscribe, room.Room.StateSubs.Listen(store.StateKeySub(database.StateRetention, ""), func() {
+		go pm.onStateChange()
+	}))
+	pm.unsubscribe = append(pm.unsubscribe, room.Room.StateSubs.Listen(event.StatePowerLevels.Type, func() {
+		go pm.onStateChange()
+	}))
+
+	pm.lock.Lock()
+	pm.renderLocked()
+	pm.lock.Unlock()
+	if !room.Room.StateLoaded.Load() {
+		go pm.loadState()
+	}
+
+	return pm
+}
+
+func (pm *PrivacyModal) Focus() {
+	pm.container.Focus()
+}
+
+func (pm *PrivacyModal) Blur() {
+	pm.container.Blur()
+}
+
+func (pm *PrivacyModal) close() {
+	pm.lock.Lock()
+	for _, unsubscribe := range pm.unsubscribe {
+		unsubscribe()
+	}
+	pm.unsubscribe = nil
+	pm.lock.Unlock()
+	pm.parent.HideModal()
+}
+
+func (pm *PrivacyModal) loadState() {
+	defer debug.Recover()
+	err := pm.parent.matrix.LoadRoomState(context.TODO(), pm.room.Room.ID, false, false)
+	if err != nil {
+		pm.setStatus(tcell.ColorRed, fmt.Sprintf("Failed to load room state: %v", err))
+		return
+	}
+	pm.onStateChange()
+}
+
+func (pm *PrivacyModal) setStatus(color tcell.Color, text string) {
+	pm.status.SetTextColor(color).SetText(text)
+	pm.parent.parent.Render()
+}
+
+func (pm *PrivacyModal) currentValue(section *privacySection) (string, []event.JoinRuleAllow, id.EventID) {
+	evt := pm.room.Room.GetStateEvent(section.evtType, "")
+	if evt == nil {
+		return section.defaultValue, nil, ""
+	}
+	value := gjson.GetBytes(evt.Content, section.field).Str
+	if value == "" {
+		value = section.defaultValue
+	}
+	var allow []event.JoinRuleAllow
+	if section.evtType == event.StateJoinRules && isRestrictedJoinRule(value) {
+		allow = evt.GetMautrixContent().AsJoinRules().Allow
+	}
+	return value, allow, evt.ID
+}
+
+// displayedValue returns the value that should be shown as selected, which is the pending change if there is one.
+func (pm *PrivacyModal) displayedValue(section *privacySection) (string, []event.JoinRuleAllow) {
+	if change, ok := pm.pending[section]; ok {
+		return change.option.value, change.allow
+	}
+	value, allow, _ := pm.currentValue(section)
+	return value, allow
+}
+
+func (pm *PrivacyModal) requiredLevel(section *privacySection) (required int, ok bool) {
+	pls := pm.room.Room.GetPowerLevels()
+	required = pls.GetEventLevel(section.evtType)
+	return required, pls.GetUserLevel(pm.parent.matrix.UserID) >= required
+}
+
+func (pm *PrivacyModal) spaceName(roomID id.RoomID) string {
+	if space := pm.parent.matrix.GetRoom(roomID); space != nil {
+		if name := ptr.Val(space.Meta.Current().Name); name != "" {
+			return name
+		}
+	}
+	return roomID.String()
+}
+
+func (pm *PrivacyModal) allowNames(allow []event.JoinRuleAllow) string {
+	names := make([]string, 0, len(allow))
+	for _, item := range allow {
+		if item.Type == event.JoinRuleAllowRoomMembership {
+			names = append(names, pm.spaceName(item.RoomID))
+		}
+	}
+	if len(names) == 0 {
+		return "(no spaces)"
+	}
+	return strings.Join(names, ", ")
+}
+
+func (pm *PrivacyModal) renderLocked() {
+	pm.list.Clear()
+	pm.rows = pm.rows[:0]
+	if pm.picking != nil {
+		pm.renderSpacePickerLocked()
+	} else {
+		pm.renderSettingsLocked()
+	}
+	if len(pm.rows) > 0 {
+		pm.selected = max(0, min(pm.selected, len(pm.rows)-1))
+		pm.list.Highlight(strconv.Itoa(pm.selected))
+		pm.list.ScrollToHighlight()
+	} else {
+		pm.list.Highlight()
+	}
+}
+
+func (pm *PrivacyModal) renderSettingsLocked() {
+	for _, section := range privacySections {
+		value, allow := pm.displayedValue(section)
+		change := pm.pending[section]
+		_, _ = fmt.Fprintf(pm.list, "[::b]%s[::-]", section.title)
+		if required, ok := pm.requiredLevel(section); !ok {
+			_, _ = fmt.Fprintf(pm.list, " [gray](requires power level %d)[-]", required)
+		}
+		_, _ = fmt.Fprint(pm.list, "\n")
+		for i := range section.options {
+			option := &section.options[i]
+			marker := "○"
+			if option.value == value {
+				marker = "●"
+			}
+			_, _ = fmt.Fprintf(pm.list, `["%d"]%s %s[""]`, len(pm.rows), marker, option.label)
+			if option.value == value && change != nil {
+				if change.eventID == "" {
+					_, _ = fmt.Fprint(pm.list, " [yellow](saving...)[-]")
+				} else {
+					_, _ = fmt.Fprint(pm.list, " [yellow](waiting for server)[-]")
+				}
+			}
+			_, _ = fmt.Fprintf(pm.list, "\n  [gray]%s[-]\n", option.description)
+			if option.value == value && isRestrictedJoinRule(value) {
+				_, _ = fmt.Fprintf(pm.list, "  [gray]Spaces: %s[-]\n", mauview.Escape(pm.allowNames(allow)))
+			}
+			pm.rows = append(pm.rows, privacyRow{section: section, option: option})
+		}
+		_, _ = fmt.Fprint(pm.list, "\n")
+	}
+	if maxAge := pm.room.Room.Meta.Current().Retention.MaxAge(); maxAge > 0 {
+		_, _ = fmt.Fprintf(pm.list, "[::b]Message retention[::-]\n  [gray]%s[-]\n", formatRetention(maxAge))
+	}
+}
+
+func (pm *PrivacyModal) renderSpacePickerLocked() {
+	_, _ = fmt.Fprintf(pm.list, "[::b]%s[::-]\n", pm.picking.label)
+	_, _ = fmt.Fprintf(pm.list, "[gray]Choose which spaces' members can join. Press confirm to toggle a space.[-]\n\n")
+	for _, spaceID := range pm.spaces {
+		marker := "[ ]"
+		if pm.selectedSpaces[spaceID] {
+			marker = "[x[]"
+		}
+		_, _ = fmt.Fprintf(pm.list, `["%d"]%s %s[""]`+"\n", len(pm.rows), marker, mauview.Escape(pm.spaceName(spaceID)))
+		pm.rows = append(pm.rows, privacyRow{space: spaceID})
+	}
+	_, _ = fmt.Fprintf(pm.list, `%s["%d"]Save[""]`+"\n", "\n", len(pm.rows))
+	pm.rows = append(pm.rows, privacyRow{save: true})
+}
+
+func (pm *PrivacyModal) onStateChange() {
+	defer debug.Recover()
+	pm.lock.Lock()
+	var confirmed []string
+	for section, change := range pm.pending {
+		if change.eventID == "" {
+			continue
+		}
+		if _, _, eventID := pm.currentValue(section); eventID == change.eventID {
+			delete(pm.pending, section)
+			confirmed = append(confirmed, fmt.Sprintf("%s: %s", section.title, change.option.label))
+		}
+	}
+	pm.renderLocked()
+	pm.lock.Unlock()
+	if len(confirmed) > 0 {
+		slices.Sort(confirmed)
+		pm.setStatus(tcell.ColorGreen, "Changed "+strings.Join(confirmed, ", "))
+	} else {
+		pm.parent.parent.Render()
+	}
+}
+
+func (pm *PrivacyModal) moveSelection(diff int) {
+	pm.lock.Lock()
+	defer pm.lock.Unlock()
+	if len(pm.rows) == 0 {
+		return
+	}
+	pm.selected = max(0, min(pm.selected+diff, len(pm.rows)-1))
+	pm.list.Highlight(strconv.Itoa(pm.selected))
+	pm.list.ScrollToHighlight()
+}
+
+func (pm *PrivacyModal) confirm() {
+	pm.lock.Lock()
+	defer pm.lock.Unlock()
+	if pm.selected < 0 || pm.selected >= len(pm.rows) {
+		return
+	}
+	row := pm.rows[pm.selected]
+	switch {
+	case row.save:
+		var allow []event.JoinRuleAllow
+		for _, spaceID := range pm.spaces {
+			if pm.selectedSpaces[spaceID] {
+				allow = append(allow, event.JoinRuleAllow{Type: event.JoinRuleAllowRoomMembership, RoomID: spaceID})
+			}
+		}
+		if len(allow) == 0 {
+			pm.setStatus(tcell.ColorRed, "Select at least one space")
+			return
+		}
+		option := pm.picking
+		pm.picking = nil
+		pm.selected = 0
+		pm.saveLocked(privacySections[0], &privacyChange{option: option, allow: allow})
+	case row.space != "":
+		pm.selectedSpaces[row.space] = !pm.selectedSpaces[row.space]
+		pm.renderLocked()
+	case row.option != nil:
+		if required, ok := pm.requiredLevel(row.section); !ok {
+			pm.setStatus(tcell.ColorRed, fmt.Sprintf("You need power level %d to change %s", required, strings.ToLower(row.section.title)))
+			return
+		} else if _, ok = pm.pending[row.section]; ok {
+			pm.setStatus(tcell.ColorRed, "The previous change hasn't been confirmed by the server yet")
+			return
+		}
+		if isRestrictedJoinRule(row.option.value) {
+			pm.openSpacePickerLocked(row.option)
+			return
+		}
+		if value, _, _ := pm.currentValue(row.section); value == row.option.value {
+			pm.setStatus(tcell.ColorDefault, fmt.Sprintf("%s is already %s", row.section.title, strings.ToLower(row.option.label)))
+			return
+		}
+		pm.saveLocked(row.section, &privacyChange{option: row.option})
+	}
+}
+
+func (pm *PrivacyModal) openSpacePickerLocked(option *privacyOption) {
+	value, allow, _ := pm.currentValue(privacySections[0])
+	pm.spaces = pm.spaces[:0]
+	pm.selectedSpaces = make(map[id.RoomID]bool)
+	for _, space := range pm.parent.matrix.GetJoinedSpaces() {
+		pm.spaces = append(pm.spaces, space.ID)
+	}
+	if isRestrictedJoinRule(value) {
+		for _, item := range allow {
+			if item.Type != event.JoinRuleAllowRoomMembership {
+				continue
+			}
+			// Keep spaces that the user isn't in, so that saving doesn't silently drop them from the allow list
+			if !slices.Contains(pm.spaces, item.RoomID) {
+				pm.spaces = append(pm.spaces, item.RoomID)
+			}
+			pm.selectedSpaces[item.RoomID] = true
+		}
+	}
+	if len(pm.spaces) == 0 {
+		pm.setStatus(tcell.ColorRed, "You aren't in any spaces")
+		return
+	}
+	pm.picking = option
+	pm.selected = 0
+	pm.renderLocked()
+	pm.setStatus(tcell.ColorDefault, "")
+}
+
+func (pm *PrivacyModal) saveLocked(section *privacySection, change *privacyChange) {
+	var content any
+	switch section.evtType {
+	case event.StateJoinRules:
+		content = &event.JoinRulesEventContent{JoinRule: event.JoinRule(change.option.value), Allow: change.allow}
+	case event.StateHistoryVisibility:
+		content = &event.HistoryVisibilityEventContent{HistoryVisibility: event.HistoryVisibility(change.option.value)}
+	case event.StateGuestAccess:
+		content = &event.GuestAccessEventContent{GuestAccess: event.GuestAccess(change.option.value)}
+	}
+	rawContent, err := json.Marshal(content)
+	if err != nil {
+		pm.setStatus(tcell.ColorRed, fmt.Sprintf("Failed to marshal content: %v", err))
+		return
+	}
+	pm.pending[section] = change
+	pm.renderLocked()
+	pm.setStatus(tcell.ColorDefault, "Saving...")
+	go pm.save(section, change, rawContent)
+}
+
+func (pm *PrivacyModal) save(section *privacySection, change *privacyChange, content json.RawMessage) {
+	defer debug.Recover()
+	eventID, err := pm.parent.matrix.SetState(context.TODO(), &jsoncmd.SendStateEventParams{
+		RoomID:    pm.room.Room.ID,
+		EventType: section.evtType,
+		StateKey:  "",
+		Content:   content,
+	})
+	pm.lock.Lock()
+	if pm.pending[section] != change {
+		pm.lock.Unlock()
+		return
+	} else if err != nil {
+		delete(pm.pending, section)
+		pm.renderLocked()
+		pm.lock.Unlock()
+		pm.setStatus(tcell.ColorRed, fmt.Sprintf("Failed to change %s: %v", strings.ToLower(section.title), err))
+		return
+	}
+	change.eventID = eventID
+	pm.renderLocked()
+	pm.lock.Unlock()
+	pm.setStatus(tcell.ColorDefault, "Waiting for the server to confirm the change...")
+	// The event may have come down sync before the request returned
+	pm.onStateChange()
+}
+
+func (pm *PrivacyModal) OnKeyEvent(event mauview.KeyEvent) bool {
+	kb := config.Keybind{
+		Key: event.Key(),
+		Ch:  event.Rune(),
+		Mod: event.Modifiers(),
+	}
+	switch pm.parent.config.Keybindings.Modal[kb] {
+	case "cancel":
+		pm.lock.Lock()
+		picking := pm.picking != nil
+		if picking {
+			pm.picking = nil
+			pm.selected = 0
+			pm.renderLocked()
+		}
+		pm.lock.Unlock()
+		if !picking {
+			pm.close()
+		} else {
+			pm.setStatus(tcell.ColorDefault, "")
+		}
+		return true
+	case "select_next":
+		pm.moveSelection(1)
+		return true
+	case "select_prev":
+		pm.moveSelection(-1)
+		return true
+	case "confirm":
+		pm.confirm()
+		return true
+	}
+	return false
+}
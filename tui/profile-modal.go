@@ -0,0 +1,242 @@
+// gomuks - A terminal Matrix client written in Go.
+// Copyright (C) 2020 Tulir Asokan
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+package tui
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/gdamore/tcell/v2"
+	"go.mau.fi/mauview"
+
+	"go.mau.fi/gomuks/pkg/hicli/jsoncmd"
+	"go.mau.fi/gomuks/pkg/rpc"
+	"go.mau.fi/gomuks/tui/config"
+	"go.mau.fi/gomuks/tui/debug"
+)
+
+var errEmptyDisplayname = errors.New("display name can't be empty")
+
+type ProfileModal struct {
+	mauview.Component
+
+	form *mauview.Form
+
+	currentName   *mauview.TextField
+	currentAvatar *mauview.TextField
+	status        *mauview.TextField
+
+	nameInput   *mauview.InputField
+	avatarInput *mauview.InputField
+
+	cancel *mauview.Button
+	submit *mauview.Button
+
+	originalName string
+	saving       bool
+
+	room   *RoomView
+	parent *MainView
+}
+
+func NewProfileModal(parent *MainView, room *RoomView) *ProfileModal {
+	pm := &ProfileModal{
+		parent: parent,
+		room:   room,
+		form:   mauview.NewForm(),
+	}
+
+	pm.form.
+		SetColumns([]int{1, 14, 1, 14, 1, 14, 1}).
+		SetRows([]int{1, 1, 1, 1, 1, 1, 1, 1, 1, 1, 1, 1, 1})
+
+	pm.currentName = mauview.NewTextField().SetText("Display name: loading...")
+	pm.currentAvatar = mauview.NewTextField().SetText("Avatar: loading...")
+	pm.status = mauview.NewTextField().SetTextColor(tcell.ColorRed)
+	pm.nameInput = mauview.NewInputField().SetPlaceholder("New display name")
+	pm.avatarInput = mauview.NewInputField().SetPlaceholder("Path to a new avatar image")
+
+	pm.form.AddComponent(pm.currentName, 1, 1, 5, 1)
+	pm.form.AddComponent(pm.currentAvatar, 1, 2, 5, 1)
+	// The session is shown here so that it's easy to find which one to verify from another client
+	pm.form.AddComponent(mauview.NewTextField().SetText(
+		fmt.Sprintf("Session: %s", describeSession(&parent.matrix.ClientState)),
+	), 1, 3, 5, 1)
+	pm.form.AddComponent(mauview.NewTextField().SetText("Display name"), 1, 5, 5, 1)
+	pm.form.AddFormItem(pm.nameInput, 1, 6, 5, 1)
+	pm.form.AddComponent(mauview.NewTextField().SetText("Avatar file"), 1, 7, 5, 1)
+	pm.form.AddFormItem(pm.avatarInput, 1, 8, 5, 1)
+	pm.form.AddComponent(pm.status, 1, 9, 5, 1)
+
+	pm.cancel = mauview.NewButton("Cancel").SetOnClick(pm.close)
+	pm.submit = mauview.NewButton("Save").SetOnClick(pm.ClickSubmit)
+	pm.form.AddFormItem(pm.cancel, 1, 11, 1, 1)
+	pm.form.AddFormItem(pm.submit, 5, 11, 1, 1)
+
+	box := mauview.NewBox(pm.form).SetTitle(fmt.Sprintf("Profile of %s", parent.matrix.UserID))
+	center := mauview.Center(box, 50, 15).SetAlwaysFocusChild(true)
+	center.Focus()
+	pm.form.FocusNextItem()
+	pm.Component = center
+
+	go pm.load()
+
+	return pm
+}
+
+func (pm *ProfileModal) load() {
+	defer debug.Recover()
+	profile, err := pm.parent.matrix.GetProfile(context.TODO(), &jsoncmd.GetProfileParams{
+		UserID: pm.parent.matrix.UserID,
+	})
+	if err != nil {
+		pm.currentName.SetText("Display name: unknown")
+		pm.currentAvatar.SetText("Avatar: unknown")
+		pm.status.SetText(fmt.Sprintf("Failed to load profile: %v", err))
+	} else {
+		pm.originalName = profile.DisplayName
+		pm.currentName.SetText(fmt.Sprintf("Display name: %s", orNone(profile.DisplayName)))
+		pm.currentAvatar.SetText(fmt.Sprintf("Avatar: %s", orNone(profile.AvatarURL.String())))
+		if pm.nameInput.GetText() == "" {
+			pm.nameInput.SetTextAndMoveCursor(profile.DisplayName)
+		}
+	}
+	pm.parent.parent.Render()
+}
+
+func orNone(val string) string {
+	if val == "" {
+		return "(none)"
+	}
+	return val
+}
+
+func (pm *ProfileModal) close() {
+	pm.parent.HideModal()
+}
+
+func (pm *ProfileModal) OnKeyEvent(event mauview.KeyEvent) bool {
+	kb := config.Keybind{
+		Key: event.Key(),
+		Ch:  event.Rune(),
+		Mod: event.Modifiers(),
+	}
+	if pm.parent.config.Keybindings.Modal[kb] == "cancel" {
+		pm.close()
+		return true
+	}
+	return pm.Component.OnKeyEvent(event)
+}
+
+func (pm *ProfileModal) ClickSubmit() {
+	if pm.saving {
+		return
+	}
+	name := strings.TrimSpace(pm.nameInput.GetText())
+	avatarPath := strings.TrimSpace(pm.avatarInput.GetText())
+	if name == "" {
+		pm.status.SetText(errEmptyDisplayname.Error())
+		return
+	}
+	if name == pm.originalName && avatarPath == "" {
+		pm.close()
+		return
+	}
+	pm.saving = true
+	pm.status.SetTextColor(tcell.ColorDefault).SetText("Saving...")
+	go pm.save(name, avatarPath)
+}
+
+func (pm *ProfileModal) save(name, avatarPath string) {
+	defer debug.Recover()
+	defer func() {
+		pm.saving = false
+		pm.parent.parent.Render()
+	}()
+	if name != pm.originalName {
+		err := pm.parent.SetGlobalDisplayname(name)
+		if err != nil {
+			pm.status.SetTextColor(tcell.ColorRed).SetText(err.Error())
+			return
+		}
+		pm.originalName = name
+		pm.currentName.SetText(fmt.Sprintf("Display name: %s", name))
+	}
+	if avatarPath != "" {
+		mxc, err := pm.parent.SetGlobalAvatarFromFile(avatarPath)
+		if err != nil {
+			pm.status.SetTextColor(tcell.ColorRed).SetText(err.Error())
+			return
+		}
+		pm.avatarInput.SetText("")
+		pm.currentAvatar.SetText(fmt.Sprintf("Avatar: %s", mxc))
+	}
+	pm.close()
+	pm.room.AddServiceMessage("Profile updated")
+}
+
+// SetGlobalDisplayname changes the account-wide display name. The member list of each room is
+// updated when the resulting member events come down sync.
+func (view *MainView) SetGlobalDisplayname(name string) error {
+	name = strings.TrimSpace(name)
+	if name == "" {
+		return errEmptyDisplayname
+	}
+	err := view.matrix.SetProfileField(context.TODO(), &jsoncmd.SetProfileFieldParams{
+		Field: "displayname",
+		Value: name,
+	})
+	if err != nil {
+		return fmt.Errorf("failed to set display name: %w", err)
+	}
+	return nil
+}
+
+// SetGlobalAvatarFromFile uploads the given local file and sets it as the account-wide avatar.
+func (view *MainView) SetGlobalAvatarFromFile(path string) (string, error) {
+	if strings.HasPrefix(path, "~/") {
+		home, err := os.UserHomeDir()
+		if err == nil {
+			path = filepath.Join(home, path[2:])
+		}
+	}
+	file, err := os.Open(path)
+	if err != nil {
+		return "", fmt.Errorf("failed to open avatar file: %w", err)
+	}
+	defer file.Close()
+	content, err := view.matrix.UploadMedia(context.TODO(), file, rpc.UploadMediaParams{
+		FileName: filepath.Base(path),
+	})
+	if err != nil {
+		return "", fmt.Errorf("failed to upload avatar: %w", err)
+	} else if content.URL == "" {
+		return "", fmt.Errorf("failed to upload avatar: server didn't return a content URI")
+	}
+	err = view.matrix.SetProfileField(context.TODO(), &jsoncmd.SetProfileFieldParams{
+		Field: "avatar_url",
+		Value: content.URL,
+	})
+	if err != nil {
+		return "", fmt.Errorf("failed to set avatar: %w", err)
+	}
+	return string(content.URL), nil
+}
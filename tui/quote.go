@@ -0,0 +1,171 @@
+// gomuks - A terminal Matrix client written in Go.
+// Copyright (C) 2026 Tulir Asokan
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+package tui
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"go.mau.fi/gomuks/tui/config"
+	"go.mau.fi/gomuks/tui/messages"
+)
+
+// quoteSelection is the range of lines being picked from a message in the quote sub-selection mode.
+// The lines are the logical lines of the plain text of the message, so wrapping doesn't affect them.
+type quoteSelection struct {
+	message *messages.UIMessage
+	lines   []string
+	anchor  int
+	cursor  int
+	reply   bool
+}
+
+func (qs *quoteSelection) bounds() (start, end int) {
+	return min(qs.anchor, qs.cursor), max(qs.anchor, qs.cursor)
+}
+
+const quotePreviewLength = 40
+
+func (qs *quoteSelection) status() string {
+	start, end := qs.bounds()
+	var lineRange string
+	if start == end {
+		lineRange = fmt.Sprintf("line %d", start+1)
+	} else {
+		lineRange = fmt.Sprintf("lines %d-%d", start+1, end+1)
+	}
+	preview := []rune(strings.TrimSpace(qs.lines[qs.cursor]))
+	if len(preview) > quotePreviewLength {
+		preview = append(preview[:quotePreviewLength], '…')
+	}
+	return fmt.Sprintf("Quoting %s of %d (Space to start range here, Enter to insert): %s", lineRange, len(qs.lines), string(preview))
+}
+
+var (
+	// quoteInlineEscapeRegex matches characters that have a meaning anywhere in markdown, including the extensions
+	// for spoilers (||), strikethrough (~~) and math ($).
+	quoteInlineEscapeRegex = regexp.MustCompile("([\\\\`*_\\[\\]()~|$<>&])")
+	// quoteBlockEscapeRegex and quoteListEscapeRegex match the start of lines that would otherwise become headers
+	// or lists. Nested quotes don't need to be handled here, as > is already escaped by the inline regex.
+	quoteBlockEscapeRegex = regexp.MustCompile(`^([#+=-])`)
+	quoteListEscapeRegex  = regexp.MustCompile(`^([0-9]+)([.)])`)
+)
+
+// escapeQuoteLine escapes a line of plain text so that it's rendered as-is inside a markdown blockquote.
+func escapeQuoteLine(line string) string {
+	// Leading whitespace would turn the line into a code block
+	line = strings.TrimLeft(line, " \t")
+	line = quoteInlineEscapeRegex.ReplaceAllString(line, "\\$1")
+	line = quoteBlockEscapeRegex.ReplaceAllString(line, "\\$1")
+	return quoteListEscapeRegex.ReplaceAllString(line, "$1\\$2")
+}
+
+// formatQuote formats the given lines as a markdown blockquote, followed by an empty line for the reply.
+func formatQuote(lines []string) string {
+	var buf strings.Builder
+	for _, line := range lines {
+		buf.WriteString("> ")
+		buf.WriteString(escapeQuoteLine(line))
+		buf.WriteString("\n")
+	}
+	buf.WriteString("\n")
+	return buf.String()
+}
+
+// parseLineRange parses a 1-indexed line range like "3" or "2-4" into 0-indexed inclusive bounds.
+func parseLineRange(lineRange string, lineCount int) (start, end int, err error) {
+	startStr, endStr, isRange := strings.Cut(lineRange, "-")
+	start, err = strconv.Atoi(strings.TrimSpace(startStr))
+	if err != nil {
+		return 0, 0, fmt.Errorf("invalid line number %q", startStr)
+	}
+	end = start
+	if isRange {
+		end, err = strconv.Atoi(strings.TrimSpace(endStr))
+		if err != nil {
+			return 0, 0, fmt.Errorf("invalid line number %q", endStr)
+		}
+	}
+	if start < 1 || end < start || end > lineCount {
+		return 0, 0, fmt.Errorf("line range must be within 1-%d", lineCount)
+	}
+	return start - 1, end - 1, nil
+}
+
+// StartQuoting quotes lines of the given message into the input box. If lineRange is empty and the message has
+// multiple lines, the quote sub-selection mode is started and true is returned. If reply is true, the quote is sent
+// as a reply to the message.
+func (view *RoomView) StartQuoting(message *messages.UIMessage, reply bool, lineRange string) bool {
+	text := strings.TrimRight(message.Renderer.PlainText(), "\n")
+	if strings.TrimSpace(text) == "" {
+		view.AddServiceMessage("The selected message has no text to quote")
+		return false
+	}
+	qs := &quoteSelection{
+		message: message,
+		lines:   strings.Split(text, "\n"),
+		reply:   reply,
+	}
+	if lineRange != "" {
+		var err error
+		qs.anchor, qs.cursor, err = parseLineRange(lineRange, len(qs.lines))
+		if err != nil {
+			view.AddServiceMessage("Failed to quote message: %v", err)
+			return false
+		}
+	} else if len(qs.lines) > 1 {
+		view.quoting = qs
+		return true
+	} else {
+		qs.cursor = len(qs.lines) - 1
+	}
+	view.insertQuote(qs)
+	return false
+}
+
+func (view *RoomView) insertQuote(qs *quoteSelection) {
+	start, end := qs.bounds()
+	if qs.reply {
+		view.replying = qs.message.Event
+	}
+	view.SetInputText(formatQuote(qs.lines[start:end+1]) + view.input.GetText())
+}
+
+// onQuoteKey handles keys in the quote sub-selection mode. The visual mode keybindings are used for moving
+// the cursor, confirming and cancelling, and space starts the range from the current line.
+func (view *RoomView) onQuoteKey(kb config.Keybind) {
+	qs := view.quoting
+	if kb.Ch == ' ' {
+		qs.anchor = qs.cursor
+		return
+	}
+	switch view.config.Keybindings.Visual[kb] {
+	case "select_prev":
+		qs.cursor = max(qs.cursor-1, 0)
+	case "select_next":
+		qs.cursor = min(qs.cursor+1, len(qs.lines)-1)
+	case "confirm":
+		view.insertQuote(qs)
+		view.StopSelecting()
+		view.input.Focus()
+	case "clear":
+		// Go back to selecting the message
+		view.quoting = nil
+	}
+}
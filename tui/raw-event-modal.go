@@ -0,0 +1,225 @@
+// gomuks - A terminal Matrix client written in Go.
+// Copyright (C) 2026 Tulir Asokan
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+package tui
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/gdamore/tcell/v2"
+	"go.mau.fi/mauview"
+	"maunium.net/go/mautrix/event"
+
+	"go.mau.fi/gomuks/pkg/hicli/jsoncmd"
+	"go.mau.fi/gomuks/tui/config"
+	"go.mau.fi/gomuks/tui/debug"
+)
+
+// rawContentTemplates are used to pre-fill the raw event modal for common event types.
+var rawContentTemplates = map[string]string{
+	event.EventMessage.Type:      `{"msgtype": "m.text", "body": ""}`,
+	event.EventReaction.Type:     `{"m.relates_to": {"rel_type": "m.annotation", "event_id": "", "key": ""}}`,
+	event.EventRedaction.Type:    `{"redacts": "", "reason": ""}`,
+	event.StateTopic.Type:        `{"topic": ""}`,
+	event.StateRoomName.Type:     `{"name": ""}`,
+	event.StatePinnedEvents.Type: `{"pinned": []}`,
+}
+
+// RawEventModal is a modal for entering the raw JSON content of an event to send.
+type RawEventModal struct {
+	mauview.Component
+
+	container *mauview.Box
+	input     *mauview.InputArea
+	status    *mauview.TextField
+
+	evtType  event.Type
+	stateKey *string
+
+	room   *RoomView
+	parent *MainView
+}
+
+func rawDraftKey(evtType event.Type, stateKey *string) string {
+	if stateKey != nil {
+		return fmt.Sprintf("state:%s:%s", evtType.Type, *stateKey)
+	}
+	return fmt.Sprintf("message:%s", evtType.Type)
+}
+
+// NewRawEventModal creates a modal for sending a message event, or a state event if stateKey is non-nil.
+func NewRawEventModal(parent *MainView, room *RoomView, evtType event.Type, stateKey *string) *RawEventModal {
+	rem := &RawEventModal{
+		evtType:  evtType,
+		stateKey: stateKey,
+		room:     room,
+		parent:   parent,
+	}
+	rem.input = mauview.NewInputArea().
+		SetTextColor(tcell.ColorWhite).
+		SetBackgroundColor(tcell.ColorDarkCyan)
+	rem.input.SetTextAndMoveCursor(room.getRawContentDraft(evtType, stateKey))
+	rem.input.Focus()
+	rem.status = mauview.NewTextField().SetText("Enter to send, Alt+Enter for a new line, Esc to cancel")
+
+	flex := mauview.NewFlex().
+		SetDirection(mauview.FlexRow).
+		AddProportionalComponent(rem.input, 1).
+		AddFixedComponent(rem.status, 1)
+
+	title := fmt.Sprintf("Send %s event", evtType.Type)
+	if stateKey != nil {
+		title = fmt.Sprintf("Send %s state event with key %q", evtType.Type, *stateKey)
+	}
+	rem.container = mauview.NewBox(flex).
+		SetBorder(true).
+		SetTitle(title).
+		SetBlurCaptureFunc(func() bool {
+			rem.close()
+			return true
+		})
+	rem.Component = mauview.FractionalCenter(rem.container, 42, 10, 0.6, 0.5)
+	return rem
+}
+
+// getRawContentDraft returns the content last entered for the given event type in this room,
+// or a template if nothing has been entered yet. State events default to the current state.
+func (view *RoomView) getRawContentDraft(evtType event.Type, stateKey *string) string {
+	if draft, ok := view.rawContentDrafts[rawDraftKey(evtType, stateKey)]; ok {
+		return draft
+	}
+	if stateKey != nil {
+		if evt := view.Room.GetStateEvent(evtType, *stateKey); evt != nil {
+			var buf bytes.Buffer
+			if json.Indent(&buf, evt.GetContent(), "", "  ") == nil {
+				return buf.String()
+			}
+		}
+	}
+	if template, ok := rawContentTemplates[evtType.Type]; ok {
+		return template
+	}
+	return "{}"
+}
+
+func (rem *RawEventModal) Focus() {
+	rem.container.Focus()
+}
+
+func (rem *RawEventModal) Blur() {
+	rem.container.Blur()
+}
+
+func (rem *RawEventModal) close() {
+	rem.room.setRawContentDraft(rem.evtType, rem.stateKey, rem.input.GetText())
+	rem.parent.HideModal()
+}
+
+func (view *RoomView) setRawContentDraft(evtType event.Type, stateKey *string, content string) {
+	if view.rawContentDrafts == nil {
+		view.rawContentDrafts = make(map[string]string)
+	}
+	view.rawContentDrafts[rawDraftKey(evtType, stateKey)] = content
+}
+
+func (rem *RawEventModal) OnKeyEvent(event mauview.KeyEvent) bool {
+	kb := config.Keybind{
+		Key: event.Key(),
+		Ch:  event.Rune(),
+		Mod: event.Modifiers(),
+	}
+	if rem.parent.config.Keybindings.Main[kb] == "add_newline" {
+		return rem.input.OnKeyEvent(tcell.NewEventKey(tcell.KeyEnter, '\n', event.Modifiers()|tcell.ModShift))
+	}
+	switch rem.parent.config.Keybindings.Modal[kb] {
+	case "cancel":
+		rem.close()
+		return true
+	case "confirm":
+		rem.submit()
+		return true
+	}
+	return rem.input.OnKeyEvent(event)
+}
+
+func (rem *RawEventModal) submit() {
+	content := strings.TrimSpace(rem.input.GetText())
+	var parsed map[string]any
+	if err := json.Unmarshal([]byte(content), &parsed); err != nil {
+		rem.status.SetTextColor(tcell.ColorRed).SetText(fmt.Sprintf("Invalid JSON: %v", err))
+		return
+	}
+	rem.close()
+	go rem.room.SendRawEvent(rem.evtType, rem.stateKey, json.RawMessage(content))
+}
+
+// SendRawEvent sends an event with the given raw content and shows the result as a service message.
+// If stateKey is non-nil, the content is sent as a state event.
+func (view *RoomView) SendRawEvent(evtType event.Type, stateKey *string, content json.RawMessage) {
+	defer debug.Recover()
+	defer view.parent.parent.Render()
+	if stateKey != nil {
+		evtType.Class = event.StateEventType
+		eventID, err := view.parent.matrix.SetState(context.TODO(), &jsoncmd.SendStateEventParams{
+			RoomID:    view.Room.ID,
+			EventType: evtType,
+			StateKey:  *stateKey,
+			Content:   content,
+		})
+		if err != nil {
+			view.AddServiceMessage("Failed to send %s state event: %v", evtType.Type, err)
+		} else {
+			view.AddServiceMessage("Sent %s state event %s", evtType.Type, eventID)
+		}
+		return
+	}
+	evtType.Class = event.MessageEventType
+	evt, err := view.parent.matrix.SendEvent(context.TODO(), &jsoncmd.SendEventParams{
+		RoomID:      view.Room.ID,
+		EventType:   evtType,
+		Content:     content,
+		Synchronous: true,
+	})
+	if err != nil {
+		view.AddServiceMessage("Failed to send %s event: %v", evtType.Type, err)
+	} else if evt.SendError != "" {
+		view.AddServiceMessage("Failed to send %s event: %s", evtType.Type, evt.SendError)
+	} else {
+		view.AddServiceMessage("Sent %s event %s", evtType.Type, evt.ID)
+	}
+}
+
+// SendWithMsgType sends a markdown message with a custom msgtype.
+func (view *RoomView) SendWithMsgType(msgType event.MessageType, text string) {
+	defer debug.Recover()
+	defer view.parent.parent.Render()
+	if msgType == "" || strings.TrimSpace(text) == "" {
+		view.AddServiceMessage("Usage: /msgtype <msgtype> <text>")
+		return
+	}
+	err := view.parent.matrix.SendMessage(context.TODO(), &jsoncmd.SendMessageParams{
+		RoomID:      view.Room.ID,
+		BaseContent: &event.MessageEventContent{MsgType: msgType},
+		Text:        text,
+	})
+	if err != nil {
+		view.AddServiceMessage("Failed to send %s message: %v", msgType, err)
+	}
+}
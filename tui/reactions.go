@@ -0,0 +1,108 @@
+// gomuks - A terminal Matrix client written in Go.
+// Copyright (C) 2026 Tulir Asokan
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+package tui
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"go.mau.fi/util/jsontime"
+	"go.mau.fi/util/ptr"
+
+	"go.mau.fi/gomuks/pkg/hicli/database"
+	"go.mau.fi/gomuks/pkg/hicli/jsoncmd"
+	"go.mau.fi/gomuks/pkg/rpc/store"
+	"go.mau.fi/gomuks/tui/debug"
+)
+
+const (
+	// reactionFeedLimit is how many reactions /reactions shows.
+	reactionFeedLimit = 20
+	// reactionSnippetLength is the maximum number of characters of the reacted-to message shown with a reaction.
+	reactionSnippetLength = 80
+)
+
+// reactionSnippet returns a single-line preview of the message that was reacted to.
+func reactionSnippet(target *database.Event) string {
+	if target == nil {
+		return "a message that isn't stored locally"
+	} else if target.RedactedBy != "" {
+		return "a deleted message"
+	}
+	body := strings.Join(strings.Fields(target.GetMautrixContent().AsMessage().Body), " ")
+	if body == "" {
+		return "a message without text"
+	} else if runes := []rune(body); len(runes) > reactionSnippetLength {
+		body = string(runes[:reactionSnippetLength-1]) + "…"
+	}
+	return body
+}
+
+func reactionKey(reaction *database.Event) string {
+	return reaction.GetMautrixContent().AsReaction().RelatesTo.Key
+}
+
+// ShowReactionsToMe prints the most recent reactions other users have sent to your messages.
+func (view *RoomView) ShowReactionsToMe() {
+	defer debug.Recover()
+	main := view.parent
+	reactions, err := main.matrix.GetReactionsToMe(context.TODO(), &jsoncmd.GetReactionsToMeParams{
+		MaxTimestamp: jsontime.UM(time.Now()),
+		Limit:        reactionFeedLimit,
+	})
+	if err != nil {
+		view.AddServiceMessage("Failed to get reactions: %v", err)
+		main.parent.Render()
+		return
+	} else if len(reactions) == 0 {
+		view.AddServiceMessage("Nobody has reacted to your messages yet")
+		main.parent.Render()
+		return
+	}
+	view.AddServiceMessage("Recent reactions to your messages:")
+	// The reactions are sorted newest first, but service messages are appended to the bottom
+	for i := len(reactions) - 1; i >= 0; i-- {
+		evt := reactions[i].Reaction
+		room := main.matrix.GetRoom(evt.RoomID)
+		sender := evt.Sender.String()
+		var roomName string
+		if room != nil {
+			sender = room.GetDisplayname(evt.Sender)
+			roomName = ptr.Val(room.Meta.Current().Name)
+		}
+		if roomName == "" {
+			roomName = evt.RoomID.String()
+		}
+		view.AddServiceMessage(
+			"%s from %s on: %s (in %s)",
+			reactionKey(evt), sender, reactionSnippet(reactions[i].Target), roomName,
+		)
+	}
+	main.parent.Render()
+}
+
+// reactionNotificationBody formats the notification body for a reaction to one of your messages.
+// The reacted-to message is only shown if it's in the local cache.
+func reactionNotificationBody(room *store.RoomStore, reaction *database.Event) string {
+	target := room.GetEventByID(reaction.RelatesTo)
+	if target == nil {
+		return fmt.Sprintf("Reacted %s to your message", reactionKey(reaction))
+	}
+	return fmt.Sprintf("Reacted %s to: %s", reactionKey(reaction), reactionSnippet(target))
+}
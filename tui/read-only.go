@@ -0,0 +1,86 @@
+// gomuks - A terminal Matrix client written in Go.
+// Copyright (C) 2026 Tulir Asokan
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses/>.
+package tui
+
+import (
+	"github.com/gdamore/tcell/v2"
+	"go.mau.fi/mauview"
+	"maunium.net/go/mautrix/event"
+
+	"go.mau.fi/gomuks/pkg/rpc/store"
+	"go.mau.fi/gomuks/tui/widget"
+)
+
+// listenSendRestriction marks the send restriction for recomputation whenever state that affects it changes.
+// The room store lock is held while subscribers are called, so the restriction is only recomputed when drawing.
+func (view *RoomView) listenSendRestriction() func() {
+	view.sendRestrictionDirty.Store(true)
+	markDirty := func() {
+		view.sendRestrictionDirty.Store(true)
+		view.parent.parent.NeedsRender = true
+	}
+	subs := store.StateSubsAffectingSend(view.parent.matrix.UserID)
+	unlisteners := make([]func(), len(subs))
+	for i, sub := range subs {
+		unlisteners[i] = view.Room.StateSubs.Listen(sub, markDirty)
+	}
+	return func() {
+		for _, unlisten := range unlisteners {
+			unlisten()
+		}
+	}
+}
+
+// readOnlyNotice returns the notice shown instead of the input area if the user can't send messages to the room.
+// Archived rooms are excluded, as the status bar already explains how to rejoin them and commands must still work.
+func (view *RoomView) readOnlyNotice() string {
+	if view.Room.Archived {
+		return ""
+	}
+	if view.sendRestrictionDirty.CompareAndSwap(true, false) {
+		view.sendRestriction = view.Room.GetSendRestriction(event.EventMessage)
+	}
+	return view.sendRestriction.Message()
+}
+
+func (view *RoomView) drawReadOnlyNotice(screen mauview.Screen, notice string) {
+	width, _ := screen.Size()
+	style := tcell.StyleDefault.Foreground(tcell.ColorGray).Italic(true)
+	widget.WriteLinePadded(screen, mauview.AlignLeft, notice, 0, 0, width, style)
+}
+
+// selectionDeniedReason checks whether the user is allowed to send the events that the given selection
+// mode results in. An explanation is returned if not.
+func (view *RoomView) selectionDeniedReason(reason SelectReason) string {
+	var evtType event.Type
+	var action string
+	switch reason {
+	case SelectReact:
+		evtType, action = event.EventReaction, "react to messages"
+	case SelectRedact:
+		evtType, action = event.EventRedaction, "remove messages"
+	default:
+		return ""
+	}
+	switch restriction := view.Room.GetSendRestriction(evtType); restriction {
+	case store.SendAllowed:
+		return ""
+	case store.SendRestrictedPowerLevel:
+		return "You don't have permission to " + action + " in this room"
+	default:
+		return restriction.Message()
+	}
+}
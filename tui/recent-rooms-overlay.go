@@ -0,0 +1,131 @@
+// gomuks - A terminal Matrix client written in Go.
+// Copyright (C) 2026 Tulir Asokan
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+package tui
+
+import (
+	"fmt"
+	"strconv"
+	"sync"
+	"time"
+
+	"go.mau.fi/mauview"
+	"go.mau.fi/util/ptr"
+	"maunium.net/go/mautrix/id"
+
+	"go.mau.fi/gomuks/tui/config"
+)
+
+// RecentRoomCycleTimeout is how long the recent room overlay waits for another press of the
+// switch key before switching to the highlighted room.
+const RecentRoomCycleTimeout = 800 * time.Millisecond
+
+// RecentRoomsOverlay is shown while cycling through the recent room stack. Each press of the
+// recent_room key moves the highlight deeper into the stack, and the highlighted room is opened
+// when the key isn't pressed again for a moment.
+type RecentRoomsOverlay struct {
+	mauview.Component
+
+	list  *mauview.TextView
+	rooms []id.RoomID
+
+	lock     sync.Mutex
+	selected int
+	timer    *time.Timer
+	done     bool
+
+	parent *MainView
+}
+
+func NewRecentRoomsOverlay(mainView *MainView, rooms []id.RoomID) *RecentRoomsOverlay {
+	ro := &RecentRoomsOverlay{
+		parent: mainView,
+		rooms:  rooms,
+		list:   mauview.NewTextView().SetRegions(true),
+	}
+	for i, roomID := range rooms {
+		name := roomID.String()
+		if room := mainView.matrix.GetRoom(roomID); room != nil {
+			if roomName := ptr.Val(room.Meta.Current().Name); roomName != "" {
+				name = roomName
+			}
+		}
+		_, _ = fmt.Fprintf(ro.list, `["%d"]%s[""]`+"\n", i, name)
+	}
+	box := mauview.NewBox(ro.list).SetBorder(true).SetTitle("Recent rooms")
+	ro.Component = mauview.Center(box, 42, min(len(rooms), 10)+2)
+	return ro
+}
+
+// Next moves the highlight to the next room in the stack and restarts the switch timer.
+func (ro *RecentRoomsOverlay) Next() {
+	ro.lock.Lock()
+	defer ro.lock.Unlock()
+	if ro.done {
+		return
+	}
+	ro.selected = (ro.selected + 1) % len(ro.rooms)
+	ro.list.Highlight(strconv.Itoa(ro.selected))
+	ro.list.ScrollToHighlight()
+	if ro.timer != nil {
+		ro.timer.Stop()
+	}
+	ro.timer = time.AfterFunc(RecentRoomCycleTimeout, func() {
+		ro.finish(true)
+		ro.parent.parent.Render()
+	})
+}
+
+func (ro *RecentRoomsOverlay) finish(switchRoom bool) {
+	ro.lock.Lock()
+	if ro.done {
+		ro.lock.Unlock()
+		return
+	}
+	ro.done = true
+	if ro.timer != nil {
+		ro.timer.Stop()
+	}
+	target := ro.rooms[ro.selected]
+	ro.lock.Unlock()
+	ro.parent.HideModal()
+	if switchRoom {
+		ro.parent.SwitchRoom(target)
+	}
+}
+
+func (ro *RecentRoomsOverlay) OnKeyEvent(event mauview.KeyEvent) bool {
+	kb := config.Keybind{
+		Key: event.Key(),
+		Ch:  event.Rune(),
+		Mod: event.Modifiers(),
+	}
+	if ro.parent.config.Keybindings.Main[kb] == "recent_room" {
+		ro.Next()
+		return true
+	}
+	switch ro.parent.config.Keybindings.Modal[kb] {
+	case "cancel":
+		ro.finish(false)
+		return true
+	case "confirm":
+		ro.finish(true)
+		return true
+	}
+	// Any other key switches immediately and is then handled normally
+	ro.finish(true)
+	return ro.parent.OnKeyEvent(event)
+}
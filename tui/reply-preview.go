@@ -0,0 +1,142 @@
+// gomuks - A terminal Matrix client written in Go.
+// Copyright (C) 2025 Tulir Asokan
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+package tui
+
+import (
+	"strings"
+
+	"github.com/gdamore/tcell/v2"
+	"github.com/mattn/go-runewidth"
+	"go.mau.fi/mauview"
+
+	"go.mau.fi/gomuks/pkg/hicli/database"
+	"go.mau.fi/gomuks/tui/config"
+	"go.mau.fi/gomuks/tui/messages"
+	"go.mau.fi/gomuks/tui/widget"
+)
+
+// MaxPreviewTextLines is the maximum number of lines of the target message shown in the reply/edit preview.
+const MaxPreviewTextLines = 2
+
+const previewIndent = "│ "
+
+// previewTarget returns the event that should be previewed above the input, or nil if not replying or editing.
+func (view *RoomView) previewTarget() *database.Event {
+	if view.editing != nil {
+		return view.editing
+	}
+	return view.replying
+}
+
+// replyTargetDenied returns true if the message being replied to was sent by a user whose server is denied by
+// the server ACL of the room, which means they most likely can't see the reply.
+func (view *RoomView) replyTargetDenied() bool {
+	return view.editing == nil && view.replying != nil && view.Room.IsServerDenied(view.replying.Sender)
+}
+
+const replyTargetDeniedWarning = "⚠ Their server is banned by the room's server ACL, they likely can't see your reply"
+
+// previewText returns the lines of the target message's plaintext that fit in the preview pane.
+func (view *RoomView) previewText(evt *database.Event, width int) []string {
+	msg, _ := evt.RenderMeta.(*messages.UIMessage)
+	if msg == nil {
+		msg = messages.ParseEvent(view.parent.matrix, &view.config.Preferences, view.Room, evt)
+		if msg == nil {
+			return nil
+		}
+	}
+	textWidth := width - runewidth.StringWidth(previewIndent)
+	if textWidth <= 0 {
+		return nil
+	}
+	var lines []string
+	for _, line := range strings.Split(strings.TrimSpace(msg.PlainText()), "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" {
+			continue
+		} else if len(lines) == MaxPreviewTextLines {
+			lines[len(lines)-1] = runewidth.Truncate(lines[len(lines)-1]+" …", textWidth, "…")
+			break
+		}
+		lines = append(lines, runewidth.Truncate(line, textWidth, "…"))
+	}
+	return lines
+}
+
+// previewHeight returns the number of rows the reply/edit and formatting preview panes take at the given width.
+func (view *RoomView) previewHeight(width int) int {
+	height := view.formatPreviewHeight()
+	if evt := view.previewTarget(); evt != nil {
+		height += 1 + len(view.previewText(evt, width))
+		if view.replyTargetDenied() {
+			height++
+		}
+	}
+	return height
+}
+
+func (view *RoomView) drawPreview(screen mauview.Screen) {
+	width, height := screen.Size()
+	x := 0
+	write := func(text string, y int, color tcell.Color) {
+		widget.WriteLineSimpleColor(screen, text, x, y, color)
+		x += runewidth.StringWidth(text)
+	}
+	if formatHeight := view.formatPreviewHeight(); formatHeight > 0 {
+		view.drawFormatPreview(write, width, height-formatHeight)
+		x = 0
+	}
+	evt := view.previewTarget()
+	if evt == nil {
+		return
+	}
+	if view.editing != nil {
+		write("Editing message", 0, tcell.ColorDefault)
+	} else {
+		mode := view.config.Preferences.GetPerMessageProfiles()
+		write("Replying to ", 0, tcell.ColorDefault)
+		write(messages.SenderDisplayName(mode, view.Room, evt), 0, widget.GetHashColor(messages.SenderColorKey(mode, evt)))
+	}
+	if cancelKey := config.FindKeybind(view.config.Keybindings.Room, "clear"); cancelKey != "" {
+		hint := " - " + cancelKey + " to cancel"
+		if x+runewidth.StringWidth(hint) <= width {
+			write(hint, 0, tcell.ColorGray)
+		}
+	}
+	lines := view.previewText(evt, width)
+	for i, line := range lines {
+		x = 0
+		write(previewIndent, i+1, tcell.ColorGray)
+		write(line, i+1, tcell.ColorDefault)
+	}
+	if view.replyTargetDenied() {
+		x = 0
+		write(runewidth.Truncate(replyTargetDeniedWarning, width, "…"), len(lines)+1, tcell.ColorYellow)
+	}
+}
+
+// onPreviewClick scrolls the timeline to the message that is being replied to or edited.
+func (view *RoomView) onPreviewClick() bool {
+	evt := view.previewTarget()
+	if evt == nil {
+		return false
+	}
+	if !view.content.ScrollToEvent(evt.RowID) {
+		view.AddServiceMessage("The message isn't loaded in the timeline")
+	}
+	return true
+}
@@ -0,0 +1,126 @@
+// gomuks - A terminal Matrix client written in Go.
+// Copyright (C) 2026 Tulir Asokan
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+package tui
+
+import (
+	"slices"
+	"sync"
+
+	"maunium.net/go/mautrix/id"
+)
+
+// MaxRecentRooms is the maximum number of rooms kept in the recent rooms stack and the back/forward history.
+const MaxRecentRooms = 50
+
+// RoomHistory keeps track of visited rooms, both as a most-recently-used stack for quick switching
+// and as a browser-style back/forward history.
+type RoomHistory struct {
+	lock sync.Mutex
+	// recent contains room IDs ordered by when they were last visited, with the most recent one first.
+	recent []id.RoomID
+	// back and forward contain the visit history, with the nearest room last.
+	back    []id.RoomID
+	forward []id.RoomID
+	current id.RoomID
+}
+
+func NewRoomHistory(recent []id.RoomID) *RoomHistory {
+	if len(recent) > MaxRecentRooms {
+		recent = recent[:MaxRecentRooms]
+	}
+	return &RoomHistory{recent: slices.Clone(recent)}
+}
+
+func pushCapped(list []id.RoomID, roomID id.RoomID) []id.RoomID {
+	list = append(list, roomID)
+	if len(list) > MaxRecentRooms {
+		list = slices.Delete(list, 0, len(list)-MaxRecentRooms)
+	}
+	return list
+}
+
+func (rh *RoomHistory) moveToFront(roomID id.RoomID) {
+	rh.recent = slices.DeleteFunc(rh.recent, func(existing id.RoomID) bool {
+		return existing == roomID
+	})
+	rh.recent = slices.Insert(rh.recent, 0, roomID)
+	if len(rh.recent) > MaxRecentRooms {
+		rh.recent = rh.recent[:MaxRecentRooms]
+	}
+}
+
+// Visit records a normal room switch, which clears the forward history.
+func (rh *RoomHistory) Visit(roomID id.RoomID) {
+	rh.lock.Lock()
+	defer rh.lock.Unlock()
+	if roomID == rh.current {
+		return
+	}
+	if rh.current != "" {
+		rh.back = pushCapped(rh.back, rh.current)
+	}
+	rh.forward = nil
+	rh.current = roomID
+	rh.moveToFront(roomID)
+}
+
+// Back returns the previous room in the visit history that still exists and moves the current room
+// to the forward history. It returns an empty string if there's nowhere to go back to.
+func (rh *RoomHistory) Back(exists func(id.RoomID) bool) id.RoomID {
+	return rh.step(&rh.back, &rh.forward, exists)
+}
+
+// Forward is the opposite of Back.
+func (rh *RoomHistory) Forward(exists func(id.RoomID) bool) id.RoomID {
+	return rh.step(&rh.forward, &rh.back, exists)
+}
+
+func (rh *RoomHistory) step(from, to *[]id.RoomID, exists func(id.RoomID) bool) id.RoomID {
+	rh.lock.Lock()
+	defer rh.lock.Unlock()
+	for len(*from) > 0 {
+		target := (*from)[len(*from)-1]
+		*from = (*from)[:len(*from)-1]
+		if target == rh.current || !exists(target) {
+			continue
+		}
+		if rh.current != "" {
+			*to = pushCapped(*to, rh.current)
+		}
+		rh.current = target
+		rh.moveToFront(target)
+		return target
+	}
+	return ""
+}
+
+// Recent returns the recent room stack with the most recently visited room first,
+// excluding rooms that don't exist anymore.
+func (rh *RoomHistory) Recent(exists func(id.RoomID) bool) []id.RoomID {
+	rh.lock.Lock()
+	defer rh.lock.Unlock()
+	return slices.DeleteFunc(slices.Clone(rh.recent), func(roomID id.RoomID) bool {
+		return !exists(roomID)
+	})
+}
+
+// RecencyRank returns the position of the room in the recent room stack, or -1 if it's not there.
+func (rh *RoomHistory) RecencyRank(roomID id.RoomID) int {
+	rh.lock.Lock()
+	defer rh.lock.Unlock()
+	return slices.Index(rh.recent, roomID)
+}
@@ -17,16 +17,22 @@
 package tui
 
 import (
+	"context"
 	"fmt"
 	"slices"
 	"strconv"
 	"sync"
 
 	"github.com/gdamore/tcell/v2"
+	"github.com/mattn/go-runewidth"
 	"go.mau.fi/mauview"
+	"go.mau.fi/util/ptr"
 	"maunium.net/go/mautrix/id"
 
+	"go.mau.fi/gomuks/pkg/hicli/database"
 	"go.mau.fi/gomuks/pkg/rpc/store"
+	"go.mau.fi/gomuks/tui/config"
+	"go.mau.fi/gomuks/tui/debug"
 	"go.mau.fi/gomuks/tui/widget"
 )
 
@@ -38,9 +44,15 @@ type RoomList struct {
 	rooms    []*store.RoomListEntry
 	selected id.RoomID
 
+	archived     []*database.Room
+	showArchived bool
+
 	scrollOffset int
-	height       int
-	width        int
+	// The number of entries that fit on the screen, not the number of rows.
+	height int
+	width  int
+	// The number of rows used by each entry, 2 when subtitles are shown.
+	entryHeight int
 
 	// The item main text color.
 	mainTextColor tcell.Color
@@ -55,6 +67,7 @@ func NewRoomList(parent *MainView) *RoomList {
 		parent: parent,
 
 		scrollOffset: 0,
+		entryHeight:  1,
 
 		mainTextColor:           tcell.ColorDefault,
 		selectedTextColor:       tcell.ColorWhite,
@@ -88,8 +101,12 @@ func (list *RoomList) Previous() id.RoomID {
 	list.lock.RLock()
 	defer list.lock.RUnlock()
 	idx := list.index(list.selected)
-	if idx > 0 && idx < len(list.rooms) {
-		return list.rooms[idx-1].RoomID
+	if idx == len(list.rooms)+1 {
+		// Skip the archived section header
+		idx--
+	}
+	if idx > 0 {
+		return list.roomAt(idx - 1)
 	}
 	return ""
 }
@@ -104,27 +121,118 @@ func (list *RoomList) Next() id.RoomID {
 		return list.rooms[0].RoomID
 	}
 	idx := list.index(list.selected)
-	if idx >= 0 && idx < len(list.rooms)-1 {
-		return list.rooms[idx+1].RoomID
+	if idx == len(list.rooms)-1 {
+		// Skip the archived section header
+		idx++
+	}
+	if idx >= 0 {
+		return list.roomAt(idx + 1)
 	}
 	return ""
 }
 
+// unreadCounts returns the unread counts of the room from the source chosen in the preferences.
+func (list *RoomList) unreadCounts(room *store.RoomListEntry) database.UnreadCounts {
+	if list.parent.config.Preferences.GetUnreadCountSource() == config.UnreadCountSourceServer && !room.IsInvite {
+		return room.ServerUnreadCounts.AsUnreadCounts()
+	}
+	return room.UnreadCounts
+}
+
 func (list *RoomList) NextWithActivity() id.RoomID {
 	list.lock.RLock()
 	defer list.lock.RUnlock()
 	for _, room := range list.rooms {
-		if room.UnreadHighlights > 0 || room.UnreadMessages > 0 || room.MarkedUnread {
+		if counts := list.unreadCounts(room); counts.UnreadHighlights > 0 || counts.UnreadMessages > 0 || room.MarkedUnread {
 			return room.RoomID
 		}
 	}
 	return ""
 }
 
+// index returns the row of the given room in the list. The archived section header is
+// right after the normal rooms, and archived rooms are after it if the section is expanded.
 func (list *RoomList) index(roomID id.RoomID) int {
-	return slices.IndexFunc(list.rooms, func(entry *store.RoomListEntry) bool {
+	idx := slices.IndexFunc(list.rooms, func(entry *store.RoomListEntry) bool {
 		return entry.RoomID == roomID
 	})
+	if idx == -1 && list.showArchived {
+		idx = slices.IndexFunc(list.archived, func(room *database.Room) bool {
+			return room.ID == roomID
+		})
+		if idx != -1 {
+			idx += len(list.rooms) + 1
+		}
+	}
+	return idx
+}
+
+func (list *RoomList) roomAt(idx int) id.RoomID {
+	if idx >= 0 && idx < len(list.rooms) {
+		return list.rooms[idx].RoomID
+	}
+	idx -= len(list.rooms) + 1
+	if list.showArchived && idx >= 0 && idx < len(list.archived) {
+		return list.archived[idx].ID
+	}
+	return ""
+}
+
+func (list *RoomList) rowCount() int {
+	count := len(list.rooms) + 1
+	if list.showArchived {
+		count += len(list.archived)
+	}
+	return count
+}
+
+// GetArchived returns the metadata of an archived room in the list, or nil if the room isn't archived.
+func (list *RoomList) GetArchived(roomID id.RoomID) *database.Room {
+	list.lock.RLock()
+	defer list.lock.RUnlock()
+	for _, room := range list.archived {
+		if room.ID == roomID {
+			return room
+		}
+	}
+	return nil
+}
+
+// ToggleArchived expands or collapses the archived rooms section. The list of archived rooms
+// is fetched from the backend every time the section is expanded.
+func (list *RoomList) ToggleArchived() {
+	list.lock.Lock()
+	list.showArchived = !list.showArchived
+	show := list.showArchived
+	list.lock.Unlock()
+	if show {
+		go list.LoadArchived()
+	}
+}
+
+func (list *RoomList) LoadArchived() {
+	defer debug.Recover()
+	rooms, err := list.parent.matrix.GetLeftRooms(context.TODO())
+	if err != nil {
+		debug.Print("Failed to get archived rooms:", err)
+		return
+	}
+	list.lock.Lock()
+	list.archived = rooms
+	list.lock.Unlock()
+	list.parent.parent.Render()
+}
+
+// RemoveArchived removes a room from the archived section after it's forgotten or rejoined.
+func (list *RoomList) RemoveArchived(roomID id.RoomID) {
+	list.lock.Lock()
+	defer list.lock.Unlock()
+	list.archived = slices.DeleteFunc(list.archived, func(room *database.Room) bool {
+		return room.ID == roomID
+	})
+	if list.selected == roomID {
+		list.selected = ""
+	}
 }
 
 func (list *RoomList) OnKeyEvent(_ mauview.KeyEvent) bool {
@@ -149,12 +257,17 @@ func (list *RoomList) OnMouseEvent(event mauview.MouseEvent) bool {
 	case tcell.Button1:
 		_, y := event.Position()
 		list.lock.RLock()
-		defer list.lock.RUnlock()
-		y += list.scrollOffset
-		if y < 0 || y > len(list.rooms) {
+		y = y/list.entryHeight + list.scrollOffset
+		isArchivedHeader := y == len(list.rooms)
+		roomID := list.roomAt(y)
+		list.lock.RUnlock()
+		if isArchivedHeader {
+			list.ToggleArchived()
+			return true
+		} else if roomID == "" {
 			return false
 		}
-		list.parent.SwitchRoom(list.rooms[y].RoomID)
+		list.parent.SwitchRoom(roomID)
 		return true
 	}
 	return false
@@ -162,8 +275,8 @@ func (list *RoomList) OnMouseEvent(event mauview.MouseEvent) bool {
 
 func (list *RoomList) addScrollOffset(offset int) {
 	list.scrollOffset += offset
-	if list.scrollOffset > len(list.rooms)-list.height {
-		list.scrollOffset = len(list.rooms) - list.height
+	if list.scrollOffset > list.rowCount()-list.height {
+		list.scrollOffset = list.rowCount() - list.height
 	}
 	if list.scrollOffset < 0 {
 		list.scrollOffset = 0
@@ -177,31 +290,119 @@ func (list *RoomList) Draw(screen mauview.Screen) {
 	list.lock.Lock()
 	list.rooms = list.parent.matrix.ReversedRoomList.Current()
 	list.width, list.height = screen.Size()
+	list.entryHeight = 1
+	if list.width >= minSubtitleWidth && !list.parent.config.Preferences.DisableRoomPreviews {
+		list.entryHeight = 2
+	}
+	list.height /= list.entryHeight
+	entryHeight := list.entryHeight
 	roomSlice := list.rooms[min(len(list.rooms), list.scrollOffset):min(len(list.rooms), list.scrollOffset+list.height)]
+	archived := list.archived
+	showArchived := list.showArchived
 	list.lock.Unlock()
 
-	for y, room := range roomSlice {
+	for i, room := range roomSlice {
+		y := i * entryHeight
+		counts := list.unreadCounts(room)
 		style := tcell.StyleDefault.
 			Foreground(list.mainTextColor).
-			Bold(room.MarkedUnread || room.UnreadNotifications > 0 || room.UnreadHighlights > 0)
+			Bold(room.MarkedUnread || counts.UnreadNotifications > 0 || counts.UnreadHighlights > 0)
 		if room.RoomID == list.selected {
 			style = style.
 				Foreground(list.selectedTextColor).
 				Background(list.selectedBackgroundColor)
 		}
 
-		widget.WriteLinePadded(screen, mauview.AlignLeft, room.Name, 0, y, list.width, style)
+		widget.DrawAvatar(screen, 0, y, room.RoomID.String(), room.Name)
+		nameX := widget.AvatarWidth
+		widget.WriteLinePadded(screen, mauview.AlignLeft, " "+room.Name, nameX, y, list.width-nameX, style)
 
-		if room.UnreadMessages > 0 {
+		unreadWidth := 0
+		if counts.UnreadMessages > 0 {
+			unreadWidth = 7
 			unreadMessageCount := "99+"
-			if room.UnreadMessages < 1000 {
-				unreadMessageCount = strconv.Itoa(room.UnreadMessages)
+			if counts.UnreadMessages < 1000 {
+				unreadMessageCount = strconv.Itoa(counts.UnreadMessages)
 			}
-			if room.UnreadHighlights > 0 {
+			if counts.UnreadHighlights > 0 {
 				unreadMessageCount += "!"
 			}
 			unreadMessageCount = fmt.Sprintf("(%s)", unreadMessageCount)
 			widget.WriteLine(screen, mauview.AlignRight, unreadMessageCount, list.width-7, y, 7, style)
 		}
+		if callIndicator := list.callIndicator(room.RoomID); callIndicator != "" {
+			indicatorWidth := runewidth.StringWidth(callIndicator) + 1
+			widget.WriteLine(screen, mauview.AlignRight, callIndicator, list.width-unreadWidth-indicatorWidth, y, indicatorWidth, style)
+		}
+		if entryHeight > 1 {
+			list.drawSubtitle(screen, room, y+1, style)
+		}
+	}
+	list.drawArchived(screen, archived, showArchived, entryHeight)
+}
+
+// minSubtitleWidth is the minimum room list width for showing a preview line under each room name.
+const minSubtitleWidth = 24
+
+func (list *RoomList) drawSubtitle(screen mauview.Screen, entry *store.RoomListEntry, y int, style tcell.Style) {
+	var subtitle string
+	if entry.IsInvite {
+		subtitle = "Invitation"
+	} else if room := list.parent.matrix.GetRoom(entry.RoomID); room != nil {
+		subtitle = room.GetSubtitle()
+	}
+	subtitleX := widget.AvatarWidth + 1
+	widget.WriteLinePadded(screen, mauview.AlignLeft, subtitle, subtitleX, y, list.width-subtitleX, style.Bold(false).Dim(true))
+}
+
+// callIndicator returns the phone icon and active participant count shown for voice rooms and rooms with calls.
+func (list *RoomList) callIndicator(roomID id.RoomID) string {
+	room := list.parent.matrix.GetRoom(roomID)
+	if room == nil {
+		return ""
+	}
+	participants := room.CallParticipantCount()
+	if participants > 0 {
+		return fmt.Sprintf("📞%d", participants)
+	} else if room.IsCallRoom() {
+		return "📞"
+	}
+	return ""
+}
+
+func (list *RoomList) drawArchived(screen mauview.Screen, archived []*database.Room, show bool, entryHeight int) {
+	// The archived section starts right after the last normal room
+	y := len(list.rooms) - list.scrollOffset
+	if y >= list.height {
+		return
+	} else if y >= 0 {
+		header := "▸ Archived"
+		if show {
+			header = fmt.Sprintf("▾ Archived (%d)", len(archived))
+		}
+		style := tcell.StyleDefault.Foreground(tcell.ColorGray).Italic(true)
+		widget.WriteLinePadded(screen, mauview.AlignLeft, header, 0, y*entryHeight, list.width, style)
+	}
+	if !show {
+		return
+	}
+	for _, room := range archived {
+		y++
+		if y < 0 {
+			continue
+		} else if y >= list.height {
+			break
+		}
+		style := tcell.StyleDefault.Foreground(tcell.ColorGray)
+		if room.ID == list.selected {
+			style = style.
+				Foreground(list.selectedTextColor).
+				Background(tcell.ColorDarkGray)
+		}
+		name := ptr.Val(room.Name)
+		if name == "" {
+			name = room.ID.String()
+		}
+		widget.WriteLinePadded(screen, mauview.AlignLeft, "  "+name, 0, y*entryHeight, list.width, style)
 	}
 }
@@ -0,0 +1,61 @@
+// gomuks - A terminal Matrix client written in Go.
+// Copyright (C) 2026 Tulir Asokan
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+package tui
+
+import (
+	"context"
+
+	"maunium.net/go/mautrix/event"
+
+	"go.mau.fi/gomuks/pkg/hicli/jsoncmd"
+	"go.mau.fi/gomuks/tui/debug"
+)
+
+// OpenPredecessor switches to the room that this room was upgraded from.
+// If the old room isn't known locally, it's joined first.
+func (view *RoomView) OpenPredecessor() {
+	defer debug.Recover()
+	main := view.parent
+	meta := view.Room.Meta.Current()
+	if meta.CreationContent == nil || meta.CreationContent.Predecessor == nil {
+		return
+	}
+	oldRoomID := meta.CreationContent.Predecessor.RoomID
+	if existing := main.matrix.GetRoom(oldRoomID); existing != nil || main.roomList.GetArchived(oldRoomID) != nil {
+		main.SwitchRoom(oldRoomID)
+		return
+	}
+	var via []string
+	if createEvt := view.Room.GetStateEvent(event.StateCreate, ""); createEvt != nil {
+		via = []string{createEvt.Sender.Homeserver()}
+	}
+	view.AddServiceMessage("Joining the old room %s...", oldRoomID)
+	main.parent.Render()
+	_, err := main.matrix.JoinRoom(context.TODO(), &jsoncmd.JoinRoomParams{
+		RoomIDOrAlias: oldRoomID.String(),
+		Via:           via,
+	})
+	if err != nil {
+		view.AddServiceMessage("Failed to join the old room: %v", err)
+		main.parent.Render()
+		return
+	}
+	if !main.SwitchRoomWhenJoined(oldRoomID) {
+		view.AddServiceMessage("Joined the old room, but it hasn't appeared in the room list yet")
+		main.parent.Render()
+	}
+}
@@ -17,27 +17,42 @@
 package tui
 
 import (
+	"cmp"
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"html"
+	"io"
+	"os"
+	"path/filepath"
 	"strings"
+	"sync"
+	"sync/atomic"
 	"time"
+	"unicode"
+	"unicode/utf8"
 
 	"github.com/gdamore/tcell/v2"
+	"github.com/mattn/go-runewidth"
 	"github.com/zyedidia/clipboard"
 	"go.mau.fi/mauview"
+	"go.mau.fi/util/exstrings"
 	"go.mau.fi/util/ptr"
 	"go.mau.fi/util/variationselector"
 	"maunium.net/go/mautrix/crypto/attachment"
 	"maunium.net/go/mautrix/event"
+	"maunium.net/go/mautrix/format"
 	"maunium.net/go/mautrix/id"
 
 	"go.mau.fi/gomuks/pkg/hicli/database"
+	"go.mau.fi/gomuks/pkg/hicli/editsource"
 	"go.mau.fi/gomuks/pkg/hicli/jsoncmd"
+	"go.mau.fi/gomuks/pkg/rpc"
 	"go.mau.fi/gomuks/pkg/rpc/store"
 	"go.mau.fi/gomuks/tui/config"
 	"go.mau.fi/gomuks/tui/debug"
+	"go.mau.fi/gomuks/tui/lib/clipimage"
 	"go.mau.fi/gomuks/tui/messages"
 	"go.mau.fi/gomuks/tui/widget"
 )
@@ -45,6 +60,7 @@ import (
 type RoomView struct {
 	topic    *mauview.TextView
 	content  *MessageView
+	space    *SpaceView
 	status   *mauview.TextField
 	userList *MemberList
 	ulBorder *widget.Border
@@ -52,8 +68,11 @@ type RoomView struct {
 	Room     *store.RoomStore
 
 	topicScreen    *mauview.ProxyScreen
+	topicOverlay   *mauview.ProxyScreen
 	contentScreen  *mauview.ProxyScreen
 	statusScreen   *mauview.ProxyScreen
+	bannerScreen   *mauview.ProxyScreen
+	previewScreen  *mauview.ProxyScreen
 	inputScreen    *mauview.ProxyScreen
 	ulBorderScreen *mauview.ProxyScreen
 	ulScreen       *mauview.ProxyScreen
@@ -68,20 +87,53 @@ type RoomView struct {
 	selecting     bool
 	selectReason  SelectReason
 	selectContent string
+	quoting       *quoteSelection
 
 	replying *database.Event
 
 	editing      *database.Event
 	editMoveText string
 
+	pendingPaste   *pastedImage
+	locationPrompt *locationPrompt
+	tooLargePrompt *tooLargePrompt
+
+	// rawContentDrafts is the last content entered in the raw event modal for each event type.
+	rawContentDrafts map[string]string
+
+	failedBusy atomic.Bool
+
+	urlPreviewPrompt     *urlPreviewPrompt
+	urlPreviewPromptLock sync.Mutex
+
+	queuedMessage       *queuedMessage
+	queuedMessageLock   sync.Mutex
+	slowModePlaceholder bool
+
 	completions struct {
 		list      []string
 		textCache string
 		time      time.Time
 	}
 
+	topicText string
+	// topicExpanded is the full topic shown over the timeline, or nil if the topic bar is collapsed.
+	topicExpanded *expandedTopic
+	// lastAnnounced is the newest message that has been passed to the screen reader.
+	lastAnnounced database.EventRowID
+	// encrypted is whether the room was encrypted the last time the metadata was updated.
+	encrypted  bool
+	metaLoaded bool
+	// sendRestriction is the cached result of RoomStore.GetSendRestriction, which is recomputed
+	// on the next draw when sendRestrictionDirty is set.
+	sendRestriction      store.SendRestriction
+	sendRestrictionDirty atomic.Bool
+
 	unlistenMeta     func()
 	unlistenTimeline func()
+	unlistenCall     func()
+	unlistenReceipts func()
+	unlistenSendPerm func()
 }
 
 func NewRoomView(parent *MainView, room *store.RoomStore) *RoomView {
@@ -94,8 +146,11 @@ func NewRoomView(parent *MainView, room *store.RoomStore) *RoomView {
 		Room:     room,
 
 		topicScreen:    &mauview.ProxyScreen{OffsetX: 0, OffsetY: 0, Height: TopicBarHeight},
+		topicOverlay:   &mauview.ProxyScreen{OffsetX: 0, OffsetY: TopicBarHeight},
 		contentScreen:  &mauview.ProxyScreen{OffsetX: 0, OffsetY: StatusBarHeight},
 		statusScreen:   &mauview.ProxyScreen{OffsetX: 0, Height: StatusBarHeight},
+		bannerScreen:   &mauview.ProxyScreen{OffsetX: 0},
+		previewScreen:  &mauview.ProxyScreen{OffsetX: 0},
 		inputScreen:    &mauview.ProxyScreen{OffsetX: 0},
 		ulBorderScreen: &mauview.ProxyScreen{OffsetY: StatusBarHeight, Width: UserListBorderWidth},
 		ulScreen:       &mauview.ProxyScreen{OffsetY: StatusBarHeight, Width: UserListWidth},
@@ -120,19 +175,51 @@ func NewRoomView(parent *MainView, room *store.RoomStore) *RoomView {
 
 	view.status.SetBackgroundColor(tcell.ColorDimGray)
 
-	view.Update(room.Meta.Current())
+	view.Load()
 
-	view.unlistenMeta = room.Meta.Listen(view.Update)
-	view.unlistenTimeline = room.TimelineCache.Listen(func(_ *[]*database.Event) {
+	return view
+}
+
+// Load starts listening to room metadata and timeline changes. It is called automatically by NewRoomView,
+// and must be called again when switching back to a view that was previously unloaded.
+func (view *RoomView) Load() {
+	if view.unlistenMeta != nil {
+		return
+	}
+	// The metadata may have changed while the view was unloaded
+	view.Update(view.Room.Meta.Current())
+	view.unlistenMeta = view.Room.Meta.Listen(view.Update)
+	view.lastAnnounced = 0
+	view.announceNewMessages(ptr.Val(view.Room.TimelineCache.Current()))
+	view.unlistenTimeline = view.Room.TimelineCache.Listen(func(timeline *[]*database.Event) {
 		view.parent.parent.NeedsRender = true
+		view.announceNewMessages(*timeline)
 	})
-
-	return view
+	view.unlistenCall = view.Room.StateSubs.Listen(store.StateMSC3401CallMember.Type, func() {
+		view.parent.parent.NeedsRender = true
+	})
+	view.unlistenReceipts = view.Room.ReadByOthers.Listen(func(database.TimelineRowID) {
+		view.parent.parent.NeedsRender = true
+	})
+	view.unlistenSendPerm = view.listenSendRestriction()
 }
 
+// Unload stops listening to room changes. The view keeps its state (scroll position, reply and edit targets, etc.)
+// and can be resumed later with Load.
 func (view *RoomView) Unload() {
+	if view.unlistenMeta == nil {
+		return
+	}
 	view.unlistenTimeline()
 	view.unlistenMeta()
+	view.unlistenCall()
+	view.unlistenReceipts()
+	view.unlistenSendPerm()
+	view.unlistenMeta = nil
+	view.unlistenTimeline = nil
+	view.unlistenCall = nil
+	view.unlistenReceipts = nil
+	view.unlistenSendPerm = nil
 }
 
 func (view *RoomView) SetInputChangedFunc(fn func(room *RoomView, text string)) *RoomView {
@@ -163,16 +250,32 @@ func (view *RoomView) Blur() {
 type SelectReason string
 
 const (
-	SelectReply    SelectReason = "reply to"
-	SelectReact    SelectReason = "react to"
-	SelectRedact   SelectReason = "redact"
-	SelectEdit     SelectReason = "edit"
-	SelectDownload SelectReason = "download"
-	SelectOpen     SelectReason = "open"
-	SelectCopy     SelectReason = "copy"
+	SelectReply     SelectReason = "reply to"
+	SelectReact     SelectReason = "react to"
+	SelectRedact    SelectReason = "redact"
+	SelectEdit      SelectReason = "edit"
+	SelectDownload  SelectReason = "download"
+	SelectOpen      SelectReason = "open"
+	SelectCopy      SelectReason = "copy"
+	SelectCopySrc   SelectReason = "copy the source of"
+	SelectCopyID    SelectReason = "copy the ID of"
+	SelectCopyLink  SelectReason = "copy a link to"
+	SelectSource    SelectReason = "view source of"
+	SelectReveal    SelectReason = "reveal"
+	SelectQuote     SelectReason = "quote and reply to"
+	SelectQuoteOnly SelectReason = "quote"
 )
 
+func (reason SelectReason) isCopy() bool {
+	return reason == SelectCopy || reason == SelectCopySrc || reason == SelectCopyID || reason == SelectCopyLink
+}
+
 func (view *RoomView) StartSelecting(reason SelectReason, content string) {
+	if denied := view.selectionDeniedReason(reason); denied != "" {
+		view.AddServiceMessage(denied)
+		view.parent.parent.Render()
+		return
+	}
 	view.selecting = true
 	view.selectReason = reason
 	view.selectContent = content
@@ -187,6 +290,7 @@ func (view *RoomView) StartSelecting(reason SelectReason, content string) {
 
 func (view *RoomView) StopSelecting() {
 	view.selecting = false
+	view.quoting = nil
 	view.selectContent = ""
 	view.MessageView().SetSelected(nil)
 }
@@ -206,6 +310,11 @@ func (view *RoomView) OnSelect(message *messages.UIMessage) {
 	case SelectReact:
 		go view.SendReaction(message.ID, view.selectContent)
 	case SelectRedact:
+		if !view.Room.CanRedact(message.Sender) {
+			view.AddServiceMessage("You don't have permission to remove other users' messages in this room")
+			view.parent.parent.Render()
+			break
+		}
 		go view.Redact(message.ID, view.selectContent)
 	case SelectDownload, SelectOpen:
 		//msg, ok := message.Renderer.(*messages.FileMessage)
@@ -218,8 +327,17 @@ func (view *RoomView) OnSelect(message *messages.UIMessage) {
 		//	}
 		//	go view.Download(msg.URL, msg.IsEncrypted, path, view.selectReason == SelectOpen)
 		//}
-	case SelectCopy:
-		go view.CopyToClipboard(message.Renderer.PlainText(), view.selectContent)
+	case SelectCopy, SelectCopySrc, SelectCopyID, SelectCopyLink:
+		go view.CopyMessage(message, view.selectReason, view.selectContent)
+	case SelectSource:
+		view.parent.ShowModal(NewViewSourceModal(view.parent, message.Event))
+	case SelectReveal:
+		view.MessageView().ToggleBanRemoved(message)
+	case SelectQuote, SelectQuoteOnly:
+		if view.StartQuoting(message, view.selectReason == SelectQuote, view.selectContent) {
+			// Keep the message selected while picking the lines to quote
+			return
+		}
 	}
 	view.selecting = false
 	view.selectContent = ""
@@ -230,16 +348,40 @@ func (view *RoomView) OnSelect(message *messages.UIMessage) {
 func (view *RoomView) GetStatus() string {
 	var buf strings.Builder
 
-	if view.editing != nil {
-		buf.WriteString("Editing message - ")
-	} else if view.replying != nil {
-		buf.WriteString("Replying to ")
-		buf.WriteString(string(view.replying.Sender))
+	if connStatus := view.parent.ConnectionStatusText(); connStatus != "" {
+		buf.WriteString(connStatus)
+		buf.WriteString(" - ")
+	}
+
+	if filter := view.MessageView().GetFilter(); filter != nil {
+		buf.WriteString(filter.Status())
+		buf.WriteString(" - ")
+	}
+
+	if prompt := view.urlPreviewPromptStatus(); prompt != "" {
+		buf.WriteString(prompt)
+		buf.WriteString(" - ")
+	} else if view.Room.Archived {
+		buf.WriteString("You left this room - use /rejoin to join it again or /forget to forget it - ")
+	} else if view.locationPrompt != nil {
+		buf.WriteString("Pasted image contains location data, remove it before sending? [y/n] - ")
+	} else if view.tooLargePrompt != nil {
+		buf.WriteString(tooLargePromptStatus)
+		buf.WriteString(" - ")
+	} else if view.pendingPaste != nil {
+		buf.WriteString("Enter a caption for the pasted image (or leave empty) - ")
+	} else if queued := view.queuedMessageStatus(); queued != "" {
+		buf.WriteString(queued)
+		buf.WriteString(" - ")
+	} else if view.quoting != nil {
+		buf.WriteString(view.quoting.status())
 		buf.WriteString(" - ")
 	} else if view.selecting {
 		buf.WriteString("Selecting message to ")
 		buf.WriteString(string(view.selectReason))
 		buf.WriteString(" - ")
+	} else if view.activeSpaceView() != nil {
+		buf.WriteString("Space overview - Enter to open or join a room - ")
 	}
 
 	if len(view.completions.list) > 0 {
@@ -291,34 +433,51 @@ func (view *RoomView) Draw(screen mauview.Screen) {
 
 	if view.prevScreen != screen {
 		view.topicScreen.Parent = screen
+		view.topicOverlay.Parent = screen
 		view.contentScreen.Parent = screen
 		view.statusScreen.Parent = screen
+		view.bannerScreen.Parent = screen
+		view.previewScreen.Parent = screen
 		view.inputScreen.Parent = screen
 		view.ulBorderScreen.Parent = screen
 		view.ulScreen.Parent = screen
 		view.prevScreen = screen
 	}
 
+	view.updateSlowModePlaceholder()
 	view.input.PrepareDraw(width)
 	inputHeight := view.input.GetTextHeight()
-	if inputHeight > MaxInputHeight {
+	readOnlyNotice := view.readOnlyNotice()
+	if readOnlyNotice != "" {
+		inputHeight = 1
+	} else if inputHeight > MaxInputHeight {
 		inputHeight = MaxInputHeight
 	} else if inputHeight < 1 {
 		inputHeight = 1
 	}
-	contentHeight := height - inputHeight - TopicBarHeight - StatusBarHeight
+	bannerHeight := view.failedBannerHeight()
+	previewHeight := view.previewHeight(width)
+	contentHeight := height - inputHeight - bannerHeight - previewHeight - TopicBarHeight - StatusBarHeight
 	contentWidth := width - StaticHorizontalSpace
 	if view.config.Preferences.HideUserList {
 		contentWidth = width
 	}
 
 	view.topicScreen.Width = width
+	view.topicOverlay.Width = contentWidth
+	view.topicOverlay.Height = view.topicOverlayHeight(contentWidth, contentHeight)
 	view.contentScreen.Width = contentWidth
 	view.contentScreen.Height = contentHeight
 	view.statusScreen.OffsetY = view.contentScreen.YEnd()
 	view.statusScreen.Width = width
+	view.bannerScreen.Width = width
+	view.bannerScreen.OffsetY = view.statusScreen.YEnd()
+	view.bannerScreen.Height = bannerHeight
+	view.previewScreen.Width = width
+	view.previewScreen.OffsetY = view.bannerScreen.YEnd()
+	view.previewScreen.Height = previewHeight
 	view.inputScreen.Width = width
-	view.inputScreen.OffsetY = view.statusScreen.YEnd()
+	view.inputScreen.OffsetY = view.previewScreen.YEnd()
 	view.inputScreen.Height = inputHeight
 	view.ulBorderScreen.OffsetX = view.contentScreen.XEnd()
 	view.ulBorderScreen.Height = contentHeight
@@ -326,11 +485,25 @@ func (view *RoomView) Draw(screen mauview.Screen) {
 	view.ulScreen.Height = contentHeight
 
 	// Draw everything
+	view.topic.SetText(view.topicBarText(width))
 	view.topic.Draw(view.topicScreen)
-	view.content.Draw(view.contentScreen)
+	if space := view.activeSpaceView(); space != nil {
+		space.Draw(view.contentScreen)
+	} else {
+		view.content.Draw(view.contentScreen)
+	}
+	if view.topicOverlay.Height > 0 {
+		view.drawExpandedTopic(view.topicOverlay)
+	}
 	view.status.SetText(view.GetStatus())
 	view.status.Draw(view.statusScreen)
-	view.input.Draw(view.inputScreen)
+	view.drawFailedBanner(view.bannerScreen)
+	view.drawPreview(view.previewScreen)
+	if readOnlyNotice != "" {
+		view.drawReadOnlyNotice(view.inputScreen, readOnlyNotice)
+	} else {
+		view.input.Draw(view.inputScreen)
+	}
 	if !view.config.Preferences.HideUserList {
 		view.ulBorder.Draw(view.ulBorderScreen)
 		view.userList.Draw(view.ulScreen)
@@ -341,6 +514,9 @@ func (view *RoomView) ClearAllContext() {
 	view.SetEditing(nil)
 	view.StopSelecting()
 	view.replying = nil
+	view.pendingPaste = nil
+	view.locationPrompt = nil
+	view.CancelQueuedMessage()
 	view.input.Focus()
 }
 
@@ -352,6 +528,52 @@ func (view *RoomView) OnKeyEvent(event mauview.KeyEvent) bool {
 		Mod: event.Modifiers(),
 	}
 
+	if view.urlPreviewPromptStatus() != "" {
+		switch {
+		case event.Rune() == 'y' || event.Rune() == 'Y':
+			view.ResolveURLPreviewPrompt(true)
+		case event.Rune() == 'n' || event.Rune() == 'N':
+			view.ResolveURLPreviewPrompt(false)
+		case view.config.Keybindings.Room[kb] == "clear":
+			view.CancelURLPreviewPrompt()
+		}
+		// Don't let other keys through, so that the answer doesn't end up in the input box
+		return true
+	}
+
+	if view.locationPrompt != nil {
+		switch {
+		case event.Rune() == 'y' || event.Rune() == 'Y':
+			view.resolveLocationPrompt(config.StripEXIFAlways)
+		case event.Rune() == 'n' || event.Rune() == 'N':
+			view.resolveLocationPrompt(config.StripEXIFNever)
+		case view.config.Keybindings.Room[kb] == "clear":
+			view.locationPrompt = nil
+		}
+		return true
+	}
+
+	if view.tooLargePrompt != nil {
+		switch {
+		case event.Rune() == 'f' || event.Rune() == 'F':
+			view.resolveTooLargePrompt('f')
+		case event.Rune() == 's' || event.Rune() == 'S':
+			view.resolveTooLargePrompt('s')
+		case event.Rune() == 'n' || event.Rune() == 'N', view.config.Keybindings.Room[kb] == "clear":
+			view.resolveTooLargePrompt('n')
+		}
+		return true
+	}
+
+	if space := view.activeSpaceView(); space != nil && !view.selecting && space.OnKeyEvent(event) {
+		return true
+	}
+
+	if view.quoting != nil {
+		view.onQuoteKey(kb)
+		return true
+	}
+
 	if view.selecting {
 		switch view.config.Keybindings.Visual[kb] {
 		case "clear":
@@ -362,19 +584,47 @@ func (view *RoomView) OnKeyEvent(event mauview.KeyEvent) bool {
 			view.SelectNext()
 		case "confirm":
 			view.OnSelect(msgView.GetSelected())
+		case "toggle_spoilers":
+			msgView.ToggleSpoilers(msgView.GetSelected())
+		case "toggle_profile_changes":
+			msgView.ToggleProfileChanges(msgView.GetSelected())
+		case "toggle_ban_removed":
+			msgView.ToggleBanRemoved(msgView.GetSelected())
+		case "copy_text", "copy_source", "copy_id", "copy_link":
+			if !view.selectReason.isCopy() {
+				// The select content is only a clipboard register when the selection was started by /copy
+				view.selectContent = ""
+			}
+			view.selectReason = visualCopyActions[view.config.Keybindings.Visual[kb]]
+			view.OnSelect(msgView.GetSelected())
+		case "quote_reply", "quote":
+			view.selectReason = SelectQuote
+			if view.config.Keybindings.Visual[kb] == "quote" {
+				view.selectReason = SelectQuoteOnly
+			}
+			view.selectContent = ""
+			view.OnSelect(msgView.GetSelected())
 		default:
 			return false
 		}
 		return true
 	}
 
+	if view.onFailedBannerKey(event) {
+		return true
+	}
+
+	if view.topicExpanded != nil && view.onExpandedTopicKey(view.config.Keybindings.Room[kb]) {
+		return true
+	}
+
 	switch view.config.Keybindings.Room[kb] {
 	case "clear":
 		view.ClearAllContext()
 		return true
 	case "scroll_up":
 		if msgView.IsAtTop() {
-			go view.parent.LoadHistory(view.Room.ID)
+			view.LoadHistory()
 		}
 		msgView.AddScrollOffset(+msgView.Height() / 2)
 		return true
@@ -382,23 +632,52 @@ func (view *RoomView) OnKeyEvent(event mauview.KeyEvent) bool {
 		msgView.AddScrollOffset(-msgView.Height() / 2)
 		return true
 	case "send":
-		view.InputSubmit(view.input.GetText())
+		if view.readOnlyNotice() == "" {
+			view.InputSubmit(view.input.GetText())
+		}
 		return true
+	case "clear_filter":
+		return view.ClearTimelineFilter()
+	case "toggle_topic":
+		view.ToggleTopic()
+		return true
+	default:
+		if view.readOnlyNotice() != "" {
+			// The input area is replaced with the read-only notice
+			return false
+		} else if view.OnInputEditKey(view.config.Keybindings.Room[kb]) {
+			return true
+		}
 	}
 	return view.input.OnKeyEvent(event)
 }
 
 func (view *RoomView) OnPasteEvent(event mauview.PasteEvent) bool {
+	if view.readOnlyNotice() != "" {
+		return false
+	} else if isBinaryPaste(event.Text()) {
+		// The terminal tried to paste something that isn't text, so check if there's an image in the clipboard
+		go view.PasteImage("")
+		return true
+	}
 	return view.input.OnPasteEvent(event)
 }
 
 func (view *RoomView) OnMouseEvent(event mauview.MouseEvent) bool {
 	switch {
+	case view.topicOverlay.Height > 0 && view.topicOverlay.IsInArea(event.Position()):
+		return view.onTopicMouseEvent(view.topicOverlay.OffsetMouseEvent(event))
 	case view.contentScreen.IsInArea(event.Position()):
 		return view.content.OnMouseEvent(view.contentScreen.OffsetMouseEvent(event))
 	case view.topicScreen.IsInArea(event.Position()):
+		if event.Buttons() == tcell.Button1 && !event.HasMotion() {
+			view.ToggleTopic()
+			return true
+		}
 		return view.topic.OnMouseEvent(view.topicScreen.OffsetMouseEvent(event))
-	case view.inputScreen.IsInArea(event.Position()):
+	case view.previewScreen.IsInArea(event.Position()):
+		return event.Buttons() == tcell.Button1 && !event.HasMotion() && view.onPreviewClick()
+	case view.inputScreen.IsInArea(event.Position()) && view.readOnlyNotice() == "":
 		return view.input.OnMouseEvent(view.inputScreen.OffsetMouseEvent(event))
 	}
 	return false
@@ -541,38 +820,59 @@ func (view *RoomView) SelectPrevious() {
 	//}
 }
 
+// maxCompletions is the maximum number of users or rooms suggested by tab completion.
+const maxCompletions = 10
+
 type completion struct {
 	displayName string
 	id          string
+	// link is the matrix.to URL that's inserted into the message when the completion is chosen.
+	link string
+}
+
+func userCompletion(displayName string, userID id.UserID) completion {
+	return completion{displayName, string(userID), userID.URI().MatrixToURL()}
 }
 
+// AutocompleteUser finds users matching the given text. Members of the current room are suggested first,
+// followed by users recently seen in other rooms.
 func (view *RoomView) AutocompleteUser(existingText string) (completions []completion) {
-	textWithoutPrefix := strings.TrimPrefix(existingText, "@")
-	for _, user := range view.Room.GetMembers() {
-		if user.Displayname == textWithoutPrefix || string(user.UserID) == existingText {
-			// Exact match, return that.
-			return []completion{{user.Displayname, string(user.UserID)}}
+	query := strings.TrimPrefix(existingText, "@")
+	added := make(map[id.UserID]struct{})
+	for _, member := range view.Room.SearchMembers(query, maxCompletions) {
+		added[member.UserID] = struct{}{}
+		completions = append(completions, userCompletion(member.Displayname, member.UserID))
+	}
+	for _, user := range view.parent.matrix.Autocomplete.SearchUsers(query, maxCompletions) {
+		if len(completions) >= maxCompletions {
+			break
+		} else if _, alreadyAdded := added[user.UserID]; !alreadyAdded {
+			completions = append(completions, userCompletion(user.Displayname, user.UserID))
 		}
-
-		if strings.HasPrefix(user.Displayname, textWithoutPrefix) || strings.HasPrefix(string(user.UserID), existingText) {
-			completions = append(completions, completion{user.Displayname, string(user.UserID)})
+	}
+	for _, compl := range completions {
+		if compl.displayName == query || compl.id == existingText {
+			// Exact match, return that.
+			return []completion{compl}
 		}
 	}
 	return
 }
 
+// AutocompleteRoom finds rooms whose name or canonical alias matches the given text.
 func (view *RoomView) AutocompleteRoom(existingText string) (completions []completion) {
-	//for _, room := range view.parent.rooms {
-	//	alias := string(room.Room.GetCanonicalAlias())
-	//	if alias == existingText {
-	//		// Exact match, return that.
-	//		return []completion{{alias, string(room.Room.ID)}}
-	//	}
-	//	if strings.HasPrefix(alias, existingText) {
-	//		completions = append(completions, completion{alias, string(room.Room.ID)})
-	//		continue
-	//	}
-	//}
+	for _, room := range view.parent.matrix.Autocomplete.SearchRooms(existingText, maxCompletions) {
+		if room.Alias == "" {
+			completions = append(completions, completion{room.Name, string(room.RoomID), room.RoomID.URI().MatrixToURL()})
+			continue
+		}
+		compl := completion{cmp.Or(room.Name, string(room.Alias)), string(room.Alias), room.Alias.URI().MatrixToURL()}
+		if string(room.Alias) == existingText {
+			// Exact match, return that.
+			return []completion{compl}
+		}
+		completions = append(completions, compl)
+	}
 	return
 }
 
@@ -600,93 +900,102 @@ func (view *RoomView) AutocompleteEmoji(word string) (completions []string) {
 	return
 }
 
-//func findWordToTabComplete(text string) string {
-//	output := ""
-//	runes := []rune(text)
-//	for i := len(runes) - 1; i >= 0; i-- {
-//		if unicode.IsSpace(runes[i]) {
-//			break
-//		}
-//		output = string(runes[i]) + output
-//	}
-//	return output
-//}
+func findWordToTabComplete(text string) string {
+	return text[strings.LastIndexFunc(text, unicode.IsSpace)+1:]
+}
 
-//var (
-//	mentionMarkdown  = "[%[1]s](https://matrix.to/#/%[2]s)"
-//	mentionHTML      = `<a href="https://matrix.to/#/%[2]s">%[1]s</a>`
-//	mentionPlaintext = "%[1]s"
-//)
-//
-//func (view *RoomView) defaultAutocomplete(word string, startIndex int) (strCompletions []string, strCompletion string) {
-//	if len(word) == 0 {
-//		return []string{}, ""
-//	}
-//
-//	completions := view.AutocompleteUser(word)
-//	completions = append(completions, view.AutocompleteRoom(word)...)
-//
-//	if len(completions) == 1 {
-//		completion := completions[0]
-//		template := mentionMarkdown
-//		if view.config.Preferences.DisableMarkdown {
-//			if view.config.Preferences.DisableHTML {
-//				template = mentionPlaintext
-//			} else {
-//				template = mentionHTML
-//			}
-//		}
-//		strCompletion = fmt.Sprintf(template, completion.displayName, completion.id)
-//		if startIndex == 0 && completion.id[0] == '@' {
-//			strCompletion = strCompletion + ":"
-//		}
-//	} else if len(completions) > 1 {
-//		for _, completion := range completions {
-//			strCompletions = append(strCompletions, completion.displayName)
-//		}
-//	}
-//
-//	//strCompletions = append(strCompletions, view.parent.cmdProcessor.AutocompleteCommand(word)...)
-//	strCompletions = append(strCompletions, view.AutocompleteEmoji(word)...)
-//
-//	return
-//}
+func (view *RoomView) defaultAutocomplete(word string, startIndex int) (strCompletions []string, strCompletion string) {
+	if len(word) == 0 {
+		return []string{}, ""
+	}
+
+	var completions []completion
+	if strings.HasPrefix(word, "#") {
+		completions = view.AutocompleteRoom(word)
+	} else {
+		completions = view.AutocompleteUser(word)
+	}
+
+	if len(completions) == 1 {
+		compl := completions[0]
+		strCompletion = format.MarkdownLink(compl.displayName, compl.link)
+		if startIndex == 0 && compl.id[0] == '@' {
+			strCompletion = strCompletion + ":"
+		}
+	} else if len(completions) > 1 {
+		for _, compl := range completions {
+			strCompletions = append(strCompletions, compl.displayName)
+		}
+	}
+
+	strCompletions = append(strCompletions, view.AutocompleteEmoji(word)...)
+
+	return
+}
+
+// commandTabComplete completes a command name at the start of the input. If there's only one match,
+// its description is shown in the status bar.
+func (view *RoomView) commandTabComplete(word, rest string) {
+	matches := view.CompleteCommand(word[1:])
+	if len(matches) == 1 {
+		view.input.SetTextAndMoveCursor("/" + matches[0].Command + " " + strings.TrimLeft(rest, " "))
+		completion := "/" + matches[0].Command
+		if description := commandDescription(matches[0]); description != "" {
+			completion += " - " + description
+		}
+		view.SetCompletions([]string{completion})
+		return
+	}
+	names := make([]string, len(matches))
+	for i, cmd := range matches {
+		names[i] = "/" + cmd.Command
+	}
+	if prefix := exstrings.LongestCommonPrefix(names); len(prefix) > len(word) {
+		view.input.SetTextAndMoveCursor(prefix + rest)
+	}
+	view.SetCompletions(names)
+}
 
 func (view *RoomView) InputTabComplete(text string, cursorOffset int) {
-	//if len(text) == 0 {
-	//	return
-	//}
-	//
-	//str := runewidth.Truncate(text, cursorOffset, "")
-	//word := findWordToTabComplete(str)
-	//startIndex := len(str) - len(word)
-	//
-	//var strCompletion string
-	//
-	//strCompletions, newText, ok := view.parent.cmdProcessor.Autocomplete(view, text, cursorOffset)
-	//if !ok {
-	//	strCompletions, strCompletion = view.defaultAutocomplete(word, startIndex)
-	//}
-	//
-	//if len(strCompletions) > 0 {
-	//	strCompletion = exstrings.LongestCommonPrefix(strCompletions)
-	//	sort.Sort(sort.StringSlice(strCompletions))
-	//}
-	//if len(strCompletion) > 0 && len(strCompletions) < 2 {
-	//	strCompletion += " "
-	//	strCompletions = []string{}
-	//}
-	//
-	//if len(strCompletion) > 0 && newText == text {
-	//	newText = str[0:startIndex] + strCompletion + text[len(str):]
-	//}
-	//
-	//view.input.SetTextAndMoveCursor(newText)
-	//view.SetCompletions(strCompletions)
+	if len(text) == 0 {
+		return
+	}
+
+	str := runewidth.Truncate(text, cursorOffset, "")
+	word := findWordToTabComplete(str)
+	startIndex := len(str) - len(word)
+	if startIndex == 0 && strings.HasPrefix(word, "/") && !strings.HasPrefix(word, "//") {
+		view.commandTabComplete(word, text[len(str):])
+		return
+	}
+
+	strCompletions, strCompletion := view.defaultAutocomplete(word, startIndex)
+
+	if len(strCompletions) > 0 {
+		// Completions may match anywhere in the name, so only use the common prefix if it extends the word.
+		prefix := exstrings.LongestCommonPrefix(strCompletions)
+		if len(strCompletions) == 1 || len(prefix) > len(word) {
+			strCompletion = prefix
+		}
+	}
+	if len(strCompletion) > 0 && len(strCompletions) < 2 {
+		strCompletion += " "
+		strCompletions = []string{}
+	}
+
+	if len(strCompletion) > 0 {
+		view.input.SetTextAndMoveCursor(str[:startIndex] + strCompletion + text[len(str):])
+	}
+	view.SetCompletions(strCompletions)
 }
 
 func (view *RoomView) InputSubmit(text string) {
-	if len(text) == 0 {
+	if paste := view.pendingPaste; paste != nil {
+		view.pendingPaste = nil
+		go view.sendPastedImage(paste, text)
+		view.SetInputText("")
+		return
+	} else if len(text) == 0 {
 		return
 	} else if cmd, err := view.ParseCommand(text); err != nil {
 		view.Room.ApplyPending(database.MakeFakeEvent(view.Room.ID,
@@ -694,24 +1003,183 @@ func (view *RoomView) InputSubmit(text string) {
 		view.parent.parent.Render()
 	} else if cmd != nil {
 		go view.HandleCommand(cmd)
-	} else {
-		go view.SendMessage(event.MsgText, text)
+	} else if view.Room.Archived {
+		view.AddServiceMessage("Can't send messages to a room you've left")
+		view.parent.parent.Render()
+		return
+	} else if matches := view.findSensitiveContent(text); len(matches) > 0 {
+		view.confirmSensitiveContent(text, matches)
+	} else if !view.submitMessage(text) {
+		return
 	}
 	view.editMoveText = ""
 	view.SetInputText("")
 }
 
-func (view *RoomView) CopyToClipboard(text string, register string) {
-	if register == "clipboard" || register == "primary" {
-		err := clipboard.WriteAll(text, register)
-		if err != nil {
-			//view.AddServiceMessage(fmt.Sprintf("Clipboard unsupported: %v", err))
-			//view.parent.parent.Render()
+// submitMessage sends a text message, or queues it if slow mode is active.
+// It returns false if the message couldn't be queued.
+func (view *RoomView) submitMessage(text string) bool {
+	if view.sendCooldownRemaining() > 0 {
+		if !view.QueueMessage(text) {
+			view.AddServiceMessage("Slow mode is active and a message is already queued")
+			view.parent.parent.Render()
+			return false
 		}
 	} else {
-		//view.AddServiceMessage(fmt.Sprintf("Clipboard register %v unsupported", register))
-		//view.parent.parent.Render()
+		go view.SendMessage(event.MsgText, text)
+	}
+	return true
+}
+
+var visualCopyActions = map[string]SelectReason{
+	"copy_text":   SelectCopy,
+	"copy_source": SelectCopySrc,
+	"copy_id":     SelectCopyID,
+	"copy_link":   SelectCopyLink,
+}
+
+// CopyMessage copies the text, markdown source, event ID or a matrix.to link of the given message.
+func (view *RoomView) CopyMessage(message *messages.UIMessage, what SelectReason, register string) {
+	defer debug.Recover()
+	var text, description string
+	switch what {
+	case SelectCopySrc:
+		description = "message source"
+		if message.Event.LocalContent != nil && message.Event.LocalContent.EditSource != "" {
+			text = message.Event.LocalContent.EditSource
+		} else if content := message.Event.GetMautrixContent().AsMessage(); content.MsgType != "" {
+			text = editsource.FromContent(content)
+		} else {
+			text = message.Renderer.PlainText()
+		}
+	case SelectCopyID:
+		description = "event ID"
+		text = string(message.ID)
+	case SelectCopyLink:
+		description = "message link"
+		text = view.Room.GetEventPermalink(message.ID)
+	default:
+		description = "message text"
+		text = message.Renderer.PlainText()
+	}
+	if view.CopyToClipboard(text, register) {
+		view.AddServiceMessage("Copied %s to %s", description, cmp.Or(register, "clipboard"))
+	}
+	view.parent.parent.Render()
+}
+
+// CopyToClipboard writes the given text to the clipboard or primary selection.
+// Errors are reported as service messages.
+func (view *RoomView) CopyToClipboard(text string, register string) bool {
+	if register == "" {
+		register = "clipboard"
+	}
+	if register != "clipboard" && register != "primary" {
+		view.AddServiceMessage("Clipboard register %s unsupported", register)
+		return false
+	}
+	err := clipboard.WriteAll(text, register)
+	if err != nil {
+		view.AddServiceMessage("Clipboard unsupported: %v", err)
+		return false
+	}
+	return true
+}
+
+type pastedImage struct {
+	data     []byte
+	mimeType string
+	// stripEXIF overrides the strip_exif preference after the user has answered the location data question.
+	stripEXIF string
+}
+
+// locationPrompt is a pasted image that wasn't sent because it contains location data
+// and the strip_exif preference is set to ask.
+type locationPrompt struct {
+	paste   *pastedImage
+	caption string
+}
+
+func (view *RoomView) resolveLocationPrompt(stripEXIF string) {
+	prompt := view.locationPrompt
+	view.locationPrompt = nil
+	prompt.paste.stripEXIF = stripEXIF
+	go view.sendPastedImage(prompt.paste, prompt.caption)
+}
+
+func isBinaryPaste(text string) bool {
+	return !utf8.ValidString(text) || strings.ContainsRune(text, 0) || strings.HasPrefix(text, "\x89PNG")
+}
+
+func (view *RoomView) PasteImage(caption string) {
+	defer debug.Recover()
+	data, mimeType, err := clipimage.Read(context.TODO())
+	if err != nil {
+		view.AddServiceMessage("Failed to paste image: %v", err)
+		view.parent.parent.Render()
+		return
 	}
+	paste := &pastedImage{data: data, mimeType: mimeType}
+	if caption == "" && view.config.Preferences.AskPasteCaption {
+		view.pendingPaste = paste
+		view.parent.parent.Render()
+		return
+	}
+	view.sendPastedImage(paste, caption)
+}
+
+func (view *RoomView) sendPastedImage(paste *pastedImage, caption string) {
+	defer debug.Recover()
+	ext := strings.TrimPrefix(paste.mimeType, "image/")
+	tempFile, err := os.CreateTemp("", "gomuks-paste-*."+ext)
+	if err != nil {
+		view.AddServiceMessage("Failed to create temp file for pasted image: %v", err)
+		view.parent.parent.Render()
+		return
+	}
+	defer func() {
+		_ = tempFile.Close()
+		_ = os.Remove(tempFile.Name())
+	}()
+	_, err = tempFile.Write(paste.data)
+	if err == nil {
+		_, err = tempFile.Seek(0, io.SeekStart)
+	}
+	if err != nil {
+		view.AddServiceMessage("Failed to write pasted image to temp file: %v", err)
+		view.parent.parent.Render()
+		return
+	}
+	content, err := view.parent.matrix.UploadMedia(context.TODO(), tempFile, rpc.UploadMediaParams{
+		FileName:  fmt.Sprintf("Pasted image %s.%s", time.Now().Format("2006-01-02 15-04-05"), ext),
+		Encrypt:   view.Room.Meta.Current().EncryptionEvent != nil,
+		StripEXIF: cmp.Or(paste.stripEXIF, view.config.Preferences.GetStripEXIF()),
+	})
+	if errors.Is(err, rpc.ErrImageHasLocation) {
+		view.locationPrompt = &locationPrompt{paste: paste, caption: caption}
+		view.parent.parent.Render()
+		return
+	} else if err != nil {
+		view.AddServiceMessage("Failed to upload pasted image: %v", err)
+		view.parent.parent.Render()
+		return
+	}
+	var relatesTo *event.RelatesTo
+	if view.replying != nil {
+		relatesTo = (&event.RelatesTo{}).SetReplyTo(view.replying.ID)
+		view.replying = nil
+	}
+	err = view.parent.matrix.SendMessage(context.TODO(), &jsoncmd.SendMessageParams{
+		RoomID:      view.Room.ID,
+		BaseContent: content,
+		Text:        caption,
+		RelatesTo:   relatesTo,
+	})
+	if err != nil {
+		debug.Print("Failed to send pasted image:", err)
+		view.AddServiceMessage("Failed to send pasted image: %v", err)
+	}
+	view.parent.parent.Render()
 }
 
 func (view *RoomView) Download(url id.ContentURI, file *attachment.EncryptedFile, filename string, openFile bool) {
@@ -745,6 +1213,11 @@ func (view *RoomView) Redact(eventID id.EventID, reason string) {
 func (view *RoomView) SendReaction(eventID id.EventID, reaction string) {
 	defer debug.Recover()
 	reaction = variationselector.Add(strings.TrimSpace(reaction))
+	if existing := view.Room.GetMyReaction(eventID, reaction); existing != "" {
+		debug.Print("Removing reaction", existing, "to", eventID, "in", view.Room.ID)
+		view.Redact(existing, "")
+		return
+	}
 	debug.Print("Reacting to", eventID, "in", view.Room.ID, "with", reaction)
 	contentJSON, _ := json.Marshal(&event.ReactionEventContent{RelatesTo: event.RelatesTo{
 		Type:    event.RelAnnotation,
@@ -759,31 +1232,45 @@ func (view *RoomView) SendReaction(eventID id.EventID, reaction string) {
 	if err != nil {
 		view.AddServiceMessage("Failed to send reaction: %v", err)
 		view.parent.parent.Render()
+	} else if target := view.Room.GetEventByID(eventID); target != nil && view.Room.IsServerDenied(target.Sender) {
+		view.AddServiceMessage("%s's server is banned by the room's server ACL, they likely can't see your reaction", target.Sender)
+		view.parent.parent.Render()
 	}
 }
 
-func (view *RoomView) SendMessage(msgtype event.MessageType, text string) {
+// sendMessage sends a text message to the room. If stripURLPreviews is true, the message is sent
+// with an empty URL preview list to tell other clients not to generate previews for links.
+func (view *RoomView) sendMessage(text string, stripURLPreviews bool) {
 	defer debug.Recover()
 	var relatesTo *event.RelatesTo
 	if view.replying != nil {
 		relatesTo = (&event.RelatesTo{}).SetReplyTo(view.replying.ID)
 		view.replying = nil
 	}
-	err := view.parent.matrix.SendMessage(context.TODO(), &jsoncmd.SendMessageParams{
+	var urlPreviews []*event.BeeperLinkPreview
+	if stripURLPreviews {
+		urlPreviews = []*event.BeeperLinkPreview{}
+	}
+	view.sendMessageParams(&jsoncmd.SendMessageParams{
 		RoomID:      view.Room.ID,
 		BaseContent: nil,
 		Extra:       nil,
 		Text:        text,
 		RelatesTo:   relatesTo,
-		Mentions:    nil,
-		URLPreviews: nil,
+		Mentions:    view.Room.FindMentions(text),
+		URLPreviews: urlPreviews,
 	})
-	if err != nil {
-		debug.Print("Failed to send message:", err)
-		view.AddServiceMessage("Failed to send message: %v", err)
+}
+
+// SendSpoiler sends the given text hidden behind a spoiler.
+// If the text is in the form "reason: text", the part before the colon is used as the spoiler reason.
+func (view *RoomView) SendSpoiler(text string) {
+	var reason string
+	if before, after, found := strings.Cut(text, ": "); found && len(after) > 0 {
+		reason, text = strings.TrimSpace(before), after
 	}
-	debug.Print("Rendering after sending message")
-	view.parent.parent.Render()
+	formatted := fmt.Sprintf(`<span data-mx-spoiler="%s">%s</span>`, html.EscapeString(reason), html.EscapeString(text))
+	view.SendMessage(event.MsgText, "/html "+formatted)
 }
 
 func (view *RoomView) SendMessageHTML(msgtype event.MessageType, text, html string) {
@@ -797,6 +1284,155 @@ func (view *RoomView) SendMessageHTML(msgtype event.MessageType, text, html stri
 	//view.addLocalEcho(evt)
 }
 
+// updatePreferences changes the room-specific gomuks preferences of the room. The update function receives
+// the raw existing preferences, so that keys unknown to the terminal client are preserved.
+func (view *RoomView) updatePreferences(update func(content map[string]any)) error {
+	content := make(map[string]any)
+	if existing := view.Room.GetAccountData(store.AccountDataGomuksPreferences); existing != nil {
+		_ = json.Unmarshal(existing.Content, &content)
+	}
+	update(content)
+	rawContent, err := json.Marshal(content)
+	if err != nil {
+		return err
+	}
+	return view.parent.matrix.SetAccountData(context.TODO(), &jsoncmd.SetAccountDataParams{
+		RoomID:  view.Room.ID,
+		Type:    store.AccountDataGomuksPreferences.Type,
+		Content: rawContent,
+	})
+}
+
+// SetHidden changes the hide_room preference of the room, which hides it from the room list,
+// the room switcher and notifications.
+func (view *RoomView) SetHidden(hidden bool) {
+	defer debug.Recover()
+	err := view.updatePreferences(func(content map[string]any) {
+		if hidden {
+			content["hide_room"] = true
+		} else {
+			delete(content, "hide_room")
+		}
+	})
+	if err != nil {
+		view.AddServiceMessage("Failed to update room preferences: %v", err)
+	} else if hidden {
+		view.AddServiceMessage("Room hidden - use the room switcher with hidden rooms included and /unhide to show it again")
+	} else {
+		view.AddServiceMessage("Room is no longer hidden")
+	}
+	view.parent.parent.Render()
+}
+
+// SetNotificationSound changes the notification_sound preference of the room,
+// which overrides whether notifications in the room play a sound.
+func (view *RoomView) SetNotificationSound(mode string) {
+	defer debug.Recover()
+	defer view.parent.parent.Render()
+	switch mode {
+	case "default":
+		mode = config.RoomSoundDefault
+	case config.RoomSoundAlways, config.RoomSoundNever:
+	default:
+		view.AddServiceMessage("Usage: /notify-sound <always|never|default>")
+		return
+	}
+	err := view.updatePreferences(func(content map[string]any) {
+		if mode == config.RoomSoundDefault {
+			delete(content, "notification_sound")
+		} else {
+			content["notification_sound"] = mode
+		}
+	})
+	if err != nil {
+		view.AddServiceMessage("Failed to update room preferences: %v", err)
+	} else if mode == config.RoomSoundDefault {
+		view.AddServiceMessage("Notification sounds in this room now follow the global settings")
+	} else {
+		view.AddServiceMessage("Notifications in this room will %s play a sound", mode)
+	}
+}
+
+func (view *RoomView) FlushSendQueue() {
+	defer debug.Recover()
+	dropped, err := view.parent.matrix.FlushSendQueue(context.TODO(), &jsoncmd.FlushSendQueueParams{
+		RoomID: view.Room.ID,
+	})
+	if err != nil {
+		view.AddServiceMessage("Failed to flush send queue: %v", err)
+	} else {
+		view.AddServiceMessage("Dropped %d queued messages", dropped)
+	}
+	view.parent.parent.Render()
+}
+
+// ExportMembers writes the member list of the room into a file. The file is written by the backend,
+// so the path is made absolute first in case the backend has a different working directory.
+func (view *RoomView) ExportMembers(path, memberships string) {
+	defer debug.Recover()
+	defer view.parent.parent.Render()
+	path = strings.TrimSpace(path)
+	if path == "" {
+		view.AddServiceMessage("Usage: /members export <path> [memberships...]")
+		return
+	}
+	path = expandHomePath(path)
+	if absPath, err := filepath.Abs(path); err == nil {
+		path = absPath
+	}
+	exportFormat := jsoncmd.MemberExportCSV
+	if strings.EqualFold(filepath.Ext(path), ".json") {
+		exportFormat = jsoncmd.MemberExportJSON
+	}
+	var membershipFilter []event.Membership
+	for _, membership := range strings.Fields(memberships) {
+		membershipFilter = append(membershipFilter, event.Membership(strings.ToLower(membership)))
+	}
+	view.AddServiceMessage("Exporting member list...")
+	resp, err := view.parent.matrix.ExportMembers(context.TODO(), &jsoncmd.ExportMembersParams{
+		RoomID:      view.Room.ID,
+		Format:      exportFormat,
+		Memberships: membershipFilter,
+		Path:        path,
+	})
+	if err != nil {
+		view.AddServiceMessage("Failed to export member list: %v", err)
+	} else {
+		view.AddServiceMessage("Exported %d members to %s", resp.Count, path)
+	}
+}
+
+// ToggleContinuousHistory enables or disables loading history from predecessor rooms. The mode is "on", "off"
+// or empty to toggle.
+func (view *RoomView) ToggleContinuousHistory(mode string) {
+	if view.Room.Meta.Current().CreationContent.GetPredecessor().RoomID == "" {
+		view.AddServiceMessage("This room wasn't upgraded from another room")
+		return
+	}
+	enabled := mode == "on" || (mode == "" && view.content.Lineage() == nil)
+	view.content.SetContinuousHistory(enabled)
+	if enabled {
+		view.AddServiceMessage("Scroll past the beginning of the room to load messages from the rooms it was upgraded from")
+		if !view.Room.HasMoreHistory() {
+			view.LoadHistory()
+		}
+	} else {
+		view.AddServiceMessage("Stopped showing messages from the rooms this room was upgraded from")
+	}
+}
+
+// LoadHistory loads older messages in the background. If the beginning of the room has been reached and continuous
+// history is enabled, messages are loaded from the room it was upgraded from instead.
+func (view *RoomView) LoadHistory() {
+	if lineage := view.content.Lineage(); lineage != nil && !view.Room.HasMoreHistory() {
+		if lineage.HasMore() {
+			go view.parent.LoadLineageHistory(view.Room.ID, lineage)
+		}
+		return
+	}
+	go view.parent.LoadHistory(view.Room.ID)
+}
+
 func (view *RoomView) MessageView() *MessageView {
 	return view.content
 }
@@ -811,16 +1447,50 @@ func (view *RoomView) Update(meta *database.Room) {
 		}
 		topicStr = strings.TrimSpace(topicStr)
 	}
-	view.topic.SetText(topicStr)
-	if meta.EncryptionEvent != nil && meta.EncryptionEvent.Algorithm == id.AlgorithmMegolmV1 {
+	if topicStr != view.topicText {
+		view.invalidateTopic()
+	}
+	view.topicText = topicStr
+	if view.space == nil && meta.CreationContent != nil && meta.CreationContent.Type == event.RoomTypeSpace {
+		view.space = NewSpaceView(view)
+	}
+	if meta.EncryptionEvent != nil && meta.EncryptionEvent.Algorithm == id.AlgorithmMegolmV1 && !view.slowModePlaceholder {
 		view.input.SetPlaceholder("Send an encrypted message...")
 	}
+	encrypted := meta.EncryptionEvent != nil
+	if view.metaLoaded && encrypted && !view.encrypted {
+		// The room store lock is held while metadata listeners are called, so don't read state here
+		go view.onEncryptionEnabled()
+	}
+	view.encrypted = encrypted
+	view.metaLoaded = true
+	// Encryption changes which event type is checked, and the tombstone is also in the metadata
+	view.sendRestrictionDirty.Store(true)
 	if !view.userListLoaded && view.Room.FullMembersLoaded.Load() {
 		view.UpdateUserList()
 	}
 	view.parent.parent.NeedsRender = true
 }
 
+// callStatus returns the call indicator shown at the start of the topic bar in voice rooms and rooms with active calls.
+func (view *RoomView) callStatus() string {
+	participants := view.Room.CallParticipantCount()
+	if participants > 0 {
+		return fmt.Sprintf("📞 %d in call - ", participants)
+	} else if view.Room.IsCallRoom() {
+		return "📞 Voice room - "
+	}
+	return ""
+}
+
+// activeSpaceView returns the space view if this room is a space whose hierarchy could be loaded.
+func (view *RoomView) activeSpaceView() *SpaceView {
+	if view.space == nil || view.space.IsFallback() {
+		return nil
+	}
+	return view.space
+}
+
 func (view *RoomView) UpdateUserList() {
 	view.userList.Update(view.Room.GetMembers(), view.Room.GetPowerLevels())
 	view.userListLoaded = true
@@ -0,0 +1,265 @@
+// gomuks - A terminal Matrix client written in Go.
+// Copyright (C) 2026 Tulir Asokan
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+package tui
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"go.mau.fi/mauview"
+	"go.mau.fi/util/ptr"
+	"maunium.net/go/mautrix/event"
+
+	"go.mau.fi/gomuks/pkg/hicli/database"
+	"go.mau.fi/gomuks/pkg/rpc/store"
+	"go.mau.fi/gomuks/tui/config"
+	"go.mau.fi/gomuks/tui/debug"
+	"go.mau.fi/gomuks/tui/messages"
+)
+
+// ScreenReader writes a linear transcript of new messages and navigation events for screen readers and
+// braille displays, which can't follow the two-dimensional layout of the rest of the UI.
+//
+// Announcements are formatted and written by a separate goroutine in the order they were made, because
+// timeline listeners are called while the room store is locked and writing to a FIFO may block.
+type ScreenReader struct {
+	config *config.Config
+	queue  chan func() string
+
+	output     io.Writer
+	lineEnding string
+}
+
+func NewScreenReader(cfg *config.Config) *ScreenReader {
+	sr := &ScreenReader{
+		config: cfg,
+		queue:  make(chan func() string, 256),
+	}
+	go sr.loop()
+	return sr
+}
+
+// Enabled returns true if the screen reader mode is enabled in the preferences.
+func (sr *ScreenReader) Enabled() bool {
+	return sr.config.Preferences.ScreenReaderMode
+}
+
+func (sr *ScreenReader) enqueue(fn func() string) {
+	select {
+	case sr.queue <- fn:
+	default:
+		debug.Print("Screen reader queue is full, dropping announcement")
+	}
+}
+
+// Announce writes the given text as a single line if the screen reader mode is enabled.
+func (sr *ScreenReader) Announce(text string, args ...any) {
+	if !sr.Enabled() {
+		return
+	}
+	if len(args) > 0 {
+		text = fmt.Sprintf(text, args...)
+	}
+	sr.enqueue(func() string {
+		return text
+	})
+}
+
+// AnnounceMessages writes each of the given events as a line in the "HH:MM sender: message" form.
+// Events that aren't messages, like edits and state events, are skipped.
+func (sr *ScreenReader) AnnounceMessages(room *store.RoomStore, evts []*database.Event) {
+	if !sr.Enabled() || len(evts) == 0 {
+		return
+	}
+	mode := sr.config.Preferences.GetPerMessageProfiles()
+	revealBanRemoved := sr.config.Preferences.RevealBanRemoved
+	sr.enqueue(func() string {
+		lines := make([]string, 0, len(evts))
+		for _, evt := range evts {
+			if !revealBanRemoved && messages.GetRemovingBan(room, evt) != nil {
+				continue
+			} else if line := formatAnnouncement(mode, room, evt); line != "" {
+				lines = append(lines, line)
+			}
+		}
+		return strings.Join(lines, "\n")
+	})
+}
+
+func formatAnnouncement(mode string, room *store.RoomStore, evt *database.Event) string {
+	if evtType := evt.GetType(); evtType != event.EventMessage && evtType != event.EventSticker {
+		return ""
+	} else if evt.RedactedBy != "" || evt.RelationType == event.RelReplace {
+		return ""
+	}
+	content := evt.GetMautrixContent().AsMessage()
+	body := strings.Join(strings.Fields(content.Body), " ")
+	if body == "" {
+		return ""
+	}
+	timestamp := evt.Timestamp.Local().Format("15:04")
+	sender := messages.SenderDisplayName(mode, room, evt)
+	if content.MsgType == event.MsgEmote {
+		return fmt.Sprintf("%s * %s %s", timestamp, sender, body)
+	}
+	return fmt.Sprintf("%s %s: %s", timestamp, sender, body)
+}
+
+func (sr *ScreenReader) openOutput() error {
+	path := sr.config.ScreenReaderOutput
+	if path == "" {
+		// The terminal is in raw mode while the UI is running, so newlines don't return the cursor by themselves
+		sr.output, sr.lineEnding = os.Stdout, "\r\n"
+		return nil
+	}
+	// Opening a FIFO blocks until there's a reader, which is fine as this is only called from the writer goroutine
+	file, err := os.OpenFile(path, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0600)
+	if err != nil {
+		return err
+	}
+	sr.output, sr.lineEnding = file, "\n"
+	return nil
+}
+
+func (sr *ScreenReader) loop() {
+	for fn := range sr.queue {
+		text := fn()
+		if text == "" {
+			continue
+		} else if sr.output == nil {
+			if err := sr.openOutput(); err != nil {
+				debug.Print("Failed to open screen reader output:", err)
+				continue
+			}
+		}
+		text = strings.ReplaceAll(text, "\n", sr.lineEnding) + sr.lineEnding
+		_, err := io.WriteString(sr.output, text)
+		if err != nil {
+			debug.Print("Failed to write to screen reader output:", err)
+			// Try to reopen the output for the next line, e.g. if the FIFO reader went away
+			sr.output = nil
+		}
+	}
+}
+
+// announceRoom tells the screen reader which room is focused after switching rooms or panes.
+func (view *MainView) announceRoom(roomView *RoomView) {
+	if roomView == nil || !view.screenReader.Enabled() {
+		return
+	}
+	meta := roomView.Room.Meta.Current()
+	name := ptr.Val(meta.Name)
+	if name == "" {
+		name = roomView.Room.ID.String()
+	}
+	counts := meta.UnreadCounts
+	if view.config.Preferences.GetUnreadCountSource() == config.UnreadCountSourceServer {
+		counts = meta.ServerUnreadCounts.AsUnreadCounts()
+	}
+	if counts.UnreadMessages > 0 {
+		view.screenReader.Announce("Switched to room %s, %d unread", name, counts.UnreadMessages)
+	} else {
+		view.screenReader.Announce("Switched to room %s", name)
+	}
+}
+
+// modalTitle returns the name of the given modal for screen reader announcements.
+func modalTitle(modal mauview.Component) string {
+	switch typedModal := modal.(type) {
+	case *PasswordModal:
+		return typedModal.title
+	case *FuzzySearchModal:
+		return "quick room switcher"
+	case *RecentRoomsOverlay:
+		return "recent rooms"
+	case *HelpModal:
+		return "help"
+	case *LogsModal:
+		return "recent logs"
+	case *DirectoryModal:
+		return "room directory"
+	case *ProfileModal:
+		return "profile"
+	case *ThreePIDModal:
+		return "email addresses and phone numbers"
+	case *PrivacyModal:
+		return "room privacy"
+	case *LeaveRoomsModal:
+		return "leave rooms"
+	case *RawEventModal:
+		return "raw event editor"
+	case *ViewSourceModal:
+		return "event source"
+	case *ConnectionModal:
+		return "backend connection"
+	case *SetupWizardModal:
+		return "setup wizard"
+	case *SessionProblemModal:
+		return "session problem"
+	case *SensitiveContentModal:
+		return "sensitive content confirmation"
+	case *DateContextModal:
+		return "messages around date"
+	default:
+		return "dialog"
+	}
+}
+
+// announceNewMessages sends the messages that were added to the end of the timeline to the screen reader
+// if the room is focused. Messages loaded from history or gap fills are never announced, as they're not
+// after the previously newest message.
+func (view *RoomView) announceNewMessages(timeline []*database.Event) {
+	if !view.parent.screenReader.Enabled() {
+		view.lastAnnounced = 0
+		return
+	}
+	lastIdx := -1
+	newestIdx := -1
+	for i := len(timeline) - 1; i >= 0; i-- {
+		if timeline[i].Pending {
+			continue
+		} else if newestIdx == -1 {
+			newestIdx = i
+		}
+		if timeline[i].RowID == view.lastAnnounced {
+			lastIdx = i
+			break
+		}
+	}
+	if newestIdx == -1 {
+		return
+	}
+	newest := timeline[newestIdx].RowID
+	if lastIdx == -1 || newest == view.lastAnnounced {
+		// Nothing has been announced in this room yet (or the timeline was reset), so just remember where to start
+		view.lastAnnounced = newest
+		return
+	}
+	view.lastAnnounced = newest
+	if view.parent.currentRoom != view {
+		return
+	}
+	newEvts := make([]*database.Event, 0, newestIdx-lastIdx)
+	for _, evt := range timeline[lastIdx+1 : newestIdx+1] {
+		if !evt.Pending {
+			newEvts = append(newEvts, evt)
+		}
+	}
+	view.parent.screenReader.AnnounceMessages(view.Room, newEvts)
+}
@@ -0,0 +1,249 @@
+// gomuks - A terminal Matrix client written in Go.
+// Copyright (C) 2026 Tulir Asokan
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+package tui
+
+import (
+	"encoding/json"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"go.mau.fi/mauview"
+	"go.mau.fi/util/jsontime"
+	"go.mau.fi/util/ptr"
+	"maunium.net/go/mautrix/event"
+	"maunium.net/go/mautrix/id"
+
+	"go.mau.fi/gomuks/pkg/hicli/database"
+	"go.mau.fi/gomuks/pkg/hicli/jsoncmd"
+	"go.mau.fi/gomuks/pkg/rpc/client"
+	"go.mau.fi/gomuks/pkg/rpc/store"
+	"go.mau.fi/gomuks/tui/config"
+)
+
+// lineWriter sends each written line to a channel.
+type lineWriter chan string
+
+func (lw lineWriter) Write(data []byte) (int, error) {
+	for _, line := range strings.Split(strings.TrimSuffix(string(data), "\n"), "\n") {
+		lw <- line
+	}
+	return len(data), nil
+}
+
+const screenReaderMarker = "-- end of test --"
+
+// newTestScreenReader creates a screen reader that writes lines to the returned channel instead of stdout.
+func newTestScreenReader(cfg *config.Config) (*ScreenReader, lineWriter) {
+	sr := NewScreenReader(cfg)
+	output := make(lineWriter, 64)
+	// The output is only used by the writer goroutine after the first announcement is queued
+	sr.output, sr.lineEnding = output, "\n"
+	return sr, output
+}
+
+// readAnnouncements announces a marker and returns everything written before it. Announcements are written
+// in order, so this returns exactly the announcements made before calling it.
+func readAnnouncements(t *testing.T, sr *ScreenReader, output lineWriter) []string {
+	t.Helper()
+	enabled := sr.config.Preferences.ScreenReaderMode
+	sr.config.Preferences.ScreenReaderMode = true
+	sr.Announce(screenReaderMarker)
+	sr.config.Preferences.ScreenReaderMode = enabled
+	var lines []string
+	for {
+		select {
+		case line := <-output:
+			if line == screenReaderMarker {
+				return lines
+			}
+			lines = append(lines, line)
+		case <-time.After(5 * time.Second):
+			t.Fatalf("Timed out waiting for screen reader output, got %q", lines)
+		}
+	}
+}
+
+var screenReaderTestTime = time.Date(2026, 1, 1, 12, 34, 0, 0, time.UTC)
+
+type screenReaderTest struct {
+	cfg    *config.Config
+	main   *MainView
+	sr     *ScreenReader
+	output lineWriter
+	nextID database.EventRowID
+}
+
+func newScreenReaderTest(enabled bool) *screenReaderTest {
+	cfg := &config.Config{}
+	cfg.Preferences.ScreenReaderMode = enabled
+	sr, output := newTestScreenReader(cfg)
+	gs := store.NewStore()
+	gs.UserID = "@alice:example.com"
+	return &screenReaderTest{
+		cfg: cfg,
+		main: &MainView{
+			matrix:       &client.GomuksClient{GomuksStore: gs},
+			config:       cfg,
+			parent:       &GomuksTUI{app: mauview.NewApplication()},
+			screenReader: sr,
+		},
+		sr:     sr,
+		output: output,
+		nextID: 1,
+	}
+}
+
+func (srt *screenReaderTest) newRoom(roomID id.RoomID, name string, unread int) *RoomView {
+	room := store.NewRoomStore(srt.main.matrix.GomuksStore, &database.Room{
+		ID:           roomID,
+		Name:         ptr.NonZero(name),
+		UnreadCounts: database.UnreadCounts{UnreadMessages: unread},
+	})
+	stateKey := "@bob:example.com"
+	room.ApplyState(&database.Event{
+		RowID:    1000,
+		RoomID:   roomID,
+		ID:       "$bob-member",
+		Sender:   "@bob:example.com",
+		Type:     event.StateMember.Type,
+		StateKey: &stateKey,
+		Content:  json.RawMessage(`{"membership":"join","displayname":"Bob"}`),
+	})
+	return NewRoomView(srt.main, room)
+}
+
+// sync adds events with the given contents to the end of the timeline of the room.
+func (srt *screenReaderTest) sync(view *RoomView, evtType event.Type, contents ...string) {
+	sync := &jsoncmd.SyncRoom{Meta: view.Room.Meta.Current()}
+	for _, content := range contents {
+		rowID := srt.nextID
+		srt.nextID++
+		evt := &database.Event{
+			RowID:         rowID,
+			TimelineRowID: database.TimelineRowID(rowID),
+			RoomID:        view.Room.ID,
+			ID:            id.EventID("$evt" + string(rune('a'+rowID))),
+			Sender:        "@bob:example.com",
+			Type:          evtType.Type,
+			Timestamp:     jsontime.UM(screenReaderTestTime),
+			Content:       json.RawMessage(content),
+		}
+		if evtType.IsState() {
+			evt.StateKey = ptr.Ptr("")
+		}
+		sync.Events = append(sync.Events, evt)
+		sync.Timeline = append(sync.Timeline, database.TimelineRowTuple{Timeline: evt.TimelineRowID, Event: rowID})
+	}
+	view.Room.ApplySync(sync)
+}
+
+func TestScreenReader_NewMessages(t *testing.T) {
+	srt := newScreenReaderTest(true)
+	view := srt.newRoom("!room:example.com", "General", 0)
+	srt.main.currentRoom = view
+	timestamp := screenReaderTestTime.Local().Format("15:04")
+
+	// Messages that were in the timeline when the room was opened aren't announced
+	srt.sync(view, event.EventMessage, `{"msgtype":"m.text","body":"old message"}`)
+	if lines := readAnnouncements(t, srt.sr, srt.output); len(lines) != 0 {
+		t.Errorf("Initial timeline was announced: %q", lines)
+	}
+
+	srt.sync(view, event.EventMessage,
+		`{"msgtype":"m.text","body":"hello\n  world"}`,
+		`{"msgtype":"m.emote","body":"waves"}`,
+	)
+	srt.sync(view, event.StateTopic, `{"topic":"not a message"}`)
+	srt.sync(view, event.EventMessage, `{"msgtype":"m.notice","body":"last"}`)
+	want := []string{
+		timestamp + " Bob: hello world",
+		timestamp + " * Bob waves",
+		timestamp + " Bob: last",
+	}
+	if lines := readAnnouncements(t, srt.sr, srt.output); !slices.Equal(lines, want) {
+		t.Errorf("Announced %q, want %q", lines, want)
+	}
+
+	// Messages in rooms that aren't focused aren't announced
+	srt.main.currentRoom = nil
+	srt.sync(view, event.EventMessage, `{"msgtype":"m.text","body":"while away"}`)
+	srt.main.currentRoom = view
+	srt.sync(view, event.EventMessage, `{"msgtype":"m.text","body":"after coming back"}`)
+	want = []string{timestamp + " Bob: after coming back"}
+	if lines := readAnnouncements(t, srt.sr, srt.output); !slices.Equal(lines, want) {
+		t.Errorf("Announced %q, want %q", lines, want)
+	}
+}
+
+func TestScreenReader_Navigation(t *testing.T) {
+	srt := newScreenReaderTest(true)
+	general := srt.newRoom("!general:example.com", "General", 3)
+	unnamed := srt.newRoom("!unnamed:example.com", "", 0)
+	timestamp := screenReaderTestTime.Local().Format("15:04")
+
+	srt.main.announceRoom(general)
+	srt.main.currentRoom = general
+	srt.sync(general, event.EventMessage, `{"msgtype":"m.text","body":"first"}`)
+	srt.sync(general, event.EventMessage, `{"msgtype":"m.text","body":"second"}`)
+	srt.main.ShowModal(mauview.NewTextView())
+	srt.main.HideModal()
+	srt.main.announceRoom(unnamed)
+	srt.main.currentRoom = unnamed
+	srt.sync(general, event.EventMessage, `{"msgtype":"m.text","body":"in the other room"}`)
+	want := []string{
+		"Switched to room General, 3 unread",
+		timestamp + " Bob: second",
+		"Opened dialog",
+		"Switched to room !unnamed:example.com",
+	}
+	if lines := readAnnouncements(t, srt.sr, srt.output); !slices.Equal(lines, want) {
+		t.Errorf("Announced %q, want %q", lines, want)
+	}
+}
+
+func TestScreenReader_Disabled(t *testing.T) {
+	srt := newScreenReaderTest(false)
+	view := srt.newRoom("!room:example.com", "General", 1)
+	srt.main.currentRoom = view
+	srt.main.announceRoom(view)
+	srt.sync(view, event.EventMessage, `{"msgtype":"m.text","body":"first"}`)
+	srt.sync(view, event.EventMessage, `{"msgtype":"m.text","body":"second"}`)
+	srt.main.ShowModal(mauview.NewTextView())
+	if lines := readAnnouncements(t, srt.sr, srt.output); len(lines) != 0 {
+		t.Errorf("Screen reader mode is disabled, but %q was announced", lines)
+	}
+}
+
+func TestModalTitle(t *testing.T) {
+	tests := []struct {
+		modal mauview.Component
+		want  string
+	}{
+		{&PasswordModal{title: "Change password"}, "Change password"},
+		{(*FuzzySearchModal)(nil), "quick room switcher"},
+		{(*HelpModal)(nil), "help"},
+		{(*SetupWizardModal)(nil), "setup wizard"},
+		{mauview.NewTextView(), "dialog"},
+	}
+	for _, test := range tests {
+		if got := modalTitle(test.modal); got != test.want {
+			t.Errorf("modalTitle(%T) = %q, want %q", test.modal, got, test.want)
+		}
+	}
+}
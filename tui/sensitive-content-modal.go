@@ -0,0 +1,145 @@
+// gomuks - A terminal Matrix client written in Go.
+// Copyright (C) 2026 Tulir Asokan
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+package tui
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+
+	"go.mau.fi/mauview"
+
+	"go.mau.fi/gomuks/pkg/sensitive"
+	"go.mau.fi/gomuks/tui/config"
+	"go.mau.fi/gomuks/tui/debug"
+)
+
+// findSensitiveContent runs the configured detectors on a message that's about to be sent.
+// Nothing is checked if the check is disabled in the config or the confirm_sensitive_content preference.
+func (view *RoomView) findSensitiveContent(text string) []sensitive.Match {
+	if prefs := view.parent.matrix.PreferenceCache.Current(); prefs != nil && !prefs.ConfirmSensitiveContent {
+		return nil
+	}
+	detectors, err := view.config.SensitiveContent.Detectors()
+	if err != nil {
+		debug.Print("Invalid sensitive content detectors:", err)
+		view.AddServiceMessage("Invalid sensitive content detector in config: %v", err)
+	}
+	return sensitive.Find(text, detectors)
+}
+
+// confirmSensitiveContent asks whether a message with likely sensitive content should be sent as-is,
+// sent with the matched parts redacted or not sent at all.
+func (view *RoomView) confirmSensitiveContent(text string, matches []sensitive.Match) {
+	view.parent.ShowModal(NewSensitiveContentModal(view, text, matches))
+	view.parent.parent.Render()
+}
+
+type SensitiveContentModal struct {
+	mauview.Component
+
+	text    string
+	matches []sensitive.Match
+	parent  *RoomView
+}
+
+// highlightMatches formats the text for a text view with dynamic colors, with the matched spans highlighted.
+func highlightMatches(text string, matches []sensitive.Match) string {
+	var buf strings.Builder
+	prevEnd := 0
+	for _, match := range matches {
+		buf.WriteString(mauview.Escape(text[prevEnd:match.Start]))
+		_, _ = fmt.Fprintf(&buf, "[white:red]%s[-:-]", mauview.Escape(text[match.Start:match.End]))
+		prevEnd = match.End
+	}
+	buf.WriteString(mauview.Escape(text[prevEnd:]))
+	return buf.String()
+}
+
+func NewSensitiveContentModal(parent *RoomView, text string, matches []sensitive.Match) *SensitiveContentModal {
+	scm := &SensitiveContentModal{
+		text:    text,
+		matches: matches,
+		parent:  parent,
+	}
+	var detectorNames []string
+	for _, match := range matches {
+		if !slices.Contains(detectorNames, match.Detector) {
+			detectorNames = append(detectorNames, match.Detector)
+		}
+	}
+	description := mauview.NewTextField().SetText(fmt.Sprintf(
+		"The message looks like it contains sensitive content (%s)", strings.Join(detectorNames, ", "),
+	))
+	preview := mauview.NewTextView().SetDynamicColors(true).SetWrap(true).SetWordWrap(true)
+	preview.SetText(highlightMatches(text, matches))
+
+	form := mauview.NewForm()
+	form.SetColumns([]int{1, 0, 1, 12, 1, 12, 1, 15, 1})
+	form.SetRows([]int{1, 1, 1, 0, 1, 1, 1})
+	form.AddComponent(description, 1, 1, 7, 1)
+	form.AddComponent(preview, 1, 3, 7, 1)
+	form.AddFormItem(mauview.NewButton("Cancel").SetOnClick(scm.ClickCancel), 3, 5, 1, 1)
+	form.AddFormItem(mauview.NewButton("Redact").SetOnClick(scm.ClickRedact), 5, 5, 1, 1)
+	form.AddFormItem(mauview.NewButton("Send anyway").SetOnClick(scm.ClickSend), 7, 5, 1, 1)
+
+	box := mauview.NewBox(form).SetTitle("Send sensitive content?")
+	center := mauview.Center(box, 70, 16).SetAlwaysFocusChild(true)
+	center.Focus()
+	form.FocusNextItem()
+	scm.Component = center
+	return scm
+}
+
+func (scm *SensitiveContentModal) close() {
+	scm.parent.parent.HideModal()
+	scm.parent.parent.parent.Render()
+}
+
+func (scm *SensitiveContentModal) submit(text string) {
+	scm.close()
+	if !scm.parent.submitMessage(text) {
+		scm.parent.SetInputText(scm.text)
+	}
+}
+
+func (scm *SensitiveContentModal) ClickSend() {
+	scm.submit(scm.text)
+}
+
+func (scm *SensitiveContentModal) ClickRedact() {
+	scm.submit(sensitive.Redact(scm.text, scm.matches))
+}
+
+// ClickCancel closes the modal and puts the message back in the input box so that it can be edited.
+func (scm *SensitiveContentModal) ClickCancel() {
+	scm.close()
+	scm.parent.SetInputText(scm.text)
+}
+
+func (scm *SensitiveContentModal) OnKeyEvent(event mauview.KeyEvent) bool {
+	kb := config.Keybind{
+		Key: event.Key(),
+		Ch:  event.Rune(),
+		Mod: event.Modifiers(),
+	}
+	if scm.parent.config.Keybindings.Modal[kb] == "cancel" {
+		scm.ClickCancel()
+		return true
+	}
+	return scm.Component.OnKeyEvent(event)
+}
@@ -0,0 +1,138 @@
+// gomuks - A terminal Matrix client written in Go.
+// Copyright (C) 2026 Tulir Asokan
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+package tui
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"strings"
+
+	"github.com/gdamore/tcell/v2"
+	"go.mau.fi/mauview"
+
+	"go.mau.fi/gomuks/pkg/hicli/jsoncmd"
+	"go.mau.fi/gomuks/tui/config"
+	"go.mau.fi/gomuks/tui/debug"
+)
+
+// SessionProblemModal shows the problems found by the startup session checks of the backend.
+type SessionProblemModal struct {
+	mauview.Component
+
+	text   *mauview.TextView
+	status *mauview.TextField
+	fatal  bool
+
+	parent *MainView
+}
+
+// canLogOutToFix returns true if logging in again fixes the problem. Unsupported databases are excluded,
+// because logging out would delete the data that a newer version could still read.
+func canLogOutToFix(problem *jsoncmd.SessionProblem) bool {
+	return problem.Fatal && problem.Code != jsoncmd.SessionProblemUnsupportedDatabase
+}
+
+func NewSessionProblemModal(parent *MainView, problems []*jsoncmd.SessionProblem) *SessionProblemModal {
+	spm := &SessionProblemModal{
+		parent: parent,
+		text:   mauview.NewTextView().SetDynamicColors(true).SetWrap(true).SetWordWrap(true),
+		status: mauview.NewTextField(),
+	}
+	var buf strings.Builder
+	for i, problem := range problems {
+		if i > 0 {
+			buf.WriteString("\n\n")
+		}
+		color := "yellow"
+		if problem.Fatal {
+			color = "red"
+			spm.fatal = true
+		}
+		_, _ = fmt.Fprintf(&buf, "[%s]%s[-]", color, mauview.Escape(problem.Message))
+		if problem.Details != "" {
+			_, _ = fmt.Fprintf(&buf, "\n[gray]%s[-]", mauview.Escape(problem.Details))
+		}
+	}
+	spm.text.SetText(buf.String())
+
+	form := mauview.NewForm()
+	form.SetColumns([]int{1, 0, 1, 12, 1, 12, 1})
+	form.SetRows([]int{1, 0, 1, 1, 1, 1})
+	form.AddComponent(spm.text, 1, 1, 5, 1)
+	form.AddComponent(spm.status, 1, 3, 5, 1)
+	if slices.ContainsFunc(problems, canLogOutToFix) {
+		form.AddFormItem(mauview.NewButton("Log out").SetOnClick(spm.ClickLogout), 3, 4, 1, 1)
+	}
+	title := "Session warning"
+	if spm.fatal {
+		title = "Session problem"
+	} else {
+		form.AddFormItem(mauview.NewButton("Close").SetOnClick(spm.Close), 5, 4, 1, 1)
+	}
+
+	box := mauview.NewBox(form).SetTitle(title)
+	center := mauview.Center(box, 70, 16).SetAlwaysFocusChild(true)
+	center.Focus()
+	form.FocusNextItem()
+	spm.Component = center
+	return spm
+}
+
+// ShowSessionProblems opens a modal with the problems from the startup session checks.
+func (view *MainView) ShowSessionProblems(problems []*jsoncmd.SessionProblem) {
+	if len(problems) == 0 || view.sessionProblemsShown {
+		return
+	}
+	view.sessionProblemsShown = true
+	view.ShowModal(NewSessionProblemModal(view, problems))
+	view.parent.Render()
+}
+
+func (spm *SessionProblemModal) ClickLogout() {
+	spm.status.SetText("Logging out...")
+	spm.parent.parent.Render()
+	go func() {
+		defer debug.Recover()
+		err := spm.parent.matrix.Logout(context.TODO())
+		if err != nil {
+			spm.status.SetTextColor(tcell.ColorRed).SetText(fmt.Sprintf("Failed to log out: %v", err))
+		} else {
+			spm.status.SetText("Logged out")
+		}
+		spm.parent.parent.Render()
+	}()
+}
+
+func (spm *SessionProblemModal) Close() {
+	spm.parent.HideModal()
+	spm.parent.parent.Render()
+}
+
+func (spm *SessionProblemModal) OnKeyEvent(event mauview.KeyEvent) bool {
+	kb := config.Keybind{
+		Key: event.Key(),
+		Ch:  event.Rune(),
+		Mod: event.Modifiers(),
+	}
+	// Fatal problems can't be dismissed, as the client won't work until they're fixed.
+	if !spm.fatal && spm.parent.config.Keybindings.Modal[kb] == "cancel" {
+		spm.Close()
+		return true
+	}
+	return spm.Component.OnKeyEvent(event)
+}
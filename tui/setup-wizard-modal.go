@@ -0,0 +1,204 @@
+// gomuks - A terminal Matrix client written in Go.
+// Copyright (C) 2020 Tulir Asokan
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+package tui
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+
+	"github.com/gdamore/tcell/v2"
+	"go.mau.fi/mauview"
+
+	"go.mau.fi/gomuks/tui/config"
+	"go.mau.fi/gomuks/tui/debug"
+)
+
+type SetupWizardModal struct {
+	mauview.Component
+
+	box *mauview.Box
+
+	description *mauview.TextView
+	input       *mauview.InputField
+	choices     *mauview.TextView
+	errorText   *mauview.TextField
+	hint        *mauview.TextField
+
+	flow     *SetupWizardFlow
+	prompt   SetupWizardPrompt
+	selected int
+	busy     bool
+
+	parent *MainView
+}
+
+func NewSetupWizardModal(parent *MainView) *SetupWizardModal {
+	wm := &SetupWizardModal{
+		parent:      parent,
+		flow:        NewSetupWizardFlow(parent.matrix),
+		description: mauview.NewTextView().SetWordWrap(true),
+		choices:     mauview.NewTextView().SetRegions(true),
+		errorText:   mauview.NewTextField().SetTextColor(tcell.ColorRed),
+		hint:        mauview.NewTextField().SetTextColor(tcell.ColorGray),
+	}
+	wm.box = mauview.NewBox(nil).
+		SetBorder(true).
+		SetBlurCaptureFunc(func() bool {
+			// The wizard must be completed or explicitly cancelled
+			return true
+		})
+	wm.Component = mauview.FractionalCenter(wm.box, 60, 16, 0.5, 0.4)
+	wm.refresh()
+	return wm
+}
+
+func (wm *SetupWizardModal) Focus() {
+	wm.box.Focus()
+}
+
+func (wm *SetupWizardModal) Blur() {
+	wm.box.Blur()
+}
+
+// refresh rebuilds the modal contents for the current step of the flow.
+func (wm *SetupWizardModal) refresh() {
+	wm.prompt = wm.flow.Prompt()
+	wm.box.SetTitle(fmt.Sprintf("Set up gomuks: %s", wm.prompt.Title))
+	wm.description.SetText(wm.prompt.Description)
+	flex := mauview.NewFlex().
+		SetDirection(mauview.FlexRow).
+		AddProportionalComponent(wm.description, 1)
+	if len(wm.prompt.Choices) > 0 {
+		wm.selected = 0
+		wm.choices.Clear()
+		for i, choice := range wm.prompt.Choices {
+			_, _ = fmt.Fprintf(wm.choices, `["%d"]%s[""]`+"\n", i, choice)
+		}
+		wm.choices.Highlight("0")
+		flex.AddFixedComponent(wm.choices, len(wm.prompt.Choices))
+	} else {
+		wm.input = mauview.NewInputField().
+			SetPlaceholder(wm.prompt.Placeholder).
+			SetText(wm.prompt.Default).
+			SetTextColor(tcell.ColorWhite).
+			SetBackgroundColor(tcell.ColorDarkCyan)
+		if wm.prompt.Masked {
+			wm.input.SetMaskCharacter('*')
+		}
+		wm.input.Focus()
+		flex.AddFixedComponent(wm.input, 1)
+	}
+	hint := "Enter: continue"
+	if wm.prompt.CanGoBack {
+		hint += " - Escape: back"
+	} else if !wm.flow.LoggedIn {
+		hint += " - Escape: cancel"
+	}
+	wm.hint.SetText(hint)
+	flex.AddFixedComponent(wm.errorText, 1).AddFixedComponent(wm.hint, 1)
+	wm.box.SetInnerComponent(flex)
+}
+
+func (wm *SetupWizardModal) currentInput() string {
+	if len(wm.prompt.Choices) > 0 {
+		return wm.prompt.Choices[wm.selected]
+	}
+	return wm.input.GetText()
+}
+
+func (wm *SetupWizardModal) submit(input string) {
+	defer debug.Recover()
+	wm.errorText.SetText("Please wait...")
+	wm.parent.parent.Render()
+	err := wm.flow.Submit(context.TODO(), input)
+	wm.busy = false
+	if err != nil {
+		wm.errorText.SetText(err.Error())
+		wm.parent.parent.Render()
+		return
+	}
+	wm.errorText.SetText("")
+	if wm.flow.Step == SetupStepDone {
+		wm.finish()
+		return
+	}
+	wm.refresh()
+	wm.parent.parent.Render()
+}
+
+// finish saves the preferences chosen in the wizard and closes it.
+func (wm *SetupWizardModal) finish() {
+	oldPrefs := wm.parent.config.Preferences
+	wm.parent.config.Preferences.DisableNotifications = !wm.flow.EnableNotifications
+	wm.parent.config.Preferences.HideRoomList = !wm.flow.ShowRoomList
+	if wm.parent.config.Preferences != oldPrefs {
+		wm.parent.SavePreferences(oldPrefs)
+	}
+	wm.parent.HideModal()
+	wm.parent.parent.Render()
+}
+
+func (wm *SetupWizardModal) moveSelection(diff int) {
+	if len(wm.prompt.Choices) == 0 {
+		return
+	}
+	wm.selected = (wm.selected + diff + len(wm.prompt.Choices)) % len(wm.prompt.Choices)
+	wm.choices.Highlight(strconv.Itoa(wm.selected))
+}
+
+func (wm *SetupWizardModal) OnKeyEvent(event mauview.KeyEvent) bool {
+	if wm.busy {
+		return true
+	}
+	kb := config.Keybind{
+		Key: event.Key(),
+		Ch:  event.Rune(),
+		Mod: event.Modifiers(),
+	}
+	switch wm.parent.config.Keybindings.Modal[kb] {
+	case "cancel":
+		if wm.flow.Back() {
+			wm.errorText.SetText("")
+			wm.refresh()
+		} else if !wm.flow.LoggedIn {
+			wm.parent.HideModal()
+		}
+		return true
+	case "confirm":
+		wm.busy = true
+		go wm.submit(wm.currentInput())
+		return true
+	case "select_next":
+		wm.moveSelection(1)
+		return true
+	case "select_prev":
+		wm.moveSelection(-1)
+		return true
+	}
+	if wm.input != nil && len(wm.prompt.Choices) == 0 {
+		return wm.input.OnKeyEvent(event)
+	}
+	return false
+}
+
+func (wm *SetupWizardModal) OnPasteEvent(event mauview.PasteEvent) bool {
+	if wm.input != nil && len(wm.prompt.Choices) == 0 {
+		return wm.input.OnPasteEvent(event)
+	}
+	return false
+}
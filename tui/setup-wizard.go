@@ -0,0 +1,387 @@
+// gomuks - A terminal Matrix client written in Go.
+// Copyright (C) 2020 Tulir Asokan
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+package tui
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/url"
+	"os"
+	"strings"
+
+	"maunium.net/go/mautrix"
+	"maunium.net/go/mautrix/id"
+
+	"go.mau.fi/gomuks/pkg/hicli/jsoncmd"
+)
+
+type SetupStep int
+
+const (
+	SetupStepUserID SetupStep = iota
+	SetupStepHomeserver
+	SetupStepLoginMethod
+	SetupStepPassword
+	SetupStepSSO
+	SetupStepVerify
+	SetupStepDeviceName
+	SetupStepNotifications
+	SetupStepRoomList
+	SetupStepDone
+)
+
+// SSORedirectURL is where the homeserver redirects after SSO login. Nothing is expected to be listening there:
+// the user copies the URL (which contains the login token) from their browser back into the terminal.
+const SSORedirectURL = "http://localhost/"
+
+const (
+	setupChoicePassword = "Password"
+	setupChoiceSSO      = "Single sign-on"
+	setupChoiceYes      = "Yes"
+	setupChoiceNo       = "No"
+)
+
+// SetupWizardClient contains the backend methods used by the setup wizard.
+type SetupWizardClient interface {
+	DiscoverHomeserver(ctx context.Context, params *jsoncmd.DiscoverHomeserverParams) (*mautrix.ClientWellKnown, error)
+	GetLoginFlows(ctx context.Context, params *jsoncmd.GetLoginFlowsParams) (*mautrix.RespLoginFlows, error)
+	Login(ctx context.Context, params *jsoncmd.LoginParams) error
+	LoginCustom(ctx context.Context, params *jsoncmd.LoginCustomParams) error
+	Verify(ctx context.Context, params *jsoncmd.VerifyParams) error
+	SetDeviceName(ctx context.Context, params *jsoncmd.SetDeviceNameParams) error
+}
+
+// SetupWizardPrompt describes what the current step of the setup wizard asks from the user.
+type SetupWizardPrompt struct {
+	Title       string
+	Description string
+	Placeholder string
+	Default     string
+	Choices     []string
+	Masked      bool
+	CanGoBack   bool
+}
+
+// SetupWizardFlow is the state machine behind the first-run setup wizard. It's independent of the UI,
+// which only renders the current prompt and passes the user's input to Submit or calls Back.
+type SetupWizardFlow struct {
+	client SetupWizardClient
+
+	Step    SetupStep
+	history []SetupStep
+
+	UserID        id.UserID
+	HomeserverURL string
+	LoginMethods  []string
+	LoggedIn      bool
+	DeviceName    string
+
+	EnableNotifications bool
+	ShowRoomList        bool
+}
+
+func NewSetupWizardFlow(client SetupWizardClient) *SetupWizardFlow {
+	return &SetupWizardFlow{
+		client:              client,
+		Step:                SetupStepUserID,
+		DeviceName:          DefaultDeviceName(),
+		EnableNotifications: true,
+		ShowRoomList:        true,
+	}
+}
+
+func (flow *SetupWizardFlow) Prompt() SetupWizardPrompt {
+	prompt := SetupWizardPrompt{CanGoBack: flow.CanGoBack()}
+	switch flow.Step {
+	case SetupStepUserID:
+		prompt.Title = "Matrix account"
+		prompt.Description = "Enter your Matrix user ID."
+		prompt.Placeholder = "@user:example.com"
+		prompt.Default = flow.UserID.String()
+	case SetupStepHomeserver:
+		prompt.Title = "Homeserver"
+		prompt.Description = "Couldn't find the homeserver automatically. Enter the homeserver URL."
+		prompt.Placeholder = "https://matrix.example.com"
+		prompt.Default = flow.HomeserverURL
+	case SetupStepLoginMethod:
+		prompt.Title = "Login method"
+		prompt.Description = fmt.Sprintf("How do you want to log in to %s?", flow.HomeserverURL)
+		prompt.Choices = flow.LoginMethods
+	case SetupStepPassword:
+		prompt.Title = "Password"
+		prompt.Description = fmt.Sprintf("Enter the password for %s.", flow.UserID)
+		prompt.Masked = true
+	case SetupStepSSO:
+		prompt.Title = "Single sign-on"
+		prompt.Description = fmt.Sprintf(
+			"Open the following URL in a browser and log in. You'll be redirected to a page that doesn't load: "+
+				"copy its address and paste it below.\n\n%s", flow.SSOURL(),
+		)
+		prompt.Placeholder = SSORedirectURL + "?loginToken=..."
+	case SetupStepVerify:
+		prompt.Title = "Verify session"
+		prompt.Description = "Enter your recovery key to verify this session and get access to encrypted " +
+			"message history. Leave empty to skip."
+		prompt.Placeholder = "EsT* **** **** ****"
+		prompt.Masked = true
+	case SetupStepDeviceName:
+		prompt.Title = "Session name"
+		prompt.Description = "Enter a name for this session. Other clients show it in their session lists, " +
+			"which makes it easier to find the right session when verifying. Leave empty to skip."
+		prompt.Placeholder = DefaultDeviceName()
+		prompt.Default = flow.DeviceName
+	case SetupStepNotifications:
+		prompt.Title = "Notifications"
+		prompt.Description = "Do you want desktop notifications for new messages?"
+		prompt.Choices = []string{setupChoiceYes, setupChoiceNo}
+	case SetupStepRoomList:
+		prompt.Title = "Room list"
+		prompt.Description = "Do you want to show the room list on the left side of the screen?"
+		prompt.Choices = []string{setupChoiceYes, setupChoiceNo}
+	case SetupStepDone:
+		prompt.Title = "Done"
+		prompt.Description = "Setup complete."
+	}
+	return prompt
+}
+
+// SSOURL returns the URL the user should open to log in with single sign-on.
+func (flow *SetupWizardFlow) SSOURL() string {
+	return fmt.Sprintf(
+		"%s/_matrix/client/v3/login/sso/redirect?redirectUrl=%s",
+		strings.TrimRight(flow.HomeserverURL, "/"), url.QueryEscape(SSORedirectURL),
+	)
+}
+
+// CanGoBack returns true if there's a previous step to return to. Login steps can't be revisited after logging in.
+func (flow *SetupWizardFlow) CanGoBack() bool {
+	if len(flow.history) == 0 || flow.Step == SetupStepDone {
+		return false
+	}
+	prev := flow.history[len(flow.history)-1]
+	return !flow.LoggedIn || prev > SetupStepVerify
+}
+
+func (flow *SetupWizardFlow) Back() bool {
+	if !flow.CanGoBack() {
+		return false
+	}
+	flow.Step = flow.history[len(flow.history)-1]
+	flow.history = flow.history[:len(flow.history)-1]
+	return true
+}
+
+func (flow *SetupWizardFlow) advance(next SetupStep) {
+	flow.history = append(flow.history, flow.Step)
+	flow.Step = next
+}
+
+var ErrInvalidChoice = errors.New("please select one of the options")
+
+// Submit validates the input for the current step, performs any requests needed and moves to the next step.
+// If an error is returned, the flow stays in the current step.
+func (flow *SetupWizardFlow) Submit(ctx context.Context, input string) error {
+	input = strings.TrimSpace(input)
+	switch flow.Step {
+	case SetupStepUserID:
+		return flow.submitUserID(ctx, input)
+	case SetupStepHomeserver:
+		return flow.submitHomeserver(ctx, input)
+	case SetupStepLoginMethod:
+		switch input {
+		case setupChoicePassword:
+			flow.advance(SetupStepPassword)
+		case setupChoiceSSO:
+			flow.advance(SetupStepSSO)
+		default:
+			return ErrInvalidChoice
+		}
+	case SetupStepPassword:
+		if input == "" {
+			return errors.New("please enter your password")
+		}
+		err := flow.client.Login(ctx, &jsoncmd.LoginParams{
+			HomeserverURL: flow.HomeserverURL,
+			Username:      flow.UserID.String(),
+			Password:      input,
+		})
+		if err != nil {
+			return fmt.Errorf("failed to log in: %w", err)
+		}
+		flow.LoggedIn = true
+		flow.advance(SetupStepVerify)
+	case SetupStepSSO:
+		token, err := parseSSOLoginToken(input)
+		if err != nil {
+			return err
+		}
+		err = flow.client.LoginCustom(ctx, &jsoncmd.LoginCustomParams{
+			HomeserverURL: flow.HomeserverURL,
+			Request: &mautrix.ReqLogin{
+				Type:  mautrix.AuthTypeToken,
+				Token: token,
+			},
+		})
+		if err != nil {
+			return fmt.Errorf("failed to log in: %w", err)
+		}
+		flow.LoggedIn = true
+		flow.advance(SetupStepVerify)
+	case SetupStepVerify:
+		if input != "" {
+			err := flow.client.Verify(ctx, &jsoncmd.VerifyParams{RecoveryKey: input})
+			if err != nil {
+				return fmt.Errorf("failed to verify session: %w", err)
+			}
+		}
+		flow.advance(SetupStepDeviceName)
+	case SetupStepDeviceName:
+		if input != "" {
+			err := flow.client.SetDeviceName(ctx, &jsoncmd.SetDeviceNameParams{Name: input})
+			if err != nil {
+				return fmt.Errorf("failed to set session name: %w", err)
+			}
+		}
+		flow.DeviceName = input
+		flow.advance(SetupStepNotifications)
+	case SetupStepNotifications:
+		value, err := parseSetupYesNo(input)
+		if err != nil {
+			return err
+		}
+		flow.EnableNotifications = value
+		flow.advance(SetupStepRoomList)
+	case SetupStepRoomList:
+		value, err := parseSetupYesNo(input)
+		if err != nil {
+			return err
+		}
+		flow.ShowRoomList = value
+		flow.advance(SetupStepDone)
+	}
+	return nil
+}
+
+func (flow *SetupWizardFlow) submitUserID(ctx context.Context, input string) error {
+	userID := id.UserID(input)
+	_, server, err := userID.Parse()
+	if err != nil || !strings.HasPrefix(input, "@") || server == "" {
+		return errors.New("please enter a full user ID like @user:example.com")
+	}
+	flow.UserID = userID
+	wellKnown, err := flow.client.DiscoverHomeserver(ctx, &jsoncmd.DiscoverHomeserverParams{UserID: userID})
+	if err != nil || wellKnown == nil || wellKnown.Homeserver.BaseURL == "" {
+		// Broken or missing .well-known, ask for the URL manually and guess a default
+		flow.HomeserverURL = "https://" + server
+		flow.advance(SetupStepHomeserver)
+		return nil
+	}
+	flow.HomeserverURL = wellKnown.Homeserver.BaseURL
+	if err = flow.loadLoginFlows(ctx); err != nil {
+		// The discovered URL doesn't seem to work, let the user fix it
+		flow.advance(SetupStepHomeserver)
+		return nil
+	}
+	flow.advanceToLogin()
+	return nil
+}
+
+func (flow *SetupWizardFlow) submitHomeserver(ctx context.Context, input string) error {
+	parsed, err := url.Parse(input)
+	if err != nil || (parsed.Scheme != "http" && parsed.Scheme != "https") || parsed.Host == "" {
+		return errors.New("please enter a valid http(s) URL")
+	}
+	flow.HomeserverURL = input
+	if err = flow.loadLoginFlows(ctx); err != nil {
+		return err
+	}
+	flow.advanceToLogin()
+	return nil
+}
+
+func (flow *SetupWizardFlow) loadLoginFlows(ctx context.Context) error {
+	resp, err := flow.client.GetLoginFlows(ctx, &jsoncmd.GetLoginFlowsParams{HomeserverURL: flow.HomeserverURL})
+	if err != nil {
+		return fmt.Errorf("failed to get login flows: %w", err)
+	}
+	flow.LoginMethods = flow.LoginMethods[:0]
+	if resp.HasFlow(mautrix.AuthTypePassword) {
+		flow.LoginMethods = append(flow.LoginMethods, setupChoicePassword)
+	}
+	if resp.HasFlow(mautrix.AuthTypeSSO) {
+		flow.LoginMethods = append(flow.LoginMethods, setupChoiceSSO)
+	}
+	if len(flow.LoginMethods) == 0 {
+		return errors.New("the homeserver doesn't support any login methods that gomuks supports")
+	}
+	return nil
+}
+
+func (flow *SetupWizardFlow) advanceToLogin() {
+	if len(flow.LoginMethods) > 1 {
+		flow.advance(SetupStepLoginMethod)
+	} else if flow.LoginMethods[0] == setupChoiceSSO {
+		flow.advance(SetupStepSSO)
+	} else {
+		flow.advance(SetupStepPassword)
+	}
+}
+
+// DefaultDeviceName returns the suggested name for new sessions. The hostname is included,
+// so that sessions on different computers can be told apart.
+func DefaultDeviceName() string {
+	return defaultDeviceName(os.Hostname())
+}
+
+func defaultDeviceName(hostname string, err error) string {
+	hostname = strings.TrimSpace(hostname)
+	if err != nil || hostname == "" {
+		return "gomuks terminal"
+	}
+	return fmt.Sprintf("gomuks terminal (%s)", hostname)
+}
+
+func parseSSOLoginToken(input string) (string, error) {
+	if input == "" {
+		return "", errors.New("please paste the URL you were redirected to")
+	}
+	if !strings.Contains(input, "://") {
+		// Assume the user only pasted the token itself
+		return input, nil
+	}
+	parsed, err := url.Parse(input)
+	if err != nil {
+		return "", fmt.Errorf("failed to parse URL: %w", err)
+	}
+	token := parsed.Query().Get("loginToken")
+	if token == "" {
+		return "", errors.New("the URL doesn't contain a login token")
+	}
+	return token, nil
+}
+
+func parseSetupYesNo(input string) (bool, error) {
+	switch input {
+	case setupChoiceYes:
+		return true, nil
+	case setupChoiceNo:
+		return false, nil
+	default:
+		return false, ErrInvalidChoice
+	}
+}
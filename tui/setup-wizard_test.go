@@ -0,0 +1,432 @@
+// gomuks - A terminal Matrix client written in Go.
+// Copyright (C) 2026 Tulir Asokan
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+package tui
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"testing"
+
+	"maunium.net/go/mautrix"
+
+	"go.mau.fi/gomuks/pkg/hicli/jsoncmd"
+)
+
+// fakeSetupClient returns canned responses to the setup wizard and records the requests it receives.
+type fakeSetupClient struct {
+	wellKnown     *mautrix.ClientWellKnown
+	wellKnownErr  error
+	flows         *mautrix.RespLoginFlows
+	flowsErr      error
+	loginErr      error
+	verifyErr     error
+	deviceNameErr error
+
+	calls []string
+}
+
+var _ SetupWizardClient = (*fakeSetupClient)(nil)
+
+func (fsc *fakeSetupClient) DiscoverHomeserver(_ context.Context, params *jsoncmd.DiscoverHomeserverParams) (*mautrix.ClientWellKnown, error) {
+	fsc.calls = append(fsc.calls, "discover "+params.UserID.String())
+	return fsc.wellKnown, fsc.wellKnownErr
+}
+
+func (fsc *fakeSetupClient) GetLoginFlows(_ context.Context, params *jsoncmd.GetLoginFlowsParams) (*mautrix.RespLoginFlows, error) {
+	fsc.calls = append(fsc.calls, "flows "+params.HomeserverURL)
+	return fsc.flows, fsc.flowsErr
+}
+
+func (fsc *fakeSetupClient) Login(_ context.Context, params *jsoncmd.LoginParams) error {
+	fsc.calls = append(fsc.calls, fmt.Sprintf("login %s %s %s", params.HomeserverURL, params.Username, params.Password))
+	return fsc.loginErr
+}
+
+func (fsc *fakeSetupClient) LoginCustom(_ context.Context, params *jsoncmd.LoginCustomParams) error {
+	fsc.calls = append(fsc.calls, fmt.Sprintf("login %s %s %s", params.HomeserverURL, params.Request.Type, params.Request.Token))
+	return fsc.loginErr
+}
+
+func (fsc *fakeSetupClient) Verify(_ context.Context, params *jsoncmd.VerifyParams) error {
+	fsc.calls = append(fsc.calls, "verify "+params.RecoveryKey)
+	return fsc.verifyErr
+}
+
+func (fsc *fakeSetupClient) SetDeviceName(_ context.Context, params *jsoncmd.SetDeviceNameParams) error {
+	fsc.calls = append(fsc.calls, "device name "+params.Name)
+	return fsc.deviceNameErr
+}
+
+var (
+	testWellKnown = &mautrix.ClientWellKnown{Homeserver: mautrix.HomeserverInfo{BaseURL: "https://matrix.example.com"}}
+	passwordFlows = &mautrix.RespLoginFlows{Flows: []mautrix.LoginFlow{{Type: mautrix.AuthTypePassword}}}
+	ssoFlows      = &mautrix.RespLoginFlows{Flows: []mautrix.LoginFlow{{Type: mautrix.AuthTypeSSO}}}
+	allFlows      = &mautrix.RespLoginFlows{Flows: []mautrix.LoginFlow{{Type: mautrix.AuthTypePassword}, {Type: mautrix.AuthTypeSSO}}}
+)
+
+func TestDefaultDeviceName(t *testing.T) {
+	tests := []struct {
+		name     string
+		hostname string
+		err      error
+		want     string
+	}{
+		{"hostname", "workstation", nil, "gomuks terminal (workstation)"},
+		{"hostname with whitespace", " laptop.local\n", nil, "gomuks terminal (laptop.local)"},
+		{"empty hostname", "", nil, "gomuks terminal"},
+		{"whitespace hostname", "  ", nil, "gomuks terminal"},
+		{"hostname error", "ignored", errors.New("no hostname"), "gomuks terminal"},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if got := defaultDeviceName(test.hostname, test.err); got != test.want {
+				t.Errorf("defaultDeviceName(%q, %v) = %q, want %q", test.hostname, test.err, got, test.want)
+			}
+		})
+	}
+}
+
+func TestSetupWizardFlow_Submit(t *testing.T) {
+	errServer := errors.New("server error")
+	tests := []struct {
+		name   string
+		client fakeSetupClient
+		// inputs are submitted first to get to the step being tested and must succeed
+		inputs    []string
+		input     string
+		wantStep  SetupStep
+		wantErr   bool
+		wantCalls []string
+	}{
+		{
+			name:     "invalid user ID",
+			input:    "alice",
+			wantStep: SetupStepUserID, wantErr: true,
+		},
+		{
+			name:     "user ID without server",
+			input:    "@alice",
+			wantStep: SetupStepUserID, wantErr: true,
+		},
+		{
+			name:      "discovered homeserver with password login",
+			client:    fakeSetupClient{wellKnown: testWellKnown, flows: passwordFlows},
+			input:     "@alice:example.com",
+			wantStep:  SetupStepPassword,
+			wantCalls: []string{"discover @alice:example.com", "flows https://matrix.example.com"},
+		},
+		{
+			name:      "discovered homeserver with only SSO",
+			client:    fakeSetupClient{wellKnown: testWellKnown, flows: ssoFlows},
+			input:     "@alice:example.com",
+			wantStep:  SetupStepSSO,
+			wantCalls: []string{"discover @alice:example.com", "flows https://matrix.example.com"},
+		},
+		{
+			name:      "discovered homeserver with multiple login methods",
+			client:    fakeSetupClient{wellKnown: testWellKnown, flows: allFlows},
+			input:     "@alice:example.com",
+			wantStep:  SetupStepLoginMethod,
+			wantCalls: []string{"discover @alice:example.com", "flows https://matrix.example.com"},
+		},
+		{
+			name:      "broken well-known",
+			client:    fakeSetupClient{wellKnownErr: errServer},
+			input:     "@alice:example.com",
+			wantStep:  SetupStepHomeserver,
+			wantCalls: []string{"discover @alice:example.com"},
+		},
+		{
+			name:      "well-known without homeserver",
+			client:    fakeSetupClient{wellKnown: &mautrix.ClientWellKnown{}},
+			input:     "@alice:example.com",
+			wantStep:  SetupStepHomeserver,
+			wantCalls: []string{"discover @alice:example.com"},
+		},
+		{
+			name:      "discovered homeserver doesn't respond",
+			client:    fakeSetupClient{wellKnown: testWellKnown, flowsErr: errServer},
+			input:     "@alice:example.com",
+			wantStep:  SetupStepHomeserver,
+			wantCalls: []string{"discover @alice:example.com", "flows https://matrix.example.com"},
+		},
+		{
+			name:      "discovered homeserver has no supported login methods",
+			client:    fakeSetupClient{wellKnown: testWellKnown, flows: &mautrix.RespLoginFlows{}},
+			input:     "@alice:example.com",
+			wantStep:  SetupStepHomeserver,
+			wantCalls: []string{"discover @alice:example.com", "flows https://matrix.example.com"},
+		},
+		{
+			name:     "invalid homeserver URL",
+			client:   fakeSetupClient{wellKnownErr: errServer, flows: passwordFlows},
+			inputs:   []string{"@alice:example.com"},
+			input:    "matrix.example.com",
+			wantStep: SetupStepHomeserver, wantErr: true,
+		},
+		{
+			name:      "manual homeserver doesn't respond",
+			client:    fakeSetupClient{wellKnownErr: errServer, flowsErr: errServer},
+			inputs:    []string{"@alice:example.com"},
+			input:     "https://matrix.example.com",
+			wantStep:  SetupStepHomeserver,
+			wantErr:   true,
+			wantCalls: []string{"flows https://matrix.example.com"},
+		},
+		{
+			name:      "manual homeserver",
+			client:    fakeSetupClient{wellKnownErr: errServer, flows: passwordFlows},
+			inputs:    []string{"@alice:example.com"},
+			input:     "https://matrix.example.com",
+			wantStep:  SetupStepPassword,
+			wantCalls: []string{"flows https://matrix.example.com"},
+		},
+		{
+			name:     "invalid login method",
+			client:   fakeSetupClient{wellKnown: testWellKnown, flows: allFlows},
+			inputs:   []string{"@alice:example.com"},
+			input:    "Carrier pigeon",
+			wantStep: SetupStepLoginMethod, wantErr: true,
+		},
+		{
+			name:     "choose SSO",
+			client:   fakeSetupClient{wellKnown: testWellKnown, flows: allFlows},
+			inputs:   []string{"@alice:example.com"},
+			input:    setupChoiceSSO,
+			wantStep: SetupStepSSO,
+		},
+		{
+			name:     "empty password",
+			client:   fakeSetupClient{wellKnown: testWellKnown, flows: passwordFlows},
+			inputs:   []string{"@alice:example.com"},
+			input:    "",
+			wantStep: SetupStepPassword, wantErr: true,
+		},
+		{
+			name:      "wrong password",
+			client:    fakeSetupClient{wellKnown: testWellKnown, flows: passwordFlows, loginErr: mautrix.MForbidden},
+			inputs:    []string{"@alice:example.com"},
+			input:     "hunter2",
+			wantStep:  SetupStepPassword,
+			wantErr:   true,
+			wantCalls: []string{"login https://matrix.example.com @alice:example.com hunter2"},
+		},
+		{
+			name:      "password login",
+			client:    fakeSetupClient{wellKnown: testWellKnown, flows: passwordFlows},
+			inputs:    []string{"@alice:example.com"},
+			input:     "hunter2",
+			wantStep:  SetupStepVerify,
+			wantCalls: []string{"login https://matrix.example.com @alice:example.com hunter2"},
+		},
+		{
+			name:     "SSO redirect without token",
+			client:   fakeSetupClient{wellKnown: testWellKnown, flows: ssoFlows},
+			inputs:   []string{"@alice:example.com"},
+			input:    "http://localhost/?foo=bar",
+			wantStep: SetupStepSSO, wantErr: true,
+		},
+		{
+			name:      "SSO login failed",
+			client:    fakeSetupClient{wellKnown: testWellKnown, flows: ssoFlows, loginErr: errServer},
+			inputs:    []string{"@alice:example.com"},
+			input:     "http://localhost/?loginToken=meow",
+			wantStep:  SetupStepSSO,
+			wantErr:   true,
+			wantCalls: []string{"login https://matrix.example.com m.login.token meow"},
+		},
+		{
+			name:      "SSO login",
+			client:    fakeSetupClient{wellKnown: testWellKnown, flows: ssoFlows},
+			inputs:    []string{"@alice:example.com"},
+			input:     "http://localhost/?loginToken=meow",
+			wantStep:  SetupStepVerify,
+			wantCalls: []string{"login https://matrix.example.com m.login.token meow"},
+		},
+		{
+			name:      "SSO login with bare token",
+			client:    fakeSetupClient{wellKnown: testWellKnown, flows: ssoFlows},
+			inputs:    []string{"@alice:example.com"},
+			input:     "meow",
+			wantStep:  SetupStepVerify,
+			wantCalls: []string{"login https://matrix.example.com m.login.token meow"},
+		},
+		{
+			name:      "wrong recovery key",
+			client:    fakeSetupClient{wellKnown: testWellKnown, flows: passwordFlows, verifyErr: errServer},
+			inputs:    []string{"@alice:example.com", "hunter2"},
+			input:     "EsTc 1234",
+			wantStep:  SetupStepVerify,
+			wantErr:   true,
+			wantCalls: []string{"verify EsTc 1234"},
+		},
+		{
+			name:      "recovery key",
+			client:    fakeSetupClient{wellKnown: testWellKnown, flows: passwordFlows},
+			inputs:    []string{"@alice:example.com", "hunter2"},
+			input:     "EsTc 1234",
+			wantStep:  SetupStepDeviceName,
+			wantCalls: []string{"verify EsTc 1234"},
+		},
+		{
+			name:     "skip verification",
+			client:   fakeSetupClient{wellKnown: testWellKnown, flows: passwordFlows},
+			inputs:   []string{"@alice:example.com", "hunter2"},
+			input:    "",
+			wantStep: SetupStepDeviceName,
+		},
+		{
+			name:      "device name failed",
+			client:    fakeSetupClient{wellKnown: testWellKnown, flows: passwordFlows, deviceNameErr: errServer},
+			inputs:    []string{"@alice:example.com", "hunter2", ""},
+			input:     "laptop",
+			wantStep:  SetupStepDeviceName,
+			wantErr:   true,
+			wantCalls: []string{"device name laptop"},
+		},
+		{
+			name:      "device name",
+			client:    fakeSetupClient{wellKnown: testWellKnown, flows: passwordFlows},
+			inputs:    []string{"@alice:example.com", "hunter2", ""},
+			input:     "laptop",
+			wantStep:  SetupStepNotifications,
+			wantCalls: []string{"device name laptop"},
+		},
+		{
+			name:     "invalid notification choice",
+			client:   fakeSetupClient{wellKnown: testWellKnown, flows: passwordFlows},
+			inputs:   []string{"@alice:example.com", "hunter2", "", ""},
+			input:    "Maybe",
+			wantStep: SetupStepNotifications, wantErr: true,
+		},
+		{
+			name:     "notifications",
+			client:   fakeSetupClient{wellKnown: testWellKnown, flows: passwordFlows},
+			inputs:   []string{"@alice:example.com", "hunter2", "", ""},
+			input:    setupChoiceNo,
+			wantStep: SetupStepRoomList,
+		},
+		{
+			name:     "invalid room list choice",
+			client:   fakeSetupClient{wellKnown: testWellKnown, flows: passwordFlows},
+			inputs:   []string{"@alice:example.com", "hunter2", "", "", setupChoiceNo},
+			input:    "",
+			wantStep: SetupStepRoomList, wantErr: true,
+		},
+		{
+			name:     "room list",
+			client:   fakeSetupClient{wellKnown: testWellKnown, flows: passwordFlows},
+			inputs:   []string{"@alice:example.com", "hunter2", "", "", setupChoiceNo},
+			input:    setupChoiceYes,
+			wantStep: SetupStepDone,
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			ctx := context.Background()
+			client := test.client
+			flow := NewSetupWizardFlow(&client)
+			for _, input := range test.inputs {
+				if err := flow.Submit(ctx, input); err != nil {
+					t.Fatalf("Submitting %q in step %d failed: %v", input, flow.Step, err)
+				}
+			}
+			client.calls = nil
+			err := flow.Submit(ctx, test.input)
+			if (err != nil) != test.wantErr {
+				t.Errorf("Submit(%q) returned error %v, want error: %t", test.input, err, test.wantErr)
+			}
+			if flow.Step != test.wantStep {
+				t.Errorf("Flow is in step %d after submitting %q, want %d", flow.Step, test.input, test.wantStep)
+			}
+			if !slices.Equal(client.calls, test.wantCalls) {
+				t.Errorf("Submit(%q) made requests %q, want %q", test.input, client.calls, test.wantCalls)
+			}
+		})
+	}
+}
+
+func TestSetupWizardFlow_FullFlow(t *testing.T) {
+	ctx := context.Background()
+	client := &fakeSetupClient{wellKnownErr: errors.New("broken well-known"), flows: allFlows}
+	flow := NewSetupWizardFlow(client)
+	steps := []struct {
+		input    string
+		wantStep SetupStep
+	}{
+		{"@alice:example.com", SetupStepHomeserver},
+		{"https://matrix.example.com", SetupStepLoginMethod},
+		{setupChoicePassword, SetupStepPassword},
+		{"hunter2", SetupStepVerify},
+		{"EsTc 1234", SetupStepDeviceName},
+		{"laptop", SetupStepNotifications},
+		{setupChoiceNo, SetupStepRoomList},
+		{setupChoiceNo, SetupStepDone},
+	}
+	for _, step := range steps {
+		if err := flow.Submit(ctx, step.input); err != nil {
+			t.Fatalf("Submitting %q in step %d failed: %v", step.input, flow.Step, err)
+		} else if flow.Step != step.wantStep {
+			t.Fatalf("Flow is in step %d after submitting %q, want %d", flow.Step, step.input, step.wantStep)
+		}
+	}
+	if !flow.LoggedIn || flow.HomeserverURL != "https://matrix.example.com" || flow.DeviceName != "laptop" {
+		t.Errorf("Unexpected flow state after setup: %+v", flow)
+	} else if flow.EnableNotifications || flow.ShowRoomList {
+		t.Error("Preference answers weren't stored")
+	}
+	if flow.CanGoBack() || flow.Back() {
+		t.Error("Flow allowed going back after setup was done")
+	}
+}
+
+func TestSetupWizardFlow_Back(t *testing.T) {
+	ctx := context.Background()
+	client := &fakeSetupClient{wellKnown: testWellKnown, flows: allFlows}
+	flow := NewSetupWizardFlow(client)
+	if flow.CanGoBack() || flow.Back() {
+		t.Error("Flow allowed going back from the first step")
+	}
+	for _, input := range []string{"@alice:example.com", setupChoiceSSO} {
+		if err := flow.Submit(ctx, input); err != nil {
+			t.Fatalf("Submitting %q failed: %v", input, err)
+		}
+	}
+	// Changing the login method after picking one
+	if !flow.Back() || flow.Step != SetupStepLoginMethod {
+		t.Fatalf("Going back from SSO led to step %d, want %d", flow.Step, SetupStepLoginMethod)
+	}
+	if !flow.Back() || flow.Step != SetupStepUserID {
+		t.Fatalf("Going back from the login method led to step %d, want %d", flow.Step, SetupStepUserID)
+	} else if prompt := flow.Prompt(); prompt.Default != "@alice:example.com" {
+		t.Errorf("User ID prompt after going back has default %q", prompt.Default)
+	}
+	for _, input := range []string{"@alice:example.com", setupChoicePassword, "hunter2", "", "laptop"} {
+		if err := flow.Submit(ctx, input); err != nil {
+			t.Fatalf("Submitting %q failed: %v", input, err)
+		}
+	}
+	// Steps after logging in can be revisited, but login steps can't
+	if flow.Step != SetupStepNotifications || !flow.Back() || flow.Step != SetupStepDeviceName {
+		t.Fatalf("Going back from notifications led to step %d, want %d", flow.Step, SetupStepDeviceName)
+	}
+	if flow.CanGoBack() || flow.Back() {
+		t.Errorf("Flow allowed going back from the session name to step %d after logging in", flow.Step)
+	}
+}
@@ -0,0 +1,145 @@
+// gomuks - A terminal Matrix client written in Go.
+// Copyright (C) 2026 Tulir Asokan
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+package tui
+
+import (
+	"fmt"
+	"math"
+	"time"
+
+	"maunium.net/go/mautrix/event"
+	"maunium.net/go/mautrix/id"
+
+	"go.mau.fi/gomuks/pkg/hicli/jsoncmd"
+	"go.mau.fi/gomuks/tui/debug"
+)
+
+// queuedMessage is a message that was entered while the room was in slow mode.
+// It's sent automatically when the cooldown expires.
+type queuedMessage struct {
+	text  string
+	timer *time.Timer
+}
+
+// HandleSendCooldown re-renders the UI every second while a send cooldown is active,
+// so that the countdown in the input placeholder stays up to date.
+func (view *MainView) HandleSendCooldown(evt *jsoncmd.SendCooldown) {
+	room := view.matrix.GetRoom(evt.RoomID)
+	if room == nil {
+		return
+	}
+	view.parent.Render()
+	if evt.Until.IsZero() {
+		return
+	}
+	go func() {
+		defer debug.Recover()
+		ticker := time.NewTicker(1 * time.Second)
+		defer ticker.Stop()
+		// Stop if the cooldown expires or is replaced by a newer one, which will have its own ticker
+		for time.Now().Before(evt.Until.Time) && room.SendCooldown.Current().Equal(evt.Until.Time) {
+			<-ticker.C
+			view.parent.Render()
+		}
+	}()
+}
+
+// sendCooldownRemaining returns how long the user has to wait before sending messages to the room.
+func (view *RoomView) sendCooldownRemaining() time.Duration {
+	until := view.Room.SendCooldown.Current()
+	if until.IsZero() {
+		return 0
+	}
+	return max(time.Until(until), 0)
+}
+
+// defaultPlaceholder returns the input placeholder used when the room isn't in slow mode.
+func (view *RoomView) defaultPlaceholder() string {
+	meta := view.Room.Meta.Current()
+	if meta != nil && meta.EncryptionEvent != nil && meta.EncryptionEvent.Algorithm == id.AlgorithmMegolmV1 {
+		return "Send an encrypted message..."
+	}
+	return "Send a message..."
+}
+
+// updateSlowModePlaceholder shows the remaining cooldown in the input placeholder while the room is in slow mode.
+func (view *RoomView) updateSlowModePlaceholder() {
+	if remaining := view.sendCooldownRemaining(); remaining > 0 {
+		seconds := int(math.Ceil(remaining.Seconds()))
+		view.input.SetPlaceholder(fmt.Sprintf("Slow mode: wait %ds", seconds))
+		view.slowModePlaceholder = true
+	} else if view.slowModePlaceholder {
+		view.input.SetPlaceholder(view.defaultPlaceholder())
+		view.slowModePlaceholder = false
+	}
+}
+
+// QueueMessage queues a message to be sent when the send cooldown of the room expires.
+// Only one message can be queued at a time, so false is returned if there's already one.
+func (view *RoomView) QueueMessage(text string) bool {
+	view.queuedMessageLock.Lock()
+	defer view.queuedMessageLock.Unlock()
+	if view.queuedMessage != nil {
+		return false
+	}
+	msg := &queuedMessage{text: text}
+	msg.timer = time.AfterFunc(view.sendCooldownRemaining(), func() {
+		view.sendQueuedMessage(msg)
+	})
+	view.queuedMessage = msg
+	return true
+}
+
+func (view *RoomView) sendQueuedMessage(msg *queuedMessage) {
+	view.queuedMessageLock.Lock()
+	defer view.queuedMessageLock.Unlock()
+	if view.queuedMessage != msg {
+		return
+	} else if remaining := view.sendCooldownRemaining(); remaining > 0 {
+		// Another send was rate limited while waiting, so the cooldown got extended
+		msg.timer.Reset(remaining)
+		return
+	}
+	view.queuedMessage = nil
+	go view.SendMessage(event.MsgText, msg.text)
+}
+
+// CancelQueuedMessage cancels sending the message that's waiting for the cooldown to expire
+// and puts its text back in the input box if the input box is empty.
+func (view *RoomView) CancelQueuedMessage() bool {
+	view.queuedMessageLock.Lock()
+	defer view.queuedMessageLock.Unlock()
+	msg := view.queuedMessage
+	if msg == nil {
+		return false
+	}
+	view.queuedMessage = nil
+	msg.timer.Stop()
+	if view.input.GetText() == "" {
+		view.SetInputText(msg.text)
+	}
+	return true
+}
+
+func (view *RoomView) queuedMessageStatus() string {
+	view.queuedMessageLock.Lock()
+	defer view.queuedMessageLock.Unlock()
+	if view.queuedMessage == nil {
+		return ""
+	}
+	return "Message queued until slow mode ends"
+}
@@ -0,0 +1,104 @@
+// gomuks - A terminal Matrix client written in Go.
+// Copyright (C) 2026 Tulir Asokan
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+package tui
+
+import (
+	"fmt"
+	"maps"
+	"slices"
+	"strings"
+
+	"maunium.net/go/mautrix/event"
+	"maunium.net/go/mautrix/event/cmdschema"
+
+	"go.mau.fi/gomuks/pkg/hicli/cmdspec"
+	"go.mau.fi/gomuks/pkg/rpc/store"
+)
+
+// BuiltinSnippets are the text transformer commands that are always available. The expansions are markdown,
+// so characters that markdown would eat must be escaped. Snippets in the config override these.
+var BuiltinSnippets = map[string]string{
+	"shrug":     `$text ¯\\\_(ツ)\_/¯`,
+	"tableflip": `$text (╯°□°)╯︵ ┻━┻`,
+	"unflip":    `$text ┬─┬ノ( º _ ºノ)`,
+	"lenny":     `$text ( ͡° ͜ʖ ͡°)`,
+}
+
+// snippetPlaceholder is replaced with the text after the command. A literal $ can be written as $$.
+const snippetPlaceholder = "$text"
+
+// ExpandSnippet substitutes the text after a snippet command into the snippet template.
+// The result is sent through the normal markdown path, so it's escaped with a second slash
+// if it starts with a slash to prevent it from being interpreted as another command.
+func ExpandSnippet(template, text string) string {
+	expanded := strings.NewReplacer("$$", "$", snippetPlaceholder, text).Replace(template)
+	expanded = strings.TrimSpace(expanded)
+	if strings.HasPrefix(expanded, "/") {
+		expanded = "/" + expanded
+	}
+	return expanded
+}
+
+// getSnippet returns the template of the given snippet command, preferring the ones in the config.
+func (view *RoomView) getSnippet(name string) (string, bool) {
+	if template, ok := view.config.Snippets[name]; ok {
+		return template, true
+	}
+	template, ok := BuiltinSnippets[name]
+	return template, ok
+}
+
+func (view *RoomView) snippetCommands(yield func(command *store.WrappedCommand) bool) {
+	names := slices.Collect(maps.Keys(BuiltinSnippets))
+	for name := range view.config.Snippets {
+		if _, isBuiltin := BuiltinSnippets[name]; !isBuiltin {
+			names = append(names, name)
+		}
+	}
+	slices.Sort(names)
+	for _, name := range names {
+		if !yield(&store.WrappedCommand{
+			EventContent: &cmdschema.EventContent{
+				Command:     name,
+				Description: event.MakeExtensibleText(fmt.Sprintf("Send a message using the %s snippet", name)),
+				Parameters: []*cmdschema.Parameter{{
+					Key:         "text",
+					Schema:      cmdschema.PrimitiveTypeString.Schema(),
+					Description: event.MakeExtensibleText("The text to include in the message"),
+					Optional:    true,
+				}},
+				TailParam: "text",
+			},
+			Source: cmdspec.FakeGomuksSender,
+		}) {
+			return
+		}
+	}
+}
+
+// SendSnippet expands the given snippet command and sends the result as a normal markdown message.
+// It returns false if there's no snippet with the given name.
+func (view *RoomView) SendSnippet(name, text string) bool {
+	template, ok := view.getSnippet(name)
+	if !ok {
+		return false
+	}
+	if expanded := ExpandSnippet(template, text); expanded != "" {
+		view.SendMessage(event.MsgText, expanded)
+	}
+	return true
+}
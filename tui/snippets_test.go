@@ -0,0 +1,72 @@
+// gomuks - A terminal Matrix client written in Go.
+// Copyright (C) 2026 Tulir Asokan
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+package tui
+
+import (
+	"strings"
+	"testing"
+
+	"maunium.net/go/mautrix/format"
+)
+
+func TestExpandSnippet(t *testing.T) {
+	tests := []struct {
+		name     string
+		template string
+		text     string
+		want     string
+	}{
+		{"placeholder", "$text!", "hello", "hello!"},
+		{"empty text", "$text ¯\\\\\\_(ツ)\\_/¯", "", "¯\\\\\\_(ツ)\\_/¯"},
+		{"multiple placeholders", "$text and $text", "a", "a and a"},
+		{"no placeholder", "static text", "ignored", "static text"},
+		{"escaped dollar", "costs $$5 $text", "now", "costs $5 now"},
+		{"escaped placeholder", "$$text is $text", "x", "$text is x"},
+		{"placeholder in text isn't expanded", "<$text>", "$text", "<$text>"},
+		{"markdown in template", "**$text**", "loud", "**loud**"},
+		{"text starting with slash", "$text", "/notacommand", "//notacommand"},
+		{"text starting with double slash", "$text", "//already escaped", "///already escaped"},
+		{"template starting with slash", "/me $text", "waves", "//me waves"},
+		{"whitespace is trimmed", "  $text  ", "hi", "hi"},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if got := ExpandSnippet(test.template, test.text); got != test.want {
+				t.Errorf("ExpandSnippet(%q, %q) = %q, want %q", test.template, test.text, got, test.want)
+			}
+		})
+	}
+}
+
+func TestBuiltinSnippets_Markdown(t *testing.T) {
+	tests := map[string]string{
+		"shrug":     `¯\_(ツ)_/¯`,
+		"tableflip": "(╯°□°)╯︵ ┻━┻",
+		"unflip":    "┬─┬ノ( º _ ºノ)",
+		"lenny":     "( ͡° ͜ʖ ͡°)",
+	}
+	for name, emoticon := range tests {
+		t.Run(name, func(t *testing.T) {
+			content := format.RenderMarkdown(ExpandSnippet(BuiltinSnippets[name], "hi *there*"), true, false)
+			if want := "hi _there_ " + emoticon; content.Body != want {
+				t.Errorf("Body = %q, want %q", content.Body, want)
+			} else if !strings.Contains(content.FormattedBody, "<em>there</em> "+emoticon) {
+				t.Errorf("Expected formatted body to contain the formatted text and emoticon, got %q", content.FormattedBody)
+			}
+		})
+	}
+}
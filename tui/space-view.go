@@ -0,0 +1,375 @@
+// gomuks - A terminal Matrix client written in Go.
+// Copyright (C) 2020 Tulir Asokan
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+package tui
+
+import (
+	"cmp"
+	"context"
+	"encoding/json"
+	"fmt"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/gdamore/tcell/v2"
+	"go.mau.fi/mauview"
+	"maunium.net/go/mautrix"
+	"maunium.net/go/mautrix/event"
+	"maunium.net/go/mautrix/id"
+
+	"go.mau.fi/gomuks/pkg/hicli/jsoncmd"
+	"go.mau.fi/gomuks/tui/config"
+	"go.mau.fi/gomuks/tui/debug"
+)
+
+const (
+	spaceHierarchyPageSize = 50
+	spaceViewPageStep      = 10
+)
+
+type spaceViewEntry struct {
+	room  *mautrix.ChildRoomsChunk
+	depth int
+	via   []string
+}
+
+// SpaceView replaces the timeline of space rooms with a navigable tree of the rooms in the space.
+type SpaceView struct {
+	parent *RoomView
+
+	list   *mauview.TextView
+	status *mauview.TextField
+	flex   *mauview.Flex
+
+	lock          sync.Mutex
+	rooms         []*mautrix.ChildRoomsChunk
+	entries       []spaceViewEntry
+	nextBatch     string
+	selected      int
+	suggestedOnly bool
+	loading       bool
+	loaded        bool
+	fallback      bool
+	cancelLoad    context.CancelFunc
+}
+
+func NewSpaceView(parent *RoomView) *SpaceView {
+	sv := &SpaceView{parent: parent}
+	sv.list = mauview.NewTextView().SetRegions(true).SetDynamicColors(true)
+	sv.status = mauview.NewTextField().SetTextColor(tcell.ColorGray)
+	sv.flex = mauview.NewFlex().
+		SetDirection(mauview.FlexRow).
+		AddFixedComponent(sv.status, 1).
+		AddProportionalComponent(sv.list, 1)
+	go sv.load("")
+	return sv
+}
+
+// IsFallback returns true if the hierarchy couldn't be loaded and the normal timeline should be shown instead.
+func (sv *SpaceView) IsFallback() bool {
+	sv.lock.Lock()
+	defer sv.lock.Unlock()
+	return sv.fallback
+}
+
+func (sv *SpaceView) Draw(screen mauview.Screen) {
+	sv.flex.Draw(screen)
+}
+
+// Refresh refetches the space hierarchy from the beginning.
+func (sv *SpaceView) Refresh() {
+	go sv.load("")
+}
+
+// ToggleSuggestedOnly switches between showing all rooms and only suggested rooms, then refetches the hierarchy.
+func (sv *SpaceView) ToggleSuggestedOnly() {
+	sv.lock.Lock()
+	sv.suggestedOnly = !sv.suggestedOnly
+	sv.lock.Unlock()
+	sv.Refresh()
+}
+
+// load fetches a page of the hierarchy. If from is empty, the current rooms are replaced,
+// otherwise the new rooms are appended. Any previous in-flight request is cancelled.
+func (sv *SpaceView) load(from string) {
+	defer debug.Recover()
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	sv.lock.Lock()
+	if sv.cancelLoad != nil {
+		sv.cancelLoad()
+	}
+	sv.cancelLoad = cancel
+	sv.loading = true
+	suggestedOnly := sv.suggestedOnly
+	sv.lock.Unlock()
+	sv.setStatus("Loading space rooms...")
+
+	resp, err := sv.parent.parent.matrix.GetSpaceHierarchy(ctx, &jsoncmd.GetHierarchyParams{
+		RoomID:        sv.parent.Room.ID,
+		From:          from,
+		Limit:         spaceHierarchyPageSize,
+		SuggestedOnly: suggestedOnly,
+	})
+	if ctx.Err() != nil {
+		return
+	}
+
+	sv.lock.Lock()
+	sv.loading = false
+	if err != nil {
+		if !sv.loaded {
+			debug.Print("Failed to get space hierarchy of", sv.parent.Room.ID, "- falling back to timeline:", err)
+			sv.fallback = true
+			sv.lock.Unlock()
+			sv.parent.parent.parent.Render()
+		} else {
+			sv.lock.Unlock()
+			sv.setStatus(fmt.Sprintf("Failed to load space rooms: %v", err))
+		}
+		return
+	}
+	sv.loaded = true
+	if from == "" {
+		sv.rooms = resp.Rooms
+		sv.selected = 0
+	} else {
+		sv.rooms = append(sv.rooms, resp.Rooms...)
+	}
+	sv.nextBatch = resp.NextBatch
+	sv.buildTree()
+	count := len(sv.entries)
+	hasMore := sv.nextBatch != ""
+	sv.lock.Unlock()
+
+	status := fmt.Sprintf("%d rooms", count)
+	if hasMore {
+		status += " (more available)"
+	}
+	if suggestedOnly {
+		status += " - showing suggested rooms only"
+	}
+	sv.setStatus(status)
+	sv.render()
+}
+
+func (sv *SpaceView) setStatus(text string) {
+	sv.status.SetText(text)
+	sv.parent.parent.parent.Render()
+}
+
+func getSpaceChildren(room *mautrix.ChildRoomsChunk) (children []id.RoomID, via map[id.RoomID][]string) {
+	type orderedChild struct {
+		roomID id.RoomID
+		order  string
+	}
+	ordered := make([]orderedChild, 0, len(room.ChildrenState))
+	via = make(map[id.RoomID][]string, len(room.ChildrenState))
+	for _, evt := range room.ChildrenState {
+		if evt.Type != event.StateSpaceChild || evt.StateKey == nil {
+			continue
+		}
+		var content event.SpaceChildEventContent
+		if err := json.Unmarshal(evt.Content.VeryRaw, &content); err != nil || len(content.Via) == 0 {
+			continue
+		}
+		childID := id.RoomID(*evt.StateKey)
+		via[childID] = content.Via
+		ordered = append(ordered, orderedChild{roomID: childID, order: content.Order})
+	}
+	slices.SortStableFunc(ordered, func(a, b orderedChild) int {
+		// Children with an order come first, sorted lexicographically
+		if (a.order == "") != (b.order == "") {
+			if a.order == "" {
+				return 1
+			}
+			return -1
+		}
+		return cmp.Compare(a.order, b.order)
+	})
+	children = make([]id.RoomID, len(ordered))
+	for i, child := range ordered {
+		children[i] = child.roomID
+	}
+	return
+}
+
+// buildTree flattens the loaded hierarchy into a depth-first list of entries. The lock must be held.
+func (sv *SpaceView) buildTree() {
+	byID := make(map[id.RoomID]*mautrix.ChildRoomsChunk, len(sv.rooms))
+	for _, room := range sv.rooms {
+		byID[room.RoomID] = room
+	}
+	sv.entries = sv.entries[:0]
+	visited := make(map[id.RoomID]struct{}, len(sv.rooms))
+	visited[sv.parent.Room.ID] = struct{}{}
+	var walk func(room *mautrix.ChildRoomsChunk, depth int)
+	walk = func(room *mautrix.ChildRoomsChunk, depth int) {
+		children, via := getSpaceChildren(room)
+		for _, childID := range children {
+			if _, alreadyVisited := visited[childID]; alreadyVisited {
+				continue
+			}
+			child, ok := byID[childID]
+			if !ok {
+				// The child may be in a later page or inaccessible
+				continue
+			}
+			visited[childID] = struct{}{}
+			sv.entries = append(sv.entries, spaceViewEntry{room: child, depth: depth, via: via[childID]})
+			walk(child, depth+1)
+		}
+	}
+	if root, ok := byID[sv.parent.Room.ID]; ok {
+		walk(root, 0)
+	}
+	// Include any rooms whose parent hasn't been loaded (yet) at the top level
+	for _, room := range sv.rooms {
+		if _, alreadyVisited := visited[room.RoomID]; !alreadyVisited {
+			visited[room.RoomID] = struct{}{}
+			sv.entries = append(sv.entries, spaceViewEntry{room: room})
+			walk(room, 1)
+		}
+	}
+	if len(sv.entries) > 0 {
+		sv.selected = min(sv.selected, len(sv.entries)-1)
+	}
+}
+
+func (sv *SpaceView) render() {
+	sv.lock.Lock()
+	defer sv.lock.Unlock()
+	sv.list.Clear()
+	for i, entry := range sv.entries {
+		room := entry.room
+		name := room.Name
+		if name == "" {
+			name = room.CanonicalAlias.String()
+		}
+		if name == "" {
+			name = room.RoomID.String()
+		}
+		icon := "#"
+		if room.RoomType == event.RoomTypeSpace {
+			icon = "▸"
+		}
+		status := "[gray]not joined[-]"
+		if existing := sv.parent.parent.matrix.GetRoom(room.RoomID); existing != nil && !existing.Archived {
+			status = "[green]joined[-]"
+		}
+		indent := strings.Repeat("  ", entry.depth)
+		_, _ = fmt.Fprintf(
+			sv.list, `["%d"]%s%s %s (%d members, %s)`,
+			i, indent, icon, mauview.Escape(name), room.NumJoinedMembers, status,
+		)
+		if topic := truncateTopic(room.Topic); topic != "" {
+			_, _ = fmt.Fprintf(sv.list, "\n%s  [gray]%s[-]", indent, mauview.Escape(topic))
+		}
+		_, _ = fmt.Fprint(sv.list, `[""]`+"\n")
+	}
+	if len(sv.entries) > 0 {
+		sv.list.Highlight(strconv.Itoa(sv.selected))
+		sv.list.ScrollToHighlight()
+	} else {
+		sv.list.Highlight()
+	}
+	sv.parent.parent.parent.Render()
+}
+
+func (sv *SpaceView) moveSelection(diff int) {
+	sv.lock.Lock()
+	if len(sv.entries) == 0 {
+		sv.lock.Unlock()
+		return
+	}
+	sv.selected = max(0, min(sv.selected+diff, len(sv.entries)-1))
+	sv.list.Highlight(strconv.Itoa(sv.selected))
+	sv.list.ScrollToHighlight()
+	// Load the next page when the selection gets close to the end of the list
+	shouldLoadMore := sv.selected >= len(sv.entries)-3 && sv.nextBatch != "" && !sv.loading
+	nextBatch := sv.nextBatch
+	sv.lock.Unlock()
+	if shouldLoadMore {
+		go sv.load(nextBatch)
+	}
+}
+
+func (sv *SpaceView) getSelected() *spaceViewEntry {
+	sv.lock.Lock()
+	defer sv.lock.Unlock()
+	if sv.selected < 0 || sv.selected >= len(sv.entries) {
+		return nil
+	}
+	entry := sv.entries[sv.selected]
+	return &entry
+}
+
+// activate switches to the given room if it's already joined, or joins it otherwise.
+func (sv *SpaceView) activate(entry *spaceViewEntry) {
+	defer debug.Recover()
+	mainView := sv.parent.parent
+	if existing := mainView.matrix.GetRoom(entry.room.RoomID); existing != nil && !existing.Archived {
+		mainView.SwitchRoom(entry.room.RoomID)
+		return
+	}
+	sv.setStatus("Joining room...")
+	_, err := mainView.matrix.JoinRoom(context.TODO(), &jsoncmd.JoinRoomParams{
+		RoomIDOrAlias: entry.room.RoomID.String(),
+		Via:           entry.via,
+	})
+	if err != nil {
+		sv.setStatus(fmt.Sprintf("Failed to join room: %v", err))
+		return
+	}
+	mainView.SwitchRoomWhenJoined(entry.room.RoomID)
+}
+
+func (sv *SpaceView) OnKeyEvent(event mauview.KeyEvent) bool {
+	kb := config.Keybind{
+		Key: event.Key(),
+		Ch:  event.Rune(),
+		Mod: event.Modifiers(),
+	}
+	switch sv.parent.config.Keybindings.Room[kb] {
+	case "space_refresh":
+		sv.Refresh()
+		return true
+	case "space_suggested":
+		sv.ToggleSuggestedOnly()
+		return true
+	}
+	switch event.Key() {
+	case tcell.KeyUp:
+		sv.moveSelection(-1)
+	case tcell.KeyDown:
+		sv.moveSelection(1)
+	case tcell.KeyPgUp:
+		sv.moveSelection(-spaceViewPageStep)
+	case tcell.KeyPgDn:
+		sv.moveSelection(spaceViewPageStep)
+	case tcell.KeyEnter:
+		if sv.parent.input.GetText() != "" {
+			return false
+		} else if entry := sv.getSelected(); entry != nil {
+			go sv.activate(entry)
+		}
+	default:
+		return false
+	}
+	return true
+}
@@ -0,0 +1,278 @@
+// gomuks - A terminal Matrix client written in Go.
+// Copyright (C) 2026 Tulir Asokan
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+package tui
+
+import (
+	"github.com/gdamore/tcell/v2"
+	"go.mau.fi/mauview"
+	"maunium.net/go/mautrix/id"
+
+	"go.mau.fi/gomuks/tui/debug"
+)
+
+// SplitPane holds the room view boxes. Normally only the first pane is shown, but in split mode the
+// panes are drawn side by side with a border between them. Input events only go to the active pane.
+type SplitPane struct {
+	panes   [2]*mauview.Box
+	screens [2]*mauview.ProxyScreen
+	split   bool
+	active  int
+	focused bool
+
+	prevScreen mauview.Screen
+
+	// onActivate is called when the user clicks on the inactive pane.
+	onActivate func()
+}
+
+func NewSplitPane(split bool, onActivate func()) *SplitPane {
+	return &SplitPane{
+		panes: [2]*mauview.Box{
+			mauview.NewBox(nil).SetBorder(false),
+			mauview.NewBox(nil).SetBorder(false),
+		},
+		screens: [2]*mauview.ProxyScreen{
+			{OffsetX: 0, OffsetY: 0},
+			{OffsetY: 0},
+		},
+		split:      split,
+		onActivate: onActivate,
+	}
+}
+
+// IsSplit returns true if both panes are visible.
+func (sp *SplitPane) IsSplit() bool {
+	return sp.split
+}
+
+// SetSplit shows or hides the second pane. When the split is closed, the first pane becomes active.
+func (sp *SplitPane) SetSplit(split bool) {
+	sp.split = split
+	if !split && sp.active != 0 {
+		sp.SetActive(0)
+	}
+}
+
+// Active returns the box of the pane that receives input.
+func (sp *SplitPane) Active() *mauview.Box {
+	return sp.panes[sp.active]
+}
+
+// Inactive returns the box of the pane that doesn't receive input.
+func (sp *SplitPane) Inactive() *mauview.Box {
+	return sp.panes[1-sp.active]
+}
+
+// Secondary returns the box of the right pane.
+func (sp *SplitPane) Secondary() *mauview.Box {
+	return sp.panes[1]
+}
+
+// SetActive changes which pane receives input.
+func (sp *SplitPane) SetActive(index int) {
+	if sp.active == index {
+		return
+	}
+	if sp.focused {
+		sp.panes[sp.active].Blur()
+	}
+	sp.active = index
+	if sp.focused {
+		sp.panes[sp.active].Focus()
+	}
+}
+
+// Toggle switches the active pane.
+func (sp *SplitPane) Toggle() {
+	sp.SetActive(1 - sp.active)
+}
+
+func (sp *SplitPane) Draw(screen mauview.Screen) {
+	if !sp.split {
+		sp.panes[0].Draw(screen)
+		return
+	}
+	width, height := screen.Size()
+	if sp.prevScreen != screen {
+		sp.screens[0].Parent = screen
+		sp.screens[1].Parent = screen
+		sp.prevScreen = screen
+	}
+	leftWidth := (width - 1) / 2
+	sp.screens[0].Width = leftWidth
+	sp.screens[0].Height = height
+	sp.screens[1].OffsetX = leftWidth + 1
+	sp.screens[1].Width = width - leftWidth - 1
+	sp.screens[1].Height = height
+	sp.panes[0].Draw(sp.screens[0])
+	sp.panes[1].Draw(sp.screens[1])
+
+	borderStyle := tcell.StyleDefault.Foreground(mauview.Styles.BorderColor)
+	for y := 0; y < height; y++ {
+		screen.SetContent(leftWidth, y, mauview.Borders.Vertical, nil, borderStyle)
+	}
+	// Point the border towards the pane that receives input
+	indicator := '◀'
+	if sp.active == 1 {
+		indicator = '▶'
+	}
+	indicatorStyle := tcell.StyleDefault.Foreground(tcell.ColorGreen)
+	screen.SetContent(leftWidth, 0, indicator, nil, indicatorStyle)
+	screen.SetContent(leftWidth, height/2, indicator, nil, indicatorStyle)
+}
+
+func (sp *SplitPane) OnKeyEvent(event mauview.KeyEvent) bool {
+	return sp.Active().OnKeyEvent(event)
+}
+
+func (sp *SplitPane) OnPasteEvent(event mauview.PasteEvent) bool {
+	return sp.Active().OnPasteEvent(event)
+}
+
+func (sp *SplitPane) OnMouseEvent(event mauview.MouseEvent) bool {
+	if !sp.split {
+		return sp.panes[0].OnMouseEvent(event)
+	}
+	for i, screen := range sp.screens {
+		if !screen.IsInArea(event.Position()) {
+			continue
+		}
+		if i != sp.active && event.Buttons() == tcell.Button1 && !event.HasMotion() && sp.onActivate != nil {
+			sp.onActivate()
+		}
+		return sp.panes[i].OnMouseEvent(screen.OffsetMouseEvent(event))
+	}
+	return false
+}
+
+func (sp *SplitPane) Focus() {
+	sp.focused = true
+	sp.Active().Focus()
+}
+
+func (sp *SplitPane) Blur() {
+	sp.focused = false
+	sp.Active().Blur()
+}
+
+// ToggleSplit opens or closes the second room pane. When opening, the pane shows the room it had
+// previously, or the most recent room other than the current one.
+func (view *MainView) ToggleSplit() {
+	if view.split.IsSplit() {
+		view.closeSplit()
+	} else {
+		view.split.SetSplit(true)
+		roomID := view.config.SplitPaneRoom
+		if roomID == "" || !view.roomExists(roomID) || view.isCurrentRoom(roomID) {
+			roomID = ""
+			for _, recentRoomID := range view.history.Recent(view.roomExists) {
+				if !view.isCurrentRoom(recentRoomID) {
+					roomID = recentRoomID
+					break
+				}
+			}
+		}
+		view.openOtherPane(roomID)
+	}
+	view.saveSplitState()
+	view.parent.Render()
+}
+
+// closeSplit closes the unfocused pane and moves the focused room to the first pane.
+func (view *MainView) closeSplit() {
+	if view.otherRoom != nil {
+		view.otherRoom.Unload()
+		view.otherRoom = nil
+	}
+	view.split.Inactive().SetInnerComponent(nil)
+	if view.split.Active() == view.split.Secondary() {
+		view.split.Secondary().SetInnerComponent(nil)
+		view.split.SetSplit(false)
+		if view.currentRoom != nil {
+			view.split.Active().SetInnerComponent(view.currentRoom)
+		}
+	} else {
+		view.split.SetSplit(false)
+	}
+	view.roomView = view.split.Active()
+	view.roomView.Focus()
+}
+
+// SwitchPane moves focus to the other pane when the split pane mode is enabled.
+// The room in the focused pane is treated as the current room for marking as read and notifications.
+func (view *MainView) SwitchPane() {
+	if !view.split.IsSplit() {
+		return
+	}
+	view.StopTyping()
+	view.split.Toggle()
+	view.roomView = view.split.Active()
+	view.currentRoom, view.otherRoom = view.otherRoom, view.currentRoom
+	if view.currentRoom != nil {
+		view.roomList.SetSelected(view.currentRoom.Room.ID)
+		view.announceRoom(view.currentRoom)
+		view.MarkReadIfActive(view.currentRoom)
+	} else {
+		view.roomList.SetSelected("")
+	}
+	view.parent.Render()
+}
+
+func (view *MainView) isCurrentRoom(roomID id.RoomID) bool {
+	return view.currentRoom != nil && view.currentRoom.Room.ID == roomID
+}
+
+// openOtherPane shows the given room in the unfocused pane.
+func (view *MainView) openOtherPane(roomID id.RoomID) {
+	if roomID == "" || view.isCurrentRoom(roomID) {
+		return
+	}
+	roomData := view.getRoomData(roomID)
+	if roomData == nil {
+		debug.Print("Tried to open nonexistent room in split pane!", roomID)
+		return
+	}
+	if view.otherRoom != nil {
+		view.otherRoom.Unload()
+	}
+	view.otherRoom = view.openRoomView(roomData)
+	view.split.Inactive().SetInnerComponent(view.otherRoom)
+}
+
+// SplitPaneRoom returns the ID of the room in the right pane, or an empty string if the split pane
+// mode is disabled or the pane is empty.
+func (view *MainView) SplitPaneRoom() id.RoomID {
+	if !view.split.IsSplit() {
+		return ""
+	}
+	roomView := view.otherRoom
+	if view.split.Active() == view.split.Secondary() {
+		roomView = view.currentRoom
+	}
+	if roomView == nil {
+		return ""
+	}
+	return roomView.Room.ID
+}
+
+func (view *MainView) saveSplitState() {
+	view.config.SplitPane = view.split.IsSplit()
+	if roomID := view.SplitPaneRoom(); roomID != "" {
+		view.config.SplitPaneRoom = roomID
+	}
+	view.config.Save()
+}
@@ -0,0 +1,412 @@
+// gomuks - A terminal Matrix client written in Go.
+// Copyright (C) 2026 Tulir Asokan
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+package tui
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/gdamore/tcell/v2"
+	"go.mau.fi/mauview"
+
+	"go.mau.fi/gomuks/pkg/hicli/jsoncmd"
+	"go.mau.fi/gomuks/tui/config"
+	"go.mau.fi/gomuks/tui/debug"
+)
+
+type threePIDRowType int
+
+const (
+	threePIDRowAddress threePIDRowType = iota
+	threePIDRowAdd
+	threePIDRowFinish
+	threePIDRowResend
+)
+
+type threePIDRow struct {
+	rowType threePIDRowType
+	pid     *jsoncmd.ThreePID
+}
+
+type ThreePIDModal struct {
+	mauview.Component
+
+	container *mauview.Box
+	list      *mauview.TextView
+	status    *mauview.TextField
+
+	lock     sync.Mutex
+	pids     []*jsoncmd.ThreePID
+	loaded   bool
+	rows     []threePIDRow
+	selected int
+	busy     bool
+	// confirmDelete is the address that will be removed if the row is confirmed again.
+	confirmDelete *jsoncmd.ThreePID
+	// pending is the email address that a validation email has been sent to.
+	pending       string
+	pendingCode   bool
+	pendingExpiry bool
+	password      string
+
+	parent *MainView
+}
+
+func NewThreePIDModal(parent *MainView) *ThreePIDModal {
+	tm := &ThreePIDModal{parent: parent}
+
+	tm.list = mauview.NewTextView().SetRegions(true).SetDynamicColors(true)
+	tm.status = mauview.NewTextField()
+
+	flex := mauview.NewFlex().
+		SetDirection(mauview.FlexRow).
+		AddProportionalComponent(tm.list, 1).
+		AddFixedComponent(tm.status, 1)
+
+	tm.container = mauview.NewBox(flex).
+		SetBorder(true).
+		SetTitle("Email addresses and phone numbers").
+		SetBlurCaptureFunc(func() bool {
+			tm.close()
+			return true
+		})
+	tm.Component = mauview.Center(tm.container, 70, 20).SetAlwaysFocusChild(true)
+
+	tm.lock.Lock()
+	tm.renderLocked()
+	tm.lock.Unlock()
+	go tm.load()
+
+	return tm
+}
+
+func (tm *ThreePIDModal) Focus() {
+	tm.container.Focus()
+}
+
+func (tm *ThreePIDModal) Blur() {
+	tm.container.Blur()
+}
+
+func (tm *ThreePIDModal) close() {
+	tm.parent.HideModal()
+}
+
+func (tm *ThreePIDModal) setStatus(color tcell.Color, text string) {
+	tm.status.SetTextColor(color).SetText(text)
+	tm.parent.parent.Render()
+}
+
+func (tm *ThreePIDModal) load() {
+	defer debug.Recover()
+	pids, err := tm.parent.matrix.Get3PIDs(context.TODO())
+	if err != nil {
+		tm.setStatus(tcell.ColorRed, fmt.Sprintf("Failed to load addresses: %v", err))
+		return
+	}
+	tm.lock.Lock()
+	tm.pids = pids
+	tm.loaded = true
+	tm.renderLocked()
+	tm.lock.Unlock()
+	tm.parent.parent.Render()
+}
+
+func (tm *ThreePIDModal) renderLocked() {
+	tm.list.Clear()
+	tm.rows = tm.rows[:0]
+	if !tm.loaded {
+		_, _ = fmt.Fprint(tm.list, "[gray]Loading...[-]\n")
+	} else if len(tm.pids) == 0 {
+		_, _ = fmt.Fprint(tm.list, "[gray]No email addresses or phone numbers are linked to your account.[-]\n")
+	}
+	for _, pid := range tm.pids {
+		_, _ = fmt.Fprintf(tm.list, `["%d"]%s[""] [gray](%s, added %s)[-]`, len(tm.rows), mauview.Escape(pid.Address), pid.Medium, pid.AddedAt.Format("2006-01-02"))
+		if tm.confirmDelete == pid {
+			_, _ = fmt.Fprint(tm.list, " [red](confirm again to remove)[-]")
+		}
+		_, _ = fmt.Fprint(tm.list, "\n")
+		tm.rows = append(tm.rows, threePIDRow{rowType: threePIDRowAddress, pid: pid})
+	}
+	_, _ = fmt.Fprint(tm.list, "\n")
+	if tm.pending != "" {
+		if tm.pendingExpiry {
+			_, _ = fmt.Fprintf(tm.list, `["%d"]Resend validation email to %s[""]`+"\n", len(tm.rows), mauview.Escape(tm.pending))
+			tm.rows = append(tm.rows, threePIDRow{rowType: threePIDRowResend})
+		} else {
+			label := "Finish adding %s (after opening the link in the email)"
+			if tm.pendingCode {
+				label = "Enter the code sent to %s"
+			}
+			_, _ = fmt.Fprintf(tm.list, `["%d"]`+label+`[""]`+"\n", len(tm.rows), mauview.Escape(tm.pending))
+			tm.rows = append(tm.rows, threePIDRow{rowType: threePIDRowFinish})
+			_, _ = fmt.Fprintf(tm.list, `["%d"]Resend validation email[""]`+"\n", len(tm.rows))
+			tm.rows = append(tm.rows, threePIDRow{rowType: threePIDRowResend})
+		}
+	}
+	_, _ = fmt.Fprintf(tm.list, `["%d"]Add email address[""]`+"\n", len(tm.rows))
+	tm.rows = append(tm.rows, threePIDRow{rowType: threePIDRowAdd})
+	tm.selected = max(0, min(tm.selected, len(tm.rows)-1))
+	tm.list.Highlight(strconv.Itoa(tm.selected))
+	tm.list.ScrollToHighlight()
+}
+
+func (tm *ThreePIDModal) moveSelection(diff int) {
+	tm.lock.Lock()
+	defer tm.lock.Unlock()
+	tm.selected = max(0, min(tm.selected+diff, len(tm.rows)-1))
+	tm.list.Highlight(strconv.Itoa(tm.selected))
+	tm.list.ScrollToHighlight()
+}
+
+func (tm *ThreePIDModal) confirm() {
+	tm.lock.Lock()
+	defer tm.lock.Unlock()
+	if tm.selected < 0 || tm.selected >= len(tm.rows) {
+		return
+	} else if tm.busy {
+		tm.setStatus(tcell.ColorYellow, "Please wait for the previous request to finish")
+		return
+	}
+	row := tm.rows[tm.selected]
+	if row.rowType != threePIDRowAddress {
+		tm.confirmDelete = nil
+	}
+	switch row.rowType {
+	case threePIDRowAddress:
+		if tm.confirmDelete != row.pid {
+			tm.confirmDelete = row.pid
+			tm.renderLocked()
+			tm.setStatus(tcell.ColorDefault, fmt.Sprintf("Confirm again to remove %s from your account", row.pid.Address))
+			return
+		}
+		tm.confirmDelete = nil
+		tm.busy = true
+		go tm.delete(row.pid)
+	case threePIDRowAdd:
+		tm.busy = true
+		go tm.askEmail()
+	case threePIDRowFinish:
+		tm.busy = true
+		go tm.finish(tm.pending, tm.pendingCode)
+	case threePIDRowResend:
+		tm.busy = true
+		go tm.sendValidation(tm.pending, true)
+	}
+}
+
+func (tm *ThreePIDModal) done() {
+	tm.lock.Lock()
+	tm.busy = false
+	tm.renderLocked()
+	tm.lock.Unlock()
+	tm.parent.parent.Render()
+}
+
+// ask shows a text prompt in place of this modal and switches back to this modal afterwards.
+func (tm *ThreePIDModal) ask(title, thing, placeholder string, password bool) (string, bool) {
+	var value string
+	var ok bool
+	if password {
+		value, ok = tm.parent.AskPassword(title, thing, "", false)
+	} else {
+		value, ok = tm.parent.AskText(title, thing, placeholder)
+		value = strings.TrimSpace(value)
+	}
+	tm.parent.ShowModal(tm)
+	tm.parent.parent.Render()
+	return value, ok && value != ""
+}
+
+func (tm *ThreePIDModal) delete(pid *jsoncmd.ThreePID) {
+	defer debug.Recover()
+	defer tm.done()
+	tm.setStatus(tcell.ColorDefault, fmt.Sprintf("Removing %s...", pid.Address))
+	err := tm.parent.matrix.Delete3PID(context.TODO(), &jsoncmd.Delete3PIDParams{
+		Medium:  pid.Medium,
+		Address: pid.Address,
+	})
+	if err != nil {
+		tm.setStatus(tcell.ColorRed, fmt.Sprintf("Failed to remove %s: %v", pid.Address, err))
+		return
+	}
+	tm.setStatus(tcell.ColorGreen, fmt.Sprintf("Removed %s", pid.Address))
+	tm.load()
+}
+
+func (tm *ThreePIDModal) askEmail() {
+	defer debug.Recover()
+	email, ok := tm.ask("Add email address", "email address", "user@example.com", false)
+	if !ok {
+		tm.done()
+		return
+	}
+	tm.sendValidation(email, false)
+}
+
+func (tm *ThreePIDModal) sendValidation(email string, resend bool) {
+	defer debug.Recover()
+	tm.setStatus(tcell.ColorDefault, fmt.Sprintf("Sending validation email to %s...", email))
+	resp, err := tm.parent.matrix.Add3PIDEmail(context.TODO(), &jsoncmd.Add3PIDEmailParams{
+		Email:  email,
+		Resend: resend,
+	})
+	if err != nil {
+		tm.setStatus(tcell.ColorRed, fmt.Sprintf("Failed to send validation email: %v", err))
+		tm.done()
+		return
+	}
+	if !tm.handleResponse(email, resp) || !resp.CodeSupported {
+		tm.done()
+		return
+	}
+	tm.finish(email, true)
+}
+
+// finish completes the validation and adds the address to the account,
+// prompting for the validation code and account password as necessary.
+func (tm *ThreePIDModal) finish(email string, askCode bool) {
+	defer debug.Recover()
+	defer tm.done()
+	params := &jsoncmd.Add3PIDEmailParams{Email: email}
+	for {
+		if askCode {
+			var ok bool
+			params.Code, ok = tm.ask("Validate email address", "code from the email", "123456", false)
+			if !ok {
+				return
+			}
+		}
+		if tm.password == "" {
+			var ok bool
+			tm.password, ok = tm.ask("Add email address", "account password", "", true)
+			if !ok {
+				return
+			}
+		}
+		params.Password = tm.password
+		tm.setStatus(tcell.ColorDefault, fmt.Sprintf("Adding %s...", email))
+		resp, err := tm.parent.matrix.Add3PIDEmail(context.TODO(), params)
+		if err != nil {
+			tm.setStatus(tcell.ColorRed, fmt.Sprintf("Failed to add %s: %v", email, err))
+			return
+		}
+		if resp.Status == jsoncmd.Add3PIDAuthFailed {
+			msg, wrongPassword := uiaFailureMessage(&jsoncmd.UIAResponse{UIA: resp.UIA})
+			tm.password = ""
+			tm.setStatus(tcell.ColorRed, fmt.Sprintf("Failed to add %s: %s", email, msg))
+			if wrongPassword {
+				// The code has already been accepted at this point, so only the password needs to be asked again
+				askCode = false
+				continue
+			}
+			return
+		}
+		if !tm.handleResponse(email, resp) {
+			return
+		}
+		askCode = resp.Status == jsoncmd.Add3PIDInvalidCode
+		if !askCode {
+			return
+		}
+	}
+}
+
+// handleResponse updates the pending validation state and status text based on the response.
+// It returns true if the validation is still pending.
+func (tm *ThreePIDModal) handleResponse(email string, resp *jsoncmd.Add3PIDResponse) bool {
+	var color tcell.Color
+	var text string
+	pending := true
+	tm.lock.Lock()
+	tm.pendingCode = resp.CodeSupported
+	tm.pendingExpiry = false
+	switch resp.Status {
+	case jsoncmd.Add3PIDValidationSent:
+		color = tcell.ColorDefault
+		if resp.CodeSupported {
+			text = fmt.Sprintf("Validation email sent to %s, enter the code from it to continue", email)
+		} else {
+			text = fmt.Sprintf("Validation email sent to %s, open the link in it and then select finish", email)
+		}
+	case jsoncmd.Add3PIDNotValidated:
+		color = tcell.ColorYellow
+		text = "The address hasn't been validated yet, open the link in the email first"
+	case jsoncmd.Add3PIDInvalidCode:
+		color = tcell.ColorRed
+		text = "The validation code was incorrect, check the email and try again"
+	case jsoncmd.Add3PIDSessionExpired:
+		color = tcell.ColorYellow
+		text = "The validation took too long, request a new validation email to try again"
+		tm.pendingExpiry = true
+	case jsoncmd.Add3PIDInUse:
+		color = tcell.ColorRed
+		text = fmt.Sprintf("%s is already linked to another account", email)
+		pending = false
+	case jsoncmd.Add3PIDAdded, jsoncmd.Add3PIDBound:
+		color = tcell.ColorGreen
+		text = fmt.Sprintf("Added %s to your account", email)
+		if resp.BindError != "" {
+			color = tcell.ColorYellow
+			text = fmt.Sprintf("Added %s, but binding it on the identity server failed: %s", email, resp.BindError)
+		}
+		pending = false
+	default:
+		color = tcell.ColorRed
+		text = fmt.Sprintf("Unexpected response status %q", resp.Status)
+		pending = false
+	}
+	if pending {
+		tm.pending = email
+	} else {
+		tm.pending = ""
+	}
+	expired := tm.pendingExpiry
+	tm.renderLocked()
+	tm.lock.Unlock()
+	tm.setStatus(color, text)
+	if resp.Status == jsoncmd.Add3PIDAdded || resp.Status == jsoncmd.Add3PIDBound {
+		tm.load()
+	}
+	return pending && !expired
+}
+
+func (tm *ThreePIDModal) OnKeyEvent(event mauview.KeyEvent) bool {
+	kb := config.Keybind{
+		Key: event.Key(),
+		Ch:  event.Rune(),
+		Mod: event.Modifiers(),
+	}
+	switch tm.parent.config.Keybindings.Modal[kb] {
+	case "cancel":
+		tm.close()
+		return true
+	case "select_next":
+		tm.moveSelection(1)
+		return true
+	case "select_prev":
+		tm.moveSelection(-1)
+		return true
+	case "confirm":
+		tm.confirm()
+		return true
+	}
+	return false
+}
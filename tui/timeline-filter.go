@@ -0,0 +1,159 @@
+// gomuks - A terminal Matrix client written in Go.
+// Copyright (C) 2026 Tulir Asokan
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+package tui
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"sync/atomic"
+
+	"maunium.net/go/mautrix/event"
+	"maunium.net/go/mautrix/id"
+
+	"go.mau.fi/gomuks/pkg/hicli/database"
+	"go.mau.fi/gomuks/tui/debug"
+)
+
+type TimelineFilterMode string
+
+const (
+	FilterMedia    TimelineFilterMode = "media"
+	FilterMentions TimelineFilterMode = "mentions"
+	FilterFrom     TimelineFilterMode = "from"
+	FilterText     TimelineFilterMode = "text"
+)
+
+const (
+	// minFilterMatches is how many matching messages the timeline filter tries to find by loading more history.
+	minFilterMatches = 20
+	// maxFilterPaginationRequests limits how many history requests are made to find matching messages.
+	maxFilterPaginationRequests = 10
+)
+
+// TimelineFilter limits the messages shown in a room's message view.
+type TimelineFilter struct {
+	Mode  TimelineFilterMode
+	Query string
+
+	// shown is the number of matching messages in the message buffer, updated when the buffer is rebuilt.
+	shown atomic.Int32
+}
+
+func NewTimelineFilter(mode TimelineFilterMode, query string) (*TimelineFilter, error) {
+	query = strings.TrimSpace(query)
+	switch mode {
+	case FilterMedia, FilterMentions:
+	case FilterFrom:
+		if _, _, err := id.UserID(query).Parse(); err != nil {
+			return nil, fmt.Errorf("invalid user ID %q", query)
+		}
+	case FilterText:
+		if query == "" {
+			return nil, fmt.Errorf("text filter requires a query")
+		}
+		query = strings.ToLower(query)
+	default:
+		return nil, fmt.Errorf("unknown filter mode %q", mode)
+	}
+	return &TimelineFilter{Mode: mode, Query: query}, nil
+}
+
+// Match checks whether the given event should be shown while the filter is active.
+func (filter *TimelineFilter) Match(evt *database.Event) bool {
+	if evt == nil || evt.RelationType == event.RelReplace || evt.RedactedBy != "" {
+		return false
+	}
+	evtType := evt.GetType()
+	if evtType != event.EventMessage && evtType != event.EventSticker {
+		return false
+	}
+	switch filter.Mode {
+	case FilterMedia:
+		if evtType == event.EventSticker {
+			return true
+		}
+		switch evt.GetMautrixContent().AsMessage().MsgType {
+		case event.MsgImage, event.MsgVideo, event.MsgAudio, event.MsgFile:
+			return true
+		}
+		return false
+	case FilterMentions:
+		return evt.UnreadType.Is(database.UnreadTypeHighlight)
+	case FilterFrom:
+		return evt.Sender == id.UserID(filter.Query)
+	case FilterText:
+		return strings.Contains(strings.ToLower(evt.GetMautrixContent().AsMessage().Body), filter.Query)
+	default:
+		return false
+	}
+}
+
+// Status returns the status bar indicator for the filter.
+func (filter *TimelineFilter) Status() string {
+	desc := string(filter.Mode)
+	if filter.Query != "" {
+		desc = fmt.Sprintf("%s %s", desc, filter.Query)
+	}
+	return fmt.Sprintf("Filter: %s (%d shown)", desc, filter.shown.Load())
+}
+
+// countMatches returns the number of events in the loaded timeline that match the filter.
+func (filter *TimelineFilter) countMatches(timeline []*database.Event) (count int) {
+	for _, evt := range timeline {
+		if filter.Match(evt) {
+			count++
+		}
+	}
+	return
+}
+
+// SetTimelineFilter applies the filter to the message view and loads more history until enough matching
+// messages are found.
+func (view *RoomView) SetTimelineFilter(mode TimelineFilterMode, query string) {
+	filter, err := NewTimelineFilter(mode, query)
+	if err != nil {
+		view.AddServiceMessage("Invalid filter: %v", err)
+		return
+	}
+	view.MessageView().SetFilter(filter)
+	go view.loadFilterHistory(filter)
+}
+
+// ClearTimelineFilter removes the timeline filter and restores the scroll position from before it was applied.
+func (view *RoomView) ClearTimelineFilter() bool {
+	return view.MessageView().ClearFilter()
+}
+
+func (view *RoomView) loadFilterHistory(filter *TimelineFilter) {
+	defer debug.Recover()
+	for range maxFilterPaginationRequests {
+		if view.MessageView().GetFilter() != filter || !view.Room.HasMoreHistory() {
+			return
+		}
+		timeline := view.Room.TimelineCache.Current()
+		if timeline != nil && filter.countMatches(*timeline) >= minFilterMatches {
+			return
+		}
+		err := view.parent.matrix.LoadMoreHistory(context.TODO(), view.Room.ID)
+		if err != nil {
+			debug.Print("Failed to load history for timeline filter in", view.Room.ID, err)
+			return
+		}
+		view.parent.parent.Render()
+	}
+}
@@ -0,0 +1,173 @@
+// gomuks - A terminal Matrix client written in Go.
+// Copyright (C) 2026 Tulir Asokan
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+package tui
+
+import (
+	"strings"
+
+	"github.com/gdamore/tcell/v2"
+	"github.com/mattn/go-runewidth"
+	"go.mau.fi/mauview"
+	"maunium.net/go/mautrix/event"
+
+	"go.mau.fi/gomuks/tui/messages/html"
+	"go.mau.fi/gomuks/tui/widget"
+)
+
+const topicEllipsis = "…"
+
+// truncateTopicToWidth cuts the topic bar text to fit in the given width, replacing the end with an ellipsis
+// if it doesn't fit. The returned bool is true if the text was truncated.
+func truncateTopicToWidth(text string, width int) (string, bool) {
+	if runewidth.StringWidth(text) <= width {
+		return text, false
+	} else if width <= 0 {
+		return "", true
+	}
+	return runewidth.Truncate(text, width, topicEllipsis), true
+}
+
+// expandedTopic is the full room topic shown over the top of the timeline.
+type expandedTopic struct {
+	// content is the parsed topic. It's cleared when the topic changes, so that it's parsed again on the next draw.
+	content html.Entity
+	// width is the width that the content buffer was last calculated for.
+	width  int
+	scroll int
+}
+
+// ToggleTopic expands or collapses the topic bar.
+func (view *RoomView) ToggleTopic() {
+	if view.topicExpanded != nil {
+		view.topicExpanded = nil
+	} else {
+		view.topicExpanded = &expandedTopic{}
+	}
+}
+
+// invalidateTopic makes the expanded topic get parsed again after the topic changes.
+func (view *RoomView) invalidateTopic() {
+	if expanded := view.topicExpanded; expanded != nil {
+		expanded.content = nil
+	}
+}
+
+func (view *RoomView) parseTopic() html.Entity {
+	evt := view.Room.GetStateEvent(event.StateTopic, "")
+	if evt == nil {
+		return html.NewTextEntity("This room has no topic")
+	}
+	content := evt.GetMautrixContent().AsTopic()
+	root := html.ParseTopic(&view.config.Preferences, view.Room, evt, content)
+	if root == nil || root.IsEmpty() {
+		return html.NewTextEntity("This room has no topic")
+	}
+	return root
+}
+
+// topicOverlayHeight returns the height of the expanded topic including the bottom border, or 0 if it's collapsed.
+// At most two thirds of the timeline are covered, and the rest of the topic can be scrolled.
+func (view *RoomView) topicOverlayHeight(width, contentHeight int) int {
+	expanded := view.topicExpanded
+	if expanded == nil || width <= 0 || contentHeight <= 1 {
+		return 0
+	}
+	if expanded.content == nil {
+		expanded.content = view.parseTopic()
+		expanded.width = 0
+	}
+	if expanded.width != width {
+		expanded.content.CalculateBuffer(width, 0, view.topicDrawContext())
+		expanded.width = width
+	}
+	return min(expanded.content.Height()+1, max(contentHeight*2/3, 2))
+}
+
+func (view *RoomView) topicDrawContext() html.DrawContext {
+	return html.DrawContext{
+		RevealSpoilers: view.config.Preferences.RevealSpoilers,
+		EmojiDisplay:   view.config.Preferences.GetEmojiDisplay(),
+	}
+}
+
+// scrollTopic scrolls the expanded topic by the given number of lines and keeps the scroll within the content.
+func (view *RoomView) scrollTopic(lines int) {
+	expanded := view.topicExpanded
+	if expanded == nil || expanded.content == nil {
+		return
+	}
+	visibleLines := view.topicOverlay.Height - 1
+	expanded.scroll = max(min(expanded.scroll+lines, expanded.content.Height()-visibleLines), 0)
+}
+
+func (view *RoomView) drawExpandedTopic(screen mauview.Screen) {
+	expanded := view.topicExpanded
+	width, height := screen.Size()
+	if expanded == nil || expanded.content == nil || height < 2 {
+		return
+	}
+	visibleLines := height - 1
+	expanded.scroll = max(min(expanded.scroll, expanded.content.Height()-visibleLines), 0)
+	screen.Clear()
+	content := &mauview.ProxyScreen{Parent: screen, OffsetY: -expanded.scroll, Width: width, Height: visibleLines + expanded.scroll}
+	expanded.content.Draw(content, view.topicDrawContext())
+
+	hint := " Esc to close "
+	if expanded.content.Height() > visibleLines {
+		hint = " Esc to close, PgUp/PgDn to scroll "
+	}
+	borderStyle := tcell.StyleDefault.Foreground(tcell.ColorDarkGreen)
+	for x := 0; x < width; x++ {
+		screen.SetContent(x, visibleLines, '─', nil, borderStyle)
+	}
+	if runewidth.StringWidth(hint)+2 <= width {
+		widget.WriteLineSimpleColor(screen, hint, width-runewidth.StringWidth(hint)-1, visibleLines, tcell.ColorGray)
+	}
+}
+
+// onExpandedTopicKey handles keybindings while the topic is expanded.
+func (view *RoomView) onExpandedTopicKey(action string) bool {
+	switch action {
+	case "clear", "toggle_topic":
+		view.topicExpanded = nil
+	case "scroll_up":
+		view.scrollTopic(-view.topicOverlay.Height / 2)
+	case "scroll_down":
+		view.scrollTopic(view.topicOverlay.Height / 2)
+	default:
+		return false
+	}
+	return true
+}
+
+// onTopicMouseEvent handles mouse events on the expanded topic. Links are opened by the terminal.
+func (view *RoomView) onTopicMouseEvent(event mauview.MouseEvent) bool {
+	switch event.Buttons() {
+	case tcell.WheelUp:
+		view.scrollTopic(-1)
+	case tcell.WheelDown:
+		view.scrollTopic(1)
+	}
+	return true
+}
+
+// topicBarText returns the text for the collapsed topic bar, truncated with an ellipsis if it doesn't fit.
+func (view *RoomView) topicBarText(width int) string {
+	text := strings.TrimSuffix(view.callStatus()+view.topicText, " - ")
+	text, _ = truncateTopicToWidth(text, width)
+	return text
+}
@@ -0,0 +1,56 @@
+// gomuks - A terminal Matrix client written in Go.
+// Copyright (C) 2026 Tulir Asokan
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+package tui
+
+import (
+	"testing"
+
+	"github.com/mattn/go-runewidth"
+)
+
+func TestTruncateTopicToWidth(t *testing.T) {
+	tests := []struct {
+		name          string
+		text          string
+		width         int
+		want          string
+		wantTruncated bool
+	}{
+		{"fits", "hello world", 20, "hello world", false},
+		{"exact width", "hello world", 11, "hello world", false},
+		{"one over", "hello world", 10, "hello wor…", true},
+		{"empty", "", 5, "", false},
+		{"zero width", "hello", 0, "", true},
+		{"negative width", "hello", -3, "", true},
+		{"width of ellipsis", "hello", 1, "…", true},
+		{"wide characters fit", "日本語", 6, "日本語", false},
+		{"wide characters", "日本語のトピック", 7, "日本語…", true},
+		{"wide character doesn't fit in last cell", "日本語", 4, "日…", true},
+		{"emoji", "🐈🐈🐈🐈", 5, "🐈🐈…", true},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			got, truncated := truncateTopicToWidth(test.text, test.width)
+			if got != test.want || truncated != test.wantTruncated {
+				t.Errorf("truncateTopicToWidth(%q, %d) = %q, %t, want %q, %t", test.text, test.width, got, truncated, test.want, test.wantTruncated)
+			}
+			if width := runewidth.StringWidth(got); test.width >= 0 && width > test.width {
+				t.Errorf("Result %q is %d cells wide, over the limit of %d", got, width, test.width)
+			}
+		})
+	}
+}
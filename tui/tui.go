@@ -18,6 +18,7 @@ package tui
 
 import (
 	"context"
+	"fmt"
 	"os"
 	"os/exec"
 	"os/signal"
@@ -30,10 +31,13 @@ import (
 	"go.mau.fi/util/exzerolog"
 
 	"go.mau.fi/gomuks/pkg/hicli/jsoncmd"
+	"go.mau.fi/gomuks/pkg/rpc"
 	"go.mau.fi/gomuks/pkg/rpc/client"
 	"go.mau.fi/gomuks/pkg/rpc/store"
+	"go.mau.fi/gomuks/pkg/usercolor"
 	"go.mau.fi/gomuks/tui/config"
 	"go.mau.fi/gomuks/tui/debug"
+	"go.mau.fi/gomuks/tui/widget"
 )
 
 type View string
@@ -81,18 +85,19 @@ func NewGomuksTUI() *GomuksTUI {
 
 func (ui *GomuksTUI) Run() {
 	ui.Config.LoadAll()
+	ui.applyHashColorScheme()
 	log := exerrors.Must(ui.Config.LogConfig.Compile())
 	exzerolog.SetupDefaults(log)
 	loggedIn := false
 	if ui.Config.Server != "" && ui.Config.Username != "" && ui.Config.Password != "" {
-		ui.gmx = exerrors.Must(client.NewGomuksClient(ui.Config.Server))
-		exerrors.PanicIfNotNil(ui.gmx.Authenticate(context.TODO(), ui.Config.Username, ui.Config.Password))
+		ui.gmx = exerrors.Must(client.NewGomuksClient(ui.Config.Server, ui.connectionOptions()))
 		loggedIn = true
 	}
 
 	mauview.Backspace2RemovesWord = ui.Config.Backspace2RemovesWord
 	mauview.Backspace1RemovesWord = ui.Config.Backspace1RemovesWord
 	ui.app.SetAlwaysClear(ui.Config.AlwaysClearScreen)
+	ui.enableFocusReporting()
 	_ = clipboard.Initialize()
 	ui.views = map[View]mauview.Component{
 		ViewLogin: ui.NewLoginView(),
@@ -113,24 +118,77 @@ func (ui *GomuksTUI) Run() {
 	}()
 
 	if ui.gmx != nil {
-		go ui.Connect()
+		go ui.authenticateAndConnect()
 	}
 	exerrors.PanicIfNotNil(ui.app.Start())
 }
 
-func (ui *GomuksTUI) Connect() {
+func (ui *GomuksTUI) connectionOptions() rpc.ConnectionOptions {
+	return rpc.ConnectionOptions{
+		InsecureSkipVerify: ui.Config.InsecureSkipVerify,
+	}
+}
+
+func (ui *GomuksTUI) attachClient() {
 	ui.gmx.ReversedRoomList.Listen(func(_ []*store.RoomListEntry) {
 		ui.NeedsRender = true
 	})
+	ui.gmx.UnreadSummary.Listen(func(_ store.UnreadSummary) {
+		ui.NeedsRender = true
+	})
 	ui.gmx.SendNotification = ui.MainView.NotifyMessage
 	ui.gmx.EventHandler = ui.gomuksEventHandler
 	ui.MainView.matrix = ui.gmx
-	exerrors.PanicIfNotNil(ui.gmx.Connect(context.TODO()))
+}
+
+func (ui *GomuksTUI) authenticateAndConnect() {
+	err := ui.gmx.Authenticate(context.TODO(), ui.Config.Username, ui.Config.Password)
+	if err != nil {
+		debug.Print("Failed to authenticate to backend:", err)
+		ui.attachClient()
+		ui.MainView.ShowConnectionModal(err.Error())
+		return
+	}
+	ui.Connect()
+}
+
+// Connect attaches the current client to the UI and connects to the websocket.
+// If connecting fails, the connection modal is shown so that the backend address can be changed.
+func (ui *GomuksTUI) Connect() {
+	ui.attachClient()
+	err := ui.gmx.Connect(context.TODO())
+	if err != nil {
+		debug.Print("Failed to connect to backend:", err)
+		ui.MainView.ShowConnectionModal(err.Error())
+	}
 }
 
 func (ui *GomuksTUI) gomuksEventHandler(ctx context.Context, rawEvt any) {
-	switch rawEvt.(type) {
+	if cm := ui.MainView.connectionModal; cm != nil {
+		cm.HandleEvent(rawEvt)
+	}
+	switch evt := rawEvt.(type) {
+	case *jsoncmd.ClientState:
+		if len(evt.Problems) > 0 {
+			ui.MainView.ShowSessionProblems(evt.Problems)
+		} else if evt.Initialized && !evt.IsLoggedIn {
+			ui.MainView.ShowSetupWizard()
+		}
+	case *rpc.ConnectionStatus:
+		ui.MainView.HandleConnectionStatus(evt)
+		if evt.State == rpc.ConnectionStateGaveUp {
+			ui.MainView.ShowConnectionModal(fmt.Sprintf("Lost connection to backend: %v", evt.Error))
+		}
+	case *jsoncmd.InitComplete:
+		ui.MainView.HandleInitComplete()
+	case *jsoncmd.PolicyEnforced:
+		ui.MainView.HandlePolicyEnforced(evt)
+	case *jsoncmd.SendCooldown:
+		ui.MainView.HandleSendCooldown(evt)
+	case *jsoncmd.LeaveRoomsProgress:
+		ui.MainView.HandleLeaveRoomsProgress(evt)
 	case *jsoncmd.SyncComplete:
+		ui.MainView.HandleSyncMembers(evt)
 		if ui.NeedsRender {
 			debug.Print("Rendering...")
 			ui.Render()
@@ -140,6 +198,14 @@ func (ui *GomuksTUI) gomuksEventHandler(ctx context.Context, rawEvt any) {
 
 func (ui *GomuksTUI) Stop() {
 	debug.Print("Stopping")
+	// Only save the recent rooms after the room list is loaded, as rooms that don't exist are filtered out
+	if ui.MainView != nil && ui.gmx != nil && len(ui.gmx.ReversedRoomList.Current()) > 0 {
+		ui.Config.RecentRooms = ui.MainView.RecentRooms()
+		if roomID := ui.MainView.SplitPaneRoom(); roomID != "" {
+			ui.Config.SplitPaneRoom = roomID
+		}
+		ui.Config.Save()
+	}
 	ui.gmx.Disconnect()
 	debug.Print("Disconnection complete")
 	ui.app.Stop()
@@ -166,15 +232,44 @@ func (ui *GomuksTUI) OnLogout() {
 }
 
 func (ui *GomuksTUI) HandleNewPreferences() {
+	ui.applyHashColorScheme()
 	ui.Render()
 }
 
+// applyHashColorScheme passes the background hint and user color overrides from the config to GetHashColor.
+// Hash colors and other decorations are disabled in the screen reader mode.
+func (ui *GomuksTUI) applyHashColorScheme() {
+	bg := ui.Config.Preferences.GetBackgroundHint()
+	overrides := make(map[string]tcell.Color, len(ui.Config.UserColors))
+	for userID, colorName := range ui.Config.UserColors {
+		color := tcell.GetColor(colorName)
+		if color == tcell.ColorDefault {
+			debug.Printf("Ignoring invalid color %q for %s", colorName, userID)
+			continue
+		}
+		r, g, b := color.RGB()
+		if !usercolor.IsReadable(usercolor.Color(uint32(r)<<16|uint32(g)<<8|uint32(b)), bg) {
+			debug.Printf("Color %q for %s may be hard to read on a %s background", colorName, userID, bg)
+		}
+		overrides[string(userID)] = color
+	}
+	widget.SetHashColorScheme(&widget.HashColorScheme{
+		Background: bg,
+		Overrides:  overrides,
+		Monochrome: ui.Config.Preferences.ScreenReaderMode,
+	})
+	widget.HideDecorations.Store(ui.Config.Preferences.ScreenReaderMode)
+}
+
 func (ui *GomuksTUI) SetView(name View) {
 	ui.app.SetRoot(ui.views[name])
 }
 
 func (ui *GomuksTUI) RunExternal(executablePath string, args ...string) error {
 	callback := make(chan error)
+	if ui.MainView != nil {
+		ui.MainView.StopTyping()
+	}
 	ui.app.Suspend(func() {
 		cmd := exec.Command(executablePath, args...)
 		cmd.Stdout = os.Stdout
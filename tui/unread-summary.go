@@ -0,0 +1,98 @@
+// gomuks - A terminal Matrix client written in Go.
+// Copyright (C) 2026 Tulir Asokan
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses/>.
+package tui
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/gdamore/tcell/v2"
+	"go.mau.fi/mauview"
+	"maunium.net/go/mautrix/id"
+
+	"go.mau.fi/gomuks/pkg/rpc/store"
+	"go.mau.fi/gomuks/tui/widget"
+)
+
+// UnreadSummaryBar is the line under the room list that shows the total unread counts of all rooms.
+// The totals are maintained by the store, so drawing doesn't need to go through the rooms.
+type UnreadSummaryBar struct {
+	parent *MainView
+}
+
+func NewUnreadSummaryBar(parent *MainView) *UnreadSummaryBar {
+	return &UnreadSummaryBar{parent: parent}
+}
+
+func pluralize(count int, singular, plural string) string {
+	if count == 1 {
+		return fmt.Sprintf("%d %s", count, singular)
+	}
+	return fmt.Sprintf("%d %s", count, plural)
+}
+
+// formatUnreadSummary formats the summary like "12 rooms unread · 3 mentions · 1 invite".
+// Zero counts are left out, and an empty string is returned if there's nothing unread.
+func formatUnreadSummary(summary store.UnreadSummary) string {
+	parts := make([]string, 0, 3)
+	if summary.UnreadRooms > 0 {
+		parts = append(parts, pluralize(summary.UnreadRooms, "room", "rooms")+" unread")
+	}
+	if summary.Mentions > 0 {
+		parts = append(parts, pluralize(summary.Mentions, "mention", "mentions"))
+	}
+	if summary.Invites > 0 {
+		parts = append(parts, pluralize(summary.Invites, "invite", "invites"))
+	}
+	return strings.Join(parts, " · ")
+}
+
+func (usb *UnreadSummaryBar) Draw(screen mauview.Screen) {
+	width, _ := screen.Size()
+	summary := usb.parent.matrix.UnreadSummary.Current()
+	text := formatUnreadSummary(summary)
+	style := tcell.StyleDefault.Foreground(tcell.ColorGray)
+	if text == "" {
+		text = "All caught up"
+	} else if summary.Mentions > 0 {
+		style = style.Foreground(tcell.ColorYellow)
+	}
+	widget.WriteLinePadded(screen, mauview.AlignLeft, text, 0, 0, width, style)
+}
+
+func (usb *UnreadSummaryBar) OnKeyEvent(_ mauview.KeyEvent) bool {
+	return false
+}
+
+func (usb *UnreadSummaryBar) OnPasteEvent(_ mauview.PasteEvent) bool {
+	return false
+}
+
+func (usb *UnreadSummaryBar) OnMouseEvent(_ mauview.MouseEvent) bool {
+	return false
+}
+
+// NextMention switches to the room that has had unread mentions for the longest time.
+// Unlike next_active_room, rooms that only have normal unread messages are skipped.
+func (view *MainView) NextMention() {
+	var current id.RoomID
+	if view.currentRoom != nil {
+		current = view.currentRoom.Room.ID
+	}
+	if roomID := view.matrix.NextUnreadMention(current); roomID != "" {
+		view.SwitchRoom(roomID)
+	}
+}
@@ -0,0 +1,136 @@
+// gomuks - A terminal Matrix client written in Go.
+// Copyright (C) 2025 Tulir Asokan
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+package tui
+
+import (
+	"fmt"
+	"time"
+
+	"maunium.net/go/mautrix/event"
+	"mvdan.cc/xurls/v2"
+
+	"go.mau.fi/gomuks/pkg/hicli/database"
+	"go.mau.fi/gomuks/tui/config"
+)
+
+// URLPreviewPromptTimeout is how long the URL preview question waits for an answer
+// before the message is sent using the send_bundled_url_previews account preference.
+const URLPreviewPromptTimeout = 10 * time.Second
+
+type urlPreviewPrompt struct {
+	text  string
+	timer *time.Timer
+}
+
+// messageHasLinks returns true if URL previews are relevant for the given message, i.e. if the text
+// contains links or if the message being edited had previews.
+func messageHasLinks(text string, editTarget *database.Event) bool {
+	if xurls.Strict().MatchString(text) {
+		return true
+	} else if editTarget == nil {
+		return false
+	}
+	content, ok := editTarget.GetMautrixContent().Parsed.(*event.MessageEventContent)
+	return ok && len(content.BeeperLinkPreviews) > 0
+}
+
+// defaultAllowURLPreviews returns the answer used when the URL preview prompt times out.
+func (view *RoomView) defaultAllowURLPreviews() bool {
+	prefs := view.parent.matrix.PreferenceCache.Current()
+	return prefs == nil || prefs.SendBundledURLPreviews
+}
+
+// SendMessage sends a text message to the room, applying the url_previews_when_sending preference.
+func (view *RoomView) SendMessage(msgtype event.MessageType, text string) {
+	if !messageHasLinks(text, view.editing) {
+		view.sendMessage(text, false)
+		return
+	}
+	switch view.config.Preferences.GetURLPreviewsWhenSending() {
+	case config.URLPreviewsNever:
+		view.sendMessage(text, true)
+	case config.URLPreviewsAsk:
+		view.askURLPreviews(text)
+	default:
+		view.sendMessage(text, false)
+	}
+}
+
+func (view *RoomView) askURLPreviews(text string) {
+	view.urlPreviewPromptLock.Lock()
+	defer view.urlPreviewPromptLock.Unlock()
+	if view.urlPreviewPrompt != nil {
+		// Only one question can be shown at a time, so resolve the previous one with the default answer
+		view.resolveURLPreviewPromptLocked(view.defaultAllowURLPreviews())
+	}
+	prompt := &urlPreviewPrompt{text: text}
+	prompt.timer = time.AfterFunc(URLPreviewPromptTimeout, func() {
+		view.urlPreviewPromptLock.Lock()
+		defer view.urlPreviewPromptLock.Unlock()
+		if view.urlPreviewPrompt == prompt {
+			view.resolveURLPreviewPromptLocked(view.defaultAllowURLPreviews())
+			view.parent.parent.Render()
+		}
+	})
+	view.urlPreviewPrompt = prompt
+	view.parent.parent.Render()
+}
+
+// ResolveURLPreviewPrompt answers the pending URL preview question and sends the message.
+func (view *RoomView) ResolveURLPreviewPrompt(allow bool) {
+	view.urlPreviewPromptLock.Lock()
+	defer view.urlPreviewPromptLock.Unlock()
+	view.resolveURLPreviewPromptLocked(allow)
+}
+
+func (view *RoomView) resolveURLPreviewPromptLocked(allow bool) {
+	prompt := view.urlPreviewPrompt
+	if prompt == nil {
+		return
+	}
+	view.urlPreviewPrompt = nil
+	prompt.timer.Stop()
+	go view.sendMessage(prompt.text, !allow)
+}
+
+// CancelURLPreviewPrompt cancels sending the message that the URL preview question is about
+// and puts its text back in the input box.
+func (view *RoomView) CancelURLPreviewPrompt() bool {
+	view.urlPreviewPromptLock.Lock()
+	defer view.urlPreviewPromptLock.Unlock()
+	prompt := view.urlPreviewPrompt
+	if prompt == nil {
+		return false
+	}
+	view.urlPreviewPrompt = nil
+	prompt.timer.Stop()
+	view.SetInputText(prompt.text)
+	return true
+}
+
+func (view *RoomView) urlPreviewPromptStatus() string {
+	view.urlPreviewPromptLock.Lock()
+	defer view.urlPreviewPromptLock.Unlock()
+	if view.urlPreviewPrompt == nil {
+		return ""
+	}
+	defaultAnswer := "no"
+	if view.defaultAllowURLPreviews() {
+		defaultAnswer = "yes"
+	}
+	return fmt.Sprintf("Message contains links, allow URL previews? [y/n] (default: %s)", defaultAnswer)
+}
@@ -129,7 +129,7 @@ func (view *LoginView) actuallyLogin(server, username, password string) {
 	debug.Printf("Logging into %s as %s...", server, username)
 	view.parent.Config.Server = server
 	var err error
-	view.parent.gmx, err = client.NewGomuksClient(server)
+	view.parent.gmx, err = client.NewGomuksClient(server, view.parent.connectionOptions())
 	if err != nil {
 		view.Error(err.Error())
 		debug.Print("Init error:", err)
@@ -18,37 +18,80 @@ package tui
 
 import (
 	"bufio"
+	"bytes"
 	"context"
+	"encoding/json"
 	"fmt"
+	"maps"
 	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/gdamore/tcell/v2"
+	"github.com/mattn/go-runewidth"
 	"go.mau.fi/mauview"
+	"go.mau.fi/util/jsontime"
 	"go.mau.fi/util/ptr"
+	"maunium.net/go/mautrix/event"
 	"maunium.net/go/mautrix/id"
 
+	"go.mau.fi/gomuks/pkg/hicli/database"
 	"go.mau.fi/gomuks/pkg/hicli/jsoncmd"
+	"go.mau.fi/gomuks/pkg/rpc"
 	"go.mau.fi/gomuks/pkg/rpc/client"
 	"go.mau.fi/gomuks/pkg/rpc/store"
 	"go.mau.fi/gomuks/tui/config"
 	"go.mau.fi/gomuks/tui/debug"
 	"go.mau.fi/gomuks/tui/lib/notification"
+	"go.mau.fi/gomuks/tui/messages"
 	"go.mau.fi/gomuks/tui/widget"
 )
 
 type MainView struct {
 	flex *mauview.Flex
 
-	roomList    *RoomList
+	roomList *RoomList
+	split    *SplitPane
+	// roomView is the box of the focused pane and currentRoom is the room shown in it.
 	roomView    *mauview.Box
 	currentRoom *RoomView
+	// otherRoom is the room shown in the unfocused pane when the split pane mode is enabled.
+	otherRoom *RoomView
+	// recentRooms contains the most recently viewed rooms, with the most recent one last.
+	recentRooms []*RoomView
+	history     *RoomHistory
 	//cmdProcessor *CommandProcessor
 	focused mauview.Focusable
 
 	modal mauview.Component
 
-	lastFocusTime time.Time
+	setupWizardShown bool
+	// sessionProblemsShown is set after the session problem modal is opened, so that
+	// reconnecting doesn't open it again.
+	sessionProblemsShown bool
+	connectionModal      *ConnectionModal
+
+	connStatus        *rpc.ConnectionStatus
+	reinitRoomID      id.RoomID
+	reinitOtherRoomID id.RoomID
+
+	idle  *IdleTracker
+	focus TerminalFocus
+	// activityReportedAt is when the backend was last told that the user is active, for automatic presence.
+	activityReportedAt atomic.Int64
+
+	screenReader *ScreenReader
+
+	typingLock   sync.Mutex
+	typingRoomID id.RoomID
+	typingSentAt time.Time
+
+	// killRegister contains the text most recently removed with a kill keybinding in any room's input.
+	killRegister string
 
 	matrix *client.GomuksClient
 	config *config.Config
@@ -57,20 +100,29 @@ type MainView struct {
 
 func (ui *GomuksTUI) NewMainView() mauview.Component {
 	mainView := &MainView{
-		flex:     mauview.NewFlex().SetDirection(mauview.FlexColumn),
-		roomView: mauview.NewBox(nil).SetBorder(false),
+		flex: mauview.NewFlex().SetDirection(mauview.FlexColumn),
+
+		history: NewRoomHistory(ui.Config.RecentRooms),
 
 		matrix: ui.gmx,
 		config: ui.Config,
 		parent: ui,
 	}
 	mainView.roomList = NewRoomList(mainView)
+	mainView.split = NewSplitPane(ui.Config.SplitPane, mainView.SwitchPane)
+	mainView.roomView = mainView.split.Active()
+	mainView.idle = NewIdleTracker(ui.Config.Preferences.IdleTimeout, mainView.onIdle)
+	mainView.screenReader = NewScreenReader(ui.Config)
 	//mainView.cmdProcessor = NewCommandProcessor(mainView)
 
+	sidebar := mauview.NewFlex().
+		SetDirection(mauview.FlexRow).
+		AddProportionalComponent(mainView.roomList, 1).
+		AddFixedComponent(NewUnreadSummaryBar(mainView), 1)
 	mainView.flex.
-		AddFixedComponent(mainView.roomList, 25).
+		AddFixedComponent(sidebar, 25).
 		AddFixedComponent(widget.NewBorder(), 1).
-		AddProportionalComponent(mainView.roomView, 1)
+		AddProportionalComponent(mainView.split, 1)
 	mainView.BumpFocus(nil)
 
 	ui.MainView = mainView
@@ -87,16 +139,17 @@ func (view *MainView) ShowModal(modal mauview.Component) {
 	} else {
 		view.focused.Focus()
 	}
+	view.screenReader.Announce("Opened %s", modalTitle(modal))
 }
 
 func (view *MainView) HideModal() {
 	view.modal = nil
-	view.focused = view.roomView
+	view.focused = view.split
 }
 
 func (view *MainView) Draw(screen mauview.Screen) {
 	if view.config.Preferences.HideRoomList {
-		view.roomView.Draw(screen)
+		view.split.Draw(screen)
 	} else {
 		view.flex.Draw(screen)
 	}
@@ -107,14 +160,52 @@ func (view *MainView) Draw(screen mauview.Screen) {
 }
 
 func (view *MainView) BumpFocus(roomView *RoomView) {
+	view.idle.Bump()
+	view.reportActivity()
 	if roomView != nil {
-		view.lastFocusTime = time.Now()
 		view.MarkRead(roomView)
 	}
 }
 
+// activityReportInterval is how often user input is reported to the backend at most.
+const activityReportInterval = 1 * time.Minute
+
+// reportActivity tells the backend that the user is active, so it can set the presence to online.
+// The reports are debounced to once per activityReportInterval.
+func (view *MainView) reportActivity() {
+	now := time.Now().UnixMilli()
+	last := view.activityReportedAt.Load()
+	if now-last < activityReportInterval.Milliseconds() || !view.activityReportedAt.CompareAndSwap(last, now) {
+		return
+	}
+	go func() {
+		defer debug.Recover()
+		err := view.matrix.ReportActivity(context.TODO())
+		if err != nil {
+			debug.Print("Failed to report activity:", err)
+			// Try again on the next input
+			view.activityReportedAt.CompareAndSwap(now, 0)
+		}
+	}()
+}
+
+func (view *MainView) onIdle() {
+	debug.Print("User is idle, stopping typing notifications")
+	view.StopTyping()
+}
+
+// MarkReadIfActive marks the room as read unless the user is idle or the terminal isn't focused. It should be used instead of MarkRead
+// when the room is marked read automatically rather than because the user did something.
+func (view *MainView) MarkReadIfActive(roomView *RoomView) {
+	if !view.IsPresent() {
+		debug.Print("Not marking", roomView.Room.ID, "as read: user is away")
+		return
+	}
+	view.MarkRead(roomView)
+}
+
 func (view *MainView) MarkRead(roomView *RoomView) {
-	if roomView != nil && roomView == view.currentRoom && roomView.MessageView().GetScrollOffset() == 0 {
+	if roomView != nil && roomView == view.currentRoom && !roomView.Room.Archived && roomView.MessageView().GetScrollOffset() == 0 {
 		req := roomView.Room.GetMarkAsReadParams()
 		if req != nil {
 			go func() {
@@ -128,30 +219,147 @@ func (view *MainView) MarkRead(roomView *RoomView) {
 	}
 }
 
+const (
+	// TypingNotificationTimeout is how long the server should show the user as typing after a notification.
+	TypingNotificationTimeout = 10 * time.Second
+	// TypingNotificationInterval is how often the typing notification is refreshed while the user is typing.
+	TypingNotificationInterval = 5 * time.Second
+)
+
 func (view *MainView) InputChanged(roomView *RoomView, text string) {
-	//if !roomView.config.Preferences.DisableTypingNotifs {
-	//	view.matrix.SendTyping(roomView.Room.ID, len(text) > 0 && text[0] != '/')
-	//}
+	if view.config.Preferences.DisableTypingNotifs || roomView.Room.Archived {
+		return
+	}
+	if len(text) == 0 || text[0] == '/' {
+		view.StopTyping()
+		return
+	}
+	view.typingLock.Lock()
+	defer view.typingLock.Unlock()
+	if view.typingRoomID != roomView.Room.ID {
+		view.stopTypingLocked()
+	} else if time.Since(view.typingSentAt) < TypingNotificationInterval {
+		return
+	}
+	view.typingRoomID = roomView.Room.ID
+	view.typingSentAt = time.Now()
+	go view.sendTyping(roomView.Room.ID, TypingNotificationTimeout)
+}
+
+// StopTyping sends a typing notification with a zero timeout if one is currently active,
+// e.g. when the message is sent, the room is switched or the user stops using gomuks.
+func (view *MainView) StopTyping() {
+	view.typingLock.Lock()
+	view.stopTypingLocked()
+	view.typingLock.Unlock()
+}
+
+func (view *MainView) stopTypingLocked() {
+	if view.typingRoomID == "" {
+		return
+	}
+	if time.Since(view.typingSentAt) < TypingNotificationTimeout {
+		go view.sendTyping(view.typingRoomID, 0)
+	}
+	view.typingRoomID = ""
+	view.typingSentAt = time.Time{}
+}
+
+func (view *MainView) sendTyping(roomID id.RoomID, timeout time.Duration) {
+	defer debug.Recover()
+	err := view.matrix.SetTyping(context.TODO(), &jsoncmd.SetTypingParams{
+		RoomID:  roomID,
+		Timeout: int(timeout.Milliseconds()),
+	})
+	if err != nil {
+		debug.Print("Failed to send typing notification to", roomID, err)
+	}
+}
+
+// paginateLines splits the given lines into pages that fit in a terminal of the given size,
+// taking into account lines that wrap to multiple rows.
+func paginateLines(lines []string, width, height int) (pages [][]string) {
+	var page []string
+	rows := 0
+	for _, line := range lines {
+		lineRows := max(1, (runewidth.StringWidth(line)+width-1)/max(width, 1))
+		if rows+lineRows > height && len(page) > 0 {
+			pages = append(pages, page)
+			page = nil
+			rows = 0
+		}
+		page = append(page, line)
+		rows += lineRows
+	}
+	if len(page) > 0 {
+		pages = append(pages, page)
+	}
+	return
 }
 
 func (view *MainView) ShowBare(roomView *RoomView) {
 	if roomView == nil {
 		return
 	}
-	_, height := view.parent.app.Screen().Size()
+	width, height := view.parent.app.Screen().Size()
+	msgView := roomView.MessageView()
+	capture := strings.TrimSuffix(msgView.CapturePlaintext(msgView.VisibleMessageCount(), 0), "\n")
+	// Leave one row for the prompt
+	pages := paginateLines(strings.Split(capture, "\n"), width, height-1)
+	view.StopTyping()
 	view.parent.app.Suspend(func() {
-		print("\033[2J\033[0;0H")
-		// We don't know how much space there exactly is. Too few messages looks weird,
-		// and too many messages shouldn't cause any problems, so we just show too many.
-		height *= 2
-		fmt.Println(roomView.MessageView().CapturePlaintext(height))
-		fmt.Println("Press enter to return to normal mode.")
 		reader := bufio.NewReader(os.Stdin)
-		_, _, _ = reader.ReadRune()
+		for i, page := range pages {
+			print("\033[2J\033[0;0H")
+			fmt.Println(strings.Join(page, "\n"))
+			if i < len(pages)-1 {
+				fmt.Printf("-- Page %d/%d, press enter to continue or q and enter to return --", i+1, len(pages))
+				line, _ := reader.ReadString('\n')
+				if strings.TrimSpace(line) == "q" {
+					break
+				}
+			} else {
+				fmt.Print("Press enter to return to normal mode.")
+				_, _ = reader.ReadString('\n')
+			}
+		}
 		print("\033[2J\033[0;0H")
 	})
 }
 
+// SaveView writes a plaintext capture of all loaded messages in the room up to the current
+// scroll position to the given file.
+// expandHomePath replaces a leading ~/ in the given path with the user's home directory.
+func expandHomePath(path string) string {
+	if strings.HasPrefix(path, "~/") {
+		home, err := os.UserHomeDir()
+		if err == nil {
+			path = filepath.Join(home, path[2:])
+		}
+	}
+	return path
+}
+
+func (view *MainView) SaveView(roomView *RoomView, path string) error {
+	path = expandHomePath(path)
+	capture := roomView.MessageView().CapturePlaintext(0, 0)
+	err := os.WriteFile(path, []byte(capture), 0600)
+	if err != nil {
+		return fmt.Errorf("failed to write file: %w", err)
+	}
+	return nil
+}
+
+// ShowSetupWizard opens the first-run setup wizard, unless it has already been opened.
+func (view *MainView) ShowSetupWizard() {
+	if view.setupWizardShown {
+		return
+	}
+	view.setupWizardShown = true
+	view.ShowModal(NewSetupWizardModal(view))
+	view.parent.Render()
+}
+
 func (view *MainView) OpenSyncingModal() *SyncingModal {
 	component, modal := NewSyncingModal(view)
 	view.ShowModal(component)
@@ -176,19 +384,38 @@ func (view *MainView) OnKeyEvent(event mauview.KeyEvent) bool {
 	case "prev_room":
 		view.SwitchRoom(view.roomList.Previous())
 	case "search_rooms":
-		view.ShowModal(NewFuzzySearchModal(view, 42, 12))
+		view.ShowModal(NewFuzzySearchModal(view, 42, 12, false))
 	case "scroll_up":
 		msgView := view.currentRoom.MessageView()
 		msgView.AddScrollOffset(msgView.TotalHeight())
 	case "scroll_down":
 		msgView := view.currentRoom.MessageView()
 		msgView.AddScrollOffset(-msgView.TotalHeight())
+		view.MarkRead(view.currentRoom)
 	case "add_newline":
 		return view.flex.OnKeyEvent(tcell.NewEventKey(tcell.KeyEnter, '\n', event.Modifiers()|tcell.ModShift))
+	case "recent_room":
+		view.ShowRecentRooms()
+	case "history_back":
+		if target := view.history.Back(view.roomExists); target != "" {
+			view.switchRoom(target)
+		}
+	case "history_forward":
+		if target := view.history.Forward(view.roomExists); target != "" {
+			view.switchRoom(target)
+		}
 	case "next_active_room":
 		view.SwitchRoom(view.roomList.NextWithActivity())
+	case "next_mention":
+		view.NextMention()
 	case "show_bare":
 		view.ShowBare(view.currentRoom)
+	case "toggle_split":
+		view.ToggleSplit()
+	case "switch_pane":
+		view.SwitchPane()
+	case "disconnect":
+		view.Disconnect()
 	case "force_quit":
 		view.parent.Finish()
 		return false
@@ -197,7 +424,7 @@ func (view *MainView) OnKeyEvent(event mauview.KeyEvent) bool {
 		return false
 	default:
 		if view.config.Preferences.HideRoomList {
-			return view.roomView.OnKeyEvent(event)
+			return view.split.OnKeyEvent(event)
 		}
 		return view.flex.OnKeyEvent(event)
 	}
@@ -212,16 +439,17 @@ func (view *MainView) OnMouseEvent(event mauview.MouseEvent) bool {
 		return view.modal.OnMouseEvent(event)
 	}
 	if view.config.Preferences.HideRoomList {
-		return view.roomView.OnMouseEvent(event)
+		return view.split.OnMouseEvent(event)
 	}
 	return view.flex.OnMouseEvent(event)
 }
 
 func (view *MainView) OnPasteEvent(event mauview.PasteEvent) bool {
+	view.BumpFocus(view.currentRoom)
 	if view.modal != nil {
 		return view.modal.OnPasteEvent(event)
 	} else if view.config.Preferences.HideRoomList {
-		return view.roomView.OnPasteEvent(event)
+		return view.split.OnPasteEvent(event)
 	}
 	return view.flex.OnPasteEvent(event)
 }
@@ -238,54 +466,307 @@ func (view *MainView) Blur() {
 	}
 }
 
+func (view *MainView) roomExists(roomID id.RoomID) bool {
+	return view.matrix.GetRoom(roomID) != nil || view.roomList.GetArchived(roomID) != nil
+}
+
+// ShowRecentRooms opens the recent room overlay with the previous room highlighted.
+func (view *MainView) ShowRecentRooms() {
+	recent := view.history.Recent(view.roomExists)
+	if len(recent) < 2 {
+		return
+	}
+	overlay := NewRecentRoomsOverlay(view, recent)
+	view.ShowModal(overlay)
+	overlay.Next()
+}
+
+// RecentRooms returns the IDs of recently visited rooms that still exist, most recent first.
+func (view *MainView) RecentRooms() []id.RoomID {
+	return view.history.Recent(view.roomExists)
+}
+
 func (view *MainView) SwitchRoom(roomID id.RoomID) {
+	if view.switchRoom(roomID) {
+		view.history.Visit(roomID)
+	}
+}
+
+func (view *MainView) getRoomData(roomID id.RoomID) *store.RoomStore {
 	roomData := view.matrix.GetRoom(roomID)
+	if roomData == nil {
+		if archivedMeta := view.roomList.GetArchived(roomID); archivedMeta != nil {
+			roomData = view.matrix.OpenArchivedRoom(archivedMeta)
+		}
+	}
+	return roomData
+}
+
+func (view *MainView) switchRoom(roomID id.RoomID) bool {
+	if view.otherRoom != nil && view.otherRoom.Room.ID == roomID {
+		// The room is already open in the other pane, so just move focus there
+		view.flex.SetFocused(view.split)
+		view.SwitchPane()
+		return true
+	}
+	roomData := view.getRoomData(roomID)
 	if roomData == nil {
 		debug.Print("Tried to switch to nonexistent room!", roomID)
-		return
+		return false
 	}
 	debug.Print("Selecting room", roomID)
 	view.roomList.SetSelected(roomID)
-	view.flex.SetFocused(view.roomView)
+	view.flex.SetFocused(view.split)
 	if view.currentRoom != nil {
+		view.StopTyping()
 		view.currentRoom.Unload()
 	}
-	currentRoom := NewRoomView(view, roomData)
+	currentRoom := view.openRoomView(roomData)
 	view.currentRoom = currentRoom
 	view.roomView.SetInnerComponent(currentRoom)
 	view.roomView.Focus()
-	view.MarkRead(currentRoom)
+	view.announceRoom(currentRoom)
+	view.MarkReadIfActive(currentRoom)
+	view.parent.Render()
+	return true
+}
+
+// openRoomView loads the view of the given room and starts fetching history and members if necessary.
+func (view *MainView) openRoomView(roomData *store.RoomStore) *RoomView {
+	roomView := view.getRoomView(roomData)
+	roomView.Load()
 	if len(ptr.Val(roomData.TimelineCache.Current())) < 50 {
-		go view.LoadHistory(roomID)
+		go view.LoadHistory(roomData.ID)
 	}
 	if !roomData.FullMembersLoaded.Load() {
 		// TODO only load necessary members rather than all?
 		go func() {
 			defer debug.Recover()
-			err := view.matrix.LoadRoomState(context.TODO(), roomID, true, false)
+			err := view.matrix.LoadRoomState(context.TODO(), roomData.ID, true, false)
 			if err != nil {
-				debug.Print("Failed to load room state for", roomID, err)
+				debug.Print("Failed to load room state for", roomData.ID, err)
 			} else {
-				currentRoom.UpdateUserList()
+				roomView.UpdateUserList()
 				view.parent.Render()
 			}
 		}()
 	}
+	return roomView
+}
+
+// HandleSyncMembers refreshes the member list of the current room if the sync contained
+// member events for it, e.g. after a display name or avatar change.
+func (view *MainView) HandleSyncMembers(evt *jsoncmd.SyncComplete) {
+	currentRoom := view.currentRoom
+	if currentRoom == nil || !currentRoom.userListLoaded {
+		return
+	}
+	syncRoom, ok := evt.Rooms[currentRoom.Room.ID]
+	if ok && len(syncRoom.State[event.StateMember]) > 0 {
+		currentRoom.UpdateUserList()
+		view.parent.NeedsRender = true
+	}
+}
+
+// SwitchRoomWhenJoined waits for a just-joined room to appear in the room list and then switches to it.
+// The room will only appear after the next sync, so this gives up after a few seconds.
+func (view *MainView) SwitchRoomWhenJoined(roomID id.RoomID) bool {
+	for range 20 {
+		if room := view.matrix.GetRoom(roomID); room != nil && !room.Archived {
+			view.SwitchRoom(roomID)
+			return true
+		}
+		time.Sleep(500 * time.Millisecond)
+	}
+	return false
+}
+
+// MaxRecentRoomViews is the number of room views that are kept in memory after switching away from them,
+// so that the scroll position and other state is preserved when switching back.
+const MaxRecentRoomViews = 5
+
+func (view *MainView) getRoomView(roomData *store.RoomStore) *RoomView {
+	for i, roomView := range view.recentRooms {
+		if roomView.Room.ID != roomData.ID {
+			continue
+		}
+		view.recentRooms = slices.Delete(view.recentRooms, i, i+1)
+		if roomView.Room != roomData {
+			// The room store was replaced, so the cached view is stale
+			roomView.Unload()
+			break
+		}
+		view.recentRooms = append(view.recentRooms, roomView)
+		return roomView
+	}
+	roomView := NewRoomView(view, roomData).SetInputChangedFunc(view.InputChanged)
+	view.recentRooms = append(view.recentRooms, roomView)
+	if len(view.recentRooms) > MaxRecentRoomViews {
+		// Never evict the views that are currently visible in either pane
+		evictIdx := slices.IndexFunc(view.recentRooms, func(rv *RoomView) bool {
+			return rv != view.currentRoom && rv != view.otherRoom
+		})
+		view.recentRooms[evictIdx].Unload()
+		view.recentRooms = slices.Delete(view.recentRooms, evictIdx, evictIdx+1)
+	}
+	return roomView
+}
+
+// HandleConnectionStatus stores the websocket connection state for the status bar. If the connection
+// was re-established without resuming, the room views are reset and the current room is reopened
+// once the backend has sent the initial sync again.
+func (view *MainView) HandleConnectionStatus(status *rpc.ConnectionStatus) {
+	prevStatus := view.connStatus
+	view.connStatus = status
+	if status.State == rpc.ConnectionStateConnected && !status.Resumed &&
+		prevStatus != nil && prevStatus.State != rpc.ConnectionStateConnected {
+		if view.currentRoom != nil {
+			view.reinitRoomID = view.currentRoom.Room.ID
+		}
+		if view.otherRoom != nil {
+			view.reinitOtherRoomID = view.otherRoom.Room.ID
+		}
+		view.ResetRooms()
+	}
 	view.parent.Render()
 }
 
+// HandleInitComplete reopens the rooms that were open before a non-resumed reconnection.
+// On startup, it opens the room that was in the second pane if the split pane mode was enabled.
+func (view *MainView) HandleInitComplete() {
+	if view.reinitRoomID != "" {
+		roomID := view.reinitRoomID
+		view.reinitRoomID = ""
+		view.switchRoom(roomID)
+	}
+	if view.split.IsSplit() && view.otherRoom == nil {
+		roomID := view.reinitOtherRoomID
+		view.reinitOtherRoomID = ""
+		if roomID == "" {
+			roomID = view.config.SplitPaneRoom
+		}
+		view.openOtherPane(roomID)
+	}
+	go view.restorePreferences()
+}
+
+// restorePreferences loads the preferences from the settings backup in account data,
+// if the local preferences have never been changed.
+func (view *MainView) restorePreferences() {
+	defer debug.Recover()
+	if view.config.PreferencesModifiedAt != 0 || !view.config.Preferences.IsDefault() {
+		return
+	}
+	backup, err := view.matrix.ExportSettings(context.TODO())
+	if err != nil {
+		debug.Print("Failed to get settings backup:", err)
+		return
+	}
+	section, ok := backup.Sections[config.SettingsSection]
+	if !ok || section == nil {
+		return
+	}
+	err = view.config.Preferences.Import(section.Values)
+	if err != nil {
+		debug.Print("Failed to restore preferences from settings backup:", err)
+		return
+	}
+	view.config.PreferencesModifiedAt = section.ModifiedAt.UnixMilli()
+	view.config.Save()
+	view.parent.HandleNewPreferences()
+}
+
+// SavePreferences saves the preferences to the config file and copies the values
+// that changed since oldPrefs to the settings backup in account data.
+func (view *MainView) SavePreferences(oldPrefs config.UserPreferences) {
+	now := time.Now()
+	view.config.PreferencesModifiedAt = now.UnixMilli()
+	view.config.Save()
+	oldValues, err := oldPrefs.Export()
+	if err != nil {
+		debug.Print("Failed to export old preferences:", err)
+		return
+	}
+	newValues, err := view.config.Preferences.Export()
+	if err != nil {
+		debug.Print("Failed to export preferences:", err)
+		return
+	}
+	maps.DeleteFunc(newValues, func(key string, value json.RawMessage) bool {
+		return bytes.Equal(oldValues[key], value)
+	})
+	if len(newValues) == 0 {
+		return
+	}
+	go func() {
+		defer debug.Recover()
+		resp, err := view.matrix.ImportSettings(context.TODO(), &jsoncmd.ImportSettingsParams{
+			Settings: &jsoncmd.SettingsBackup{
+				Sections: map[string]*jsoncmd.SettingsSection{
+					config.SettingsSection: {
+						ModifiedAt: jsontime.UM(now),
+						Values:     newValues,
+					},
+				},
+			},
+		})
+		if err != nil {
+			debug.Print("Failed to update settings backup:", err)
+		} else if len(resp.Conflicts) > 0 {
+			debug.Print("Settings backup was modified more recently, not overwriting sections", resp.Conflicts)
+		}
+	}()
+}
+
+// ConnectionStatusText returns a description of the connection state to show in the status bar,
+// or an empty string if the websocket is connected.
+func (view *MainView) ConnectionStatusText() string {
+	status := view.connStatus
+	if status == nil {
+		return ""
+	}
+	switch status.State {
+	case rpc.ConnectionStateDisconnected:
+		return "Disconnected from backend"
+	case rpc.ConnectionStateReconnecting:
+		return fmt.Sprintf("Disconnected from backend, reconnecting (attempt %d)", status.Attempt)
+	case rpc.ConnectionStateGaveUp:
+		return "Disconnected from backend"
+	default:
+		return ""
+	}
+}
+
+// ResetRooms closes all room views. It's used when the store is cleared after switching backends.
+func (view *MainView) ResetRooms() {
+	for _, roomView := range view.recentRooms {
+		roomView.Unload()
+	}
+	view.recentRooms = nil
+	view.currentRoom = nil
+	view.otherRoom = nil
+	view.split.Active().SetInnerComponent(nil)
+	view.split.Inactive().SetInnerComponent(nil)
+	view.roomList.SetSelected("")
+}
+
 func (view *MainView) NotifyMessage(room *store.RoomStore, notif jsoncmd.SyncNotification) {
 	if view.config.Preferences.DisableNotifications {
 		return
 	}
-	currentRoom := view.currentRoom
-	isCurrent := currentRoom != nil && currentRoom.Room.ID == room.ID
-	recentlyFocused := time.Now().Add(-30 * time.Second).Before(view.lastFocusTime)
-	if recentlyFocused && isCurrent {
+	if !view.shouldNotify(room.ID, notif.Highlight) {
 		debug.Print("Not sending notification: room is focused")
 		return
 	}
-	body := notif.Event.GetMautrixContent().AsMessage().Body
+	var body string
+	if notif.Reaction {
+		if !view.config.ReactionNotifications {
+			return
+		}
+		body = reactionNotificationBody(room, notif.Event)
+	} else {
+		body = notif.Event.GetMautrixContent().AsMessage().Body
+	}
 	if len(body) == 0 {
 		debug.Print("Not sending notification with empty body")
 		return
@@ -293,15 +774,30 @@ func (view *MainView) NotifyMessage(room *store.RoomStore, notif jsoncmd.SyncNot
 	if len(body) > 400 {
 		body = body[:350] + " […]"
 	}
-	memberEvt := room.GetMember(notif.Event.Sender)
-	notifTitle := notif.Event.Sender.Localpart()
-	if memberEvt != nil && memberEvt.Displayname != "" {
-		notifTitle = memberEvt.Displayname
-	}
+	notifTitle := messages.SenderDisplayName(view.config.Preferences.GetPerMessageProfiles(), room, notif.Event)
+	senderName := notifTitle
 	if roomName := room.Meta.Current().Name; roomName != nil && *roomName != "" && notifTitle != *roomName {
 		notifTitle = fmt.Sprintf("%s (%s)", notifTitle, *roomName)
 	}
-	err := notification.Send(notifTitle, body, notif.Highlight, notif.Sound)
+	if len(view.config.NotificationCommand) > 0 {
+		notification.RunCommand(view.config.NotificationCommand, notification.CommandInfo{
+			RoomName:  ptr.Val(room.Meta.Current().Name),
+			RoomID:    room.ID.String(),
+			Sender:    senderName,
+			SenderID:  notif.Event.Sender.String(),
+			Body:      body,
+			Highlight: notif.Highlight,
+		})
+		if view.config.NotificationCommandOnly {
+			return
+		}
+	}
+	var roomSound string
+	if prefs := room.PreferenceCache.Current(); prefs != nil {
+		roomSound = prefs.NotificationSound
+	}
+	sound := view.config.ShouldPlaySound(roomSound, notif.Highlight, notif.Sound)
+	err := notification.Send(notifTitle, body, notif.Highlight, sound, view.config.NotificationSoundFile)
 	if err != nil {
 		debug.Print("Failed to send notification:", err)
 	} else {
@@ -309,6 +805,27 @@ func (view *MainView) NotifyMessage(room *store.RoomStore, notif jsoncmd.SyncNot
 	}
 }
 
+// LoadLineageHistory loads messages from the predecessors of a room into the composed timeline of its view.
+func (view *MainView) LoadLineageHistory(roomID id.RoomID, lineage *store.LineageTimeline) {
+	defer debug.Recover()
+	view.parent.Render()
+	err := view.matrix.LoadLineageHistory(context.TODO(), lineage)
+	if err != nil {
+		debug.Print("Failed to fetch predecessor history for", roomID, err)
+	}
+	view.parent.Render()
+}
+
+func (view *MainView) FillGap(roomID id.RoomID, gapRowID database.TimelineRowID) {
+	defer debug.Recover()
+	err := view.matrix.FillGap(context.TODO(), roomID, gapRowID)
+	if err != nil {
+		debug.Print("Failed to fill timeline gap in", roomID, err)
+		return
+	}
+	view.parent.Render()
+}
+
 func (view *MainView) LoadHistory(roomID id.RoomID) {
 	defer debug.Recover()
 	err := view.matrix.LoadMoreHistory(context.TODO(), roomID)
@@ -318,6 +835,6 @@ func (view *MainView) LoadHistory(roomID id.RoomID) {
 	}
 	view.parent.Render()
 	if room := view.currentRoom; room != nil && room.Room.ID == roomID {
-		view.MarkRead(room)
+		view.MarkReadIfActive(room)
 	}
 }
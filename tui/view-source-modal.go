@@ -0,0 +1,103 @@
+// gomuks - A terminal Matrix client written in Go.
+// Copyright (C) 2020 Tulir Asokan
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+package tui
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+
+	"github.com/gdamore/tcell/v2"
+	"go.mau.fi/mauview"
+	"maunium.net/go/mautrix/event"
+
+	"go.mau.fi/gomuks/pkg/hicli/database"
+	"go.mau.fi/gomuks/pkg/hicli/jsoncmd"
+	"go.mau.fi/gomuks/tui/config"
+	"go.mau.fi/gomuks/tui/debug"
+)
+
+type ViewSourceModal struct {
+	mauview.FocusableComponent
+	parent   *MainView
+	textView *mauview.TextView
+	source   string
+}
+
+func NewViewSourceModal(parent *MainView, evt *database.Event) *ViewSourceModal {
+	vsm := &ViewSourceModal{parent: parent}
+
+	source, err := json.MarshalIndent(evt, "", "  ")
+	vsm.source = string(source)
+	if err != nil {
+		vsm.source = fmt.Sprintf("Failed to marshal event: %v", err)
+	}
+	text := vsm.source
+	if evt.Type == event.EventEncrypted.Type && !evt.Pending {
+		text = "Encryption:\n  Loading...\n\n" + vsm.source
+		go vsm.loadEncryptionInfo(evt)
+	}
+
+	vsm.textView = mauview.NewTextView().
+		SetText(text).
+		SetScrollable(true).
+		SetWrap(true).
+		SetTextColor(tcell.ColorDefault)
+
+	box := mauview.NewBox(vsm.textView).
+		SetBorder(true).
+		SetTitle(fmt.Sprintf("Source of %s", evt.ID)).
+		SetBlurCaptureFunc(func() bool {
+			vsm.parent.HideModal()
+			return true
+		})
+	box.Focus()
+
+	vsm.FocusableComponent = mauview.FractionalCenter(box, 42, 10, 0.8, 0.8)
+
+	return vsm
+}
+
+// loadEncryptionInfo fetches the encryption details of the event from the backend and shows them above the source.
+func (vsm *ViewSourceModal) loadEncryptionInfo(evt *database.Event) {
+	defer debug.Recover()
+	info, err := vsm.parent.matrix.GetEventEncryptionInfo(context.TODO(), &jsoncmd.GetEventEncryptionInfoParams{
+		RoomID:  evt.RoomID,
+		EventID: evt.ID,
+	})
+	var section string
+	if err != nil {
+		section = fmt.Sprintf("Encryption:\n  Failed to get encryption info: %v\n", err)
+	} else {
+		section = formatEncryptionInfo(info)
+	}
+	vsm.textView.SetText(section + "\n" + vsm.source)
+	vsm.parent.parent.Render()
+}
+
+func (vsm *ViewSourceModal) OnKeyEvent(event mauview.KeyEvent) bool {
+	kb := config.Keybind{
+		Key: event.Key(),
+		Ch:  event.Rune(),
+		Mod: event.Modifiers(),
+	}
+	if vsm.parent.config.Keybindings.Modal[kb] == "cancel" || event.Rune() == 'q' {
+		vsm.parent.HideModal()
+		return true
+	}
+	return vsm.FocusableComponent.OnKeyEvent(event)
+}
@@ -0,0 +1,127 @@
+// gomuks - A terminal Matrix client written in Go.
+// Copyright (C) 2020 Tulir Asokan
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+package widget
+
+import (
+	"hash/fnv"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/gdamore/tcell/v2"
+	"github.com/mattn/go-runewidth"
+	"github.com/rivo/uniseg"
+	"go.mau.fi/mauview"
+)
+
+// AvatarWidth is the number of cells used by avatar blocks drawn with DrawAvatar.
+const AvatarWidth = 2
+
+// avatarBackgrounds are the background colors used for avatar blocks.
+// They're all dark enough for white text to be readable on top of them.
+var avatarBackgrounds = []tcell.Color{
+	tcell.ColorMaroon,
+	tcell.ColorGreen,
+	tcell.ColorOlive,
+	tcell.ColorNavy,
+	tcell.ColorPurple,
+	tcell.ColorTeal,
+	tcell.ColorDarkCyan,
+	tcell.ColorDarkGoldenrod,
+	tcell.ColorDarkMagenta,
+	tcell.ColorDarkOliveGreen,
+	tcell.ColorDarkOrchid,
+	tcell.ColorDarkRed,
+	tcell.ColorDarkSlateBlue,
+	tcell.ColorDarkSlateGray,
+	tcell.ColorFireBrick,
+	tcell.ColorIndigo,
+	tcell.ColorMidnightBlue,
+	tcell.ColorRebeccaPurple,
+	tcell.ColorSaddleBrown,
+	tcell.ColorSeaGreen,
+}
+
+// GetAvatarColor returns the background color of the avatar block for the given ID.
+func GetAvatarColor(id string) tcell.Color {
+	h := fnv.New32a()
+	_, _ = h.Write([]byte(id))
+	return avatarBackgrounds[h.Sum32()%uint32(len(avatarBackgrounds))]
+}
+
+// firstLetter returns the first grapheme cluster of the given string
+// along with its width in terminal cells.
+func firstLetter(s string) (string, int) {
+	cluster, _, width, _ := uniseg.FirstGraphemeClusterInString(s, -1)
+	return cluster, width
+}
+
+// GetInitials returns up to AvatarWidth cells worth of initials for the given name.
+//
+// Leading Matrix sigils are ignored. If the name starts with a wide character (e.g. an emoji or a CJK character),
+// that character alone is returned. Otherwise, the first letters of the first two words that start with a letter
+// or digit are used, or just the first letter if there's only one such word.
+func GetInitials(name string) string {
+	name = strings.TrimLeft(strings.TrimSpace(name), "#!@+")
+	var initials string
+	var count int
+	for _, word := range strings.Fields(name) {
+		letter, width := firstLetter(word)
+		// Only keep the base rune so that the result can be drawn one rune per cell
+		r, _ := utf8.DecodeRuneInString(letter)
+		if width >= AvatarWidth {
+			if count == 0 {
+				return string(r)
+			}
+			continue
+		} else if width != 1 || (!unicode.IsLetter(r) && !unicode.IsDigit(r)) {
+			continue
+		}
+		initials += strings.ToUpper(letter)
+		count++
+		if count == AvatarWidth {
+			break
+		}
+	}
+	if count == 0 && name != "" {
+		// The name doesn't have any letters, just use the first character
+		if letter, width := firstLetter(name); width == 1 {
+			return letter
+		}
+	}
+	return initials
+}
+
+// DrawAvatar draws a colored avatar block with the initials of the given name at the given position.
+func DrawAvatar(screen mauview.Screen, x, y int, id, name string) {
+	style := tcell.StyleDefault.Background(GetAvatarColor(id)).Foreground(tcell.ColorWhite).Bold(true)
+	initials := GetInitials(name)
+	for i := 0; i < AvatarWidth; i++ {
+		screen.SetContent(x+i, y, ' ', nil, style)
+	}
+	offset := 0
+	for _, ch := range initials {
+		chWidth := runewidth.RuneWidth(ch)
+		if chWidth == 0 {
+			continue
+		} else if offset+chWidth > AvatarWidth {
+			break
+		}
+		screen.SetContent(x+offset, y, ch, nil, style)
+		offset += chWidth
+	}
+}
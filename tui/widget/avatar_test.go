@@ -0,0 +1,67 @@
+// gomuks - A terminal Matrix client written in Go.
+// Copyright (C) 2026 Tulir Asokan
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+package widget
+
+import (
+	"testing"
+)
+
+func TestGetInitials(t *testing.T) {
+	tests := []struct {
+		name string
+		want string
+	}{
+		{"", ""},
+		{"   ", ""},
+		{"matrix", "M"},
+		{"Matrix HQ", "MH"},
+		{"gomuks development chat", "GD"},
+		{"  spaced   out  ", "SO"},
+		{"#room:example.com", "R"},
+		{"@alice:example.com", "A"},
+		{"!abc:example.com", "A"},
+		{"\U0001F408 cats", "\U0001F408"},
+		{"\U0001F469‍\U0001F469‍\U0001F467 family", "\U0001F469"},
+		{"cats \U0001F408", "C"},
+		{"日本語 room", "日"},
+		{"room 日本語", "R"},
+		{"(test) room", "R"},
+		{"a - b", "AB"},
+		{"42 things", "4T"},
+		{"élan vital", "ÉV"},
+		{"élan", "É"},
+		{"---", "-"},
+	}
+	for _, test := range tests {
+		if got := GetInitials(test.name); got != test.want {
+			t.Errorf("GetInitials(%q) = %q, want %q", test.name, got, test.want)
+		}
+	}
+}
+
+func TestGetAvatarColor(t *testing.T) {
+	if GetAvatarColor("!room:example.com") != GetAvatarColor("!room:example.com") {
+		t.Error("Expected avatar color to be stable")
+	}
+	seen := make(map[any]bool)
+	for _, roomID := range []string{"!a:example.com", "!b:example.com", "!c:example.com", "!d:example.com", "!e:example.com"} {
+		seen[GetAvatarColor(roomID)] = true
+	}
+	if len(seen) < 2 {
+		t.Error("Expected different rooms to get different colors")
+	}
+}
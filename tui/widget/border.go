@@ -17,10 +17,16 @@
 package widget
 
 import (
+	"sync/atomic"
+
 	"github.com/gdamore/tcell/v2"
 	"go.mau.fi/mauview"
 )
 
+// HideDecorations makes borders draw nothing. It's enabled in the screen reader mode,
+// where box-drawing characters are just noise.
+var HideDecorations atomic.Bool
+
 // Border is a simple tview widget that renders a horizontal or vertical bar.
 //
 // If the width of the box is 1, the bar will be vertical.
@@ -38,6 +44,9 @@ func NewBorder() *Border {
 }
 
 func (border *Border) Draw(screen mauview.Screen) {
+	if HideDecorations.Load() {
+		return
+	}
 	width, height := screen.Size()
 	if width == 1 {
 		for borderY := 0; borderY < height; borderY++ {
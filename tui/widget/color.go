@@ -18,203 +18,80 @@ package widget
 
 import (
 	"fmt"
-	"hash/fnv"
+	"sync/atomic"
 
 	"github.com/gdamore/tcell/v2"
-
 	"maunium.net/go/mautrix/id"
+
+	"go.mau.fi/gomuks/pkg/usercolor"
 )
 
-var colorNames = []string{
-	"maroon",
-	"green",
-	"olive",
-	"navy",
-	"purple",
-	"teal",
-	"silver",
-	"gray",
-	"red",
-	"lime",
-	"yellow",
-	"blue",
-	"fuchsia",
-	"aqua",
-	"white",
-	"aliceblue",
-	"antiquewhite",
-	"aquamarine",
-	"azure",
-	"beige",
-	"bisque",
-	"blanchedalmond",
-	"blueviolet",
-	"brown",
-	"burlywood",
-	"cadetblue",
-	"chartreuse",
-	"chocolate",
-	"coral",
-	"cornflowerblue",
-	"cornsilk",
-	"crimson",
-	"darkblue",
-	"darkcyan",
-	"darkgoldenrod",
-	"darkgray",
-	"darkgreen",
-	"darkkhaki",
-	"darkmagenta",
-	"darkolivegreen",
-	"darkorange",
-	"darkorchid",
-	"darkred",
-	"darksalmon",
-	"darkseagreen",
-	"darkslateblue",
-	"darkslategray",
-	"darkturquoise",
-	"darkviolet",
-	"deeppink",
-	"deepskyblue",
-	"dimgray",
-	"dodgerblue",
-	"firebrick",
-	"floralwhite",
-	"forestgreen",
-	"gainsboro",
-	"ghostwhite",
-	"gold",
-	"goldenrod",
-	"greenyellow",
-	"honeydew",
-	"hotpink",
-	"indianred",
-	"indigo",
-	"ivory",
-	"khaki",
-	"lavender",
-	"lavenderblush",
-	"lawngreen",
-	"lemonchiffon",
-	"lightblue",
-	"lightcoral",
-	"lightcyan",
-	"lightgoldenrodyellow",
-	"lightgray",
-	"lightgreen",
-	"lightpink",
-	"lightsalmon",
-	"lightseagreen",
-	"lightskyblue",
-	"lightslategray",
-	"lightsteelblue",
-	"lightyellow",
-	"limegreen",
-	"linen",
-	"mediumaquamarine",
-	"mediumblue",
-	"mediumorchid",
-	"mediumpurple",
-	"mediumseagreen",
-	"mediumslateblue",
-	"mediumspringgreen",
-	"mediumturquoise",
-	"mediumvioletred",
-	"midnightblue",
-	"mintcream",
-	"mistyrose",
-	"moccasin",
-	"navajowhite",
-	"oldlace",
-	"olivedrab",
-	"orange",
-	"orangered",
-	"orchid",
-	"palegoldenrod",
-	"palegreen",
-	"paleturquoise",
-	"palevioletred",
-	"papayawhip",
-	"peachpuff",
-	"peru",
-	"pink",
-	"plum",
-	"powderblue",
-	"rebeccapurple",
-	"rosybrown",
-	"royalblue",
-	"saddlebrown",
-	"salmon",
-	"sandybrown",
-	"seagreen",
-	"seashell",
-	"sienna",
-	"skyblue",
-	"slateblue",
-	"slategray",
-	"snow",
-	"springgreen",
-	"steelblue",
-	"tan",
-	"thistle",
-	"tomato",
-	"turquoise",
-	"violet",
-	"wheat",
-	"whitesmoke",
-	"yellowgreen",
-	"grey",
-	"dimgrey",
-	"darkgrey",
-	"darkslategrey",
-	"lightgrey",
-	"lightslategrey",
-	"slategrey",
+// HashColorScheme contains the settings that affect the colors returned by GetHashColor.
+type HashColorScheme struct {
+	Background usercolor.Background
+	Overrides  map[string]tcell.Color
+	// Monochrome disables hash colors entirely, so that everything uses the default text color.
+	Monochrome bool
 }
 
-// GetHashColorName gets a color name for the given string based on its FNV-1 hash.
-//
-// The array of possible color names are the alphabetically ordered color
-// names specified in tcell.ColorNames.
-//
-// The algorithm to get the color is as follows:
+var hashColorScheme atomic.Pointer[HashColorScheme]
+
+// SetHashColorScheme changes the background hint and per-user color overrides used by GetHashColor.
+func SetHashColorScheme(scheme *HashColorScheme) {
+	hashColorScheme.Store(scheme)
+}
+
+// GetHashColorForString gets the color for the given string (usually a user ID).
 //
-//	colorNames[ FNV1(string) % len(colorNames) ]
+// The color is picked from a palette that has enough contrast against the configured background,
+// using the same algorithm as the web frontend (see the usercolor package), so users have the same
+// color in both. Colors can be overridden per user in the config.
 //
-// With the exception of the three special cases:
+// There are three special cases:
 //
 //	--> = green
 //	<-- = red
 //	--- = yellow
-func GetHashColorName(s string) string {
+//
+// If the scheme is monochrome, the default color is always returned.
+func GetHashColorForString(s string) tcell.Color {
+	scheme := hashColorScheme.Load()
+	if scheme != nil && scheme.Monochrome {
+		return tcell.ColorDefault
+	}
 	switch s {
 	case "-->":
-		return "green"
+		return tcell.ColorGreen
 	case "<--":
-		return "red"
+		return tcell.ColorRed
 	case "---":
-		return "yellow"
-	default:
-		h := fnv.New32a()
-		_, _ = h.Write([]byte(s))
-		return colorNames[h.Sum32()%uint32(len(colorNames))]
+		return tcell.ColorYellow
+	}
+	var bg usercolor.Background
+	if scheme != nil {
+		if override, ok := scheme.Overrides[s]; ok {
+			return override
+		}
+		bg = scheme.Background
 	}
+	return tcell.NewHexColor(int32(usercolor.Get(s, bg)))
 }
 
-// GetHashColor gets the tcell Color value for the given string.
-//
-// GetHashColor calls GetHashColorName() and gets the Color value from the tcell.ColorNames map.
-func GetHashColor(val interface{}) tcell.Color {
+// GetHashColor gets the tcell Color value for the given string or identifier.
+func GetHashColor(val any) tcell.Color {
 	switch str := val.(type) {
 	case string:
-		return tcell.ColorNames[GetHashColorName(str)]
+		return GetHashColorForString(str)
 	case *string:
-		return tcell.ColorNames[GetHashColorName(*str)]
+		return GetHashColorForString(*str)
 	case id.UserID:
-		return tcell.ColorNames[GetHashColorName(string(str))]
+		return GetHashColorForString(string(str))
+	case id.RoomAlias:
+		return GetHashColorForString(string(str))
+	case fmt.Stringer:
+		return GetHashColorForString(str.String())
 	default:
-		return tcell.ColorNames["red"]
+		return tcell.ColorRed
 	}
 }
 
@@ -0,0 +1,69 @@
+// gomuks - A terminal Matrix client written in Go.
+// Copyright (C) 2026 Tulir Asokan
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+package widget
+
+import (
+	"testing"
+
+	"github.com/gdamore/tcell/v2"
+	"maunium.net/go/mautrix/id"
+
+	"go.mau.fi/gomuks/pkg/usercolor"
+)
+
+func TestGetHashColor(t *testing.T) {
+	const alice id.UserID = "@alice:example.com"
+	aliceDark := tcell.NewHexColor(int32(usercolor.DarkBackgroundPalette[usercolor.Index(alice.String())]))
+	aliceLight := tcell.NewHexColor(int32(usercolor.LightBackgroundPalette[usercolor.Index(alice.String())]))
+	tests := []struct {
+		name   string
+		scheme *HashColorScheme
+		val    any
+		want   tcell.Color
+	}{
+		{"no scheme", nil, alice, aliceDark},
+		{"dark background", &HashColorScheme{Background: usercolor.BackgroundDark}, alice, aliceDark},
+		{"light background", &HashColorScheme{Background: usercolor.BackgroundLight}, alice, aliceLight},
+		{"string", &HashColorScheme{Background: usercolor.BackgroundLight}, alice.String(), aliceLight},
+		{
+			"override",
+			&HashColorScheme{Background: usercolor.BackgroundLight, Overrides: map[string]tcell.Color{alice.String(): tcell.ColorPurple}},
+			alice, tcell.ColorPurple,
+		},
+		{
+			"override for another user",
+			&HashColorScheme{Overrides: map[string]tcell.Color{"@bob:example.com": tcell.ColorPurple}},
+			alice, aliceDark,
+		},
+		{"monochrome", &HashColorScheme{Monochrome: true, Overrides: map[string]tcell.Color{alice.String(): tcell.ColorPurple}}, alice, tcell.ColorDefault},
+		{"join arrow", &HashColorScheme{Background: usercolor.BackgroundLight}, "-->", tcell.ColorGreen},
+		{"leave arrow", nil, "<--", tcell.ColorRed},
+		{"other membership change", nil, "---", tcell.ColorYellow},
+		{"unsupported type", nil, 123, tcell.ColorRed},
+	}
+	t.Cleanup(func() {
+		SetHashColorScheme(nil)
+	})
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			SetHashColorScheme(test.scheme)
+			if got := GetHashColor(test.val); got != test.want {
+				t.Errorf("GetHashColor(%v) = %v, want %v", test.val, got, test.want)
+			}
+		})
+	}
+}
@@ -19,8 +19,10 @@ package widget
 import (
 	"fmt"
 	"strconv"
+	"unicode"
 
 	"github.com/mattn/go-runewidth"
+	"github.com/rivo/uniseg"
 
 	"github.com/gdamore/tcell/v2"
 	"go.mau.fi/mauview"
@@ -46,20 +48,40 @@ func WriteLine(screen mauview.Screen, align int, line string, x, y, maxWidth int
 	if offsetX < 0 {
 		offsetX = 0
 	}
-	for _, ch := range line {
-		chWidth := runewidth.RuneWidth(ch)
+	// Text is drawn one grapheme cluster at a time, so that the drawn width matches runewidth.StringWidth,
+	// which is what the wrapping is based on. Otherwise e.g. ZWJ emoji sequences would take more cells than
+	// expected and overflow into whatever is next to the text.
+	state := -1
+	var cluster string
+	for len(line) > 0 {
+		cluster, line, _, state = uniseg.FirstGraphemeClusterInString(line, state)
+		chWidth := runewidth.StringWidth(cluster)
 		if chWidth == 0 {
 			continue
+		} else if offsetX+chWidth > maxWidth {
+			break
 		}
-
-		for localOffset := 0; localOffset < chWidth; localOffset++ {
-			screen.SetContent(x+offsetX+localOffset, y, ch, nil, style)
+		mainc, combc := splitCluster(cluster)
+		screen.SetContent(x+offsetX, y, mainc, combc, style)
+		for localOffset := 1; localOffset < chWidth; localOffset++ {
+			screen.SetContent(x+offsetX+localOffset, y, mainc, nil, style)
 		}
 		offsetX += chWidth
-		if offsetX >= maxWidth {
-			break
+	}
+}
+
+// splitCluster splits a grapheme cluster into the main rune and combining runes for tcell.
+// Invisible formatting characters other than joiners (e.g. bidi overrides) are dropped,
+// as they'd otherwise be passed to the terminal and could mess up the rest of the line.
+func splitCluster(cluster string) (mainc rune, combc []rune) {
+	for i, char := range cluster {
+		if i == 0 {
+			mainc = char
+		} else if char == '\u200c' || char == '\u200d' || !unicode.Is(unicode.Cf, char) {
+			combc = append(combc, char)
 		}
 	}
+	return
 }
 
 func WriteLinePadded(screen mauview.Screen, align int, line string, x, y, maxWidth int, style tcell.Style) {
@@ -22,9 +22,11 @@ self.addEventListener("push", evt => {
 	}
 	const data = evt.data.json()
 	evt.waitUntil(Promise.all(data.messages.map(notif => self.registration.showNotification(
-		notif.room_name === notif.sender.name ? notif.sender.name : `${notif.sender.name} (${notif.room_name})`,
+		notif.content_omitted
+			? "New message"
+			: notif.room_name === notif.sender.name ? notif.sender.name : `${notif.sender.name} (${notif.room_name})`,
 		{
-			body: notif.text,
+			body: notif.content_omitted ? "Open gomuks to read it" : notif.text,
 			timestamp: notif.timestamp,
 			silent: !notif.sound,
 			badge: "gomuks.png",
@@ -23,7 +23,7 @@ import WasmClient from "./api/wasmclient.ts"
 import WSClient from "./api/wsclient.ts"
 import ClientContext from "./ui/ClientContext.ts"
 import MainScreen from "./ui/MainScreen.tsx"
-import { LoginScreen, VerificationScreen } from "./ui/login"
+import { LoginScreen, SessionProblemScreen, VerificationScreen } from "./ui/login"
 import { LightboxWrapper } from "./ui/modal"
 import { useEventAsState } from "./util/eventdispatcher.ts"
 
@@ -86,6 +86,8 @@ function App() {
 			<ScaleLoader width="2rem" height="2rem" color="var(--primary-color)"/>
 			{msg}
 		</div>
+	} else if (clientState.problems?.some(problem => problem.fatal)) {
+		return <div className="pre-main"><SessionProblemScreen client={client} clientState={clientState}/></div>
 	} else if (!clientState.is_logged_in) {
 		return <div className="pre-main"><LoginScreen client={client} clientState={clientState}/></div>
 	} else if (!clientState.is_verified) {
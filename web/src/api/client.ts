@@ -14,7 +14,7 @@
 // You should have received a copy of the GNU Affero General Public License
 // along with this program.  If not, see <https://www.gnu.org/licenses/>.
 import type { MouseEvent } from "react"
-import { CachedEventDispatcher, NonNullCachedEventDispatcher } from "../util/eventdispatcher.ts"
+import { CachedEventDispatcher, EventDispatcher, NonNullCachedEventDispatcher } from "../util/eventdispatcher.ts"
 import RPCClient, { SendMessageParams } from "./rpc.ts"
 import { RoomStateStore, StateStore, WidgetListener, fakeGomuksSender } from "./statestore"
 import {
@@ -24,20 +24,30 @@ import {
 	EventType,
 	GomuksAndroidMessageToWeb,
 	ImagePackRooms,
+	OpenURIData,
 	RPCEvent,
 	RawDBEvent,
 	RelationType,
 	RoomID,
 	RoomStateGUID,
+	StorageChangedData,
+	StorageCompactionProgressData,
 	SyncStatus,
+	TimelineRowID,
 	UnreadType,
 	UserID,
 } from "./types"
+import { PreferenceValueType, isExportablePreference } from "./types/preferences"
+import WailsClient from "./wailsclient.ts"
+
+const settingsBackupSection = "web"
 
 export default class Client {
 	readonly state = new CachedEventDispatcher<ClientState>()
 	readonly syncStatus = new NonNullCachedEventDispatcher<SyncStatus>({ type: "waiting", error_count: 0 })
 	readonly initComplete = new NonNullCachedEventDispatcher<boolean>(false)
+	readonly storageChanged = new EventDispatcher<StorageChangedData>()
+	readonly storageCompactionProgress = new EventDispatcher<StorageCompactionProgressData>()
 	readonly store = new StateStore()
 	#stateRequests: RoomStateGUID[] = []
 	#stateRequestPromise: Promise<void> | null = null
@@ -47,6 +57,9 @@ export default class Client {
 	constructor(readonly rpc: RPCClient) {
 		this.rpc.event.listen(this.#handleEvent)
 		this.rpc.connect.listen(() => this.initComplete.emit(false))
+		if (this.rpc instanceof WailsClient) {
+			this.rpc.focusRoom.listen(roomID => this.store.onClickNotification(roomID))
+		}
 		this.store.accountDataSubs.getSubscriber("im.ponies.emote_rooms")(() =>
 			queueMicrotask(() => this.#handleEmoteRoomsChange()))
 	}
@@ -256,19 +269,90 @@ export default class Client {
 			this.syncStatus.emit(ev.data)
 		} else if (ev.command === "init_complete") {
 			this.initComplete.emit(true)
+			this.#restoreDevicePreferences().catch(err => console.error("Failed to restore settings backup", err))
 		} else if (ev.command === "sync_complete") {
 			this.store.applySync(ev.data)
 		} else if (ev.command === "events_decrypted") {
 			this.store.applyDecrypted(ev.data)
 		} else if (ev.command === "send_complete") {
 			this.store.applySendComplete(ev.data)
+		} else if (ev.command === "send_queue_update") {
+			this.store.applySendQueueUpdate(ev.data)
 		} else if (ev.command === "image_auth_token") {
 			this.store.imageAuthToken = ev.data
 		} else if (ev.command === "typing") {
 			this.store.applyTyping(ev.data)
+		} else if (ev.command === "open_uri") {
+			this.#openURI(ev.data)
+		} else if (ev.command === "storage_changed") {
+			this.storageChanged.emit(ev.data)
+		} else if (ev.command === "storage_compaction_progress") {
+			this.storageCompactionProgress.emit(ev.data)
+		}
+	}
+
+	#openURI(data: OpenURIData) {
+		const mainScreen = window.mainScreenContext
+		if (data.user_id) {
+			mainScreen.setRightPanel({ type: "user", userID: data.user_id })
+		} else if (data.room_id) {
+			mainScreen.setActiveRoom(data.room_id, {
+				previewMeta: { via: data.via ?? [] },
+				openEventID: data.event_id,
+			})
+		} else if (data.room_alias) {
+			const alias = data.room_alias
+			this.rpc.resolveAlias(alias).then(
+				res => mainScreen.setActiveRoom(res.room_id, {
+					previewMeta: {
+						alias,
+						via: res.servers.slice(0, 3),
+					},
+					openEventID: data.event_id,
+				}),
+				err => window.alert(`Failed to resolve room alias ${alias}: ${err}`),
+			)
+		}
+	}
+
+	async #restoreDevicePreferences() {
+		// Only restore the backup if no device preferences have been set on this device yet.
+		if (localStorage.getItem("global_prefs") !== null) {
+			return
+		}
+		const backup = await this.rpc.exportSettings()
+		const section = backup.sections[settingsBackupSection]
+		if (!section) {
+			return
+		}
+		for (const [key, value] of Object.entries(section.values)) {
+			if (isExportablePreference(key) && value !== null) {
+				(this.store.localPreferenceCache[key] as PreferenceValueType) = value as PreferenceValueType
+			}
 		}
 	}
 
+	backupDevicePreferences(values: Record<string, unknown>) {
+		const exportable = Object.fromEntries(Object.entries(values)
+			.filter(([key]) => isExportablePreference(key))
+			.map(([key, value]) => [key, value ?? null]))
+		if (Object.keys(exportable).length === 0) {
+			return
+		}
+		this.rpc.importSettings({
+			sections: {
+				[settingsBackupSection]: { modified_at: Date.now(), values: exportable },
+			},
+		}).then(
+			resp => {
+				if (resp.conflicts?.length) {
+					console.warn("Settings backup was modified more recently on another device", resp.conflicts)
+				}
+			},
+			err => console.error("Failed to update settings backup", err),
+		)
+	}
+
 	requestMemberEvent(room: RoomStateStore | RoomID | undefined, userID: UserID) {
 		if (typeof room === "string") {
 			room = this.store.rooms.get(room)
@@ -526,7 +610,7 @@ export default class Client {
 			console.log("Requesting 50 messages of history and a timeline reset in", roomID)
 			const resp = await this.rpc.paginate(roomID, 0, 50, true)
 			room.hasMoreHistory = resp.has_more
-			room.applyPagination(resp.events, resp.related_events, resp.receipts)
+			room.applyPagination(resp.events, resp.related_events, resp.receipts, false, resp.gaps)
 		} finally {
 			room.paginating = false
 		}
@@ -550,7 +634,24 @@ export default class Client {
 				throw new Error("Timeline changed while loading history")
 			}
 			room.hasMoreHistory = resp.has_more
-			room.applyPagination(resp.events, resp.related_events, resp.receipts)
+			room.applyPagination(resp.events, resp.related_events, resp.receipts, false, resp.gaps)
+		} finally {
+			room.paginating = false
+		}
+	}
+
+	async fillGap(roomID: RoomID, gapRowID: TimelineRowID): Promise<void> {
+		const room = this.store.rooms.get(roomID)
+		if (!room) {
+			throw new Error("Room not found")
+		} else if (room.paginating) {
+			throw new Error("Already paginating")
+		}
+		room.paginating = true
+		try {
+			console.log("Filling timeline gap before", gapRowID, "in", roomID)
+			const resp = await this.rpc.fillGap(roomID, gapRowID)
+			room.applyGapFill(gapRowID, resp)
 		} finally {
 			room.paginating = false
 		}
@@ -30,6 +30,7 @@ export const getEncryptedMediaURL = (mxc?: string): string | undefined => {
 
 const FALLBACK_COLOR_COUNT = 10
 
+// note: this should stay in sync with Index in pkg/usercolor/usercolor.go
 export const getUserColorIndex = (userID: UserID) =>
 	userID.split("").reduce((acc, char) => acc + char.charCodeAt(0), 0) % FALLBACK_COLOR_COUNT
 
@@ -16,13 +16,31 @@
 import { CachedEventDispatcher, EventDispatcher } from "../util/eventdispatcher.ts"
 import { CancellablePromise } from "../util/promise.ts"
 import {
+	Add3PIDEmailParams,
+	Add3PIDResponse,
 	ClientWellKnown,
+	CompactStorageParams,
+	DBFrontendStoreEntry,
+	DBPolicyEnforcement,
+	DBPolicySubscription,
 	DBPushRegistration,
+	DBRoom,
+	DeviceID,
 	Direction,
 	EventContextResponse,
+	EventEncryptionInfo,
 	EventID,
+	EventRowID,
 	EventType,
+	ExportMembersParams,
+	ExportMembersResponse,
+	FillGapResponse,
+	ImportSettingsResponse,
 	JSONValue,
+	LeaveRoomsParams,
+	LeaveRoomsResponse,
+	ListPolicyRulesResponse,
+	LogLevels,
 	LoginFlowsResponse,
 	LoginRequest,
 	ManualPaginationResponse,
@@ -30,11 +48,14 @@ import {
 	MembershipAction,
 	Mentions,
 	MessageEventContent,
+	PaginateLineageParams,
+	PaginateLineageResponse,
 	PaginationResponse,
 	ProfileEncryptionInfo,
 	RPCCommand,
 	RPCEvent,
 	RawDBEvent,
+	ReactionToMe,
 	ReceiptType,
 	RelatesTo,
 	RelationType,
@@ -43,6 +64,7 @@ import {
 	RespCreateRoom,
 	RespMediaConfig,
 	RespOpenIDToken,
+	RespPublicRooms,
 	RespRoomJoin,
 	RespSpaceHierarchy,
 	RespTurnServer,
@@ -50,7 +72,14 @@ import {
 	RoomID,
 	RoomStateGUID,
 	RoomSummary,
+	SessionDevices,
+	SettingsBackup,
+	StorageStats,
+	SyncFilterSettings,
+	ThreePID,
 	TimelineRowID,
+	TimestampToEventResponse,
+	UIAResponse,
 	URLPreview,
 	UnreadType,
 	UserID,
@@ -79,6 +108,7 @@ export interface SendMessageParams {
 	relates_to?: RelatesTo
 	mentions?: Mentions
 	url_previews?: URLPreview[]
+	split_code_blocks?: boolean
 }
 
 export default abstract class RPCClient {
@@ -181,6 +211,30 @@ export default abstract class RPCClient {
 		return this.request("logout", {})
 	}
 
+	changePassword(old_password: string, new_password: string, logout_devices: boolean): Promise<UIAResponse> {
+		return this.request("change_password", { old_password, new_password, logout_devices })
+	}
+
+	deactivateAccount(password: string, erase: boolean): Promise<UIAResponse> {
+		return this.request("deactivate_account", { password, erase })
+	}
+
+	setDeviceName(name: string, device_id?: DeviceID): Promise<boolean> {
+		return this.request("set_device_name", { name, device_id })
+	}
+
+	get3PIDs(): Promise<ThreePID[]> {
+		return this.request("get_3pids", {})
+	}
+
+	add3PIDEmail(params: Add3PIDEmailParams): Promise<Add3PIDResponse> {
+		return this.request("add_3pid_email", params)
+	}
+
+	delete3PID(medium: string, address: string): Promise<boolean> {
+		return this.request("delete_3pid", { medium, address })
+	}
+
 	sendMessage(params: SendMessageParams): Promise<RawDBEvent | null> {
 		return this.request("send_message", params)
 	}
@@ -199,6 +253,14 @@ export default abstract class RPCClient {
 		return this.request("resend_event", { transaction_id })
 	}
 
+	discardEvent(transaction_id: string): Promise<void> {
+		return this.request("discard_event", { transaction_id })
+	}
+
+	flushSendQueue(room_id: RoomID): Promise<number> {
+		return this.request("flush_send_queue", { room_id })
+	}
+
 	reportEvent(room_id: RoomID, event_id: EventID, reason: string): Promise<boolean> {
 		return this.request("report_event", { room_id, event_id, reason })
 	}
@@ -260,6 +322,14 @@ export default abstract class RPCClient {
 		return this.request("track_user_devices", { user_id })
 	}
 
+	getEventEncryptionInfo(room_id: RoomID, event_id: EventID): Promise<EventEncryptionInfo> {
+		return this.request("get_event_encryption_info", { room_id, event_id })
+	}
+
+	getSessionDevices(room_id: RoomID, session_id: string): Promise<SessionDevices> {
+		return this.request("get_session_devices", { room_id, session_id })
+	}
+
 	ensureGroupSessionShared(room_id: RoomID): Promise<boolean> {
 		return this.request("ensure_group_session_shared", { room_id })
 	}
@@ -276,6 +346,10 @@ export default abstract class RPCClient {
 		return this.request("get_specific_room_state", { keys })
 	}
 
+	getEventsByRowIDs(row_ids: EventRowID[]): Promise<RawDBEvent[]> {
+		return this.request("get_events_by_row_ids", { row_ids })
+	}
+
 	getRoomState(
 		room_id: RoomID, include_members = false, fetch_members = false, refetch = false,
 	): Promise<RawDBEvent[]> {
@@ -299,10 +373,22 @@ export default abstract class RPCClient {
 		return this.request("get_mentions", { max_timestamp, type, limit, room_id })
 	}
 
+	getReactionsToMe(max_timestamp: number, limit: number = 50): Promise<ReactionToMe[]> {
+		return this.request("get_reactions_to_me", { max_timestamp, limit })
+	}
+
 	getEventContext(room_id: RoomID, event_id: EventID, limit: number = 20): Promise<EventContextResponse> {
 		return this.request("get_event_context", { room_id, event_id, limit })
 	}
 
+	getEventNearTimestamp(
+		room_id: RoomID,
+		ts: number,
+		direction: Direction = "f",
+	): Promise<TimestampToEventResponse> {
+		return this.request("get_event_near_timestamp", { room_id, ts, direction })
+	}
+
 	paginateManual(
 		room_id: RoomID,
 		since: string,
@@ -321,10 +407,122 @@ export default abstract class RPCClient {
 		return this.request("paginate", { room_id, max_timeline_id, limit, reset })
 	}
 
+	paginateLineage(params: PaginateLineageParams): Promise<PaginateLineageResponse> {
+		return this.request("paginate_lineage", params)
+	}
+
+	fillGap(room_id: RoomID, timeline_rowid: TimelineRowID, limit: number = 100): Promise<FillGapResponse> {
+		return this.request("fill_gap", { room_id, timeline_rowid, limit })
+	}
+
+	resetRoom(room_id: RoomID, limit: number = 50): Promise<void> {
+		return this.request("reset_room", { room_id, limit, confirm: true })
+	}
+
+	resetSync(): Promise<void> {
+		return this.request("reset_sync", { confirm: true })
+	}
+
 	getRoomSummary(room_id_or_alias: RoomID | RoomAlias, via?: string[]): Promise<RoomSummary> {
 		return this.request("get_room_summary", { room_id_or_alias, via })
 	}
 
+	searchPublicRooms(
+		params: { server?: string, filter?: string, since?: string, limit?: number } = {},
+	): Promise<RespPublicRooms> {
+		return this.request("search_public_rooms", params)
+	}
+
+	setLogLevel(level: string, component?: string): Promise<LogLevels> {
+		return this.request("set_log_level", { level, component })
+	}
+
+	getRecentLogs(limit?: number): Promise<Record<string, unknown>[]> {
+		return this.request("get_recent_logs", { limit })
+	}
+
+	getStorageStats(): Promise<StorageStats> {
+		return this.request("get_storage_stats", {})
+	}
+
+	compactStorage(params: CompactStorageParams = {}): Promise<StorageStats> {
+		return this.request("compact_storage", params)
+	}
+
+	wipeStorage(): Promise<boolean> {
+		return this.request("wipe_storage", {})
+	}
+
+	reportActivity(): Promise<boolean> {
+		return this.request("report_activity", {})
+	}
+
+	getLeftRooms(): Promise<DBRoom[]> {
+		return this.request("get_left_rooms", {})
+	}
+
+	forgetRoom(room_id: RoomID): Promise<boolean> {
+		return this.request("forget_room", { room_id })
+	}
+
+	getSyncFilter(): Promise<SyncFilterSettings> {
+		return this.request("get_sync_filter", {})
+	}
+
+	setSyncFilter(settings: SyncFilterSettings): Promise<boolean> {
+		return this.request("set_sync_filter", settings)
+	}
+
+	exportSettings(): Promise<SettingsBackup> {
+		return this.request("export_settings", {})
+	}
+
+	importSettings(settings: SettingsBackup, override: boolean = false): Promise<ImportSettingsResponse> {
+		return this.request("import_settings", { settings, override })
+	}
+
+	storageGet(namespace: string, key: string): Promise<DBFrontendStoreEntry | null> {
+		return this.request("storage_get", { namespace, key })
+	}
+
+	storageSet(namespace: string, key: string, value: JSONValue, broadcast: boolean = false): Promise<void> {
+		return this.request("storage_set", { namespace, key, value, broadcast })
+	}
+
+	storageDelete(namespace: string, key: string, broadcast: boolean = false): Promise<void> {
+		return this.request("storage_delete", { namespace, key, broadcast })
+	}
+
+	storageList(namespace: string): Promise<DBFrontendStoreEntry[]> {
+		return this.request("storage_list", { namespace })
+	}
+
+	exportMembers(room_id: RoomID, params: ExportMembersParams = {}): Promise<ExportMembersResponse> {
+		return this.request("export_members", { room_id, ...params })
+	}
+
+	subscribePolicyRoom(
+		policy_room_id: RoomID, target_room_id?: RoomID, action?: DBPolicySubscription["action"],
+	): Promise<boolean> {
+		return this.request("subscribe_policy_room", { policy_room_id, target_room_id, action })
+	}
+
+	unsubscribePolicyRoom(policy_room_id: RoomID, target_room_id?: RoomID): Promise<boolean> {
+		return this.request("unsubscribe_policy_room", { policy_room_id, target_room_id })
+	}
+
+	listPolicyRules(policy_room_id?: RoomID): Promise<ListPolicyRulesResponse> {
+		return this.request("list_policy_rules", { policy_room_id })
+	}
+
+	policyDryRun(target_room_id?: RoomID): Promise<DBPolicyEnforcement[]> {
+		return this.request("policy_dry_run", { target_room_id })
+	}
+
+	getImageAuthToken(): Promise<string> {
+		return this.request("get_image_auth_token", {})
+	}
+
 	getSpaceHierarchy(
 		room_id: RoomID,
 		params: { from?: string, limit?: number, max_depth?: number | null, suggested_only?: boolean } = {},
@@ -344,6 +542,10 @@ export default abstract class RPCClient {
 		return this.request("leave_room", { room_id, reason })
 	}
 
+	leaveRooms(params: LeaveRoomsParams): Promise<LeaveRoomsResponse> {
+		return this.request("leave_rooms", params)
+	}
+
 	createRoom(request: ReqCreateRoom): Promise<RespCreateRoom> {
 		return this.request("create_room", request)
 	}
@@ -356,6 +558,18 @@ export default abstract class RPCClient {
 		return this.request("resolve_alias", { alias })
 	}
 
+	createAlias(room_id: RoomID, alias: RoomAlias, canonical?: "main" | "alt"): Promise<boolean> {
+		return this.request("create_alias", { room_id, alias, canonical })
+	}
+
+	deleteAlias(room_id: RoomID | undefined, alias: RoomAlias, update_canonical = false): Promise<boolean> {
+		return this.request("delete_alias", { room_id, alias, update_canonical })
+	}
+
+	getLocalAliases(room_id: RoomID): Promise<RoomAlias[]> {
+		return this.request("get_local_aliases", { room_id })
+	}
+
 	discoverHomeserver(user_id: UserID): Promise<ClientWellKnown> {
 		return this.request("discover_homeserver", { user_id })
 	}
@@ -31,6 +31,7 @@ import {
 	RoomID,
 	RoomStateGUID,
 	SendCompleteData,
+	SendQueueUpdateData,
 	SyncCompleteData,
 	SyncRoom,
 	SyncToDevice,
@@ -332,11 +333,17 @@ export class StateStore {
 
 			if (
 				window.Notification?.permission === "granted"
+				// The desktop app sends native notifications
+				&& !window.gomuksDesktop
 				&& !focused.current
 				&& data.notifications
 				&& !this.localPreferenceCache.web_push
 			) {
 				for (const notification of data.notifications) {
+					if (notification.reaction) {
+						// Reaction notifications are currently only supported by gomuks terminal
+						continue
+					}
 					this.showNotification(room, notification.event_rowid, notification.sound)
 				}
 			}
@@ -554,6 +561,10 @@ export class StateStore {
 		room.applySendComplete(data.event)
 	}
 
+	applySendQueueUpdate(data: SendQueueUpdateData) {
+		this.rooms.get(data.room_id)?.applySendQueueUpdate(data.positions)
+	}
+
 	applyDecrypted(decrypted: EventsDecryptedData) {
 		const room = this.rooms.get(decrypted.room_id)
 		if (!room) {
@@ -29,6 +29,7 @@ import {
 	EventRowID,
 	EventType,
 	EventsDecryptedData,
+	FillGapResponse,
 	ImagePack,
 	LazyLoadSummary,
 	MemDBEvent,
@@ -38,6 +39,8 @@ import {
 	RawDBEvent,
 	RoomID,
 	SyncRoom,
+	TimelineGap,
+	TimelineRowID,
 	TimelineRowTuple,
 	UnknownEventContent,
 	UserID,
@@ -128,6 +131,7 @@ export class RoomStateStore {
 	readonly meta: NonNullCachedEventDispatcher<DBRoom>
 	searchString: string
 	timeline: TimelineRowTuple[] = []
+	gaps: Map<TimelineRowID, TimelineGap> = new Map()
 	timelineCache: (MemDBEvent | null)[] = []
 	editTargets: EventRowID[] = []
 	state: Map<EventType, Map<string, EventRowID>> = new Map()
@@ -369,6 +373,7 @@ export class RoomStateStore {
 		related: RawDBEvent[],
 		allReceipts: Record<EventID, DBReceipt[]>,
 		reset: boolean = false,
+		gaps: TimelineGap[] = [],
 	) {
 		// Pagination comes in newest to oldest, timeline is in the opposite order
 		history.reverse()
@@ -383,15 +388,39 @@ export class RoomStateStore {
 		}
 		if (reset) {
 			this.timeline = newTimeline
+			this.gaps = new Map()
 		} else {
 			this.timeline.splice(0, 0, ...newTimeline)
 		}
+		for (const gap of gaps) {
+			this.gaps.set(gap.timeline_rowid, gap)
+		}
 		this.notifyTimelineSubscribers()
 		for (const [evtID, receipts] of Object.entries(allReceipts)) {
 			this.applyReceipts(receipts, evtID, true)
 		}
 	}
 
+	applyGapFill(gapRowID: TimelineRowID, resp: FillGapResponse) {
+		for (const evt of resp.events) {
+			this.applyEvent(evt)
+		}
+		if (resp.timeline.length > 0) {
+			// The new entries usually go right before the gap, but if there was no room,
+			// the backend moves existing entries after the gap, so their old positions are dropped.
+			const moved = new Set(resp.timeline.map(rt => rt.event_rowid))
+			this.timeline = this.timeline
+				.filter(rt => !moved.has(rt.event_rowid))
+				.concat(resp.timeline)
+				.sort((a, b) => a.timeline_rowid - b.timeline_rowid)
+		}
+		this.gaps = new Map(this.gaps.entries().filter(([rowID]) => rowID < gapRowID))
+		for (const gap of resp.gaps) {
+			this.gaps.set(gap.timeline_rowid, gap)
+		}
+		this.notifyTimelineSubscribers()
+	}
+
 	applyReceipts(receipts: DBReceipt[], evtID: EventID, override: boolean) {
 		const evt = this.eventsByID.get(evtID)
 		if (!evt?.timeline_rowid) {
@@ -516,9 +545,31 @@ export class RoomStateStore {
 			return
 		}
 		this.applyEvent(evt, true)
+		if (evt.send_error === "discarded") {
+			const pendingIdx = this.pendingEvents.indexOf(evt.rowid)
+			if (pendingIdx !== -1) {
+				this.pendingEvents.splice(pendingIdx, 1)
+			}
+		}
 		this.notifyTimelineSubscribers()
 	}
 
+	applySendQueueUpdate(positions: Record<string, number>) {
+		let changed = false
+		for (const rowID of this.pendingEvents) {
+			const evt = this.eventsByRowID.get(rowID)
+			const position = evt?.transaction_id ? positions[evt.transaction_id] : undefined
+			if (!evt?.pending || position === undefined || evt.queue_position === position) {
+				continue
+			}
+			this.#saveEventToMaps({ ...evt, queue_position: position })
+			changed = true
+		}
+		if (changed) {
+			this.notifyTimelineSubscribers()
+		}
+	}
+
 	invalidateStateCaches(evtType: string, key: string) {
 		if (evtType === "im.ponies.room_emotes") {
 			this.#emojiPacksCache.delete(key)
@@ -584,10 +635,14 @@ export class RoomStateStore {
 		}
 		if (sync.reset) {
 			this.timeline = sync.timeline ?? []
+			this.gaps = new Map()
 			this.pendingEvents.splice(0, this.pendingEvents.length)
 		} else if (sync.timeline) {
 			this.timeline.push(...sync.timeline)
 		}
+		for (const gap of sync.gaps ?? []) {
+			this.gaps.set(gap.timeline_rowid, gap)
+		}
 		if (sync.meta.unread_notifications === 0 && sync.meta.unread_highlights === 0) {
 			for (const notif of this.openNotifications.values()) {
 				notif.close()
@@ -736,6 +791,7 @@ export class RoomStateStore {
 		this.paginationRequestedForRow = -1
 		this.hasMoreHistory = true
 		this.timeline = []
+		this.gaps = new Map()
 		this.notifyTimelineSubscribers()
 		const eventsToKeepList = this.eventsByRowID.values()
 			.filter(evt => eventsToKeep.has(evt.rowid))
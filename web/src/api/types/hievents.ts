@@ -16,18 +16,22 @@
 import {
 	DBAccountData,
 	DBInvitedRoom,
+	DBPolicyEnforcement,
 	DBReceipt,
 	DBRoom,
 	DBRoomAccountData,
 	DBSpaceEdge,
 	EventRowID,
 	RawDBEvent,
+	TimelineGap,
 	TimelineRowTuple,
 } from "./hitypes.ts"
 import {
 	DeviceID,
 	EventID,
 	EventType,
+	JSONValue,
+	RoomAlias,
 	RoomID,
 	UserID,
 } from "./mxtypes.ts"
@@ -76,6 +80,7 @@ export interface SyncRoom {
 	events: RawDBEvent[] | null
 	state: Record<EventType, Record<string, EventRowID>> | null
 	reset: boolean
+	gaps?: TimelineGap[]
 	notifications: SyncNotification[] | null
 	account_data: Record<EventType, DBRoomAccountData> | null
 	receipts: Record<EventID, DBReceipt[]> | null
@@ -84,6 +89,7 @@ export interface SyncRoom {
 export interface SyncNotification {
 	event_rowid: EventRowID
 	sound: boolean
+	reaction?: boolean
 }
 
 export interface SyncToDevice {
@@ -110,17 +116,30 @@ export interface SyncCompleteEvent extends BaseRPCCommand<SyncCompleteData> {
 }
 
 
+export type SessionProblemCode =
+	"logged_out" | "device_mismatch" | "crypto_store_mismatch" | "clock_skew" | "unsupported_database"
+
+export interface SessionProblem {
+	code: SessionProblemCode
+	message: string
+	fatal: boolean
+	details?: string
+}
+
 export type ClientState = {
 	is_initialized: boolean
 	is_logged_in: false
 	is_verified: false
+	problems?: SessionProblem[]
 } | {
 	is_initialized: boolean
 	is_logged_in: true
 	is_verified: boolean
 	user_id: UserID
 	device_id: DeviceID
+	device_name?: string
 	homeserver_url: string
+	problems?: SessionProblem[]
 }
 
 export interface ClientStateEvent extends BaseRPCCommand<ClientState> {
@@ -152,6 +171,81 @@ export interface RunIDEvent extends BaseRPCCommand<RunData> {
 	command: "run_id"
 }
 
+export interface OpenURIData {
+	uri: string
+	user_id?: UserID
+	room_id?: RoomID
+	room_alias?: RoomAlias
+	event_id?: EventID
+	via?: string[]
+}
+
+export interface OpenURIEvent extends BaseRPCCommand<OpenURIData> {
+	command: "open_uri"
+}
+
+export interface StorageChangedData {
+	namespace: string
+	key: string
+	value?: JSONValue
+	deleted?: boolean
+}
+
+export interface StorageChangedEvent extends BaseRPCCommand<StorageChangedData> {
+	command: "storage_changed"
+}
+
+export interface PolicyEnforcedData extends DBPolicyEnforcement {
+	error?: string
+}
+
+export interface PolicyEnforcedEvent extends BaseRPCCommand<PolicyEnforcedData> {
+	command: "policy_enforced"
+}
+
+export interface StorageCompactionProgressData {
+	step: number
+	total_steps: number
+	message: string
+}
+
+export interface StorageCompactionProgressEvent extends BaseRPCCommand<StorageCompactionProgressData> {
+	command: "storage_compaction_progress"
+}
+
+export interface SendCooldownData {
+	room_id: RoomID
+	until?: number
+	count?: number
+}
+
+export interface SendCooldownEvent extends BaseRPCCommand<SendCooldownData> {
+	command: "send_cooldown"
+}
+
+export interface SendQueueUpdateData {
+	room_id: RoomID
+	// Maps transaction IDs to the number of events ahead of them in the send queue.
+	positions: Record<string, number>
+}
+
+export interface SendQueueUpdateEvent extends BaseRPCCommand<SendQueueUpdateData> {
+	command: "send_queue_update"
+}
+
+export interface LeaveRoomsProgressData {
+	dry_run_id: string
+	room_id: RoomID
+	done: number
+	total: number
+	error?: string
+	retry_at?: number
+}
+
+export interface LeaveRoomsProgressEvent extends BaseRPCCommand<LeaveRoomsProgressData> {
+	command: "leave_rooms_progress"
+}
+
 export interface ResponseCommand extends BaseRPCCommand<unknown> {
 	command: "response"
 }
@@ -173,6 +267,13 @@ export type RPCEvent =
 	SyncCompleteEvent |
 	ImageAuthTokenEvent |
 	InitCompleteEvent |
-	RunIDEvent
+	RunIDEvent |
+	OpenURIEvent |
+	StorageChangedEvent |
+	PolicyEnforcedEvent |
+	StorageCompactionProgressEvent |
+	SendCooldownEvent |
+	SendQueueUpdateEvent |
+	LeaveRoomsProgressEvent
 
 export type RPCCommand = RPCEvent | ResponseCommand | ErrorCommand | PingCommand
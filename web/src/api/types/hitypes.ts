@@ -21,9 +21,12 @@ import {
 	EncryptionEventContent,
 	EventID,
 	EventType,
+	JSONValue,
 	LazyLoadSummary,
+	Membership,
 	ReceiptType,
 	RelationType,
+	RetentionEventContent,
 	RoomAlias,
 	RoomID,
 	StrippedStateEvent,
@@ -47,10 +50,16 @@ export enum RoomNameQuality {
 	Explicit,
 }
 
+export interface ServerUnreadCounts {
+	highlight_count: number
+	notification_count: number
+}
+
 export interface DBRoom {
 	room_id: RoomID
 	creation_content?: CreateEventContent
 	tombstone?: TombstoneEventContent
+	retention?: RetentionEventContent
 
 	name?: string
 	name_quality: RoomNameQuality
@@ -69,9 +78,11 @@ export interface DBRoom {
 	unread_highlights: number
 	unread_notifications: number
 	unread_messages: number
+	server_unread_counts?: ServerUnreadCounts
 	marked_unread: boolean
 
 	prev_batch: string
+	left_at?: number
 }
 
 export interface DBSpaceEdge {
@@ -98,6 +109,7 @@ export enum UnreadType {
 	Notify = 0b0010,
 	Highlight = 0b0100,
 	Sound = 0b1000,
+	ReactionToMe = 0b10000,
 }
 
 export interface LocalContent {
@@ -134,8 +146,10 @@ export interface BaseDBEvent {
 	send_error?: string
 
 	reactions?: Record<string, number>
+	reaction_senders?: Record<string, Record<UserID, EventID>>
 	last_edit_rowid?: EventRowID
 	unread_type: UnreadType
+	queue_position?: number
 }
 
 export interface RawDBEvent extends BaseDBEvent {
@@ -180,11 +194,50 @@ export interface MemReceipt extends DBReceipt {
 	timeline_rowid: TimelineRowID
 }
 
+export interface TimelineGap {
+	room_id: RoomID
+	timeline_rowid: TimelineRowID
+	missed_count?: number
+}
+
 export interface PaginationResponse {
 	events: RawDBEvent[]
 	receipts: Record<EventID, DBReceipt[]>
 	related_events: RawDBEvent[]
 	has_more: boolean
+	gaps?: TimelineGap[]
+}
+
+export interface RoomLineageEntry {
+	room_id: RoomID
+	name?: string
+	successor_id: RoomID
+	upgraded_at: number
+	local: boolean
+	error?: string
+}
+
+export interface PaginateLineageResponse {
+	room: RoomLineageEntry | null
+	events: RawDBEvent[]
+	receipts?: Record<EventID, DBReceipt[]>
+	has_more: boolean
+	next_batch?: string
+	next?: RoomLineageEntry
+}
+
+export interface PaginateLineageParams {
+	room_id: RoomID
+	predecessor_id?: RoomID
+	max_timeline_id?: TimelineRowID
+	since?: string
+	limit: number
+}
+
+export interface FillGapResponse {
+	events: RawDBEvent[]
+	timeline: TimelineRowTuple[]
+	gaps: TimelineGap[]
 }
 
 export interface EventContextResponse {
@@ -195,6 +248,30 @@ export interface EventContextResponse {
 	event: RawDBEvent
 }
 
+export interface TimestampToEventResponse {
+	event_id: EventID
+	origin_server_ts: number
+}
+
+export interface ReactionToMe {
+	reaction: RawDBEvent
+	target: RawDBEvent | null
+}
+
+export interface StorageStats {
+	database_size: number
+	database_free_size: number
+	database_files: string[]
+	media_cache_size: number
+	media_cache_entries: number
+	usage: number
+	quota: number
+}
+
+export interface CompactStorageParams {
+	clear_media_cache?: boolean
+}
+
 export interface ManualPaginationResponse {
 	events: RawDBEvent[]
 	next_batch: string
@@ -211,6 +288,72 @@ export interface LoginFlowsResponse {
 	}[]
 }
 
+export interface UserInteractiveAuth {
+	flows?: { stages: string[] }[]
+	params?: Record<string, unknown>
+	session?: string
+	completed?: string[]
+	errcode?: string
+	error?: string
+}
+
+export interface UIAResponse {
+	success: boolean
+	uia?: UserInteractiveAuth
+}
+
+export interface ThreePID {
+	medium: string
+	address: string
+	validated_at: number
+	added_at: number
+}
+
+export type Add3PIDStatus =
+	"validation_sent" | "not_validated" | "invalid_code" | "session_expired" | "in_use" | "auth_failed" | "added" | "bound"
+
+export interface Add3PIDEmailParams {
+	email: string
+	password?: string
+	code?: string
+	resend?: boolean
+	identity_server?: string
+}
+
+export interface Add3PIDResponse {
+	status: Add3PIDStatus
+	code_supported?: boolean
+	binding?: boolean
+	uia?: UserInteractiveAuth
+	bind_error?: string
+}
+
+export interface LogLevels {
+	global: string
+	components: Record<string, string>
+}
+
+export interface SyncFilterSettings {
+	timeline_limit?: number
+	disable_lazy_load_members?: boolean
+	exclude_event_types?: string[]
+}
+
+export interface SettingsSection {
+	modified_at: number
+	values: Record<string, unknown>
+}
+
+export interface SettingsBackup {
+	version?: number
+	sections: Record<string, SettingsSection>
+}
+
+export interface ImportSettingsResponse {
+	settings: SettingsBackup
+	conflicts?: string[]
+}
+
 export interface EventUnsigned {
 	prev_content?: unknown
 	prev_sender?: UserID
@@ -296,6 +439,39 @@ export interface ProfileEncryptionInfo {
 	errors: string[]
 }
 
+export interface DBMegolmSessionShare {
+	room_id: RoomID
+	session_id: string
+	user_count: number
+	device_count: number
+	shared_at: number
+}
+
+export interface EventEncryptionInfo {
+	encrypted: boolean
+	algorithm?: string
+	session_id?: string
+	decryption_error?: string
+	has_session: boolean
+	outbound?: boolean
+	forwarded?: boolean
+	received_at?: number
+	key_backup_version?: string
+	sender_device?: ProfileDevice
+	sender_trust?: TrustState
+	share?: DBMegolmSessionShare
+}
+
+export interface SessionDevice extends ProfileDevice {
+	has_session: boolean
+	current?: boolean
+}
+
+export interface SessionDevices {
+	outbound: boolean
+	devices: SessionDevice[]
+}
+
 export interface DBPushRegistration {
 	device_id: string
 	type: "fcm" | "web"
@@ -304,6 +480,81 @@ export interface DBPushRegistration {
 	expiration?: number
 }
 
+export interface DBFrontendStoreEntry {
+	namespace: string
+	key: string
+	value: JSONValue
+	updated_at: number
+}
+
+export type MemberExportFormat = "csv" | "json"
+
+export interface ExportMembersParams {
+	format?: MemberExportFormat
+	memberships?: Membership[]
+	path?: string
+}
+
+export interface ExportMembersResponse {
+	count: number
+	data?: string
+}
+
+export interface LeaveRoomsParams {
+	space_id?: RoomID
+	server_name?: string
+	room_ids?: RoomID[]
+	dry_run?: boolean
+	dry_run_id?: string
+	exclude_room_ids?: RoomID[]
+	reason?: string
+}
+
+export interface LeaveRoomsEntry {
+	room_id: RoomID
+	name?: string
+	left?: boolean
+	error?: string
+}
+
+export interface LeaveRoomsResponse {
+	dry_run_id?: string
+	rooms: LeaveRoomsEntry[]
+}
+
+export type PolicyAction = "ban" | "flag" | "unban" | "unflag"
+
+export interface DBPolicySubscription {
+	policy_room_id: RoomID
+	target_room_id?: RoomID
+	action: PolicyAction
+}
+
+export interface DBPolicyEnforcement {
+	room_id: RoomID
+	user_id: UserID
+	action: PolicyAction
+	policy_room_id: RoomID
+	entity: string
+	reason: string
+	timestamp: number
+}
+
+export interface PolicyRule {
+	policy_room_id: RoomID
+	event_id: EventID
+	entity_type: "user" | "server"
+	entity: string
+	reason?: string
+	recommendation: string
+}
+
+export interface ListPolicyRulesResponse {
+	subscriptions: DBPolicySubscription[]
+	rules: PolicyRule[]
+	enforcements: DBPolicyEnforcement[]
+}
+
 export interface MediaEncodingOptions {
 	encode_to?: string
 	quality?: number
@@ -312,6 +563,7 @@ export interface MediaEncodingOptions {
 	resize_percent?: number
 	_no_encrypt?: boolean
 	voice_message?: boolean
+	strip_exif?: "always" | "ask" | "never"
 }
 
 export type MembershipAction = "invite" | "kick" | "ban" | "unban"
@@ -61,6 +61,11 @@ export interface TombstoneEventContent {
 	replacement_room: RoomID
 }
 
+export interface RetentionEventContent {
+	max_lifetime?: number
+	min_lifetime?: number
+}
+
 export interface LazyLoadSummary {
 	"m.heroes"?: UserID[]
 	"m.joined_member_count"?: number
@@ -445,6 +450,13 @@ export interface RespSpaceHierarchy {
 	next_batch?: string
 }
 
+export interface RespPublicRooms {
+	chunk: PublicRoomInfo[]
+	next_batch?: string
+	prev_batch?: string
+	total_room_count_estimate?: number
+}
+
 export interface RespRoomJoin {
 	room_id: RoomID
 }
@@ -28,10 +28,12 @@ export const codeBlockStyles = [
 ] as const
 export const mapProviders = ["leaflet", "google", "none"] as const
 export const gifProviders = ["giphy", "tenor"] as const
+export const stripEXIFModes = ["always", "ask", "never"] as const
 
 export type CodeBlockStyle = typeof codeBlockStyles[number]
 export type MapProvider = typeof mapProviders[number]
 export type GIFProvider = typeof gifProviders[number]
+export type StripEXIFMode = typeof stripEXIFModes[number]
 
 /* eslint-disable max-len */
 export const preferences = {
@@ -114,6 +116,12 @@ export const preferences = {
 		allowedContexts: anyContext,
 		defaultValue: true,
 	}),
+	show_ban_removed_messages: new Preference<boolean>({
+		displayName: "Show messages removed by bans",
+		description: "Whether messages from users who were banned with the option to remove their messages should still be visible.",
+		allowedContexts: anyContext,
+		defaultValue: false,
+	}),
 	show_membership_events: new Preference<boolean>({
 		displayName: "Show membership events",
 		description: "Whether any membership events should be visible in the room timeline.",
@@ -162,6 +170,13 @@ export const preferences = {
 		allowedContexts: anyContext,
 		defaultValue: true,
 	}),
+	strip_exif: new Preference<StripEXIFMode>({
+		displayName: "Remove image metadata",
+		description: "Whether EXIF metadata like GPS coordinates should be removed from images before uploading, or if you should be asked when an image contains location data.",
+		allowedValues: stripEXIFModes,
+		allowedContexts: anyContext,
+		defaultValue: "ask",
+	}),
 	map_provider: new Preference<MapProvider>({
 		displayName: "Map provider",
 		description: "The map provider to use for location messages.",
@@ -245,6 +260,26 @@ export const preferences = {
 		allowedContexts: roomSpecific,
 		defaultValue: null,
 	}),
+	hide_room: new Preference<boolean>({
+		displayName: "Hide room",
+		description: "Hide this room from the room list and notifications. Currently only used by gomuks terminal.",
+		allowedContexts: roomSpecific,
+		defaultValue: false,
+	}),
+	notification_sound: new Preference<string>({
+		displayName: "Notification sound",
+		description: "Always or never play a sound for notifications in this room. Currently only used by gomuks terminal.",
+		allowedValues: ["", "always", "never"] as const,
+		valueLabels: ["Default", "Always", "Never"] as const,
+		allowedContexts: roomSpecific,
+		defaultValue: "",
+	}),
+	confirm_sensitive_content: new Preference<boolean>({
+		displayName: "Confirm sensitive content",
+		description: "Ask for confirmation before sending messages that look like they contain passwords, private keys or access tokens. Currently only used by gomuks terminal.",
+		allowedContexts: anyGlobalContext,
+		defaultValue: true,
+	}),
 	low_bandwidth: new Preference<boolean>({
 		displayName: "Low bandwidth mode",
 		description: "Whether to enable bandwidth saving features. Refresh to apply changes.",
@@ -270,3 +305,8 @@ export type Preferences = {
 export function isValidPreferenceKey(key: unknown): key is keyof Preferences {
 	return typeof key === "string" && existingPreferenceKeys.has(key)
 }
+
+// Device-specific preferences are never included in the settings backup in account data.
+export function isExportablePreference(key: unknown): key is keyof Preferences {
+	return isValidPreferenceKey(key) && preferences[key].allowedContexts !== globalDeviceSpecific
+}
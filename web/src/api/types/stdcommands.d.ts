@@ -21,7 +21,9 @@ export type CommandName =
 	| "rawstate"
 	| "discardsession"
 	| "devtools"
+	| "jump-to-date"
 	| "alias add"
 	| "alias del"
+	| "alias list"
 
 export default BotCommandList
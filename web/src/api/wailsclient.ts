@@ -14,8 +14,10 @@
 // You should have received a copy of the GNU Affero General Public License
 // along with this program.  If not, see <https://www.gnu.org/licenses/>.
 import type * as Wails from "@wailsio/runtime"
+import { EventDispatcher } from "@/util/eventdispatcher.ts"
 import { CancellablePromise } from "@/util/promise.ts"
 import RPCClient, { ErrorResponse } from "./rpc.ts"
+import type { RoomID } from "./types"
 
 declare global {
 	interface Window {
@@ -32,12 +34,17 @@ declare global {
 export default class WailsClient extends RPCClient {
 	protected isConnected = true
 	#wails?: typeof Wails
+	// Emitted when a native notification is clicked
+	readonly focusRoom = new EventDispatcher<RoomID>()
 
 	async start() {
 		this.#wails = await import("@wailsio/runtime")
 		this.#wails.Events.On("hicli_event", (evt: Wails.Events.WailsEvent) => {
 			this.event.emit(evt.data[0])
 		})
+		this.#wails.Events.On("focus_room", (evt: Wails.Events.WailsEvent) => {
+			this.focusRoom.emit(evt.data[0])
+		})
 		this.#wails.Call.ByName("main.CommandHandler.Init")
 		this.connect.emit({ connected: true, reconnecting: false, error: null })
 	}
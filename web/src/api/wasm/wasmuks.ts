@@ -23,6 +23,13 @@ interface MediaResponse {
 	contentDisposition: string
 }
 
+interface MediaCacheStats {
+	size: number
+	entries: number
+}
+
+const mediaCacheName = "wasmuks-media-v1"
+
 declare global {
 	interface Window {
 		meowDownloadMedia: (
@@ -33,12 +40,42 @@ declare global {
 				reject: () => void
 			},
 		) => void
+		meowStorageEstimate: () => Promise<StorageEstimate>
+		meowMediaCacheStats: () => Promise<MediaCacheStats>
+		meowClearMediaCache: () => Promise<number>
+	}
+}
+
+function setupStorageBridge() {
+	self.meowStorageEstimate = () => navigator.storage.estimate()
+	self.meowMediaCacheStats = async () => {
+		const cache = await caches.open(mediaCacheName)
+		const stats: MediaCacheStats = { size: 0, entries: 0 }
+		for (const req of await cache.keys()) {
+			const resp = await cache.match(req)
+			if (resp) {
+				stats.size += (await resp.blob()).size
+				stats.entries++
+			}
+		}
+		return stats
+	}
+	// The service worker keeps the cache open, so delete the entries rather than the whole cache
+	self.meowClearMediaCache = async () => {
+		const cache = await caches.open(mediaCacheName)
+		let deleted = 0
+		for (const req of await cache.keys()) {
+			if (await cache.delete(req)) {
+				deleted++
+			}
+		}
+		return deleted
 	}
 }
 
 async function setupMediaChannel() {
 	const bc = new BroadcastChannel("wasmuks-media-download")
-	const cache = await caches.open("wasmuks-media-v1")
+	const cache = await caches.open(mediaCacheName)
 	bc.addEventListener("message", async evt => {
 		if (evt.data.type !== "request") {
 			return
@@ -69,6 +106,7 @@ async function setupMediaChannel() {
 	await initSqlite()
 	const instance = await initGomuksWasm(go.importObject)
 	await setupMediaChannel()
+	setupStorageBridge()
 	await go.run(instance)
 	self.postMessage({
 		command: "wasm-connection",
@@ -395,6 +395,27 @@ const MainScreen = () => {
 		}
 	}, [context, client])
 	useEffect(() => context.keybindings.listen(), [context])
+	useEffect(() => {
+		// Tell the backend about user input for automatic presence, at most once a minute
+		let lastReported = 0
+		const listener = () => {
+			const now = Date.now()
+			if (now - lastReported < 60_000) {
+				return
+			}
+			lastReported = now
+			client.rpc.reportActivity().catch(err => {
+				console.warn("Failed to report activity:", err)
+				lastReported = 0
+			})
+		}
+		window.addEventListener("keydown", listener)
+		window.addEventListener("pointerdown", listener)
+		return () => {
+			window.removeEventListener("keydown", listener)
+			window.removeEventListener("pointerdown", listener)
+		}
+	}, [client])
 	const [roomListWidth, resizeHandle1] = useResizeHandle(
 		350, 96, Math.min(900, window.innerWidth * 0.4),
 		"roomListWidth", { className: "room-list-resizer" },
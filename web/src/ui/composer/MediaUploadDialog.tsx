@@ -16,6 +16,7 @@
 import React, { JSX, use, useEffect, useRef, useState } from "react"
 import type { MediaEncodingOptions } from "@/api/types"
 import { ModalCloseContext } from "@/ui/modal"
+import { formatSize } from "@/util/filesize.ts"
 import { isMobileDevice } from "@/util/ismobile.ts"
 import "./MediaUploadDialog.css"
 
@@ -29,17 +30,6 @@ export interface MediaUploadDialogProps {
 	isVoice?: boolean
 }
 
-function formatSize(bytes: number): string {
-	const units = ["B", "KiB", "MiB", "GiB", "TiB"]
-	let unitIndex = 0
-	let size = bytes
-	while (size >= 1024 && unitIndex < units.length - 1) {
-		size /= 1024
-		unitIndex++
-	}
-	return `${unitIndex === 0 ? size : size.toFixed(2)} ${units[unitIndex]}`
-}
-
 const imageReencTargets = ["image/webp", "image/jpeg", "image/png", "image/gif"]
 const nonEncodableSources = ["image/bmp", "image/tiff", "image/heif", "image/heic"]
 const imageReencSources = [...imageReencTargets, ...nonEncodableSources]
@@ -343,7 +343,7 @@ const MessageComposer = () => {
 				return
 			}
 		}
-		client.sendMessage({
+		const params = {
 			room_id: room.roomID,
 			base_content,
 			extra,
@@ -351,7 +351,23 @@ const MessageComposer = () => {
 			relates_to,
 			mentions,
 			url_previews: state.previews,
-		}).catch(err => window.alert("Failed to send message: " + err))
+		}
+		client.sendMessage(params).catch(err => {
+			if (!`${err}`.startsWith("FI.MAU.GOMUKS.MESSAGE_TOO_LARGE")) {
+				window.alert("Failed to send message: " + err)
+				return
+			}
+			const asFile = window.confirm(
+				"The message is too long to send as a single event without splitting a code block. " +
+				"Press OK to attach it as a text file instead, or Cancel to split it into multiple messages anyway.",
+			)
+			if (asFile) {
+				doUploadFile(new File([text], "message.txt", { type: "text/plain" }), "message.txt")
+			} else {
+				client.sendMessage({ ...params, split_code_blocks: true })
+					.catch(err => window.alert("Failed to send message: " + err))
+			}
+		})
 	}
 	const onComposerCaretChange = (evt: CaretEvent<HTMLTextAreaElement>, newText?: string) => {
 		const area = evt.currentTarget
@@ -560,7 +576,7 @@ const MessageComposer = () => {
 			["encrypt", encryptUpload.toString()],
 			["progress", "true"],
 			["filename", filename],
-			...Object.entries(encodingOpts ?? {})
+			...Object.entries({ strip_exif: room.preferences.strip_exif, ...encodingOpts })
 				.filter(([key, value]) => !key.startsWith("_") && !!value)
 				.map(([key, value]) => [key, value.toString()]),
 		])
@@ -587,6 +603,13 @@ const MessageComposer = () => {
 			} catch {}
 			if (xhr.status >= 200 && xhr.status < 300 && !media?.error) {
 				setState({ media, location: null })
+			} else if (media?.errcode === "FI.MAU.GOMUKS.IMAGE_HAS_LOCATION") {
+				const strip = window.confirm("The image contains location data. Remove it before uploading?")
+				// Retry after the loadend handler of this request has reset the upload state
+				setTimeout(() => doUploadFile(file, filename, {
+					...encodingOpts,
+					strip_exif: strip ? "always" : "never",
+				}))
 			} else {
 				window.alert(`Failed to upload file: ${media?.error || xhr.statusText}`)
 			}
@@ -607,7 +630,7 @@ const MessageComposer = () => {
 		xhr.open("POST", `_gomuks/upload?${params.toString()}`)
 		xhr.setRequestHeader("Content-Type", file.type)
 		xhr.send(file)
-	}, [client.rpc, isEncrypted])
+	}, [client.rpc, isEncrypted, room])
 	const openFileUploadModal = (file: File | null | undefined, isVoice?: true) => {
 		if (!file) {
 			return
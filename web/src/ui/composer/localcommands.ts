@@ -18,10 +18,12 @@ import { fakeGomuksSender } from "@/api/statestore"
 import { BotArgumentValue, RawDBEvent, RoomID, WrappedBotCommand } from "@/api/types"
 import type { CommandName } from "@/api/types/stdcommands.d.ts"
 import { escapeHTML } from "@/util/markdown.ts"
+import { parseDate } from "@/util/parsedate.ts"
 import { matrixToToMatrixURI, parseMatrixURI } from "@/util/validation.ts"
 import { MainScreenContextFields } from "../MainScreenContext.ts"
 import { modals } from "../modal"
 import { RoomContextData } from "../roomview/roomcontext.ts"
+import { jumpToDate } from "../util/jumpToEvent.tsx"
 
 const commandHandlers: { [K in CommandName]?: CommandCallback } = {
 	join: ({ client, mainScreen, reply }, { room_reference }) => {
@@ -60,6 +62,20 @@ const commandHandlers: { [K in CommandName]?: CommandCallback } = {
 	devtools: ({ roomCtx }) => {
 		window.openModal(modals.roomStateExplorer(roomCtx.store))
 	},
+	"jump-to-date": ({ client, roomCtx, reply }, { date }) => {
+		if (typeof date !== "string" || !date) {
+			window.openModal(modals.jumpToDate(roomCtx))
+			return
+		}
+		const parsed = parseDate(date)
+		if (!parsed) {
+			reply(escapedHTML`Invalid date <code>${date}</code>, expected e.g. 2025-03-03, yesterday or last monday`)
+			return
+		}
+		jumpToDate(client, roomCtx, parsed).catch(
+			err => reply(escapedHTML`Failed to jump to <code>${date}</code>: ${err.message}`),
+		)
+	},
 }
 
 type BotArgMap = Record<string, BotArgumentValue>
@@ -0,0 +1,45 @@
+// gomuks - A Matrix client written in Go.
+// Copyright (C) 2026 Tulir Asokan
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses/>.
+import { useState } from "react"
+import { LoginScreenProps } from "./LoginScreen.tsx"
+import "./LoginScreen.css"
+
+export const SessionProblemScreen = ({ client, clientState }: LoginScreenProps) => {
+	const [error, setError] = useState("")
+	const problems = clientState.problems ?? []
+	// Logging out of an unsupported database would delete data that a newer version can still use
+	const canLogout = clientState.is_logged_in
+		&& problems.some(problem => problem.fatal && problem.code !== "unsupported_database")
+
+	const logout = () => {
+		client.rpc.logout().then(
+			() => {},
+			err => setError(err.toString()),
+		)
+	}
+
+	return <main className="matrix-login">
+		<h1>gomuks web</h1>
+		{problems.map(problem => <div key={problem.code} className={problem.fatal ? "error" : "warning"}>
+			<p>{problem.message}</p>
+			{problem.details && <p><small><code>{problem.details}</code></small></p>}
+		</div>)}
+		{canLogout && <button className="mx-login-button primary-color-button" onClick={logout}>Log out</button>}
+		{error && <div className="error">
+			{error}
+		</div>}
+	</main>
+}
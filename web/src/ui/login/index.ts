@@ -1,2 +1,3 @@
 export { LoginScreen } from "./LoginScreen.tsx"
 export { VerificationScreen } from "./VerificationScreen.tsx"
+export { SessionProblemScreen } from "./SessionProblemScreen.tsx"
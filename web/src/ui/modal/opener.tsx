@@ -25,6 +25,7 @@ import RoomStateExplorer from "../settings/RoomStateExplorer.tsx"
 import SettingsView from "../settings/SettingsView.tsx"
 import EventContextModal from "../timeline/EventContextModal.tsx"
 import EventEditHistory from "../timeline/EventEditHistory.tsx"
+import JumpToDateModal from "../timeline/JumpToDateModal.tsx"
 import JSONView from "../util/JSONView.tsx"
 import { ShareModal } from "./ShareModal.tsx"
 import { ModalState, NestableModalState, NonNestableModalState } from "./contexts.ts"
@@ -119,7 +120,7 @@ export function settings(room: RoomStateStore): NestableModalState {
 	}
 }
 
-export function eventContext(roomCtx: RoomContextData, eventID: EventID): NestableModalState {
+export function eventContext(roomCtx: RoomContextData, eventID: EventID, label?: string): NestableModalState {
 	if (roomCtx.threadParentRoom) {
 		roomCtx = roomCtx.threadParentRoom
 	}
@@ -127,11 +128,22 @@ export function eventContext(roomCtx: RoomContextData, eventID: EventID): Nestab
 		dimmed: true,
 		boxed: true,
 		boxClass: "event-context-modal",
-		content: <EventContextModal roomCtx={roomCtx} eventID={eventID} key={eventID} />,
+		content: <EventContextModal roomCtx={roomCtx} eventID={eventID} label={label} key={eventID} />,
 		nestable: true,
 	}
 }
 
+export function jumpToDate(roomCtx: RoomContextData): ModalState {
+	if (roomCtx.threadParentRoom) {
+		roomCtx = roomCtx.threadParentRoom
+	}
+	return {
+		dimmed: true,
+		boxed: true,
+		content: <JumpToDateModal roomCtx={roomCtx} />,
+	}
+}
+
 export function eventEditHistory(roomCtx: RoomContextData, evt: MemDBEvent): NestableModalState {
 	return {
 		content: <EventEditHistory evt={evt} roomCtx={roomCtx}/>,
@@ -13,12 +13,12 @@
 //
 // You should have received a copy of the GNU Affero General Public License
 // along with this program.  If not, see <https://www.gnu.org/licenses/>.
-import { Suspense, lazy, use, useCallback, useRef, useState } from "react"
+import { Suspense, lazy, use, useCallback, useEffect, useRef, useState } from "react"
 import { ScaleLoader } from "react-spinners"
 import Client from "@/api/client.ts"
 import { getRoomAvatarThumbnailURL, getRoomAvatarURL } from "@/api/media.ts"
 import { RoomStateStore, usePreferences } from "@/api/statestore"
-import { KeyRestoreProgress, RoomID, RoomType } from "@/api/types"
+import { KeyRestoreProgress, RoomID, RoomType, StorageStats } from "@/api/types"
 import {
 	Preference,
 	PreferenceContext,
@@ -28,6 +28,7 @@ import {
 	preferences,
 } from "@/api/types/preferences"
 import { NonNullCachedEventDispatcher, useEventAsState } from "@/util/eventdispatcher.ts"
+import { formatSize } from "@/util/filesize.ts"
 import useEvent from "@/util/useEvent.ts"
 import ClientContext from "../ClientContext.ts"
 import { LightboxContext, ModalCloseContext, ModalContext, modals } from "../modal"
@@ -436,6 +437,77 @@ const KeyExportView = ({ room }: SettingsViewProps) => {
 	</div>
 }
 
+const StorageView = () => {
+	const client = use(ClientContext)!
+	const [stats, setStats] = useState<StorageStats | null>(null)
+	const [status, setStatus] = useState("Loading storage stats...")
+	const [busy, setBusy] = useState(false)
+	useEffect(() => {
+		client.rpc.getStorageStats().then(
+			res => {
+				setStats(res)
+				setStatus("")
+			},
+			err => setStatus(`Failed to get storage stats: ${err}`),
+		)
+		return client.storageCompactionProgress.listen(prog => {
+			setStatus(`${prog.message} (${prog.step}/${prog.total_steps})...`)
+		})
+	}, [client])
+	const compact = (clearMediaCache: boolean) => {
+		setBusy(true)
+		client.rpc.compactStorage({ clear_media_cache: clearMediaCache }).then(
+			res => {
+				setStats(res)
+				setStatus("Compaction complete")
+			},
+			err => setStatus(`Failed to compact storage: ${err}`),
+		).finally(() => setBusy(false))
+	}
+	const wipe = () => {
+		if (!window.confirm("Really log out and delete all local data, including encryption keys?")) {
+			return
+		}
+		setBusy(true)
+		client.rpc.wipeStorage().then(
+			() => console.info("Successfully wiped storage"),
+			err => setStatus(`Failed to wipe storage: ${err}`),
+		).finally(() => setBusy(false))
+	}
+	return <div className="storage-view">
+		<h3>Storage</h3>
+		{stats && <ul>
+			<li>
+				Database: {formatSize(stats.database_size)}
+				{stats.database_free_size > 0 && <> ({formatSize(stats.database_free_size)} reclaimable)</>}
+			</li>
+			<li>
+				Media cache: {stats.media_cache_size >= 0
+					? <>{formatSize(stats.media_cache_size)} in {stats.media_cache_entries} files</>
+					: "unknown"}
+			</li>
+			{stats.quota > 0 && <li>Browser storage: {formatSize(stats.usage)} of {formatSize(stats.quota)}</li>}
+		</ul>}
+		{status && <div className="storage-status">{status}</div>}
+		<div className="storage-buttons">
+			<button onClick={() => compact(false)} disabled={busy}>Compact database</button>
+			<button onClick={() => compact(true)} disabled={busy}>Compact and clear media cache</button>
+			<button className="wipe-storage" onClick={wipe} disabled={busy}>Wipe all data</button>
+		</div>
+	</div>
+}
+
+function formatRetention(maxLifetime?: number): string | null {
+	if (!maxLifetime || maxLifetime <= 0) {
+		return null
+	}
+	const hours = Math.max(Math.round(maxLifetime / (60 * 60 * 1000)), 1)
+	if (hours < 48) {
+		return `Messages are deleted after ${hours} hour${hours === 1 ? "" : "s"}`
+	}
+	return `Messages are deleted after ${Math.round(hours / 24)} days`
+}
+
 const SettingsView = ({ room }: SettingsViewProps) => {
 	const roomMeta = useEventAsState(room.meta)
 	const client = use(ClientContext)!
@@ -455,6 +527,7 @@ const SettingsView = ({ room }: SettingsViewProps) => {
 			} else {
 				(client.store.localPreferenceCache[key] as PreferenceValueType) = value
 			}
+			client.backupDevicePreferences({ [key]: value })
 			if (key === "web_push") {
 				client.registerWebPush()
 			}
@@ -504,6 +577,7 @@ const SettingsView = ({ room }: SettingsViewProps) => {
 		)
 	}
 	const previousRoomID = roomMeta.creation_content?.predecessor?.room_id
+	const retentionText = formatRetention(roomMeta.retention?.max_lifetime)
 	const openPredecessorRoom = () => {
 		window.mainScreenContext.setActiveRoom(previousRoomID!)
 		closeModal()
@@ -528,6 +602,7 @@ const SettingsView = ({ room }: SettingsViewProps) => {
 				{roomMeta.name && <div className="room-name">{roomMeta.name}</div>}
 				<code>{room.roomID}</code>
 				<div>{roomMeta.topic}</div>
+				{retentionText && <div className="room-retention">{retentionText}</div>}
 				<div className="room-buttons">
 					<button className="leave-room" onClick={onClickLeave}>Leave room</button>
 					<button className="devtools" onClick={openDevtools}>Explore room state</button>
@@ -570,6 +645,10 @@ const SettingsView = ({ room }: SettingsViewProps) => {
 		<hr/>
 		<KeyExportView room={room} />
 		<hr/>
+		{window.gomuksWebWasm && <>
+			<StorageView />
+			<hr/>
+		</>}
 		<div className="misc-buttons">
 			<button onClick={onClickOpenCSSApp}>Sign into css.gomuks.app</button>
 			{window.Notification && !window.gomuksAndroid && <button onClick={client.requestNotificationPermission}>
@@ -26,9 +26,10 @@ import "./EventContextModal.css"
 export interface EventContextModalProps {
 	roomCtx: RoomContextData
 	eventID: EventID
+	label?: string
 }
 
-const EventContextModal = ({ roomCtx, eventID }: EventContextModalProps) => {
+const EventContextModal = ({ roomCtx, eventID, label }: EventContextModalProps) => {
 	const client = use(ClientContext)!
 	const room = roomCtx.store
 	const [error, setError] = useState("loading")
@@ -112,6 +113,7 @@ const EventContextModal = ({ roomCtx, eventID }: EventContextModalProps) => {
 		</div>
 	}
 	const content = <div className="timeline-view" ref={viewRef}>
+		{label ? <h3 className="event-context-label">Messages around {label}</h3> : null}
 		<div className="timeline-edge">
 			{start ? <button onClick={loadStart} disabled={startLoading}>
 				{startLoading
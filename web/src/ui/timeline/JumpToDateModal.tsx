@@ -0,0 +1,59 @@
+// gomuks - A Matrix client written in Go.
+// Copyright (C) 2026 Tulir Asokan
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses/>.
+import { use, useState } from "react"
+import { parseDate } from "@/util/parsedate.ts"
+import ClientContext from "../ClientContext.ts"
+import ConfirmModal from "../modal/ConfirmModal.tsx"
+import { RoomContextData } from "../roomview/roomcontext.ts"
+import { jumpToDate } from "../util/jumpToEvent.tsx"
+
+export interface JumpToDateModalProps {
+	roomCtx: RoomContextData
+}
+
+const todayISO = () => {
+	const now = new Date()
+	return `${now.getFullYear()}-${String(now.getMonth() + 1).padStart(2, "0")}-${String(now.getDate()).padStart(2, "0")}`
+}
+
+const JumpToDateModal = ({ roomCtx }: JumpToDateModalProps) => {
+	const client = use(ClientContext)!
+	const [date, setDate] = useState(todayISO)
+	const onConfirm = (input: string) => {
+		const parsed = parseDate(input)
+		if (!parsed) {
+			return Promise.reject(new Error(`invalid date ${input}`))
+		}
+		return jumpToDate(client, roomCtx, parsed)
+	}
+	return <ConfirmModal<readonly [string]>
+		title="Jump to date"
+		description="View the messages sent around a specific day"
+		confirmButton="Jump"
+		onConfirm={onConfirm}
+		confirmArgs={[date]}
+	>
+		<input
+			type="date"
+			value={date}
+			max={todayISO()}
+			required
+			onChange={evt => setDate(evt.target.value)}
+		/>
+	</ConfirmModal>
+}
+
+export default JumpToDateModal
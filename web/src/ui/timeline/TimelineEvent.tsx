@@ -22,7 +22,7 @@ import {
 	maybeRedactMemberEvent,
 	useRoomMember,
 } from "@/api/statestore"
-import { MemDBEvent, URLPreview as URLPreviewType, UnreadType } from "@/api/types"
+import { EventID, MemDBEvent, URLPreview as URLPreviewType, UnreadType, UserID } from "@/api/types"
 import { displayAsRedacted } from "@/util/displayAsRedacted.ts"
 import { isMobileDevice } from "@/util/ismobile.ts"
 import { getDisplayname, getRelatesTo, isEventID } from "@/util/validation.ts"
@@ -70,28 +70,44 @@ const newSafeDate = (val: number) => {
 
 interface EventReactionsProps {
 	reactions: Record<string, number>
+	senders?: Record<string, Record<UserID, EventID>>
+	ownUserID: UserID
 	onRereact: (mouseEvt: React.MouseEvent) => void
 }
 
-const EventReactions = ({ reactions, onRereact }: EventReactionsProps) => {
+const EventReactions = ({ reactions, senders, ownUserID, onRereact }: EventReactionsProps) => {
 	const reactionEntries = Object.entries(reactions).filter(([, count]) => count > 0).sort((a, b) => b[1] - a[1])
 	if (reactionEntries.length === 0) {
 		return null
 	}
 	return <div className="event-reactions">
-		{reactionEntries.map(([reaction, count]) =>
-			<div key={reaction} className="reaction" title={reaction} onClick={onRereact}>
+		{reactionEntries.map(([reaction, count]) => {
+			const reactors = Object.keys(senders?.[reaction] ?? {})
+			const isOwn = reactors.includes(ownUserID)
+			return <div
+				key={reaction}
+				className={`reaction${isOwn ? " own-reaction" : ""}`}
+				title={reactors.length > 0 ? `${reaction} by ${reactors.join(", ")}` : reaction}
+				data-reaction-key={reaction}
+				onClick={onRereact}
+			>
 				{reaction.startsWith("mxc://")
 					? <img className="reaction-emoji" src={getMediaURL(reaction)} alt=""/>
 					: <span className="reaction-emoji">{reaction}</span>}
 				<span className="reaction-count">{count}</span>
-			</div>)}
+			</div>
+		})}
 	</div>
 }
 
 const EventSendStatus = ({ evt }: { evt: MemDBEvent }) => {
 	if (evt.send_error && evt.send_error !== "not sent") {
 		return <div className="event-send-status error" title={evt.send_error}><ErrorIcon/></div>
+	} else if (evt.queue_position) {
+		return <div
+			title={`Queued behind ${evt.queue_position} other event${evt.queue_position === 1 ? "" : "s"}`}
+			className="event-send-status sending"
+		><PendingIcon/></div>
 	} else if (evt.event_id.startsWith("~")) {
 		return <div title="Waiting for /send to return" className="event-send-status sending"><PendingIcon/></div>
 	} else if (evt.pending) {
@@ -144,11 +160,23 @@ const TimelineEvent = ({
 		})
 	}
 	const onRereact = useCallback((mouseEvt: React.MouseEvent) => {
+		const key = mouseEvt.currentTarget.getAttribute("data-reaction-key")
+		if (!key) {
+			return
+		}
+		const ownReaction = evt.reaction_senders?.[key]?.[client.userID]
+		if (ownReaction) {
+			client.rpc.redactEvent(evt.room_id, ownReaction, "").catch(err => {
+				console.error("Failed to remove reaction", err)
+				window.alert(`Failed to remove reaction: ${err}`)
+			})
+			return
+		}
 		client.sendEvent(evt.room_id, "m.reaction", {
 			"m.relates_to": {
 				rel_type: "m.annotation",
 				event_id: evt.event_id,
-				key: mouseEvt.currentTarget.getAttribute("title"),
+				key,
 			},
 		}).catch(err => {
 			console.error("Failed to send reaction", err)
@@ -365,7 +393,12 @@ const TimelineEvent = ({
 			isThread={true}
 			threadRoot={threadRoot}
 			timelineThreadMsg={true}
-			reactions={evt.reactions ? <EventReactions reactions={evt.reactions} onRereact={onRereact} /> : null}
+			reactions={evt.reactions ? <EventReactions
+				reactions={evt.reactions}
+				senders={evt.reaction_senders}
+				ownUserID={client.userID}
+				onRereact={onRereact}
+			/> : null}
 		/> : <div className="event-content">
 			{replyInMessage}
 			<ContentErrorBoundary>
@@ -379,7 +412,12 @@ const TimelineEvent = ({
 			>
 				(edited at {formatShortTime(editEventTS)})
 			</div> : null}
-			{evt.reactions ? <EventReactions reactions={evt.reactions} onRereact={onRereact} /> : null}
+			{evt.reactions ? <EventReactions
+				reactions={evt.reactions}
+				senders={evt.reaction_senders}
+				ownUserID={client.userID}
+				onRereact={onRereact}
+			/> : null}
 		</div>}
 		{!evt.event_id.startsWith("~")
 			&& roomCtx.store.preferences.display_read_receipts
@@ -0,0 +1,55 @@
+// gomuks - A Matrix client written in Go.
+// Copyright (C) 2026 Tulir Asokan
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses/>.
+import { use, useState } from "react"
+import { ScaleLoader } from "react-spinners"
+import { RoomID, TimelineRowID } from "@/api/types"
+import ClientContext from "../ClientContext.ts"
+import "./TimelineGap.css"
+
+interface TimelineGapProps {
+	roomID: RoomID
+	timelineRowID: TimelineRowID
+	missedCount?: number
+}
+
+const missedMessagesText = (count?: number) => {
+	if (!count) {
+		return "Missed messages — click to load"
+	} else if (count === 1) {
+		return "1 missed message — click to load"
+	}
+	return `${count} missed messages — click to load`
+}
+
+const TimelineGap = ({ roomID, timelineRowID, missedCount }: TimelineGapProps) => {
+	const client = use(ClientContext)!
+	const [loading, setLoading] = useState(false)
+	const fillGap = () => {
+		setLoading(true)
+		client.fillGap(roomID, timelineRowID)
+			.catch(err => console.error("Failed to fill timeline gap", err))
+			.finally(() => setLoading(false))
+	}
+	return <div className="timeline-gap">
+		<button onClick={fillGap} disabled={loading}>
+			{loading
+				? <><ScaleLoader color="var(--primary-color)"/> Loading missed messages...</>
+				: missedMessagesText(missedCount)}
+		</button>
+	</div>
+}
+
+export default TimelineGap
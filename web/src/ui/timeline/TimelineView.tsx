@@ -124,7 +124,7 @@ const TimelineView = () => {
 		</div>
 		<div className="timeline-list">
 			<div className="timeline-top-ref" ref={topRef}/>
-			{renderTimelineList("timeline", timeline, room.preferences, { focusedEventRowID })}
+			{renderTimelineList("timeline", timeline, room.preferences, { focusedEventRowID, gaps: room.gaps })}
 			<div className="timeline-bottom-ref" ref={bottomRef}/>
 		</div>
 	</div>
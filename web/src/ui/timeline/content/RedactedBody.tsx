@@ -27,11 +27,11 @@ const RedactedBody = ({ event, sender, room }: EventContentProps) => {
 			const redacterProfile = room.getStateEvent("m.room.member", redactedByEvent.sender)
 			suffix = `by ${getDisplayname(redactedByEvent.sender, redacterProfile?.content)}`
 		}
-	} else {
-		const senderContent = sender?.content as MemberEventContent | undefined
-		if (senderContent?.["org.matrix.msc4293.redact_events"]) {
-			suffix = "via ban event"
-		}
+	} else if (sender && (sender.content as MemberEventContent)["org.matrix.msc4293.redact_events"]) {
+		const bannerProfile = room.getStateEvent("m.room.member", sender.sender)
+		return <div className="redacted-body">
+			<DeleteIcon/> Message removed (user banned by {getDisplayname(sender.sender, bannerProfile?.content)})
+		</div>
 	}
 	return <div className="redacted-body">
 		<DeleteIcon/> Message deleted {suffix}
@@ -13,15 +13,17 @@
 //
 // You should have received a copy of the GNU Affero General Public License
 // along with this program.  If not, see <https://www.gnu.org/licenses/>.
-import { JSX } from "react"
-import { MemDBEvent, MemberEventContent } from "@/api/types"
+import { Fragment, JSX } from "react"
+import { MemDBEvent, MemberEventContent, TimelineGap as TimelineGapInfo, TimelineRowID } from "@/api/types"
 import { Preferences } from "@/api/types/preferences"
 import TimelineEvent, { TimelineEventViewType } from "./TimelineEvent.tsx"
+import TimelineGap from "./TimelineGap.tsx"
 import { HiddenEvent, getBodyType } from "./content"
 
 interface renderTimelineListParams {
 	focusedEventRowID?: number | null
 	prevEventOverride?: MemDBEvent
+	gaps?: Map<TimelineRowID, TimelineGapInfo>
 }
 
 function isHiddenEvent(entry: MemDBEvent): boolean {
@@ -68,7 +70,7 @@ export function renderTimelineList(
 	viewType: TimelineEventViewType,
 	timeline: (MemDBEvent | null)[],
 	prefs: Preferences,
-	{ focusedEventRowID, prevEventOverride }: renderTimelineListParams = {},
+	{ focusedEventRowID, prevEventOverride, gaps }: renderTimelineListParams = {},
 ): (JSX.Element | null)[] {
 	let prevEvt: MemDBEvent | null = prevEventOverride ?? null
 	let receiptMergeIdx: number | null = null
@@ -84,7 +86,22 @@ export function renderTimelineList(
 	return timeline.map(entry => {
 		if (!entry) {
 			return null
-		} else if (shouldHide(entry, flattenedPrefs)) {
+		}
+		const gapInfo = gaps?.get(entry.timeline_rowid)
+		const gap = gapInfo
+			? <TimelineGap
+				key={`gap-${entry.timeline_rowid}`}
+				roomID={entry.room_id}
+				timelineRowID={entry.timeline_rowid}
+				missedCount={gapInfo.missed_count}
+			/>
+			: null
+		if (gap) {
+			// Don't merge events across gaps
+			prevEvt = null
+			receiptMergeIdx = null
+		}
+		if (shouldHide(entry, flattenedPrefs)) {
 			if (prevEvt && viewType === "timeline" && flattenedPrefs.display_read_receipts) {
 				// Completely pointless optimization to avoid recreating the receipt_flattening array on every render
 				if (!prevEvt.receipt_flattening) {
@@ -100,7 +117,7 @@ export function renderTimelineList(
 					receiptMergeIdx = null
 				}
 			}
-			return null
+			return gap
 		}
 		if (
 			prevEvt?.receipt_flattening
@@ -120,6 +137,6 @@ export function renderTimelineList(
 		/>
 		prevEvt = entry
 		receiptMergeIdx = 0
-		return thisEvt
+		return gap ? <Fragment key={entry.rowid}>{gap}{thisEvt}</Fragment> : thisEvt
 	})
 }
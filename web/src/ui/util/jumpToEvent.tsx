@@ -13,6 +13,7 @@
 //
 // You should have received a copy of the GNU Affero General Public License
 // along with this program.  If not, see <https://www.gnu.org/licenses/>.
+import Client from "@/api/client.ts"
 import { EventID } from "@/api/types"
 import { modals } from "../modal"
 import { RoomContextData } from "../roomview/roomcontext.ts"
@@ -60,3 +61,10 @@ export const jumpToEventInView = (roomCtx: RoomContextData, evtID: EventID, pare
 		window.openNestableModal(modals.eventContext(roomCtx, evtID))
 	}
 }
+
+const dateLabelFormatter = new Intl.DateTimeFormat("en-GB", { dateStyle: "full" })
+
+export const jumpToDate = async (client: Client, roomCtx: RoomContextData, date: Date) => {
+	const res = await client.rpc.getEventNearTimestamp(roomCtx.store.roomID, date.getTime(), "f")
+	window.openNestableModal(modals.eventContext(roomCtx, res.event_id, dateLabelFormatter.format(date)))
+}
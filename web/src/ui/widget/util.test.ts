@@ -0,0 +1,38 @@
+import { expect, suite, test } from "vitest"
+import { makeWidgetHostURL } from "./util.ts"
+
+const gomuksOrigin = "https://gomuks.example.com"
+const roomID = "!room:example.com"
+
+suite("makeWidgetHostURL", () => {
+	test("fills template variables", () => {
+		const hostURL = makeWidgetHostURL(
+			"https://widgets.example.com/?room=$matrix_room_id&user=$matrix_user_id&other=$unknown",
+			roomID, "widget", { matrix_room_id: roomID, matrix_user_id: "@user:example.com" }, gomuksOrigin,
+		)
+		expect(hostURL.startsWith("_gomuks/widget/host?")).toBe(true)
+		const params = new URLSearchParams(hostURL.slice(hostURL.indexOf("?")))
+		expect(params.get("room_id")).toBe(roomID)
+		expect(params.get("widget_id")).toBe("widget")
+		expect(params.get("url")).toBe(
+			"https://widgets.example.com/?room=!room%3Aexample.com&user=%40user%3Aexample.com&other=$unknown",
+		)
+	})
+
+	test("allows http", () => {
+		expect(() => makeWidgetHostURL("http://widgets.example.com/", roomID, "widget", {}, gomuksOrigin))
+			.not.toThrow()
+	})
+
+	for (const url of [
+		"javascript:alert(document.cookie)",
+		"data:text/html,<script>alert(1)</script>",
+		"file:///etc/passwd",
+		"https://gomuks.example.com/_gomuks/media",
+		"not a url",
+	]) {
+		test(`rejects ${url}`, () => {
+			expect(() => makeWidgetHostURL(url, roomID, "widget", {}, gomuksOrigin)).toThrow()
+		})
+	}
+})
@@ -15,7 +15,7 @@
 // along with this program.  If not, see <https://www.gnu.org/licenses/>.
 import type { IRoomEvent } from "matrix-widget-api"
 import type { RoomStateStore } from "@/api/statestore"
-import type { MemDBEvent } from "@/api/types"
+import type { MemDBEvent, RoomID } from "@/api/types"
 
 export function isRecord(value: unknown): value is Record<string, unknown> {
 	return typeof value === "object" && value !== null
@@ -54,3 +54,24 @@ export function filterEvent(eventType: string, msgtype: string | undefined, stat
 		&& (!msgtype || evt.content.msgtype === msgtype)
 		&& (!stateKey || evt.state_key === stateKey)
 }
+
+// Builds the URL of the backend-hosted widget page, which embeds the widget and enforces its capabilities
+// on the server side. Template variables like $matrix_room_id in the widget URL are replaced using vars.
+// Throws if the widget URL isn't http(s) or is on the same origin as gomuks.
+export function makeWidgetHostURL(
+	widgetURL: string, roomID: RoomID, widgetID: string, vars: Record<string, string>,
+	gomuksOrigin: string = location.origin,
+): string {
+	const filledURL = widgetURL.replace(
+		/\$([a-zA-Z0-9_.]+)/g,
+		(match, name: string) => encodeURIComponent(vars[name] ?? match),
+	)
+	const parsedURL = new URL(filledURL)
+	if (parsedURL.protocol !== "http:" && parsedURL.protocol !== "https:") {
+		throw new Error(`Unsupported widget URL scheme ${parsedURL.protocol}`)
+	} else if (parsedURL.origin === gomuksOrigin) {
+		throw new Error("Widget URL must not be on the same origin as gomuks")
+	}
+	const params = new URLSearchParams({ room_id: roomID, widget_id: widgetID, url: filledURL })
+	return `_gomuks/widget/host?${params}`
+}
@@ -27,6 +27,8 @@ export function displayAsRedacted(
 		return false
 	} else if (evt.redacted_by) {
 		return true
+	} else if (room?.preferences.show_ban_removed_messages) {
+		return false
 	} else if (profile?.["org.matrix.msc4293.redact_events"] && profile.membership === "ban") {
 		if (memberEvt && room) {
 			// It would be more proper to pass the power levels as a parameter so it can use useRoomState,
@@ -0,0 +1,46 @@
+// gomuks - A Matrix client written in Go.
+// Copyright (C) 2026 Tulir Asokan
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses/>.
+const weekdays = ["sunday", "monday", "tuesday", "wednesday", "thursday", "friday", "saturday"]
+
+const startOfDay = (date: Date) => new Date(date.getFullYear(), date.getMonth(), date.getDate())
+
+// parseDate parses a date typed by the user. ISO dates (2025-03-03), "today", "yesterday" and weekdays
+// ("monday" or "last monday", both meaning the most recent one before today) are supported.
+// The returned date is the start of the day in local time, or null if the input isn't a valid date.
+export function parseDate(input: string, now: Date = new Date()): Date | null {
+	input = input.trim().toLowerCase()
+	const today = startOfDay(now)
+	if (input === "today") {
+		return today
+	} else if (input === "yesterday") {
+		return new Date(today.getFullYear(), today.getMonth(), today.getDate() - 1)
+	}
+	const weekday = weekdays.indexOf(input.replace(/^last\s+/, ""))
+	if (weekday !== -1) {
+		const daysAgo = (today.getDay() - weekday + 7) % 7 || 7
+		return new Date(today.getFullYear(), today.getMonth(), today.getDate() - daysAgo)
+	}
+	const match = /^(\d{4})-(\d{2})-(\d{2})$/.exec(input)
+	if (!match) {
+		return null
+	}
+	const [year, month, day] = [+match[1], +match[2] - 1, +match[3]]
+	const date = new Date(year, month, day)
+	if (date.getFullYear() !== year || date.getMonth() !== month || date.getDate() !== day) {
+		return null
+	}
+	return date
+}